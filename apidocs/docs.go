@@ -19,6 +19,103 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/autorestart": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns whether the automatic restarts of every instance are paused, since when and until when",
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Get the global pause of automatic restarts",
+                "responses": {
+                    "200": {
+                        "description": "Global pause",
+                        "schema": {
+                            "$ref": "#/definitions/server.AutoRestartPauseStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/autorestart/pause": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Keeps the instances running, but any backend that crashes stays down instead of being restarted, for duration or until resumed when no duration is given. The pause holds on top of the pauses of the instances and survives a restart of llamactl.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Pause the automatic restarts of every instance",
+                "parameters": [
+                    {
+                        "description": "Duration of the pause",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/server.AutoRestartPauseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Global pause",
+                        "schema": {
+                            "$ref": "#/definitions/server.AutoRestartPauseStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/autorestart/resume": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lifts the global pause of automatic restarts. Instances paused on their own stay paused.",
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Resume the automatic restarts of every instance",
+                "responses": {
+                    "200": {
+                        "description": "Global pause",
+                        "schema": {
+                            "$ref": "#/definitions/server.AutoRestartPauseStatus"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/backends/llama-cpp/devices": {
             "get": {
                 "security": [
@@ -256,26 +353,23 @@ const docTemplate = `{
                 }
             }
         },
-        "/instances": {
+        "/config": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns a list of all instances managed by the server",
+                "description": "Returns every instances setting llamactl runs with, after the configuration file, environment variables and runtime overrides are applied. Each setting has its value, its source (default, file, env or override) and whether it can be changed at runtime, with the reason when it cannot. Secrets are redacted.",
                 "tags": [
-                    "instances"
+                    "system"
                 ],
-                "summary": "List all instances",
+                "summary": "Get the effective configuration",
                 "responses": {
                     "200": {
-                        "description": "List of instances",
+                        "description": "Effective configuration",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/instance.Process"
-                            }
+                            "$ref": "#/definitions/server.ConfigResponse"
                         }
                     },
                     "500": {
@@ -285,38 +379,41 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/instances/{name}": {
-            "get": {
+            },
+            "patch": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns the details of a specific instance by name",
+                "description": "Changes the instances settings of the body, keyed like the configuration file, without a restart. Only settings read whenever they are used can be changed, others are rejected with the reason. Changes are validated, recorded in the audit log and saved to config_overrides.yaml in the data directory, which is applied over the configuration file and environment variables on startup.",
+                "consumes": [
+                    "application/json"
+                ],
                 "tags": [
-                    "instances"
+                    "system"
                 ],
-                "summary": "Get details of a specific instance",
+                "summary": "Change settings at runtime",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Instance Name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
+                        "description": "Settings to change, e.g. {\\",
+                        "name": "settings",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Instance details",
+                        "description": "Effective configuration",
                         "schema": {
-                            "$ref": "#/definitions/instance.Process"
+                            "$ref": "#/definitions/server.ConfigResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid name format",
+                        "description": "Invalid or read-only setting",
                         "schema": {
                             "type": "string"
                         }
@@ -328,50 +425,25 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/debug/goroutines": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Updates the configuration of a specific instance by name",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Returns runtime.NumGoroutine alongside the goroutines started and stopped on behalf of each instance",
                 "tags": [
-                    "instances"
-                ],
-                "summary": "Update an instance's configuration",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Instance Name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Instance configuration options",
-                        "name": "options",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/instance.CreateInstanceOptions"
-                        }
-                    }
+                    "debug"
                 ],
+                "summary": "Get goroutine usage",
                 "responses": {
                     "200": {
-                        "description": "Updated instance details",
-                        "schema": {
-                            "$ref": "#/definitions/instance.Process"
-                        }
-                    },
-                    "400": {
-                        "description": "Invalid name format",
+                        "description": "Goroutine usage",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.GoroutinesResponse"
                         }
                     },
                     "500": {
@@ -381,50 +453,25 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
+            }
+        },
+        "/debug/memory": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Creates a new instance with the provided configuration options",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Returns the approximate bytes held by the output tails and replayed events of each instance, the limits they are held to and the entries evicted to enforce them, alongside the Go runtime memory statistics",
                 "tags": [
-                    "instances"
-                ],
-                "summary": "Create and start a new instance",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Instance Name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Instance configuration options",
-                        "name": "options",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/instance.CreateInstanceOptions"
-                        }
-                    }
+                    "debug"
                 ],
+                "summary": "Get memory usage",
                 "responses": {
-                    "201": {
-                        "description": "Created instance details",
-                        "schema": {
-                            "$ref": "#/definitions/instance.Process"
-                        }
-                    },
-                    "400": {
-                        "description": "Invalid request body",
+                    "200": {
+                        "description": "Memory usage",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.MemoryResponse"
                         }
                     },
                     "500": {
@@ -434,39 +481,65 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/drift": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Stops and removes a specific instance by name",
+                "description": "Compares every instance as /instances/{name}/drift does, listing those with any discrepancy, so that reconciling restarts can be planned",
                 "tags": [
                     "instances"
                 ],
-                "summary": "Delete an instance",
+                "summary": "List the instances whose definition differs from their runtime state",
+                "responses": {
+                    "200": {
+                        "description": "Instances with drift",
+                        "schema": {
+                            "$ref": "#/definitions/manager.DriftSummary"
+                        }
+                    }
+                }
+            }
+        },
+        "/events": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Streams instance lifecycle events as Server-Sent Events. Each event carries a stable reason code and a human readable message. A client reconnecting with the Last-Event-ID header first gets the events it missed that are still kept for replay.",
+                "tags": [
+                    "events"
+                ],
+                "summary": "Stream instance events",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Instance Name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
+                        "description": "Comma-separated list of instance names to filter on",
+                        "name": "instance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the last event received, to resume after",
+                        "name": "Last-Event-ID",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
-                    },
-                    "400": {
-                        "description": "Invalid name format",
+                    "200": {
+                        "description": "Stream of events",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/events.Event"
                         }
                     },
                     "500": {
-                        "description": "Internal Server Error",
+                        "description": "Streaming not supported",
                         "schema": {
                             "type": "string"
                         }
@@ -474,42 +547,44 @@ const docTemplate = `{
                 }
             }
         },
-        "/instances/{name}/logs": {
-            "get": {
+        "/fleet/apply": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns the logs from a specific instance by name with optional line limit",
+                "description": "Reconciles the instances with the YAML fleet file in the request body: missing instances are created, drifted options updated, and instances started or stopped to match their desired state. With prune=true, instances not listed in the file are stopped and deleted. With dry_run=true, the plan is returned without changing anything.",
+                "consumes": [
+                    "application/yaml"
+                ],
                 "tags": [
-                    "instances"
+                    "fleet"
                 ],
-                "summary": "Get logs from a specific instance",
+                "summary": "Apply a fleet file",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Instance Name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
+                        "type": "boolean",
+                        "description": "Only report the plan",
+                        "name": "dry_run",
+                        "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "Number of lines to retrieve (default: all lines)",
-                        "name": "lines",
+                        "type": "boolean",
+                        "description": "Delete instances not listed in the file",
+                        "name": "prune",
                         "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Instance logs",
+                        "description": "Applied or planned actions",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/manager.FleetPlan"
                         }
                     },
                     "400": {
-                        "description": "Invalid name format or lines parameter",
+                        "description": "Invalid fleet file",
                         "schema": {
                             "type": "string"
                         }
@@ -523,62 +598,145 @@ const docTemplate = `{
                 }
             }
         },
-        "/instances/{name}/proxy": {
+        "/fleet/status": {
             "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Forwards HTTP requests to the llama-server instance running on a specific port",
+                "description": "Returns the plan of the most recent reconciliation against the configured fleet source, and the error of the most recent one if it failed",
                 "tags": [
-                    "instances"
-                ],
-                "summary": "Proxy requests to a specific instance",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Instance Name",
-                        "name": "name",
-                        "in": "path",
-                        "required": true
-                    }
+                    "fleet"
                 ],
+                "summary": "Get the fleet watch mode status",
                 "responses": {
                     "200": {
-                        "description": "Request successfully proxied to instance"
+                        "description": "Fleet watch mode status",
+                        "schema": {
+                            "$ref": "#/definitions/server.FleetStatus"
+                        }
                     },
-                    "400": {
-                        "description": "Invalid name format",
+                    "404": {
+                        "description": "Fleet watch mode is not enabled",
                         "schema": {
                             "type": "string"
                         }
-                    },
+                    }
+                }
+            }
+        },
+        "/instances": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a list of all instances managed by the server. With verbose=true each instance includes its proxy and admission queue stats.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "List all instances",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Include stats",
+                        "name": "verbose",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of instances",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/instance.Process"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
                             "type": "string"
                         }
-                    },
-                    "503": {
-                        "description": "Instance is not running",
+                    }
+                }
+            }
+        },
+        "/instances/": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns an HTML page linking to the web UI of every llama.cpp instance",
+                "tags": [
+                    "ui"
+                ],
+                "summary": "List the instance web UIs",
+                "responses": {
+                    "200": {
+                        "description": "Index page",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/instances/login": {
             "post": {
+                "description": "Checks the API key of the login form and keeps it in an HttpOnly cookie scoped to /instances/, then redirects back to the page that required it",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "tags": [
+                    "ui"
+                ],
+                "summary": "Log in to the instance web UIs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Inference or management API key",
+                        "name": "api_key",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Page to return to",
+                        "name": "redirect",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "303": {
+                        "description": "Redirect to the page that required the login"
+                    },
+                    "401": {
+                        "description": "Invalid API key",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}": {
+            "get": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Forwards HTTP requests to the llama-server instance running on a specific port",
+                "description": "Returns the details of a specific instance by name",
                 "tags": [
                     "instances"
                 ],
-                "summary": "Proxy requests to a specific instance",
+                "summary": "Get details of a specific instance",
                 "parameters": [
                     {
                         "type": "string",
@@ -590,7 +748,10 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Request successfully proxied to instance"
+                        "description": "Instance details",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
                     },
                     "400": {
                         "description": "Invalid name format",
@@ -603,28 +764,23 @@ const docTemplate = `{
                         "schema": {
                             "type": "string"
                         }
-                    },
-                    "503": {
-                        "description": "Instance is not running",
-                        "schema": {
-                            "type": "string"
-                        }
                     }
                 }
-            }
-        },
-        "/instances/{name}/restart": {
-            "post": {
+            },
+            "put": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Restarts a specific instance by name",
+                "description": "Updates the configuration of a specific instance by name",
+                "consumes": [
+                    "application/json"
+                ],
                 "tags": [
                     "instances"
                 ],
-                "summary": "Restart a running instance",
+                "summary": "Update an instance's configuration",
                 "parameters": [
                     {
                         "type": "string",
@@ -632,17 +788,38 @@ const docTemplate = `{
                         "name": "name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Instance configuration options",
+                        "name": "options",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/instance.CreateInstanceOptions"
+                        }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Restart a running instance to apply the options now rather than on its next start",
+                        "name": "restart",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Restarted instance details",
+                        "description": "Updated instance details",
                         "schema": {
                             "$ref": "#/definitions/instance.Process"
                         }
                     },
                     "400": {
-                        "description": "Invalid name format",
+                        "description": "Invalid instance options",
+                        "schema": {
+                            "$ref": "#/definitions/server.ValidationErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Config-managed instance",
                         "schema": {
                             "type": "string"
                         }
@@ -654,20 +831,21 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/instances/{name}/start": {
+            },
             "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Starts a specific instance by name",
+                "description": "Creates a new instance with the provided configuration options",
+                "consumes": [
+                    "application/json"
+                ],
                 "tags": [
                     "instances"
                 ],
-                "summary": "Start a stopped instance",
+                "summary": "Create and start a new instance",
                 "parameters": [
                     {
                         "type": "string",
@@ -675,19 +853,28 @@ const docTemplate = `{
                         "name": "name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "Instance configuration options",
+                        "name": "options",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/instance.CreateInstanceOptions"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Started instance details",
+                    "201": {
+                        "description": "Created instance details",
                         "schema": {
                             "$ref": "#/definitions/instance.Process"
                         }
                     },
                     "400": {
-                        "description": "Invalid name format",
+                        "description": "Invalid instance options",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/server.ValidationErrorResponse"
                         }
                     },
                     "500": {
@@ -697,20 +884,18 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/instances/{name}/stop": {
-            "post": {
+            },
+            "delete": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Stops a specific instance by name",
+                "description": "Removes a stopped instance by name, moving its definition to the trash until the trash retention runs out unless permanent is set or the trash is disabled",
                 "tags": [
                     "instances"
                 ],
-                "summary": "Stop a running instance",
+                "summary": "Delete an instance",
                 "parameters": [
                     {
                         "type": "string",
@@ -718,14 +903,23 @@ const docTemplate = `{
                         "name": "name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove the definition right away rather than moving it to the trash",
+                        "name": "permanent",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Remove the log files too once the definition is purged",
+                        "name": "purge_logs",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Stopped instance details",
-                        "schema": {
-                            "$ref": "#/definitions/instance.Process"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Invalid name format",
@@ -733,6 +927,12 @@ const docTemplate = `{
                             "type": "string"
                         }
                     },
+                    "403": {
+                        "description": "Protected config-managed instance",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -742,27 +942,54 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/": {
-            "post": {
+        "/instances/{name}/allowed-paths": {
+            "put": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Handles all POST requests to /v1/*, routing to the appropriate instance based on the request body. Requires API key authentication via the ` + "`" + `Authorization` + "`" + ` header.",
+                "description": "Replaces the paths the proxy forwards to the backend of an instance, as prefixes or glob patterns, without restarting it. Requests for other paths get 404 Not Found. No paths allow every path.",
                 "consumes": [
                     "application/json"
                 ],
                 "tags": [
-                    "openai"
+                    "instances"
+                ],
+                "summary": "Update the allowed paths of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Allowed paths",
+                        "name": "paths",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.AllowedPathsRequest"
+                        }
+                    }
                 ],
-                "summary": "OpenAI-compatible proxy endpoint",
                 "responses": {
                     "200": {
-                        "description": "OpenAI response"
+                        "description": "Effective allowed paths",
+                        "schema": {
+                            "$ref": "#/definitions/server.AllowedPathsRequest"
+                        }
                     },
                     "400": {
-                        "description": "Invalid request body or instance name",
+                        "description": "Invalid paths",
+                        "schema": {
+                            "$ref": "#/definitions/server.ValidationErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Config-managed instance",
                         "schema": {
                             "type": "string"
                         }
@@ -776,23 +1003,50 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/models": {
-            "get": {
+        "/instances/{name}/apply-options": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns a list of instances in a format compatible with OpenAI API",
+                "description": "Restarts a running instance whose options were updated while it was running, so they take effect right away. An instance without pending options is returned unchanged.",
                 "tags": [
-                    "openai"
+                    "instances"
+                ],
+                "summary": "Apply the pending options of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for an operation in progress instead of failing",
+                        "name": "queue",
+                        "in": "query"
+                    }
                 ],
-                "summary": "List instances in OpenAI-compatible format",
                 "responses": {
                     "200": {
-                        "description": "List of OpenAI-compatible instances",
+                        "description": "Instance details",
                         "schema": {
-                            "$ref": "#/definitions/server.OpenAIListInstancesResponse"
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another operation is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/instance.OperationInProgressError"
                         }
                     },
                     "500": {
@@ -804,23 +1058,233 @@ const docTemplate = `{
                 }
             }
         },
-        "/version": {
-            "get": {
+        "/instances/{name}/autorestart/pause": {
+            "post": {
                 "security": [
                     {
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Returns the version of the llamactl command",
+                "description": "Keeps the instance running, but if its backend crashes it stays down instead of being restarted, for duration or until resumed when no duration is given. The restart options are left untouched, and the pause survives a restart of llamactl.",
+                "consumes": [
+                    "application/json"
+                ],
                 "tags": [
-                    "version"
+                    "instances"
+                ],
+                "summary": "Pause the automatic restarts of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Duration of the pause",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/server.AutoRestartPauseRequest"
+                        }
+                    }
                 ],
-                "summary": "Get llamactl version",
                 "responses": {
                     "200": {
-                        "description": "Version information",
+                        "description": "Instance with its restarts paused",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or unmanaged instance",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/autorestart/resume": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Lifts the pause of the automatic restarts of an instance. A backend that crashed while paused is not started. Restarts stay paused while they are paused globally.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Resume the automatic restarts of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Instance",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/cancel-start": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Aborts the start of an instance that is loading its model, queued for a loading slot or waiting to be restarted. The backend is killed right away and no automatic restart follows. Unlike a stop, the cancellation does not wait for a start operation in progress, e.g. one queued for a loading slot, which fails instead.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Cancel the start of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stopped instance details",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Instance is not starting, or not managed by llamactl",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/command": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the command, arguments and launch wrapper an instance is started with, and the full argument vector that is executed",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Preview the command line of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Command preview",
+                        "schema": {
+                            "$ref": "#/definitions/instance.CommandPreview"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Instance is not managed by llamactl",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/drift": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Compares the persisted definition of an instance, its options in memory, its definition in the configuration file for a static instance and, where the platform tells, the command line of its running process, listing every field that differs with its values",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Compare the definition of an instance with its runtime state",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Drift of the instance",
+                        "schema": {
+                            "$ref": "#/definitions/manager.InstanceDrift"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
                         }
                     },
                     "500": {
@@ -831,83 +1295,4697 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "backends.BackendType": {
-            "type": "string",
-            "enum": [
-                "llama_cpp",
-                "mlx_lm",
-                "vllm"
-            ],
-            "x-enum-varnames": [
-                "BackendTypeLlamaCpp",
-                "BackendTypeMlxLm",
-                "BackendTypeVllm"
-            ]
         },
-        "instance.CreateInstanceOptions": {
+        "/instances/{name}/generate": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Renders a prompt template of an instance with the given variables and forwards the prompt to the completion endpoint of its backend (/completion for llama.cpp, /v1/completions otherwise), with params. The response of the backend, streamed when stream is set, is returned as is. Requests are admitted and started on demand like proxied requests.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Complete a prompt template",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Template, variables and completion parameters",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.GenerateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Completion of the backend",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request, or a variable of the template is missing",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Template not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Instance is not running",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/last-exit": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the exit code or signal, reason, time and last lines of output of the most recent crash of the backend. It is kept across restarts until the next crash.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Get the last crash of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Last crash",
+                        "schema": {
+                            "$ref": "#/definitions/instance.LastExit"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "The instance never crashed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/lb-health": {
+            "get": {
+                "description": "Reports whether an instance should get more work, from its cached state only: 200 when it is ready and its queue is below its lb_health thresholds, 429 while it drains its requests before a recycle, and 503 otherwise. Needs no API key; lb_health_allowed_networks restricts the clients allowed.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Get the health of an instance for load balancers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Healthy",
+                        "schema": {
+                            "$ref": "#/definitions/instance.LBHealth"
+                        }
+                    },
+                    "403": {
+                        "description": "Client not in lb_health_allowed_networks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Instance not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Draining",
+                        "schema": {
+                            "$ref": "#/definitions/instance.LBHealth"
+                        }
+                    },
+                    "503": {
+                        "description": "Unhealthy",
+                        "schema": {
+                            "$ref": "#/definitions/instance.LBHealth"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/logs": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the logs from a specific instance by name with optional line limit, as plain text, JSON lines or an auto-refreshing HTML page. The format is chosen by the format parameter, or else by the Accept header. With follow, lines are streamed as they are logged.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Get logs from a specific instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Number of lines to retrieve (default: all lines, 1000 for html, 10 when following)",
+                        "name": "lines",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Output format: text, ndjson or html (default: from the Accept header, else text)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Keep the response open and stream new lines (text and ndjson only)",
+                        "name": "follow",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only lines logged at or after this RFC 3339 time (ndjson only)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only lines logged at or before this RFC 3339 time (ndjson only)",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Most recent bytes of logs to return, up to log_response_max_size_mb (default: log_response_size_mb)",
+                        "name": "max_bytes",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Instance logs, the last of them with X-Logs-Truncated if they exceed max_bytes",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format, lines, format, follow, since, until or max_bytes parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Instance is not managed by llamactl",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/logs/files": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the current and rotated log files of an instance with their sizes and ages",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "List log files of a specific instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Instance log files",
+                        "schema": {
+                            "$ref": "#/definitions/server.InstanceLogFilesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/operations": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the lifecycle operation in progress on an instance, the operations waiting their turn, and recent operations with their outcome",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Get lifecycle operations of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Operations",
+                        "schema": {
+                            "$ref": "#/definitions/instance.OperationsSnapshot"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/options/effective": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the options an instance runs with after merging global defaults and its own options. With explain=true every field is returned with its value and source (default, template:\u003cname\u003e, explicit or inferred).",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Get the effective options of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Report the source of every field",
+                        "name": "explain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the options the next start uses, including those updated while running",
+                        "name": "pending",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Effective options with their sources",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "$ref": "#/definitions/instance.EffectiveOption"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/proxy": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Forwards HTTP requests to the llama-server instance running on a specific port",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Proxy requests to a specific instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Request successfully proxied to instance"
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Instance is not running",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Forwards HTTP requests to the llama-server instance running on a specific port",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Proxy requests to a specific instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Request successfully proxied to instance"
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Instance is not running",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/queue": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the number of active and queued requests for an instance, broken down by priority",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Get admission queue stats for an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Admission queue stats",
+                        "schema": {
+                            "$ref": "#/definitions/instance.QueueStats"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/reload": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Starts a second backend for a running instance on a free port, with the options of its next start, and switches the proxy over to it once it passes its readiness check. The previous backend is stopped once its requests in flight completed. When the new backend does not become ready it is killed and the previous one keeps serving.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Reload an instance without downtime",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for an operation in progress instead of failing",
+                        "name": "queue",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reloaded instance details",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another operation is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/instance.OperationInProgressError"
+                        }
+                    },
+                    "500": {
+                        "description": "The new backend did not become ready, the previous one keeps serving",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/requests": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the proxied requests an instance is serving, oldest first, with their age. Request bodies are not recorded.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "List the in-flight requests of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "In-flight requests",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/instance.InFlightRequest"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/requests/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Cancels a proxied request, aborting its backend request. A client still waiting for the response headers receives 503 Service Unavailable.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Cancel an in-flight request of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Request cancelled"
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Request not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/restart": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stops a specific instance by name, cancelling any pending automatic restart, and starts it again once its port is free. A stopped instance is started. With if_binary_changed, only a running instance whose backend binary changed since its process was launched, by checksum, is restarted, and the response tells whether it was.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Restart an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for an operation in progress instead of failing",
+                        "name": "queue",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only restart if the backend binary changed since the process was launched",
+                        "name": "if_binary_changed",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "With if_binary_changed: whether the instance was restarted, and the instance",
+                        "schema": {
+                            "$ref": "#/definitions/manager.BinaryRestart"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another operation is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/instance.OperationInProgressError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/retry": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Starts an instance that failed, e.g. after exhausting its restart attempts, with its restart counter reset. The last error stays available as last_error.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Retry a failed instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for an operation in progress instead of failing",
+                        "name": "queue",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Started instance details",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another operation is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/instance.OperationInProgressError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/rotate-backend-key": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces the API key llamactl generated for the backend of an instance with managed_backend_key. A stopped instance gets the new key from its next start. A running instance is answered with 202 and recycled in the background once its in-flight requests finished, until then it keeps being reached with its current key. The key itself is never returned.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Rotate the managed backend key of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Key rotated",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "202": {
+                        "description": "Key rotated, instance recycling",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Instance does not use a managed backend key",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/signal": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Sends a signal, such as TERM, KILL, HUP or USR1, to the backend of a running instance, to its whole process group on Linux and macOS. A backend the signal makes exit is handled as any other exit, and restarted per its policy. Only KILL can be sent on Windows.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Send a signal to the backend of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Signal to send",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.SignalRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Instance details",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request or unsupported signal",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Instance is not running, or not managed by llamactl",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/slo": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Evaluates the service level objectives of an instance over their window and returns its compliance, burn rate and whether it is degraded. Probe requests and requests completed during warm-up are not counted.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Get the SLO compliance of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SLO compliance",
+                        "schema": {
+                            "$ref": "#/definitions/instance.SLOStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Instance has no service level objectives",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces the service level objectives of an instance without restarting it and returns its compliance with the new objectives. Objectives without a latency or error rate objective remove the SLO.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Update the SLO of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Service level objectives",
+                        "name": "objectives",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/instance.SLOOptions"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SLO compliance",
+                        "schema": {
+                            "$ref": "#/definitions/instance.SLOStatus"
+                        }
+                    },
+                    "204": {
+                        "description": "SLO removed"
+                    },
+                    "400": {
+                        "description": "Invalid objectives",
+                        "schema": {
+                            "$ref": "#/definitions/server.ValidationErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Config-managed instance",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/start": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Starts a specific instance by name",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Start a stopped instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for an operation in progress instead of failing",
+                        "name": "queue",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for the instance to become healthy",
+                        "name": "wait",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Started instance details",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another operation is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/instance.OperationInProgressError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "The instance did not become healthy within its readiness timeout",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/stats": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns request, error, in-flight and latency stats for requests proxied to an instance",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Get proxy stats for an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Proxy stats",
+                        "schema": {
+                            "$ref": "#/definitions/instance.StatsSnapshot"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/stop": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stops a specific instance by name. When require_stop_confirmation is enabled, a disruptive stop is refused unless confirm=true is passed.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Stop a running instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Wait for an operation in progress instead of failing",
+                        "name": "queue",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Confirm a disruptive stop",
+                        "name": "confirm",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stopped instance details",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Another operation is in progress",
+                        "schema": {
+                            "$ref": "#/definitions/instance.OperationInProgressError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/stop-impact": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Dry run of a stop: reports in-flight and queued requests, the services routing to the instance, whether their other members can absorb its traffic, and the services depending on them, with a safe or disruptive verdict",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Report the impact of stopping an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stop impact report",
+                        "schema": {
+                            "$ref": "#/definitions/manager.StopImpact"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Instance not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/templates": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the prompt templates of an instance, sorted by name. Templates are returned as stored, they are never redacted.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "List the prompt templates of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Prompt templates",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.PromptTemplate"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid name format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/templates/{template}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Stores a Go text/template prompt with the options of an instance, without restarting it. Variables are referred to by a dot and their name, e.g. .name in an action. Templates are limited to 16 KiB, and to 64 per instance. They are stored and returned in clear, so they must not contain secrets.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Add or replace a prompt template of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Template Name",
+                        "name": "template",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Template, its name is taken from the path",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.PromptTemplate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stored template",
+                        "schema": {
+                            "$ref": "#/definitions/server.PromptTemplate"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid template",
+                        "schema": {
+                            "$ref": "#/definitions/server.ValidationErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Config-managed instance",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Removes a prompt template of an instance, without restarting it",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Delete a prompt template of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Template Name",
+                        "name": "template",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Config-managed instance",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Template not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/ui/": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Proxies the llama-server web UI of an instance under /instances/{name}/ui/, including its SSE and WebSocket streams. HTML pages get a base href so their relative assets load through the proxy, and a bar to switch between instances. A stopped instance shows a page offering to start it.",
+                "tags": [
+                    "ui"
+                ],
+                "summary": "Proxy the web UI of an instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Request successfully proxied to instance"
+                    },
+                    "404": {
+                        "description": "Instance not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Instance is not running",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/instances/{name}/validate": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Validates a name and options as creating or updating the instance would, without changing anything. Every invalid field is reported with its JSON path.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Validate an instance configuration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Instance configuration options",
+                        "name": "options",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/instance.CreateInstanceOptions"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The configuration is valid",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "boolean"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid instance options",
+                        "schema": {
+                            "$ref": "#/definitions/server.ValidationErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/logs/quota": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the disk space the log files of the instances use against log_disk_quota_mb, with the usage and share of each instance, as of the last check after a rotation or with the retention policy",
+                "tags": [
+                    "system"
+                ],
+                "summary": "Get the use of the log disk quota",
+                "responses": {
+                    "200": {
+                        "description": "Log disk usage",
+                        "schema": {
+                            "$ref": "#/definitions/manager.LogQuotaStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/logs/tail": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Merges the logs of the listed instances, or of the instances matching a selector, into one stream of Server-Sent Events. Each instance starts with its last lines. Lines are sent as log events labeled with their instance, taking turns between instances; an instance whose lines pile up while the client falls behind has its oldest lines dropped, reported by a dropped event. Status changes of the instances are sent as status events, and join and leave events tell when instances start and stop matching a selector.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Stream the logs of several instances",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma-separated list of instance names",
+                        "name": "instances",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "all, tag:\u003ctag\u003e or group:\u003cservice\u003e",
+                        "name": "selector",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of lines of each instance to start with (default: 10, at most 256)",
+                        "name": "lines",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stream of log, status, join, leave and dropped events",
+                        "schema": {
+                            "$ref": "#/definitions/server.TailLine"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid instances, selector or lines parameter",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Instance not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Instance is not managed by llamactl",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Streaming not supported",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/maintenance/rolling-restart": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Restarts the running instances matching the selector in the background, max_unavailable at a time, waiting for each to become ready before moving on. With if_binary_changed, the instances whose backend binary did not change since they were launched are skipped. The rolling restart is aborted once more than max_failures instances fail. Progress is published as rolling_restart events.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Start a rolling restart",
+                "parameters": [
+                    {
+                        "description": "Selector and rolling restart options",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/server.RollingRestartRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Rolling restart started",
+                        "schema": {
+                            "$ref": "#/definitions/manager.RollingRestartStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid selector or options",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "A rolling restart is already running",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/maintenance/rolling-restart/status": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the progress of the current or most recent rolling restart, or the idle state if none has run",
+                "tags": [
+                    "maintenance"
+                ],
+                "summary": "Get the rolling restart status",
+                "responses": {
+                    "200": {
+                        "description": "Rolling restart status",
+                        "schema": {
+                            "$ref": "#/definitions/manager.RollingRestartStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Exposes request latency and token count histograms of proxied requests, the restart budget of instances and the disk space of their log files, in the Prometheus text format",
+                "tags": [
+                    "metrics"
+                ],
+                "summary": "Prometheus metrics",
+                "responses": {
+                    "200": {
+                        "description": "Prometheus metrics",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/models/available": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the GGUF files found in the configured model directories with the metadata read from their headers: architecture, parameter count, quantization, context length and chat template presence. Each file lists the instances that reference it.",
+                "tags": [
+                    "models"
+                ],
+                "summary": "List available model files",
+                "responses": {
+                    "200": {
+                        "description": "Indexed model files",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.AvailableModel"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Model indexing is not enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/models/rescan": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Scans the configured model directories right away, indexing new and changed GGUF files, and returns the updated index",
+                "tags": [
+                    "models"
+                ],
+                "summary": "Rescan the model directories",
+                "responses": {
+                    "200": {
+                        "description": "Indexed model files",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/server.AvailableModel"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Model indexing is not enabled",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/models/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the file name, size and SHA-256 checksum of a model registered for download by other nodes",
+                "tags": [
+                    "models"
+                ],
+                "summary": "Get a registered model",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Model Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Model info",
+                        "schema": {
+                            "$ref": "#/definitions/models.ModelInfo"
+                        }
+                    },
+                    "404": {
+                        "description": "Model not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/models/{name}/download": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Serves the file of a registered model. Range requests are supported to resume downloads, and the X-Checksum-Sha256 header carries the checksum of the whole file.",
+                "tags": [
+                    "models"
+                ],
+                "summary": "Download a registered model",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Model Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Model file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "206": {
+                        "description": "Requested range of the model file",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Model not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "416": {
+                        "description": "Requested range not satisfiable",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/options/aliases": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the alternative spellings accepted for the keys of instance options, camelCase and llama.cpp spellings such as n_ctx, with the canonical key each maps onto. Responses always use the canonical keys.",
+                "tags": [
+                    "instances"
+                ],
+                "summary": "Get the aliases of option keys",
+                "responses": {
+                    "200": {
+                        "description": "Aliases of the top-level and backend option keys",
+                        "schema": {
+                            "$ref": "#/definitions/instance.OptionAliases"
+                        }
+                    }
+                }
+            }
+        },
+        "/quotas": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the quota status of every API key with a quota, identified by key ID",
+                "tags": [
+                    "quotas"
+                ],
+                "summary": "List API key quotas",
+                "responses": {
+                    "200": {
+                        "description": "Quota status per key",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/quota.Status"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/quotas/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the limits, consumed usage, remaining budget and reset time of an API key's quota",
+                "tags": [
+                    "quotas"
+                ],
+                "summary": "Get an API key quota",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Quota status",
+                        "schema": {
+                            "$ref": "#/definitions/quota.Status"
+                        }
+                    },
+                    "404": {
+                        "description": "No quota for this key",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Sets the requests and tokens an API key has consumed in the current window. The change is recorded in the audit log.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "quotas"
+                ],
+                "summary": "Adjust an API key quota",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Consumed requests and tokens",
+                        "name": "usage",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/quota.Usage"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated quota status",
+                        "schema": {
+                            "$ref": "#/definitions/quota.Status"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "No quota for this key",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Reports whether llamactl completed the initial restore of the instances: 503 with the restoring status until the instances persisted running are adopted or started again and the static instances reconciled, then 200. Needs no API key.",
+                "tags": [
+                    "system"
+                ],
+                "summary": "Get the readiness of llamactl",
+                "responses": {
+                    "200": {
+                        "description": "Ready",
+                        "schema": {
+                            "$ref": "#/definitions/server.readiness"
+                        }
+                    },
+                    "503": {
+                        "description": "Restoring",
+                        "schema": {
+                            "$ref": "#/definitions/server.readiness"
+                        }
+                    }
+                }
+            }
+        },
+        "/restore": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the progress of the restore of every instance persisted running or defined in the configuration file, since llamactl started",
+                "tags": [
+                    "system"
+                ],
+                "summary": "Get the progress of the initial restore",
+                "responses": {
+                    "200": {
+                        "description": "Restore progress",
+                        "schema": {
+                            "$ref": "#/definitions/manager.RestoreStatus"
+                        }
+                    }
+                }
+            }
+        },
+        "/services/{alias}/failback": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Routes the requests for a service with a standby back to its primary, once the primary passes a health check. Does nothing when the primary is already active.",
+                "tags": [
+                    "services"
+                ],
+                "summary": "Fail a service back to its primary",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service Alias",
+                        "name": "alias",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Primary and standby state after the failback",
+                        "schema": {
+                            "$ref": "#/definitions/manager.StandbyStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid alias format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Service has no standby",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Primary is not healthy",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/services/{alias}/health": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a healthy, degraded or down verdict for a configured service, computed from member readiness, recent error rates and dependencies. Responds with 503 when the service is down.",
+                "tags": [
+                    "services"
+                ],
+                "summary": "Get aggregated health of a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service Alias",
+                        "name": "alias",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Service is healthy or degraded",
+                        "schema": {
+                            "$ref": "#/definitions/manager.ServiceHealth"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid alias format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "503": {
+                        "description": "Service is down",
+                        "schema": {
+                            "$ref": "#/definitions/manager.ServiceHealth"
+                        }
+                    }
+                }
+            }
+        },
+        "/services/{alias}/routes": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the weights requests for a service are split by, and the requests routed to each member, such as a stable instance and its canary",
+                "tags": [
+                    "services"
+                ],
+                "summary": "Get the weighted routing of a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service Alias",
+                        "name": "alias",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Weights and stats of the members",
+                        "schema": {
+                            "$ref": "#/definitions/manager.RouteStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid alias format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Service has no weighted routing",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Replaces the weights requests for a service are split by, without dropping the requests being served. The weights are kept across restarts until reset. Promoting a canary is setting its weight to 100 and that of the stable instance to 0.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "services"
+                ],
+                "summary": "Set the weighted routing of a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service Alias",
+                        "name": "alias",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Weights, canary and guardrail",
+                        "name": "rules",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/manager.RouteRules"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Weights and stats of the members",
+                        "schema": {
+                            "$ref": "#/definitions/manager.RouteStatus"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid weights",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Drops the weights set through the API or by the guardrail, going back to the weights of the configuration file, if any",
+                "tags": [
+                    "services"
+                ],
+                "summary": "Reset the weighted routing of a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Service Alias",
+                        "name": "alias",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Routing reset"
+                    },
+                    "400": {
+                        "description": "Invalid alias format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Service not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/sinks/status": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the connectivity of every configured event sink, with the events it published, dropped because its buffer was full, and still buffered",
+                "tags": [
+                    "system"
+                ],
+                "summary": "Get the status of the event sinks",
+                "responses": {
+                    "200": {
+                        "description": "Status of each sink, in configuration order",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/sinks.Status"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/trash": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the deleted instances kept in the trash until they are purged, with the options they can be restored with, secret environment values masked",
+                "tags": [
+                    "trash"
+                ],
+                "summary": "List deleted instances",
+                "responses": {
+                    "200": {
+                        "description": "Deleted instances, sorted by name",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/manager.TrashedInstance"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/trash/{name}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Removes a deleted instance from the trash right away, with its log files if they were to be purged",
+                "tags": [
+                    "trash"
+                ],
+                "summary": "Purge a deleted instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "Instance not in the trash",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/trash/{name}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Brings a deleted instance back from the trash, stopped, with the options it was deleted with. It fails if its name or its port was taken meanwhile.",
+                "tags": [
+                    "trash"
+                ],
+                "summary": "Restore a deleted instance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Instance Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Restored instance",
+                        "schema": {
+                            "$ref": "#/definitions/instance.Process"
+                        }
+                    },
+                    "404": {
+                        "description": "Instance not in the trash",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Name or port taken, or options no longer valid",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Handles all POST requests to /v1/*, routing to the appropriate instance based on the request body. Requires API key authentication via the ` + "`" + `Authorization` + "`" + ` header.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "openai"
+                ],
+                "summary": "OpenAI-compatible proxy endpoint",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Request priority (low, normal, high)",
+                        "name": "X-Priority",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Longest wait for admission, in seconds or as an RFC 3339 time",
+                        "name": "X-Deadline",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OpenAI response",
+                        "headers": {
+                            "X-Llamactl-Transform": {
+                                "type": "string",
+                                "description": "Transform applied to the request and response"
+                            },
+                            "X-Llamactl-Transform-Error": {
+                                "type": "string",
+                                "description": "Error of a transform that failed open"
+                            },
+                            "X-Queue-Estimated-Wait-Ms": {
+                                "type": "integer",
+                                "description": "Wait estimated for the request when it was queued"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or instance name",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "429": {
+                        "description": "Admission queue is full, or the estimated wait exceeds the deadline",
+                        "schema": {
+                            "$ref": "#/definitions/server.QueueFullResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "502": {
+                        "description": "Transform failed closed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "504": {
+                        "description": "Request exceeded max_request_duration",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/models": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a list of instances in a format compatible with OpenAI API",
+                "tags": [
+                    "openai"
+                ],
+                "summary": "List instances in OpenAI-compatible format",
+                "responses": {
+                    "200": {
+                        "description": "List of OpenAI-compatible instances",
+                        "schema": {
+                            "$ref": "#/definitions/server.OpenAIListInstancesResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/version": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns the version of the llamactl command",
+                "tags": [
+                    "version"
+                ],
+                "summary": "Get llamactl version",
+                "responses": {
+                    "200": {
+                        "description": "Version information",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "backends.BackendType": {
+            "type": "string",
+            "enum": [
+                "llama_cpp",
+                "mlx_lm",
+                "vllm"
+            ],
+            "x-enum-varnames": [
+                "BackendTypeLlamaCpp",
+                "BackendTypeMlxLm",
+                "BackendTypeVllm"
+            ]
+        },
+        "config.CanaryGuardrail": {
+            "type": "object",
+            "properties": {
+                "max_error_rate": {
+                    "description": "Error rate of the requests routed to the canary over the last minute above which its\nweight is set to 0",
+                    "type": "number"
+                },
+                "min_requests": {
+                    "description": "Minimum recent requests before the error rate is considered (default: 20)",
+                    "type": "integer"
+                }
+            }
+        },
+        "config.SettingValue": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "description": "Why the setting cannot be changed at runtime",
+                    "type": "string"
+                },
+                "runtime_tunable": {
+                    "type": "boolean"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "value": {}
+            }
+        },
+        "events.Event": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "data": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.BackendTLSOptions": {
+            "type": "object",
+            "properties": {
+                "ca_file": {
+                    "description": "PEM bundle used instead of the system roots",
+                    "type": "string"
+                },
+                "cert_file": {
+                    "description": "Client certificate for mutual TLS",
+                    "type": "string"
+                },
+                "insecure_skip_verify": {
+                    "description": "Requires allow_insecure_backends",
+                    "type": "boolean"
+                },
+                "key_file": {
+                    "description": "Client key for mutual TLS",
+                    "type": "string"
+                },
+                "scheme": {
+                    "description": "\"http\" or \"https\" (default: \"https\" when TLS options are set)",
+                    "type": "string"
+                }
+            }
+        },
+        "instance.CommandPreview": {
+            "type": "object",
+            "properties": {
+                "args": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "argv": {
+                    "description": "Full argument vector that is executed, wrapper included",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "command": {
+                    "description": "Backend command and arguments, before wrapping",
+                    "type": "string"
+                },
+                "wrapper": {
+                    "description": "Launch wrapper the backend runs under, empty if none",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "instance.ConfigVerification": {
+            "type": "object",
+            "properties": {
+                "checked": {
+                    "description": "Settings compared",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "mismatches": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/instance.SettingMismatch"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.ConnectionStats": {
+            "type": "object",
+            "properties": {
+                "idle": {
+                    "type": "integer"
+                },
+                "max": {
+                    "description": "0 = unlimited",
+                    "type": "integer"
+                },
+                "open": {
+                    "type": "integer"
+                },
+                "reaped": {
+                    "description": "Idle connections closed beyond the floor",
+                    "type": "integer"
+                },
+                "rejected": {
+                    "description": "Requests failed at the cap with the fail policy",
+                    "type": "integer"
+                }
+            }
+        },
+        "instance.CreateInstanceOptions": {
+            "type": "object",
+            "properties": {
+                "allowed_paths": {
+                    "description": "Paths the proxy forwards to the backend, as prefixes or glob patterns, updatable without\na restart (default: every path)",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "auto_restart": {
+                    "description": "Auto restart",
+                    "type": "boolean"
+                },
+                "backend_connection_policy": {
+                    "description": "At the cap, wait for a connection to close (\"block\", the default) or fail the request (\"fail\")",
+                    "type": "string"
+                },
+                "backend_options": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "backend_tls": {
+                    "description": "Backend connection",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.BackendTLSOptions"
+                        }
+                    ]
+                },
+                "backend_type": {
+                    "$ref": "#/definitions/backends.BackendType"
+                },
+                "bind_host": {
+                    "description": "Address the backend listens on, passed to it as --host (default: the backend default)",
+                    "type": "string"
+                },
+                "bind_interface": {
+                    "description": "Interface name or address the backend listens on, resolved when the instance starts",
+                    "type": "string"
+                },
+                "connect_host": {
+                    "description": "Host llamactl connects to the backend on (default: the bind host, 127.0.0.1 for a wildcard bind host)",
+                    "type": "string"
+                },
+                "drain_timeout": {
+                    "description": "Time to complete the requests in flight before SIGTERM, 0 = stop right away (default: default_drain_timeout)",
+                    "type": "string"
+                },
+                "environment": {
+                    "description": "Environment variables",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "health_check": {
+                    "description": "Liveness checks of the running backend, killed and restarted when it keeps failing them",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.HealthCheckOptions"
+                        }
+                    ]
+                },
+                "hook_timeout": {
+                    "description": "Time each of them is given before it is killed (default: 5m)",
+                    "type": "string"
+                },
+                "idle_timeout": {
+                    "description": "Idle timeout, a bare integer is in minutes",
+                    "type": "string"
+                },
+                "launch_wrapper": {
+                    "description": "Command prepended to the backend command line, e.g. [\"numactl\", \"--interleave=all\"]",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "lb_health": {
+                    "description": "When external load balancers are told the instance takes no more work",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.LBHealthOptions"
+                        }
+                    ]
+                },
+                "llama_executable": {
+                    "description": "Executable of a llama.cpp backend, e.g. a CUDA or Vulkan build of llama-server\n(default: llama_executable, or the command of the llama.cpp backend)",
+                    "type": "string"
+                },
+                "log_quota_weight": {
+                    "description": "Weight of the share of log_disk_quota the log files can use, relative to the other instances (default: 1)",
+                    "type": "integer"
+                },
+                "log_retention_days": {
+                    "description": "Log retention",
+                    "type": "integer"
+                },
+                "log_sanitize": {
+                    "description": "Sanitization of backend output before it is logged: \"off\", \"strip\" or \"collapse\"",
+                    "type": "string"
+                },
+                "managed": {
+                    "description": "Managed instances are started and stopped by llamactl; unmanaged ones are proxy-only",
+                    "type": "boolean"
+                },
+                "managed_backend_key": {
+                    "description": "Start a llama.cpp backend with an API key llamactl generates, keeps and authenticates with",
+                    "type": "boolean"
+                },
+                "max_backend_connections": {
+                    "description": "0 = unlimited",
+                    "type": "integer"
+                },
+                "max_concurrent_requests": {
+                    "description": "Request admission",
+                    "type": "integer"
+                },
+                "max_queued_requests": {
+                    "description": "0 = unlimited",
+                    "type": "integer"
+                },
+                "max_request_duration": {
+                    "description": "time a request is proxied for once admitted, 0 = unlimited",
+                    "type": "string"
+                },
+                "max_restarts": {
+                    "type": "integer"
+                },
+                "min_idle_backend_connections": {
+                    "description": "Idle connections kept open when idle connections are reaped",
+                    "type": "integer"
+                },
+                "min_uptime_seconds": {
+                    "description": "Time a process has to run for its crash not to count as a rapid failure, a bare integer\nis in seconds. The instance fails as crash-looping after 3 of them in a row (default: 0, disabled)",
+                    "type": "string"
+                },
+                "normalize_responses": {
+                    "description": "Rewrite OpenAI responses to the strict OpenAI shape",
+                    "type": "boolean"
+                },
+                "on_demand_start": {
+                    "description": "On demand start",
+                    "type": "boolean"
+                },
+                "on_gpu_fault": {
+                    "description": "When the backend fails requests with GPU errors: \"restart\" it (default) or only \"flag\" it",
+                    "type": "string"
+                },
+                "on_model_change": {
+                    "description": "When the model files change on disk: \"flag\" the instance (default) or also \"restart\" it",
+                    "type": "string"
+                },
+                "on_start_cmd": {
+                    "description": "Shell commands run before the backend starts, failing the start when they fail, and\nafter it exits, automatic restarts included",
+                    "type": "string"
+                },
+                "on_stop_cmd": {
+                    "type": "string"
+                },
+                "preserve_slots": {
+                    "description": "Save the slots of a llama.cpp backend before a restart and restore them afterwards",
+                    "type": "boolean"
+                },
+                "prompt_templates": {
+                    "description": "Named prompts rendered with variables for the completion endpoint of the backend,\nupdatable without a restart",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "queue_timeout": {
+                    "description": "time a request can wait for admission, 0 = unlimited",
+                    "type": "string"
+                },
+                "readiness_timeout": {
+                    "description": "Time to become healthy after starting, derived from the model size when unset",
+                    "type": "string"
+                },
+                "remote_model": {
+                    "description": "Model registered on the control plane, downloaded before the instance starts",
+                    "type": "string"
+                },
+                "restart_backoff": {
+                    "description": "Double the restart delay on each consecutive crash, up to restart_backoff_max (default: 5m)",
+                    "type": "boolean"
+                },
+                "restart_backoff_max": {
+                    "type": "string"
+                },
+                "restart_delay": {
+                    "type": "string"
+                },
+                "restart_jitter": {
+                    "description": "Random delay added to the restart delay, a percentage of it (\"25%\") or a duration",
+                    "type": "string"
+                },
+                "restart_reset_after": {
+                    "description": "Time a run has to stay ready for the restart counter and backoff to start over (default: 10m)",
+                    "type": "string"
+                },
+                "secret_environment": {
+                    "description": "Environment variables whose values are masked when the instance is read back, in\naddition to those named like secrets",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "slo": {
+                    "description": "Service level objectives, updatable without a restart",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.SLOOptions"
+                        }
+                    ]
+                },
+                "slot_retention_hours": {
+                    "description": "hours, 0 = keep forever",
+                    "type": "integer"
+                },
+                "start_schedule": {
+                    "description": "Cron expressions at which the instance is started and stopped, in the time zone of llamactl",
+                    "type": "string"
+                },
+                "stop_schedule": {
+                    "type": "string"
+                },
+                "stop_timeout": {
+                    "description": "Time to exit after SIGTERM before the process group is killed (default: default_stop_timeout)",
+                    "type": "string"
+                },
+                "tags": {
+                    "description": "Labels used to select instances, e.g. for rolling restarts",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "transform": {
+                    "description": "Transform rewriting the proxied OpenAI requests and non-streamed responses",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.TransformOptions"
+                        }
+                    ]
+                }
+            }
+        },
+        "instance.EffectiveOption": {
+            "type": "object",
+            "properties": {
+                "source": {
+                    "$ref": "#/definitions/instance.OptionSource"
+                },
+                "value": {}
+            }
+        },
+        "instance.GPUFault": {
+            "type": "object",
+            "properties": {
+                "detected_at": {
+                    "description": "When the threshold was reached",
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "Matching responses within the window",
+                    "type": "integer"
+                },
+                "sample": {
+                    "description": "Body of the last matching response, truncated",
+                    "type": "string"
+                },
+                "window": {
+                    "description": "gpu_fault_window the errors were counted over",
+                    "type": "string"
+                }
+            }
+        },
+        "instance.GoroutineStats": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "integer"
+                },
+                "started": {
+                    "type": "integer"
+                },
+                "stopped": {
+                    "type": "integer"
+                }
+            }
+        },
+        "instance.HealthCheckOptions": {
+            "type": "object",
+            "properties": {
+                "failure_threshold": {
+                    "description": "Consecutive failures the backend is killed after (default: 3)",
+                    "type": "integer"
+                },
+                "interval": {
+                    "description": "Between checks (default: 30s)",
+                    "type": "string"
+                },
+                "timeout": {
+                    "description": "Of a single check (default: 5s)",
+                    "type": "string"
+                }
+            }
+        },
+        "instance.HistogramBucket": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "le_ms": {
+                    "type": "number"
+                }
+            }
+        },
+        "instance.InFlightRequest": {
+            "type": "object",
+            "properties": {
+                "age_seconds": {
+                    "type": "number"
+                },
+                "api_key": {
+                    "description": "Masked key the request authenticated with",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "priority": {
+                    "description": "Admission priority, empty for requests not admitted through the queue",
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "streaming": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "instance.InstanceStatus": {
+            "type": "integer",
+            "enum": [
+                0,
+                1,
+                2,
+                3,
+                4,
+                5
+            ],
+            "x-enum-comments": {
+                "Queued": "Waiting for a loading slot to start",
+                "Restarting": "The process exited and an automatic restart is pending",
+                "Starting": "The process runs but has not passed a readiness check yet"
+            },
+            "x-enum-descriptions": [
+                "Waiting for a loading slot to start",
+                "The process runs but has not passed a readiness check yet",
+                "The process exited and an automatic restart is pending"
+            ],
+            "x-enum-varnames": [
+                "Stopped",
+                "Running",
+                "Failed",
+                "Queued",
+                "Starting",
+                "Restarting"
+            ]
+        },
+        "instance.LBHealth": {
+            "type": "object",
+            "properties": {
+                "in_flight": {
+                    "type": "integer"
+                },
+                "queue_depth": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.LBHealthOptions": {
+            "type": "object",
+            "properties": {
+                "in_flight_threshold": {
+                    "description": "Requests in flight the instance is unhealthy at (default: unlimited)",
+                    "type": "integer"
+                },
+                "queue_threshold": {
+                    "description": "Queued requests the instance is unhealthy at (default: 1)",
+                    "type": "integer"
+                }
+            }
+        },
+        "instance.LastExit": {
+            "type": "object",
+            "properties": {
+                "binary_replaced": {
+                    "description": "The backend binary was replaced on disk while the process ran, e.g. by an upgrade",
+                    "type": "boolean"
+                },
+                "exit_code": {
+                    "description": "-1 if the process was killed by a signal",
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "output": {
+                    "description": "Last lines the backend wrote to stdout and stderr, as logged",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "reason": {
+                    "$ref": "#/definitions/instance.ReasonCode"
+                },
+                "signal": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.ListenCheck": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "degraded": {
+                    "type": "boolean"
+                },
+                "expected": {
+                    "type": "string"
+                },
+                "listening": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.LogDiskUsage": {
+            "type": "object",
+            "properties": {
+                "deleted_by_quota": {
+                    "description": "Rotated log files removed to stay within the share",
+                    "type": "integer"
+                },
+                "share_bytes": {
+                    "description": "Share of the quota, unset without a quota",
+                    "type": "integer"
+                },
+                "used_bytes": {
+                    "type": "integer"
+                },
+                "weight": {
+                    "type": "integer"
+                }
+            }
+        },
+        "instance.LogFileInfo": {
+            "type": "object",
+            "properties": {
+                "age_seconds": {
+                    "type": "integer"
+                },
+                "current": {
+                    "type": "boolean"
+                },
+                "lines": {
+                    "description": "Of a rotated file",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "start": {
+                    "description": "When the first line was logged, if known",
+                    "type": "string"
+                },
+                "timestamp": {
+                    "description": "Rotation time of a rotated file, mtime of the current one",
+                    "type": "string"
+                }
+            }
+        },
+        "instance.ModelChange": {
+            "type": "object",
+            "properties": {
+                "detected_at": {
+                    "description": "When the change settled",
+                    "type": "string"
+                },
+                "path": {
+                    "description": "Model file found changed",
+                    "type": "string"
+                }
+            }
+        },
+        "instance.Operation": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "queued_at": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/instance.OperationState"
+                },
+                "type": {
+                    "$ref": "#/definitions/instance.OperationType"
+                }
+            }
+        },
+        "instance.OperationInProgressError": {
+            "type": "object",
+            "properties": {
+                "instance": {
+                    "type": "string"
+                },
+                "operation": {
+                    "$ref": "#/definitions/instance.Operation"
+                }
+            }
+        },
+        "instance.OperationState": {
+            "type": "string",
+            "enum": [
+                "queued",
+                "running",
+                "succeeded",
+                "failed",
+                "cancelled"
+            ],
+            "x-enum-varnames": [
+                "OperationQueued",
+                "OperationRunning",
+                "OperationSucceeded",
+                "OperationFailed",
+                "OperationCancelled"
+            ]
+        },
+        "instance.OperationType": {
+            "type": "string",
+            "enum": [
+                "start",
+                "stop",
+                "restart",
+                "reload"
+            ],
+            "x-enum-varnames": [
+                "OperationStart",
+                "OperationStop",
+                "OperationRestart",
+                "OperationReload"
+            ]
+        },
+        "instance.OperationsSnapshot": {
+            "type": "object",
+            "properties": {
+                "current": {
+                    "$ref": "#/definitions/instance.Operation"
+                },
+                "queued": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/instance.Operation"
+                    }
+                },
+                "recent": {
+                    "description": "Most recent first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/instance.Operation"
+                    }
+                }
+            }
+        },
+        "instance.OptionAliases": {
+            "type": "object",
+            "properties": {
+                "backend_options": {
+                    "description": "Keys of backend_options, per backend type",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "options": {
+                    "description": "Top-level keys",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "instance.OptionSource": {
+            "type": "string",
+            "enum": [
+                "default",
+                "explicit",
+                "inferred"
+            ],
+            "x-enum-comments": {
+                "SourceDefault": "Global instance defaults",
+                "SourceExplicit": "Set in the instance's own options",
+                "SourceInferred": "Filled in by llamactl, e.g. an assigned port"
+            },
+            "x-enum-descriptions": [
+                "Global instance defaults",
+                "Set in the instance's own options",
+                "Filled in by llamactl, e.g. an assigned port"
+            ],
+            "x-enum-varnames": [
+                "SourceDefault",
+                "SourceExplicit",
+                "SourceInferred"
+            ]
+        },
+        "instance.PlacementDecision": {
+            "type": "object",
+            "properties": {
+                "conflicts": {
+                    "description": "Members the policy is broken with",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "gpus": {
+                    "description": "null when the instance may use every GPU",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "mode": {
+                    "type": "string"
+                },
+                "policy": {
+                    "type": "string"
+                },
+                "satisfied": {
+                    "type": "boolean"
+                },
+                "service": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.Process": {
+            "type": "object",
+            "properties": {
+                "config_verification": {
+                    "description": "How the configuration the backend reported once ready compared with its options",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.ConfigVerification"
+                        }
+                    ]
+                },
+                "created": {
+                    "description": "Creation time",
+                    "type": "integer"
+                },
+                "gpu_fault": {
+                    "description": "Backend failing requests with GPU errors since, during the current run",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.GPUFault"
+                        }
+                    ]
+                },
+                "last_error": {
+                    "description": "Most recent abnormal termination",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.StatusReason"
+                        }
+                    ]
+                },
+                "last_exit": {
+                    "description": "Exit status and output of the most recent crash",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.LastExit"
+                        }
+                    ]
+                },
+                "last_slot_restore": {
+                    "description": "Outcome of restoring the slots saved before the last restart",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.SlotRestore"
+                        }
+                    ]
+                },
+                "listen_check": {
+                    "description": "Where the backend of the current run listens",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.ListenCheck"
+                        }
+                    ]
+                },
+                "model_change": {
+                    "description": "Set when the model files changed on disk since the running process loaded them",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.ModelChange"
+                        }
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                },
+                "next_restart_at": {
+                    "description": "When the pending automatic restart is due",
+                    "type": "string"
+                },
+                "placement": {
+                    "description": "How the last creation or start went against the GPU placement of its services",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/instance.PlacementDecision"
+                    }
+                },
+                "start_failure": {
+                    "description": "Most recent exit of the backend while starting, cleared once it passes a readiness check",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.StartFailure"
+                        }
+                    ]
+                },
+                "status": {
+                    "$ref": "#/definitions/instance.InstanceStatus"
+                },
+                "status_reason": {
+                    "description": "Reason for the most recent transition",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.StatusReason"
+                        }
+                    ]
+                },
+                "stopped_reason": {
+                    "description": "Reason the instance last left the running state",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.StatusReason"
+                        }
+                    ]
+                }
+            }
+        },
+        "instance.QueueStats": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "integer"
+                },
+                "active_by_priority": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "estimated_wait_ms": {
+                    "description": "Expected wait of a new normal priority request",
+                    "type": "number"
+                },
+                "max_concurrent": {
+                    "description": "0 = unlimited",
+                    "type": "integer"
+                },
+                "max_queued": {
+                    "description": "0 = unlimited",
+                    "type": "integer"
+                },
+                "queued": {
+                    "type": "integer"
+                },
+                "queued_by_priority": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "service_time_ms": {
+                    "description": "Moving average of the time requests hold their slot",
+                    "type": "number"
+                }
+            }
+        },
+        "instance.ReasonCode": {
+            "type": "string",
+            "enum": [
+                "user_start",
+                "user_stop",
+                "auto_restart",
+                "restored",
+                "clean_exit",
+                "crash",
+                "oom_kill",
+                "health_probe_success",
+                "health_probe_failure",
+                "idle_timeout",
+                "schedule",
+                "preempted",
+                "max_restarts_exceeded",
+                "shutdown",
+                "model_download",
+                "fatal_log",
+                "start_queued",
+                "gpu_fault",
+                "crash_loop",
+                "adopted",
+                "reload"
+            ],
+            "x-enum-varnames": [
+                "ReasonUserStart",
+                "ReasonUserStop",
+                "ReasonAutoRestart",
+                "ReasonRestored",
+                "ReasonCleanExit",
+                "ReasonCrash",
+                "ReasonOOMKill",
+                "ReasonHealthProbeSuccess",
+                "ReasonHealthProbeFailure",
+                "ReasonIdleTimeout",
+                "ReasonSchedule",
+                "ReasonPreempted",
+                "ReasonMaxRestartsExceeded",
+                "ReasonShutdown",
+                "ReasonModelDownload",
+                "ReasonFatalLog",
+                "ReasonStartQueued",
+                "ReasonGPUFault",
+                "ReasonCrashLoop",
+                "ReasonAdopted",
+                "ReasonReload"
+            ]
+        },
+        "instance.SLOOptions": {
+            "type": "object",
+            "properties": {
+                "burn_rate_threshold": {
+                    "description": "Burn rate the instance is degraded at (default: 2)",
+                    "type": "number"
+                },
+                "error_rate": {
+                    "description": "Fraction of failed requests, 0 = no objective",
+                    "type": "number"
+                },
+                "latency_p95_ms": {
+                    "description": "p95 time until the backend responds, 0 = no objective",
+                    "type": "integer"
+                },
+                "min_requests": {
+                    "description": "Requests in the window before the SLO is evaluated (default: 10)",
+                    "type": "integer"
+                },
+                "warmup": {
+                    "description": "Time after a start during which requests are ignored, a bare integer is in seconds",
+                    "type": "string"
+                },
+                "window": {
+                    "description": "Evaluation window, a bare integer is in seconds (default: 5m, max: 1h)",
+                    "type": "string"
+                }
+            }
+        },
+        "instance.SLOStatus": {
+            "type": "object",
+            "properties": {
+                "burn_rate": {
+                    "description": "The higher of the two",
+                    "type": "number"
+                },
+                "compliant": {
+                    "type": "boolean"
+                },
+                "degraded": {
+                    "type": "boolean"
+                },
+                "error_burn_rate": {
+                    "type": "number"
+                },
+                "error_rate": {
+                    "type": "number"
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "evaluated_at": {
+                    "type": "string"
+                },
+                "latency_burn_rate": {
+                    "type": "number"
+                },
+                "latency_p95_ms": {
+                    "description": "Upper bound of the latency histogram bucket holding the p95",
+                    "type": "number"
+                },
+                "objectives": {
+                    "$ref": "#/definitions/instance.SLOOptions"
+                },
+                "request_bytes": {
+                    "type": "integer"
+                },
+                "requests": {
+                    "type": "integer"
+                },
+                "response_bytes": {
+                    "type": "integer"
+                },
+                "since": {
+                    "description": "When the instance entered the state",
+                    "type": "string"
+                },
+                "slow_requests": {
+                    "description": "Requests above the p95 latency objective",
+                    "type": "integer"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "window": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.SettingMismatch": {
+            "type": "object",
+            "properties": {
+                "effective": {
+                    "description": "As reported by the backend",
+                    "type": "string"
+                },
+                "requested": {
+                    "type": "string"
+                },
+                "setting": {
+                    "description": "Backend option, e.g. ctx_size",
+                    "type": "string"
+                }
+            }
+        },
+        "instance.SlotRestore": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Why the slots were not all saved or restored",
+                    "type": "string"
+                },
+                "restored": {
+                    "type": "integer"
+                },
+                "saved": {
+                    "type": "integer"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.StartFailure": {
+            "type": "object",
+            "properties": {
+                "allocation_failure": {
+                    "description": "Set when the output matched alloc_failure_patterns, in which case the instance\nis not restarted since the next attempt would fail the same way",
+                    "type": "boolean"
+                },
+                "exit_code": {
+                    "description": "-1 if the process was killed by a signal",
+                    "type": "integer"
+                },
+                "output": {
+                    "description": "Last lines of error output of the backend",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "signal": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.StartupProgress": {
+            "type": "object",
+            "properties": {
+                "percent": {
+                    "description": "Unset while the phase is unknown",
+                    "type": "integer"
+                },
+                "phase": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.StatsSnapshot": {
+            "type": "object",
+            "properties": {
+                "avg_latency_ms": {
+                    "type": "number"
+                },
+                "cancelled": {
+                    "description": "Requests abandoned by the client before the response completed",
+                    "type": "integer"
+                },
+                "connections": {
+                    "description": "Backend connections, once the proxy is in use",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.ConnectionStats"
+                        }
+                    ]
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "in_flight": {
+                    "type": "integer"
+                },
+                "latency_histogram": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/instance.HistogramBucket"
+                    }
+                },
+                "log_disk": {
+                    "description": "Disk space of the log files, once measured",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.LogDiskUsage"
+                        }
+                    ]
+                },
+                "queue": {
+                    "description": "Admission queue and wait estimate, when max_concurrent_requests is set",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.QueueStats"
+                        }
+                    ]
+                },
+                "recent_error_rate": {
+                    "type": "number"
+                },
+                "recent_errors": {
+                    "type": "integer"
+                },
+                "recent_requests": {
+                    "type": "integer"
+                },
+                "requests": {
+                    "type": "integer"
+                },
+                "timeouts": {
+                    "description": "Requests cut off by max_request_duration",
+                    "type": "integer"
+                }
+            }
+        },
+        "instance.StatusReason": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/instance.ReasonCode"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "instance.TransformOptions": {
+            "type": "object",
+            "properties": {
+                "fail_mode": {
+                    "description": "\"open\" (default) or \"closed\"",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "Name the transform is registered under",
+                    "type": "string"
+                },
+                "params": {
+                    "description": "Passed to the transform",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "timeout": {
+                    "description": "Time budget of each call (default: 100ms)",
+                    "type": "string"
+                }
+            }
+        },
+        "manager.BinaryRestart": {
+            "type": "object",
+            "properties": {
+                "instance": {
+                    "$ref": "#/definitions/instance.Process"
+                },
+                "message": {
+                    "description": "Why the instance was restarted or not",
+                    "type": "string"
+                },
+                "restarted": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "manager.DependencyHealth": {
+            "type": "object",
+            "properties": {
+                "service": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "manager.DriftDiscrepancy": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "description": "Option, e.g. backend_options.ctx_size, or command line flag",
+                    "type": "string"
+                },
+                "kind": {
+                    "type": "string"
+                },
+                "message": {
+                    "description": "For discrepancies of the whole instance",
+                    "type": "string"
+                },
+                "values": {
+                    "description": "By state compared, null where unset",
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "manager.DriftSummary": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "drifted": {
+                    "type": "integer"
+                },
+                "instances": {
+                    "description": "Only those with drift",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.InstanceDrift"
+                    }
+                }
+            }
+        },
+        "manager.FleetAction": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "changes": {
+                    "description": "Options that differ from the fleet, for updates",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                },
+                "field_errors": {
+                    "description": "The invalid fields when the options failed validation",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/validation.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "restart_required": {
+                    "description": "The instance is running and is restarted to apply the update",
+                    "type": "boolean"
+                }
+            }
+        },
+        "manager.FleetPlan": {
+            "type": "object",
+            "properties": {
+                "actions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.FleetAction"
+                    }
+                },
+                "checksum": {
+                    "type": "string"
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "unlisted": {
+                    "description": "Instances not listed in the fleet, kept because prune is off",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "manager.InstanceDrift": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "discrepancies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.DriftDiscrepancy"
+                    }
+                },
+                "drifted": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "unchecked": {
+                    "description": "Comparisons that could not be made, by kind, and why",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "manager.InstanceLogUsage": {
+            "type": "object",
+            "properties": {
+                "deleted_by_quota": {
+                    "description": "Rotated log files removed to stay within the share",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "share_bytes": {
+                    "description": "Share of the quota, unset without a quota",
+                    "type": "integer"
+                },
+                "used_bytes": {
+                    "type": "integer"
+                },
+                "weight": {
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.InstanceRestore": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "progress": {
+                    "type": "string"
+                }
+            }
+        },
+        "manager.LogQuotaStatus": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "instances": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.InstanceLogUsage"
+                    }
+                },
+                "level": {
+                    "type": "string"
+                },
+                "percent": {
+                    "description": "Of the quota used, unset without a quota",
+                    "type": "number"
+                },
+                "quota_bytes": {
+                    "description": "0 = unlimited",
+                    "type": "integer"
+                },
+                "used_bytes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.MemberHealth": {
+            "type": "object",
+            "properties": {
+                "facts": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "ready": {
+                    "type": "boolean"
+                },
+                "recent_error_rate": {
+                    "type": "number"
+                },
+                "recent_errors": {
+                    "type": "integer"
+                },
+                "recent_requests": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "manager.RestoreStatus": {
+            "type": "object",
+            "properties": {
+                "completed_at": {
+                    "type": "string"
+                },
+                "instances": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.InstanceRestore"
+                    }
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.RollingRestartInstance": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "description": "Why the instance failed or was skipped",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "manager.RollingRestartOptions": {
+            "type": "object",
+            "properties": {
+                "if_binary_changed": {
+                    "description": "Skip the instances whose backend binary did not change since they were launched",
+                    "type": "boolean"
+                },
+                "max_failures": {
+                    "description": "Failed instances tolerated before the rolling restart is aborted (default: 0)",
+                    "type": "integer"
+                },
+                "max_unavailable": {
+                    "description": "Instances restarted at the same time (default: 1)",
+                    "type": "integer"
+                },
+                "ready_timeout": {
+                    "description": "Seconds to wait for a restarted instance to become ready (default: its readiness timeout)",
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.RollingRestartStatus": {
+            "type": "object",
+            "properties": {
+                "failures": {
+                    "type": "integer"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "instances": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.RollingRestartInstance"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "options": {
+                    "$ref": "#/definitions/manager.RollingRestartOptions"
+                },
+                "selector": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "state": {
+                    "type": "string"
+                }
+            }
+        },
+        "manager.RouteMemberStatus": {
+            "type": "object",
+            "properties": {
+                "avg_duration_ms": {
+                    "description": "Until the response was fully written",
+                    "type": "number"
+                },
+                "canary": {
+                    "type": "boolean"
+                },
+                "errors": {
+                    "type": "integer"
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "recent_error_rate": {
+                    "type": "number"
+                },
+                "recent_errors": {
+                    "type": "integer"
+                },
+                "recent_requests": {
+                    "type": "integer"
+                },
+                "requests": {
+                    "type": "integer"
+                },
+                "share": {
+                    "description": "Fraction of the requests routed to the member",
+                    "type": "number"
+                },
+                "weight": {
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.RouteRules": {
+            "type": "object",
+            "properties": {
+                "canary": {
+                    "type": "string"
+                },
+                "guardrail": {
+                    "$ref": "#/definitions/config.CanaryGuardrail"
+                },
+                "weights": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "manager.RouteStatus": {
+            "type": "object",
+            "properties": {
+                "canary": {
+                    "type": "string"
+                },
+                "guardrail": {
+                    "$ref": "#/definitions/config.CanaryGuardrail"
+                },
+                "guardrail_reason": {
+                    "type": "string"
+                },
+                "guardrail_tripped_at": {
+                    "description": "Last time the guardrail zeroed the canary",
+                    "type": "string"
+                },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.RouteMemberStatus"
+                    }
+                },
+                "service": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "weights": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "manager.ServiceHealth": {
+            "type": "object",
+            "properties": {
+                "dependencies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.DependencyHealth"
+                    }
+                },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.MemberHealth"
+                    }
+                },
+                "ready_members": {
+                    "type": "integer"
+                },
+                "reasons": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "service": {
+                    "type": "string"
+                },
+                "standby": {
+                    "description": "Services with a standby only",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/manager.StandbyStatus"
+                        }
+                    ]
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_members": {
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.ServiceImpact": {
+            "type": "object",
+            "properties": {
+                "can_absorb": {
+                    "type": "boolean"
+                },
+                "dependents": {
+                    "description": "Services that depend, directly or transitively, on this service",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "remaining_ready": {
+                    "description": "Ready members other than the instance being stopped",
+                    "type": "integer"
+                },
+                "service": {
+                    "type": "string"
+                },
+                "total_members": {
+                    "type": "integer"
+                }
+            }
+        },
+        "manager.StandbyStatus": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "The member requests for the service are routed to",
+                    "type": "string"
+                },
+                "consecutive_failures": {
+                    "description": "Consecutive failed health checks of the active member",
+                    "type": "integer"
+                },
+                "failback": {
+                    "type": "string"
+                },
+                "failed_over_at": {
+                    "type": "string"
+                },
+                "failovers": {
+                    "type": "integer"
+                },
+                "last_reason": {
+                    "type": "string"
+                },
+                "primary": {
+                    "type": "string"
+                },
+                "primary_healthy_since": {
+                    "description": "While the standby is active",
+                    "type": "string"
+                },
+                "standby": {
+                    "type": "string"
+                }
+            }
+        },
+        "manager.StopImpact": {
+            "type": "object",
+            "properties": {
+                "dependent_services": {
+                    "description": "Services that depend on any of the services the instance is a member of",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "in_flight": {
+                    "type": "integer"
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "queued": {
+                    "type": "integer"
+                },
+                "reasons": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "running": {
+                    "type": "boolean"
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/manager.ServiceImpact"
+                    }
+                },
+                "verdict": {
+                    "type": "string"
+                }
+            }
+        },
+        "manager.TrashedInstance": {
+            "type": "object",
+            "properties": {
+                "deleted_at": {
+                    "type": "string"
+                },
+                "deleted_by": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "options": {
+                    "$ref": "#/definitions/instance.CreateInstanceOptions"
+                },
+                "purge_at": {
+                    "type": "string"
+                },
+                "purge_logs": {
+                    "description": "The log files are removed on purge",
+                    "type": "boolean"
+                }
+            }
+        },
+        "memory.Usage": {
+            "type": "object",
+            "properties": {
+                "bytes": {
+                    "type": "integer"
+                },
+                "subsystems": {
+                    "description": "Bytes per kind of buffer",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                }
+            }
+        },
+        "models.ModelInfo": {
+            "type": "object",
+            "properties": {
+                "filename": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "sha256": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "quota.Status": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "key_hint": {
+                    "type": "string"
+                },
+                "remaining_requests": {
+                    "type": "integer"
+                },
+                "remaining_tokens": {
+                    "type": "integer"
+                },
+                "request_limit": {
+                    "type": "integer"
+                },
+                "reset_at": {
+                    "type": "string"
+                },
+                "token_limit": {
+                    "type": "integer"
+                },
+                "used": {
+                    "$ref": "#/definitions/quota.Usage"
+                },
+                "window": {
+                    "type": "string"
+                }
+            }
+        },
+        "quota.Usage": {
+            "type": "object",
+            "properties": {
+                "requests": {
+                    "type": "integer"
+                },
+                "tokens": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.AllowedPathsRequest": {
+            "type": "object",
+            "properties": {
+                "allowed_paths": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "server.AutoRestartPauseRequest": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "description": "Restarts resume by themselves after it, paused until resumed when unset",
+                    "type": "string"
+                }
+            }
+        },
+        "server.AutoRestartPauseStatus": {
+            "type": "object",
+            "properties": {
+                "paused": {
+                    "type": "boolean"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "until": {
+                    "description": "Unset while paused until resumed",
+                    "type": "string"
+                }
+            }
+        },
+        "server.AvailableModel": {
+            "type": "object",
+            "properties": {
+                "architecture": {
+                    "type": "string"
+                },
+                "context_length": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "has_chat_template": {
+                    "type": "boolean"
+                },
+                "instances": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "mod_time": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parameter_count": {
+                    "type": "integer"
+                },
+                "path": {
+                    "type": "string"
+                },
+                "quantization": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.ConfigResponse": {
+            "type": "object",
+            "properties": {
+                "instances": {
+                    "description": "Every instances setting with its value and source, by configuration file key",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/config.SettingValue"
+                    }
+                }
+            }
+        },
+        "server.FleetStatus": {
             "type": "object",
             "properties": {
-                "auto_restart": {
-                    "description": "Auto restart",
-                    "type": "boolean"
+                "last_error": {
+                    "type": "string"
                 },
-                "backend_options": {
+                "last_plan": {
+                    "$ref": "#/definitions/manager.FleetPlan"
+                },
+                "source": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.GenerateRequest": {
+            "type": "object",
+            "properties": {
+                "params": {
+                    "description": "Passed to the completion endpoint of the backend, e.g. n_predict",
                     "type": "object",
                     "additionalProperties": {}
                 },
-                "backend_type": {
-                    "$ref": "#/definitions/backends.BackendType"
+                "stream": {
+                    "type": "boolean"
                 },
-                "idle_timeout": {
-                    "description": "Idle timeout",
+                "template": {
+                    "type": "string"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "server.GoroutinesResponse": {
+            "type": "object",
+            "properties": {
+                "instances": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/instance.GoroutineStats"
+                    }
+                },
+                "num_goroutine": {
                     "type": "integer"
                 },
-                "max_restarts": {
+                "total": {
+                    "$ref": "#/definitions/instance.GoroutineStats"
+                }
+            }
+        },
+        "server.InstanceLogFilesResponse": {
+            "type": "object",
+            "properties": {
+                "deleted_by_retention": {
                     "type": "integer"
                 },
-                "on_demand_start": {
-                    "description": "On demand start",
-                    "type": "boolean"
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/instance.LogFileInfo"
+                    }
                 },
-                "restart_delay": {
-                    "description": "seconds",
+                "retention_days": {
                     "type": "integer"
                 }
             }
         },
-        "instance.InstanceStatus": {
-            "type": "integer",
-            "enum": [
-                0,
-                1,
-                2
-            ],
-            "x-enum-varnames": [
-                "Stopped",
-                "Running",
-                "Failed"
-            ]
-        },
-        "instance.Process": {
+        "server.MemoryResponse": {
             "type": "object",
             "properties": {
-                "created": {
-                    "description": "Creation time",
+                "evictions": {
+                    "description": "Entries evicted to keep usage within the limits, per kind of buffer",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "global_limit": {
+                    "description": "0 = unlimited",
                     "type": "integer"
                 },
-                "name": {
-                    "type": "string"
+                "instance_limit": {
+                    "description": "0 = unlimited",
+                    "type": "integer"
                 },
-                "status": {
-                    "description": "Status",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/instance.InstanceStatus"
-                        }
-                    ]
+                "instances": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/memory.Usage"
+                    }
+                },
+                "runtime": {
+                    "$ref": "#/definitions/server.RuntimeMemStats"
+                },
+                "total": {
+                    "$ref": "#/definitions/memory.Usage"
                 }
             }
         },
@@ -949,6 +6027,237 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "server.PromptTemplate": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "template": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.QueueFullErrorDetail": {
+            "type": "object",
+            "properties": {
+                "deadline_ms": {
+                    "type": "integer"
+                },
+                "estimated_wait_ms": {
+                    "description": "For requests rejected at their deadline",
+                    "type": "integer"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "priority": {
+                    "type": "string"
+                },
+                "queue": {
+                    "$ref": "#/definitions/instance.QueueStats"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.QueueFullResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/server.QueueFullErrorDetail"
+                }
+            }
+        },
+        "server.RollingRestartRequest": {
+            "type": "object",
+            "properties": {
+                "if_binary_changed": {
+                    "description": "Skip the instances whose backend binary did not change since they were launched",
+                    "type": "boolean"
+                },
+                "max_failures": {
+                    "description": "Failed instances tolerated before the rolling restart is aborted (default: 0)",
+                    "type": "integer"
+                },
+                "max_unavailable": {
+                    "description": "Instances restarted at the same time (default: 1)",
+                    "type": "integer"
+                },
+                "ready_timeout": {
+                    "description": "Seconds to wait for a restarted instance to become ready (default: its readiness timeout)",
+                    "type": "integer"
+                },
+                "selector": {
+                    "description": "\"all\", \"tag:\u003ctag\u003e\" or \"group:\u003cservice\u003e\"",
+                    "type": "string"
+                }
+            }
+        },
+        "server.RuntimeMemStats": {
+            "type": "object",
+            "properties": {
+                "alloc": {
+                    "type": "integer"
+                },
+                "heap_alloc": {
+                    "type": "integer"
+                },
+                "heap_idle": {
+                    "type": "integer"
+                },
+                "heap_inuse": {
+                    "type": "integer"
+                },
+                "heap_objects": {
+                    "type": "integer"
+                },
+                "heap_released": {
+                    "type": "integer"
+                },
+                "next_gc": {
+                    "type": "integer"
+                },
+                "num_gc": {
+                    "type": "integer"
+                },
+                "stack_inuse": {
+                    "type": "integer"
+                },
+                "sys": {
+                    "type": "integer"
+                },
+                "total_alloc": {
+                    "type": "integer"
+                }
+            }
+        },
+        "server.SignalRequest": {
+            "type": "object",
+            "properties": {
+                "signal": {
+                    "description": "Name of the signal, with or without its SIG prefix, e.g. \"USR1\"",
+                    "type": "string"
+                }
+            }
+        },
+        "server.StartFailedResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "start_failure": {
+                    "$ref": "#/definitions/instance.StartFailure"
+                },
+                "startup_progress": {
+                    "description": "How far the startup got",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/instance.StartupProgress"
+                        }
+                    ]
+                }
+            }
+        },
+        "server.TailLine": {
+            "type": "object",
+            "properties": {
+                "instance": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "string"
+                },
+                "stream": {
+                    "type": "string"
+                },
+                "ts": {
+                    "type": "string"
+                }
+            }
+        },
+        "server.ValidationErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/validation.FieldError"
+                    }
+                }
+            }
+        },
+        "server.readiness": {
+            "type": "object",
+            "properties": {
+                "pending": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "sinks.Status": {
+            "type": "object",
+            "properties": {
+                "buffer_size": {
+                    "description": "Events buffered at most",
+                    "type": "integer"
+                },
+                "buffered": {
+                    "description": "Events waiting for delivery",
+                    "type": "integer"
+                },
+                "connected": {
+                    "type": "boolean"
+                },
+                "dropped": {
+                    "description": "Events dropped because the buffer was full",
+                    "type": "integer"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "last_error_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "published": {
+                    "description": "Events acknowledged by the broker",
+                    "type": "integer"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "validation.FieldError": {
+            "type": "object",
+            "properties": {
+                "constraint": {
+                    "type": "string"
+                },
+                "field": {
+                    "description": "JSON path in the instance options, e.g. backend_options.ctx_size",
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "value": {}
+            }
         }
     }
 }`
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"llamactl/pkg/config"
+	"llamactl/pkg/manager"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runApplyCommand implements "llamactl apply", which sends a fleet file to a running
+// llamactl server and prints the resulting plan. It returns the process exit code.
+func runApplyCommand(args []string) int {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	file := flags.String("f", "", "fleet file to apply, - for stdin")
+	dryRun := flags.Bool("dry-run", false, "only print the plan, without changing anything")
+	prune := flags.Bool("prune", false, "stop and delete instances that are not listed in the file")
+	serverURL := flags.String("server", "", "URL of the llamactl server (default: the configured host and port)")
+	apiKey := flags.String("api-key", os.Getenv("LLAMACTL_API_KEY"), "management API key (default: $LLAMACTL_API_KEY or the first configured management key)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: llamactl apply -f fleet.yaml [-dry-run] [-prune] [-server url] [-api-key key]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.LoadConfig(os.Getenv("LLAMACTL_CONFIG_PATH"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+	if *serverURL == "" {
+		host := cfg.Server.Host
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "localhost"
+		}
+		*serverURL = "http://" + net.JoinHostPort(host, strconv.Itoa(cfg.Server.Port))
+	}
+	if *apiKey == "" && len(cfg.Auth.ManagementKeys) > 0 {
+		*apiKey = cfg.Auth.ManagementKeys[0]
+	}
+
+	var data []byte
+	if *file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", *file, err)
+		return 1
+	}
+	// Catch mistakes in the file before contacting the server
+	if _, err := manager.ParseFleet(data); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	query := url.Values{}
+	query.Set("dry_run", strconv.FormatBool(*dryRun))
+	query.Set("prune", strconv.FormatBool(*prune))
+	req, err := http.NewRequest("POST", strings.TrimSuffix(*serverURL, "/")+"/api/v1/fleet/apply?"+query.Encode(), bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	req.Header.Set("Content-Type", "application/yaml")
+	if *apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+*apiKey)
+	}
+
+	// Applying waits for instances to stop and start
+	client := &http.Client{Timeout: 30 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error contacting %s: %v\n", *serverURL, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "Error: %s: %s\n", resp.Status, strings.TrimSpace(string(body)))
+		return 1
+	}
+
+	var plan manager.FleetPlan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		fmt.Fprintf(os.Stderr, "Error decoding plan: %v\n", err)
+		return 1
+	}
+	printFleetPlan(os.Stdout, &plan)
+	if plan.Failed() {
+		return 1
+	}
+	return 0
+}
+
+func printFleetPlan(w io.Writer, plan *manager.FleetPlan) {
+	verb := "Applied"
+	if plan.DryRun {
+		verb = "Plan for"
+	}
+	fmt.Fprintf(w, "%s fleet sha256:%s\n", verb, plan.Checksum)
+	if len(plan.Actions) == 0 {
+		fmt.Fprintln(w, "  no changes")
+	}
+	for _, action := range plan.Actions {
+		line := fmt.Sprintf("  %-6s %s", action.Action, action.Instance)
+		if len(action.Changes) > 0 {
+			line += " (" + strings.Join(action.Changes, ", ") + ")"
+		}
+		if action.RestartRequired {
+			line += " [restart]"
+		}
+		if action.Error != "" {
+			line += ": FAILED: " + action.Error
+		}
+		fmt.Fprintln(w, line)
+	}
+	if len(plan.Unlisted) > 0 {
+		fmt.Fprintf(w, "Not in the file, kept without -prune: %s\n", strings.Join(plan.Unlisted, ", "))
+	}
+}
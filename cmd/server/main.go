@@ -2,9 +2,13 @@ package main
 
 import (
 	"fmt"
+	"llamactl/pkg/activation"
 	"llamactl/pkg/config"
 	"llamactl/pkg/manager"
 	"llamactl/pkg/server"
+	"llamactl/pkg/sinks"
+	"llamactl/pkg/storage"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -32,6 +36,16 @@ func main() {
 		return
 	}
 
+	// export/import subcommands copy persisted state between storage backends
+	if len(os.Args) > 1 && (os.Args[1] == "export" || os.Args[1] == "import") {
+		os.Exit(runStorageCommand(os.Args[1], os.Args[2:]))
+	}
+
+	// apply sends a fleet file to a running server
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		os.Exit(runApplyCommand(os.Args[2:]))
+	}
+
 	configPath := os.Getenv("LLAMACTL_CONFIG_PATH")
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
@@ -57,11 +71,30 @@ func main() {
 		}
 	}
 
-	// Initialize the instance manager
-	instanceManager := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	// Open the storage backend for persisted state
+	store, err := storage.Open(cfg.Storage, cfg.Instances)
+	if err != nil {
+		fmt.Printf("Error opening %s storage: %v\n", cfg.Storage.Backend, err)
+		os.Exit(1)
+	}
+
+	// Export events to the configured message brokers, the audit log included
+	exporter, err := sinks.NewExporter(cfg.Sinks)
+	if err != nil {
+		fmt.Printf("Error configuring event sinks: %v\n", err)
+		os.Exit(1)
+	}
+	store = sinks.ExportAuditLog(store, exporter)
+
+	// Initialize the instance manager. The persisted instances are registered, stopped, and
+	// only restored once the server listens, so /readyz and the API answer during the restore.
+	instanceManager := manager.New(cfg.Backends, cfg.Instances, manager.WithStore(store), manager.WithDeferredRestore())
+	instanceManager.SetServices(cfg.Services)
+	exporter.Watch(instanceManager.SubscribeEvents())
 
 	// Create a new handler with the instance manager
-	handler := server.NewHandler(instanceManager, cfg)
+	handler := server.NewHandlerWithStore(instanceManager, cfg, store)
+	handler.SetExporter(exporter)
 
 	// Setup the router with the handler
 	r := server.SetupRouter(handler)
@@ -70,30 +103,72 @@ func main() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
-	server := http.Server{
-		Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler: r,
+	// Use the sockets passed by systemd when socket-activated instead of binding
+	listeners, err := activation.Listeners()
+	if err != nil {
+		fmt.Printf("Error using socket activation: %v\n", err)
+		os.Exit(1)
 	}
 
-	go func() {
-		fmt.Printf("Llamactl server listening on %s:%d\n", cfg.Server.Host, cfg.Server.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	var servers []*http.Server
+	if len(listeners) == 0 {
+		server := &http.Server{
+			Addr:    fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
+			Handler: r,
+		}
+		servers = append(servers, server)
+
+		// Bound before the restore starts, so requests are served from the start
+		ln, err := net.Listen("tcp", server.Addr)
+		if err != nil {
 			fmt.Printf("Error starting server: %v\n", err)
+			os.Exit(1)
 		}
-	}()
+		go func() {
+			fmt.Printf("Llamactl server listening on %s:%d\n", cfg.Server.Host, cfg.Server.Port)
+			if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+				fmt.Printf("Error starting server: %v\n", err)
+			}
+		}()
+	}
+
+	for _, l := range listeners {
+		srv := &http.Server{Handler: server.ForListenerRole(l.Name, r)}
+		servers = append(servers, srv)
+
+		go func(l activation.Listener) {
+			fmt.Printf("Llamactl server listening on socket-activated %s (%s)\n", l.Addr(), l.Name)
+			if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+				fmt.Printf("Error serving on %s: %v\n", l.Addr(), err)
+			}
+		}(l)
+	}
+
+	// Adopt or start again the instances that were running, then reconcile the static ones
+	instanceManager.Restore()
 
 	// Wait for shutdown signal
 	<-stop
 	fmt.Println("Shutting down server...")
 
-	if err := server.Close(); err != nil {
-		fmt.Printf("Error shutting down server: %v\n", err)
-	} else {
-		fmt.Println("Server shut down gracefully.")
+	// Closing an activated listener only closes llamactl's copy of the socket; systemd keeps
+	// it open and queues new connections for the next llamactl
+	for _, srv := range servers {
+		if err := srv.Close(); err != nil {
+			fmt.Printf("Error shutting down server: %v\n", err)
+		} else {
+			fmt.Println("Server shut down gracefully.")
+		}
 	}
 
-	// Wait for all instances to stop
+	// Stop background work driving the instances, then wait for all instances to stop
+	handler.Shutdown()
 	instanceManager.Shutdown()
+	exporter.Close()
+
+	if err := store.Close(); err != nil {
+		fmt.Printf("Error closing storage: %v\n", err)
+	}
 
 	fmt.Println("Exiting llamactl.")
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"llamactl/pkg/config"
+	"llamactl/pkg/storage"
+	"os"
+)
+
+// runStorageCommand implements "llamactl export" and "llamactl import", which dump the
+// persisted state of the configured storage backend to a file and load it back, for
+// example to migrate from the file backend to SQLite. It returns the process exit code.
+func runStorageCommand(command string, args []string) int {
+	flags := flag.NewFlagSet(command, flag.ContinueOnError)
+	backend := flags.String("backend", "", "storage backend to use instead of the configured one (file or sqlite)")
+	path := flags.String("path", "", "SQLite database path to use instead of the configured one")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: llamactl %s [-backend file|sqlite] [-path db] [file]\n", command)
+		fmt.Fprintf(flags.Output(), "Reads from or writes to stdin/stdout when no file is given.\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := config.LoadConfig(os.Getenv("LLAMACTL_CONFIG_PATH"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+	if *backend != "" {
+		cfg.Storage.Backend = *backend
+	}
+	if *path != "" {
+		cfg.Storage.Path = *path
+	}
+
+	store, err := storage.Open(cfg.Storage, cfg.Instances)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening %s storage: %v\n", cfg.Storage.Backend, err)
+		return 1
+	}
+	defer store.Close()
+
+	file := flags.Arg(0)
+	switch command {
+	case "export":
+		var w io.Writer = os.Stdout
+		if file != "" {
+			// The export holds the secrets of the instances, so only the owner may read it
+			f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", file, err)
+				return 1
+			}
+			defer f.Close()
+			w = f
+		}
+		err = storage.Export(store, w)
+	case "import":
+		var r io.Reader = os.Stdin
+		if file != "" {
+			f, err := os.Open(file)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", file, err)
+				return 1
+			}
+			defer f.Close()
+			r = f
+		}
+		err = storage.Import(store, r)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error during %s: %v\n", command, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+// Command embed runs a llama-server instance from a Go program, without the llamactl HTTP
+// server, and prints the lifecycle moments of the instance as they happen:
+//
+//	go run ./examples/embed -model /path/to/model.gguf
+//
+// The backends and instances settings are read from the llamactl configuration, so the
+// instance restarts on crashes and gets a port as it would under the server.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// printHooks prints the lifecycle moments of the instances. Hooks of a registration are
+// called one at a time, so they write to out without locking.
+type printHooks struct {
+	out  io.Writer
+	logs bool // Print the output of the backends too
+}
+
+func (h printHooks) OnStateChange(change manager.StateChange) {
+	fmt.Fprintf(h.out, "%s: %s -> %s (%s)\n", change.Instance, change.OldStatus, change.NewStatus, change.Reason.Message)
+}
+
+func (h printHooks) OnCrash(crash manager.Crash) {
+	fmt.Fprintf(h.out, "%s: crashed with exit code %d: %s\n", crash.Instance, crash.Exit.ExitCode, crash.Exit.Message)
+}
+
+func (h printHooks) OnRestartScheduled(restart manager.RestartScheduled) {
+	fmt.Fprintf(h.out, "%s: restart %d at %s\n", restart.Instance, restart.Attempt, restart.At.Format("15:04:05"))
+}
+
+func (h printHooks) OnReady(ready manager.Ready) {
+	fmt.Fprintf(h.out, "%s: ready\n", ready.Instance)
+}
+
+func (h printHooks) OnLogLine(line manager.LogLine) {
+	if h.logs {
+		fmt.Fprintf(h.out, "%s [%s] %s\n", line.Instance, line.Stream, line.Line)
+	}
+}
+
+// run starts an instance with the given options and keeps it running until stop is
+// closed, then stops it. Persistence is disabled: the instance only lives as long as run.
+func run(cfg config.AppConfig, name string, options *instance.CreateInstanceOptions, hooks manager.Hooks, stop <-chan struct{}) error {
+	mngr := manager.New(cfg.Backends, cfg.Instances, manager.WithStore(nil), manager.WithHooks(hooks))
+	defer mngr.Shutdown()
+
+	if _, err := mngr.CreateInstance(name, options); err != nil {
+		return fmt.Errorf("failed to create instance %s: %w", name, err)
+	}
+	if _, err := mngr.StartInstance(name); err != nil {
+		return fmt.Errorf("failed to start instance %s: %w", name, err)
+	}
+	<-stop
+	return nil
+}
+
+func main() {
+	configPath := flag.String("config", os.Getenv("LLAMACTL_CONFIG_PATH"), "path of the llamactl configuration")
+	name := flag.String("name", "embedded", "name of the instance")
+	model := flag.String("model", "", "path of the model to serve")
+	logs := flag.Bool("logs", false, "print the output of the backend")
+	flag.Parse()
+	if *model == "" {
+		fmt.Fprintln(os.Stderr, "-model is required")
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: *model},
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	if err := run(cfg, *name, options, printHooks{out: os.Stdout, logs: *logs}, stop); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
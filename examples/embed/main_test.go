@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a buffer the test reads while the hooks write to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	// The backend is a shell that logs a line and sleeps, with the health endpoint served here
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	cfg := config.AppConfig{
+		Backends: config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "echo model loaded; exec sleep 30"}}},
+		Instances: config.InstancesConfig{
+			PortRange:              [2]int{8000, 9000},
+			LogsDir:                t.TempDir(),
+			MaxInstances:           1,
+			MaxRunningInstances:    -1,
+			ReadinessProbeInterval: config.Duration(20 * time.Millisecond),
+		},
+	}
+	options := &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  backendURL.Hostname(),
+			Port:  port,
+		},
+	}
+
+	out := &syncBuffer{}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- run(cfg, "embedded", options, printHooks{out: out, logs: true}, stop) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "embedded: ready") {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the instance to be ready, got:\n%s", out)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	// The moments are in order, up to the stop on the way out. Log lines are not ordered
+	// against them.
+	var moments, lines []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if strings.HasPrefix(line, "embedded [") {
+			lines = append(lines, line)
+			continue
+		}
+		moments = append(moments, strings.SplitN(line, " (", 2)[0])
+	}
+	if len(lines) != 1 || lines[0] != "embedded [stdout] model loaded" {
+		t.Errorf("Expected the line of the backend, got %q", lines)
+	}
+	expected := []string{
+		"embedded: stopped -> starting",
+		"embedded: starting -> running",
+		"embedded: ready",
+		"embedded: running -> stopped",
+	}
+	if strings.Join(moments, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected the moments:\n%s\ngot:\n%s", strings.Join(expected, "\n"), out)
+	}
+}
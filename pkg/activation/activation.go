@@ -0,0 +1,74 @@
+// Package activation implements systemd socket activation: the service manager binds the
+// listening sockets and passes them to llamactl, so they stay open across restarts.
+package activation
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFdsStart is the first file descriptor passed by the service manager (SD_LISTEN_FDS_START)
+const listenFdsStart = 3
+
+// Listener is a socket passed by the service manager together with its FileDescriptorName
+type Listener struct {
+	net.Listener
+	Name string
+}
+
+// Listeners returns the sockets passed by the service manager through LISTEN_PID, LISTEN_FDS
+// and LISTEN_FDNAMES. It returns nil when the process was not socket-activated. The
+// environment variables are removed so instances started by llamactl do not see them.
+//
+// Each returned listener owns a duplicate of the passed descriptor; closing it on shutdown
+// leaves the service manager's copy open, so the next llamactl receives the same socket.
+func Listeners() ([]Listener, error) {
+	pid, fds, names := os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if fds == "" {
+		return nil, nil
+	}
+	if pid != strconv.Itoa(os.Getpid()) {
+		// The sockets were meant for another process (e.g. inherited through a wrapper)
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS value %q", fds)
+	}
+
+	var fdNames []string
+	if names != "" {
+		fdNames = strings.Split(names, ":")
+	}
+
+	listeners := make([]Listener, 0, count)
+	for i := range count {
+		name := "unknown" // systemd's name for sockets without FileDescriptorName
+		if i < len(fdNames) && fdNames[i] != "" {
+			name = fdNames[i]
+		}
+
+		f := os.NewFile(uintptr(listenFdsStart+i), name)
+		l, err := net.FileListener(f)
+		// FileListener works on a close-on-exec duplicate, so the original descriptor is
+		// closed here and is not leaked into backend processes
+		f.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("passed file descriptor %d (%s) is not a listening socket: %w", listenFdsStart+i, name, err)
+		}
+		listeners = append(listeners, Listener{Listener: l, Name: name})
+	}
+
+	return listeners, nil
+}
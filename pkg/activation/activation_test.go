@@ -0,0 +1,136 @@
+package activation_test
+
+import (
+	"fmt"
+	"io"
+	"llamactl/pkg/activation"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestListeners_NotActivated(t *testing.T) {
+	t.Setenv("LISTEN_FDS", "")
+	listeners, err := activation.Listeners()
+	if err != nil || listeners != nil {
+		t.Errorf("Expected no listeners without LISTEN_FDS, got %v (%v)", listeners, err)
+	}
+}
+
+func TestListeners_OtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, err := activation.Listeners()
+	if err != nil || listeners != nil {
+		t.Errorf("Expected sockets meant for another process to be ignored, got %v (%v)", listeners, err)
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("Expected LISTEN_FDS to be removed from the environment")
+	}
+}
+
+// TestActivatedHelperProcess runs in the child started by TestListeners_Activated. It
+// answers one connection on every passed socket with the socket's name.
+func TestActivatedHelperProcess(t *testing.T) {
+	if os.Getenv("LLAMACTL_ACTIVATION_HELPER") != "1" {
+		t.Skip("helper process for TestListeners_Activated")
+	}
+
+	listeners, err := activation.Listeners()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		fmt.Fprintln(os.Stderr, "LISTEN_FDS was not removed")
+		os.Exit(1)
+	}
+	for _, l := range listeners {
+		conn, err := l.Accept()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		io.WriteString(conn, l.Name)
+		conn.Close()
+		l.Close()
+	}
+	os.Exit(0)
+}
+
+func TestListeners_Activated(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("socket activation requires a POSIX system")
+	}
+
+	// Pre-bind the sockets the way the service manager would
+	var files []*os.File
+	var listeners []*net.TCPListener
+	for range 2 {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer l.Close()
+		f, err := l.(*net.TCPListener).File()
+		if err != nil {
+			t.Fatalf("Failed to get listener file: %v", err)
+		}
+		defer f.Close()
+		files = append(files, f)
+		listeners = append(listeners, l.(*net.TCPListener))
+	}
+
+	// LISTEN_PID must be the pid of the activated process, which the shell knows before exec
+	cmd := exec.Command("sh", "-c", `LISTEN_PID=$$ exec "$0" "$@"`, os.Args[0], "-test.run=^TestActivatedHelperProcess$")
+	cmd.Env = append(os.Environ(), "LLAMACTL_ACTIVATION_HELPER=1", "LISTEN_FDS=2", "LISTEN_FDNAMES=inference:management")
+	cmd.ExtraFiles = files
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start helper process: %v", err)
+	}
+
+	for i, expected := range []string{"inference", "management"} {
+		if got := readName(t, listeners[i].Addr().String()); got != expected {
+			t.Errorf("Expected socket %d to be named %q, got %q", i, expected, got)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("Helper process failed: %v", err)
+	}
+
+	// The sockets are still usable after the activated process exited
+	done := make(chan error, 1)
+	go func() {
+		conn, err := listeners[0].Accept()
+		if err == nil {
+			io.WriteString(conn, "reused")
+			conn.Close()
+		}
+		done <- err
+	}()
+	if got := readName(t, listeners[0].Addr().String()); got != "reused" {
+		t.Errorf("Expected the socket to be reusable after the helper exited, got %q", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Accept after helper exit failed: %v", err)
+	}
+}
+
+func readName(t *testing.T, addr string) string {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to connect to %s: %v", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Failed to read from %s: %v", addr, err)
+	}
+	return string(data)
+}
@@ -1,12 +1,20 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"maps"
+	"net/netip"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"gopkg.in/yaml.v3"
 )
@@ -37,13 +45,20 @@ type BackendConfig struct {
 
 // AppConfig represents the configuration for llamactl
 type AppConfig struct {
-	Server     ServerConfig    `yaml:"server"`
-	Backends   BackendConfig   `yaml:"backends"`
-	Instances  InstancesConfig `yaml:"instances"`
-	Auth       AuthConfig      `yaml:"auth"`
-	Version    string          `yaml:"-"`
-	CommitHash string          `yaml:"-"`
-	BuildTime  string          `yaml:"-"`
+	Server     ServerConfig             `yaml:"server"`
+	Backends   BackendConfig            `yaml:"backends"`
+	Instances  InstancesConfig          `yaml:"instances"`
+	Auth       AuthConfig               `yaml:"auth"`
+	Services   map[string]ServiceConfig `yaml:"services,omitempty"`
+	Storage    StorageConfig            `yaml:"storage"`
+	Models     map[string]ModelConfig   `yaml:"models,omitempty"`
+	ModelIndex ModelIndexConfig         `yaml:"model_index,omitempty"`
+	Fleet      FleetConfig              `yaml:"fleet,omitempty"`
+	Sinks      []SinkConfig             `yaml:"sinks,omitempty"`
+	Sources    map[string]string        `yaml:"-"` // Source of each instances setting not from the defaults, by key
+	Version    string                   `yaml:"-"`
+	CommitHash string                   `yaml:"-"`
+	BuildTime  string                   `yaml:"-"`
 }
 
 // ServerConfig contains HTTP server configuration
@@ -65,6 +80,10 @@ type ServerConfig struct {
 
 	// Response headers to send with responses
 	ResponseHeaders map[string]string `yaml:"response_headers,omitempty"`
+
+	// Networks (CIDR or single address) allowed to query the load balancer health of the
+	// instances, which needs no API key (empty = any client)
+	LBHealthAllowedNetworks []string `yaml:"lb_health_allowed_networks,omitempty"`
 }
 
 // InstancesConfig contains instance management configuration
@@ -81,6 +100,9 @@ type InstancesConfig struct {
 	// Logs directory override
 	LogsDir string `yaml:"logs_dir"`
 
+	// Slot snapshots directory override
+	SlotsDir string `yaml:"slots_dir"`
+
 	// Automatically create the data directory if it doesn't exist
 	AutoCreateDirs bool `yaml:"auto_create_dirs"`
 
@@ -93,23 +115,343 @@ type InstancesConfig struct {
 	// Enable LRU eviction for instance logs
 	EnableLRUEviction bool `yaml:"enable_lru_eviction"`
 
+	// Executable llama.cpp instances are started with unless they set their own, in place of
+	// the command of the llama.cpp backend
+	LlamaExecutable string `yaml:"llama_executable,omitempty"`
+
 	// Default auto-restart setting for new instances
 	DefaultAutoRestart bool `yaml:"default_auto_restart"`
 
 	// Default max restarts for new instances
 	DefaultMaxRestarts int `yaml:"default_max_restarts"`
 
-	// Default restart delay for new instances (in seconds)
-	DefaultRestartDelay int `yaml:"default_restart_delay"`
+	// Default restart delay for new instances
+	DefaultRestartDelay Duration `yaml:"default_restart_delay"`
+
+	// Time a stopping instance is given to exit after SIGTERM before it is killed, for
+	// instances that do not set their own
+	DefaultStopTimeout Duration `yaml:"default_stop_timeout,omitempty"`
+
+	// Time a stopping instance is given to complete the requests it is serving before it is
+	// sent SIGTERM, for instances that do not set their own (0 = stop right away)
+	DefaultDrainTimeout Duration `yaml:"default_drain_timeout,omitempty"`
+
+	// Maximum number of restarting instances loading their model at the same time, further
+	// restarts wait for one of them to become ready (0 = unlimited)
+	MaxConcurrentRestarts int `yaml:"max_concurrent_restarts"`
+
+	// Also hold manual starts to max_concurrent_restarts instead of letting them bypass it
+	LimitManualStarts bool `yaml:"limit_manual_starts"`
+
+	// Approximate bytes the in-memory buffers of a single instance, the tails of its output
+	// and its events kept for replay, can hold before their oldest entries are evicted
+	// (0 = unlimited)
+	InstanceMemoryLimit int64 `yaml:"instance_memory_limit"`
+
+	// Approximate bytes the in-memory buffers of all instances together can hold (0 = unlimited)
+	MemoryLimit int64 `yaml:"memory_limit"`
 
 	// Default on-demand start setting for new instances
 	DefaultOnDemandStart bool `yaml:"default_on_demand_start"`
 
-	// How long to wait for an instance to start on demand (in seconds)
-	OnDemandStartTimeout int `yaml:"on_demand_start_timeout,omitempty"`
+	// How long to wait for an instance to start on demand, used as the readiness timeout of
+	// instances whose model size is unknown
+	OnDemandStartTimeout Duration `yaml:"on_demand_start_timeout,omitempty"`
+
+	// Time an instance is given to become ready on top of loading its model
+	ReadinessBaseTimeout Duration `yaml:"readiness_base_timeout,omitempty"`
+
+	// Interval between the health checks of a starting instance
+	ReadinessProbeInterval Duration `yaml:"readiness_probe_interval,omitempty"`
+
+	// Assumed model load throughput (in MB/s) readiness timeouts are derived from
+	ModelLoadMBPerSecond int `yaml:"model_load_mb_per_second,omitempty"`
+
+	// Interval for checking instance timeouts (a bare integer is in minutes)
+	TimeoutCheckInterval Duration `yaml:"timeout_check_interval"`
+
+	// Number of days to keep rotated instance log files (0 = keep forever)
+	LogRetentionDays int `yaml:"log_retention_days"`
+
+	// Size in MB at which instance log files are rotated (0 = never)
+	LogRotateSizeMB int `yaml:"log_rotate_size_mb,omitempty"`
+
+	// Size in MB the log files of all instances can use together (0 = unlimited), shared among
+	// the instances by their log_quota_weight
+	LogDiskQuotaMB int `yaml:"log_disk_quota_mb,omitempty"`
+
+	// Size in MB of the most recent logs returned by the logs endpoint unless the request
+	// asks for another size with max_bytes (0 = unlimited)
+	LogResponseSizeMB int `yaml:"log_response_size_mb"`
+
+	// Largest size in MB a request to the logs endpoint can ask for with max_bytes (0 = unlimited)
+	LogResponseMaxSizeMB int `yaml:"log_response_max_size_mb"`
+
+	// Number of whole logs the logs endpoint reads at the same time, others waiting (0 = unlimited)
+	LogReadConcurrency int `yaml:"log_read_concurrency"`
+
+	// Default sanitization of instance log lines: "off", "strip" or "collapse"
+	LogSanitize string `yaml:"log_sanitize"`
+
+	// Number of hours to keep slot snapshots that were not restored (0 = keep forever)
+	SlotRetentionHours int `yaml:"slot_retention_hours"`
+
+	// Time deleted instances are kept in the trash before they are purged (0 = no trash)
+	TrashRetention Duration `yaml:"trash_retention"`
 
-	// Interval for checking instance timeouts (in minutes)
-	TimeoutCheckInterval int `yaml:"timeout_check_interval"`
+	// Time a backend connection of an instance proxy can stay idle before it is reaped
+	BackendIdleTimeout Duration `yaml:"backend_idle_timeout"`
+
+	// Allow instances to disable TLS certificate verification for their backends
+	AllowInsecureBackends bool `yaml:"allow_insecure_backends"`
+
+	// Allow instances to run commands of their choosing on the llamactl host: on_start_cmd,
+	// on_stop_cmd, launch_wrapper and llama_executable
+	AllowInstanceCommands bool `yaml:"allow_instance_commands"`
+
+	// Require ?confirm=true to stop an instance when stopping it would be disruptive
+	RequireStopConfirmation bool `yaml:"require_stop_confirmation"`
+
+	// Leave the backends running when llamactl shuts down, and adopt them back on startup
+	// instead of starting them again (not supported on Windows)
+	LeaveRunning bool `yaml:"leave_running"`
+
+	// Control plane to download the remote models of instances from
+	ModelSource ModelSourceConfig `yaml:"model_source,omitempty"`
+
+	// Regular expressions matched against each line of backend output; a match means the
+	// backend hit a fatal error, so it is killed and restarted per its restart policy
+	FatalLogPatterns []string `yaml:"fatal_log_patterns"`
+
+	// Regular expressions matched against the error output of a backend that exits while
+	// starting; a match means the model does not fit in memory, so it is not restarted
+	AllocFailurePatterns []string `yaml:"alloc_failure_patterns"`
+
+	// Regular expressions matched against the bodies of backend 5xx responses; a match means
+	// the driver evicted the backend from the GPU while the process kept running
+	GPUFaultPatterns []string `yaml:"gpu_fault_patterns"`
+
+	// Matching responses within gpu_fault_window after which an instance has a GPU fault
+	GPUFaultThreshold int `yaml:"gpu_fault_threshold"`
+
+	// Window matching responses are counted over
+	GPUFaultWindow Duration `yaml:"gpu_fault_window"`
+
+	// Instances defined in the configuration file, by name
+	Static map[string]StaticInstanceConfig `yaml:"static,omitempty"`
+}
+
+// UnmarshalYAML reads a bare integer timeout_check_interval as minutes, the unit it was
+// given in before it accepted durations
+func (c *InstancesConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain InstancesConfig
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key, value := node.Content[i], node.Content[i+1]; key.Value == "timeout_check_interval" {
+			d, err := ParseDurationYAML(value, time.Minute)
+			if err != nil {
+				return err
+			}
+			c.TimeoutCheckInterval = d
+		}
+	}
+	return nil
+}
+
+// Sanitization modes of instance log lines
+const (
+	LogSanitizeOff      = "off"      // Lines are stored exactly as the backend wrote them
+	LogSanitizeStrip    = "strip"    // ANSI escape sequences are removed and invalid UTF-8 is replaced
+	LogSanitizeCollapse = "collapse" // As strip, and progress updates overwritten by a carriage return are dropped
+)
+
+// Update policies of static instances
+const (
+	StaticUpdateReject = "reject" // Option updates through the API are rejected
+	StaticUpdateRevert = "revert" // Option updates through the API apply until llamactl restarts
+)
+
+// StaticInstanceConfig defines an instance in the configuration file. It is created, or
+// reconciled with its definition, every time llamactl starts.
+type StaticInstanceConfig struct {
+	// Instance options, with the same keys as the JSON API
+	Options map[string]any `yaml:"options"`
+
+	// State after startup: "running" (default) or "stopped"
+	State string `yaml:"state,omitempty"`
+
+	// Reject deleting the instance through the API
+	Protected bool `yaml:"protected,omitempty"`
+
+	// Option updates through the API: "reject" (default) or "revert"
+	UpdatePolicy string `yaml:"update_policy,omitempty"`
+}
+
+// DefaultFatalLogPatterns match the fatal errors after which llama.cpp may hang instead of exiting
+var DefaultFatalLogPatterns = []string{
+	`GGML_ASSERT\(.*\) failed`,
+	`CUDA error`,
+	`HIP error`,
+	`SYCL error`,
+}
+
+// DefaultAllocFailurePatterns match the errors of llama.cpp and vLLM failing to fit a
+// model in memory
+var DefaultAllocFailurePatterns = []string{
+	`failed to allocate`,
+	`unable to allocate`,
+	`cudaMalloc failed`,
+	`out of memory`,
+	`ErrorOutOfDeviceMemory`,
+}
+
+// DefaultGPUFaultPatterns match the errors llama.cpp and vLLM respond with once their GPU
+// allocations are gone
+var DefaultGPUFaultPatterns = []string{
+	`CUDA error`,
+	`HIP error`,
+	`cudaError`,
+	`CUBLAS_STATUS_`,
+	`illegal memory access`,
+	`ErrorDeviceLost`,
+}
+
+// ModelSourceConfig points a worker node at the control plane serving its model files
+type ModelSourceConfig struct {
+	// Base URL of the control plane (e.g., "http://control-plane:8080")
+	URL string `yaml:"url"`
+
+	// Management API key used to download models
+	APIKey string `yaml:"api_key,omitempty"`
+
+	// Directory where downloaded models are cached (default: <data_dir>/models)
+	CacheDir string `yaml:"cache_dir,omitempty"`
+}
+
+// ModelConfig registers a model file that other nodes can download
+type ModelConfig struct {
+	// Path of the model file
+	Path string `yaml:"path"`
+
+	// Expected SHA-256 of the file; computed on first download if empty
+	SHA256 string `yaml:"sha256,omitempty"`
+}
+
+// ModelIndexConfig enables background indexing of the GGUF files in model directories
+type ModelIndexConfig struct {
+	// Directories scanned recursively for .gguf files; indexing is disabled when empty
+	Dirs []string `yaml:"dirs"`
+
+	// Interval between rescans (in seconds)
+	RescanInterval int `yaml:"rescan_interval"`
+}
+
+// FleetConfig enables watch mode, reconciling the instances against a declarative fleet file
+type FleetConfig struct {
+	// Path or http(s) URL of the fleet file; watch mode is disabled when empty
+	Source string `yaml:"source"`
+
+	// Interval between polls of the source (in seconds)
+	Interval int `yaml:"interval"`
+
+	// Delete instances that are not listed in the fleet file
+	Prune bool `yaml:"prune"`
+}
+
+// SinkConfig exports events to a message broker
+type SinkConfig struct {
+	// Name the sink is reported under (default: its type)
+	Name string `yaml:"name,omitempty"`
+
+	// Broker: "nats", or "kafka" through a Kafka REST proxy
+	Type string `yaml:"type"`
+
+	// nats://host:port of a NATS server, or the http(s) URL of a Kafka REST proxy
+	URL string `yaml:"url"`
+
+	// Subject or topic per event class ("lifecycle", "request" or "audit"); classes left out
+	// are not exported (default: llamactl.<class> for every class)
+	Topics map[string]string `yaml:"topics,omitempty"`
+
+	// Events buffered while the broker is unreachable, dropped beyond (default: 1000)
+	BufferSize int `yaml:"buffer_size,omitempty"`
+
+	// Credentials: NATS user and password, or basic auth of the Kafka REST proxy
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// Sink types
+const (
+	SinkNATS  = "nats"
+	SinkKafka = "kafka"
+)
+
+// Event classes exported to sinks
+const (
+	EventClassLifecycle = "lifecycle" // Instance status changes, rolling restarts, SLO and failover events
+	EventClassRequest   = "request"   // A summary of every proxied request
+	EventClassAudit     = "audit"     // Audit log records
+)
+
+// validateSinks checks the sinks and fills in their defaults
+func validateSinks(sinks []SinkConfig) error {
+	names := make(map[string]bool, len(sinks))
+	for i := range sinks {
+		sink := &sinks[i]
+		if sink.Type != SinkNATS && sink.Type != SinkKafka {
+			return fmt.Errorf("invalid sink type %q: must be %s or %s", sink.Type, SinkNATS, SinkKafka)
+		}
+		if sink.Name == "" {
+			sink.Name = sink.Type
+		}
+		if names[sink.Name] {
+			return fmt.Errorf("duplicate sink name %s", sink.Name)
+		}
+		names[sink.Name] = true
+
+		if sink.URL == "" {
+			return fmt.Errorf("sink %s requires a url", sink.Name)
+		}
+		if sink.BufferSize < 0 {
+			return fmt.Errorf("buffer_size of sink %s cannot be negative", sink.Name)
+		}
+		if sink.BufferSize == 0 {
+			sink.BufferSize = 1000
+		}
+		if len(sink.Topics) == 0 {
+			sink.Topics = map[string]string{}
+			for _, class := range []string{EventClassLifecycle, EventClassRequest, EventClassAudit} {
+				sink.Topics[class] = "llamactl." + class
+			}
+		}
+		for class, topic := range sink.Topics {
+			switch class {
+			case EventClassLifecycle, EventClassRequest, EventClassAudit:
+			default:
+				return fmt.Errorf("invalid event class %q in the topics of sink %s: must be %s, %s or %s", class, sink.Name, EventClassLifecycle, EventClassRequest, EventClassAudit)
+			}
+			if topic == "" {
+				return fmt.Errorf("empty topic for %s events of sink %s", class, sink.Name)
+			}
+			// NATS takes the subject in the PUB line as is
+			if sink.Type == SinkNATS && strings.ContainsFunc(topic, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) {
+				return fmt.Errorf("invalid topic %q for %s events of sink %s: NATS subjects cannot contain whitespace or control characters", topic, class, sink.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// StorageConfig selects where instance definitions and other persisted state are stored
+type StorageConfig struct {
+	// Storage backend: "file" (JSON files under the data directory) or "sqlite"
+	Backend string `yaml:"backend"`
+
+	// SQLite database path (default: <data_dir>/llamactl.db)
+	Path string `yaml:"path,omitempty"`
 }
 
 // AuthConfig contains authentication settings
@@ -126,12 +468,277 @@ type AuthConfig struct {
 
 	// List of keys for management endpoints
 	ManagementKeys []string `yaml:"management_keys"`
+
+	// Request priority ("low", "normal", "high") for API keys, used when a request has no
+	// X-Priority header and capping the header otherwise
+	KeyPriorities map[string]string `yaml:"key_priorities,omitempty"`
+
+	// Request and token budgets for API keys
+	KeyQuotas map[string]KeyQuotaConfig `yaml:"key_quotas,omitempty"`
+
+	// Bearer tokens of an OpenID Connect provider accepted on management endpoints
+	JWT JWTAuthConfig `yaml:"jwt,omitempty"`
+
+	// Inference requests signed by a trusted gateway, accepted alongside inference API keys
+	RequestSigning RequestSigningConfig `yaml:"request_signing,omitempty"`
+}
+
+// RequestSigningConfig verifies inference requests signed with a shared secret by a gateway
+// that authenticates the end users itself. Verification is enabled when a secret is set.
+type RequestSigningConfig struct {
+	// Shared secrets a signature may be made with; listing several allows rotating them
+	Secrets []string `yaml:"secrets,omitempty"`
+
+	// Seconds a signed timestamp may differ from the server clock (default: 300). Nonces are
+	// remembered as long, so a signed request cannot be replayed.
+	ClockSkew int `yaml:"clock_skew,omitempty"`
+
+	// Bytes of body a signed request may carry (default: 32 MiB); the body is read whole to be
+	// hashed, so larger ones are rejected before the signature is checked
+	MaxBodySize int64 `yaml:"max_body_size,omitempty"`
+}
+
+// Permission scopes of management API identities, from least to most privileged
+const (
+	ScopeReadOnly = "read-only" // Read instances, logs and stats
+	ScopeOperator = "operator"  // Also start, stop and restart instances and use their backends
+	ScopeAdmin    = "admin"     // Also create, update and delete instances and change the fleet
+)
+
+// JWTAuthConfig validates JWTs signed by an OpenID Connect provider. Validation is enabled
+// when an issuer is set; management API keys keep working alongside tokens.
+type JWTAuthConfig struct {
+	// Expected "iss" claim; the JWKS URL is discovered from <issuer>/.well-known/openid-configuration
+	Issuer string `yaml:"issuer,omitempty"`
+
+	// URL of the signing keys, overriding discovery
+	JWKSURL string `yaml:"jwks_url,omitempty"`
+
+	// Value the "aud" claim must contain
+	Audience string `yaml:"audience,omitempty"`
+
+	// Claim identifying the caller in the audit log (default: "sub")
+	IdentityClaim string `yaml:"identity_claim,omitempty"`
+
+	// Claim holding the groups or roles mapped by Roles (default: "groups")
+	RolesClaim string `yaml:"roles_claim,omitempty"`
+
+	// Permissions granted by each value of the roles claim. Tokens matching no role are rejected.
+	Roles map[string]JWTRoleConfig `yaml:"roles,omitempty"`
+
+	// Seconds between refreshes of the signing keys (default: 3600). Keys are also refreshed
+	// when a token names an unknown key, at most once a minute.
+	JWKSRefreshInterval int `yaml:"jwks_refresh_interval,omitempty"`
+
+	// Seconds of clock skew tolerated when checking "exp" and "nbf" (default: 60)
+	ClockSkew int `yaml:"clock_skew,omitempty"`
+}
+
+// JWTRoleConfig is the permission granted to the tokens carrying a role
+type JWTRoleConfig struct {
+	// "read-only", "operator" or "admin"
+	Scope string `yaml:"scope"`
+
+	// Instance name patterns (as in path.Match) the role is limited to; none means every instance
+	Namespaces []string `yaml:"namespaces,omitempty"`
+}
+
+// KeyQuotaConfig limits the requests and tokens an API key may use per window; a zero limit is unlimited
+type KeyQuotaConfig struct {
+	// Maximum number of requests per window
+	Requests int64 `yaml:"requests,omitempty"`
+
+	// Maximum number of prompt and completion tokens per window
+	Tokens int64 `yaml:"tokens,omitempty"`
+
+	// "calendar_month" (default) resets on the first of the month (UTC), "rolling" counts the last rolling_days days
+	Window string `yaml:"window,omitempty"`
+
+	// Length of a rolling window in days (default: 30)
+	RollingDays int `yaml:"rolling_days,omitempty"`
+}
+
+// ServiceConfig groups instances that serve the same model behind a single alias
+type ServiceConfig struct {
+	// Instances serving this service
+	Members []string `yaml:"members"`
+
+	// Services this service depends on; a dependency that is down takes this service down
+	DependsOn []string `yaml:"depends_on,omitempty"`
+
+	// Fraction of ready members below which the service is degraded (default: 0.5)
+	DegradedThreshold float64 `yaml:"degraded_threshold,omitempty"`
+
+	// Recent error rate above which a member counts as erroring (default: 0.25)
+	ErrorRateThreshold float64 `yaml:"error_rate_threshold,omitempty"`
+
+	// Minimum recent requests before a member's error rate is considered (default: 10)
+	MinRequests int `yaml:"min_requests,omitempty"`
+
+	// Placement of the members on GPUs: "anti_affinity" keeps them on distinct GPUs so a
+	// single GPU failure cannot take the service down, "affinity" keeps them on the same
+	// GPUs, as for a draft model next to its main model (default: no constraint)
+	Placement string `yaml:"placement,omitempty"`
+
+	// Whether a member breaking the placement is still created and started, with a warning
+	// ("prefer", default), or refused ("require")
+	PlacementMode string `yaml:"placement_mode,omitempty"`
+
+	// Hot standby: requests for the alias go to the primary while it is healthy, and to the
+	// standby, kept loaded without traffic, once the primary fails its health checks. Both
+	// are members of the service whether or not they are listed.
+	Primary string `yaml:"primary,omitempty"`
+	Standby string `yaml:"standby,omitempty"`
+
+	// Return to the primary once it is healthy again: "manual" (default) or "automatic"
+	Failback string `yaml:"failback,omitempty"`
+
+	// Time the primary must stay healthy before an automatic failback (default: 5m)
+	FailbackAfter Duration `yaml:"failback_after,omitempty"`
+
+	// Consecutive failed health checks of the primary before failing over (default: 3)
+	FailureThreshold int `yaml:"failure_threshold,omitempty"`
+
+	// Interval between health checks of the primary and the standby (default: 5s)
+	HealthCheckInterval Duration `yaml:"health_check_interval,omitempty"`
+
+	// Weighted routing: requests for the alias are split between instances in proportion to
+	// their weight, such as 95 to the stable instance and 5 to a canary. Every weighted
+	// instance is a member of the service whether or not it is listed.
+	Weights map[string]int `yaml:"weights,omitempty"`
+
+	// Weighted instance the guardrail watches
+	Canary string `yaml:"canary,omitempty"`
+
+	// Zero the weight of the canary once its error rate gets too high (default: no guardrail)
+	Guardrail *CanaryGuardrail `yaml:"guardrail,omitempty"`
+}
+
+// CanaryGuardrail takes a canary out of the routing of its service when its requests fail
+type CanaryGuardrail struct {
+	// Error rate of the requests routed to the canary over the last minute above which its
+	// weight is set to 0
+	MaxErrorRate float64 `yaml:"max_error_rate" json:"max_error_rate"`
+
+	// Minimum recent requests before the error rate is considered (default: 20)
+	MinRequests int `yaml:"min_requests,omitempty" json:"min_requests,omitempty"`
+}
+
+// Failback policies of services with a standby
+const (
+	FailbackManual    = "manual"    // The standby stays active until a failback is requested
+	FailbackAutomatic = "automatic" // The primary becomes active again once it stayed healthy for failback_after
+)
+
+// GPU placement policies and modes of services
+const (
+	PlacementAntiAffinity = "anti_affinity" // Members run on distinct GPUs
+	PlacementAffinity     = "affinity"      // Members run on the same GPUs
+	PlacementPrefer       = "prefer"        // A member breaking the policy is allowed with a warning
+	PlacementRequire      = "require"       // A member breaking the policy is refused
+)
+
+// validateServices checks the placement, standby and routing settings of the services and adds
+// their primary, standby and weighted instances to the members
+func validateServices(services map[string]ServiceConfig) error {
+	for alias, svc := range services {
+		if len(svc.Weights) > 0 || svc.Canary != "" || svc.Guardrail != nil {
+			if svc.Primary != "" || svc.Standby != "" {
+				return fmt.Errorf("service %s cannot set both weights and a primary and standby", alias)
+			}
+			if err := ValidateRouteWeights(svc.Weights, svc.Canary, svc.Guardrail); err != nil {
+				return fmt.Errorf("service %s: %w", alias, err)
+			}
+			weighted := slices.Sorted(maps.Keys(svc.Weights))
+			for _, member := range weighted {
+				if !slices.Contains(svc.Members, member) {
+					svc.Members = append(svc.Members, member)
+				}
+			}
+			services[alias] = svc
+		}
+
+		switch svc.Placement {
+		case "", PlacementAntiAffinity, PlacementAffinity:
+		default:
+			return fmt.Errorf("invalid placement %q for service %s: must be %s or %s", svc.Placement, alias, PlacementAntiAffinity, PlacementAffinity)
+		}
+		switch svc.PlacementMode {
+		case "", PlacementPrefer, PlacementRequire:
+		default:
+			return fmt.Errorf("invalid placement_mode %q for service %s: must be %s or %s", svc.PlacementMode, alias, PlacementPrefer, PlacementRequire)
+		}
+
+		if svc.Primary == "" && svc.Standby == "" {
+			continue
+		}
+		if svc.Primary == "" || svc.Standby == "" {
+			return fmt.Errorf("service %s must set both a primary and a standby", alias)
+		}
+		if svc.Primary == svc.Standby {
+			return fmt.Errorf("service %s cannot use instance %s as both its primary and its standby", alias, svc.Primary)
+		}
+		switch svc.Failback {
+		case "", FailbackManual, FailbackAutomatic:
+		default:
+			return fmt.Errorf("invalid failback %q for service %s: must be %s or %s", svc.Failback, alias, FailbackManual, FailbackAutomatic)
+		}
+		if svc.FailbackAfter < 0 || svc.HealthCheckInterval < 0 || svc.FailureThreshold < 0 {
+			return fmt.Errorf("failback_after, health_check_interval and failure_threshold of service %s cannot be negative", alias)
+		}
+		for _, member := range []string{svc.Primary, svc.Standby} {
+			if !slices.Contains(svc.Members, member) {
+				svc.Members = append(svc.Members, member)
+			}
+		}
+		services[alias] = svc
+	}
+	return nil
+}
+
+// ValidateRouteWeights checks the weights of a service with weighted routing, its canary and
+// the guardrail of the canary
+func ValidateRouteWeights(weights map[string]int, canary string, guardrail *CanaryGuardrail) error {
+	if len(weights) == 0 {
+		return fmt.Errorf("weights are required")
+	}
+	total := 0
+	for member, weight := range weights {
+		if member == "" {
+			return fmt.Errorf("weights cannot name an empty instance")
+		}
+		if weight < 0 {
+			return fmt.Errorf("weight of instance %s cannot be negative", member)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one weight must be positive")
+	}
+	if canary != "" {
+		if _, ok := weights[canary]; !ok {
+			return fmt.Errorf("canary %s must have a weight", canary)
+		}
+	}
+	if guardrail != nil {
+		if canary == "" {
+			return fmt.Errorf("a guardrail requires a canary")
+		}
+		if guardrail.MaxErrorRate <= 0 || guardrail.MaxErrorRate > 1 {
+			return fmt.Errorf("max_error_rate of the guardrail must be greater than 0 and at most 1")
+		}
+		if guardrail.MinRequests < 0 {
+			return fmt.Errorf("min_requests of the guardrail cannot be negative")
+		}
+	}
+	return nil
 }
 
 // LoadConfig loads configuration with the following precedence:
 // 1. Hardcoded defaults
 // 2. Config file
 // 3. Environment variables
+// 4. Overrides made at runtime through the API, kept in the data directory
 func LoadConfig(configPath string) (AppConfig, error) {
 	// 1. Start with defaults
 	cfg := AppConfig{
@@ -180,25 +787,70 @@ func LoadConfig(configPath string) (AppConfig, error) {
 			DataDir:   getDefaultDataDirectory(),
 			// NOTE: empty strings are set as placeholder values since InstancesDir and LogsDir
 			// should be relative path to DataDir if not explicitly set.
-			InstancesDir:         "",
-			LogsDir:              "",
-			AutoCreateDirs:       true,
-			MaxInstances:         -1, // -1 means unlimited
-			MaxRunningInstances:  -1, // -1 means unlimited
-			EnableLRUEviction:    true,
-			DefaultAutoRestart:   true,
-			DefaultMaxRestarts:   3,
-			DefaultRestartDelay:  5,
-			DefaultOnDemandStart: true,
-			OnDemandStartTimeout: 120, // 2 minutes
-			TimeoutCheckInterval: 5,   // Check timeouts every 5 minutes
+			InstancesDir:            "",
+			LogsDir:                 "",
+			AutoCreateDirs:          true,
+			MaxInstances:            -1, // -1 means unlimited
+			MaxRunningInstances:     -1, // -1 means unlimited
+			EnableLRUEviction:       true,
+			DefaultAutoRestart:      true,
+			DefaultMaxRestarts:      3,
+			DefaultRestartDelay:     Duration(5 * time.Second),
+			DefaultStopTimeout:      Duration(30 * time.Second),
+			DefaultDrainTimeout:     Duration(30 * time.Second),
+			DefaultOnDemandStart:    true,
+			OnDemandStartTimeout:    Duration(2 * time.Minute),
+			ReadinessBaseTimeout:    Duration(30 * time.Second), // Plus the time to load the model
+			ReadinessProbeInterval:  Duration(time.Second),      // Between health checks while starting
+			ModelLoadMBPerSecond:    100,                        // 100 MB/s
+			InstanceMemoryLimit:     4 << 20,                    // 4 MiB per instance
+			MemoryLimit:             64 << 20,                   // 64 MiB in total
+			TimeoutCheckInterval:    Duration(5 * time.Minute),
+			TrashRetention:          Duration(7 * 24 * time.Hour),
+			LogRetentionDays:        0,                          // Keep rotated logs forever
+			LogResponseSizeMB:       10,                         // Last 10 MB of logs unless asked for more
+			LogResponseMaxSizeMB:    100,                        // Up to 100 MB when asked
+			LogReadConcurrency:      2,                          // Two whole logs read at a time
+			SlotRetentionHours:      24,                         // Remove unrestored slot snapshots after a day
+			BackendIdleTimeout:      Duration(90 * time.Second), // Reap idle connections
+			AllowInsecureBackends:   false,
+			AllowInstanceCommands:   false,
+			RequireStopConfirmation: false,
+			LeaveRunning:            false,
+			LogSanitize:             LogSanitizeCollapse,
+			FatalLogPatterns:        DefaultFatalLogPatterns,
+			AllocFailurePatterns:    DefaultAllocFailurePatterns,
+			GPUFaultPatterns:        DefaultGPUFaultPatterns,
+			GPUFaultThreshold:       3,
+			GPUFaultWindow:          Duration(time.Minute),
 		},
 		Auth: AuthConfig{
 			RequireInferenceAuth:  true,
 			InferenceKeys:         []string{},
 			RequireManagementAuth: true,
 			ManagementKeys:        []string{},
+			JWT: JWTAuthConfig{
+				IdentityClaim:       "sub",
+				RolesClaim:          "groups",
+				JWKSRefreshInterval: 3600,
+				ClockSkew:           60,
+			},
+			RequestSigning: RequestSigningConfig{
+				ClockSkew:   300,
+				MaxBodySize: 32 << 20,
+			},
+		},
+		Storage: StorageConfig{
+			Backend: "file",
 		},
+		ModelIndex: ModelIndexConfig{
+			Dirs:           []string{},
+			RescanInterval: 300, // Rescan every 5 minutes
+		},
+		Fleet: FleetConfig{
+			Interval: 60, // Poll every minute
+		},
+		Sources: map[string]string{},
 	}
 
 	// 2. Load from config file
@@ -208,6 +860,12 @@ func LoadConfig(configPath string) (AppConfig, error) {
 
 	// 3. Override with environment variables
 	loadEnvVars(&cfg)
+	markEnvSources(&cfg)
+
+	// 4. Override with the settings changed at runtime
+	if err := loadOverrides(&cfg); err != nil {
+		return cfg, err
+	}
 
 	// If InstancesDir or LogsDir is not set, set it to relative path of DataDir
 	if cfg.Instances.InstancesDir == "" {
@@ -216,10 +874,206 @@ func LoadConfig(configPath string) (AppConfig, error) {
 	if cfg.Instances.LogsDir == "" {
 		cfg.Instances.LogsDir = filepath.Join(cfg.Instances.DataDir, "logs")
 	}
+	if cfg.Instances.SlotsDir == "" {
+		cfg.Instances.SlotsDir = filepath.Join(cfg.Instances.DataDir, "slots")
+	}
+	if cfg.Instances.ModelSource.CacheDir == "" {
+		cfg.Instances.ModelSource.CacheDir = filepath.Join(cfg.Instances.DataDir, "models")
+	}
+	if cfg.Storage.Path == "" {
+		cfg.Storage.Path = filepath.Join(cfg.Instances.DataDir, "llamactl.db")
+	}
+
+	for key, quota := range cfg.Auth.KeyQuotas {
+		if quota.Window != "" && quota.Window != "calendar_month" && quota.Window != "rolling" {
+			return cfg, fmt.Errorf("invalid quota window %q for key %s: must be calendar_month or rolling", quota.Window, maskKey(key))
+		}
+		if quota.Requests < 0 || quota.Tokens < 0 || quota.RollingDays < 0 {
+			return cfg, fmt.Errorf("quota limits for key %s cannot be negative", maskKey(key))
+		}
+	}
+
+	if err := validateServer(cfg.Server); err != nil {
+		return cfg, err
+	}
+
+	if err := validateJWTAuth(cfg.Auth.JWT); err != nil {
+		return cfg, err
+	}
+
+	if err := validateRequestSigning(cfg.Auth.RequestSigning); err != nil {
+		return cfg, err
+	}
+
+	if err := validateServices(cfg.Services); err != nil {
+		return cfg, err
+	}
+
+	if err := validateSinks(cfg.Sinks); err != nil {
+		return cfg, err
+	}
+
+	if err := validateInstances(cfg.Instances); err != nil {
+		return cfg, err
+	}
 
 	return cfg, nil
 }
 
+// validateServer checks the HTTP server settings
+func validateServer(c ServerConfig) error {
+	for _, network := range c.LBHealthAllowedNetworks {
+		if _, err := ParseNetwork(network); err != nil {
+			return fmt.Errorf("invalid lb_health_allowed_networks entry %q: %w", network, err)
+		}
+	}
+	return nil
+}
+
+// ParseNetwork parses a network in CIDR notation, or a single address as the network
+// holding only it
+func ParseNetwork(network string) (netip.Prefix, error) {
+	network = strings.TrimSpace(network)
+	if strings.Contains(network, "/") {
+		prefix, err := netip.ParsePrefix(network)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(network)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// validateInstances checks the instances settings
+func validateInstances(c InstancesConfig) error {
+	for name, static := range c.Static {
+		if static.State != "" && static.State != "running" && static.State != "stopped" {
+			return fmt.Errorf("invalid state %q for static instance %s: must be running or stopped", static.State, name)
+		}
+		if static.UpdatePolicy != "" && static.UpdatePolicy != StaticUpdateReject && static.UpdatePolicy != StaticUpdateRevert {
+			return fmt.Errorf("invalid update policy %q for static instance %s: must be %s or %s", static.UpdatePolicy, name, StaticUpdateReject, StaticUpdateRevert)
+		}
+	}
+
+	switch c.LogSanitize {
+	case "", LogSanitizeOff, LogSanitizeStrip, LogSanitizeCollapse:
+	default:
+		return fmt.Errorf("invalid log_sanitize %q: must be %s, %s or %s", c.LogSanitize, LogSanitizeOff, LogSanitizeStrip, LogSanitizeCollapse)
+	}
+
+	if c.TrashRetention < 0 {
+		return fmt.Errorf("invalid trash_retention %s: cannot be negative", c.TrashRetention)
+	}
+	if c.LogRotateSizeMB < 0 {
+		return fmt.Errorf("invalid log_rotate_size_mb %d: cannot be negative", c.LogRotateSizeMB)
+	}
+	if c.LogDiskQuotaMB < 0 {
+		return fmt.Errorf("invalid log_disk_quota_mb %d: cannot be negative", c.LogDiskQuotaMB)
+	}
+	if c.LogResponseSizeMB < 0 {
+		return fmt.Errorf("invalid log_response_size_mb %d: cannot be negative", c.LogResponseSizeMB)
+	}
+	if c.LogResponseMaxSizeMB < 0 {
+		return fmt.Errorf("invalid log_response_max_size_mb %d: cannot be negative", c.LogResponseMaxSizeMB)
+	}
+	if c.LogResponseMaxSizeMB > 0 && (c.LogResponseSizeMB == 0 || c.LogResponseSizeMB > c.LogResponseMaxSizeMB) {
+		return fmt.Errorf("invalid log_response_size_mb %d: must be between 1 and log_response_max_size_mb %d", c.LogResponseSizeMB, c.LogResponseMaxSizeMB)
+	}
+	if c.LogReadConcurrency < 0 {
+		return fmt.Errorf("invalid log_read_concurrency %d: cannot be negative", c.LogReadConcurrency)
+	}
+	if c.MaxConcurrentRestarts < 0 {
+		return fmt.Errorf("invalid max_concurrent_restarts %d: cannot be negative", c.MaxConcurrentRestarts)
+	}
+	if c.InstanceMemoryLimit < 0 {
+		return fmt.Errorf("invalid instance_memory_limit %d: cannot be negative", c.InstanceMemoryLimit)
+	}
+	if c.MemoryLimit < 0 {
+		return fmt.Errorf("invalid memory_limit %d: cannot be negative", c.MemoryLimit)
+	}
+
+	for setting, d := range map[string]Duration{
+		"default_restart_delay":    c.DefaultRestartDelay,
+		"default_stop_timeout":     c.DefaultStopTimeout,
+		"default_drain_timeout":    c.DefaultDrainTimeout,
+		"on_demand_start_timeout":  c.OnDemandStartTimeout,
+		"readiness_base_timeout":   c.ReadinessBaseTimeout,
+		"readiness_probe_interval": c.ReadinessProbeInterval,
+		"timeout_check_interval":   c.TimeoutCheckInterval,
+		"backend_idle_timeout":     c.BackendIdleTimeout,
+		"gpu_fault_window":         c.GPUFaultWindow,
+	} {
+		if d < 0 {
+			return fmt.Errorf("invalid %s %s: cannot be negative", setting, d)
+		}
+	}
+
+	for _, pattern := range c.FatalLogPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid fatal log pattern %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range c.AllocFailurePatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid allocation failure pattern %q: %w", pattern, err)
+		}
+	}
+	for _, pattern := range c.GPUFaultPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid GPU fault pattern %q: %w", pattern, err)
+		}
+	}
+	if c.GPUFaultThreshold < 0 {
+		return fmt.Errorf("invalid gpu_fault_threshold %d: cannot be negative", c.GPUFaultThreshold)
+	}
+
+	return nil
+}
+
+// validateJWTAuth checks the token validation settings when an issuer is configured
+func validateJWTAuth(jwt JWTAuthConfig) error {
+	if jwt.Issuer == "" {
+		return nil
+	}
+	if jwt.Audience == "" {
+		return fmt.Errorf("jwt audience is required when an issuer is configured")
+	}
+	if jwt.JWKSRefreshInterval <= 0 || jwt.ClockSkew < 0 {
+		return fmt.Errorf("jwt jwks_refresh_interval must be positive and clock_skew cannot be negative")
+	}
+	for role, mapping := range jwt.Roles {
+		switch mapping.Scope {
+		case ScopeReadOnly, ScopeOperator, ScopeAdmin:
+		default:
+			return fmt.Errorf("invalid scope %q for jwt role %s: must be %s, %s or %s", mapping.Scope, role, ScopeReadOnly, ScopeOperator, ScopeAdmin)
+		}
+		for _, namespace := range mapping.Namespaces {
+			if _, err := path.Match(namespace, ""); err != nil {
+				return fmt.Errorf("invalid namespace %q for jwt role %s: %w", namespace, role, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRequestSigning checks the request signing settings when a secret is set
+func validateRequestSigning(signing RequestSigningConfig) error {
+	if len(signing.Secrets) == 0 {
+		return nil
+	}
+	if slices.Contains(signing.Secrets, "") {
+		return fmt.Errorf("request_signing secrets cannot be empty")
+	}
+	if signing.ClockSkew <= 0 {
+		return fmt.Errorf("request_signing clock_skew must be positive")
+	}
+	if signing.MaxBodySize <= 0 {
+		return fmt.Errorf("request_signing max_body_size must be positive")
+	}
+	return nil
+}
+
 // loadConfigFile attempts to load config from file with fallback locations
 func loadConfigFile(cfg *AppConfig, configPath string) error {
 	var configLocations []string
@@ -237,6 +1091,7 @@ func loadConfigFile(cfg *AppConfig, configPath string) error {
 			if err := yaml.Unmarshal(data, cfg); err != nil {
 				return err
 			}
+			markFileSources(cfg, data)
 			log.Printf("Read config at %s", path)
 			return nil
 		}
@@ -259,6 +1114,9 @@ func loadEnvVars(cfg *AppConfig) {
 	if allowedOrigins := os.Getenv("LLAMACTL_ALLOWED_ORIGINS"); allowedOrigins != "" {
 		cfg.Server.AllowedOrigins = strings.Split(allowedOrigins, ",")
 	}
+	if networks := os.Getenv("LLAMACTL_LB_HEALTH_ALLOWED_NETWORKS"); networks != "" {
+		cfg.Server.LBHealthAllowedNetworks = strings.Split(networks, ",")
+	}
 	if enableSwagger := os.Getenv("LLAMACTL_ENABLE_SWAGGER"); enableSwagger != "" {
 		if b, err := strconv.ParseBool(enableSwagger); err == nil {
 			cfg.Server.EnableSwagger = b
@@ -275,6 +1133,9 @@ func loadEnvVars(cfg *AppConfig) {
 	if logsDir := os.Getenv("LLAMACTL_LOGS_DIR"); logsDir != "" {
 		cfg.Instances.LogsDir = logsDir
 	}
+	if slotsDir := os.Getenv("LLAMACTL_SLOTS_DIR"); slotsDir != "" {
+		cfg.Instances.SlotsDir = slotsDir
+	}
 	if autoCreate := os.Getenv("LLAMACTL_AUTO_CREATE_DATA_DIR"); autoCreate != "" {
 		if b, err := strconv.ParseBool(autoCreate); err == nil {
 			cfg.Instances.AutoCreateDirs = b
@@ -433,25 +1294,180 @@ func loadEnvVars(cfg *AppConfig) {
 		}
 	}
 	if restartDelay := os.Getenv("LLAMACTL_DEFAULT_RESTART_DELAY"); restartDelay != "" {
-		if seconds, err := strconv.Atoi(restartDelay); err == nil {
-			cfg.Instances.DefaultRestartDelay = seconds
+		if d, err := ParseDuration(restartDelay, time.Second); err == nil {
+			cfg.Instances.DefaultRestartDelay = d
+		}
+	}
+	if stopTimeout := os.Getenv("LLAMACTL_DEFAULT_STOP_TIMEOUT"); stopTimeout != "" {
+		if d, err := ParseDuration(stopTimeout, time.Second); err == nil {
+			cfg.Instances.DefaultStopTimeout = d
+		}
+	}
+	if drainTimeout := os.Getenv("LLAMACTL_DEFAULT_DRAIN_TIMEOUT"); drainTimeout != "" {
+		if d, err := ParseDuration(drainTimeout, time.Second); err == nil {
+			cfg.Instances.DefaultDrainTimeout = d
 		}
 	}
+	if llamaExecutable := os.Getenv("LLAMACTL_LLAMA_EXECUTABLE"); llamaExecutable != "" {
+		cfg.Instances.LlamaExecutable = llamaExecutable
+	}
 	if onDemandStart := os.Getenv("LLAMACTL_DEFAULT_ON_DEMAND_START"); onDemandStart != "" {
 		if b, err := strconv.ParseBool(onDemandStart); err == nil {
 			cfg.Instances.DefaultOnDemandStart = b
 		}
 	}
 	if onDemandTimeout := os.Getenv("LLAMACTL_ON_DEMAND_START_TIMEOUT"); onDemandTimeout != "" {
-		if seconds, err := strconv.Atoi(onDemandTimeout); err == nil {
-			cfg.Instances.OnDemandStartTimeout = seconds
+		if d, err := ParseDuration(onDemandTimeout, time.Second); err == nil {
+			cfg.Instances.OnDemandStartTimeout = d
+		}
+	}
+	if baseTimeout := os.Getenv("LLAMACTL_READINESS_BASE_TIMEOUT"); baseTimeout != "" {
+		if d, err := ParseDuration(baseTimeout, time.Second); err == nil {
+			cfg.Instances.ReadinessBaseTimeout = d
+		}
+	}
+	if probeInterval := os.Getenv("LLAMACTL_READINESS_PROBE_INTERVAL"); probeInterval != "" {
+		if d, err := ParseDuration(probeInterval, time.Second); err == nil {
+			cfg.Instances.ReadinessProbeInterval = d
+		}
+	}
+	if loadRate := os.Getenv("LLAMACTL_MODEL_LOAD_MB_PER_SECOND"); loadRate != "" {
+		if rate, err := strconv.Atoi(loadRate); err == nil {
+			cfg.Instances.ModelLoadMBPerSecond = rate
 		}
 	}
 	if timeoutCheckInterval := os.Getenv("LLAMACTL_TIMEOUT_CHECK_INTERVAL"); timeoutCheckInterval != "" {
-		if minutes, err := strconv.Atoi(timeoutCheckInterval); err == nil {
-			cfg.Instances.TimeoutCheckInterval = minutes
+		if d, err := ParseDuration(timeoutCheckInterval, time.Minute); err == nil {
+			cfg.Instances.TimeoutCheckInterval = d
+		}
+	}
+	if logRetentionDays := os.Getenv("LLAMACTL_LOG_RETENTION_DAYS"); logRetentionDays != "" {
+		if days, err := strconv.Atoi(logRetentionDays); err == nil {
+			cfg.Instances.LogRetentionDays = days
+		}
+	}
+	if logRotateSize := os.Getenv("LLAMACTL_LOG_ROTATE_SIZE_MB"); logRotateSize != "" {
+		if size, err := strconv.Atoi(logRotateSize); err == nil {
+			cfg.Instances.LogRotateSizeMB = size
+		}
+	}
+	if logDiskQuota := os.Getenv("LLAMACTL_LOG_DISK_QUOTA_MB"); logDiskQuota != "" {
+		if size, err := strconv.Atoi(logDiskQuota); err == nil {
+			cfg.Instances.LogDiskQuotaMB = size
+		}
+	}
+	if responseSize := os.Getenv("LLAMACTL_LOG_RESPONSE_SIZE_MB"); responseSize != "" {
+		if size, err := strconv.Atoi(responseSize); err == nil {
+			cfg.Instances.LogResponseSizeMB = size
+		}
+	}
+	if responseMaxSize := os.Getenv("LLAMACTL_LOG_RESPONSE_MAX_SIZE_MB"); responseMaxSize != "" {
+		if size, err := strconv.Atoi(responseMaxSize); err == nil {
+			cfg.Instances.LogResponseMaxSizeMB = size
+		}
+	}
+	if readConcurrency := os.Getenv("LLAMACTL_LOG_READ_CONCURRENCY"); readConcurrency != "" {
+		if n, err := strconv.Atoi(readConcurrency); err == nil {
+			cfg.Instances.LogReadConcurrency = n
+		}
+	}
+	if logSanitize := os.Getenv("LLAMACTL_LOG_SANITIZE"); logSanitize != "" {
+		cfg.Instances.LogSanitize = logSanitize
+	}
+	if slotRetentionHours := os.Getenv("LLAMACTL_SLOT_RETENTION_HOURS"); slotRetentionHours != "" {
+		if hours, err := strconv.Atoi(slotRetentionHours); err == nil {
+			cfg.Instances.SlotRetentionHours = hours
+		}
+	}
+	if trashRetention := os.Getenv("LLAMACTL_TRASH_RETENTION"); trashRetention != "" {
+		if d, err := ParseDuration(trashRetention, time.Second); err == nil {
+			cfg.Instances.TrashRetention = d
+		}
+	}
+	if idleTimeout := os.Getenv("LLAMACTL_BACKEND_IDLE_TIMEOUT"); idleTimeout != "" {
+		if d, err := ParseDuration(idleTimeout, time.Second); err == nil {
+			cfg.Instances.BackendIdleTimeout = d
+		}
+	}
+	if allowInsecure := os.Getenv("LLAMACTL_ALLOW_INSECURE_BACKENDS"); allowInsecure != "" {
+		if b, err := strconv.ParseBool(allowInsecure); err == nil {
+			cfg.Instances.AllowInsecureBackends = b
+		}
+	}
+	if allowCommands := os.Getenv("LLAMACTL_ALLOW_INSTANCE_COMMANDS"); allowCommands != "" {
+		if b, err := strconv.ParseBool(allowCommands); err == nil {
+			cfg.Instances.AllowInstanceCommands = b
+		}
+	}
+	if maxRestarts := os.Getenv("LLAMACTL_MAX_CONCURRENT_RESTARTS"); maxRestarts != "" {
+		if n, err := strconv.Atoi(maxRestarts); err == nil {
+			cfg.Instances.MaxConcurrentRestarts = n
 		}
 	}
+	if limitManual := os.Getenv("LLAMACTL_LIMIT_MANUAL_STARTS"); limitManual != "" {
+		if b, err := strconv.ParseBool(limitManual); err == nil {
+			cfg.Instances.LimitManualStarts = b
+		}
+	}
+	if instanceMemory := os.Getenv("LLAMACTL_INSTANCE_MEMORY_LIMIT"); instanceMemory != "" {
+		if n, err := strconv.ParseInt(instanceMemory, 10, 64); err == nil {
+			cfg.Instances.InstanceMemoryLimit = n
+		}
+	}
+	if memory := os.Getenv("LLAMACTL_MEMORY_LIMIT"); memory != "" {
+		if n, err := strconv.ParseInt(memory, 10, 64); err == nil {
+			cfg.Instances.MemoryLimit = n
+		}
+	}
+	if requireConfirmation := os.Getenv("LLAMACTL_REQUIRE_STOP_CONFIRMATION"); requireConfirmation != "" {
+		if b, err := strconv.ParseBool(requireConfirmation); err == nil {
+			cfg.Instances.RequireStopConfirmation = b
+		}
+	}
+	if leaveRunning := os.Getenv("LLAMACTL_LEAVE_RUNNING"); leaveRunning != "" {
+		if b, err := strconv.ParseBool(leaveRunning); err == nil {
+			cfg.Instances.LeaveRunning = b
+		}
+	}
+	if modelSourceURL := os.Getenv("LLAMACTL_MODEL_SOURCE_URL"); modelSourceURL != "" {
+		cfg.Instances.ModelSource.URL = modelSourceURL
+	}
+	if modelSourceKey := os.Getenv("LLAMACTL_MODEL_SOURCE_API_KEY"); modelSourceKey != "" {
+		cfg.Instances.ModelSource.APIKey = modelSourceKey
+	}
+	if modelCacheDir := os.Getenv("LLAMACTL_MODEL_CACHE_DIR"); modelCacheDir != "" {
+		cfg.Instances.ModelSource.CacheDir = modelCacheDir
+	}
+	// Model index config
+	if modelIndexDirs := os.Getenv("LLAMACTL_MODEL_INDEX_DIRS"); modelIndexDirs != "" {
+		cfg.ModelIndex.Dirs = strings.Split(modelIndexDirs, ",")
+	}
+	if rescanInterval := os.Getenv("LLAMACTL_MODEL_INDEX_RESCAN_INTERVAL"); rescanInterval != "" {
+		if seconds, err := strconv.Atoi(rescanInterval); err == nil {
+			cfg.ModelIndex.RescanInterval = seconds
+		}
+	}
+	// Fleet config
+	if fleetSource := os.Getenv("LLAMACTL_FLEET_SOURCE"); fleetSource != "" {
+		cfg.Fleet.Source = fleetSource
+	}
+	if fleetInterval := os.Getenv("LLAMACTL_FLEET_INTERVAL"); fleetInterval != "" {
+		if seconds, err := strconv.Atoi(fleetInterval); err == nil {
+			cfg.Fleet.Interval = seconds
+		}
+	}
+	if fleetPrune := os.Getenv("LLAMACTL_FLEET_PRUNE"); fleetPrune != "" {
+		if b, err := strconv.ParseBool(fleetPrune); err == nil {
+			cfg.Fleet.Prune = b
+		}
+	}
+	// Storage config
+	if storageBackend := os.Getenv("LLAMACTL_STORAGE_BACKEND"); storageBackend != "" {
+		cfg.Storage.Backend = storageBackend
+	}
+	if storagePath := os.Getenv("LLAMACTL_STORAGE_PATH"); storagePath != "" {
+		cfg.Storage.Path = storagePath
+	}
 	// Auth config
 	if requireInferenceAuth := os.Getenv("LLAMACTL_REQUIRE_INFERENCE_AUTH"); requireInferenceAuth != "" {
 		if b, err := strconv.ParseBool(requireInferenceAuth); err == nil {
@@ -469,6 +1485,24 @@ func loadEnvVars(cfg *AppConfig) {
 	if managementKeys := os.Getenv("LLAMACTL_MANAGEMENT_KEYS"); managementKeys != "" {
 		cfg.Auth.ManagementKeys = strings.Split(managementKeys, ",")
 	}
+	if issuer := os.Getenv("LLAMACTL_JWT_ISSUER"); issuer != "" {
+		cfg.Auth.JWT.Issuer = issuer
+	}
+	if jwksURL := os.Getenv("LLAMACTL_JWT_JWKS_URL"); jwksURL != "" {
+		cfg.Auth.JWT.JWKSURL = jwksURL
+	}
+	if audience := os.Getenv("LLAMACTL_JWT_AUDIENCE"); audience != "" {
+		cfg.Auth.JWT.Audience = audience
+	}
+	if secrets := os.Getenv("LLAMACTL_REQUEST_SIGNING_SECRETS"); secrets != "" {
+		cfg.Auth.RequestSigning.Secrets = strings.Split(secrets, ",")
+	}
+	if keyPriorities := os.Getenv("LLAMACTL_KEY_PRIORITIES"); keyPriorities != "" {
+		if cfg.Auth.KeyPriorities == nil {
+			cfg.Auth.KeyPriorities = make(map[string]string)
+		}
+		parseEnvVars(keyPriorities, cfg.Auth.KeyPriorities)
+	}
 }
 
 // ParsePortRange parses port range from string formats like "8000-9000" or "8000,9000"
@@ -599,3 +1633,11 @@ func (bc *BackendConfig) GetBackendSettings(backendType string) BackendSettings
 		return BackendSettings{}
 	}
 }
+
+// maskKey hides an API key in error messages
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return "..." + key[len(key)-4:]
+}
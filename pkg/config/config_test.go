@@ -1,10 +1,14 @@
 package config_test
 
 import (
+	"encoding/json"
 	"llamactl/pkg/config"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig_Defaults(t *testing.T) {
@@ -48,8 +52,8 @@ func TestLoadConfig_Defaults(t *testing.T) {
 	if cfg.Instances.DefaultMaxRestarts != 3 {
 		t.Errorf("Expected default max restarts 3, got %d", cfg.Instances.DefaultMaxRestarts)
 	}
-	if cfg.Instances.DefaultRestartDelay != 5 {
-		t.Errorf("Expected default restart delay 5, got %d", cfg.Instances.DefaultRestartDelay)
+	if cfg.Instances.DefaultRestartDelay != config.Duration(5*time.Second) {
+		t.Errorf("Expected default restart delay 5s, got %s", cfg.Instances.DefaultRestartDelay)
 	}
 }
 
@@ -104,8 +108,8 @@ instances:
 	if cfg.Instances.DefaultMaxRestarts != 10 {
 		t.Errorf("Expected max restarts 10, got %d", cfg.Instances.DefaultMaxRestarts)
 	}
-	if cfg.Instances.DefaultRestartDelay != 30 {
-		t.Errorf("Expected restart delay 30, got %d", cfg.Instances.DefaultRestartDelay)
+	if cfg.Instances.DefaultRestartDelay != config.Duration(30*time.Second) {
+		t.Errorf("Expected restart delay 30s, got %s", cfg.Instances.DefaultRestartDelay)
 	}
 }
 
@@ -158,8 +162,8 @@ func TestLoadConfig_EnvironmentOverrides(t *testing.T) {
 	if cfg.Instances.DefaultMaxRestarts != 7 {
 		t.Errorf("Expected max restarts 7, got %d", cfg.Instances.DefaultMaxRestarts)
 	}
-	if cfg.Instances.DefaultRestartDelay != 15 {
-		t.Errorf("Expected restart delay 15, got %d", cfg.Instances.DefaultRestartDelay)
+	if cfg.Instances.DefaultRestartDelay != config.Duration(15*time.Second) {
+		t.Errorf("Expected restart delay 15s, got %s", cfg.Instances.DefaultRestartDelay)
 	}
 }
 
@@ -229,6 +233,544 @@ instances:
 	}
 }
 
+func TestLoadConfig_FatalLogPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "defaults",
+			content:  "instances:\n  max_instances: 5\n",
+			expected: config.DefaultFatalLogPatterns,
+		},
+		{
+			name:     "custom patterns replace the defaults",
+			content:  "instances:\n  fatal_log_patterns: [\"out of memory\"]\n",
+			expected: []string{"out of memory"},
+		},
+		{
+			name:     "empty list disables detection",
+			content:  "instances:\n  fatal_log_patterns: []\n",
+			expected: []string{},
+		},
+		{
+			name:    "invalid pattern",
+			content: "instances:\n  fatal_log_patterns: [\"GGML_ASSERT(\"]\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject the invalid pattern")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if !slices.Equal(cfg.Instances.FatalLogPatterns, tt.expected) {
+				t.Errorf("Expected patterns %v, got %v", tt.expected, cfg.Instances.FatalLogPatterns)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_AllocFailurePatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "defaults",
+			content:  "instances:\n  max_instances: 5\n",
+			expected: config.DefaultAllocFailurePatterns,
+		},
+		{
+			name:     "custom patterns replace the defaults",
+			content:  "instances:\n  alloc_failure_patterns: [\"CUDA error: out of memory\"]\n",
+			expected: []string{"CUDA error: out of memory"},
+		},
+		{
+			name:     "empty list restarts after every failure",
+			content:  "instances:\n  alloc_failure_patterns: []\n",
+			expected: []string{},
+		},
+		{
+			name:    "invalid pattern",
+			content: "instances:\n  alloc_failure_patterns: [\"cudaMalloc(\"]\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject the invalid pattern")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if !slices.Equal(cfg.Instances.AllocFailurePatterns, tt.expected) {
+				t.Errorf("Expected patterns %v, got %v", tt.expected, cfg.Instances.AllocFailurePatterns)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_StaticInstances(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			content: "instances:\n  static:\n    embed:\n      protected: true\n      update_policy: revert\n      options:\n        backend_type: llama_cpp\n",
+		},
+		{
+			name:    "invalid update policy",
+			content: "instances:\n  static:\n    embed:\n      update_policy: ignore\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid state",
+			content: "instances:\n  static:\n    embed:\n      state: paused\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject the static instance")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			embed, ok := cfg.Instances.Static["embed"]
+			if !ok || !embed.Protected || embed.UpdatePolicy != config.StaticUpdateRevert || embed.Options["backend_type"] != "llama_cpp" {
+				t.Errorf("Expected the static instance to be loaded, got %+v", cfg.Instances.Static)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_LogSanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+		wantErr  bool
+	}{
+		{"defaults", "instances:\n  max_instances: 5\n", config.LogSanitizeCollapse, false},
+		{"byte-exact logs", "instances:\n  log_sanitize: \"off\"\n", config.LogSanitizeOff, false},
+		{"invalid mode", "instances:\n  log_sanitize: escape\n", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject the invalid mode")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if cfg.Instances.LogSanitize != tt.expected {
+				t.Errorf("Expected log_sanitize %q, got %q", tt.expected, cfg.Instances.LogSanitize)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_MaxConcurrentRestarts(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		expected     int
+		expectManual bool
+		wantErr      bool
+	}{
+		{"unlimited by default", "instances:\n  max_instances: 5\n", 0, false, false},
+		{"limited", "instances:\n  max_concurrent_restarts: 2\n  limit_manual_starts: true\n", 2, true, false},
+		{"negative", "instances:\n  max_concurrent_restarts: -1\n", 0, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject a negative limit")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if cfg.Instances.MaxConcurrentRestarts != tt.expected || cfg.Instances.LimitManualStarts != tt.expectManual {
+				t.Errorf("Expected max_concurrent_restarts %d and limit_manual_starts %v, got %d and %v",
+					tt.expected, tt.expectManual, cfg.Instances.MaxConcurrentRestarts, cfg.Instances.LimitManualStarts)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Durations(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		check   func(t *testing.T, cfg config.InstancesConfig)
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			content: "instances:\n  max_instances: 5\n",
+			check: func(t *testing.T, cfg config.InstancesConfig) {
+				if cfg.DefaultRestartDelay.Duration() != 5*time.Second || cfg.TimeoutCheckInterval.Duration() != 5*time.Minute {
+					t.Errorf("Expected 5s restart delay and 5m check interval, got %s and %s", cfg.DefaultRestartDelay, cfg.TimeoutCheckInterval)
+				}
+				if cfg.ReadinessProbeInterval.Duration() != time.Second {
+					t.Errorf("Expected a 1s readiness probe interval, got %s", cfg.ReadinessProbeInterval)
+				}
+				if cfg.DefaultStopTimeout.Duration() != 30*time.Second {
+					t.Errorf("Expected a 30s default stop timeout, got %s", cfg.DefaultStopTimeout)
+				}
+			},
+		},
+		{
+			name:    "duration strings",
+			content: "instances:\n  default_restart_delay: 1m30s\n  on_demand_start_timeout: \"5m\"\n  timeout_check_interval: 30s\n  backend_idle_timeout: 2h\n",
+			check: func(t *testing.T, cfg config.InstancesConfig) {
+				if cfg.DefaultRestartDelay.Duration() != 90*time.Second {
+					t.Errorf("Expected restart delay 1m30s, got %s", cfg.DefaultRestartDelay)
+				}
+				if cfg.OnDemandStartTimeout.Duration() != 5*time.Minute {
+					t.Errorf("Expected on-demand start timeout 5m, got %s", cfg.OnDemandStartTimeout)
+				}
+				if cfg.TimeoutCheckInterval.Duration() != 30*time.Second {
+					t.Errorf("Expected check interval 30s, got %s", cfg.TimeoutCheckInterval)
+				}
+				if cfg.BackendIdleTimeout.Duration() != 2*time.Hour {
+					t.Errorf("Expected backend idle timeout 2h, got %s", cfg.BackendIdleTimeout)
+				}
+			},
+		},
+		{
+			name:    "legacy integers keep their units",
+			content: "instances:\n  readiness_base_timeout: 45\n  timeout_check_interval: 10\n",
+			check: func(t *testing.T, cfg config.InstancesConfig) {
+				if cfg.ReadinessBaseTimeout.Duration() != 45*time.Second {
+					t.Errorf("Expected readiness base timeout 45s, got %s", cfg.ReadinessBaseTimeout)
+				}
+				if cfg.TimeoutCheckInterval.Duration() != 10*time.Minute {
+					t.Errorf("Expected check interval 10m, got %s", cfg.TimeoutCheckInterval)
+				}
+			},
+		},
+		{
+			name:    "environment variables",
+			content: "instances:\n  max_instances: 5\n",
+			env:     map[string]string{"LLAMACTL_BACKEND_IDLE_TIMEOUT": "3m", "LLAMACTL_TIMEOUT_CHECK_INTERVAL": "2"},
+			check: func(t *testing.T, cfg config.InstancesConfig) {
+				if cfg.BackendIdleTimeout.Duration() != 3*time.Minute {
+					t.Errorf("Expected backend idle timeout 3m, got %s", cfg.BackendIdleTimeout)
+				}
+				if cfg.TimeoutCheckInterval.Duration() != 2*time.Minute {
+					t.Errorf("Expected check interval 2m, got %s", cfg.TimeoutCheckInterval)
+				}
+			},
+		},
+		{
+			name:    "invalid duration",
+			content: "instances:\n  default_restart_delay: soon\n",
+			wantErr: true,
+		},
+		{
+			name:    "negative duration",
+			content: "instances:\n  backend_idle_timeout: -1m\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject the duration")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			tt.check(t, cfg.Instances)
+		})
+	}
+}
+
+func TestLoadConfig_ServiceStandby(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "primary and standby",
+			content: "services:\n  chat:\n    primary: chat-a\n    standby: chat-b\n    failback: automatic\n    failback_after: 10m\n    placement: anti_affinity\n    placement_mode: require\n",
+		},
+		{
+			name:    "standby without primary",
+			content: "services:\n  chat:\n    standby: chat-b\n",
+			wantErr: true,
+		},
+		{
+			name:    "same instance",
+			content: "services:\n  chat:\n    primary: chat-a\n    standby: chat-a\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid failback",
+			content: "services:\n  chat:\n    primary: chat-a\n    standby: chat-b\n    failback: sometimes\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid placement",
+			content: "services:\n  chat:\n    primary: chat-a\n    standby: chat-b\n    placement: spread\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid placement mode",
+			content: "services:\n  chat:\n    primary: chat-a\n    standby: chat-b\n    placement: anti_affinity\n    placement_mode: always\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject the service")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+
+			svc := cfg.Services["chat"]
+			if len(svc.Members) != 2 || svc.Members[0] != "chat-a" || svc.Members[1] != "chat-b" {
+				t.Errorf("Expected the primary and standby to be members, got %v", svc.Members)
+			}
+			if svc.Failback != config.FailbackAutomatic || svc.FailbackAfter.Duration() != 10*time.Minute {
+				t.Errorf("Expected automatic failback after 10m, got %s after %s", svc.Failback, svc.FailbackAfter)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ServiceWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "stable and canary",
+			content: "services:\n  chat:\n    weights:\n      chat-stable: 95\n      chat-canary: 5\n    canary: chat-canary\n    guardrail:\n      max_error_rate: 0.1\n",
+		},
+		{
+			name:    "negative weight",
+			content: "services:\n  chat:\n    weights:\n      chat-stable: 100\n      chat-canary: -5\n",
+			wantErr: true,
+		},
+		{
+			name:    "no positive weight",
+			content: "services:\n  chat:\n    weights:\n      chat-stable: 0\n",
+			wantErr: true,
+		},
+		{
+			name:    "canary without weight",
+			content: "services:\n  chat:\n    weights:\n      chat-stable: 100\n    canary: chat-canary\n",
+			wantErr: true,
+		},
+		{
+			name:    "guardrail without canary",
+			content: "services:\n  chat:\n    weights:\n      chat-stable: 100\n    guardrail:\n      max_error_rate: 0.1\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid error rate",
+			content: "services:\n  chat:\n    weights:\n      chat-stable: 95\n      chat-canary: 5\n    canary: chat-canary\n    guardrail:\n      max_error_rate: 2\n",
+			wantErr: true,
+		},
+		{
+			name:    "weights and standby",
+			content: "services:\n  chat:\n    primary: chat-a\n    standby: chat-b\n    weights:\n      chat-a: 100\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject the service")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+
+			svc := cfg.Services["chat"]
+			if len(svc.Members) != 2 || svc.Members[0] != "chat-canary" || svc.Members[1] != "chat-stable" {
+				t.Errorf("Expected the weighted instances to be members, got %v", svc.Members)
+			}
+			if svc.Guardrail == nil || svc.Guardrail.MaxErrorRate != 0.1 {
+				t.Errorf("Expected a guardrail at a 10%% error rate, got %+v", svc.Guardrail)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Sinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "defaults",
+			content: "sinks:\n  - type: nats\n    url: nats://localhost:4222\n",
+		},
+		{
+			name:    "unknown type",
+			content: "sinks:\n  - type: rabbitmq\n    url: amqp://localhost\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing url",
+			content: "sinks:\n  - type: kafka\n",
+			wantErr: true,
+		},
+		{
+			name:    "duplicate name",
+			content: "sinks:\n  - type: nats\n    url: nats://a:4222\n  - type: nats\n    url: nats://b:4222\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown event class",
+			content: "sinks:\n  - type: nats\n    url: nats://localhost:4222\n    topics:\n      metrics: llamactl.metrics\n",
+			wantErr: true,
+		},
+		{
+			name:    "nats topic with whitespace",
+			content: "sinks:\n  - type: nats\n    url: nats://localhost:4222\n    topics:\n      audit: \"llamactl audit\"\n",
+			wantErr: true,
+		},
+		{
+			name:    "nats topic with control character",
+			content: "sinks:\n  - type: nats\n    url: nats://localhost:4222\n    topics:\n      audit: \"llamactl.audit\\r\\nPUB x 0\"\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject the sink")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+
+			sink := cfg.Sinks[0]
+			if sink.Name != config.SinkNATS || sink.BufferSize != 1000 {
+				t.Errorf("Expected the sink to default to name nats and 1000 buffered events, got %s and %d", sink.Name, sink.BufferSize)
+			}
+			if len(sink.Topics) != 3 || sink.Topics[config.EventClassRequest] != "llamactl.request" {
+				t.Errorf("Expected every event class on default topics, got %v", sink.Topics)
+			}
+		})
+	}
+}
+
 func TestParsePortRange(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -510,3 +1052,214 @@ func TestGetBackendSettings_InvalidBackendType(t *testing.T) {
 		t.Errorf("Expected empty command for invalid backend, got %q", settings.Command)
 	}
 }
+
+func TestLoadConfig_JWTAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "disabled without an issuer",
+			content: "auth:\n  jwt:\n    roles:\n      admins: {scope: root}\n",
+		},
+		{
+			name:    "valid",
+			content: "auth:\n  jwt:\n    issuer: https://idp.example.com\n    audience: llamactl\n    roles:\n      admins: {scope: admin}\n      team-a: {scope: operator, namespaces: [\"team-a-*\"]}\n",
+		},
+		{
+			name:    "missing audience",
+			content: "auth:\n  jwt:\n    issuer: https://idp.example.com\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid scope",
+			content: "auth:\n  jwt:\n    issuer: https://idp.example.com\n    audience: llamactl\n    roles:\n      admins: {scope: root}\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid namespace",
+			content: "auth:\n  jwt:\n    issuer: https://idp.example.com\n    audience: llamactl\n    roles:\n      team-a: {scope: operator, namespaces: [\"team-[a\"]}\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			cfg, err := config.LoadConfig(configFile)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected LoadConfig to reject the JWT settings")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if cfg.Auth.JWT.IdentityClaim != "sub" || cfg.Auth.JWT.RolesClaim != "groups" || cfg.Auth.JWT.JWKSRefreshInterval != 3600 {
+				t.Errorf("Expected the JWT defaults to be kept, got %+v", cfg.Auth.JWT)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_LBHealthAllowedNetworks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{
+			name:    "networks and addresses",
+			content: "server:\n  lb_health_allowed_networks: [\"10.0.0.0/8\", \"192.168.1.10\", \"fd00::/8\"]\n",
+		},
+		{
+			name:    "invalid network",
+			content: "server:\n  lb_health_allowed_networks: [\"10.0.0.0/33\"]\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid address",
+			content: "server:\n  lb_health_allowed_networks: [\"load-balancer\"]\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(configFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config file: %v", err)
+			}
+
+			_, err := config.LoadConfig(configFile)
+			if tt.wantErr && err == nil {
+				t.Error("Expected LoadConfig to reject the networks")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+		})
+	}
+
+	prefix, err := config.ParseNetwork("192.168.1.10")
+	if err != nil || prefix.String() != "192.168.1.10/32" {
+		t.Errorf("Expected a single address to be its own network, got %v, %v", prefix, err)
+	}
+}
+
+func TestLoadConfig_Sources(t *testing.T) {
+	dataDir := t.TempDir()
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	content := "instances:\n  data_dir: " + dataDir + "\n  default_max_restarts: 5\n  max_instances: 8\n  model_source:\n    url: http://control-plane:8080\n    api_key: secret-management-key\n"
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+	overrides := "default_max_restarts: 7\n"
+	if err := os.WriteFile(filepath.Join(dataDir, config.OverridesFile), []byte(overrides), 0644); err != nil {
+		t.Fatalf("Failed to write overrides file: %v", err)
+	}
+	t.Setenv("LLAMACTL_DEFAULT_RESTART_DELAY", "10s")
+
+	cfg, err := config.LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Instances.DefaultMaxRestarts != 7 {
+		t.Errorf("Expected the override to win over the file, got %d", cfg.Instances.DefaultMaxRestarts)
+	}
+
+	settings, err := config.DescribeInstances(cfg.Instances, cfg.Sources)
+	if err != nil {
+		t.Fatalf("DescribeInstances failed: %v", err)
+	}
+	for key, expected := range map[string]string{
+		"default_max_restarts":  config.SourceOverride,
+		"max_instances":         config.SourceFile,
+		"default_restart_delay": config.SourceEnv,
+		"slot_retention_hours":  config.SourceDefault,
+	} {
+		if source := settings[key].Source; source != expected {
+			t.Errorf("Expected %s to come from %s, got %s", key, expected, source)
+		}
+	}
+	if value := settings["default_restart_delay"].Value; value != "10s" {
+		t.Errorf("Expected durations to be rendered as written, got %v", value)
+	}
+	if !settings["default_max_restarts"].RuntimeTunable || settings["port_range"].RuntimeTunable || settings["port_range"].Reason == "" {
+		t.Errorf("Expected only runtime settings to be tunable, got %+v and %+v", settings["default_max_restarts"], settings["port_range"])
+	}
+	source, _ := settings["model_source"].Value.(map[string]any)
+	if source["api_key"] == "secret-management-key" || source["url"] != "http://control-plane:8080" {
+		t.Errorf("Expected the API key to be redacted, got %v", source)
+	}
+
+	// Only runtime settings can be overridden
+	if err := os.WriteFile(filepath.Join(dataDir, config.OverridesFile), []byte("port_range: [1, 2]\n"), 0644); err != nil {
+		t.Fatalf("Failed to write overrides file: %v", err)
+	}
+	if _, err := config.LoadConfig(configFile); err == nil {
+		t.Error("Expected LoadConfig to reject an override of a startup setting")
+	}
+}
+
+func TestPatchInstances(t *testing.T) {
+	current := config.InstancesConfig{DefaultMaxRestarts: 3, LogSanitize: config.LogSanitizeCollapse, PortRange: [2]int{8000, 9000}}
+
+	tests := []struct {
+		name    string
+		patch   string
+		check   func(t *testing.T, c config.InstancesConfig)
+		wantErr string
+	}{
+		{
+			name:  "runtime settings",
+			patch: `{"default_max_restarts": 5, "default_restart_delay": "30s", "readiness_base_timeout": 45}`,
+			check: func(t *testing.T, c config.InstancesConfig) {
+				if c.DefaultMaxRestarts != 5 || c.DefaultRestartDelay != config.Duration(30*time.Second) {
+					t.Errorf("Expected the settings to be applied, got %+v", c)
+				}
+				if c.ReadinessBaseTimeout != config.Duration(45*time.Second) {
+					t.Errorf("Expected a bare integer duration in seconds, got %v", c.ReadinessBaseTimeout)
+				}
+				if c.PortRange != current.PortRange || c.LogSanitize != current.LogSanitize {
+					t.Errorf("Expected the other settings to be kept, got %+v", c)
+				}
+			},
+		},
+		{name: "startup setting", patch: `{"port_range": [1, 2]}`, wantErr: "port_range cannot be changed at runtime: "},
+		{name: "unknown setting", patch: `{"webhook_url": "http://example.com"}`, wantErr: `unknown setting "webhook_url"`},
+		{name: "invalid value", patch: `{"log_sanitize": "bogus"}`, wantErr: "invalid log_sanitize"},
+		{name: "negative limit", patch: `{"max_concurrent_restarts": -1}`, wantErr: "cannot be negative"},
+		{name: "negative memory limit", patch: `{"instance_memory_limit": -1}`, wantErr: "invalid instance_memory_limit -1"},
+		{name: "log response over its maximum", patch: `{"log_response_size_mb": 200, "log_response_max_size_mb": 100}`, wantErr: "invalid log_response_size_mb 200"},
+		{name: "startup log read concurrency", patch: `{"log_read_concurrency": 4}`, wantErr: "log_read_concurrency cannot be changed at runtime: "},
+		{name: "null", patch: `{"default_max_restarts": null}`, wantErr: "cannot be null"},
+		{name: "empty", patch: `{}`, wantErr: "no settings to change"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+				t.Fatalf("Invalid patch: %v", err)
+			}
+			updated, _, err := config.PatchInstances(current, patch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PatchInstances failed: %v", err)
+			}
+			tt.check(t, updated)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a length of time in the configuration and the options of instances. It is
+// written as a Go duration string such as "90s" or "5m"; a bare integer is a number of
+// seconds, the unit these settings were given in before they accepted durations.
+type Duration time.Duration
+
+// Duration returns d as a time.Duration
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String renders d without the zero units time.Duration.String ends with, e.g. "5m"
+// rather than "5m0s"
+func (d Duration) String() string {
+	s := time.Duration(d).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// ParseDuration parses a Go duration string, or a bare integer counted in unit
+func ParseDuration(value string, unit time.Duration) (Duration, error) {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return Duration(time.Duration(n) * unit), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: expected an integer number of %s or a duration such as \"90s\" or \"5m\"", value, unitName(unit))
+	}
+	return Duration(d), nil
+}
+
+// ParseDurationJSON parses a JSON string or integer, the integer counted in unit
+func ParseDurationJSON(data []byte, unit time.Duration) (Duration, error) {
+	var value string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return 0, err
+		}
+	} else {
+		value = string(data)
+	}
+	return ParseDuration(value, unit)
+}
+
+// ParseDurationYAML parses a YAML scalar, an integer counted in unit
+func ParseDurationYAML(node *yaml.Node, unit time.Duration) (Duration, error) {
+	if node.Kind != yaml.ScalarNode {
+		return 0, fmt.Errorf("line %d: expected a duration", node.Line)
+	}
+	d, err := ParseDuration(node.Value, unit)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	return d, nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON leaves the duration unchanged on null, as encoding/json does for other types
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	parsed, err := ParseDurationJSON(data, time.Second)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := ParseDurationYAML(node, time.Second)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func unitName(unit time.Duration) string {
+	switch unit {
+	case time.Minute:
+		return "minutes"
+	case time.Hour:
+		return "hours"
+	}
+	return "seconds"
+}
@@ -0,0 +1,143 @@
+package config_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{input: `30`, expected: 30 * time.Second},
+		{input: `0`, expected: 0},
+		{input: `"90s"`, expected: 90 * time.Second},
+		{input: `"5m"`, expected: 5 * time.Minute},
+		{input: `"1h30m"`, expected: 90 * time.Minute},
+		{input: `"500ms"`, expected: 500 * time.Millisecond},
+		{input: `"45"`, expected: 45 * time.Second},
+		{input: `"soon"`, wantErr: true},
+		{input: `1.5`, wantErr: true},
+		{input: `true`, wantErr: true},
+		{input: `null`, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var d config.Duration
+			err := json.Unmarshal([]byte(tt.input), &d)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected %s to be rejected, got %s", tt.input, d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if d.Duration() != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, d.Duration())
+			}
+		})
+	}
+
+	// null keeps the value already set
+	options := struct {
+		Timeout config.Duration `json:"timeout"`
+	}{Timeout: config.Duration(time.Minute)}
+	if err := json.Unmarshal([]byte(`{"timeout": null}`), &options); err != nil {
+		t.Fatalf("Unmarshal of null failed: %v", err)
+	}
+	if options.Timeout.Duration() != time.Minute {
+		t.Errorf("Expected null to keep 1m, got %v", options.Timeout.Duration())
+	}
+}
+
+func TestDuration_UnmarshalYAML(t *testing.T) {
+	var settings struct {
+		Seconds  config.Duration `yaml:"seconds"`
+		String   config.Duration `yaml:"string"`
+		Quoted   config.Duration `yaml:"quoted"`
+		Fraction config.Duration `yaml:"fraction"`
+	}
+	input := "seconds: 90\nstring: 5m\nquoted: \"2h\"\nfraction: 1.5s\n"
+	if err := yaml.Unmarshal([]byte(input), &settings); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if settings.Seconds.Duration() != 90*time.Second {
+		t.Errorf("Expected 90s, got %s", settings.Seconds)
+	}
+	if settings.String.Duration() != 5*time.Minute {
+		t.Errorf("Expected 5m, got %s", settings.String)
+	}
+	if settings.Quoted.Duration() != 2*time.Hour {
+		t.Errorf("Expected 2h, got %s", settings.Quoted)
+	}
+	if settings.Fraction.Duration() != 1500*time.Millisecond {
+		t.Errorf("Expected 1.5s, got %s", settings.Fraction)
+	}
+
+	if err := yaml.Unmarshal([]byte("seconds: [1, 2]\n"), &settings); err == nil {
+		t.Error("Expected a sequence to be rejected")
+	}
+}
+
+func TestDuration_RoundTrip(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		rendered string
+	}{
+		{0, "0s"},
+		{500 * time.Millisecond, "500ms"},
+		{90 * time.Second, "1m30s"},
+		{5 * time.Minute, "5m"},
+		{time.Hour, "1h"},
+		{90 * time.Minute, "1h30m"},
+		{time.Hour + time.Second, "1h0m1s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rendered, func(t *testing.T) {
+			d := config.Duration(tt.duration)
+
+			data, err := json.Marshal(d)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if string(data) != `"`+tt.rendered+`"` {
+				t.Errorf("Expected JSON %q, got %s", tt.rendered, data)
+			}
+			var fromJSON config.Duration
+			if err := json.Unmarshal(data, &fromJSON); err != nil || fromJSON != d {
+				t.Errorf("JSON round-trip of %s returned %s (%v)", d, fromJSON, err)
+			}
+
+			out, err := yaml.Marshal(map[string]config.Duration{"d": d})
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var fromYAML map[string]config.Duration
+			if err := yaml.Unmarshal(out, &fromYAML); err != nil || fromYAML["d"] != d {
+				t.Errorf("YAML round-trip of %s returned %s (%v)", d, fromYAML["d"], err)
+			}
+		})
+	}
+}
+
+func TestParseDuration_Unit(t *testing.T) {
+	d, err := config.ParseDuration("10", time.Minute)
+	if err != nil || d.Duration() != 10*time.Minute {
+		t.Errorf("Expected a bare integer to be counted in minutes, got %s (%v)", d, err)
+	}
+	d, err = config.ParseDuration("90s", time.Minute)
+	if err != nil || d.Duration() != 90*time.Second {
+		t.Errorf("Expected a duration string to ignore the unit, got %s (%v)", d, err)
+	}
+}
@@ -0,0 +1,340 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of the instances settings
+const (
+	SourceDefault  = "default"  // Hardcoded default
+	SourceFile     = "file"     // Configuration file
+	SourceEnv      = "env"      // Environment variable
+	SourceOverride = "override" // Changed at runtime through the API
+)
+
+// OverridesFile is the name of the file in the data directory holding the settings changed
+// at runtime, applied over the configuration file and environment variables on startup
+const OverridesFile = "config_overrides.yaml"
+
+// runtimeSettings are the instances settings that can be changed while llamactl runs,
+// because they are read whenever they are used rather than once at startup
+var runtimeSettings = []string{
+	"max_instances",
+	"max_running_instances",
+	"enable_lru_eviction",
+	"llama_executable",
+	"default_auto_restart",
+	"default_max_restarts",
+	"default_restart_delay",
+	"default_stop_timeout",
+	"default_drain_timeout",
+	"max_concurrent_restarts",
+	"limit_manual_starts",
+	"instance_memory_limit",
+	"memory_limit",
+	"default_on_demand_start",
+	"on_demand_start_timeout",
+	"readiness_base_timeout",
+	"readiness_probe_interval",
+	"model_load_mb_per_second",
+	"log_retention_days",
+	"log_disk_quota_mb",
+	"log_sanitize",
+	"log_response_size_mb",
+	"log_response_max_size_mb",
+	"slot_retention_hours",
+	"trash_retention",
+	"require_stop_confirmation",
+}
+
+// staticSettings explains why each of the other instances settings cannot be changed at runtime
+var staticSettings = map[string]string{
+	"port_range":              "ports are allocated from the range set at startup",
+	"data_dir":                "directories are set up at startup",
+	"configs_dir":             "directories are set up at startup",
+	"logs_dir":                "directories are set up at startup",
+	"slots_dir":               "directories are set up at startup",
+	"auto_create_dirs":        "directories are set up at startup",
+	"timeout_check_interval":  "the timeout checker is started with it at startup",
+	"backend_idle_timeout":    "the connection pools of instances are set up with it",
+	"allow_insecure_backends": "disabling TLS verification can only be allowed from the configuration file",
+	"allow_instance_commands": "running commands set through the API can only be allowed from the configuration file",
+	"model_source":            "the model download client and its credentials are set up at startup",
+	"fatal_log_patterns":      "instances compile the patterns when they are created",
+	"alloc_failure_patterns":  "instances compile the patterns when they are created",
+	"gpu_fault_patterns":      "instances compile the patterns when they are created",
+	"gpu_fault_threshold":     "instances set up GPU fault detection when they are created",
+	"gpu_fault_window":        "instances set up GPU fault detection when they are created",
+	"static":                  "static instances are reconciled with the configuration file at startup",
+	"log_read_concurrency":    "the logs endpoint sets up its limit of concurrent reads at startup",
+	"leave_running":           "backends are only spawned so that they can outlive llamactl when it is set at startup",
+}
+
+// instancesEnvVars are the environment variables setting each instances setting
+var instancesEnvVars = map[string][]string{
+	"port_range":                {"LLAMACTL_INSTANCE_PORT_RANGE"},
+	"data_dir":                  {"LLAMACTL_DATA_DIRECTORY"},
+	"configs_dir":               {"LLAMACTL_INSTANCES_DIR"},
+	"logs_dir":                  {"LLAMACTL_LOGS_DIR"},
+	"slots_dir":                 {"LLAMACTL_SLOTS_DIR"},
+	"auto_create_dirs":          {"LLAMACTL_AUTO_CREATE_DATA_DIR"},
+	"max_instances":             {"LLAMACTL_MAX_INSTANCES"},
+	"max_running_instances":     {"LLAMACTL_MAX_RUNNING_INSTANCES"},
+	"enable_lru_eviction":       {"LLAMACTL_ENABLE_LRU_EVICTION"},
+	"llama_executable":          {"LLAMACTL_LLAMA_EXECUTABLE"},
+	"default_auto_restart":      {"LLAMACTL_DEFAULT_AUTO_RESTART"},
+	"default_max_restarts":      {"LLAMACTL_DEFAULT_MAX_RESTARTS"},
+	"default_restart_delay":     {"LLAMACTL_DEFAULT_RESTART_DELAY"},
+	"default_stop_timeout":      {"LLAMACTL_DEFAULT_STOP_TIMEOUT"},
+	"default_drain_timeout":     {"LLAMACTL_DEFAULT_DRAIN_TIMEOUT"},
+	"max_concurrent_restarts":   {"LLAMACTL_MAX_CONCURRENT_RESTARTS"},
+	"limit_manual_starts":       {"LLAMACTL_LIMIT_MANUAL_STARTS"},
+	"instance_memory_limit":     {"LLAMACTL_INSTANCE_MEMORY_LIMIT"},
+	"memory_limit":              {"LLAMACTL_MEMORY_LIMIT"},
+	"default_on_demand_start":   {"LLAMACTL_DEFAULT_ON_DEMAND_START"},
+	"on_demand_start_timeout":   {"LLAMACTL_ON_DEMAND_START_TIMEOUT"},
+	"readiness_base_timeout":    {"LLAMACTL_READINESS_BASE_TIMEOUT"},
+	"readiness_probe_interval":  {"LLAMACTL_READINESS_PROBE_INTERVAL"},
+	"model_load_mb_per_second":  {"LLAMACTL_MODEL_LOAD_MB_PER_SECOND"},
+	"timeout_check_interval":    {"LLAMACTL_TIMEOUT_CHECK_INTERVAL"},
+	"log_retention_days":        {"LLAMACTL_LOG_RETENTION_DAYS"},
+	"log_disk_quota_mb":         {"LLAMACTL_LOG_DISK_QUOTA_MB"},
+	"log_sanitize":              {"LLAMACTL_LOG_SANITIZE"},
+	"log_response_size_mb":      {"LLAMACTL_LOG_RESPONSE_SIZE_MB"},
+	"log_response_max_size_mb":  {"LLAMACTL_LOG_RESPONSE_MAX_SIZE_MB"},
+	"log_read_concurrency":      {"LLAMACTL_LOG_READ_CONCURRENCY"},
+	"slot_retention_hours":      {"LLAMACTL_SLOT_RETENTION_HOURS"},
+	"trash_retention":           {"LLAMACTL_TRASH_RETENTION"},
+	"backend_idle_timeout":      {"LLAMACTL_BACKEND_IDLE_TIMEOUT"},
+	"allow_insecure_backends":   {"LLAMACTL_ALLOW_INSECURE_BACKENDS"},
+	"allow_instance_commands":   {"LLAMACTL_ALLOW_INSTANCE_COMMANDS"},
+	"require_stop_confirmation": {"LLAMACTL_REQUIRE_STOP_CONFIRMATION"},
+	"leave_running":             {"LLAMACTL_LEAVE_RUNNING"},
+	"model_source":              {"LLAMACTL_MODEL_SOURCE_URL", "LLAMACTL_MODEL_SOURCE_API_KEY", "LLAMACTL_MODEL_CACHE_DIR"},
+}
+
+// redacted replaces secrets in described settings
+const redacted = "[redacted]"
+
+// SettingValue is the resolved value of an instances setting and where it came from
+type SettingValue struct {
+	Value          any    `json:"value"`
+	Source         string `json:"source"`
+	RuntimeTunable bool   `json:"runtime_tunable"`
+	Reason         string `json:"reason,omitempty"` // Why the setting cannot be changed at runtime
+}
+
+// IsRuntimeSetting reports whether the instances setting can be changed while llamactl runs
+func IsRuntimeSetting(key string) bool {
+	return slices.Contains(runtimeSettings, key)
+}
+
+// instancesField returns the field of c set by the yaml key
+func instancesField(c *InstancesConfig, key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if tag == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// markFileSources records the instances settings present in the configuration file data
+func markFileSources(cfg *AppConfig, data []byte) {
+	var file struct {
+		Instances map[string]yaml.Node `yaml:"instances"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return
+	}
+	for key := range file.Instances {
+		cfg.Sources[key] = SourceFile
+	}
+}
+
+// markEnvSources records the instances settings set by environment variables
+func markEnvSources(cfg *AppConfig) {
+	for key, names := range instancesEnvVars {
+		for _, name := range names {
+			if os.Getenv(name) != "" {
+				cfg.Sources[key] = SourceEnv
+			}
+		}
+	}
+}
+
+// loadOverrides applies the settings changed at runtime, kept in the data directory
+func loadOverrides(cfg *AppConfig) error {
+	path := filepath.Join(cfg.Instances.DataDir, OverridesFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read configuration overrides: %w", err)
+	}
+
+	var overrides map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("invalid configuration overrides %s: %w", path, err)
+	}
+	for key := range overrides {
+		if !IsRuntimeSetting(key) {
+			return fmt.Errorf("invalid configuration overrides %s: %s cannot be changed at runtime", path, key)
+		}
+	}
+	if err := yaml.Unmarshal(data, &cfg.Instances); err != nil {
+		return fmt.Errorf("invalid configuration overrides %s: %w", path, err)
+	}
+	for key := range overrides {
+		cfg.Sources[key] = SourceOverride
+	}
+	return nil
+}
+
+// DescribeInstances returns every instances setting of c with its source, secrets redacted.
+// Settings missing from sources come from the defaults.
+func DescribeInstances(c InstancesConfig, sources map[string]string) (map[string]SettingValue, error) {
+	if c.ModelSource.APIKey != "" {
+		c.ModelSource.APIKey = redacted
+	}
+	// The options of static instances may hold secrets, they are shown by the instances API
+	staticNames := make([]string, 0, len(c.Static))
+	for name := range c.Static {
+		staticNames = append(staticNames, name)
+	}
+	sort.Strings(staticNames)
+
+	settings := make(map[string]SettingValue)
+	t := reflect.TypeOf(c)
+	v := reflect.ValueOf(c)
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		var value any
+		if key == "static" {
+			value = staticNames
+		} else {
+			// Values are rendered the way they are written in the configuration file
+			data, err := yaml.Marshal(v.Field(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("failed to render %s: %w", key, err)
+			}
+			if err := yaml.Unmarshal(data, &value); err != nil {
+				return nil, fmt.Errorf("failed to render %s: %w", key, err)
+			}
+		}
+
+		source := sources[key]
+		if source == "" {
+			source = SourceDefault
+		}
+		settings[key] = SettingValue{
+			Value:          value,
+			Source:         source,
+			RuntimeTunable: IsRuntimeSetting(key),
+			Reason:         staticSettings[key],
+		}
+	}
+	return settings, nil
+}
+
+// PatchInstances returns c with the settings of patch, keyed like the configuration file,
+// applied and validated, along with the sorted keys it changes. Settings that cannot be
+// changed at runtime are rejected.
+func PatchInstances(c InstancesConfig, patch map[string]json.RawMessage) (InstancesConfig, []string, error) {
+	keys := make([]string, 0, len(patch))
+	for key, value := range patch {
+		if !IsRuntimeSetting(key) {
+			if reason, ok := staticSettings[key]; ok {
+				return c, nil, fmt.Errorf("%s cannot be changed at runtime: %s", key, reason)
+			}
+			return c, nil, fmt.Errorf("unknown setting %q", key)
+		}
+		if string(value) == "null" {
+			return c, nil, fmt.Errorf("%s cannot be null", key)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return c, nil, fmt.Errorf("no settings to change")
+	}
+	sort.Strings(keys)
+
+	// JSON is YAML, so values are read exactly as in the configuration file
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return c, nil, err
+	}
+	updated := c
+	if err := yaml.Unmarshal(data, &updated); err != nil {
+		return c, nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	if err := validateInstances(updated); err != nil {
+		return c, nil, err
+	}
+	return updated, keys, nil
+}
+
+// CopySettings copies the settings with the given keys from src to dst, leaving the other
+// fields of dst untouched
+func CopySettings(dst *InstancesConfig, src InstancesConfig, keys []string) {
+	for _, key := range keys {
+		to, ok := instancesField(dst, key)
+		from, _ := instancesField(&src, key)
+		if ok {
+			to.Set(from)
+		}
+	}
+}
+
+// SaveOverrides adds the settings with the given keys of c to the overrides file in its data
+// directory, keeping the settings changed before. Nothing is saved without a data directory.
+func SaveOverrides(c InstancesConfig, keys []string) error {
+	if c.DataDir == "" {
+		return nil
+	}
+	path := filepath.Join(c.DataDir, OverridesFile)
+
+	overrides := make(map[string]any)
+	if data, err := os.ReadFile(path); err == nil {
+		if err := yaml.Unmarshal(data, &overrides); err != nil {
+			return fmt.Errorf("invalid configuration overrides %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	for _, key := range keys {
+		if field, ok := instancesField(&c, key); ok {
+			overrides[key] = field.Interface()
+		}
+	}
+
+	data, err := yaml.Marshal(overrides)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.DataDir, 0755); err != nil {
+		return err
+	}
+	// Written aside and renamed, so a crash never leaves a partial file behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,86 @@
+package cron_test
+
+import (
+	"llamactl/pkg/cron"
+	"testing"
+	"time"
+)
+
+func TestSchedule_Next(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2024, 6, 19, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 6, 19, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 6, 19, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2024, 6, 20, 9, 0, 0, 0, time.UTC)},
+		{"0 18 * * 1-5", time.Date(2024, 6, 19, 18, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2024, 6, 20, 10, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 6, 23, 0, 0, 0, 0, time.UTC)},
+		{"0 8,20 * * SAT,SUN", time.Date(2024, 6, 22, 8, 0, 0, 0, time.UTC)},
+		{"5/20 11 * * *", time.Date(2024, 6, 19, 11, 5, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 6, 19, 11, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either is due
+		{"0 0 1 * fri", time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := cron.Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if next := schedule.Next(from); !next.Equal(tt.expected) {
+				t.Errorf("Expected %s, got %s", tt.expected, next)
+			}
+		})
+	}
+}
+
+func TestSchedule_NextInLocation(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	schedule, err := cron.Parse("30 2 * * *")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// Due at 02:30 local time, skipped on the night clocks are set forward past it
+	next := schedule.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, loc))
+	if expected := time.Date(2024, 4, 1, 2, 30, 0, 0, loc); !next.Equal(expected) {
+		t.Errorf("Expected %s, got %s", expected, next)
+	}
+	next = schedule.Next(time.Date(2024, 6, 19, 12, 0, 0, 0, loc))
+	if expected := time.Date(2024, 6, 20, 0, 30, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Errorf("Expected 02:30 in Berlin, got %s", next.UTC())
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+		"@reboot",
+	} {
+		if _, err := cron.Parse(expr); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package events
+
+import (
+	"encoding/json"
+	"llamactl/pkg/memory"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types published on the bus
+const (
+	TypeStatusChange    = "status_change"
+	TypeRollingRestart  = "rolling_restart"
+	TypeSLO             = "slo"
+	TypeFailover        = "failover"
+	TypeModelChange     = "model_change"
+	TypeStorage         = "storage"
+	TypeGPUFault        = "gpu_fault"
+	TypeLBHealth        = "lb_health"
+	TypeAutoRestart     = "autorestart"
+	TypeConfigMismatch  = "config_mismatch"
+	TypeStartupProgress = "startup_progress"
+	TypeCanary          = "canary"
+	TypeLogQuota        = "log_quota"
+
+	// Only exported to sinks, never published on the bus
+	TypeRequest = "request"
+	TypeAudit   = "audit"
+)
+
+// Event is a single notification about something that happened to an instance
+type Event struct {
+	ID        uint64         `json:"id"`
+	Type      string         `json:"type"`
+	Instance  string         `json:"instance,omitempty"`
+	Code      string         `json:"code,omitempty"`
+	Message   string         `json:"message,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// subscriberBufferSize is the number of events buffered per subscriber before events are dropped
+const subscriberBufferSize = 64
+
+// replayBufferSize is the number of the last events of each instance kept for subscribers
+// resuming a stream
+const replayBufferSize = 256
+
+// MemoryReplay is the subsystem the events kept for replay are accounted under
+const MemoryReplay = "event_replay"
+
+// Bus fans out events to all current subscribers.
+// Publishing never blocks: slow subscribers miss events instead of stalling the publisher.
+// The last events of each instance are kept so that a subscriber can resume after the last
+// event it received.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+	nextID      atomic.Uint64
+	dropped     atomic.Int64
+
+	replayMu   sync.Mutex
+	replay     map[string]*memory.Ring[Event] // By instance, "" for events of no instance
+	accountant *memory.Accountant
+}
+
+// NewBus creates an empty event bus, charging the events kept for replay to accountant.
+// A nil accountant leaves them unlimited, beyond replayBufferSize per instance.
+func NewBus(accountant *memory.Accountant) *Bus {
+	return &Bus{
+		subscribers: make(map[chan Event]struct{}),
+		replay:      make(map[string]*memory.Ring[Event]),
+		accountant:  accountant,
+	}
+}
+
+// Publish assigns an ID and timestamp to the event and delivers it to all subscribers
+func (b *Bus) Publish(event Event) Event {
+	event.ID = b.nextID.Add(1)
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.keep(event)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+
+	return event
+}
+
+// Subscribe registers a new subscriber. The returned function must be called to unsubscribe.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Dropped returns the number of events not delivered because a subscriber's buffer was full
+func (b *Bus) Dropped() int64 {
+	return b.dropped.Load()
+}
+
+// keep adds an event to the replay buffer of its instance
+func (b *Bus) keep(event Event) {
+	b.replayMu.Lock()
+	ring, ok := b.replay[event.Instance]
+	if !ok {
+		ring = memory.NewRing[Event](b.accountant, event.Instance, MemoryReplay, replayBufferSize)
+		b.replay[event.Instance] = ring
+	}
+	b.replayMu.Unlock()
+	ring.Add(event, eventSize(event))
+}
+
+// eventSize approximates the bytes held by an event
+func eventSize(event Event) int64 {
+	size := len(event.Type) + len(event.Instance) + len(event.Code) + len(event.Message)
+	if len(event.Data) > 0 {
+		data, _ := json.Marshal(event.Data)
+		size += len(data)
+	}
+	return int64(size)
+}
+
+// Since returns the events kept for replay that were published after the event with the
+// given ID, oldest first. Events evicted from the replay buffers are missing.
+func (b *Bus) Since(id uint64) []Event {
+	b.replayMu.Lock()
+	rings := make([]*memory.Ring[Event], 0, len(b.replay))
+	for _, ring := range b.replay {
+		rings = append(rings, ring)
+	}
+	b.replayMu.Unlock()
+
+	var replayed []Event
+	for _, ring := range rings {
+		for _, event := range ring.Snapshot() {
+			if event.ID > id {
+				replayed = append(replayed, event)
+			}
+		}
+	}
+	sort.Slice(replayed, func(i, j int) bool { return replayed[i].ID < replayed[j].ID })
+	return replayed
+}
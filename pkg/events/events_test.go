@@ -0,0 +1,54 @@
+package events_test
+
+import (
+	"llamactl/pkg/events"
+	"llamactl/pkg/memory"
+	"strings"
+	"testing"
+)
+
+func TestBus_Since(t *testing.T) {
+	bus := events.NewBus(nil)
+	first := bus.Publish(events.Event{Type: events.TypeStatusChange, Instance: "a"})
+	bus.Publish(events.Event{Type: events.TypeStatusChange, Instance: "b"})
+	bus.Publish(events.Event{Type: events.TypeRollingRestart})
+	bus.Publish(events.Event{Type: events.TypeStatusChange, Instance: "a"})
+
+	replayed := bus.Since(first.ID)
+	if len(replayed) != 3 {
+		t.Fatalf("Expected the 3 events after the first, got %d", len(replayed))
+	}
+	for idx, event := range replayed {
+		if event.ID != first.ID+uint64(idx)+1 {
+			t.Errorf("Expected the events of all instances in order, got %+v", replayed)
+		}
+	}
+	if replayed := bus.Since(replayed[2].ID); len(replayed) != 0 {
+		t.Errorf("Expected nothing after the last event, got %+v", replayed)
+	}
+}
+
+func TestBus_ReplayLimit(t *testing.T) {
+	accountant := memory.NewAccountant(64<<10, 0)
+	bus := events.NewBus(accountant)
+	quiet := bus.Publish(events.Event{Type: events.TypeStatusChange, Instance: "quiet", Message: "running"})
+
+	message := strings.Repeat("x", 1000)
+	var last events.Event
+	for range 100000 {
+		last = bus.Publish(events.Event{Type: events.TypeStatusChange, Instance: "chatty", Message: message})
+	}
+
+	snapshot := accountant.Snapshot()
+	if usage := snapshot.Instances["chatty"]; usage.Bytes > 64<<10 || usage.Subsystems[events.MemoryReplay] != usage.Bytes {
+		t.Errorf("Expected the replayed events to stay within the instance limit, got %+v", usage)
+	}
+	if snapshot.Evictions[events.MemoryReplay] == 0 {
+		t.Error("Expected evictions to be counted")
+	}
+
+	replayed := bus.Since(0)
+	if len(replayed) < 2 || replayed[0].ID != quiet.ID || replayed[len(replayed)-1].ID != last.ID {
+		t.Errorf("Expected the quiet instance and the last events of the chatty one, got %d events", len(replayed))
+	}
+}
@@ -0,0 +1,338 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority controls the order in which queued requests are admitted to an instance
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// Priorities lists all priorities from lowest to highest
+var Priorities = []Priority{PriorityLow, PriorityNormal, PriorityHigh}
+
+// DefaultAgingInterval is how long a request waits before its effective priority is raised by one level
+const DefaultAgingInterval = 5 * time.Second
+
+// serviceTimeSmoothing is the weight of the latest request in the moving average of the
+// time requests hold their slot
+const serviceTimeSmoothing = 0.2
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePriority parses a priority name ("low", "normal", "high") or its numeric value (0-2)
+func ParsePriority(s string) (Priority, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for _, p := range Priorities {
+		if s == p.String() {
+			return p, nil
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= int(PriorityLow) && n <= int(PriorityHigh) {
+		return Priority(n), nil
+	}
+	return PriorityNormal, fmt.Errorf("invalid priority %q: must be one of low, normal, high", s)
+}
+
+// QueueStats is a snapshot of an admission queue
+type QueueStats struct {
+	MaxConcurrent    int            `json:"max_concurrent"` // 0 = unlimited
+	MaxQueued        int            `json:"max_queued"`     // 0 = unlimited
+	Active           int            `json:"active"`
+	Queued           int            `json:"queued"`
+	ActiveByPriority map[string]int `json:"active_by_priority"`
+	QueuedByPriority map[string]int `json:"queued_by_priority"`
+	ServiceTimeMs    float64        `json:"service_time_ms"`   // Moving average of the time requests hold their slot
+	EstimatedWaitMs  float64        `json:"estimated_wait_ms"` // Expected wait of a new normal priority request
+}
+
+// QueueFullError is returned when a request cannot be queued because the queue is full
+type QueueFullError struct {
+	Priority Priority
+	Stats    QueueStats
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("admission queue is full (%d queued, %d active)", e.Stats.Queued, e.Stats.Active)
+}
+
+// QueueDeadlineError is returned when a request cannot be admitted within its maximum wait,
+// either because its estimated wait is longer or because it was still queued once it elapsed
+type QueueDeadlineError struct {
+	Priority      Priority
+	EstimatedWait time.Duration
+	MaxWait       time.Duration
+	TimedOut      bool // Queued for the whole maximum wait rather than rejected on arrival
+	Stats         QueueStats
+}
+
+func (e *QueueDeadlineError) Error() string {
+	if e.TimedOut {
+		return fmt.Sprintf("request not admitted within %s (estimated wait %s)", e.MaxWait, e.EstimatedWait.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("estimated queue wait of %s exceeds the deadline of %s", e.EstimatedWait.Round(time.Millisecond), e.MaxWait)
+}
+
+type admissionWaiter struct {
+	priority   Priority
+	enqueuedAt time.Time
+	ready      chan struct{}
+	admitted   bool
+	admittedAt time.Time
+}
+
+// AdmissionQueue limits the number of concurrent requests to an instance.
+// Requests beyond the limit wait in a priority queue; waiting requests are aged
+// so that low priority requests cannot be starved by a steady stream of higher priority ones.
+// Admitted requests keep their slot until released, so a long running stream is never preempted.
+// The time requests hold their slot is averaged to estimate how long a new request will wait.
+type AdmissionQueue struct {
+	mu            sync.Mutex
+	maxConcurrent int
+	maxQueued     int
+	agingInterval time.Duration
+	active        map[Priority]int
+	waiters       []*admissionWaiter
+	serviceTime   time.Duration // Moving average, 0 until a request has been released
+	now           func() time.Time
+}
+
+// NewAdmissionQueue creates an admission queue; a maxConcurrent of 0 disables queueing
+func NewAdmissionQueue(maxConcurrent, maxQueued int) *AdmissionQueue {
+	return &AdmissionQueue{
+		maxConcurrent: maxConcurrent,
+		maxQueued:     maxQueued,
+		agingInterval: DefaultAgingInterval,
+		active:        make(map[Priority]int),
+		now:           time.Now,
+	}
+}
+
+// SetLimits updates the concurrency and queue limits, admitting waiters if capacity was added
+func (q *AdmissionQueue) SetLimits(maxConcurrent, maxQueued int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxConcurrent = maxConcurrent
+	q.maxQueued = maxQueued
+	q.dispatch()
+}
+
+// SetAgingInterval changes how quickly waiting requests gain priority
+func (q *AdmissionQueue) SetAgingInterval(d time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.agingInterval = d
+}
+
+// Acquire waits for a slot and returns a function that releases it.
+// It returns a *QueueFullError if the queue is full, or the context error if ctx is done first.
+func (q *AdmissionQueue) Acquire(ctx context.Context, priority Priority) (func(), error) {
+	release, _, err := q.AcquireWithin(ctx, priority, 0)
+	return release, err
+}
+
+// AcquireWithin waits for a slot like Acquire, for at most maxWait (0 = no limit), and also
+// returns the wait estimated for the request on arrival. A request whose estimated wait
+// exceeds maxWait is rejected right away with a *QueueDeadlineError, as is a request still
+// queued once maxWait has elapsed.
+func (q *AdmissionQueue) AcquireWithin(ctx context.Context, priority Priority, maxWait time.Duration) (func(), time.Duration, error) {
+	q.mu.Lock()
+	now := q.now()
+
+	if q.hasCapacity() && len(q.waiters) == 0 {
+		q.active[priority]++
+		q.mu.Unlock()
+		return q.releaseFunc(priority, now), 0, nil
+	}
+
+	if q.maxQueued > 0 && len(q.waiters) >= q.maxQueued {
+		err := &QueueFullError{Priority: priority, Stats: q.statsLocked()}
+		q.mu.Unlock()
+		return nil, 0, err
+	}
+
+	estimate := q.estimateWait(priority, now)
+	if maxWait > 0 && estimate > maxWait {
+		err := &QueueDeadlineError{Priority: priority, EstimatedWait: estimate, MaxWait: maxWait, Stats: q.statsLocked()}
+		q.mu.Unlock()
+		return nil, estimate, err
+	}
+
+	w := &admissionWaiter{
+		priority:   priority,
+		enqueuedAt: now,
+		ready:      make(chan struct{}),
+	}
+	q.waiters = append(q.waiters, w)
+	q.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-w.ready:
+		return q.releaseFunc(priority, w.admittedAt), estimate, nil
+	case <-timeout:
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if w.admitted {
+			// Admitted concurrently with the timeout, the request can still use the slot
+			return q.releaseFunc(priority, w.admittedAt), estimate, nil
+		}
+		q.removeWaiter(w)
+		return nil, estimate, &QueueDeadlineError{Priority: priority, EstimatedWait: estimate, MaxWait: maxWait, TimedOut: true, Stats: q.statsLocked()}
+	case <-ctx.Done():
+		q.mu.Lock()
+		if w.admitted {
+			// Admitted concurrently with cancellation, give the slot back
+			q.active[priority]--
+			q.dispatch()
+		} else {
+			q.removeWaiter(w)
+		}
+		q.mu.Unlock()
+		return nil, estimate, ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the queue
+func (q *AdmissionQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.statsLocked()
+}
+
+// releaseFunc returns the function releasing the slot of a request admitted at admittedAt,
+// which counts the time the slot was held towards the service time
+func (q *AdmissionQueue) releaseFunc(priority Priority, admittedAt time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.active[priority]--
+			if held := q.now().Sub(admittedAt); held >= 0 {
+				if q.serviceTime == 0 {
+					q.serviceTime = held
+				} else {
+					q.serviceTime += time.Duration(serviceTimeSmoothing * float64(held-q.serviceTime))
+				}
+			}
+			q.dispatch()
+		})
+	}
+}
+
+// estimateWait returns how long a request arriving now with the given priority is expected
+// to wait: the slots needed by the waiters admitted before it and by itself, freed at the
+// service time of one slot each spread over every slot (caller must hold the lock)
+func (q *AdmissionQueue) estimateWait(priority Priority, now time.Time) time.Duration {
+	if q.maxConcurrent <= 0 || (q.hasCapacity() && len(q.waiters) == 0) {
+		return 0
+	}
+	ahead := 0
+	for _, w := range q.waiters {
+		if q.effectivePriority(w, now) >= int(priority) {
+			ahead++
+		}
+	}
+	return q.serviceTime * time.Duration(ahead+1) / time.Duration(q.maxConcurrent)
+}
+
+// hasCapacity reports whether another request can be admitted (caller must hold the lock)
+func (q *AdmissionQueue) hasCapacity() bool {
+	return q.maxConcurrent <= 0 || q.activeCount() < q.maxConcurrent
+}
+
+func (q *AdmissionQueue) activeCount() int {
+	total := 0
+	for _, n := range q.active {
+		total += n
+	}
+	return total
+}
+
+// dispatch admits waiters while there is capacity (caller must hold the lock)
+func (q *AdmissionQueue) dispatch() {
+	for len(q.waiters) > 0 && q.hasCapacity() {
+		now := q.now()
+		best := 0
+		for idx := 1; idx < len(q.waiters); idx++ {
+			// Waiters are in arrival order, so a strict comparison keeps FIFO order within a priority
+			if q.effectivePriority(q.waiters[idx], now) > q.effectivePriority(q.waiters[best], now) {
+				best = idx
+			}
+		}
+
+		w := q.waiters[best]
+		q.waiters = append(q.waiters[:best], q.waiters[best+1:]...)
+		w.admitted = true
+		w.admittedAt = now
+		q.active[w.priority]++
+		close(w.ready)
+	}
+}
+
+// effectivePriority raises a waiter's priority by one level for every aging interval it has waited
+func (q *AdmissionQueue) effectivePriority(w *admissionWaiter, now time.Time) int {
+	effective := int(w.priority)
+	if q.agingInterval > 0 {
+		effective += int(now.Sub(w.enqueuedAt) / q.agingInterval)
+	}
+	return effective
+}
+
+func (q *AdmissionQueue) removeWaiter(w *admissionWaiter) {
+	for idx, waiter := range q.waiters {
+		if waiter == w {
+			q.waiters = append(q.waiters[:idx], q.waiters[idx+1:]...)
+			return
+		}
+	}
+}
+
+func (q *AdmissionQueue) statsLocked() QueueStats {
+	stats := QueueStats{
+		MaxConcurrent:    q.maxConcurrent,
+		MaxQueued:        q.maxQueued,
+		Active:           q.activeCount(),
+		Queued:           len(q.waiters),
+		ActiveByPriority: make(map[string]int, len(Priorities)),
+		QueuedByPriority: make(map[string]int, len(Priorities)),
+		ServiceTimeMs:    float64(q.serviceTime) / float64(time.Millisecond),
+		EstimatedWaitMs:  float64(q.estimateWait(PriorityNormal, q.now())) / float64(time.Millisecond),
+	}
+	for _, p := range Priorities {
+		stats.ActiveByPriority[p.String()] = q.active[p]
+		stats.QueuedByPriority[p.String()] = 0
+	}
+	for _, w := range q.waiters {
+		stats.QueuedByPriority[w.priority.String()]++
+	}
+	return stats
+}
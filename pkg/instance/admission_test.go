@@ -0,0 +1,259 @@
+package instance_test
+
+import (
+	"context"
+	"errors"
+	"llamactl/pkg/instance"
+	"testing"
+	"time"
+)
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected instance.Priority
+		wantErr  bool
+	}{
+		{"low", instance.PriorityLow, false},
+		{"Normal", instance.PriorityNormal, false},
+		{" high ", instance.PriorityHigh, false},
+		{"0", instance.PriorityLow, false},
+		{"2", instance.PriorityHigh, false},
+		{"3", instance.PriorityNormal, true},
+		{"urgent", instance.PriorityNormal, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := instance.ParsePriority(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePriority(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParsePriority(%q) = %v, expected %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+// enqueue starts an Acquire in the background and waits until it shows up in the queue
+func enqueue(t *testing.T, q *instance.AdmissionQueue, priority instance.Priority, admitted chan<- instance.Priority) {
+	t.Helper()
+	before := q.Stats().Queued
+	go func() {
+		release, err := q.Acquire(context.Background(), priority)
+		if err != nil {
+			t.Errorf("Acquire failed: %v", err)
+			return
+		}
+		admitted <- priority
+		release()
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Stats().Queued == before {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for request to be queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAdmissionQueue_PriorityOrder(t *testing.T) {
+	q := instance.NewAdmissionQueue(1, 0)
+	q.SetAgingInterval(0)
+
+	release, err := q.Acquire(context.Background(), instance.PriorityNormal)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	admitted := make(chan instance.Priority, 3)
+	enqueue(t, q, instance.PriorityLow, admitted)
+	enqueue(t, q, instance.PriorityNormal, admitted)
+	enqueue(t, q, instance.PriorityHigh, admitted)
+
+	stats := q.Stats()
+	if stats.Active != 1 || stats.Queued != 3 {
+		t.Errorf("Expected 1 active and 3 queued, got %d active and %d queued", stats.Active, stats.Queued)
+	}
+	for _, p := range []string{"low", "normal", "high"} {
+		if stats.QueuedByPriority[p] != 1 {
+			t.Errorf("Expected 1 queued %s request, got %d", p, stats.QueuedByPriority[p])
+		}
+	}
+
+	release()
+
+	expected := []instance.Priority{instance.PriorityHigh, instance.PriorityNormal, instance.PriorityLow}
+	for _, want := range expected {
+		select {
+		case got := <-admitted:
+			if got != want {
+				t.Errorf("Expected %s request to be admitted, got %s", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s request", want)
+		}
+	}
+}
+
+func TestAdmissionQueue_Aging(t *testing.T) {
+	q := instance.NewAdmissionQueue(1, 0)
+	q.SetAgingInterval(10 * time.Millisecond)
+
+	release, err := q.Acquire(context.Background(), instance.PriorityNormal)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	admitted := make(chan instance.Priority, 2)
+	enqueue(t, q, instance.PriorityLow, admitted)
+	// Waiting long enough raises the low priority request above a fresh high priority one
+	time.Sleep(50 * time.Millisecond)
+	enqueue(t, q, instance.PriorityHigh, admitted)
+
+	release()
+
+	select {
+	case got := <-admitted:
+		if got != instance.PriorityLow {
+			t.Errorf("Expected aged low priority request to be admitted first, got %s", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for admission")
+	}
+}
+
+func TestAdmissionQueue_QueueFull(t *testing.T) {
+	q := instance.NewAdmissionQueue(1, 1)
+
+	release, err := q.Acquire(context.Background(), instance.PriorityHigh)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer release()
+
+	admitted := make(chan instance.Priority, 1)
+	enqueue(t, q, instance.PriorityNormal, admitted)
+
+	_, err = q.Acquire(context.Background(), instance.PriorityLow)
+	var queueFull *instance.QueueFullError
+	if !errors.As(err, &queueFull) {
+		t.Fatalf("Expected QueueFullError, got %v", err)
+	}
+	if queueFull.Priority != instance.PriorityLow {
+		t.Errorf("Expected rejected priority low, got %s", queueFull.Priority)
+	}
+	if queueFull.Stats.QueuedByPriority["normal"] != 1 || queueFull.Stats.ActiveByPriority["high"] != 1 {
+		t.Errorf("Unexpected queue stats in error: %+v", queueFull.Stats)
+	}
+}
+
+func TestAdmissionQueue_CancelWhileQueued(t *testing.T) {
+	q := instance.NewAdmissionQueue(1, 0)
+
+	release, err := q.Acquire(context.Background(), instance.PriorityNormal)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Acquire(ctx, instance.PriorityHigh); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if queued := q.Stats().Queued; queued != 0 {
+		t.Errorf("Expected cancelled request to leave the queue, got %d queued", queued)
+	}
+
+	release()
+	if active := q.Stats().Active; active != 0 {
+		t.Errorf("Expected no active requests after release, got %d", active)
+	}
+}
+
+func TestAdmissionQueue_Unlimited(t *testing.T) {
+	q := instance.NewAdmissionQueue(0, 0)
+
+	for i := 0; i < 10; i++ {
+		if _, err := q.Acquire(context.Background(), instance.PriorityLow); err != nil {
+			t.Fatalf("Acquire %d failed: %v", i, err)
+		}
+	}
+	if stats := q.Stats(); stats.Active != 10 || stats.Queued != 0 {
+		t.Errorf("Expected 10 active and none queued, got %+v", stats)
+	}
+}
+
+func TestAdmissionQueue_DeadlineShedding(t *testing.T) {
+	q := instance.NewAdmissionQueue(1, 0)
+
+	// Requests hold their slot for about 50ms
+	release, err := q.Acquire(context.Background(), instance.PriorityNormal)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	release()
+	if stats := q.Stats(); stats.ServiceTimeMs < 50 || stats.EstimatedWaitMs != 0 {
+		t.Errorf("Expected a service time of 50ms and no wait for a free slot, got %+v", stats)
+	}
+
+	release, err = q.Acquire(context.Background(), instance.PriorityNormal)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if stats := q.Stats(); stats.EstimatedWaitMs < 50 {
+		t.Errorf("Expected a wait of one service time behind the active request, got %vms", stats.EstimatedWaitMs)
+	}
+
+	_, estimate, err := q.AcquireWithin(context.Background(), instance.PriorityNormal, 10*time.Millisecond)
+	var deadlineErr *instance.QueueDeadlineError
+	if !errors.As(err, &deadlineErr) || deadlineErr.TimedOut {
+		t.Fatalf("Expected the request to be shed on arrival, got %v", err)
+	}
+	if estimate < 50*time.Millisecond || deadlineErr.EstimatedWait != estimate {
+		t.Errorf("Expected the estimated wait in the error, got %s and %s", estimate, deadlineErr.EstimatedWait)
+	}
+	if queued := q.Stats().Queued; queued != 0 {
+		t.Errorf("Expected the shed request not to be queued, got %d queued", queued)
+	}
+
+	// A request whose deadline allows the estimated wait is queued and admitted
+	done := make(chan time.Duration)
+	go func() {
+		release, estimate, err := q.AcquireWithin(context.Background(), instance.PriorityNormal, time.Second)
+		if err != nil {
+			t.Errorf("AcquireWithin failed: %v", err)
+		}
+		release()
+		done <- estimate
+	}()
+	for q.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	release()
+	if estimate := <-done; estimate < 50*time.Millisecond {
+		t.Errorf("Expected the admitted request to report its estimated wait, got %s", estimate)
+	}
+}
+
+func TestAdmissionQueue_QueueTimeout(t *testing.T) {
+	q := instance.NewAdmissionQueue(1, 0)
+
+	release, err := q.Acquire(context.Background(), instance.PriorityNormal)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer release()
+
+	// Without a service time yet the request is queued, until its maximum wait elapses
+	_, _, err = q.AcquireWithin(context.Background(), instance.PriorityHigh, 20*time.Millisecond)
+	var deadlineErr *instance.QueueDeadlineError
+	if !errors.As(err, &deadlineErr) || !deadlineErr.TimedOut {
+		t.Fatalf("Expected the request to time out in the queue, got %v", err)
+	}
+	if queued := q.Stats().Queued; queued != 0 {
+		t.Errorf("Expected the timed out request to leave the queue, got %d queued", queued)
+	}
+}
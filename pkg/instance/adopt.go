@@ -0,0 +1,379 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// adoptedPollInterval is how often an adopted process is checked for having exited, since
+// llamactl cannot wait for a process that is not its child
+const adoptedPollInterval = 200 * time.Millisecond
+
+// errAdoptedExited is the exit of an adopted process, whose exit status cannot be known
+var errAdoptedExited = errors.New("adopted process exited, its exit status is unknown")
+
+// processState is the state file of a backend spawned with leave_running, written when it is
+// spawned and removed once it exits. A process left running on shutdown keeps its file, with
+// how much of its output was logged, for the next llamactl to adopt it.
+type processState struct {
+	PID         int       `json:"pid"`
+	Port        int       `json:"port"`
+	BindAddress string    `json:"bind_address,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+
+	// Bytes of the output files already logged, unset if llamactl exited without detaching
+	StdoutOffset *int64 `json:"stdout_offset,omitempty"`
+	StderrOffset *int64 `json:"stderr_offset,omitempty"`
+}
+
+// leaveRunning reports whether backends are spawned so that they can outlive llamactl
+func (i *Process) leaveRunning() bool {
+	return AdoptionSupported && i.globalInstanceSettings != nil && i.globalInstanceSettings.LeaveRunning
+}
+
+// Detach stops watching the backend process without stopping it, so that it keeps running
+// once llamactl exits, and records in its state file how much of its output was logged. Only
+// a process spawned with leave_running can be detached. The instance must be closed next.
+func (i *Process) Detach() error {
+	i.mu.Lock()
+	if !i.IsRunning() || i.detach == nil || i.monitorDone == nil {
+		i.mu.Unlock()
+		return fmt.Errorf("instance %s has no process that can be left running", i.Name)
+	}
+	close(i.detach)
+	i.detach = nil
+	monitorDone := i.monitorDone
+	i.mu.Unlock()
+
+	<-monitorDone
+	return nil
+}
+
+// Adopt attaches the instance to the backend process a previous llamactl left running, as
+// recorded in its state file, instead of spawning a new one. The instance is starting until
+// the process passes a readiness check. It reports false, and the instance has to be started
+// as usual, when no process was left running or the recorded one cannot be adopted: it
+// exited, its port changed or it does not run the binary of the instance.
+func (i *Process) Adopt() (bool, error) {
+	state, err := i.logger.readProcessState()
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err == nil {
+		err = i.adopt(state)
+	}
+	if err != nil {
+		// The process is not ours, or gone, so it is not tried again
+		i.logger.removeProcessState()
+		return false, err
+	}
+	return true, nil
+}
+
+// adopt attaches the instance to the process of state
+func (i *Process) adopt(state *processState) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.closed {
+		return fmt.Errorf("instance %s has been deleted", i.Name)
+	}
+	// The status may still be the one persisted before the restart
+	if i.startDone != nil || i.cmd != nil || i.monitorDone != nil {
+		return fmt.Errorf("instance %s is already running", i.Name)
+	}
+	if i.options == nil || !i.options.IsManaged() {
+		return fmt.Errorf("instance %s does not run a managed backend", i.Name)
+	}
+	if port := i.options.port(); state.Port != port {
+		return fmt.Errorf("process %d of instance %s listens on port %d, not on its port %d", state.PID, i.Name, state.Port, port)
+	}
+	proc, err := os.FindProcess(state.PID)
+	if err != nil || !processAlive(proc) {
+		return fmt.Errorf("process %d of instance %s is no longer running", state.PID, i.Name)
+	}
+
+	// The process must run the binary the instance would be started with now
+	ctx, cancel := context.WithCancel(context.Background())
+	i.ctx, i.cancel = ctx, cancel
+	i.bindAddress = state.BindAddress
+	cmd, err := i.buildCommand()
+	if err != nil {
+		return fmt.Errorf("failed to build command: %w", err)
+	}
+	running, err := processExecutable(state.PID)
+	if err != nil {
+		return fmt.Errorf("cannot tell the binary process %d of instance %s runs: %w", state.PID, i.Name, err)
+	}
+	if current, err := os.Stat(cmd.Path); err != nil || !os.SameFile(running, current) {
+		return fmt.Errorf("process %d of instance %s does not run %s", state.PID, i.Name, cmd.Path)
+	}
+
+	if err := i.logger.Create(); err != nil {
+		return fmt.Errorf("failed to create log files: %w", err)
+	}
+	output, err := i.logger.followOutput(state.StdoutOffset, state.StderrOffset)
+	if err != nil {
+		i.logger.Close()
+		return err
+	}
+	state.StdoutOffset, state.StderrOffset = nil, nil
+	if err := i.logger.writeProcessState(state); err != nil {
+		log.Printf("Failed to record the process of instance %s: %v", i.Name, err)
+	}
+
+	// The process runs the binary on disk, checked above
+	backendCommand, _ := i.backendCommandFor(i.options)
+	if i.binary, err = identifyBinary(backendCommand); err != nil {
+		log.Printf("Failed to read the backend binary of instance %s: %v", i.Name, err)
+	}
+
+	cmd.Process = proc
+	i.cmd = cmd
+	i.processState = state
+	i.detach = make(chan struct{})
+	i.lastRequestTime.Store(i.timeProvider.Now().Unix())
+	i.refreshModelSize()
+	message := fmt.Sprintf("adopted process %d left running by the previous llamactl", state.PID)
+	i.logger.writeLine(message)
+	i.SetStatus(Starting, ReasonAdopted, message)
+	i.resetRun()
+	i.startedAt = state.StartedAt
+
+	detach := i.detach
+	i.watchProcess(output, func() error { return waitAdopted(proc, detach) })
+	return nil
+}
+
+// waitAdopted waits for an adopted process to exit, or to be detached, which returns nil
+func waitAdopted(proc *os.Process, detach <-chan struct{}) error {
+	ticker := time.NewTicker(adoptedPollInterval)
+	defer ticker.Stop()
+	for processAlive(proc) {
+		select {
+		case <-detach:
+			return nil
+		case <-ticker.C:
+		}
+	}
+	return errAdoptedExited
+}
+
+// waitOrDetach waits for the process to exit, and reports whether it was detached instead,
+// when detach is set. The wait then goes on without the instance, until the process exits.
+func waitOrDetach(wait func() error, detach <-chan struct{}) (detached bool, err error) {
+	if detach == nil {
+		return false, wait()
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- wait() }()
+	select {
+	case err := <-exited:
+		// An exit racing the detach is left for the next llamactl to find out
+		select {
+		case <-detach:
+			return true, nil
+		default:
+			return false, err
+		}
+	case <-detach:
+		return true, nil
+	}
+}
+
+// leaveDetached records how much of the output of the detached process was logged and lets
+// go of the process (caller must hold the lock)
+func (i *Process) leaveDetached(state *processState, output *processOutput) {
+	stdout, stderr := output.files[0].offset.Load(), output.files[1].offset.Load()
+	state.StdoutOffset, state.StderrOffset = &stdout, &stderr
+	if err := i.logger.writeProcessState(state); err != nil {
+		log.Printf("Failed to record the process of instance %s: %v", i.Name, err)
+	}
+	log.Printf("Leaving instance %s running as process %d", i.Name, state.PID)
+	i.logger.closeWithMarker("left running")
+	i.cmd = nil
+	if i.processState == state {
+		i.processState = nil
+	}
+}
+
+// clearProcessState removes the state file of the exited process of state
+// (caller must hold the lock)
+func (i *Process) clearProcessState(state *processState) {
+	if state == nil || i.processState != state {
+		return
+	}
+	i.processState = nil
+	i.logger.removeProcessState()
+}
+
+// processOutput is the output of a backend process, read into the logs
+type processOutput struct {
+	stdout, stderr io.ReadCloser
+	files          []*outputFile // Output files of a process spawned with leave_running
+	readers        sync.WaitGroup
+}
+
+// drain waits for the output of an exited or detached process to be read
+func (o *processOutput) drain() {
+	if len(o.files) == 0 {
+		drainOutput(&o.readers, []io.Closer{o.stdout, o.stderr})
+		return
+	}
+	for _, f := range o.files {
+		f.stopFollowing()
+	}
+	o.readers.Wait()
+}
+
+// outputFile follows an output file a process spawned with leave_running writes to, since
+// a pipe would break once llamactl exits. Reads wait for new output until it is stopped,
+// then return what is left up to the end of the file.
+type outputFile struct {
+	file   *os.File
+	offset atomic.Int64 // Bytes read from the file
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func (f *outputFile) Read(p []byte) (int, error) {
+	for {
+		n, err := f.file.Read(p)
+		f.offset.Add(int64(n))
+		if n > 0 || (err != nil && err != io.EOF) {
+			return n, err
+		}
+		select {
+		case <-f.stop:
+			n, err := f.file.Read(p)
+			f.offset.Add(int64(n))
+			if n > 0 {
+				return n, nil
+			}
+			if err == nil {
+				err = io.EOF
+			}
+			return 0, err
+		case <-time.After(outputPollInterval):
+		}
+	}
+}
+
+func (f *outputFile) Close() error {
+	return f.file.Close()
+}
+
+// stopFollowing ends the reads once they reach the end of the file
+func (f *outputFile) stopFollowing() {
+	f.once.Do(func() { close(f.stop) })
+}
+
+// outputPollInterval is how often a followed output file is checked for new output
+const outputPollInterval = 100 * time.Millisecond
+
+// outputPath returns the path of the file a process spawned with leave_running writes the
+// stream to
+func (i *InstanceLogger) outputPath(stream string) string {
+	return filepath.Join(i.logDir, i.name+"."+stream)
+}
+
+// processStatePath returns the path of the state file of a process spawned with leave_running
+func (i *InstanceLogger) processStatePath() string {
+	return filepath.Join(i.logDir, i.name+".process.json")
+}
+
+// createOutputFiles truncates the output files of a new process, and returns the ends it
+// writes to
+func (i *InstanceLogger) createOutputFiles() (stdout, stderr *os.File, err error) {
+	stdout, err = os.OpenFile(i.outputPath(LogStreamStdout), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create stdout file: %w", err)
+	}
+	stderr, err = os.OpenFile(i.outputPath(LogStreamStderr), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		stdout.Close()
+		return nil, nil, fmt.Errorf("failed to create stderr file: %w", err)
+	}
+	return stdout, stderr, nil
+}
+
+// followOutput opens the output files for reading from the given offsets, or from their end
+// for unknown offsets
+func (i *InstanceLogger) followOutput(stdoutOffset, stderrOffset *int64) (*processOutput, error) {
+	output := &processOutput{}
+	for _, stream := range []struct {
+		name   string
+		offset *int64
+	}{{LogStreamStdout, stdoutOffset}, {LogStreamStderr, stderrOffset}} {
+		file, err := os.Open(i.outputPath(stream.name))
+		if err == nil {
+			if stream.offset != nil {
+				_, err = file.Seek(*stream.offset, io.SeekStart)
+			} else {
+				_, err = file.Seek(0, io.SeekEnd)
+			}
+			if err != nil {
+				file.Close()
+			}
+		}
+		if err != nil {
+			for _, f := range output.files {
+				f.Close()
+			}
+			return nil, fmt.Errorf("failed to open %s file: %w", stream.name, err)
+		}
+		output.files = append(output.files, &outputFile{file: file, stop: make(chan struct{})})
+	}
+	output.stdout, output.stderr = output.files[0], output.files[1]
+	return output, nil
+}
+
+// readProcessState reads the state file of a process spawned with leave_running
+func (i *InstanceLogger) readProcessState() (*processState, error) {
+	data, err := os.ReadFile(i.processStatePath())
+	if err != nil {
+		return nil, err
+	}
+	var state processState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid process state: %w", err)
+	}
+	if state.PID <= 0 {
+		return nil, fmt.Errorf("invalid process state: no pid")
+	}
+	return &state, nil
+}
+
+// writeProcessState replaces the state file of a process spawned with leave_running
+func (i *InstanceLogger) writeProcessState(state *processState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	path := i.processStatePath()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write process state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write process state: %w", err)
+	}
+	return nil
+}
+
+// removeProcessState removes the state file of an exited process
+func (i *InstanceLogger) removeProcessState() {
+	if err := os.Remove(i.processStatePath()); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove the process state of instance %s: %v", i.name, err)
+	}
+}
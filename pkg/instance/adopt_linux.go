@@ -0,0 +1,35 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// AdoptionSupported reports whether backends can be left running on shutdown and adopted
+// back, which needs the binary a process runs to be known
+const AdoptionSupported = true
+
+// processAlive reports whether the process still runs
+func processAlive(proc *os.Process) bool {
+	return proc.Signal(syscall.Signal(0)) == nil
+}
+
+// processExecutable returns the binary the process runs
+func processExecutable(pid int) (os.FileInfo, error) {
+	return os.Stat(fmt.Sprintf("/proc/%d/exe", pid))
+}
+
+// processCommandLine returns the argument vector the process reports
+func processCommandLine(pid int) ([]string, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("the process reports no command line")
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\x00"), "\x00"), nil
+}
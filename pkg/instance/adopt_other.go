@@ -0,0 +1,29 @@
+//go:build !linux
+
+package instance
+
+import (
+	"errors"
+	"os"
+)
+
+// AdoptionSupported reports whether backends can be left running on shutdown and adopted
+// back, which needs the binary a process runs to be known
+const AdoptionSupported = false
+
+var (
+	errAdoptionUnsupported    = errors.New("adopting processes is not supported on this platform")
+	errCommandLineUnsupported = errors.New("reading the command line of a process is not supported on this platform")
+)
+
+func processAlive(proc *os.Process) bool {
+	return false
+}
+
+func processExecutable(pid int) (os.FileInfo, error) {
+	return nil, errAdoptionUnsupported
+}
+
+func processCommandLine(pid int) ([]string, error) {
+	return nil, errCommandLineUnsupported
+}
@@ -0,0 +1,186 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/backends/mlx"
+	"llamactl/pkg/backends/vllm"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// llamaCppKeyAliases are the spellings of llama.cpp options used by its own JSON, e.g. the
+// /props endpoint and the llama-cpp-python settings, that differ from the flag names
+var llamaCppKeyAliases = map[string]string{
+	"n_ctx":           "ctx_size",
+	"n_batch":         "batch_size",
+	"n_ubatch":        "ubatch_size",
+	"n_threads":       "threads",
+	"n_threads_batch": "threads_batch",
+	"n_gpu_layers":    "gpu_layers",
+	"n_predict":       "predict",
+	"n_parallel":      "parallel",
+	"n_keep":          "keep",
+}
+
+// optionKeyAliases are the spellings of top-level options kept for clients that spell them
+// after the unit of their bare values
+var optionKeyAliases = map[string]string{
+	"idle_timeout_minutes": "idle_timeout",
+	"idleTimeoutMinutes":   "idle_timeout",
+}
+
+// OptionAliases maps the alternative spellings of option keys accepted when decoding
+// instance options onto their canonical names. Options are always encoded with the
+// canonical names.
+type OptionAliases struct {
+	Options        map[string]string                          `json:"options"`         // Top-level keys
+	BackendOptions map[backends.BackendType]map[string]string `json:"backend_options"` // Keys of backend_options, per backend type
+}
+
+// AliasConflictError reports an option set under both its canonical name and an alias,
+// with different values. Keys are JSON paths, e.g. backend_options.ctx_size.
+type AliasConflictError struct {
+	Key   string
+	Alias string
+	Value any // Value of the alias
+}
+
+func (e *AliasConflictError) Error() string {
+	return fmt.Sprintf("%s and %s are the same option but are set to different values", e.Alias, e.Key)
+}
+
+// Aliases returns the alias table of the instance options
+var Aliases = sync.OnceValue(func() OptionAliases {
+	llamaCpp := camelCaseAliases(reflect.TypeFor[llamacpp.LlamaServerOptions]())
+	for alias, key := range llamaCppKeyAliases {
+		llamaCpp[alias] = key
+	}
+	options := camelCaseAliases(reflect.TypeFor[CreateInstanceOptions]())
+	for alias, key := range optionKeyAliases {
+		options[alias] = key
+	}
+	return OptionAliases{
+		Options: options,
+		BackendOptions: map[backends.BackendType]map[string]string{
+			backends.BackendTypeLlamaCpp: llamaCpp,
+			backends.BackendTypeMlxLm:    camelCaseAliases(reflect.TypeFor[mlx.MlxServerOptions]()),
+			backends.BackendTypeVllm:     camelCaseAliases(reflect.TypeFor[vllm.VllmServerOptions]()),
+		},
+	}
+})
+
+// camelCaseAliases maps the camelCase spelling of every JSON key of a struct onto the key
+func camelCaseAliases(t reflect.Type) map[string]string {
+	keys := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if key != "" && key != "-" {
+			keys[key] = true
+		}
+	}
+
+	aliases := map[string]string{}
+	for key := range keys {
+		alias := camelCase(key)
+		if alias != key && !keys[alias] {
+			aliases[alias] = key
+		}
+	}
+	return aliases
+}
+
+// camelCase converts a snake_case or kebab-case key, e.g. ctx_size to ctxSize
+func camelCase(key string) string {
+	parts := strings.FieldsFunc(key, func(r rune) bool { return r == '_' || r == '-' })
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// normalizeKeys renames the aliased keys of a JSON object to their canonical names. prefix
+// is the JSON path of the object in the error of a conflict. Conflicts are reported in the
+// order of the canonical keys, so the error does not depend on map iteration.
+func normalizeKeys(raw map[string]json.RawMessage, aliases map[string]string, prefix string) error {
+	var found []string
+	for key := range raw {
+		if _, ok := aliases[key]; ok {
+			found = append(found, key)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if aliases[found[i]] != aliases[found[j]] {
+			return aliases[found[i]] < aliases[found[j]]
+		}
+		return found[i] < found[j]
+	})
+
+	setBy := map[string]string{} // Key a canonical key was set under, if an alias
+	for _, alias := range found {
+		key := aliases[alias]
+		value := raw[alias]
+		delete(raw, alias)
+		if existing, ok := raw[key]; ok {
+			if !sameJSON(existing, value) {
+				var decoded any
+				json.Unmarshal(value, &decoded)
+				other := key
+				if setBy[key] != "" {
+					other = setBy[key]
+				}
+				return &AliasConflictError{Key: prefix + other, Alias: prefix + alias, Value: decoded}
+			}
+			continue
+		}
+		raw[key] = value
+		setBy[key] = alias
+	}
+	return nil
+}
+
+// sameJSON reports whether two JSON values are equal, regardless of formatting
+func sameJSON(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// canonicalizeOptions returns instance options JSON with the aliased top-level and
+// backend_options keys renamed to their canonical names
+func canonicalizeOptions(data []byte) ([]byte, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		// Left to the regular decoding to report
+		return data, nil
+	}
+
+	aliases := Aliases()
+	if err := normalizeKeys(raw, aliases.Options, ""); err != nil {
+		return nil, err
+	}
+
+	var backendType backends.BackendType
+	json.Unmarshal(raw["backend_type"], &backendType)
+	if backendAliases, ok := aliases.BackendOptions[backendType]; ok && len(raw["backend_options"]) > 0 {
+		var backendOptions map[string]json.RawMessage
+		if err := json.Unmarshal(raw["backend_options"], &backendOptions); err == nil && backendOptions != nil {
+			if err := normalizeKeys(backendOptions, backendAliases, "backend_options."); err != nil {
+				return nil, err
+			}
+			encoded, err := json.Marshal(backendOptions)
+			if err != nil {
+				return nil, err
+			}
+			raw["backend_options"] = encoded
+		}
+	}
+
+	return json.Marshal(raw)
+}
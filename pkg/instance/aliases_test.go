@@ -0,0 +1,119 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"errors"
+	"llamactl/pkg/instance"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionAliases_Decode(t *testing.T) {
+	data := `{
+		"backendType": "llama_cpp",
+		"autoRestart": true,
+		"maxRestarts": 3,
+		"idle_timeout_minutes": 30,
+		"backendOptions": {"model": "/path/to/model.gguf", "ctxSize": 4096, "n_gpu_layers": 99, "flashAttn": true}
+	}`
+	var options instance.CreateInstanceOptions
+	if err := json.Unmarshal([]byte(data), &options); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if options.AutoRestart == nil || !*options.AutoRestart || options.MaxRestarts == nil || *options.MaxRestarts != 3 {
+		t.Errorf("Expected the camelCase top-level keys to be decoded, got %+v", options)
+	}
+	if options.IdleTimeout == nil || options.IdleTimeout.Duration() != 30*time.Minute {
+		t.Errorf("Expected idle_timeout_minutes to be decoded as the idle timeout, got %v", options.IdleTimeout)
+	}
+	llama := options.LlamaServerOptions
+	if llama == nil || llama.Model != "/path/to/model.gguf" || llama.CtxSize != 4096 || llama.GPULayers != 99 || !llama.FlashAttn {
+		t.Fatalf("Expected the aliased backend options to be decoded, got %+v", llama)
+	}
+
+	// Encoded with the canonical keys only
+	encoded, err := json.Marshal(&options)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	for _, key := range []string{`"auto_restart"`, `"backend_type"`, `"ctx_size"`, `"gpu_layers"`} {
+		if !strings.Contains(string(encoded), key) {
+			t.Errorf("Expected %s in %s", key, encoded)
+		}
+	}
+	for _, key := range []string{`"autoRestart"`, `"ctxSize"`, `"n_gpu_layers"`} {
+		if strings.Contains(string(encoded), key) {
+			t.Errorf("Expected no %s in %s", key, encoded)
+		}
+	}
+}
+
+func TestOptionAliases_Conflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantKey   string
+		wantAlias string
+	}{
+		{
+			name:      "top-level",
+			data:      `{"backend_type": "llama_cpp", "max_restarts": 3, "maxRestarts": 5}`,
+			wantKey:   "max_restarts",
+			wantAlias: "maxRestarts",
+		},
+		{
+			name:      "backend options",
+			data:      `{"backend_type": "llama_cpp", "backend_options": {"ctx_size": 4096, "n_ctx": 8192}}`,
+			wantKey:   "backend_options.ctx_size",
+			wantAlias: "backend_options.n_ctx",
+		},
+		{
+			name:      "two aliases",
+			data:      `{"backend_type": "llama_cpp", "backend_options": {"ctxSize": 4096, "n_ctx": 8192}}`,
+			wantKey:   "backend_options.ctxSize",
+			wantAlias: "backend_options.n_ctx",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options instance.CreateInstanceOptions
+			err := json.Unmarshal([]byte(tt.data), &options)
+			var conflict *instance.AliasConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("Expected an alias conflict, got %v", err)
+			}
+			if conflict.Key != tt.wantKey || conflict.Alias != tt.wantAlias {
+				t.Errorf("Expected a conflict between %s and %s, got %+v", tt.wantKey, tt.wantAlias, conflict)
+			}
+		})
+	}
+
+	// The same value under two spellings is not a conflict
+	var options instance.CreateInstanceOptions
+	if err := json.Unmarshal([]byte(`{"backend_type": "llama_cpp", "backend_options": {"ctx_size": 4096, "n_ctx": 4096}}`), &options); err != nil {
+		t.Fatalf("Expected equal values to be accepted, got %v", err)
+	}
+	if options.LlamaServerOptions.CtxSize != 4096 {
+		t.Errorf("Expected ctx_size 4096, got %d", options.LlamaServerOptions.CtxSize)
+	}
+}
+
+func TestOptionAliases_Table(t *testing.T) {
+	aliases := instance.Aliases()
+	if aliases.Options["autoRestart"] != "auto_restart" || aliases.Options["backendOptions"] != "backend_options" {
+		t.Errorf("Expected camelCase aliases of the top-level keys, got %v", aliases.Options)
+	}
+	if _, ok := aliases.Options["tags"]; ok {
+		t.Error("Expected no alias for a key that is already a single word")
+	}
+	llama := aliases.BackendOptions["llama_cpp"]
+	if llama["ctxSize"] != "ctx_size" || llama["n_ctx"] != "ctx_size" {
+		t.Errorf("Expected the camelCase and llama.cpp spellings of ctx_size, got %v", llama)
+	}
+	if aliases.BackendOptions["vllm"]["tensorParallelSize"] != "tensor_parallel_size" {
+		t.Errorf("Expected camelCase aliases of the vLLM options")
+	}
+}
@@ -0,0 +1,98 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// defaultAllowedPaths is the effective allowed_paths of an instance that sets none: every path
+var defaultAllowedPaths = []string{"/"}
+
+// managementRequestKey marks the context of requests originating from llamactl itself
+type managementRequestKey struct{}
+
+// WithManagementRequest marks a request as originating from llamactl's management API,
+// such as the health checks of the web UI. The allowed paths of an instance do not apply to
+// it. Clients cannot set the flag, it only exists in the request context.
+func WithManagementRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, managementRequestKey{}, true)
+}
+
+// isManagementRequest reports whether WithManagementRequest marked the context
+func isManagementRequest(ctx context.Context) bool {
+	management, _ := ctx.Value(managementRequestKey{}).(bool)
+	return management
+}
+
+// AllowedPathMatches reports whether a path matches an entry of allowed_paths: a glob
+// pattern when it contains *, ? or [, whose * matches within a single path segment,
+// otherwise a prefix matching the path itself and every path below it
+func AllowedPathMatches(entry, requestPath string) bool {
+	if strings.ContainsAny(entry, "*?[") {
+		matched, _ := path.Match(entry, requestPath)
+		return matched
+	}
+	prefix := strings.TrimSuffix(entry, "/")
+	return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+}
+
+// AllowedPaths returns the paths the proxy forwards to the backend, every path unless
+// allowed_paths is set
+func (i *Process) AllowedPaths() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.allowedPaths()
+}
+
+// allowedPaths is AllowedPaths with the lock held
+func (i *Process) allowedPaths() []string {
+	if i.options == nil || len(i.options.AllowedPaths) == 0 {
+		return defaultAllowedPaths
+	}
+	return i.options.AllowedPaths
+}
+
+// AllowsRequest reports whether the proxy forwards a request to the backend: its path
+// matches allowed_paths, or it originates from the management API. The path is matched
+// once cleaned, so dot segments cannot reach a path that is not allowed.
+func (i *Process) AllowsRequest(r *http.Request) bool {
+	if isManagementRequest(r.Context()) {
+		return true
+	}
+	requestPath := path.Clean("/" + r.URL.Path)
+	return slices.ContainsFunc(i.AllowedPaths(), func(entry string) bool {
+		return AllowedPathMatches(entry, requestPath)
+	})
+}
+
+// SetAllowedPaths replaces the allowed paths of the instance without restarting it. No
+// paths allow every path.
+func (i *Process) SetAllowedPaths(paths []string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.options == nil {
+		return fmt.Errorf("instance %s has no options set", i.Name)
+	}
+	if len(paths) == 0 {
+		paths = nil
+	}
+	options := *i.options
+	options.AllowedPaths = paths
+	i.options = &options
+	if i.optionSources == nil {
+		i.optionSources = make(OptionSources)
+	}
+	if paths != nil {
+		i.optionSources["allowed_paths"] = SourceExplicit
+	} else {
+		delete(i.optionSources, "allowed_paths")
+	}
+	i.patchPendingOptions("allowed_paths", paths != nil, func(options *CreateInstanceOptions) {
+		options.AllowedPaths = paths
+	})
+	return nil
+}
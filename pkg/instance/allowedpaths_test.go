@@ -0,0 +1,63 @@
+package instance_test
+
+import (
+	"context"
+	"llamactl/pkg/instance"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowedPathMatches(t *testing.T) {
+	tests := []struct {
+		entry, path string
+		want        bool
+	}{
+		{"/", "/slots", true},
+		{"/v1/embeddings", "/v1/embeddings", true},
+		{"/v1/embeddings", "/v1/embeddings/batch", true},
+		{"/v1/embeddings/", "/v1/embeddings", true},
+		{"/v1/embeddings", "/v1/embeddingsx", false},
+		{"/v1", "/v1/chat/completions", true},
+		{"/v1/*", "/v1/embeddings", true},
+		{"/v1/*", "/v1/chat/completions", false},
+		{"/v1/*/completions", "/v1/chat/completions", true},
+	}
+
+	for _, tt := range tests {
+		if got := instance.AllowedPathMatches(tt.entry, tt.path); got != tt.want {
+			t.Errorf("AllowedPathMatches(%q, %q) = %v, want %v", tt.entry, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestAllowsRequest(t *testing.T) {
+	inst := newShellInstance(t, "exec sleep 30", false, nil)
+	if paths := inst.AllowedPaths(); len(paths) != 1 || paths[0] != "/" {
+		t.Errorf("Expected every path to be allowed by default, got %v", paths)
+	}
+
+	if err := inst.SetAllowedPaths([]string{"/v1/embeddings"}); err != nil {
+		t.Fatalf("SetAllowedPaths failed: %v", err)
+	}
+	if !inst.AllowsRequest(httptest.NewRequest("POST", "/v1/embeddings", nil)) {
+		t.Error("Expected an allowed path to be forwarded")
+	}
+	for _, path := range []string{"/slots", "/v1/embeddings/../../slots"} {
+		if inst.AllowsRequest(httptest.NewRequest("GET", path, nil)) {
+			t.Errorf("Expected %s to be rejected", path)
+		}
+	}
+
+	management := httptest.NewRequest("GET", "/slots", nil)
+	management = management.WithContext(instance.WithManagementRequest(context.Background()))
+	if !inst.AllowsRequest(management) {
+		t.Error("Expected a management request to bypass the allowed paths")
+	}
+
+	if err := inst.SetAllowedPaths(nil); err != nil {
+		t.Fatalf("SetAllowedPaths failed: %v", err)
+	}
+	if !inst.AllowsRequest(httptest.NewRequest("GET", "/slots", nil)) {
+		t.Error("Expected every path to be allowed once the allowed paths are removed")
+	}
+}
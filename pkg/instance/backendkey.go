@@ -0,0 +1,81 @@
+package instance
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"llamactl/pkg/backends"
+)
+
+// managedKeyPlaceholder stands for the managed backend key in command previews
+const managedKeyPlaceholder = "[managed]"
+
+// UsesManagedBackendKey reports whether llamactl generates the API key of the backend
+func (c *CreateInstanceOptions) UsesManagedBackendKey() bool {
+	return c != nil && c.ManagedBackendKey != nil && *c.ManagedBackendKey && c.BackendType == backends.BackendTypeLlamaCpp
+}
+
+// withAPIKey returns a copy of the options whose llama.cpp backend is started with key
+func (c *CreateInstanceOptions) withAPIKey(key string) *CreateInstanceOptions {
+	opts := *c
+	if c.LlamaServerOptions != nil {
+		backendOpts := *c.LlamaServerOptions
+		backendOpts.APIKey = key
+		opts.LlamaServerOptions = &backendOpts
+	}
+	return &opts
+}
+
+// GenerateBackendKey returns a new random API key for the backend of an instance
+func GenerateBackendKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate backend key: %w", err)
+	}
+	return "llamactl-" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SetBackendKey sets the managed API key of the backend, or removes it when key is empty.
+// A running backend keeps the key it was started with, and is reached with it, until it
+// is restarted, so the old and new keys are never in use at the same time.
+func (i *Process) SetBackendKey(key string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.IsRunning() {
+		i.pendingBackendKey = &key
+		return
+	}
+	i.backendKey = key
+	i.pendingBackendKey = nil
+}
+
+// HasBackendKey reports whether the instance has a managed backend key, applied or pending
+func (i *Process) HasBackendKey() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.pendingBackendKey != nil {
+		return *i.pendingBackendKey != ""
+	}
+	return i.backendKey != ""
+}
+
+// BackendKeyPending reports whether a rotated backend key waits for the next start
+func (i *Process) BackendKeyPending() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.pendingBackendKey != nil
+}
+
+// backendAuthKey returns the key requests to the backend authenticate with: the managed key
+// the running process was started with, or the api_key option
+func (i *Process) backendAuthKey() string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.backendKey != "" {
+		return i.backendKey
+	}
+	if i.options != nil && i.options.LlamaServerOptions != nil {
+		return i.options.LlamaServerOptions.APIKey
+	}
+	return ""
+}
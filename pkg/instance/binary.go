@@ -0,0 +1,156 @@
+package instance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BinaryIdentity identifies the backend binary a process was launched from, so that the
+// binary being replaced on disk since, e.g. by an upgrade of llama.cpp, can be told
+type BinaryIdentity struct {
+	Path    string    `json:"path"` // Backend command, resolved on the PATH
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	SHA256  string    `json:"sha256"`
+
+	info os.FileInfo // Compared with os.SameFile
+}
+
+// resolveBinary returns the absolute path of the binary command runs
+func resolveBinary(command string) (string, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// checkExecutable returns why the backend command cannot be run, nil if it names an
+// executable file, as a path or on the PATH. A command run through a launch wrapper is left
+// to the wrapper, which may find it elsewhere.
+func checkExecutable(command string, wrapped bool) error {
+	if wrapped {
+		return nil
+	}
+	if command == "" {
+		return fmt.Errorf("no backend executable is configured")
+	}
+	if _, err := exec.LookPath(command); err == nil {
+		return nil
+	}
+	if !strings.ContainsAny(command, `/\`) {
+		return fmt.Errorf("backend executable %q not found in PATH", command)
+	}
+	info, err := os.Stat(command)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return fmt.Errorf("backend executable %s does not exist", command)
+	case err != nil:
+		return fmt.Errorf("backend executable %s cannot be read: %w", command, err)
+	case info.IsDir():
+		return fmt.Errorf("backend executable %s is a directory", command)
+	default:
+		return fmt.Errorf("backend executable %s is not executable (mode %s)", command, info.Mode().Perm())
+	}
+}
+
+// identifyBinary reads the binary command runs into its identity
+func identifyBinary(command string) (*BinaryIdentity, error) {
+	path, err := resolveBinary(command)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return &BinaryIdentity{
+		Path:    path,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UTC(),
+		SHA256:  hex.EncodeToString(hash.Sum(nil)),
+		info:    info,
+	}, nil
+}
+
+// replaced reports whether the file at the path of the binary is no longer the one the
+// process was launched from, by its size, modification time and inode, without reading it
+func (b *BinaryIdentity) replaced() bool {
+	info, err := os.Stat(b.Path)
+	if err != nil {
+		return true
+	}
+	return (b.info != nil && !os.SameFile(b.info, info)) || info.Size() != b.Size || !info.ModTime().Equal(b.ModTime)
+}
+
+// backendCommandFor returns the command the backend is run with the options opts, before
+// the launch wrapper (caller must hold the lock)
+func (i *Process) backendCommandFor(opts *CreateInstanceOptions) (string, error) {
+	backendConfig, err := i.getBackendConfig()
+	if err != nil {
+		return "", err
+	}
+	return opts.GetCommand(backendConfig), nil
+}
+
+// GetBinary returns the backend binary the running process was launched from, nil if the
+// instance does not run or it could not be read
+func (i *Process) GetBinary() *BinaryIdentity {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if !i.IsRunning() {
+		return nil
+	}
+	return i.binary
+}
+
+// BinaryChanged reports whether the binary a new start would run differs from the one the
+// running process was launched from, along with why. It is compared by checksum, so a binary
+// only touched, or installed again as it was, is not a change; a binary the process was
+// launched from that is unknown is.
+func (i *Process) BinaryChanged() (bool, string, error) {
+	i.mu.RLock()
+	if i.options == nil || !i.IsRunning() {
+		i.mu.RUnlock()
+		return false, "", fmt.Errorf("instance %s is not running: %w", i.Name, ErrNotRunning)
+	}
+	launched := i.binary
+	command, err := i.backendCommandFor(i.options)
+	i.mu.RUnlock()
+	if err != nil {
+		return false, "", err
+	}
+
+	if launched == nil {
+		return true, "the binary the process was launched from is unknown", nil
+	}
+	if path, err := resolveBinary(command); err == nil && path == launched.Path && !launched.replaced() {
+		return false, fmt.Sprintf("%s is unchanged since the process was launched", path), nil
+	}
+	current, err := identifyBinary(command)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to read the backend binary of instance %s: %w", i.Name, err)
+	}
+	if current.SHA256 == launched.SHA256 {
+		return false, fmt.Sprintf("%s has the checksum of the binary the process was launched from", current.Path), nil
+	}
+	return true, fmt.Sprintf("%s changed since the process was launched from %s", current.Path, launched.Path), nil
+}
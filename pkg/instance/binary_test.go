@@ -0,0 +1,157 @@
+package instance_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// copyBinary copies the binary of command to path, with extra appended to tell copies apart
+func copyBinary(t *testing.T, command, path string, extra string) {
+	t.Helper()
+	source, err := exec.LookPath(command)
+	if err != nil {
+		t.Skipf("%s not found: %v", command, err)
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", source, err)
+	}
+	// Replaced through a rename, as package managers do, so the running copy is left alone
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, extra...), 0755); err != nil {
+		t.Fatalf("Failed to write %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Failed to rename %s: %v", tmp, err)
+	}
+}
+
+func TestBinaryChanged(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "llama-server")
+	copyBinary(t, "sh", binary, "")
+	crash := filepath.Join(dir, "crash")
+
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{
+			Command: binary,
+			Args:    []string{"-c", "while [ ! -e " + crash + " ]; do sleep 0.05; done; exit 3"},
+		},
+	}
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+	inst := instance.NewInstance("binary-instance", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+	t.Cleanup(func() { inst.Stop() })
+
+	if _, _, err := inst.BinaryChanged(); err == nil {
+		t.Error("Expected a stopped instance to have no binary to compare")
+	}
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	launched := inst.GetBinary()
+	if launched == nil || launched.Path != binary || launched.SHA256 == "" || launched.Size == 0 {
+		t.Fatalf("Expected the binary the process was launched from, got %+v", launched)
+	}
+
+	if changed, reason, err := inst.BinaryChanged(); err != nil || changed {
+		t.Errorf("Expected the binary to be unchanged, got %v (%s, %v)", changed, reason, err)
+	}
+
+	// Only touched: the checksum is the same
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(binary, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, reason, err := inst.BinaryChanged(); err != nil || changed {
+		t.Errorf("Expected a touched binary not to be a change, got %v (%s, %v)", changed, reason, err)
+	}
+
+	copyBinary(t, "sh", binary, "upgraded")
+	if changed, reason, err := inst.BinaryChanged(); err != nil || !changed || reason == "" {
+		t.Errorf("Expected the replaced binary to be a change, got %v (%q, %v)", changed, reason, err)
+	}
+
+	// A crash after the binary was replaced is labeled
+	if err := os.WriteFile(crash, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, inst, instance.Failed)
+	if lastExit := inst.GetLastExit(); lastExit == nil || !lastExit.BinaryReplaced {
+		t.Errorf("Expected the crash to be labeled with the binary replaced, got %+v", lastExit)
+	}
+	if inst.GetBinary() != nil {
+		t.Error("Expected a failed instance to report no binary")
+	}
+}
+
+func TestStart_LlamaExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "llama-server-vulkan")
+	copyBinary(t, "sh", binary, "")
+	notExecutable := filepath.Join(dir, "llama-server-cuda")
+	if err := os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "definitely-not-a-real-llama-server", Args: []string{"-c", "exec sleep 30"}},
+	}
+	newInstance := func(executable string) *instance.Process {
+		options := &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			AutoRestart:        testutil.BoolPtr(false),
+			LlamaExecutable:    executable,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+		}
+		inst := instance.NewInstance("executable-instance", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+		t.Cleanup(func() { inst.Stop() })
+		return inst
+	}
+
+	tests := []struct {
+		name       string
+		executable string
+		message    string
+	}{
+		{"not in PATH", "", "not found in PATH"},
+		{"missing file", filepath.Join(dir, "missing"), "does not exist"},
+		{"directory", dir, "is a directory"},
+		{"not executable", notExecutable, "is not executable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newInstance(tt.executable).Start()
+			if err == nil || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected the start to fail with %q, got %v", tt.message, err)
+			}
+		})
+	}
+
+	inst := newInstance(binary)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if launched := inst.GetBinary(); launched == nil || launched.Path != binary {
+		t.Errorf("Expected the process to be launched from %s, got %+v", binary, launched)
+	}
+}
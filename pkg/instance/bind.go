@@ -0,0 +1,268 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"llamactl/pkg/backends"
+	"log"
+	"net"
+	"os/exec"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Listen verification polls the process group until the backend listens on its port
+const (
+	listenCheckInterval = time.Second
+	listenCheckTimeout  = 10 * time.Minute
+)
+
+// errListenCheckUnsupported is returned where listening sockets cannot be inspected
+var errListenCheckUnsupported = errors.New("listen verification is only supported on Linux")
+
+// ListenCheck is the result of verifying where the backend of an instance listens.
+// Degraded is set when the backend also listens on an address other than the one it is
+// bound to.
+type ListenCheck struct {
+	Expected  string    `json:"expected"`
+	Listening []string  `json:"listening,omitempty"`
+	Degraded  bool      `json:"degraded"`
+	Message   string    `json:"message,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// GetListenCheck returns the result of the listen verification of the current run, or nil
+// if it has not completed or the instance is not bound to an interface
+func (i *Process) GetListenCheck() *ListenCheck {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.ListenCheck
+}
+
+// resolveBindAddress resolves bind_interface, an interface name or one of the addresses of
+// a local interface, to the address the backend listens on. The interface must be up, and
+// bind_host, if set, must be an address of the interface.
+func resolveBindAddress(bindInterface, host string) (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to list network interfaces: %w", err)
+	}
+
+	wantIP := net.ParseIP(bindInterface)
+	for _, iface := range ifaces {
+		if wantIP == nil && iface.Name != bindInterface {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return "", fmt.Errorf("failed to list addresses of interface %s: %w", iface.Name, err)
+		}
+		var ips []net.IP
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipNet.IP)
+			}
+		}
+
+		var selected net.IP
+		switch {
+		case wantIP != nil:
+			if !slices.ContainsFunc(ips, wantIP.Equal) {
+				continue
+			}
+			selected = wantIP
+		case host != "":
+			hostIP := net.ParseIP(host)
+			if hostIP == nil || !slices.ContainsFunc(ips, hostIP.Equal) {
+				return "", fmt.Errorf("bind_host %s is not an address of interface %s", host, bindInterface)
+			}
+			selected = hostIP
+		default:
+			// Prefer IPv4, which every backend accepts as a host
+			for _, ip := range ips {
+				if ip.To4() != nil {
+					selected = ip
+					break
+				}
+			}
+			if selected == nil && len(ips) > 0 {
+				selected = ips[0]
+			}
+		}
+
+		if iface.Flags&net.FlagUp == 0 {
+			return "", fmt.Errorf("interface %s is down", iface.Name)
+		}
+		if selected == nil {
+			return "", fmt.Errorf("interface %s has no usable address", iface.Name)
+		}
+		if host != "" && !selected.Equal(net.ParseIP(host)) {
+			return "", fmt.Errorf("bind_host %s is not an address of interface %s", host, bindInterface)
+		}
+		return selected.String(), nil
+	}
+
+	if wantIP != nil {
+		return "", fmt.Errorf("address %s does not belong to any network interface", bindInterface)
+	}
+	return "", fmt.Errorf("network interface %s not found", bindInterface)
+}
+
+// BackendHost returns the host set in the backend options. It is only set on options not
+// yet normalized, or when it conflicts with bind_host.
+func (c *CreateInstanceOptions) BackendHost() string {
+	if c == nil {
+		return ""
+	}
+	switch c.BackendType {
+	case backends.BackendTypeLlamaCpp:
+		if c.LlamaServerOptions != nil {
+			return c.LlamaServerOptions.Host
+		}
+	case backends.BackendTypeMlxLm:
+		if c.MlxServerOptions != nil {
+			return c.MlxServerOptions.Host
+		}
+	case backends.BackendTypeVllm:
+		if c.VllmServerOptions != nil {
+			return c.VllmServerOptions.Host
+		}
+	}
+	return ""
+}
+
+// port returns the port of the backend options
+func (c *CreateInstanceOptions) port() int {
+	if c == nil {
+		return 0
+	}
+	switch c.BackendType {
+	case backends.BackendTypeLlamaCpp:
+		if c.LlamaServerOptions != nil {
+			return c.LlamaServerOptions.Port
+		}
+	case backends.BackendTypeMlxLm:
+		if c.MlxServerOptions != nil {
+			return c.MlxServerOptions.Port
+		}
+	case backends.BackendTypeVllm:
+		if c.VllmServerOptions != nil {
+			return c.VllmServerOptions.Port
+		}
+	}
+	return 0
+}
+
+// withHost returns a copy of the options whose backend listens on host
+func (c *CreateInstanceOptions) withHost(host string) *CreateInstanceOptions {
+	opts := *c
+	switch c.BackendType {
+	case backends.BackendTypeLlamaCpp:
+		if c.LlamaServerOptions != nil {
+			backendOpts := *c.LlamaServerOptions
+			backendOpts.Host = host
+			opts.LlamaServerOptions = &backendOpts
+		}
+	case backends.BackendTypeMlxLm:
+		if c.MlxServerOptions != nil {
+			backendOpts := *c.MlxServerOptions
+			backendOpts.Host = host
+			opts.MlxServerOptions = &backendOpts
+		}
+	case backends.BackendTypeVllm:
+		if c.VllmServerOptions != nil {
+			backendOpts := *c.VllmServerOptions
+			backendOpts.Host = host
+			opts.VllmServerOptions = &backendOpts
+		}
+	}
+	return &opts
+}
+
+// withPort returns a copy of the options whose backend listens on port
+func (c *CreateInstanceOptions) withPort(port int) *CreateInstanceOptions {
+	opts := *c
+	switch c.BackendType {
+	case backends.BackendTypeLlamaCpp:
+		if c.LlamaServerOptions != nil {
+			backendOpts := *c.LlamaServerOptions
+			backendOpts.Port = port
+			opts.LlamaServerOptions = &backendOpts
+		}
+	case backends.BackendTypeMlxLm:
+		if c.MlxServerOptions != nil {
+			backendOpts := *c.MlxServerOptions
+			backendOpts.Port = port
+			opts.MlxServerOptions = &backendOpts
+		}
+	case backends.BackendTypeVllm:
+		if c.VllmServerOptions != nil {
+			backendOpts := *c.VllmServerOptions
+			backendOpts.Port = port
+			opts.VllmServerOptions = &backendOpts
+		}
+	}
+	return &opts
+}
+
+// verifyListen waits until the process group of cmd listens on the port of the instance,
+// then records whether it listens anywhere but the bound address. It gives up when the
+// process exits, signalled by done.
+func (i *Process) verifyListen(cmd *exec.Cmd, bindAddress string, port int, done <-chan struct{}) {
+	expected := net.JoinHostPort(bindAddress, strconv.Itoa(port))
+	check := &ListenCheck{Expected: expected}
+
+	ticker := time.NewTicker(listenCheckInterval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(listenCheckTimeout)
+
+	for {
+		listening, err := listeningAddresses(cmd.Process.Pid)
+		if err != nil {
+			check.Message = err.Error()
+			break
+		}
+		if slices.ContainsFunc(listening, func(addr string) bool {
+			_, p, _ := net.SplitHostPort(addr)
+			return p == strconv.Itoa(port)
+		}) {
+			check.Listening = listening
+			for _, addr := range listening {
+				host, _, _ := net.SplitHostPort(addr)
+				if !net.ParseIP(host).Equal(net.ParseIP(bindAddress)) {
+					check.Degraded = true
+					check.Message = fmt.Sprintf("backend listens on %s, outside of %s", addr, bindAddress)
+					break
+				}
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			check.Listening = listening
+			check.Message = fmt.Sprintf("backend did not listen on port %d within %s", port, listenCheckTimeout)
+			break
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	select {
+	case <-done:
+		// The process exited while checking, the result belongs to a finished run
+		return
+	default:
+	}
+	check.CheckedAt = i.timeProvider.Now()
+	i.ListenCheck = check
+	if check.Degraded {
+		log.Printf("Warning: instance %s is degraded: %s", i.Name, check.Message)
+	}
+}
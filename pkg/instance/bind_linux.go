@@ -0,0 +1,175 @@
+//go:build linux
+
+package instance
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the socket state of a listening socket in /proc/net/tcp
+const tcpListen = "0A"
+
+// listeningAddresses returns the addresses the processes in the process group pgid listen
+// on, found by matching the socket inodes of their file descriptors against /proc/net/tcp
+func listeningAddresses(pgid int) ([]string, error) {
+	inodes, err := processGroupSockets(pgid)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		listening, err := listeningSockets(table)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for inode, addr := range listening {
+			if inodes[inode] {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// processGroupSockets returns the inodes of the sockets held by the processes in a group
+func processGroupSockets(pgid int) (map[string]bool, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	inodes := make(map[string]bool)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if group, err := processGroup(pid); err != nil || group != pgid {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil {
+				continue
+			}
+			if inode, ok := strings.CutPrefix(target, "socket:["); ok {
+				inodes[strings.TrimSuffix(inode, "]")] = true
+			}
+		}
+	}
+	return inodes, nil
+}
+
+// processGroup reads the process group of pid from /proc/<pid>/stat
+func processGroup(pid int) (int, error) {
+	_, group, err := processStat(pid)
+	return group, err
+}
+
+// processStat reads the state and the process group of pid from /proc/<pid>/stat
+func processStat(pid int) (string, int, error) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return "", 0, err
+	}
+	// The command name may contain spaces, the fields after it are state, ppid and pgrp
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return "", 0, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 3 {
+		return "", 0, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	group, err := strconv.Atoi(fields[2])
+	return fields[0], group, err
+}
+
+// processGroupRunning reports whether a process of the group pgid runs. Zombies are left
+// out: killed processes reparented to one that does not reap them, such as llamactl running
+// as the init process of a container, hold no port.
+func processGroupRunning(pgid int) (bool, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return false, fmt.Errorf("failed to list processes: %w", err)
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		if state, group, err := processStat(pid); err == nil && group == pgid && state != "Z" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// listeningSockets maps the inodes of the listening sockets in a /proc/net/tcp table to
+// their local addresses
+func listeningSockets(table string) (map[string]string, error) {
+	f, err := os.Open(table)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sockets := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListen {
+			continue
+		}
+		addr, err := parseProcNetAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		sockets[fields[9]] = addr
+	}
+	return sockets, scanner.Err()
+}
+
+// parseProcNetAddr decodes an address like "0100007F:1F90", whose IP is stored as
+// native-endian 32-bit words
+func parseProcNetAddr(s string) (string, error) {
+	hexIP, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", fmt.Errorf("malformed address %q", s)
+	}
+	raw, err := hex.DecodeString(hexIP)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return "", fmt.Errorf("malformed address %q", s)
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return "", fmt.Errorf("malformed address %q", s)
+	}
+
+	ip := make(net.IP, len(raw))
+	for word := 0; word < len(raw); word += 4 {
+		// Little-endian words, as on every architecture Linux runs llama.cpp on
+		ip[word], ip[word+1], ip[word+2], ip[word+3] = raw[word+3], raw[word+2], raw[word+1], raw[word]
+	}
+	return net.JoinHostPort(ip.String(), strconv.FormatUint(port, 10)), nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package instance
+
+import "errors"
+
+func listeningAddresses(pgid int) ([]string, error) {
+	return nil, errListenCheckUnsupported
+}
+
+func processGroupRunning(pgid int) (bool, error) {
+	return false, errors.ErrUnsupported
+}
@@ -0,0 +1,157 @@
+package instance_test
+
+import (
+	"fmt"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBindHelperProcess is run as the backend of the bind tests: it listens on the
+// addresses in LLAMACTL_TEST_LISTEN until it is stopped
+func TestBindHelperProcess(t *testing.T) {
+	addrs := os.Getenv("LLAMACTL_TEST_LISTEN")
+	if addrs == "" {
+		t.Skip("only run as the backend of the bind tests")
+	}
+	for _, addr := range strings.Split(addrs, ",") {
+		if _, err := net.Listen("tcp", addr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+	os.Exit(0)
+}
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func newBindInstance(t *testing.T, bindInterface, host string, port int, listen ...string) *instance.Process {
+	t.Helper()
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{
+			Command:     os.Args[0],
+			Args:        []string{"-test.run=^TestBindHelperProcess$", "--"},
+			Environment: map[string]string{"LLAMACTL_TEST_LISTEN": strings.Join(listen, ",")},
+		},
+	}
+	options := &instance.CreateInstanceOptions{
+		BackendType:   backends.BackendTypeLlamaCpp,
+		BindInterface: bindInterface,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  host,
+			Port:  port,
+		},
+	}
+	return instance.NewInstance("bound", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+}
+
+func waitForListenCheck(t *testing.T, inst *instance.Process) *instance.ListenCheck {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if check := inst.GetListenCheck(); check != nil {
+			return check
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the listen check")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestBindInterface_VerifiesListeners(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("listen verification requires /proc")
+	}
+
+	tests := []struct {
+		name     string
+		extra    string
+		degraded bool
+	}{
+		{name: "only the bound address"},
+		{name: "also listening on all interfaces", extra: "0.0.0.0:0", degraded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := freePort(t)
+			listen := []string{fmt.Sprintf("127.0.0.1:%d", port)}
+			if tt.extra != "" {
+				listen = append(listen, tt.extra)
+			}
+			inst := newBindInstance(t, "127.0.0.1", "", port, listen...)
+
+			if err := inst.Start(); err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			defer inst.Stop()
+
+			if host := inst.GetHost(); host != "127.0.0.1" {
+				t.Errorf("Expected the backend host to be the bound address, got %q", host)
+			}
+			preview, err := inst.GetCommandPreview()
+			if err != nil {
+				t.Fatalf("GetCommandPreview failed: %v", err)
+			}
+			if !strings.Contains(strings.Join(preview.Args, " "), "--host 127.0.0.1") {
+				t.Errorf("Expected --host 127.0.0.1 in the arguments, got %v", preview.Args)
+			}
+
+			check := waitForListenCheck(t, inst)
+			if check.Degraded != tt.degraded {
+				t.Errorf("Expected degraded=%v, got %+v", tt.degraded, check)
+			}
+			if check.Expected != fmt.Sprintf("127.0.0.1:%d", port) || len(check.Listening) != len(listen) {
+				t.Errorf("Unexpected listen check %+v", check)
+			}
+		})
+	}
+}
+
+func TestBindInterface_RejectedAtStart(t *testing.T) {
+	tests := []struct {
+		name          string
+		bindInterface string
+		host          string
+		expected      string
+	}{
+		{name: "unknown interface", bindInterface: "no-such-iface0", expected: "not found"},
+		{name: "foreign address", bindInterface: "192.0.2.123", expected: "does not belong"},
+		{name: "host outside the interface", bindInterface: "127.0.0.1", host: "192.0.2.123", expected: "not an address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := newBindInstance(t, tt.bindInterface, tt.host, 8080)
+			err := inst.Start()
+			if err == nil {
+				inst.Stop()
+				t.Fatal("Expected Start to fail")
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
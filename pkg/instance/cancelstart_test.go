@@ -0,0 +1,61 @@
+package instance_test
+
+import (
+	"errors"
+	"llamactl/pkg/instance"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCancelStart(t *testing.T) {
+	// A backend loading its model that ignores SIGTERM meanwhile
+	recorder := newTransitionRecorder()
+	inst := newShellInstance(t, "trap '' TERM; exec sleep 30", true, recorder.record)
+	t.Cleanup(func() { inst.Stop() })
+
+	if err := inst.CancelStart(); !errors.Is(err, instance.ErrNotStarting) {
+		t.Errorf("Expected a stopped instance not to be cancelled, got %v", err)
+	}
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waited := make(chan error, 1)
+	go func() { waited <- inst.WaitForHealthy(time.Minute) }()
+	time.Sleep(200 * time.Millisecond) // Let the wait begin
+
+	began := time.Now()
+	if err := inst.CancelStart(); err != nil {
+		t.Fatalf("CancelStart failed: %v", err)
+	}
+	if elapsed := time.Since(began); elapsed > 5*time.Second {
+		t.Errorf("Expected the backend to be killed right away, it took %s", elapsed)
+	}
+
+	select {
+	case err := <-waited:
+		if err == nil || !strings.Contains(err.Error(), "stopped while starting") {
+			t.Errorf("Expected the readiness wait to be aborted, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the readiness wait to be aborted")
+	}
+
+	// Cancelled, not crashed: stopped without an automatic restart
+	time.Sleep(100 * time.Millisecond)
+	if status := inst.GetStatus(); status != instance.Stopped {
+		t.Errorf("Expected the instance to be stopped, it is %s", status)
+	}
+	for _, tr := range recorder.snapshot() {
+		if tr.newStatus == instance.Restarting {
+			t.Errorf("Expected no automatic restart after a cancelled start, got %+v", tr)
+		}
+	}
+	if reason := inst.GetStoppedReason(); reason == nil || reason.Code != instance.ReasonUserStop || reason.Message != "start cancelled" {
+		t.Errorf("Expected the stop to record the cancellation, got %+v", reason)
+	}
+	if lastExit := inst.GetLastExit(); lastExit != nil {
+		t.Errorf("Expected a cancelled start not to be recorded as a crash, got %+v", lastExit)
+	}
+}
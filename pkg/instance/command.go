@@ -0,0 +1,63 @@
+package instance
+
+import "fmt"
+
+// CommandPreview is the command line an instance is started with
+type CommandPreview struct {
+	// Launch wrapper the backend runs under, empty if none
+	Wrapper []string `json:"wrapper,omitempty"`
+	// Backend command and arguments, before wrapping
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Full argument vector that is executed, wrapper included
+	Argv []string `json:"argv"`
+}
+
+// WrapCommand prepends the launch wrapper, if any, to the backend command line
+func (c *CreateInstanceOptions) WrapCommand(command string, args []string) (string, []string) {
+	if len(c.LaunchWrapper) == 0 {
+		return command, args
+	}
+
+	wrapped := make([]string, 0, len(c.LaunchWrapper)+len(args))
+	wrapped = append(wrapped, c.LaunchWrapper[1:]...)
+	wrapped = append(wrapped, command)
+	wrapped = append(wrapped, args...)
+	return c.LaunchWrapper[0], wrapped
+}
+
+// GetCommandPreview returns the command line the instance is started with, without starting it
+func (i *Process) GetCommandPreview() (*CommandPreview, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if i.options == nil {
+		return nil, fmt.Errorf("instance %s has no options set", i.Name)
+	}
+	if !i.options.IsManaged() {
+		return nil, fmt.Errorf("no command for instance %s: %w", i.Name, ErrUnmanaged)
+	}
+
+	backendConfig, err := i.getBackendConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := i.commandOptions()
+	if i.backendKey != "" {
+		opts = opts.withAPIKey(managedKeyPlaceholder)
+	}
+	command := opts.GetCommand(backendConfig)
+	args := opts.BuildCommandArgs(backendConfig)
+	if args == nil {
+		args = []string{}
+	}
+	wrappedCommand, wrappedArgs := opts.WrapCommand(command, args)
+
+	return &CommandPreview{
+		Wrapper: i.options.LaunchWrapper,
+		Command: command,
+		Args:    args,
+		Argv:    append([]string{wrappedCommand}, wrappedArgs...),
+	}, nil
+}
@@ -0,0 +1,132 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/backends/vllm"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"slices"
+	"testing"
+)
+
+func TestWrapCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		wrapper     []string
+		wantCommand string
+		wantArgs    []string
+	}{
+		{"no wrapper", nil, "llama-server", []string{"--port", "8080"}},
+		{"wrapper without args", []string{"nice"}, "nice", []string{"llama-server", "--port", "8080"}},
+		{"wrapper with args", []string{"numactl", "--interleave=all"}, "numactl", []string{"--interleave=all", "llama-server", "--port", "8080"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{LaunchWrapper: tt.wrapper}
+			command, args := options.WrapCommand("llama-server", []string{"--port", "8080"})
+			if command != tt.wantCommand {
+				t.Errorf("Expected command %q, got %q", tt.wantCommand, command)
+			}
+			if !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("Expected args %v, got %v", tt.wantArgs, args)
+			}
+		})
+	}
+}
+
+func TestGetCommandPreview(t *testing.T) {
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "llama-server"},
+	}
+	options := &instance.CreateInstanceOptions{
+		BackendType:   backends.BackendTypeLlamaCpp,
+		LaunchWrapper: []string{"numactl", "--interleave=all"},
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/models/model.gguf",
+			Port:  8080,
+		},
+	}
+	inst := instance.NewInstance("test-instance", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+
+	preview, err := inst.GetCommandPreview()
+	if err != nil {
+		t.Fatalf("GetCommandPreview failed: %v", err)
+	}
+
+	if preview.Command != "llama-server" {
+		t.Errorf("Expected command llama-server, got %q", preview.Command)
+	}
+	if !slices.Equal(preview.Wrapper, options.LaunchWrapper) {
+		t.Errorf("Expected wrapper %v, got %v", options.LaunchWrapper, preview.Wrapper)
+	}
+	wantPrefix := []string{"numactl", "--interleave=all", "llama-server"}
+	if len(preview.Argv) < len(wantPrefix) || !slices.Equal(preview.Argv[:len(wantPrefix)], wantPrefix) {
+		t.Errorf("Expected argv to start with %v, got %v", wantPrefix, preview.Argv)
+	}
+	if !slices.Equal(preview.Argv[len(wantPrefix):], preview.Args) {
+		t.Errorf("Expected argv to end with the backend args %v, got %v", preview.Args, preview.Argv)
+	}
+}
+
+func TestGetCommandPreview_LlamaExecutable(t *testing.T) {
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "llama-server"},
+		VLLM:     config.BackendSettings{Command: "vllm"},
+	}
+	options := func(executable string) *instance.CreateInstanceOptions {
+		return &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			LlamaExecutable:    executable,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/models/model.gguf", Port: 8080},
+		}
+	}
+	globalSettings := &config.InstancesConfig{LogsDir: t.TempDir(), LlamaExecutable: "/opt/llama.cpp-cuda/bin/llama-server"}
+
+	tests := []struct {
+		name       string
+		executable string
+		expected   string
+	}{
+		{"global default", "", "/opt/llama.cpp-cuda/bin/llama-server"},
+		{"instance override", "/opt/llama.cpp-vulkan/bin/llama-server", "/opt/llama.cpp-vulkan/bin/llama-server"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options(tt.executable), nil)
+			preview, err := inst.GetCommandPreview()
+			if err != nil {
+				t.Fatalf("GetCommandPreview failed: %v", err)
+			}
+			if preview.Command != tt.expected || preview.Argv[0] != tt.expected {
+				t.Errorf("Expected command %s, got %q (argv %v)", tt.expected, preview.Command, preview.Argv)
+			}
+
+			data, err := json.Marshal(inst)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var status struct {
+				Executable string `json:"executable"`
+			}
+			if err := json.Unmarshal(data, &status); err != nil {
+				t.Fatal(err)
+			}
+			if status.Executable != tt.expected {
+				t.Errorf("Expected the status to report executable %s, got %q", tt.expected, status.Executable)
+			}
+		})
+	}
+
+	// Other backends keep their own command
+	inst := instance.NewInstance("test-instance", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir(), LlamaExecutable: "/opt/llama-server"}, options(""), nil)
+	inst.SetOptions(&instance.CreateInstanceOptions{
+		BackendType:       backends.BackendTypeVllm,
+		VllmServerOptions: &vllm.VllmServerOptions{Model: "model"},
+	})
+	if preview, err := inst.GetCommandPreview(); err != nil || preview.Command != "vllm" {
+		t.Errorf("Expected llama_executable to leave the vLLM command alone, got %+v (%v)", preview, err)
+	}
+}
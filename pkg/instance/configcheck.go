@@ -0,0 +1,188 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"llamactl/pkg/backends"
+)
+
+// Outcomes of comparing the configuration a backend reports with the options it was started with
+const (
+	ConfigVerified    = "verified"    // Every setting the backend reports matches the options
+	ConfigMismatch    = "mismatch"    // The backend runs with settings other than requested
+	ConfigUnavailable = "unavailable" // The backend does not report enough of its configuration
+)
+
+// configVerificationTimeout bounds the queries of the configuration of a backend
+const configVerificationTimeout = 10 * time.Second
+
+// ConfigVerification compares the configuration a backend reports once ready with the options
+// it was started with, since backends may silently ignore flags they do not know
+type ConfigVerification struct {
+	Status     string            `json:"status"`
+	Checked    []string          `json:"checked,omitempty"` // Settings compared
+	Mismatches []SettingMismatch `json:"mismatches,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Time       time.Time         `json:"time"`
+}
+
+// SettingMismatch is a setting the backend runs with other than requested
+type SettingMismatch struct {
+	Setting   string `json:"setting"` // Backend option, e.g. ctx_size
+	Requested string `json:"requested"`
+	Effective string `json:"effective"` // As reported by the backend
+}
+
+// llamaProps is the part of the /props response of llama-server that is verified
+type llamaProps struct {
+	DefaultGenerationSettings struct {
+		NCtx int `json:"n_ctx"` // Per slot
+	} `json:"default_generation_settings"`
+	TotalSlots   int    `json:"total_slots"`
+	ModelPath    string `json:"model_path"`
+	ChatTemplate string `json:"chat_template"`
+	NGPULayers   *int   `json:"n_gpu_layers"` // Only reported by some builds
+}
+
+// llamaModels is the part of the /v1/models response of llama-server that is verified
+type llamaModels struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// GetConfigVerification returns how the configuration the backend reported compared with
+// its options, nil until a backend of the instance was verified
+func (i *Process) GetConfigVerification() *ConfigVerification {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.ConfigVerification
+}
+
+// TakeConfigMismatch returns the verification that found mismatches since the last call, if any
+func (i *Process) TakeConfigMismatch() *ConfigVerification {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	verification := i.unreportedConfigMismatch
+	i.unreportedConfigMismatch = nil
+	return verification
+}
+
+// verifyConfig compares the configuration the backend of the process whose monitor closes
+// exited reports, once it passed a readiness check, with the options it was started with
+func (i *Process) verifyConfig(exited <-chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), configVerificationTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-exited:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	i.mu.RLock()
+	opts := i.commandOptions()
+	i.mu.RUnlock()
+
+	var verification *ConfigVerification
+	if opts.BackendType == backends.BackendTypeLlamaCpp && opts.LlamaServerOptions != nil {
+		verification = i.verifyLlamaConfig(ctx, opts)
+	} else {
+		verification = &ConfigVerification{
+			Status:  ConfigUnavailable,
+			Message: fmt.Sprintf("verification unavailable: the %s backend does not report its configuration", opts.BackendType),
+		}
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.monitorDone != exited {
+		return // The process exited meanwhile
+	}
+	verification.Time = i.timeProvider.Now()
+	i.ConfigVerification = verification
+	if verification.Status == ConfigMismatch {
+		log.Printf("Warning: instance %s %s", i.Name, verification.Message)
+		i.unreportedConfigMismatch = verification
+	}
+}
+
+// verifyLlamaConfig compares the context size, GPU layers, model, chat template and alias
+// llama-server reports with its options
+func (i *Process) verifyLlamaConfig(ctx context.Context, opts *CreateInstanceOptions) *ConfigVerification {
+	var props llamaProps
+	if err := i.backendRequest(ctx, "GET", "/props", nil, &props); err != nil {
+		return &ConfigVerification{Status: ConfigUnavailable, Message: "verification unavailable: /props: " + err.Error()}
+	}
+	llama := opts.LlamaServerOptions
+	v := &ConfigVerification{}
+
+	// The context is split between the slots, and may be padded
+	if llama.CtxSize > 0 && props.DefaultGenerationSettings.NCtx > 0 {
+		perSlot := props.DefaultGenerationSettings.NCtx
+		total := perSlot * max(props.TotalSlots, 1)
+		padded := (llama.CtxSize + 255) / 256 * 256
+		matches := func(n int) bool { return n >= llama.CtxSize && n <= padded }
+		v.check("ctx_size", matches(perSlot) || matches(total), llama.CtxSize, total)
+	}
+
+	// Layers beyond those of the model are not offloaded, so fewer layers than requested are
+	// expected, but none or more are flagged
+	if llama.GPULayers > 0 && props.NGPULayers != nil {
+		v.check("gpu_layers", *props.NGPULayers > 0 && *props.NGPULayers <= llama.GPULayers, llama.GPULayers, *props.NGPULayers)
+	}
+
+	if llama.Model != "" && props.ModelPath != "" {
+		v.check("model", filepath.Clean(llama.Model) == filepath.Clean(props.ModelPath), llama.Model, props.ModelPath)
+	}
+
+	// A built-in template is only reported expanded, so only a template file is compared
+	if llama.ChatTemplateFile != "" && props.ChatTemplate != "" {
+		if template, err := os.ReadFile(llama.ChatTemplateFile); err == nil {
+			same := strings.TrimSpace(string(template)) == strings.TrimSpace(props.ChatTemplate)
+			v.check("chat_template_file", same, llama.ChatTemplateFile, "a different template")
+		}
+	}
+
+	if llama.Alias != "" {
+		var models llamaModels
+		if err := i.backendRequest(ctx, "GET", "/v1/models", nil, &models); err == nil && len(models.Data) > 0 {
+			v.check("alias", models.Data[0].ID == llama.Alias, llama.Alias, models.Data[0].ID)
+		}
+	}
+
+	switch {
+	case len(v.Mismatches) > 0:
+		v.Status = ConfigMismatch
+		descriptions := make([]string, len(v.Mismatches))
+		for n, mismatch := range v.Mismatches {
+			descriptions[n] = fmt.Sprintf("%s is %s instead of %s", mismatch.Setting, mismatch.Effective, mismatch.Requested)
+		}
+		v.Message = "runs with settings other than requested: " + strings.Join(descriptions, ", ")
+	case len(v.Checked) == 0:
+		v.Status = ConfigUnavailable
+		v.Message = "verification unavailable: the backend reports none of the requested settings"
+	default:
+		v.Status = ConfigVerified
+	}
+	return v
+}
+
+// check records the comparison of a setting
+func (v *ConfigVerification) check(setting string, matches bool, requested, effective any) {
+	v.Checked = append(v.Checked, setting)
+	if !matches {
+		v.Mismatches = append(v.Mismatches, SettingMismatch{
+			Setting:   setting,
+			Requested: fmt.Sprint(requested),
+			Effective: fmt.Sprint(effective),
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigVerification(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// startVerified starts an instance whose backend answers /props with props, or 404 when nil
+	startVerified := func(t *testing.T, llama *llamacpp.LlamaServerOptions, props map[string]any, modelID string) *instance.Process {
+		t.Helper()
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/health":
+				w.Write([]byte("ok"))
+			case "/props":
+				if props == nil {
+					http.NotFound(w, r)
+					return
+				}
+				json.NewEncoder(w).Encode(props)
+			case "/v1/models":
+				json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"id": modelID}}})
+			}
+		}
+		options := &instance.CreateInstanceOptions{
+			AutoRestart:        testutil.BoolPtr(false),
+			LlamaServerOptions: llama,
+		}
+		inst := newBackendInstance(t, handler, options, &config.InstancesConfig{}, nil)
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		return inst
+	}
+	waitForVerification := func(t *testing.T, inst *instance.Process) *instance.ConfigVerification {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if verification := inst.GetConfigVerification(); verification != nil {
+				return verification
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for the configuration to be verified")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	t.Run("matching settings", func(t *testing.T) {
+		template := filepath.Join(t.TempDir(), "template.jinja")
+		os.WriteFile(template, []byte("{{ messages }}\n"), 0644)
+		inst := startVerified(t, &llamacpp.LlamaServerOptions{
+			Model:            "/models/./model.gguf",
+			CtxSize:          8000,
+			Parallel:         2,
+			GPULayers:        99,
+			Alias:            "chat",
+			ChatTemplateFile: template,
+		}, map[string]any{
+			"default_generation_settings": map[string]any{"n_ctx": 4096}, // Padded to 8192 and split
+			"total_slots":                 2,
+			"model_path":                  "/models/model.gguf",
+			"chat_template":               "{{ messages }}",
+			"n_gpu_layers":                33,
+		}, "chat")
+
+		verification := waitForVerification(t, inst)
+		if verification.Status != instance.ConfigVerified || len(verification.Mismatches) != 0 {
+			t.Errorf("Expected the settings to be verified, got %+v", verification)
+		}
+		expected := []string{"ctx_size", "gpu_layers", "model", "chat_template_file", "alias"}
+		if !slices.Equal(verification.Checked, expected) {
+			t.Errorf("Expected %v to be checked, got %v", expected, verification.Checked)
+		}
+		if inst.TakeConfigMismatch() != nil {
+			t.Error("Expected no mismatch to report")
+		}
+	})
+
+	t.Run("ignored flags are reported", func(t *testing.T) {
+		inst := startVerified(t, &llamacpp.LlamaServerOptions{
+			Model:   "/models/model.gguf",
+			CtxSize: 32768,
+			Alias:   "chat",
+		}, map[string]any{
+			"default_generation_settings": map[string]any{"n_ctx": 4096},
+			"total_slots":                 1,
+			"model_path":                  "/models/model.gguf",
+		}, "/models/model.gguf")
+
+		verification := waitForVerification(t, inst)
+		if verification.Status != instance.ConfigMismatch {
+			t.Fatalf("Expected a mismatch, got %+v", verification)
+		}
+		expected := []instance.SettingMismatch{
+			{Setting: "ctx_size", Requested: "32768", Effective: "4096"},
+			{Setting: "alias", Requested: "chat", Effective: "/models/model.gguf"},
+		}
+		if !slices.Equal(verification.Mismatches, expected) {
+			t.Errorf("Expected mismatches %+v, got %+v", expected, verification.Mismatches)
+		}
+		if !strings.Contains(verification.Message, "ctx_size is 4096 instead of 32768") {
+			t.Errorf("Expected the message to describe the mismatch, got %q", verification.Message)
+		}
+
+		// Reported once
+		if inst.TakeConfigMismatch() != verification || inst.TakeConfigMismatch() != nil {
+			t.Error("Expected the mismatch to be taken once")
+		}
+		var status struct {
+			Verification *instance.ConfigVerification `json:"config_verification"`
+		}
+		data, _ := json.Marshal(inst)
+		json.Unmarshal(data, &status)
+		if status.Verification == nil || status.Verification.Status != instance.ConfigMismatch {
+			t.Errorf("Expected the instance to report the mismatch, got %s", data)
+		}
+	})
+
+	t.Run("backends without props", func(t *testing.T) {
+		inst := startVerified(t, &llamacpp.LlamaServerOptions{Model: "/models/model.gguf"}, nil, "")
+
+		verification := waitForVerification(t, inst)
+		if verification.Status != instance.ConfigUnavailable || !strings.HasPrefix(verification.Message, "verification unavailable") {
+			t.Errorf("Expected the verification to be unavailable, got %+v", verification)
+		}
+	})
+}
@@ -0,0 +1,270 @@
+package instance
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policies for a proxied request that needs a new backend connection at the cap
+const (
+	ConnectionPolicyBlock = "block" // Wait until a connection closes, or the request is cancelled
+	ConnectionPolicyFail  = "fail"  // Fail the request right away
+)
+
+// defaultBackendIdleTimeout is used when backend_idle_timeout is not set in the configuration
+const defaultBackendIdleTimeout = 90 * time.Second
+
+// ErrBackendConnectionLimit is returned for a proxied request that needs a new backend
+// connection while max_backend_connections are open and the policy is to fail fast
+var ErrBackendConnectionLimit = errors.New("backend connection limit reached")
+
+// ConnectionStats counts the connections the proxy holds to the backend
+type ConnectionStats struct {
+	Open     int   `json:"open"`
+	Idle     int   `json:"idle"`
+	Max      int   `json:"max,omitempty"` // 0 = unlimited
+	Rejected int64 `json:"rejected"`      // Requests failed at the cap with the fail policy
+	Reaped   int64 `json:"reaped"`        // Idle connections closed beyond the floor
+}
+
+// connectionPool caps and tracks the connections of a proxy transport. The cap applies to
+// connections, not requests: keep-alive connections are reused by as many requests as
+// they can serve, and a request only needs a new connection when none is idle.
+type connectionPool struct {
+	max         int
+	failFast    bool
+	minIdle     int
+	idleTimeout time.Duration
+	dialer      *net.Dialer
+	slots       chan struct{} // Semaphore of open connections, nil when uncapped
+	transport   *http.Transport
+
+	mu    sync.Mutex
+	conns map[*trackedConn]struct{}
+
+	rejected atomic.Int64
+	reaped   atomic.Int64
+	retired  atomic.Bool // Replaced by the pool of a new proxy, connections close once idle
+}
+
+// trackedConn is a backend connection, idle since idleSince or in use when it is zero
+type trackedConn struct {
+	net.Conn
+	pool      *connectionPool
+	idleSince time.Time // guarded by pool.mu
+	closeOnce sync.Once
+}
+
+// newConnectionPool returns the pool of the proxy, configured from the options, with a
+// clone of base dialing through it. Idle connections are only closed by the pool, so the
+// floor of min_idle_backend_connections is kept open.
+func newConnectionPool(base http.RoundTripper, options *CreateInstanceOptions, idleTimeout time.Duration) *connectionPool {
+	pool := &connectionPool{
+		idleTimeout: idleTimeout,
+		dialer:      &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		conns:       make(map[*trackedConn]struct{}),
+	}
+	if pool.idleTimeout <= 0 {
+		pool.idleTimeout = defaultBackendIdleTimeout
+	}
+	if options != nil {
+		if options.MaxBackendConnections != nil && *options.MaxBackendConnections > 0 {
+			pool.max = *options.MaxBackendConnections
+			pool.slots = make(chan struct{}, pool.max)
+		}
+		pool.failFast = options.BackendConnectionPolicy == ConnectionPolicyFail
+		if options.MinIdleBackendConnections != nil {
+			pool.minIdle = *options.MinIdleBackendConnections
+		}
+	}
+
+	baseTransport, ok := base.(*http.Transport)
+	if !ok {
+		baseTransport = http.DefaultTransport.(*http.Transport)
+	}
+	transport := baseTransport.Clone()
+	transport.DialContext = pool.dialContext
+	transport.IdleConnTimeout = 0
+	// The default of 2 idle connections per host would close the connections of a burst
+	// as soon as it is over, the pool reaps them instead
+	transport.MaxIdleConnsPerHost = max(pool.max, transport.MaxIdleConns)
+	if !pool.failFast {
+		// Requests beyond the cap wait in the transport's queue for a connection. Dials are
+		// detached from their requests, so they must not be the ones waiting for a slot.
+		transport.MaxConnsPerHost = pool.max
+	}
+	pool.transport = transport
+	return pool
+}
+
+func (p *connectionPool) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if p.slots != nil {
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			if p.failFast {
+				p.rejected.Add(1)
+				return nil, fmt.Errorf("%w (%d open)", ErrBackendConnectionLimit, p.max)
+			}
+			// The transport already holds dials back at the cap, a dial only waits for the
+			// slot of a connection that is being closed
+			select {
+			case p.slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	conn, err := p.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+
+	// A new connection counts as idle until a request picks it up, which it does right
+	// away unless the request was cancelled while dialing
+	tracked := &trackedConn{Conn: conn, pool: p, idleSince: time.Now()}
+	p.mu.Lock()
+	p.conns[tracked] = struct{}{}
+	p.mu.Unlock()
+	return tracked, nil
+}
+
+func (p *connectionPool) release() {
+	if p.slots != nil {
+		<-p.slots
+	}
+}
+
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.pool.mu.Lock()
+		delete(c.pool.conns, c)
+		c.pool.mu.Unlock()
+		c.pool.release()
+	})
+	return err
+}
+
+// trace returns req with a client trace marking the connection it uses as in use, and
+// as idle once the transport puts it back in its idle pool
+func (p *connectionPool) trace(req *http.Request) *http.Request {
+	// The transport calls the hooks from different goroutines
+	var conn atomic.Pointer[trackedConn]
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			netConn := info.Conn
+			if tlsConn, ok := netConn.(*tls.Conn); ok {
+				netConn = tlsConn.NetConn()
+			}
+			if tracked, ok := netConn.(*trackedConn); ok {
+				conn.Store(tracked)
+				p.setIdle(tracked, time.Time{})
+			}
+		},
+		PutIdleConn: func(err error) {
+			tracked := conn.Load()
+			switch {
+			case tracked == nil || err != nil:
+			case p.retired.Load():
+				tracked.Close()
+			default:
+				p.setIdle(tracked, time.Now())
+			}
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// RoundTrip sends the request over a connection of the pool
+func (p *connectionPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	return p.transport.RoundTrip(p.trace(req))
+}
+
+func (p *connectionPool) setIdle(conn *trackedConn, since time.Time) {
+	p.mu.Lock()
+	conn.idleSince = since
+	p.mu.Unlock()
+}
+
+// reap closes the connections idle for longer than the idle timeout, oldest first, while
+// more than minIdle connections are idle
+func (p *connectionPool) reap(now time.Time) int {
+	p.mu.Lock()
+	var idle []*trackedConn
+	for conn := range p.conns {
+		if !conn.idleSince.IsZero() {
+			idle = append(idle, conn)
+		}
+	}
+	sort.Slice(idle, func(a, b int) bool { return idle[a].idleSince.Before(idle[b].idleSince) })
+
+	var expired []*trackedConn
+	for _, conn := range idle {
+		if len(idle)-len(expired) <= p.minIdle || now.Sub(conn.idleSince) < p.idleTimeout {
+			break
+		}
+		expired = append(expired, conn)
+	}
+	p.mu.Unlock()
+
+	for _, conn := range expired {
+		conn.Close()
+	}
+	p.reaped.Add(int64(len(expired)))
+	return len(expired)
+}
+
+// retire closes the idle connections of a pool that is no longer used by new requests,
+// and the others once their requests complete
+func (p *connectionPool) retire() {
+	p.retired.Store(true)
+	p.transport.CloseIdleConnections()
+}
+
+// inUse returns the number of connections serving a request
+func (p *connectionPool) inUse() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	busy := 0
+	for conn := range p.conns {
+		if conn.idleSince.IsZero() {
+			busy++
+		}
+	}
+	return busy
+}
+
+func (p *connectionPool) stats() *ConnectionStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := &ConnectionStats{Open: len(p.conns), Max: p.max, Rejected: p.rejected.Load(), Reaped: p.reaped.Load()}
+	for conn := range p.conns {
+		if !conn.idleSince.IsZero() {
+			stats.Idle++
+		}
+	}
+	return stats
+}
+
+// ReapIdleConnections closes the backend connections of the proxy that have been idle
+// for longer than backend_idle_timeout, keeping min_idle_backend_connections open. It
+// returns the number of connections closed.
+func (i *Process) ReapIdleConnections() int {
+	pool := i.connections.Load()
+	if pool == nil {
+		return 0
+	}
+	return pool.reap(time.Now())
+}
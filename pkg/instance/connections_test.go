@@ -0,0 +1,170 @@
+package instance_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// connectionCounter tracks the connections a test backend has open, and the most it had
+// open at once
+type connectionCounter struct {
+	open atomic.Int64
+	peak atomic.Int64
+}
+
+func (c *connectionCounter) track(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		open := c.open.Add(1)
+		for peak := c.peak.Load(); open > peak && !c.peak.CompareAndSwap(peak, open); peak = c.peak.Load() {
+		}
+	case http.StateClosed, http.StateHijacked:
+		c.open.Add(-1)
+	}
+}
+
+// newConnectionTestInstance returns an instance proxying to a slow backend that counts
+// its connections
+func newConnectionTestInstance(t *testing.T, counter *connectionCounter, idleTimeout time.Duration, configure func(*instance.CreateInstanceOptions)) *instance.Process {
+	t.Helper()
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	backend.Config.ConnState = counter.track
+	backend.Start()
+	t.Cleanup(backend.Close)
+
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+	options := &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  backendURL.Hostname(),
+			Port:  port,
+		},
+	}
+	configure(options)
+	backendConfig := &config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "llama-server"}}
+	globalSettings := &config.InstancesConfig{LogsDir: t.TempDir(), BackendIdleTimeout: config.Duration(idleTimeout)}
+
+	return instance.NewInstance("conn-instance", backendConfig, globalSettings, options, func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {})
+}
+
+// flood sends n concurrent requests through the proxy and returns their status codes
+func flood(t *testing.T, inst *instance.Process, n int) map[int]int {
+	t.Helper()
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	codes := make(map[int]int)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recorder := httptest.NewRecorder()
+			proxy.ServeHTTP(recorder, httptest.NewRequest("GET", "/v1/models", nil))
+			mu.Lock()
+			codes[recorder.Code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return codes
+}
+
+func TestBackendConnections_Cap(t *testing.T) {
+	counter := &connectionCounter{}
+	limit := 3
+	inst := newConnectionTestInstance(t, counter, 0, func(options *instance.CreateInstanceOptions) {
+		options.MaxBackendConnections = &limit
+	})
+
+	codes := flood(t, inst, 50)
+	if codes[http.StatusOK] != 50 {
+		t.Errorf("Expected every request to wait for a connection and succeed, got %v", codes)
+	}
+	if peak := counter.peak.Load(); peak > int64(limit) || peak == 0 {
+		t.Errorf("Expected at most %d backend connections, the backend saw %d", limit, peak)
+	}
+
+	stats := inst.GetStats().Connections
+	if stats == nil || stats.Open > limit || stats.Max != limit || stats.Rejected != 0 {
+		t.Errorf("Expected connection stats within the cap, got %+v", stats)
+	}
+}
+
+func TestBackendConnections_FailFast(t *testing.T) {
+	counter := &connectionCounter{}
+	limit := 2
+	inst := newConnectionTestInstance(t, counter, 0, func(options *instance.CreateInstanceOptions) {
+		options.MaxBackendConnections = &limit
+		options.BackendConnectionPolicy = instance.ConnectionPolicyFail
+	})
+
+	codes := flood(t, inst, 30)
+	if codes[http.StatusServiceUnavailable] == 0 || codes[http.StatusOK] == 0 {
+		t.Errorf("Expected requests beyond the cap to be rejected with 503, got %v", codes)
+	}
+	if codes[http.StatusOK]+codes[http.StatusServiceUnavailable] != 30 {
+		t.Errorf("Expected only successes and rejections, got %v", codes)
+	}
+	if peak := counter.peak.Load(); peak > int64(limit) {
+		t.Errorf("Expected at most %d backend connections, the backend saw %d", limit, peak)
+	}
+	if stats := inst.GetStats().Connections; stats == nil || stats.Rejected != int64(codes[http.StatusServiceUnavailable]) {
+		t.Errorf("Expected %d rejections in the stats, got %+v", codes[http.StatusServiceUnavailable], stats)
+	}
+}
+
+func TestBackendConnections_ReapIdle(t *testing.T) {
+	counter := &connectionCounter{}
+	minIdle := 1
+	inst := newConnectionTestInstance(t, counter, time.Second, func(options *instance.CreateInstanceOptions) {
+		options.MinIdleBackendConnections = &minIdle
+	})
+
+	flood(t, inst, 10)
+	stats := inst.GetStats().Connections
+	if stats == nil || stats.Idle < 2 || stats.Idle != stats.Open {
+		t.Fatalf("Expected the connections of the burst to be kept idle, got %+v", stats)
+	}
+
+	if reaped := inst.ReapIdleConnections(); reaped != 0 {
+		t.Errorf("Expected no connection to be reaped before the idle timeout, reaped %d", reaped)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	opened := stats.Open
+	if reaped := inst.ReapIdleConnections(); reaped != opened-minIdle {
+		t.Errorf("Expected %d connections to be reaped, reaped %d", opened-minIdle, reaped)
+	}
+	stats = inst.GetStats().Connections
+	if stats.Open != minIdle || stats.Idle != minIdle || stats.Reaped != int64(opened-minIdle) {
+		t.Errorf("Expected %d idle connection to be kept, got %+v", minIdle, stats)
+	}
+
+	// The connection left is reused
+	if codes := flood(t, inst, 1); codes[http.StatusOK] != 1 {
+		t.Errorf("Expected the request to succeed, got %v", codes)
+	}
+	if stats = inst.GetStats().Connections; stats.Open != minIdle {
+		t.Errorf("Expected the idle connection to be reused, got %+v", stats)
+	}
+}
@@ -0,0 +1,43 @@
+package instance
+
+import "time"
+
+// CrashLoopFailures is the number of rapid failures in a row after which an instance is
+// failed as crash-looping instead of restarted
+const CrashLoopFailures = 3
+
+// minUptime returns how long a process has to run for its crash not to count as a rapid
+// failure, zero when min_uptime_seconds is unset (caller must hold the lock)
+func (i *Process) minUptime() time.Duration {
+	if i.options == nil || i.options.MinUptime == nil || *i.options.MinUptime <= 0 {
+		return 0
+	}
+	return i.options.MinUptime.Duration()
+}
+
+// rapidFailuresAt returns the rapid failures in a row a crash at now makes: one more when
+// the current process ran for less than min_uptime_seconds, none when it ran for longer.
+// Once the exit is counted the process has no start time anymore, and the count is kept.
+// (caller must hold the lock)
+func (i *Process) rapidFailuresAt(now time.Time) int {
+	if i.startedAt.IsZero() {
+		return i.rapidFailures
+	}
+	if now.Sub(i.startedAt) < i.minUptime() {
+		return i.rapidFailures + 1
+	}
+	return 0
+}
+
+// rapidFailureDelay returns the least delay before restarting after the given rapid
+// failures in a row: min_uptime_seconds for each of them, so that a backend dying on start
+// is given more time between attempts (caller must hold the lock)
+func (i *Process) rapidFailureDelay(rapidFailures int) time.Duration {
+	return i.minUptime() * time.Duration(rapidFailures)
+}
+
+// crashLooping reports whether the instance failed too many times in a row right after
+// starting to be restarted again (caller must hold the lock)
+func (i *Process) crashLooping() bool {
+	return i.restartBudget(i.timeProvider.Now()).Reason == RestartCrashLoop
+}
@@ -0,0 +1,119 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCrashLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	newCrashLooping := func(t *testing.T, script string, maxRestarts int, onStatusChange instance.StatusChangeFunc) *instance.Process {
+		backendConfig := &config.BackendConfig{
+			LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", script}},
+		}
+		options := &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			AutoRestart:        testutil.BoolPtr(true),
+			MaxRestarts:        testutil.IntPtr(maxRestarts),
+			RestartDelay:       testutil.DurationPtr(0),
+			MinUptime:          testutil.DurationPtr(time.Second),
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+		}
+		inst := instance.NewInstance("crashing", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, onStatusChange)
+		t.Cleanup(func() { inst.Stop() })
+		return inst
+	}
+	type status struct {
+		RapidFailures int                    `json:"rapid_failures"`
+		Restarts      int                    `json:"restarts"`
+		RestartBudget instance.RestartBudget `json:"restart_budget"`
+	}
+	statusOf := func(inst *instance.Process) status {
+		var s status
+		data, _ := json.Marshal(inst)
+		json.Unmarshal(data, &s)
+		return s
+	}
+
+	t.Run("rapid failures widen the delay and fail the instance", func(t *testing.T) {
+		var mu sync.Mutex
+		var restarting time.Time
+		var gaps []time.Duration
+		var failedReason instance.StatusReason
+		done := make(chan struct{})
+		inst := newCrashLooping(t, "exit 1", 10, func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case newStatus == instance.Restarting:
+				restarting = time.Now()
+			case oldStatus == instance.Restarting:
+				gaps = append(gaps, time.Since(restarting))
+			case newStatus == instance.Failed:
+				failedReason = reason
+				close(done)
+			}
+		})
+
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for the crash loop to be detected")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failedReason.Code != instance.ReasonCrashLoop {
+			t.Errorf("Expected the instance to fail with %s, got %+v", instance.ReasonCrashLoop, failedReason)
+		}
+		// The delay grows by min_uptime_seconds with each rapid failure, despite restart_delay 0
+		expected := []time.Duration{time.Second, 2 * time.Second}
+		if len(gaps) != len(expected) {
+			t.Fatalf("Expected %d restarts, got delays %v", len(expected), gaps)
+		}
+		for idx, gap := range gaps {
+			if gap < expected[idx] {
+				t.Errorf("Expected restart %d to wait at least %v, got %v", idx+1, expected[idx], gap)
+			}
+		}
+
+		s := statusOf(inst)
+		if s.RapidFailures != instance.CrashLoopFailures || s.Restarts != 2 {
+			t.Errorf("Expected %d rapid failures after 2 restarts, got %+v", instance.CrashLoopFailures, s)
+		}
+		if s.RestartBudget.Armed || s.RestartBudget.Reason != instance.RestartCrashLoop {
+			t.Errorf("Expected the restart budget to report the crash loop, got %+v", s.RestartBudget)
+		}
+	})
+
+	t.Run("runs outliving the minimum uptime are ordinary crashes", func(t *testing.T) {
+		recorder := newTransitionRecorder()
+		inst := newCrashLooping(t, "sleep 1.2; exit 1", 1, recorder.record)
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		recorder.waitFor(t, instance.Failed)
+
+		transitions := recorder.snapshot()
+		if last := transitions[len(transitions)-1]; last.reason.Code != instance.ReasonMaxRestartsExceeded {
+			t.Errorf("Expected the instance to fail with %s, got %+v", instance.ReasonMaxRestartsExceeded, last.reason)
+		}
+		if s := statusOf(inst); s.RapidFailures != 0 {
+			t.Errorf("Expected no rapid failures, got %d", s.RapidFailures)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package instance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"llamactl/pkg/config"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrRequestTimeout is the cause of the context of a request that ran longer than the
+// max_request_duration of its instance
+var ErrRequestTimeout = errors.New("request exceeded max_request_duration")
+
+// requestTimedOut reports whether the context of req was cancelled by max_request_duration
+func requestTimedOut(req *http.Request) bool {
+	return req != nil && errors.Is(context.Cause(req.Context()), ErrRequestTimeout)
+}
+
+// withRequestDeadline bounds the context of r by limit, cancelling the backend request once
+// it runs out. The returned function releases the deadline.
+func withRequestDeadline(r *http.Request, limit time.Duration) (*http.Request, context.CancelFunc) {
+	cause := fmt.Errorf("%w of %s", ErrRequestTimeout, config.Duration(limit))
+	ctx, cancel := context.WithTimeoutCause(r.Context(), limit, cause)
+	return r.WithContext(ctx), cancel
+}
+
+// timeoutEvent is the SSE event ending a stream cut short by max_request_duration, in the
+// shape of an OpenAI error so that clients tell it apart from a dropped connection
+func timeoutEvent(cause error) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"error": map[string]any{
+			"message": cause.Error(),
+			"type":    "timeout",
+			"code":    "request_timeout",
+		},
+	})
+	return []byte("data: " + string(data) + "\n\n")
+}
+
+// timeoutEventBody ends an event stream with a timeout event when its request runs out of
+// time, instead of failing the read, which would abort the client connection
+type timeoutEventBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	pending []byte // Rest of the timeout event
+	ended   bool
+}
+
+func (b *timeoutEventBody) Read(p []byte) (int, error) {
+	if b.ended {
+		if len(b.pending) == 0 {
+			return 0, io.EOF
+		}
+		n := copy(p, b.pending)
+		b.pending = b.pending[n:]
+		return n, nil
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		if cause := context.Cause(b.ctx); errors.Is(cause, ErrRequestTimeout) {
+			b.ended = true
+			// The event starts on a line of its own, whatever the backend was writing
+			b.pending = append([]byte("\n\n"), timeoutEvent(cause)...)
+			return n, nil
+		}
+	}
+	return n, err
+}
+
+// endStreamsOnTimeout lets the event stream of a response end with a timeout event
+func endStreamsOnTimeout(resp *http.Response) {
+	if resp.Request == nil || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return
+	}
+	resp.Body = &timeoutEventBody{ReadCloser: resp.Body, ctx: resp.Request.Context()}
+}
@@ -0,0 +1,166 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// newDrainInstance starts an instance given drainTimeout to complete its requests when stopped
+func newDrainInstance(t *testing.T, drainTimeout time.Duration) *instance.Process {
+	t.Helper()
+	readinessTimeout := config.Duration(500 * time.Millisecond)
+	drain := config.Duration(drainTimeout)
+	options := &instance.CreateInstanceOptions{
+		ReadinessTimeout: &readinessTimeout,
+		DrainTimeout:     &drain,
+	}
+	globalSettings := &config.InstancesConfig{
+		ReadinessProbeInterval: config.Duration(20 * time.Millisecond),
+		DefaultStopTimeout:     config.Duration(2 * time.Second),
+	}
+
+	inst := newBackendInstance(t, reloadBackend("backend", http.StatusOK), options, globalSettings, nil)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waitForStatus(t, inst, instance.Running)
+	return inst
+}
+
+// stopAsync stops inst in the background, the returned channel receiving the result
+func stopAsync(inst *instance.Process) <-chan error {
+	stopped := make(chan error, 1)
+	go func() { stopped <- inst.Stop() }()
+	return stopped
+}
+
+func waitForDraining(t *testing.T, inst *instance.Process) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !inst.IsDraining() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the instance to drain")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStop_DrainsRequestsInFlight(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	inst := newDrainInstance(t, 5*time.Second)
+	_, done := inst.TrackRequest(httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil), "", "", true)
+
+	stopped := stopAsync(inst)
+	waitForDraining(t, inst)
+
+	data, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var status struct {
+		Status   string `json:"status"`
+		Draining bool   `json:"draining"`
+		InFlight int    `json:"in_flight"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if status.Status != "running" || !status.Draining || status.InFlight != 1 {
+		t.Errorf("Expected a running instance draining 1 request, got %+v", status)
+	}
+	if health, _ := inst.GetLBHealth(); health.Status != instance.LBDraining {
+		t.Errorf("Expected load balancers to be told the instance is draining, got %s", health.Status)
+	}
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("Expected the stop to wait for the request in flight, got %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	done()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("Stop failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the stop once the request completed")
+	}
+	if inst.GetStatus() != instance.Stopped || inst.IsDraining() {
+		t.Errorf("Expected the instance to be stopped, got %s (draining: %v)", inst.GetStatus(), inst.IsDraining())
+	}
+}
+
+func TestStop_DrainTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	inst := newDrainInstance(t, 300*time.Millisecond)
+	_, done := inst.TrackRequest(httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil), "", "", true)
+	defer done()
+
+	started := time.Now()
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 300*time.Millisecond {
+		t.Errorf("Expected the stop to wait for the drain timeout, it took %s", elapsed)
+	}
+	if inst.GetStatus() != instance.Stopped {
+		t.Errorf("Expected the instance to be stopped, got %s", inst.GetStatus())
+	}
+}
+
+func TestStop_SecondStopCutsDrainShort(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	inst := newDrainInstance(t, 30*time.Second)
+	_, done := inst.TrackRequest(httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil), "", "", true)
+	defer done()
+
+	first := stopAsync(inst)
+	waitForDraining(t, inst)
+
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Second stop failed: %v", err)
+	}
+	if inst.GetStatus() != instance.Stopped {
+		t.Errorf("Expected the instance to be stopped, got %s", inst.GetStatus())
+	}
+	select {
+	case err := <-first:
+		if err != nil {
+			t.Errorf("Expected the drained stop to succeed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the drained stop")
+	}
+}
+
+func TestStop_WithoutRequestsInFlightDoesNotDrain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	inst := newDrainInstance(t, 30*time.Second)
+	_, done := inst.TrackRequest(httptest.NewRequest(http.MethodGet, "/v1/models", nil), "", "", false)
+	done()
+	done() // Completing a request twice counts it once
+
+	started := time.Now()
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Expected the stop not to drain, it took %s", elapsed)
+	}
+}
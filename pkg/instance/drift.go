@@ -0,0 +1,144 @@
+package instance
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// commandLineArgs is the field the positional arguments of a command line are compared under
+const commandLineArgs = "args"
+
+// RunningCommandLine returns the argument vector the running process was launched with and
+// the one it reports, which differ when something other than llamactl spawned it, e.g. a
+// process adopted from a previous llamactl started with other options. The managed backend
+// key is masked in both.
+func (i *Process) RunningCommandLine() (launched, running []string, err error) {
+	i.mu.RLock()
+	if !i.IsRunning() || i.cmd == nil || i.cmd.Process == nil {
+		i.mu.RUnlock()
+		return nil, nil, fmt.Errorf("instance %s is not running: %w", i.Name, ErrNotRunning)
+	}
+	launched = slices.Clone(i.cmd.Args)
+	pid := i.cmd.Process.Pid
+	wrapper := len(i.options.LaunchWrapper)
+	backendKey := i.backendKey
+	i.mu.RUnlock()
+
+	running, err = processCommandLine(pid)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read the command line of process %d of instance %s: %w", pid, i.Name, err)
+	}
+	// A launch wrapper that execs the backend, such as nice or taskset, leaves the command
+	// line of the backend
+	if wrapper > 0 && len(launched) > wrapper && running[0] != launched[0] {
+		launched = launched[wrapper:]
+	}
+	if backendKey != "" {
+		for _, argv := range [][]string{launched, running} {
+			for n, arg := range argv {
+				if arg == backendKey {
+					argv[n] = managedKeyPlaceholder
+				}
+			}
+		}
+	}
+	return launched, running, nil
+}
+
+// CompareCommandLines returns the flags whose values differ between the argument vectors a
+// and b, sorted, along with "command" for the command and "args" for the positional
+// arguments. The value of a flag is the argument following it, a list when it is repeated,
+// or true when it takes none.
+func CompareCommandLines(a, b []string) []ValueDifference {
+	var differences []ValueDifference
+	if command(a) != command(b) {
+		differences = append(differences, ValueDifference{Field: "command", A: command(a), B: command(b)})
+	}
+
+	aValues, bValues := commandLineValues(a), commandLineValues(b)
+	var fields []string
+	for field := range aValues {
+		fields = append(fields, field)
+	}
+	for field := range bValues {
+		if _, ok := aValues[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	for _, field := range fields {
+		aValue, bValue := flagValue(aValues, field), flagValue(bValues, field)
+		if !reflect.DeepEqual(aValue, bValue) {
+			differences = append(differences, ValueDifference{Field: field, A: aValue, B: bValue})
+		}
+	}
+	return differences
+}
+
+// command returns the command of an argument vector
+func command(argv []string) string {
+	if len(argv) == 0 {
+		return ""
+	}
+	return argv[0]
+}
+
+// commandLineValues groups the arguments of an argument vector, its command left out, by
+// the flag they follow, with the positional arguments under "args"
+func commandLineValues(argv []string) map[string][]string {
+	values := make(map[string][]string)
+	if len(argv) < 2 {
+		return values
+	}
+
+	flag := ""
+	for _, arg := range argv[1:] {
+		if isFlag(arg) {
+			if name, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(name, "--") {
+				values[name] = append(values[name], value)
+				flag = ""
+				continue
+			}
+			if _, ok := values[arg]; !ok {
+				values[arg] = []string{}
+			}
+			flag = arg
+			continue
+		}
+		if flag != "" {
+			values[flag] = append(values[flag], arg)
+			flag = ""
+		} else {
+			values[commandLineArgs] = append(values[commandLineArgs], arg)
+		}
+	}
+	return values
+}
+
+// isFlag reports whether an argument is a flag, rather than a value such as -1
+func isFlag(arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' {
+		return false
+	}
+	_, err := strconv.ParseFloat(arg, 64)
+	return err != nil
+}
+
+// flagValue returns the value of a flag in grouped arguments: nil when it is not set, true
+// when it takes no value, its value, or the list of its values when repeated
+func flagValue(values map[string][]string, field string) any {
+	list, ok := values[field]
+	switch {
+	case !ok:
+		return nil
+	case len(list) == 0:
+		return true
+	case len(list) == 1 && field != commandLineArgs:
+		return list[0]
+	default:
+		return list
+	}
+}
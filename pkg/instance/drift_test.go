@@ -0,0 +1,65 @@
+package instance_test
+
+import (
+	"errors"
+	"llamactl/pkg/instance"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCompareCommandLines(t *testing.T) {
+	launched := []string{"llama-server", "--model", "/models/a.gguf", "--ctx-size", "8192", "--n-gpu-layers", "-1", "--lora", "x", "--lora", "y", "--flash-attn", "--port=8080"}
+
+	if differences := instance.CompareCommandLines(launched, launched); len(differences) != 0 {
+		t.Errorf("Expected identical command lines to have no differences, got %+v", differences)
+	}
+
+	running := []string{"llama-server", "--model", "/models/a.gguf", "--ctx-size", "4096", "--n-gpu-layers", "-1", "--lora", "x", "--port=8081", "extra"}
+	differences := instance.CompareCommandLines(launched, running)
+	expected := []instance.ValueDifference{
+		{Field: "--ctx-size", A: "8192", B: "4096"},
+		{Field: "--flash-attn", A: true, B: nil},
+		{Field: "--lora", A: []string{"x", "y"}, B: "x"},
+		{Field: "--port", A: "8080", B: "8081"},
+		{Field: "args", A: nil, B: []string{"extra"}},
+	}
+	if !reflect.DeepEqual(differences, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, differences)
+	}
+
+	differences = instance.CompareCommandLines([]string{"llama-server"}, []string{"/opt/llama-server"})
+	if len(differences) != 1 || differences[0].Field != "command" {
+		t.Errorf("Expected the command to differ, got %+v", differences)
+	}
+}
+
+func TestRunningCommandLine(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+
+	inst := newShellInstance(t, "sleep 30; :", false, nil)
+	if _, _, err := inst.RunningCommandLine(); !errors.Is(err, instance.ErrNotRunning) {
+		t.Errorf("Expected ErrNotRunning for a stopped instance, got %v", err)
+	}
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { inst.Stop() })
+
+	// The process reports no command line until the kernel has set up the new program
+	deadline := time.Now().Add(2 * time.Second)
+	launched, running, err := inst.RunningCommandLine()
+	for err != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		launched, running, err = inst.RunningCommandLine()
+	}
+	if err != nil {
+		t.Fatalf("RunningCommandLine failed: %v", err)
+	}
+	if !reflect.DeepEqual(launched, running) {
+		t.Errorf("Expected the process to report the command line it was launched with %q, got %q", launched, running)
+	}
+}
@@ -0,0 +1,73 @@
+package instance
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// MaskedEnvironmentValue stands for the value of a secret environment variable when an
+// instance is read back. Sent back in an update, it keeps the value the instance has.
+const MaskedEnvironmentValue = "[masked]"
+
+// secretEnvironmentNames are the parts of the names of environment variables whose values
+// are masked without being listed in secret_environment
+var secretEnvironmentNames = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "API_KEY", "ACCESS_KEY", "PRIVATE_KEY", "CREDENTIAL"}
+
+// IsSecretEnvironment reports whether the value of the environment variable name is masked
+// when the instance is read back: it is listed in secret_environment, or named like a secret
+func (c *CreateInstanceOptions) IsSecretEnvironment(name string) bool {
+	if c != nil && slices.Contains(c.SecretEnvironment, name) {
+		return true
+	}
+	upper := strings.ToUpper(name)
+	return slices.ContainsFunc(secretEnvironmentNames, func(part string) bool { return strings.Contains(upper, part) })
+}
+
+// MaskedSecrets returns the options with the values of their secret environment variables
+// masked, the options themselves if they have none
+func (c *CreateInstanceOptions) MaskedSecrets() *CreateInstanceOptions {
+	if c == nil || !slices.ContainsFunc(slices.Collect(maps.Keys(c.Environment)), c.IsSecretEnvironment) {
+		return c
+	}
+	masked := *c
+	masked.Environment = c.MaskEnvironment(c.Environment)
+	return &masked
+}
+
+// MaskEnvironment returns a copy of env with the values of the secret environment variables
+// of the options masked
+func (c *CreateInstanceOptions) MaskEnvironment(env map[string]string) map[string]string {
+	if env == nil {
+		return nil
+	}
+	masked := make(map[string]string, len(env))
+	for name, value := range env {
+		if c.IsSecretEnvironment(name) {
+			value = MaskedEnvironmentValue
+		}
+		masked[name] = value
+	}
+	return masked
+}
+
+// RestoreMaskedEnvironment replaces the masked values of the environment variables, as read
+// back from the instance, with their values in previous
+func (c *CreateInstanceOptions) RestoreMaskedEnvironment(previous *CreateInstanceOptions) {
+	if previous == nil {
+		return
+	}
+	for name, value := range c.Environment {
+		if previousValue, ok := previous.Environment[name]; ok && value == MaskedEnvironmentValue {
+			c.Environment[name] = previousValue
+		}
+	}
+}
+
+// clone returns a copy of the options whose environment the caller can no longer change
+func (c *CreateInstanceOptions) clone() *CreateInstanceOptions {
+	options := *c
+	options.Environment = maps.Clone(c.Environment)
+	options.SecretEnvironment = slices.Clone(c.SecretEnvironment)
+	return &options
+}
@@ -0,0 +1,142 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newEnvironmentOptions(environment map[string]string, secrets ...string) *instance.CreateInstanceOptions {
+	return &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		Environment:        environment,
+		SecretEnvironment:  secrets,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+}
+
+func TestEnvironment_CopiedFromCaller(t *testing.T) {
+	environment := map[string]string{"CUDA_VISIBLE_DEVICES": "0"}
+	inst := instance.NewInstance("env-instance", &config.BackendConfig{}, &config.InstancesConfig{LogsDir: t.TempDir()}, newEnvironmentOptions(environment), nil)
+
+	environment["CUDA_VISIBLE_DEVICES"] = "1"
+	if value := inst.GetOptions().Environment["CUDA_VISIBLE_DEVICES"]; value != "0" {
+		t.Errorf("Expected the environment given to NewInstance to be copied, got %q", value)
+	}
+
+	updated := map[string]string{"HIP_VISIBLE_DEVICES": "2"}
+	inst.SetOptions(newEnvironmentOptions(updated))
+	updated["HIP_VISIBLE_DEVICES"] = "3"
+	if value := inst.GetOptions().Environment["HIP_VISIBLE_DEVICES"]; value != "2" {
+		t.Errorf("Expected the environment given to SetOptions to be copied, got %q", value)
+	}
+}
+
+func TestEnvironment_SecretsMasked(t *testing.T) {
+	options := newEnvironmentOptions(map[string]string{
+		"CUDA_VISIBLE_DEVICES": "0",
+		"HF_TOKEN":             "hf_secret",
+		"LLAMA_ARG_ENDPOINT":   "https://internal",
+	}, "LLAMA_ARG_ENDPOINT")
+	inst := instance.NewInstance("env-instance", &config.BackendConfig{}, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+
+	decode := func(data []byte) map[string]string {
+		t.Helper()
+		var record struct {
+			Options struct {
+				Environment map[string]string `json:"environment"`
+			} `json:"options"`
+		}
+		if err := json.Unmarshal(data, &record); err != nil {
+			t.Fatalf("Failed to decode the instance: %v", err)
+		}
+		return record.Options.Environment
+	}
+
+	data, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	environment := decode(data)
+	if environment["CUDA_VISIBLE_DEVICES"] != "0" || environment["HF_TOKEN"] != instance.MaskedEnvironmentValue || environment["LLAMA_ARG_ENDPOINT"] != instance.MaskedEnvironmentValue {
+		t.Errorf("Expected only the secrets to be masked, got %v", environment)
+	}
+	if strings.Contains(string(data), "hf_secret") {
+		t.Error("Expected the secret value to be left out of the instance")
+	}
+
+	data, err = inst.MarshalPersisted()
+	if err != nil {
+		t.Fatalf("MarshalPersisted failed: %v", err)
+	}
+	if environment := decode(data); environment["HF_TOKEN"] != "hf_secret" {
+		t.Errorf("Expected the persisted instance to keep the secret, got %v", environment)
+	}
+	if inst.GetOptions().Environment["HF_TOKEN"] != "hf_secret" {
+		t.Error("Expected masking to leave the options of the instance alone")
+	}
+
+	// Sent back as read, the secret keeps its value
+	update := newEnvironmentOptions(map[string]string{"HF_TOKEN": instance.MaskedEnvironmentValue, "NEW_TOKEN": instance.MaskedEnvironmentValue})
+	update.RestoreMaskedEnvironment(inst.GetOptions())
+	if update.Environment["HF_TOKEN"] != "hf_secret" || update.Environment["NEW_TOKEN"] != instance.MaskedEnvironmentValue {
+		t.Errorf("Expected only the known secrets to be restored, got %v", update.Environment)
+	}
+}
+
+func TestEnvironment_AppliedOnStart(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "env")
+	inst := newShellInstance(t, `echo "$LLAMACTL_TEST_ENV" > "$LLAMACTL_TEST_OUTPUT"; exec sleep 30`, false, nil)
+	t.Cleanup(func() { inst.Stop() })
+	options := *inst.GetOptions()
+	options.Environment = map[string]string{"LLAMACTL_TEST_ENV": "first", "LLAMACTL_TEST_OUTPUT": output}
+	inst.SetOptions(&options)
+
+	readOutput := func() string {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if data, err := os.ReadFile(output); err == nil && len(data) > 0 {
+				return strings.TrimSpace(string(data))
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatal("The backend did not write its environment")
+		return ""
+	}
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if value := readOutput(); value != "first" {
+		t.Errorf("Expected the backend to see the environment of the instance, got %q", value)
+	}
+
+	// A running backend keeps its environment until restarted
+	options.Environment = map[string]string{"LLAMACTL_TEST_ENV": "second", "LLAMACTL_TEST_OUTPUT": output}
+	inst.SetOptions(&options)
+	if !inst.RestartRequired() {
+		t.Fatal("Expected a changed environment to require a restart")
+	}
+	if value := inst.GetOptions().Environment["LLAMACTL_TEST_ENV"]; value != "first" {
+		t.Errorf("Expected the environment in effect to be kept, got %q", value)
+	}
+
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	os.Remove(output)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if value := readOutput(); value != "second" {
+		t.Errorf("Expected the backend to see the updated environment, got %q", value)
+	}
+}
@@ -0,0 +1,91 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrUnmanaged is returned for lifecycle operations on instances whose backend llamactl does not manage
+var ErrUnmanaged = errors.New("instance is not managed by llamactl")
+
+// externalProbeTimeout bounds a single health probe of an unmanaged backend
+const externalProbeTimeout = 5 * time.Second
+
+// IsManaged reports whether llamactl starts, stops and restarts the backend (default: true)
+func (c *CreateInstanceOptions) IsManaged() bool {
+	return c == nil || c.Managed == nil || *c.Managed
+}
+
+// IsManaged reports whether llamactl owns the backend process of this instance.
+// Unmanaged instances point at an externally run backend and are only proxied and probed.
+func (i *Process) IsManaged() bool {
+	return !i.unmanaged.Load()
+}
+
+// healthURL returns the URL of the backend health endpoint
+func (i *Process) healthURL() (string, error) {
+	opts := i.GetOptions()
+	if opts == nil {
+		return "", fmt.Errorf("instance %s has no options set", i.Name)
+	}
+
+	return fmt.Sprintf("%s://%s/health", opts.BackendTLS.BackendScheme(), net.JoinHostPort(i.GetHost(), strconv.Itoa(i.GetPort()))), nil
+}
+
+// ProbeHealth performs a single health check against the backend
+func (i *Process) ProbeHealth(ctx context.Context) error {
+	healthURL, err := i.healthURL()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", healthURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create health request: %w", err)
+	}
+
+	client := &http.Client{
+		Transport: i.BackendTransport(),
+		Timeout:   externalProbeTimeout,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RefreshExternalStatus probes the backend of an unmanaged instance and updates its status:
+// a healthy backend is running, an unreachable or unhealthy one is failed.
+func (i *Process) RefreshExternalStatus(ctx context.Context) error {
+	if i.IsManaged() {
+		return fmt.Errorf("instance %s is managed by llamactl, its status follows the process", i.Name)
+	}
+
+	probeErr := i.ProbeHealth(ctx)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// The options may have changed, or the instance may have been deleted, while probing
+	if !i.options.IsManaged() && !i.closed {
+		switch {
+		case probeErr == nil && i.GetStatus() != Running:
+			i.SetStatus(Running, ReasonHealthProbeSuccess, "external backend is healthy")
+		case probeErr != nil && i.GetStatus() != Failed:
+			i.SetStatus(Failed, ReasonHealthProbeFailure, probeErr.Error())
+		}
+	}
+
+	return probeErr
+}
@@ -0,0 +1,78 @@
+package instance_test
+
+import (
+	"context"
+	"errors"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUnmanagedInstance_Lifecycle(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	inst := newTLSTestInstance(t, backend, nil)
+	managed := false
+	options := inst.GetOptions()
+	options.Managed = &managed
+	inst.SetOptions(options)
+
+	if inst.IsManaged() {
+		t.Fatal("Expected instance to be unmanaged")
+	}
+	if err := inst.Start(); !errors.Is(err, instance.ErrUnmanaged) {
+		t.Errorf("Expected Start to fail with ErrUnmanaged, got %v", err)
+	}
+	if err := inst.Stop(); !errors.Is(err, instance.ErrUnmanaged) {
+		t.Errorf("Expected Stop to fail with ErrUnmanaged, got %v", err)
+	}
+	if _, err := inst.GetLogs(-1); !errors.Is(err, instance.ErrUnmanaged) {
+		t.Errorf("Expected GetLogs to fail with ErrUnmanaged, got %v", err)
+	}
+}
+
+func TestUnmanagedInstance_RefreshExternalStatus(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	inst := newTLSTestInstance(t, backend, nil)
+	managed := false
+	options := inst.GetOptions()
+	options.Managed = &managed
+	inst.SetOptions(options)
+
+	if err := inst.RefreshExternalStatus(context.Background()); err != nil {
+		t.Fatalf("Expected healthy probe, got %v", err)
+	}
+	if inst.GetStatus() != instance.Running || inst.GetStatusReason().Code != instance.ReasonHealthProbeSuccess {
+		t.Errorf("Expected running with %s, got %s with %+v", instance.ReasonHealthProbeSuccess, inst.GetStatus(), inst.GetStatusReason())
+	}
+
+	healthy.Store(false)
+	if err := inst.RefreshExternalStatus(context.Background()); err == nil {
+		t.Fatal("Expected unhealthy probe to fail")
+	}
+	if inst.GetStatus() != instance.Failed || inst.GetStatusReason().Code != instance.ReasonHealthProbeFailure {
+		t.Errorf("Expected failed with %s, got %s with %+v", instance.ReasonHealthProbeFailure, inst.GetStatus(), inst.GetStatusReason())
+	}
+
+	// Managed instances get their status from the process instead
+	managed = true
+	inst.SetOptions(options)
+	if err := inst.RefreshExternalStatus(context.Background()); err == nil {
+		t.Error("Expected RefreshExternalStatus to fail for a managed instance")
+	}
+}
@@ -0,0 +1,61 @@
+package instance
+
+import (
+	"log"
+	"regexp"
+	"sync/atomic"
+)
+
+// fatalLogWatcher matches backend output against the fatal log patterns. It fires at most
+// once per process run, so a flood of matching lines results in a single recovery.
+type fatalLogWatcher struct {
+	pattern *regexp.Regexp // All patterns combined into one alternation, evaluated once per line
+	fired   atomic.Bool
+}
+
+// newFatalLogWatcher compiles the patterns, returning nil when there are none
+func newFatalLogWatcher(name string, patterns []string) *fatalLogWatcher {
+	pattern := compilePatterns(name, "fatal log", patterns)
+	if pattern == nil {
+		return nil
+	}
+	return &fatalLogWatcher{pattern: pattern}
+}
+
+// arm re-enables the watcher for a new process run
+func (w *fatalLogWatcher) arm() {
+	if w != nil {
+		w.fired.Store(false)
+	}
+}
+
+// match reports whether line is the first fatal line of the current run
+func (w *fatalLogWatcher) match(line string) bool {
+	if w == nil || w.fired.Load() || !w.pattern.MatchString(line) {
+		return false
+	}
+	return w.fired.CompareAndSwap(false, true)
+}
+
+// checkFatalLog kills the backend when it logs a fatal error. Some failures, such as CUDA
+// errors, leave the process hanging instead of exiting; once it is killed the monitor
+// records the matched line as the exit reason and restarts the instance per its policy.
+func (i *Process) checkFatalLog(line string) {
+	if !i.fatalLog.match(line) {
+		return
+	}
+
+	i.mu.Lock()
+	if !i.IsRunning() || i.cmd == nil || i.cmd.Process == nil {
+		i.mu.Unlock()
+		return
+	}
+	i.fatalLogLine = line
+	cmd := i.cmd
+	i.mu.Unlock()
+
+	log.Printf("Instance %s logged a fatal error, killing it: %s", i.Name, line)
+	if err := killProcessGroup(cmd); err != nil {
+		log.Printf("Failed to kill instance %s after a fatal error: %v", i.Name, err)
+	}
+}
@@ -0,0 +1,86 @@
+package instance_test
+
+import (
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"strings"
+	"testing"
+)
+
+// newFatalLogInstance is newShellInstance with the default fatal log patterns configured
+func newFatalLogInstance(t *testing.T, script string, autoRestart bool, onStatusChange instance.StatusChangeFunc) *instance.Process {
+	t.Helper()
+	return newShellInstanceWithSettings(t, script, autoRestart, &config.InstancesConfig{FatalLogPatterns: config.DefaultFatalLogPatterns}, onStatusChange)
+}
+
+func TestFatalLog_KillsHungBackend(t *testing.T) {
+	// The backend floods fatal lines and then hangs; it must be killed once per run
+	const script = `for i in 1 2 3 4 5; do echo 'GGML_ASSERT(n_tokens > 0) failed'; done; exec sleep 30`
+
+	tests := []struct {
+		name        string
+		autoRestart bool
+		expected    []instance.ReasonCode
+	}{
+		{
+			name:     "no auto restart",
+			expected: []instance.ReasonCode{instance.ReasonUserStart, instance.ReasonFatalLog, instance.ReasonFatalLog},
+		},
+		{
+			name:        "restarted per policy",
+			autoRestart: true,
+			expected: []instance.ReasonCode{
+				instance.ReasonUserStart,
+				instance.ReasonFatalLog,
+				instance.ReasonAutoRestart,
+				instance.ReasonFatalLog,
+				instance.ReasonMaxRestartsExceeded,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := newTransitionRecorder()
+			inst := newFatalLogInstance(t, script, tt.autoRestart, recorder.record)
+
+			if err := inst.Start(); err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			recorder.waitFor(t, instance.Failed)
+
+			transitions := recorder.snapshot()
+			if len(transitions) != len(tt.expected) {
+				t.Fatalf("Expected %d transitions, got %+v", len(tt.expected), transitions)
+			}
+			for i, tr := range transitions {
+				if tr.reason.Code != tt.expected[i] {
+					t.Errorf("Transition %d: expected reason %q, got %q", i, tt.expected[i], tr.reason.Code)
+				}
+			}
+
+			if !strings.Contains(transitions[1].reason.Message, "GGML_ASSERT(n_tokens > 0) failed") {
+				t.Errorf("Expected the matched line in the crash reason, got %q", transitions[1].reason.Message)
+			}
+		})
+	}
+}
+
+func TestFatalLog_IgnoresOrdinaryOutput(t *testing.T) {
+	recorder := newTransitionRecorder()
+	inst := newFatalLogInstance(t, `echo 'ggml_cuda_init: found 1 CUDA devices'; exec sleep 30`, false, recorder.record)
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	recorder.waitFor(t, instance.Starting)
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	for _, tr := range recorder.snapshot() {
+		if tr.reason.Code == instance.ReasonFatalLog {
+			t.Errorf("Unexpected fatal log transition: %+v", tr)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package instance
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// goroutineJoinTimeout bounds how long Stop and Close wait for the instance's goroutines to exit
+const goroutineJoinTimeout = 5 * time.Second
+
+// GoroutineStats counts the goroutines started on behalf of an instance
+type GoroutineStats struct {
+	Started int64 `json:"started"`
+	Stopped int64 `json:"stopped"`
+	Active  int64 `json:"active"`
+}
+
+// goroutineTracker accounts for every goroutine an instance owns so that they can be
+// joined when the instance stops or is deleted. Unlike a sync.WaitGroup it may be waited
+// on while new goroutines are being started, which happens when a restart races a stop.
+// The zero value is ready to use.
+type goroutineTracker struct {
+	started atomic.Int64
+	stopped atomic.Int64
+
+	mu   sync.Mutex
+	idle chan struct{} // closed once the active goroutines have exited, nil if none were started
+}
+
+// Go runs f in a new goroutine owned by the instance
+func (t *goroutineTracker) Go(f func()) {
+	t.mu.Lock()
+	if t.active() == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.started.Add(1)
+	t.mu.Unlock()
+
+	go func() {
+		defer t.done()
+		f()
+	}()
+}
+
+func (t *goroutineTracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped.Add(1)
+	if t.active() == 0 {
+		close(t.idle)
+	}
+}
+
+// active returns the number of running goroutines (caller must hold the lock)
+func (t *goroutineTracker) active() int64 {
+	return t.started.Load() - t.stopped.Load()
+}
+
+// Wait blocks until no goroutines are active, returning false if the timeout expires first
+func (t *goroutineTracker) Wait(timeout time.Duration) bool {
+	t.mu.Lock()
+	idle := t.idle
+	t.mu.Unlock()
+
+	if idle == nil {
+		return true
+	}
+	select {
+	case <-idle:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Stats returns the goroutine counters
+func (t *goroutineTracker) Stats() GoroutineStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return GoroutineStats{
+		Started: t.started.Load(),
+		Stopped: t.stopped.Load(),
+		Active:  t.active(),
+	}
+}
+
+// GetGoroutineStats returns the accounting of goroutines owned by the instance
+func (i *Process) GetGoroutineStats() GoroutineStats {
+	return i.goroutines.Stats()
+}
+
+// joinGoroutines waits for the instance's goroutines to exit, logging any that outlive the timeout
+func (i *Process) joinGoroutines() error {
+	if i.goroutines.Wait(goroutineJoinTimeout) {
+		return nil
+	}
+	stats := i.goroutines.Stats()
+	log.Printf("Warning: instance %s still has %d goroutines running after %v", i.Name, stats.Active, goroutineJoinTimeout)
+	return fmt.Errorf("instance %s still has %d goroutines running", i.Name, stats.Active)
+}
+
+// Close releases the instance when it is deleted: it cancels any pending restart, waits
+// for the instance's goroutines to exit and frees its output tails. The instance must
+// already be stopped.
+func (i *Process) Close() error {
+	i.mu.Lock()
+	i.closed = true
+	if i.restartCancel != nil {
+		i.restartCancel()
+		i.restartCancel = nil
+		i.NextRestartAt = nil
+	}
+	if i.queueCancel != nil {
+		i.queueCancel()
+		i.queueCancel = nil
+	}
+	i.mu.Unlock()
+
+	err := i.joinGoroutines()
+	i.mu.Lock()
+	i.stderrTail.release()
+	i.outputTail.release()
+	i.mu.Unlock()
+	return err
+}
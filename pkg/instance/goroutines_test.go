@@ -0,0 +1,83 @@
+package instance_test
+
+import (
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestGoroutines_StartStopCycles(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	inst := newShellInstance(t, "exec sleep 30", false, func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {})
+
+	const cycles = 3
+	for range cycles {
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if stats := inst.GetGoroutineStats(); stats.Active != 4 {
+			t.Errorf("Expected 4 active goroutines while starting, got %+v", stats)
+		}
+		if err := inst.Stop(); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		if stats := inst.GetGoroutineStats(); stats.Active != 0 {
+			t.Errorf("Expected no active goroutines after stop, got %+v", stats)
+		}
+	}
+
+	stats := inst.GetGoroutineStats()
+	if stats.Started != 4*cycles || stats.Stopped != 4*cycles {
+		t.Errorf("Expected %d goroutines started and stopped, got %+v", 4*cycles, stats)
+	}
+}
+
+func TestGoroutines_CrashCycles(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	recorder := newTransitionRecorder()
+	inst := newShellInstance(t, "exit 1", true, recorder.record)
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	recorder.waitFor(t, instance.Failed)
+
+	if err := inst.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if stats := inst.GetGoroutineStats(); stats.Active != 0 || stats.Started != 8 {
+		t.Errorf("Expected 8 goroutines over two runs and none active, got %+v", stats)
+	}
+}
+
+func TestGoroutines_CloseCancelsPendingRestart(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	recorder := newTransitionRecorder()
+	inst := newShellInstance(t, "exit 1", true, recorder.record)
+
+	// Update the options so the restart stays pending
+	options := inst.GetOptions()
+	options.RestartDelay = testutil.DurationPtr(time.Minute)
+	inst.SetOptions(options)
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	recorder.waitFor(t, instance.Restarting)
+
+	if err := inst.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if stats := inst.GetGoroutineStats(); stats.Active != 0 {
+		t.Errorf("Expected no active goroutines after close, got %+v", stats)
+	}
+	if err := inst.Start(); err == nil {
+		t.Error("Expected Start to fail after the instance was closed")
+	}
+}
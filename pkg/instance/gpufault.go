@@ -0,0 +1,185 @@
+package instance
+
+import (
+	"bytes"
+	"io"
+	"llamactl/pkg/config"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policies for instances whose backend lost its GPU
+const (
+	GPUFaultFlag    = "flag"    // Report the fault and leave the instance running
+	GPUFaultRestart = "restart" // Also kill the backend so it is restarted per its restart policy
+)
+
+const (
+	// defaultGPUFaultWindow is the window matching responses are counted over when
+	// gpu_fault_window is unset
+	defaultGPUFaultWindow = time.Minute
+	// gpuFaultPeekBytes is how much of an error response body is matched against the patterns
+	gpuFaultPeekBytes = 4096
+	// gpuFaultSampleBytes is how much of the last matching body a fault reports
+	gpuFaultSampleBytes = 512
+)
+
+// GPUFault reports that the backend of the running process keeps failing requests with GPU
+// errors, typically because the driver evicted its allocations for another process. The
+// process survives and passes shallow health checks, so it is degraded rather than crashed.
+type GPUFault struct {
+	Errors     int             `json:"errors"`      // Matching responses within the window
+	Window     config.Duration `json:"window"`      // gpu_fault_window the errors were counted over
+	Sample     string          `json:"sample"`      // Body of the last matching response, truncated
+	DetectedAt time.Time       `json:"detected_at"` // When the threshold was reached
+}
+
+// gpuFaultDetector counts the backend 5xx responses matching the GPU fault patterns over a
+// sliding window. It fires at most once per process run, so a single transient error does
+// not bounce the instance and a burst results in a single recovery. Only server errors
+// reach it, so the lock is never taken on the path of successful requests.
+type gpuFaultDetector struct {
+	pattern   *regexp.Regexp
+	threshold int
+	window    time.Duration
+
+	mu      sync.Mutex
+	matches []time.Time // Oldest first, within the window
+	fired   bool
+}
+
+// newGPUFaultDetector compiles the patterns, returning nil when there are none
+func newGPUFaultDetector(name string, patterns []string, threshold int, window time.Duration) *gpuFaultDetector {
+	pattern := compilePatterns(name, "GPU fault", patterns)
+	if pattern == nil {
+		return nil
+	}
+	if window <= 0 {
+		window = defaultGPUFaultWindow
+	}
+	return &gpuFaultDetector{pattern: pattern, threshold: max(threshold, 1), window: window}
+}
+
+// arm forgets the matches of the previous process run
+func (d *gpuFaultDetector) arm() {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.matches = nil
+	d.fired = false
+}
+
+// record counts a matching response and returns the number of matches within the window
+// if they reach the threshold for the first time this run, or 0
+func (d *gpuFaultDetector) record(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fired {
+		return 0
+	}
+	cutoff := now.Add(-d.window)
+	kept := d.matches[:0]
+	for _, at := range d.matches {
+		if at.After(cutoff) {
+			kept = append(kept, at)
+		}
+	}
+	d.matches = append(kept, now)
+	if len(d.matches) < d.threshold {
+		return 0
+	}
+	d.fired = true
+	return len(d.matches)
+}
+
+// peekBody returns the start of a response body, leaving the body readable in full
+func peekBody(resp *http.Response) []byte {
+	if resp.Body == nil {
+		return nil
+	}
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, gpuFaultPeekBytes))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), resp.Body), resp.Body}
+	return head
+}
+
+// checkGPUFault matches a backend 5xx response against the GPU fault patterns. Once enough
+// of them match within the window, the instance records the fault and, if its policy asks
+// for it, the backend is killed like after a fatal log line, so it is restarted.
+func (i *Process) checkGPUFault(resp *http.Response) {
+	if i.gpuFault == nil {
+		return
+	}
+	body := peekBody(resp)
+	if !i.gpuFault.pattern.Match(body) {
+		return
+	}
+	now := i.timeProvider.Now()
+	matches := i.gpuFault.record(now)
+	if matches == 0 {
+		return
+	}
+
+	sample := strings.TrimSpace(strings.ToValidUTF8(string(body[:min(len(body), gpuFaultSampleBytes)]), ""))
+	fault := &GPUFault{
+		Errors:     matches,
+		Window:     config.Duration(i.gpuFault.window),
+		Sample:     sample,
+		DetectedAt: now,
+	}
+	i.mu.Lock()
+	if !i.IsRunning() {
+		i.mu.Unlock()
+		return
+	}
+	i.GPUFault = fault
+	i.unreportedGPUFault = fault
+	restart := i.options.GPUFaultPolicy() == GPUFaultRestart && i.cmd != nil && i.cmd.Process != nil
+	cmd := i.cmd
+	if restart {
+		i.gpuFaultKilled = true
+	}
+	i.mu.Unlock()
+
+	if !restart {
+		log.Printf("Warning: instance %s is degraded, %d responses with GPU errors within %v: %s", i.Name, matches, i.gpuFault.window, fault.Sample)
+		return
+	}
+	log.Printf("Instance %s failed %d responses with GPU errors within %v, killing it: %s", i.Name, matches, i.gpuFault.window, fault.Sample)
+	if err := killProcessGroup(cmd); err != nil {
+		log.Printf("Failed to kill instance %s after a GPU fault: %v", i.Name, err)
+	}
+}
+
+// TakeGPUFault returns the GPU fault detected since the last call, if any. The fault of a
+// backend killed for it is still returned after the instance restarted.
+func (i *Process) TakeGPUFault() *GPUFault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	fault := i.unreportedGPUFault
+	i.unreportedGPUFault = nil
+	return fault
+}
+
+// GPUFaultPolicy returns what is done when the backend of the instance loses its GPU:
+// managed instances are restarted unless they ask to be flagged only
+func (c *CreateInstanceOptions) GPUFaultPolicy() string {
+	if c == nil {
+		return GPUFaultFlag
+	}
+	if c.OnGPUFault == "" {
+		if c.IsManaged() {
+			return GPUFaultRestart
+		}
+		return GPUFaultFlag
+	}
+	return c.OnGPUFault
+}
@@ -0,0 +1,133 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"io"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// cudaErrorBody is the response of a llama.cpp backend whose GPU allocations were evicted
+const cudaErrorBody = `{"error": {"code": 500, "message": "CUDA error: an illegal memory access was encountered"}}`
+
+// newGPUFaultInstance starts an instance proxying to a backend that responds to /gpu with
+// GPU errors and to /fail with other server errors
+func newGPUFaultInstance(t *testing.T, policy string, onStatusChange instance.StatusChangeFunc) *instance.Process {
+	t.Helper()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gpu":
+			http.Error(w, cudaErrorBody, http.StatusInternalServerError)
+		case "/fail":
+			http.Error(w, "backend error", http.StatusInternalServerError)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}
+	options := &instance.CreateInstanceOptions{
+		AutoRestart: testutil.BoolPtr(false),
+		OnGPUFault:  policy,
+	}
+	globalSettings := &config.InstancesConfig{
+		GPUFaultPatterns:  config.DefaultGPUFaultPatterns,
+		GPUFaultThreshold: 3,
+		GPUFaultWindow:    config.Duration(time.Minute),
+	}
+
+	inst := newBackendInstance(t, handler, options, globalSettings, onStatusChange)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := inst.WaitForHealthy(5 * time.Second); err != nil {
+		t.Fatalf("WaitForHealthy failed: %v", err)
+	}
+	return inst
+}
+
+func TestGPUFault_Flag(t *testing.T) {
+	inst := newGPUFaultInstance(t, instance.GPUFaultFlag, nil)
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+	request := func(path string) string {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		body, _ := io.ReadAll(w.Body)
+		return string(body)
+	}
+
+	// Other server errors and a couple of GPU errors are not a fault
+	request("/fail")
+	request("/gpu")
+	if body := request("/gpu"); body != cudaErrorBody+"\n" {
+		t.Errorf("Expected the client to get the whole error body, got %q", body)
+	}
+	request("/fail")
+	if fault := inst.TakeGPUFault(); fault != nil {
+		t.Fatalf("Expected no GPU fault below the threshold, got %+v", fault)
+	}
+
+	request("/gpu")
+	fault := inst.TakeGPUFault()
+	if fault == nil || fault.Errors != 3 || fault.Window != config.Duration(time.Minute) || fault.Sample != cudaErrorBody {
+		t.Fatalf("Expected a GPU fault after 3 GPU errors, got %+v", fault)
+	}
+	if again := inst.TakeGPUFault(); again != nil {
+		t.Errorf("Expected the fault to be taken once, got %+v", again)
+	}
+
+	// Flagged only: the instance keeps running, degraded
+	if !inst.IsRunning() {
+		t.Errorf("Expected the instance to keep running, got %s", inst.GetStatus())
+	}
+	data, _ := json.Marshal(inst)
+	var marshaled struct {
+		Degraded bool `json:"degraded"`
+		GPUFault *struct {
+			Window string `json:"window"`
+		} `json:"gpu_fault"`
+	}
+	json.Unmarshal(data, &marshaled)
+	if !marshaled.Degraded || marshaled.GPUFault == nil || marshaled.GPUFault.Window != "1m" {
+		t.Errorf("Expected the instance to be degraded with its GPU fault over 1m, got %s", data)
+	}
+
+	// Further errors of the same run are not reported again
+	request("/gpu")
+	if again := inst.TakeGPUFault(); again != nil {
+		t.Errorf("Expected a single fault per run, got %+v", again)
+	}
+}
+
+func TestGPUFault_Restart(t *testing.T) {
+	recorder := newTransitionRecorder()
+	inst := newGPUFaultInstance(t, "", recorder.record)
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+
+	for range 3 {
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/gpu", nil))
+	}
+	recorder.waitFor(t, instance.Stopped)
+
+	var reason *instance.StatusReason
+	for _, tr := range recorder.snapshot() {
+		if tr.newStatus == instance.Stopped {
+			reason = &tr.reason
+		}
+	}
+	if reason.Code != instance.ReasonGPUFault {
+		t.Errorf("Expected the gpu_fault reason, got %+v", reason)
+	}
+	if fault := inst.TakeGPUFault(); fault == nil {
+		t.Error("Expected the fault to be reported after the backend was killed")
+	}
+}
@@ -0,0 +1,76 @@
+package instance
+
+import "net"
+
+// defaultConnectHost is where llamactl reaches a backend listening on every interface or
+// on its default address
+const defaultConnectHost = "127.0.0.1"
+
+// normalizeHosts moves the host of the backend options, which used to be both the
+// address the backend listens on and the address llamactl connects to, to bind_host, or
+// to connect_host for unmanaged instances. A host conflicting with bind_host or
+// connect_host is left in place for validation to reject.
+func (c *CreateInstanceOptions) normalizeHosts() {
+	host := c.BackendHost()
+	if host == "" {
+		return
+	}
+
+	switch {
+	case c.IsManaged():
+		if c.BindHost == "" {
+			c.BindHost = host
+		}
+		if c.BindHost != host {
+			return
+		}
+	case isUnspecifiedHost(host):
+		// An external backend listening on every interface is reached on the default host
+	default:
+		if c.ConnectHost == "" {
+			c.ConnectHost = host
+		}
+		if c.ConnectHost != host {
+			return
+		}
+	}
+	*c = *c.withHost("")
+}
+
+// bindHost returns the address the backend listens on, empty for the backend default
+func (c *CreateInstanceOptions) bindHost() string {
+	if c.BindHost != "" {
+		return c.BindHost
+	}
+	return c.BackendHost()
+}
+
+// connectHost returns the host llamactl reaches the backend on: connect_host if set,
+// otherwise the address the backend listens on, the one resolved from bind_interface
+// taking precedence. A backend listening on every interface, or on its default address,
+// is reached over the loopback interface.
+func (c *CreateInstanceOptions) connectHost(bindAddress string) string {
+	if c.ConnectHost != "" {
+		return c.ConnectHost
+	}
+
+	host := bindAddress
+	if host == "" {
+		host = c.bindHost()
+	}
+	if host == "" {
+		return defaultConnectHost
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		if ip.To4() == nil {
+			return "::1"
+		}
+		return defaultConnectHost
+	}
+	return host
+}
+
+func isUnspecifiedHost(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsUnspecified()
+}
@@ -0,0 +1,134 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestHosts(t *testing.T) {
+	unmanaged := false
+	tests := []struct {
+		name        string
+		bindHost    string
+		connectHost string
+		backendHost string // Host of definitions created before bind_host and connect_host
+		managed     *bool
+		wantArg     string // Value of --host, empty when not passed
+		wantConnect string
+	}{
+		{name: "defaults", wantConnect: "127.0.0.1"},
+		{name: "bind host", bindHost: "10.0.0.5", wantArg: "10.0.0.5", wantConnect: "10.0.0.5"},
+		{name: "wildcard bind host", bindHost: "0.0.0.0", wantArg: "0.0.0.0", wantConnect: "127.0.0.1"},
+		{name: "IPv6 wildcard bind host", bindHost: "::", wantArg: "::", wantConnect: "::1"},
+		{name: "connect host", connectHost: "backend.internal", wantConnect: "backend.internal"},
+		{name: "bind and connect hosts", bindHost: "0.0.0.0", connectHost: "172.17.0.2", wantArg: "0.0.0.0", wantConnect: "172.17.0.2"},
+		{name: "legacy host", backendHost: "localhost", wantArg: "localhost", wantConnect: "localhost"},
+		{name: "legacy wildcard host", backendHost: "0.0.0.0", wantArg: "0.0.0.0", wantConnect: "127.0.0.1"},
+		{name: "legacy host with connect host", backendHost: "0.0.0.0", connectHost: "172.17.0.2", wantArg: "0.0.0.0", wantConnect: "172.17.0.2"},
+		{name: "unmanaged connect host", connectHost: "10.0.0.7", managed: &unmanaged, wantConnect: "10.0.0.7"},
+		{name: "unmanaged legacy host", backendHost: "10.0.0.7", managed: &unmanaged, wantConnect: "10.0.0.7"},
+		{name: "unmanaged legacy wildcard host", backendHost: "0.0.0.0", managed: &unmanaged, wantConnect: "127.0.0.1"},
+	}
+
+	backendConfig := &config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "llama-server"}}
+	globalSettings := &config.InstancesConfig{LogsDir: t.TempDir()}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{
+				BackendType: backends.BackendTypeLlamaCpp,
+				BindHost:    tt.bindHost,
+				ConnectHost: tt.connectHost,
+				Managed:     tt.managed,
+				LlamaServerOptions: &llamacpp.LlamaServerOptions{
+					Model: "/path/to/model.gguf",
+					Host:  tt.backendHost,
+					Port:  8080,
+				},
+			}
+			inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, nil)
+
+			args := inst.GetOptions().BuildCommandArgs(&backendConfig.LlamaCpp)
+			var gotArg string
+			if idx := slices.Index(args, "--host"); idx != -1 {
+				gotArg = args[idx+1]
+			}
+			if gotArg != tt.wantArg {
+				t.Errorf("Expected --host %q, got %q in %v", tt.wantArg, gotArg, args)
+			}
+
+			if host := inst.GetHost(); host != tt.wantConnect {
+				t.Errorf("Expected connect host %s, got %s", tt.wantConnect, host)
+			}
+			proxy, err := inst.GetProxy()
+			if err != nil {
+				t.Fatalf("GetProxy failed: %v", err)
+			}
+			req := httptest.NewRequest("GET", "/v1/models", nil)
+			proxy.Director(req)
+			if got, want := req.URL.Hostname(), tt.wantConnect; got != want || req.URL.Port() != "8080" {
+				t.Errorf("Expected the proxy to target %s:8080, got %s", want, req.URL.Host)
+			}
+		})
+	}
+}
+
+func TestHosts_MigratesPersistedHost(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantBind    string
+		wantConnect string
+	}{
+		{
+			name:     "managed",
+			data:     `{"backend_type": "llama_cpp", "backend_options": {"model": "/m.gguf", "host": "0.0.0.0", "port": 8080}}`,
+			wantBind: "0.0.0.0",
+		},
+		{
+			name:        "unmanaged",
+			data:        `{"backend_type": "llama_cpp", "managed": false, "backend_options": {"host": "10.0.0.7", "port": 8080}}`,
+			wantConnect: "10.0.0.7",
+		},
+		{
+			name:     "already migrated",
+			data:     `{"backend_type": "llama_cpp", "bind_host": "0.0.0.0", "backend_options": {"model": "/m.gguf", "port": 8080}}`,
+			wantBind: "0.0.0.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var options instance.CreateInstanceOptions
+			if err := json.Unmarshal([]byte(tt.data), &options); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if options.BindHost != tt.wantBind || options.ConnectHost != tt.wantConnect {
+				t.Errorf("Expected bind host %q and connect host %q, got %q and %q", tt.wantBind, tt.wantConnect, options.BindHost, options.ConnectHost)
+			}
+			if host := options.BackendHost(); host != "" {
+				t.Errorf("Expected the backend host to be moved, got %s", host)
+			}
+
+			// The migrated definition is persisted without the backend host
+			data, err := json.Marshal(&options)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var persisted struct {
+				BackendOptions map[string]any `json:"backend_options"`
+			}
+			if err := json.Unmarshal(data, &persisted); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if _, ok := persisted.BackendOptions["host"]; ok {
+				t.Errorf("Expected no backend host in %s", data)
+			}
+		})
+	}
+}
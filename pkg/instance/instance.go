@@ -3,17 +3,23 @@ package instance
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"llamactl/pkg/backends"
 	"llamactl/pkg/config"
+	"llamactl/pkg/memory"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -29,16 +35,65 @@ func (realTimeProvider) Now() time.Time {
 	return time.Now()
 }
 
+// TimerProvider is a TimeProvider that also provides the timer the delay of an automatic
+// restart waits on, so that tests can fire it
+type TimerProvider interface {
+	TimeProvider
+	After(d time.Duration) <-chan time.Time
+}
+
+// after returns a channel receiving the time once d elapsed, from the time provider if it
+// provides timers
+func (i *Process) after(d time.Duration) <-chan time.Time {
+	if timers, ok := i.timeProvider.(TimerProvider); ok {
+		return timers.After(d)
+	}
+	return time.After(d)
+}
+
+// StatusChangeFunc is called on every status transition with the reason for the transition
+type StatusChangeFunc func(oldStatus, newStatus InstanceStatus, reason StatusReason)
+
 // Process represents a running instance of the llama server
 type Process struct {
 	Name                   string                 `json:"name"`
 	options                *CreateInstanceOptions `json:"-"`
+	optionSources          OptionSources          // Layer each option came from
+	pendingOptions         *CreateInstanceOptions // Set while running, applied on the next start
+	pendingSources         OptionSources          // Layer each pending option came from
 	globalInstanceSettings *config.InstancesConfig
 	globalBackendSettings  *config.BackendConfig
 
-	// Status
+	// Status, guarded by statusMu rather than mu since it is set with and without mu held
+	statusMu       sync.RWMutex
 	Status         InstanceStatus `json:"status"`
-	onStatusChange func(oldStatus, newStatus InstanceStatus)
+	StatusReason   *StatusReason  `json:"status_reason,omitempty"`  // Reason for the most recent transition
+	StoppedReason  *StatusReason  `json:"stopped_reason,omitempty"` // Reason the instance last left the running state
+	LastError      *StatusReason  `json:"last_error,omitempty"`     // Most recent abnormal termination
+	LastExit       *LastExit      `json:"last_exit,omitempty"`      // Exit status and output of the most recent crash
+	ListenCheck    *ListenCheck   `json:"listen_check,omitempty"`   // Where the backend of the current run listens
+	onStatusChange StatusChangeFunc
+
+	// Outcome of restoring the slots saved before the last restart
+	LastSlotRestore *SlotRestore `json:"last_slot_restore,omitempty"`
+
+	// Most recent exit of the backend while starting, cleared once it passes a readiness check
+	StartFailure *StartFailure `json:"start_failure,omitempty"`
+
+	// Set when the model files changed on disk since the running process loaded them
+	ModelChange *ModelChange `json:"model_change,omitempty"`
+
+	// How the configuration the backend reported once ready compared with its options
+	ConfigVerification *ConfigVerification `json:"config_verification,omitempty"`
+
+	// How the last creation or start went against the GPU placement of its services
+	Placement []PlacementDecision `json:"placement,omitempty"`
+
+	// Backend failing requests with GPU errors since, during the current run
+	GPUFault *GPUFault `json:"gpu_fault,omitempty"`
+
+	// When the pending automatic restart is due
+	NextRestartAt *time.Time `json:"next_restart_at,omitempty"`
 
 	// Creation time
 	Created int64 `json:"created,omitempty"` // Unix timestamp when the instance was created
@@ -54,28 +109,136 @@ type Process struct {
 	stderr   io.ReadCloser          `json:"-"` // Standard error stream
 	mu       sync.RWMutex           `json:"-"` // RWMutex for better read/write separation
 	restarts int                    `json:"-"` // Number of restarts
+	crashes  int                    `json:"-"` // Consecutive crashes the restart backoff is computed from
 	proxy    *httputil.ReverseProxy `json:"-"` // Reverse proxy for this instance
 
+	transport   http.RoundTripper              `json:"-"` // Transport used to reach the backend
+	connections atomic.Pointer[connectionPool] `json:"-"` // Connections of the proxy to the backend
+
 	// Restart control
 	restartCancel context.CancelFunc `json:"-"` // Cancel function for pending restarts
+	rapidFailures int                `json:"-"` // Crashes in a row within min_uptime_seconds of starting
+	restartPause  *RestartPause      `json:"-"` // Own pause of the automatic restarts
+	queueCancel   context.CancelFunc `json:"-"` // Cancel function for a start waiting for a loading slot
+	restartMu     sync.Mutex         `json:"-"` // Serializes Restart and Reload calls
+	reloadCancel  context.CancelFunc `json:"-"` // Gives up the replacement of the reload in progress
+	drainStop     chan struct{}      `json:"-"` // Closed to cut the drain of a stop short, nil unless draining
+	forwardOutput *atomic.Bool       `json:"-"` // Set while the output of the current process reaches the detectors
+	startDone     chan struct{}      `json:"-"` // Closed once the Start in progress is done, nil without one
+	startLimiter  *StartLimiter      `json:"-"` // Bounds the instances loading their model at the same time
+	memory        *memory.Accountant `json:"-"` // Accounts for the output tails, nil for unlimited
 	monitorDone   chan struct{}      `json:"-"` // Channel to signal monitor goroutine completion
+	detach        chan struct{}      `json:"-"` // Closed by Detach, nil unless the process can be left running
+	processState  *processState      `json:"-"` // State file of the process spawned with leave_running
+
+	// Receives the crashes, scheduled restarts and output lines
+	observer atomic.Pointer[Observer]
+
+	// Pause of the automatic restarts of every instance of the manager
+	globalPause *GlobalRestartPause
+
+	// State reported to external load balancers
+	draining atomic.Bool
+	lbStatus atomic.Pointer[string] // Last status returned by GetLBHealth
+
+	// Goroutines owned by the instance, joined on stop and delete
+	goroutines goroutineTracker
+	closed     bool // Set once the instance has been deleted
+
+	// Request admission
+	admission *AdmissionQueue `json:"-"`
+
+	// Proxy stats
+	stats *ProxyStats `json:"-"`
+
+	// Requests being proxied
+	requests *RequestTracker `json:"-"`
+
+	// Disk space of the log files when last measured against the log disk quota
+	logDisk atomic.Pointer[LogDiskUsage]
+
+	// Lifecycle operations
+	operations *OperationQueue `json:"-"`
+
+	// Set when the backend is run outside of llamactl
+	unmanaged atomic.Bool
+
+	// Set when the instance is defined in the configuration file
+	configManaged atomic.Bool
+
+	// Local path of the downloaded remote model
+	modelPath string
+
+	// Size of the model files in bytes, 0 if unknown, refreshed when the options change and on start
+	modelSize int64
+
+	// Model files of the running process, compared with the files on disk
+	modelWatch modelWatch
+
+	// Backend binary the current process was launched from, nil if it could not be read
+	binary *BinaryIdentity
+
+	// Address of bind_interface the backend listens on, resolved at start
+	bindAddress string
+
+	// Slots saved before a restart, restored once the next process is ready
+	pendingSlots *slotSnapshot
+
+	// Managed API key the backend runs with, and the key it gets from its next start when
+	// the key was rotated while it was running
+	backendKey        string
+	pendingBackendKey *string
+
+	// Fatal log detection
+	fatalLog     *fatalLogWatcher
+	fatalLogLine string // Fatal line the current process was killed for
+	notReady     string // Why the current process was killed before passing a readiness check
+	unhealthy    string // Why the current process was killed after failing its liveness checks
+	health       *Health
+
+	// Signal sent to the current process through Signal, zero if none
+	sentSignal syscall.Signal
+
+	// GPU fault detection
+	gpuFault           *gpuFaultDetector
+	gpuFaultKilled     bool      // Set when the current process was killed for a GPU fault
+	unreportedGPUFault *GPUFault // Detected fault not taken by TakeGPUFault yet
+
+	// Verification that found mismatches not taken by TakeConfigMismatch yet
+	unreportedConfigMismatch *ConfigVerification
+
+	// Start failure detection
+	startedAt         time.Time      // When the current process was started
+	healthy           bool           // Set once the current process passes a readiness check
+	readyAt           time.Time      // When the current process passed a readiness check
+	stderrTail        *outputTail    // Last lines of error output of the current process
+	outputTail        *outputTail    // Last lines of output of the current process
+	allocationFailure *regexp.Regexp // Output of a backend that ran out of memory
+
+	// Startup progress of the current process, parsed from its output
+	startupProgress *StartupProgress
+	startupLayers   int // Number of layers of the model, once reported
+	startupReported int // Percentage last reported to the observer
 
 	// Timeout management
 	lastRequestTime atomic.Int64 // Unix timestamp of last request
+	lastRequestAt   atomic.Int64 // Unix timestamp of the last request proxied, 0 if none was
 	timeProvider    TimeProvider `json:"-"` // Time provider for testing
 }
 
 // NewInstance creates a new instance with the given name, log path, and options
-func NewInstance(name string, globalBackendSettings *config.BackendConfig, globalInstanceSettings *config.InstancesConfig, options *CreateInstanceOptions, onStatusChange func(oldStatus, newStatus InstanceStatus)) *Process {
+func NewInstance(name string, globalBackendSettings *config.BackendConfig, globalInstanceSettings *config.InstancesConfig, options *CreateInstanceOptions, onStatusChange StatusChangeFunc) *Process {
 	// Validate and copy options
-	options.ValidateAndApplyDefaults(name, globalInstanceSettings)
+	sources := options.ValidateAndApplyDefaults(name, globalInstanceSettings)
+	options = options.clone()
 
 	// Create the instance logger
 	logger := NewInstanceLogger(name, globalInstanceSettings.LogsDir)
 
-	return &Process{
+	inst := &Process{
 		Name:                   name,
 		options:                options,
+		optionSources:          sources,
 		globalInstanceSettings: globalInstanceSettings,
 		globalBackendSettings:  globalBackendSettings,
 		logger:                 logger,
@@ -83,7 +246,23 @@ func NewInstance(name string, globalBackendSettings *config.BackendConfig, globa
 		Created:                time.Now().Unix(),
 		Status:                 Stopped,
 		onStatusChange:         onStatusChange,
+		admission:              NewAdmissionQueue(admissionLimits(options)),
+		operations:             NewOperationQueue(name),
+		stats:                  NewProxyStats(),
+		fatalLog:               newFatalLogWatcher(name, globalInstanceSettings.FatalLogPatterns),
+		allocationFailure:      compilePatterns(name, "allocation failure", globalInstanceSettings.AllocFailurePatterns),
+		gpuFault: newGPUFaultDetector(name, globalInstanceSettings.GPUFaultPatterns,
+			globalInstanceSettings.GPUFaultThreshold, globalInstanceSettings.GPUFaultWindow.Duration()),
 	}
+	inst.requests = NewRequestTracker(func() time.Time { return inst.timeProvider.Now() })
+	inst.refreshModelSize()
+	inst.unmanaged.Store(!options.IsManaged())
+	inst.stats.ConfigureSLO(options.SLO)
+	logger.SetSanitizeMode(options.LogSanitize)
+	logger.SetRotateSize(int64(globalInstanceSettings.LogRotateSizeMB) * 1024 * 1024)
+	logger.onLine = inst.onOutputLine
+	logger.onRotate = inst.onLogRotated
+	return inst
 }
 
 func (i *Process) GetOptions() *CreateInstanceOptions {
@@ -95,47 +274,19 @@ func (i *Process) GetOptions() *CreateInstanceOptions {
 func (i *Process) GetPort() int {
 	i.mu.RLock()
 	defer i.mu.RUnlock()
-	if i.options != nil {
-		switch i.options.BackendType {
-		case backends.BackendTypeLlamaCpp:
-			if i.options.LlamaServerOptions != nil {
-				return i.options.LlamaServerOptions.Port
-			}
-		case backends.BackendTypeMlxLm:
-			if i.options.MlxServerOptions != nil {
-				return i.options.MlxServerOptions.Port
-			}
-		case backends.BackendTypeVllm:
-			if i.options.VllmServerOptions != nil {
-				return i.options.VllmServerOptions.Port
-			}
-		}
-	}
-	return 0
+	return i.options.port()
 }
 
+// GetHost returns the host llamactl connects to the backend on
 func (i *Process) GetHost() string {
 	i.mu.RLock()
 	defer i.mu.RUnlock()
-	if i.options != nil {
-		switch i.options.BackendType {
-		case backends.BackendTypeLlamaCpp:
-			if i.options.LlamaServerOptions != nil {
-				return i.options.LlamaServerOptions.Host
-			}
-		case backends.BackendTypeMlxLm:
-			if i.options.MlxServerOptions != nil {
-				return i.options.MlxServerOptions.Host
-			}
-		case backends.BackendTypeVllm:
-			if i.options.VllmServerOptions != nil {
-				return i.options.VllmServerOptions.Host
-			}
-		}
-	}
-	return ""
+	return i.options.connectHost(i.bindAddress)
 }
 
+// SetOptions replaces the options of the instance. A backend started by llamactl keeps
+// running with the current options: the new ones are pending until its next start, and
+// GetOptions returns the options in effect meanwhile.
 func (i *Process) SetOptions(options *CreateInstanceOptions) {
 	i.mu.Lock()
 	defer i.mu.Unlock()
@@ -146,11 +297,149 @@ func (i *Process) SetOptions(options *CreateInstanceOptions) {
 	}
 
 	// Validate and copy options
-	options.ValidateAndApplyDefaults(i.Name, i.globalInstanceSettings)
+	sources := options.ValidateAndApplyDefaults(i.Name, i.globalInstanceSettings)
+	options = options.clone()
+
+	// A start in progress already built its command from the current options
+	if (i.IsRunning() || i.startDone != nil) && !i.unmanaged.Load() {
+		i.pendingOptions, i.pendingSources = options, sources
+		return
+	}
+	i.pendingOptions, i.pendingSources = nil, nil
+	i.applyOptions(options, sources)
+}
 
+// applyOptions makes options the options in effect (caller must hold the lock)
+func (i *Process) applyOptions(options *CreateInstanceOptions, sources OptionSources) {
 	i.options = options
-	// Clear the proxy so it gets recreated with new options
+	i.optionSources = sources
+	i.unmanaged.Store(!options.IsManaged())
+	i.admission.SetLimits(admissionLimits(options))
+	i.stats.ConfigureSLO(options.SLO)
+	i.logger.SetSanitizeMode(options.LogSanitize)
+	i.refreshModelSize()
+	// Clear the proxy and transport so they get recreated with new options
+	i.resetProxy()
+	i.transport = nil
+}
+
+// resetProxy drops the proxy so it is recreated on next use, retiring its backend
+// connections (caller must hold the lock)
+func (i *Process) resetProxy() {
 	i.proxy = nil
+	if pool := i.connections.Swap(nil); pool != nil {
+		pool.retire()
+	}
+}
+
+// BackendTransport returns the HTTP transport used for proxying and health checks
+func (i *Process) BackendTransport() http.RoundTripper {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.backendTransport()
+}
+
+// backendTransport returns the cached backend transport, creating it if needed (caller must hold the lock)
+func (i *Process) backendTransport() http.RoundTripper {
+	if i.transport == nil {
+		var tlsOptions *BackendTLSOptions
+		if i.options != nil {
+			tlsOptions = i.options.BackendTLS
+		}
+		i.transport = newBackendTransport(tlsOptions)
+	}
+	return i.transport
+}
+
+// admissionLimits returns the concurrency and queue limits configured in the options
+func admissionLimits(options *CreateInstanceOptions) (maxConcurrent, maxQueued int) {
+	if options == nil {
+		return 0, 0
+	}
+	if options.MaxConcurrentRequests != nil {
+		maxConcurrent = *options.MaxConcurrentRequests
+	}
+	if options.MaxQueuedRequests != nil {
+		maxQueued = *options.MaxQueuedRequests
+	}
+	return maxConcurrent, maxQueued
+}
+
+// AcquireRequestSlot waits for the instance's admission queue to admit a request with the given priority,
+// for at most the deadline of the request or the queue timeout of the instance, whichever is shorter
+// (0 = no deadline). It also returns the wait estimated for the request. The returned function must
+// be called once the request has completed.
+func (i *Process) AcquireRequestSlot(ctx context.Context, priority Priority, deadline time.Duration) (func(), time.Duration, error) {
+	maxWait := deadline
+	if opts := i.GetOptions(); opts != nil && opts.QueueTimeout != nil && *opts.QueueTimeout > 0 {
+		if timeout := opts.QueueTimeout.Duration(); maxWait <= 0 || timeout < maxWait {
+			maxWait = timeout
+		}
+	}
+	return i.admission.AcquireWithin(ctx, priority, maxWait)
+}
+
+// GetStats returns a snapshot of the instance's proxy stats
+func (i *Process) GetStats() StatsSnapshot {
+	snapshot := i.stats.Snapshot(i.timeProvider.Now())
+	if pool := i.connections.Load(); pool != nil {
+		snapshot.Connections = pool.stats()
+	}
+	if queue := i.admission.Stats(); queue.MaxConcurrent > 0 {
+		snapshot.Queue = &queue
+	}
+	snapshot.LogDisk = i.logDisk.Load()
+	return snapshot
+}
+
+// TrackRequest registers a proxied request as in flight, bounded by the max_request_duration
+// of the instance. The returned request must be proxied in place of r, and the returned
+// function deferred until it completes.
+func (i *Process) TrackRequest(r *http.Request, apiKey, priority string, streaming bool) (*http.Request, func()) {
+	r, done := i.requests.Track(r, apiKey, priority, streaming)
+	opts := i.GetOptions()
+	if opts == nil || opts.MaxRequestDuration == nil || *opts.MaxRequestDuration <= 0 {
+		return r, done
+	}
+	r, cancel := withRequestDeadline(r, opts.MaxRequestDuration.Duration())
+	return r, func() {
+		cancel()
+		done()
+	}
+}
+
+// GetRequests returns the in-flight proxied requests of the instance, oldest first
+func (i *Process) GetRequests() []InFlightRequest {
+	return i.requests.List()
+}
+
+// CancelRequest cancels an in-flight proxied request, reporting whether it was found
+func (i *Process) CancelRequest(id string) bool {
+	return i.requests.Cancel(id)
+}
+
+// GetQueueStats returns a snapshot of the instance's admission queue
+func (i *Process) GetQueueStats() QueueStats {
+	return i.admission.Stats()
+}
+
+// Where an instance is defined, and so where its options are changed
+const (
+	ManagedByAPI    = "api"
+	ManagedByConfig = "config"
+)
+
+// SetManagedBy records where the instance is defined, ManagedByAPI or ManagedByConfig
+func (i *Process) SetManagedBy(source string) {
+	i.configManaged.Store(source == ManagedByConfig)
+}
+
+// ManagedBy returns where the instance is defined
+func (i *Process) ManagedBy() string {
+	if i.configManaged.Load() {
+		return ManagedByConfig
+	}
+	return ManagedByAPI
 }
 
 // SetTimeProvider sets a custom time provider for testing
@@ -171,32 +460,25 @@ func (i *Process) GetProxy() (*httputil.ReverseProxy, error) {
 		return nil, fmt.Errorf("instance %s has no options set", i.Name)
 	}
 
-	var host string
-	var port int
-	switch i.options.BackendType {
-	case backends.BackendTypeLlamaCpp:
-		if i.options.LlamaServerOptions != nil {
-			host = i.options.LlamaServerOptions.Host
-			port = i.options.LlamaServerOptions.Port
-		}
-	case backends.BackendTypeMlxLm:
-		if i.options.MlxServerOptions != nil {
-			host = i.options.MlxServerOptions.Host
-			port = i.options.MlxServerOptions.Port
-		}
-	case backends.BackendTypeVllm:
-		if i.options.VllmServerOptions != nil {
-			host = i.options.VllmServerOptions.Host
-			port = i.options.VllmServerOptions.Port
-		}
-	}
-
-	targetURL, err := url.Parse(fmt.Sprintf("http://%s:%d", host, port))
+	host, port := i.options.connectHost(i.bindAddress), i.options.port()
+	targetURL, err := url.Parse(fmt.Sprintf("%s://%s", i.options.BackendTLS.BackendScheme(), net.JoinHostPort(host, strconv.Itoa(port))))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse target URL for instance %s: %w", i.Name, err)
 	}
 
+	pool := newConnectionPool(i.backendTransport(), i.options, i.globalInstanceSettings.BackendIdleTimeout.Duration())
 	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	proxy.Transport = &statsTransport{next: pool, stats: i.stats, now: i.timeProvider.Now, onServerError: i.checkGPUFault}
+	if i.options.UsesManagedBackendKey() {
+		// The backend only accepts its own key, callers authenticated with llamactl's
+		director := proxy.Director
+		proxy.Director = func(req *http.Request) {
+			director(req)
+			if key := i.backendAuthKey(); key != "" {
+				req.Header.Set("Authorization", "Bearer "+key)
+			}
+		}
+	}
 
 	var responseHeaders map[string]string
 	switch i.options.BackendType {
@@ -220,20 +502,59 @@ func (i *Process) GetProxy() (*httputil.ReverseProxy, error) {
 		for key, value := range responseHeaders {
 			resp.Header.Set(key, value)
 		}
+		markStreaming(resp)
+		endStreamsOnTimeout(resp)
 		return nil
 	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(context.Cause(r.Context()), ErrRequestCancelled) {
+			http.Error(w, "Request cancelled by an operator", http.StatusServiceUnavailable)
+			return
+		}
+		if requestTimedOut(r) {
+			http.Error(w, "Request timed out: "+context.Cause(r.Context()).Error(), http.StatusGatewayTimeout)
+			return
+		}
+		if clientCancelled(r, err) {
+			// The client went away and the backend request was cancelled with it
+			return
+		}
+		if errors.Is(err, ErrBackendConnectionLimit) {
+			http.Error(w, "Backend connection limit reached, retry later", http.StatusServiceUnavailable)
+			return
+		}
+		log.Printf("Proxy error for instance %s: %v", i.Name, err)
+		w.WriteHeader(http.StatusBadGateway)
+	}
 
 	i.proxy = proxy
+	i.connections.Store(pool)
 
 	return i.proxy, nil
 }
 
-// MarshalJSON implements json.Marshaler for Instance
+// MarshalJSON implements json.Marshaler for Instance, masking the values of secret
+// environment variables
 func (i *Process) MarshalJSON() ([]byte, error) {
+	return i.marshalJSON(true)
+}
+
+// MarshalPersisted encodes the instance as MarshalJSON does, with the values of its secret
+// environment variables, for the store
+func (i *Process) MarshalPersisted() ([]byte, error) {
+	return i.marshalJSON(false)
+}
+
+func (i *Process) marshalJSON(maskSecrets bool) ([]byte, error) {
 	// Use read lock since we're only reading data
 	i.mu.RLock()
 	defer i.mu.RUnlock()
 
+	options, pendingOptions := i.options, i.pendingOptions
+	if maskSecrets {
+		options, pendingOptions = options.MaskedSecrets(), pendingOptions.MaskedSecrets()
+	}
+
 	// Determine if docker is enabled for this instance's backend
 	var dockerEnabled bool
 	if i.options != nil {
@@ -251,16 +572,140 @@ func (i *Process) MarshalJSON() ([]byte, error) {
 		}
 	}
 
+	var readiness *Readiness
+	var restartBudget *RestartBudget
+	var executable string
+	if i.options != nil && i.options.IsManaged() {
+		executable, _ = i.backendCommandFor(i.options)
+		readiness = i.readiness()
+		budget := i.restartBudget(i.timeProvider.Now())
+		restartBudget = &budget
+	}
+
+	// The backend listens where it should not, lost its GPU, or burns its error budget too fast
+	degraded := (i.ListenCheck != nil && i.ListenCheck.Degraded) || i.GPUFault != nil
+	if tracker := i.stats.slo.Load(); tracker != nil && tracker.degraded() {
+		degraded = true
+	}
+
+	// Start time and uptime of the current process
+	var startedAt *time.Time
+	var uptimeSeconds *int64
+	if i.IsRunning() && !i.startedAt.IsZero() {
+		started := i.startedAt.UTC()
+		uptime := int64(i.timeProvider.Now().Sub(i.startedAt) / time.Second)
+		startedAt, uptimeSeconds = &started, &uptime
+	}
+
+	// Last liveness check of the current process
+	var health *Health
+	if i.IsRunning() {
+		health = i.health
+	}
+
+	// Startup progress of the current process
+	var startupProgress *StartupProgress
+	if i.IsRunning() {
+		startupProgress = i.startupProgress
+	}
+
+	// Pause of the automatic restarts, by the instance or globally
+	restartPaused, restartPausedUntil := i.restartPaused(i.timeProvider.Now())
+
+	// Requests still in flight, which a draining instance waits for before stopping
+	var inFlight int
+	var binary *BinaryIdentity
+	var lastRequestAt *time.Time
+	if at := i.LastRequestAt(); !at.IsZero() {
+		at = at.UTC()
+		lastRequestAt = &at
+	}
+	if i.IsRunning() {
+		inFlight = i.requests.Count()
+		binary = i.binary
+	}
+
 	// Use anonymous struct to avoid recursion
 	type Alias Process
+	i.statusMu.RLock()
+	status, statusReason, stoppedReason, lastError := i.Status, i.StatusReason, i.StoppedReason, i.LastError
+	i.statusMu.RUnlock()
+
 	return json.Marshal(&struct {
 		*Alias
+		Status        InstanceStatus         `json:"status"`
+		StatusReason  *StatusReason          `json:"status_reason,omitempty"`
+		StoppedReason *StatusReason          `json:"stopped_reason,omitempty"`
+		LastError     *StatusReason          `json:"last_error,omitempty"`
 		Options       *CreateInstanceOptions `json:"options,omitempty"`
+		OptionSources OptionSources          `json:"option_sources,omitempty"`
 		DockerEnabled bool                   `json:"docker_enabled,omitempty"`
+		Executable    string                 `json:"executable,omitempty"` // Backend command the instance is started with
+		Readiness     *Readiness             `json:"readiness,omitempty"`
+		Degraded      bool                   `json:"degraded,omitempty"`
+		ManagedBy     string                 `json:"managed_by"`
+		Restarts      int                    `json:"restarts"`
+		RapidFailures int                    `json:"rapid_failures,omitempty"`
+		Restarting    bool                   `json:"restarting,omitempty"` // An automatic restart is due at next_restart_at
+		StartedAt     *time.Time             `json:"started_at,omitempty"`
+		UptimeSeconds *int64                 `json:"uptime_seconds,omitempty"`
+		RestartBudget *RestartBudget         `json:"restart_budget,omitempty"`
+		AllowedPaths  []string               `json:"allowed_paths"` // Effective allowed paths of the proxy
+		Health        *Health                `json:"health,omitempty"`
+		Draining      bool                   `json:"draining,omitempty"`
+		InFlight      int                    `json:"in_flight,omitempty"`
+		LastRequestAt *time.Time             `json:"last_request_at,omitempty"`
+		Binary        *BinaryIdentity        `json:"binary,omitempty"`
+
+		// Next start or stop of the start_schedule and stop_schedule
+		NextScheduledAction *ScheduledAction `json:"next_scheduled_action,omitempty"`
+
+		StartupProgress *StartupProgress `json:"startup_progress,omitempty"`
+
+		// Own pause of the automatic restarts, and the pause in effect with the global one
+		RestartPause           *RestartPause `json:"autorestart_pause,omitempty"`
+		AutoRestartPaused      bool          `json:"autorestart_paused,omitempty"`
+		AutoRestartPausedUntil *time.Time    `json:"autorestart_paused_until,omitempty"`
+
+		// Options set while running, applied on the next start
+		PendingOptions  *CreateInstanceOptions `json:"pending_options,omitempty"`
+		RestartRequired bool                   `json:"restart_required"`
 	}{
 		Alias:         (*Alias)(i),
-		Options:       i.options,
+		Status:        status,
+		StatusReason:  statusReason,
+		StoppedReason: stoppedReason,
+		LastError:     lastError,
+		Options:       options,
+		OptionSources: i.optionSources,
 		DockerEnabled: dockerEnabled,
+		Executable:    executable,
+		Readiness:     readiness,
+		Degraded:      degraded,
+		ManagedBy:     i.ManagedBy(),
+		Restarts:      i.restarts,
+		RapidFailures: i.rapidFailures,
+		Restarting:    i.NextRestartAt != nil,
+		StartedAt:     startedAt,
+		UptimeSeconds: uptimeSeconds,
+		RestartBudget: restartBudget,
+		AllowedPaths:  i.allowedPaths(),
+		Health:        health,
+		Draining:      i.drainStop != nil,
+		InFlight:      inFlight,
+		LastRequestAt: lastRequestAt,
+		Binary:        binary,
+
+		NextScheduledAction: i.nextScheduledAction(i.timeProvider.Now()),
+
+		StartupProgress: startupProgress,
+
+		RestartPause:           i.restartPause,
+		AutoRestartPaused:      restartPaused,
+		AutoRestartPausedUntil: restartPausedUntil,
+
+		PendingOptions:  pendingOptions,
+		RestartRequired: i.pendingOptions != nil,
 	})
 }
 
@@ -270,7 +715,11 @@ func (i *Process) UnmarshalJSON(data []byte) error {
 	type Alias Process
 	aux := &struct {
 		*Alias
-		Options *CreateInstanceOptions `json:"options,omitempty"`
+		Options       *CreateInstanceOptions `json:"options,omitempty"`
+		OptionSources OptionSources          `json:"option_sources,omitempty"`
+		// The process the options were pending for did not survive the save
+		PendingOptions *CreateInstanceOptions `json:"pending_options,omitempty"`
+		RestartPause   *RestartPause          `json:"autorestart_pause,omitempty"`
 	}{
 		Alias: (*Alias)(i),
 	}
@@ -278,11 +727,40 @@ func (i *Process) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, aux); err != nil {
 		return err
 	}
+	if aux.PendingOptions != nil {
+		aux.Options, aux.OptionSources = aux.PendingOptions, nil
+	}
+	// A restart pending when the instance was saved died with the process that scheduled it
+	i.NextRestartAt = nil
+	i.restartPause = aux.RestartPause
 
 	// Handle options with validation and defaults
 	if aux.Options != nil {
-		aux.Options.ValidateAndApplyDefaults(i.Name, i.globalInstanceSettings)
+		sources := aux.Options.ValidateAndApplyDefaults(i.Name, i.globalInstanceSettings)
+		// Persisted defaults are baked into the options, keep the sources they were merged from
+		for name, source := range aux.OptionSources {
+			if _, ok := sources[name]; ok {
+				sources[name] = source
+			}
+		}
 		i.options = aux.Options
+		i.optionSources = sources
+	}
+	i.unmanaged.Store(!i.options.IsManaged())
+
+	if i.stats == nil {
+		i.stats = NewProxyStats()
+	}
+	if i.options != nil {
+		i.stats.ConfigureSLO(i.options.SLO)
+	}
+	if i.operations == nil {
+		i.operations = NewOperationQueue(i.Name)
+	}
+	if i.admission == nil {
+		i.admission = NewAdmissionQueue(admissionLimits(i.options))
+	} else {
+		i.admission.SetLimits(admissionLimits(i.options))
 	}
 
 	return nil
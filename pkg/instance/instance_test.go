@@ -8,6 +8,7 @@ import (
 	"llamactl/pkg/instance"
 	"llamactl/pkg/testutil"
 	"testing"
+	"time"
 )
 
 func TestNewInstance(t *testing.T) {
@@ -30,7 +31,7 @@ func TestNewInstance(t *testing.T) {
 		LogsDir:             "/tmp/test",
 		DefaultAutoRestart:  true,
 		DefaultMaxRestarts:  3,
-		DefaultRestartDelay: 5,
+		DefaultRestartDelay: config.Duration(5 * time.Second),
 	}
 
 	options := &instance.CreateInstanceOptions{
@@ -42,7 +43,7 @@ func TestNewInstance(t *testing.T) {
 	}
 
 	// Mock onStatusChange function
-	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
 
@@ -69,8 +70,8 @@ func TestNewInstance(t *testing.T) {
 	if opts.MaxRestarts == nil || *opts.MaxRestarts != 3 {
 		t.Errorf("Expected MaxRestarts to be 3 (default), got %v", opts.MaxRestarts)
 	}
-	if opts.RestartDelay == nil || *opts.RestartDelay != 5 {
-		t.Errorf("Expected RestartDelay to be 5 (default), got %v", opts.RestartDelay)
+	if opts.RestartDelay == nil || opts.RestartDelay.Duration() != 5*time.Second {
+		t.Errorf("Expected RestartDelay to be 5s (default), got %v", opts.RestartDelay)
 	}
 }
 
@@ -94,13 +95,13 @@ func TestNewInstance_WithRestartOptions(t *testing.T) {
 		LogsDir:             "/tmp/test",
 		DefaultAutoRestart:  true,
 		DefaultMaxRestarts:  3,
-		DefaultRestartDelay: 5,
+		DefaultRestartDelay: config.Duration(5 * time.Second),
 	}
 
 	// Override some defaults
 	autoRestart := false
 	maxRestarts := 10
-	restartDelay := 15
+	restartDelay := config.Duration(15 * time.Second)
 
 	options := &instance.CreateInstanceOptions{
 		AutoRestart:  &autoRestart,
@@ -113,7 +114,7 @@ func TestNewInstance_WithRestartOptions(t *testing.T) {
 	}
 
 	// Mock onStatusChange function
-	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 	instance := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
 	opts := instance.GetOptions()
@@ -125,8 +126,8 @@ func TestNewInstance_WithRestartOptions(t *testing.T) {
 	if opts.MaxRestarts == nil || *opts.MaxRestarts != 10 {
 		t.Errorf("Expected MaxRestarts to be 10 (overridden), got %v", opts.MaxRestarts)
 	}
-	if opts.RestartDelay == nil || *opts.RestartDelay != 15 {
-		t.Errorf("Expected RestartDelay to be 15 (overridden), got %v", opts.RestartDelay)
+	if opts.RestartDelay == nil || opts.RestartDelay.Duration() != 15*time.Second {
+		t.Errorf("Expected RestartDelay to be 15s (overridden), got %v", opts.RestartDelay)
 	}
 }
 
@@ -150,7 +151,7 @@ func TestSetOptions(t *testing.T) {
 		LogsDir:             "/tmp/test",
 		DefaultAutoRestart:  true,
 		DefaultMaxRestarts:  3,
-		DefaultRestartDelay: 5,
+		DefaultRestartDelay: config.Duration(5 * time.Second),
 	}
 
 	initialOptions := &instance.CreateInstanceOptions{
@@ -162,7 +163,7 @@ func TestSetOptions(t *testing.T) {
 	}
 
 	// Mock onStatusChange function
-	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, initialOptions, mockOnStatusChange)
 
@@ -220,7 +221,7 @@ func TestGetProxy(t *testing.T) {
 	}
 
 	// Mock onStatusChange function
-	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
 
@@ -263,7 +264,7 @@ func TestMarshalJSON(t *testing.T) {
 		LogsDir:             "/tmp/test",
 		DefaultAutoRestart:  true,
 		DefaultMaxRestarts:  3,
-		DefaultRestartDelay: 5,
+		DefaultRestartDelay: config.Duration(5 * time.Second),
 	}
 
 	options := &instance.CreateInstanceOptions{
@@ -275,7 +276,7 @@ func TestMarshalJSON(t *testing.T) {
 	}
 
 	// Mock onStatusChange function
-	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 	instance := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
 
@@ -330,6 +331,60 @@ func TestMarshalJSON(t *testing.T) {
 	}
 }
 
+func TestMarshalJSON_Uptime(t *testing.T) {
+	inst := newShellInstance(t, "exec sleep 30", false, nil)
+	clock := NewMockTimeProvider(time.Date(2024, 6, 20, 12, 0, 0, 0, time.UTC))
+	inst.SetTimeProvider(clock)
+
+	type uptime struct {
+		Restarts      *int       `json:"restarts"`
+		StartedAt     *time.Time `json:"started_at"`
+		UptimeSeconds *int64     `json:"uptime_seconds"`
+	}
+	marshal := func() uptime {
+		t.Helper()
+		data, err := json.Marshal(inst)
+		if err != nil {
+			t.Fatalf("JSON marshal failed: %v", err)
+		}
+		var result uptime
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatalf("JSON unmarshal failed: %v", err)
+		}
+		return result
+	}
+
+	if stopped := marshal(); stopped.Restarts == nil || *stopped.Restarts != 0 || stopped.StartedAt != nil || stopped.UptimeSeconds != nil {
+		t.Errorf("Expected no restarts and no start time before starting, got %+v", stopped)
+	}
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	clock.SetTime(time.Date(2024, 6, 20, 12, 1, 30, 0, time.UTC))
+	running := marshal()
+	if running.StartedAt == nil || !running.StartedAt.Equal(time.Date(2024, 6, 20, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected started_at to be the start time, got %v", running.StartedAt)
+	}
+	if running.UptimeSeconds == nil || *running.UptimeSeconds != 90 {
+		t.Errorf("Expected an uptime of 90 seconds, got %v", running.UptimeSeconds)
+	}
+
+	// Instances reloaded with the fields are accepted
+	data, _ := json.Marshal(inst)
+	var reloaded instance.Process
+	if err := json.Unmarshal(data, &reloaded); err != nil {
+		t.Fatalf("JSON unmarshal of a marshaled instance failed: %v", err)
+	}
+
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if stopped := marshal(); stopped.StartedAt != nil || stopped.UptimeSeconds != nil {
+		t.Errorf("Expected the start time to be cleared on stop, got %+v", stopped)
+	}
+}
+
 func TestUnmarshalJSON(t *testing.T) {
 	jsonData := `{
 		"name": "test-instance",
@@ -382,32 +437,105 @@ func TestUnmarshalJSON(t *testing.T) {
 	}
 }
 
+func TestUnmarshalJSON_Durations(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations string
+		wantErr   bool
+	}{
+		// Definitions persisted before durations were accepted: seconds, idle_timeout in minutes
+		{name: "legacy integers", durations: `"restart_delay": 90, "idle_timeout": 30, "readiness_timeout": 600, "queue_timeout": 5, "min_uptime_seconds": 30, "slo": {"error_rate": 0.1, "window": 600, "warmup": 60}`},
+		{name: "duration strings", durations: `"restart_delay": "1m30s", "idle_timeout": "30m", "readiness_timeout": "10m", "queue_timeout": "5s", "min_uptime_seconds": "30s", "slo": {"error_rate": 0.1, "window": "10m", "warmup": "1m"}`},
+		{name: "invalid duration", durations: `"restart_delay": "soon"`, wantErr: true},
+		{name: "invalid idle timeout", durations: `"idle_timeout": "soon"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := `{"backend_type": "llama_cpp", "backend_options": {"model": "/path/to/model.gguf"}, ` + tt.durations + `}`
+			var opts instance.CreateInstanceOptions
+			err := json.Unmarshal([]byte(data), &opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected the invalid duration to be rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("JSON unmarshal failed: %v", err)
+			}
+
+			check := func(opts *instance.CreateInstanceOptions) {
+				t.Helper()
+				if opts.RestartDelay == nil || opts.RestartDelay.Duration() != 90*time.Second {
+					t.Errorf("Expected RestartDelay 1m30s, got %v", opts.RestartDelay)
+				}
+				if opts.IdleTimeout == nil || opts.IdleTimeout.Duration() != 30*time.Minute {
+					t.Errorf("Expected IdleTimeout 30m, got %v", opts.IdleTimeout)
+				}
+				if opts.ReadinessTimeout == nil || opts.ReadinessTimeout.Duration() != 10*time.Minute {
+					t.Errorf("Expected ReadinessTimeout 10m, got %v", opts.ReadinessTimeout)
+				}
+				if opts.QueueTimeout == nil || opts.QueueTimeout.Duration() != 5*time.Second {
+					t.Errorf("Expected QueueTimeout 5s, got %v", opts.QueueTimeout)
+				}
+				if opts.MinUptime == nil || opts.MinUptime.Duration() != 30*time.Second {
+					t.Errorf("Expected MinUptime 30s, got %v", opts.MinUptime)
+				}
+				if opts.SLO == nil || opts.SLO.Window.Duration() != 10*time.Minute || opts.SLO.Warmup.Duration() != time.Minute {
+					t.Errorf("Expected an SLO window of 10m and warm-up of 1m, got %+v", opts.SLO)
+				}
+			}
+			check(&opts)
+
+			// Durations are rendered as strings, which read back to the same values
+			out, err := json.Marshal(&opts)
+			if err != nil {
+				t.Fatalf("JSON marshal failed: %v", err)
+			}
+			var rendered map[string]any
+			if err := json.Unmarshal(out, &rendered); err != nil {
+				t.Fatalf("JSON unmarshal failed: %v", err)
+			}
+			slo, _ := rendered["slo"].(map[string]any)
+			if rendered["restart_delay"] != "1m30s" || rendered["idle_timeout"] != "30m" || rendered["min_uptime_seconds"] != "30s" || slo["window"] != "10m" {
+				t.Errorf("Expected durations rendered as strings, got %s", out)
+			}
+			var roundTrip instance.CreateInstanceOptions
+			if err := json.Unmarshal(out, &roundTrip); err != nil {
+				t.Fatalf("JSON unmarshal failed: %v", err)
+			}
+			check(&roundTrip)
+		})
+	}
+}
+
 func TestCreateInstanceOptionsValidation(t *testing.T) {
 	tests := []struct {
 		name          string
 		maxRestarts   *int
-		restartDelay  *int
+		restartDelay  *config.Duration
 		expectedMax   int
-		expectedDelay int
+		expectedDelay config.Duration
 	}{
 		{
 			name:          "valid positive values",
 			maxRestarts:   testutil.IntPtr(10),
-			restartDelay:  testutil.IntPtr(30),
+			restartDelay:  testutil.DurationPtr(30 * time.Second),
 			expectedMax:   10,
-			expectedDelay: 30,
+			expectedDelay: config.Duration(30 * time.Second),
 		},
 		{
 			name:          "zero values",
 			maxRestarts:   testutil.IntPtr(0),
-			restartDelay:  testutil.IntPtr(0),
+			restartDelay:  testutil.DurationPtr(0),
 			expectedMax:   0,
 			expectedDelay: 0,
 		},
 		{
 			name:          "negative values should be corrected",
 			maxRestarts:   testutil.IntPtr(-5),
-			restartDelay:  testutil.IntPtr(-10),
+			restartDelay:  testutil.DurationPtr(-10 * time.Second),
 			expectedMax:   0,
 			expectedDelay: 0,
 		},
@@ -444,7 +572,7 @@ func TestCreateInstanceOptionsValidation(t *testing.T) {
 			}
 
 			// Mock onStatusChange function
-			mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+			mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 			instance := instance.NewInstance("test", backendConfig, globalSettings, options, mockOnStatusChange)
 			opts := instance.GetOptions()
@@ -458,7 +586,7 @@ func TestCreateInstanceOptionsValidation(t *testing.T) {
 			if opts.RestartDelay == nil {
 				t.Error("Expected RestartDelay to be set")
 			} else if *opts.RestartDelay != tt.expectedDelay {
-				t.Errorf("Expected RestartDelay %d, got %d", tt.expectedDelay, *opts.RestartDelay)
+				t.Errorf("Expected RestartDelay %s, got %s", tt.expectedDelay, *opts.RestartDelay)
 			}
 		})
 	}
@@ -0,0 +1,84 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"llamactl/pkg/config"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RestartJitter is a random delay added to the restart delay of an instance, so instances
+// that crashed together do not all restart at the same instant. It is written either as a
+// percentage of the restart delay, e.g. "25%", or as a duration, e.g. "30s" or 30.
+type RestartJitter struct {
+	Percent float64       // Up to this percentage of the restart delay is added
+	Max     time.Duration // Up to this long is added, when Percent is 0
+}
+
+// ParseRestartJitter parses a percentage such as "25%" or a duration
+func ParseRestartJitter(value string) (RestartJitter, error) {
+	value = strings.TrimSpace(value)
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		p, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || p < 0 || p > 100 {
+			return RestartJitter{}, fmt.Errorf("invalid restart jitter %q: expected a percentage between 0%% and 100%%", value)
+		}
+		return RestartJitter{Percent: p}, nil
+	}
+
+	d, err := config.ParseDuration(value, time.Second)
+	if err != nil {
+		return RestartJitter{}, fmt.Errorf("invalid restart jitter: %w", err)
+	}
+	if d < 0 {
+		return RestartJitter{}, fmt.Errorf("invalid restart jitter %q: cannot be negative", value)
+	}
+	return RestartJitter{Max: d.Duration()}, nil
+}
+
+// String renders the jitter the way it is written
+func (j RestartJitter) String() string {
+	if j.Percent > 0 {
+		return strconv.FormatFloat(j.Percent, 'f', -1, 64) + "%"
+	}
+	return config.Duration(j.Max).String()
+}
+
+func (j RestartJitter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.String())
+}
+
+func (j *RestartJitter) UnmarshalJSON(data []byte) error {
+	var value string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	} else {
+		value = string(data)
+	}
+	parsed, err := ParseRestartJitter(value)
+	if err != nil {
+		return err
+	}
+	*j = parsed
+	return nil
+}
+
+// Apply returns delay with a random jitter of up to the configured amount added
+func (j *RestartJitter) Apply(delay time.Duration) time.Duration {
+	if j == nil {
+		return delay
+	}
+	spread := j.Max
+	if j.Percent > 0 {
+		spread = time.Duration(float64(delay) * j.Percent / 100)
+	}
+	if spread <= 0 {
+		return delay
+	}
+	return delay + rand.N(spread+1)
+}
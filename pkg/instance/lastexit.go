@@ -0,0 +1,72 @@
+package instance
+
+import (
+	"errors"
+	"log"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// lastExitOutputLines is the number of lines of output kept for the last crash
+const lastExitOutputLines = 50
+
+// LastExit describes the most recent crash of the backend. It is kept across restarts, and
+// persisted, until the next crash replaces it.
+type LastExit struct {
+	ExitCode  int        `json:"exit_code"` // -1 if the process was killed by a signal
+	Signal    string     `json:"signal,omitempty"`
+	Reason    ReasonCode `json:"reason"`
+	Message   string     `json:"message"`
+	Timestamp time.Time  `json:"timestamp"`
+	// Last lines the backend wrote to stdout and stderr, as logged
+	Output []string `json:"output"`
+	// The backend binary was replaced on disk while the process ran, e.g. by an upgrade
+	BinaryReplaced bool `json:"binary_replaced,omitempty"`
+}
+
+// exitStatus returns the exit code of a process from the result of cmd.Wait, along with the
+// signal that killed it, if any
+func exitStatus(err error) (int, syscall.Signal) {
+	if err == nil {
+		return 0, 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return -1, 0
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return exitErr.ExitCode(), status.Signal()
+	}
+	return exitErr.ExitCode(), 0
+}
+
+// recordLastExit records a crash of the current process (caller must hold the lock)
+func (i *Process) recordLastExit(err error, code ReasonCode, message string) {
+	exitCode, signal := exitStatus(err)
+	lastExit := &LastExit{
+		ExitCode:  exitCode,
+		Reason:    code,
+		Message:   message,
+		Timestamp: i.timeProvider.Now(),
+		Output:    i.outputTail.snapshot(),
+	}
+	if signal != 0 {
+		lastExit.Signal = signal.String()
+	}
+	if i.binary != nil && i.binary.replaced() {
+		lastExit.BinaryReplaced = true
+		log.Printf("Instance %s crashed after its backend binary %s was replaced", i.Name, i.binary.Path)
+	}
+	i.LastExit = lastExit
+	if observer := i.observer.Load(); observer != nil && observer.Crashed != nil {
+		observer.Crashed(*lastExit)
+	}
+}
+
+// GetLastExit returns the most recent crash of the backend, or nil if it never crashed
+func (i *Process) GetLastExit() *LastExit {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.LastExit
+}
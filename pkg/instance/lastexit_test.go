@@ -0,0 +1,60 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/instance"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLastExit(t *testing.T) {
+	// The first run crashes, the restarted one keeps running
+	marker := filepath.Join(t.TempDir(), "crashed")
+	script := "if [ -e " + marker + " ]; then exec sleep 30; fi; touch " + marker + "; echo loading model; echo 'CUDA out of memory' >&2; exit 3"
+	recorder := newTransitionRecorder()
+	inst := newShellInstance(t, script, true, recorder.record)
+	t.Cleanup(func() { inst.Stop() })
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if lastExit := inst.GetLastExit(); lastExit != nil {
+		t.Fatalf("Expected no last exit before a crash, got %+v", lastExit)
+	}
+
+	recorder.waitFor(t, instance.Restarting)
+	waitForStatus(t, inst, instance.Starting)
+
+	// Kept across the restart
+	lastExit := inst.GetLastExit()
+	if lastExit == nil {
+		t.Fatal("Expected the crash to be recorded")
+	}
+	if lastExit.ExitCode != 3 || lastExit.Signal != "" || lastExit.Reason != instance.ReasonCrash || lastExit.Timestamp.IsZero() {
+		t.Errorf("Expected exit code 3 with the crash reason, got %+v", lastExit)
+	}
+	if !slices.Contains(lastExit.Output, "loading model") || !slices.Contains(lastExit.Output, "CUDA out of memory") {
+		t.Errorf("Expected the output of both streams, got %q", lastExit.Output)
+	}
+
+	data, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatalf("JSON marshal failed: %v", err)
+	}
+	var marshaled struct {
+		LastExit *instance.LastExit `json:"last_exit"`
+	}
+	json.Unmarshal(data, &marshaled)
+	if marshaled.LastExit == nil || marshaled.LastExit.ExitCode != 3 {
+		t.Errorf("Expected last_exit in the instance JSON, got %s", data)
+	}
+
+	// Stopping is not a crash
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if after := inst.GetLastExit(); after == nil || !after.Timestamp.Equal(lastExit.Timestamp) {
+		t.Errorf("Expected a stop to keep the last crash, got %+v", after)
+	}
+}
@@ -0,0 +1,91 @@
+package instance
+
+// States of an instance reported to external load balancers
+const (
+	LBHealthy   = "healthy"   // Ready and able to take more work
+	LBDraining  = "draining"  // Finishing its requests before a recycle or a stop, takes no new work
+	LBUnhealthy = "unhealthy" // Not running, not ready, failing its health checks or saturated
+)
+
+// Reasons an instance is not reported healthy to load balancers
+const (
+	LBReasonNotRunning  = "not_running"
+	LBReasonNotReady    = "not_ready"
+	LBReasonHealthCheck = "health_check_failed"
+	LBReasonQueueDepth  = "queue_depth"
+	LBReasonInFlight    = "in_flight"
+	LBReasonDraining    = "draining"
+)
+
+// defaultLBQueueThreshold reports a backend unhealthy as soon as a request waits for a slot
+const defaultLBQueueThreshold = 1
+
+// LBHealthOptions set when an instance stops taking more work from external load balancers
+type LBHealthOptions struct {
+	QueueThreshold    int `json:"queue_threshold,omitempty"`     // Queued requests the instance is unhealthy at (default: 1)
+	InFlightThreshold int `json:"in_flight_threshold,omitempty"` // Requests in flight the instance is unhealthy at (default: unlimited)
+}
+
+func (o *LBHealthOptions) queueThreshold() int {
+	if o == nil || o.QueueThreshold <= 0 {
+		return defaultLBQueueThreshold
+	}
+	return o.QueueThreshold
+}
+
+func (o *LBHealthOptions) inFlightThreshold() int {
+	if o == nil {
+		return 0
+	}
+	return o.InFlightThreshold
+}
+
+// LBHealth is the state of an instance for external load balancers
+type LBHealth struct {
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+	QueueDepth int    `json:"queue_depth"`
+	InFlight   int    `json:"in_flight"`
+}
+
+// GetLBHealth returns the state of the instance for external load balancers, from its
+// cached state only: the backend is never probed. previous is the state returned by the
+// last call, empty on the first one, so that callers can report transitions.
+func (i *Process) GetLBHealth() (health LBHealth, previous string) {
+	queue := i.admission.Stats()
+	health = LBHealth{Status: LBUnhealthy, QueueDepth: queue.Queued, InFlight: queue.Active}
+
+	i.mu.RLock()
+	var lbOptions *LBHealthOptions
+	if i.options != nil {
+		lbOptions = i.options.LBHealth
+	}
+	switch {
+	case !i.IsRunning():
+		health.Reason = LBReasonNotRunning
+	case i.GetStatus() != Running:
+		health.Reason = LBReasonNotReady
+	case i.draining.Load() || i.drainStop != nil:
+		health.Status, health.Reason = LBDraining, LBReasonDraining
+	case i.health != nil && !i.health.Healthy:
+		health.Reason = LBReasonHealthCheck
+	case queue.Queued >= lbOptions.queueThreshold():
+		health.Reason = LBReasonQueueDepth
+	case lbOptions.inFlightThreshold() > 0 && queue.Active >= lbOptions.inFlightThreshold():
+		health.Reason = LBReasonInFlight
+	default:
+		health.Status = LBHealthy
+	}
+	i.mu.RUnlock()
+
+	if last := i.lbStatus.Swap(&health.Status); last != nil {
+		previous = *last
+	}
+	return health, previous
+}
+
+// SetDraining marks the instance as finishing its requests before a recycle, which load
+// balancers are told to send no new work to
+func (i *Process) SetDraining(draining bool) {
+	i.draining.Store(draining)
+}
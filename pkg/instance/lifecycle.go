@@ -2,12 +2,16 @@ package instance
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -17,87 +21,386 @@ import (
 
 // Start starts the llama server instance and returns an error if it fails.
 func (i *Process) Start() error {
+	return i.StartWithReason(ReasonUserStart, "")
+}
+
+// ErrAlreadyStarting is returned when starting an instance while another start of it is in progress
+var ErrAlreadyStarting = errors.New("instance is already starting")
+
+// StartWithReason starts the instance, recording the given reason for the transition to running.
+// Only one start of an instance runs at a time, any other one fails with ErrAlreadyStarting.
+// The lock is only held while the fields of the instance change: the log files, the pre-start
+// command and the spawn happen without it, so that the instance can be read meanwhile.
+func (i *Process) StartWithReason(code ReasonCode, message string) error {
+	return i.start(context.Background(), code, message)
+}
+
+// start is StartWithReason for an automatic restart or a queued start, which a stop cancels
+// through startCtx. It is checked under the lock the start begins with, so that a stop
+// returning before the start is never undone by it.
+func (i *Process) start(startCtx context.Context, code ReasonCode, message string) error {
 	i.mu.Lock()
-	defer i.mu.Unlock()
 
+	if i.closed {
+		i.mu.Unlock()
+		return fmt.Errorf("instance %s has been deleted", i.Name)
+	}
+	if startCtx.Err() != nil {
+		i.mu.Unlock()
+		return fmt.Errorf("start of instance %s was cancelled", i.Name)
+	}
+
+	// Checked before the status, which only becomes starting once the process was spawned
+	if i.startDone != nil {
+		i.mu.Unlock()
+		return fmt.Errorf("cannot start instance %s: %w", i.Name, ErrAlreadyStarting)
+	}
 	if i.IsRunning() {
+		i.mu.Unlock()
 		return fmt.Errorf("instance %s is already running", i.Name)
 	}
 
+	// Options set while the previous process was running take effect now
+	i.applyPendingOptions()
+
 	// Safety check: ensure options are valid
 	if i.options == nil {
+		i.mu.Unlock()
 		return fmt.Errorf("instance %s has no options set", i.Name)
 	}
 
+	if !i.options.IsManaged() {
+		i.mu.Unlock()
+		return fmt.Errorf("cannot start instance %s: %w", i.Name, ErrUnmanaged)
+	}
+
 	// Reset restart counter when manually starting (not during auto-restart)
 	// We can detect auto-restart by checking if restartCancel is set
 	if i.restartCancel == nil {
 		i.restarts = 0
+		i.crashes = 0
+		i.rapidFailures = 0
 	}
 
 	// Initialize last request time to current time when starting
 	i.lastRequestTime.Store(i.timeProvider.Now().Unix())
 
+	// The model files may have changed since the options were set
+	i.refreshModelSize()
+
+	// Resolve the interface the backend is restricted to
+	i.bindAddress = ""
+	i.ListenCheck = nil
+	if i.options.BindInterface != "" {
+		bindAddress, err := resolveBindAddress(i.options.BindInterface, i.options.bindHost())
+		if err != nil {
+			i.mu.Unlock()
+			return fmt.Errorf("failed to bind instance %s to %s: %w", i.Name, i.options.BindInterface, err)
+		}
+		i.bindAddress = bindAddress
+	}
+
 	// Create context before building command (needed for CommandContext)
-	i.ctx, i.cancel = context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	i.ctx, i.cancel = ctx, cancel
+
+	// A key rotated while the previous process ran applies from this one
+	if i.pendingBackendKey != nil {
+		i.backendKey = *i.pendingBackendKey
+		i.pendingBackendKey = nil
+	}
+
+	// Build command using backend-specific methods, reported once the log files exist
+	cmd, cmdErr := i.buildCommand()
+	backendCommand, _ := i.backendCommandFor(i.options)
+	wrapped := len(i.options.LaunchWrapper) > 0
+	onStart := i.lifecycleCommand(hookOnStart)
+
+	// Other starts are refused and stops wait until the process was spawned or the start failed
+	startDone := make(chan struct{})
+	i.startDone = startDone
+	i.mu.Unlock()
+	defer func() {
+		i.mu.Lock()
+		i.startDone = nil
+		close(startDone)
+		i.mu.Unlock()
+	}()
 
 	// Create log files
 	if err := i.logger.Create(); err != nil {
+		cancel()
 		return fmt.Errorf("failed to create log files: %w", err)
 	}
 
-	// Build command using backend-specific methods
-	cmd, cmdErr := i.buildCommand()
+	// Every failure from here on closes what was opened, so failed starts neither leak
+	// descriptors nor leave the log without a stop marker
+	var opened []io.Closer
+	fail := func(err error) error {
+		for _, f := range opened {
+			f.Close()
+		}
+		i.logger.CloseStartFailed(err)
+		cancel()
+		return err
+	}
+
+	// The pre-start command prepares what the backend needs, such as its model files
+	if onStart != nil {
+		if err := i.runLifecycleCommand(onStart); err != nil {
+			return fail(fmt.Errorf("failed to start instance %s: %w", i.Name, err))
+		}
+	}
+
 	if cmdErr != nil {
-		return fmt.Errorf("failed to build command: %w", cmdErr)
+		return fail(fmt.Errorf("failed to build command: %w", cmdErr))
+	}
+	if err := checkExecutable(backendCommand, wrapped); err != nil {
+		return fail(fmt.Errorf("failed to start instance %s: %w", i.Name, err))
 	}
-	i.cmd = cmd
 
+	detached := i.leaveRunning()
 	if runtime.GOOS != "windows" {
-		setProcAttrs(i.cmd)
+		if detached {
+			setDetachedProcAttrs(cmd)
+		} else {
+			setProcAttrs(cmd)
+		}
+	}
+
+	// A process left running when llamactl exits writes to files, which outlive llamactl,
+	// rather than to pipes. Unlike with StdoutPipe, whose read end Wait closes as soon as the
+	// process exits, the output the process wrote right before exiting is still read either way.
+	var output *processOutput
+	var stdoutWriter, stderrWriter *os.File
+	if detached {
+		var err error
+		stdoutWriter, stderrWriter, err = i.logger.createOutputFiles()
+		if err != nil {
+			return fail(err)
+		}
+		opened = append(opened, stdoutWriter, stderrWriter)
+		if output, err = i.logger.followOutput(new(int64), new(int64)); err != nil {
+			return fail(err)
+		}
+		opened = append(opened, output.stdout, output.stderr)
+	} else {
+		stdoutReader, writer, err := os.Pipe()
+		if err != nil {
+			return fail(fmt.Errorf("failed to get stdout pipe: %w", err))
+		}
+		stdoutWriter = writer
+		opened = append(opened, stdoutReader, stdoutWriter)
+		stderrReader, writer, err := os.Pipe()
+		if err != nil {
+			return fail(fmt.Errorf("failed to get stderr pipe: %w", err))
+		}
+		stderrWriter = writer
+		opened = append(opened, stderrReader, stderrWriter)
+		output = &processOutput{stdout: stdoutReader, stderr: stderrReader}
 	}
+	cmd.Stdout, cmd.Stderr = stdoutWriter, stderrWriter
 
-	var err error
-	i.stdout, err = i.cmd.StdoutPipe()
+	// Recorded to tell whether the binary is replaced while the process runs
+	binary, err := identifyBinary(backendCommand)
 	if err != nil {
-		i.logger.Close()
-		return fmt.Errorf("failed to get stdout pipe: %w", err)
+		log.Printf("Failed to read the backend binary of instance %s: %v", i.Name, err)
 	}
-	i.stderr, err = i.cmd.StderrPipe()
+
+	err = cmd.Start()
 	if err != nil {
-		i.stdout.Close()
-		i.logger.Close()
-		return fmt.Errorf("failed to get stderr pipe: %w", err)
+		return fail(fmt.Errorf("failed to start instance %s: %w", i.Name, err))
 	}
+	// The process has its own copies of the write ends
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if err := attachProcessGroup(cmd); err != nil {
+		log.Printf("Failed to track the process tree of instance %s: %v", i.Name, err)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
 
-	if err := i.cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start instance %s: %w", i.Name, err)
+	// Deleted while the process was spawned, nothing would ever stop it
+	if i.closed {
+		killProcessGroup(cmd)
+		cmd.Wait()
+		releaseProcessGroup(cmd)
+		return fail(fmt.Errorf("instance %s has been deleted", i.Name))
 	}
 
-	i.SetStatus(Running)
+	i.cmd = cmd
+	i.binary = binary
+	i.SetStatus(Starting, code, message)
+	i.resetRun()
+	i.startedAt = i.timeProvider.Now()
+
+	if detached {
+		// Recorded for the next llamactl to adopt the process, should this one exit
+		i.processState = &processState{PID: cmd.Process.Pid, Port: i.options.port(), BindAddress: i.bindAddress, StartedAt: i.startedAt}
+		i.detach = make(chan struct{})
+		if err := i.logger.writeProcessState(i.processState); err != nil {
+			log.Printf("Failed to record the process of instance %s: %v", i.Name, err)
+		}
+	}
+	i.watchProcess(output, cmd.Wait)
 
-	// Create channel for monitor completion signaling
-	i.monitorDone = make(chan struct{})
+	return nil
+}
+
+// resetRun resets what is tracked about the current process for a new one (caller must hold
+// the lock)
+func (i *Process) resetRun() {
+	i.stats.startWarmup(i.timeProvider.Now())
+	i.fatalLogLine = ""
+	i.sentSignal = 0
+	i.notReady = ""
+	i.unhealthy = ""
+	i.health = nil
+	i.fatalLog.arm()
+	i.GPUFault = nil
+	i.gpuFaultKilled = false
+	i.ConfigVerification = nil
+	i.gpuFault.arm()
+	i.healthy = false
+	i.readyAt = time.Time{}
+	i.resetStartupProgress()
+	i.armModelWatch()
+	i.stderrTail.release()
+	i.outputTail.release()
+	i.stderrTail = newOutputTail(i.memory, i.Name, memoryStderrTail, startFailureOutputLines)
+	i.outputTail = newOutputTail(i.memory, i.Name, memoryOutputTail, lastExitOutputLines)
+}
 
-	go i.logger.readOutput(i.stdout)
-	go i.logger.readOutput(i.stderr)
+// watchProcess starts the goroutines reading the output of the current process into the
+// logs and watching it until wait returns (caller must hold the lock)
+func (i *Process) watchProcess(output *processOutput, wait func() error) {
+	i.forwardOutput = new(atomic.Bool)
+	i.forwardOutput.Store(true)
+	i.readProcessOutput(output, i.stderrTail, i.outputTail, i.forwardOutput)
+	i.monitorRun(output, wait)
+}
 
-	go i.monitorProcess()
+// readProcessOutput starts the goroutines reading the output of a process into the logs and
+// the tails. Its lines are only seen by the detectors of the current process while current
+// is true.
+func (i *Process) readProcessOutput(output *processOutput, stderrTail, outputTail *outputTail, current *atomic.Bool) {
+	output.readers.Add(2)
+	i.goroutines.Go(func() {
+		defer output.readers.Done()
+		i.logger.readOutputOf(output.stdout, LogStreamStdout, current, outputTail)
+	})
+	i.goroutines.Go(func() {
+		defer output.readers.Done()
+		i.logger.readOutputOf(output.stderr, LogStreamStderr, current, stderrTail, outputTail)
+	})
+}
 
-	return nil
+// monitorRun starts the goroutines watching the current process, whose output is read,
+// until wait returns (caller must hold the lock)
+func (i *Process) monitorRun(output *processOutput, wait func() error) {
+	// Create channel for monitor completion signaling
+	i.monitorDone = make(chan struct{})
+	i.stdout, i.stderr = output.stdout, output.stderr
+
+	monitorDone, detach, state := i.monitorDone, i.detach, i.processState
+	i.goroutines.Go(func() { i.monitorProcess(monitorDone, output, wait, detach, state) })
+	i.goroutines.Go(func() { i.watchReadiness(monitorDone) })
+	if i.bindAddress != "" {
+		cmd, bindAddress, port := i.cmd, i.bindAddress, i.options.port()
+		i.goroutines.Go(func() { i.verifyListen(cmd, bindAddress, port, monitorDone) })
+	}
+	if snapshot := i.pendingSlots; snapshot != nil {
+		i.pendingSlots = nil
+		i.goroutines.Go(func() { i.restoreSlots(snapshot, monitorDone) })
+	}
 }
 
 // Stop terminates the subprocess
 func (i *Process) Stop() error {
+	return i.StopWithReason(ReasonUserStop, "")
+}
+
+// StopWithReason terminates the subprocess, recording the given reason for the transition to stopped.
+func (i *Process) StopWithReason(code ReasonCode, message string) error {
+	return i.stop(code, message, false)
+}
+
+// ErrNotStarting is returned when cancelling the start of an instance that is not starting
+var ErrNotStarting = errors.New("instance is not starting")
+
+// CancelStart aborts the start of an instance that is loading its model, queued for a
+// loading slot or waiting to be restarted. A loading backend serves nothing yet, so it is
+// killed right away instead of being given its stop timeout. No automatic restart follows.
+func (i *Process) CancelStart() error {
+	return i.stop(ReasonUserStop, "start cancelled", true)
+}
+
+// stop terminates the subprocess, or only one that is still starting with cancelStart
+func (i *Process) stop(code ReasonCode, message string, cancelStart bool) error {
 	i.mu.Lock()
 
+	// A start in progress is let spawn its process, which is then stopped
+	for i.startDone != nil {
+		startDone := i.startDone
+		i.mu.Unlock()
+		<-startDone
+		i.mu.Lock()
+	}
+
+	if !i.options.IsManaged() {
+		i.mu.Unlock()
+		return fmt.Errorf("cannot stop instance %s: %w", i.Name, ErrUnmanaged)
+	}
+	if status := i.GetStatus(); cancelStart && status != Starting && status != Queued && status != Restarting {
+		i.mu.Unlock()
+		return fmt.Errorf("cannot cancel the start of instance %s, it is %s: %w", i.Name, status, ErrNotStarting)
+	}
+
+	// A reload in progress gives up its replacement, the current process is the one stopped
+	if i.reloadCancel != nil {
+		i.reloadCancel()
+	}
+
+	// The requests in flight are given the drain timeout to complete before the process is
+	// signalled, new ones being turned away meanwhile. A stop while draining stops right away.
+	if i.drainStop != nil {
+		close(i.drainStop)
+		i.drainStop = nil
+	} else if timeout := i.drainTimeout(); !cancelStart && i.GetStatus() == Running && timeout > 0 && i.requests.Count() > 0 {
+		stopNow := make(chan struct{})
+		i.drainStop = stopNow
+		exited := i.monitorDone
+		i.mu.Unlock()
+		cut := i.drain(timeout, exited, stopNow)
+		i.mu.Lock()
+		if cut {
+			// Stopped by the stop that cut the drain short
+			i.mu.Unlock()
+			return nil
+		}
+		i.drainStop = nil
+	}
+
 	if !i.IsRunning() {
 		// Even if not running, cancel any pending restart
 		if i.restartCancel != nil {
 			i.restartCancel()
 			i.restartCancel = nil
+			i.NextRestartAt = nil
 			log.Printf("Cancelled pending restart for instance %s", i.Name)
 		}
+		if i.queueCancel != nil {
+			i.queueCancel()
+			i.queueCancel = nil
+		}
+		// A queued instance is stopped by giving up its place in the queue, and one waiting
+		// to be restarted by cancelling the restart
+		if status := i.GetStatus(); status == Queued || status == Restarting {
+			i.SetStatus(Stopped, code, message)
+			i.mu.Unlock()
+			return nil
+		}
 		i.mu.Unlock()
 		return fmt.Errorf("instance %s is not running", i.Name)
 	}
@@ -109,40 +412,54 @@ func (i *Process) Stop() error {
 	}
 
 	// Set status to stopped first to signal intentional stop
-	i.SetStatus(Stopped)
+	i.SetStatus(Stopped, code, message)
+	i.startedAt = time.Time{}
 
 	// Clean up the proxy
-	i.proxy = nil
+	i.resetProxy()
 
-	// Get the monitor done channel before releasing the lock
+	// Get the monitor done channel, stop timeout and post-stop command before releasing the lock
 	monitorDone := i.monitorDone
+	stopTimeout := i.stopTimeout()
+	onStop := i.lifecycleCommand(hookOnStop)
 
 	i.mu.Unlock()
 
-	// Stop the process with SIGINT if cmd exists
+	// Stop the process with SIGTERM if cmd exists. The signal goes to the whole process group
+	// so that a backend started through a launch wrapper is stopped along with the wrapper.
 	if i.cmd != nil && i.cmd.Process != nil {
-		if err := i.cmd.Process.Signal(syscall.SIGINT); err != nil {
-			log.Printf("Failed to send SIGINT to instance %s: %v", i.Name, err)
+		if cancelStart {
+			if err := killProcessGroup(i.cmd); err != nil {
+				log.Printf("Failed to kill starting instance %s: %v", i.Name, err)
+			}
+		} else if err := signalProcessGroup(i.cmd, syscall.SIGTERM); err != nil {
+			log.Printf("Failed to send SIGTERM to instance %s: %v", i.Name, err)
 		}
 	}
 
 	// If no process exists, we can return immediately
 	if i.cmd == nil || monitorDone == nil {
+		if i.cmd != nil {
+			releaseProcessGroup(i.cmd)
+		}
 		i.logger.Close()
+		i.joinGoroutines()
 		return nil
 	}
 
 	select {
 	case <-monitorDone:
-		// Process exited normally
-	case <-time.After(30 * time.Second):
-		// Force kill if it doesn't exit within 30 seconds
+		// Process exited normally. Children of a launch wrapper may outlive it, for example
+		// background jobs of a shell, which may ignore SIGTERM, so kill whatever is left of the group.
+		killProcessGroup(i.cmd)
+	case <-time.After(stopTimeout):
+		// Force kill the whole group if it doesn't exit within the stop timeout
 		if i.cmd != nil && i.cmd.Process != nil {
-			killErr := i.cmd.Process.Kill()
+			killErr := killProcessGroup(i.cmd)
 			if killErr != nil {
 				log.Printf("Failed to force kill instance %s: %v", i.Name, killErr)
 			}
-			log.Printf("Instance %s did not stop in time, force killed", i.Name)
+			log.Printf("Instance %s did not stop within %s, force killed", i.Name, config.Duration(stopTimeout))
 
 			// Wait a bit more for the monitor to finish after force kill
 			select {
@@ -154,61 +471,216 @@ func (i *Process) Stop() error {
 		}
 	}
 
+	// Killed processes of the group may hold the port of the backend until they are gone
+	if !waitProcessGroupExit(i.cmd, processGroupExitTimeout) {
+		log.Printf("Warning: processes of instance %s were still running %s after they were killed", i.Name, processGroupExitTimeout)
+	}
+	releaseProcessGroup(i.cmd)
+
+	// Cleans up after the backend, a failure not failing the stop
+	i.runOnStopCommand(onStop)
 	i.logger.Close()
 
+	// Output readers exit once the process has exited and its pipes are closed
+	i.joinGoroutines()
+
 	return nil
 }
 
+// defaultStopTimeout is the stop timeout when neither the instance nor the configuration sets one
+const defaultStopTimeout = 30 * time.Second
+
+// processGroupExitTimeout is how long Stop waits for the processes of a killed group to exit
+const processGroupExitTimeout = 5 * time.Second
+
+// waitProcessGroupExit waits up to timeout for every process of the group led by the
+// command to exit, reporting whether they did
+func waitProcessGroupExit(cmd *exec.Cmd, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for processGroupAlive(cmd) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+// drainInterval is how often the requests in flight on a draining instance are checked
+const drainInterval = 100 * time.Millisecond
+
+// stopTimeout returns the time a stopping backend is given to exit before it is killed: the
+// stop_timeout option if set, otherwise default_stop_timeout (caller must hold the lock)
+func (i *Process) stopTimeout() time.Duration {
+	if i.options != nil && i.options.StopTimeout != nil && *i.options.StopTimeout > 0 {
+		return i.options.StopTimeout.Duration()
+	}
+	if i.globalInstanceSettings != nil && i.globalInstanceSettings.DefaultStopTimeout > 0 {
+		return i.globalInstanceSettings.DefaultStopTimeout.Duration()
+	}
+	return defaultStopTimeout
+}
+
+// drainTimeout returns the time a stopping backend is given to complete the requests in
+// flight: the drain_timeout option if set, otherwise default_drain_timeout (caller must hold
+// the lock)
+func (i *Process) drainTimeout() time.Duration {
+	if i.options != nil && i.options.DrainTimeout != nil {
+		return i.options.DrainTimeout.Duration()
+	}
+	if i.globalInstanceSettings != nil {
+		return i.globalInstanceSettings.DefaultDrainTimeout.Duration()
+	}
+	return 0
+}
+
+// drain waits for the requests in flight to complete, for at most timeout. It gives up once
+// the process whose monitor closes exited exits, and reports whether stopNow was closed by
+// another stop meanwhile.
+func (i *Process) drain(timeout time.Duration, exited <-chan struct{}, stopNow <-chan struct{}) bool {
+	message := fmt.Sprintf("draining %d requests in flight before stopping, for at most %s", i.requests.Count(), config.Duration(timeout))
+	log.Printf("Instance %s %s", i.Name, message)
+	i.logger.writeLine(message)
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+	for i.requests.Count() > 0 {
+		select {
+		case <-stopNow:
+			return true
+		case <-exited:
+			return false
+		case <-deadline:
+			log.Printf("Instance %s still had %d requests in flight after %s, stopping it", i.Name, i.requests.Count(), config.Duration(timeout))
+			return false
+		case <-ticker.C:
+		}
+	}
+	return false
+}
+
+// IsDraining reports whether the instance is stopping once its requests in flight completed,
+// taking no new ones meanwhile
+func (i *Process) IsDraining() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.drainStop != nil
+}
+
 func (i *Process) LastRequestTime() int64 {
 	return i.lastRequestTime.Load()
 }
 
-func (i *Process) WaitForHealthy(timeout int) error {
-	if !i.IsRunning() {
+// WaitForHealthy waits up to timeout, or the readiness timeout of the instance if timeout
+// is 0, for the backend to pass its health check. A backend that is still loading or
+// has not opened its port yet is waited on, but one whose process exits fails right away.
+func (i *Process) WaitForHealthy(timeout time.Duration) error {
+	i.mu.RLock()
+	running, exited := i.IsRunning(), i.monitorDone
+	if timeout <= 0 {
+		timeout = i.readiness().Timeout.Duration()
+	}
+	i.mu.RUnlock()
+
+	if !running {
 		return fmt.Errorf("instance %s is not running", i.Name)
 	}
 
-	if timeout <= 0 {
-		timeout = 30 // Default to 30 seconds if no timeout is specified
+	if err := i.waitForHealthy(timeout, exited); err != nil {
+		return err
 	}
+	i.markReady(exited)
+	return nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
-	defer cancel()
+// watchReadiness moves the instance from starting to running once the process whose
+// monitor closes exited passes a readiness check, then keeps checking its liveness if it
+// has a health_check. A process that does not pass one within its readiness timeout is
+// killed, and the monitor restarts it per its policy.
+func (i *Process) watchReadiness(exited chan struct{}) {
+	if _, err := i.healthURL(); err != nil {
+		log.Printf("Cannot check the readiness of instance %s: %v", i.Name, err)
+		return
+	}
+	i.mu.RLock()
+	timeout := i.readiness().Timeout
+	i.mu.RUnlock()
+
+	err := i.waitForHealthy(timeout.Duration(), exited)
+	if err == nil {
+		i.markReady(exited)
+		i.verifyConfig(exited)
+		i.watchLiveness(exited)
+		return
+	}
+	select {
+	case <-exited:
+		return
+	default:
+	}
 
-	// Get instance options to build the health check URL
-	opts := i.GetOptions()
-	if opts == nil {
-		return fmt.Errorf("instance %s has no options set", i.Name)
+	i.mu.Lock()
+	if i.monitorDone != exited || i.GetStatus() != Starting || i.cmd == nil || i.cmd.Process == nil {
+		i.mu.Unlock()
+		return
 	}
+	i.notReady = fmt.Sprintf("no passing readiness check within %s", timeout)
+	cmd := i.cmd
+	i.mu.Unlock()
 
-	// Build the health check URL directly
-	var host string
-	var port int
-	switch opts.BackendType {
-	case backends.BackendTypeLlamaCpp:
-		if opts.LlamaServerOptions != nil {
-			host = opts.LlamaServerOptions.Host
-			port = opts.LlamaServerOptions.Port
-		}
-	case backends.BackendTypeMlxLm:
-		if opts.MlxServerOptions != nil {
-			host = opts.MlxServerOptions.Host
-			port = opts.MlxServerOptions.Port
-		}
-	case backends.BackendTypeVllm:
-		if opts.VllmServerOptions != nil {
-			host = opts.VllmServerOptions.Host
-			port = opts.VllmServerOptions.Port
-		}
+	log.Printf("Instance %s did not become ready within %s, killing it", i.Name, timeout)
+	if err := killProcessGroup(cmd); err != nil {
+		log.Printf("Failed to kill instance %s after its readiness timeout: %v", i.Name, err)
 	}
-	if host == "" {
-		host = "localhost"
+}
+
+// markReady records that the process whose monitor closes exited passed a readiness check,
+// so it is no longer starting
+func (i *Process) markReady(exited chan struct{}) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.monitorDone != exited {
+		return
+	}
+	i.healthy = true
+	i.readyAt = i.timeProvider.Now()
+	i.StartFailure = nil
+	ready := 100
+	i.startupProgress = &StartupProgress{Phase: StartupPhaseReady, Percent: &ready}
+	if i.GetStatus() == Starting {
+		i.SetStatus(Running, ReasonHealthProbeSuccess, "backend passed its readiness check")
+	}
+}
+
+// waitForHealthy polls the health check of the backend until it passes, the timeout
+// expires or exited is closed
+func (i *Process) waitForHealthy(timeout time.Duration, exited chan struct{}) error {
+	healthURL, err := i.healthURL()
+	if err != nil {
+		return err
+	}
+
+	err = i.pollHealth(context.Background(), healthURL, i.BackendTransport(), timeout, exited)
+	if errors.Is(err, errExitedBeforeHealthy) {
+		return i.exitedBeforeHealthy()
 	}
-	healthURL := fmt.Sprintf("http://%s:%d/health", host, port)
+	return err
+}
+
+// errExitedBeforeHealthy is returned by pollHealth when the process exits first
+var errExitedBeforeHealthy = errors.New("process exited before becoming healthy")
+
+// pollHealth polls healthURL through transport until it responds 200 OK, the timeout
+// expires, ctx is done or exited is closed
+func (i *Process) pollHealth(parent context.Context, healthURL string, transport http.RoundTripper, timeout time.Duration, exited <-chan struct{}) error {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
 
 	// Create a dedicated HTTP client for health checks
 	client := &http.Client{
-		Timeout: 5 * time.Second, // 5 second timeout per request
+		Transport: transport,
+		Timeout:   5 * time.Second, // 5 second timeout per request
 	}
 
 	// Helper function to check health directly
@@ -233,13 +705,21 @@ func (i *Process) WaitForHealthy(timeout int) error {
 	}
 
 	// If immediate check failed, start polling
-	ticker := time.NewTicker(1 * time.Second)
+	i.mu.RLock()
+	interval := i.probeInterval()
+	i.mu.RUnlock()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("timeout waiting for instance %s to become healthy after %d seconds", i.Name, timeout)
+			if err := context.Cause(parent); err != nil {
+				return err
+			}
+			return fmt.Errorf("timeout waiting for instance %s to become healthy after %s", i.Name, config.Duration(timeout))
+		case <-exited:
+			return errExitedBeforeHealthy
 		case <-ticker.C:
 			if checkHealth() {
 				return nil // Instance is healthy
@@ -249,27 +729,101 @@ func (i *Process) WaitForHealthy(timeout int) error {
 	}
 }
 
-func (i *Process) monitorProcess() {
+// exitedBeforeHealthy describes the exit of a backend that never became healthy
+func (i *Process) exitedBeforeHealthy() error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	stopped := i.GetStoppedReason()
+	if stopped != nil && stopped.Code == ReasonUserStop {
+		return fmt.Errorf("instance %s was stopped while starting", i.Name)
+	}
+	if i.StartFailure != nil && stopped != nil {
+		return &StartFailureError{Name: i.Name, Message: stopped.Message, Failure: i.StartFailure}
+	}
+	if stopped != nil && stopped.Message != "" {
+		return fmt.Errorf("instance %s exited before becoming healthy: %s", i.Name, stopped.Message)
+	}
+	return fmt.Errorf("instance %s exited before becoming healthy", i.Name)
+}
+
+// monitorProcess waits for the process to exit and handles restarts. done belongs to this
+// monitor only, since an auto-restart from here starts a new monitor with its own channel.
+// A process spawned with leave_running has a state, and is let go of once detach is closed.
+func (i *Process) monitorProcess(done chan struct{}, output *processOutput, wait func() error, detach <-chan struct{}, state *processState) {
 	defer func() {
 		i.mu.Lock()
-		if i.monitorDone != nil {
-			close(i.monitorDone)
+		close(done)
+		if i.monitorDone == done {
 			i.monitorDone = nil
 		}
 		i.mu.Unlock()
 	}()
 
-	err := i.cmd.Wait()
+	detached, err := waitOrDetach(wait, detach)
+	output.drain()
 
 	i.mu.Lock()
+	if detached {
+		i.leaveDetached(state, output)
+		i.mu.Unlock()
+		return
+	}
+	i.clearProcessState(state)
+	if i.detach == detach {
+		i.detach = nil
+	}
+
+	// Replaced by the process of a reload, which the instance runs on
+	if i.monitorDone != done {
+		i.mu.Unlock()
+		return
+	}
 
 	// Check if the instance was intentionally stopped
 	if !i.IsRunning() {
 		i.mu.Unlock()
 		return
 	}
-
-	i.SetStatus(Stopped)
+	// Whatever the process started is not left holding the port of its restart, where the
+	// platform tracks process trees rather than groups
+	releaseProcessGroup(i.cmd)
+
+	code, message := exitReason(err)
+	var startFailure *StartFailure
+	if err != nil && i.starting() {
+		// Failed while loading, typically because the model does not fit in memory
+		startFailure, message = i.startFailure(err)
+		i.StartFailure = startFailure
+	}
+	if i.fatalLogLine != "" {
+		// Killed after logging a fatal error
+		code, message = ReasonFatalLog, "fatal error in log: "+i.fatalLogLine
+		i.fatalLogLine = ""
+	}
+	if i.gpuFaultKilled && i.GPUFault != nil {
+		// Killed after failing requests with GPU errors
+		code, message = ReasonGPUFault, "GPU fault: "+i.GPUFault.Sample
+		i.gpuFaultKilled = false
+	}
+	if code == ReasonOOMKill && i.sentSignal == syscall.SIGKILL {
+		// Killed through the API, not by the OOM killer
+		code, message = ReasonCrash, "process was killed by SIGKILL sent through the API"
+	}
+	if i.notReady != "" {
+		// Killed after its readiness timeout
+		code, message = ReasonHealthProbeFailure, i.notReady
+		i.notReady = ""
+	}
+	if i.unhealthy != "" {
+		// Killed after failing its liveness checks
+		code, message = ReasonHealthProbeFailure, i.unhealthy
+		i.unhealthy = ""
+	}
+	// Counted before the start time is cleared, for crashes only
+	rapidFailures := i.rapidFailuresAt(i.timeProvider.Now())
+	i.startedAt = time.Time{}
+	// Cleans up after the backend, before it is restarted
+	i.runOnStopCommand(i.lifecycleCommand(hookOnStop))
 	i.logger.Close()
 
 	// Cancel any existing restart context since we're handling a new exit
@@ -279,29 +833,93 @@ func (i *Process) monitorProcess() {
 	}
 
 	// Log the exit
-	if err != nil {
-		log.Printf("Instance %s crashed with error: %v", i.Name, err)
+	if startFailure != nil && startFailure.AllocationFailure {
+		// Restarting would run out of memory again
+		log.Printf("Instance %s failed to allocate memory while starting, not restarting: %s", i.Name, message)
+		i.recordLastExit(err, code, message)
+		i.SetStatus(Stopped, code, message)
+		i.SetStatus(Failed, code, message)
+		i.mu.Unlock()
+	} else if err != nil || code == ReasonFatalLog || code == ReasonGPUFault || code == ReasonHealthProbeFailure {
+		log.Printf("Instance %s crashed: %s", i.Name, message)
+		i.rapidFailures = rapidFailures
+		i.recordLastExit(err, code, message)
 		// Handle restart while holding the lock, then release it
-		i.handleRestart()
+		i.handleRestart(code, message)
 	} else {
 		log.Printf("Instance %s exited cleanly", i.Name)
+		i.SetStatus(Stopped, code, message)
 		i.mu.Unlock()
 	}
 }
 
-// handleRestart manages the restart process while holding the lock
-func (i *Process) handleRestart() {
+// drainOutput waits for the output of an exited process to be read. Children that outlive
+// the process keep the pipes open, so after outputDrainTimeout they are closed instead.
+func drainOutput(output *sync.WaitGroup, pipes []io.Closer) {
+	drained := make(chan struct{})
+	go func() {
+		output.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(outputDrainTimeout):
+		for _, pipe := range pipes {
+			pipe.Close()
+		}
+		<-drained
+	}
+}
+
+// exitReason classifies the result of cmd.Wait into a reason code and a human readable message
+func exitReason(err error) (ReasonCode, string) {
+	if err == nil {
+		return ReasonCleanExit, "process exited with code 0"
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() && status.Signal() == syscall.SIGKILL {
+			// llamactl only sends SIGKILL when a stop times out, which happens after the status
+			// has already been set to stopped, so an unexpected SIGKILL is most likely the OOM killer
+			return ReasonOOMKill, "process was killed by SIGKILL (possibly out of memory)"
+		}
+	}
+
+	return ReasonCrash, err.Error()
+}
+
+// handleRestart manages the restart process while holding the lock. The instance is
+// restarting with the exit reason until the restart delay elapsed, or stopped and then
+// failed with it when it is not restarted.
+func (i *Process) handleRestart(exitCode ReasonCode, exitMessage string) {
 	// Validate restart conditions and get safe parameters
 	shouldRestart, maxRestarts, restartDelay := i.validateRestartConditions()
+	restartDelay = i.options.RestartJitter.Apply(max(i.backoff(restartDelay), i.rapidFailureDelay(i.rapidFailures)))
 	if !shouldRestart {
-		i.SetStatus(Failed)
+		i.SetStatus(Stopped, exitCode, exitMessage)
+		if i.crashLooping() {
+			i.SetStatus(Failed, ReasonCrashLoop, fmt.Sprintf("crashed within %v of starting %d times in a row", i.minUptime(), i.rapidFailures))
+		} else if i.exceededMaxRestarts() {
+			i.SetStatus(Failed, ReasonMaxRestartsExceeded, fmt.Sprintf("exceeded max restart attempts (%d)", *i.options.MaxRestarts))
+		} else {
+			i.SetStatus(Failed, exitCode, exitMessage)
+		}
 		i.mu.Unlock()
 		return
 	}
 
 	i.restarts++
+	i.crashes++
+	nextRestartAt := i.timeProvider.Now().Add(restartDelay)
+	i.NextRestartAt = &nextRestartAt
+	i.SetStatus(Restarting, exitCode, exitMessage)
+	if observer := i.observer.Load(); observer != nil && observer.RestartScheduled != nil {
+		observer.RestartScheduled(i.restarts, nextRestartAt)
+	}
 	log.Printf("Auto-restarting instance %s (attempt %d/%d) in %v",
-		i.Name, i.restarts, maxRestarts, time.Duration(restartDelay)*time.Second)
+		i.Name, i.restarts, maxRestarts, restartDelay)
 
 	// Create a cancellable context for the restart delay
 	restartCtx, cancel := context.WithCancel(context.Background())
@@ -311,63 +929,152 @@ func (i *Process) handleRestart() {
 	i.mu.Unlock()
 
 	// Use context-aware sleep so it can be cancelled
+	cancelled := false
 	select {
-	case <-time.After(time.Duration(restartDelay) * time.Second):
+	case <-i.after(restartDelay):
 		// Sleep completed normally, continue with restart
 	case <-restartCtx.Done():
 		// Restart was cancelled
 		log.Printf("Restart cancelled for instance %s", i.Name)
+		cancelled = true
+	}
+	i.mu.Lock()
+	if i.NextRestartAt == &nextRestartAt {
+		i.NextRestartAt = nil
+	}
+	// A stop as the delay expired cancelled the restart all the same
+	if restartCtx.Err() != nil {
+		cancelled = true
+	}
+	// Restarts paused during the delay keep the backend down
+	paused := false
+	if !cancelled && i.GetStatus() == Restarting {
+		if paused, _ = i.restartPaused(i.timeProvider.Now()); paused {
+			log.Printf("Automatic restarts of instance %s are paused, not restarting", i.Name)
+			cancel()
+			i.restartCancel = nil
+			i.SetStatus(Failed, exitCode, exitMessage)
+		}
+	}
+	i.mu.Unlock()
+	if cancelled || paused {
 		return
 	}
 
-	// Restart the instance
-	if err := i.Start(); err != nil {
+	// Restart the instance, once a loading slot is free
+	if err := i.startLimited(restartCtx, ReasonAutoRestart, fmt.Sprintf("restart attempt %d/%d", i.restarts, maxRestarts)); err != nil {
+		if restartCtx.Err() != nil {
+			log.Printf("Restart cancelled for instance %s", i.Name)
+			return
+		}
 		log.Printf("Failed to restart instance %s: %v", i.Name, err)
+		i.mu.Lock()
+		// Unless the restart was cancelled by a stop meanwhile
+		if i.GetStatus() == Restarting {
+			i.SetStatus(Failed, exitCode, "restart failed: "+err.Error())
+		}
+		i.mu.Unlock()
 	} else {
 		log.Printf("Successfully restarted instance %s", i.Name)
-		// Clear the cancel function
+		// Clear the cancel function, unless the new process already exited and its restart
+		// cancelled this one and took its place
 		i.mu.Lock()
-		i.restartCancel = nil
+		if restartCtx.Err() == nil {
+			i.restartCancel = nil
+		}
 		i.mu.Unlock()
 	}
 }
 
-// validateRestartConditions checks if the instance should be restarted and returns the parameters
-func (i *Process) validateRestartConditions() (shouldRestart bool, maxRestarts int, restartDelay int) {
-	if i.options == nil {
-		log.Printf("Instance %s not restarting: options are nil", i.Name)
-		return false, 0, 0
-	}
+// defaultRestartResetAfter is how long a process has to stay ready for the restart counter
+// and backoff to start over when restart_reset_after is unset
+const defaultRestartResetAfter = 10 * time.Minute
 
-	if i.options.AutoRestart == nil || !*i.options.AutoRestart {
-		log.Printf("Instance %s not restarting: AutoRestart is disabled", i.Name)
-		return false, 0, 0
+// defaultRestartBackoffMax caps the restart delay under backoff when restart_backoff_max is unset
+const defaultRestartBackoffMax = 5 * time.Minute
+
+// backoff returns the restart delay after the exit of the current process: the restart delay
+// doubled for each consecutive crash before it with restart_backoff, up to
+// restart_backoff_max (caller must hold the lock)
+func (i *Process) backoff(delay time.Duration) time.Duration {
+	return i.backoffAfter(i.crashes, delay)
+}
+
+// backoffAfter returns the restart delay after the given number of consecutive crashes
+// (caller must hold the lock)
+func (i *Process) backoffAfter(crashes int, delay time.Duration) time.Duration {
+	if i.options == nil || i.options.RestartBackoff == nil || !*i.options.RestartBackoff {
+		return delay
 	}
 
-	if i.options.MaxRestarts == nil {
-		log.Printf("Instance %s not restarting: MaxRestarts is nil", i.Name)
-		return false, 0, 0
+	maxDelay := defaultRestartBackoffMax
+	if i.options.RestartBackoffMax != nil && *i.options.RestartBackoffMax > 0 {
+		maxDelay = i.options.RestartBackoffMax.Duration()
+	}
+	if delay >= maxDelay {
+		return delay
 	}
+	for range crashes {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
 
-	if i.options.RestartDelay == nil {
-		log.Printf("Instance %s not restarting: RestartDelay is nil", i.Name)
-		return false, 0, 0
+// exceededMaxRestarts reports whether the restart budget is used up (caller must hold the lock)
+func (i *Process) exceededMaxRestarts() bool {
+	return i.restartBudget(i.timeProvider.Now()).Reason == RestartExhausted
+}
+
+// restartResetAfter returns how long a process has to stay ready for the restart counter to
+// start over (caller must hold the lock)
+func (i *Process) restartResetAfter() time.Duration {
+	if i.options != nil && i.options.RestartResetAfter != nil && *i.options.RestartResetAfter > 0 {
+		return i.options.RestartResetAfter.Duration()
 	}
+	return defaultRestartResetAfter
+}
 
-	// Values are already validated during unmarshaling/SetOptions
-	maxRestarts = *i.options.MaxRestarts
-	restartDelay = *i.options.RestartDelay
+// validateRestartConditions checks if the instance should be restarted and returns the parameters
+func (i *Process) validateRestartConditions() (shouldRestart bool, maxRestarts int, restartDelay time.Duration) {
+	now := i.timeProvider.Now()
+
+	// A run that stayed ready long enough earns back the restart budget and ends the streak
+	// of consecutive crashes
+	if i.restartCounterExpired(now) {
+		if i.restarts > 0 {
+			log.Printf("Instance %s was ready for %v, resetting its restart counter (%d)", i.Name, i.restartResetAfter(), i.restarts)
+		}
+		i.restarts = 0
+		i.crashes = 0
+	}
 
-	if i.restarts >= maxRestarts {
-		log.Printf("Instance %s exceeded max restart attempts (%d)", i.Name, maxRestarts)
+	budget := i.restartBudget(now)
+	if !budget.Armed {
+		switch budget.Reason {
+		case RestartExhausted:
+			log.Printf("Instance %s exceeded max restart attempts (%d)", i.Name, budget.MaxRestarts)
+		case RestartCrashLoop:
+			log.Printf("Instance %s is crash-looping (%d rapid failures), not restarting", i.Name, budget.RapidFailures)
+		default:
+			log.Printf("Instance %s not restarting: %s", i.Name, budget.Reason)
+		}
 		return false, 0, 0
 	}
 
-	return true, maxRestarts, restartDelay
+	return true, budget.MaxRestarts, i.options.RestartDelay.Duration()
 }
 
 // buildCommand builds the command to execute using backend-specific logic
 func (i *Process) buildCommand() (*exec.Cmd, error) {
+	return i.buildCommandFor(i.ctx, i.commandOptions())
+}
+
+// buildCommandFor builds the command of a process run with the command options opts, for
+// the lifetime of ctx
+func (i *Process) buildCommandFor(ctx context.Context, opts *CreateInstanceOptions) (*exec.Cmd, error) {
 	// Get backend configuration
 	backendConfig, err := i.getBackendConfig()
 	if err != nil {
@@ -375,16 +1082,19 @@ func (i *Process) buildCommand() (*exec.Cmd, error) {
 	}
 
 	// Build the environment variables
-	env := i.options.BuildEnvironment(backendConfig)
+	env := opts.BuildEnvironment(backendConfig)
 
 	// Get the command to execute
-	command := i.options.GetCommand(backendConfig)
+	command := opts.GetCommand(backendConfig)
 
 	// Build command arguments
-	args := i.options.BuildCommandArgs(backendConfig)
+	args := opts.BuildCommandArgs(backendConfig)
+
+	// Run the backend through the launch wrapper, if any
+	command, args = opts.WrapCommand(command, args)
 
 	// Create the exec.Cmd
-	cmd := exec.CommandContext(i.ctx, command, args...)
+	cmd := exec.CommandContext(ctx, command, args...)
 
 	// Start with host environment variables
 	cmd.Env = os.Environ()
@@ -413,5 +1123,8 @@ func (i *Process) getBackendConfig() (*config.BackendSettings, error) {
 	}
 
 	settings := i.globalBackendSettings.GetBackendSettings(backendTypeStr)
+	if i.options.BackendType == backends.BackendTypeLlamaCpp && i.globalInstanceSettings != nil && i.globalInstanceSettings.LlamaExecutable != "" {
+		settings.Command = i.globalInstanceSettings.LlamaExecutable
+	}
 	return &settings, nil
 }
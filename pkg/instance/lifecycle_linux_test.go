@@ -0,0 +1,126 @@
+package instance_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// openFDs returns the descriptors open in the test process
+func openFDs(t *testing.T) []int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatalf("Failed to list open descriptors: %v", err)
+	}
+	fds := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		if fd, err := strconv.Atoi(entry.Name()); err == nil {
+			fds = append(fds, fd)
+		}
+	}
+	return fds
+}
+
+// newFailingInstance returns an instance running command, with its logs in logsDir
+func newFailingInstance(command, logsDir string) *instance.Process {
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: command, Args: []string{"-c", "exec sleep 30"}},
+	}
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+	return instance.NewInstance("failing", backendConfig, &config.InstancesConfig{LogsDir: logsDir}, options, nil)
+}
+
+// assertStartFails starts the instance a few times, expecting each start to fail with
+// wantErr without leaving a descriptor open
+func assertStartFails(t *testing.T, inst *instance.Process, wantErr string) {
+	t.Helper()
+	before := len(openFDs(t))
+	for range 5 {
+		err := inst.Start()
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("Expected start to fail with %q, got %v", wantErr, err)
+		}
+	}
+	if after := len(openFDs(t)); after != before {
+		t.Errorf("Expected %d open descriptors after the failed starts, got %d", before, after)
+	}
+	if inst.IsRunning() {
+		t.Errorf("Expected the instance not to run, it is %s", inst.GetStatus())
+	}
+}
+
+// assertLogClosed checks that the log records each failed start and is closed after it
+func assertLogClosed(t *testing.T, logsDir string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(logsDir, "failing.log"))
+	if err != nil {
+		t.Fatalf("Failed to read the instance log: %v", err)
+	}
+	log := string(data)
+	started := strings.Count(log, "=== Instance failing started at")
+	stopped := strings.Count(log, "=== Instance failing stopped at")
+	failed := strings.Count(log, "start failed: ")
+	if started != 5 || stopped != 5 || failed != 5 {
+		t.Errorf("Expected 5 start, start failed and stop markers, got %d, %d and %d:\n%s", started, failed, stopped, log)
+	}
+}
+
+func TestStart_FailureCleanup(t *testing.T) {
+	t.Run("log dir missing", func(t *testing.T) {
+		// A regular file where the log directory should be created
+		blocker := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(blocker, nil, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		assertStartFails(t, newFailingInstance("sh", filepath.Join(blocker, "logs")), "failed to create log files")
+	})
+
+	t.Run("exec failure", func(t *testing.T) {
+		logsDir := t.TempDir()
+		assertStartFails(t, newFailingInstance("/nonexistent/llama-server", logsDir), "failed to start instance failing")
+		assertLogClosed(t, logsDir)
+	})
+
+	t.Run("pipe creation", func(t *testing.T) {
+		logsDir := t.TempDir()
+		inst := newFailingInstance("sh", logsDir)
+
+		// Leave room for the two log files only, so creating the pipes runs out of descriptors
+		used := map[int]bool{}
+		for _, fd := range openFDs(t) {
+			used[fd] = true
+		}
+		limit, free := 0, 0
+		for free < 2 {
+			if !used[limit] {
+				free++
+			}
+			limit++
+		}
+		var saved syscall.Rlimit
+		if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &saved); err != nil {
+			t.Fatalf("Getrlimit failed: %v", err)
+		}
+		lowered := saved
+		lowered.Cur = uint64(limit)
+		if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lowered); err != nil {
+			t.Skipf("Cannot lower the descriptor limit: %v", err)
+		}
+		defer syscall.Setrlimit(syscall.RLIMIT_NOFILE, &saved)
+
+		assertStartFails(t, inst, "failed to get stdout pipe")
+		syscall.Setrlimit(syscall.RLIMIT_NOFILE, &saved)
+		assertLogClosed(t, logsDir)
+	})
+}
@@ -0,0 +1,119 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentStart(t *testing.T) {
+	record := filepath.Join(t.TempDir(), "spawned")
+	inst := newShellInstance(t, "echo $$ >> "+record+"; exec sleep 30", false, nil)
+	t.Cleanup(func() { inst.Stop() })
+
+	const starts = 16
+	errs := make([]error, starts)
+	var wg sync.WaitGroup
+	begin := make(chan struct{})
+	for n := range starts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-begin
+			errs[n] = inst.Start()
+		}()
+	}
+	close(begin)
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else if !strings.Contains(err.Error(), "already") {
+			t.Errorf("Expected the other starts to find the instance starting or running, got %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("Expected exactly one start to succeed, %d did", succeeded)
+	}
+
+	// Given the time to write their pid, had several been spawned
+	deadline := time.Now().Add(5 * time.Second)
+	for data, _ := os.ReadFile(record); len(data) == 0 && time.Now().Before(deadline); data, _ = os.ReadFile(record) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	data, _ := os.ReadFile(record)
+	if spawned := strings.Fields(string(data)); len(spawned) != 1 {
+		t.Errorf("Expected exactly one process to be spawned, got %v", spawned)
+	}
+}
+
+func TestSlowStartKeepsInstanceReadable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// The pre-start command stands in for a start that takes a while
+	backendConfig := &config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "exec sleep 30"}}}
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		OnStartCmd:         "sleep 1",
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+	inst := instance.NewInstance("slow", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+	t.Cleanup(func() { inst.Stop() })
+
+	started := make(chan error, 1)
+	go func() { started <- inst.Start() }()
+	deadline := time.Now().Add(5 * time.Second)
+	for logs, _ := inst.GetLogs(0); !strings.Contains(logs, "running on_start_cmd"); logs, _ = inst.GetLogs(0) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the start to run its pre-start command")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Reads do not wait for the start
+	begin := time.Now()
+	if _, err := json.Marshal(inst); err != nil {
+		t.Errorf("Marshal failed: %v", err)
+	}
+	inst.GetProxy()
+	inst.GetLogs(10)
+	if elapsed := time.Since(begin); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the instance to be readable during the start, reads took %v", elapsed)
+	}
+	select {
+	case err := <-started:
+		t.Fatalf("Expected the start to still be in progress, it returned %v", err)
+	default:
+	}
+
+	// Conflicting operations are not: another start is refused, and a stop waits for the
+	// process to be spawned before stopping it
+	if err := inst.Start(); err == nil || !strings.Contains(err.Error(), "already starting") {
+		t.Errorf("Expected the start to be refused while one is in progress, got %v", err)
+	}
+	if err := inst.Stop(); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+	if err := <-started; err != nil {
+		t.Errorf("Start failed: %v", err)
+	}
+	if inst.IsRunning() {
+		t.Error("Expected the stop to stop the process spawned meanwhile")
+	}
+}
@@ -0,0 +1,130 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"llamactl/pkg/config"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// Lifecycle commands, run around every start of the backend including automatic restarts
+const (
+	hookOnStart = "on_start_cmd" // Before the backend starts, failing the start when it fails
+	hookOnStop  = "on_stop_cmd"  // After the backend exited, only logged when it fails
+)
+
+// defaultHookTimeout bounds a lifecycle command when hook_timeout is unset
+const defaultHookTimeout = 5 * time.Minute
+
+// hookWaitDelay is how long the output of a lifecycle command that exited or was killed is
+// still read, since children it left behind may keep its pipes open
+const hookWaitDelay = 5 * time.Second
+
+// lifecycleCommand is a lifecycle command ready to run, taken from the options under the lock
+type lifecycleCommand struct {
+	hook    string
+	command string
+	env     []string
+	timeout time.Duration
+}
+
+// lifecycleCommand returns the given lifecycle command of the instance, nil when it has
+// none (caller must hold the lock)
+func (i *Process) lifecycleCommand(hook string) *lifecycleCommand {
+	if i.options == nil {
+		return nil
+	}
+	command := i.options.OnStartCmd
+	if hook == hookOnStop {
+		command = i.options.OnStopCmd
+	}
+	if command == "" {
+		return nil
+	}
+
+	timeout := defaultHookTimeout
+	if i.options.HookTimeout != nil && *i.options.HookTimeout > 0 {
+		timeout = i.options.HookTimeout.Duration()
+	}
+
+	// The environment of the backend, and what the command needs to find the instance
+	env := os.Environ()
+	for k, v := range i.options.Environment {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	env = append(env,
+		"LLAMACTL_INSTANCE_NAME="+i.Name,
+		fmt.Sprintf("LLAMACTL_INSTANCE_PORT=%d", i.options.port()),
+		"LLAMACTL_HOOK="+hook,
+	)
+
+	return &lifecycleCommand{hook: hook, command: command, env: env, timeout: timeout}
+}
+
+// runLifecycleCommand runs a lifecycle command through the shell, appending its output to
+// the log of the instance. A command still running after its timeout is killed along with
+// its children.
+func (i *Process) runLifecycleCommand(c *lifecycleCommand) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", c.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", c.command)
+	}
+	setProcAttrs(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.Env = c.env
+	cmd.WaitDelay = hookWaitDelay
+
+	// Not an *os.File, so that Wait gives up on the output after WaitDelay
+	reader, writer := io.Pipe()
+	cmd.Stdout, cmd.Stderr = writer, writer
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		i.logger.readOutput(reader, LogStreamHook)
+	}()
+
+	i.logger.writeLine(fmt.Sprintf("running %s: %s", c.hook, c.command))
+	err := cmd.Start()
+	if err == nil {
+		if attachErr := attachProcessGroup(cmd); attachErr != nil {
+			log.Printf("Failed to track the process tree of %s of instance %s: %v", c.hook, i.Name, attachErr)
+		}
+		err = cmd.Wait()
+		releaseProcessGroup(cmd)
+	}
+	writer.Close()
+	<-read
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		err = fmt.Errorf("%s timed out after %s", c.hook, config.Duration(c.timeout))
+	case err != nil:
+		err = fmt.Errorf("%s failed: %w", c.hook, err)
+	default:
+		return nil
+	}
+	i.logger.writeLine(err.Error())
+	return err
+}
+
+// runOnStopCommand runs the post-stop command, if any. Its failure is only logged, the
+// backend being gone already.
+func (i *Process) runOnStopCommand(c *lifecycleCommand) {
+	if c == nil {
+		return
+	}
+	if err := i.runLifecycleCommand(c); err != nil {
+		log.Printf("Instance %s: %v", i.Name, err)
+	}
+}
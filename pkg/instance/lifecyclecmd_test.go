@@ -0,0 +1,152 @@
+package instance_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLifecycleCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	newHookedInstance := func(t *testing.T, script string, configure func(*instance.CreateInstanceOptions), onStatusChange instance.StatusChangeFunc) *instance.Process {
+		backendConfig := &config.BackendConfig{
+			LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", script}},
+		}
+		options := &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			AutoRestart:        testutil.BoolPtr(false),
+			RestartDelay:       testutil.DurationPtr(0),
+			Environment:        map[string]string{"SCRATCH": "/mnt/nvme"},
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8123},
+		}
+		configure(options)
+		inst := instance.NewInstance("hooked", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, onStatusChange)
+		t.Cleanup(func() { inst.Stop() })
+		return inst
+	}
+	readLines := func(t *testing.T, path string) []string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	t.Run("commands run around a start and a stop", func(t *testing.T) {
+		record := filepath.Join(t.TempDir(), "hooks")
+		inst := newHookedInstance(t, "exec sleep 30", func(o *instance.CreateInstanceOptions) {
+			o.OnStartCmd = `echo "start $LLAMACTL_INSTANCE_NAME $LLAMACTL_INSTANCE_PORT $SCRATCH" >> ` + record + `; echo model linked`
+			o.OnStopCmd = `echo "stop $LLAMACTL_HOOK" >> ` + record
+		}, nil)
+
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if lines := readLines(t, record); len(lines) != 1 || lines[0] != "start hooked 8123 /mnt/nvme" {
+			t.Errorf("Expected the pre-start command to run with the instance variables, got %q", lines)
+		}
+		if err := inst.Stop(); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		if lines := readLines(t, record); len(lines) != 2 || lines[1] != "stop on_stop_cmd" {
+			t.Errorf("Expected the post-stop command to run once stopped, got %q", lines)
+		}
+
+		logs, err := inst.GetLogs(0)
+		if err != nil {
+			t.Fatalf("GetLogs failed: %v", err)
+		}
+		if !strings.Contains(logs, "running on_start_cmd") || !strings.Contains(logs, "model linked") {
+			t.Errorf("Expected the output of the pre-start command in the log, got %q", logs)
+		}
+	})
+
+	t.Run("a failing pre-start command aborts the start", func(t *testing.T) {
+		record := filepath.Join(t.TempDir(), "backend")
+		inst := newHookedInstance(t, "touch "+record+"; exec sleep 30", func(o *instance.CreateInstanceOptions) {
+			o.OnStartCmd = "echo no space left >&2; exit 7"
+		}, nil)
+
+		err := inst.Start()
+		if err == nil || !strings.Contains(err.Error(), "on_start_cmd failed: exit status 7") {
+			t.Fatalf("Expected the start to fail with the pre-start command, got %v", err)
+		}
+		if inst.IsRunning() {
+			t.Error("Expected the instance not to be running")
+		}
+		time.Sleep(100 * time.Millisecond)
+		if _, err := os.Stat(record); err == nil {
+			t.Error("Expected the backend not to be started")
+		}
+		if logs, _ := inst.GetLogs(0); !strings.Contains(logs, "no space left") {
+			t.Errorf("Expected the output of the pre-start command in the log, got %q", logs)
+		}
+	})
+
+	t.Run("a pre-start command running past its timeout is killed", func(t *testing.T) {
+		inst := newHookedInstance(t, "exec sleep 30", func(o *instance.CreateInstanceOptions) {
+			o.OnStartCmd = "sleep 30"
+			o.HookTimeout = testutil.DurationPtr(100 * time.Millisecond)
+		}, nil)
+
+		begin := time.Now()
+		err := inst.Start()
+		if err == nil || !strings.Contains(err.Error(), "on_start_cmd timed out after 100ms") {
+			t.Fatalf("Expected the start to fail with the timeout, got %v", err)
+		}
+		if elapsed := time.Since(begin); elapsed > 5*time.Second {
+			t.Errorf("Expected the command to be killed at its timeout, took %v", elapsed)
+		}
+	})
+
+	t.Run("a failing post-stop command does not fail the stop", func(t *testing.T) {
+		inst := newHookedInstance(t, "exec sleep 30", func(o *instance.CreateInstanceOptions) {
+			o.OnStopCmd = "exit 1"
+		}, nil)
+
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if err := inst.Stop(); err != nil {
+			t.Errorf("Expected the stop to succeed, got %v", err)
+		}
+		if status := inst.GetStatus(); status != instance.Stopped {
+			t.Errorf("Expected the instance to be stopped, got %s", status)
+		}
+		if logs, _ := inst.GetLogs(0); !strings.Contains(logs, "on_stop_cmd failed: exit status 1") {
+			t.Errorf("Expected the failure of the post-stop command in the log, got %q", logs)
+		}
+	})
+
+	t.Run("commands run around automatic restarts", func(t *testing.T) {
+		record := filepath.Join(t.TempDir(), "hooks")
+		recorder := newTransitionRecorder()
+		inst := newHookedInstance(t, "exit 3", func(o *instance.CreateInstanceOptions) {
+			o.AutoRestart, o.MaxRestarts = testutil.BoolPtr(true), testutil.IntPtr(1)
+			o.OnStartCmd = "echo start >> " + record
+			o.OnStopCmd = "echo stop >> " + record
+		}, recorder.record)
+
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		recorder.waitFor(t, instance.Failed)
+
+		expected := []string{"start", "stop", "start", "stop"}
+		if lines := readLines(t, record); strings.Join(lines, " ") != strings.Join(expected, " ") {
+			t.Errorf("Expected the commands around the start and its restart, got %q", lines)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package instance
+
+import (
+	"fmt"
+	"llamactl/pkg/config"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Fallbacks for health check settings left unset
+const (
+	defaultHealthCheckInterval  = 30 * time.Second
+	defaultHealthCheckTimeout   = 5 * time.Second
+	defaultHealthCheckThreshold = 3
+)
+
+// HealthCheckOptions enable the liveness checks of a running backend. A backend that
+// deadlocks or keeps failing its health endpoint is killed after failure_threshold
+// consecutive failed checks, and restarted per its restart policy.
+type HealthCheckOptions struct {
+	Interval         config.Duration `json:"interval,omitempty"`          // Between checks (default: 30s)
+	Timeout          config.Duration `json:"timeout,omitempty"`           // Of a single check (default: 5s)
+	FailureThreshold int             `json:"failure_threshold,omitempty"` // Consecutive failures the backend is killed after (default: 3)
+}
+
+func (o *HealthCheckOptions) interval() time.Duration {
+	if o.Interval <= 0 {
+		return defaultHealthCheckInterval
+	}
+	return o.Interval.Duration()
+}
+
+func (o *HealthCheckOptions) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return defaultHealthCheckTimeout
+	}
+	return o.Timeout.Duration()
+}
+
+func (o *HealthCheckOptions) threshold() int {
+	if o.FailureThreshold <= 0 {
+		return defaultHealthCheckThreshold
+	}
+	return o.FailureThreshold
+}
+
+// Health is the result of the last liveness check of the running backend
+type Health struct {
+	Healthy             bool      `json:"healthy"`
+	LastCheck           time.Time `json:"last_check"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// GetHealth returns the result of the last liveness check of the running backend, or nil
+// if the instance has no health_check or was not checked since it became ready
+func (i *Process) GetHealth() *Health {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.health == nil {
+		return nil
+	}
+	health := *i.health
+	return &health
+}
+
+// watchLiveness checks the health of the process whose monitor closes exited every
+// health_check interval once it is ready. After failure_threshold consecutive failures
+// the process is killed, and the monitor restarts it per its policy.
+func (i *Process) watchLiveness(exited chan struct{}) {
+	i.mu.RLock()
+	if i.options == nil || i.options.HealthCheck == nil {
+		i.mu.RUnlock()
+		return
+	}
+	check := *i.options.HealthCheck
+	i.mu.RUnlock()
+
+	healthURL, err := i.healthURL()
+	if err != nil {
+		return
+	}
+	client := &http.Client{Transport: i.BackendTransport(), Timeout: check.timeout()}
+	ticker := time.NewTicker(check.interval())
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-exited:
+			return
+		case <-ticker.C:
+		}
+
+		err := checkLiveness(client, healthURL)
+		if err != nil {
+			failures++
+		} else {
+			failures = 0
+		}
+
+		i.mu.Lock()
+		if i.monitorDone != exited {
+			i.mu.Unlock()
+			return
+		}
+		if i.GetStatus() != Running {
+			// Stopping, a failed check is expected
+			i.mu.Unlock()
+			continue
+		}
+		i.health = &Health{Healthy: err == nil, LastCheck: i.timeProvider.Now(), ConsecutiveFailures: failures}
+		if err != nil {
+			i.health.LastError = err.Error()
+		}
+		if failures < check.threshold() || i.cmd == nil || i.cmd.Process == nil {
+			i.mu.Unlock()
+			continue
+		}
+		i.unhealthy = fmt.Sprintf("%d consecutive health checks failed: %v", failures, err)
+		cmd := i.cmd
+		i.mu.Unlock()
+
+		log.Printf("Instance %s failed %d consecutive health checks, killing it: %v", i.Name, failures, err)
+		if err := killProcessGroup(cmd); err != nil {
+			log.Printf("Failed to kill unhealthy instance %s: %v", i.Name, err)
+		}
+		return
+	}
+}
+
+// checkLiveness performs a single liveness check
+func checkLiveness(client *http.Client, healthURL string) error {
+	resp, err := client.Get(healthURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned status %d", resp.StatusCode)
+	}
+	return nil
+}
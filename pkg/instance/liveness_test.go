@@ -0,0 +1,91 @@
+package instance_test
+
+import (
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newLivenessInstance starts an instance whose health endpoint fails while failing is set
+func newLivenessInstance(t *testing.T, check *instance.HealthCheckOptions, failing *atomic.Bool, onStatusChange instance.StatusChangeFunc) *instance.Process {
+	t.Helper()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "deadlocked", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+	options := &instance.CreateInstanceOptions{
+		AutoRestart:  testutil.BoolPtr(true),
+		MaxRestarts:  testutil.IntPtr(1),
+		RestartDelay: testutil.DurationPtr(0),
+		HealthCheck:  check,
+	}
+	globalSettings := &config.InstancesConfig{ReadinessProbeInterval: config.Duration(20 * time.Millisecond)}
+
+	inst := newBackendInstance(t, handler, options, globalSettings, onStatusChange)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waitForStatus(t, inst, instance.Running)
+	return inst
+}
+
+func TestLiveness_RestartsUnhealthy(t *testing.T) {
+	var failing atomic.Bool
+	recorder := newTransitionRecorder()
+	check := &instance.HealthCheckOptions{Interval: config.Duration(20 * time.Millisecond), FailureThreshold: 3}
+	inst := newLivenessInstance(t, check, &failing, recorder.record)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for health := inst.GetHealth(); health == nil || !health.Healthy; health = inst.GetHealth() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a passing liveness check, got %+v", health)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The backend deadlocks: it is killed after 3 failed checks and restarted
+	failing.Store(true)
+	recorder.waitFor(t, instance.Restarting)
+	failing.Store(false)
+	waitForStatus(t, inst, instance.Running)
+
+	var reason *instance.StatusReason
+	for _, tr := range recorder.snapshot() {
+		if tr.newStatus == instance.Restarting {
+			reason = &tr.reason
+		}
+	}
+	if reason.Code != instance.ReasonHealthProbeFailure || !strings.HasPrefix(reason.Message, "3 consecutive health checks failed") {
+		t.Errorf("Expected the health_probe_failure reason, got %+v", reason)
+	}
+	if budget := inst.GetRestartBudget(); budget.Restarts != 1 {
+		t.Errorf("Expected the restart to be counted, got %+v", budget)
+	}
+	if lastExit := inst.GetLastExit(); lastExit == nil || lastExit.Reason != instance.ReasonHealthProbeFailure {
+		t.Errorf("Expected the kill to be recorded as the last exit, got %+v", lastExit)
+	}
+}
+
+func TestLiveness_Disabled(t *testing.T) {
+	var failing atomic.Bool
+	recorder := newTransitionRecorder()
+	inst := newLivenessInstance(t, nil, &failing, recorder.record)
+
+	// Without a health_check, a running backend is not checked
+	failing.Store(true)
+	time.Sleep(300 * time.Millisecond)
+	if inst.GetStatus() != instance.Running {
+		t.Errorf("Expected the instance to keep running, got %s", inst.GetStatus())
+	}
+	if health := inst.GetHealth(); health != nil {
+		t.Errorf("Expected no liveness check, got %+v", health)
+	}
+}
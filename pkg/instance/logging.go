@@ -4,8 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"llamactl/pkg/config"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,14 +17,68 @@ type InstanceLogger struct {
 	name        string
 	logDir      string
 	logFile     *os.File
-	logFilePath string
+	mu          sync.Mutex // Guards logFile, which the output readers write while the monitor closes it
+	logFilePath string     // Set once the log files were created, guarded by mu
+
+	// Structured copy of the log, one LogLine per line, and the followers of new lines
+	structuredFile *os.File
+	followers      map[*LogFollower]struct{}
+
+	// Number of rotated log files removed by the retention policy and the log disk quota
+	retentionDeleted atomic.Int64
+	quotaDeleted     atomic.Int64
+
+	// Size in bytes at which the log files are rotated (0 = never), the size of the current
+	// log file (guarded by mu), and the lock of the manifest of the rotated files
+	rotateSize atomic.Int64
+	written    int64
+	manifestMu sync.Mutex
+
+	// Sanitization mode of the output, see config.LogSanitizeOff and friends
+	sanitize atomic.Value
+
+	// Called with every line of output of the backend, and after every rotation of the log
+	// files with the lock held, if set
+	onLine   func(line LogLine)
+	onRotate func()
+}
+
+// LogFileInfo describes a log file belonging to an instance
+type LogFileInfo struct {
+	Name       string     `json:"name"`
+	Size       int64      `json:"size"`
+	Current    bool       `json:"current"`
+	Timestamp  time.Time  `json:"timestamp"`       // Rotation time of a rotated file, mtime of the current one
+	Start      *time.Time `json:"start,omitempty"` // When the first line was logged, if known
+	Lines      int        `json:"lines,omitempty"` // Of a rotated file
+	AgeSeconds int64      `json:"age_seconds"`
+}
+
+// rotatedTimestampLayouts are the timestamp formats recognized in rotated log file suffixes
+var rotatedTimestampLayouts = []string{
+	"20060102T150405",
+	"20060102-150405",
+	"2006-01-02T15-04-05",
+	"2006-01-02",
+	"20060102",
 }
 
 func NewInstanceLogger(name string, logDir string) *InstanceLogger {
-	return &InstanceLogger{
+	logger := &InstanceLogger{
 		name:   name,
 		logDir: logDir,
 	}
+	logger.sanitize.Store(config.LogSanitizeOff)
+	return logger
+}
+
+// SetSanitizeMode sets how output is sanitized before it is logged, taking effect from the
+// next line. An empty mode logs the output as is.
+func (i *InstanceLogger) SetSanitizeMode(mode string) {
+	if mode == "" {
+		mode = config.LogSanitizeOff
+	}
+	i.sanitize.Store(mode)
 }
 
 // Create creates and opens the log files for stdout and stderr
@@ -32,7 +90,6 @@ func (i *InstanceLogger) Create() error {
 	// Set up instance logs
 	logPath := i.logDir + "/" + i.name + ".log"
 
-	i.logFilePath = logPath
 	if err := os.MkdirAll(i.logDir, 0755); err != nil {
 		return fmt.Errorf("failed to create log directory: %w", err)
 	}
@@ -41,23 +98,49 @@ func (i *InstanceLogger) Create() error {
 	if err != nil {
 		return fmt.Errorf("failed to create stdout log file: %w", err)
 	}
+	structuredFile, err := os.OpenFile(i.structuredLogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logFile.Close()
+		return fmt.Errorf("failed to create structured log file: %w", err)
+	}
 
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.logFilePath = logPath
 	i.logFile = logFile
+	i.structuredFile = structuredFile
 
 	// Write a startup marker to both files
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	fmt.Fprintf(i.logFile, "\n=== Instance %s started at %s ===\n", i.name, timestamp)
+	now := time.Now()
+	marker := fmt.Sprintf("=== Instance %s started at %s ===", i.name, now.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(i.logFile, "\n%s\n", marker)
+	i.writeStructured(LogLine{Time: now, Stream: LogStreamLlamactl, Line: marker})
+	if info, err := logFile.Stat(); err == nil {
+		i.written = info.Size()
+	}
 
 	return nil
 }
 
+// createdPath returns the path of the current log file, empty until the log files were created
+func (i *InstanceLogger) createdPath() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.logFilePath
+}
+
 // GetLogs retrieves the last n lines of logs from the instance
 func (i *Process) GetLogs(num_lines int) (string, error) {
-	i.mu.RLock()
-	logFileName := i.logger.logFilePath
-	i.mu.RUnlock()
+	logFileName := i.logger.createdPath()
+
+	// Held while reading, so a rotation cannot move lines between the files meanwhile
+	i.logger.manifestMu.Lock()
+	defer i.logger.manifestMu.Unlock()
 
 	if logFileName == "" {
+		if !i.IsManaged() {
+			return "", fmt.Errorf("logs are not available for instance %s: %w", i.Name, ErrUnmanaged)
+		}
 		return "", fmt.Errorf("log file not created for instance %s", i.Name)
 	}
 
@@ -87,32 +170,236 @@ func (i *Process) GetLogs(num_lines int) (string, error) {
 		return "", fmt.Errorf("error reading file: %w", err)
 	}
 
+	// Lines missing from the current file are taken from the newest rotated files
+	if len(lines) < num_lines {
+		older, err := i.logger.tailSegments(num_lines - len(lines))
+		if err != nil {
+			return "", err
+		}
+		lines = append(older, lines...)
+	}
+
 	// Return the last N lines
 	start := max(len(lines)-num_lines, 0)
 
 	return strings.Join(lines[start:], "\n"), nil
 }
 
+// currentLogName returns the file name of the active log file
+func (i *InstanceLogger) currentLogName() string {
+	return i.name + ".log"
+}
+
+// rotatedTimestamp returns the rotation time of a rotated log file, preferring a timestamp
+// embedded in the file name (e.g. name.log.20240601 or name.log-2024-06-01.gz) over mtime
+func (i *InstanceLogger) rotatedTimestamp(fileName string, modTime time.Time) time.Time {
+	suffix := strings.TrimPrefix(fileName, i.currentLogName())
+	suffix = strings.TrimLeft(suffix, ".-_")
+	suffix = strings.TrimSuffix(suffix, ".gz")
+
+	for _, layout := range rotatedTimestampLayouts {
+		if ts, err := time.ParseInLocation(layout, suffix, time.Local); err == nil {
+			return ts
+		}
+	}
+
+	return modTime
+}
+
+// ListFiles returns the current log file and all rotated log files of the instance as
+// indexed in the manifest, with the current file first and rotated files ordered from newest
+// to oldest
+func (i *InstanceLogger) ListFiles(now time.Time) ([]LogFileInfo, error) {
+	if i.logDir == "" {
+		return nil, fmt.Errorf("logDir is empty for instance %s", i.name)
+	}
+
+	i.manifestMu.Lock()
+	manifest, err := i.loadManifest()
+	i.manifestMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	files := []LogFileInfo{}
+	if info, err := os.Stat(filepath.Join(i.logDir, i.currentLogName())); err == nil {
+		file := LogFileInfo{
+			Name:       info.Name(),
+			Size:       info.Size(),
+			Current:    true,
+			Timestamp:  info.ModTime(),
+			AgeSeconds: int64(now.Sub(info.ModTime()).Seconds()),
+		}
+		if !manifest.CurrentStart.IsZero() {
+			file.Start = &manifest.CurrentStart
+		}
+		files = append(files, file)
+	}
+
+	for n := len(manifest.Segments) - 1; n >= 0; n-- {
+		segment := manifest.Segments[n]
+		file := LogFileInfo{
+			Name:       segment.File,
+			Size:       segment.Size,
+			Timestamp:  segment.End,
+			Lines:      segment.Lines,
+			AgeSeconds: int64(now.Sub(segment.End).Seconds()),
+		}
+		if !segment.Start.IsZero() {
+			file.Start = &segment.Start
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// EnforceRetention removes rotated log files that ended more than retentionDays ago,
+// along with their entries in the manifest. The current log file is never removed. A
+// retentionDays of 0 keeps all files.
+func (i *InstanceLogger) EnforceRetention(retentionDays int, now time.Time) ([]string, error) {
+	if retentionDays <= 0 {
+		return nil, nil
+	}
+
+	i.manifestMu.Lock()
+	defer i.manifestMu.Unlock()
+	manifest, err := i.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := now.Add(-time.Duration(retentionDays) * 24 * time.Hour)
+	var deleted []string
+	var removeErr error
+	kept := make([]LogSegment, 0, len(manifest.Segments))
+	for _, segment := range manifest.Segments {
+		if removeErr != nil || !segment.End.Before(cutoff) {
+			kept = append(kept, segment)
+			continue
+		}
+		if err := os.Remove(filepath.Join(i.logDir, segment.File)); err != nil && !os.IsNotExist(err) {
+			removeErr = fmt.Errorf("failed to remove rotated log file %s: %w", segment.File, err)
+			kept = append(kept, segment)
+			continue
+		}
+		if segment.Structured != "" {
+			os.Remove(filepath.Join(i.logDir, segment.Structured))
+		}
+		deleted = append(deleted, segment.File)
+		i.retentionDeleted.Add(1)
+	}
+
+	if len(deleted) > 0 {
+		manifest.Segments = kept
+		if err := i.saveManifest(manifest); err != nil && removeErr == nil {
+			removeErr = err
+		}
+	}
+	return deleted, removeErr
+}
+
+// RetentionDeletedCount returns the number of rotated log files removed by the retention policy
+func (i *InstanceLogger) RetentionDeletedCount() int64 {
+	return i.retentionDeleted.Load()
+}
+
+// GetLogFiles lists the current and rotated log files of the instance
+func (i *Process) GetLogFiles() ([]LogFileInfo, error) {
+	return i.logger.ListFiles(i.timeProvider.Now())
+}
+
+// LogRetentionDeletedCount returns the number of rotated log files removed by the retention policy
+func (i *Process) LogRetentionDeletedCount() int64 {
+	return i.logger.RetentionDeletedCount()
+}
+
+// EnforceLogRetention removes rotated log files older than the instance's retention setting
+func (i *Process) EnforceLogRetention() ([]string, error) {
+	opts := i.GetOptions()
+	if opts == nil || opts.LogRetentionDays == nil {
+		return nil, nil
+	}
+	return i.logger.EnforceRetention(*opts.LogRetentionDays, i.timeProvider.Now())
+}
+
+// writeLine writes a line of llamactl to the log files, if they are open
+func (i *InstanceLogger) writeLine(line string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.logFile != nil {
+		fmt.Fprintln(i.logFile, line)
+		i.writeStructured(LogLine{Time: time.Now(), Stream: LogStreamLlamactl, Line: line})
+		i.countWritten(len(line) + 1)
+	}
+}
+
 // closeLogFile closes the log files
+// CloseStartFailed records why the process could not be started and closes the log files,
+// so the startup marker is followed by the error and a stop marker
+func (i *InstanceLogger) CloseStartFailed(err error) {
+	i.mu.Lock()
+	if i.logFile != nil {
+		line := fmt.Sprintf("start failed: %v", err)
+		fmt.Fprintln(i.logFile, line)
+		i.writeStructured(LogLine{Time: time.Now(), Stream: LogStreamLlamactl, Line: line})
+	}
+	i.mu.Unlock()
+	i.Close()
+}
+
 func (i *InstanceLogger) Close() {
+	i.closeWithMarker("stopped")
+}
+
+// closeWithMarker closes the log files, marking what happened to the process
+func (i *InstanceLogger) closeWithMarker(event string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	if i.logFile != nil {
-		timestamp := time.Now().Format("2006-01-02 15:04:05")
-		fmt.Fprintf(i.logFile, "=== Instance %s stopped at %s ===\n\n", i.name, timestamp)
+		now := time.Now()
+		marker := fmt.Sprintf("=== Instance %s %s at %s ===", i.name, event, now.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(i.logFile, "%s\n\n", marker)
 		i.logFile.Close()
 		i.logFile = nil
+		i.writeStructured(LogLine{Time: now, Stream: LogStreamLlamactl, Line: marker})
 	}
+	if i.structuredFile != nil {
+		i.structuredFile.Close()
+		i.structuredFile = nil
+	}
+}
+
+// readOutput reads from the given reader and writes lines of the given stream to the log
+// files, keeping the last lines in each of tails
+func (i *InstanceLogger) readOutput(reader io.ReadCloser, stream string, tails ...*outputTail) {
+	i.readOutputOf(reader, stream, nil, tails...)
 }
 
-// readOutput reads from the given reader and writes lines to the log file
-func (i *InstanceLogger) readOutput(reader io.ReadCloser) {
+// readOutputOf is readOutput for a process that may not be the current process: with
+// current set, lines are only passed on to onLine while current is true
+func (i *InstanceLogger) readOutputOf(reader io.ReadCloser, stream string, current *atomic.Bool, tails ...*outputTail) {
 	defer reader.Close()
 
 	scanner := bufio.NewScanner(reader)
+	scanner.Split(i.scanLines)
 	for scanner.Scan() {
-		line := scanner.Text()
+		line := sanitizeLogLine(scanner.Text(), i.sanitize.Load().(string))
+		logLine := LogLine{Time: time.Now(), Stream: stream, Line: line}
+		i.mu.Lock()
 		if i.logFile != nil {
 			fmt.Fprintln(i.logFile, line)
 			i.logFile.Sync() // Ensure data is written to disk
+			i.writeStructured(logLine)
+			i.countWritten(len(line) + 1)
+		}
+		i.mu.Unlock()
+		for _, tail := range tails {
+			tail.add(line)
+		}
+		// Lines of the lifecycle commands are not output of the backend
+		if i.onLine != nil && stream != LogStreamHook && (current == nil || current.Load()) {
+			i.onLine(logLine)
 		}
 	}
 }
@@ -0,0 +1,210 @@
+package instance_test
+
+import (
+	"fmt"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func writeLogFile(t *testing.T, dir, name string, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("log line\n"), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set mtime of %s: %v", name, err)
+	}
+}
+
+func TestInstanceLogger_ListFiles(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local)
+
+	writeLogFile(t, dir, "test.log", now)
+	writeLogFile(t, dir, "test.log.1", now.Add(-24*time.Hour))
+	writeLogFile(t, dir, "test.log.20240601", now) // timestamp in name wins over mtime
+	writeLogFile(t, dir, "test-other.log", now)    // different instance
+	writeLogFile(t, dir, "testing.log.1", now)     // different instance
+
+	logger := instance.NewInstanceLogger("test", dir)
+	files, err := logger.ListFiles(now)
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+
+	if len(files) != 3 {
+		t.Fatalf("Expected 3 files, got %d: %+v", len(files), files)
+	}
+	if !files[0].Current || files[0].Name != "test.log" {
+		t.Errorf("Expected current log file first, got %+v", files[0])
+	}
+	if files[1].Name != "test.log.1" {
+		t.Errorf("Expected test.log.1 second, got %q", files[1].Name)
+	}
+	if files[2].Name != "test.log.20240601" {
+		t.Errorf("Expected test.log.20240601 last, got %q", files[2].Name)
+	}
+	if files[2].AgeSeconds != int64(19*24*60*60+12*60*60) {
+		t.Errorf("Expected age derived from file name, got %d seconds", files[2].AgeSeconds)
+	}
+}
+
+func TestInstanceLogger_EnforceRetention(t *testing.T) {
+	tests := []struct {
+		name          string
+		retentionDays int
+		expectDeleted []string
+	}{
+		{"keep forever", 0, nil},
+		{"14 days", 14, []string{"test.log.20240501", "test.log.2"}},
+		{"60 days", 60, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local)
+
+			writeLogFile(t, dir, "test.log", now.Add(-30*24*time.Hour)) // current file is never removed
+			writeLogFile(t, dir, "test.log.1", now.Add(-2*24*time.Hour))
+			writeLogFile(t, dir, "test.log.2", now.Add(-20*24*time.Hour))
+			writeLogFile(t, dir, "test.log.20240501", now)
+
+			logger := instance.NewInstanceLogger("test", dir)
+			deleted, err := logger.EnforceRetention(tt.retentionDays, now)
+			if err != nil {
+				t.Fatalf("EnforceRetention failed: %v", err)
+			}
+
+			if len(deleted) != len(tt.expectDeleted) {
+				t.Fatalf("Expected %d deleted files, got %v", len(tt.expectDeleted), deleted)
+			}
+			for _, name := range tt.expectDeleted {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be deleted", name)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dir, "test.log")); err != nil {
+				t.Errorf("Current log file should never be deleted: %v", err)
+			}
+			if logger.RetentionDeletedCount() != int64(len(tt.expectDeleted)) {
+				t.Errorf("Expected deleted count %d, got %d", len(tt.expectDeleted), logger.RetentionDeletedCount())
+			}
+		})
+	}
+}
+
+// logOutput runs a backend that writes the given file to its output and returns the
+// logs of the instance once the last line has been logged
+func logOutput(t *testing.T, sanitize string, outputPath string, lastLine string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	outputPath, _ = filepath.Abs(outputPath)
+	backendConfig := &config.BackendConfig{LlamaCpp: config.BackendSettings{
+		Command: "sh",
+		Args:    []string{"-c", fmt.Sprintf("cat '%s'; exec sleep 30", outputPath)},
+	}}
+	globalSettings := &config.InstancesConfig{LogsDir: t.TempDir(), LogSanitize: config.LogSanitizeCollapse}
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		LogSanitize:        sanitize,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+
+	inst := instance.NewInstance("log-instance", backendConfig, globalSettings, options, func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {})
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer inst.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if logs, _ := inst.GetLogs(0); strings.Contains(logs, lastLine) {
+			return logs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %q to be logged", lastLine)
+	return ""
+}
+
+func TestInstanceLogger_Sanitize(t *testing.T) {
+	// Output of llama-server downloading a model from Hugging Face and loading it
+	const output = "testdata/llamacpp-output.log"
+	const lastLine = "all slots are idle"
+
+	t.Run("collapse by default", func(t *testing.T) {
+		logs := logOutput(t, "", output, lastLine)
+		if strings.ContainsAny(logs, "\r\x1b") || !utf8.ValidString(logs) {
+			t.Errorf("Expected carriage returns, escape sequences and invalid UTF-8 to be removed, got %q", logs)
+		}
+		if count := strings.Count(logs, "%  ("); count != 1 {
+			t.Errorf("Expected only the final progress frame to be kept, got %d frames", count)
+		}
+		for _, line := range []string{
+			"[==================================================>] 100%  (806 MB / 806 MB)\n",
+			"\nload: special tokens cache size = 6414\n",
+			"\nllama_model_load: loading model tensors, this can take a while... (mmap = true)\n",
+			`"<mask>", "` + "\uFFFD" + `", ...`,
+		} {
+			if !strings.Contains(logs, line) {
+				t.Errorf("Expected the logs to contain %q, got %q", line, logs)
+			}
+		}
+	})
+
+	t.Run("strip", func(t *testing.T) {
+		logs := logOutput(t, config.LogSanitizeStrip, output, lastLine)
+		if strings.Contains(logs, "\x1b") || !utf8.ValidString(logs) {
+			t.Errorf("Expected escape sequences and invalid UTF-8 to be removed, got %q", logs)
+		}
+		if count := strings.Count(logs, "%  ("); count != 21 {
+			t.Errorf("Expected every progress frame to be kept, got %d frames", count)
+		}
+	})
+
+	t.Run("off", func(t *testing.T) {
+		raw, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs := logOutput(t, config.LogSanitizeOff, output, lastLine)
+		// Lines are logged as is, apart from the \r of the CRLF line ending
+		expected := strings.Replace(string(raw), "\r\n", "\n", 1)
+		if !strings.Contains(logs, expected) {
+			t.Errorf("Expected the output to be logged byte for byte, got %q", logs)
+		}
+	})
+}
+
+func TestInstanceLogger_SanitizeUnterminatedProgress(t *testing.T) {
+	// A progress bar redrawn far beyond the scanner buffer before its line ends
+	var output strings.Builder
+	for pct := range 5000 {
+		fmt.Fprintf(&output, "\r\x1b[2Kdownloading %5.1f%%", float64(pct)/50)
+	}
+	output.WriteString("\r\x1b[2Kdownload complete\nmain: starting the main loop\n")
+	path := filepath.Join(t.TempDir(), "progress.log")
+	if err := os.WriteFile(path, []byte(output.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := logOutput(t, config.LogSanitizeCollapse, path, "starting the main loop")
+	if !strings.Contains(logs, "\ndownload complete\n") || strings.Contains(logs, "downloading") {
+		t.Errorf("Expected only the final frame to be logged, got %q", logs)
+	}
+}
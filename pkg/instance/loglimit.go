@@ -0,0 +1,104 @@
+package instance
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// GetLogsReader returns a reader of the whole text log of the current or last process, as
+// GetLogs returns with no line limit. The reader reads from the file as it goes, so even a
+// large log is not held in memory.
+func (i *Process) GetLogsReader() (io.ReadCloser, error) {
+	if err := i.logsAvailable(); err != nil {
+		return nil, err
+	}
+	path := i.logger.createdPath()
+	if path == "" {
+		return nil, fmt.Errorf("log file not created for instance %s", i.Name)
+	}
+	reader, file, err := logReader(path, 0)
+	if err != nil {
+		return nil, err
+	}
+	return logReadCloser{Reader: reader, file: file}, nil
+}
+
+// limitChunk is the size of the reads LimitLogs looks for a line boundary with
+const limitChunk = 64 * 1024
+
+// LimitLogs returns a reader of the last lines of logs holding at most maxBytes, and whether
+// earlier lines were cut. Logs read from a file, such as those of GetLogsReader and
+// GetStructuredLogs, are cut without reading the lines skipped; other logs are read through,
+// keeping only their end in memory. The result starts with a whole line unless the last line
+// alone is longer than maxBytes. No limit applies if maxBytes is not positive.
+func LimitLogs(logs io.Reader, maxBytes int64) (io.Reader, bool, error) {
+	if maxBytes <= 0 {
+		return logs, false, nil
+	}
+	if closer, ok := logs.(logReadCloser); ok {
+		logs = closer.Reader
+	}
+	if section, ok := logs.(*io.SectionReader); ok {
+		return limitSection(section, maxBytes)
+	}
+
+	// Keeps the byte before the last maxBytes too, telling whether they start a line
+	keep := maxBytes + 1
+	var tail []byte
+	buf := make([]byte, limitChunk)
+	for {
+		n, err := logs.Read(buf)
+		tail = append(tail, buf[:n]...)
+		if int64(len(tail)) > 2*keep {
+			tail = tail[:copy(tail, tail[int64(len(tail))-keep:])]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read logs: %w", err)
+		}
+	}
+	if int64(len(tail)) <= maxBytes {
+		return bytes.NewReader(tail), false, nil
+	}
+
+	tail = tail[int64(len(tail))-keep:]
+	data := tail[1:]
+	if tail[0] != '\n' {
+		// The line cut short is dropped, unless it is the last one
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 && idx < len(data)-1 {
+			data = data[idx+1:]
+		}
+	}
+	return bytes.NewReader(data), true, nil
+}
+
+// limitSection returns the last lines of a section of a file holding at most maxBytes
+func limitSection(section *io.SectionReader, maxBytes int64) (io.Reader, bool, error) {
+	file, offset, size := section.Outer()
+	if size <= maxBytes {
+		return section, false, nil
+	}
+	end := offset + size
+	start := end - maxBytes
+
+	// The first line kept is the first one starting at or after start
+	buf := make([]byte, limitChunk+1)
+	for from := start - 1; from < end; from += limitChunk {
+		chunk := buf[:min(int64(len(buf)), end-from)]
+		if _, err := file.ReadAt(chunk, from); err != nil && err != io.EOF {
+			return nil, false, fmt.Errorf("failed to read logs: %w", err)
+		}
+		if idx := bytes.IndexByte(chunk, '\n'); idx >= 0 {
+			begin := from + int64(idx) + 1
+			if begin < end {
+				return io.NewSectionReader(file, begin, end-begin), true, nil
+			}
+			break
+		}
+	}
+	// The last line alone is too long, its end is kept
+	return io.NewSectionReader(file, start, maxBytes), true, nil
+}
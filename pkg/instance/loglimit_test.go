@@ -0,0 +1,73 @@
+package instance_test
+
+import (
+	"io"
+	"llamactl/pkg/instance"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLimitLogs(t *testing.T) {
+	logs := "first line\nsecond line\nthird line\n"
+	path := filepath.Join(t.TempDir(), "test.log")
+	if err := os.WriteFile(path, []byte(logs), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		maxBytes  int64
+		want      string
+		truncated bool
+	}{
+		{"unlimited", 0, logs, false},
+		{"within the limit", int64(len(logs)), logs, false},
+		{"cut within a line", 15, "third line\n", true},
+		{"cut at a line start", 23, "second line\nthird line\n", true},
+		{"cut a byte into a line", 22, "third line\n", true},
+		{"last line too long", 5, "line\n", true},
+	}
+	for _, tt := range tests {
+		// Files are cut without reading them whole, other readers are read through; both agree
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		readers := map[string]io.Reader{
+			"file":   io.NewSectionReader(file, 0, int64(len(logs))),
+			"stream": strings.NewReader(logs),
+		}
+		for kind, reader := range readers {
+			t.Run(tt.name+"/"+kind, func(t *testing.T) {
+				limited, truncated, err := instance.LimitLogs(reader, tt.maxBytes)
+				if err != nil {
+					t.Fatalf("LimitLogs failed: %v", err)
+				}
+				data, _ := io.ReadAll(limited)
+				if string(data) != tt.want || truncated != tt.truncated {
+					t.Errorf("Expected %q truncated %v, got %q truncated %v", tt.want, tt.truncated, data, truncated)
+				}
+			})
+		}
+		file.Close()
+	}
+}
+
+func TestLimitLogs_LargeStream(t *testing.T) {
+	var logs strings.Builder
+	for range 100000 {
+		logs.WriteString("0123456789\n")
+	}
+	logs.WriteString("last line\n")
+
+	limited, truncated, err := instance.LimitLogs(strings.NewReader(logs.String()), 30)
+	if err != nil {
+		t.Fatalf("LimitLogs failed: %v", err)
+	}
+	data, _ := io.ReadAll(limited)
+	if string(data) != "0123456789\nlast line\n" || !truncated {
+		t.Errorf("Expected the last whole lines within 30 bytes, got %q truncated %v", data, truncated)
+	}
+}
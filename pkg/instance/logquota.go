@@ -0,0 +1,148 @@
+package instance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LogDiskUsage is the disk space the log files of an instance use, against its share of the
+// log_disk_quota
+type LogDiskUsage struct {
+	UsedBytes      int64 `json:"used_bytes"`
+	ShareBytes     int64 `json:"share_bytes,omitempty"` // Share of the quota, unset without a quota
+	Weight         int   `json:"weight"`
+	DeletedByQuota int64 `json:"deleted_by_quota"` // Rotated log files removed to stay within the share
+}
+
+// logFiles returns the paths of the log files in use: the current text and structured logs
+// and the output files of a process left running
+func (i *InstanceLogger) logFiles() []string {
+	return []string{
+		filepath.Join(i.logDir, i.currentLogName()),
+		i.structuredLogPath(),
+		i.outputPath(LogStreamStdout),
+		i.outputPath(LogStreamStderr),
+	}
+}
+
+// diskUsage returns the bytes the log files use, and the size of each rotated segment
+// (caller must hold manifestMu)
+func (i *InstanceLogger) diskUsage(manifest *logManifest) (int64, []int64) {
+	var used int64
+	for _, path := range i.logFiles() {
+		if info, err := os.Stat(path); err == nil {
+			used += info.Size()
+		}
+	}
+	sizes := make([]int64, len(manifest.Segments))
+	for n, segment := range manifest.Segments {
+		sizes[n] = segment.Size
+		if segment.Structured != "" {
+			if info, err := os.Stat(filepath.Join(i.logDir, segment.Structured)); err == nil {
+				sizes[n] += info.Size()
+			}
+		}
+		used += sizes[n]
+	}
+	return used, sizes
+}
+
+// DiskUsage returns the bytes the current and rotated log files of the instance use
+func (i *InstanceLogger) DiskUsage() (int64, error) {
+	if i.logDir == "" {
+		return 0, nil
+	}
+	i.manifestMu.Lock()
+	defer i.manifestMu.Unlock()
+	manifest, err := i.loadManifest()
+	if err != nil {
+		return 0, err
+	}
+	used, _ := i.diskUsage(manifest)
+	return used, nil
+}
+
+// TrimToShare removes the oldest rotated log files until the log files use at most share
+// bytes, and returns the files removed and the bytes still used. The log files in use are
+// never removed, so more than share may be left.
+func (i *InstanceLogger) TrimToShare(share int64) ([]string, int64, error) {
+	if i.logDir == "" {
+		return nil, 0, nil
+	}
+	i.manifestMu.Lock()
+	defer i.manifestMu.Unlock()
+	manifest, err := i.loadManifest()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	used, sizes := i.diskUsage(manifest)
+	var deleted []string
+	var removeErr error
+	first := 0
+	for ; first < len(manifest.Segments) && used > share; first++ {
+		segment := manifest.Segments[first]
+		if err := os.Remove(filepath.Join(i.logDir, segment.File)); err != nil && !os.IsNotExist(err) {
+			removeErr = fmt.Errorf("failed to remove rotated log file %s: %w", segment.File, err)
+			break
+		}
+		if segment.Structured != "" {
+			os.Remove(filepath.Join(i.logDir, segment.Structured))
+		}
+		used -= sizes[first]
+		deleted = append(deleted, segment.File)
+		i.quotaDeleted.Add(1)
+	}
+
+	if len(deleted) > 0 {
+		manifest.Segments = manifest.Segments[first:]
+		if err := i.saveManifest(manifest); err != nil && removeErr == nil {
+			removeErr = err
+		}
+	}
+	return deleted, used, removeErr
+}
+
+// LogQuotaWeight returns the weight the share of the log disk quota of the instance is
+// computed with
+func (i *Process) LogQuotaWeight() int {
+	if opts := i.GetOptions(); opts != nil && opts.LogQuotaWeight != nil && *opts.LogQuotaWeight > 0 {
+		return *opts.LogQuotaWeight
+	}
+	return 1
+}
+
+// MeasureLogDisk records and returns the disk space the log files of the instance use,
+// without a share of the quota
+func (i *Process) MeasureLogDisk() (int64, error) {
+	used, err := i.logger.DiskUsage()
+	if err != nil {
+		return 0, err
+	}
+	i.logDisk.Store(&LogDiskUsage{UsedBytes: used, Weight: i.LogQuotaWeight(), DeletedByQuota: i.logger.quotaDeleted.Load()})
+	return used, nil
+}
+
+// EnforceLogQuota removes the oldest rotated log files of the instance until they use at most
+// share bytes, recording the disk space used against the share. The current log files are
+// kept, even when they alone exceed the share.
+func (i *Process) EnforceLogQuota(share int64) ([]string, error) {
+	deleted, used, err := i.logger.TrimToShare(share)
+	if err != nil && len(deleted) == 0 {
+		return nil, err
+	}
+	i.logDisk.Store(&LogDiskUsage{
+		UsedBytes:      used,
+		ShareBytes:     share,
+		Weight:         i.LogQuotaWeight(),
+		DeletedByQuota: i.logger.quotaDeleted.Load(),
+	})
+	return deleted, err
+}
+
+// GetLogDiskUsage returns the disk space the log files of the instance used when last
+// measured, nil before the first measurement
+func (i *Process) GetLogDiskUsage() *LogDiskUsage {
+	return i.logDisk.Load()
+}
@@ -0,0 +1,49 @@
+package instance_test
+
+import (
+	"llamactl/pkg/instance"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInstanceLogger_TrimToShare(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local)
+
+	// Four files of 9 bytes, the current one and three rotated by another tool
+	writeLogFile(t, dir, "test.log", now)
+	writeLogFile(t, dir, "test.log.1", now.Add(-24*time.Hour))
+	writeLogFile(t, dir, "test.log.2", now.Add(-48*time.Hour))
+	writeLogFile(t, dir, "test.log.3", now.Add(-72*time.Hour))
+
+	logger := instance.NewInstanceLogger("test", dir)
+	if used, err := logger.DiskUsage(); err != nil || used != 36 {
+		t.Fatalf("Expected the log files to use 36 bytes, got %d (%v)", used, err)
+	}
+
+	deleted, used, err := logger.TrimToShare(20)
+	if err != nil {
+		t.Fatalf("TrimToShare failed: %v", err)
+	}
+	if len(deleted) != 2 || deleted[0] != "test.log.3" || deleted[1] != "test.log.2" || used != 18 {
+		t.Errorf("Expected the two oldest files to be removed, leaving 18 bytes, got %v and %d bytes", deleted, used)
+	}
+
+	// The current file is kept even when it alone exceeds the share
+	deleted, used, err = logger.TrimToShare(0)
+	if err != nil {
+		t.Fatalf("TrimToShare failed: %v", err)
+	}
+	if len(deleted) != 1 || used != 9 {
+		t.Errorf("Expected only the rotated file to be removed, got %v and %d bytes", deleted, used)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test.log")); err != nil {
+		t.Errorf("Expected the current log file to be kept: %v", err)
+	}
+	files, err := logger.ListFiles(now)
+	if err != nil || len(files) != 1 || !files[0].Current {
+		t.Errorf("Expected the manifest to only list the current file, got %+v (%v)", files, err)
+	}
+}
@@ -0,0 +1,55 @@
+package instance
+
+import (
+	"bufio"
+	"bytes"
+	"llamactl/pkg/config"
+	"regexp"
+	"strings"
+)
+
+// ansiEscapePattern matches CSI sequences (colors, cursor movement, line clearing), OSC
+// sequences (window titles, hyperlinks) and the remaining two byte escape sequences
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// maxProgressBuffer is the size up to which a line overwritten by carriage returns is
+// buffered whole. Beyond it the frames already overwritten are dropped as they arrive, so a
+// progress bar that never ends its line cannot outgrow the scanner buffer.
+const maxProgressBuffer = 32 * 1024
+
+// sanitizeLogLine cleans up a line of backend output according to the sanitization mode
+func sanitizeLogLine(line string, mode string) string {
+	if mode != config.LogSanitizeStrip && mode != config.LogSanitizeCollapse {
+		return line
+	}
+
+	line = strings.ToValidUTF8(line, "�")
+	line = ansiEscapePattern.ReplaceAllString(line, "")
+	if mode == config.LogSanitizeCollapse && strings.Contains(line, "\r") {
+		line = finalFrame(line)
+	}
+	return line
+}
+
+// finalFrame returns the last non-empty frame of a line overwritten by carriage returns,
+// which is what a terminal would show
+func finalFrame(line string) string {
+	frames := strings.Split(line, "\r")
+	for idx := len(frames) - 1; idx >= 0; idx-- {
+		if frames[idx] != "" {
+			return frames[idx]
+		}
+	}
+	return ""
+}
+
+// scanLines splits output into lines like bufio.ScanLines. When collapsing, the carriage
+// return frames of a line longer than maxProgressBuffer are dropped before its end arrives.
+func (i *InstanceLogger) scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if !atEOF && len(data) > maxProgressBuffer && i.sanitize.Load() == config.LogSanitizeCollapse && bytes.IndexByte(data, '\n') < 0 {
+		if cr := bytes.LastIndexByte(data, '\r'); cr >= 0 && cr < len(data)-1 {
+			return cr + 1, nil, nil
+		}
+	}
+	return bufio.ScanLines(data, atEOF)
+}
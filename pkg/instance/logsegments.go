@@ -0,0 +1,436 @@
+package instance
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// segmentTimeLayout is the timestamp in the names of rotated log files, e.g.
+// name-20240601T120000.log, so a file keeps its name once rotated
+const segmentTimeLayout = "20060102T150405"
+
+// LogSegment is a rotated log file of an instance, as indexed in its manifest
+type LogSegment struct {
+	File       string    `json:"file"`
+	Structured string    `json:"structured,omitempty"` // Structured log rotated along with it
+	Start      time.Time `json:"start"`                // Zero when unknown
+	End        time.Time `json:"end"`
+	Lines      int       `json:"lines"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"` // When indexed, so a file changed by other tools is indexed again
+}
+
+// logManifest indexes the rotated log files of an instance, oldest first, so the files
+// relevant to a request can be picked without opening all of them
+type logManifest struct {
+	CurrentStart time.Time    `json:"current_start"` // When the current log file was started, zero when unknown
+	Segments     []LogSegment `json:"segments"`
+}
+
+// overlaps reports whether the segment may hold lines logged between since and until,
+// either of which may be zero for no bound
+func (s LogSegment) overlaps(since, until time.Time) bool {
+	if !since.IsZero() && s.End.Before(since) {
+		return false
+	}
+	return until.IsZero() || s.Start.IsZero() || !s.Start.After(until)
+}
+
+// SetRotateSize sets the size in bytes at which the current log files are rotated, 0 to
+// never rotate them
+func (i *InstanceLogger) SetRotateSize(size int64) {
+	i.rotateSize.Store(max(size, 0))
+}
+
+// manifestPath returns the path of the manifest of the rotated log files. Like the
+// structured log, it does not start with the name of the text log.
+func (i *InstanceLogger) manifestPath() string {
+	return filepath.Join(i.logDir, i.name+".segments.json")
+}
+
+// isLegacyRotated reports whether a file is a log file of the instance rotated by other
+// tools, such as name.log.1 or name.log-20240601.gz
+func (i *InstanceLogger) isLegacyRotated(fileName string) bool {
+	current := i.currentLogName()
+	// Instance names cannot contain dots, so anything after "name.log" must be a rotation suffix
+	return len(fileName) > len(current) && strings.HasPrefix(fileName, current) &&
+		strings.ContainsAny(fileName[len(current):len(current)+1], ".-_")
+}
+
+// loadManifest reads the manifest and brings it up to date with the logs directory: files
+// that are gone or were changed by other tools are dropped, and files rotated by other tools
+// are indexed, taking their end from a timestamp in the name or the mtime and their start
+// from the end of the file before them (caller must hold manifestMu)
+func (i *InstanceLogger) loadManifest() (*logManifest, error) {
+	manifest := &logManifest{}
+	if data, err := os.ReadFile(i.manifestPath()); err == nil {
+		if err := json.Unmarshal(data, manifest); err != nil {
+			log.Printf("Warning: instance %s has an unreadable log manifest, indexing its log files again: %v", i.name, err)
+			manifest = &logManifest{}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read log manifest: %w", err)
+	}
+
+	entries, err := os.ReadDir(i.logDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read log directory: %w", err)
+	}
+	present := make(map[string]os.FileInfo, len(entries))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			present[entry.Name()] = info
+		}
+	}
+
+	changed := false
+	indexed := make(map[string]bool, len(manifest.Segments))
+	segments := make([]LogSegment, 0, len(manifest.Segments))
+	for _, segment := range manifest.Segments {
+		info, ok := present[segment.File]
+		if !ok || info.Size() != segment.Size || !info.ModTime().Equal(segment.ModTime) {
+			changed = true
+			continue
+		}
+		segments = append(segments, segment)
+		indexed[segment.File] = true
+	}
+
+	migrated := false
+	for fileName, info := range present {
+		if indexed[fileName] || !i.isLegacyRotated(fileName) {
+			continue
+		}
+		lines, err := countLines(filepath.Join(i.logDir, fileName))
+		if err != nil {
+			continue
+		}
+		segments = append(segments, LogSegment{
+			File:    fileName,
+			End:     i.rotatedTimestamp(fileName, info.ModTime()),
+			Lines:   lines,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		migrated = true
+	}
+
+	if migrated {
+		sort.SliceStable(segments, func(a, b int) bool { return segments[a].End.Before(segments[b].End) })
+		for n := 1; n < len(segments); n++ {
+			if segments[n].Start.IsZero() {
+				segments[n].Start = segments[n-1].End
+			}
+		}
+	}
+	manifest.Segments = segments
+
+	if changed || migrated {
+		if err := i.saveManifest(manifest); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// saveManifest replaces the manifest, writing it aside and renaming it so a crash never
+// leaves a partial manifest behind (caller must hold manifestMu)
+func (i *InstanceLogger) saveManifest(manifest *logManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode log manifest: %w", err)
+	}
+	path := i.manifestPath()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write log manifest: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write log manifest: %w", err)
+	}
+	return nil
+}
+
+// countWritten counts n bytes written to the current log file, rotating the log files once
+// it reached the rotation size (caller must hold the lock)
+func (i *InstanceLogger) countWritten(n int) {
+	i.written += int64(n)
+	if size := i.rotateSize.Load(); size > 0 && i.written >= size && i.logFile != nil {
+		i.rotate(time.Now())
+	}
+}
+
+// rotate renames the current log files after the time of the rotation, records them in the
+// manifest and reopens the log files empty (caller must hold the lock)
+func (i *InstanceLogger) rotate(now time.Time) {
+	i.manifestMu.Lock()
+	defer i.manifestMu.Unlock()
+
+	// Tried again after as many bytes if the rotation fails
+	i.written = 0
+
+	manifest, err := i.loadManifest()
+	if err != nil {
+		log.Printf("Instance %s: failed to rotate log files: %v", i.name, err)
+		return
+	}
+
+	stamp := now.Local().Format(segmentTimeLayout)
+	base := i.name + "-" + stamp
+	for n := 2; ; n++ {
+		if _, err := os.Stat(filepath.Join(i.logDir, base+".log")); os.IsNotExist(err) {
+			break
+		}
+		base = fmt.Sprintf("%s-%s-%d", i.name, stamp, n)
+	}
+
+	// The first rotation takes the start from the first line of the structured log
+	segment := LogSegment{File: base + ".log", Start: manifest.CurrentStart, End: now}
+	if segment.Start.IsZero() {
+		segment.Start = firstLogTime(i.structuredLogPath())
+	}
+
+	// Closed first, as open files cannot be renamed on Windows
+	i.logFile.Close()
+	i.logFile = nil
+	if i.structuredFile != nil {
+		i.structuredFile.Close()
+		i.structuredFile = nil
+	}
+
+	segment.Lines, _ = countLines(i.logFilePath)
+	segmentPath := filepath.Join(i.logDir, segment.File)
+	if err := os.Rename(i.logFilePath, segmentPath); err != nil {
+		log.Printf("Instance %s: failed to rotate log files: %v", i.name, err)
+	} else {
+		if info, err := os.Stat(segmentPath); err == nil {
+			segment.Size, segment.ModTime = info.Size(), info.ModTime()
+		}
+		if err := os.Rename(i.structuredLogPath(), filepath.Join(i.logDir, base+".jsonl")); err == nil {
+			segment.Structured = base + ".jsonl"
+		}
+		manifest.Segments = append(manifest.Segments, segment)
+		manifest.CurrentStart = now
+		if err := i.saveManifest(manifest); err != nil {
+			log.Printf("Instance %s: failed to record rotated log file %s: %v", i.name, segment.File, err)
+		}
+		if i.onRotate != nil {
+			i.onRotate()
+		}
+	}
+
+	if i.logFile, err = os.OpenFile(i.logFilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		log.Printf("Instance %s: failed to reopen log file after rotation: %v", i.name, err)
+		i.logFile = nil
+	}
+	if i.structuredFile, err = os.OpenFile(i.structuredLogPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		log.Printf("Instance %s: failed to reopen structured log file after rotation: %v", i.name, err)
+		i.structuredFile = nil
+	}
+}
+
+// RemoveFiles removes every log file of the instance: the current and rotated text and
+// structured logs and their manifest
+func (i *InstanceLogger) RemoveFiles() error {
+	if i.logDir == "" {
+		return nil
+	}
+	i.manifestMu.Lock()
+	defer i.manifestMu.Unlock()
+	manifest, err := i.loadManifest()
+	if err != nil {
+		return err
+	}
+
+	paths := []string{filepath.Join(i.logDir, i.currentLogName()), i.structuredLogPath()}
+	for _, segment := range manifest.Segments {
+		paths = append(paths, filepath.Join(i.logDir, segment.File))
+		if segment.Structured != "" {
+			paths = append(paths, filepath.Join(i.logDir, segment.Structured))
+		}
+	}
+	paths = append(paths, i.manifestPath(), i.outputPath(LogStreamStdout), i.outputPath(LogStreamStderr), i.processStatePath())
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove log file: %w", err)
+		}
+	}
+	return nil
+}
+
+// tailSegments returns the last n lines of the rotated log files, reading only the newest
+// files the manifest says are needed (caller must hold manifestMu)
+func (i *InstanceLogger) tailSegments(n int) ([]string, error) {
+	manifest, err := i.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	first := len(manifest.Segments)
+	for needed := n; first > 0 && needed > 0; {
+		first--
+		needed -= manifest.Segments[first].Lines
+	}
+
+	var lines []string
+	for _, segment := range manifest.Segments[first:] {
+		reader, err := openSegment(filepath.Join(i.logDir, segment.File))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		reader.Close()
+	}
+	return lines[max(len(lines)-n, 0):], nil
+}
+
+// GetStructuredLogsBetween returns a reader of the lines of the structured logs of the
+// instance logged between since and until, either of which may be zero for no bound. Only
+// the rotated files the manifest says overlap the range are read.
+func (i *Process) GetStructuredLogsBetween(since, until time.Time) (io.ReadCloser, error) {
+	if err := i.logsAvailable(); err != nil {
+		return nil, err
+	}
+	logger := i.logger
+
+	// Opened under the lock, so a rotation cannot move the current file away meanwhile
+	logger.manifestMu.Lock()
+	manifest, err := logger.loadManifest()
+	if err != nil {
+		logger.manifestMu.Unlock()
+		return nil, err
+	}
+	var files []*os.File
+	var lastEnd time.Time
+	for _, segment := range manifest.Segments {
+		lastEnd = segment.End
+		if segment.Structured == "" || !segment.overlaps(since, until) {
+			continue
+		}
+		if file, err := os.Open(filepath.Join(logger.logDir, segment.Structured)); err == nil {
+			files = append(files, file)
+		}
+	}
+	if until.IsZero() || !until.Before(lastEnd) {
+		if file, err := os.Open(logger.structuredLogPath()); err == nil {
+			files = append(files, file)
+		}
+	}
+	logger.manifestMu.Unlock()
+
+	reader, writer := io.Pipe()
+	go func() {
+		defer func() {
+			for _, file := range files {
+				file.Close()
+			}
+		}()
+		writer.CloseWithError(filterLogLines(writer, files, since, until))
+	}()
+	return reader, nil
+}
+
+// filterLogLines copies the structured log lines of files logged between since and until
+// to w
+func filterLogLines(w io.Writer, files []*os.File, since, until time.Time) error {
+	for _, file := range files {
+		reader := bufio.NewReader(file)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var entry struct {
+					Time time.Time `json:"ts"`
+				}
+				if json.Unmarshal(line, &entry) == nil &&
+					(since.IsZero() || !entry.Time.Before(since)) && (until.IsZero() || !entry.Time.After(until)) {
+					if _, err := w.Write(line); err != nil {
+						return err
+					}
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read log file: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// firstLogTime returns the time of the first line of a structured log, zero if unknown
+func firstLogTime(path string) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}
+	}
+	defer file.Close()
+	line, _ := bufio.NewReader(file).ReadBytes('\n')
+	var entry LogLine
+	json.Unmarshal(line, &entry)
+	return entry.Time
+}
+
+// openSegment opens a rotated log file, decompressing it if it is gzipped
+func openSegment(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return gzipFile{Reader: gz, file: file}, nil
+}
+
+// gzipFile closes the file a gzip reader reads from along with it
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (f gzipFile) Close() error {
+	f.Reader.Close()
+	return f.file.Close()
+}
+
+// countLines returns the number of lines of a log file
+func countLines(path string) (int, error) {
+	reader, err := openSegment(path)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	lines := 0
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := reader.Read(buf)
+		lines += bytes.Count(buf[:n], []byte{'\n'})
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
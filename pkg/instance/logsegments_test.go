@@ -0,0 +1,148 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRotation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// 25000 lines of 101 bytes fill two files of 1 MB
+	dir := t.TempDir()
+	backendConfig := &config.BackendConfig{LlamaCpp: config.BackendSettings{
+		Command: "sh",
+		Args:    []string{"-c", "yes $(printf '%0100d' 0) | head -n 25000; echo last line; exec sleep 30"},
+	}}
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		LogSanitize:        config.LogSanitizeOff,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+	inst := instance.NewInstance("rotated", backendConfig, &config.InstancesConfig{LogsDir: dir, LogRotateSizeMB: 1}, options, nil)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer inst.Stop()
+
+	deadline := time.Now().Add(20 * time.Second)
+	for {
+		if logs, _ := inst.GetLogs(1); logs == "last line" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the output to be logged")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	files, err := inst.GetLogFiles()
+	if err != nil {
+		t.Fatalf("GetLogFiles failed: %v", err)
+	}
+	if len(files) != 3 || !files[0].Current {
+		t.Fatalf("Expected the current file and two rotated files, got %+v", files)
+	}
+	segmentName := regexp.MustCompile(`^rotated-\d{8}T\d{6}(-\d+)?\.log$`)
+	totalLines := 0
+	for _, file := range files[1:] {
+		if !segmentName.MatchString(file.Name) {
+			t.Errorf("Expected a rotated file named after its rotation time, got %q", file.Name)
+		}
+		if file.Size < 1024*1024 || file.Lines == 0 || file.Start == nil || file.Start.After(file.Timestamp) {
+			t.Errorf("Expected the rotated file to be indexed with its range and lines, got %+v", file)
+		}
+		if _, err := os.Stat(filepath.Join(dir, strings.TrimSuffix(file.Name, ".log")+".jsonl")); err != nil {
+			t.Errorf("Expected the structured log to be rotated along: %v", err)
+		}
+		totalLines += file.Lines
+	}
+	if !files[1].Timestamp.After(files[2].Timestamp) {
+		t.Errorf("Expected rotated files from newest to oldest, got %+v", files[1:])
+	}
+
+	// The last lines span the rotated files
+	logs, err := inst.GetLogs(totalLines + 1)
+	if err != nil {
+		t.Fatalf("GetLogs failed: %v", err)
+	}
+	if count := strings.Count(logs, "\n") + 1; count != totalLines+1 {
+		t.Errorf("Expected %d lines, got %d", totalLines+1, count)
+	}
+	if !strings.HasSuffix(logs, "last line") {
+		t.Errorf("Expected the logs to end with the current file, got %q", logs[max(len(logs)-100, 0):])
+	}
+
+	t.Run("time range", func(t *testing.T) {
+		// Only lines logged by the end of the oldest rotated file
+		reader, err := inst.GetStructuredLogsBetween(time.Time{}, files[2].Timestamp)
+		if err != nil {
+			t.Fatalf("GetStructuredLogsBetween failed: %v", err)
+		}
+		defer reader.Close()
+		data, _ := io.ReadAll(reader)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) < files[2].Lines-2 || strings.Contains(string(data), "last line") {
+			t.Errorf("Expected the lines of the oldest rotated file, got %d lines", len(lines))
+		}
+		for _, line := range lines {
+			var entry instance.LogLine
+			if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Time.After(files[2].Timestamp) {
+				t.Fatalf("Expected structured lines up to the end of the range, got %q", line)
+			}
+		}
+	})
+}
+
+func TestInstanceLogger_Migration(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 6, 20, 12, 0, 0, 0, time.Local)
+
+	writeLogFile(t, dir, "test.log", now)
+	writeLogFile(t, dir, "test.log.1", now.Add(-24*time.Hour))
+	writeLogFile(t, dir, "test.log.2", now.Add(-48*time.Hour))
+
+	logger := instance.NewInstanceLogger("test", dir)
+	files, err := logger.ListFiles(now)
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+	if len(files) != 3 || files[1].Name != "test.log.1" || files[2].Name != "test.log.2" {
+		t.Fatalf("Expected the numbered files to be indexed, got %+v", files)
+	}
+	if files[1].Lines != 1 || files[1].Start == nil || !files[1].Start.Equal(now.Add(-48*time.Hour)) {
+		t.Errorf("Expected test.log.1 to start where test.log.2 ended, got %+v", files[1])
+	}
+	if files[2].Start != nil {
+		t.Errorf("Expected the start of the oldest file to be unknown, got %v", *files[2].Start)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test.segments.json")); err != nil {
+		t.Errorf("Expected the manifest to be written: %v", err)
+	}
+
+	// Files shuffled by another rotation are indexed again
+	os.Rename(filepath.Join(dir, "test.log.1"), filepath.Join(dir, "test.log.3"))
+	os.Remove(filepath.Join(dir, "test.log.2"))
+	files, err = logger.ListFiles(now)
+	if err != nil {
+		t.Fatalf("ListFiles failed: %v", err)
+	}
+	if len(files) != 2 || files[1].Name != "test.log.3" || !files[1].Timestamp.Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("Expected the manifest to follow the renamed file, got %+v", files)
+	}
+}
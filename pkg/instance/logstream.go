@@ -0,0 +1,213 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Streams a log line was written to
+const (
+	LogStreamStdout   = "stdout"
+	LogStreamStderr   = "stderr"
+	LogStreamLlamactl = "llamactl" // Start and stop markers written by llamactl
+	LogStreamHook     = "hook"     // Output of the on_start_cmd and on_stop_cmd lifecycle commands
+)
+
+// followerBuffer is the number of lines a follower may fall behind before it is
+// disconnected, so a slow consumer cannot hold up the output readers
+const followerBuffer = 1024
+
+// LogLine is a line of output of an instance, as kept in its structured log
+type LogLine struct {
+	Time   time.Time `json:"ts"`
+	Stream string    `json:"stream"`
+	Line   string    `json:"line"`
+}
+
+// structuredLogPath returns the path of the structured log. It does not start with the name
+// of the text log, so it is not taken for one of its rotated files.
+func (i *InstanceLogger) structuredLogPath() string {
+	return filepath.Join(i.logDir, i.name+".jsonl")
+}
+
+// writeStructured appends a line to the structured log and hands it to the followers
+// (caller must hold the lock)
+func (i *InstanceLogger) writeStructured(entry LogLine) {
+	if i.structuredFile != nil {
+		if data, err := json.Marshal(entry); err == nil {
+			i.structuredFile.Write(append(data, '\n'))
+			i.structuredFile.Sync()
+		}
+	}
+	for follower := range i.followers {
+		select {
+		case follower.lines <- entry:
+		default:
+			// Fell too far behind, ending its stream tells it lines were missed
+			delete(i.followers, follower)
+			close(follower.lines)
+		}
+	}
+}
+
+// LogFollower receives the lines an instance logs after it started following
+type LogFollower struct {
+	// History holds the last lines logged before following started, in the format
+	// requested, up to the first line sent on Lines
+	History io.Reader
+	// Lines receives the lines logged from then on, across restarts of the instance. It is
+	// closed if the follower falls too far behind.
+	Lines <-chan LogLine
+
+	lines   chan LogLine
+	logger  *InstanceLogger
+	history *os.File
+}
+
+// Close stops following and releases the history
+func (f *LogFollower) Close() {
+	f.logger.mu.Lock()
+	if _, ok := f.logger.followers[f]; ok {
+		delete(f.logger.followers, f)
+		close(f.lines)
+	}
+	f.logger.mu.Unlock()
+	if f.history != nil {
+		f.history.Close()
+	}
+}
+
+// logReader returns a reader of the last numLines lines of a log file, all lines if numLines
+// is not positive. A log file which does not exist yet reads as empty.
+func logReader(path string, numLines int) (io.Reader, *os.File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return eofReader{}, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to read log file: %w", err)
+	}
+	reader, err := tailReader(file, info.Size(), numLines)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return reader, file, nil
+}
+
+// tailReader returns a reader of the last numLines lines of the first size bytes of a file,
+// found by reading it backwards so a large log is not read whole
+func tailReader(file io.ReaderAt, size int64, numLines int) (io.Reader, error) {
+	if numLines <= 0 {
+		return io.NewSectionReader(file, 0, size), nil
+	}
+
+	buf := make([]byte, 64*1024)
+	found := 0
+	end := max(size-1, 0) // The newline ending the last line does not start another line
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := file.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read log file: %w", err)
+		}
+		for idx := len(chunk) - 1; idx >= 0; idx-- {
+			if chunk[idx] != '\n' {
+				continue
+			}
+			if found++; found == numLines {
+				offset := start + int64(idx) + 1
+				return io.NewSectionReader(file, offset, size-offset), nil
+			}
+		}
+		end = start
+	}
+	return io.NewSectionReader(file, 0, size), nil
+}
+
+// eofReader reads as empty
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+// logsAvailable returns an error if no logs are kept for the instance
+func (i *Process) logsAvailable() error {
+	if !i.IsManaged() {
+		return fmt.Errorf("logs are not available for instance %s: %w", i.Name, ErrUnmanaged)
+	}
+	if i.logger.logDir == "" {
+		return fmt.Errorf("logDir is empty for instance %s", i.Name)
+	}
+	return nil
+}
+
+// GetStructuredLogs returns a reader of the last n lines of the structured log of the
+// instance, one JSON encoded LogLine per line, all lines if n is not positive. The reader
+// reads from the file as it goes, so even a large log is not held in memory.
+func (i *Process) GetStructuredLogs(numLines int) (io.ReadCloser, error) {
+	if err := i.logsAvailable(); err != nil {
+		return nil, err
+	}
+	if i.logger.createdPath() == "" {
+		return nil, fmt.Errorf("log file not created for instance %s", i.Name)
+	}
+	reader, file, err := logReader(i.logger.structuredLogPath(), numLines)
+	if err != nil {
+		return nil, err
+	}
+	return logReadCloser{Reader: reader, file: file}, nil
+}
+
+// logReadCloser closes the log file it reads from, if any
+type logReadCloser struct {
+	io.Reader
+	file *os.File
+}
+
+func (r logReadCloser) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// FollowLogs follows the lines logged by the instance, starting with the last numLines lines
+// already logged, as text or as structured lines. History and Lines neither overlap nor miss
+// a line. An instance which has not logged yet can be followed as well. The follower must
+// be closed.
+func (i *Process) FollowLogs(numLines int, structured bool) (*LogFollower, error) {
+	if err := i.logsAvailable(); err != nil {
+		return nil, err
+	}
+	logger := i.logger
+	path := filepath.Join(logger.logDir, logger.currentLogName())
+	if structured {
+		path = logger.structuredLogPath()
+	}
+
+	// Lines are written under the lock, so the log ends exactly where the follower starts
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	history, file, err := logReader(path, numLines)
+	if err != nil {
+		return nil, err
+	}
+	follower := &LogFollower{History: history, lines: make(chan LogLine, followerBuffer), logger: logger, history: file}
+	follower.Lines = follower.lines
+
+	if logger.followers == nil {
+		logger.followers = map[*LogFollower]struct{}{}
+	}
+	logger.followers[follower] = struct{}{}
+	return follower, nil
+}
@@ -0,0 +1,162 @@
+package instance_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startLoggingInstance starts an instance whose backend writes a line to stdout and then one
+// to stderr, and waits for both to be logged
+func startLoggingInstance(t *testing.T) *instance.Process {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backendConfig := &config.BackendConfig{LlamaCpp: config.BackendSettings{
+		Command: "sh",
+		Args:    []string{"-c", "echo 'to stdout'; sleep 0.1; echo 'to stderr' >&2; exec sleep 30"},
+	}}
+	globalSettings := &config.InstancesConfig{LogsDir: t.TempDir()}
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+	inst := instance.NewInstance("log-instance", backendConfig, globalSettings, options, nil)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { inst.Stop() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		// The structured line is written right after the text line
+		if logs, err := inst.GetStructuredLogs(0); err == nil {
+			data, _ := io.ReadAll(logs)
+			logs.Close()
+			if strings.Contains(string(data), "to stderr") {
+				return inst
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for the output to be logged")
+	return nil
+}
+
+// readLogLines decodes the structured lines read from r
+func readLogLines(t *testing.T, r io.Reader) []instance.LogLine {
+	t.Helper()
+	var lines []instance.LogLine
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var line instance.LogLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Invalid structured log line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestGetStructuredLogs(t *testing.T) {
+	inst := startLoggingInstance(t)
+
+	logs, err := inst.GetStructuredLogs(0)
+	if err != nil {
+		t.Fatalf("GetStructuredLogs failed: %v", err)
+	}
+	lines := readLogLines(t, logs)
+	logs.Close()
+
+	if len(lines) != 3 {
+		t.Fatalf("Expected the start marker and two lines of output, got %+v", lines)
+	}
+	if lines[0].Stream != instance.LogStreamLlamactl || !strings.Contains(lines[0].Line, "started") {
+		t.Errorf("Expected the start marker first, got %+v", lines[0])
+	}
+	if lines[1] != (instance.LogLine{Time: lines[1].Time, Stream: instance.LogStreamStdout, Line: "to stdout"}) {
+		t.Errorf("Expected the stdout line, got %+v", lines[1])
+	}
+	if lines[2] != (instance.LogLine{Time: lines[2].Time, Stream: instance.LogStreamStderr, Line: "to stderr"}) {
+		t.Errorf("Expected the stderr line, got %+v", lines[2])
+	}
+	if lines[1].Time.IsZero() || lines[2].Time.Before(lines[1].Time) {
+		t.Errorf("Expected lines to carry the time they were logged, got %v and %v", lines[1].Time, lines[2].Time)
+	}
+
+	logs, err = inst.GetStructuredLogs(1)
+	if err != nil {
+		t.Fatalf("GetStructuredLogs failed: %v", err)
+	}
+	defer logs.Close()
+	if last := readLogLines(t, logs); len(last) != 1 || last[0].Line != "to stderr" {
+		t.Errorf("Expected only the last line, got %+v", last)
+	}
+}
+
+func TestFollowLogs(t *testing.T) {
+	tests := []struct {
+		name            string
+		structured      bool
+		expectedHistory string
+	}{
+		{"text", false, "to stdout\nto stderr\n"},
+		{"structured", true, `{"stream":"stdout","line":"to stdout"}` + "\n" + `{"stream":"stderr","line":"to stderr"}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := startLoggingInstance(t)
+
+			follower, err := inst.FollowLogs(2, tt.structured)
+			if err != nil {
+				t.Fatalf("FollowLogs failed: %v", err)
+			}
+			defer follower.Close()
+
+			history, err := io.ReadAll(follower.History)
+			if err != nil {
+				t.Fatalf("Failed to read history: %v", err)
+			}
+			if tt.structured {
+				// Drop the timestamps, which differ from run to run
+				var stripped strings.Builder
+				for _, line := range readLogLines(t, strings.NewReader(string(history))) {
+					data, _ := json.Marshal(struct {
+						Stream string `json:"stream"`
+						Line   string `json:"line"`
+					}{line.Stream, line.Line})
+					stripped.Write(append(data, '\n'))
+				}
+				history = []byte(stripped.String())
+			}
+			if string(history) != tt.expectedHistory {
+				t.Errorf("Expected history %q, got %q", tt.expectedHistory, history)
+			}
+
+			// Lines logged from then on are sent to the follower
+			if err := inst.Stop(); err != nil {
+				t.Fatalf("Stop failed: %v", err)
+			}
+			select {
+			case line := <-follower.Lines:
+				if line.Stream != instance.LogStreamLlamactl || !strings.Contains(line.Line, "stopped") {
+					t.Errorf("Expected the stop marker, got %+v", line)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for the stop marker")
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package instance_test
+
+import (
+	"llamactl/pkg/instance"
+	"llamactl/pkg/memory"
+	"strings"
+	"testing"
+)
+
+func TestOutputTails_MemoryLimit(t *testing.T) {
+	// 500 lines of 2000 bytes on both streams, then a crash
+	script := `line=$(printf '%2000s' | tr ' ' x); i=0; while [ $i -lt 500 ]; do echo "$i $line"; echo "$i $line" >&2; i=$((i+1)); done; exit 1`
+	recorder := newTransitionRecorder()
+	inst := newShellInstance(t, script, false, recorder.record)
+	accountant := memory.NewAccountant(32<<10, 0)
+	inst.SetMemoryAccountant(accountant)
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	recorder.waitFor(t, instance.Failed)
+
+	snapshot := accountant.Snapshot()
+	usage := snapshot.Instances["test-instance"]
+	if usage.Bytes == 0 || usage.Bytes > 32<<10 {
+		t.Errorf("Expected the output tails to stay within the instance limit, got %+v", usage)
+	}
+	if snapshot.Evictions["stderr_tail"]+snapshot.Evictions["output_tail"] == 0 {
+		t.Errorf("Expected evictions to be counted, got %v", snapshot.Evictions)
+	}
+
+	// The tails keep the last lines
+	lastExit := inst.GetLastExit()
+	if lastExit == nil || len(lastExit.Output) == 0 || !strings.HasPrefix(lastExit.Output[len(lastExit.Output)-1], "499 ") {
+		t.Fatalf("Expected the last crash to end with the last line, got %+v", lastExit)
+	}
+
+	// Deleting the instance frees its tails
+	if err := inst.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if bytes := accountant.Snapshot().Total.Bytes; bytes != 0 {
+		t.Errorf("Expected nothing charged once the instance is closed, got %d bytes", bytes)
+	}
+}
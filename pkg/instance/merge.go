@@ -0,0 +1,257 @@
+package instance
+
+import (
+	"encoding/json"
+	"fmt"
+	"llamactl/pkg/config"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// OptionSource names the layer the effective value of an option came from
+type OptionSource string
+
+const (
+	SourceDefault  OptionSource = "default"  // Global instance defaults
+	SourceExplicit OptionSource = "explicit" // Set in the instance's own options
+	SourceInferred OptionSource = "inferred" // Filled in by llamactl, e.g. an assigned port
+)
+
+// SourceTemplate is the source of values contributed by the named template
+func SourceTemplate(name string) OptionSource {
+	return OptionSource("template:" + name)
+}
+
+// backendOptionPrefix qualifies backend option keys in OptionSources
+const backendOptionPrefix = "backend_options."
+
+// OptionSources maps every set option, by JSON name, to its source. Backend options
+// are keyed "backend_options.<key>".
+type OptionSources map[string]OptionSource
+
+// OptionLayer is a set of options contributed by a single source
+type OptionLayer struct {
+	Source  OptionSource
+	Options *CreateInstanceOptions
+}
+
+// EffectiveOption is the effective value of an option along with its source
+type EffectiveOption struct {
+	Value  any          `json:"value"`
+	Source OptionSource `json:"source"`
+}
+
+// MergeOptions merges layers in increasing order of precedence: a field set in a later
+// layer overrides the earlier ones. The backend options are taken as a whole from the
+// last layer that sets them. The returned sources record the layer of every set field.
+func MergeOptions(layers ...OptionLayer) (*CreateInstanceOptions, OptionSources) {
+	merged := &CreateInstanceOptions{}
+	sources := make(OptionSources)
+
+	dst := reflect.ValueOf(merged).Elem()
+	fields := dst.Type()
+	for _, layer := range layers {
+		if layer.Options == nil {
+			continue
+		}
+		src := reflect.ValueOf(layer.Options).Elem()
+
+		backendSet := false
+		for i := 0; i < fields.NumField(); i++ {
+			value := src.Field(i)
+			if !fields.Field(i).IsExported() || value.IsZero() {
+				continue
+			}
+			dst.Field(i).Set(value)
+
+			name := jsonName(fields.Field(i))
+			switch name {
+			case "-":
+				backendSet = true
+			case "backend_options":
+				// Derived from the backend-specific options, recorded per key below
+			default:
+				sources[name] = layer.Source
+			}
+		}
+
+		if backendSet {
+			for key := range sources {
+				if strings.HasPrefix(key, backendOptionPrefix) {
+					delete(sources, key)
+				}
+			}
+			for _, key := range backendOptionKeys(layer.Options) {
+				sources[backendOptionPrefix+key] = layer.Source
+			}
+		}
+	}
+
+	return merged, sources
+}
+
+// DefaultOptions returns the options layer holding the global instance defaults
+func DefaultOptions(globalSettings *config.InstancesConfig) *CreateInstanceOptions {
+	autoRestart := globalSettings.DefaultAutoRestart
+	maxRestarts := globalSettings.DefaultMaxRestarts
+	restartDelay := globalSettings.DefaultRestartDelay
+	onDemandStart := globalSettings.DefaultOnDemandStart
+	idleTimeout := config.Duration(0)
+	logRetentionDays := globalSettings.LogRetentionDays
+	slotRetentionHours := globalSettings.SlotRetentionHours
+	managed := true
+
+	return &CreateInstanceOptions{
+		AutoRestart:        &autoRestart,
+		MaxRestarts:        &maxRestarts,
+		RestartDelay:       &restartDelay,
+		OnDemandStart:      &onDemandStart,
+		IdleTimeout:        &idleTimeout,
+		LogRetentionDays:   &logRetentionDays,
+		SlotRetentionHours: &slotRetentionHours,
+		LogSanitize:        globalSettings.LogSanitize,
+		Managed:            &managed,
+	}
+}
+
+// ExplainOptions returns every effective option of the instance with its source
+func (i *Process) ExplainOptions() (map[string]EffectiveOption, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	values, err := optionValues(i.options.MaskedSecrets())
+	if err != nil {
+		return nil, err
+	}
+
+	explained := make(map[string]EffectiveOption, len(values))
+	for name, value := range values {
+		source, ok := i.optionSources[name]
+		if !ok {
+			source = SourceExplicit
+		}
+		explained[name] = EffectiveOption{Value: value, Source: source}
+	}
+	return explained, nil
+}
+
+// GetOptionSources returns the source of every set option
+func (i *Process) GetOptionSources() OptionSources {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	sources := make(OptionSources, len(i.optionSources))
+	for name, source := range i.optionSources {
+		sources[name] = source
+	}
+	return sources
+}
+
+// MarkInferred records that llamactl filled in the given option, e.g. "backend_options.port"
+func (i *Process) MarkInferred(name string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.optionSources == nil {
+		i.optionSources = make(OptionSources)
+	}
+	i.optionSources[name] = SourceInferred
+}
+
+// DiffOptions returns the options, by JSON name, whose values differ between current and
+// desired, sorted. Backend options are keyed "backend_options.<key>".
+func DiffOptions(current, desired *CreateInstanceOptions) ([]string, error) {
+	differences, err := CompareOptions(current, desired)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, difference := range differences {
+		changed = append(changed, difference.Field)
+	}
+	return changed, nil
+}
+
+// ValueDifference is a field whose value differs between two states of an instance, with
+// its value in each, nil where it is unset
+type ValueDifference struct {
+	Field string
+	A, B  any
+}
+
+// CompareOptions returns the options whose values differ between a and b, sorted by JSON
+// name, with backend options keyed "backend_options.<key>"
+func CompareOptions(a, b *CreateInstanceOptions) ([]ValueDifference, error) {
+	aValues, err := optionValues(a)
+	if err != nil {
+		return nil, err
+	}
+	bValues, err := optionValues(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var differences []ValueDifference
+	for name, value := range bValues {
+		if !reflect.DeepEqual(aValues[name], value) {
+			differences = append(differences, ValueDifference{Field: name, A: aValues[name], B: value})
+		}
+	}
+	for name, value := range aValues {
+		if _, ok := bValues[name]; !ok {
+			differences = append(differences, ValueDifference{Field: name, A: value})
+		}
+	}
+	sort.Slice(differences, func(x, y int) bool { return differences[x].Field < differences[y].Field })
+	return differences, nil
+}
+
+// optionValues flattens options into their JSON values, with backend options keyed
+// "backend_options.<key>"
+func optionValues(options *CreateInstanceOptions) (map[string]any, error) {
+	if options == nil {
+		return map[string]any{}, nil
+	}
+
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal options: %w", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal options: %w", err)
+	}
+
+	if backendOptions, ok := values["backend_options"].(map[string]any); ok {
+		delete(values, "backend_options")
+		for key, value := range backendOptions {
+			values[backendOptionPrefix+key] = value
+		}
+	}
+	return values, nil
+}
+
+// backendOptionKeys returns the keys of the backend options that are set, sorted
+func backendOptionKeys(options *CreateInstanceOptions) []string {
+	values, err := optionValues(options)
+	if err != nil {
+		return nil
+	}
+
+	var keys []string
+	for name := range values {
+		if key, ok := strings.CutPrefix(name, backendOptionPrefix); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,120 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"testing"
+	"time"
+)
+
+func TestMergeOptions_TracksSources(t *testing.T) {
+	defaults := &instance.CreateInstanceOptions{
+		AutoRestart: testutil.BoolPtr(true),
+		MaxRestarts: testutil.IntPtr(3),
+		IdleTimeout: testutil.DurationPtr(0),
+	}
+	template := &instance.CreateInstanceOptions{
+		MaxRestarts: testutil.IntPtr(10),
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model:     "/models/base.gguf",
+			GPULayers: 99,
+			CtxSize:   4096,
+		},
+	}
+	explicit := &instance.CreateInstanceOptions{
+		IdleTimeout: testutil.DurationPtr(30 * time.Minute),
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/models/tuned.gguf",
+			Port:  8080,
+		},
+	}
+
+	merged, sources := instance.MergeOptions(
+		instance.OptionLayer{Source: instance.SourceDefault, Options: defaults},
+		instance.OptionLayer{Source: instance.SourceTemplate("gpu"), Options: template},
+		instance.OptionLayer{Source: instance.SourceExplicit, Options: explicit},
+	)
+
+	if *merged.AutoRestart != true || *merged.MaxRestarts != 10 || merged.IdleTimeout.Duration() != 30*time.Minute {
+		t.Errorf("unexpected merged options: auto_restart=%v max_restarts=%d idle_timeout=%s",
+			*merged.AutoRestart, *merged.MaxRestarts, *merged.IdleTimeout)
+	}
+	if merged.LlamaServerOptions.Model != "/models/tuned.gguf" || merged.LlamaServerOptions.CtxSize != 0 {
+		t.Errorf("expected the backend options of the last layer, got %+v", merged.LlamaServerOptions)
+	}
+
+	expected := instance.OptionSources{
+		"auto_restart":          instance.SourceDefault,
+		"max_restarts":          "template:gpu",
+		"idle_timeout":          instance.SourceExplicit,
+		"backend_type":          instance.SourceExplicit,
+		"backend_options.model": instance.SourceExplicit,
+		"backend_options.port":  instance.SourceExplicit,
+	}
+	if len(sources) != len(expected) {
+		t.Errorf("expected sources %v, got %v", expected, sources)
+	}
+	for name, source := range expected {
+		if sources[name] != source {
+			t.Errorf("%s: expected source %q, got %q", name, source, sources[name])
+		}
+	}
+}
+
+func TestExplainOptions(t *testing.T) {
+	globalSettings := &config.InstancesConfig{
+		LogsDir:            t.TempDir(),
+		DefaultAutoRestart: true,
+		DefaultMaxRestarts: 3,
+	}
+	options := &instance.CreateInstanceOptions{
+		MaxRestarts: testutil.IntPtr(7),
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Port:  8080,
+		},
+	}
+	inst := instance.NewInstance("test-instance", &config.BackendConfig{}, globalSettings, options, nil)
+	inst.MarkInferred("backend_options.port")
+
+	check := func(t *testing.T, inst *instance.Process) {
+		t.Helper()
+		explained, err := inst.ExplainOptions()
+		if err != nil {
+			t.Fatalf("ExplainOptions failed: %v", err)
+		}
+
+		expected := map[string]instance.EffectiveOption{
+			"auto_restart":          {Value: true, Source: instance.SourceDefault},
+			"max_restarts":          {Value: float64(7), Source: instance.SourceExplicit},
+			"managed":               {Value: true, Source: instance.SourceDefault},
+			"backend_options.model": {Value: "/path/to/model.gguf", Source: instance.SourceExplicit},
+			"backend_options.port":  {Value: float64(8080), Source: instance.SourceInferred},
+		}
+		for name, option := range expected {
+			if explained[name] != option {
+				t.Errorf("%s: expected %+v, got %+v", name, option, explained[name])
+			}
+		}
+	}
+
+	check(t, inst)
+
+	// Sources survive persistence, even though the defaults are baked into the options
+	data, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatalf("JSON marshal failed: %v", err)
+	}
+	var restored instance.Process
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("JSON unmarshal failed: %v", err)
+	}
+	check(t, &restored)
+}
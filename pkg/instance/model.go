@@ -0,0 +1,106 @@
+package instance
+
+import (
+	"fmt"
+	"llamactl/pkg/backends"
+)
+
+// HasRemoteModel reports whether the instance's model is downloaded from the control plane
+func (c *CreateInstanceOptions) HasRemoteModel() bool {
+	return c != nil && c.RemoteModel != ""
+}
+
+// ModelPath returns the model the backend options point at
+func (c *CreateInstanceOptions) ModelPath() string {
+	if c == nil {
+		return ""
+	}
+	switch c.BackendType {
+	case backends.BackendTypeLlamaCpp:
+		if c.LlamaServerOptions != nil {
+			return c.LlamaServerOptions.Model
+		}
+	case backends.BackendTypeMlxLm:
+		if c.MlxServerOptions != nil {
+			return c.MlxServerOptions.Model
+		}
+	case backends.BackendTypeVllm:
+		if c.VllmServerOptions != nil {
+			return c.VllmServerOptions.Model
+		}
+	}
+	return ""
+}
+
+// withModelPath returns a copy of the options whose backend runs the model file at path
+func (c *CreateInstanceOptions) withModelPath(path string) *CreateInstanceOptions {
+	opts := *c
+	switch c.BackendType {
+	case backends.BackendTypeLlamaCpp:
+		if c.LlamaServerOptions != nil {
+			backendOpts := *c.LlamaServerOptions
+			backendOpts.Model = path
+			opts.LlamaServerOptions = &backendOpts
+		}
+	case backends.BackendTypeMlxLm:
+		if c.MlxServerOptions != nil {
+			backendOpts := *c.MlxServerOptions
+			backendOpts.Model = path
+			opts.MlxServerOptions = &backendOpts
+		}
+	case backends.BackendTypeVllm:
+		if c.VllmServerOptions != nil {
+			backendOpts := *c.VllmServerOptions
+			backendOpts.Model = path
+			opts.VllmServerOptions = &backendOpts
+		}
+	}
+	return &opts
+}
+
+// commandOptions returns the options the command line is built from: the remote model,
+// once downloaded, replaces the model of the backend options, and the address resolved
+// from bind_interface replaces the bind host, and slots are saved to the slot directory of
+// the instance when they are preserved across restarts (caller must hold the lock)
+func (i *Process) commandOptions() *CreateInstanceOptions {
+	return i.commandOptionsFor(i.options, i.bindAddress, i.backendKey)
+}
+
+// commandOptionsFor is commandOptions for a process run with options, bound to bindAddress
+// and given backendKey (caller must hold the lock)
+func (i *Process) commandOptionsFor(options *CreateInstanceOptions, bindAddress, backendKey string) *CreateInstanceOptions {
+	opts := options
+	if opts.HasRemoteModel() && i.modelPath != "" {
+		opts = opts.withModelPath(i.modelPath)
+	}
+	if bindAddress != "" {
+		bound := *opts
+		bound.BindHost = bindAddress
+		opts = &bound
+	}
+	if i.preservesSlots() && opts.LlamaServerOptions.SlotSavePath == "" {
+		if dir := i.slotDir(); dir != "" {
+			opts = opts.withSlotSavePath(dir)
+		}
+	}
+	if backendKey != "" {
+		opts = opts.withAPIKey(backendKey)
+	}
+	return opts
+}
+
+// SetModelPath records where the remote model of the instance was downloaded to
+func (i *Process) SetModelPath(path string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.modelPath = path
+	i.refreshModelSize()
+}
+
+// ReportModelDownload surfaces the progress of the remote model download in the status
+// reason of the instance, without changing its status
+func (i *Process) ReportModelDownload(percent int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.SetStatus(i.GetStatus(), ReasonModelDownload, fmt.Sprintf("downloading model %d%%", percent))
+}
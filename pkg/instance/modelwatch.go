@@ -0,0 +1,176 @@
+package instance
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Policies for running instances whose model files change on disk
+const (
+	ModelChangeFlag    = "flag"    // Report the change and leave the instance running
+	ModelChangeRestart = "restart" // Also recycle the instance once its requests finished
+)
+
+// ModelChange reports that the model files of the running process changed on disk since
+// it loaded them, so the process serves a different model than a new start would
+type ModelChange struct {
+	Path       string    `json:"path"`        // Model file found changed
+	DetectedAt time.Time `json:"detected_at"` // When the change settled
+}
+
+// modelFile is what a model file is compared by. Modification times are compared to the
+// second, since NFS clients may report them with varying sub-second precision.
+type modelFile struct {
+	size    int64
+	modTime int64
+}
+
+// modelFingerprint maps the files of a model to their size and modification time
+type modelFingerprint map[string]modelFile
+
+// fingerprintModel returns the fingerprint of a model: a file, all the parts of a split GGUF
+// model, or the files of a model directory. It returns nil if the model is not a local path.
+func fingerprintModel(path string) modelFingerprint {
+	if path == "" {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	fingerprint := modelFingerprint{}
+	add := func(file string, info fs.FileInfo) {
+		fingerprint[file] = modelFile{size: info.Size(), modTime: info.ModTime().Unix()}
+	}
+	if !info.IsDir() {
+		match := splitGGUFPattern.FindStringSubmatch(path)
+		if match == nil {
+			add(path, info)
+			return fingerprint
+		}
+		parts, _ := filepath.Glob(match[1] + "-*-of-" + match[2] + ".gguf")
+		for _, part := range parts {
+			if partInfo, err := os.Stat(part); err == nil {
+				add(part, partInfo)
+			}
+		}
+		return fingerprint
+	}
+
+	filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if entryInfo, err := entry.Info(); err == nil {
+				add(file, entryInfo)
+			}
+		}
+		return nil
+	})
+	return fingerprint
+}
+
+// changedFile returns the first file, in path order, that differs between two fingerprints,
+// or "" if they are equal
+func (f modelFingerprint) changedFile(other modelFingerprint) string {
+	var changed []string
+	for file, info := range f {
+		if otherInfo, ok := other[file]; !ok || otherInfo != info {
+			changed = append(changed, file)
+		}
+	}
+	for file := range other {
+		if _, ok := f[file]; !ok {
+			changed = append(changed, file)
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+	sort.Strings(changed)
+	return changed[0]
+}
+
+// modelWatch tracks the model files of the running process
+type modelWatch struct {
+	loaded       modelFingerprint // Files when the process started
+	pending      modelFingerprint // Changed files waiting to settle
+	pendingSince time.Time
+}
+
+// runModelPath returns the local path of the model the process runs (caller must hold the lock)
+func (i *Process) runModelPath() string {
+	if i.options.HasRemoteModel() {
+		return i.modelPath
+	}
+	return i.options.ModelPath()
+}
+
+// armModelWatch records the model files the starting process loads and clears a reported
+// change, which the new process no longer serves (caller must hold the lock)
+func (i *Process) armModelWatch() {
+	i.modelWatch = modelWatch{loaded: fingerprintModel(i.runModelPath())}
+	i.ModelChange = nil
+}
+
+// CheckModelFiles compares the model files of the running process with the files on disk.
+// A change is only reported once the files stayed the same for settle, so a file still being
+// copied or a flapping NFS attribute cache is not reported. It returns the change and whether
+// it was detected by this check.
+func (i *Process) CheckModelFiles(settle time.Duration) (*ModelChange, bool) {
+	i.mu.RLock()
+	if !i.IsRunning() || !i.options.IsManaged() || i.modelWatch.loaded == nil || i.ModelChange != nil {
+		change := i.ModelChange
+		i.mu.RUnlock()
+		return change, false
+	}
+	path, monitorDone := i.runModelPath(), i.monitorDone
+	i.mu.RUnlock()
+
+	// Read outside the lock, a model directory on a network share may be slow to walk
+	current := fingerprintModel(path)
+	now := i.timeProvider.Now()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.monitorDone != monitorDone || !i.IsRunning() {
+		return nil, false // Restarted meanwhile, the new process loaded the current files
+	}
+
+	watch := &i.modelWatch
+	if watch.loaded.changedFile(current) == "" {
+		watch.pending, watch.pendingSince = nil, time.Time{} // Changed back, or never changed
+		return nil, false
+	}
+	if watch.pendingSince.IsZero() || watch.pending.changedFile(current) != "" {
+		watch.pending, watch.pendingSince = current, now
+		return nil, false
+	}
+	if now.Sub(watch.pendingSince) < settle {
+		return nil, false
+	}
+
+	i.ModelChange = &ModelChange{Path: watch.loaded.changedFile(current), DetectedAt: now}
+	return i.ModelChange, true
+}
+
+// GetModelChange returns the change of the model files of the running process, nil if
+// they did not change
+func (i *Process) GetModelChange() *ModelChange {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.ModelChange
+}
+
+// ModelChangePolicy returns what is done when the model files of the instance change
+func (c *CreateInstanceOptions) ModelChangePolicy() string {
+	if c == nil || c.OnModelChange == "" {
+		return ModelChangeFlag
+	}
+	return c.OnModelChange
+}
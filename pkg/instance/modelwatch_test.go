@@ -0,0 +1,130 @@
+package instance_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCheckModelFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	model := filepath.Join(dir, "model.gguf")
+	if err := os.WriteFile(model, []byte("old quantization"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now().Add(-time.Hour)
+	os.Chtimes(model, started, started)
+
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "sleep 30"}},
+	}
+	globalSettings := &config.InstancesConfig{LogsDir: t.TempDir()}
+	options := &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		AutoRestart: testutil.BoolPtr(false),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: model,
+			Port:  8080,
+		},
+	}
+	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, nil)
+	clock := NewMockTimeProvider(time.Now())
+	inst.SetTimeProvider(clock)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer inst.Stop()
+
+	settle := time.Minute
+	if _, detected := inst.CheckModelFiles(settle); detected {
+		t.Fatal("Expected no change before the model file is overwritten")
+	}
+
+	// Overwritten in place with a new quantization
+	if err := os.WriteFile(model, []byte("new quantization, larger"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, detected := inst.CheckModelFiles(settle); detected {
+		t.Error("Expected the change not to be reported before it settled")
+	}
+	clock.SetTime(clock.Now().Add(30 * time.Second))
+	if _, detected := inst.CheckModelFiles(settle); detected {
+		t.Error("Expected the change not to be reported before it settled")
+	}
+
+	clock.SetTime(clock.Now().Add(time.Minute))
+	change, detected := inst.CheckModelFiles(settle)
+	if !detected || change == nil || change.Path != model {
+		t.Fatalf("Expected the changed model file to be reported once settled, got %+v", change)
+	}
+	if inst.GetModelChange() == nil {
+		t.Error("Expected the instance to be flagged")
+	}
+	if _, detected := inst.CheckModelFiles(settle); detected {
+		t.Error("Expected the change to be reported only once")
+	}
+
+	// The next start loads the new file
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if inst.GetModelChange() != nil {
+		t.Error("Expected the flag to be cleared once the instance started again")
+	}
+}
+
+func TestCheckModelFiles_ChangedBack(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	model := filepath.Join(dir, "model.gguf")
+	if err := os.WriteFile(model, []byte("model"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	original := time.Now().Add(-time.Hour)
+	os.Chtimes(model, original, original)
+
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "sleep 30"}},
+	}
+	globalSettings := &config.InstancesConfig{LogsDir: t.TempDir()}
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: model, Port: 8080},
+	}
+	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, nil)
+	clock := NewMockTimeProvider(time.Now())
+	inst.SetTimeProvider(clock)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer inst.Stop()
+
+	// An NFS client briefly reporting another modification time
+	flapped := original.Add(time.Second)
+	os.Chtimes(model, flapped, flapped)
+	inst.CheckModelFiles(time.Minute)
+	os.Chtimes(model, original, original)
+	clock.SetTime(clock.Now().Add(2 * time.Minute))
+
+	if change, detected := inst.CheckModelFiles(time.Minute); detected {
+		t.Errorf("Expected a change that reverted not to be reported, got %+v", change)
+	}
+}
@@ -0,0 +1,36 @@
+package instance
+
+import "time"
+
+// Observer receives the lifecycle moments of an instance that its status changes do not
+// carry. Its functions are called synchronously, the status and crash ones with the
+// instance lock held: they must return quickly and must not call back into the instance.
+// Any of them may be nil.
+type Observer struct {
+	Crashed          func(exit LastExit)             // The backend crashed, before its restart or failure
+	RestartScheduled func(attempt int, at time.Time) // A restart of the crashed backend is pending until at
+	LogLine          func(line LogLine)              // The backend wrote a line to stdout or stderr
+	StartupProgress  func(progress StartupProgress)  // The starting backend advanced its startup
+	LogRotated       func()                          // The log files were rotated
+}
+
+// SetObserver sets the observer of the lifecycle moments of the instance, nil removing it
+func (i *Process) SetObserver(observer *Observer) {
+	i.observer.Store(observer)
+}
+
+// onOutputLine handles a line the backend wrote, after it was logged
+func (i *Process) onOutputLine(line LogLine) {
+	i.checkFatalLog(line.Line)
+	i.trackStartupProgress(line.Line)
+	if observer := i.observer.Load(); observer != nil && observer.LogLine != nil {
+		observer.LogLine(line)
+	}
+}
+
+// onLogRotated handles a rotation of the log files
+func (i *Process) onLogRotated() {
+	if observer := i.observer.Load(); observer != nil && observer.LogRotated != nil {
+		observer.LogRotated()
+	}
+}
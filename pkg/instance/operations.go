@@ -0,0 +1,226 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OperationType is a lifecycle operation that changes the state of an instance
+type OperationType string
+
+const (
+	OperationStart   OperationType = "start"
+	OperationStop    OperationType = "stop"
+	OperationRestart OperationType = "restart"
+	OperationReload  OperationType = "reload"
+)
+
+// OperationState is the progress or outcome of an operation
+type OperationState string
+
+const (
+	OperationQueued    OperationState = "queued"
+	OperationRunning   OperationState = "running"
+	OperationSucceeded OperationState = "succeeded"
+	OperationFailed    OperationState = "failed"
+	OperationCancelled OperationState = "cancelled"
+)
+
+// maxRecentOperations bounds the history of finished operations kept per instance
+const maxRecentOperations = 20
+
+// Operation records a single lifecycle operation on an instance
+type Operation struct {
+	ID         string         `json:"id"`
+	Type       OperationType  `json:"type"`
+	State      OperationState `json:"state"`
+	Error      string         `json:"error,omitempty"`
+	QueuedAt   time.Time      `json:"queued_at"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+// OperationsSnapshot lists the current, waiting and recently finished operations of an instance
+type OperationsSnapshot struct {
+	Current *Operation  `json:"current,omitempty"`
+	Queued  []Operation `json:"queued"`
+	Recent  []Operation `json:"recent"` // Most recent first
+}
+
+// OperationInProgressError is returned when an operation is requested without waiting
+// while another one is in progress on the same instance
+type OperationInProgressError struct {
+	Instance  string    `json:"instance"`
+	Operation Operation `json:"operation"`
+}
+
+func (e *OperationInProgressError) Error() string {
+	return fmt.Sprintf("instance %s has a %s operation in progress (%s)", e.Instance, e.Operation.Type, e.Operation.ID)
+}
+
+type operationWaiter struct {
+	op    *Operation
+	ready chan struct{}
+}
+
+// OperationQueue serializes lifecycle operations on an instance. Only one operation runs at
+// a time; others are either rejected or wait their turn in arrival order, and a waiting
+// operation can be cancelled through its context.
+type OperationQueue struct {
+	name    string
+	mu      sync.Mutex
+	nextID  uint64
+	current *Operation
+	waiters []*operationWaiter
+	recent  []Operation
+	now     func() time.Time
+}
+
+// NewOperationQueue creates an operation queue for the named instance
+func NewOperationQueue(name string) *OperationQueue {
+	return &OperationQueue{name: name, now: time.Now}
+}
+
+// Begin starts an operation and returns a function that records its outcome and lets the
+// next operation run. If another operation is in progress, Begin returns an
+// *OperationInProgressError unless wait is set, in which case it waits until the operation's
+// turn or until ctx is done.
+func (q *OperationQueue) Begin(ctx context.Context, opType OperationType, wait bool) (func(error), error) {
+	q.mu.Lock()
+
+	q.nextID++
+	op := &Operation{
+		ID:       fmt.Sprintf("%s-%d", q.name, q.nextID),
+		Type:     opType,
+		State:    OperationQueued,
+		QueuedAt: q.now(),
+	}
+
+	// Waiters are only queued behind a current operation, so none are waiting if there is none
+	if q.current == nil {
+		q.run(op)
+		q.mu.Unlock()
+		return q.finishFunc(op), nil
+	}
+
+	if !wait {
+		blocking := *q.current
+		q.mu.Unlock()
+		return nil, &OperationInProgressError{Instance: q.name, Operation: blocking}
+	}
+
+	w := &operationWaiter{op: op, ready: make(chan struct{})}
+	q.waiters = append(q.waiters, w)
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return q.finishFunc(op), nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		if q.current == op {
+			// Started concurrently with cancellation, hand the turn on
+			q.finishLocked(op, OperationCancelled, ctx.Err())
+		} else {
+			q.removeWaiter(w)
+			q.recordLocked(op, OperationCancelled, ctx.Err())
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Snapshot returns the current, queued and recent operations
+func (q *OperationQueue) Snapshot() OperationsSnapshot {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	snapshot := OperationsSnapshot{
+		Queued: make([]Operation, 0, len(q.waiters)),
+		Recent: make([]Operation, 0, len(q.recent)),
+	}
+	if q.current != nil {
+		current := *q.current
+		snapshot.Current = &current
+	}
+	for _, w := range q.waiters {
+		snapshot.Queued = append(snapshot.Queued, *w.op)
+	}
+	for idx := len(q.recent) - 1; idx >= 0; idx-- {
+		snapshot.Recent = append(snapshot.Recent, q.recent[idx])
+	}
+	return snapshot
+}
+
+// run makes op the current operation (caller must hold the lock)
+func (q *OperationQueue) run(op *Operation) {
+	started := q.now()
+	op.State = OperationRunning
+	op.StartedAt = &started
+	q.current = op
+}
+
+func (q *OperationQueue) finishFunc(op *Operation) func(error) {
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			state := OperationSucceeded
+			if err != nil {
+				state = OperationFailed
+			}
+			q.finishLocked(op, state, err)
+		})
+	}
+}
+
+// finishLocked records the outcome of the current operation and starts the next waiter
+func (q *OperationQueue) finishLocked(op *Operation, state OperationState, err error) {
+	q.recordLocked(op, state, err)
+	q.current = nil
+
+	if len(q.waiters) > 0 {
+		w := q.waiters[0]
+		q.waiters = q.waiters[1:]
+		q.run(w.op)
+		close(w.ready)
+	}
+}
+
+// recordLocked marks op as finished and adds it to the history
+func (q *OperationQueue) recordLocked(op *Operation, state OperationState, err error) {
+	finished := q.now()
+	op.State = state
+	op.FinishedAt = &finished
+	if err != nil {
+		op.Error = err.Error()
+	}
+
+	q.recent = append(q.recent, *op)
+	if len(q.recent) > maxRecentOperations {
+		q.recent = q.recent[len(q.recent)-maxRecentOperations:]
+	}
+}
+
+func (q *OperationQueue) removeWaiter(w *operationWaiter) {
+	for idx, waiter := range q.waiters {
+		if waiter == w {
+			q.waiters = append(q.waiters[:idx], q.waiters[idx+1:]...)
+			return
+		}
+	}
+}
+
+// BeginOperation serializes a lifecycle operation with any other in progress on the instance.
+// See OperationQueue.Begin.
+func (i *Process) BeginOperation(ctx context.Context, opType OperationType, wait bool) (func(error), error) {
+	return i.operations.Begin(ctx, opType, wait)
+}
+
+// GetOperations returns the current, queued and recent lifecycle operations of the instance
+func (i *Process) GetOperations() OperationsSnapshot {
+	return i.operations.Snapshot()
+}
@@ -0,0 +1,150 @@
+package instance_test
+
+import (
+	"context"
+	"errors"
+	"llamactl/pkg/instance"
+	"testing"
+	"time"
+)
+
+func TestOperationQueue_RejectsWhileInProgress(t *testing.T) {
+	q := instance.NewOperationQueue("test-instance")
+
+	finish, err := q.Begin(context.Background(), instance.OperationRestart, false)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+
+	_, err = q.Begin(context.Background(), instance.OperationStop, false)
+	var inProgress *instance.OperationInProgressError
+	if !errors.As(err, &inProgress) {
+		t.Fatalf("Expected OperationInProgressError, got %v", err)
+	}
+	if inProgress.Operation.Type != instance.OperationRestart || inProgress.Operation.State != instance.OperationRunning {
+		t.Errorf("Expected the running restart in the error, got %+v", inProgress.Operation)
+	}
+
+	finish(nil)
+
+	finish, err = q.Begin(context.Background(), instance.OperationStop, false)
+	if err != nil {
+		t.Fatalf("Expected Begin to succeed once the restart finished, got %v", err)
+	}
+	finish(errors.New("boom"))
+
+	snapshot := q.Snapshot()
+	if snapshot.Current != nil {
+		t.Errorf("Expected no current operation, got %+v", snapshot.Current)
+	}
+	if len(snapshot.Recent) != 2 {
+		t.Fatalf("Expected 2 recent operations, got %d", len(snapshot.Recent))
+	}
+	if snapshot.Recent[0].State != instance.OperationFailed || snapshot.Recent[0].Error != "boom" {
+		t.Errorf("Expected the failed stop first, got %+v", snapshot.Recent[0])
+	}
+	if snapshot.Recent[1].State != instance.OperationSucceeded || snapshot.Recent[1].Type != instance.OperationRestart {
+		t.Errorf("Expected the succeeded restart second, got %+v", snapshot.Recent[1])
+	}
+}
+
+func TestOperationQueue_WaitsInOrder(t *testing.T) {
+	q := instance.NewOperationQueue("test-instance")
+
+	finish, err := q.Begin(context.Background(), instance.OperationStart, true)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+
+	order := make(chan instance.OperationType, 2)
+	for idx, opType := range []instance.OperationType{instance.OperationStop, instance.OperationRestart} {
+		go func() {
+			finish, err := q.Begin(context.Background(), opType, true)
+			if err != nil {
+				t.Errorf("Begin %s failed: %v", opType, err)
+				return
+			}
+			order <- opType
+			finish(nil)
+		}()
+		waitForQueued(t, q, idx+1)
+	}
+
+	if current := q.Snapshot().Current; current == nil || current.Type != instance.OperationStart {
+		t.Fatalf("Expected the start to still be current, got %+v", current)
+	}
+	finish(nil)
+
+	for _, want := range []instance.OperationType{instance.OperationStop, instance.OperationRestart} {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Errorf("Expected %s to run next, got %s", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for %s", want)
+		}
+	}
+}
+
+func TestOperationQueue_CancelWaiting(t *testing.T) {
+	q := instance.NewOperationQueue("test-instance")
+
+	finish, err := q.Begin(context.Background(), instance.OperationRestart, false)
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	defer finish(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := q.Begin(ctx, instance.OperationStop, true)
+		errCh <- err
+	}()
+	waitForQueued(t, q, 1)
+	cancel()
+
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	snapshot := q.Snapshot()
+	if len(snapshot.Queued) != 0 {
+		t.Errorf("Expected no queued operations, got %d", len(snapshot.Queued))
+	}
+	if len(snapshot.Recent) != 1 || snapshot.Recent[0].State != instance.OperationCancelled {
+		t.Errorf("Expected the cancelled stop in recent operations, got %+v", snapshot.Recent)
+	}
+}
+
+func TestOperationQueue_RecentIsBounded(t *testing.T) {
+	q := instance.NewOperationQueue("test-instance")
+
+	for range 30 {
+		finish, err := q.Begin(context.Background(), instance.OperationStart, false)
+		if err != nil {
+			t.Fatalf("Begin failed: %v", err)
+		}
+		finish(nil)
+	}
+
+	recent := q.Snapshot().Recent
+	if len(recent) != 20 {
+		t.Fatalf("Expected 20 recent operations, got %d", len(recent))
+	}
+	if recent[0].ID != "test-instance-30" {
+		t.Errorf("Expected the latest operation first, got %s", recent[0].ID)
+	}
+}
+
+func waitForQueued(t *testing.T, q *instance.OperationQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(q.Snapshot().Queued) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d queued operations", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
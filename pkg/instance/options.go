@@ -10,19 +10,108 @@ import (
 	"llamactl/pkg/config"
 	"log"
 	"maps"
+	"time"
 )
 
 type CreateInstanceOptions struct {
 	// Auto restart
-	AutoRestart  *bool `json:"auto_restart,omitempty"`
-	MaxRestarts  *int  `json:"max_restarts,omitempty"`
-	RestartDelay *int  `json:"restart_delay,omitempty"` // seconds
+	AutoRestart  *bool            `json:"auto_restart,omitempty"`
+	MaxRestarts  *int             `json:"max_restarts,omitempty"`
+	RestartDelay *config.Duration `json:"restart_delay,omitempty"`
+	// Random delay added to the restart delay, a percentage of it ("25%") or a duration
+	RestartJitter *RestartJitter `json:"restart_jitter,omitempty"`
+	// Double the restart delay on each consecutive crash, up to restart_backoff_max (default: 5m)
+	RestartBackoff    *bool            `json:"restart_backoff,omitempty"`
+	RestartBackoffMax *config.Duration `json:"restart_backoff_max,omitempty"`
+	// Time a run has to stay ready for the restart counter and backoff to start over (default: 10m)
+	RestartResetAfter *config.Duration `json:"restart_reset_after,omitempty"`
+	// Time a process has to run for its crash not to count as a rapid failure, a bare integer
+	// is in seconds. The instance fails as crash-looping after 3 of them in a row (default: 0, disabled)
+	MinUptime *config.Duration `json:"min_uptime_seconds,omitempty"`
 	// On demand start
 	OnDemandStart *bool `json:"on_demand_start,omitempty"`
-	// Idle timeout
-	IdleTimeout *int `json:"idle_timeout,omitempty"` // minutes
+	// Idle timeout, a bare integer is in minutes
+	IdleTimeout *config.Duration `json:"idle_timeout,omitempty"`
+	// Cron expressions at which the instance is started and stopped, in the time zone of llamactl
+	StartSchedule string `json:"start_schedule,omitempty"`
+	StopSchedule  string `json:"stop_schedule,omitempty"`
+	// Time to become healthy after starting, derived from the model size when unset
+	ReadinessTimeout *config.Duration `json:"readiness_timeout,omitempty"`
+	// Liveness checks of the running backend, killed and restarted when it keeps failing them
+	HealthCheck *HealthCheckOptions `json:"health_check,omitempty"`
+	// When external load balancers are told the instance takes no more work
+	LBHealth *LBHealthOptions `json:"lb_health,omitempty"`
+	// Time to exit after SIGTERM before the process group is killed (default: default_stop_timeout)
+	StopTimeout *config.Duration `json:"stop_timeout,omitempty"`
+	// Time to complete the requests in flight before SIGTERM, 0 = stop right away (default: default_drain_timeout)
+	DrainTimeout *config.Duration `json:"drain_timeout,omitempty"`
 	//Environment variables
 	Environment map[string]string `json:"environment,omitempty"`
+	// Environment variables whose values are masked when the instance is read back, in
+	// addition to those named like secrets
+	SecretEnvironment []string `json:"secret_environment,omitempty"`
+	// Log retention
+	LogRetentionDays *int `json:"log_retention_days,omitempty"` // days, 0 = keep forever
+	// Weight of the share of log_disk_quota the log files can use, relative to the other instances (default: 1)
+	LogQuotaWeight *int `json:"log_quota_weight,omitempty"`
+	// Sanitization of backend output before it is logged: "off", "strip" or "collapse"
+	LogSanitize string `json:"log_sanitize,omitempty"`
+	// Save the slots of a llama.cpp backend before a restart and restore them afterwards
+	PreserveSlots      *bool `json:"preserve_slots,omitempty"`
+	SlotRetentionHours *int  `json:"slot_retention_hours,omitempty"` // hours, 0 = keep forever
+	// Request admission
+	MaxConcurrentRequests *int             `json:"max_concurrent_requests,omitempty"` // 0 = unlimited
+	MaxQueuedRequests     *int             `json:"max_queued_requests,omitempty"`     // 0 = unlimited
+	QueueTimeout          *config.Duration `json:"queue_timeout,omitempty"`           // time a request can wait for admission, 0 = unlimited
+	MaxRequestDuration    *config.Duration `json:"max_request_duration,omitempty"`    // time a request is proxied for once admitted, 0 = unlimited
+	// Service level objectives, updatable without a restart
+	SLO *SLOOptions `json:"slo,omitempty"`
+	// Backend connection
+	BackendTLS            *BackendTLSOptions `json:"backend_tls,omitempty"`
+	MaxBackendConnections *int               `json:"max_backend_connections,omitempty"` // 0 = unlimited
+	// At the cap, wait for a connection to close ("block", the default) or fail the request ("fail")
+	BackendConnectionPolicy string `json:"backend_connection_policy,omitempty"`
+	// Idle connections kept open when idle connections are reaped
+	MinIdleBackendConnections *int `json:"min_idle_backend_connections,omitempty"`
+	// Managed instances are started and stopped by llamactl; unmanaged ones are proxy-only
+	Managed *bool `json:"managed,omitempty"`
+	// Start a llama.cpp backend with an API key llamactl generates, keeps and authenticates with
+	ManagedBackendKey *bool `json:"managed_backend_key,omitempty"`
+	// Executable of a llama.cpp backend, e.g. a CUDA or Vulkan build of llama-server
+	// (default: llama_executable, or the command of the llama.cpp backend)
+	LlamaExecutable string `json:"llama_executable,omitempty"`
+	// Command prepended to the backend command line, e.g. ["numactl", "--interleave=all"]
+	LaunchWrapper []string `json:"launch_wrapper,omitempty"`
+	// Shell commands run before the backend starts, failing the start when they fail, and
+	// after it exits, automatic restarts included
+	OnStartCmd string `json:"on_start_cmd,omitempty"`
+	OnStopCmd  string `json:"on_stop_cmd,omitempty"`
+	// Time each of them is given before it is killed (default: 5m)
+	HookTimeout *config.Duration `json:"hook_timeout,omitempty"`
+	// Model registered on the control plane, downloaded before the instance starts
+	RemoteModel string `json:"remote_model,omitempty"`
+	// Rewrite OpenAI responses to the strict OpenAI shape
+	NormalizeResponses *bool `json:"normalize_responses,omitempty"`
+	// When the model files change on disk: "flag" the instance (default) or also "restart" it
+	OnModelChange string `json:"on_model_change,omitempty"`
+	// When the backend fails requests with GPU errors: "restart" it (default) or only "flag" it
+	OnGPUFault string `json:"on_gpu_fault,omitempty"`
+	// Transform rewriting the proxied OpenAI requests and non-streamed responses
+	Transform *TransformOptions `json:"transform,omitempty"`
+	// Interface name or address the backend listens on, resolved when the instance starts
+	BindInterface string `json:"bind_interface,omitempty"`
+	// Address the backend listens on, passed to it as --host (default: the backend default)
+	BindHost string `json:"bind_host,omitempty"`
+	// Host llamactl connects to the backend on (default: the bind host, 127.0.0.1 for a wildcard bind host)
+	ConnectHost string `json:"connect_host,omitempty"`
+	// Labels used to select instances, e.g. for rolling restarts
+	Tags []string `json:"tags,omitempty"`
+	// Paths the proxy forwards to the backend, as prefixes or glob patterns, updatable without
+	// a restart (default: every path)
+	AllowedPaths []string `json:"allowed_paths,omitempty"`
+	// Named prompts rendered with variables for the completion endpoint of the backend,
+	// updatable without a restart
+	PromptTemplates map[string]string `json:"prompt_templates,omitempty"`
 
 	BackendType    backends.BackendType `json:"backend_type"`
 	BackendOptions map[string]any       `json:"backend_options,omitempty"`
@@ -39,13 +128,27 @@ func (c *CreateInstanceOptions) UnmarshalJSON(data []byte) error {
 	type Alias CreateInstanceOptions
 	aux := &struct {
 		*Alias
+		// Read separately, a bare integer is in minutes
+		IdleTimeout json.RawMessage `json:"idle_timeout,omitempty"`
 	}{
 		Alias: (*Alias)(c),
 	}
 
+	// Accept the camelCase and llama.cpp spellings of the keys
+	data, err := canonicalizeOptions(data)
+	if err != nil {
+		return err
+	}
 	if err := json.Unmarshal(data, aux); err != nil {
 		return err
 	}
+	if len(aux.IdleTimeout) > 0 && string(aux.IdleTimeout) != "null" {
+		idleTimeout, err := config.ParseDurationJSON(aux.IdleTimeout, time.Minute)
+		if err != nil {
+			return fmt.Errorf("invalid idle_timeout: %w", err)
+		}
+		c.IdleTimeout = &idleTimeout
+	}
 
 	// Parse backend-specific options
 	switch c.BackendType {
@@ -90,6 +193,8 @@ func (c *CreateInstanceOptions) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("unknown backend type: %s", c.BackendType)
 	}
 
+	// Definitions persisted before bind_host and connect_host only set the backend host
+	c.normalizeHosts()
 	return nil
 }
 
@@ -152,8 +257,11 @@ func (c *CreateInstanceOptions) MarshalJSON() ([]byte, error) {
 	return json.Marshal(aux)
 }
 
-// ValidateAndApplyDefaults validates the instance options and applies constraints
-func (c *CreateInstanceOptions) ValidateAndApplyDefaults(name string, globalSettings *config.InstancesConfig) {
+// ValidateAndApplyDefaults validates the instance options, applies constraints and fills
+// unset fields from the global defaults. It returns the source of every set field.
+func (c *CreateInstanceOptions) ValidateAndApplyDefaults(name string, globalSettings *config.InstancesConfig) OptionSources {
+	c.normalizeHosts()
+
 	// Validate and apply constraints
 	if c.MaxRestarts != nil && *c.MaxRestarts < 0 {
 		log.Printf("Instance %s MaxRestarts value (%d) cannot be negative, setting to 0", name, *c.MaxRestarts)
@@ -161,34 +269,103 @@ func (c *CreateInstanceOptions) ValidateAndApplyDefaults(name string, globalSett
 	}
 
 	if c.RestartDelay != nil && *c.RestartDelay < 0 {
-		log.Printf("Instance %s RestartDelay value (%d) cannot be negative, setting to 0 seconds", name, *c.RestartDelay)
+		log.Printf("Instance %s RestartDelay value (%s) cannot be negative, setting to 0s", name, *c.RestartDelay)
 		*c.RestartDelay = 0
 	}
 
+	if c.RestartBackoffMax != nil && *c.RestartBackoffMax < 0 {
+		log.Printf("Instance %s RestartBackoffMax value (%s) cannot be negative, setting to 0 (default)", name, *c.RestartBackoffMax)
+		*c.RestartBackoffMax = 0
+	}
+
+	if c.RestartResetAfter != nil && *c.RestartResetAfter < 0 {
+		log.Printf("Instance %s RestartResetAfter value (%s) cannot be negative, setting to 0 (default)", name, *c.RestartResetAfter)
+		*c.RestartResetAfter = 0
+	}
+
+	if c.MinUptime != nil && *c.MinUptime < 0 {
+		log.Printf("Instance %s MinUptime value (%s) cannot be negative, setting to 0 (disabled)", name, *c.MinUptime)
+		*c.MinUptime = 0
+	}
+
 	if c.IdleTimeout != nil && *c.IdleTimeout < 0 {
-		log.Printf("Instance %s IdleTimeout value (%d) cannot be negative, setting to 0 minutes", name, *c.IdleTimeout)
+		log.Printf("Instance %s IdleTimeout value (%s) cannot be negative, setting to 0 (disabled)", name, *c.IdleTimeout)
 		*c.IdleTimeout = 0
 	}
 
-	// Apply defaults from global settings for nil fields
+	if c.ReadinessTimeout != nil && *c.ReadinessTimeout < 0 {
+		log.Printf("Instance %s ReadinessTimeout value (%s) cannot be negative, setting to 0 (derived)", name, *c.ReadinessTimeout)
+		*c.ReadinessTimeout = 0
+	}
+
+	if c.StopTimeout != nil && *c.StopTimeout < 0 {
+		log.Printf("Instance %s StopTimeout value (%s) cannot be negative, setting to 0 (default)", name, *c.StopTimeout)
+		*c.StopTimeout = 0
+	}
+
+	if c.DrainTimeout != nil && *c.DrainTimeout < 0 {
+		log.Printf("Instance %s DrainTimeout value (%s) cannot be negative, setting to 0 (no drain)", name, *c.DrainTimeout)
+		*c.DrainTimeout = 0
+	}
+
+	if c.HookTimeout != nil && *c.HookTimeout < 0 {
+		log.Printf("Instance %s HookTimeout value (%s) cannot be negative, setting to 0 (default)", name, *c.HookTimeout)
+		*c.HookTimeout = 0
+	}
+
+	if c.LogQuotaWeight != nil && *c.LogQuotaWeight < 0 {
+		log.Printf("Instance %s LogQuotaWeight value (%d) cannot be negative, setting to 0 (default)", name, *c.LogQuotaWeight)
+		*c.LogQuotaWeight = 0
+	}
+
+	if c.LogRetentionDays != nil && *c.LogRetentionDays < 0 {
+		log.Printf("Instance %s LogRetentionDays value (%d) cannot be negative, setting to 0 days", name, *c.LogRetentionDays)
+		*c.LogRetentionDays = 0
+	}
+
+	if c.MaxConcurrentRequests != nil && *c.MaxConcurrentRequests < 0 {
+		log.Printf("Instance %s MaxConcurrentRequests value (%d) cannot be negative, setting to 0 (unlimited)", name, *c.MaxConcurrentRequests)
+		*c.MaxConcurrentRequests = 0
+	}
+
+	if c.MaxQueuedRequests != nil && *c.MaxQueuedRequests < 0 {
+		log.Printf("Instance %s MaxQueuedRequests value (%d) cannot be negative, setting to 0 (unlimited)", name, *c.MaxQueuedRequests)
+		*c.MaxQueuedRequests = 0
+	}
+
+	if c.QueueTimeout != nil && *c.QueueTimeout < 0 {
+		log.Printf("Instance %s QueueTimeout value (%s) cannot be negative, setting to 0 (unlimited)", name, *c.QueueTimeout)
+		*c.QueueTimeout = 0
+	}
+
+	if c.MaxRequestDuration != nil && *c.MaxRequestDuration < 0 {
+		log.Printf("Instance %s MaxRequestDuration value (%s) cannot be negative, setting to 0 (unlimited)", name, *c.MaxRequestDuration)
+		*c.MaxRequestDuration = 0
+	}
+
+	if c.SlotRetentionHours != nil && *c.SlotRetentionHours < 0 {
+		log.Printf("Instance %s SlotRetentionHours value (%d) cannot be negative, setting to 0 (keep forever)", name, *c.SlotRetentionHours)
+		*c.SlotRetentionHours = 0
+	}
+
+	if c.MaxBackendConnections != nil && *c.MaxBackendConnections < 0 {
+		log.Printf("Instance %s MaxBackendConnections value (%d) cannot be negative, setting to 0 (unlimited)", name, *c.MaxBackendConnections)
+		*c.MaxBackendConnections = 0
+	}
+
+	if c.MinIdleBackendConnections != nil && *c.MinIdleBackendConnections < 0 {
+		log.Printf("Instance %s MinIdleBackendConnections value (%d) cannot be negative, setting to 0", name, *c.MinIdleBackendConnections)
+		*c.MinIdleBackendConnections = 0
+	}
+
+	// Merge the options over the global defaults
+	layers := []OptionLayer{{Source: SourceExplicit, Options: c}}
 	if globalSettings != nil {
-		if c.AutoRestart == nil {
-			c.AutoRestart = &globalSettings.DefaultAutoRestart
-		}
-		if c.MaxRestarts == nil {
-			c.MaxRestarts = &globalSettings.DefaultMaxRestarts
-		}
-		if c.RestartDelay == nil {
-			c.RestartDelay = &globalSettings.DefaultRestartDelay
-		}
-		if c.OnDemandStart == nil {
-			c.OnDemandStart = &globalSettings.DefaultOnDemandStart
-		}
-		if c.IdleTimeout == nil {
-			defaultIdleTimeout := 0
-			c.IdleTimeout = &defaultIdleTimeout
-		}
+		layers = append([]OptionLayer{{Source: SourceDefault, Options: DefaultOptions(globalSettings)}}, layers...)
 	}
+	merged, sources := MergeOptions(layers...)
+	*c = *merged
+	return sources
 }
 
 func (c *CreateInstanceOptions) GetCommand(backendConfig *config.BackendSettings) string {
@@ -197,12 +374,20 @@ func (c *CreateInstanceOptions) GetCommand(backendConfig *config.BackendSettings
 		return "docker"
 	}
 
+	if c.BackendType == backends.BackendTypeLlamaCpp && c.LlamaExecutable != "" {
+		return c.LlamaExecutable
+	}
 	return backendConfig.Command
 }
 
 // BuildCommandArgs builds command line arguments for the backend
 func (c *CreateInstanceOptions) BuildCommandArgs(backendConfig *config.BackendSettings) []string {
 
+	// The backend listens on the bind host
+	if c.BindHost != "" && c.BackendHost() != c.BindHost {
+		c = c.withHost(c.BindHost)
+	}
+
 	var args []string
 
 	if backendConfig.Docker != nil && backendConfig.Docker.Enabled && c.BackendType != backends.BackendTypeMlxLm {
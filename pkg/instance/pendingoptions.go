@@ -0,0 +1,57 @@
+package instance
+
+// GetPendingOptions returns the options set while the backend was running, to be applied
+// on its next start, or nil if the options in effect are up to date
+func (i *Process) GetPendingOptions() *CreateInstanceOptions {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.pendingOptions
+}
+
+// GetDesiredOptions returns the options the next start uses: the pending options if any,
+// else the options in effect
+func (i *Process) GetDesiredOptions() *CreateInstanceOptions {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.pendingOptions != nil {
+		return i.pendingOptions
+	}
+	return i.options
+}
+
+// RestartRequired reports whether options are pending until the next start
+func (i *Process) RestartRequired() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.pendingOptions != nil
+}
+
+// applyPendingOptions makes the pending options the options in effect before a start
+// (caller must hold the lock)
+func (i *Process) applyPendingOptions() {
+	if i.pendingOptions == nil {
+		return
+	}
+	options, sources := i.pendingOptions, i.pendingSources
+	i.pendingOptions, i.pendingSources = nil, nil
+	i.applyOptions(options, sources)
+}
+
+// patchPendingOptions applies a change made to the options in effect without a restart to
+// the pending options too, so the next start does not revert it (caller must hold the lock)
+func (i *Process) patchPendingOptions(name string, explicit bool, patch func(options *CreateInstanceOptions)) {
+	if i.pendingOptions == nil {
+		return
+	}
+	options := *i.pendingOptions
+	patch(&options)
+	i.pendingOptions = &options
+	if i.pendingSources == nil {
+		i.pendingSources = make(OptionSources)
+	}
+	if explicit {
+		i.pendingSources[name] = SourceExplicit
+	} else {
+		delete(i.pendingSources, name)
+	}
+}
@@ -0,0 +1,96 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/instance"
+	"testing"
+)
+
+func TestSetOptions_PendingWhileRunning(t *testing.T) {
+	inst := newShellInstance(t, "exec sleep 30", false, nil)
+	t.Cleanup(func() { inst.Stop() })
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+
+	inst.SetOptions(&instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/other.gguf",
+			Port:  8081,
+		},
+	})
+
+	// The running backend is still proxied to with its options
+	if port := inst.GetPort(); port != 8080 {
+		t.Errorf("Expected the port in effect to be kept, got %d", port)
+	}
+	if current, _ := inst.GetProxy(); current != proxy {
+		t.Error("Expected the proxy of the running backend to be kept")
+	}
+	if pending := inst.GetPendingOptions(); pending == nil || pending.LlamaServerOptions.Port != 8081 {
+		t.Errorf("Expected the new options to be pending, got %+v", pending)
+	}
+	if desired := inst.GetDesiredOptions(); desired.LlamaServerOptions.Model != "/path/to/other.gguf" {
+		t.Errorf("Expected the next start to use the new model, got %q", desired.LlamaServerOptions.Model)
+	}
+
+	data, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var status struct {
+		RestartRequired bool                            `json:"restart_required"`
+		Options         *instance.CreateInstanceOptions `json:"options"`
+		PendingOptions  *instance.CreateInstanceOptions `json:"pending_options"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !status.RestartRequired || status.Options.LlamaServerOptions.Port != 8080 || status.PendingOptions.LlamaServerOptions.Port != 8081 {
+		t.Errorf("Expected the restart to be reported as required, got %s", data)
+	}
+
+	// A saved instance starts with its pending options
+	restored := &instance.Process{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal of the instance failed: %v", err)
+	}
+	if restored.GetOptions().LlamaServerOptions.Port != 8081 || restored.RestartRequired() {
+		t.Errorf("Expected the pending options to be restored as the options, got %+v", restored.GetOptions().LlamaServerOptions)
+	}
+
+	// The next start applies them
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if !inst.RestartRequired() {
+		t.Error("Expected the options to stay pending until the next start")
+	}
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if inst.GetPort() != 8081 || inst.RestartRequired() {
+		t.Errorf("Expected the pending options to be applied, got port %d", inst.GetPort())
+	}
+}
+
+func TestSetOptions_AppliedWhenStopped(t *testing.T) {
+	inst := newShellInstance(t, "exec sleep 30", false, nil)
+	inst.SetOptions(&instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/other.gguf",
+			Port:  8081,
+		},
+	})
+	if inst.GetPort() != 8081 || inst.RestartRequired() || inst.GetPendingOptions() != nil {
+		t.Errorf("Expected the options of a stopped instance to apply right away, got port %d", inst.GetPort())
+	}
+}
@@ -0,0 +1,101 @@
+package instance
+
+import (
+	"llamactl/pkg/backends"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// visibleDevicesVars are the environment variables restricting the GPUs a backend sees
+var visibleDevicesVars = []string{"CUDA_VISIBLE_DEVICES", "HIP_VISIBLE_DEVICES", "ROCR_VISIBLE_DEVICES"}
+
+// devicePattern matches a llama.cpp device name, such as CUDA0 or Vulkan1, capturing its index
+var devicePattern = regexp.MustCompile(`^[A-Za-z]+(\d+)$`)
+
+// PlacementDecision is how the creation or start of an instance went against the GPU
+// placement policy of a service it is a member of
+type PlacementDecision struct {
+	Service   string    `json:"service"`
+	Policy    string    `json:"policy"`
+	Mode      string    `json:"mode"`
+	GPUs      []string  `json:"gpus"` // null when the instance may use every GPU
+	Satisfied bool      `json:"satisfied"`
+	Conflicts []string  `json:"conflicts,omitempty"` // Members the policy is broken with
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GPUs returns the GPUs the backend of the instance is assigned: the indexes or UUIDs of a
+// visible devices variable of its environment, narrowed by the llama.cpp device option. It
+// returns nil when the instance is not restricted and may use every GPU, and an empty list
+// when it uses none.
+func (i *Process) GPUs() []string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.options == nil {
+		return nil
+	}
+
+	env := maps.Clone(i.options.Environment)
+	if backendConfig, err := i.getBackendConfig(); err == nil {
+		env = i.options.BuildEnvironment(backendConfig)
+	}
+	var visible []string
+	restricted := false
+	for _, name := range visibleDevicesVars {
+		if value, ok := env[name]; ok {
+			visible, restricted = splitDevices(value), true
+			break
+		}
+	}
+
+	if i.options.BackendType == backends.BackendTypeLlamaCpp && i.options.LlamaServerOptions != nil {
+		if device := strings.TrimSpace(i.options.LlamaServerOptions.Device); device == "none" {
+			return []string{}
+		} else if device != "" {
+			gpus := []string{}
+			for _, name := range splitDevices(device) {
+				match := devicePattern.FindStringSubmatch(name)
+				if match == nil {
+					// Not a device name the GPU can be told from
+					return visible
+				}
+				// Device indexes count the visible devices only
+				idx, _ := strconv.Atoi(match[1])
+				switch {
+				case !restricted:
+					gpus = append(gpus, match[1])
+				case idx < len(visible):
+					gpus = append(gpus, visible[idx])
+				}
+			}
+			return gpus
+		}
+	}
+	if restricted {
+		return visible
+	}
+	return nil
+}
+
+// splitDevices splits a comma-separated list of devices, an empty value listing none
+func splitDevices(value string) []string {
+	devices := []string{}
+	for _, device := range strings.Split(value, ",") {
+		if device = strings.TrimSpace(device); device != "" {
+			devices = append(devices, device)
+		}
+	}
+	return devices
+}
+
+// SetPlacement records how the last creation or start of the instance went against the
+// GPU placement of its services
+func (i *Process) SetPlacement(decisions []PlacementDecision) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.Placement = decisions
+}
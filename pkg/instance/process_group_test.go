@@ -0,0 +1,96 @@
+package instance_test
+
+import (
+	"fmt"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestProcessTreeHelperProcess is run as the backend of the process tree tests: it starts
+// itself as a child listening on LLAMACTL_TEST_TREE_LISTEN and ignoring SIGTERM, then waits
+// for it, like a wrapper script starting the backend
+func TestProcessTreeHelperProcess(t *testing.T) {
+	addr := os.Getenv("LLAMACTL_TEST_TREE_LISTEN")
+	if addr == "" {
+		t.Skip("only run as the backend of the process tree tests")
+	}
+
+	if os.Getenv("LLAMACTL_TEST_TREE_CHILD") != "" {
+		signal.Ignore(syscall.SIGTERM, os.Interrupt)
+		if _, err := net.Listen("tcp", addr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.WriteFile(os.Getenv("LLAMACTL_TEST_TREE_READY"), nil, 0o644)
+		select {}
+	}
+
+	child := exec.Command(os.Args[0], "-test.run=^TestProcessTreeHelperProcess$")
+	child.Env = append(os.Environ(), "LLAMACTL_TEST_TREE_CHILD=1")
+	if err := child.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(1)
+}
+
+func TestStop_ProcessTreeReleasesPort(t *testing.T) {
+	port := freePort(t)
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	ready := filepath.Join(t.TempDir(), "ready")
+
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{
+			Command: os.Args[0],
+			Args:    []string{"-test.run=^TestProcessTreeHelperProcess$", "--"},
+			Environment: map[string]string{
+				"LLAMACTL_TEST_TREE_LISTEN": addr,
+				"LLAMACTL_TEST_TREE_READY":  ready,
+			},
+		},
+	}
+	options := &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		AutoRestart: testutil.BoolPtr(false),
+		StopTimeout: testutil.DurationPtr(500 * time.Millisecond),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Port:  port,
+		},
+	}
+	inst := instance.NewInstance("tree", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, err := os.Stat(ready); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			inst.Stop()
+			t.Fatal("Backend did not start its child process")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Expected the port of the backend to be free once Stop returned: %v", err)
+	}
+	l.Close()
+}
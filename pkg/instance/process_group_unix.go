@@ -3,13 +3,77 @@
 package instance
 
 import (
+	"errors"
+	"os"
 	"os/exec"
 	"syscall"
 )
 
+// signalsByName are the signals Signal sends, by name without their SIG prefix
+var signalsByName = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"ABRT": syscall.SIGABRT,
+	"KILL": syscall.SIGKILL,
+	"SEGV": syscall.SIGSEGV,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
 func setProcAttrs(cmd *exec.Cmd) {
 	if cmd.SysProcAttr == nil {
 		cmd.SysProcAttr = &syscall.SysProcAttr{}
 	}
 	cmd.SysProcAttr.Setpgid = true
 }
+
+// setDetachedProcAttrs runs the command in a session of its own, which also makes it the
+// leader of its process group, so that it outlives llamactl and its terminal
+func setDetachedProcAttrs(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+}
+
+// signalProcessGroup sends sig to the process group led by the command, which includes
+// the backend and any children of a launch wrapper. Falls back to signalling the process
+// itself if the group is already gone.
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	unixSig, ok := sig.(syscall.Signal)
+	if !ok {
+		return cmd.Process.Signal(sig)
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, unixSig); err != nil {
+		if errors.Is(err, syscall.ESRCH) {
+			return cmd.Process.Signal(sig)
+		}
+		return err
+	}
+	return nil
+}
+
+// attachProcessGroup does nothing, the process leads a group of its own from the start
+func attachProcessGroup(cmd *exec.Cmd) error {
+	return nil
+}
+
+// releaseProcessGroup does nothing, a process group needs no cleaning up
+func releaseProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup forcibly kills the process group led by the command
+func killProcessGroup(cmd *exec.Cmd) error {
+	return signalProcessGroup(cmd, syscall.SIGKILL)
+}
+
+// processGroupAlive reports whether a process of the group led by the command is left,
+// zombies left out where the platform tells them apart
+func processGroupAlive(cmd *exec.Cmd) bool {
+	if err := syscall.Kill(-cmd.Process.Pid, 0); err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	running, err := processGroupRunning(cmd.Process.Pid)
+	return running || err != nil
+}
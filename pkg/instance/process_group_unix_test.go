@@ -0,0 +1,163 @@
+//go:build !windows
+
+package instance_test
+
+import (
+	"llamactl/pkg/testutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStop_KillsProcessTreeBehindShellWrapper(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "backend.pid")
+
+	// The backend leaves a grandchild running, and the wrapper shell stays in the middle
+	// instead of exec-ing, so signalling only the direct child would orphan the sleep.
+	inst := newShellInstance(t, "sleep 300 & echo $! > "+pidFile+"; wait", false, nil)
+	opts := inst.GetOptions()
+	opts.LaunchWrapper = []string{"sh", "-c", `"$0" "$@"; exit $?`}
+	inst.SetOptions(opts)
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	var pid int
+	deadline := time.Now().Add(5 * time.Second)
+	for pid == 0 && time.Now().Before(deadline) {
+		if data, err := os.ReadFile(pidFile); err == nil {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pid == 0 {
+		inst.Stop()
+		t.Fatal("Backend did not start its child process")
+	}
+
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	deadline = time.Now().Add(5 * time.Second)
+	for syscall.Kill(pid, 0) == nil {
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatal("Expected the backend's child process to be stopped with the wrapper")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStop_GrandchildGoneWhenStopReturns(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "grandchild.pid")
+
+	// A wrapper script starting a helper that ignores SIGTERM and leaves a sleeping
+	// grandchild, which only the SIGKILL of the whole group stops
+	script := `sh -c 'trap "" TERM; sleep 300 & echo $! > ` + pidFile + `; wait' & wait`
+	inst := newShellInstance(t, script, false, nil)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	var pid int
+	deadline := time.Now().Add(5 * time.Second)
+	for pid == 0 && time.Now().Before(deadline) {
+		if data, err := os.ReadFile(pidFile); err == nil {
+			pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pid == 0 {
+		inst.Stop()
+		t.Fatal("Backend did not start its grandchild process")
+	}
+
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if processRunning(pid) {
+		syscall.Kill(pid, syscall.SIGKILL)
+		t.Fatal("Expected the grandchild to be dead once Stop returned")
+	}
+}
+
+// processRunning reports whether pid runs, a zombie left to be reaped not counted
+func processRunning(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	if _, err := os.Stat("/proc/self"); err != nil {
+		return true // No /proc to tell zombies apart
+	}
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	end := strings.LastIndexByte(string(data), ')')
+	return end < 0 || !strings.HasPrefix(string(data[end+1:]), " Z")
+}
+
+func TestStop_TermsThenKillsAfterStopTimeout(t *testing.T) {
+	t.Run("exits on SIGTERM", func(t *testing.T) {
+		marker := filepath.Join(t.TempDir(), "terminated")
+		inst := newShellInstance(t, "trap 'echo done > "+marker+"; exit 0' TERM; sleep 300 & wait", false, nil)
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond) // Let the shell set its trap
+
+		if err := inst.Stop(); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		if _, err := os.Stat(marker); err != nil {
+			t.Errorf("Expected the backend to handle SIGTERM before exiting: %v", err)
+		}
+	})
+
+	t.Run("killed after the stop timeout", func(t *testing.T) {
+		pidFile := filepath.Join(t.TempDir(), "backend.pid")
+		// The ignored signal is inherited by the sleep, so nothing exits on SIGTERM
+		inst := newShellInstance(t, "trap '' TERM; sleep 300 & echo $! > "+pidFile+"; wait", false, nil)
+		opts := inst.GetOptions()
+		opts.StopTimeout = testutil.DurationPtr(200 * time.Millisecond)
+		inst.SetOptions(opts)
+
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		var pid int
+		deadline := time.Now().Add(5 * time.Second)
+		for pid == 0 && time.Now().Before(deadline) {
+			if data, err := os.ReadFile(pidFile); err == nil {
+				pid, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if pid == 0 {
+			inst.Stop()
+			t.Fatal("Backend did not start its child process")
+		}
+
+		start := time.Now()
+		if err := inst.Stop(); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 5*time.Second {
+			t.Errorf("Expected the group to be killed after the 200ms stop timeout, took %v", elapsed)
+		}
+		deadline = time.Now().Add(5 * time.Second)
+		for syscall.Kill(pid, 0) == nil {
+			if time.Now().After(deadline) {
+				syscall.Kill(pid, syscall.SIGKILL)
+				t.Fatal("Expected the whole process group to be killed")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
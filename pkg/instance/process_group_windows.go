@@ -2,8 +2,147 @@
 
 package instance
 
-import "os/exec"
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// signalsByName are the signals Signal sends, by name without their SIG prefix. Windows
+// processes can only be killed.
+var signalsByName = map[string]syscall.Signal{
+	"KILL": syscall.SIGKILL,
+}
+
+// jobs are the job objects the processes started by llamactl are assigned to, by command.
+// Windows has no process groups, a job holds the process and every process it starts. A
+// command whose process could not be assigned to a job maps to 0.
+var jobs = struct {
+	sync.Mutex
+	byCmd map[*exec.Cmd]windows.Handle
+}{byCmd: make(map[*exec.Cmd]windows.Handle)}
+
+// jobObjectBasicAccountingInformation is JOBOBJECT_BASIC_ACCOUNTING_INFORMATION
+type jobObjectBasicAccountingInformation struct {
+	TotalUserTime             int64
+	TotalKernelTime           int64
+	ThisPeriodTotalUserTime   int64
+	ThisPeriodTotalKernelTime int64
+	TotalPageFaultCount       uint32
+	TotalProcesses            uint32
+	ActiveProcesses           uint32
+	TotalTerminatedProcesses  uint32
+}
 
 func setProcAttrs(cmd *exec.Cmd) {
+	// The process is assigned to a job once started, see attachProcessGroup
+}
+
+func setDetachedProcAttrs(cmd *exec.Cmd) {
 	// No-op on Windows
 }
+
+// attachProcessGroup assigns the started process of the command to a job object of its own,
+// which the processes it starts from then on join, and which is terminated on close. Without
+// a job the process tree is killed with taskkill.
+func attachProcessGroup(cmd *exec.Cmd) error {
+	job, err := newProcessJob(cmd.Process.Pid)
+	jobs.Lock()
+	jobs.byCmd[cmd] = job
+	jobs.Unlock()
+	return err
+}
+
+// newProcessJob creates a job object terminated on close and assigns the process pid to it
+func newProcessJob(pid int) (windows.Handle, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create a job object: %w", err)
+	}
+	limits := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	limits.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&limits)), uint32(unsafe.Sizeof(limits))); err != nil {
+		windows.CloseHandle(job)
+		return 0, fmt.Errorf("failed to configure the job object: %w", err)
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		windows.CloseHandle(job)
+		return 0, fmt.Errorf("failed to open process %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(process)
+	if err := windows.AssignProcessToJobObject(job, process); err != nil {
+		windows.CloseHandle(job)
+		return 0, fmt.Errorf("failed to assign process %d to a job object: %w", pid, err)
+	}
+	return job, nil
+}
+
+// releaseProcessGroup closes the job object of the command, which terminates whatever runs in
+// it still
+func releaseProcessGroup(cmd *exec.Cmd) {
+	jobs.Lock()
+	job, ok := jobs.byCmd[cmd]
+	delete(jobs.byCmd, cmd)
+	jobs.Unlock()
+	if ok && job != 0 {
+		windows.CloseHandle(job)
+	}
+}
+
+// processJob returns the job object of the command, and whether it was attached at all
+func processJob(cmd *exec.Cmd) (windows.Handle, bool) {
+	jobs.Lock()
+	defer jobs.Unlock()
+	job, ok := jobs.byCmd[cmd]
+	return job, ok
+}
+
+// signalProcessGroup terminates the process tree of the command for SIGTERM and SIGKILL:
+// Windows cannot ask a console process of another console to exit. Other signals go to the
+// process itself.
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	if sig == syscall.SIGTERM || sig == syscall.SIGKILL {
+		return killProcessGroup(cmd)
+	}
+	return cmd.Process.Signal(sig)
+}
+
+// killProcessGroup terminates the job object of the command, or kills its process tree with
+// taskkill if it has none, falling back to killing the process itself
+func killProcessGroup(cmd *exec.Cmd) error {
+	job, attached := processJob(cmd)
+	switch {
+	case job != 0:
+		if err := windows.TerminateJobObject(job, 1); err == nil {
+			return nil
+		}
+	case attached:
+		taskkill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+		if err := taskkill.Run(); err == nil {
+			return nil
+		}
+	}
+	return cmd.Process.Kill()
+}
+
+// processGroupAlive reports whether a process of the job object of the command is left. The
+// process of a command without one is waited for by its monitor.
+func processGroupAlive(cmd *exec.Cmd) bool {
+	job, _ := processJob(cmd)
+	if job == 0 {
+		return false
+	}
+	var info jobObjectBasicAccountingInformation
+	if err := windows.QueryInformationJobObject(job, windows.JobObjectBasicAccountingInformation, uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info)), nil); err != nil {
+		return false
+	}
+	return info.ActiveProcesses > 0
+}
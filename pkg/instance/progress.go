@@ -0,0 +1,173 @@
+package instance
+
+import (
+	"regexp"
+	"strconv"
+
+	"llamactl/pkg/backends"
+)
+
+// Phases of the startup of a backend, in the order they happen
+const (
+	StartupPhaseUnknown         = "unknown"          // No progress output recognized yet
+	StartupPhaseLoadingMetadata = "loading_metadata" // Reading the model file header
+	StartupPhaseLoadingTensors  = "loading_tensors"  // Loading the layers and uploading them to the devices
+	StartupPhaseCreatingContext = "creating_context" // Allocating the context and KV cache
+	StartupPhaseWarmingUp       = "warming_up"       // Running the warmup pass
+	StartupPhaseStartingServer  = "starting_server"  // Model loaded, the HTTP server comes up
+	StartupPhaseReady           = "ready"            // Passed a readiness check
+)
+
+var startupPhaseOrder = map[string]int{
+	StartupPhaseUnknown:         0,
+	StartupPhaseLoadingMetadata: 1,
+	StartupPhaseLoadingTensors:  2,
+	StartupPhaseCreatingContext: 3,
+	StartupPhaseWarmingUp:       4,
+	StartupPhaseStartingServer:  5,
+	StartupPhaseReady:           6,
+}
+
+// StartupProgress is the coarse progress of the startup of the current process, parsed from
+// the output of llama-server. Parsing is best effort: output it does not recognize, such as
+// that of other backends or versions, leaves the phase unknown until the backend is ready.
+type StartupProgress struct {
+	Phase   string `json:"phase"`
+	Percent *int   `json:"percent,omitempty"` // Unset while the phase is unknown
+}
+
+// startupPattern maps a line of llama-server output to a phase and a percentage
+type startupPattern struct {
+	pattern *regexp.Regexp
+	phase   string
+	percent int
+}
+
+// llamaStartupPatterns are the load messages of llama-server, from first to last. The
+// percentages are rough shares of a typical load, the tensors taking the most.
+var llamaStartupPatterns = []startupPattern{
+	{regexp.MustCompile(`llama_model_loader: loaded meta data`), StartupPhaseLoadingMetadata, 5},
+	{regexp.MustCompile(`load_tensors: loading model tensors`), StartupPhaseLoadingTensors, 10},
+	{regexp.MustCompile(`load_tensors: offloaded \d+/\d+ layers`), StartupPhaseLoadingTensors, 60},
+	{regexp.MustCompile(`load_tensors:\s+\S+ model buffer size`), StartupPhaseLoadingTensors, 65},
+	{regexp.MustCompile(`llama_context: constructing llama_context|llama_new_context_with_model:|llama_init_from_model:`), StartupPhaseCreatingContext, 75},
+	{regexp.MustCompile(`warming up the model`), StartupPhaseWarmingUp, 85},
+	{regexp.MustCompile(`main: model loaded|server is listening on`), StartupPhaseStartingServer, 95},
+}
+
+var (
+	// Number of layers of the model, reported before they are loaded
+	llamaLayerCount = regexp.MustCompile(`(?:print_info|llm_load_print_meta): n_layer\s*=\s*(\d+)`)
+	// A layer being loaded, between the first two tensor messages
+	llamaLayerLoaded = regexp.MustCompile(`load_tensors: layer\s+(\d+) assigned to device`)
+)
+
+// minProgressStep is the advance of the percentage, within a phase, worth reporting to the
+// observer
+const minProgressStep = 10
+
+// GetStartupProgress returns the progress of the startup of the current or last process,
+// nil if the instance was never started
+func (i *Process) GetStartupProgress() *StartupProgress {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.startupProgress
+}
+
+// resetStartupProgress starts tracking the progress of a new process from scratch
+// (caller must hold the lock)
+func (i *Process) resetStartupProgress() {
+	i.startupProgress = &StartupProgress{Phase: StartupPhaseUnknown}
+	i.startupLayers = 0
+	i.startupReported = 0
+}
+
+// trackStartupProgress advances the startup progress of a starting llama-server with a line of
+// its output, and reports the progress to the observer when the phase changes or the
+// percentage advanced enough
+func (i *Process) trackStartupProgress(line string) {
+	phase, percent, layers := parseStartupLine(line)
+	if phase == "" && layers == 0 {
+		return
+	}
+
+	i.mu.Lock()
+	if i.GetStatus() != Starting || i.options == nil || i.options.BackendType != backends.BackendTypeLlamaCpp || i.startupProgress == nil {
+		i.mu.Unlock()
+		return
+	}
+	if layers > 0 {
+		i.startupLayers = layers
+		i.mu.Unlock()
+		return
+	}
+
+	// A loaded layer is only a share of the loading of the tensors once their number is known
+	if layer, ok := layerIndex(line); ok {
+		if i.startupLayers == 0 {
+			i.mu.Unlock()
+			return
+		}
+		percent = 10 + 50*min(layer+1, i.startupLayers)/i.startupLayers
+	}
+
+	previous := i.startupProgress.Phase
+	if !i.advanceStartupProgress(phase, percent) {
+		i.mu.Unlock()
+		return
+	}
+	progress := *i.startupProgress
+	report := progress.Phase != previous || *progress.Percent-i.startupReported >= minProgressStep
+	if report {
+		i.startupReported = *progress.Percent
+	}
+	i.mu.Unlock()
+
+	if report {
+		if observer := i.observer.Load(); observer != nil && observer.StartupProgress != nil {
+			observer.StartupProgress(progress)
+		}
+	}
+}
+
+// advanceStartupProgress moves the progress forward to phase and percent, never back, and
+// reports whether it moved (caller must hold the lock)
+func (i *Process) advanceStartupProgress(phase string, percent int) bool {
+	current := i.startupProgress
+	if startupPhaseOrder[phase] < startupPhaseOrder[current.Phase] {
+		return false
+	}
+	if current.Percent != nil && percent <= *current.Percent {
+		return false
+	}
+	i.startupProgress = &StartupProgress{Phase: phase, Percent: &percent}
+	return true
+}
+
+// parseStartupLine returns the phase and percentage a line of llama-server output marks, or
+// the number of layers of the model it reports
+func parseStartupLine(line string) (phase string, percent int, layers int) {
+	if match := llamaLayerCount.FindStringSubmatch(line); match != nil {
+		layers, _ = strconv.Atoi(match[1])
+		return "", 0, layers
+	}
+	if _, ok := layerIndex(line); ok {
+		return StartupPhaseLoadingTensors, 0, 0
+	}
+	for _, p := range llamaStartupPatterns {
+		if p.pattern.MatchString(line) {
+			return p.phase, p.percent, 0
+		}
+	}
+	return "", 0, 0
+}
+
+// layerIndex returns the index of the layer a line reports loaded
+func layerIndex(line string) (int, bool) {
+	match := llamaLayerLoaded.FindStringSubmatch(line)
+	if match == nil {
+		return 0, false
+	}
+	layer, err := strconv.Atoi(match[1])
+	return layer, err == nil
+}
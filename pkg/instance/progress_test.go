@@ -0,0 +1,110 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/instance"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartupProgress(t *testing.T) {
+	// The first run loads a model of 4 layers, the next ones write nothing recognizable
+	marker := filepath.Join(t.TempDir(), "loaded")
+	script := `if [ -e ` + marker + ` ]; then echo "some other backend output"; else
+touch ` + marker + `
+echo "starting up"
+echo "llama_model_loader: loaded meta data with 30 key-value pairs and 291 tensors"
+echo "print_info: n_layer          = 4"
+echo "load_tensors: loading model tensors, this can take a while... (mmap = true)"
+echo "load_tensors: layer   0 assigned to device CUDA0, is_swa = 0"
+echo "load_tensors: layer   1 assigned to device CUDA0, is_swa = 0"
+echo "load_tensors: layer   2 assigned to device CUDA0, is_swa = 0"
+echo "load_tensors: layer   3 assigned to device CUDA0, is_swa = 0"
+echo "llama_model_loader: loaded meta data with 30 key-value pairs and 291 tensors"
+echo "common_init_from_params: warming up the model with an empty run - please wait ..."
+fi
+exec sleep 30`
+	inst := newShellInstance(t, script, false, nil)
+	t.Cleanup(func() { inst.Stop() })
+
+	var mu sync.Mutex
+	var reported []string
+	inst.SetObserver(&instance.Observer{StartupProgress: func(progress instance.StartupProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, progress.Phase)
+	}})
+
+	if inst.GetStartupProgress() != nil {
+		t.Error("Expected no startup progress before the first start")
+	}
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	progress := waitForStartupPhase(t, inst, instance.StartupPhaseWarmingUp)
+	if progress.Percent == nil || *progress.Percent != 85 {
+		t.Errorf("Expected the warmup at 85%%, got %+v", progress)
+	}
+
+	// Each phase is reported, and the layers loaded as they advance the percentage enough,
+	// never going back
+	mu.Lock()
+	got := strings.Join(reported, ",")
+	mu.Unlock()
+	if want := "loading_metadata,loading_tensors,loading_tensors,loading_tensors,loading_tensors,loading_tensors,warming_up"; got != want {
+		t.Errorf("Expected the progress reported %s, got %s", want, got)
+	}
+
+	if data := marshalInstance(t, inst); !strings.Contains(data, `"startup_progress":{"phase":"warming_up","percent":85}`) {
+		t.Errorf("Expected the details to include the startup progress, got %s", data)
+	}
+
+	// A start from scratch, whose output is not recognized
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if strings.Contains(marshalInstance(t, inst), "startup_progress") {
+		t.Error("Expected no startup progress in the details of a stopped instance")
+	}
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for logs, _ := inst.GetLogs(1); !strings.Contains(logs, "some other backend output"); logs, _ = inst.GetLogs(1) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the output to be logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if progress := inst.GetStartupProgress(); progress == nil || progress.Phase != instance.StartupPhaseUnknown || progress.Percent != nil {
+		t.Errorf("Expected the progress to restart unknown, got %+v", progress)
+	}
+}
+
+// waitForStartupPhase waits for the startup of an instance to reach phase
+func waitForStartupPhase(t *testing.T, inst *instance.Process, phase string) instance.StartupProgress {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if progress := inst.GetStartupProgress(); progress != nil && progress.Phase == phase {
+			return *progress
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the startup of %s to reach %s, it is %+v", inst.Name, phase, inst.GetStartupProgress())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// marshalInstance returns the details of an instance as served by the API
+func marshalInstance(t *testing.T, inst *instance.Process) string {
+	t.Helper()
+	data, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,129 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Limits of the prompt templates of an instance, which are kept with its options
+const (
+	MaxPromptTemplateSize = 16 * 1024 // Bytes of a template
+	MaxPromptTemplates    = 64        // Templates of an instance
+)
+
+// ErrPromptTemplateNotFound is returned when rendering a template the instance does not have
+var ErrPromptTemplateNotFound = errors.New("prompt template not found")
+
+// missingKeyPattern extracts the variable from the error of a template executed with
+// missingkey=error
+var missingKeyPattern = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+// MissingVariableError is returned when a template refers to a variable it was not given
+type MissingVariableError struct {
+	Template string
+	Variable string
+}
+
+func (e *MissingVariableError) Error() string {
+	return fmt.Sprintf("prompt template %s requires variable %q", e.Template, e.Variable)
+}
+
+// ParsePromptTemplate parses a prompt template. Variables are referred to as {{.name}}, and
+// rendering fails on a variable that is not given.
+func ParsePromptTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+// RenderPromptTemplate renders a parsed prompt template with the given variables
+func RenderPromptTemplate(tmpl *template.Template, variables map[string]any) (string, error) {
+	if variables == nil {
+		variables = map[string]any{}
+	}
+	var prompt strings.Builder
+	if err := tmpl.Execute(&prompt, variables); err != nil {
+		if match := missingKeyPattern.FindStringSubmatch(err.Error()); match != nil {
+			return "", &MissingVariableError{Template: tmpl.Name(), Variable: match[1]}
+		}
+		return "", fmt.Errorf("failed to render prompt template %s: %w", tmpl.Name(), err)
+	}
+	return prompt.String(), nil
+}
+
+// GetPromptTemplates returns a copy of the prompt templates of the instance, by name
+func (i *Process) GetPromptTemplates() map[string]string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if i.options == nil {
+		return nil
+	}
+	return maps.Clone(i.options.PromptTemplates)
+}
+
+// RenderPrompt renders a prompt template of the instance with the given variables
+func (i *Process) RenderPrompt(name string, variables map[string]any) (string, error) {
+	i.mu.RLock()
+	var text string
+	var ok bool
+	if i.options != nil {
+		text, ok = i.options.PromptTemplates[name]
+	}
+	i.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrPromptTemplateNotFound, name)
+	}
+
+	tmpl, err := ParsePromptTemplate(name, text)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template %s: %w", name, err)
+	}
+	return RenderPromptTemplate(tmpl, variables)
+}
+
+// SetPromptTemplate adds or replaces a prompt template of the instance without restarting
+// it. An empty text removes the template.
+func (i *Process) SetPromptTemplate(name, text string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.options == nil {
+		return fmt.Errorf("instance %s has no options set", i.Name)
+	}
+
+	update := func(templates map[string]string) map[string]string {
+		templates = maps.Clone(templates)
+		if text == "" {
+			delete(templates, name)
+		} else {
+			if templates == nil {
+				templates = map[string]string{}
+			}
+			templates[name] = text
+		}
+		if len(templates) == 0 {
+			return nil
+		}
+		return templates
+	}
+
+	options := *i.options
+	options.PromptTemplates = update(options.PromptTemplates)
+	i.options = &options
+	if i.optionSources == nil {
+		i.optionSources = make(OptionSources)
+	}
+	if options.PromptTemplates != nil {
+		i.optionSources["prompt_templates"] = SourceExplicit
+	} else {
+		delete(i.optionSources, "prompt_templates")
+	}
+	if i.pendingOptions != nil {
+		templates := update(i.pendingOptions.PromptTemplates)
+		i.patchPendingOptions("prompt_templates", templates != nil, func(options *CreateInstanceOptions) {
+			options.PromptTemplates = templates
+		})
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package instance_test
+
+import (
+	"errors"
+	"llamactl/pkg/instance"
+	"testing"
+)
+
+func TestRenderPrompt(t *testing.T) {
+	inst := newShellInstance(t, "exec sleep 30", false, nil)
+	if err := inst.SetPromptTemplate("greet", "Hello {{.user.name}}{{range .topics}}, {{.}}{{end}}"); err != nil {
+		t.Fatalf("SetPromptTemplate failed: %v", err)
+	}
+
+	prompt, err := inst.RenderPrompt("greet", map[string]any{
+		"user":   map[string]any{"name": "Ada"},
+		"topics": []any{"engines", "notes"},
+	})
+	if err != nil || prompt != "Hello Ada, engines, notes" {
+		t.Errorf("Expected the rendered prompt, got %q, %v", prompt, err)
+	}
+
+	var missing *instance.MissingVariableError
+	if _, err := inst.RenderPrompt("greet", map[string]any{"topics": nil}); !errors.As(err, &missing) || missing.Variable != "user" {
+		t.Errorf("Expected the missing variable to be named, got %v", err)
+	}
+	if _, err := inst.RenderPrompt("greet", nil); !errors.As(err, &missing) {
+		t.Errorf("Expected no variables to miss the first one, got %v", err)
+	}
+	if _, err := inst.RenderPrompt("farewell", nil); !errors.Is(err, instance.ErrPromptTemplateNotFound) {
+		t.Errorf("Expected an unknown template not to be found, got %v", err)
+	}
+
+	// Templates are kept with the options, and removed with an empty text
+	if templates := inst.GetOptions().PromptTemplates; templates["greet"] == "" {
+		t.Errorf("Expected the template in the options, got %v", templates)
+	}
+	if err := inst.SetPromptTemplate("greet", ""); err != nil {
+		t.Fatalf("SetPromptTemplate failed: %v", err)
+	}
+	if templates := inst.GetPromptTemplates(); templates != nil {
+		t.Errorf("Expected no templates left, got %v", templates)
+	}
+}
@@ -0,0 +1,137 @@
+package instance
+
+import (
+	"io/fs"
+	"llamactl/pkg/config"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// Sources of the readiness timeout of an instance
+const (
+	ReadinessSourceExplicit  = "explicit"   // Set by the readiness_timeout option
+	ReadinessSourceModelSize = "model_size" // Derived from the size of the model files
+	ReadinessSourceDefault   = "default"    // on_demand_start_timeout, the model size is unknown
+)
+
+// Fallbacks for readiness settings left unset in the configuration
+const (
+	defaultReadinessTimeout       = config.Duration(2 * time.Minute)
+	defaultModelLoadMBPerSecond   = 100
+	defaultReadinessProbeInterval = time.Second
+)
+
+// splitGGUFPattern matches the first file of a GGUF model split into several files
+var splitGGUFPattern = regexp.MustCompile(`^(.*)-00001-of-(\d{5})\.gguf$`)
+
+// Readiness is how long an instance is given to become healthy after it starts, with the
+// inputs a timeout derived from the model size was computed from
+type Readiness struct {
+	Timeout        config.Duration `json:"timeout"`
+	Source         string          `json:"source"`
+	ModelSizeBytes int64           `json:"model_size_bytes,omitempty"`
+	MBPerSecond    int             `json:"mb_per_second,omitempty"`
+	BaseTimeout    config.Duration `json:"base_timeout,omitempty"`
+}
+
+// GetReadiness returns the readiness timeout of the instance and where it comes from
+func (i *Process) GetReadiness() *Readiness {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.readiness()
+}
+
+// readiness resolves the readiness timeout: the readiness_timeout option if set, otherwise
+// the base timeout plus the time to load the model files at model_load_mb_per_second, or
+// on_demand_start_timeout when the model size is unknown (caller must hold the lock)
+func (i *Process) readiness() *Readiness {
+	if i.options != nil && i.options.ReadinessTimeout != nil && *i.options.ReadinessTimeout > 0 {
+		return &Readiness{Timeout: *i.options.ReadinessTimeout, Source: ReadinessSourceExplicit}
+	}
+
+	settings := i.globalInstanceSettings
+	if i.modelSize > 0 {
+		rate := settings.ModelLoadMBPerSecond
+		if rate <= 0 {
+			rate = defaultModelLoadMBPerSecond
+		}
+		base := max(settings.ReadinessBaseTimeout, 0)
+		bytesPerSecond := int64(rate) * 1000 * 1000
+		loadSeconds := (i.modelSize + bytesPerSecond - 1) / bytesPerSecond
+		return &Readiness{
+			Timeout:        base + config.Duration(time.Duration(loadSeconds)*time.Second),
+			Source:         ReadinessSourceModelSize,
+			ModelSizeBytes: i.modelSize,
+			MBPerSecond:    rate,
+			BaseTimeout:    base,
+		}
+	}
+
+	timeout := settings.OnDemandStartTimeout
+	if timeout <= 0 {
+		timeout = defaultReadinessTimeout
+	}
+	return &Readiness{Timeout: timeout, Source: ReadinessSourceDefault}
+}
+
+// probeInterval returns the interval between the health checks of a starting backend
+// (caller must hold the lock)
+func (i *Process) probeInterval() time.Duration {
+	if interval := i.globalInstanceSettings.ReadinessProbeInterval.Duration(); interval > 0 {
+		return interval
+	}
+	return defaultReadinessProbeInterval
+}
+
+// refreshModelSize records the size of the model files the instance runs, the downloaded
+// file of a remote model once available (caller must hold the lock)
+func (i *Process) refreshModelSize() {
+	path := i.options.ModelPath()
+	if i.options.HasRemoteModel() {
+		path = i.modelPath
+	}
+	i.modelSize = modelFileSize(path)
+}
+
+// modelFileSize returns the size of a model: a file, all the parts of a split GGUF model,
+// or the files of a model directory. It returns 0 if the model is not a local path.
+func modelFileSize(path string) int64 {
+	if path == "" {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+
+	if !info.IsDir() {
+		match := splitGGUFPattern.FindStringSubmatch(path)
+		if match == nil {
+			return info.Size()
+		}
+		parts, _ := filepath.Glob(match[1] + "-*-of-" + match[2] + ".gguf")
+		var size int64
+		for _, part := range parts {
+			if partInfo, err := os.Stat(part); err == nil {
+				size += partInfo.Size()
+			}
+		}
+		return size
+	}
+
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			if entryInfo, err := entry.Info(); err == nil {
+				size += entryInfo.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
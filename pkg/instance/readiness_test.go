@@ -0,0 +1,250 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sparseFile creates a file of the given size without writing its content
+func sparseFile(t *testing.T, path string, size int64) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadiness(t *testing.T) {
+	const mb = 1000 * 1000
+	dir := t.TempDir()
+	single := sparseFile(t, filepath.Join(dir, "single.gguf"), 250*mb)
+	sparseFile(t, filepath.Join(dir, "split-00002-of-00002.gguf"), 100*mb)
+	split := sparseFile(t, filepath.Join(dir, "split-00001-of-00002.gguf"), 100*mb)
+	sparseFile(t, filepath.Join(dir, "safetensors", "model-1.safetensors"), 120*mb)
+	sparseFile(t, filepath.Join(dir, "safetensors", "model-2.safetensors"), 30*mb)
+	explicit := config.Duration(45 * time.Second)
+
+	tests := []struct {
+		name        string
+		model       string
+		timeout     *config.Duration
+		rate        int
+		wantTimeout int
+		wantSource  string
+		wantSize    int64
+	}{
+		{name: "explicit", model: single, timeout: &explicit, rate: 100, wantTimeout: 45, wantSource: instance.ReadinessSourceExplicit},
+		{name: "model file", model: single, rate: 100, wantTimeout: 33, wantSource: instance.ReadinessSourceModelSize, wantSize: 250 * mb},
+		{name: "slow storage", model: single, rate: 10, wantTimeout: 55, wantSource: instance.ReadinessSourceModelSize, wantSize: 250 * mb},
+		{name: "default rate", model: single, wantTimeout: 33, wantSource: instance.ReadinessSourceModelSize, wantSize: 250 * mb},
+		{name: "split model", model: split, rate: 100, wantTimeout: 32, wantSource: instance.ReadinessSourceModelSize, wantSize: 200 * mb},
+		{name: "model directory", model: filepath.Join(dir, "safetensors"), rate: 100, wantTimeout: 32, wantSource: instance.ReadinessSourceModelSize, wantSize: 150 * mb},
+		{name: "unknown model", model: filepath.Join(dir, "missing.gguf"), rate: 100, wantTimeout: 120, wantSource: instance.ReadinessSourceDefault},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backendConfig := &config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "llama-server"}}
+			globalSettings := &config.InstancesConfig{
+				LogsDir:              t.TempDir(),
+				OnDemandStartTimeout: config.Duration(2 * time.Minute),
+				ReadinessBaseTimeout: config.Duration(30 * time.Second),
+				ModelLoadMBPerSecond: tt.rate,
+			}
+			options := &instance.CreateInstanceOptions{
+				BackendType:        backends.BackendTypeLlamaCpp,
+				ReadinessTimeout:   tt.timeout,
+				LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: tt.model},
+			}
+			inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, nil)
+
+			readiness := inst.GetReadiness()
+			if readiness.Timeout.Duration() != time.Duration(tt.wantTimeout)*time.Second || readiness.Source != tt.wantSource || readiness.ModelSizeBytes != tt.wantSize {
+				t.Errorf("Expected a %ds %s timeout for %d bytes, got %+v", tt.wantTimeout, tt.wantSource, tt.wantSize, readiness)
+			}
+
+			// The timeout and its inputs are part of the instance details
+			data, err := json.Marshal(inst)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var details struct {
+				Readiness *instance.Readiness `json:"readiness"`
+			}
+			if err := json.Unmarshal(data, &details); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if details.Readiness == nil || *details.Readiness != *readiness {
+				t.Errorf("Expected readiness %+v in the instance details, got %s", readiness, data)
+			}
+		})
+	}
+}
+
+func TestWaitForHealthy_ProcessExits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	// The backend dies while "loading", without ever opening its port
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "sleep 0.2; exit 3"}},
+	}
+	globalSettings := &config.InstancesConfig{LogsDir: t.TempDir()}
+	autoRestart := false
+	timeout := config.Duration(10 * time.Minute)
+	options := &instance.CreateInstanceOptions{
+		BackendType:      backends.BackendTypeLlamaCpp,
+		AutoRestart:      &autoRestart,
+		ReadinessTimeout: &timeout,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  "127.0.0.1",
+			Port:  port,
+		},
+	}
+	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, nil)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer inst.Stop()
+
+	start := time.Now()
+	err = inst.WaitForHealthy(0)
+	if err == nil || !strings.Contains(err.Error(), "exited before becoming healthy") {
+		t.Fatalf("Expected the exit to be reported, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the crash to be reported within seconds, took %v", elapsed)
+	}
+}
+
+func TestReadiness_TimeoutKillsProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// The backend keeps loading, answering its health checks with 503
+	var probes atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "exec sleep 30"}},
+	}
+	globalSettings := &config.InstancesConfig{
+		LogsDir:                t.TempDir(),
+		ReadinessProbeInterval: config.Duration(50 * time.Millisecond),
+	}
+	options := &instance.CreateInstanceOptions{
+		BackendType:      backends.BackendTypeLlamaCpp,
+		AutoRestart:      testutil.BoolPtr(true),
+		MaxRestarts:      testutil.IntPtr(1),
+		RestartDelay:     testutil.DurationPtr(0),
+		ReadinessTimeout: testutil.DurationPtr(300 * time.Millisecond),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  backendURL.Hostname(),
+			Port:  port,
+		},
+	}
+	recorder := newTransitionRecorder()
+	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, recorder.record)
+	t.Cleanup(func() { inst.Stop() })
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	recorder.waitFor(t, instance.Failed)
+
+	var statuses []instance.InstanceStatus
+	for _, tr := range recorder.snapshot() {
+		statuses = append(statuses, tr.newStatus)
+		if (tr.newStatus == instance.Restarting || tr.newStatus == instance.Stopped) && tr.reason.Code != instance.ReasonHealthProbeFailure {
+			t.Errorf("Expected the process to be killed for its readiness timeout, got %+v", tr.reason)
+		}
+	}
+	expected := []instance.InstanceStatus{instance.Starting, instance.Restarting, instance.Starting, instance.Stopped, instance.Failed}
+	if !slices.Equal(statuses, expected) {
+		t.Errorf("Expected transitions %v, got %v", expected, statuses)
+	}
+
+	// Each run is checked every 50ms until it times out
+	if n := probes.Load(); n < 6 {
+		t.Errorf("Expected the probe interval to be used, got %d health checks", n)
+	}
+}
+
+// Run with -race: the readiness check marks the instance ready from its own goroutine
+func TestReadiness_StatusReadWhileBecomingReady(t *testing.T) {
+	globalSettings := &config.InstancesConfig{ReadinessProbeInterval: config.Duration(10 * time.Millisecond)}
+	inst := newBackendInstance(t, reloadBackend("backend", http.StatusOK), &instance.CreateInstanceOptions{}, globalSettings, nil)
+
+	done := make(chan struct{})
+	readersDone := make(chan struct{})
+	go func() {
+		defer close(readersDone)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			inst.GetStatus()
+			inst.IsReady()
+			inst.GetStatusReason()
+			if _, err := json.Marshal(inst); err != nil {
+				t.Errorf("Marshal failed: %v", err)
+				return
+			}
+		}
+	}()
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waitForStatus(t, inst, instance.Running)
+	close(done)
+	<-readersDone
+
+	if !inst.IsReady() {
+		t.Errorf("Expected the instance to be ready, got %s", inst.GetStatus())
+	}
+}
@@ -0,0 +1,306 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"llamactl/pkg/config"
+)
+
+// ErrReloadUnsupported is returned when reloading an instance whose backend can only be restarted
+var ErrReloadUnsupported = errors.New("instance cannot be reloaded")
+
+// errReloadStopped is the cause of a reload given up because the instance was stopped
+var errReloadStopped = errors.New("instance was stopped during the reload")
+
+// errPreviousExited is the cause of a reload given up because the process it replaces exited
+var errPreviousExited = errors.New("the process being replaced exited during the reload")
+
+// reloadDrainInterval is how often the requests in flight on a replaced process are checked
+const reloadDrainInterval = 100 * time.Millisecond
+
+// Reload replaces the backend of a running instance without taking it offline. A second
+// process is started on port with the options the next start would use, and once it passes
+// a readiness check the proxy switches over to it, so the instance runs on port from then
+// on. The previous process is stopped once the requests in flight on it completed, or its
+// stop timeout elapsed. A replacement that does not become ready is killed and the previous
+// process keeps serving. Stopping the instance meanwhile gives up the reload.
+func (i *Process) Reload(port int) error {
+	i.restartMu.Lock()
+	defer i.restartMu.Unlock()
+
+	i.mu.Lock()
+	if err := i.reloadable(); err != nil {
+		i.mu.Unlock()
+		return err
+	}
+
+	// The replacement runs with the options of the next start
+	pending := i.pendingOptions
+	options, sources := i.options, i.optionSources
+	if pending != nil {
+		options, sources = pending, i.pendingSources
+	}
+	if options.BackendType != i.options.BackendType {
+		i.mu.Unlock()
+		return fmt.Errorf("cannot reload instance %s to another backend type, restart it: %w", i.Name, ErrReloadUnsupported)
+	}
+	options = options.withPort(port)
+	sources = maps.Clone(sources)
+	if sources == nil {
+		sources = make(OptionSources)
+	}
+	sources["backend_options.port"] = SourceInferred
+
+	bindAddress := ""
+	if options.BindInterface != "" {
+		var err error
+		if bindAddress, err = resolveBindAddress(options.BindInterface, options.bindHost()); err != nil {
+			i.mu.Unlock()
+			return fmt.Errorf("failed to bind instance %s to %s: %w", i.Name, options.BindInterface, err)
+		}
+	}
+	backendKey, pendingKey := i.backendKey, i.pendingBackendKey
+	if pendingKey != nil {
+		backendKey = *pendingKey
+	}
+
+	reloadCtx, cancelReload := context.WithCancelCause(context.Background())
+	i.reloadCancel = func() { cancelReload(errReloadStopped) }
+	processCtx, cancelProcess := context.WithCancel(context.Background())
+	cmd, err := i.buildCommandFor(processCtx, i.commandOptionsFor(options, bindAddress, backendKey))
+	backendCommand, _ := i.backendCommandFor(options)
+	previous := i.monitorDone
+	timeout := i.readiness().Timeout.Duration()
+	stderrTail := newOutputTail(i.memory, i.Name, memoryStderrTail, startFailureOutputLines)
+	outputTail := newOutputTail(i.memory, i.Name, memoryOutputTail, lastExitOutputLines)
+	i.mu.Unlock()
+	defer func() {
+		i.mu.Lock()
+		i.reloadCancel = nil
+		i.mu.Unlock()
+		cancelReload(nil)
+	}()
+	release := func() {
+		cancelProcess()
+		stderrTail.release()
+		outputTail.release()
+	}
+	if err != nil {
+		release()
+		return fmt.Errorf("failed to build command: %w", err)
+	}
+	if err := checkExecutable(backendCommand, len(options.LaunchWrapper) > 0); err != nil {
+		release()
+		return fmt.Errorf("failed to start the replacement of instance %s: %w", i.Name, err)
+	}
+
+	// Given up if the process it replaces exits meanwhile
+	go func() {
+		select {
+		case <-previous:
+			cancelReload(errPreviousExited)
+		case <-reloadCtx.Done():
+		}
+	}()
+
+	binary, binaryErr := identifyBinary(backendCommand)
+	if binaryErr != nil {
+		log.Printf("Failed to read the backend binary of instance %s: %v", i.Name, binaryErr)
+	}
+	output, err := i.spawnReplacement(cmd)
+	if err != nil {
+		release()
+		return fmt.Errorf("failed to start the replacement of instance %s: %w", i.Name, err)
+	}
+	message := fmt.Sprintf("reloading: started process %d on port %d", cmd.Process.Pid, port)
+	log.Printf("Instance %s %s", i.Name, message)
+	i.logger.writeLine(message)
+
+	// The output of the replacement reaches the detectors once it is the current process
+	current := new(atomic.Bool)
+	i.readProcessOutput(output, stderrTail, outputTail, current)
+	exited := make(chan struct{})
+	var exitErr error
+	i.goroutines.Go(func() {
+		exitErr = cmd.Wait()
+		close(exited)
+	})
+
+	rollback := func(reason error) error {
+		if err := killProcessGroup(cmd); err != nil {
+			log.Printf("Failed to kill the replacement of instance %s: %v", i.Name, err)
+		}
+		<-exited
+		releaseProcessGroup(cmd)
+		output.drain()
+		release()
+		log.Printf("Reload of instance %s failed, keeping the current process: %v", i.Name, reason)
+		i.logger.writeLine("reload failed, keeping the current process: " + reason.Error())
+		return fmt.Errorf("failed to reload instance %s: %w", i.Name, reason)
+	}
+
+	transport := newBackendTransport(options.BackendTLS)
+	healthURL := fmt.Sprintf("%s://%s/health", options.BackendTLS.BackendScheme(), net.JoinHostPort(options.connectHost(bindAddress), strconv.Itoa(port)))
+	if err := i.pollHealth(reloadCtx, healthURL, transport, timeout, exited); err != nil {
+		if errors.Is(err, errExitedBeforeHealthy) {
+			code, exitMessage := exitReason(exitErr)
+			err = fmt.Errorf("replacement exited before becoming healthy (%s): %s", code, exitMessage)
+		}
+		return rollback(err)
+	}
+
+	// Switches the instance over to the replacement
+	i.mu.Lock()
+	if err := context.Cause(reloadCtx); err != nil || i.closed || i.monitorDone != previous || i.GetStatus() != Running {
+		if err == nil {
+			err = fmt.Errorf("instance %s is %s", i.Name, i.GetStatus())
+		}
+		i.mu.Unlock()
+		return rollback(err)
+	}
+	previousCmd, previousCancel, previousPool := i.cmd, i.cancel, i.connections.Load()
+	if i.pendingOptions == pending {
+		i.pendingOptions, i.pendingSources = nil, nil
+	}
+	i.applyOptions(options, sources)
+	i.transport = transport
+	i.bindAddress, i.ListenCheck = bindAddress, nil
+	if pendingKey != nil && i.pendingBackendKey == pendingKey {
+		i.backendKey, i.pendingBackendKey = *pendingKey, nil
+	}
+	i.ctx, i.cancel, i.cmd = processCtx, cancelProcess, cmd
+	i.binary = binary
+	i.resetRun()
+	// Keeps the output the replacement wrote while loading
+	i.stderrTail.release()
+	i.outputTail.release()
+	i.stderrTail, i.outputTail = stderrTail, outputTail
+	now := i.timeProvider.Now()
+	i.startedAt, i.readyAt, i.healthy = now, now, true
+	ready := 100
+	i.startupProgress = &StartupProgress{Phase: StartupPhaseReady, Percent: &ready}
+	if i.forwardOutput != nil {
+		i.forwardOutput.Store(false)
+	}
+	current.Store(true)
+	i.forwardOutput = current
+	message = fmt.Sprintf("reloaded onto process %d on port %d", cmd.Process.Pid, port)
+	i.SetStatus(Running, ReasonReload, message)
+	i.monitorRun(output, func() error {
+		<-exited
+		return exitErr
+	})
+	stopTimeout := i.stopTimeout()
+	i.mu.Unlock()
+	log.Printf("Instance %s %s, stopping the previous process", i.Name, message)
+	i.logger.writeLine(message)
+
+	i.stopReplaced(previousCmd, previous, previousPool, stopTimeout)
+	previousCancel()
+	return nil
+}
+
+// reloadable checks that the instance runs a backend a reload can replace (caller must hold
+// the lock)
+func (i *Process) reloadable() error {
+	if i.closed {
+		return fmt.Errorf("instance %s has been deleted", i.Name)
+	}
+	if i.options == nil {
+		return fmt.Errorf("instance %s has no options set", i.Name)
+	}
+	if !i.options.IsManaged() {
+		return fmt.Errorf("cannot reload instance %s: %w", i.Name, ErrUnmanaged)
+	}
+	if i.startDone != nil {
+		return fmt.Errorf("cannot reload instance %s: %w", i.Name, ErrAlreadyStarting)
+	}
+	if i.GetStatus() != Running || !i.healthy || i.cmd == nil || i.monitorDone == nil {
+		return fmt.Errorf("cannot reload instance %s, it is %s: %w", i.Name, i.GetStatus(), ErrNotRunning)
+	}
+	if i.drainStop != nil {
+		return fmt.Errorf("cannot reload instance %s, it is stopping: %w", i.Name, ErrNotRunning)
+	}
+	if i.detach != nil {
+		// Its output goes to files the replacement would share
+		return fmt.Errorf("cannot reload instance %s, its backend is left running on shutdown: %w", i.Name, ErrReloadUnsupported)
+	}
+	return nil
+}
+
+// spawnReplacement starts cmd in a process group of its own, its output going to pipes
+func (i *Process) spawnReplacement(cmd *exec.Cmd) (*processOutput, error) {
+	if runtime.GOOS != "windows" {
+		setProcAttrs(cmd)
+	}
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+	stderrReader, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdoutReader.Close()
+		stdoutWriter.Close()
+		return nil, fmt.Errorf("failed to get stderr pipe: %w", err)
+	}
+	cmd.Stdout, cmd.Stderr = stdoutWriter, stderrWriter
+	err = cmd.Start()
+	// The process has its own copies of the write ends
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if err != nil {
+		stdoutReader.Close()
+		stderrReader.Close()
+		return nil, err
+	}
+	if err := attachProcessGroup(cmd); err != nil {
+		log.Printf("Failed to track the process tree of the replacement of instance %s: %v", i.Name, err)
+	}
+	return &processOutput{stdout: stdoutReader, stderr: stderrReader}, nil
+}
+
+// stopReplaced stops the process a reload replaced, whose monitor closes done: once the
+// requests in flight on it through pool completed it is sent SIGTERM, and killed if it does
+// not exit within the stop timeout
+func (i *Process) stopReplaced(cmd *exec.Cmd, done <-chan struct{}, pool *connectionPool, stopTimeout time.Duration) {
+	drainDeadline := time.Now().Add(stopTimeout)
+	for pool != nil && pool.inUse() > 0 && time.Now().Before(drainDeadline) {
+		select {
+		case <-done:
+			return
+		case <-time.After(reloadDrainInterval):
+		}
+	}
+
+	if err := signalProcessGroup(cmd, syscall.SIGTERM); err != nil {
+		log.Printf("Failed to send SIGTERM to the replaced process of instance %s: %v", i.Name, err)
+	}
+	select {
+	case <-done:
+		// Whatever is left of its process group
+		killProcessGroup(cmd)
+	case <-time.After(stopTimeout):
+		if err := killProcessGroup(cmd); err != nil {
+			log.Printf("Failed to force kill the replaced process of instance %s: %v", i.Name, err)
+		}
+		log.Printf("Replaced process of instance %s did not stop within %s, force killed", i.Name, config.Duration(stopTimeout))
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			log.Printf("Warning: monitor of the replaced process of instance %s did not complete after force kill", i.Name)
+		}
+	}
+	releaseProcessGroup(cmd)
+}
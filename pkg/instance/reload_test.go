@@ -0,0 +1,152 @@
+package instance_test
+
+import (
+	"errors"
+	"io"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// reloadBackend answers the health checks of a process with status and its other requests
+// with name
+func reloadBackend(name string, status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(name))
+	}
+}
+
+// newReloadInstance starts an instance answered for by handler, its processes sleeping
+// while the test servers answer for them
+func newReloadInstance(t *testing.T, handler http.HandlerFunc) *instance.Process {
+	t.Helper()
+	readinessTimeout := config.Duration(500 * time.Millisecond)
+	options := &instance.CreateInstanceOptions{
+		ReadinessTimeout: &readinessTimeout,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model:   "/path/to/model.gguf",
+			CtxSize: 4096,
+		},
+	}
+	globalSettings := &config.InstancesConfig{
+		ReadinessProbeInterval: config.Duration(20 * time.Millisecond),
+		DefaultStopTimeout:     config.Duration(2 * time.Second),
+	}
+
+	inst := newBackendInstance(t, handler, options, globalSettings, nil)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	waitForStatus(t, inst, instance.Running)
+	return inst
+}
+
+// proxiedBy returns the backend a request through the proxy of the instance reaches
+func proxiedBy(t *testing.T, inst *instance.Process) string {
+	t.Helper()
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	proxy.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	body, _ := io.ReadAll(recorder.Body)
+	return string(body)
+}
+
+func withCtxSize(options *instance.CreateInstanceOptions, ctxSize int) *instance.CreateInstanceOptions {
+	updated := *options
+	backendOptions := *options.LlamaServerOptions
+	backendOptions.CtxSize = ctxSize
+	updated.LlamaServerOptions = &backendOptions
+	return &updated
+}
+
+func TestReload_SwitchesToReplacement(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	inst := newReloadInstance(t, reloadBackend("previous", http.StatusOK))
+	replacementPort := serveBackend(t, reloadBackend("replacement", http.StatusOK))
+
+	if got := proxiedBy(t, inst); got != "previous" {
+		t.Fatalf("Expected the proxy to reach the previous backend, got %q", got)
+	}
+	inst.SetOptions(withCtxSize(inst.GetOptions(), 8192))
+	if !inst.RestartRequired() {
+		t.Fatal("Expected the new options to be pending")
+	}
+
+	if err := inst.Reload(replacementPort); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if got := proxiedBy(t, inst); got != "replacement" {
+		t.Errorf("Expected the proxy to reach the replacement, got %q", got)
+	}
+	if inst.GetPort() != replacementPort {
+		t.Errorf("Expected the instance to run on port %d, got %d", replacementPort, inst.GetPort())
+	}
+	if inst.RestartRequired() || inst.GetOptions().LlamaServerOptions.CtxSize != 8192 {
+		t.Errorf("Expected the pending options to be in effect, got ctx_size %d", inst.GetOptions().LlamaServerOptions.CtxSize)
+	}
+	if sources := inst.GetOptionSources(); sources["backend_options.port"] != instance.SourceInferred {
+		t.Errorf("Expected the port of the replacement to be inferred, got %q", sources["backend_options.port"])
+	}
+
+	// The exit of the replaced process is not a crash of the instance
+	time.Sleep(200 * time.Millisecond)
+	if inst.GetStatus() != instance.Running || inst.GetStatusReason() == nil || inst.GetStatusReason().Code != instance.ReasonReload {
+		t.Errorf("Expected the instance to keep running after the reload, got %s (%+v)", inst.GetStatus(), inst.GetStatusReason())
+	}
+	if err := inst.Stop(); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+}
+
+func TestReload_KeepsPreviousWhenReplacementUnhealthy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	inst := newReloadInstance(t, reloadBackend("previous", http.StatusOK))
+	previousPort := inst.GetPort()
+	replacementPort := serveBackend(t, reloadBackend("replacement", http.StatusServiceUnavailable))
+	inst.SetOptions(withCtxSize(inst.GetOptions(), 8192))
+
+	if err := inst.Reload(replacementPort); err == nil {
+		t.Fatal("Expected the reload to fail")
+	}
+
+	if got := proxiedBy(t, inst); got != "previous" {
+		t.Errorf("Expected the previous backend to keep serving, got %q", got)
+	}
+	if inst.GetPort() != previousPort || inst.GetStatus() != instance.Running {
+		t.Errorf("Expected the instance to keep running on port %d, got %s on %d", previousPort, inst.GetStatus(), inst.GetPort())
+	}
+	if !inst.RestartRequired() {
+		t.Error("Expected the new options to stay pending")
+	}
+}
+
+func TestReload_RequiresRunningInstance(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	inst := newReloadInstance(t, reloadBackend("previous", http.StatusOK))
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+
+	if err := inst.Reload(serveBackend(t, reloadBackend("replacement", http.StatusOK))); !errors.Is(err, instance.ErrNotRunning) {
+		t.Errorf("Expected a stopped instance not to be reloaded, got %v", err)
+	}
+}
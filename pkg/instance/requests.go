@@ -0,0 +1,128 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRequestCancelled is the cause of the context of a request cancelled with CancelRequest
+var ErrRequestCancelled = errors.New("request cancelled by an operator")
+
+// InFlightRequest describes a proxied request an instance is serving. Request and
+// response bodies are never recorded.
+type InFlightRequest struct {
+	ID         string    `json:"id"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	APIKey     string    `json:"api_key,omitempty"`  // Masked key the request authenticated with
+	Priority   string    `json:"priority,omitempty"` // Admission priority, empty for requests not admitted through the queue
+	Streaming  bool      `json:"streaming"`
+	StartedAt  time.Time `json:"started_at"`
+	AgeSeconds float64   `json:"age_seconds"`
+}
+
+// RequestTracker tracks the in-flight requests of an instance. Each request only touches
+// its own entry, which sync.Map serves without a shared lock, so tracking stays off the
+// critical path of concurrent requests.
+type RequestTracker struct {
+	nextID   atomic.Uint64
+	requests sync.Map // ID -> *trackedRequest
+	inFlight atomic.Int64
+	now      func() time.Time
+}
+
+type trackedRequest struct {
+	info      InFlightRequest
+	streaming atomic.Bool
+	cancel    context.CancelCauseFunc
+}
+
+type trackedRequestKey struct{}
+
+// NewRequestTracker creates an empty request tracker
+func NewRequestTracker(now func() time.Time) *RequestTracker {
+	return &RequestTracker{now: now}
+}
+
+// Track registers r as in flight. It returns r bound to a context that Cancel cancels, and
+// a function removing the request, which must be deferred so the request is removed
+// however it ends.
+func (t *RequestTracker) Track(r *http.Request, apiKey, priority string, streaming bool) (*http.Request, func()) {
+	ctx, cancel := context.WithCancelCause(r.Context())
+	tracked := &trackedRequest{
+		info: InFlightRequest{
+			ID:        strconv.FormatUint(t.nextID.Add(1), 10),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			APIKey:    apiKey,
+			Priority:  priority,
+			StartedAt: t.now(),
+		},
+		cancel: cancel,
+	}
+	tracked.streaming.Store(streaming)
+	t.requests.Store(tracked.info.ID, tracked)
+	t.inFlight.Add(1)
+
+	var once sync.Once
+	done := func() {
+		once.Do(func() {
+			t.requests.Delete(tracked.info.ID)
+			t.inFlight.Add(-1)
+			cancel(context.Canceled)
+		})
+	}
+	return r.WithContext(context.WithValue(ctx, trackedRequestKey{}, tracked)), done
+}
+
+// List returns the in-flight requests, oldest first
+func (t *RequestTracker) List() []InFlightRequest {
+	now := t.now()
+	requests := []InFlightRequest{}
+	t.requests.Range(func(_, value any) bool {
+		tracked := value.(*trackedRequest)
+		info := tracked.info
+		info.Streaming = tracked.streaming.Load()
+		info.AgeSeconds = now.Sub(info.StartedAt).Seconds()
+		requests = append(requests, info)
+		return true
+	})
+	sort.Slice(requests, func(a, b int) bool {
+		return requests[a].StartedAt.Before(requests[b].StartedAt)
+	})
+	return requests
+}
+
+// Count returns the number of requests in flight
+func (t *RequestTracker) Count() int {
+	return int(t.inFlight.Load())
+}
+
+// Cancel cancels the context of an in-flight request, which aborts its backend request.
+// It reports whether the request was found.
+func (t *RequestTracker) Cancel(id string) bool {
+	value, ok := t.requests.Load(id)
+	if !ok {
+		return false
+	}
+	value.(*trackedRequest).cancel(ErrRequestCancelled)
+	return true
+}
+
+// markStreaming flags the tracked request of a response as streaming once the backend
+// answers with an event stream
+func markStreaming(resp *http.Response) {
+	if resp.Request == nil || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return
+	}
+	if tracked, ok := resp.Request.Context().Value(trackedRequestKey{}).(*trackedRequest); ok {
+		tracked.streaming.Store(true)
+	}
+}
@@ -0,0 +1,162 @@
+package instance_test
+
+import (
+	"context"
+	"io"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitForRequests waits until the instance has count in-flight requests
+func waitForRequests(t *testing.T, inst *instance.Process, count int) []instance.InFlightRequest {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		requests := inst.GetRequests()
+		if len(requests) == count {
+			return requests
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %d in-flight requests, got %+v", count, requests)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInFlightRequests(t *testing.T) {
+	received := make(chan struct{}, 1)
+	cancelled := make(chan struct{}, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stream" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {}\n\n")
+			w.(http.Flusher).Flush()
+		}
+		received <- struct{}{}
+		<-r.Context().Done()
+		cancelled <- struct{}{}
+	}))
+	defer backend.Close()
+
+	inst := newTLSTestInstance(t, backend, nil)
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, done := inst.TrackRequest(r, "sk-test...abcd", "high", false)
+		defer done()
+		if r.URL.Path == "/panic" {
+			panic(http.ErrAbortHandler)
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer front.Close()
+
+	// A request is in flight before the proxy has forwarded it
+	waitForBackend := func(t *testing.T) {
+		t.Helper()
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the backend to receive the request")
+		}
+	}
+
+	t.Run("listed and cancelled", func(t *testing.T) {
+		responses := make(chan *http.Response, 1)
+		go func() {
+			resp, err := http.Post(front.URL+"/stream", "application/json", nil)
+			if err != nil {
+				t.Errorf("Request failed: %v", err)
+				close(responses)
+				return
+			}
+			responses <- resp
+		}()
+		resp := <-responses
+		if resp == nil {
+			return
+		}
+		defer resp.Body.Close()
+
+		requests := waitForRequests(t, inst, 1)
+		waitForBackend(t)
+		got := requests[0]
+		if got.Method != "POST" || got.Path != "/stream" || got.APIKey != "sk-test...abcd" || got.Priority != "high" || !got.Streaming {
+			t.Errorf("Unexpected in-flight request %+v", got)
+		}
+		if got.AgeSeconds < 0 || got.StartedAt.IsZero() {
+			t.Errorf("Expected the age of the request to be reported, got %+v", got)
+		}
+
+		if !inst.CancelRequest(got.ID) {
+			t.Fatal("Expected the request to be found")
+		}
+		select {
+		case <-cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("The backend request was not cancelled")
+		}
+		waitForRequests(t, inst, 0)
+		if inst.CancelRequest(got.ID) {
+			t.Error("Expected a completed request not to be found")
+		}
+	})
+
+	t.Run("cancelled before the response", func(t *testing.T) {
+		type result struct {
+			resp *http.Response
+			err  error
+		}
+		results := make(chan result, 1)
+		go func() {
+			resp, err := http.Get(front.URL + "/slow")
+			results <- result{resp, err}
+		}()
+
+		requests := waitForRequests(t, inst, 1)
+		waitForBackend(t)
+		if requests[0].Streaming {
+			t.Error("Expected a request without response to not be streaming")
+		}
+		inst.CancelRequest(requests[0].ID)
+		<-cancelled
+
+		res := <-results
+		if res.err != nil {
+			t.Fatalf("Request failed: %v", res.err)
+		}
+		res.resp.Body.Close()
+		if res.resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected the client to receive 503, got %d", res.resp.StatusCode)
+		}
+		waitForRequests(t, inst, 0)
+	})
+
+	t.Run("removed when the client disconnects", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, "GET", front.URL+"/slow", nil)
+		go func() {
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}()
+
+		waitForRequests(t, inst, 1)
+		waitForBackend(t)
+		cancel()
+		<-cancelled
+		waitForRequests(t, inst, 0)
+	})
+
+	t.Run("removed when the handler panics", func(t *testing.T) {
+		if resp, err := http.Get(front.URL + "/panic"); err == nil {
+			resp.Body.Close()
+		}
+		waitForRequests(t, inst, 0)
+	})
+}
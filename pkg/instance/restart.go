@@ -0,0 +1,74 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"llamactl/pkg/config"
+)
+
+// portReleaseTimeout is how long Restart waits for the port of the stopped backend to be
+// released before starting the new one anyway
+const portReleaseTimeout = 10 * time.Second
+
+// Restart stops the instance, if it runs, and starts it again as a single operation. A
+// pending automatic restart is cancelled, and the new process is started once the previous
+// one released its port. A stopped or failed instance is simply started.
+func (i *Process) Restart() error {
+	i.restartMu.Lock()
+	defer i.restartMu.Unlock()
+
+	i.mu.RLock()
+	options := i.options
+	i.mu.RUnlock()
+
+	if options == nil {
+		return fmt.Errorf("instance %s has no options set", i.Name)
+	}
+	if !options.IsManaged() {
+		return fmt.Errorf("cannot restart instance %s: %w", i.Name, ErrUnmanaged)
+	}
+
+	// Also cancels a pending automatic restart or queued start. The only error left is that
+	// nothing was running, which leaves nothing to stop.
+	_ = i.StopWithReason(ReasonUserStop, "stopped to restart")
+
+	i.mu.RLock()
+	host, port := i.bindAddress, options.port()
+	if host == "" {
+		host = options.bindHost()
+	}
+	i.mu.RUnlock()
+	if port > 0 && !waitForPortRelease(host, port, portReleaseTimeout) {
+		log.Printf("Warning: port %d of instance %s is still in use after %s, starting it anyway", port, i.Name, config.Duration(portReleaseTimeout))
+	}
+
+	return i.StartWithReason(ReasonUserStart, "restarted")
+}
+
+// waitForPortRelease waits up to timeout for no process to listen on host:port, and reports
+// whether it was released. Errors other than the address being in use, e.g. a host that is
+// not local, leave nothing to wait for.
+func waitForPortRelease(host string, port int, timeout time.Duration) bool {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	deadline := time.Now().Add(timeout)
+	for {
+		listener, err := net.Listen("tcp", address)
+		if err == nil {
+			listener.Close()
+			return true
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
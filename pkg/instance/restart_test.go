@@ -0,0 +1,162 @@
+package instance_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestart(t *testing.T) {
+	t.Run("running", func(t *testing.T) {
+		recorder := newTransitionRecorder()
+		inst := newShellInstance(t, "exec sleep 30", false, recorder.record)
+		t.Cleanup(func() { inst.Stop() })
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+
+		if err := inst.Restart(); err != nil {
+			t.Fatalf("Restart failed: %v", err)
+		}
+		if !inst.IsRunning() {
+			t.Fatalf("Expected the instance to run after the restart, it is %s", inst.GetStatus())
+		}
+		var statuses []string
+		for _, tr := range recorder.snapshot() {
+			statuses = append(statuses, tr.newStatus.String()+"/"+string(tr.reason.Code))
+		}
+		if got := strings.Join(statuses, " "); got != "starting/user_start stopped/user_stop starting/user_start" {
+			t.Errorf("Expected a stop and a start, got %s", got)
+		}
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		inst := newShellInstance(t, "exec sleep 30", false, nil)
+		t.Cleanup(func() { inst.Stop() })
+		if err := inst.Restart(); err != nil {
+			t.Fatalf("Restart failed: %v", err)
+		}
+		if !inst.IsRunning() {
+			t.Errorf("Expected a stopped instance to be started, it is %s", inst.GetStatus())
+		}
+	})
+
+	t.Run("pending auto-restart", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("requires a POSIX shell")
+		}
+		// Crashes first, and keeps running once restarted
+		marker := t.TempDir() + "/crashed"
+		script := "if [ -e " + marker + " ]; then exec sleep 30; fi; touch " + marker + "; exit 1"
+		options := &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			AutoRestart:        testutil.BoolPtr(true),
+			MaxRestarts:        testutil.IntPtr(3),
+			RestartDelay:       testutil.DurationPtr(time.Minute),
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+		}
+		backendConfig := &config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", script}}}
+		recorder := newTransitionRecorder()
+		inst := instance.NewInstance("test-instance", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, recorder.record)
+		t.Cleanup(func() { inst.Stop() })
+
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		recorder.waitFor(t, instance.Restarting)
+
+		if err := inst.Restart(); err != nil {
+			t.Fatalf("Restart failed: %v", err)
+		}
+		if !inst.IsRunning() || inst.NextRestartAt != nil {
+			t.Errorf("Expected the pending restart to be replaced by a start, got %s with next restart at %v", inst.GetStatus(), inst.NextRestartAt)
+		}
+		if budget := inst.GetRestartBudget(); budget.Restarts != 0 {
+			t.Errorf("Expected the restart counter to start over, got %d", budget.Restarts)
+		}
+	})
+
+	t.Run("waits for the port", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen failed: %v", err)
+		}
+		inst := newShellInstance(t, "exec sleep 30", false, nil)
+		t.Cleanup(func() { inst.Stop() })
+		options := inst.GetOptions()
+		options.LlamaServerOptions.Host = "127.0.0.1"
+		options.LlamaServerOptions.Port = listener.Addr().(*net.TCPAddr).Port
+		inst.SetOptions(options)
+
+		// The port is released some time after the previous process stopped
+		const held = 300 * time.Millisecond
+		time.AfterFunc(held, func() { listener.Close() })
+		start := time.Now()
+		if err := inst.Restart(); err != nil {
+			t.Fatalf("Restart failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed < held {
+			t.Errorf("Expected the start to wait for the port, it took %s", elapsed)
+		}
+	})
+
+	t.Run("no options", func(t *testing.T) {
+		inst := &instance.Process{Name: "test-instance"}
+		if err := inst.Restart(); err == nil || !strings.Contains(err.Error(), "no options") {
+			t.Errorf("Expected an error for an instance without options, got %v", err)
+		}
+	})
+}
+
+// stopAtRestartClock stops its instance as the delay of an automatic restart expires
+type stopAtRestartClock struct {
+	inst *instance.Process
+}
+
+func (c *stopAtRestartClock) Now() time.Time { return time.Now() }
+
+func (c *stopAtRestartClock) After(d time.Duration) <-chan time.Time {
+	c.inst.Stop()
+	expired := make(chan time.Time, 1)
+	expired <- time.Now()
+	return expired
+}
+
+func TestStopDuringRestartDelay(t *testing.T) {
+	record := filepath.Join(t.TempDir(), "spawned")
+	inst := newShellInstance(t, "echo $$ >> "+record+"; exit 1", true, nil)
+	t.Cleanup(func() { inst.Stop() })
+	inst.SetTimeProvider(&stopAtRestartClock{inst: inst})
+
+	// The restart sees both its delay expired and the stop, whichever it picks it must not
+	// bring the instance back
+	const attempts = 20
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for inst.GetGoroutineStats().Active != 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for the restart to give up, goroutines %+v", inst.GetGoroutineStats())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		if status := inst.GetStatus(); status != instance.Stopped {
+			t.Fatalf("Expected the stopped instance to stay stopped on attempt %d, it is %s", attempt, status)
+		}
+		data, _ := os.ReadFile(record)
+		if spawned := len(strings.Fields(string(data))); spawned != attempt {
+			t.Fatalf("Expected %d processes spawned after attempt %d, got %d", attempt, attempt, spawned)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package instance
+
+import (
+	"llamactl/pkg/config"
+	"time"
+)
+
+// Reasons a crash would not be followed by an automatic restart
+const (
+	RestartDisabled  = "auto_restart_disabled" // auto_restart is off, or its parameters are unset
+	RestartExhausted = "max_restarts_exceeded" // The restart budget is used up
+	RestartCrashLoop = "crash_loop"            // The backend died right after starting too many times in a row
+	RestartPaused    = "autorestart_paused"    // Automatic restarts are paused, for the instance or globally
+	RestartUnmanaged = "unmanaged"             // llamactl does not run the backend
+)
+
+// RestartBudget tells what would happen if the backend crashed now. It is computed by the
+// same code that decides on a restart after an actual crash, so the two cannot disagree.
+type RestartBudget struct {
+	Armed       bool            `json:"armed"`            // A crash now is followed by an automatic restart
+	Reason      string          `json:"reason,omitempty"` // Why it would not be, when not armed
+	Restarts    int             `json:"restarts"`         // Restarts counted against max_restarts
+	MaxRestarts int             `json:"max_restarts"`
+	Remaining   int             `json:"remaining"`            // Restarts left before the instance fails
+	Crashes     int             `json:"crashes"`              // Consecutive crashes the restart backoff grows with
+	NextDelay   config.Duration `json:"next_delay,omitempty"` // Delay before the restart, jitter aside
+	// Seconds until the current run has been ready for restart_reset_after and the counter
+	// starts over, while it is ready and has restarts to earn back
+	ResetsIn *int64 `json:"resets_in,omitempty"`
+
+	// Crashes in a row within min_uptime_seconds of starting, counting a crash now. The
+	// instance fails as crash-looping instead of restarting at 3.
+	RapidFailures int `json:"rapid_failures,omitempty"`
+}
+
+// restartCounterExpired reports whether the current run stayed ready long enough to earn
+// back the restart budget (caller must hold the lock)
+func (i *Process) restartCounterExpired(now time.Time) bool {
+	return !i.readyAt.IsZero() && now.Sub(i.readyAt) >= i.restartResetAfter()
+}
+
+// restartBudget computes the restart budget at now, without changing the counters (caller
+// must hold the lock)
+func (i *Process) restartBudget(now time.Time) RestartBudget {
+	budget := RestartBudget{Restarts: i.restarts, Crashes: i.crashes, RapidFailures: i.rapidFailuresAt(now)}
+	if i.restartCounterExpired(now) {
+		budget.Restarts, budget.Crashes = 0, 0
+	} else if !i.readyAt.IsZero() && (i.restarts > 0 || i.crashes > 0) {
+		resetsIn := int64(i.readyAt.Add(i.restartResetAfter()).Sub(now) / time.Second)
+		budget.ResetsIn = &resetsIn
+	}
+
+	switch {
+	case i.options == nil || !i.options.IsManaged():
+		budget.Reason = RestartUnmanaged
+		return budget
+	case i.options.AutoRestart == nil || !*i.options.AutoRestart || i.options.MaxRestarts == nil || i.options.RestartDelay == nil:
+		budget.Reason = RestartDisabled
+		return budget
+	}
+
+	// Values are already validated during unmarshaling/SetOptions
+	budget.MaxRestarts = *i.options.MaxRestarts
+	budget.Remaining = max(budget.MaxRestarts-budget.Restarts, 0)
+	if paused, _ := i.restartPaused(now); paused {
+		budget.Reason = RestartPaused
+		return budget
+	}
+	if budget.RapidFailures >= CrashLoopFailures {
+		budget.Reason = RestartCrashLoop
+		return budget
+	}
+	if budget.Remaining == 0 {
+		budget.Reason = RestartExhausted
+		return budget
+	}
+	budget.Armed = true
+	budget.NextDelay = config.Duration(max(
+		i.backoffAfter(budget.Crashes, i.options.RestartDelay.Duration()),
+		i.rapidFailureDelay(budget.RapidFailures),
+	))
+	return budget
+}
+
+// GetRestartBudget returns what would happen if the backend crashed now
+func (i *Process) GetRestartBudget() RestartBudget {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.restartBudget(i.timeProvider.Now())
+}
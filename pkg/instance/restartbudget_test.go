@@ -0,0 +1,61 @@
+package instance_test
+
+import (
+	"llamactl/pkg/instance"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestartBudget(t *testing.T) {
+	// Each run of the backend crashes once the trigger file appears
+	trigger := filepath.Join(t.TempDir(), "crash")
+	script := "while [ ! -e " + trigger + " ]; do sleep 0.02; done; rm " + trigger + "; exit 1"
+	recorder := newTransitionRecorder()
+	inst := newShellInstance(t, script, true, recorder.record)
+	t.Cleanup(func() { inst.Stop() })
+	crash := func() {
+		t.Helper()
+		if err := os.WriteFile(trigger, nil, 0644); err != nil {
+			t.Fatalf("Failed to write the trigger file: %v", err)
+		}
+	}
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	budget := inst.GetRestartBudget()
+	if !budget.Armed || budget.Restarts != 0 || budget.MaxRestarts != 1 || budget.Remaining != 1 || budget.ResetsIn != nil {
+		t.Fatalf("Expected a full budget with a restart armed, got %+v", budget)
+	}
+
+	// The budget predicts the restart, then that the next crash fails the instance
+	crash()
+	recorder.waitFor(t, instance.Restarting)
+	waitForStatus(t, inst, instance.Starting)
+	budget = inst.GetRestartBudget()
+	if budget.Armed || budget.Reason != instance.RestartExhausted || budget.Restarts != 1 || budget.Remaining != 0 {
+		t.Fatalf("Expected the budget to be used up after a restart, got %+v", budget)
+	}
+
+	crash()
+	recorder.waitFor(t, instance.Failed)
+	if lastErr := inst.GetLastError(); lastErr == nil || lastErr.Code != instance.ReasonMaxRestartsExceeded {
+		t.Errorf("Expected the instance to fail as predicted, got %+v", lastErr)
+	}
+
+	// A manual start earns the budget back
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if budget := inst.GetRestartBudget(); !budget.Armed || budget.Remaining != 1 {
+		t.Errorf("Expected a full budget after a manual start, got %+v", budget)
+	}
+}
+
+func TestRestartBudget_Disabled(t *testing.T) {
+	inst := newShellInstance(t, "exec sleep 30", false, nil)
+	if budget := inst.GetRestartBudget(); budget.Armed || budget.Reason != instance.RestartDisabled {
+		t.Errorf("Expected no restart without auto_restart, got %+v", budget)
+	}
+}
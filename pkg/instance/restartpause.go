@@ -0,0 +1,91 @@
+package instance
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RestartPause suspends automatic restarts without stopping anything: a backend that crashes
+// meanwhile stays down. It is checked ahead of the restart options, which are left untouched.
+type RestartPause struct {
+	Since time.Time  `json:"since"`
+	Until *time.Time `json:"until,omitempty"` // When restarts resume by themselves, paused until resumed when unset
+}
+
+// NewRestartPause returns a pause starting at now, lasting duration or until resumed when
+// duration is 0
+func NewRestartPause(now time.Time, duration time.Duration) *RestartPause {
+	pause := &RestartPause{Since: now.UTC()}
+	if duration > 0 {
+		until := pause.Since.Add(duration)
+		pause.Until = &until
+	}
+	return pause
+}
+
+// Active reports whether the pause holds at now
+func (p *RestartPause) Active(now time.Time) bool {
+	return p != nil && (p.Until == nil || now.Before(*p.Until))
+}
+
+// GlobalRestartPause holds the pause of the automatic restarts of every instance of a
+// manager, shared by its instances
+type GlobalRestartPause struct {
+	pause atomic.Pointer[RestartPause]
+}
+
+// Get returns the global pause, nil when restarts are not paused
+func (g *GlobalRestartPause) Get() *RestartPause {
+	if g == nil {
+		return nil
+	}
+	return g.pause.Load()
+}
+
+// Set replaces the global pause, nil resuming restarts
+func (g *GlobalRestartPause) Set(pause *RestartPause) {
+	g.pause.Store(pause)
+}
+
+// SetGlobalRestartPause sets the pause shared by the instances of the manager, which holds
+// on top of the own pause of the instance
+func (i *Process) SetGlobalRestartPause(global *GlobalRestartPause) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.globalPause = global
+}
+
+// GetRestartPause returns the own pause of the automatic restarts of the instance, nil when
+// it has none
+func (i *Process) GetRestartPause() *RestartPause {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.restartPause
+}
+
+// SetRestartPause replaces the own pause of the automatic restarts of the instance, nil
+// resuming them unless restarts are paused globally
+func (i *Process) SetRestartPause(pause *RestartPause) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.restartPause = pause
+}
+
+// restartPaused reports whether automatic restarts are paused at now, by the instance or
+// globally, and until when: nil when either pause lasts until resumed (caller must hold the
+// lock)
+func (i *Process) restartPaused(now time.Time) (paused bool, until *time.Time) {
+	for _, pause := range []*RestartPause{i.restartPause, i.globalPause.Get()} {
+		if !pause.Active(now) {
+			continue
+		}
+		switch {
+		case pause.Until == nil:
+			return true, nil
+		case !paused || (until != nil && pause.Until.After(*until)):
+			until = pause.Until
+		}
+		paused = true
+	}
+	return paused, until
+}
@@ -0,0 +1,92 @@
+package instance
+
+import (
+	"llamactl/pkg/cron"
+	"time"
+)
+
+// Actions of the start and stop schedules of an instance
+const (
+	ScheduleStart = "start"
+	ScheduleStop  = "stop"
+)
+
+// ScheduledAction is a start or a stop of the instance its schedules have due
+type ScheduledAction struct {
+	Action string    `json:"action"` // start or stop
+	At     time.Time `json:"at"`
+}
+
+// schedules returns the start and stop schedules of the options the instance is next
+// started with, nil for those unset or invalid (caller must hold the lock)
+func (i *Process) schedules() (start, stop *cron.Schedule) {
+	options := i.options
+	if i.pendingOptions != nil {
+		options = i.pendingOptions
+	}
+	if options == nil || !options.IsManaged() {
+		return nil, nil
+	}
+	if options.StartSchedule != "" {
+		start, _ = cron.Parse(options.StartSchedule)
+	}
+	if options.StopSchedule != "" {
+		stop, _ = cron.Parse(options.StopSchedule)
+	}
+	return start, stop
+}
+
+// NextScheduledAction returns the next start or stop the schedules of the instance have due,
+// in the local time zone, or nil without schedules
+func (i *Process) NextScheduledAction() *ScheduledAction {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.nextScheduledAction(i.timeProvider.Now())
+}
+
+// nextScheduledAction returns the first scheduled action after now (caller must hold the lock)
+func (i *Process) nextScheduledAction(now time.Time) *ScheduledAction {
+	start, stop := i.schedules()
+	now = now.In(time.Local)
+
+	var next *ScheduledAction
+	for _, s := range []struct {
+		action   string
+		schedule *cron.Schedule
+	}{{ScheduleStop, stop}, {ScheduleStart, start}} {
+		if s.schedule == nil {
+			continue
+		}
+		if at := s.schedule.Next(now); !at.IsZero() && (next == nil || at.Before(next.At)) {
+			next = &ScheduledAction{Action: s.action, At: at}
+		}
+	}
+	return next
+}
+
+// DueScheduledAction returns the last start or stop the schedules of the instance had due
+// after from and up to to, or nil if none was. The instance is left as the latest of them
+// would leave it, a stop winning when both are due at once.
+func (i *Process) DueScheduledAction(from, to time.Time) *ScheduledAction {
+	i.mu.RLock()
+	start, stop := i.schedules()
+	i.mu.RUnlock()
+
+	var due *ScheduledAction
+	for _, s := range []struct {
+		action   string
+		schedule *cron.Schedule
+	}{{ScheduleStart, start}, {ScheduleStop, stop}} {
+		if s.schedule == nil {
+			continue
+		}
+		var last time.Time
+		for at := s.schedule.Next(from.In(time.Local)); !at.IsZero() && !at.After(to); at = s.schedule.Next(at) {
+			last = at
+		}
+		if !last.IsZero() && (due == nil || !last.Before(due.At)) {
+			due = &ScheduledAction{Action: s.action, At: last}
+		}
+	}
+	return due
+}
@@ -0,0 +1,75 @@
+package instance_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"testing"
+	"time"
+)
+
+func newScheduledInstance(t *testing.T, start, stop string) *instance.Process {
+	t.Helper()
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		StartSchedule:      start,
+		StopSchedule:       stop,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+	return instance.NewInstance("scheduled-instance", &config.BackendConfig{}, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+}
+
+func TestNextScheduledAction(t *testing.T) {
+	inst := newScheduledInstance(t, "0 9 * * mon-fri", "0 18 * * mon-fri")
+	// A Wednesday
+	clock := NewMockTimeProvider(time.Date(2024, 6, 19, 12, 0, 0, 0, time.Local))
+	inst.SetTimeProvider(clock)
+
+	next := inst.NextScheduledAction()
+	if next == nil || next.Action != instance.ScheduleStop || !next.At.Equal(time.Date(2024, 6, 19, 18, 0, 0, 0, time.Local)) {
+		t.Errorf("Expected the stop at 18:00, got %+v", next)
+	}
+
+	// Friday evening: next start is on Monday
+	clock.SetTime(time.Date(2024, 6, 21, 19, 0, 0, 0, time.Local))
+	next = inst.NextScheduledAction()
+	if next == nil || next.Action != instance.ScheduleStart || !next.At.Equal(time.Date(2024, 6, 24, 9, 0, 0, 0, time.Local)) {
+		t.Errorf("Expected the start on Monday at 9:00, got %+v", next)
+	}
+
+	if next := newScheduledInstance(t, "", "").NextScheduledAction(); next != nil {
+		t.Errorf("Expected no scheduled action without schedules, got %+v", next)
+	}
+}
+
+func TestDueScheduledAction(t *testing.T) {
+	inst := newScheduledInstance(t, "0 9 * * *", "0 18 * * *")
+	at := func(hour, minute, second int) time.Time {
+		return time.Date(2024, 6, 19, hour, minute, second, 0, time.Local)
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		expected string
+	}{
+		{"none due", at(10, 0, 0), at(10, 0, 15), ""},
+		{"start due", at(8, 59, 50), at(9, 0, 5), instance.ScheduleStart},
+		{"due at the end", at(17, 59, 45), at(18, 0, 0), instance.ScheduleStop},
+		// Due at the start of the window, acted on by the previous check
+		{"due at the start", at(18, 0, 0), at(18, 0, 15), ""},
+		{"latest wins", at(8, 0, 0), at(19, 0, 0), instance.ScheduleStop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due := inst.DueScheduledAction(tt.from, tt.to)
+			switch {
+			case tt.expected == "" && due != nil:
+				t.Errorf("Expected no scheduled action, got %+v", due)
+			case tt.expected != "" && (due == nil || due.Action != tt.expected):
+				t.Errorf("Expected a scheduled %s, got %+v", tt.expected, due)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// ErrNotRunning is returned when signalling an instance whose backend is not running
+var ErrNotRunning = errors.New("instance is not running")
+
+// ParseSignal returns the signal of a name such as "USR1" or "SIGUSR1", in any case. Only
+// the signals of signalsByName can be sent.
+func ParseSignal(name string) (syscall.Signal, error) {
+	name = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+	if sig, ok := signalsByName[name]; ok {
+		return sig, nil
+	}
+	names := make([]string, 0, len(signalsByName))
+	for name := range signalsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("unsupported signal %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// SignalName returns the name of a signal without its SIG prefix, e.g. "USR1"
+func SignalName(sig syscall.Signal) string {
+	for name, s := range signalsByName {
+		if s == sig {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", int(sig))
+}
+
+// Signal sends a signal to the backend of a running instance, to the whole process group on
+// Unix. The instance is otherwise left alone: a signal the backend exits on is handled as
+// any other exit, restarting it per its policy.
+func (i *Process) Signal(sig syscall.Signal) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !i.options.IsManaged() {
+		return fmt.Errorf("cannot signal instance %s: %w", i.Name, ErrUnmanaged)
+	}
+	if !i.IsRunning() || i.cmd == nil || i.cmd.Process == nil || i.monitorDone == nil {
+		return fmt.Errorf("cannot signal instance %s, it is %s: %w", i.Name, i.GetStatus(), ErrNotRunning)
+	}
+
+	log.Printf("Sending SIG%s to instance %s", SignalName(sig), i.Name)
+	if err := signalProcessGroup(i.cmd, sig); err != nil {
+		return fmt.Errorf("failed to send SIG%s to instance %s: %w", SignalName(sig), i.Name, err)
+	}
+	i.sentSignal = sig
+	return nil
+}
@@ -0,0 +1,55 @@
+package instance_test
+
+import (
+	"errors"
+	"llamactl/pkg/instance"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"KILL", "sigkill", " SIGKILL "} {
+		if sig, err := instance.ParseSignal(name); err != nil || sig != syscall.SIGKILL {
+			t.Errorf("ParseSignal(%q) = %v, %v, expected SIGKILL", name, sig, err)
+		}
+	}
+	if _, err := instance.ParseSignal("STOP"); err == nil || !strings.Contains(err.Error(), "KILL") {
+		t.Errorf("Expected STOP to be rejected with the supported signals, got %v", err)
+	}
+	if name := instance.SignalName(syscall.SIGKILL); name != "KILL" {
+		t.Errorf("Expected KILL, got %s", name)
+	}
+}
+
+func TestSignal(t *testing.T) {
+	t.Run("refused unless running", func(t *testing.T) {
+		inst := newShellInstance(t, "exec sleep 30", false, nil)
+		if err := inst.Signal(syscall.SIGKILL); !errors.Is(err, instance.ErrNotRunning) {
+			t.Errorf("Expected ErrNotRunning, got %v", err)
+		}
+	})
+
+	t.Run("a killed backend is restarted", func(t *testing.T) {
+		recorder := newTransitionRecorder()
+		inst := newShellInstance(t, "exec sleep 30", true, recorder.record)
+		t.Cleanup(func() { inst.Stop() })
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+
+		if err := inst.Signal(syscall.SIGKILL); err != nil {
+			t.Fatalf("Signal failed: %v", err)
+		}
+		recorder.waitFor(t, instance.Restarting)
+		for _, tr := range recorder.snapshot() {
+			if tr.newStatus == instance.Restarting {
+				if tr.reason.Code != instance.ReasonCrash || !strings.Contains(tr.reason.Message, "sent through the API") {
+					t.Errorf("Expected a crash killed through the API, got %+v", tr.reason)
+				}
+				break
+			}
+		}
+		waitForStatus(t, inst, instance.Starting)
+	})
+}
@@ -0,0 +1,52 @@
+//go:build !windows
+
+package instance_test
+
+import (
+	"llamactl/pkg/instance"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSignal_Unix(t *testing.T) {
+	for _, name := range []string{"USR1", "sigusr1", " SIGUSR1 "} {
+		if sig, err := instance.ParseSignal(name); err != nil || sig != syscall.SIGUSR1 {
+			t.Errorf("ParseSignal(%q) = %v, %v, expected SIGUSR1", name, sig, err)
+		}
+	}
+	if _, err := instance.ParseSignal("STOP"); err == nil || !strings.Contains(err.Error(), "HUP, INT") {
+		t.Errorf("Expected STOP to be rejected with the supported signals, got %v", err)
+	}
+	if name := instance.SignalName(syscall.SIGHUP); name != "HUP" {
+		t.Errorf("Expected HUP, got %s", name)
+	}
+}
+
+func TestSignal_DeliveredToProcessGroup(t *testing.T) {
+	// The signal reaches the child of the shell, which keeps running
+	record := filepath.Join(t.TempDir(), "signals")
+	inst := newShellInstance(t, `sh -c 'trap "echo usr1 >> `+record+`" USR1; while true; do sleep 0.05; done'`, false, nil)
+	t.Cleanup(func() { inst.Stop() })
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond) // Let the trap be set
+
+	if err := inst.Signal(syscall.SIGUSR1); err != nil {
+		t.Fatalf("Signal failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for data, _ := os.ReadFile(record); string(data) != "usr1\n"; data, _ = os.ReadFile(record) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the child to receive SIGUSR1, got %q", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !inst.IsRunning() {
+		t.Error("Expected the instance to keep running")
+	}
+}
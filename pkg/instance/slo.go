@@ -0,0 +1,325 @@
+package instance
+
+import (
+	"fmt"
+	"llamactl/pkg/config"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SLO states of an instance
+const (
+	SLOStateNoData   = "no_data"  // Fewer than min_requests requests in the window
+	SLOStateOK       = "ok"       // Every objective is met
+	SLOStateBreached = "breached" // An objective is missed, the error budget burns faster than it accrues
+	SLOStateBurning  = "burning"  // The error budget burns burn_rate_threshold times too fast, the instance is degraded
+)
+
+// ProbeHeader marks synthetic requests, such as probes or warm-up requests, that are
+// proxied like any other but left out of SLO evaluation
+const ProbeHeader = "X-Llamactl-Probe"
+
+// MaxSLOWindow is the longest window the objectives of an instance are evaluated over
+const MaxSLOWindow = time.Hour
+
+const (
+	defaultSLOWindow            = 5 * time.Minute
+	defaultSLOBurnRateThreshold = 2.0
+	defaultSLOMinRequests       = 10
+	// sloLatencyBudget is the fraction of requests allowed above the p95 latency objective
+	sloLatencyBudget = 0.05
+	sloBucketCount   = int(MaxSLOWindow / statsBucketDuration)
+)
+
+// SLOOptions are the service level objectives of an instance, evaluated over a sliding window
+type SLOOptions struct {
+	LatencyP95Ms      int             `json:"latency_p95_ms,omitempty"`      // p95 time until the backend responds, 0 = no objective
+	ErrorRate         float64         `json:"error_rate,omitempty"`          // Fraction of failed requests, 0 = no objective
+	Window            config.Duration `json:"window,omitempty"`              // Evaluation window, a bare integer is in seconds (default: 5m, max: 1h)
+	BurnRateThreshold float64         `json:"burn_rate_threshold,omitempty"` // Burn rate the instance is degraded at (default: 2)
+	MinRequests       int             `json:"min_requests,omitempty"`        // Requests in the window before the SLO is evaluated (default: 10)
+	Warmup            config.Duration `json:"warmup,omitempty"`              // Time after a start during which requests are ignored, a bare integer is in seconds
+}
+
+// HasObjectives reports whether a latency or error rate objective is set
+func (o *SLOOptions) HasObjectives() bool {
+	return o != nil && (o.LatencyP95Ms > 0 || o.ErrorRate > 0)
+}
+
+// window returns the evaluation window, falling back to the default
+func (o *SLOOptions) window() time.Duration {
+	if o.Window <= 0 {
+		return defaultSLOWindow
+	}
+	return min(o.Window.Duration(), MaxSLOWindow)
+}
+
+// SLOStatus is the compliance of an instance with its objectives over the evaluation window.
+// A burn rate of 1 consumes the error budget exactly as fast as it accrues.
+type SLOStatus struct {
+	State           string          `json:"state"`
+	Degraded        bool            `json:"degraded"`
+	Since           *time.Time      `json:"since,omitempty"` // When the instance entered the state
+	EvaluatedAt     time.Time       `json:"evaluated_at"`
+	Objectives      SLOOptions      `json:"objectives"`
+	Window          config.Duration `json:"window"`
+	Requests        int64           `json:"requests"`
+	Errors          int64           `json:"errors"`
+	SlowRequests    int64           `json:"slow_requests"` // Requests above the p95 latency objective
+	ErrorRate       float64         `json:"error_rate"`
+	LatencyP95Ms    float64         `json:"latency_p95_ms"` // Upper bound of the latency histogram bucket holding the p95
+	RequestBytes    int64           `json:"request_bytes"`
+	ResponseBytes   int64           `json:"response_bytes"`
+	ErrorBurnRate   float64         `json:"error_burn_rate"`
+	LatencyBurnRate float64         `json:"latency_burn_rate"`
+	BurnRate        float64         `json:"burn_rate"` // The higher of the two
+	Compliant       bool            `json:"compliant"`
+}
+
+type sloBucket struct {
+	epoch         atomic.Int64
+	requests      atomic.Int64
+	errors        atomic.Int64
+	slow          atomic.Int64
+	requestBytes  atomic.Int64
+	responseBytes atomic.Int64
+	latency       [len(LatencyBucketBounds) + 1]atomic.Int64
+}
+
+// sloTracker counts the requests of the last hour in buckets, recorded with atomic
+// operations only like the rest of the proxy stats. Evaluation state is guarded by mu,
+// which the proxy path never takes.
+type sloTracker struct {
+	latencyThreshold atomic.Int64 // nanoseconds, 0 = no latency objective
+	warmup           atomic.Int64 // nanoseconds
+	warmupUntil      atomic.Int64 // unix nanoseconds
+	buckets          [sloBucketCount]sloBucket
+
+	mu    sync.Mutex
+	state string
+	since time.Time
+}
+
+// ConfigureSLO starts tracking the requests of the instance against its objectives, or
+// stops if it has none. Data already recorded is kept when the objectives change.
+func (s *ProxyStats) ConfigureSLO(options *SLOOptions) {
+	if !options.HasObjectives() {
+		s.slo.Store(nil)
+		return
+	}
+	tracker := s.slo.Load()
+	if tracker == nil {
+		tracker = &sloTracker{state: SLOStateNoData}
+	}
+	tracker.latencyThreshold.Store(int64(time.Duration(options.LatencyP95Ms) * time.Millisecond))
+	tracker.warmup.Store(int64(max(options.Warmup.Duration(), 0)))
+	s.slo.Store(tracker)
+}
+
+// startWarmup ignores the requests completed within the warm-up period from now on
+func (s *ProxyStats) startWarmup(now time.Time) {
+	if tracker := s.slo.Load(); tracker != nil {
+		tracker.warmupUntil.Store(now.Add(time.Duration(tracker.warmup.Load())).UnixNano())
+	}
+}
+
+// recordSLO counts a request towards the SLO, unless it is a probe or completed during
+// warm-up. It reports whether the request was counted.
+func (s *ProxyStats) recordSLO(req *http.Request, now time.Time, latency time.Duration, failed bool) bool {
+	tracker := s.slo.Load()
+	if tracker == nil || req.Header.Get(ProbeHeader) != "" || now.UnixNano() < tracker.warmupUntil.Load() {
+		return false
+	}
+
+	b := tracker.bucket(now)
+	b.requests.Add(1)
+	if failed {
+		b.errors.Add(1)
+	}
+	if threshold := tracker.latencyThreshold.Load(); threshold > 0 && int64(latency) > threshold {
+		b.slow.Add(1)
+	}
+	if req.ContentLength > 0 {
+		b.requestBytes.Add(req.ContentLength)
+	}
+	idx := len(LatencyBucketBounds)
+	for i, bound := range LatencyBucketBounds {
+		if latency <= bound {
+			idx = i
+			break
+		}
+	}
+	b.latency[idx].Add(1)
+	return true
+}
+
+// recordSLOResponseBytes adds the size of the response body of a counted request once it
+// has been read
+func (s *ProxyStats) recordSLOResponseBytes(now time.Time, n int64) {
+	if tracker := s.slo.Load(); tracker != nil && n > 0 {
+		tracker.bucket(now).responseBytes.Add(n)
+	}
+}
+
+// bucket returns the bucket for the given time, resetting it if it still holds an older window
+func (t *sloTracker) bucket(now time.Time) *sloBucket {
+	epoch := bucketEpoch(now)
+	b := &t.buckets[epoch%int64(sloBucketCount)]
+	if old := b.epoch.Load(); old != epoch && b.epoch.CompareAndSwap(old, epoch) {
+		b.requests.Store(0)
+		b.errors.Store(0)
+		b.slow.Store(0)
+		b.requestBytes.Store(0)
+		b.responseBytes.Store(0)
+		for idx := range b.latency {
+			b.latency[idx].Store(0)
+		}
+	}
+	return b
+}
+
+// EvaluateSLO computes the compliance of the instance with its objectives over the window
+// ending now. It returns nil if the instance has no objectives, and whether the state
+// changed since the previous evaluation.
+func (i *Process) EvaluateSLO() (*SLOStatus, bool) {
+	i.mu.RLock()
+	var objectives SLOOptions
+	if i.options != nil && i.options.SLO != nil {
+		objectives = *i.options.SLO
+	}
+	i.mu.RUnlock()
+
+	tracker := i.stats.slo.Load()
+	if tracker == nil || !objectives.HasObjectives() {
+		return nil, false
+	}
+	return tracker.evaluate(&objectives, i.timeProvider.Now())
+}
+
+func (t *sloTracker) evaluate(objectives *SLOOptions, now time.Time) (*SLOStatus, bool) {
+	window := objectives.window()
+	status := &SLOStatus{
+		EvaluatedAt: now,
+		Objectives:  *objectives,
+		Window:      config.Duration(window),
+	}
+
+	var latency [len(LatencyBucketBounds) + 1]int64
+	current := bucketEpoch(now)
+	oldest := current - int64(window/statsBucketDuration)
+	for idx := range t.buckets {
+		b := &t.buckets[idx]
+		if epoch := b.epoch.Load(); epoch > oldest && epoch <= current {
+			status.Requests += b.requests.Load()
+			status.Errors += b.errors.Load()
+			status.SlowRequests += b.slow.Load()
+			status.RequestBytes += b.requestBytes.Load()
+			status.ResponseBytes += b.responseBytes.Load()
+			for bound := range latency {
+				latency[bound] += b.latency[bound].Load()
+			}
+		}
+	}
+
+	if status.Requests > 0 {
+		status.ErrorRate = float64(status.Errors) / float64(status.Requests)
+		status.LatencyP95Ms = latencyPercentileMs(latency[:], 1-sloLatencyBudget)
+		if objectives.ErrorRate > 0 {
+			status.ErrorBurnRate = status.ErrorRate / objectives.ErrorRate
+		}
+		if objectives.LatencyP95Ms > 0 {
+			status.LatencyBurnRate = float64(status.SlowRequests) / float64(status.Requests) / sloLatencyBudget
+		}
+		status.BurnRate = math.Max(status.ErrorBurnRate, status.LatencyBurnRate)
+	}
+
+	minRequests := int64(objectives.MinRequests)
+	if minRequests <= 0 {
+		minRequests = defaultSLOMinRequests
+	}
+	threshold := objectives.BurnRateThreshold
+	if threshold <= 0 {
+		threshold = defaultSLOBurnRateThreshold
+	}
+	switch {
+	case status.Requests < minRequests:
+		status.State = SLOStateNoData
+	case status.BurnRate >= threshold:
+		status.State = SLOStateBurning
+	case status.BurnRate > 1:
+		status.State = SLOStateBreached
+	default:
+		status.State = SLOStateOK
+	}
+	status.Compliant = status.State != SLOStateBreached && status.State != SLOStateBurning
+	status.Degraded = status.State == SLOStateBurning
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changed := status.State != t.state
+	if changed || t.since.IsZero() {
+		t.state, t.since = status.State, now
+	}
+	since := t.since
+	status.Since = &since
+	return status, changed
+}
+
+// degraded reports whether the last evaluation found the error budget burning too fast
+func (t *sloTracker) degraded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state == SLOStateBurning
+}
+
+// latencyPercentileMs returns the upper bound of the histogram bucket holding the given
+// percentile, or the last finite bound if it falls in the +Inf bucket
+func latencyPercentileMs(histogram []int64, percentile float64) float64 {
+	var total int64
+	for _, count := range histogram {
+		total += count
+	}
+	rank := int64(math.Ceil(percentile * float64(total)))
+	var cumulative int64
+	for idx, count := range histogram {
+		cumulative += count
+		if cumulative >= rank && idx < len(LatencyBucketBounds) {
+			return float64(LatencyBucketBounds[idx]) / float64(time.Millisecond)
+		}
+	}
+	return float64(LatencyBucketBounds[len(LatencyBucketBounds)-1]) / float64(time.Millisecond)
+}
+
+// SetSLO replaces the objectives of the instance without restarting it. Requests already
+// recorded are evaluated against the new objectives, slow requests against the latency
+// objective in effect when they completed.
+func (i *Process) SetSLO(objectives *SLOOptions) error {
+	if objectives != nil && !objectives.HasObjectives() {
+		objectives = nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.options == nil {
+		return fmt.Errorf("instance %s has no options set", i.Name)
+	}
+	options := *i.options
+	options.SLO = objectives
+	i.options = &options
+	if i.optionSources == nil {
+		i.optionSources = make(OptionSources)
+	}
+	if objectives != nil {
+		i.optionSources["slo"] = SourceExplicit
+	} else {
+		delete(i.optionSources, "slo")
+	}
+	i.patchPendingOptions("slo", objectives != nil, func(options *CreateInstanceOptions) {
+		options.SLO = objectives
+	})
+	i.stats.ConfigureSLO(objectives)
+	return nil
+}
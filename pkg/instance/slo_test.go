@@ -0,0 +1,174 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// newSLOTestInstance returns an instance with the given objectives proxying to a backend
+// that fails requests to /fail and answers requests to /slow after 300ms
+func newSLOTestInstance(t *testing.T, objectives *instance.SLOOptions) *instance.Process {
+	t.Helper()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			http.Error(w, "backend error", http.StatusInternalServerError)
+		case "/slow":
+			time.Sleep(300 * time.Millisecond)
+			w.Write([]byte("ok"))
+		default:
+			w.Write([]byte("ok"))
+		}
+	}
+	options := &instance.CreateInstanceOptions{
+		AutoRestart: testutil.BoolPtr(false),
+		SLO:         objectives,
+	}
+	return newBackendInstance(t, handler, options, &config.InstancesConfig{}, nil)
+}
+
+// send proxies n requests to path, marked as probes if probe is set
+func send(t *testing.T, inst *instance.Process, path string, n int, probe bool) {
+	t.Helper()
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+	for range n {
+		req := httptest.NewRequest("GET", path, nil)
+		if probe {
+			req.Header.Set(instance.ProbeHeader, "1")
+		}
+		proxy.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestSLO_ErrorBudgetBurning(t *testing.T) {
+	inst := newSLOTestInstance(t, &instance.SLOOptions{ErrorRate: 0.1, MinRequests: 10})
+	mockTime := NewMockTimeProvider(time.Now())
+	inst.SetTimeProvider(mockTime)
+
+	send(t, inst, "/v1/models", 5, false)
+	status, changed := inst.EvaluateSLO()
+	if status == nil || status.State != instance.SLOStateNoData || status.Requests != 5 {
+		t.Fatalf("Expected no data below min_requests, got %+v", status)
+	}
+	if changed {
+		t.Error("Expected no state change while there is no data")
+	}
+
+	// Probe requests are left out, however many fail
+	send(t, inst, "/fail", 20, true)
+	send(t, inst, "/v1/models", 10, false)
+	send(t, inst, "/fail", 5, false)
+	status, changed = inst.EvaluateSLO()
+	if status.Requests != 20 || status.Errors != 5 {
+		t.Fatalf("Expected 20 requests with 5 errors, got %d with %d", status.Requests, status.Errors)
+	}
+	if status.State != instance.SLOStateBurning || !status.Degraded || status.Compliant || !changed {
+		t.Errorf("Expected a burn rate of 2.5 to degrade the instance, got %+v (changed %v)", status, changed)
+	}
+	if status.ErrorBurnRate != 2.5 || status.BurnRate != 2.5 {
+		t.Errorf("Expected a burn rate of 2.5, got %v", status.BurnRate)
+	}
+	if status.ResponseBytes == 0 {
+		t.Error("Expected the response bytes to be counted")
+	}
+	if _, changed = inst.EvaluateSLO(); changed {
+		t.Error("Expected no state change without new requests")
+	}
+
+	data, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var marshaled struct {
+		Degraded bool `json:"degraded"`
+	}
+	json.Unmarshal(data, &marshaled)
+	if !marshaled.Degraded {
+		t.Errorf("Expected the instance to be marked degraded, got %s", data)
+	}
+
+	// The requests age out of the window
+	mockTime.SetTime(mockTime.Now().Add(6 * time.Minute))
+	if status, _ = inst.EvaluateSLO(); status.State != instance.SLOStateNoData || status.Requests != 0 {
+		t.Errorf("Expected the window to be empty, got %+v", status)
+	}
+}
+
+func TestSLO_Latency(t *testing.T) {
+	inst := newSLOTestInstance(t, &instance.SLOOptions{LatencyP95Ms: 200})
+
+	send(t, inst, "/v1/models", 18, false)
+	send(t, inst, "/slow", 2, false)
+	status, _ := inst.EvaluateSLO()
+	if status.SlowRequests != 2 {
+		t.Fatalf("Expected 2 slow requests, got %d", status.SlowRequests)
+	}
+	// 10% of the requests are slow with a budget of 5%
+	if status.State != instance.SLOStateBurning || status.LatencyBurnRate != 2 {
+		t.Errorf("Expected a latency burn rate of 2, got %+v", status)
+	}
+	if status.LatencyP95Ms < 250 {
+		t.Errorf("Expected the p95 latency to include the slow requests, got %vms", status.LatencyP95Ms)
+	}
+}
+
+func TestSLO_WarmupExcluded(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	inst := newSLOTestInstance(t, &instance.SLOOptions{ErrorRate: 0.1, Warmup: config.Duration(time.Minute)})
+	mockTime := NewMockTimeProvider(time.Now())
+	inst.SetTimeProvider(mockTime)
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	send(t, inst, "/fail", 10, false)
+	if status, _ := inst.EvaluateSLO(); status.Requests != 0 {
+		t.Errorf("Expected requests during warm-up to be left out, got %d", status.Requests)
+	}
+
+	mockTime.SetTime(mockTime.Now().Add(time.Minute))
+	send(t, inst, "/fail", 10, false)
+	if status, _ := inst.EvaluateSLO(); status.Requests != 10 {
+		t.Errorf("Expected requests after warm-up to be counted, got %d", status.Requests)
+	}
+}
+
+func TestSLO_UpdateAtRuntime(t *testing.T) {
+	inst := newSLOTestInstance(t, &instance.SLOOptions{ErrorRate: 0.5})
+
+	send(t, inst, "/v1/models", 15, false)
+	send(t, inst, "/fail", 5, false)
+	if status, _ := inst.EvaluateSLO(); status.State != instance.SLOStateOK {
+		t.Fatalf("Expected an error rate of 25%% to meet the objective, got %+v", status)
+	}
+
+	if err := inst.SetSLO(&instance.SLOOptions{ErrorRate: 0.1, BurnRateThreshold: 3}); err != nil {
+		t.Fatalf("SetSLO failed: %v", err)
+	}
+	status, changed := inst.EvaluateSLO()
+	if status.State != instance.SLOStateBreached || !changed || status.Degraded {
+		t.Errorf("Expected the recorded requests to breach the new objective, got %+v", status)
+	}
+	if opts := inst.GetOptions(); opts.SLO == nil || opts.SLO.ErrorRate != 0.1 {
+		t.Errorf("Expected the options to hold the new objectives, got %+v", opts.SLO)
+	}
+
+	if err := inst.SetSLO(nil); err != nil {
+		t.Fatalf("SetSLO failed: %v", err)
+	}
+	if status, _ := inst.EvaluateSLO(); status != nil {
+		t.Errorf("Expected no SLO status once the objectives are removed, got %+v", status)
+	}
+}
@@ -0,0 +1,266 @@
+package instance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"llamactl/pkg/backends"
+)
+
+// slotSnapshotTimeout bounds saving or restoring the slots of an instance, which writes or
+// reads the prompt cache of every slot
+const slotSnapshotTimeout = 2 * time.Minute
+
+// slotFilePrefix names the slot files written by llamactl in the slot directory
+const slotFilePrefix = "llamactl-slot-"
+
+// SlotRestore is the outcome of saving the slots of an instance before its last restart and
+// restoring them once the new process was ready
+type SlotRestore struct {
+	Time     time.Time `json:"time"`
+	Saved    int       `json:"saved"`
+	Restored int       `json:"restored"`
+	Error    string    `json:"error,omitempty"` // Why the slots were not all saved or restored
+}
+
+// slotSnapshot lists the slot files saved before a restart, by slot id
+type slotSnapshot struct {
+	dir   string
+	files map[int]string
+}
+
+// PreservesSlots reports whether the slots of the instance are saved before a restart and
+// restored afterwards
+func (i *Process) PreservesSlots() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.preservesSlots()
+}
+
+// preservesSlots is PreservesSlots for callers holding the lock
+func (i *Process) preservesSlots() bool {
+	opts := i.options
+	return opts != nil && opts.IsManaged() && opts.PreserveSlots != nil && *opts.PreserveSlots &&
+		opts.BackendType == backends.BackendTypeLlamaCpp && opts.LlamaServerOptions != nil
+}
+
+// slotDir returns the directory llama-server saves slots to: slot_save_path if set, the
+// directory of the instance under slots_dir otherwise, or "" if neither is set (caller
+// must hold the lock)
+func (i *Process) slotDir() string {
+	if i.options.LlamaServerOptions != nil && i.options.LlamaServerOptions.SlotSavePath != "" {
+		return i.options.LlamaServerOptions.SlotSavePath
+	}
+	if i.globalInstanceSettings == nil || i.globalInstanceSettings.SlotsDir == "" {
+		return ""
+	}
+	return filepath.Join(i.globalInstanceSettings.SlotsDir, i.Name)
+}
+
+// withSlotSavePath returns a copy of the options with the slot save path of llama-server set to dir
+func (c *CreateInstanceOptions) withSlotSavePath(dir string) *CreateInstanceOptions {
+	opts := *c
+	if c.LlamaServerOptions != nil {
+		backendOpts := *c.LlamaServerOptions
+		backendOpts.SlotSavePath = dir
+		opts.LlamaServerOptions = &backendOpts
+	}
+	return &opts
+}
+
+// SaveSlots asks the backend to save every slot into the slot directory, to be restored
+// once the instance has been restarted and is ready. A failure is recorded as the outcome
+// of the last slot restore.
+func (i *Process) SaveSlots() (int, error) {
+	i.mu.RLock()
+	running, dir := i.IsRunning(), i.slotDir()
+	i.mu.RUnlock()
+
+	snapshot := &slotSnapshot{dir: dir, files: make(map[int]string)}
+	err := func() error {
+		if !running {
+			return fmt.Errorf("instance %s is not running", i.Name)
+		}
+		if dir == "" {
+			return fmt.Errorf("no slot directory, set slots_dir or slot_save_path")
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create slot directory: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), slotSnapshotTimeout)
+		defer cancel()
+
+		var slots []struct {
+			ID int `json:"id"`
+		}
+		if err := i.backendRequest(ctx, "GET", "/slots", nil, &slots); err != nil {
+			return fmt.Errorf("failed to list slots: %w", err)
+		}
+		for _, slot := range slots {
+			filename := slotFilePrefix + strconv.Itoa(slot.ID) + ".bin"
+			body := map[string]string{"filename": filename}
+			if err := i.backendRequest(ctx, "POST", fmt.Sprintf("/slots/%d?action=save", slot.ID), body, nil); err != nil {
+				return fmt.Errorf("failed to save slot %d: %w", slot.ID, err)
+			}
+			snapshot.files[slot.ID] = filename
+		}
+		return nil
+	}()
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if err != nil {
+		i.pendingSlots = nil
+		i.LastSlotRestore = &SlotRestore{Time: i.timeProvider.Now(), Saved: len(snapshot.files), Error: err.Error()}
+		return len(snapshot.files), err
+	}
+	i.pendingSlots = snapshot
+	return len(snapshot.files), nil
+}
+
+// restoreSlots waits for the backend started with the pending snapshot to become ready,
+// then restores its slots and removes the snapshot. It gives up when the process exits,
+// signalled by exited.
+func (i *Process) restoreSlots(snapshot *slotSnapshot, exited <-chan struct{}) {
+	result := &SlotRestore{Saved: len(snapshot.files)}
+	err := i.WaitForHealthy(0)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), slotSnapshotTimeout)
+		defer cancel()
+		go func() {
+			select {
+			case <-exited:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		for id, filename := range snapshot.files {
+			body := map[string]string{"filename": filename}
+			if err = i.backendRequest(ctx, "POST", fmt.Sprintf("/slots/%d?action=restore", id), body, nil); err != nil {
+				err = fmt.Errorf("failed to restore slot %d: %w", id, err)
+				break
+			}
+			result.Restored++
+		}
+	}
+	if err == nil {
+		for _, filename := range snapshot.files {
+			os.Remove(filepath.Join(snapshot.dir, filename))
+		}
+	} else {
+		result.Error = err.Error()
+		log.Printf("Warning: failed to restore the slots of instance %s: %v", i.Name, err)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	result.Time = i.timeProvider.Now()
+	i.LastSlotRestore = result
+}
+
+// GetLastSlotRestore returns the outcome of the last slot restore, nil if the slots of the
+// instance were never saved
+func (i *Process) GetLastSlotRestore() *SlotRestore {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.LastSlotRestore
+}
+
+// backendRequest sends a request to the API of the backend and decodes its JSON response
+// into out, if not nil
+func (i *Process) backendRequest(ctx context.Context, method, path string, body, out any) error {
+	opts := i.GetOptions()
+	target := fmt.Sprintf("%s://%s%s", opts.BackendTLS.BackendScheme(), net.JoinHostPort(i.GetHost(), strconv.Itoa(i.GetPort())), path)
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := i.backendAuthKey(); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := (&http.Client{Transport: i.BackendTransport()}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("backend returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// EnforceSlotRetention removes the slot files written by llamactl that are older than the
+// slot retention of the instance, except those of a snapshot waiting to be restored. It
+// returns the files removed.
+func (i *Process) EnforceSlotRetention() ([]string, error) {
+	i.mu.RLock()
+	opts, dir, pending := i.options, i.slotDir(), i.pendingSlots
+	i.mu.RUnlock()
+
+	if opts == nil || opts.SlotRetentionHours == nil || *opts.SlotRetentionHours == 0 || dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := i.timeProvider.Now().Add(-time.Duration(*opts.SlotRetentionHours) * time.Hour)
+	var removed []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, slotFilePrefix) || (pending != nil && pending.dir == dir && pending.has(name)) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
+
+func (s *slotSnapshot) has(filename string) bool {
+	for _, name := range s.files {
+		if name == filename {
+			return true
+		}
+	}
+	return false
+}
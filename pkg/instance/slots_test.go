@@ -0,0 +1,173 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSlotServer serves the slot API of llama-server, writing and reading slot files in dir
+type fakeSlotServer struct {
+	dir         string
+	failRestore bool
+
+	mu       sync.Mutex
+	restored []string
+}
+
+func (s *fakeSlotServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/health":
+		w.WriteHeader(http.StatusOK)
+	case r.URL.Path == "/slots":
+		json.NewEncoder(w).Encode([]map[string]any{{"id": 0}, {"id": 1}})
+	case r.Method == "POST":
+		var body struct {
+			Filename string `json:"filename"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		path := filepath.Join(s.dir, body.Filename)
+		switch r.URL.Query().Get("action") {
+		case "save":
+			os.WriteFile(path, []byte(r.URL.Path), 0644)
+		case "restore":
+			if _, err := os.Stat(path); err != nil || s.failRestore {
+				http.Error(w, `{"error":"failed to restore slot"}`, http.StatusBadRequest)
+				return
+			}
+			s.mu.Lock()
+			s.restored = append(s.restored, body.Filename)
+			s.mu.Unlock()
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id_slot": 0})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newSlotTestInstance(t *testing.T, backend *fakeSlotServer) *instance.Process {
+	t.Helper()
+	slotsDir := t.TempDir()
+	backend.dir = filepath.Join(slotsDir, "test-instance")
+	globalSettings := &config.InstancesConfig{SlotsDir: slotsDir, SlotRetentionHours: 24}
+	options := &instance.CreateInstanceOptions{
+		AutoRestart:   testutil.BoolPtr(false),
+		PreserveSlots: testutil.BoolPtr(true),
+	}
+	return newBackendInstance(t, backend.ServeHTTP, options, globalSettings, nil)
+}
+
+// waitForSlotRestore waits for the slots saved before a restart to be restored
+func waitForSlotRestore(t *testing.T, inst *instance.Process) *instance.SlotRestore {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if result := inst.GetLastSlotRestore(); result != nil && !result.Time.IsZero() {
+			return result
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for the slots to be restored")
+	return nil
+}
+
+func TestSlots_SaveAndRestore(t *testing.T) {
+	backend := &fakeSlotServer{}
+	inst := newSlotTestInstance(t, backend)
+
+	preview, err := inst.GetCommandPreview()
+	if err != nil {
+		t.Fatalf("GetCommandPreview failed: %v", err)
+	}
+	if idx := slices.Index(preview.Args, "--slot-save-path"); idx < 0 || preview.Args[idx+1] != backend.dir {
+		t.Errorf("Expected slots to be saved to %s, got args %v", backend.dir, preview.Args)
+	}
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	saved, err := inst.SaveSlots()
+	if err != nil || saved != 2 {
+		t.Fatalf("Expected 2 slots to be saved, got %d: %v", saved, err)
+	}
+	if err := inst.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	result := waitForSlotRestore(t, inst)
+	if result.Saved != 2 || result.Restored != 2 || result.Error != "" {
+		t.Errorf("Expected both slots to be restored, got %+v", result)
+	}
+	if entries, _ := os.ReadDir(backend.dir); len(entries) != 0 {
+		t.Errorf("Expected the restored slot files to be removed, got %d files", len(entries))
+	}
+}
+
+func TestSlots_RestoreFailure(t *testing.T) {
+	backend := &fakeSlotServer{failRestore: true}
+	inst := newSlotTestInstance(t, backend)
+
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if _, err := inst.SaveSlots(); err != nil {
+		t.Fatalf("SaveSlots failed: %v", err)
+	}
+	inst.Stop()
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Expected the restart to go ahead, got %v", err)
+	}
+
+	result := waitForSlotRestore(t, inst)
+	if result.Restored != 0 || result.Error == "" {
+		t.Errorf("Expected the failed restore to be recorded, got %+v", result)
+	}
+	if !inst.IsRunning() {
+		t.Error("Expected the instance to keep running without its slots")
+	}
+	if entries, _ := os.ReadDir(backend.dir); len(entries) != 2 {
+		t.Errorf("Expected the slot files to be kept for the retention period, got %d files", len(entries))
+	}
+}
+
+func TestSlots_Retention(t *testing.T) {
+	backend := &fakeSlotServer{}
+	inst := newSlotTestInstance(t, backend)
+	if err := os.MkdirAll(backend.dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	old := filepath.Join(backend.dir, "llamactl-slot-0.bin")
+	recent := filepath.Join(backend.dir, "llamactl-slot-1.bin")
+	foreign := filepath.Join(backend.dir, "user-slot.bin")
+	for _, path := range []string{old, recent, foreign} {
+		os.WriteFile(path, nil, 0644)
+	}
+	longAgo := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(old, longAgo, longAgo)
+	os.Chtimes(foreign, longAgo, longAgo)
+
+	removed, err := inst.EnforceSlotRetention()
+	if err != nil {
+		t.Fatalf("EnforceSlotRetention failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != "llamactl-slot-0.bin" {
+		t.Errorf("Expected only the expired slot file of llamactl to be removed, got %v", removed)
+	}
+	for _, path := range []string{recent, foreign} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package instance
+
+import (
+	"errors"
+	"fmt"
+	"llamactl/pkg/memory"
+	"log"
+	"os/exec"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// startFailureOutputLines is the number of lines of error output kept for the reason of a
+// failed start
+const startFailureOutputLines = 20
+
+// outputDrainTimeout bounds how long the output of an exited process is read for
+const outputDrainTimeout = 500 * time.Millisecond
+
+// StartFailure describes a backend that exited while starting, before it passed a
+// readiness check and within its readiness timeout
+type StartFailure struct {
+	ExitCode int    `json:"exit_code"` // -1 if the process was killed by a signal
+	Signal   string `json:"signal,omitempty"`
+	// Last lines of error output of the backend
+	Output []string `json:"output"`
+	// Set when the output matched alloc_failure_patterns, in which case the instance
+	// is not restarted since the next attempt would fail the same way
+	AllocationFailure bool      `json:"allocation_failure"`
+	Timestamp         time.Time `json:"timestamp"`
+}
+
+// StartFailureError is returned when waiting for an instance whose backend exited while starting
+type StartFailureError struct {
+	Name    string
+	Message string
+	Failure *StartFailure
+}
+
+func (e *StartFailureError) Error() string {
+	return fmt.Sprintf("instance %s exited before becoming healthy: %s", e.Name, e.Message)
+}
+
+// Subsystems the output tails are accounted under
+const (
+	memoryStderrTail = "stderr_tail" // Error output kept for the reason of a failed start
+	memoryOutputTail = "output_tail" // Output kept for the last crash
+)
+
+// outputTail keeps the last lines of output of a process run, in a ring charged to the
+// memory accountant of the instance
+type outputTail struct {
+	ring *memory.Ring[string]
+}
+
+func newOutputTail(accountant *memory.Accountant, name, subsystem string, size int) *outputTail {
+	return &outputTail{ring: memory.NewRing[string](accountant, name, subsystem, size)}
+}
+
+func (t *outputTail) add(line string) {
+	if t == nil || strings.TrimSpace(line) == "" {
+		return
+	}
+	t.ring.Add(line, int64(len(line)))
+}
+
+func (t *outputTail) snapshot() []string {
+	if t == nil {
+		return nil
+	}
+	return append([]string{}, t.ring.Snapshot()...)
+}
+
+// release frees the lines once the tail is replaced or the instance deleted
+func (t *outputTail) release() {
+	if t != nil {
+		t.ring.Release()
+	}
+}
+
+// compilePatterns combines regular expressions into one alternation, returning nil when
+// there are none or they are invalid
+func compilePatterns(name, kind string, patterns []string) *regexp.Regexp {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	alternatives := make([]string, len(patterns))
+	for idx, pattern := range patterns {
+		alternatives[idx] = "(?:" + pattern + ")"
+	}
+	pattern, err := regexp.Compile(strings.Join(alternatives, "|"))
+	if err != nil {
+		// Patterns are validated when the configuration is loaded
+		log.Printf("Warning: %s patterns of instance %s are invalid, ignoring them: %v", kind, name, err)
+		return nil
+	}
+	return pattern
+}
+
+// starting reports whether the current process has neither passed a readiness check nor
+// used up its readiness timeout (caller must hold the lock)
+func (i *Process) starting() bool {
+	if i.healthy || i.startedAt.IsZero() {
+		return false
+	}
+	return i.timeProvider.Now().Before(i.startedAt.Add(i.readiness().Timeout.Duration()))
+}
+
+// startFailure describes the exit of a process that was starting from the result of
+// cmd.Wait and its last lines of error output, and returns the message of the exit reason
+// (caller must hold the lock)
+func (i *Process) startFailure(err error) (*StartFailure, string) {
+	failure := &StartFailure{
+		Output:    i.stderrTail.snapshot(),
+		Timestamp: i.timeProvider.Now(),
+	}
+
+	message := "process exited with code 0 while starting"
+	var signal syscall.Signal
+	failure.ExitCode, signal = exitStatus(err)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		message = fmt.Sprintf("process exited with code %d while starting", failure.ExitCode)
+		if signal != 0 {
+			failure.Signal = signal.String()
+			message = fmt.Sprintf("process was killed by signal %d (%s) while starting", signal, failure.Signal)
+		}
+	} else if err != nil {
+		message = err.Error() + " while starting"
+	}
+
+	// Report the allocation failure, or else the last line the backend wrote
+	var detail string
+	for idx := len(failure.Output) - 1; idx >= 0; idx-- {
+		if i.allocationFailure != nil && i.allocationFailure.MatchString(failure.Output[idx]) {
+			failure.AllocationFailure = true
+			detail = failure.Output[idx]
+			break
+		}
+	}
+	if detail == "" && len(failure.Output) > 0 {
+		detail = failure.Output[len(failure.Output)-1]
+	}
+	if detail != "" {
+		message += ": " + strings.TrimSpace(detail)
+	}
+
+	return failure, message
+}
+
+// SetMemoryAccountant sets the accountant the output tails of the next starts are charged
+// to, nil leaving them unlimited
+func (i *Process) SetMemoryAccountant(accountant *memory.Accountant) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.memory = accountant
+}
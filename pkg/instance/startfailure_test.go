@@ -0,0 +1,151 @@
+package instance_test
+
+import (
+	"errors"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStartFailureInstance returns an auto-restarted instance running script, with the
+// default allocation failure patterns, whose backend is expected on the given address
+func newStartFailureInstance(t *testing.T, script string, host string, port int, onStatusChange instance.StatusChangeFunc) *instance.Process {
+	t.Helper()
+	globalSettings := &config.InstancesConfig{AllocFailurePatterns: config.DefaultAllocFailurePatterns}
+	options := &instance.CreateInstanceOptions{
+		BackendType:      backends.BackendTypeLlamaCpp,
+		AutoRestart:      testutil.BoolPtr(true),
+		MaxRestarts:      testutil.IntPtr(1),
+		RestartDelay:     testutil.DurationPtr(0),
+		ReadinessTimeout: testutil.DurationPtr(10 * time.Minute),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  host,
+			Port:  port,
+		},
+	}
+
+	inst := newScriptInstance(t, "test-instance", script, options, globalSettings, onStatusChange)
+	t.Cleanup(func() {
+		if inst.IsRunning() {
+			inst.Stop()
+		}
+	})
+	return inst
+}
+
+// closedPort returns a local port nothing listens on
+func closedPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestStartFailure_AllocationFailureNotRestarted(t *testing.T) {
+	// Output of llama-server loading a model that does not fit in VRAM
+	const script = `echo 'load_tensors: offloading 32 repeating layers to GPU' >&2
+echo 'ggml_backend_cuda_buffer_type_alloc_buffer: allocating 7338.00 MiB on device 0: cudaMalloc failed: out of memory' >&2
+echo 'alloc_tensor_range: failed to allocate CUDA0 buffer of size 7694454784' >&2
+echo 'llama_model_load: error loading model: unable to allocate CUDA0 buffer' >&2
+exit 1`
+
+	recorder := newTransitionRecorder()
+	inst := newStartFailureInstance(t, script, "127.0.0.1", closedPort(t), recorder.record)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	start := time.Now()
+	err := inst.WaitForHealthy(0)
+	var startErr *instance.StartFailureError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("Expected a start failure, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the exit to end the readiness wait, took %v", elapsed)
+	}
+
+	failure := startErr.Failure
+	if failure.ExitCode != 1 || !failure.AllocationFailure || len(failure.Output) != 4 {
+		t.Fatalf("Expected the exit code and output to be captured, got %+v", failure)
+	}
+	if !strings.HasSuffix(err.Error(), "process exited with code 1 while starting: llama_model_load: error loading model: unable to allocate CUDA0 buffer") {
+		t.Errorf("Expected the reason to quote the allocation failure, got %q", err)
+	}
+
+	// Restarting would fail the same way
+	recorder.waitFor(t, instance.Failed)
+	time.Sleep(100 * time.Millisecond)
+	codes := []instance.ReasonCode{}
+	for _, tr := range recorder.snapshot() {
+		codes = append(codes, tr.reason.Code)
+	}
+	if expected := []instance.ReasonCode{instance.ReasonUserStart, instance.ReasonCrash, instance.ReasonCrash}; !slices.Equal(codes, expected) {
+		t.Errorf("Expected the instance to fail without a restart, got %v", codes)
+	}
+	if inst.GetStatus() != instance.Failed || inst.StartFailure == nil {
+		t.Errorf("Expected the failed instance to keep its start failure, got %s", inst.GetStatus())
+	}
+}
+
+func TestStartFailure_OtherFailuresRestarted(t *testing.T) {
+	recorder := newTransitionRecorder()
+	inst := newStartFailureInstance(t, "echo 'error: unknown argument: --bogus' >&2; exit 2", "127.0.0.1", closedPort(t), recorder.record)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	recorder.waitFor(t, instance.Failed)
+	var restarted bool
+	for _, tr := range recorder.snapshot() {
+		restarted = restarted || tr.reason.Code == instance.ReasonAutoRestart
+	}
+	if !restarted {
+		t.Errorf("Expected the instance to be restarted per its policy, got %+v", recorder.snapshot())
+	}
+	if inst.StartFailure == nil || inst.StartFailure.AllocationFailure || inst.StartFailure.ExitCode != 2 {
+		t.Errorf("Expected the start failure to be recorded, got %+v", inst.StartFailure)
+	}
+}
+
+func TestStartFailure_ExitAfterHealthy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	recorder := newTransitionRecorder()
+	inst := newStartFailureInstance(t, "sleep 0.5; echo 'out of memory' >&2; exit 1", backendURL.Hostname(), port, recorder.record)
+	if err := inst.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := inst.WaitForHealthy(0); err != nil {
+		t.Fatalf("WaitForHealthy failed: %v", err)
+	}
+
+	// A crash once the backend is serving is not a start failure, whatever it logged
+	recorder.waitFor(t, instance.Stopped)
+	if inst.StartFailure != nil {
+		t.Errorf("Expected no start failure for a backend that became healthy, got %+v", inst.StartFailure)
+	}
+	if codes := recorder.snapshot(); codes[2].reason.Code != instance.ReasonCrash || codes[2].reason.Message != "exit status 1" {
+		t.Errorf("Expected a crash, got %+v", codes[2].reason)
+	}
+}
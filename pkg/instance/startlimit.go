@@ -0,0 +1,174 @@
+package instance
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// StartLimiter bounds the number of instances loading their model at the same time. An
+// instance holds a loading slot from its start until it passes a readiness check, exits or
+// runs out of readiness timeout. Starts beyond the limit wait for a slot in arrival order,
+// so instances that crashed together come back a few at a time instead of all at once.
+type StartLimiter struct {
+	mu      sync.Mutex
+	limit   int // 0 = unlimited
+	loading int
+	queue   []chan struct{} // Closed when the slot of a waiting start is handed over
+}
+
+// NewStartLimiter creates a limiter allowing limit instances to load at the same time, any
+// number if limit is 0
+func NewStartLimiter(limit int) *StartLimiter {
+	return &StartLimiter{limit: max(limit, 0)}
+}
+
+// tryAcquire takes a loading slot if one is free and no start is waiting for one
+func (l *StartLimiter) tryAcquire() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit > 0 && (l.loading >= l.limit || len(l.queue) > 0) {
+		return false
+	}
+	l.loading++
+	return true
+}
+
+// acquire waits for a loading slot, returning false if ctx is done first
+func (l *StartLimiter) acquire(ctx context.Context) bool {
+	l.mu.Lock()
+	if l.limit == 0 || (l.loading < l.limit && len(l.queue) == 0) {
+		l.loading++
+		l.mu.Unlock()
+		return true
+	}
+	ready := make(chan struct{})
+	l.queue = append(l.queue, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return true
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	for idx, waiter := range l.queue {
+		if waiter == ready {
+			l.queue = append(l.queue[:idx], l.queue[idx+1:]...)
+			l.mu.Unlock()
+			return false
+		}
+	}
+	l.mu.Unlock()
+	// The slot was handed over as ctx was done, pass it on
+	l.release()
+	return false
+}
+
+// release frees a loading slot, handing it to the first waiting start if any and the limit
+// was not lowered below the instances loading
+func (l *StartLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.queue) > 0 && (l.limit == 0 || l.loading <= l.limit) {
+		close(l.queue[0])
+		l.queue = l.queue[1:]
+		return
+	}
+	l.loading--
+}
+
+// Limit returns the number of instances allowed to load at the same time, 0 if unlimited
+func (l *StartLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// SetLimit changes the number of instances allowed to load at the same time. Raising it
+// lets waiting starts through right away, lowering it makes new starts wait until enough
+// of the instances loading are ready.
+func (l *StartLimiter) SetLimit(limit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = max(limit, 0)
+	for len(l.queue) > 0 && (l.limit == 0 || l.loading < l.limit) {
+		close(l.queue[0])
+		l.queue = l.queue[1:]
+		l.loading++
+	}
+}
+
+// SetStartLimiter sets the limiter the automatic restarts of the instance, and the starts
+// through StartQueued, wait on
+func (i *Process) SetStartLimiter(limiter *StartLimiter) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.startLimiter = limiter
+}
+
+// StartQueued starts the instance like Start, once the start limiter has a loading slot for
+// it. Meanwhile the instance is queued, and stopping it cancels the start.
+func (i *Process) StartQueued() error {
+	return i.StartQueuedWithReason(ReasonUserStart, "")
+}
+
+// StartQueuedWithReason is StartQueued recording the given reason for the transition to running
+func (i *Process) StartQueuedWithReason(code ReasonCode, message string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	i.mu.Lock()
+	i.queueCancel = cancel
+	i.mu.Unlock()
+	defer func() {
+		i.mu.Lock()
+		i.queueCancel = nil
+		i.mu.Unlock()
+	}()
+
+	return i.startLimited(ctx, code, message)
+}
+
+// startLimited starts the instance once the start limiter has a loading slot for it, with
+// the instance queued while it waits. The slot is released once the new process is ready.
+func (i *Process) startLimited(ctx context.Context, code ReasonCode, message string) error {
+	i.mu.Lock()
+	limiter := i.startLimiter
+	if limiter == nil {
+		i.mu.Unlock()
+		return i.start(ctx, code, message)
+	}
+	queued := !limiter.tryAcquire()
+	previous, previousReason := i.GetStatus(), i.GetStatusReason()
+	if queued && !i.IsRunning() {
+		i.SetStatus(Queued, ReasonStartQueued, fmt.Sprintf("waiting for one of %d loading slots", limiter.Limit()))
+	}
+	i.mu.Unlock()
+
+	// Back to the status before queuing if the instance did not start
+	unqueue := func() {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		if i.GetStatus() == Queued && previousReason != nil {
+			i.SetStatus(previous, previousReason.Code, previousReason.Message)
+		}
+	}
+
+	if queued && !limiter.acquire(ctx) {
+		unqueue()
+		return fmt.Errorf("start of instance %s was cancelled while queued", i.Name)
+	}
+
+	if err := i.start(ctx, code, message); err != nil {
+		limiter.release()
+		unqueue()
+		return err
+	}
+	i.goroutines.Go(func() {
+		i.WaitForHealthy(0) // Returns as soon as the process exits
+		limiter.release()
+	})
+	return nil
+}
@@ -0,0 +1,414 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRestartJitter(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    instance.RestartJitter
+		expectError bool
+	}{
+		{`"25%"`, instance.RestartJitter{Percent: 25}, false},
+		{`"30s"`, instance.RestartJitter{Max: 30 * time.Second}, false},
+		{`30`, instance.RestartJitter{Max: 30 * time.Second}, false},
+		{`"150%"`, instance.RestartJitter{}, true},
+		{`"-5%"`, instance.RestartJitter{}, true},
+		{`"-5s"`, instance.RestartJitter{}, true},
+		{`"soon"`, instance.RestartJitter{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var jitter instance.RestartJitter
+			err := json.Unmarshal([]byte(tt.input), &jitter)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", jitter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if jitter != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, jitter)
+			}
+
+			data, _ := json.Marshal(jitter)
+			var roundTrip instance.RestartJitter
+			if err := json.Unmarshal(data, &roundTrip); err != nil || roundTrip != jitter {
+				t.Errorf("Expected %s to read back as %+v, got %+v (%v)", data, jitter, roundTrip, err)
+			}
+		})
+	}
+}
+
+func TestRestartJitter_Apply(t *testing.T) {
+	delay := 10 * time.Second
+	tests := []struct {
+		name   string
+		jitter *instance.RestartJitter
+		max    time.Duration
+	}{
+		{"none", nil, delay},
+		{"percentage", &instance.RestartJitter{Percent: 50}, 15 * time.Second},
+		{"duration", &instance.RestartJitter{Max: time.Minute}, delay + time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spread := false
+			for range 100 {
+				got := tt.jitter.Apply(delay)
+				if got < delay || got > tt.max {
+					t.Fatalf("Expected a delay between %v and %v, got %v", delay, tt.max, got)
+				}
+				spread = spread || got != delay
+			}
+			if tt.jitter != nil && !spread {
+				t.Error("Expected the jitter to vary the delay")
+			}
+		})
+	}
+}
+
+// newLimitedInstance creates an instance whose backend never becomes ready, so that it
+// holds its loading slot until it is stopped
+func newLimitedInstance(t *testing.T, name string, limiter *instance.StartLimiter) *instance.Process {
+	t.Helper()
+	options := &instance.CreateInstanceOptions{
+		BackendType:      backends.BackendTypeLlamaCpp,
+		AutoRestart:      testutil.BoolPtr(false),
+		ReadinessTimeout: testutil.DurationPtr(10 * time.Minute),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  "127.0.0.1",
+			Port:  1, // Nothing listens there
+		},
+	}
+	inst := newScriptInstance(t, name, "exec sleep 30", options, &config.InstancesConfig{}, nil)
+	inst.SetStartLimiter(limiter)
+	t.Cleanup(func() { inst.Stop() })
+	return inst
+}
+
+// waitForStatus waits for the instance to reach status
+func waitForStatus(t *testing.T, inst *instance.Process, status instance.InstanceStatus) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for inst.GetStatus() != status {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s to be %s, it is %s", inst.Name, status, inst.GetStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStartLimiter(t *testing.T) {
+	limiter := instance.NewStartLimiter(1)
+	loading := newLimitedInstance(t, "loading", limiter)
+	waiting := newLimitedInstance(t, "waiting", limiter)
+	cancelled := newLimitedInstance(t, "cancelled", limiter)
+
+	if err := loading.StartQueued(); err != nil {
+		t.Fatalf("StartQueued failed: %v", err)
+	}
+
+	started := make(chan error, 1)
+	go func() { started <- waiting.StartQueued() }()
+	waitForStatus(t, waiting, instance.Queued)
+	if reason := waiting.GetStatusReason(); reason == nil || reason.Code != instance.ReasonStartQueued {
+		t.Errorf("Expected the start_queued reason, got %+v", reason)
+	}
+
+	cancelledResult := make(chan error, 1)
+	go func() { cancelledResult <- cancelled.StartQueued() }()
+	waitForStatus(t, cancelled, instance.Queued)
+
+	// Stopping a queued instance takes it out of the queue
+	if err := cancelled.Stop(); err != nil {
+		t.Fatalf("Stop of a queued instance failed: %v", err)
+	}
+	if err := <-cancelledResult; err == nil {
+		t.Error("Expected the cancelled start to fail")
+	}
+	if cancelled.GetStatus() != instance.Stopped {
+		t.Errorf("Expected the cancelled instance to be stopped, got %s", cancelled.GetStatus())
+	}
+
+	// The slot is handed over once the loading instance exits
+	if err := loading.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("Queued start failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the queued instance to start")
+	}
+	if !waiting.IsRunning() {
+		t.Errorf("Expected the queued instance to be running, got %s", waiting.GetStatus())
+	}
+
+	// Starts that bypass the limiter do not wait
+	if err := loading.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+}
+
+func TestStartLimiter_AutoRestart(t *testing.T) {
+	limiter := instance.NewStartLimiter(1)
+	holder := newLimitedInstance(t, "holder", limiter)
+
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "sleep 0.2; exit 1"}},
+	}
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(true),
+		MaxRestarts:        testutil.IntPtr(1),
+		RestartDelay:       testutil.DurationPtr(0),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+	crashing := instance.NewInstance("crashing", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+	crashing.SetStartLimiter(limiter)
+	t.Cleanup(func() { crashing.Stop() })
+
+	// Started by hand, bypassing the limiter
+	if err := crashing.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := holder.StartQueued(); err != nil {
+		t.Fatalf("StartQueued failed: %v", err)
+	}
+
+	// The restart after the crash waits for the slot held by the other instance
+	waitForStatus(t, crashing, instance.Queued)
+	if err := holder.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	waitForStatus(t, crashing, instance.Starting)
+}
+
+func TestStartLimiter_SetLimit(t *testing.T) {
+	limiter := instance.NewStartLimiter(1)
+	loading := newLimitedInstance(t, "loading", limiter)
+	waiting := newLimitedInstance(t, "waiting", limiter)
+
+	if err := loading.StartQueued(); err != nil {
+		t.Fatalf("StartQueued failed: %v", err)
+	}
+	started := make(chan error, 1)
+	go func() { started <- waiting.StartQueued() }()
+	waitForStatus(t, waiting, instance.Queued)
+
+	// Raising the limit lets the waiting start through
+	limiter.SetLimit(2)
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("Queued start failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the queued instance to start")
+	}
+	if limiter.Limit() != 2 {
+		t.Errorf("Expected a limit of 2, got %d", limiter.Limit())
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	newCrashing := func(t *testing.T, delay, maxDelay time.Duration, maxRestarts int, onStatusChange instance.StatusChangeFunc) *instance.Process {
+		backendConfig := &config.BackendConfig{
+			LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "exit 1"}},
+		}
+		options := &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			AutoRestart:        testutil.BoolPtr(true),
+			MaxRestarts:        testutil.IntPtr(maxRestarts),
+			RestartDelay:       testutil.DurationPtr(delay),
+			RestartBackoff:     testutil.BoolPtr(true),
+			RestartBackoffMax:  testutil.DurationPtr(maxDelay),
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+		}
+		inst := instance.NewInstance("crashing", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, onStatusChange)
+		t.Cleanup(func() { inst.Stop() })
+		return inst
+	}
+
+	t.Run("delays double up to the maximum", func(t *testing.T) {
+		var mu sync.Mutex
+		var restarting time.Time
+		var gaps []time.Duration
+		done := make(chan struct{})
+		inst := newCrashing(t, 100*time.Millisecond, 250*time.Millisecond, 4, func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case newStatus == instance.Restarting:
+				restarting = time.Now()
+			case oldStatus == instance.Restarting:
+				gaps = append(gaps, time.Since(restarting))
+			case newStatus == instance.Failed:
+				close(done)
+			}
+		})
+
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("Timed out waiting for the restarts to run out")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond, 250 * time.Millisecond}
+		if len(gaps) != len(expected) {
+			t.Fatalf("Expected %d restarts, got delays %v", len(expected), gaps)
+		}
+		for idx, gap := range gaps {
+			if gap < expected[idx] {
+				t.Errorf("Expected restart %d to wait at least %v, got %v", idx+1, expected[idx], gap)
+			}
+		}
+		// Without the maximum the last restart would wait 800ms
+		if gaps[3] >= 750*time.Millisecond {
+			t.Errorf("Expected the last restart to wait at most about 250ms, got %v", gaps[3])
+		}
+	})
+
+	t.Run("next restart time", func(t *testing.T) {
+		recorder := newTransitionRecorder()
+		inst := newCrashing(t, time.Minute, 0, 1, recorder.record)
+
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		recorder.waitFor(t, instance.Restarting)
+
+		var status struct {
+			Restarting    bool       `json:"restarting"`
+			NextRestartAt *time.Time `json:"next_restart_at"`
+		}
+		data, _ := json.Marshal(inst)
+		if err := json.Unmarshal(data, &status); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		if !status.Restarting || status.NextRestartAt == nil || time.Until(*status.NextRestartAt) < 50*time.Second {
+			t.Fatalf("Expected the next restart in about a minute, got %v at %v", status.Restarting, status.NextRestartAt)
+		}
+
+		if err := inst.Stop(); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		status.Restarting, status.NextRestartAt = false, nil
+		data, _ = json.Marshal(inst)
+		json.Unmarshal(data, &status)
+		if status.Restarting || status.NextRestartAt != nil {
+			t.Errorf("Expected no next restart once stopped, got %v at %v", status.Restarting, status.NextRestartAt)
+		}
+	})
+}
+
+func TestRestartResetAfter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	// newReadyCrashing returns an instance whose backend passes its readiness check, then
+	// crashes after 300ms
+	newReadyCrashing := func(t *testing.T, resetAfter time.Duration, onStatusChange instance.StatusChangeFunc) *instance.Process {
+		backendConfig := &config.BackendConfig{
+			LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "sleep 0.3; exit 1"}},
+		}
+		options := &instance.CreateInstanceOptions{
+			BackendType:       backends.BackendTypeLlamaCpp,
+			AutoRestart:       testutil.BoolPtr(true),
+			MaxRestarts:       testutil.IntPtr(1),
+			RestartDelay:      testutil.DurationPtr(0),
+			RestartResetAfter: testutil.DurationPtr(resetAfter),
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{
+				Model: "/path/to/model.gguf",
+				Host:  backendURL.Hostname(),
+				Port:  port,
+			},
+		}
+		inst := instance.NewInstance("crashing", backendConfig, &config.InstancesConfig{LogsDir: t.TempDir()}, options, onStatusChange)
+		t.Cleanup(func() { inst.Stop() })
+		return inst
+	}
+	restartsOf := func(inst *instance.Process) int {
+		var status struct {
+			Restarts int `json:"restarts"`
+		}
+		data, _ := json.Marshal(inst)
+		json.Unmarshal(data, &status)
+		return status.Restarts
+	}
+
+	t.Run("stable runs reset the counter", func(t *testing.T) {
+		restarted := make(chan struct{}, 16)
+		inst := newReadyCrashing(t, 100*time.Millisecond, func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {
+			if newStatus == instance.Restarting {
+				restarted <- struct{}{}
+			}
+		})
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+
+		// Each run stays ready longer than restart_reset_after, so MaxRestarts is never reached
+		for attempt := range 3 {
+			select {
+			case <-restarted:
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Timed out waiting for restart %d, the instance is %s", attempt+1, inst.GetStatus())
+			}
+		}
+		if restarts := restartsOf(inst); restarts != 1 {
+			t.Errorf("Expected the restart counter to start over at each crash, got %d", restarts)
+		}
+	})
+
+	t.Run("unstable runs use up the restarts", func(t *testing.T) {
+		recorder := newTransitionRecorder()
+		inst := newReadyCrashing(t, time.Hour, recorder.record)
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		recorder.waitFor(t, instance.Failed)
+		if restarts := restartsOf(inst); restarts != 1 {
+			t.Errorf("Expected 1 restart before giving up, got %d", restarts)
+		}
+	})
+}
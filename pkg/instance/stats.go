@@ -0,0 +1,267 @@
+package instance
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// statsBucketDuration is the width of a single error-rate bucket
+	statsBucketDuration = 10 * time.Second
+	// statsBucketCount buckets make up the recent window (1 minute)
+	statsBucketCount = 6
+)
+
+// RecentStatsWindow is the window covered by the recent request and error counters
+const RecentStatsWindow = statsBucketDuration * statsBucketCount
+
+// LatencyBucketBounds are the upper bounds of the response latency histogram
+var LatencyBucketBounds = [...]time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+type statsBucket struct {
+	epoch    atomic.Int64
+	requests atomic.Int64
+	errors   atomic.Int64
+}
+
+// ProxyStats counts proxied requests using only atomic operations. Recording on the
+// proxy path never takes a lock, and snapshots are assembled by reading the counters,
+// so stats requests can never stall proxied traffic or contend with lifecycle locks.
+type ProxyStats struct {
+	requests       atomic.Int64
+	errors         atomic.Int64
+	inFlight       atomic.Int64
+	cancelled      atomic.Int64
+	timeouts       atomic.Int64
+	latencySum     atomic.Int64                               // nanoseconds
+	latencyBuckets [len(LatencyBucketBounds) + 1]atomic.Int64 // last bucket is +Inf
+	buckets        [statsBucketCount]statsBucket
+	slo            atomic.Pointer[sloTracker] // nil unless the instance has objectives
+}
+
+// StatsSnapshot is a point-in-time copy of an instance's proxy stats
+type StatsSnapshot struct {
+	Requests         int64             `json:"requests"`
+	Errors           int64             `json:"errors"`
+	InFlight         int64             `json:"in_flight"`
+	Cancelled        int64             `json:"cancelled"` // Requests abandoned by the client before the response completed
+	Timeouts         int64             `json:"timeouts"`  // Requests cut off by max_request_duration
+	RecentRequests   int64             `json:"recent_requests"`
+	RecentErrors     int64             `json:"recent_errors"`
+	RecentErrorRate  float64           `json:"recent_error_rate"`
+	AvgLatencyMs     float64           `json:"avg_latency_ms"`
+	LatencyHistogram []HistogramBucket `json:"latency_histogram"`
+	Connections      *ConnectionStats  `json:"connections,omitempty"` // Backend connections, once the proxy is in use
+	Queue            *QueueStats       `json:"queue,omitempty"`       // Admission queue and wait estimate, when max_concurrent_requests is set
+	LogDisk          *LogDiskUsage     `json:"log_disk,omitempty"`    // Disk space of the log files, once measured
+}
+
+// HistogramBucket is a single cumulative latency histogram bucket; LeMs is 0 for the +Inf bucket
+type HistogramBucket struct {
+	LeMs  float64 `json:"le_ms"`
+	Count int64   `json:"count"`
+}
+
+// NewProxyStats creates empty proxy stats
+func NewProxyStats() *ProxyStats {
+	return &ProxyStats{}
+}
+
+// Record counts a completed request; failed reports a transport error or a 5xx response
+func (s *ProxyStats) Record(now time.Time, failed bool) {
+	s.requests.Add(1)
+	if failed {
+		s.errors.Add(1)
+	}
+
+	b := s.bucket(now)
+	b.requests.Add(1)
+	if failed {
+		b.errors.Add(1)
+	}
+}
+
+// RecordCancelled counts a request the client abandoned before the backend responded.
+// It is counted as a request but not as an error.
+func (s *ProxyStats) RecordCancelled(now time.Time) {
+	s.Record(now, false)
+	s.cancelled.Add(1)
+}
+
+// RecordLatency adds the time until the backend responded to the latency histogram
+func (s *ProxyStats) RecordLatency(d time.Duration) {
+	s.latencySum.Add(int64(d))
+	idx := len(LatencyBucketBounds)
+	for i, bound := range LatencyBucketBounds {
+		if d <= bound {
+			idx = i
+			break
+		}
+	}
+	s.latencyBuckets[idx].Add(1)
+}
+
+// Snapshot returns the current counters, with the recent counters covering RecentStatsWindow
+func (s *ProxyStats) Snapshot(now time.Time) StatsSnapshot {
+	snapshot := StatsSnapshot{
+		Requests:  s.requests.Load(),
+		Errors:    s.errors.Load(),
+		InFlight:  s.inFlight.Load(),
+		Cancelled: s.cancelled.Load(),
+		Timeouts:  s.timeouts.Load(),
+	}
+
+	current := bucketEpoch(now)
+	for idx := range s.buckets {
+		b := &s.buckets[idx]
+		if epoch := b.epoch.Load(); epoch > current-statsBucketCount && epoch <= current {
+			snapshot.RecentRequests += b.requests.Load()
+			snapshot.RecentErrors += b.errors.Load()
+		}
+	}
+	if snapshot.RecentRequests > 0 {
+		snapshot.RecentErrorRate = float64(snapshot.RecentErrors) / float64(snapshot.RecentRequests)
+	}
+
+	var cumulative int64
+	snapshot.LatencyHistogram = make([]HistogramBucket, len(s.latencyBuckets))
+	for idx := range s.latencyBuckets {
+		cumulative += s.latencyBuckets[idx].Load()
+		snapshot.LatencyHistogram[idx].Count = cumulative
+		if idx < len(LatencyBucketBounds) {
+			snapshot.LatencyHistogram[idx].LeMs = float64(LatencyBucketBounds[idx]) / float64(time.Millisecond)
+		}
+	}
+	if cumulative > 0 {
+		snapshot.AvgLatencyMs = float64(s.latencySum.Load()) / float64(cumulative) / float64(time.Millisecond)
+	}
+
+	return snapshot
+}
+
+// bucket returns the bucket for the given time, resetting it if it still holds an older window
+func (s *ProxyStats) bucket(now time.Time) *statsBucket {
+	epoch := bucketEpoch(now)
+	b := &s.buckets[epoch%statsBucketCount]
+	if old := b.epoch.Load(); old != epoch && b.epoch.CompareAndSwap(old, epoch) {
+		b.requests.Store(0)
+		b.errors.Store(0)
+	}
+	return b
+}
+
+func bucketEpoch(t time.Time) int64 {
+	return t.UnixNano() / int64(statsBucketDuration)
+}
+
+// statsTransport records proxy stats around every backend round trip
+type statsTransport struct {
+	next          http.RoundTripper
+	stats         *ProxyStats
+	now           func() time.Time
+	onServerError func(resp *http.Response) // Inspects 5xx responses before they are proxied
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := t.now()
+	t.stats.inFlight.Add(1)
+
+	resp, err := t.next.RoundTrip(req)
+	end := t.now()
+	latency := end.Sub(start)
+	t.stats.RecordLatency(latency)
+	if err != nil {
+		t.stats.inFlight.Add(-1)
+		if clientCancelled(req, err) {
+			t.stats.RecordCancelled(end)
+		} else {
+			t.stats.Record(end, true)
+			t.stats.recordSLO(req, end, latency, true)
+			if requestTimedOut(req) {
+				t.stats.timeouts.Add(1)
+			}
+		}
+		return nil, err
+	}
+
+	failed := resp.StatusCode >= http.StatusInternalServerError
+	if failed && t.onServerError != nil {
+		t.onServerError(resp)
+	}
+	t.stats.Record(end, failed)
+	counted := t.stats.recordSLO(req, end, latency, failed)
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		// Upgraded connections need the original read-write body
+		t.stats.inFlight.Add(-1)
+		return resp, nil
+	}
+	// The request stays in flight until the (possibly streamed) body has been consumed
+	body := &inFlightBody{ReadCloser: resp.Body, stats: t.stats, req: req}
+	if counted {
+		body.now = t.now
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// clientCancelled reports whether a round trip failed because the client went away. The
+// proxied request carries the context of the client request, so the backend connection
+// is torn down as soon as the client disconnects.
+func clientCancelled(req *http.Request, err error) bool {
+	return errors.Is(err, context.Canceled) && errors.Is(req.Context().Err(), context.Canceled)
+}
+
+// inFlightBody decrements the in-flight counter once the response body is closed, and
+// counts the request as cancelled if the client went away before the body was complete, or
+// as timed out if it ran out of time
+type inFlightBody struct {
+	io.ReadCloser
+	stats  *ProxyStats
+	req    *http.Request
+	now    func() time.Time // Set when the size of the body counts towards the SLO
+	read   atomic.Int64
+	eof    atomic.Bool
+	closed atomic.Bool
+}
+
+func (b *inFlightBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read.Add(int64(n))
+	if err == io.EOF {
+		b.eof.Store(true)
+	}
+	return n, err
+}
+
+func (b *inFlightBody) Close() error {
+	if b.closed.CompareAndSwap(false, true) {
+		b.stats.inFlight.Add(-1)
+		if !b.eof.Load() {
+			if errors.Is(b.req.Context().Err(), context.Canceled) {
+				b.stats.cancelled.Add(1)
+			} else if requestTimedOut(b.req) {
+				b.stats.timeouts.Add(1)
+			}
+		}
+		if b.now != nil {
+			b.stats.recordSLOResponseBytes(b.now(), b.read.Load())
+		}
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,299 @@
+package instance_test
+
+import (
+	"context"
+	"io"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProxyStats_Snapshot(t *testing.T) {
+	stats := instance.NewProxyStats()
+	start := time.Unix(1700000000, 0)
+
+	stats.Record(start, false)
+	stats.Record(start, true)
+	stats.RecordLatency(3 * time.Millisecond)
+	stats.RecordLatency(20 * time.Millisecond)
+	stats.RecordLatency(time.Minute)
+
+	snapshot := stats.Snapshot(start)
+	if snapshot.Requests != 2 || snapshot.Errors != 1 {
+		t.Errorf("Expected 2 requests and 1 error, got %d and %d", snapshot.Requests, snapshot.Errors)
+	}
+	if snapshot.RecentRequests != 2 || snapshot.RecentErrorRate != 0.5 {
+		t.Errorf("Expected 2 recent requests at error rate 0.5, got %d at %v", snapshot.RecentRequests, snapshot.RecentErrorRate)
+	}
+
+	histogram := snapshot.LatencyHistogram
+	if len(histogram) != len(instance.LatencyBucketBounds)+1 {
+		t.Fatalf("Expected %d histogram buckets, got %d", len(instance.LatencyBucketBounds)+1, len(histogram))
+	}
+	if histogram[0].LeMs != 5 || histogram[0].Count != 1 {
+		t.Errorf("Expected 1 request within 5ms, got %+v", histogram[0])
+	}
+	if histogram[2].LeMs != 25 || histogram[2].Count != 2 {
+		t.Errorf("Expected 2 requests within 25ms, got %+v", histogram[2])
+	}
+	if last := histogram[len(histogram)-1]; last.Count != 3 {
+		t.Errorf("Expected 3 requests in the +Inf bucket, got %d", last.Count)
+	}
+
+	// Requests outside the recent window are no longer counted as recent
+	later := start.Add(instance.RecentStatsWindow + time.Second)
+	snapshot = stats.Snapshot(later)
+	if snapshot.RecentRequests != 0 || snapshot.Requests != 2 {
+		t.Errorf("Expected no recent requests and 2 total, got %d and %d", snapshot.RecentRequests, snapshot.Requests)
+	}
+
+	stats.Record(later, false)
+	if snapshot = stats.Snapshot(later); snapshot.RecentRequests != 1 || snapshot.RecentErrors != 0 {
+		t.Errorf("Expected 1 recent request and no errors, got %d and %d", snapshot.RecentRequests, snapshot.RecentErrors)
+	}
+}
+
+func TestProxyStats_InFlight(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer backend.Close()
+
+	inst := newTLSTestInstance(t, backend, nil)
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/completions", nil))
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for inst.GetStats().InFlight != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for request to be in flight")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	<-done
+
+	stats := inst.GetStats()
+	if stats.InFlight != 0 || stats.Requests != 1 {
+		t.Errorf("Expected 1 completed request and none in flight, got %+v", stats)
+	}
+}
+
+func TestProxyStats_ClientCancelled(t *testing.T) {
+	tests := []struct {
+		name string
+		// Whether the backend sends the response headers before stalling
+		streaming bool
+	}{
+		{name: "before the response", streaming: false},
+		{name: "while streaming", streaming: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan struct{}, 1)
+			cancelled := make(chan time.Time, 1)
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.streaming {
+					w.Header().Set("Content-Type", "text/event-stream")
+					io.WriteString(w, "data: {}\n\n")
+					w.(http.Flusher).Flush()
+				}
+				received <- struct{}{}
+				<-r.Context().Done()
+				cancelled <- time.Now()
+			}))
+			defer backend.Close()
+
+			inst := newTLSTestInstance(t, backend, nil)
+			proxy, err := inst.GetProxy()
+			if err != nil {
+				t.Fatalf("GetProxy failed: %v", err)
+			}
+			front := httptest.NewServer(proxy)
+			defer front.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			req, _ := http.NewRequestWithContext(ctx, "POST", front.URL+"/v1/completions", nil)
+			go func() {
+				if resp, err := http.DefaultClient.Do(req); err == nil {
+					resp.Body.Read(make([]byte, 64))
+					resp.Body.Close()
+				}
+			}()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for inst.GetStats().InFlight != 1 {
+				if time.Now().After(deadline) {
+					t.Fatal("Timed out waiting for request to be in flight")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			// The request is in flight before the proxy has forwarded it
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for the backend to receive the request")
+			}
+			if tt.streaming {
+				// Let the client receive the first chunk before it drops
+				time.Sleep(20 * time.Millisecond)
+			}
+
+			dropped := time.Now()
+			cancel()
+			select {
+			case at := <-cancelled:
+				if latency := at.Sub(dropped); latency > 250*time.Millisecond {
+					t.Errorf("Expected the backend request to be cancelled within milliseconds, took %v", latency)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("The backend request was not cancelled after the client dropped")
+			}
+
+			deadline = time.Now().Add(5 * time.Second)
+			for inst.GetStats().InFlight != 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("Timed out waiting for the request to complete")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			stats := inst.GetStats()
+			if stats.Requests != 1 || stats.Cancelled != 1 || stats.Errors != 0 {
+				t.Errorf("Expected 1 cancelled request and no errors, got %+v", stats)
+			}
+		})
+	}
+}
+
+// paceRequests sends total requests at the given rate and returns the latency of each one
+func paceRequests(handler http.Handler, rate, total int) []time.Duration {
+	latencies := make([]time.Duration, total)
+	interval := time.Second / time.Duration(rate)
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for range 64 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				start := time.Now()
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/completions", nil))
+				latencies[idx] = time.Since(start)
+			}
+		}()
+	}
+
+	start := time.Now()
+	for idx := range total {
+		if wait := time.Until(start.Add(time.Duration(idx) * interval)); wait > 0 {
+			time.Sleep(wait)
+		}
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+
+	return latencies
+}
+
+func p99(latencies []time.Duration) time.Duration {
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	return sorted[len(sorted)*99/100]
+}
+
+func median(values []time.Duration) time.Duration {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+// BenchmarkProxyStatsOverhead drives 10k proxied requests/sec against a fake backend,
+// once through a plain reverse proxy and once through the instance proxy while stats
+// are read continuously, and fails if the p99 latency regresses by more than 5%.
+// Run it on an otherwise idle machine with spare cores; a saturated single core adds
+// scheduler noise of the same order as the margin.
+func BenchmarkProxyStatsOverhead(b *testing.B) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		b.Fatalf("Failed to parse backend URL: %v", err)
+	}
+	plainProxy := httputil.NewSingleHostReverseProxy(backendURL)
+
+	inst := newTLSTestInstance(b, backend, nil)
+	statsProxy, err := inst.GetProxy()
+	if err != nil {
+		b.Fatalf("GetProxy failed: %v", err)
+	}
+
+	// Alternate short rounds so drift in the environment affects both modes equally
+	const rate, rounds, perRound = 10000, 20, 1000
+	var disabled, enabled []time.Duration // per-round p99 latencies
+
+	paceRequests(statsProxy, rate, perRound) // warm up connections
+
+	b.ResetTimer()
+	for range b.N {
+		for range rounds {
+			disabled = append(disabled, p99(paceRequests(plainProxy, rate, perRound)))
+
+			// Read stats every 100µs (10k snapshots/sec) while the requests are running
+			stop := make(chan struct{})
+			var hammer sync.WaitGroup
+			hammer.Add(1)
+			go func() {
+				defer hammer.Done()
+				ticker := time.NewTicker(100 * time.Microsecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-stop:
+						return
+					case <-ticker.C:
+						_ = inst.GetStats()
+						_ = inst.GetQueueStats()
+					}
+				}
+			}()
+			enabled = append(enabled, p99(paceRequests(statsProxy, rate, perRound)))
+			close(stop)
+			hammer.Wait()
+		}
+	}
+	b.StopTimer()
+
+	// Compare the median of the per-round p99s, which is far less noisy than a single p99
+	disabledP99, enabledP99 := median(disabled), median(enabled)
+	b.ReportMetric(float64(disabledP99.Microseconds()), "p99-disabled-µs")
+	b.ReportMetric(float64(enabledP99.Microseconds()), "p99-enabled-µs")
+
+	// Allow a small absolute slack so scheduler noise on sub-millisecond latencies does not dominate
+	if limit := disabledP99 + disabledP99/20 + 100*time.Microsecond; enabledP99 > limit {
+		b.Errorf("p99 latency with stats %v exceeds %v (without stats %v)", enabledP99, limit, disabledP99)
+	}
+}
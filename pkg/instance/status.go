@@ -3,6 +3,7 @@ package instance
 import (
 	"encoding/json"
 	"log"
+	"time"
 )
 
 // Enum for instance status
@@ -12,36 +13,172 @@ const (
 	Stopped InstanceStatus = iota
 	Running
 	Failed
+	Queued     // Waiting for a loading slot to start
+	Starting   // The process runs but has not passed a readiness check yet
+	Restarting // The process exited and an automatic restart is pending
 )
 
 var nameToStatus = map[string]InstanceStatus{
-	"stopped": Stopped,
-	"running": Running,
-	"failed":  Failed,
+	"stopped":    Stopped,
+	"running":    Running,
+	"failed":     Failed,
+	"queued":     Queued,
+	"starting":   Starting,
+	"restarting": Restarting,
 }
 
 var statusToName = map[InstanceStatus]string{
-	Stopped: "stopped",
-	Running: "running",
-	Failed:  "failed",
+	Stopped:    "stopped",
+	Running:    "running",
+	Failed:     "failed",
+	Queued:     "queued",
+	Starting:   "starting",
+	Restarting: "restarting",
 }
 
-func (p *Process) SetStatus(status InstanceStatus) {
+// ReasonCode is a stable, machine-readable reason attached to every status transition.
+// Codes are part of the API contract: existing values must never change, new ones may be added.
+type ReasonCode string
+
+const (
+	ReasonUserStart           ReasonCode = "user_start"
+	ReasonUserStop            ReasonCode = "user_stop"
+	ReasonAutoRestart         ReasonCode = "auto_restart"
+	ReasonRestored            ReasonCode = "restored"
+	ReasonCleanExit           ReasonCode = "clean_exit"
+	ReasonCrash               ReasonCode = "crash"
+	ReasonOOMKill             ReasonCode = "oom_kill"
+	ReasonHealthProbeSuccess  ReasonCode = "health_probe_success"
+	ReasonHealthProbeFailure  ReasonCode = "health_probe_failure"
+	ReasonIdleTimeout         ReasonCode = "idle_timeout"
+	ReasonSchedule            ReasonCode = "schedule"
+	ReasonPreempted           ReasonCode = "preempted"
+	ReasonMaxRestartsExceeded ReasonCode = "max_restarts_exceeded"
+	ReasonShutdown            ReasonCode = "shutdown"
+	ReasonModelDownload       ReasonCode = "model_download"
+	ReasonFatalLog            ReasonCode = "fatal_log"
+	ReasonStartQueued         ReasonCode = "start_queued"
+	ReasonGPUFault            ReasonCode = "gpu_fault"
+	ReasonCrashLoop           ReasonCode = "crash_loop"
+	ReasonAdopted             ReasonCode = "adopted"
+	ReasonReload              ReasonCode = "reload"
+)
+
+// ReasonCodes lists all known reason codes
+var ReasonCodes = []ReasonCode{
+	ReasonUserStart,
+	ReasonUserStop,
+	ReasonAutoRestart,
+	ReasonRestored,
+	ReasonCleanExit,
+	ReasonCrash,
+	ReasonOOMKill,
+	ReasonHealthProbeSuccess,
+	ReasonHealthProbeFailure,
+	ReasonIdleTimeout,
+	ReasonSchedule,
+	ReasonPreempted,
+	ReasonMaxRestartsExceeded,
+	ReasonShutdown,
+	ReasonModelDownload,
+	ReasonFatalLog,
+	ReasonStartQueued,
+	ReasonGPUFault,
+	ReasonCrashLoop,
+	ReasonAdopted,
+	ReasonReload,
+}
+
+// IsError reports whether the reason code describes an abnormal termination
+func (c ReasonCode) IsError() bool {
+	switch c {
+	case ReasonCrash, ReasonOOMKill, ReasonHealthProbeFailure, ReasonMaxRestartsExceeded, ReasonFatalLog, ReasonGPUFault, ReasonCrashLoop:
+		return true
+	}
+	return false
+}
+
+// StatusReason describes why a status transition happened
+type StatusReason struct {
+	Code      ReasonCode `json:"code"`
+	Message   string     `json:"message,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// SetStatus transitions the instance to the given status, recording the reason for the transition
+func (p *Process) SetStatus(status InstanceStatus, code ReasonCode, message string) {
+	if code == "" {
+		log.Printf("Warning: status transition of instance %s to %s has no reason code", p.Name, statusToName[status])
+	}
+
+	now := time.Now()
+	if p.timeProvider != nil {
+		now = p.timeProvider.Now()
+	}
+
+	reason := &StatusReason{
+		Code:      code,
+		Message:   message,
+		Timestamp: now,
+	}
+
+	p.statusMu.Lock()
 	oldStatus := p.Status
 	p.Status = status
+	p.StatusReason = reason
+	if !status.IsRunning() {
+		p.StoppedReason = reason
+	}
+	if code.IsError() {
+		p.LastError = reason
+	}
+	p.statusMu.Unlock()
 
 	if p.onStatusChange != nil {
-		p.onStatusChange(oldStatus, status)
+		p.onStatusChange(oldStatus, status, *reason)
 	}
 }
 
 func (p *Process) GetStatus() InstanceStatus {
+	p.statusMu.RLock()
+	defer p.statusMu.RUnlock()
 	return p.Status
 }
 
-// IsRunning returns true if the status is Running
+// GetStatusReason returns the reason for the most recent status transition
+func (p *Process) GetStatusReason() *StatusReason {
+	p.statusMu.RLock()
+	defer p.statusMu.RUnlock()
+	return p.StatusReason
+}
+
+// GetStoppedReason returns the reason the instance last left the running state
+func (p *Process) GetStoppedReason() *StatusReason {
+	p.statusMu.RLock()
+	defer p.statusMu.RUnlock()
+	return p.StoppedReason
+}
+
+// GetLastError returns the most recent abnormal termination, or nil if there was none
+func (p *Process) GetLastError() *StatusReason {
+	p.statusMu.RLock()
+	defer p.statusMu.RUnlock()
+	return p.LastError
+}
+
+// IsRunning returns true if the backend process runs, that is the status is Starting or Running
 func (p *Process) IsRunning() bool {
-	return p.Status == Running
+	return p.GetStatus().IsRunning()
+}
+
+// IsReady returns true if the backend serves traffic, that is the status is Running
+func (p *Process) IsReady() bool {
+	return p.GetStatus() == Running
+}
+
+// IsRunning reports whether a backend process runs in the status, ready or not
+func (s InstanceStatus) IsRunning() bool {
+	return s == Running || s == Starting
 }
 
 func (s InstanceStatus) MarshalJSON() ([]byte, error) {
@@ -52,6 +189,21 @@ func (s InstanceStatus) MarshalJSON() ([]byte, error) {
 	return json.Marshal(name)
 }
 
+// String returns the name of the status
+func (s InstanceStatus) String() string {
+	name, ok := statusToName[s]
+	if !ok {
+		return "stopped"
+	}
+	return name
+}
+
+// ParseStatus returns the status with the given name
+func ParseStatus(name string) (InstanceStatus, bool) {
+	status, ok := nameToStatus[name]
+	return status, ok
+}
+
 // UnmarshalJSON implements json.Unmarshaler
 func (s *InstanceStatus) UnmarshalJSON(data []byte) error {
 	var str string
@@ -0,0 +1,366 @@
+package instance_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedTransition struct {
+	oldStatus instance.InstanceStatus
+	newStatus instance.InstanceStatus
+	reason    instance.StatusReason
+}
+
+type transitionRecorder struct {
+	mu          sync.Mutex
+	transitions []recordedTransition
+	notify      chan struct{}
+}
+
+func newTransitionRecorder() *transitionRecorder {
+	return &transitionRecorder{notify: make(chan struct{}, 64)}
+}
+
+func (r *transitionRecorder) record(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {
+	r.mu.Lock()
+	r.transitions = append(r.transitions, recordedTransition{oldStatus, newStatus, reason})
+	r.mu.Unlock()
+	r.notify <- struct{}{}
+}
+
+func (r *transitionRecorder) snapshot() []recordedTransition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]recordedTransition(nil), r.transitions...)
+}
+
+// waitFor blocks until a transition into the given status has been recorded
+func (r *transitionRecorder) waitFor(t *testing.T, status instance.InstanceStatus) {
+	t.Helper()
+	deadline := time.After(10 * time.Second)
+	for {
+		for _, tr := range r.snapshot() {
+			if tr.newStatus == status {
+				return
+			}
+		}
+		select {
+		case <-r.notify:
+		case <-deadline:
+			t.Fatalf("Timed out waiting for status %s, got transitions %+v", status, r.snapshot())
+		}
+	}
+}
+
+func newShellInstance(t *testing.T, script string, autoRestart bool, onStatusChange instance.StatusChangeFunc) *instance.Process {
+	t.Helper()
+	return newShellInstanceWithSettings(t, script, autoRestart, &config.InstancesConfig{}, onStatusChange)
+}
+
+// newShellInstanceWithSettings is newShellInstance with the given instances settings, logging
+// to a temporary directory
+func newShellInstanceWithSettings(t *testing.T, script string, autoRestart bool, globalSettings *config.InstancesConfig, onStatusChange instance.StatusChangeFunc) *instance.Process {
+	t.Helper()
+	options := &instance.CreateInstanceOptions{
+		BackendType:  backends.BackendTypeLlamaCpp,
+		AutoRestart:  testutil.BoolPtr(autoRestart),
+		MaxRestarts:  testutil.IntPtr(1),
+		RestartDelay: testutil.DurationPtr(0),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Port:  8080,
+		},
+	}
+	return newScriptInstance(t, "test-instance", script, options, globalSettings, onStatusChange)
+}
+
+// newScriptInstance returns an instance named name whose backend runs script, with the given
+// options and instances settings, logging to a temporary directory
+func newScriptInstance(t *testing.T, name string, script string, options *instance.CreateInstanceOptions, globalSettings *config.InstancesConfig, onStatusChange instance.StatusChangeFunc) *instance.Process {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	backendConfig := &config.BackendConfig{
+		LlamaCpp: config.BackendSettings{
+			Command: "sh",
+			Args:    []string{"-c", script},
+		},
+	}
+	globalSettings.LogsDir = t.TempDir()
+	return instance.NewInstance(name, backendConfig, globalSettings, options, onStatusChange)
+}
+
+// serveBackend serves handler as the backend of a process and returns the port it listens on
+func serveBackend(t *testing.T, handler http.HandlerFunc) int {
+	t.Helper()
+	backend := httptest.NewServer(handler)
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+	return port
+}
+
+// newBackendInstance returns a llama.cpp instance with the given options and instances
+// settings whose process sleeps while handler answers for its backend. It is stopped when
+// the test ends.
+func newBackendInstance(t *testing.T, handler http.HandlerFunc, options *instance.CreateInstanceOptions, globalSettings *config.InstancesConfig, onStatusChange instance.StatusChangeFunc) *instance.Process {
+	t.Helper()
+	if options.LlamaServerOptions == nil {
+		options.LlamaServerOptions = &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"}
+	}
+	options.BackendType = backends.BackendTypeLlamaCpp
+	options.LlamaServerOptions.Host = "127.0.0.1"
+	options.LlamaServerOptions.Port = serveBackend(t, handler)
+
+	inst := newScriptInstance(t, "test-instance", "exec sleep 30", options, globalSettings, onStatusChange)
+	t.Cleanup(func() { inst.Stop() })
+	return inst
+}
+
+func TestReasonCodes_Stable(t *testing.T) {
+	expected := map[instance.ReasonCode]string{
+		instance.ReasonUserStart:           "user_start",
+		instance.ReasonUserStop:            "user_stop",
+		instance.ReasonAutoRestart:         "auto_restart",
+		instance.ReasonRestored:            "restored",
+		instance.ReasonCleanExit:           "clean_exit",
+		instance.ReasonCrash:               "crash",
+		instance.ReasonOOMKill:             "oom_kill",
+		instance.ReasonHealthProbeSuccess:  "health_probe_success",
+		instance.ReasonHealthProbeFailure:  "health_probe_failure",
+		instance.ReasonIdleTimeout:         "idle_timeout",
+		instance.ReasonSchedule:            "schedule",
+		instance.ReasonPreempted:           "preempted",
+		instance.ReasonMaxRestartsExceeded: "max_restarts_exceeded",
+		instance.ReasonShutdown:            "shutdown",
+		instance.ReasonModelDownload:       "model_download",
+		instance.ReasonFatalLog:            "fatal_log",
+		instance.ReasonStartQueued:         "start_queued",
+		instance.ReasonGPUFault:            "gpu_fault",
+		instance.ReasonCrashLoop:           "crash_loop",
+		instance.ReasonAdopted:             "adopted",
+		instance.ReasonReload:              "reload",
+	}
+
+	if len(instance.ReasonCodes) != len(expected) {
+		t.Errorf("Expected %d reason codes, got %d", len(expected), len(instance.ReasonCodes))
+	}
+	for _, code := range instance.ReasonCodes {
+		want, ok := expected[code]
+		if !ok {
+			t.Errorf("Unexpected reason code %q", code)
+			continue
+		}
+		if string(code) != want {
+			t.Errorf("Expected reason code %q, got %q", want, code)
+		}
+	}
+}
+
+func TestSetStatus_RecordsReason(t *testing.T) {
+	recorder := newTransitionRecorder()
+	inst := newShellInstance(t, "exit 0", false, recorder.record)
+
+	inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
+	inst.SetStatus(instance.Failed, instance.ReasonCrash, "exit status 1")
+
+	transitions := recorder.snapshot()
+	if len(transitions) != 2 {
+		t.Fatalf("Expected 2 transitions, got %d", len(transitions))
+	}
+	if transitions[0].reason.Code != instance.ReasonUserStart {
+		t.Errorf("Expected reason %q, got %q", instance.ReasonUserStart, transitions[0].reason.Code)
+	}
+	if transitions[1].oldStatus != instance.Running || transitions[1].newStatus != instance.Failed {
+		t.Errorf("Expected running -> failed, got %s -> %s", transitions[1].oldStatus, transitions[1].newStatus)
+	}
+
+	if inst.GetStatusReason() == nil || inst.GetStatusReason().Code != instance.ReasonCrash {
+		t.Errorf("Expected status reason %q, got %+v", instance.ReasonCrash, inst.GetStatusReason())
+	}
+	if inst.GetStoppedReason() == nil || inst.GetStoppedReason().Message != "exit status 1" {
+		t.Errorf("Expected stopped reason message 'exit status 1', got %+v", inst.GetStoppedReason())
+	}
+	if inst.GetLastError() == nil || inst.GetLastError().Code != instance.ReasonCrash {
+		t.Errorf("Expected last error %q, got %+v", instance.ReasonCrash, inst.GetLastError())
+	}
+}
+
+func TestLifecycle_ReasonCodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      string
+		autoRestart bool
+		stop        bool
+		waitFor     instance.InstanceStatus
+		expected    []instance.ReasonCode
+	}{
+		{
+			name:     "user start and stop",
+			script:   "exec sleep 30",
+			stop:     true,
+			expected: []instance.ReasonCode{instance.ReasonUserStart, instance.ReasonUserStop},
+		},
+		{
+			name:     "clean exit",
+			script:   "exit 0",
+			waitFor:  instance.Stopped,
+			expected: []instance.ReasonCode{instance.ReasonUserStart, instance.ReasonCleanExit},
+		},
+		{
+			name:     "killed by SIGKILL",
+			script:   "kill -9 $$",
+			waitFor:  instance.Failed,
+			expected: []instance.ReasonCode{instance.ReasonUserStart, instance.ReasonOOMKill, instance.ReasonOOMKill},
+		},
+		{
+			name:        "crash loop exceeds max restarts",
+			script:      "exit 1",
+			autoRestart: true,
+			waitFor:     instance.Failed,
+			expected: []instance.ReasonCode{
+				instance.ReasonUserStart,
+				instance.ReasonCrash,
+				instance.ReasonAutoRestart,
+				instance.ReasonCrash,
+				instance.ReasonMaxRestartsExceeded,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := newTransitionRecorder()
+			inst := newShellInstance(t, tt.script, tt.autoRestart, recorder.record)
+
+			if err := inst.Start(); err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+
+			if tt.stop {
+				recorder.waitFor(t, instance.Starting)
+				if err := inst.Stop(); err != nil {
+					t.Fatalf("Stop failed: %v", err)
+				}
+			} else {
+				recorder.waitFor(t, tt.waitFor)
+			}
+
+			transitions := recorder.snapshot()
+			if len(transitions) != len(tt.expected) {
+				t.Fatalf("Expected %d transitions, got %+v", len(tt.expected), transitions)
+			}
+			for i, tr := range transitions {
+				if tr.reason.Code == "" {
+					t.Errorf("Transition %d (%s -> %s) has no reason code", i, tr.oldStatus, tr.newStatus)
+				}
+				if tr.reason.Code != tt.expected[i] {
+					t.Errorf("Transition %d: expected reason %q, got %q", i, tt.expected[i], tr.reason.Code)
+				}
+				if tr.reason.Timestamp.IsZero() {
+					t.Errorf("Transition %d has no timestamp", i)
+				}
+			}
+		})
+	}
+}
+
+func TestLifecycle_States(t *testing.T) {
+	t.Run("starting until ready", func(t *testing.T) {
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer backend.Close()
+		backendURL, _ := url.Parse(backend.URL)
+		port, _ := strconv.Atoi(backendURL.Port())
+
+		recorder := newTransitionRecorder()
+		inst := newStartFailureInstance(t, "exec sleep 30", backendURL.Hostname(), port, recorder.record)
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		recorder.waitFor(t, instance.Running)
+
+		transitions := recorder.snapshot()
+		if transitions[0].newStatus != instance.Starting || transitions[0].reason.Code != instance.ReasonUserStart {
+			t.Errorf("Expected the start to be starting first, got %+v", transitions[0])
+		}
+		if transitions[1].reason.Code != instance.ReasonHealthProbeSuccess {
+			t.Errorf("Expected the readiness check to make it running, got %+v", transitions[1])
+		}
+	})
+
+	t.Run("stop while starting", func(t *testing.T) {
+		inst := newStartFailureInstance(t, "exec sleep 30", "127.0.0.1", closedPort(t), nil)
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if inst.GetStatus() != instance.Starting || !inst.IsRunning() {
+			t.Fatalf("Expected a running process that is starting, got %s", inst.GetStatus())
+		}
+
+		waited := make(chan error, 1)
+		go func() { waited <- inst.WaitForHealthy(0) }()
+		time.Sleep(200 * time.Millisecond) // Let the wait begin
+		if err := inst.Stop(); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		select {
+		case err := <-waited:
+			if err == nil || !strings.Contains(err.Error(), "stopped while starting") {
+				t.Errorf("Expected the wait to end with the stop, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for WaitForHealthy to return")
+		}
+		if inst.GetStatus() != instance.Stopped {
+			t.Errorf("Expected the instance to be stopped, got %s", inst.GetStatus())
+		}
+	})
+
+	t.Run("restarting until stopped", func(t *testing.T) {
+		recorder := newTransitionRecorder()
+		inst := newShellInstance(t, "exit 1", true, recorder.record)
+		options := inst.GetOptions()
+		options.RestartDelay = testutil.DurationPtr(time.Minute)
+		inst.SetOptions(options)
+
+		if err := inst.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		recorder.waitFor(t, instance.Restarting)
+		if inst.IsRunning() || inst.GetStatusReason().Code != instance.ReasonCrash {
+			t.Errorf("Expected a crashed instance waiting for its restart, got %s %+v", inst.GetStatus(), inst.GetStatusReason())
+		}
+		data, _ := json.Marshal(inst)
+		if !strings.Contains(string(data), `"status":"restarting"`) {
+			t.Errorf("Expected the restarting status in the JSON, got %s", data)
+		}
+
+		// Stopping cancels the pending restart
+		if err := inst.Stop(); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		if inst.GetStatus() != instance.Stopped || inst.GetStatusReason().Code != instance.ReasonUserStop {
+			t.Errorf("Expected the instance to be stopped by the user, got %s %+v", inst.GetStatus(), inst.GetStatusReason())
+		}
+		for _, tr := range recorder.snapshot() {
+			if tr.newStatus == instance.Stopped && tr.reason.Code == instance.ReasonCrash {
+				t.Errorf("Expected the crash not to look like a stop, got %+v", tr)
+			}
+		}
+	})
+}
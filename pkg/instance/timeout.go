@@ -1,5 +1,16 @@
 package instance
 
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// idleExemptPaths are the backend paths polled by health checks and metrics scrapers, whose
+// requests do not keep an instance from timing out
+var idleExemptPaths = []string{"/health", "/healthz", "/v1/health", "/metrics"}
+
 // UpdateLastRequestTime updates the last request access time for the instance via proxy
 func (i *Process) UpdateLastRequestTime() {
 	i.mu.Lock()
@@ -7,22 +18,56 @@ func (i *Process) UpdateLastRequestTime() {
 
 	lastRequestTime := i.timeProvider.Now().Unix()
 	i.lastRequestTime.Store(lastRequestTime)
+	i.lastRequestAt.Store(lastRequestTime)
+}
+
+// RecordRequest updates the last request time for a request proxied to the instance, unless
+// it is a probe or a health check, which are not activity
+func (i *Process) RecordRequest(r *http.Request) {
+	if !CountsAsActivity(r) {
+		return
+	}
+	i.UpdateLastRequestTime()
+}
+
+// CountsAsActivity reports whether a request proxied to the backend keeps the instance from
+// timing out: requests marked with ProbeHeader and requests to health check and metrics
+// paths do not
+func CountsAsActivity(r *http.Request) bool {
+	if r.Header.Get(ProbeHeader) != "" {
+		return false
+	}
+	return !slices.Contains(idleExemptPaths, strings.TrimSuffix(r.URL.Path, "/"))
+}
+
+// LastRequestAt returns when the last request was proxied to the instance, the zero time
+// if none was since llamactl started
+func (i *Process) LastRequestAt() time.Time {
+	if at := i.lastRequestAt.Load(); at != 0 {
+		return time.Unix(at, 0)
+	}
+	return time.Time{}
+}
+
+// IdleFor returns how long the instance has gone without requests since it started
+func (i *Process) IdleFor() time.Duration {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.timeProvider.Now().Sub(time.Unix(i.lastRequestTime.Load(), 0))
 }
 
 func (i *Process) ShouldTimeout() bool {
 	i.mu.RLock()
 	defer i.mu.RUnlock()
 
-	if !i.IsRunning() || i.options.IdleTimeout == nil || *i.options.IdleTimeout <= 0 {
+	if !i.IsRunning() || !i.options.IsManaged() || i.options.IdleTimeout == nil || *i.options.IdleTimeout <= 0 {
 		return false
 	}
 
 	// Check if the last request time exceeds the idle timeout
 	lastRequest := i.lastRequestTime.Load()
-	idleTimeoutMinutes := *i.options.IdleTimeout
-
-	// Convert timeout from minutes to seconds for comparison
-	idleTimeoutSeconds := int64(idleTimeoutMinutes * 60)
+	idleTimeoutSeconds := int64(i.options.IdleTimeout.Duration() / time.Second)
 
 	return (i.timeProvider.Now().Unix() - lastRequest) > idleTimeoutSeconds
 }
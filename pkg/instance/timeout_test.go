@@ -1,11 +1,14 @@
 package instance_test
 
 import (
+	"encoding/json"
 	"llamactl/pkg/backends"
 	"llamactl/pkg/backends/llamacpp"
 	"llamactl/pkg/config"
 	"llamactl/pkg/instance"
 	"llamactl/pkg/testutil"
+	"net/http"
+	"net/http/httptest"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -54,7 +57,7 @@ func TestUpdateLastRequestTime(t *testing.T) {
 	}
 
 	// Mock onStatusChange function
-	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
 
@@ -76,7 +79,7 @@ func TestShouldTimeout_NotRunning(t *testing.T) {
 		LogsDir: "/tmp/test",
 	}
 
-	idleTimeout := 1 // 1 minute
+	idleTimeout := config.Duration(time.Minute)
 	options := &instance.CreateInstanceOptions{
 		IdleTimeout: &idleTimeout,
 		BackendType: backends.BackendTypeLlamaCpp,
@@ -86,7 +89,7 @@ func TestShouldTimeout_NotRunning(t *testing.T) {
 	}
 
 	// Mock onStatusChange function
-	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
 
@@ -112,17 +115,17 @@ func TestShouldTimeout_NoTimeoutConfigured(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		idleTimeout *int
+		idleTimeout *config.Duration
 	}{
 		{"nil timeout", nil},
-		{"zero timeout", testutil.IntPtr(0)},
-		{"negative timeout", testutil.IntPtr(-5)},
+		{"zero timeout", testutil.DurationPtr(0)},
+		{"negative timeout", testutil.DurationPtr(-5 * time.Minute)},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Mock onStatusChange function
-			mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+			mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 			options := &instance.CreateInstanceOptions{
 				IdleTimeout: tt.idleTimeout,
@@ -134,7 +137,7 @@ func TestShouldTimeout_NoTimeoutConfigured(t *testing.T) {
 
 			inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
 			// Simulate running state
-			inst.SetStatus(instance.Running)
+			inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
 
 			if inst.ShouldTimeout() {
 				t.Errorf("Instance with %s should not timeout", tt.name)
@@ -157,7 +160,7 @@ func TestShouldTimeout_WithinTimeLimit(t *testing.T) {
 		LogsDir: "/tmp/test",
 	}
 
-	idleTimeout := 5 // 5 minutes
+	idleTimeout := config.Duration(5 * time.Minute)
 	options := &instance.CreateInstanceOptions{
 		IdleTimeout: &idleTimeout,
 		BackendType: backends.BackendTypeLlamaCpp,
@@ -167,10 +170,10 @@ func TestShouldTimeout_WithinTimeLimit(t *testing.T) {
 	}
 
 	// Mock onStatusChange function
-	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
-	inst.SetStatus(instance.Running)
+	inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
 
 	// Update last request time to now
 	inst.UpdateLastRequestTime()
@@ -195,7 +198,7 @@ func TestShouldTimeout_ExceedsTimeLimit(t *testing.T) {
 		LogsDir: "/tmp/test",
 	}
 
-	idleTimeout := 1 // 1 minute
+	idleTimeout := config.Duration(time.Minute)
 	options := &instance.CreateInstanceOptions{
 		IdleTimeout: &idleTimeout,
 		BackendType: backends.BackendTypeLlamaCpp,
@@ -205,10 +208,10 @@ func TestShouldTimeout_ExceedsTimeLimit(t *testing.T) {
 	}
 
 	// Mock onStatusChange function
-	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+	mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 	inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
-	inst.SetStatus(instance.Running)
+	inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
 
 	// Use MockTimeProvider to simulate old last request time
 	mockTime := NewMockTimeProvider(time.Now())
@@ -241,13 +244,13 @@ func TestTimeoutConfiguration_Validation(t *testing.T) {
 
 	tests := []struct {
 		name            string
-		inputTimeout    *int
-		expectedTimeout int
+		inputTimeout    *config.Duration
+		expectedTimeout config.Duration
 	}{
 		{"default value when nil", nil, 0},
-		{"positive value", testutil.IntPtr(10), 10},
-		{"zero value", testutil.IntPtr(0), 0},
-		{"negative value gets corrected", testutil.IntPtr(-5), 0},
+		{"positive value", testutil.DurationPtr(10 * time.Minute), config.Duration(10 * time.Minute)},
+		{"zero value", testutil.DurationPtr(0), 0},
+		{"negative value gets corrected", testutil.DurationPtr(-5 * time.Minute), 0},
 	}
 
 	for _, tt := range tests {
@@ -261,14 +264,69 @@ func TestTimeoutConfiguration_Validation(t *testing.T) {
 			}
 
 			// Mock onStatusChange function
-			mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus) {}
+			mockOnStatusChange := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {}
 
 			inst := instance.NewInstance("test-instance", backendConfig, globalSettings, options, mockOnStatusChange)
 			opts := inst.GetOptions()
 
 			if opts.IdleTimeout == nil || *opts.IdleTimeout != tt.expectedTimeout {
-				t.Errorf("Expected IdleTimeout %d, got %v", tt.expectedTimeout, opts.IdleTimeout)
+				t.Errorf("Expected IdleTimeout %s, got %v", tt.expectedTimeout, opts.IdleTimeout)
 			}
 		})
 	}
 }
+
+func TestRecordRequest_IgnoresHealthChecks(t *testing.T) {
+	idleTimeout := config.Duration(time.Minute)
+	options := &instance.CreateInstanceOptions{
+		IdleTimeout: &idleTimeout,
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+		},
+	}
+	inst := instance.NewInstance("test-instance", &config.BackendConfig{}, &config.InstancesConfig{LogsDir: t.TempDir()}, options, nil)
+	inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
+
+	start := time.Now()
+	mockTime := NewMockTimeProvider(start)
+	inst.SetTimeProvider(mockTime)
+	inst.UpdateLastRequestTime()
+
+	// Health checks, metrics scrapes and probes past the timeout
+	mockTime.SetTime(start.Add(2 * time.Minute))
+	probe := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	probe.Header.Set(instance.ProbeHeader, "1")
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/health", nil),
+		httptest.NewRequest(http.MethodGet, "/metrics", nil),
+		probe,
+	} {
+		inst.RecordRequest(r)
+	}
+	if !inst.ShouldTimeout() {
+		t.Error("Expected health checks and probes not to count as activity")
+	}
+	if !inst.LastRequestAt().Equal(time.Unix(start.Unix(), 0)) {
+		t.Errorf("Expected the last request at %v, got %v", start, inst.LastRequestAt())
+	}
+
+	inst.RecordRequest(httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	if inst.ShouldTimeout() {
+		t.Error("Expected a completion request to count as activity")
+	}
+
+	data, err := json.Marshal(inst)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var status struct {
+		LastRequestAt *time.Time `json:"last_request_at"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if status.LastRequestAt == nil || !status.LastRequestAt.Equal(time.Unix(start.Add(2*time.Minute).Unix(), 0)) {
+		t.Errorf("Expected last_request_at in the status, got %s", data)
+	}
+}
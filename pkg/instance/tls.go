@@ -0,0 +1,160 @@
+package instance
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// BackendTLSOptions configures how llamactl connects to an instance's backend over TLS
+type BackendTLSOptions struct {
+	Scheme             string `json:"scheme,omitempty"`               // "http" or "https" (default: "https" when TLS options are set)
+	CAFile             string `json:"ca_file,omitempty"`              // PEM bundle used instead of the system roots
+	CertFile           string `json:"cert_file,omitempty"`            // Client certificate for mutual TLS
+	KeyFile            string `json:"key_file,omitempty"`             // Client key for mutual TLS
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // Requires allow_insecure_backends
+}
+
+// BackendScheme returns the URL scheme used to reach the backend
+func (o *BackendTLSOptions) BackendScheme() string {
+	if o == nil {
+		return "http"
+	}
+	if o.Scheme == "" {
+		return "https"
+	}
+	return o.Scheme
+}
+
+// backendTLS builds TLS configurations whose CA bundle and client certificate are
+// reloaded when the files on disk change, so certificates can be rotated without
+// recreating the instance.
+type backendTLS struct {
+	opts BackendTLSOptions
+
+	mu        sync.Mutex
+	caModTime time.Time
+	caPool    *x509.CertPool
+
+	certModTime time.Time
+	keyModTime  time.Time
+	cert        *tls.Certificate
+}
+
+func newBackendTLS(opts BackendTLSOptions) *backendTLS {
+	return &backendTLS{opts: opts}
+}
+
+// newBackendTransport returns an HTTP transport for the backend, with TLS configured if requested
+func newBackendTransport(opts *BackendTLSOptions) http.RoundTripper {
+	if opts == nil || opts.BackendScheme() != "https" {
+		return http.DefaultTransport
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = newBackendTLS(*opts).clientConfig()
+	return transport
+}
+
+func (b *backendTLS) clientConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if b.opts.CertFile != "" && b.opts.KeyFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return b.clientCertificate()
+		}
+	}
+
+	switch {
+	case b.opts.InsecureSkipVerify:
+		cfg.InsecureSkipVerify = true
+	case b.opts.CAFile != "":
+		// Verification is done in VerifyConnection so the CA bundle can be reloaded
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = b.verifyConnection
+	}
+
+	return cfg
+}
+
+func (b *backendTLS) verifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("backend presented no certificates")
+	}
+
+	pool, err := b.rootCAs()
+	if err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err = cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         pool,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// rootCAs returns the CA pool, reloading the bundle if it changed on disk
+func (b *backendTLS) rootCAs() (*x509.CertPool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	info, err := os.Stat(b.opts.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat CA file: %w", err)
+	}
+	if b.caPool != nil && info.ModTime().Equal(b.caModTime) {
+		return b.caPool, nil
+	}
+
+	data, err := os.ReadFile(b.opts.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", b.opts.CAFile)
+	}
+
+	b.caPool = pool
+	b.caModTime = info.ModTime()
+	return pool, nil
+}
+
+// clientCertificate returns the client certificate, reloading it if the files changed on disk
+func (b *backendTLS) clientCertificate() (*tls.Certificate, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	certInfo, err := os.Stat(b.opts.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat client certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(b.opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat client key: %w", err)
+	}
+	if b.cert != nil && certInfo.ModTime().Equal(b.certModTime) && keyInfo.ModTime().Equal(b.keyModTime) {
+		return b.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(b.opts.CertFile, b.opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+
+	b.cert = &cert
+	b.certModTime = certInfo.ModTime()
+	b.keyModTime = keyInfo.ModTime()
+	return b.cert, nil
+}
@@ -0,0 +1,142 @@
+package instance_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// writeCA writes a DER certificate as a PEM bundle with the given modification time
+func writeCA(t *testing.T, der []byte, path string, modTime time.Time) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set CA file time: %v", err)
+	}
+}
+
+// generateCA creates an unrelated self-signed CA certificate
+func generateCA(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "unrelated test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return der
+}
+
+func newTLSTestInstance(t testing.TB, server *httptest.Server, tlsOptions *instance.BackendTLSOptions) *instance.Process {
+	t.Helper()
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse server URL: %v", err)
+	}
+	port, _ := strconv.Atoi(serverURL.Port())
+
+	options := &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		BackendTLS:  tlsOptions,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  serverURL.Hostname(),
+			Port:  port,
+		},
+	}
+	backendConfig := &config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "llama-server"}}
+	globalSettings := &config.InstancesConfig{LogsDir: t.TempDir()}
+
+	return instance.NewInstance("tls-instance", backendConfig, globalSettings, options, func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {})
+}
+
+func proxyStatus(t *testing.T, inst *instance.Process) int {
+	t.Helper()
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	proxy.ServeHTTP(recorder, httptest.NewRequest("GET", "/health", nil))
+	return recorder.Code
+}
+
+func TestBackendTLS_CustomCA(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	past := time.Now().Add(-time.Hour)
+
+	// Start with a CA bundle that does not match the backend certificate
+	writeCA(t, generateCA(t), caFile, past)
+	inst := newTLSTestInstance(t, backend, &instance.BackendTLSOptions{CAFile: caFile})
+
+	if code := proxyStatus(t, inst); code != http.StatusBadGateway {
+		t.Errorf("Expected 502 with an untrusted backend certificate, got %d", code)
+	}
+
+	// Rotating the CA bundle on disk is picked up without recreating the instance
+	writeCA(t, backend.Certificate().Raw, caFile, time.Now())
+	if code := proxyStatus(t, inst); code != http.StatusOK {
+		t.Errorf("Expected 200 after the CA bundle was updated, got %d", code)
+	}
+}
+
+func TestBackendTLS_SchemeAndInsecure(t *testing.T) {
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	tests := []struct {
+		name     string
+		tls      *instance.BackendTLSOptions
+		expected int
+	}{
+		{"plain http to TLS backend", nil, http.StatusBadRequest},
+		{"https with system roots", &instance.BackendTLSOptions{Scheme: "https"}, http.StatusBadGateway},
+		{"https skipping verification", &instance.BackendTLSOptions{InsecureSkipVerify: true}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inst := newTLSTestInstance(t, backend, tt.tls)
+			if code := proxyStatus(t, inst); code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package instance
+
+import (
+	"llamactl/pkg/config"
+	"time"
+)
+
+// Failure modes of a transform
+const (
+	TransformFailOpen   = "open"   // Forward the request or response unchanged
+	TransformFailClosed = "closed" // Reject the request, or replace the response with an error
+)
+
+const (
+	defaultTransformTimeout = 100 * time.Millisecond
+	// MaxTransformTimeout caps the time budget of a transform
+	MaxTransformTimeout = 10 * time.Second
+)
+
+// TransformOptions select a transform rewriting the OpenAI requests proxied to an instance
+// and their non-streamed responses
+type TransformOptions struct {
+	Name     string          `json:"name"`                // Name the transform is registered under
+	Params   map[string]any  `json:"params,omitempty"`    // Passed to the transform
+	Timeout  config.Duration `json:"timeout,omitempty"`   // Time budget of each call (default: 100ms)
+	FailMode string          `json:"fail_mode,omitempty"` // "open" (default) or "closed"
+}
+
+// Budget returns the time budget of each call of the transform
+func (o *TransformOptions) Budget() time.Duration {
+	if o.Timeout <= 0 {
+		return defaultTransformTimeout
+	}
+	return min(o.Timeout.Duration(), MaxTransformTimeout)
+}
+
+// FailsClosed reports whether a failed transform rejects the request or response
+func (o *TransformOptions) FailsClosed() bool {
+	return o.FailMode == TransformFailClosed
+}
@@ -0,0 +1,162 @@
+package manager
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"syscall"
+	"time"
+)
+
+// actorManager makes the changes of an authenticated caller, recording its identity in the
+// audit log, and waits for the lifecycle operations in progress as the caller asked. Reads go
+// straight to the underlying manager.
+type actorManager struct {
+	*instanceManager
+	actor      string
+	operations OperationOptions
+}
+
+// WithActor returns a view of the manager whose changes are recorded in the audit log as
+// made by actor
+func (im *instanceManager) WithActor(actor string) InstanceManager {
+	return &actorManager{instanceManager: im, actor: actor}
+}
+
+// WithOperationOptions returns a view of the manager whose starts, stops, restarts and
+// reloads wait for the operations in progress on the instance as opts tell
+func (im *instanceManager) WithOperationOptions(opts OperationOptions) InstanceManager {
+	return &actorManager{instanceManager: im, operations: opts}
+}
+
+func (am *actorManager) WithOperationOptions(opts OperationOptions) InstanceManager {
+	return &actorManager{instanceManager: am.instanceManager, actor: am.actor, operations: opts}
+}
+
+func (am *actorManager) CreateInstance(name string, options *instance.CreateInstanceOptions) (*instance.Process, error) {
+	am.waitRestored(name)
+	return am.createInstance(name, options, am.actor)
+}
+
+func (am *actorManager) UpdateInstance(name string, options *instance.CreateInstanceOptions) (*instance.Process, error) {
+	am.waitRestored(name)
+	if err := am.checkConfigUpdate(name); err != nil {
+		return nil, err
+	}
+	return am.updateInstance(name, options, am.actor, false)
+}
+
+func (am *actorManager) ApplyPendingOptions(name string) (*instance.Process, error) {
+	return am.applyPendingOptions(name, am.actor, am.operations)
+}
+
+func (am *actorManager) DeleteInstance(name string) error {
+	am.waitRestored(name)
+	return am.deleteInstance(name, DeleteOptions{}, am.actor)
+}
+
+func (am *actorManager) DeleteInstanceWithOptions(name string, opts DeleteOptions) error {
+	am.waitRestored(name)
+	return am.deleteInstance(name, opts, am.actor)
+}
+
+func (am *actorManager) RestoreInstance(name string) (*instance.Process, error) {
+	return am.restoreInstance(name, am.actor)
+}
+
+func (am *actorManager) PurgeInstance(name string) error {
+	return am.purgeInstance(name, am.actor)
+}
+
+func (am *actorManager) StartInstance(name string) (*instance.Process, error) {
+	return am.startInstance(name, am.actor, am.operations)
+}
+
+func (am *actorManager) StopInstance(name string) (*instance.Process, error) {
+	return am.stopInstance(name, instance.ReasonUserStop, "", am.actor, am.operations)
+}
+
+func (am *actorManager) CancelStart(name string) (*instance.Process, error) {
+	return am.cancelStart(name, am.actor)
+}
+
+func (am *actorManager) SignalInstance(name string, sig syscall.Signal) (*instance.Process, error) {
+	return am.signalInstance(name, sig, am.actor)
+}
+
+func (am *actorManager) RestartInstance(name string) (*instance.Process, error) {
+	return am.restartInstance(name, am.actor, am.operations)
+}
+
+func (am *actorManager) RestartInstanceIfBinaryChanged(name string) (*BinaryRestart, error) {
+	return am.restartInstanceIfBinaryChanged(name, am.actor, am.operations)
+}
+
+func (am *actorManager) ReloadInstance(name string) (*instance.Process, error) {
+	return am.reloadInstance(name, am.actor, am.operations)
+}
+
+func (am *actorManager) RetryInstance(name string) (*instance.Process, error) {
+	return am.retryInstance(name, am.actor, am.operations)
+}
+
+func (am *actorManager) UpdateSLO(name string, objectives *instance.SLOOptions) (*instance.SLOStatus, error) {
+	return am.updateSLO(name, objectives, am.actor)
+}
+
+func (am *actorManager) UpdateAllowedPaths(name string, paths []string) (*instance.Process, error) {
+	return am.updateAllowedPaths(name, paths, am.actor)
+}
+
+func (am *actorManager) SetPromptTemplate(name, template, text string) error {
+	return am.setPromptTemplate(name, template, text, am.actor)
+}
+
+func (am *actorManager) DeletePromptTemplate(name, template string) error {
+	return am.deletePromptTemplate(name, template, am.actor)
+}
+
+func (am *actorManager) PauseAutoRestart(name string, duration time.Duration) (*instance.Process, error) {
+	return am.pauseAutoRestart(name, duration, am.actor)
+}
+
+func (am *actorManager) ResumeAutoRestart(name string) (*instance.Process, error) {
+	return am.resumeAutoRestart(name, am.actor)
+}
+
+func (am *actorManager) PauseAllAutoRestarts(duration time.Duration) (*instance.RestartPause, error) {
+	return am.pauseAllAutoRestarts(duration, am.actor)
+}
+
+func (am *actorManager) ResumeAllAutoRestarts() error {
+	return am.resumeAllAutoRestarts(am.actor)
+}
+
+func (am *actorManager) StartRollingRestart(selector string, names []string, opts RollingRestartOptions) (*RollingRestartStatus, error) {
+	return am.startRollingRestart(selector, names, opts, am.actor)
+}
+
+func (am *actorManager) ApplyFleet(fleet *Fleet, opts ApplyOptions) (*FleetPlan, error) {
+	am.waitAllRestored()
+	return am.applyFleet(fleet, opts, am.actor)
+}
+
+func (am *actorManager) Failback(alias string) (*StandbyStatus, error) {
+	return am.failback(alias, am.actor)
+}
+
+func (am *actorManager) SetRoutes(alias string, rules RouteRules) (*RouteStatus, error) {
+	return am.setRoutes(alias, rules, am.actor)
+}
+
+func (am *actorManager) ResetRoutes(alias string) error {
+	return am.resetRoutes(alias, am.actor)
+}
+
+func (am *actorManager) UpdateSettings(patch map[string]json.RawMessage) (config.InstancesConfig, error) {
+	return am.updateSettings(patch, am.actor)
+}
+
+func (am *actorManager) RotateBackendKey(name string) (*instance.Process, error) {
+	return am.rotateBackendKey(name, am.actor)
+}
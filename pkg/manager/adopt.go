@@ -0,0 +1,34 @@
+package manager
+
+import (
+	"llamactl/pkg/instance"
+	"log"
+)
+
+// adoptProcesses attaches the instances to the backend processes a previous llamactl left
+// running with leave_running instead of starting them again, and returns the names of the
+// adopted instances. An instance whose process cannot be adopted is started as usual.
+func (im *instanceManager) adoptProcesses() map[string]bool {
+	im.mu.RLock()
+	managed := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		if inst.IsManaged() {
+			managed = append(managed, inst)
+		}
+	}
+	im.mu.RUnlock()
+
+	adopted := make(map[string]bool)
+	for _, inst := range managed {
+		ok, err := inst.Adopt()
+		if err != nil {
+			log.Printf("Not adopting the process left running by instance %s: %v", inst.Name, err)
+			continue
+		}
+		if ok {
+			log.Printf("Adopted the process left running by instance %s", inst.Name)
+			adopted[inst.Name] = true
+		}
+	}
+	return adopted
+}
@@ -0,0 +1,146 @@
+package manager_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"llamactl/pkg/testutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newLeaveRunningManager returns a manager leaving its backends running on shutdown, and a
+// function creating the next one on the same directories. The backend prints its pid, then
+// "said" whenever the file say appears in dir.
+func newLeaveRunningManager(t *testing.T) (manager.InstanceManager, func() manager.InstanceManager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	say := filepath.Join(dir, "say")
+	script := "trap 'exit 0' TERM; echo up $$; while :; do if [ -f " + say + " ]; then rm " + say + "; echo said; fi; sleep 0.1; done"
+	backendConfig := config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", script}}}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         filepath.Join(dir, "instances"),
+		LogsDir:              filepath.Join(dir, "logs"),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+		LeaveRunning:         true,
+	}
+	next := func() manager.InstanceManager {
+		mngr := manager.NewInstanceManagerWithStore(backendConfig, cfg, storage.NewFileStore(cfg.InstancesDir, dir))
+		t.Cleanup(mngr.Shutdown)
+		return mngr
+	}
+	return next(), next, dir
+}
+
+// waitForLogs waits for the logs of the instance to match pattern and returns the match
+func waitForLogs(t *testing.T, inst *instance.Process, pattern string) []string {
+	t.Helper()
+	re := regexp.MustCompile(pattern)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		logs, _ := inst.GetLogs(0)
+		if match := re.FindStringSubmatch(logs); match != nil {
+			return match
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the logs of instance %s to match %q, got %q", inst.Name, pattern, logs)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// startLeftRunning starts an instance of mngr, shuts mngr down and returns the pid of the
+// backend it left running
+func startLeftRunning(t *testing.T, mngr manager.InstanceManager) int {
+	t.Helper()
+	if _, err := mngr.CreateInstance("kept", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(true),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	inst, err := mngr.StartInstance("kept")
+	if err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	pid, _ := strconv.Atoi(waitForLogs(t, inst, `up (\d+)`)[1])
+	t.Cleanup(func() { syscall.Kill(-pid, syscall.SIGKILL) })
+
+	mngr.Shutdown()
+	if err := syscall.Kill(pid, 0); err != nil {
+		t.Fatalf("Expected the backend to be left running on shutdown: %v", err)
+	}
+	return pid
+}
+
+func TestLeaveRunning_Adopt(t *testing.T) {
+	mngr, next, dir := newLeaveRunningManager(t)
+	pid := startLeftRunning(t, mngr)
+
+	// Output written while llamactl is down is logged once the process is adopted
+	if err := os.WriteFile(filepath.Join(dir, "say"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mngr = next()
+	inst, err := mngr.GetInstance("kept")
+	if err != nil {
+		t.Fatalf("GetInstance failed: %v", err)
+	}
+	if adopted := waitForLogs(t, inst, `adopted process (\d+)`)[1]; adopted != strconv.Itoa(pid) {
+		t.Errorf("Expected process %d to be adopted, got %s", pid, adopted)
+	}
+	if !inst.IsRunning() {
+		t.Error("Expected the adopted instance to run")
+	}
+	waitForLogs(t, inst, `said`)
+	logs, _ := inst.GetLogs(0)
+	if spawned := strings.Count(logs, "up "); spawned != 1 {
+		t.Errorf("Expected the backend to be spawned and its output logged once, got %d times in %q", spawned, logs)
+	}
+
+	// The adopted process is stopped like one spawned by this llamactl
+	if _, err := mngr.StopInstance("kept"); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+	if err := syscall.Kill(pid, 0); err == nil {
+		t.Error("Expected the adopted process to be stopped")
+	}
+}
+
+func TestLeaveRunning_DeadProcessIsStartedAgain(t *testing.T) {
+	mngr, next, _ := newLeaveRunningManager(t)
+	pid := startLeftRunning(t, mngr)
+
+	syscall.Kill(-pid, syscall.SIGKILL)
+	deadline := time.Now().Add(5 * time.Second)
+	for syscall.Kill(pid, 0) == nil && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	mngr = next()
+	inst, err := mngr.GetInstance("kept")
+	if err != nil {
+		t.Fatalf("GetInstance failed: %v", err)
+	}
+	restarted := waitForLogs(t, inst, `(?s)up \d+.*up (\d+)`)[1]
+	if restarted == strconv.Itoa(pid) {
+		t.Errorf("Expected a new process, got process %d again", pid)
+	}
+	if _, err := mngr.StopInstance("kept"); err != nil {
+		t.Errorf("StopInstance failed: %v", err)
+	}
+}
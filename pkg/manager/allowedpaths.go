@@ -0,0 +1,42 @@
+package manager
+
+import (
+	"fmt"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/validation"
+	"strings"
+)
+
+// UpdateAllowedPaths replaces the paths the proxy forwards to the backend of an instance,
+// without restarting it, and returns the instance. No paths allow every path.
+func (im *instanceManager) UpdateAllowedPaths(name string, paths []string) (*instance.Process, error) {
+	return im.updateAllowedPaths(name, paths, "")
+}
+
+// updateAllowedPaths is UpdateAllowedPaths on behalf of actor
+func (im *instanceManager) updateAllowedPaths(name string, paths []string, actor string) (*instance.Process, error) {
+	if err := im.checkConfigUpdate(name); err != nil {
+		return nil, err
+	}
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.ValidateAllowedPaths(&instance.CreateInstanceOptions{AllowedPaths: paths}); err != nil {
+		return nil, err
+	}
+
+	if err := inst.SetAllowedPaths(paths); err != nil {
+		return nil, err
+	}
+
+	im.mu.Lock()
+	err = im.persistInstance(inst)
+	im.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist instance %s: %w", name, err)
+	}
+	im.recordAudit(actor, "update_allowed_paths", name, strings.Join(paths, ","))
+
+	return inst, nil
+}
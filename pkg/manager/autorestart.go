@@ -0,0 +1,288 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/storage"
+	"log"
+	"time"
+)
+
+// globalPauseKey is the key of the global pause of the automatic restarts in the store, and
+// the target of its audit entries
+const globalPauseKey = "all"
+
+// Codes of the autorestart events
+const (
+	AutoRestartPaused  = "paused"
+	AutoRestartResumed = "resumed"
+	AutoRestartExpired = "expired" // The pause ran out and restarts resumed by themselves
+)
+
+// PauseAutoRestart pauses the automatic restarts of an instance, for duration or until
+// resumed when duration is 0, without stopping it. If it crashes meanwhile it stays down.
+func (im *instanceManager) PauseAutoRestart(name string, duration time.Duration) (*instance.Process, error) {
+	return im.pauseAutoRestart(name, duration, "")
+}
+
+// pauseAutoRestart is PauseAutoRestart on behalf of actor
+func (im *instanceManager) pauseAutoRestart(name string, duration time.Duration, actor string) (*instance.Process, error) {
+	if duration < 0 {
+		return nil, fmt.Errorf("pause duration cannot be negative")
+	}
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	if !inst.IsManaged() {
+		return nil, fmt.Errorf("cannot pause the automatic restarts of instance %s: %w", name, instance.ErrUnmanaged)
+	}
+
+	pause := instance.NewRestartPause(time.Now(), duration)
+	if err := im.setRestartPause(inst, pause); err != nil {
+		return nil, err
+	}
+	im.recordAudit(actor, "pause_autorestart", name, pauseDetails(pause))
+	im.publishRestartPause(name, AutoRestartPaused, pause)
+	return inst, nil
+}
+
+// ResumeAutoRestart lifts the pause of the automatic restarts of an instance. Restarts stay
+// paused while they are paused globally.
+func (im *instanceManager) ResumeAutoRestart(name string) (*instance.Process, error) {
+	return im.resumeAutoRestart(name, "")
+}
+
+// resumeAutoRestart is ResumeAutoRestart on behalf of actor
+func (im *instanceManager) resumeAutoRestart(name, actor string) (*instance.Process, error) {
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	pause := inst.GetRestartPause()
+	if pause == nil {
+		return inst, nil
+	}
+
+	if err := im.setRestartPause(inst, nil); err != nil {
+		return nil, err
+	}
+	im.recordAudit(actor, "resume_autorestart", name, "")
+	im.publishRestartPause(name, AutoRestartResumed, pause)
+	return inst, nil
+}
+
+// setRestartPause replaces the pause of an instance and schedules its expiry
+func (im *instanceManager) setRestartPause(inst *instance.Process, pause *instance.RestartPause) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	inst.SetRestartPause(pause)
+	im.schedulePauseExpiry(inst.Name, pause)
+	if err := im.persistInstance(inst); err != nil {
+		return fmt.Errorf("failed to persist instance %s: %w", inst.Name, err)
+	}
+	return nil
+}
+
+// GetGlobalRestartPause returns the pause of the automatic restarts of every instance, nil
+// when they are not paused globally
+func (im *instanceManager) GetGlobalRestartPause() *instance.RestartPause {
+	if pause := im.restartPause.Get(); pause.Active(time.Now()) {
+		return pause
+	}
+	return nil
+}
+
+// PauseAllAutoRestarts pauses the automatic restarts of every instance, for duration or
+// until resumed when duration is 0, on top of the pauses of the instances
+func (im *instanceManager) PauseAllAutoRestarts(duration time.Duration) (*instance.RestartPause, error) {
+	return im.pauseAllAutoRestarts(duration, "")
+}
+
+// pauseAllAutoRestarts is PauseAllAutoRestarts on behalf of actor
+func (im *instanceManager) pauseAllAutoRestarts(duration time.Duration, actor string) (*instance.RestartPause, error) {
+	if duration < 0 {
+		return nil, fmt.Errorf("pause duration cannot be negative")
+	}
+	pause := instance.NewRestartPause(time.Now(), duration)
+	if err := im.setGlobalRestartPause(pause); err != nil {
+		return nil, err
+	}
+	im.recordAudit(actor, "pause_autorestart", globalPauseKey, pauseDetails(pause))
+	im.publishRestartPause("", AutoRestartPaused, pause)
+	return pause, nil
+}
+
+// ResumeAllAutoRestarts lifts the global pause of the automatic restarts. Instances paused
+// on their own stay paused.
+func (im *instanceManager) ResumeAllAutoRestarts() error {
+	return im.resumeAllAutoRestarts("")
+}
+
+// resumeAllAutoRestarts is ResumeAllAutoRestarts on behalf of actor
+func (im *instanceManager) resumeAllAutoRestarts(actor string) error {
+	pause := im.restartPause.Get()
+	if pause == nil {
+		return nil
+	}
+	if err := im.setGlobalRestartPause(nil); err != nil {
+		return err
+	}
+	im.recordAudit(actor, "resume_autorestart", globalPauseKey, "")
+	im.publishRestartPause("", AutoRestartResumed, pause)
+	return nil
+}
+
+// setGlobalRestartPause replaces the global pause, saves it and schedules its expiry
+func (im *instanceManager) setGlobalRestartPause(pause *instance.RestartPause) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.restartPause.Set(pause)
+	im.schedulePauseExpiry("", pause)
+	if err := im.saveGlobalRestartPause(pause); err != nil {
+		return fmt.Errorf("failed to persist the global pause of automatic restarts: %w", err)
+	}
+	return nil
+}
+
+// saveGlobalRestartPause persists the global pause, removing it when pause is nil
+func (im *instanceManager) saveGlobalRestartPause(pause *instance.RestartPause) error {
+	if im.store == nil {
+		return nil // Persistence disabled
+	}
+	if pause == nil {
+		return im.store.Delete(storage.NamespaceAutoRestartPause, globalPauseKey)
+	}
+	data, err := json.Marshal(pause)
+	if err != nil {
+		return err
+	}
+	return im.store.Put(storage.NamespaceAutoRestartPause, globalPauseKey, data)
+}
+
+// loadGlobalRestartPause restores the persisted global pause, if any
+func (im *instanceManager) loadGlobalRestartPause() error {
+	if im.store == nil {
+		return nil
+	}
+	data, err := im.store.Get(storage.NamespaceAutoRestartPause, globalPauseKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read the global pause of automatic restarts: %w", err)
+	}
+	var pause instance.RestartPause
+	if err := json.Unmarshal(data, &pause); err != nil {
+		return fmt.Errorf("invalid global pause of automatic restarts: %w", err)
+	}
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	im.restartPause.Set(&pause)
+	im.schedulePauseExpiry("", &pause)
+	return nil
+}
+
+// schedulePauseExpiry replaces the timer resuming the restarts of an instance, or of every
+// instance for an empty name, once pause runs out. A pause that already ran out, such as one
+// restored after llamactl was down, expires right away. (caller must hold the lock)
+func (im *instanceManager) schedulePauseExpiry(name string, pause *instance.RestartPause) {
+	if timer, ok := im.pauseTimers[name]; ok {
+		timer.Stop()
+		delete(im.pauseTimers, name)
+	}
+	if pause == nil || pause.Until == nil {
+		return
+	}
+	im.pauseTimers[name] = time.AfterFunc(time.Until(*pause.Until), func() {
+		im.expireRestartPause(name, pause)
+	})
+}
+
+// expireRestartPause lifts a pause that ran out, unless it was replaced meanwhile
+func (im *instanceManager) expireRestartPause(name string, pause *instance.RestartPause) {
+	im.mu.Lock()
+	if im.isShutdown {
+		im.mu.Unlock()
+		return
+	}
+	target := name
+	var err error
+	if name == "" {
+		if im.restartPause.Get() != pause {
+			im.mu.Unlock()
+			return
+		}
+		target = globalPauseKey
+		im.restartPause.Set(nil)
+		err = im.saveGlobalRestartPause(nil)
+	} else {
+		inst, ok := im.instances[name]
+		if !ok || inst.GetRestartPause() != pause {
+			im.mu.Unlock()
+			return
+		}
+		inst.SetRestartPause(nil)
+		err = im.persistInstance(inst)
+	}
+	delete(im.pauseTimers, name)
+	im.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Failed to persist the expired pause of automatic restarts of %s: %v", target, err)
+	}
+	log.Printf("Pause of the automatic restarts of %s expired", target)
+	im.recordAudit("", "resume_autorestart", target, "pause expired")
+	im.publishRestartPause(name, AutoRestartExpired, pause)
+}
+
+// stopPauseTimers stops the timers of the pauses, on shutdown
+func (im *instanceManager) stopPauseTimers() {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	for name, timer := range im.pauseTimers {
+		timer.Stop()
+		delete(im.pauseTimers, name)
+	}
+}
+
+// pauseDetails describes a pause for the audit log
+func pauseDetails(pause *instance.RestartPause) string {
+	if pause.Until == nil {
+		return "until resumed"
+	}
+	return "until " + pause.Until.Format(time.RFC3339)
+}
+
+// publishRestartPause publishes an autorestart event for a pause of an instance, or of every
+// instance for an empty name
+func (im *instanceManager) publishRestartPause(name, code string, pause *instance.RestartPause) {
+	scope := "instance"
+	if name == "" {
+		scope = "global"
+	}
+	var message string
+	switch code {
+	case AutoRestartPaused:
+		message = "automatic restarts paused " + pauseDetails(pause)
+	case AutoRestartResumed:
+		message = "automatic restarts resumed"
+	case AutoRestartExpired:
+		message = "pause of automatic restarts expired, restarts resumed"
+	}
+
+	data := map[string]any{"scope": scope, "since": pause.Since}
+	if pause.Until != nil {
+		data["until"] = *pause.Until
+	}
+	im.events.Publish(events.Event{
+		Type:     events.TypeAutoRestart,
+		Instance: name,
+		Code:     code,
+		Message:  message,
+		Data:     data,
+	})
+}
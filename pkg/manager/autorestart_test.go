@@ -0,0 +1,173 @@
+package manager_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"llamactl/pkg/testutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForEvent returns the first event of sub matching match
+func waitForEvent(t *testing.T, sub <-chan events.Event, what string, match func(events.Event) bool) events.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-sub:
+			if match(event) {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestPauseAutoRestart(t *testing.T) {
+	mngr, options := newHooksManager(t, "sleep 0.3; exit 3")
+	options.AutoRestart, options.MaxRestarts, options.RestartDelay = testutil.BoolPtr(true), testutil.IntPtr(3), testutil.DurationPtr(0)
+	if _, err := mngr.CreateInstance("paused", options); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	sub, unsubscribe := mngr.SubscribeEvents()
+	defer unsubscribe()
+
+	inst, err := mngr.PauseAutoRestart("paused", 0)
+	if err != nil {
+		t.Fatalf("PauseAutoRestart failed: %v", err)
+	}
+	if budget := inst.GetRestartBudget(); budget.Armed || budget.Reason != instance.RestartPaused {
+		t.Errorf("Expected the restart budget to report the pause, got %+v", budget)
+	}
+	waitForEvent(t, sub, "the pause event", func(event events.Event) bool {
+		return event.Type == events.TypeAutoRestart && event.Instance == "paused" && event.Code == manager.AutoRestartPaused
+	})
+
+	// The backend crashes while paused and stays down, with its options untouched
+	if _, err := mngr.StartInstance("paused"); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	waitForEvent(t, sub, "the instance to fail", func(event events.Event) bool {
+		return event.Type == events.TypeStatusChange && event.Instance == "paused" && event.Data["new_status"] == "failed"
+	})
+	if restarts := inst.GetRestartBudget().Restarts; restarts != 0 {
+		t.Errorf("Expected no restart while paused, got %d", restarts)
+	}
+	if !*inst.GetOptions().AutoRestart {
+		t.Error("Expected auto_restart to be left on")
+	}
+
+	var status struct {
+		Paused bool                   `json:"autorestart_paused"`
+		Until  *time.Time             `json:"autorestart_paused_until"`
+		Pause  *instance.RestartPause `json:"autorestart_pause"`
+	}
+	data, _ := json.Marshal(inst)
+	json.Unmarshal(data, &status)
+	if !status.Paused || status.Until != nil || status.Pause == nil {
+		t.Errorf("Expected the instance to report a pause until resumed, got %s", data)
+	}
+
+	if _, err := mngr.ResumeAutoRestart("paused"); err != nil {
+		t.Fatalf("ResumeAutoRestart failed: %v", err)
+	}
+	if budget := inst.GetRestartBudget(); !budget.Armed {
+		t.Errorf("Expected restarts to be armed once resumed, got %+v", budget)
+	}
+}
+
+func TestPauseAllAutoRestarts(t *testing.T) {
+	mngr, options := newHooksManager(t, "exec sleep 30")
+	options.AutoRestart, options.MaxRestarts, options.RestartDelay = testutil.BoolPtr(true), testutil.IntPtr(3), testutil.DurationPtr(0)
+	inst, err := mngr.CreateInstance("fleet", options)
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	sub, unsubscribe := mngr.SubscribeEvents()
+	defer unsubscribe()
+
+	pause, err := mngr.PauseAllAutoRestarts(200 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("PauseAllAutoRestarts failed: %v", err)
+	}
+	if pause.Until == nil || mngr.GetGlobalRestartPause() != pause {
+		t.Fatalf("Expected a global pause with an end, got %+v", pause)
+	}
+	if budget := inst.GetRestartBudget(); budget.Reason != instance.RestartPaused {
+		t.Errorf("Expected the global pause to hold for the instance, got %+v", budget)
+	}
+
+	// The pause runs out by itself
+	event := waitForEvent(t, sub, "the pause to expire", func(event events.Event) bool {
+		return event.Type == events.TypeAutoRestart && event.Code == manager.AutoRestartExpired
+	})
+	if event.Instance != "" || event.Data["scope"] != "global" {
+		t.Errorf("Expected the expiry of the global pause, got %+v", event)
+	}
+	if pause := mngr.GetGlobalRestartPause(); pause != nil {
+		t.Errorf("Expected no global pause once expired, got %+v", pause)
+	}
+	if budget := inst.GetRestartBudget(); !budget.Armed {
+		t.Errorf("Expected restarts to be armed once the pause expired, got %+v", budget)
+	}
+}
+
+func TestPauseAutoRestart_Persisted(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         filepath.Join(dir, "instances"),
+		LogsDir:              filepath.Join(dir, "logs"),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	store := storage.NewFileStore(cfg.InstancesDir, dir)
+	mngr := manager.NewInstanceManagerWithStore(config.BackendConfig{}, cfg, store)
+	if _, err := mngr.CreateInstance("paused", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(true),
+		MaxRestarts:        testutil.IntPtr(3),
+		RestartDelay:       testutil.DurationPtr(0),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.PauseAutoRestart("paused", time.Hour); err != nil {
+		t.Fatalf("PauseAutoRestart failed: %v", err)
+	}
+	if _, err := mngr.PauseAllAutoRestarts(0); err != nil {
+		t.Fatalf("PauseAllAutoRestarts failed: %v", err)
+	}
+	mngr.Shutdown()
+
+	// Both pauses survive a restart of llamactl
+	restored := manager.NewInstanceManagerWithStore(config.BackendConfig{}, cfg, store)
+	t.Cleanup(restored.Shutdown)
+	if pause := restored.GetGlobalRestartPause(); pause == nil || pause.Until != nil {
+		t.Errorf("Expected the global pause until resumed to be restored, got %+v", pause)
+	}
+	inst, err := restored.GetInstance("paused")
+	if err != nil {
+		t.Fatalf("GetInstance failed: %v", err)
+	}
+	if pause := inst.GetRestartPause(); pause == nil || pause.Until == nil || time.Until(*pause.Until) < 50*time.Minute {
+		t.Errorf("Expected the pause of the instance to be restored, got %+v", pause)
+	}
+
+	// Resuming globally leaves the pause of the instance
+	if err := restored.ResumeAllAutoRestarts(); err != nil {
+		t.Fatalf("ResumeAllAutoRestarts failed: %v", err)
+	}
+	if budget := inst.GetRestartBudget(); budget.Reason != instance.RestartPaused {
+		t.Errorf("Expected the instance to stay paused, got %+v", budget)
+	}
+}
@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/storage"
+)
+
+// ErrNoManagedBackendKey is returned when rotating the backend key of an instance that does
+// not use managed_backend_key
+var ErrNoManagedBackendKey = errors.New("instance does not use a managed backend key")
+
+// backendKeyRecord is the persisted managed backend key of an instance
+type backendKeyRecord struct {
+	Key string `json:"key"`
+}
+
+// saveBackendKey persists the managed backend key of an instance, removing it when key is empty
+func (im *instanceManager) saveBackendKey(name, key string) error {
+	if im.store == nil {
+		return nil // Persistence disabled
+	}
+	if key == "" {
+		return im.store.Delete(storage.NamespaceBackendKeys, name)
+	}
+	data, err := json.Marshal(backendKeyRecord{Key: key})
+	if err != nil {
+		return err
+	}
+	return im.store.Put(storage.NamespaceBackendKeys, name, data)
+}
+
+// loadBackendKey restores the persisted managed backend key of an instance, if any
+func (im *instanceManager) loadBackendKey(inst *instance.Process) error {
+	if im.store == nil {
+		return nil
+	}
+	data, err := im.store.Get(storage.NamespaceBackendKeys, inst.Name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read backend key of instance %s: %w", inst.Name, err)
+	}
+	var record backendKeyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("invalid backend key of instance %s: %w", inst.Name, err)
+	}
+	inst.SetBackendKey(record.Key)
+	return nil
+}
+
+// syncBackendKey generates the managed backend key of an instance that uses one and does not
+// have it yet, and removes the key of an instance that no longer uses one, as of the options
+// of its next start
+func (im *instanceManager) syncBackendKey(inst *instance.Process) error {
+	managed := inst.GetDesiredOptions().UsesManagedBackendKey()
+	switch has := inst.HasBackendKey(); {
+	case managed && !has:
+		key, err := instance.GenerateBackendKey()
+		if err != nil {
+			return err
+		}
+		if err := im.saveBackendKey(inst.Name, key); err != nil {
+			return fmt.Errorf("failed to persist backend key of instance %s: %w", inst.Name, err)
+		}
+		inst.SetBackendKey(key)
+	case !managed && has:
+		if err := im.saveBackendKey(inst.Name, ""); err != nil {
+			return fmt.Errorf("failed to delete backend key of instance %s: %w", inst.Name, err)
+		}
+		inst.SetBackendKey("")
+	}
+	return nil
+}
+
+// RotateBackendKey replaces the managed backend key of an instance with a new one. A running
+// instance keeps its current key until it is recycled once its in-flight requests finished,
+// and starts with the new key, so the two keys never need to be accepted at the same time.
+func (im *instanceManager) RotateBackendKey(name string) (*instance.Process, error) {
+	return im.rotateBackendKey(name, "")
+}
+
+// rotateBackendKey is RotateBackendKey on behalf of actor
+func (im *instanceManager) rotateBackendKey(name string, actor string) (*instance.Process, error) {
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	if !inst.GetOptions().UsesManagedBackendKey() {
+		return nil, fmt.Errorf("instance %s: %w", name, ErrNoManagedBackendKey)
+	}
+
+	key, err := instance.GenerateBackendKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := im.saveBackendKey(name, key); err != nil {
+		return nil, fmt.Errorf("failed to persist backend key of instance %s: %w", name, err)
+	}
+	inst.SetBackendKey(key)
+	im.recordAudit(actor, "rotate_backend_key", name, "")
+
+	if inst.BackendKeyPending() {
+		im.recycleWhenIdle(name, "to apply its rotated backend key", (*instance.Process).BackendKeyPending)
+	}
+	return inst, nil
+}
@@ -0,0 +1,133 @@
+package manager_test
+
+import (
+	"errors"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"llamactl/pkg/testutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRotateBackendKey(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backendConfig := config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "exec sleep 30"}}}
+	dir := t.TempDir()
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         filepath.Join(dir, "instances"),
+		LogsDir:              filepath.Join(dir, "logs"),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	store := storage.NewFileStore(cfg.InstancesDir, dir)
+	mngr := manager.NewInstanceManagerWithStore(backendConfig, cfg, store)
+
+	storedKey := func() string {
+		t.Helper()
+		data, err := store.Get(storage.NamespaceBackendKeys, "keyed")
+		if err != nil {
+			t.Fatalf("Expected a persisted backend key: %v", err)
+		}
+		return string(data)
+	}
+
+	if _, err := mngr.CreateInstance("keyed", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		ManagedBackendKey:  testutil.BoolPtr(true),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	created := storedKey()
+	if info, err := os.Stat(filepath.Join(dir, string(storage.NamespaceBackendKeys), "keyed.json")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the key to only be readable by its owner, got %v (%v)", info.Mode(), err)
+	}
+
+	inst, err := mngr.RotateBackendKey("keyed")
+	if err != nil {
+		t.Fatalf("RotateBackendKey failed: %v", err)
+	}
+	if storedKey() == created {
+		t.Error("Expected a new key to be persisted")
+	}
+	if inst.BackendKeyPending() {
+		t.Error("Expected the key of a stopped instance to apply right away")
+	}
+
+	if _, err := mngr.CreateInstance("plain", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.RotateBackendKey("plain"); !errors.Is(err, manager.ErrNoManagedBackendKey) {
+		t.Errorf("Expected ErrNoManagedBackendKey, got %v", err)
+	}
+
+	// A running instance is recycled to apply the new key
+	if _, err := mngr.StartInstance("keyed"); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	if inst, err = mngr.RotateBackendKey("keyed"); err != nil {
+		t.Fatalf("RotateBackendKey failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for inst.BackendKeyPending() || !inst.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the instance to be recycled, it is %s", inst.GetStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := mngr.StopInstance("keyed"); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+
+	// The key survives a restart of llamactl
+	rotated := storedKey()
+	mngr.Shutdown()
+	mngr = manager.NewInstanceManagerWithStore(backendConfig, cfg, store)
+	t.Cleanup(mngr.Shutdown)
+	if storedKey() != rotated {
+		t.Error("Expected the persisted key to be kept on load")
+	}
+
+	// Turning the option off removes the key
+	if _, err := mngr.UpdateInstance("keyed", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	}); err != nil {
+		t.Fatalf("UpdateInstance failed: %v", err)
+	}
+	if _, err := store.Get(storage.NamespaceBackendKeys, "keyed"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected the key to be deleted, got %v", err)
+	}
+}
+
+func TestManagedBackendKey_Validation(t *testing.T) {
+	mngr := manager.NewInstanceManager(config.BackendConfig{}, config.InstancesConfig{
+		PortRange:    [2]int{8000, 9000},
+		MaxInstances: 10,
+	})
+	defer mngr.Shutdown()
+
+	err := mngr.ValidateInstance("keyed", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		ManagedBackendKey:  testutil.BoolPtr(true),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", APIKey: "my-own-key"},
+	})
+	if err == nil {
+		t.Error("Expected api_key to conflict with managed_backend_key")
+	}
+}
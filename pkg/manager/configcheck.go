@@ -0,0 +1,34 @@
+package manager
+
+import (
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+)
+
+// reportConfigMismatches publishes a config_mismatch event for every instance whose backend
+// reported, once ready, settings other than requested since the last check
+func (im *instanceManager) reportConfigMismatches() {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	im.mu.RUnlock()
+
+	for _, inst := range instances {
+		verification := inst.TakeConfigMismatch()
+		if verification == nil {
+			continue
+		}
+		im.events.Publish(events.Event{
+			Type:     events.TypeConfigMismatch,
+			Instance: inst.Name,
+			Code:     verification.Status,
+			Message:  verification.Message,
+			Data: map[string]any{
+				"mismatches":  verification.Mismatches,
+				"verified_at": verification.Time,
+			},
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package manager
+
+import (
+	"llamactl/pkg/instance"
+	"time"
+)
+
+// connectionReapInterval is how often idle backend connections of the instance proxies are reaped
+const connectionReapInterval = 10 * time.Second
+
+// reapIdleConnections closes the backend connections each instance proxy has kept idle for
+// longer than the backend idle timeout, down to the instance's floor of idle connections
+func (im *instanceManager) reapIdleConnections() {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	im.mu.RUnlock()
+
+	for _, inst := range instances {
+		inst.ReapIdleConnections()
+	}
+}
@@ -0,0 +1,219 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/storage"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of drift, each comparing two states of an instance
+const (
+	DriftPersisted = "persisted" // The persisted definition differs from the options in memory
+	DriftConfig    = "config"    // A static instance differs from its definition in the configuration file
+	DriftPending   = "pending"   // The options in memory are not in effect until the next start
+	DriftProcess   = "process"   // The running process reports another command line than it was launched with
+)
+
+// States of an instance that drift compares, the keys of the values of a discrepancy
+const (
+	DriftSourcePersisted = "persisted" // Definition in the store
+	DriftSourceConfig    = "config"    // Definition in the configuration file, with the defaults applied
+	DriftSourceDesired   = "desired"   // Options in memory the next start uses
+	DriftSourceInEffect  = "in_effect" // Options the running process was started with
+	DriftSourceLaunched  = "launched"  // Command line llamactl launched the process with
+	DriftSourceRunning   = "running"   // Command line the process reports
+)
+
+// DriftDiscrepancy is a field that differs between two states of an instance
+type DriftDiscrepancy struct {
+	Kind    string         `json:"kind"`
+	Field   string         `json:"field,omitempty"`   // Option, e.g. backend_options.ctx_size, or command line flag
+	Values  map[string]any `json:"values,omitempty"`  // By state compared, null where unset
+	Message string         `json:"message,omitempty"` // For discrepancies of the whole instance
+}
+
+// InstanceDrift compares the persisted definition of an instance, its options in memory, and
+// the command line of its running process
+type InstanceDrift struct {
+	Name          string             `json:"name"`
+	Drifted       bool               `json:"drifted"`
+	Discrepancies []DriftDiscrepancy `json:"discrepancies"`
+	// Comparisons that could not be made, by kind, and why
+	Unchecked map[string]string `json:"unchecked,omitempty"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+// DriftSummary lists the instances with drift
+type DriftSummary struct {
+	Drifted   int             `json:"drifted"`
+	Instances []InstanceDrift `json:"instances"` // Only those with drift
+	CheckedAt time.Time       `json:"checked_at"`
+}
+
+// persistedDefinition is the part of a persisted instance drift compares
+type persistedDefinition struct {
+	Options        *instance.CreateInstanceOptions `json:"options,omitempty"`
+	PendingOptions *instance.CreateInstanceOptions `json:"pending_options,omitempty"`
+}
+
+// GetInstanceDrift compares the persisted definition of an instance, the options in memory
+// and, where the platform tells, the command line of the running process, reporting every
+// field that differs
+func (im *instanceManager) GetInstanceDrift(name string) (*InstanceDrift, error) {
+	im.mu.RLock()
+	inst, exists := im.instances[name]
+	im.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("instance with name %s not found", name)
+	}
+
+	var static *FleetInstance
+	if _, ok := im.instancesConfig.Static[name]; ok {
+		fleet := im.staticFleet()
+		if n := slices.IndexFunc(fleet.Instances, func(f FleetInstance) bool { return f.Name == name }); n >= 0 {
+			static = &fleet.Instances[n]
+		}
+	}
+	return im.instanceDrift(inst, static), nil
+}
+
+// GetDrift compares every instance as GetInstanceDrift does, listing those with drift
+func (im *instanceManager) GetDrift() *DriftSummary {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	im.mu.RUnlock()
+	slices.SortFunc(instances, func(a, b *instance.Process) int { return strings.Compare(a.Name, b.Name) })
+
+	statics := make(map[string]*FleetInstance)
+	if len(im.instancesConfig.Static) > 0 {
+		fleet := im.staticFleet()
+		for n := range fleet.Instances {
+			statics[fleet.Instances[n].Name] = &fleet.Instances[n]
+		}
+	}
+
+	summary := &DriftSummary{Instances: []InstanceDrift{}, CheckedAt: time.Now()}
+	for _, inst := range instances {
+		if drift := im.instanceDrift(inst, statics[inst.Name]); drift.Drifted {
+			summary.Drifted++
+			summary.Instances = append(summary.Instances, *drift)
+		}
+	}
+	return summary
+}
+
+// instanceDrift compares the states of an instance, and of its definition in the
+// configuration file if it is a static instance
+func (im *instanceManager) instanceDrift(inst *instance.Process, static *FleetInstance) *InstanceDrift {
+	drift := &InstanceDrift{Name: inst.Name, Discrepancies: []DriftDiscrepancy{}, CheckedAt: time.Now()}
+	unchecked := func(kind, reason string) {
+		if drift.Unchecked == nil {
+			drift.Unchecked = make(map[string]string)
+		}
+		drift.Unchecked[kind] = reason
+	}
+	desired := inst.GetDesiredOptions()
+	compare := func(kind string, differences []instance.ValueDifference, a, b string) {
+		for _, difference := range differences {
+			if difference.Field == "environment" {
+				difference.A, difference.B = maskEnvironment(desired, difference.A), maskEnvironment(desired, difference.B)
+			}
+			drift.Discrepancies = append(drift.Discrepancies, DriftDiscrepancy{
+				Kind:   kind,
+				Field:  difference.Field,
+				Values: map[string]any{a: difference.A, b: difference.B},
+			})
+		}
+	}
+
+	// The definition in the store, as the next llamactl would load it
+	if im.store == nil {
+		unchecked(DriftPersisted, "persistence is disabled")
+	} else if persisted, err := im.persistedOptions(inst.Name); errors.Is(err, storage.ErrNotFound) {
+		drift.Discrepancies = append(drift.Discrepancies, DriftDiscrepancy{Kind: DriftPersisted, Message: "the instance has no persisted definition"})
+	} else if err != nil {
+		unchecked(DriftPersisted, err.Error())
+	} else if differences, err := instance.CompareOptions(persisted, desired); err != nil {
+		unchecked(DriftPersisted, err.Error())
+	} else {
+		compare(DriftPersisted, differences, DriftSourcePersisted, DriftSourceDesired)
+	}
+
+	// The definition in the configuration file, which the next llamactl reverts to
+	if static != nil {
+		if defined, err := im.effectiveFleetOptions(inst, static.Options); err != nil {
+			unchecked(DriftConfig, err.Error())
+		} else if differences, err := instance.CompareOptions(defined, desired); err != nil {
+			unchecked(DriftConfig, err.Error())
+		} else {
+			compare(DriftConfig, differences, DriftSourceConfig, DriftSourceDesired)
+		}
+	}
+
+	if inst.IsRunning() && inst.IsManaged() {
+		if inst.RestartRequired() {
+			if differences, err := instance.CompareOptions(inst.GetOptions(), desired); err != nil {
+				unchecked(DriftPending, err.Error())
+			} else {
+				compare(DriftPending, differences, DriftSourceInEffect, DriftSourceDesired)
+			}
+		}
+
+		if launched, running, err := inst.RunningCommandLine(); err != nil {
+			if !errors.Is(err, instance.ErrNotRunning) {
+				unchecked(DriftProcess, err.Error())
+			}
+		} else {
+			compare(DriftProcess, instance.CompareCommandLines(launched, running), DriftSourceLaunched, DriftSourceRunning)
+		}
+	}
+
+	drift.Drifted = len(drift.Discrepancies) > 0
+	return drift
+}
+
+// persistedOptions returns the options the persisted definition of an instance starts with
+func (im *instanceManager) persistedOptions(name string) (*instance.CreateInstanceOptions, error) {
+	data, err := im.store.Get(storage.NamespaceInstances, name)
+	if err != nil {
+		return nil, err
+	}
+	var definition persistedDefinition
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return nil, fmt.Errorf("failed to read the persisted definition: %w", err)
+	}
+	options := definition.Options
+	if definition.PendingOptions != nil {
+		options = definition.PendingOptions
+	}
+	if options != nil {
+		// As loaded on startup
+		options.ValidateAndApplyDefaults(name, &im.instancesConfig)
+	}
+	return options, nil
+}
+
+// maskEnvironment masks the values of the secret environment variables of the options in
+// the environment a discrepancy reports
+func maskEnvironment(options *instance.CreateInstanceOptions, value any) any {
+	environment, ok := value.(map[string]any)
+	if !ok {
+		return value
+	}
+	masked := make(map[string]any, len(environment))
+	for name, value := range environment {
+		if options.IsSecretEnvironment(name) {
+			value = instance.MaskedEnvironmentValue
+		}
+		masked[name] = value
+	}
+	return masked
+}
@@ -0,0 +1,114 @@
+package manager_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"testing"
+	"time"
+)
+
+func TestGetInstanceDrift_Persisted(t *testing.T) {
+	tempDir := t.TempDir()
+	backendConfig := config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "llama-server"}}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         tempDir,
+		LogsDir:              t.TempDir(),
+		MaxInstances:         10,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	store := storage.NewFileStore(tempDir, tempDir)
+	mngr := manager.NewInstanceManagerWithStore(backendConfig, cfg, store)
+	defer mngr.Shutdown()
+
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080, CtxSize: 4096},
+	}
+	if _, err := mngr.CreateInstance("drift-instance", options); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	drift, err := mngr.GetInstanceDrift("drift-instance")
+	if err != nil {
+		t.Fatalf("GetInstanceDrift failed: %v", err)
+	}
+	if drift.Drifted || len(drift.Discrepancies) != 0 || len(drift.Unchecked) != 0 {
+		t.Fatalf("Expected a freshly created instance to have no drift, got %+v", drift)
+	}
+
+	// The definition on disk edited by hand
+	data, err := store.Get(storage.NamespaceInstances, "drift-instance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	record["options"].(map[string]any)["backend_options"].(map[string]any)["ctx_size"] = 8192
+	if data, err = json.Marshal(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(storage.NamespaceInstances, "drift-instance", data); err != nil {
+		t.Fatal(err)
+	}
+
+	drift, err = mngr.GetInstanceDrift("drift-instance")
+	if err != nil {
+		t.Fatalf("GetInstanceDrift failed: %v", err)
+	}
+	if !drift.Drifted || len(drift.Discrepancies) != 1 {
+		t.Fatalf("Expected one discrepancy, got %+v", drift)
+	}
+	discrepancy := drift.Discrepancies[0]
+	if discrepancy.Kind != manager.DriftPersisted || discrepancy.Field != "backend_options.ctx_size" ||
+		discrepancy.Values[manager.DriftSourcePersisted] != float64(8192) || discrepancy.Values[manager.DriftSourceDesired] != float64(4096) {
+		t.Errorf("Expected the persisted ctx_size to differ, got %+v", discrepancy)
+	}
+
+	summary := mngr.GetDrift()
+	if summary.Drifted != 1 || len(summary.Instances) != 1 || summary.Instances[0].Name != "drift-instance" {
+		t.Errorf("Expected the summary to list the instance, got %+v", summary)
+	}
+
+	// Deleted from the store behind llamactl's back
+	if err := store.Delete(storage.NamespaceInstances, "drift-instance"); err != nil {
+		t.Fatal(err)
+	}
+	drift, err = mngr.GetInstanceDrift("drift-instance")
+	if err != nil {
+		t.Fatalf("GetInstanceDrift failed: %v", err)
+	}
+	if !drift.Drifted || len(drift.Discrepancies) != 1 || drift.Discrepancies[0].Message == "" {
+		t.Errorf("Expected the missing definition to be reported, got %+v", drift)
+	}
+}
+
+func TestGetInstanceDrift_WithoutStore(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+
+	if _, err := mngr.CreateInstance("drift-instance", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	drift, err := mngr.GetInstanceDrift("drift-instance")
+	if err != nil {
+		t.Fatalf("GetInstanceDrift failed: %v", err)
+	}
+	if drift.Drifted || drift.Unchecked[manager.DriftPersisted] == "" {
+		t.Errorf("Expected the persisted definition to be unchecked without a store, got %+v", drift)
+	}
+	if _, err := mngr.GetInstanceDrift("missing"); err == nil {
+		t.Error("Expected an error for a missing instance")
+	}
+}
@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"context"
+	"llamactl/pkg/instance"
+	"log"
+	"sync"
+	"time"
+)
+
+// externalProbeInterval is how often the backends of unmanaged instances are health checked
+const externalProbeInterval = 10 * time.Second
+
+// probeExternalInstances refreshes the status of every unmanaged instance from its health endpoint
+func (im *instanceManager) probeExternalInstances() {
+	im.mu.RLock()
+	var external []*instance.Process
+	for _, inst := range im.instances {
+		if !inst.IsManaged() {
+			external = append(external, inst)
+		}
+	}
+	im.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, inst := range external {
+		wg.Add(1)
+		go func(inst *instance.Process) {
+			defer wg.Done()
+			im.probeExternalInstance(inst)
+		}(inst)
+	}
+	wg.Wait()
+}
+
+// probeExternalInstance refreshes the status of a single unmanaged instance
+func (im *instanceManager) probeExternalInstance(inst *instance.Process) {
+	wasRunning := inst.IsRunning()
+	err := inst.RefreshExternalStatus(context.Background())
+	if err != nil && wasRunning {
+		log.Printf("External instance %s failed its health probe: %v", inst.Name, err)
+	}
+}
+
+// runningManagedCount returns the number of running instances whose process llamactl owns.
+// Unmanaged instances do not count towards max_running_instances (caller must hold the lock).
+func (im *instanceManager) runningManagedCount() int {
+	count := 0
+	for name := range im.runningInstances {
+		if inst := im.instances[name]; inst == nil || inst.IsManaged() {
+			count++
+		}
+	}
+	return count
+}
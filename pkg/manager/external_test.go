@@ -0,0 +1,74 @@
+package manager_test
+
+import (
+	"errors"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestUnmanagedInstance(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+	managed := false
+
+	// The port of the external backend is required
+	_, err := mngr.CreateInstance("no-port", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		Managed:            &managed,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	})
+	if err == nil {
+		t.Error("Expected error creating an unmanaged instance without a port")
+	}
+
+	inst, err := mngr.CreateInstance("external", &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		Managed:     &managed,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  backendURL.Hostname(),
+			Port:  port,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	// Health comes from probing the external backend
+	deadline := time.Now().Add(5 * time.Second)
+	for !inst.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the external backend to be probed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := mngr.StartInstance("external"); !errors.Is(err, instance.ErrUnmanaged) {
+		t.Errorf("Expected StartInstance to fail with ErrUnmanaged, got %v", err)
+	}
+	if _, err := mngr.StopInstance("external"); !errors.Is(err, instance.ErrUnmanaged) {
+		t.Errorf("Expected StopInstance to fail with ErrUnmanaged, got %v", err)
+	}
+	if _, err := mngr.RestartInstance("external"); !errors.Is(err, instance.ErrUnmanaged) {
+		t.Errorf("Expected RestartInstance to fail with ErrUnmanaged, got %v", err)
+	}
+
+	// Deleting only stops proxying, the external backend keeps running
+	if err := mngr.DeleteInstance("external"); err != nil {
+		t.Errorf("Expected running unmanaged instance to be deletable, got %v", err)
+	}
+}
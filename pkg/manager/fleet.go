@@ -0,0 +1,499 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/validation"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxFleetSize bounds the fleet file read from a URL
+const maxFleetSize = 16 * 1024 * 1024
+
+// Desired states of a fleet instance
+const (
+	FleetStateRunning = "running"
+	FleetStateStopped = "stopped"
+)
+
+// Fleet plan actions
+const (
+	FleetCreate = "create"
+	FleetUpdate = "update"
+	FleetStart  = "start"
+	FleetStop   = "stop"
+	FleetDelete = "delete"
+)
+
+// Fleet is the desired state of the instances, declared in a YAML file
+type Fleet struct {
+	Instances []FleetInstance
+	// SHA-256 of the file the fleet was parsed from
+	Checksum string
+	// The static instances of the configuration file, which may update config-managed instances
+	fromConfig bool
+}
+
+// FleetInstance is the definition and desired state of a single instance of a fleet
+type FleetInstance struct {
+	Name    string
+	State   string
+	Options *instance.CreateInstanceOptions
+}
+
+// fleetFile is the YAML layout of a fleet file. Options use the same keys as the JSON API.
+type fleetFile struct {
+	Instances []struct {
+		Name    string         `yaml:"name"`
+		State   string         `yaml:"state"`
+		Options map[string]any `yaml:"options"`
+	} `yaml:"instances"`
+}
+
+// ParseFleet parses a fleet file
+func ParseFleet(data []byte) (*Fleet, error) {
+	var file fleetFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse fleet file: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	fleet := &Fleet{Checksum: hex.EncodeToString(sum[:])}
+	seen := make(map[string]bool)
+	for idx, entry := range file.Instances {
+		name, err := validation.ValidateInstanceName(entry.Name)
+		if err != nil {
+			return nil, fmt.Errorf("instance %d: %w", idx+1, err)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("instance %s is listed more than once", name)
+		}
+		seen[name] = true
+
+		state := entry.State
+		if state == "" {
+			state = FleetStateRunning
+		}
+		if state != FleetStateRunning && state != FleetStateStopped {
+			return nil, fmt.Errorf("instance %s: state must be %s or %s, got %q", name, FleetStateRunning, FleetStateStopped, state)
+		}
+
+		// Round trip through JSON so the options are parsed exactly like API requests
+		optionsJSON, err := json.Marshal(entry.Options)
+		if err != nil {
+			return nil, fmt.Errorf("instance %s: failed to convert options: %w", name, err)
+		}
+		options := &instance.CreateInstanceOptions{}
+		if err := json.Unmarshal(optionsJSON, options); err != nil {
+			return nil, fmt.Errorf("instance %s: invalid options: %w", name, err)
+		}
+
+		fleet.Instances = append(fleet.Instances, FleetInstance{Name: name, State: state, Options: options})
+	}
+	return fleet, nil
+}
+
+// LoadFleetSource reads a fleet file from a path or an http(s) URL
+func LoadFleetSource(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch fleet file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch fleet file: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFleetSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fleet file: %w", err)
+	}
+	if len(data) > maxFleetSize {
+		return nil, fmt.Errorf("fleet file exceeds %d bytes", maxFleetSize)
+	}
+	return data, nil
+}
+
+// ApplyOptions controls how a fleet is applied
+type ApplyOptions struct {
+	// Only report the plan, without changing anything
+	DryRun bool `json:"dry_run"`
+	// Stop and delete instances that are not listed in the fleet
+	Prune bool `json:"prune"`
+}
+
+// FleetAction is a single step of a fleet plan
+type FleetAction struct {
+	Action   string `json:"action"`
+	Instance string `json:"instance"`
+	// Options that differ from the fleet, for updates
+	Changes []string `json:"changes,omitempty"`
+	// The instance is running and is restarted to apply the update
+	RestartRequired bool   `json:"restart_required,omitempty"`
+	Error           string `json:"error,omitempty"`
+	// The invalid fields when the options failed validation
+	FieldErrors []validation.FieldError `json:"field_errors,omitempty"`
+}
+
+// FleetPlan is the set of actions that reconcile the instances with a fleet
+type FleetPlan struct {
+	Checksum string        `json:"checksum"`
+	DryRun   bool          `json:"dry_run"`
+	Actions  []FleetAction `json:"actions"`
+	// Instances not listed in the fleet, kept because prune is off
+	Unlisted []string `json:"unlisted,omitempty"`
+}
+
+// Failed reports whether any action of the plan failed
+func (p *FleetPlan) Failed() bool {
+	for _, action := range p.Actions {
+		if action.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyFleet reconciles the instances with a fleet: missing instances are created, drifted
+// options updated and instances started or stopped to match their desired state. With
+// Prune, instances that are not listed are stopped and deleted. Options are validated
+// like API requests; an invalid instance fails its action without stopping the others.
+// Every applied change is recorded in the audit log with the checksum of the fleet file.
+// It waits for the initial restore of the instances to complete first.
+func (im *instanceManager) ApplyFleet(fleet *Fleet, opts ApplyOptions) (*FleetPlan, error) {
+	im.waitAllRestored()
+	return im.applyFleet(fleet, opts, "")
+}
+
+// applyFleet is ApplyFleet on behalf of actor
+func (im *instanceManager) applyFleet(fleet *Fleet, opts ApplyOptions, actor string) (*FleetPlan, error) {
+	im.fleetMu.Lock()
+	defer im.fleetMu.Unlock()
+
+	plan := &FleetPlan{Checksum: fleet.Checksum, DryRun: opts.DryRun, Actions: []FleetAction{}}
+	origin := "fleet sha256:" + fleet.Checksum
+	if fleet.fromConfig {
+		origin = "config"
+	}
+
+	listed := make(map[string]bool, len(fleet.Instances))
+	for _, desired := range fleet.Instances {
+		listed[desired.Name] = true
+		if opts.DryRun {
+			plan.Actions = append(plan.Actions, im.planFleetInstance(desired, fleet.fromConfig)...)
+			continue
+		}
+		for _, action := range im.planFleetInstance(desired, fleet.fromConfig) {
+			if action.Error == "" {
+				if err := im.applyFleetAction(desired, action, fleet.fromConfig, actor); err != nil {
+					action.Error = err.Error()
+				} else {
+					im.recordAudit(actor, "apply_"+action.Action, action.Instance, origin)
+				}
+			}
+			plan.Actions = append(plan.Actions, action)
+			if action.Error != "" {
+				// Later actions of the instance depend on this one
+				break
+			}
+		}
+	}
+
+	instances, err := im.ListInstances()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+	for _, inst := range instances {
+		// Instances defined in the configuration file are left to it
+		if listed[inst.Name] || inst.ManagedBy() == instance.ManagedByConfig {
+			continue
+		}
+		if !opts.Prune {
+			plan.Unlisted = append(plan.Unlisted, inst.Name)
+			continue
+		}
+		action := FleetAction{Action: FleetDelete, Instance: inst.Name}
+		if !opts.DryRun {
+			if err := im.pruneInstance(inst, actor); err != nil {
+				action.Error = err.Error()
+			} else {
+				im.recordAudit(actor, "apply_"+action.Action, action.Instance, origin)
+			}
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	return plan, nil
+}
+
+// planFleetInstance returns the actions that bring an instance to its desired state. Only
+// the static instances of the configuration file can update config-managed instances.
+func (im *instanceManager) planFleetInstance(desired FleetInstance, fromConfig bool) []FleetAction {
+	fail := func(action string, err error) []FleetAction {
+		failed := FleetAction{Action: action, Instance: desired.Name, Error: err.Error()}
+		var validationErr *validation.ValidationError
+		if errors.As(err, &validationErr) {
+			failed.FieldErrors = validationErr.Errors
+		}
+		return []FleetAction{failed}
+	}
+
+	im.mu.RLock()
+	inst, exists := im.instances[desired.Name]
+	im.mu.RUnlock()
+
+	if !exists {
+		if err := im.validateOptions(desired.Options); err != nil {
+			return fail(FleetCreate, err)
+		}
+		actions := []FleetAction{{Action: FleetCreate, Instance: desired.Name}}
+		if desired.State == FleetStateRunning && desired.Options.IsManaged() {
+			actions = append(actions, FleetAction{Action: FleetStart, Instance: desired.Name})
+		}
+		return actions
+	}
+
+	var actions []FleetAction
+	options, _, err := im.fleetOptions(inst, desired.Options)
+	if err != nil {
+		return fail(FleetUpdate, err)
+	}
+	effective, err := im.effectiveFleetOptions(inst, desired.Options)
+	if err != nil {
+		return fail(FleetUpdate, err)
+	}
+	changes, err := instance.DiffOptions(inst.GetOptions(), effective)
+	if err != nil {
+		return fail(FleetUpdate, err)
+	}
+	running := inst.IsRunning()
+	if len(changes) > 0 {
+		if err := im.validateOptions(desired.Options); err != nil {
+			return fail(FleetUpdate, err)
+		}
+		if !fromConfig {
+			if err := im.checkConfigUpdate(desired.Name); err != nil {
+				return fail(FleetUpdate, err)
+			}
+		}
+		actions = append(actions, FleetAction{
+			Action:          FleetUpdate,
+			Instance:        desired.Name,
+			Changes:         changes,
+			RestartRequired: running && inst.IsManaged(),
+		})
+	}
+
+	// External backends are not started or stopped by llamactl
+	if !options.IsManaged() {
+		return actions
+	}
+	switch {
+	case desired.State == FleetStateRunning && !running:
+		actions = append(actions, FleetAction{Action: FleetStart, Instance: desired.Name})
+	case desired.State == FleetStateStopped && running:
+		actions = append(actions, FleetAction{Action: FleetStop, Instance: desired.Name})
+	}
+	return actions
+}
+
+// fleetOptions returns a copy of the options of a fleet instance for an existing instance,
+// keeping the port llamactl assigned when the fleet does not set one
+func (im *instanceManager) fleetOptions(inst *instance.Process, options *instance.CreateInstanceOptions) (*instance.CreateInstanceOptions, bool, error) {
+	desired, err := copyOptions(options)
+	if err != nil {
+		return nil, false, err
+	}
+
+	keptPort := false
+	if im.getPortFromOptions(desired) == 0 {
+		if current := inst.GetOptions(); current != nil && current.BackendType == desired.BackendType {
+			if port := im.getPortFromOptions(current); port != 0 {
+				im.setPortInOptions(desired, port)
+				keptPort = true
+			}
+		}
+	}
+	return desired, keptPort, nil
+}
+
+// effectiveFleetOptions returns the options of a fleet instance for an existing instance as
+// they would be stored, with the defaults applied
+func (im *instanceManager) effectiveFleetOptions(inst *instance.Process, options *instance.CreateInstanceOptions) (*instance.CreateInstanceOptions, error) {
+	desired, _, err := im.fleetOptions(inst, options)
+	if err != nil {
+		return nil, err
+	}
+	effective, err := copyOptions(desired)
+	if err != nil {
+		return nil, err
+	}
+	effective.ValidateAndApplyDefaults(inst.Name, &im.instancesConfig)
+	return effective, nil
+}
+
+func copyOptions(options *instance.CreateInstanceOptions) (*instance.CreateInstanceOptions, error) {
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy options: %w", err)
+	}
+	copied := &instance.CreateInstanceOptions{}
+	if err := json.Unmarshal(data, copied); err != nil {
+		return nil, fmt.Errorf("failed to copy options: %w", err)
+	}
+	return copied, nil
+}
+
+func (im *instanceManager) applyFleetAction(desired FleetInstance, action FleetAction, fromConfig bool, actor string) error {
+	var err error
+	switch action.Action {
+	case FleetCreate:
+		_, err = im.createInstance(desired.Name, desired.Options, actor)
+	case FleetUpdate:
+		im.mu.RLock()
+		inst, exists := im.instances[desired.Name]
+		im.mu.RUnlock()
+		if !exists {
+			return fmt.Errorf("instance with name %s not found", desired.Name)
+		}
+		options, keptPort, optErr := im.fleetOptions(inst, desired.Options)
+		if optErr != nil {
+			return optErr
+		}
+		if !fromConfig {
+			if err := im.checkConfigUpdate(desired.Name); err != nil {
+				return err
+			}
+		}
+		if _, err = im.updateInstance(desired.Name, options, actor, true); err == nil && keptPort {
+			inst.MarkInferred("backend_options.port")
+		}
+	case FleetStart:
+		_, err = im.startInstance(desired.Name, actor, OperationOptions{})
+	case FleetStop:
+		_, err = im.stopInstance(desired.Name, instance.ReasonUserStop, "", actor, OperationOptions{})
+	}
+	return err
+}
+
+// pruneInstance stops an instance that is not listed in the fleet and deletes it
+func (im *instanceManager) pruneInstance(inst *instance.Process, actor string) error {
+	if inst.IsRunning() && inst.IsManaged() {
+		if _, err := im.stopInstance(inst.Name, instance.ReasonUserStop, "", actor, OperationOptions{}); err != nil {
+			return err
+		}
+	}
+	return im.deleteInstance(inst.Name, DeleteOptions{}, actor)
+}
+
+// FleetWatcher polls a fleet file and applies it, so that the instances follow the file
+// and drift is reverted
+type FleetWatcher struct {
+	im     InstanceManager
+	source string
+	prune  bool
+
+	mu        sync.RWMutex
+	lastPlan  *FleetPlan
+	lastError string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewFleetWatcher creates a watcher applying the fleet file at source, a path or an http(s) URL
+func NewFleetWatcher(im InstanceManager, source string, prune bool) *FleetWatcher {
+	return &FleetWatcher{im: im, source: source, prune: prune}
+}
+
+// Start applies the fleet in the background, then again every interval
+func (fw *FleetWatcher) Start(interval time.Duration) {
+	fw.stop = make(chan struct{})
+	fw.done = make(chan struct{})
+
+	go func() {
+		defer close(fw.done)
+		fw.Reconcile()
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				fw.Reconcile()
+			case <-fw.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends polling and waits for a running reconciliation to finish
+func (fw *FleetWatcher) Stop() {
+	if fw.stop == nil {
+		return
+	}
+	close(fw.stop)
+	<-fw.done
+	fw.stop = nil
+}
+
+// Reconcile loads the fleet file and applies it. Failures are logged and kept for Last.
+func (fw *FleetWatcher) Reconcile() {
+	plan, err := fw.reconcile()
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if err != nil {
+		log.Printf("Failed to apply fleet %s: %v", fw.source, err)
+		fw.lastError = err.Error()
+		return
+	}
+	fw.lastPlan, fw.lastError = plan, ""
+	for _, action := range plan.Actions {
+		if action.Error != "" {
+			log.Printf("Failed to %s instance %s from fleet %s: %s", action.Action, action.Instance, fw.source, action.Error)
+		}
+	}
+}
+
+func (fw *FleetWatcher) reconcile() (*FleetPlan, error) {
+	data, err := LoadFleetSource(fw.source)
+	if err != nil {
+		return nil, err
+	}
+	fleet, err := ParseFleet(data)
+	if err != nil {
+		return nil, err
+	}
+	return fw.im.ApplyFleet(fleet, ApplyOptions{Prune: fw.prune})
+}
+
+// Last returns the plan of the most recent successful reconciliation and the error of the
+// most recent one, if it failed
+func (fw *FleetWatcher) Last() (*FleetPlan, string) {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	return fw.lastPlan, fw.lastError
+}
@@ -0,0 +1,223 @@
+package manager_test
+
+import (
+	"llamactl/pkg/config"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFleet = `
+instances:
+  - name: chat
+    options:
+      backend_type: llama_cpp
+      backend_options:
+        model: /models/chat.gguf
+  - name: embed
+    state: stopped
+    options:
+      backend_type: llama_cpp
+      tags: [embeddings]
+      backend_options:
+        model: /models/embed.gguf
+        port: 8123
+`
+
+func newFleetManager(t *testing.T) (manager.InstanceManager, storage.Store) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{
+			Command: "sh",
+			Args:    []string{"-c", "exec sleep 30"},
+		},
+	}
+	dir := t.TempDir()
+	cfg := config.InstancesConfig{
+		PortRange:             [2]int{8000, 9000},
+		InstancesDir:          filepath.Join(dir, "instances"),
+		LogsDir:               filepath.Join(dir, "logs"),
+		MaxInstances:          10,
+		MaxRunningInstances:   -1,
+		DefaultAutoRestart:    true,
+		DefaultMaxRestarts:    3,
+		TimeoutCheckInterval:  config.Duration(5 * time.Minute),
+		AllowInstanceCommands: true,
+	}
+	store := storage.NewFileStore(cfg.InstancesDir, dir)
+	mngr := manager.NewInstanceManagerWithStore(backendConfig, cfg, store)
+	t.Cleanup(mngr.Shutdown)
+	return mngr, store
+}
+
+func applyFleet(t *testing.T, mngr manager.InstanceManager, data string, opts manager.ApplyOptions) *manager.FleetPlan {
+	t.Helper()
+	fleet, err := manager.ParseFleet([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseFleet failed: %v", err)
+	}
+	plan, err := mngr.ApplyFleet(fleet, opts)
+	if err != nil {
+		t.Fatalf("ApplyFleet failed: %v", err)
+	}
+	return plan
+}
+
+// planSummary renders the actions of a plan as "action instance" strings
+func planSummary(plan *manager.FleetPlan) []string {
+	var summary []string
+	for _, action := range plan.Actions {
+		entry := action.Action + " " + action.Instance
+		if len(action.Changes) > 0 {
+			entry += " " + strings.Join(action.Changes, ",")
+		}
+		if action.Error != "" {
+			entry += " failed"
+		}
+		summary = append(summary, entry)
+	}
+	return summary
+}
+
+func TestParseFleet(t *testing.T) {
+	fleet, err := manager.ParseFleet([]byte(testFleet))
+	if err != nil {
+		t.Fatalf("ParseFleet failed: %v", err)
+	}
+	if len(fleet.Instances) != 2 || len(fleet.Checksum) != 64 {
+		t.Fatalf("Expected 2 instances and a SHA-256 checksum, got %+v", fleet)
+	}
+	chat, embed := fleet.Instances[0], fleet.Instances[1]
+	if chat.State != manager.FleetStateRunning || chat.Options.LlamaServerOptions.Model != "/models/chat.gguf" {
+		t.Errorf("Unexpected chat instance: %+v", chat)
+	}
+	if embed.State != manager.FleetStateStopped || embed.Options.LlamaServerOptions.Port != 8123 || !slices.Equal(embed.Options.Tags, []string{"embeddings"}) {
+		t.Errorf("Unexpected embed instance: %+v", embed)
+	}
+
+	invalid := map[string]string{
+		"not yaml":      "instances: [",
+		"missing name":  "instances:\n  - options: {backend_type: llama_cpp}",
+		"invalid name":  "instances:\n  - name: ../etc\n",
+		"duplicate":     "instances:\n  - name: a\n  - name: a\n",
+		"unknown state": "instances:\n  - name: a\n    state: paused\n",
+		"bad options":   "instances:\n  - name: a\n    options: {backend_type: llama_cpp, backend_options: {port: high}}\n",
+	}
+	for name, data := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := manager.ParseFleet([]byte(data)); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestApplyFleet(t *testing.T) {
+	mngr, store := newFleetManager(t)
+
+	// An instance the file does not know about
+	extra := applyFleet(t, mngr, "instances:\n  - name: extra\n    state: stopped\n    options: {backend_type: llama_cpp, backend_options: {model: /models/x.gguf}}\n", manager.ApplyOptions{})
+	if got := planSummary(extra); !slices.Equal(got, []string{"create extra"}) {
+		t.Fatalf("Unexpected plan %v", got)
+	}
+
+	// A dry run reports the plan without changing anything
+	plan := applyFleet(t, mngr, testFleet, manager.ApplyOptions{DryRun: true})
+	if got, expected := planSummary(plan), []string{"create chat", "start chat", "create embed"}; !slices.Equal(got, expected) {
+		t.Errorf("Expected plan %v, got %v", expected, got)
+	}
+	if !slices.Equal(plan.Unlisted, []string{"extra"}) {
+		t.Errorf("Expected extra to be reported as unlisted, got %v", plan.Unlisted)
+	}
+	if _, err := mngr.GetInstance("chat"); err == nil {
+		t.Error("Expected a dry run not to create instances")
+	}
+
+	plan = applyFleet(t, mngr, testFleet, manager.ApplyOptions{})
+	if plan.Failed() {
+		t.Fatalf("Expected the fleet to apply, got %+v", plan.Actions)
+	}
+	chat, err := mngr.GetInstance("chat")
+	if err != nil || !chat.IsRunning() {
+		t.Fatalf("Expected chat to be created and running, got %v", err)
+	}
+
+	// Applying the same file again changes nothing, the assigned port is not drift
+	if got := planSummary(applyFleet(t, mngr, testFleet, manager.ApplyOptions{})); len(got) != 0 {
+		t.Errorf("Expected no actions on a reapply, got %v", got)
+	}
+
+	// Drift is reverted: a changed option updates the instance, restarting it
+	port := chat.GetPort()
+	changed := strings.Replace(testFleet, "/models/chat.gguf", "/models/chat-v2.gguf", 1)
+	plan = applyFleet(t, mngr, changed, manager.ApplyOptions{})
+	if len(plan.Actions) != 1 || plan.Actions[0].Action != manager.FleetUpdate || !plan.Actions[0].RestartRequired {
+		t.Fatalf("Expected a single update requiring a restart, got %+v", plan.Actions)
+	}
+	if !slices.Equal(plan.Actions[0].Changes, []string{"backend_options.model"}) {
+		t.Errorf("Expected only the model to change, got %v", plan.Actions[0].Changes)
+	}
+	if chat.GetOptions().LlamaServerOptions.Model != "/models/chat-v2.gguf" || chat.GetPort() != port || !chat.IsRunning() {
+		t.Errorf("Expected chat to run the new model on port %d, got %+v", port, chat.GetOptions().LlamaServerOptions)
+	}
+
+	// A stopped instance is started again
+	if _, err := mngr.StopInstance("chat"); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+	if got := planSummary(applyFleet(t, mngr, changed, manager.ApplyOptions{})); !slices.Equal(got, []string{"start chat"}) {
+		t.Errorf("Expected chat to be started, got %v", got)
+	}
+
+	// Pruning deletes the unlisted instance
+	if got := planSummary(applyFleet(t, mngr, changed, manager.ApplyOptions{Prune: true})); !slices.Equal(got, []string{"delete extra"}) {
+		t.Errorf("Expected extra to be pruned, got %v", got)
+	}
+	if _, err := mngr.GetInstance("extra"); err == nil {
+		t.Error("Expected extra to be deleted")
+	}
+
+	// Applied changes are audited with the checksum of the file
+	fleet, _ := manager.ParseFleet([]byte(changed))
+	records, err := store.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit failed: %v", err)
+	}
+	var audited []string
+	for _, record := range records {
+		if record.Details == "fleet sha256:"+fleet.Checksum {
+			audited = append(audited, record.Action+" "+record.Target)
+		}
+	}
+	if expected := []string{"apply_update chat", "apply_start chat", "apply_delete extra"}; !slices.Equal(audited, expected) {
+		t.Errorf("Expected audit entries %v, got %v", expected, audited)
+	}
+}
+
+func TestApplyFleet_InvalidInstance(t *testing.T) {
+	mngr, _ := newFleetManager(t)
+
+	data := "instances:\n" +
+		"  - name: wrapped\n    state: stopped\n    options: {backend_type: llama_cpp, launch_wrapper: [\"\"], backend_options: {model: /models/a.gguf}}\n" +
+		"  - name: fine\n    state: stopped\n    options: {backend_type: llama_cpp, backend_options: {model: /models/b.gguf}}\n"
+	for _, dryRun := range []bool{true, false} {
+		plan := applyFleet(t, mngr, data, manager.ApplyOptions{DryRun: dryRun})
+		if got, expected := planSummary(plan), []string{"create wrapped failed", "create fine"}; !slices.Equal(got, expected) {
+			t.Errorf("dry run %v: expected plan %v, got %v", dryRun, expected, got)
+		}
+		if fieldErrs := plan.Actions[0].FieldErrors; len(fieldErrs) != 1 || fieldErrs[0].Field != "launch_wrapper[0]" {
+			t.Errorf("dry run %v: expected launch_wrapper[0] to be reported invalid, got %+v", dryRun, fieldErrs)
+		}
+	}
+	if _, err := mngr.GetInstance("fine"); err != nil {
+		t.Errorf("Expected the valid instance to be created: %v", err)
+	}
+}
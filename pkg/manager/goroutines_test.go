@@ -0,0 +1,83 @@
+package manager_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"runtime"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestGoroutines_NoLeaksAfterDelete(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{
+			Command: "sh",
+			Args:    []string{"-c", "exec sleep 30"},
+		},
+	}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		LogsDir:              t.TempDir(),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	mngr := manager.NewInstanceManager(backendConfig, cfg)
+	defer mngr.Shutdown()
+
+	options := func(model string) *instance.CreateInstanceOptions {
+		return &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: model, Port: 8080},
+		}
+	}
+
+	inst, err := mngr.CreateInstance("leak-test", options("/path/to/model.gguf"))
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	// Event subscribers attach and detach while the instance changes status
+	events, unsubscribe := mngr.SubscribeEvents()
+
+	if _, err := mngr.StartInstance("leak-test"); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	// Updating the options of a running instance and applying them restarts it
+	if _, err := mngr.UpdateInstance("leak-test", options("/path/to/other.gguf")); err != nil {
+		t.Fatalf("UpdateInstance failed: %v", err)
+	}
+	if _, err := mngr.ApplyPendingOptions("leak-test"); err != nil {
+		t.Fatalf("ApplyPendingOptions failed: %v", err)
+	}
+	if _, err := mngr.RestartInstance("leak-test"); err != nil {
+		t.Fatalf("RestartInstance failed: %v", err)
+	}
+	if _, err := mngr.StopInstance("leak-test"); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+
+	unsubscribe()
+	for range events {
+		// Drain buffered events until the channel is closed
+	}
+
+	if err := mngr.DeleteInstance("leak-test"); err != nil {
+		t.Fatalf("DeleteInstance failed: %v", err)
+	}
+
+	stats := inst.GetGoroutineStats()
+	if stats.Active != 0 || stats.Started != 12 {
+		t.Errorf("Expected 12 goroutines over three runs and none active after delete, got %+v", stats)
+	}
+}
@@ -0,0 +1,37 @@
+package manager
+
+import (
+	"fmt"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+)
+
+// reportGPUFaults publishes a gpu_fault event for every instance whose backend started
+// failing requests with GPU errors since the last check
+func (im *instanceManager) reportGPUFaults() {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	im.mu.RUnlock()
+
+	for _, inst := range instances {
+		fault := inst.TakeGPUFault()
+		if fault == nil {
+			continue
+		}
+		im.events.Publish(events.Event{
+			Type:     events.TypeGPUFault,
+			Instance: inst.Name,
+			Code:     inst.GetOptions().GPUFaultPolicy(),
+			Message:  fmt.Sprintf("%d responses with GPU errors within %s", fault.Errors, fault.Window),
+			Data: map[string]any{
+				"errors":      fault.Errors,
+				"window":      fault.Window,
+				"sample":      fault.Sample,
+				"detected_at": fault.DetectedAt,
+			},
+		})
+	}
+}
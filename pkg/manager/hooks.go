@@ -0,0 +1,216 @@
+package manager
+
+import (
+	"llamactl/pkg/instance"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// hookQueueSize is the number of notifications pending for a registration of hooks past
+// which new ones are dropped
+const hookQueueSize = 1024
+
+// hookDrainTimeout bounds how long Shutdown waits for the pending notifications to be delivered
+const hookDrainTimeout = 5 * time.Second
+
+// StateChange is the transition of an instance from one status to another
+type StateChange struct {
+	Instance  string
+	OldStatus instance.InstanceStatus
+	NewStatus instance.InstanceStatus
+	Reason    instance.StatusReason
+}
+
+// Crash is an unexpected exit of the backend of an instance
+type Crash struct {
+	Instance string
+	Exit     instance.LastExit
+}
+
+// RestartScheduled is an automatic restart of a crashed instance, pending until At
+type RestartScheduled struct {
+	Instance string
+	Attempt  int // Restarts since the instance was last started by hand, this one included
+	At       time.Time
+}
+
+// Ready is an instance becoming running once its backend passed its readiness check
+type Ready struct {
+	Instance  string
+	Timestamp time.Time
+}
+
+// LogLine is a line the backend of an instance wrote to stdout or stderr
+type LogLine struct {
+	Instance string
+	instance.LogLine
+}
+
+// Hooks receives the lifecycle moments of the instances of a manager, for programs that
+// embed it. Embed NopHooks to implement only some of the methods.
+//
+// Hooks are called asynchronously, one at a time, from a goroutine of their registration:
+//   - Moments are delivered in the order the manager observed them. For an instance, a
+//     crash precedes the state change it caused, which precedes the restart scheduled
+//     after it; a ready follows the state change to running it comes with.
+//   - Log lines of a stream come in the order they were written, but are not ordered
+//     against the other moments, nor against the lines of the other stream.
+//   - A hook that panics is recovered and logged; the next moments are still delivered.
+//   - A slow hook only delays its own registration. Once hookQueueSize moments are
+//     pending, new ones are dropped and logged rather than blocking the manager.
+type Hooks interface {
+	OnStateChange(change StateChange)
+	OnCrash(crash Crash)
+	OnRestartScheduled(restart RestartScheduled)
+	OnReady(ready Ready)
+	OnLogLine(line LogLine)
+}
+
+// NopHooks implements Hooks by ignoring every moment
+type NopHooks struct{}
+
+func (NopHooks) OnStateChange(StateChange)           {}
+func (NopHooks) OnCrash(Crash)                       {}
+func (NopHooks) OnRestartScheduled(RestartScheduled) {}
+func (NopHooks) OnReady(Ready)                       {}
+func (NopHooks) OnLogLine(LogLine)                   {}
+
+// hookDispatcher delivers the moments of a registration of hooks from its own goroutine
+type hookDispatcher struct {
+	hooks   Hooks
+	queue   chan func(Hooks)
+	done    chan struct{}
+	dropped int64 // Guarded by the lock of the registry
+}
+
+func (d *hookDispatcher) run() {
+	defer close(d.done)
+	for notify := range d.queue {
+		d.call(notify)
+	}
+}
+
+// call invokes a hook, recovering from its panics
+func (d *hookDispatcher) call(notify func(Hooks)) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Lifecycle hook panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	notify(d.hooks)
+}
+
+// hookRegistry holds the registrations of hooks of a manager
+type hookRegistry struct {
+	mu          sync.Mutex
+	dispatchers map[*hookDispatcher]struct{}
+	closed      bool
+}
+
+// add registers hooks, returning the function that removes them
+func (r *hookRegistry) add(hooks Hooks) func() {
+	d := &hookDispatcher{hooks: hooks, queue: make(chan func(Hooks), hookQueueSize), done: make(chan struct{})}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return func() {}
+	}
+	if r.dispatchers == nil {
+		r.dispatchers = map[*hookDispatcher]struct{}{}
+	}
+	r.dispatchers[d] = struct{}{}
+	go d.run()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if _, ok := r.dispatchers[d]; ok {
+				delete(r.dispatchers, d)
+				close(d.queue)
+			}
+		})
+	}
+}
+
+// notify queues a moment for every registration, dropping it for those that are full. The
+// lock is held while queueing so that registrations see the moments in the same order.
+func (r *hookRegistry) notify(notify func(Hooks)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for d := range r.dispatchers {
+		select {
+		case d.queue <- notify:
+		default:
+			d.dropped++
+			if d.dropped == 1 || d.dropped%hookQueueSize == 0 {
+				log.Printf("Lifecycle hooks are not keeping up, %d moments dropped", d.dropped)
+			}
+		}
+	}
+}
+
+// close removes every registration, waiting up to hookDrainTimeout for the moments pending
+// to be delivered
+func (r *hookRegistry) close() {
+	r.mu.Lock()
+	r.closed = true
+	dispatchers := r.dispatchers
+	r.dispatchers = nil
+	for d := range dispatchers {
+		close(d.queue)
+	}
+	r.mu.Unlock()
+
+	deadline := time.After(hookDrainTimeout)
+	for d := range dispatchers {
+		select {
+		case <-d.done:
+		case <-deadline:
+			log.Printf("Lifecycle hooks did not return within %v, not waiting for them", hookDrainTimeout)
+			return
+		}
+	}
+}
+
+// AddHooks registers hooks that receive the lifecycle moments of every instance from now
+// on. The returned function removes them.
+func (im *instanceManager) AddHooks(hooks Hooks) func() {
+	return im.hooks.add(hooks)
+}
+
+// observeInstance forwards the moments of an instance that its status changes do not carry
+// to the hooks and the event bus
+func (im *instanceManager) observeInstance(inst *instance.Process) {
+	name := inst.Name
+	inst.SetObserver(&instance.Observer{
+		Crashed: func(exit instance.LastExit) {
+			im.hooks.notify(func(h Hooks) { h.OnCrash(Crash{Instance: name, Exit: exit}) })
+		},
+		RestartScheduled: func(attempt int, at time.Time) {
+			im.hooks.notify(func(h Hooks) {
+				h.OnRestartScheduled(RestartScheduled{Instance: name, Attempt: attempt, At: at})
+			})
+		},
+		LogLine: func(line instance.LogLine) {
+			im.hooks.notify(func(h Hooks) { h.OnLogLine(LogLine{Instance: name, LogLine: line}) })
+		},
+		StartupProgress: func(progress instance.StartupProgress) {
+			im.reportStartupProgress(name, progress)
+		},
+		LogRotated: im.requestLogQuotaCheck,
+	})
+}
+
+// notifyStateChange forwards a status change of an instance to the hooks
+func (im *instanceManager) notifyStateChange(name string, oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {
+	change := StateChange{Instance: name, OldStatus: oldStatus, NewStatus: newStatus, Reason: reason}
+	im.hooks.notify(func(h Hooks) { h.OnStateChange(change) })
+	if newStatus == instance.Running && oldStatus != instance.Running {
+		ready := Ready{Instance: name, Timestamp: reason.Timestamp}
+		im.hooks.notify(func(h Hooks) { h.OnReady(ready) })
+	}
+}
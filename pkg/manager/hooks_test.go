@@ -0,0 +1,184 @@
+package manager_test
+
+import (
+	"fmt"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingHooks records the moments it receives, panicking on the first ready
+type recordingHooks struct {
+	mu       sync.Mutex
+	moments  []string
+	lines    []string
+	panicked bool
+}
+
+func (h *recordingHooks) record(moment string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.moments = append(h.moments, moment)
+}
+
+func (h *recordingHooks) OnStateChange(change manager.StateChange) {
+	h.record(fmt.Sprintf("%s: %s -> %s", change.Instance, change.OldStatus, change.NewStatus))
+}
+
+func (h *recordingHooks) OnCrash(crash manager.Crash) {
+	h.record(fmt.Sprintf("%s: crash %d", crash.Instance, crash.Exit.ExitCode))
+}
+
+func (h *recordingHooks) OnRestartScheduled(restart manager.RestartScheduled) {
+	h.record(fmt.Sprintf("%s: restart %d", restart.Instance, restart.Attempt))
+}
+
+func (h *recordingHooks) OnReady(ready manager.Ready) {
+	h.record(ready.Instance + ": ready")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.panicked {
+		h.panicked = true
+		panic("hook failure")
+	}
+}
+
+func (h *recordingHooks) OnLogLine(line manager.LogLine) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lines = append(h.lines, line.Stream+": "+line.Line)
+}
+
+func (h *recordingHooks) snapshot() ([]string, []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.moments), slices.Clone(h.lines)
+}
+
+// newHooksManager creates a manager running script as the backend of its instances, with a
+// health endpoint that always passes
+func newHooksManager(t *testing.T, script string, opts ...manager.Option) (manager.InstanceManager, *instance.CreateInstanceOptions) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	backendConfig := config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", script}}}
+	cfg := config.InstancesConfig{
+		PortRange:              [2]int{8000, 9000},
+		LogsDir:                t.TempDir(),
+		MaxInstances:           10,
+		MaxRunningInstances:    -1,
+		ReadinessProbeInterval: config.Duration(20 * time.Millisecond),
+		TimeoutCheckInterval:   config.Duration(5 * time.Minute),
+	}
+	mngr := manager.New(backendConfig, cfg, append([]manager.Option{manager.WithStore(nil)}, opts...)...)
+	t.Cleanup(mngr.Shutdown)
+
+	options := &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  backendURL.Hostname(),
+			Port:  port,
+		},
+	}
+	return mngr, options
+}
+
+func TestHooks_Lifecycle(t *testing.T) {
+	hooks := &recordingHooks{}
+	mngr, options := newHooksManager(t, "echo loading; sleep 0.3; exit 3", manager.WithHooks(hooks))
+	autoRestart, maxRestarts, restartDelay := true, 1, config.Duration(0)
+	options.AutoRestart, options.MaxRestarts, options.RestartDelay = &autoRestart, &maxRestarts, &restartDelay
+
+	if _, err := mngr.CreateInstance("hooked", options); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.StartInstance("hooked"); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	waitFor(t, "the instance to fail", func() bool {
+		moments, _ := hooks.snapshot()
+		return slices.Contains(moments, "hooked: stopped -> failed")
+	})
+
+	// Each crash precedes its state change, and the ready that panicked did not stop delivery
+	mngr.Shutdown()
+	moments, lines := hooks.snapshot()
+	expected := []string{
+		"hooked: stopped -> starting",
+		"hooked: starting -> running",
+		"hooked: ready",
+		"hooked: crash 3",
+		"hooked: running -> restarting",
+		"hooked: restart 1",
+		"hooked: restarting -> starting",
+		"hooked: starting -> running",
+		"hooked: ready",
+		"hooked: crash 3",
+		"hooked: running -> stopped",
+		"hooked: stopped -> failed",
+	}
+	if !slices.Equal(moments, expected) {
+		t.Errorf("Expected moments %q, got %q", expected, moments)
+	}
+	if !slices.Equal(lines, []string{"stdout: loading", "stdout: loading"}) {
+		t.Errorf("Expected the line of each start, got %q", lines)
+	}
+}
+
+// blockingHooks blocks on every log line until unblocked
+type blockingHooks struct {
+	manager.NopHooks
+	unblock chan struct{}
+}
+
+func (h blockingHooks) OnLogLine(manager.LogLine) {
+	<-h.unblock
+}
+
+func TestHooks_SlowHooksDoNotBlock(t *testing.T) {
+	mngr, options := newHooksManager(t, "seq 5000")
+	hooks := blockingHooks{unblock: make(chan struct{})}
+	remove := mngr.AddHooks(hooks)
+	t.Cleanup(func() { close(hooks.unblock) })
+
+	sub, unsubscribe := mngr.SubscribeEvents()
+	defer unsubscribe()
+	if _, err := mngr.CreateInstance("chatty", options); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.StartInstance("chatty"); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+
+	// The lines past the queue of the blocked hooks are dropped rather than holding the backend
+	timeout := time.After(5 * time.Second)
+	for stopped := false; !stopped; {
+		select {
+		case event := <-sub:
+			stopped = event.Type == events.TypeStatusChange && event.Data["new_status"] == "stopped"
+		case <-timeout:
+			t.Fatal("Timed out waiting for the instance to exit")
+		}
+	}
+	remove()
+	remove()
+}
@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"fmt"
+	"llamactl/pkg/config"
+	"slices"
+	"sort"
+)
+
+// Stop impact verdicts
+const (
+	StopSafe       = "safe"
+	StopDisruptive = "disruptive"
+)
+
+// StopImpact describes what would break if an instance were stopped now
+type StopImpact struct {
+	Instance string          `json:"instance"`
+	Verdict  string          `json:"verdict"`
+	Running  bool            `json:"running"`
+	InFlight int64           `json:"in_flight"`
+	Queued   int             `json:"queued"`
+	Services []ServiceImpact `json:"services,omitempty"`
+	// Services that depend on any of the services the instance is a member of
+	DependentServices []string `json:"dependent_services,omitempty"`
+	Reasons           []string `json:"reasons,omitempty"`
+}
+
+// ServiceImpact describes how stopping an instance affects a service it is a member of
+type ServiceImpact struct {
+	Service string `json:"service"`
+	// Ready members other than the instance being stopped
+	RemainingReady int  `json:"remaining_ready"`
+	TotalMembers   int  `json:"total_members"`
+	CanAbsorb      bool `json:"can_absorb"`
+	// Services that depend, directly or transitively, on this service
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+// EvaluateStopImpact reports the in-flight work of an instance, the services routing to it,
+// whether their other members can absorb its traffic, and which services depend on them.
+// Stopping is disruptive when requests would be cut off or a service would lose its last ready member.
+func EvaluateStopImpact(im InstanceManager, services map[string]config.ServiceConfig, name string) (*StopImpact, error) {
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+
+	impact := &StopImpact{
+		Instance: name,
+		Verdict:  StopSafe,
+		Running:  inst.IsRunning(),
+		InFlight: inst.GetStats().InFlight,
+		Queued:   inst.GetQueueStats().Queued,
+	}
+
+	if !impact.Running {
+		impact.Reasons = append(impact.Reasons, "instance is not running")
+		return impact, nil
+	}
+
+	if impact.InFlight > 0 {
+		impact.disrupt(fmt.Sprintf("%d requests in flight would be cut off", impact.InFlight))
+	}
+	if impact.Queued > 0 {
+		impact.disrupt(fmt.Sprintf("%d queued requests would be rejected", impact.Queued))
+	}
+
+	dependents := map[string]bool{}
+	for _, alias := range sortedServices(services) {
+		svc := services[alias]
+		if !slices.Contains(svc.Members, name) {
+			continue
+		}
+
+		svcImpact := ServiceImpact{
+			Service:      alias,
+			TotalMembers: len(svc.Members),
+			Dependents:   serviceDependents(services, alias),
+		}
+		for _, member := range svc.Members {
+			if member == name {
+				continue
+			}
+			if other, err := im.GetInstance(member); err == nil && other.IsReady() {
+				svcImpact.RemainingReady++
+			}
+		}
+		svcImpact.CanAbsorb = svcImpact.RemainingReady > 0
+
+		if !svcImpact.CanAbsorb {
+			reason := fmt.Sprintf("service %s would have no ready members", alias)
+			if len(svcImpact.Dependents) > 0 {
+				reason += fmt.Sprintf(", taking down dependent services %v", svcImpact.Dependents)
+			}
+			impact.disrupt(reason)
+		}
+		for _, dep := range svcImpact.Dependents {
+			dependents[dep] = true
+		}
+
+		impact.Services = append(impact.Services, svcImpact)
+	}
+	for dep := range dependents {
+		impact.DependentServices = append(impact.DependentServices, dep)
+	}
+	sort.Strings(impact.DependentServices)
+
+	return impact, nil
+}
+
+func (s *StopImpact) disrupt(reason string) {
+	s.Verdict = StopDisruptive
+	s.Reasons = append(s.Reasons, reason)
+}
+
+// serviceDependents returns the services that depend on alias, directly or through other services
+func serviceDependents(services map[string]config.ServiceConfig, alias string) []string {
+	seen := map[string]bool{alias: true}
+	queue := []string{alias}
+	var dependents []string
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, other := range sortedServices(services) {
+			if !seen[other] && slices.Contains(services[other].DependsOn, current) {
+				seen[other] = true
+				dependents = append(dependents, other)
+				queue = append(queue, other)
+			}
+		}
+	}
+
+	sort.Strings(dependents)
+	return dependents
+}
+
+func sortedServices(services map[string]config.ServiceConfig) []string {
+	aliases := make([]string, 0, len(services))
+	for alias := range services {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
@@ -0,0 +1,95 @@
+package manager_test
+
+import (
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"slices"
+	"testing"
+)
+
+func TestEvaluateStopImpact(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+	createServiceMembers(t, mngr, map[string]bool{
+		"chat-1":  true,
+		"chat-2":  true,
+		"embed-1": true,
+		"idle":    false,
+	})
+
+	services := map[string]config.ServiceConfig{
+		"chat":   {Members: []string{"chat-1", "chat-2"}},
+		"embed":  {Members: []string{"embed-1"}},
+		"rag":    {Members: []string{"chat-1"}, DependsOn: []string{"embed"}},
+		"search": {Members: []string{"chat-1"}, DependsOn: []string{"rag"}},
+	}
+
+	tests := []struct {
+		name       string
+		instance   string
+		verdict    string
+		dependents []string
+	}{
+		{"stopped instance", "idle", manager.StopSafe, nil},
+		{"replica can absorb traffic", "chat-2", manager.StopSafe, nil},
+		{"last member with dependents", "embed-1", manager.StopDisruptive, []string{"rag", "search"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impact, err := manager.EvaluateStopImpact(mngr, services, tt.instance)
+			if err != nil {
+				t.Fatalf("EvaluateStopImpact failed: %v", err)
+			}
+			if impact.Verdict != tt.verdict {
+				t.Errorf("Expected verdict %s, got %s (%v)", tt.verdict, impact.Verdict, impact.Reasons)
+			}
+			if !slices.Equal(impact.DependentServices, tt.dependents) {
+				t.Errorf("Expected dependent services %v, got %v", tt.dependents, impact.DependentServices)
+			}
+		})
+	}
+
+	// chat-1 is shared by chat, which chat-2 can absorb, and rag and search, where it is the only member
+	impact, err := manager.EvaluateStopImpact(mngr, services, "chat-1")
+	if err != nil {
+		t.Fatalf("EvaluateStopImpact failed: %v", err)
+	}
+	if len(impact.Services) != 3 || !impact.Services[0].CanAbsorb || impact.Services[1].CanAbsorb || impact.Services[2].CanAbsorb {
+		t.Errorf("Expected only chat to absorb, got %+v", impact.Services)
+	}
+
+	if _, err := manager.EvaluateStopImpact(mngr, services, "missing"); err == nil {
+		t.Error("Expected error for a missing instance")
+	}
+}
+
+func TestEvaluateStopImpact_StartingMemberNotReady(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+	createServiceMembers(t, mngr, map[string]bool{"chat-1": true, "chat-2": false})
+	// Still loading its model
+	loading, err := mngr.GetInstance("chat-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loading.SetStatus(instance.Starting, instance.ReasonUserStart, "")
+
+	services := map[string]config.ServiceConfig{"chat": {Members: []string{"chat-1", "chat-2"}}}
+	health, err := manager.EvaluateServiceHealth(mngr, services, "chat")
+	if err != nil {
+		t.Fatalf("EvaluateServiceHealth failed: %v", err)
+	}
+	if health.ReadyMembers != 1 || health.Members[1].Ready {
+		t.Errorf("Expected the starting member not to be ready, got %+v", health.Members)
+	}
+
+	impact, err := manager.EvaluateStopImpact(mngr, services, "chat-1")
+	if err != nil {
+		t.Fatalf("EvaluateStopImpact failed: %v", err)
+	}
+	if impact.Verdict != manager.StopDisruptive || impact.Services[0].RemainingReady != 0 {
+		t.Errorf("Expected the starting member not to absorb the traffic, got %s with %+v", impact.Verdict, impact.Services)
+	}
+}
@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"fmt"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+)
+
+// GetLBHealth returns the state of an instance for external load balancers, publishing an
+// lb_health event when it differs from the state returned last
+func (im *instanceManager) GetLBHealth(name string) (*instance.LBHealth, error) {
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+
+	health, previous := inst.GetLBHealth()
+	if previous != "" && previous != health.Status {
+		message := fmt.Sprintf("load balancer health changed from %s to %s", previous, health.Status)
+		if health.Reason != "" {
+			message += ": " + health.Reason
+		}
+		im.events.Publish(events.Event{
+			Type:     events.TypeLBHealth,
+			Instance: name,
+			Code:     health.Status,
+			Message:  message,
+			Data: map[string]any{
+				"old_status":  previous,
+				"new_status":  health.Status,
+				"reason":      health.Reason,
+				"queue_depth": health.QueueDepth,
+				"in_flight":   health.InFlight,
+			},
+		})
+	}
+	return &health, nil
+}
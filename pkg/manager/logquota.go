@@ -0,0 +1,204 @@
+package manager
+
+import (
+	"fmt"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Levels of use of the log disk quota, each published as a log_quota event when reached
+const (
+	LogQuotaOK       = "ok"
+	LogQuotaWarning  = "warning"  // The log files use 80% of the quota
+	LogQuotaCritical = "critical" // The log files use 95% of the quota
+)
+
+const (
+	logQuotaWarningPercent  = 80
+	logQuotaCriticalPercent = 95
+)
+
+// LogQuotaStatus is the disk space the log files of all instances use against the
+// log_disk_quota, as of the last check
+type LogQuotaStatus struct {
+	QuotaBytes int64              `json:"quota_bytes"` // 0 = unlimited
+	UsedBytes  int64              `json:"used_bytes"`
+	Percent    float64            `json:"percent,omitempty"` // Of the quota used, unset without a quota
+	Level      string             `json:"level"`
+	CheckedAt  *time.Time         `json:"checked_at,omitempty"`
+	Instances  []InstanceLogUsage `json:"instances"`
+}
+
+// InstanceLogUsage is the disk space the log files of an instance use against its share
+type InstanceLogUsage struct {
+	Name string `json:"name"`
+	instance.LogDiskUsage
+}
+
+// logQuotaState is the outcome of the last check of the log disk quota
+type logQuotaState struct {
+	mu     sync.Mutex
+	status LogQuotaStatus
+}
+
+// requestLogQuotaCheck has the log disk quota checked again soon, after a rotation of the
+// log files of an instance. It never blocks, a check already pending covers the rotation.
+func (im *instanceManager) requestLogQuotaCheck() {
+	select {
+	case im.logQuotaCheck <- struct{}{}:
+	default:
+	}
+}
+
+// enforceLogQuota measures the disk space of the log files of every instance and, when they
+// use more than the log_disk_quota together, trims the rotated log files of the instances
+// using more than their share, oldest first. The shares are fair: the quota is divided by
+// log_quota_weight, and what the instances using less than their share leave is divided
+// among the others, so the noisiest instance gives up its own history first. The log files
+// in use are never removed.
+func (im *instanceManager) enforceLogQuota() {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	quota := int64(im.instancesConfig.LogDiskQuotaMB) * 1024 * 1024
+	im.mu.RUnlock()
+	slices.SortFunc(instances, func(a, b *instance.Process) int { return strings.Compare(a.Name, b.Name) })
+
+	used := make([]int64, len(instances))
+	weights := make([]int, len(instances))
+	measured := make([]bool, len(instances))
+	for n, inst := range instances {
+		usage, err := inst.MeasureLogDisk()
+		if err != nil {
+			log.Printf("Log quota: failed to measure the log files of instance %s: %v", inst.Name, err)
+		}
+		used[n], weights[n], measured[n] = usage, inst.LogQuotaWeight(), err == nil
+	}
+
+	if quota > 0 {
+		for n, share := range fairShares(quota, used, weights) {
+			inst := instances[n]
+			if !measured[n] {
+				continue
+			}
+			deleted, err := inst.EnforceLogQuota(share)
+			for _, file := range deleted {
+				log.Printf("Log quota: removed rotated log file %s of instance %s", file, inst.Name)
+			}
+			if err != nil {
+				log.Printf("Log quota failed for instance %s: %v", inst.Name, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	status := LogQuotaStatus{QuotaBytes: quota, Level: LogQuotaOK, CheckedAt: &now, Instances: make([]InstanceLogUsage, 0, len(instances))}
+	for _, inst := range instances {
+		if usage := inst.GetLogDiskUsage(); usage != nil {
+			status.UsedBytes += usage.UsedBytes
+			status.Instances = append(status.Instances, InstanceLogUsage{Name: inst.Name, LogDiskUsage: *usage})
+		}
+	}
+	if quota > 0 {
+		status.Percent = float64(status.UsedBytes) * 100 / float64(quota)
+		switch {
+		case status.Percent >= logQuotaCriticalPercent:
+			status.Level = LogQuotaCritical
+		case status.Percent >= logQuotaWarningPercent:
+			status.Level = LogQuotaWarning
+		}
+	}
+
+	im.logQuota.mu.Lock()
+	previous := im.logQuota.status.Level
+	im.logQuota.status = status
+	im.logQuota.mu.Unlock()
+
+	if previous == "" {
+		previous = LogQuotaOK
+	}
+	if status.Level == previous {
+		return
+	}
+	message := fmt.Sprintf("log files use %.1f%% of the log disk quota", status.Percent)
+	if status.Level != LogQuotaOK {
+		log.Printf("Warning: %s", message)
+	}
+	im.events.Publish(events.Event{
+		Type:    events.TypeLogQuota,
+		Code:    status.Level,
+		Message: message,
+		Data: map[string]any{
+			"previous":    previous,
+			"used_bytes":  status.UsedBytes,
+			"quota_bytes": status.QuotaBytes,
+			"percent":     status.Percent,
+		},
+	})
+}
+
+// fairShares divides quota among consumers using used bytes by weighted max-min fairness:
+// those using less than their weighted part keep what they use, and what they leave is
+// divided among the others by weight. The share of a consumer within its part is that part,
+// the most it can grow to while the others use as much as they do.
+func fairShares(quota int64, used []int64, weights []int) []int64 {
+	shares := make([]int64, len(used))
+	remaining := quota
+	pending := make([]int, 0, len(used))
+	for n := range used {
+		pending = append(pending, n)
+	}
+	for len(pending) > 0 {
+		totalWeight := 0
+		for _, n := range pending {
+			totalWeight += weights[n]
+		}
+
+		// Consumers within their part keep what they use
+		var over []int
+		var settled int64
+		for _, n := range pending {
+			if part := remaining * int64(weights[n]) / int64(totalWeight); used[n] <= part {
+				shares[n] = part
+				settled += used[n]
+			} else {
+				over = append(over, n)
+			}
+		}
+		if len(over) == len(pending) {
+			for _, n := range over {
+				shares[n] = remaining * int64(weights[n]) / int64(totalWeight)
+			}
+			break
+		}
+		remaining -= settled
+		pending = over
+	}
+	return shares
+}
+
+// GetLogQuotaStatus returns the disk space the log files use against the log disk quota, as
+// of the last check
+func (im *instanceManager) GetLogQuotaStatus() *LogQuotaStatus {
+	quota := int64(im.GetSettings().LogDiskQuotaMB) * 1024 * 1024
+
+	im.logQuota.mu.Lock()
+	defer im.logQuota.mu.Unlock()
+	status := im.logQuota.status
+	status.Instances = slices.Clone(status.Instances)
+	if status.Level == "" {
+		// Not checked yet
+		status.Level, status.QuotaBytes = LogQuotaOK, quota
+	}
+	if status.Instances == nil {
+		status.Instances = []InstanceLogUsage{}
+	}
+	return &status
+}
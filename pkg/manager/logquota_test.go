@@ -0,0 +1,109 @@
+package manager_test
+
+import (
+	"bytes"
+	"fmt"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeRotatedLogs writes count rotated log files of size bytes for an instance, the first
+// one the oldest
+func writeRotatedLogs(t *testing.T, dir, name string, count, size int) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", dir, err)
+	}
+	data := append(bytes.Repeat([]byte("x"), size-1), '\n')
+	for n := range count {
+		path := filepath.Join(dir, fmt.Sprintf("%s.log.%d", name, count-n))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+		modTime := time.Now().Add(-time.Duration(count-n) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set the mtime of %s: %v", path, err)
+		}
+	}
+}
+
+func TestLogQuota_NoisiestInstanceTrimmedFirst(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         filepath.Join(dir, "instances"),
+		LogsDir:              filepath.Join(dir, "logs"),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	store := storage.NewFileStore(cfg.InstancesDir, dir)
+
+	first := manager.New(config.BackendConfig{}, cfg, manager.WithStore(store))
+	for _, name := range []string{"noisy", "quiet"} {
+		options := &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/models/" + name + ".gguf"},
+		}
+		if _, err := first.CreateInstance(name, options); err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+	}
+	first.Shutdown()
+
+	// 1.5 MB of logs for a quota of 1 MB, shared equally: the quiet instance is within its
+	// half, the noisy one keeps the rest
+	const segment = 256 * 1024
+	writeRotatedLogs(t, cfg.LogsDir, "noisy", 4, segment)
+	writeRotatedLogs(t, cfg.LogsDir, "quiet", 2, segment)
+	cfg.LogDiskQuotaMB = 1
+	mngr := manager.New(config.BackendConfig{}, cfg, manager.WithStore(store))
+	defer mngr.Shutdown()
+
+	var status *manager.LogQuotaStatus
+	deadline := time.Now().Add(5 * time.Second)
+	for status = mngr.GetLogQuotaStatus(); status.CheckedAt == nil; status = mngr.GetLogQuotaStatus() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the log quota to be checked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	usage := map[string]manager.InstanceLogUsage{}
+	for _, instanceUsage := range status.Instances {
+		usage[instanceUsage.Name] = instanceUsage
+	}
+	if noisy := usage["noisy"]; noisy.UsedBytes != 2*segment || noisy.ShareBytes != 2*segment || noisy.DeletedByQuota != 2 {
+		t.Errorf("Expected the noisy instance to be trimmed to its share, got %+v", noisy)
+	}
+	if quiet := usage["quiet"]; quiet.UsedBytes != 2*segment || quiet.DeletedByQuota != 0 {
+		t.Errorf("Expected the quiet instance to keep its history, got %+v", quiet)
+	}
+	for _, file := range []string{"noisy.log.4", "noisy.log.3"} {
+		if _, err := os.Stat(filepath.Join(cfg.LogsDir, file)); !os.IsNotExist(err) {
+			t.Errorf("Expected the oldest file %s to be removed, got %v", file, err)
+		}
+	}
+	if status.QuotaBytes != 1024*1024 || status.UsedBytes != 4*segment || status.Level != manager.LogQuotaCritical {
+		t.Errorf("Expected the quota to be used up, got %+v", status)
+	}
+
+	var published *events.Event
+	for _, event := range mngr.EventsSince(0) {
+		if event.Type == events.TypeLogQuota {
+			published = &event
+		}
+	}
+	if published == nil || published.Code != manager.LogQuotaCritical {
+		t.Errorf("Expected a critical log_quota event, got %+v", published)
+	}
+}
@@ -0,0 +1,30 @@
+package manager
+
+import (
+	"llamactl/pkg/instance"
+	"log"
+	"time"
+)
+
+// logJanitorInterval is how often rotated log files are checked against the retention policy
+const logJanitorInterval = time.Hour
+
+// enforceLogRetention removes rotated log files that are older than each instance's retention setting
+func (im *instanceManager) enforceLogRetention() {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	im.mu.RUnlock()
+
+	for _, inst := range instances {
+		deleted, err := inst.EnforceLogRetention()
+		for _, file := range deleted {
+			log.Printf("Log retention: removed rotated log file %s of instance %s", file, inst.Name)
+		}
+		if err != nil {
+			log.Printf("Log retention failed for instance %s: %v", inst.Name, err)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRollingRestartRunning is returned when a rolling restart is requested while one is running
+var ErrRollingRestartRunning = errors.New("a rolling restart is already running")
+
+// defaultRollingMaxUnavailable is the number of instances restarted at the same time by default
+const defaultRollingMaxUnavailable = 1
+
+// Rolling restart states
+const (
+	RollingRestartIdle      = "idle"
+	RollingRestartRunning   = "running"
+	RollingRestartCompleted = "completed"
+	RollingRestartAborted   = "aborted"
+)
+
+// States of the instances of a rolling restart
+const (
+	RollingInstancePending    = "pending"
+	RollingInstanceRestarting = "restarting"
+	RollingInstanceDone       = "done"
+	RollingInstanceFailed     = "failed"
+	RollingInstanceSkipped    = "skipped"
+)
+
+// RollingRestartOptions controls how a rolling restart walks through its instances
+type RollingRestartOptions struct {
+	// Instances restarted at the same time (default: 1)
+	MaxUnavailable int `json:"max_unavailable,omitempty"`
+	// Failed instances tolerated before the rolling restart is aborted (default: 0)
+	MaxFailures int `json:"max_failures,omitempty"`
+	// Seconds to wait for a restarted instance to become ready (default: its readiness timeout)
+	ReadyTimeout int `json:"ready_timeout,omitempty"`
+	// Skip the instances whose backend binary did not change since they were launched
+	IfBinaryChanged bool `json:"if_binary_changed,omitempty"`
+}
+
+// RollingRestartStatus is the progress of the current or most recent rolling restart
+type RollingRestartStatus struct {
+	State      string                   `json:"state"`
+	Selector   string                   `json:"selector,omitempty"`
+	Options    RollingRestartOptions    `json:"options"`
+	StartedAt  *time.Time               `json:"started_at,omitempty"`
+	FinishedAt *time.Time               `json:"finished_at,omitempty"`
+	Failures   int                      `json:"failures"`
+	Message    string                   `json:"message,omitempty"`
+	Instances  []RollingRestartInstance `json:"instances,omitempty"`
+}
+
+// RollingRestartInstance is the progress of a single instance of a rolling restart
+type RollingRestartInstance struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"` // Why the instance failed or was skipped
+}
+
+// SelectInstances returns the names of the instances matching a rolling restart selector:
+// "all", "tag:<tag>" for instances carrying the tag, or "group:<service>" for the members
+// of a configured service.
+func SelectInstances(im InstanceManager, services map[string]config.ServiceConfig, selector string) ([]string, error) {
+	kind, value, _ := strings.Cut(selector, ":")
+	if kind != "all" && value == "" {
+		return nil, fmt.Errorf("invalid selector %q: expected all, tag:<tag> or group:<service>", selector)
+	}
+
+	switch kind {
+	case "all", "tag":
+		instances, err := im.ListInstances()
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, inst := range instances {
+			if kind == "all" || slices.Contains(inst.GetOptions().Tags, value) {
+				names = append(names, inst.Name)
+			}
+		}
+		sort.Strings(names)
+		return names, nil
+	case "group":
+		svc, ok := services[value]
+		if !ok {
+			return nil, fmt.Errorf("service %s not found", value)
+		}
+		for _, member := range svc.Members {
+			if _, err := im.GetInstance(member); err != nil {
+				return nil, err
+			}
+		}
+		return slices.Clone(svc.Members), nil
+	default:
+		return nil, fmt.Errorf("invalid selector %q: expected all, tag:<tag> or group:<service>", selector)
+	}
+}
+
+// StartRollingRestart restarts the named instances in the background, at most
+// MaxUnavailable at a time, waiting for each to become ready before moving on.
+// Instances that are stopped or unmanaged are skipped. The rolling restart is aborted
+// once more than MaxFailures instances fail to come back. Progress is published as
+// rolling_restart events.
+func (im *instanceManager) StartRollingRestart(selector string, names []string, opts RollingRestartOptions) (*RollingRestartStatus, error) {
+	return im.startRollingRestart(selector, names, opts, "")
+}
+
+// startRollingRestart is StartRollingRestart on behalf of actor
+func (im *instanceManager) startRollingRestart(selector string, names []string, opts RollingRestartOptions, actor string) (*RollingRestartStatus, error) {
+	if opts.MaxUnavailable < 0 || opts.MaxFailures < 0 || opts.ReadyTimeout < 0 {
+		return nil, fmt.Errorf("max_unavailable, max_failures and ready_timeout must not be negative")
+	}
+	if opts.MaxUnavailable == 0 {
+		opts.MaxUnavailable = defaultRollingMaxUnavailable
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("selector %s matches no instances", selector)
+	}
+
+	im.mu.Lock()
+	if im.isShutdown {
+		im.mu.Unlock()
+		return nil, fmt.Errorf("instance manager is shutting down")
+	}
+	// An aborted rolling restart is still running until its in-progress restarts finish
+	if im.rollingRestart != nil && im.rollingRestart.FinishedAt == nil {
+		im.mu.Unlock()
+		return nil, ErrRollingRestartRunning
+	}
+	now := time.Now()
+	run := &RollingRestartStatus{
+		State:     RollingRestartRunning,
+		Selector:  selector,
+		Options:   opts,
+		StartedAt: &now,
+	}
+	for _, name := range names {
+		run.Instances = append(run.Instances, RollingRestartInstance{Name: name, State: RollingInstancePending})
+	}
+	im.rollingRestart = run
+	im.background.Add(1)
+	im.mu.Unlock()
+
+	im.recordAudit(actor, "rolling_restart", selector, fmt.Sprintf("%d instances", len(names)))
+	im.publishRollingRestart("", RollingRestartRunning, fmt.Sprintf("rolling restart of %d instances started", len(names)))
+
+	go func() {
+		defer im.background.Done()
+		im.runRollingRestart()
+	}()
+	return im.GetRollingRestartStatus(), nil
+}
+
+// GetRollingRestartStatus returns the progress of the current or most recent rolling restart
+func (im *instanceManager) GetRollingRestartStatus() *RollingRestartStatus {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+
+	if im.rollingRestart == nil {
+		return &RollingRestartStatus{State: RollingRestartIdle}
+	}
+	status := *im.rollingRestart
+	status.Instances = slices.Clone(im.rollingRestart.Instances)
+	return &status
+}
+
+func (im *instanceManager) runRollingRestart() {
+	im.mu.RLock()
+	run := im.rollingRestart
+	im.mu.RUnlock()
+
+	slots := make(chan struct{}, run.Options.MaxUnavailable)
+	var wg sync.WaitGroup
+
+walk:
+	for idx := range run.Instances {
+		select {
+		case slots <- struct{}{}:
+		case <-im.shutdownChan:
+			im.abortRollingRestart("llamactl is shutting down")
+			break walk
+		}
+		if im.rollingRestartAborted() {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			im.recycleInstance(idx)
+		}()
+	}
+	wg.Wait()
+
+	im.mu.Lock()
+	finished := time.Now()
+	run.FinishedAt = &finished
+	if run.State == RollingRestartRunning {
+		run.State = RollingRestartCompleted
+		run.Message = fmt.Sprintf("rolling restart completed with %d failures", run.Failures)
+	}
+	state, message := run.State, run.Message
+	im.mu.Unlock()
+
+	im.publishRollingRestart("", state, message)
+}
+
+// recycleInstance restarts the instance at idx of the current rolling restart and waits
+// for it to become ready
+func (im *instanceManager) recycleInstance(idx int) {
+	im.mu.RLock()
+	run := im.rollingRestart
+	name := run.Instances[idx].Name
+	inst, exists := im.instances[name]
+	im.mu.RUnlock()
+
+	var reason string
+	switch {
+	case !exists:
+		reason = "instance was deleted"
+	case !inst.IsManaged():
+		reason = "instance is not managed"
+	case !inst.IsRunning():
+		reason = "instance is not running"
+	}
+	if reason == "" && run.Options.IfBinaryChanged {
+		// An instance whose binary cannot be compared is restarted as without the flag
+		changed, why, err := inst.BinaryChanged()
+		switch {
+		case errors.Is(err, instance.ErrNotRunning):
+			reason = "instance is not running"
+		case err == nil && !changed:
+			reason = "not needed: " + why
+		}
+	}
+	if reason != "" {
+		im.setRollingInstance(idx, RollingInstanceSkipped, reason)
+		return
+	}
+
+	im.setRollingInstance(idx, RollingInstanceRestarting, "")
+	_, err := im.RestartInstance(name)
+	if err == nil {
+		err = inst.WaitForHealthy(time.Duration(run.Options.ReadyTimeout) * time.Second)
+	}
+	if err != nil {
+		im.setRollingInstance(idx, RollingInstanceFailed, err.Error())
+
+		im.mu.Lock()
+		run.Failures++
+		failures := run.Failures
+		im.mu.Unlock()
+		if failures > run.Options.MaxFailures {
+			im.abortRollingRestart(fmt.Sprintf("aborted after %d failed instances", failures))
+		}
+		return
+	}
+	im.setRollingInstance(idx, RollingInstanceDone, "")
+}
+
+func (im *instanceManager) setRollingInstance(idx int, state, message string) {
+	im.mu.Lock()
+	entry := &im.rollingRestart.Instances[idx]
+	entry.State = state
+	entry.Message = message
+	name := entry.Name
+	im.mu.Unlock()
+
+	im.publishRollingRestart(name, state, message)
+}
+
+// abortRollingRestart stops the current rolling restart from recycling further instances.
+// Instances being restarted are left to finish.
+func (im *instanceManager) abortRollingRestart(message string) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if im.rollingRestart.State == RollingRestartRunning {
+		im.rollingRestart.State = RollingRestartAborted
+		im.rollingRestart.Message = message
+	}
+}
+
+func (im *instanceManager) rollingRestartAborted() bool {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+	return im.rollingRestart.State == RollingRestartAborted
+}
+
+func (im *instanceManager) publishRollingRestart(name, state, message string) {
+	im.mu.RLock()
+	run := im.rollingRestart
+	data := map[string]any{
+		"selector": run.Selector,
+		"failures": run.Failures,
+		"total":    len(run.Instances),
+	}
+	finished := 0
+	for _, entry := range run.Instances {
+		if entry.State != RollingInstancePending && entry.State != RollingInstanceRestarting {
+			finished++
+		}
+	}
+	data["finished"] = finished
+	im.mu.RUnlock()
+
+	im.events.Publish(events.Event{
+		Type:     events.TypeRollingRestart,
+		Instance: name,
+		Code:     state,
+		Message:  message,
+		Data:     data,
+	})
+}
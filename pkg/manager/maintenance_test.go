@@ -0,0 +1,318 @@
+package manager_test
+
+import (
+	"errors"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestRollingRestartHelperProcess is run as the backend of the rolling restart tests: it
+// serves /health on the port it is given, or exits when LLAMACTL_TEST_FAIL_IF names an
+// existing file
+func TestRollingRestartHelperProcess(t *testing.T) {
+	if os.Getenv("LLAMACTL_TEST_BACKEND") == "" {
+		t.Skip("only run as the backend of the rolling restart tests")
+	}
+	if _, err := os.Stat(os.Getenv("LLAMACTL_TEST_FAIL_IF")); err == nil {
+		os.Exit(1)
+	}
+	port := os.Args[slices.Index(os.Args, "--port")+1]
+	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+	http.ListenAndServe(net.JoinHostPort("127.0.0.1", port), nil)
+	os.Exit(1)
+}
+
+func newRollingRestartManager(t *testing.T) manager.InstanceManager {
+	t.Helper()
+	return newRollingRestartManagerRunning(t, os.Args[0])
+}
+
+// newRollingRestartManagerRunning is newRollingRestartManager with the test binary copied to
+// command as the backend
+func newRollingRestartManagerRunning(t *testing.T, command string) manager.InstanceManager {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("stopping the backend relies on POSIX signals")
+	}
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{
+			Command:     command,
+			Args:        []string{"-test.run=^TestRollingRestartHelperProcess$", "--"},
+			Environment: map[string]string{"LLAMACTL_TEST_BACKEND": "1"},
+		},
+	}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		LogsDir:              t.TempDir(),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	mngr := manager.NewInstanceManager(backendConfig, cfg)
+	t.Cleanup(mngr.Shutdown)
+	return mngr
+}
+
+// createRollingInstance creates an instance listening on a free port, started unless
+// stopped is set. The backend fails to start once failIf exists.
+func createRollingInstance(t *testing.T, mngr manager.InstanceManager, name, failIf string, stopped bool) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	options := &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		Environment: map[string]string{"LLAMACTL_TEST_FAIL_IF": failIf},
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  "127.0.0.1",
+			Port:  port,
+		},
+	}
+	inst, err := mngr.CreateInstance(name, options)
+	if err != nil {
+		t.Fatalf("CreateInstance %s failed: %v", name, err)
+	}
+	if stopped {
+		return
+	}
+	if _, err := mngr.StartInstance(name); err != nil {
+		t.Fatalf("StartInstance %s failed: %v", name, err)
+	}
+	if err := inst.WaitForHealthy(10 * time.Second); err != nil {
+		t.Fatalf("instance %s did not become healthy: %v", name, err)
+	}
+}
+
+func waitForRollingRestart(t *testing.T, mngr manager.InstanceManager) *manager.RollingRestartStatus {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		if status := mngr.GetRollingRestartStatus(); status.FinishedAt != nil {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the rolling restart, got %+v", mngr.GetRollingRestartStatus())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func instanceStates(status *manager.RollingRestartStatus) map[string]string {
+	states := make(map[string]string)
+	for _, entry := range status.Instances {
+		states[entry.Name] = entry.State
+	}
+	return states
+}
+
+func TestRollingRestart(t *testing.T) {
+	mngr := newRollingRestartManager(t)
+	createRollingInstance(t, mngr, "a", "", false)
+	createRollingInstance(t, mngr, "b", "", false)
+	createRollingInstance(t, mngr, "idle", "", true)
+
+	if status := mngr.GetRollingRestartStatus(); status.State != manager.RollingRestartIdle {
+		t.Errorf("Expected idle state before any rolling restart, got %s", status.State)
+	}
+
+	subscription, unsubscribe := mngr.SubscribeEvents()
+	defer unsubscribe()
+
+	names, err := manager.SelectInstances(mngr, nil, "all")
+	if err != nil {
+		t.Fatalf("SelectInstances failed: %v", err)
+	}
+	started, err := mngr.StartRollingRestart("all", names, manager.RollingRestartOptions{MaxUnavailable: 2, ReadyTimeout: 10})
+	if err != nil {
+		t.Fatalf("StartRollingRestart failed: %v", err)
+	}
+	if started.State != manager.RollingRestartRunning {
+		t.Errorf("Expected running state, got %s", started.State)
+	}
+
+	status := waitForRollingRestart(t, mngr)
+	if status.State != manager.RollingRestartCompleted || status.Failures != 0 {
+		t.Errorf("Expected a completed rolling restart without failures, got %+v", status)
+	}
+	expected := map[string]string{
+		"a":    manager.RollingInstanceDone,
+		"b":    manager.RollingInstanceDone,
+		"idle": manager.RollingInstanceSkipped,
+	}
+	if got := instanceStates(status); !maps.Equal(got, expected) {
+		t.Errorf("Expected instance states %v, got %v", expected, got)
+	}
+
+	// Progress is published on the event bus, ending with the final state
+	var last events.Event
+	for last.Code != manager.RollingRestartCompleted {
+		select {
+		case event := <-subscription:
+			if event.Type == events.TypeRollingRestart {
+				last = event
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for the completion event, last event %+v", last)
+		}
+	}
+	if last.Data["finished"] != 3 || last.Data["total"] != 3 {
+		t.Errorf("Expected 3 of 3 instances finished, got %v", last.Data)
+	}
+}
+
+func TestRollingRestart_AbortsAfterFailures(t *testing.T) {
+	mngr := newRollingRestartManager(t)
+	failIf := filepath.Join(t.TempDir(), "fail")
+	createRollingInstance(t, mngr, "a", failIf, false)
+	createRollingInstance(t, mngr, "b", "", false)
+
+	// The rebuilt backend of a no longer starts
+	if err := os.WriteFile(failIf, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := manager.RollingRestartOptions{ReadyTimeout: 1}
+	if _, err := mngr.StartRollingRestart("all", []string{"a", "b"}, opts); err != nil {
+		t.Fatalf("StartRollingRestart failed: %v", err)
+	}
+	if _, err := mngr.StartRollingRestart("all", []string{"a", "b"}, opts); !errors.Is(err, manager.ErrRollingRestartRunning) {
+		t.Errorf("Expected a second rolling restart to be rejected, got %v", err)
+	}
+
+	status := waitForRollingRestart(t, mngr)
+	if status.State != manager.RollingRestartAborted || status.Failures != 1 {
+		t.Errorf("Expected an aborted rolling restart with 1 failure, got %+v", status)
+	}
+	expected := map[string]string{
+		"a": manager.RollingInstanceFailed,
+		"b": manager.RollingInstancePending,
+	}
+	if got := instanceStates(status); !maps.Equal(got, expected) {
+		t.Errorf("Expected instance states %v, got %v", expected, got)
+	}
+	if status.Instances[0].Message == "" {
+		t.Error("Expected the failure to be reported")
+	}
+
+	// A finished rolling restart does not block the next one
+	if _, err := mngr.StartRollingRestart("all", []string{"b"}, opts); err != nil {
+		t.Errorf("Expected a new rolling restart to start, got %v", err)
+	}
+	waitForRollingRestart(t, mngr)
+}
+
+// installBackend copies the test binary to path, with extra appended to tell builds apart, the
+// way an upgrade replaces it
+func installBackend(t *testing.T, path, extra string) {
+	t.Helper()
+	data, err := os.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".tmp", append(data, extra...), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRollingRestart_IfBinaryChanged(t *testing.T) {
+	backend := filepath.Join(t.TempDir(), "llama-server")
+	installBackend(t, backend, "")
+	mngr := newRollingRestartManagerRunning(t, backend)
+	createRollingInstance(t, mngr, "old", "", false)
+	installBackend(t, backend, "upgraded")
+	createRollingInstance(t, mngr, "new", "", false)
+
+	result, err := mngr.RestartInstanceIfBinaryChanged("new")
+	if err != nil {
+		t.Fatalf("RestartInstanceIfBinaryChanged failed: %v", err)
+	}
+	if result.Restarted || result.Message == "" || result.Instance == nil {
+		t.Errorf("Expected an instance running the new binary not to be restarted, got %+v", result)
+	}
+
+	opts := manager.RollingRestartOptions{ReadyTimeout: 10, IfBinaryChanged: true}
+	if _, err := mngr.StartRollingRestart("all", []string{"new", "old"}, opts); err != nil {
+		t.Fatalf("StartRollingRestart failed: %v", err)
+	}
+	status := waitForRollingRestart(t, mngr)
+	expected := map[string]string{
+		"new": manager.RollingInstanceSkipped,
+		"old": manager.RollingInstanceDone,
+	}
+	if got := instanceStates(status); !maps.Equal(got, expected) {
+		t.Errorf("Expected instance states %v, got %v", expected, got)
+	}
+
+	// The restarted instance runs the new binary
+	inst, _ := mngr.GetInstance("old")
+	if changed, reason, err := inst.BinaryChanged(); err != nil || changed {
+		t.Errorf("Expected the restarted instance to run the new binary, got %v (%s, %v)", changed, reason, err)
+	}
+}
+
+func TestSelectInstances(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+	for name, tags := range map[string][]string{"a": {"gpu"}, "b": nil, "c": {"gpu", "large"}} {
+		options := &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			Tags:               tags,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+		}
+		if _, err := mngr.CreateInstance(name, options); err != nil {
+			t.Fatalf("CreateInstance %s failed: %v", name, err)
+		}
+	}
+	services := map[string]config.ServiceConfig{
+		"chat": {Members: []string{"c", "b"}},
+		"bad":  {Members: []string{"missing"}},
+	}
+
+	tests := []struct {
+		selector string
+		expected []string
+		wantErr  bool
+	}{
+		{selector: "all", expected: []string{"a", "b", "c"}},
+		{selector: "tag:gpu", expected: []string{"a", "c"}},
+		{selector: "tag:none"},
+		{selector: "group:chat", expected: []string{"c", "b"}},
+		{selector: "group:unknown", wantErr: true},
+		{selector: "group:bad", wantErr: true},
+		{selector: "tag:", wantErr: true},
+		{selector: "name:a", wantErr: true},
+		{selector: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			names, err := manager.SelectInstances(mngr, services, tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
@@ -1,15 +1,21 @@
 package manager
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"llamactl/pkg/config"
+	"llamactl/pkg/events"
 	"llamactl/pkg/instance"
+	"llamactl/pkg/memory"
+	"llamactl/pkg/models"
+	"llamactl/pkg/storage"
 	"log"
-	"os"
-	"path/filepath"
-	"strings"
+	"maps"
+	"runtime"
+	"slices"
 	"sync"
+	"syscall"
 	"time"
 )
 
@@ -19,13 +25,61 @@ type InstanceManager interface {
 	CreateInstance(name string, options *instance.CreateInstanceOptions) (*instance.Process, error)
 	GetInstance(name string) (*instance.Process, error)
 	UpdateInstance(name string, options *instance.CreateInstanceOptions) (*instance.Process, error)
+	ValidateInstance(name string, options *instance.CreateInstanceOptions) error
 	DeleteInstance(name string) error
+	DeleteInstanceWithOptions(name string, opts DeleteOptions) error
+	ListTrash() []TrashedInstance
+	RestoreInstance(name string) (*instance.Process, error)
+	PurgeInstance(name string) error
 	StartInstance(name string) (*instance.Process, error)
 	IsMaxRunningInstancesReached() bool
 	StopInstance(name string) (*instance.Process, error)
+	CancelStart(name string) (*instance.Process, error)
+	SignalInstance(name string, sig syscall.Signal) (*instance.Process, error)
 	EvictLRUInstance() error
 	RestartInstance(name string) (*instance.Process, error)
+	RestartInstanceIfBinaryChanged(name string) (*BinaryRestart, error)
+	ReloadInstance(name string) (*instance.Process, error)
+	ApplyPendingOptions(name string) (*instance.Process, error)
+	RetryInstance(name string) (*instance.Process, error)
 	GetInstanceLogs(name string) (string, error)
+	GetSLOStatus(name string) (*instance.SLOStatus, error)
+	GetLBHealth(name string) (*instance.LBHealth, error)
+	UpdateSLO(name string, objectives *instance.SLOOptions) (*instance.SLOStatus, error)
+	UpdateAllowedPaths(name string, paths []string) (*instance.Process, error)
+	SetPromptTemplate(name, template, text string) error
+	DeletePromptTemplate(name, template string) error
+	PauseAutoRestart(name string, duration time.Duration) (*instance.Process, error)
+	ResumeAutoRestart(name string) (*instance.Process, error)
+	PauseAllAutoRestarts(duration time.Duration) (*instance.RestartPause, error)
+	ResumeAllAutoRestarts() error
+	GetGlobalRestartPause() *instance.RestartPause
+	SubscribeEvents() (<-chan events.Event, func())
+	AddHooks(hooks Hooks) func()
+	EventsSince(id uint64) []events.Event
+	GetMemoryUsage() memory.Snapshot
+	StartRollingRestart(selector string, names []string, opts RollingRestartOptions) (*RollingRestartStatus, error)
+	GetRollingRestartStatus() *RollingRestartStatus
+	ApplyFleet(fleet *Fleet, opts ApplyOptions) (*FleetPlan, error)
+	Restore()
+	GetRestoreStatus() *RestoreStatus
+	GetLogQuotaStatus() *LogQuotaStatus
+	GetInstanceDrift(name string) (*InstanceDrift, error)
+	GetDrift() *DriftSummary
+	SetServices(services map[string]config.ServiceConfig)
+	GetSettings() config.InstancesConfig
+	UpdateSettings(patch map[string]json.RawMessage) (config.InstancesConfig, error)
+	RotateBackendKey(name string) (*instance.Process, error)
+	ActiveMember(alias string) (string, bool)
+	GetStandbyStatus(alias string) (*StandbyStatus, error)
+	Failback(alias string) (*StandbyStatus, error)
+	RouteMember(alias, key string) (string, bool)
+	RecordRoute(alias, name string, failed bool, duration time.Duration)
+	GetRoutes(alias string) (*RouteStatus, error)
+	SetRoutes(alias string, rules RouteRules) (*RouteStatus, error)
+	ResetRoutes(alias string) error
+	WithActor(actor string) InstanceManager
+	WithOperationOptions(opts OperationOptions) InstanceManager
 	Shutdown()
 }
 
@@ -36,35 +90,181 @@ type instanceManager struct {
 	ports            map[int]bool
 	instancesConfig  config.InstancesConfig
 	backendsConfig   config.BackendConfig
+	events           *events.Bus
+	store            storage.Store         // nil when persistence is disabled
+	models           *models.Fetcher       // nil when no model source is configured
+	rollingRestart   *RollingRestartStatus // Current or most recent rolling restart
+	fleetMu          sync.Mutex            // Serializes fleet applies
+	standbyMu        sync.Mutex            // Guards the services and those with a standby
+	services         map[string]config.ServiceConfig
+	standbys         map[string]*standbyPair
+	routesMu         sync.Mutex // Guards the weighted routing of the services
+	routes           map[string]*serviceRoute
+	startLimiter     *instance.StartLimiter // Bounds the instances loading their model at once
+	memory           *memory.Accountant     // Bounds the in-memory buffers of the instances
+	hooks            hookRegistry           // Lifecycle hooks of the programs embedding the manager
+	restore          *restoreRun            // Initial restore of the persisted and static instances
+	logQuota         logQuotaState          // Last check of the log disk quota
+	logQuotaCheck    chan struct{}          // Signalled when log files were rotated, to check the quota
+
+	// Pauses of the automatic restarts: the global one shared by the instances, and the
+	// timers resuming them, by instance name and "" for the global pause
+	restartPause instance.GlobalRestartPause
+	pauseTimers  map[string]*time.Timer
+
+	// Deleted instances kept until they are purged, and the timers purging them
+	trash       map[string]*trashRecord
+	trashTimers map[string]*time.Timer
 
 	// Timeout checker
 	timeoutChecker *time.Ticker
+	logJanitor     *time.Ticker
+	externalProbe  *time.Ticker
+	connReaper     *time.Ticker
+	sloEvaluator   *time.Ticker
+	modelWatcher   *time.Ticker
+	scheduler      *time.Ticker
 	shutdownChan   chan struct{}
 	shutdownDone   chan struct{}
 	isShutdown     bool
+
+	// End of the window the start and stop schedules were last checked over, only used by
+	// the checker goroutine
+	scheduleCheckedAt time.Time
+
+	// Background work started outside the checker goroutine, joined on shutdown
+	background sync.WaitGroup
+}
+
+// Option configures a manager created with New
+type Option func(*managerOptions)
+
+type managerOptions struct {
+	store        storage.Store
+	storeSet     bool
+	hooks        []Hooks
+	deferRestore bool
+}
+
+// WithStore persists instances in the given store rather than as JSON files in the
+// configured instances directory. A nil store disables persistence. The caller closes the
+// store after Shutdown.
+func WithStore(store storage.Store) Option {
+	return func(o *managerOptions) {
+		o.store, o.storeSet = store, true
+	}
 }
 
-// NewInstanceManager creates a new instance of InstanceManager.
+// WithHooks registers hooks before the persisted instances are restored, so that they
+// receive the moments of the first starts
+func WithHooks(hooks Hooks) Option {
+	return func(o *managerOptions) {
+		o.hooks = append(o.hooks, hooks)
+	}
+}
+
+// WithDeferredRestore registers the persisted instances, stopped, without restoring them until
+// Restore is called, so that the HTTP server can serve requests during the restore
+func WithDeferredRestore() Option {
+	return func(o *managerOptions) {
+		o.deferRestore = true
+	}
+}
+
+// NewInstanceManager creates a new instance of InstanceManager that persists instances as
+// JSON files in the configured instances directory.
 func NewInstanceManager(backendsConfig config.BackendConfig, instancesConfig config.InstancesConfig) InstanceManager {
+	return New(backendsConfig, instancesConfig)
+}
+
+// NewInstanceManagerWithStore creates a new instance of InstanceManager that persists instances
+// in the given store. A nil store disables persistence. The caller closes the store after Shutdown.
+func NewInstanceManagerWithStore(backendsConfig config.BackendConfig, instancesConfig config.InstancesConfig, store storage.Store) InstanceManager {
+	return New(backendsConfig, instancesConfig, WithStore(store))
+}
+
+// New creates an InstanceManager for the given backends and instances settings, restoring
+// the persisted instances and starting those to start. It is the entry point of programs
+// embedding llamactl; the HTTP server is built on it. Shutdown stops the instances.
+func New(backendsConfig config.BackendConfig, instancesConfig config.InstancesConfig, opts ...Option) InstanceManager {
+	var o managerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	store := o.store
+	if !o.storeSet && instancesConfig.InstancesDir != "" {
+		store = storage.NewFileStore(instancesConfig.InstancesDir, instancesConfig.DataDir)
+	}
+
 	if instancesConfig.TimeoutCheckInterval <= 0 {
-		instancesConfig.TimeoutCheckInterval = 5 // Default to 5 minutes if not set
+		instancesConfig.TimeoutCheckInterval = config.Duration(5 * time.Minute) // Default if not set
 	}
+	accountant := memory.NewAccountant(instancesConfig.InstanceMemoryLimit, instancesConfig.MemoryLimit)
 	im := &instanceManager{
 		instances:        make(map[string]*instance.Process),
 		runningInstances: make(map[string]struct{}),
 		ports:            make(map[int]bool),
 		instancesConfig:  instancesConfig,
 		backendsConfig:   backendsConfig,
-
-		timeoutChecker: time.NewTicker(time.Duration(instancesConfig.TimeoutCheckInterval) * time.Minute),
+		events:           events.NewBus(accountant),
+		store:            store,
+		startLimiter:     instance.NewStartLimiter(instancesConfig.MaxConcurrentRestarts),
+		memory:           accountant,
+		pauseTimers:      make(map[string]*time.Timer),
+		trash:            make(map[string]*trashRecord),
+		trashTimers:      make(map[string]*time.Timer),
+		restore:          newRestoreRun(),
+		logQuotaCheck:    make(chan struct{}, 1),
+
+		timeoutChecker: time.NewTicker(instancesConfig.TimeoutCheckInterval.Duration()),
+		logJanitor:     time.NewTicker(logJanitorInterval),
+		externalProbe:  time.NewTicker(externalProbeInterval),
+		connReaper:     time.NewTicker(connectionReapInterval),
+		sloEvaluator:   time.NewTicker(sloEvaluationInterval),
+		modelWatcher:   time.NewTicker(modelWatchInterval),
+		scheduler:      time.NewTicker(scheduleCheckInterval),
 		shutdownChan:   make(chan struct{}),
 		shutdownDone:   make(chan struct{}),
+
+		// Transitions due while llamactl was down are not caught up on
+		scheduleCheckedAt: time.Now(),
+	}
+
+	if instancesConfig.ModelSource.URL != "" {
+		im.models = models.NewFetcher(instancesConfig.ModelSource.URL, instancesConfig.ModelSource.APIKey, instancesConfig.ModelSource.CacheDir)
+	}
+
+	for _, hooks := range o.hooks {
+		im.hooks.add(hooks)
 	}
 
 	// Load existing instances from disk
 	if err := im.loadInstances(); err != nil {
 		log.Printf("Error loading instances: %v", err)
 	}
+	if err := im.loadGlobalRestartPause(); err != nil {
+		log.Printf("Error loading the global pause of automatic restarts: %v", err)
+	}
+	if err := im.loadTrash(); err != nil {
+		log.Printf("Error loading the trash: %v", err)
+	}
+
+	if instancesConfig.LeaveRunning && !instance.AdoptionSupported {
+		log.Printf("leave_running is not supported on %s, instances are stopped on shutdown", runtime.GOOS)
+	}
+
+	// Auto-start the restored instances, then create or reconcile the instances defined in
+	// the configuration file once the restored ones report their actual state
+	for name := range instancesConfig.Static {
+		im.restore.add(name, instance.Stopped, true)
+	}
+	if !o.deferRestore {
+		im.Restore()
+	}
+
+	// The log files are measured against the log disk quota on startup, after every rotation
+	// and with the retention policy
+	im.requestLogQuotaCheck()
 
 	// Start the timeout checker goroutine after initialization is complete
 	go func() {
@@ -74,6 +274,24 @@ func NewInstanceManager(backendsConfig config.BackendConfig, instancesConfig con
 			select {
 			case <-im.timeoutChecker.C:
 				im.checkAllTimeouts()
+			case <-im.logJanitor.C:
+				im.enforceLogRetention()
+				im.enforceLogQuota()
+				im.enforceSlotRetention()
+			case <-im.logQuotaCheck:
+				im.enforceLogQuota()
+			case <-im.externalProbe.C:
+				im.probeExternalInstances()
+			case <-im.connReaper.C:
+				im.reapIdleConnections()
+			case <-im.sloEvaluator.C:
+				im.evaluateSLOs()
+				im.reportGPUFaults()
+				im.reportConfigMismatches()
+			case <-im.modelWatcher.C:
+				im.checkModelFiles()
+			case <-im.scheduler.C:
+				im.runSchedules()
 			case <-im.shutdownChan:
 				return // Exit goroutine on shutdown
 			}
@@ -96,35 +314,41 @@ func (im *instanceManager) getNextAvailablePort() (int, error) {
 	return 0, fmt.Errorf("no available ports in the specified range")
 }
 
-// persistInstance saves an instance to its JSON file
+// persistInstance saves an instance definition to the store
 func (im *instanceManager) persistInstance(instance *instance.Process) error {
-	if im.instancesConfig.InstancesDir == "" {
+	if im.store == nil {
 		return nil // Persistence disabled
 	}
 
-	instancePath := filepath.Join(im.instancesConfig.InstancesDir, instance.Name+".json")
-	tempPath := instancePath + ".tmp"
-
-	// Serialize instance to JSON
-	jsonData, err := json.MarshalIndent(instance, "", "  ")
+	// Serialize instance to JSON, secret environment values included
+	data, err := instance.MarshalPersisted()
 	if err != nil {
 		return fmt.Errorf("failed to marshal instance %s: %w", instance.Name, err)
 	}
-
-	// Write to temporary file first
-	if err := os.WriteFile(tempPath, jsonData, 0644); err != nil {
-		return fmt.Errorf("failed to write temp file for instance %s: %w", instance.Name, err)
+	var jsonData bytes.Buffer
+	if err := json.Indent(&jsonData, data, "", "  "); err != nil {
+		return fmt.Errorf("failed to marshal instance %s: %w", instance.Name, err)
 	}
 
-	// Atomic rename
-	if err := os.Rename(tempPath, instancePath); err != nil {
-		os.Remove(tempPath) // Clean up temp file
-		return fmt.Errorf("failed to rename temp file for instance %s: %w", instance.Name, err)
+	if err := im.store.Put(storage.NamespaceInstances, instance.Name, jsonData.Bytes()); err != nil {
+		return fmt.Errorf("failed to persist instance %s: %w", instance.Name, err)
 	}
 
 	return nil
 }
 
+// recordAudit appends an entry to the audit log of the store, logging failures. The actor is
+// empty for changes made by llamactl itself or by unauthenticated callers.
+func (im *instanceManager) recordAudit(actor, action, target, details string) {
+	if im.store == nil {
+		return
+	}
+	record := storage.AuditRecord{Time: time.Now(), Actor: actor, Action: action, Target: target, Details: details}
+	if err := im.store.AppendAudit(record); err != nil {
+		log.Printf("Failed to record audit entry %s %s: %v", action, target, err)
+	}
+}
+
 func (im *instanceManager) Shutdown() {
 	im.mu.Lock()
 
@@ -138,17 +362,7 @@ func (im *instanceManager) Shutdown() {
 	// Signal the timeout checker to stop
 	close(im.shutdownChan)
 
-	// Create a list of running instances to stop
-	var runningInstances []*instance.Process
-	var runningNames []string
-	for name, inst := range im.instances {
-		if inst.IsRunning() {
-			runningInstances = append(runningInstances, inst)
-			runningNames = append(runningNames, name)
-		}
-	}
-
-	// Release lock before stopping instances to avoid deadlock
+	// Release lock before waiting for background work to avoid deadlock
 	im.mu.Unlock()
 
 	// Wait for the timeout checker goroutine to actually stop
@@ -158,6 +372,43 @@ func (im *instanceManager) Shutdown() {
 	if im.timeoutChecker != nil {
 		im.timeoutChecker.Stop()
 	}
+	if im.logJanitor != nil {
+		im.logJanitor.Stop()
+	}
+	if im.externalProbe != nil {
+		im.externalProbe.Stop()
+	}
+	if im.connReaper != nil {
+		im.connReaper.Stop()
+	}
+	if im.sloEvaluator != nil {
+		im.sloEvaluator.Stop()
+	}
+	if im.modelWatcher != nil {
+		im.modelWatcher.Stop()
+	}
+	if im.scheduler != nil {
+		im.scheduler.Stop()
+	}
+	im.stopPauseTimers()
+	im.stopTrashTimers()
+
+	// Let auto-starts and probes finish so they cannot start instances after this point
+	im.background.Wait()
+
+	// Create a list of running instances to stop
+	im.mu.RLock()
+	var runningInstances []*instance.Process
+	var runningNames []string
+	allInstances := make([]*instance.Process, 0, len(im.instances))
+	for name, inst := range im.instances {
+		allInstances = append(allInstances, inst)
+		if inst.IsRunning() && inst.IsManaged() {
+			runningInstances = append(runningInstances, inst)
+			runningNames = append(runningNames, name)
+		}
+	}
+	im.mu.RUnlock()
 
 	// Stop instances without holding the manager lock
 	var wg sync.WaitGroup
@@ -166,45 +417,52 @@ func (im *instanceManager) Shutdown() {
 	for i, inst := range runningInstances {
 		go func(name string, inst *instance.Process) {
 			defer wg.Done()
+			// Left running for the next llamactl to adopt, if it was spawned to outlive this one
+			if im.instancesConfig.LeaveRunning {
+				err := inst.Detach()
+				if err == nil {
+					fmt.Printf("Left instance %s running\n", name)
+					return
+				}
+				log.Printf("Cannot leave instance %s running, stopping it: %v", name, err)
+			}
 			fmt.Printf("Stopping instance %s...\n", name)
 			// Attempt to stop the instance gracefully
-			if err := inst.Stop(); err != nil {
+			if err := inst.StopWithReason(instance.ReasonShutdown, "llamactl is shutting down"); err != nil {
 				fmt.Printf("Error stopping instance %s: %v\n", name, err)
 			}
 		}(runningNames[i], inst)
 	}
 
 	wg.Wait()
+
+	// Cancel pending restarts and join the goroutines of every instance
+	for _, inst := range allInstances {
+		if err := inst.Close(); err != nil {
+			log.Printf("Error releasing instance %s: %v", inst.Name, err)
+		}
+	}
 	fmt.Println("All instances stopped.")
+
+	// Deliver the last moments, the instances having stopped, to the hooks
+	im.hooks.close()
 }
 
-// loadInstances restores all instances from disk
+// loadInstances restores all instances from the store
 func (im *instanceManager) loadInstances() error {
-	if im.instancesConfig.InstancesDir == "" {
+	if im.store == nil {
 		return nil // Persistence disabled
 	}
 
-	// Check if instances directory exists
-	if _, err := os.Stat(im.instancesConfig.InstancesDir); os.IsNotExist(err) {
-		return nil // No instances directory, start fresh
-	}
-
-	// Read all JSON files from instances directory
-	files, err := os.ReadDir(im.instancesConfig.InstancesDir)
+	records, err := im.store.List(storage.NamespaceInstances)
+	im.reportCorrupt()
 	if err != nil {
-		return fmt.Errorf("failed to read instances directory: %w", err)
+		return fmt.Errorf("failed to list persisted instances: %w", err)
 	}
 
 	loadedCount := 0
-	for _, file := range files {
-		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
-			continue
-		}
-
-		instanceName := strings.TrimSuffix(file.Name(), ".json")
-		instancePath := filepath.Join(im.instancesConfig.InstancesDir, file.Name())
-
-		if err := im.loadInstance(instanceName, instancePath); err != nil {
+	for instanceName, data := range records {
+		if err := im.loadInstance(instanceName, data); err != nil {
 			log.Printf("Failed to load instance %s: %v", instanceName, err)
 			continue
 		}
@@ -214,20 +472,51 @@ func (im *instanceManager) loadInstances() error {
 
 	if loadedCount > 0 {
 		log.Printf("Loaded %d instances from persistence", loadedCount)
-		// Auto-start instances that have auto-restart enabled
-		go im.autoStartInstances()
 	}
 
 	return nil
 }
 
-// loadInstance loads a single instance from its JSON file
-func (im *instanceManager) loadInstance(name, path string) error {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return fmt.Errorf("failed to read instance file: %w", err)
+// reportCorrupt logs, audits and publishes the instance definitions the store set aside
+// because they were corrupt, most likely cut short by a crash or a power loss
+func (im *instanceManager) reportCorrupt() {
+	reporter, ok := im.store.(storage.CorruptionReporter)
+	if !ok {
+		return
+	}
+	records := reporter.TakeCorrupt()
+	if len(records) == 0 {
+		return
 	}
 
+	skipped := []string{}
+	recovered := []string{}
+	for _, record := range records {
+		if record.Recovered {
+			log.Printf("Instance %s had a corrupt definition, moved to %s and recovered from its previous version", record.Key, record.Path)
+			recovered = append(recovered, record.Key)
+			im.recordAudit("", "recover", record.Key, "corrupt definition moved to "+record.Path+", previous version restored")
+		} else {
+			log.Printf("Instance %s had a corrupt definition and no previous version, moved to %s and skipped", record.Key, record.Path)
+			skipped = append(skipped, record.Key)
+			im.recordAudit("", "quarantine", record.Key, "corrupt definition moved to "+record.Path)
+		}
+	}
+
+	im.events.Publish(events.Event{
+		Type:    events.TypeStorage,
+		Code:    "corrupt_records",
+		Message: fmt.Sprintf("%d corrupt instance definitions set aside, %d recovered from their previous version", len(records), len(recovered)),
+		Data: map[string]any{
+			"records":   records,
+			"recovered": recovered,
+			"skipped":   skipped,
+		},
+	})
+}
+
+// loadInstance loads a single instance from its persisted JSON definition
+func (im *instanceManager) loadInstance(name string, data []byte) error {
 	var persistedInstance instance.Process
 	if err := json.Unmarshal(data, &persistedInstance); err != nil {
 		return fmt.Errorf("failed to unmarshal instance: %w", err)
@@ -235,19 +524,42 @@ func (im *instanceManager) loadInstance(name, path string) error {
 
 	// Validate the instance name matches the filename
 	if persistedInstance.Name != name {
-		return fmt.Errorf("instance name mismatch: file=%s, instance.Name=%s", name, persistedInstance.Name)
+		return fmt.Errorf("instance name mismatch: key=%s, instance.Name=%s", name, persistedInstance.Name)
 	}
 
-	statusCallback := func(oldStatus, newStatus instance.InstanceStatus) {
-		im.onStatusChange(persistedInstance.Name, oldStatus, newStatus)
+	statusCallback := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {
+		im.onStatusChange(persistedInstance.Name, oldStatus, newStatus, reason)
 	}
 
 	// Create new inst using NewInstance (handles validation, defaults, setup)
 	inst := instance.NewInstance(name, &im.backendsConfig, &im.instancesConfig, persistedInstance.GetOptions(), statusCallback)
+	inst.SetStartLimiter(im.startLimiter)
+	inst.SetGlobalRestartPause(&im.restartPause)
+	inst.SetMemoryAccountant(im.memory)
+	im.observeInstance(inst)
+	im.markManagedBy(inst)
+	if err := im.loadBackendKey(inst); err != nil {
+		return err
+	}
+	if err := im.syncBackendKey(inst); err != nil {
+		return err
+	}
 
 	// Restore persisted fields that NewInstance doesn't set
 	inst.Created = persistedInstance.Created
-	inst.SetStatus(persistedInstance.Status)
+	inst.SetRestartPause(persistedInstance.GetRestartPause())
+	im.schedulePauseExpiry(name, persistedInstance.GetRestartPause())
+	// An instance that was running is registered stopped until it is restored, since its
+	// backend is not running yet, and external backends until they are probed
+	switch status := persistedInstance.Status; {
+	case status.IsRunning() || status == instance.Queued || status == instance.Restarting:
+		inst.SetStatus(instance.Stopped, instance.ReasonRestored, "waiting to be restored, was "+status.String()+" when persisted")
+		if inst.IsManaged() {
+			im.restore.add(name, status, false)
+		}
+	default:
+		inst.SetStatus(status, instance.ReasonRestored, "restored from persisted state")
+	}
 
 	// Check for port conflicts and add to maps
 	if inst.GetPort() > 0 {
@@ -262,50 +574,80 @@ func (im *instanceManager) loadInstance(name, path string) error {
 	return nil
 }
 
-// autoStartInstances starts instances that were running when persisted and have auto-restart enabled
-// For instances with auto-restart disabled, it sets their status to Stopped
+// autoStartInstances adopts the processes a previous llamactl left running, then starts the
+// other instances that were running when persisted and have auto-restart enabled
+// For instances with auto-restart disabled, it leaves them stopped
 func (im *instanceManager) autoStartInstances() {
-	im.mu.RLock()
-	var instancesToStart []*instance.Process
-	var instancesToStop []*instance.Process
-	for _, inst := range im.instances {
-		if inst.IsRunning() && // Was running when persisted
-			inst.GetOptions() != nil &&
-			inst.GetOptions().AutoRestart != nil {
-			if *inst.GetOptions().AutoRestart {
-				instancesToStart = append(instancesToStart, inst)
-			} else {
-				// Instance was running but auto-restart is disabled, mark as stopped
-				instancesToStop = append(instancesToStop, inst)
-			}
+	// Adopted without the lock, which their status change takes
+	adopted := im.adoptProcesses()
+
+	// Refresh the status of external backends right away instead of waiting for the first probe
+	im.probeExternalInstances()
+
+	pending := im.restore.toStart()
+	for _, name := range slices.Sorted(maps.Keys(pending)) {
+		inst, err := im.GetInstance(name)
+		if err != nil {
+			im.restore.finish(name, "", err, false)
+			continue
+		}
+		if adopted[name] {
+			im.restore.finish(name, RestoreAdopted, nil, false) // Still running from before the restart
+			continue
 		}
-	}
-	im.mu.RUnlock()
 
-	// Stop instances that have auto-restart disabled
-	for _, inst := range instancesToStop {
-		log.Printf("Instance %s was running but auto-restart is disabled, setting status to stopped", inst.Name)
-		inst.SetStatus(instance.Stopped)
-	}
+		options := inst.GetOptions()
+		if options == nil || options.AutoRestart == nil || !*options.AutoRestart {
+			log.Printf("Instance %s was running but auto-restart is disabled, setting status to stopped", name)
+			inst.SetStatus(instance.Stopped, instance.ReasonRestored, "auto-restart is disabled, not restarting after llamactl restart")
+			im.restore.finish(name, RestoreStopped, nil, false)
+			continue
+		}
 
-	// Start instances that have auto-restart enabled
-	for _, inst := range instancesToStart {
-		log.Printf("Auto-starting instance %s", inst.Name)
-		// Reset running state before starting (since Start() expects stopped instance)
-		inst.SetStatus(instance.Stopped)
-		if err := inst.Start(); err != nil {
-			log.Printf("Failed to auto-start instance %s: %v", inst.Name, err)
+		log.Printf("Auto-starting instance %s", name)
+		err = inst.StartWithReason(instance.ReasonRestored, "auto-started after llamactl restart")
+		if err != nil {
+			log.Printf("Failed to auto-start instance %s: %v", name, err)
 		}
+		im.restore.finish(name, RestoreStarted, err, false)
 	}
 }
 
-func (im *instanceManager) onStatusChange(name string, oldStatus, newStatus instance.InstanceStatus) {
+func (im *instanceManager) onStatusChange(name string, oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {
 	im.mu.Lock()
-	defer im.mu.Unlock()
-
-	if newStatus == instance.Running {
+	if newStatus.IsRunning() {
 		im.runningInstances[name] = struct{}{}
 	} else {
 		delete(im.runningInstances, name)
 	}
+	im.mu.Unlock()
+
+	im.events.Publish(events.Event{
+		Type:      events.TypeStatusChange,
+		Instance:  name,
+		Code:      string(reason.Code),
+		Message:   reason.Message,
+		Timestamp: reason.Timestamp,
+		Data: map[string]any{
+			"old_status": oldStatus.String(),
+			"new_status": newStatus.String(),
+		},
+	})
+	im.notifyStateChange(name, oldStatus, newStatus, reason)
+}
+
+// SubscribeEvents registers a subscriber for instance events. The returned function unsubscribes.
+func (im *instanceManager) SubscribeEvents() (<-chan events.Event, func()) {
+	return im.events.Subscribe()
+}
+
+// EventsSince returns the events still kept for replay that were published after the event
+// with the given ID, oldest first
+func (im *instanceManager) EventsSince(id uint64) []events.Event {
+	return im.events.Since(id)
+}
+
+// GetMemoryUsage returns the accounting of the in-memory buffers of the instances
+func (im *instanceManager) GetMemoryUsage() memory.Snapshot {
+	return im.memory.Snapshot()
 }
@@ -1,17 +1,20 @@
 package manager_test
 
 import (
+	"errors"
 	"fmt"
 	"llamactl/pkg/backends"
 	"llamactl/pkg/backends/llamacpp"
 	"llamactl/pkg/config"
 	"llamactl/pkg/instance"
 	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestNewInstanceManager(t *testing.T) {
@@ -30,8 +33,8 @@ func TestNewInstanceManager(t *testing.T) {
 		MaxInstances:         5,
 		DefaultAutoRestart:   true,
 		DefaultMaxRestarts:   3,
-		DefaultRestartDelay:  5,
-		TimeoutCheckInterval: 5,
+		DefaultRestartDelay:  config.Duration(5 * time.Second),
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
 	}
 
 	mgr := manager.NewInstanceManager(backendConfig, cfg)
@@ -65,7 +68,7 @@ func TestPersistence(t *testing.T) {
 		PortRange:            [2]int{8000, 9000},
 		InstancesDir:         tempDir,
 		MaxInstances:         10,
-		TimeoutCheckInterval: 5,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
 	}
 
 	// Test instance persistence on creation
@@ -119,6 +122,117 @@ func TestPersistence(t *testing.T) {
 	}
 }
 
+func TestPersistence_CorruptDefinition(t *testing.T) {
+	tempDir := t.TempDir()
+	backendConfig := config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "llama-server"}}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         tempDir,
+		MaxInstances:         10,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	store := storage.NewFileStore(tempDir, tempDir)
+
+	manager1 := manager.NewInstanceManagerWithStore(backendConfig, cfg, store)
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+	}
+	if _, err := manager1.CreateInstance("test-instance", options); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	options.LlamaServerOptions.Model = "/path/to/other.gguf"
+	if _, err := manager1.UpdateInstance("test-instance", options); err != nil {
+		t.Fatalf("UpdateInstance failed: %v", err)
+	}
+	manager1.Shutdown()
+
+	// Simulate a power loss cutting the last write short
+	path := filepath.Join(tempDir, "test-instance.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read definition: %v", err)
+	}
+	if err := os.WriteFile(path, data[:len(data)/3], 0644); err != nil {
+		t.Fatalf("Failed to truncate definition: %v", err)
+	}
+
+	manager2 := manager.NewInstanceManagerWithStore(backendConfig, cfg, store)
+	defer manager2.Shutdown()
+	inst, err := manager2.GetInstance("test-instance")
+	if err != nil {
+		t.Fatalf("Expected the instance to be recovered, got %v", err)
+	}
+	if model := inst.GetOptions().LlamaServerOptions.Model; model != "/path/to/model.gguf" {
+		t.Errorf("Expected the previous definition, got model %s", model)
+	}
+
+	audit, err := store.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit failed: %v", err)
+	}
+	if last := audit[len(audit)-1]; last.Action != "recover" || last.Target != "test-instance" {
+		t.Errorf("Expected the recovery to be audited, got %+v", last)
+	}
+}
+
+func TestPersistence_SQLiteStore(t *testing.T) {
+	store, err := storage.OpenSQLiteStore(filepath.Join(t.TempDir(), "llamactl.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore failed: %v", err)
+	}
+	defer store.Close()
+
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{
+			Command: "llama-server",
+		},
+	}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		MaxInstances:         10,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+
+	manager1 := manager.NewInstanceManagerWithStore(backendConfig, cfg, store)
+	_, err = manager1.CreateInstance("test-instance", &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Port:  8080,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	manager1.Shutdown()
+
+	manager2 := manager.NewInstanceManagerWithStore(backendConfig, cfg, store)
+	defer manager2.Shutdown()
+	instances, err := manager2.ListInstances()
+	if err != nil {
+		t.Fatalf("ListInstances failed: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Name != "test-instance" {
+		t.Fatalf("Expected test-instance to be loaded from the store, got %v", instances)
+	}
+
+	if err := manager2.DeleteInstance("test-instance"); err != nil {
+		t.Fatalf("DeleteInstance failed: %v", err)
+	}
+	if _, err := store.Get(storage.NamespaceInstances, "test-instance"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected the instance to be deleted from the store, got %v", err)
+	}
+
+	audit, err := store.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit failed: %v", err)
+	}
+	if len(audit) != 2 || audit[0].Action != "create" || audit[1].Action != "delete" {
+		t.Errorf("Expected create and delete audit records, got %+v", audit)
+	}
+}
+
 func TestConcurrentAccess(t *testing.T) {
 	mgr := createTestManager()
 	defer mgr.Shutdown()
@@ -204,8 +318,8 @@ func createTestManager() manager.InstanceManager {
 		MaxInstances:         10,
 		DefaultAutoRestart:   true,
 		DefaultMaxRestarts:   3,
-		DefaultRestartDelay:  5,
-		TimeoutCheckInterval: 5,
+		DefaultRestartDelay:  config.Duration(5 * time.Second),
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
 	}
 	return manager.NewInstanceManager(backendConfig, cfg)
 }
@@ -223,7 +337,7 @@ func TestAutoRestartDisabledInstanceStatus(t *testing.T) {
 		PortRange:            [2]int{8000, 9000},
 		InstancesDir:         tempDir,
 		MaxInstances:         10,
-		TimeoutCheckInterval: 5,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
 	}
 
 	// Create first manager and instance with auto-restart disabled
@@ -246,7 +360,7 @@ func TestAutoRestartDisabledInstanceStatus(t *testing.T) {
 
 	// Simulate instance being in running state when persisted
 	// (this would happen if the instance was running when llamactl was stopped)
-	inst.SetStatus(instance.Running)
+	inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
 
 	// Shutdown first manager
 	manager1.Shutdown()
@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"llamactl/pkg/instance"
+)
+
+// fetchRemoteModel downloads the remote model of an instance from the control plane, if it
+// has one and it is not cached yet, reporting progress through the instance's status.
+func (im *instanceManager) fetchRemoteModel(inst *instance.Process) error {
+	opts := inst.GetOptions()
+	if !opts.HasRemoteModel() {
+		return nil
+	}
+	if im.models == nil {
+		return fmt.Errorf("instance %s uses remote model %s but no model source is configured", inst.Name, opts.RemoteModel)
+	}
+
+	// Abort the download when llamactl shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-im.shutdownChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	lastPercent := -1
+	path, err := im.models.Fetch(ctx, opts.RemoteModel, func(downloaded, total int64) {
+		percent := 100
+		if total > 0 {
+			percent = int(downloaded * 100 / total)
+		}
+		if percent != lastPercent {
+			lastPercent = percent
+			inst.ReportModelDownload(percent)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch model %s for instance %s: %w", opts.RemoteModel, inst.Name, err)
+	}
+
+	inst.SetModelPath(path)
+	return nil
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"fmt"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"log"
+	"time"
+)
+
+const (
+	// modelWatchInterval is how often the model files of running instances are checked
+	modelWatchInterval = 30 * time.Second
+	// modelChangeSettle is how long changed model files must stay the same before the change
+	// is reported, so copies in progress and NFS attribute cache flaps are not
+	modelChangeSettle = time.Minute
+	// recycleDrainTimeout bounds how long a recycle waits for in-flight requests
+	recycleDrainTimeout = 5 * time.Minute
+)
+
+// checkModelFiles checks the model files of the running instances, reporting those that
+// changed on disk and recycling the instances whose policy asks for it
+func (im *instanceManager) checkModelFiles() {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.runningInstances))
+	for name := range im.runningInstances {
+		if inst := im.instances[name]; inst != nil {
+			instances = append(instances, inst)
+		}
+	}
+	im.mu.RUnlock()
+
+	for _, inst := range instances {
+		change, detected := inst.CheckModelFiles(modelChangeSettle)
+		if !detected {
+			continue
+		}
+
+		policy := inst.GetOptions().ModelChangePolicy()
+		log.Printf("Model file %s of instance %s changed on disk since the instance started", change.Path, inst.Name)
+		im.events.Publish(events.Event{
+			Type:     events.TypeModelChange,
+			Instance: inst.Name,
+			Code:     policy,
+			Message:  fmt.Sprintf("model file %s changed on disk", change.Path),
+			Data: map[string]any{
+				"path":        change.Path,
+				"detected_at": change.DetectedAt,
+			},
+		})
+		if policy == instance.ModelChangeRestart {
+			im.recycleWhenIdle(inst.Name, "to load its changed model files", func(inst *instance.Process) bool {
+				return inst.GetModelChange() != nil
+			})
+		}
+	}
+}
+
+// recycleWhenIdle restarts an instance in the background once its in-flight requests
+// finished, or after recycleDrainTimeout, unless needed reports it no longer has to be
+func (im *instanceManager) recycleWhenIdle(name, purpose string, needed func(*instance.Process) bool) {
+	im.background.Add(1)
+	go func() {
+		defer im.background.Done()
+
+		// Load balancers send no new work to the instance while its requests finish
+		var drained *instance.Process
+		defer func() {
+			if drained != nil {
+				drained.SetDraining(false)
+			}
+		}()
+
+		deadline := time.Now().Add(recycleDrainTimeout)
+		for {
+			inst, err := im.GetInstance(name)
+			if err != nil || !inst.IsRunning() || !needed(inst) {
+				return // Deleted, stopped or already restarted meanwhile
+			}
+			if len(inst.GetRequests()) == 0 || time.Now().After(deadline) {
+				break
+			}
+			drained = inst
+			inst.SetDraining(true)
+			select {
+			case <-time.After(time.Second):
+			case <-im.shutdownChan:
+				return
+			}
+		}
+
+		log.Printf("Restarting instance %s %s", name, purpose)
+		if _, err := im.restartInstance(name, "", OperationOptions{}); err != nil {
+			log.Printf("Failed to restart instance %s %s: %v", name, purpose, err)
+		}
+	}()
+}
@@ -1,16 +1,52 @@
 package manager
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"llamactl/pkg/backends"
 	"llamactl/pkg/instance"
+	"llamactl/pkg/storage"
 	"llamactl/pkg/validation"
-	"os"
-	"path/filepath"
+	"log"
+	"syscall"
+	"time"
 )
 
 type MaxRunningInstancesError error
 
+// ErrNotFailed is returned when retrying an instance that has not failed
+var ErrNotFailed = errors.New("instance has not failed")
+
+// OperationOptions tells how a start, stop, restart or reload waits for another lifecycle
+// operation in progress on the same instance. The zero value waits its turn, as the
+// operations llamactl makes itself do.
+type OperationOptions struct {
+	Context context.Context // Gives up waiting once done; never when nil
+	NoQueue bool            // Fail with *instance.OperationInProgressError instead of waiting
+}
+
+// beginOperation serializes a lifecycle operation on the named instance with any other in
+// progress on it, returning the instance and the function recording the outcome
+func (im *instanceManager) beginOperation(name string, opType instance.OperationType, opts OperationOptions) (*instance.Process, func(error), error) {
+	im.mu.RLock()
+	inst, exists := im.instances[name]
+	im.mu.RUnlock()
+
+	if !exists {
+		return nil, nil, fmt.Errorf("instance with name %s not found", name)
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	finish, err := inst.BeginOperation(ctx, opType, !opts.NoQueue)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inst, finish, nil
+}
+
 // ListInstances returns a list of all instances managed by the instance manager.
 func (im *instanceManager) ListInstances() ([]*instance.Process, error) {
 	im.mu.RLock()
@@ -24,21 +60,35 @@ func (im *instanceManager) ListInstances() ([]*instance.Process, error) {
 }
 
 // CreateInstance creates a new instance with the given options and returns it.
-// The instance is initially in a "stopped" state.
+// The instance is initially in a "stopped" state. A static instance of the same name still
+// being restored is restored first.
 func (im *instanceManager) CreateInstance(name string, options *instance.CreateInstanceOptions) (*instance.Process, error) {
+	im.waitRestored(name)
+	return im.createInstance(name, options, "")
+}
+
+// createInstance is CreateInstance on behalf of actor
+func (im *instanceManager) createInstance(name string, options *instance.CreateInstanceOptions, actor string) (*instance.Process, error) {
 	if options == nil {
 		return nil, fmt.Errorf("instance options cannot be nil")
 	}
 
 	name, err := validation.ValidateInstanceName(name)
-	if err != nil {
+	if err := validation.Join(err, im.validateOptions(options)); err != nil {
 		return nil, err
 	}
-
-	err = validation.ValidateInstanceOptions(options)
+	inst, err := im.addInstance(name, options, "")
 	if err != nil {
 		return nil, err
 	}
+	im.recordAudit(actor, "create", name, "")
+	return inst, nil
+}
+
+// addInstance adds an instance with validated options, with the given managed backend key
+// or a new one if it uses one
+func (im *instanceManager) addInstance(name string, options *instance.CreateInstanceOptions, backendKey string) (*instance.Process, error) {
+	services := im.getServices()
 
 	im.mu.Lock()
 	defer im.mu.Unlock()
@@ -53,22 +103,57 @@ func (im *instanceManager) CreateInstance(name string, options *instance.CreateI
 		return nil, fmt.Errorf("instance with name %s already exists", name)
 	}
 
+	// An external backend cannot be assigned a port, it already listens on one
+	if !options.IsManaged() && im.getPortFromOptions(options) == 0 {
+		return nil, fmt.Errorf("unmanaged instance %s must specify the port of its external backend", name)
+	}
+
 	// Assign and validate port for backend-specific options
+	portInferred := im.getPortFromOptions(options) == 0
 	if err := im.assignAndValidatePort(options); err != nil {
 		return nil, err
 	}
 
-	statusCallback := func(oldStatus, newStatus instance.InstanceStatus) {
-		im.onStatusChange(name, oldStatus, newStatus)
+	statusCallback := func(oldStatus, newStatus instance.InstanceStatus, reason instance.StatusReason) {
+		im.onStatusChange(name, oldStatus, newStatus, reason)
 	}
 
 	inst := instance.NewInstance(name, &im.backendsConfig, &im.instancesConfig, options, statusCallback)
+	inst.SetStartLimiter(im.startLimiter)
+	inst.SetGlobalRestartPause(&im.restartPause)
+	inst.SetMemoryAccountant(im.memory)
+	im.observeInstance(inst)
+	im.markManagedBy(inst)
+	if portInferred {
+		inst.MarkInferred("backend_options.port")
+	}
+	// The members of its services will all run eventually
+	if err := placeInstance(inst, services, im.instances); err != nil {
+		return nil, err
+	}
+	if backendKey != "" {
+		inst.SetBackendKey(backendKey)
+		if err := im.saveBackendKey(name, backendKey); err != nil {
+			return nil, fmt.Errorf("failed to persist backend key of instance %s: %w", name, err)
+		}
+	}
+	if err := im.syncBackendKey(inst); err != nil {
+		return nil, err
+	}
 	im.instances[inst.Name] = inst
 
 	if err := im.persistInstance(inst); err != nil {
 		return nil, fmt.Errorf("failed to persist instance %s: %w", name, err)
 	}
 
+	if !inst.IsManaged() {
+		im.background.Add(1)
+		go func() {
+			defer im.background.Done()
+			im.probeExternalInstance(inst)
+		}()
+	}
+
 	return inst, nil
 }
 
@@ -84,11 +169,56 @@ func (im *instanceManager) GetInstance(name string) (*instance.Process, error) {
 	return instance, nil
 }
 
+// validateOptions runs the validation shared by creating and updating an instance. The
+// returned *validation.ValidationError lists every invalid field, not only the first one.
+func (im *instanceManager) validateOptions(options *instance.CreateInstanceOptions) error {
+	return validation.Join(
+		validation.ValidateInstanceOptions(options),
+		validation.ValidateBackendTLS(options, im.instancesConfig.AllowInsecureBackends),
+		validation.ValidateLaunchWrapper(options, im.instancesConfig.AllowInstanceCommands),
+		validation.ValidateLlamaExecutable(options, im.instancesConfig.AllowInstanceCommands),
+		validation.ValidateLifecycleCommands(options, im.instancesConfig.AllowInstanceCommands),
+		validation.ValidateHosts(options),
+		validation.ValidateBindInterface(options),
+		validation.ValidateBackendConnections(options),
+		validation.ValidateSlotPreservation(options),
+		validation.ValidateManagedBackendKey(options),
+		validation.ValidateSLO(options),
+		validation.ValidateHealthCheck(options),
+		validation.ValidateLBHealth(options),
+		validation.ValidatePromptTemplates(options),
+		validation.ValidateLogSanitize(options),
+		validation.ValidateSchedules(options),
+		validation.ValidateModelChange(options),
+		validation.ValidateGPUFault(options),
+		validation.ValidateTransform(options),
+		validation.ValidateAllowedPaths(options),
+	)
+}
+
+// ValidateInstance validates the name and options of an instance definition without
+// creating or updating anything
+func (im *instanceManager) ValidateInstance(name string, options *instance.CreateInstanceOptions) error {
+	_, err := validation.ValidateInstanceName(name)
+	return validation.Join(err, im.validateOptions(options))
+}
+
 // UpdateInstance updates the options of an existing instance and returns it.
-// If the instance is running, it will be restarted to apply the new options.
+// If the instance is running, it keeps running with its current options and the new ones
+// are pending until its next start; ApplyPendingOptions restarts it to apply them.
 func (im *instanceManager) UpdateInstance(name string, options *instance.CreateInstanceOptions) (*instance.Process, error) {
+	im.waitRestored(name)
+	if err := im.checkConfigUpdate(name); err != nil {
+		return nil, err
+	}
+	return im.updateInstance(name, options, "", false)
+}
+
+// updateInstance is UpdateInstance on behalf of actor, without the update policy of static
+// instances. With restart, a running instance is restarted to apply the options right away.
+func (im *instanceManager) updateInstance(name string, options *instance.CreateInstanceOptions, actor string, restart bool) (*instance.Process, error) {
 	im.mu.RLock()
-	instance, exists := im.instances[name]
+	inst, exists := im.instances[name]
 	im.mu.RUnlock()
 
 	if !exists {
@@ -99,104 +229,210 @@ func (im *instanceManager) UpdateInstance(name string, options *instance.CreateI
 		return nil, fmt.Errorf("instance options cannot be nil")
 	}
 
-	err := validation.ValidateInstanceOptions(options)
-	if err != nil {
+	// Secret environment values read back masked are kept as they are
+	options.RestoreMaskedEnvironment(inst.GetDesiredOptions())
+
+	if err := im.validateOptions(options); err != nil {
 		return nil, err
 	}
 
-	// Check if instance is running before updating options
-	wasRunning := instance.IsRunning()
+	// Only processes started by llamactl are stopped to apply the update
+	restart = restart && inst.IsRunning() && inst.IsManaged()
 
-	// If the instance is running, stop it first
-	if wasRunning {
-		if err := instance.Stop(); err != nil {
+	// If the instance is restarted, stop it first
+	if restart {
+		if err := inst.StopWithReason(instance.ReasonUserStop, "stopped to apply updated options"); err != nil {
 			return nil, fmt.Errorf("failed to stop instance %s for update: %w", name, err)
 		}
 	}
 
-	// Now update the options while the instance is stopped
-	instance.SetOptions(options)
+	// A backend still running keeps its options, the new ones are pending until its next start
+	inst.SetOptions(options)
+	if err := im.syncBackendKey(inst); err != nil {
+		return nil, err
+	}
 
-	// If it was running before, start it again with the new options
-	if wasRunning {
-		if err := instance.Start(); err != nil {
+	// If it was restarted, start it again with the new options
+	if restart && inst.IsManaged() {
+		if err := inst.StartWithReason(instance.ReasonUserStart, "started with updated options"); err != nil {
 			return nil, fmt.Errorf("failed to start instance %s after update: %w", name, err)
 		}
 	}
 
 	im.mu.Lock()
 	defer im.mu.Unlock()
-	if err := im.persistInstance(instance); err != nil {
+	if err := im.persistInstance(inst); err != nil {
 		return nil, fmt.Errorf("failed to persist updated instance %s: %w", name, err)
 	}
+	im.recordAudit(actor, "update", name, "")
 
-	return instance, nil
+	return inst, nil
 }
 
-// DeleteInstance removes stopped instance by its name.
+// DeleteInstance removes a stopped instance by its name, moving it to the trash when the
+// trash is enabled.
 func (im *instanceManager) DeleteInstance(name string) error {
+	im.waitRestored(name)
+	return im.deleteInstance(name, DeleteOptions{}, "")
+}
+
+// DeleteInstanceWithOptions deletes an instance, moving it to the trash unless opts.Permanent
+// is set or the trash is disabled
+func (im *instanceManager) DeleteInstanceWithOptions(name string, opts DeleteOptions) error {
+	im.waitRestored(name)
+	return im.deleteInstance(name, opts, "")
+}
+
+// deleteInstance is DeleteInstanceWithOptions on behalf of actor
+func (im *instanceManager) deleteInstance(name string, opts DeleteOptions, actor string) error {
+	if err := im.checkConfigDelete(name); err != nil {
+		return err
+	}
+
+	var trashed *trashRecord
+	inst, err := im.removeInstance(name, func(inst *instance.Process) error {
+		if opts.Permanent || im.instancesConfig.TrashRetention <= 0 {
+			return nil // Without a trash, the definition is removed right away
+		}
+		var err error
+		trashed, err = im.trashInstance(inst, opts.PurgeLogs, actor)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if trashed != nil {
+		im.recordAudit(actor, "delete", name, "moved to the trash until "+trashed.PurgeAt.Format(time.RFC3339))
+	} else {
+		im.recordAudit(actor, "delete", name, "permanently")
+		if opts.PurgeLogs {
+			im.purgeLogs(name)
+		}
+	}
+	if err := im.saveBackendKey(name, ""); err != nil {
+		log.Printf("Failed to delete backend key of instance %s: %v", name, err)
+	}
+
+	// Join the instance's goroutines without holding the manager lock, since a pending
+	// restart reports its status through the manager
+	if err := inst.Close(); err != nil {
+		log.Printf("Instance %s deleted with goroutines still running: %v", name, err)
+	}
+
+	return nil
+}
+
+// removeInstance removes a stopped instance from the manager and deletes its config file,
+// calling beforeDelete first, under the lock, which may prevent the removal.
+func (im *instanceManager) removeInstance(name string, beforeDelete func(*instance.Process) error) (*instance.Process, error) {
 	im.mu.Lock()
 	defer im.mu.Unlock()
 
-	instance, exists := im.instances[name]
+	inst, exists := im.instances[name]
 	if !exists {
-		return fmt.Errorf("instance with name %s not found", name)
+		return nil, fmt.Errorf("instance with name %s not found", name)
 	}
 
-	if instance.IsRunning() {
-		return fmt.Errorf("instance with name %s is still running, stop it before deleting", name)
+	// Deleting an unmanaged instance only stops proxying to its external backend
+	if inst.IsRunning() && inst.IsManaged() {
+		return nil, fmt.Errorf("instance with name %s is still running, stop it before deleting", name)
+	}
+	if err := beforeDelete(inst); err != nil {
+		return nil, err
 	}
 
-	delete(im.ports, instance.GetPort())
+	delete(im.ports, inst.GetPort())
 	delete(im.instances, name)
+	delete(im.runningInstances, name)
 
-	// Delete the instance's config file if persistence is enabled
-	instancePath := filepath.Join(im.instancesConfig.InstancesDir, instance.Name+".json")
-	if err := os.Remove(instancePath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to delete config file for instance %s: %w", instance.Name, err)
+	// Delete the instance's definition if persistence is enabled
+	if im.store != nil {
+		if err := im.store.Delete(storage.NamespaceInstances, inst.Name); err != nil {
+			return nil, fmt.Errorf("failed to delete persisted instance %s: %w", inst.Name, err)
+		}
 	}
 
-	return nil
+	return inst, nil
 }
 
 // StartInstance starts a stopped instance and returns it.
 // If the instance is already running, it returns an error.
 func (im *instanceManager) StartInstance(name string) (*instance.Process, error) {
+	return im.startInstance(name, "", OperationOptions{})
+}
+
+// startInstance is StartInstance on behalf of actor
+func (im *instanceManager) startInstance(name string, actor string, opts OperationOptions) (*instance.Process, error) {
+	return im.startInstanceWithReason(name, instance.ReasonUserStart, "", actor, opts)
+}
+
+// startInstanceWithReason starts a stopped instance on behalf of actor, recording the given
+// reason for the start.
+func (im *instanceManager) startInstanceWithReason(name string, code instance.ReasonCode, message string, actor string, opts OperationOptions) (*instance.Process, error) {
+	inst, finish, err := im.beginOperation(name, instance.OperationStart, opts)
+	if err != nil {
+		return nil, err
+	}
+	inst, err = im.startInOperation(inst, code, message, actor)
+	finish(err)
+	return inst, err
+}
+
+// startInOperation starts inst within a lifecycle operation already begun on it
+func (im *instanceManager) startInOperation(inst *instance.Process, code instance.ReasonCode, message string, actor string) (*instance.Process, error) {
+	name := inst.Name
+	services := im.getServices()
 	im.mu.RLock()
-	instance, exists := im.instances[name]
-	maxRunningExceeded := len(im.runningInstances) >= im.instancesConfig.MaxRunningInstances && im.instancesConfig.MaxRunningInstances != -1
+	maxRunningExceeded := im.runningManagedCount() >= im.instancesConfig.MaxRunningInstances && im.instancesConfig.MaxRunningInstances != -1
+	peers := im.runningPeers(name)
 	im.mu.RUnlock()
 
-	if !exists {
-		return nil, fmt.Errorf("instance with name %s not found", name)
+	if !inst.IsManaged() {
+		return nil, fmt.Errorf("cannot start instance %s: %w", name, instance.ErrUnmanaged)
 	}
-	if instance.IsRunning() {
-		return instance, fmt.Errorf("instance with name %s is already running", name)
+	if inst.IsRunning() {
+		return inst, fmt.Errorf("instance with name %s is already running", name)
 	}
 
 	if maxRunningExceeded {
 		return nil, MaxRunningInstancesError(fmt.Errorf("maximum number of running instances (%d) reached", im.instancesConfig.MaxRunningInstances))
 	}
+	if err := placeInstance(inst, services, peers); err != nil {
+		return nil, err
+	}
+
+	if err := im.fetchRemoteModel(inst); err != nil {
+		return nil, err
+	}
 
-	if err := instance.Start(); err != nil {
+	start := inst.StartWithReason
+	if im.instancesConfig.LimitManualStarts {
+		start = inst.StartQueuedWithReason // Waits behind the restarts loading their model
+	}
+	if err := start(code, message); err != nil {
 		return nil, fmt.Errorf("failed to start instance %s: %w", name, err)
 	}
+	details := ""
+	if code != instance.ReasonUserStart {
+		details = string(code)
+	}
+	im.recordAudit(actor, "start", name, details)
 
 	im.mu.Lock()
 	defer im.mu.Unlock()
-	err := im.persistInstance(instance)
+	err := im.persistInstance(inst)
 	if err != nil {
 		return nil, fmt.Errorf("failed to persist instance %s: %w", name, err)
 	}
 
-	return instance, nil
+	return inst, nil
 }
 
 func (im *instanceManager) IsMaxRunningInstancesReached() bool {
 	im.mu.RLock()
 	defer im.mu.RUnlock()
 
-	if im.instancesConfig.MaxRunningInstances != -1 && len(im.runningInstances) >= im.instancesConfig.MaxRunningInstances {
+	if im.instancesConfig.MaxRunningInstances != -1 && im.runningManagedCount() >= im.instancesConfig.MaxRunningInstances {
 		return true
 	}
 
@@ -205,38 +441,275 @@ func (im *instanceManager) IsMaxRunningInstancesReached() bool {
 
 // StopInstance stops a running instance and returns it.
 func (im *instanceManager) StopInstance(name string) (*instance.Process, error) {
+	return im.stopInstance(name, instance.ReasonUserStop, "", "", OperationOptions{})
+}
+
+// stopInstance stops a running instance on behalf of actor, recording the given reason for the stop.
+func (im *instanceManager) stopInstance(name string, code instance.ReasonCode, message string, actor string, opts OperationOptions) (*instance.Process, error) {
+	inst, finish, err := im.beginOperation(name, instance.OperationStop, opts)
+	if err != nil {
+		return nil, err
+	}
+	inst, err = im.stopInOperation(inst, code, message, actor)
+	finish(err)
+	return inst, err
+}
+
+// stopInOperation stops inst within a lifecycle operation already begun on it
+func (im *instanceManager) stopInOperation(inst *instance.Process, code instance.ReasonCode, message string, actor string) (*instance.Process, error) {
+	name := inst.Name
+	if !inst.IsManaged() {
+		return nil, fmt.Errorf("cannot stop instance %s: %w", name, instance.ErrUnmanaged)
+	}
+	if !inst.IsRunning() {
+		return inst, fmt.Errorf("instance with name %s is already stopped", name)
+	}
+
+	if err := inst.StopWithReason(code, message); err != nil {
+		return nil, fmt.Errorf("failed to stop instance %s: %w", name, err)
+	}
+	im.recordAudit(actor, "stop", name, string(code))
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	err := im.persistInstance(inst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist instance %s: %w", name, err)
+	}
+
+	return inst, nil
+}
+
+// CancelStart aborts the start of an instance that is loading its model or waiting to be
+// started, killing its backend, and returns the stopped instance.
+func (im *instanceManager) CancelStart(name string) (*instance.Process, error) {
+	return im.cancelStart(name, "")
+}
+
+// cancelStart is CancelStart on behalf of actor
+func (im *instanceManager) cancelStart(name string, actor string) (*instance.Process, error) {
 	im.mu.RLock()
-	instance, exists := im.instances[name]
+	inst, exists := im.instances[name]
 	im.mu.RUnlock()
 
 	if !exists {
 		return nil, fmt.Errorf("instance with name %s not found", name)
 	}
-	if !instance.IsRunning() {
-		return instance, fmt.Errorf("instance with name %s is already stopped", name)
+	if err := inst.CancelStart(); err != nil {
+		return nil, err
 	}
+	im.recordAudit(actor, "cancel_start", name, "")
 
-	if err := instance.Stop(); err != nil {
-		return nil, fmt.Errorf("failed to stop instance %s: %w", name, err)
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	if err := im.persistInstance(inst); err != nil {
+		return nil, fmt.Errorf("failed to persist instance %s: %w", name, err)
+	}
+
+	return inst, nil
+}
+
+// SignalInstance sends a signal to the backend of a running instance. A backend the signal
+// kills is handled as any other exit of the backend.
+func (im *instanceManager) SignalInstance(name string, sig syscall.Signal) (*instance.Process, error) {
+	return im.signalInstance(name, sig, "")
+}
+
+// signalInstance is SignalInstance on behalf of actor
+func (im *instanceManager) signalInstance(name string, sig syscall.Signal, actor string) (*instance.Process, error) {
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := inst.Signal(sig); err != nil {
+		return nil, err
+	}
+	im.recordAudit(actor, "signal", name, "SIG"+instance.SignalName(sig))
+	return inst, nil
+}
+
+// RestartInstance stops and then starts an instance as a single operation, returning the
+// updated instance. A stopped instance is started. The slots of an instance preserving them
+// are saved first, and restored once it is ready again.
+func (im *instanceManager) RestartInstance(name string) (*instance.Process, error) {
+	return im.restartInstance(name, "", OperationOptions{})
+}
+
+// restartInstance is RestartInstance on behalf of actor
+func (im *instanceManager) restartInstance(name string, actor string, opts OperationOptions) (*instance.Process, error) {
+	inst, finish, err := im.beginOperation(name, instance.OperationRestart, opts)
+	if err != nil {
+		return nil, err
 	}
+	inst, err = im.restartInOperation(inst, actor)
+	finish(err)
+	return inst, err
+}
+
+// restartInOperation restarts inst within a lifecycle operation already begun on it
+func (im *instanceManager) restartInOperation(inst *instance.Process, actor string) (*instance.Process, error) {
+	name := inst.Name
+	if !inst.IsManaged() {
+		return nil, fmt.Errorf("cannot restart instance %s: %w", name, instance.ErrUnmanaged)
+	}
+	if !inst.IsRunning() {
+		// Nothing to stop: a regular start, subject to the limit of running instances
+		return im.startInOperation(inst, instance.ReasonUserStart, "", actor)
+	}
+
+	if inst.PreservesSlots() {
+		// The restart goes ahead without the slots when they cannot be saved
+		if saved, err := inst.SaveSlots(); err != nil {
+			log.Printf("Warning: failed to save the slots of instance %s, restarting without them: %v", name, err)
+		} else {
+			log.Printf("Saved %d slots of instance %s before restarting it", saved, name)
+		}
+	}
+
+	if err := inst.Restart(); err != nil {
+		return nil, fmt.Errorf("failed to restart instance %s: %w", name, err)
+	}
+	im.recordAudit(actor, "restart", name, "")
 
 	im.mu.Lock()
 	defer im.mu.Unlock()
-	err := im.persistInstance(instance)
+	if err := im.persistInstance(inst); err != nil {
+		return nil, fmt.Errorf("failed to persist instance %s: %w", name, err)
+	}
+
+	return inst, nil
+}
+
+// BinaryRestart is the outcome of a restart made only if the backend binary changed
+type BinaryRestart struct {
+	Restarted bool              `json:"restarted"`
+	Message   string            `json:"message"` // Why the instance was restarted or not
+	Instance  *instance.Process `json:"instance"`
+}
+
+// RestartInstanceIfBinaryChanged restarts a running instance only when the backend binary a
+// new start would run differs, by checksum, from the one its process was launched from, so
+// that instances already running a new build are not recycled. A stopped instance runs no
+// binary and is left alone.
+func (im *instanceManager) RestartInstanceIfBinaryChanged(name string) (*BinaryRestart, error) {
+	return im.restartInstanceIfBinaryChanged(name, "", OperationOptions{})
+}
+
+// restartInstanceIfBinaryChanged is RestartInstanceIfBinaryChanged on behalf of actor
+func (im *instanceManager) restartInstanceIfBinaryChanged(name string, actor string, opts OperationOptions) (*BinaryRestart, error) {
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	if !inst.IsManaged() {
+		return nil, fmt.Errorf("cannot restart instance %s: %w", name, instance.ErrUnmanaged)
+	}
+
+	changed, reason, err := inst.BinaryChanged()
+	if errors.Is(err, instance.ErrNotRunning) {
+		return &BinaryRestart{Message: "not needed: instance is not running", Instance: inst}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		return &BinaryRestart{Message: "not needed: " + reason, Instance: inst}, nil
+	}
+	log.Printf("Restarting instance %s: %s", name, reason)
+	if inst, err = im.restartInstance(name, actor, opts); err != nil {
+		return nil, err
+	}
+	return &BinaryRestart{Restarted: true, Message: reason, Instance: inst}, nil
+}
+
+// ReloadInstance replaces the backend of a running instance without taking it offline: a
+// second process is started on a free port with the options of the next start, and the
+// proxy switches over to it once it is ready, the previous process being stopped after. The
+// instance keeps its previous process when the replacement does not become ready.
+func (im *instanceManager) ReloadInstance(name string) (*instance.Process, error) {
+	return im.reloadInstance(name, "", OperationOptions{})
+}
+
+// reloadInstance is ReloadInstance on behalf of actor
+func (im *instanceManager) reloadInstance(name string, actor string, opts OperationOptions) (*instance.Process, error) {
+	inst, finish, err := im.beginOperation(name, instance.OperationReload, opts)
+	if err != nil {
+		return nil, err
+	}
+	inst, err = im.reloadInOperation(inst, actor)
+	finish(err)
+	return inst, err
+}
+
+// reloadInOperation reloads inst within a lifecycle operation already begun on it
+func (im *instanceManager) reloadInOperation(inst *instance.Process, actor string) (*instance.Process, error) {
+	name := inst.Name
+	im.mu.Lock()
+	port, err := im.getNextAvailablePort()
+	im.mu.Unlock()
 	if err != nil {
+		return nil, fmt.Errorf("failed to get a port for the replacement of instance %s: %w", name, err)
+	}
+
+	previousPort := inst.GetPort()
+	if err := inst.Reload(port); err != nil {
+		im.mu.Lock()
+		delete(im.ports, port)
+		im.mu.Unlock()
+		return nil, err
+	}
+	im.recordAudit(actor, "reload", name, fmt.Sprintf("port %d -> %d", previousPort, port))
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	delete(im.ports, previousPort)
+	if err := im.persistInstance(inst); err != nil {
 		return nil, fmt.Errorf("failed to persist instance %s: %w", name, err)
 	}
+	return inst, nil
+}
+
+// ApplyPendingOptions restarts an instance whose options were updated while it was running,
+// so that they take effect right away. An instance without pending options is left alone.
+func (im *instanceManager) ApplyPendingOptions(name string) (*instance.Process, error) {
+	return im.applyPendingOptions(name, "", OperationOptions{})
+}
+
+// applyPendingOptions is ApplyPendingOptions on behalf of actor
+func (im *instanceManager) applyPendingOptions(name string, actor string, opts OperationOptions) (*instance.Process, error) {
+	inst, finish, err := im.beginOperation(name, instance.OperationRestart, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !inst.RestartRequired() {
+		finish(nil)
+		return inst, nil
+	}
+	inst, err = im.restartInOperation(inst, actor)
+	finish(err)
+	return inst, err
+}
 
-	return instance, nil
+// RetryInstance starts an instance that failed, typically after exhausting its restart
+// attempts. The restart counter starts over, as on any start.
+func (im *instanceManager) RetryInstance(name string) (*instance.Process, error) {
+	return im.retryInstance(name, "", OperationOptions{})
 }
 
-// RestartInstance stops and then starts an instance, returning the updated instance.
-func (im *instanceManager) RestartInstance(name string) (*instance.Process, error) {
-	instance, err := im.StopInstance(name)
+// retryInstance is RetryInstance on behalf of actor
+func (im *instanceManager) retryInstance(name string, actor string, opts OperationOptions) (*instance.Process, error) {
+	inst, finish, err := im.beginOperation(name, instance.OperationStart, opts)
 	if err != nil {
 		return nil, err
 	}
-	return im.StartInstance(instance.Name)
+	if status := inst.GetStatus(); status != instance.Failed {
+		err = fmt.Errorf("cannot retry instance %s, it is %s: %w", name, status, ErrNotFailed)
+		finish(err)
+		return nil, err
+	}
+	inst, err = im.startInOperation(inst, instance.ReasonUserStart, "", actor)
+	finish(err)
+	return inst, err
 }
 
 // GetInstanceLogs retrieves the logs for a specific instance by its name.
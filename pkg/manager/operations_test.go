@@ -1,13 +1,21 @@
 package manager_test
 
 import (
+	"context"
+	"errors"
 	"llamactl/pkg/backends"
 	"llamactl/pkg/backends/llamacpp"
 	"llamactl/pkg/config"
 	"llamactl/pkg/instance"
 	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"llamactl/pkg/testutil"
+	"llamactl/pkg/validation"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestCreateInstance_Success(t *testing.T) {
@@ -73,7 +81,7 @@ func TestCreateInstance_ValidationAndLimits(t *testing.T) {
 	cfg := config.InstancesConfig{
 		PortRange:            [2]int{8000, 9000},
 		MaxInstances:         1, // Very low limit for testing
-		TimeoutCheckInterval: 5,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
 	}
 	limitedManager := manager.NewInstanceManager(backendConfig, cfg)
 
@@ -92,6 +100,35 @@ func TestCreateInstance_ValidationAndLimits(t *testing.T) {
 	}
 }
 
+func TestCreateInstance_CommandsNotAllowedByDefault(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+
+	_, err := mngr.CreateInstance("hooked", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		OnStartCmd:         "touch /tmp/started",
+		LaunchWrapper:      []string{"nice"},
+		LlamaExecutable:    "/opt/llama.cpp/bin/llama-server",
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	})
+	var validationErr *validation.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	var fields []string
+	for _, fieldErr := range validationErr.Errors {
+		if fieldErr.Constraint == validation.ConstraintNotAllowed {
+			fields = append(fields, fieldErr.Field)
+		}
+	}
+	if expected := []string{"launch_wrapper", "llama_executable", "on_start_cmd"}; !slices.Equal(fields, expected) {
+		t.Errorf("Expected %v to be refused, got %+v", expected, validationErr.Errors)
+	}
+	if _, err := mngr.GetInstance("hooked"); err == nil {
+		t.Error("Expected the instance not to be created")
+	}
+}
+
 func TestPortManagement(t *testing.T) {
 	manager := createTestManager()
 
@@ -112,6 +149,9 @@ func TestPortManagement(t *testing.T) {
 	if port1 < 8000 || port1 > 9000 {
 		t.Errorf("Expected port in range 8000-9000, got %d", port1)
 	}
+	if sources := inst1.GetOptionSources(); sources["backend_options.port"] != instance.SourceInferred {
+		t.Errorf("Expected the assigned port to be inferred, got source %q", sources["backend_options.port"])
+	}
 
 	// Test port conflict detection
 	options2 := &instance.CreateInstanceOptions{
@@ -235,3 +275,289 @@ func TestInstanceOperations(t *testing.T) {
 		t.Errorf("Expected 'not found' error, got: %v", err)
 	}
 }
+
+func TestRetryInstance(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "exit 1"}},
+	}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		LogsDir:              t.TempDir(),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	mngr := manager.NewInstanceManager(backendConfig, cfg)
+	defer mngr.Shutdown()
+
+	inst, err := mngr.CreateInstance("crashing", &instance.CreateInstanceOptions{
+		BackendType:  backends.BackendTypeLlamaCpp,
+		AutoRestart:  testutil.BoolPtr(true),
+		MaxRestarts:  testutil.IntPtr(1),
+		RestartDelay: testutil.DurationPtr(0),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.RetryInstance("crashing"); !errors.Is(err, manager.ErrNotFailed) {
+		t.Errorf("Expected a stopped instance not to be retried, got %v", err)
+	}
+
+	waitForFailed := func() {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for inst.GetStatus() != instance.Failed {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected the instance to fail, it is %s", inst.GetStatus())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	if _, err := mngr.StartInstance("crashing"); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	waitForFailed()
+	if lastErr := inst.GetLastError(); lastErr == nil || lastErr.Code != instance.ReasonMaxRestartsExceeded || lastErr.Timestamp.IsZero() {
+		t.Fatalf("Expected the last error to record the exhausted restarts, got %+v", lastErr)
+	}
+	failedAt := inst.GetLastError().Timestamp
+
+	// A retry starts over with the whole restart budget
+	if _, err := mngr.RetryInstance("crashing"); err != nil {
+		t.Fatalf("RetryInstance failed: %v", err)
+	}
+	waitForFailed()
+	if lastErr := inst.GetLastError(); lastErr.Code != instance.ReasonMaxRestartsExceeded || !lastErr.Timestamp.After(failedAt) {
+		t.Errorf("Expected the retried instance to exhaust its restarts again, got %+v", lastErr)
+	}
+}
+
+func TestRestartInstance(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "exec sleep 30"}},
+	}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		LogsDir:              t.TempDir(),
+		MaxInstances:         10,
+		MaxRunningInstances:  1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	mngr := manager.NewInstanceManager(backendConfig, cfg)
+	defer mngr.Shutdown()
+
+	for _, name := range []string{"first", "second"} {
+		if _, err := mngr.CreateInstance(name, &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+		}); err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+	}
+
+	// A stopped instance is started
+	inst, err := mngr.RestartInstance("first")
+	if err != nil {
+		t.Fatalf("RestartInstance of a stopped instance failed: %v", err)
+	}
+	if !inst.IsRunning() {
+		t.Fatalf("Expected the instance to run, it is %s", inst.GetStatus())
+	}
+
+	// A running instance does not count against the limit it restarts under
+	if _, err := mngr.RestartInstance("first"); err != nil {
+		t.Fatalf("RestartInstance of a running instance failed: %v", err)
+	}
+	if !inst.IsRunning() {
+		t.Errorf("Expected the instance to run after the restart, it is %s", inst.GetStatus())
+	}
+
+	if _, err := mngr.RestartInstance("second"); err == nil {
+		t.Error("Expected starting a stopped instance by restarting it to respect the running instances limit")
+	}
+}
+
+func TestLifecycleOperations_Serialized(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "exec sleep 30"}},
+	}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		LogsDir:              t.TempDir(),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	mngr := manager.NewInstanceManager(backendConfig, cfg)
+	defer mngr.Shutdown()
+
+	inst, err := mngr.CreateInstance("serialized", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	finish, err := inst.BeginOperation(context.Background(), instance.OperationRestart, false)
+	if err != nil {
+		t.Fatalf("BeginOperation failed: %v", err)
+	}
+
+	// A caller that does not queue is refused with the operation in progress
+	var inProgress *instance.OperationInProgressError
+	if _, err := mngr.WithOperationOptions(manager.OperationOptions{NoQueue: true}).StartInstance("serialized"); !errors.As(err, &inProgress) {
+		t.Fatalf("Expected an operation in progress error, got %v", err)
+	}
+	if inProgress.Operation.Type != instance.OperationRestart {
+		t.Errorf("Expected the restart in progress to be reported, got %+v", inProgress.Operation)
+	}
+
+	// A queued caller gives up once its context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := mngr.WithOperationOptions(manager.OperationOptions{Context: ctx}).StopInstance("serialized"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled wait to fail, got %v", err)
+	}
+
+	// The manager's own callers wait their turn
+	started := make(chan error, 1)
+	go func() {
+		_, err := mngr.StartInstance("serialized")
+		started <- err
+	}()
+	select {
+	case err := <-started:
+		t.Fatalf("Expected the start to wait for the operation in progress, it returned %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	finish(nil)
+	select {
+	case err := <-started:
+		if err != nil {
+			t.Fatalf("StartInstance failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the start to run once the operation in progress finished")
+	}
+
+	// Restarting the running instance is a single operation, its start included
+	if _, err := mngr.RestartInstance("serialized"); err != nil {
+		t.Fatalf("RestartInstance failed: %v", err)
+	}
+	var types []instance.OperationType
+	for _, op := range inst.GetOperations().Recent {
+		if op.State == instance.OperationSucceeded {
+			types = append(types, op.Type)
+		}
+	}
+	want := []instance.OperationType{instance.OperationRestart, instance.OperationStart, instance.OperationRestart}
+	if !slices.Equal(types, want) {
+		t.Errorf("Expected the succeeded operations %v, most recent first, got %v", want, types)
+	}
+	if _, err := mngr.StopInstance("serialized"); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+}
+
+func TestUpdateInstance_KeepsMaskedSecrets(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         tempDir,
+		LogsDir:              t.TempDir(),
+		MaxInstances:         10,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	store := storage.NewFileStore(tempDir, tempDir)
+	mngr := manager.NewInstanceManagerWithStore(config.BackendConfig{LlamaCpp: config.BackendSettings{Command: "llama-server"}}, cfg, store)
+	defer mngr.Shutdown()
+
+	options := func(environment map[string]string) *instance.CreateInstanceOptions {
+		return &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			Environment:        environment,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: 8080},
+		}
+	}
+	if _, err := mngr.CreateInstance("secret", options(map[string]string{"HF_TOKEN": "hf_secret"})); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	// The environment sent back as it was read, with another variable
+	inst, err := mngr.UpdateInstance("secret", options(map[string]string{"HF_TOKEN": instance.MaskedEnvironmentValue, "CUDA_VISIBLE_DEVICES": "1"}))
+	if err != nil {
+		t.Fatalf("UpdateInstance failed: %v", err)
+	}
+	if environment := inst.GetOptions().Environment; environment["HF_TOKEN"] != "hf_secret" || environment["CUDA_VISIBLE_DEVICES"] != "1" {
+		t.Errorf("Expected the secret to keep its value, got %v", environment)
+	}
+
+	data, err := store.Get(storage.NamespaceInstances, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "hf_secret") {
+		t.Errorf("Expected the persisted definition to keep the secret, got %s", data)
+	}
+}
+
+func TestUpdateInstance_PendingUntilApplied(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "exec sleep 30"}},
+	}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		LogsDir:              t.TempDir(),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	mngr := manager.NewInstanceManager(backendConfig, cfg)
+	defer mngr.Shutdown()
+
+	options := func(model string) *instance.CreateInstanceOptions {
+		return &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: model, Port: 8080},
+		}
+	}
+	if _, err := mngr.CreateInstance("pending", options("/path/to/model.gguf")); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.StartInstance("pending"); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+
+	// The running instance keeps its options
+	inst, err := mngr.UpdateInstance("pending", options("/path/to/other.gguf"))
+	if err != nil {
+		t.Fatalf("UpdateInstance failed: %v", err)
+	}
+	if !inst.IsRunning() || !inst.RestartRequired() || inst.GetOptions().LlamaServerOptions.Model != "/path/to/model.gguf" {
+		t.Fatalf("Expected the update to be pending, got %s with %+v", inst.GetStatus(), inst.GetOptions().LlamaServerOptions)
+	}
+
+	// Applying them restarts the instance
+	if inst, err = mngr.ApplyPendingOptions("pending"); err != nil {
+		t.Fatalf("ApplyPendingOptions failed: %v", err)
+	}
+	if !inst.IsRunning() || inst.RestartRequired() || inst.GetOptions().LlamaServerOptions.Model != "/path/to/other.gguf" {
+		t.Errorf("Expected the restart to apply the options, got %s with %+v", inst.GetStatus(), inst.GetOptions().LlamaServerOptions)
+	}
+}
@@ -0,0 +1,144 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrPlacement is returned when creating or starting an instance would break the GPU
+// placement a service requires
+var ErrPlacement = errors.New("GPU placement of the service cannot be satisfied")
+
+// getServices returns the configured services
+func (im *instanceManager) getServices() map[string]config.ServiceConfig {
+	im.standbyMu.Lock()
+	defer im.standbyMu.Unlock()
+	return im.services
+}
+
+// runningPeers returns the running managed instances other than name (caller must hold the lock)
+func (im *instanceManager) runningPeers(name string) map[string]*instance.Process {
+	peers := make(map[string]*instance.Process)
+	for peerName, peer := range im.instances {
+		if peerName != name && peer.IsRunning() {
+			peers[peerName] = peer
+		}
+	}
+	return peers
+}
+
+// placeInstance evaluates the GPU placement policies of the services inst is a member of
+// against peers, the other members it has to be placed with, and records the decisions on
+// the instance. A policy broken in prefer mode is logged, one broken in require mode returns
+// an error wrapping ErrPlacement.
+func placeInstance(inst *instance.Process, services map[string]config.ServiceConfig, peers map[string]*instance.Process) error {
+	if !inst.IsManaged() {
+		return nil
+	}
+
+	aliases := make([]string, 0, len(services))
+	for alias, svc := range services {
+		if svc.Placement != "" && slices.Contains(svc.Members, inst.Name) {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+
+	var decisions []instance.PlacementDecision
+	var placementErr error
+	gpus := inst.GPUs()
+	for _, alias := range aliases {
+		svc := services[alias]
+		mode := svc.PlacementMode
+		if mode == "" {
+			mode = config.PlacementPrefer
+		}
+
+		placed, conflicts := 0, []string{}
+		for _, member := range svc.Members {
+			peer, ok := peers[member]
+			if !ok || member == inst.Name || !peer.IsManaged() {
+				continue
+			}
+			placed++
+			peerGPUs := peer.GPUs()
+			if svc.Placement == config.PlacementAntiAffinity && sharesGPU(gpus, peerGPUs) ||
+				svc.Placement == config.PlacementAffinity && !sameGPUs(gpus, peerGPUs) {
+				conflicts = append(conflicts, member)
+			}
+		}
+
+		decision := instance.PlacementDecision{
+			Service:   alias,
+			Policy:    svc.Placement,
+			Mode:      mode,
+			GPUs:      gpus,
+			Satisfied: len(conflicts) == 0,
+			Conflicts: conflicts,
+			Timestamp: time.Now(),
+		}
+		switch {
+		case placed == 0:
+			decision.Message = "no other member to place it against"
+		case decision.Satisfied && svc.Placement == config.PlacementAntiAffinity:
+			decision.Message = "runs on distinct GPUs from the other members"
+		case decision.Satisfied:
+			decision.Message = "runs on the same GPUs as the other members"
+		case svc.Placement == config.PlacementAntiAffinity && gpus == nil:
+			decision.Message = fmt.Sprintf("may use every GPU, so it shares them with %s; restrict it with CUDA_VISIBLE_DEVICES or the device option", strings.Join(conflicts, ", "))
+		case svc.Placement == config.PlacementAntiAffinity:
+			decision.Message = fmt.Sprintf("shares a GPU with %s", strings.Join(conflicts, ", "))
+		default:
+			decision.Message = fmt.Sprintf("runs on other GPUs than %s", strings.Join(conflicts, ", "))
+		}
+		decisions = append(decisions, decision)
+
+		if !decision.Satisfied {
+			if mode == config.PlacementRequire {
+				placementErr = errors.Join(placementErr, fmt.Errorf("instance %s breaks the %s placement of service %s, it %s: %w", inst.Name, svc.Placement, alias, decision.Message, ErrPlacement))
+			} else {
+				log.Printf("Warning: instance %s breaks the %s placement of service %s: it %s", inst.Name, svc.Placement, alias, decision.Message)
+			}
+		}
+	}
+
+	if placementErr != nil {
+		return placementErr
+	}
+	inst.SetPlacement(decisions)
+	return nil
+}
+
+// sharesGPU reports whether two instances may use a common GPU, nil standing for every GPU
+func sharesGPU(a, b []string) bool {
+	if (a != nil && len(a) == 0) || (b != nil && len(b) == 0) {
+		return false // Runs on the CPU
+	}
+	if a == nil || b == nil {
+		return true
+	}
+	for _, gpu := range a {
+		if slices.Contains(b, gpu) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameGPUs reports whether two instances use the same GPUs, nil standing for every GPU
+func sameGPUs(a, b []string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
@@ -0,0 +1,123 @@
+package manager_test
+
+import (
+	"errors"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"testing"
+)
+
+// pinnedOptions returns the options of an instance seeing the given CUDA devices, every GPU
+// if devices is empty, and using the llama.cpp device option if set
+func pinnedOptions(devices, device string) *instance.CreateInstanceOptions {
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Device: device},
+	}
+	if devices != "" {
+		options.Environment = map[string]string{"CUDA_VISIBLE_DEVICES": devices}
+	}
+	return options
+}
+
+func TestPlacement_AntiAffinity(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+
+	mngr.SetServices(map[string]config.ServiceConfig{
+		"chat": {
+			Members:       []string{"chat-1", "chat-2", "chat-3", "chat-4"},
+			Placement:     config.PlacementAntiAffinity,
+			PlacementMode: config.PlacementRequire,
+		},
+	})
+
+	first, err := mngr.CreateInstance("chat-1", pinnedOptions("0", ""))
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if len(first.Placement) != 1 || !first.Placement[0].Satisfied {
+		t.Errorf("Expected a satisfied placement without other members, got %+v", first.Placement)
+	}
+
+	// Device indexes count the visible devices, so CUDA1 of 0,2 is GPU 2
+	second, err := mngr.CreateInstance("chat-2", pinnedOptions("0,2", "CUDA1"))
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if decision := second.Placement[0]; !decision.Satisfied || len(decision.GPUs) != 1 || decision.GPUs[0] != "2" {
+		t.Errorf("Expected chat-2 on GPU 2 apart from chat-1, got %+v", decision)
+	}
+
+	// An unrestricted instance may use every GPU
+	if _, err := mngr.CreateInstance("chat-3", pinnedOptions("", "")); !errors.Is(err, manager.ErrPlacement) {
+		t.Errorf("Expected an unrestricted member to be refused, got %v", err)
+	}
+	if _, err := mngr.CreateInstance("chat-3", pinnedOptions("", "CUDA2")); !errors.Is(err, manager.ErrPlacement) {
+		t.Errorf("Expected a member sharing GPU 2 to be refused, got %v", err)
+	}
+	if _, err := mngr.GetInstance("chat-3"); err == nil {
+		t.Error("Expected the refused instance not to be created")
+	}
+
+	// Instances on the CPU share no GPU
+	if _, err := mngr.CreateInstance("chat-3", pinnedOptions("", "none")); err != nil {
+		t.Errorf("Expected a CPU member to be placed, got %v", err)
+	}
+
+	// Instances outside the service are not placed
+	other, err := mngr.CreateInstance("other", pinnedOptions("0", ""))
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if other.Placement != nil {
+		t.Errorf("Expected no placement outside services, got %+v", other.Placement)
+	}
+}
+
+func TestPlacement_PreferAndAffinity(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+
+	mngr.SetServices(map[string]config.ServiceConfig{
+		"spread": {
+			Members:   []string{"spread-1", "spread-2"},
+			Placement: config.PlacementAntiAffinity,
+		},
+		"paired": {
+			Members:       []string{"main", "draft"},
+			Placement:     config.PlacementAffinity,
+			PlacementMode: config.PlacementRequire,
+		},
+	})
+
+	if _, err := mngr.CreateInstance("spread-1", pinnedOptions("0", "")); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	// Broken preferences are reported, not refused
+	inst, err := mngr.CreateInstance("spread-2", pinnedOptions("0", ""))
+	if err != nil {
+		t.Fatalf("Expected a preferred placement not to be enforced, got %v", err)
+	}
+	decision := inst.Placement[0]
+	if decision.Satisfied || decision.Mode != config.PlacementPrefer || len(decision.Conflicts) != 1 || decision.Conflicts[0] != "spread-1" {
+		t.Errorf("Expected a broken preference conflicting with spread-1, got %+v", decision)
+	}
+
+	if _, err := mngr.CreateInstance("main", pinnedOptions("GPU-52a1", "")); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.CreateInstance("draft", pinnedOptions("GPU-7c3e", "")); !errors.Is(err, manager.ErrPlacement) {
+		t.Errorf("Expected a member on other GPUs to be refused, got %v", err)
+	}
+	inst, err = mngr.CreateInstance("draft", pinnedOptions("GPU-52a1", ""))
+	if err != nil {
+		t.Fatalf("Expected a member on the same GPU to be placed, got %v", err)
+	}
+	if !inst.Placement[0].Satisfied {
+		t.Errorf("Expected a satisfied affinity, got %+v", inst.Placement[0])
+	}
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"fmt"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/validation"
+	"maps"
+)
+
+// SetPromptTemplate adds or replaces a prompt template of an instance, without restarting it
+func (im *instanceManager) SetPromptTemplate(name, template, text string) error {
+	return im.setPromptTemplate(name, template, text, "")
+}
+
+// setPromptTemplate is SetPromptTemplate on behalf of actor
+func (im *instanceManager) setPromptTemplate(name, template, text, actor string) error {
+	if err := im.checkConfigUpdate(name); err != nil {
+		return err
+	}
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return err
+	}
+	templates := maps.Clone(inst.GetPromptTemplates())
+	if templates == nil {
+		templates = map[string]string{}
+	}
+	templates[template] = text
+	if err := validation.ValidatePromptTemplates(&instance.CreateInstanceOptions{PromptTemplates: templates}); err != nil {
+		return err
+	}
+
+	if err := inst.SetPromptTemplate(template, text); err != nil {
+		return err
+	}
+	if err := im.persistPromptTemplates(inst); err != nil {
+		return err
+	}
+	im.recordAudit(actor, "set_prompt_template", name, template)
+	return nil
+}
+
+// DeletePromptTemplate removes a prompt template of an instance, without restarting it
+func (im *instanceManager) DeletePromptTemplate(name, template string) error {
+	return im.deletePromptTemplate(name, template, "")
+}
+
+// deletePromptTemplate is DeletePromptTemplate on behalf of actor
+func (im *instanceManager) deletePromptTemplate(name, template, actor string) error {
+	if err := im.checkConfigUpdate(name); err != nil {
+		return err
+	}
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := inst.GetPromptTemplates()[template]; !ok {
+		return fmt.Errorf("%w: %s", instance.ErrPromptTemplateNotFound, template)
+	}
+
+	if err := inst.SetPromptTemplate(template, ""); err != nil {
+		return err
+	}
+	if err := im.persistPromptTemplates(inst); err != nil {
+		return err
+	}
+	im.recordAudit(actor, "delete_prompt_template", name, template)
+	return nil
+}
+
+// persistPromptTemplates saves an instance once its prompt templates changed
+func (im *instanceManager) persistPromptTemplates(inst *instance.Process) error {
+	im.mu.Lock()
+	err := im.persistInstance(inst)
+	im.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to persist instance %s: %w", inst.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package manager
+
+import (
+	"llamactl/pkg/instance"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// States of the initial restore of the instances
+const (
+	RestoreStateRestoring = "restoring" // The initial reconciliation pass is running, or about to
+	RestoreStateReady     = "ready"     // Every instance was restored
+)
+
+// Progress of the restore of a single instance
+const (
+	RestorePending    = "pending"    // Waiting for its turn
+	RestoreAdopted    = "adopted"    // Attached to the process the previous llamactl left running
+	RestoreStarted    = "started"    // Started again, it was running when llamactl stopped
+	RestoreStopped    = "stopped"    // Left stopped, it was running but auto-restart is disabled
+	RestoreReconciled = "reconciled" // Created or updated from the static instances of the configuration
+	RestoreFailed     = "failed"
+)
+
+// RestoreStatus is the progress of the initial restore of the instances, from their persisted
+// state and the static instances of the configuration
+type RestoreStatus struct {
+	State       string            `json:"state"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	Total       int               `json:"total"`
+	Pending     int               `json:"pending"`
+	Instances   []InstanceRestore `json:"instances"`
+}
+
+// InstanceRestore is the progress of the restore of a single instance
+type InstanceRestore struct {
+	Name     string `json:"name"`
+	Progress string `json:"progress"`
+	Error    string `json:"error,omitempty"`
+}
+
+// restoreRun tracks the initial restore. Instances with something left to restore have an
+// entry, whose channel is closed once they are restored: changes made to them through the
+// API wait for it instead of racing the restore.
+type restoreRun struct {
+	mu          sync.Mutex
+	once        sync.Once
+	startedAt   time.Time
+	completedAt time.Time
+	entries     map[string]*restoreEntry
+	done        chan struct{} // Closed once the pass completed
+}
+
+// restoreEntry is an instance being restored
+type restoreEntry struct {
+	status   instance.InstanceStatus // Persisted status to restore, Stopped for static instances only
+	static   bool                    // Reconciled with the configuration once its persisted state is restored
+	progress string
+	err      string
+	done     chan struct{}
+}
+
+func newRestoreRun() *restoreRun {
+	return &restoreRun{entries: make(map[string]*restoreEntry), done: make(chan struct{})}
+}
+
+// add registers an instance left to restore
+func (r *restoreRun) add(name string, status instance.InstanceStatus, static bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[name]
+	if !ok {
+		entry = &restoreEntry{status: status, progress: RestorePending, done: make(chan struct{})}
+		r.entries[name] = entry
+	}
+	entry.static = entry.static || static
+}
+
+// toStart returns the instances persisted running, by name
+func (r *restoreRun) toStart() map[string]instance.InstanceStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pending := make(map[string]instance.InstanceStatus)
+	for name, entry := range r.entries {
+		if entry.status != instance.Stopped {
+			pending[name] = entry.status
+		}
+	}
+	return pending
+}
+
+// finish records the progress of an instance, releasing the changes waiting for it unless
+// it is still to be reconciled with the configuration
+func (r *restoreRun) finish(name, progress string, err error, reconciled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.entries[name]
+	if !ok {
+		return
+	}
+	switch {
+	case err != nil:
+		entry.progress, entry.err = RestoreFailed, err.Error()
+	case entry.progress == RestoreFailed:
+		// A failure is kept
+	case progress == RestoreReconciled && entry.progress != RestorePending:
+		// Keeps how its persisted state was restored
+	case progress != "":
+		entry.progress = progress
+	}
+	if reconciled || !entry.static {
+		select {
+		case <-entry.done:
+		default:
+			close(entry.done)
+		}
+	}
+}
+
+// complete marks the pass completed, releasing every instance
+func (r *restoreRun) complete() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, entry := range r.entries {
+		select {
+		case <-entry.done:
+		default:
+			close(entry.done)
+		}
+	}
+	r.completedAt = time.Now()
+	close(r.done)
+}
+
+// gate returns the channel closed once an instance is restored, nil if it has nothing left to
+// restore
+func (r *restoreRun) gate(name string) <-chan struct{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if entry, ok := r.entries[name]; ok {
+		return entry.done
+	}
+	return nil
+}
+
+// status returns a copy of the progress of the pass
+func (r *restoreRun) status() *RestoreStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := &RestoreStatus{
+		State:     RestoreStateRestoring,
+		Total:     len(r.entries),
+		Instances: make([]InstanceRestore, 0, len(r.entries)),
+	}
+	if !r.startedAt.IsZero() {
+		startedAt := r.startedAt
+		status.StartedAt = &startedAt
+	}
+	if !r.completedAt.IsZero() {
+		completedAt := r.completedAt
+		status.CompletedAt = &completedAt
+		status.State = RestoreStateReady
+	}
+	for name, entry := range r.entries {
+		if entry.progress == RestorePending {
+			status.Pending++
+		}
+		status.Instances = append(status.Instances, InstanceRestore{Name: name, Progress: entry.progress, Error: entry.err})
+	}
+	slices.SortFunc(status.Instances, func(a, b InstanceRestore) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return status
+}
+
+// Restore starts the initial restore in the background, for managers created with
+// WithDeferredRestore: the instances persisted running are adopted or started again, then
+// the static instances are reconciled with the configuration. Later calls do nothing.
+func (im *instanceManager) Restore() {
+	im.restore.once.Do(func() {
+		im.restore.mu.Lock()
+		im.restore.startedAt = time.Now()
+		im.restore.mu.Unlock()
+
+		im.background.Add(1)
+		go func() {
+			defer im.background.Done()
+			im.autoStartInstances()
+			im.reconcileStaticInstances()
+			im.restore.complete()
+			if total := im.restore.status().Total; total > 0 {
+				log.Printf("Restored %d instances", total)
+			}
+		}()
+	})
+}
+
+// GetRestoreStatus returns the progress of the initial restore of the instances
+func (im *instanceManager) GetRestoreStatus() *RestoreStatus {
+	return im.restore.status()
+}
+
+// waitRestored waits until an instance being restored is restored, so a change made to it
+// through the API applies after the restore rather than racing it
+func (im *instanceManager) waitRestored(name string) {
+	gate := im.restore.gate(name)
+	if gate == nil {
+		return
+	}
+	select {
+	case <-gate:
+	case <-im.shutdownChan:
+	}
+}
+
+// waitAllRestored waits until the initial restore completed
+func (im *instanceManager) waitAllRestored() {
+	select {
+	case <-im.restore.done:
+	case <-im.shutdownChan:
+	}
+}
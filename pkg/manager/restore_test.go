@@ -0,0 +1,121 @@
+package manager_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRestore_DeferredAndSerialized(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{Command: "sh", Args: []string{"-c", "exec sleep 30"}},
+	}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         filepath.Join(dir, "instances"),
+		LogsDir:              filepath.Join(dir, "logs"),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	store := storage.NewFileStore(cfg.InstancesDir, dir)
+
+	// An instance persisted running, whose auto-restart is disabled
+	first := manager.New(backendConfig, cfg, manager.WithStore(store))
+	autoRestart := false
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        &autoRestart,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/models/was-running.gguf"},
+	}
+	if _, err := first.CreateInstance("was-running", options); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	first.Shutdown()
+	data, err := store.Get(storage.NamespaceInstances, "was-running")
+	if err != nil {
+		t.Fatalf("Failed to read the persisted instance: %v", err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Failed to parse the persisted instance: %v", err)
+	}
+	record["status"] = "running"
+	data, _ = json.Marshal(record)
+	if err := store.Put(storage.NamespaceInstances, "was-running", data); err != nil {
+		t.Fatalf("Failed to persist the instance: %v", err)
+	}
+
+	cfg.Static = map[string]config.StaticInstanceConfig{"chat": testStaticInstances["chat"]}
+	mngr := manager.New(backendConfig, cfg, manager.WithStore(store), manager.WithDeferredRestore())
+	defer mngr.Shutdown()
+
+	// Registered stopped until restored
+	inst, err := mngr.GetInstance("was-running")
+	if err != nil {
+		t.Fatalf("GetInstance failed: %v", err)
+	}
+	if inst.GetStatus() != instance.Stopped {
+		t.Errorf("Expected the instance to be registered stopped, got %s", inst.GetStatus())
+	}
+	status := mngr.GetRestoreStatus()
+	if status.State != manager.RestoreStateRestoring || status.Total != 2 || status.Pending != 2 {
+		t.Errorf("Expected 2 instances pending restore, got %+v", status)
+	}
+
+	// Creating an instance being restored waits for its restore
+	created := make(chan error, 1)
+	go func() {
+		_, err := mngr.CreateInstance("chat", options)
+		created <- err
+	}()
+	select {
+	case err := <-created:
+		t.Fatalf("Expected the create to wait for the restore, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mngr.Restore()
+	select {
+	case err := <-created:
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("Expected the create to find the restored static instance, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the create")
+	}
+
+	status = mngr.GetRestoreStatus()
+	if status.State != manager.RestoreStateReady || status.Pending != 0 || status.CompletedAt == nil {
+		t.Errorf("Expected the restore to be complete, got %+v", status)
+	}
+	progress := map[string]string{}
+	for _, restored := range status.Instances {
+		progress[restored.Name] = restored.Progress
+	}
+	if progress["was-running"] != manager.RestoreStopped || progress["chat"] != manager.RestoreReconciled {
+		t.Errorf("Expected was-running stopped and chat reconciled, got %v", progress)
+	}
+
+	// Instances that were not being restored are not held up
+	later := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/models/later.gguf"},
+	}
+	if _, err := mngr.CreateInstance("later", later); err != nil {
+		t.Errorf("CreateInstance failed: %v", err)
+	}
+}
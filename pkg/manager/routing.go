@@ -0,0 +1,477 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/storage"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Codes of canary events
+const (
+	CanaryCodeGuardrail = "guardrail" // The guardrail zeroed the weight of the canary
+)
+
+// Sources of the routing weights of a service
+const (
+	RouteSourceConfig = "config" // The weights of the configuration file
+	RouteSourceAPI    = "api"    // Weights set through the API or by the guardrail, kept across restarts
+)
+
+// defaultGuardrailMinRequests is the number of recent requests of a canary before its
+// guardrail considers its error rate
+const defaultGuardrailMinRequests = 20
+
+var (
+	// ErrServiceNotFound is returned for aliases that are not configured services
+	ErrServiceNotFound = errors.New("service not found")
+
+	// ErrNoRoutes is returned for services that do not route by weight
+	ErrNoRoutes = errors.New("service has no weighted routing")
+
+	// ErrInvalidRoutes is returned when routing weights cannot be applied to a service
+	ErrInvalidRoutes = errors.New("invalid routing weights")
+)
+
+// RouteRules are the weights requests for a service are split by, and the canary the
+// guardrail watches
+type RouteRules struct {
+	Weights   map[string]int          `json:"weights"`
+	Canary    string                  `json:"canary,omitempty"`
+	Guardrail *config.CanaryGuardrail `json:"guardrail,omitempty"`
+}
+
+// RouteStatus is the routing of a service with the requests each member was routed
+type RouteStatus struct {
+	Service string `json:"service"`
+	RouteRules
+	Source             string              `json:"source"`
+	Members            []RouteMemberStatus `json:"members"`
+	GuardrailTrippedAt *time.Time          `json:"guardrail_tripped_at,omitempty"` // Last time the guardrail zeroed the canary
+	GuardrailReason    string              `json:"guardrail_reason,omitempty"`
+}
+
+// RouteMemberStatus counts the requests routed to a member of a service by its weights
+type RouteMemberStatus struct {
+	Instance        string  `json:"instance"`
+	Weight          int     `json:"weight"`
+	Share           float64 `json:"share"` // Fraction of the requests routed to the member
+	Canary          bool    `json:"canary,omitempty"`
+	Requests        int64   `json:"requests"`
+	Errors          int64   `json:"errors"`
+	RecentRequests  int64   `json:"recent_requests"`
+	RecentErrors    int64   `json:"recent_errors"`
+	RecentErrorRate float64 `json:"recent_error_rate"`
+	AvgDurationMs   float64 `json:"avg_duration_ms"` // Until the response was fully written
+}
+
+// serviceRoute splits the requests for a service between its weighted members
+type serviceRoute struct {
+	rules   RouteRules
+	source  string
+	members []*routeMember // By name, so that the order of the picks is deterministic
+	total   int
+
+	trippedAt  time.Time
+	tripReason string
+}
+
+// routeMember is a weighted member of a service
+type routeMember struct {
+	name    string
+	weight  int
+	current int // Smooth weighted round-robin credit
+	stats   *instance.ProxyStats
+}
+
+// configureRoutes routes the services with weights, the weights set through the API taking
+// precedence over those of the configuration. The stats of the members are kept.
+func (im *instanceManager) configureRoutes(services map[string]config.ServiceConfig) {
+	overrides := im.loadRouteOverrides()
+
+	im.routesMu.Lock()
+	defer im.routesMu.Unlock()
+
+	previous := im.routes
+	im.routes = make(map[string]*serviceRoute)
+	for alias, svc := range services {
+		if svc.Primary != "" || svc.Standby != "" {
+			continue
+		}
+		rules, source := RouteRules{Weights: svc.Weights, Canary: svc.Canary, Guardrail: svc.Guardrail}, RouteSourceConfig
+		if override, ok := overrides[alias]; ok {
+			rules, source = override, RouteSourceAPI
+		}
+		if len(rules.Weights) == 0 {
+			continue
+		}
+		im.routes[alias] = newServiceRoute(rules, source, previous[alias])
+	}
+}
+
+// newServiceRoute creates the route of rules, with the stats of the members of the previous
+// route of the service. A member taken out of the routing starts over once weighted again, so
+// the errors that got it taken out do not trip the guardrail anew.
+func newServiceRoute(rules RouteRules, source string, previous *serviceRoute) *serviceRoute {
+	route := &serviceRoute{rules: rules, source: source}
+	if previous != nil {
+		route.trippedAt, route.tripReason = previous.trippedAt, previous.tripReason
+	}
+	for _, name := range slices.Sorted(maps.Keys(rules.Weights)) {
+		member := &routeMember{name: name, weight: rules.Weights[name]}
+		if old := previous.member(name); old != nil && (old.weight > 0 || member.weight == 0) {
+			member.stats = old.stats
+		} else {
+			member.stats = instance.NewProxyStats()
+		}
+		route.members = append(route.members, member)
+		route.total += member.weight
+	}
+	return route
+}
+
+// RouteMember picks the instance a request for a service with weights is routed to. Requests
+// with the same non-empty key go to the same member while the weights do not change, the
+// others are spread by smooth weighted round-robin. Members draining before a stop are
+// skipped while another member is left.
+func (im *instanceManager) RouteMember(alias, key string) (string, bool) {
+	draining := im.drainingInstances()
+
+	im.routesMu.Lock()
+	defer im.routesMu.Unlock()
+	route, ok := im.routes[alias]
+	if !ok {
+		return "", false
+	}
+	if member := route.pick(key, draining); member != nil {
+		return member.name, true
+	}
+	return route.pick(key, nil).name, true
+}
+
+// drainingInstances returns the names of the instances draining before a stop, nil if none is
+func (im *instanceManager) drainingInstances() map[string]bool {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	im.mu.RUnlock()
+
+	var draining map[string]bool
+	for _, inst := range instances {
+		if inst.IsDraining() {
+			if draining == nil {
+				draining = make(map[string]bool)
+			}
+			draining[inst.Name] = true
+		}
+	}
+	return draining
+}
+
+// RecordRoute counts a request routed to a member of a service, and zeroes the weight of the
+// canary when its guardrail trips
+func (im *instanceManager) RecordRoute(alias, name string, failed bool, duration time.Duration) {
+	now := time.Now()
+
+	im.routesMu.Lock()
+	route, ok := im.routes[alias]
+	if !ok {
+		im.routesMu.Unlock()
+		return
+	}
+	member := route.member(name)
+	if member == nil {
+		im.routesMu.Unlock()
+		return // Routed by weights changed since
+	}
+	member.stats.Record(now, failed)
+	member.stats.RecordLatency(duration)
+
+	var tripped *events.Event
+	if failed && name == route.rules.Canary {
+		tripped = route.checkGuardrail(alias, member, now)
+	}
+	if tripped != nil {
+		// Saved under the lock, so a concurrent change of the weights is not overwritten
+		if err := im.saveRouteOverride(alias, &route.rules); err != nil {
+			log.Printf("Failed to persist the routing of service %s: %v", alias, err)
+		}
+	}
+	im.routesMu.Unlock()
+
+	if tripped == nil {
+		return
+	}
+	log.Printf("Service %s: %s", alias, tripped.Message)
+	im.events.Publish(*tripped)
+}
+
+// checkGuardrail zeroes the weight of the canary of a route when its recent error rate
+// exceeds the guardrail, as long as another member is left to route to, and returns the
+// event to publish (caller must hold the routes lock)
+func (r *serviceRoute) checkGuardrail(alias string, canary *routeMember, now time.Time) *events.Event {
+	guardrail := r.rules.Guardrail
+	if guardrail == nil || canary.weight == 0 || r.total == canary.weight {
+		return nil
+	}
+	minRequests := guardrail.MinRequests
+	if minRequests <= 0 {
+		minRequests = defaultGuardrailMinRequests
+	}
+	stats := canary.stats.Snapshot(now)
+	if stats.RecentRequests < int64(minRequests) || stats.RecentErrorRate <= guardrail.MaxErrorRate {
+		return nil
+	}
+
+	weights := maps.Clone(r.rules.Weights)
+	weights[canary.name] = 0
+	r.rules.Weights = weights
+	r.total -= canary.weight
+	canary.weight = 0
+	r.source = RouteSourceAPI
+	r.trippedAt = now
+	r.tripReason = fmt.Sprintf("error rate %.0f%% of canary %s over the last %s exceeds %.0f%%, weight set to 0",
+		stats.RecentErrorRate*100, canary.name, instance.RecentStatsWindow, guardrail.MaxErrorRate*100)
+
+	return &events.Event{
+		Type:     events.TypeCanary,
+		Instance: canary.name,
+		Code:     CanaryCodeGuardrail,
+		Message:  r.tripReason,
+		Data: map[string]any{
+			"service":         alias,
+			"error_rate":      stats.RecentErrorRate,
+			"max_error_rate":  guardrail.MaxErrorRate,
+			"recent_requests": stats.RecentRequests,
+		},
+	}
+}
+
+// GetRoutes returns the routing of a service with weights and the stats of its members
+func (im *instanceManager) GetRoutes(alias string) (*RouteStatus, error) {
+	im.routesMu.Lock()
+	defer im.routesMu.Unlock()
+	route, ok := im.routes[alias]
+	if !ok {
+		return nil, fmt.Errorf("service %s: %w", alias, ErrNoRoutes)
+	}
+	return route.status(alias, time.Now()), nil
+}
+
+// SetRoutes replaces the weights of a service, which are kept across restarts until reset.
+// Requests being served keep the member they were routed to.
+func (im *instanceManager) SetRoutes(alias string, rules RouteRules) (*RouteStatus, error) {
+	return im.setRoutes(alias, rules, "")
+}
+
+// setRoutes is SetRoutes on behalf of actor
+func (im *instanceManager) setRoutes(alias string, rules RouteRules, actor string) (*RouteStatus, error) {
+	if err := config.ValidateRouteWeights(rules.Weights, rules.Canary, rules.Guardrail); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRoutes, err)
+	}
+	if _, err := im.routableService(alias); err != nil {
+		return nil, err
+	}
+	rules.Weights = maps.Clone(rules.Weights)
+
+	im.routesMu.Lock()
+	route := newServiceRoute(rules, RouteSourceAPI, im.routes[alias])
+	im.routes[alias] = route
+	status := route.status(alias, time.Now())
+	err := im.saveRouteOverride(alias, &rules)
+	im.routesMu.Unlock()
+
+	im.recordAudit(actor, "set_routes", alias, formatWeights(rules.Weights))
+	if err != nil {
+		return status, fmt.Errorf("failed to persist the routing of service %s: %w", alias, err)
+	}
+	return status, nil
+}
+
+// ResetRoutes drops the weights set through the API for a service, going back to the
+// weights of the configuration, if any
+func (im *instanceManager) ResetRoutes(alias string) error {
+	return im.resetRoutes(alias, "")
+}
+
+// resetRoutes is ResetRoutes on behalf of actor
+func (im *instanceManager) resetRoutes(alias string, actor string) error {
+	svc, err := im.routableService(alias)
+	if err != nil {
+		return err
+	}
+	im.routesMu.Lock()
+	if err := im.saveRouteOverride(alias, nil); err != nil {
+		im.routesMu.Unlock()
+		return fmt.Errorf("failed to reset the routing of service %s: %w", alias, err)
+	}
+	if len(svc.Weights) > 0 {
+		rules := RouteRules{Weights: svc.Weights, Canary: svc.Canary, Guardrail: svc.Guardrail}
+		im.routes[alias] = newServiceRoute(rules, RouteSourceConfig, im.routes[alias])
+	} else {
+		delete(im.routes, alias)
+	}
+	im.routesMu.Unlock()
+
+	im.recordAudit(actor, "reset_routes", alias, formatWeights(svc.Weights))
+	return nil
+}
+
+// routableService returns the configuration of a service that can route by weight
+func (im *instanceManager) routableService(alias string) (config.ServiceConfig, error) {
+	im.standbyMu.Lock()
+	svc, ok := im.services[alias]
+	im.standbyMu.Unlock()
+	if !ok {
+		return svc, fmt.Errorf("service %s: %w", alias, ErrServiceNotFound)
+	}
+	if svc.Primary != "" || svc.Standby != "" {
+		return svc, fmt.Errorf("%w: service %s has a primary and a standby", ErrInvalidRoutes, alias)
+	}
+	return svc, nil
+}
+
+// saveRouteOverride persists the weights set for a service, removing them when rules is nil
+func (im *instanceManager) saveRouteOverride(alias string, rules *RouteRules) error {
+	if im.store == nil {
+		return nil // Persistence disabled
+	}
+	if rules == nil {
+		return im.store.Delete(storage.NamespaceRoutes, alias)
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	return im.store.Put(storage.NamespaceRoutes, alias, data)
+}
+
+// loadRouteOverrides returns the persisted weights of the services, skipping invalid ones
+func (im *instanceManager) loadRouteOverrides() map[string]RouteRules {
+	if im.store == nil {
+		return nil
+	}
+	records, err := im.store.List(storage.NamespaceRoutes)
+	if err != nil {
+		log.Printf("Failed to read the routing of the services: %v", err)
+		return nil
+	}
+	overrides := make(map[string]RouteRules, len(records))
+	for alias, data := range records {
+		var rules RouteRules
+		if err := json.Unmarshal(data, &rules); err != nil {
+			log.Printf("Ignoring invalid routing of service %s: %v", alias, err)
+			continue
+		}
+		if err := config.ValidateRouteWeights(rules.Weights, rules.Canary, rules.Guardrail); err != nil {
+			log.Printf("Ignoring invalid routing of service %s: %v", alias, err)
+			continue
+		}
+		overrides[alias] = rules
+	}
+	return overrides
+}
+
+// pick returns the member of the next request, skipping the members in skip, or nil if every
+// weighted member is skipped (caller must hold the routes lock)
+func (r *serviceRoute) pick(key string, skip map[string]bool) *routeMember {
+	if key != "" {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		point := int(hash.Sum32() % uint32(r.total))
+		for _, member := range r.members {
+			if point < member.weight {
+				if !skip[member.name] {
+					return member
+				}
+				break // Spread over the other members instead
+			}
+			point -= member.weight
+		}
+	}
+
+	var best *routeMember
+	total := 0
+	for _, member := range r.members {
+		if member.weight == 0 || skip[member.name] {
+			continue
+		}
+		member.current += member.weight
+		total += member.weight
+		if best == nil || member.current > best.current {
+			best = member
+		}
+	}
+	if best != nil {
+		best.current -= total
+	}
+	return best
+}
+
+// member returns the weighted member of a route named name, nil if there is none
+func (r *serviceRoute) member(name string) *routeMember {
+	if r == nil {
+		return nil
+	}
+	for _, member := range r.members {
+		if member.name == name {
+			return member
+		}
+	}
+	return nil
+}
+
+// status returns a copy of the state of the route (caller must hold the routes lock)
+func (r *serviceRoute) status(alias string, now time.Time) *RouteStatus {
+	status := &RouteStatus{
+		Service: alias,
+		RouteRules: RouteRules{
+			Weights:   maps.Clone(r.rules.Weights),
+			Canary:    r.rules.Canary,
+			Guardrail: r.rules.Guardrail,
+		},
+		Source:          r.source,
+		Members:         make([]RouteMemberStatus, 0, len(r.members)),
+		GuardrailReason: r.tripReason,
+	}
+	if !r.trippedAt.IsZero() {
+		trippedAt := r.trippedAt
+		status.GuardrailTrippedAt = &trippedAt
+	}
+	for _, member := range r.members {
+		stats := member.stats.Snapshot(now)
+		status.Members = append(status.Members, RouteMemberStatus{
+			Instance:        member.name,
+			Weight:          member.weight,
+			Share:           float64(member.weight) / float64(r.total),
+			Canary:          member.name == r.rules.Canary,
+			Requests:        stats.Requests,
+			Errors:          stats.Errors,
+			RecentRequests:  stats.RecentRequests,
+			RecentErrors:    stats.RecentErrors,
+			RecentErrorRate: stats.RecentErrorRate,
+			AvgDurationMs:   stats.AvgLatencyMs,
+		})
+	}
+	return status
+}
+
+// formatWeights lists weights as name=weight, by name
+func formatWeights(weights map[string]int) string {
+	parts := make([]string, 0, len(weights))
+	for _, name := range slices.Sorted(maps.Keys(weights)) {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, weights[name]))
+	}
+	return strings.Join(parts, ",")
+}
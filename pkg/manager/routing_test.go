@@ -0,0 +1,195 @@
+package manager_test
+
+import (
+	"errors"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func canaryServices(guardrail *config.CanaryGuardrail) map[string]config.ServiceConfig {
+	return map[string]config.ServiceConfig{
+		"chat": {
+			Members:   []string{"chat-canary", "chat-stable"},
+			Weights:   map[string]int{"chat-stable": 95, "chat-canary": 5},
+			Canary:    "chat-canary",
+			Guardrail: guardrail,
+		},
+		"embeddings": {Members: []string{"embed-1"}},
+	}
+}
+
+func TestRouting_SplitsByWeight(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+	mngr.SetServices(canaryServices(nil))
+
+	if _, ok := mngr.RouteMember("embeddings", ""); ok {
+		t.Error("Expected a service without weights not to be routed")
+	}
+
+	counts := map[string]int{}
+	for range 200 {
+		member, ok := mngr.RouteMember("chat", "")
+		if !ok {
+			t.Fatal("Expected the service to be routed")
+		}
+		counts[member]++
+	}
+	if counts["chat-stable"] != 190 || counts["chat-canary"] != 10 {
+		t.Errorf("Expected 190 requests to the stable instance and 10 to the canary, got %v", counts)
+	}
+
+	// Requests with a key stick to a member
+	for i := range 20 {
+		key := "session-" + strconv.Itoa(i)
+		first, _ := mngr.RouteMember("chat", key)
+		for range 5 {
+			if member, _ := mngr.RouteMember("chat", key); member != first {
+				t.Fatalf("Expected key %s to stay on %s, got %s", key, first, member)
+			}
+		}
+	}
+}
+
+func TestRouting_GuardrailZeroesCanary(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+	mngr.SetServices(canaryServices(&config.CanaryGuardrail{MaxErrorRate: 0.5, MinRequests: 4}))
+
+	sub, unsubscribe := mngr.SubscribeEvents()
+	defer unsubscribe()
+
+	mngr.RecordRoute("chat", "chat-stable", false, 10*time.Millisecond)
+	mngr.RecordRoute("chat", "chat-canary", false, 10*time.Millisecond)
+	for range 3 {
+		mngr.RecordRoute("chat", "chat-canary", true, 10*time.Millisecond)
+	}
+
+	select {
+	case event := <-sub:
+		if event.Type != events.TypeCanary || event.Code != manager.CanaryCodeGuardrail || event.Instance != "chat-canary" {
+			t.Errorf("Expected a guardrail event for the canary, got %+v", event)
+		}
+		if event.Data["service"] != "chat" {
+			t.Errorf("Expected the event to name the service, got %v", event.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the guardrail event")
+	}
+
+	status, err := mngr.GetRoutes("chat")
+	if err != nil {
+		t.Fatalf("GetRoutes failed: %v", err)
+	}
+	if status.Weights["chat-canary"] != 0 || status.Weights["chat-stable"] != 95 {
+		t.Errorf("Expected the weight of the canary to be zeroed, got %v", status.Weights)
+	}
+	if status.Source != manager.RouteSourceAPI || status.GuardrailTrippedAt == nil {
+		t.Errorf("Expected the guardrail trip to be reported, got %+v", status)
+	}
+	for _, member := range status.Members {
+		if member.Instance == "chat-canary" && (member.Requests != 4 || member.Errors != 3 || !member.Canary) {
+			t.Errorf("Expected the canary to count 4 requests and 3 errors, got %+v", member)
+		}
+	}
+	for range 50 {
+		if member, _ := mngr.RouteMember("chat", ""); member != "chat-stable" {
+			t.Fatalf("Expected every request to go to the stable instance, got %s", member)
+		}
+	}
+
+	// Weighting the canary again starts its stats over
+	status, err = mngr.SetRoutes("chat", manager.RouteRules{
+		Weights:   map[string]int{"chat-stable": 50, "chat-canary": 50},
+		Canary:    "chat-canary",
+		Guardrail: &config.CanaryGuardrail{MaxErrorRate: 0.5, MinRequests: 4},
+	})
+	if err != nil {
+		t.Fatalf("SetRoutes failed: %v", err)
+	}
+	for _, member := range status.Members {
+		if member.Instance == "chat-canary" && member.Requests != 0 {
+			t.Errorf("Expected the stats of the canary to start over, got %+v", member)
+		}
+	}
+	mngr.RecordRoute("chat", "chat-canary", true, 10*time.Millisecond)
+	if status, _ := mngr.GetRoutes("chat"); status.Weights["chat-canary"] != 50 {
+		t.Errorf("Expected the canary to keep its weight, got %v", status.Weights)
+	}
+}
+
+func TestRouting_SetRoutesKeptAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         filepath.Join(dir, "instances"),
+		LogsDir:              filepath.Join(dir, "logs"),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+	}
+	store := storage.NewFileStore(cfg.InstancesDir, dir)
+
+	mngr := manager.NewInstanceManagerWithStore(config.BackendConfig{}, cfg, store)
+	mngr.SetServices(canaryServices(nil))
+
+	if _, err := mngr.SetRoutes("chat", manager.RouteRules{Weights: map[string]int{"chat-stable": 0}}); !errors.Is(err, manager.ErrInvalidRoutes) {
+		t.Errorf("Expected weights without a positive weight to be refused, got %v", err)
+	}
+	if _, err := mngr.SetRoutes("missing", manager.RouteRules{Weights: map[string]int{"a": 1}}); !errors.Is(err, manager.ErrServiceNotFound) {
+		t.Errorf("Expected an unknown service to be refused, got %v", err)
+	}
+	if _, err := mngr.GetRoutes("embeddings"); !errors.Is(err, manager.ErrNoRoutes) {
+		t.Errorf("Expected a service without weights to have no routing, got %v", err)
+	}
+
+	// Promoting the canary
+	promoted := map[string]int{"chat-stable": 0, "chat-canary": 100}
+	if _, err := mngr.WithActor("alice").SetRoutes("chat", manager.RouteRules{Weights: promoted, Canary: "chat-canary"}); err != nil {
+		t.Fatalf("SetRoutes failed: %v", err)
+	}
+	if member, _ := mngr.RouteMember("chat", ""); member != "chat-canary" {
+		t.Errorf("Expected the promoted canary to serve the service, got %s", member)
+	}
+	mngr.Shutdown()
+
+	mngr = manager.NewInstanceManagerWithStore(config.BackendConfig{}, cfg, store)
+	defer mngr.Shutdown()
+	mngr.SetServices(canaryServices(nil))
+
+	status, err := mngr.GetRoutes("chat")
+	if err != nil {
+		t.Fatalf("GetRoutes failed: %v", err)
+	}
+	if status.Source != manager.RouteSourceAPI || status.Weights["chat-canary"] != 100 {
+		t.Errorf("Expected the weights set through the API to be restored, got %+v", status)
+	}
+
+	if err := mngr.ResetRoutes("chat"); err != nil {
+		t.Fatalf("ResetRoutes failed: %v", err)
+	}
+	status, _ = mngr.GetRoutes("chat")
+	if status.Source != manager.RouteSourceConfig || status.Weights["chat-stable"] != 95 {
+		t.Errorf("Expected the weights of the configuration after a reset, got %+v", status)
+	}
+
+	records, err := store.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit failed: %v", err)
+	}
+	var actions []string
+	for _, record := range records {
+		if record.Target == "chat" {
+			actions = append(actions, record.Actor+":"+record.Action)
+		}
+	}
+	if len(actions) != 2 || actions[0] != "alice:set_routes" || actions[1] != ":reset_routes" {
+		t.Errorf("Expected the changes to be audited, got %v", actions)
+	}
+}
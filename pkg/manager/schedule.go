@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"llamactl/pkg/instance"
+	"log"
+	"time"
+)
+
+const (
+	// scheduleCheckInterval is how often the start and stop schedules of the instances are checked
+	scheduleCheckInterval = 15 * time.Second
+	// maxScheduleCatchUp bounds the window a check covers, so that a check delayed by a
+	// suspended host does not act on transitions long past
+	maxScheduleCatchUp = 2 * time.Minute
+)
+
+// runSchedules starts and stops the instances whose start_schedule or stop_schedule was due
+// since the last check. Only the latest transition of an instance is acted on, and only when
+// the instance is not in its state already, so a manual start or stop holds until then.
+func (im *instanceManager) runSchedules() {
+	now := time.Now()
+	from := im.scheduleCheckedAt
+	if now.Sub(from) > maxScheduleCatchUp {
+		from = now.Add(-maxScheduleCatchUp)
+	}
+	im.scheduleCheckedAt = now
+
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	im.mu.RUnlock()
+
+	for _, inst := range instances {
+		action := inst.DueScheduledAction(from, now)
+		if action == nil {
+			continue
+		}
+		// A stop waits for the requests in flight, which must not hold up the other checks
+		im.background.Add(1)
+		go func() {
+			defer im.background.Done()
+			im.runScheduledAction(inst, action)
+		}()
+	}
+}
+
+// runScheduledAction starts or stops an instance as its schedule had due
+func (im *instanceManager) runScheduledAction(inst *instance.Process, action *instance.ScheduledAction) {
+	var err error
+	switch action.Action {
+	case instance.ScheduleStart:
+		if inst.IsRunning() || inst.GetStatus() == instance.Queued {
+			return
+		}
+		log.Printf("Instance %s is due to start by its start_schedule, starting it", inst.Name)
+		_, err = im.startInstanceWithReason(inst.Name, instance.ReasonSchedule, "started by start_schedule", "", OperationOptions{})
+	case instance.ScheduleStop:
+		if !inst.IsRunning() {
+			return
+		}
+		log.Printf("Instance %s is due to stop by its stop_schedule, stopping it", inst.Name)
+		_, err = im.stopInstance(inst.Name, instance.ReasonSchedule, "stopped by stop_schedule", "", OperationOptions{})
+	}
+	if err != nil {
+		log.Printf("Error running the scheduled %s of instance %s: %v", action.Action, inst.Name, err)
+	}
+}
@@ -0,0 +1,178 @@
+package manager
+
+import (
+	"fmt"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+)
+
+// Service health verdicts
+const (
+	ServiceHealthy  = "healthy"
+	ServiceDegraded = "degraded"
+	ServiceDown     = "down"
+)
+
+// Default service health thresholds, used when a service does not configure its own
+const (
+	defaultDegradedThreshold  = 0.5
+	defaultErrorRateThreshold = 0.25
+	defaultMinRequests        = 10
+)
+
+// ServiceHealth is the aggregated health verdict of a service
+type ServiceHealth struct {
+	Service      string             `json:"service"`
+	Status       string             `json:"status"`
+	ReadyMembers int                `json:"ready_members"`
+	TotalMembers int                `json:"total_members"`
+	Reasons      []string           `json:"reasons,omitempty"`
+	Members      []MemberHealth     `json:"members"`
+	Dependencies []DependencyHealth `json:"dependencies,omitempty"`
+	Standby      *StandbyStatus     `json:"standby,omitempty"` // Services with a standby only
+}
+
+// MemberHealth describes how a single instance contributes to its service's health
+type MemberHealth struct {
+	Instance        string   `json:"instance"`
+	Status          string   `json:"status"`
+	Ready           bool     `json:"ready"`
+	RecentRequests  int64    `json:"recent_requests"`
+	RecentErrors    int64    `json:"recent_errors"`
+	RecentErrorRate float64  `json:"recent_error_rate"`
+	Facts           []string `json:"facts"`
+}
+
+// DependencyHealth is the verdict of a service dependency
+type DependencyHealth struct {
+	Service string `json:"service"`
+	Status  string `json:"status"`
+}
+
+// EvaluateServiceHealth computes the health of a configured service from member readiness,
+// recent proxy error rates and the health of its dependencies.
+// It only reads cached state, so it is cheap enough to be polled by a load balancer.
+func EvaluateServiceHealth(im InstanceManager, services map[string]config.ServiceConfig, alias string) (*ServiceHealth, error) {
+	if _, ok := services[alias]; !ok {
+		return nil, fmt.Errorf("service %s not found", alias)
+	}
+	return evaluateService(im, services, alias, map[string]bool{}), nil
+}
+
+func evaluateService(im InstanceManager, services map[string]config.ServiceConfig, alias string, visiting map[string]bool) *ServiceHealth {
+	svc := services[alias]
+	visiting[alias] = true
+	defer delete(visiting, alias)
+
+	degradedThreshold := svc.DegradedThreshold
+	if degradedThreshold <= 0 {
+		degradedThreshold = defaultDegradedThreshold
+	}
+	errorRateThreshold := svc.ErrorRateThreshold
+	if errorRateThreshold <= 0 {
+		errorRateThreshold = defaultErrorRateThreshold
+	}
+	minRequests := svc.MinRequests
+	if minRequests <= 0 {
+		minRequests = defaultMinRequests
+	}
+
+	health := &ServiceHealth{
+		Service:      alias,
+		Status:       ServiceHealthy,
+		TotalMembers: len(svc.Members),
+		Members:      make([]MemberHealth, 0, len(svc.Members)),
+	}
+	if status, err := im.GetStandbyStatus(alias); err == nil {
+		health.Standby = status
+	}
+
+	erroring := 0
+	for _, name := range svc.Members {
+		member := MemberHealth{Instance: name}
+
+		inst, err := im.GetInstance(name)
+		if err != nil {
+			member.Status = "missing"
+			member.Facts = append(member.Facts, "instance does not exist")
+			health.Members = append(health.Members, member)
+			continue
+		}
+
+		member.Status = inst.GetStatus().String()
+		member.Ready = inst.IsReady()
+		if member.Ready {
+			health.ReadyMembers++
+			member.Facts = append(member.Facts, "instance is running")
+		} else {
+			member.Facts = append(member.Facts, fmt.Sprintf("instance is %s", member.Status))
+			if lastErr := inst.GetLastError(); lastErr != nil {
+				member.Facts = append(member.Facts, fmt.Sprintf("last error: %s", lastErr.Code))
+			}
+		}
+
+		stats := inst.GetStats()
+		member.RecentRequests = stats.RecentRequests
+		member.RecentErrors = stats.RecentErrors
+		member.RecentErrorRate = stats.RecentErrorRate
+		if stats.RecentRequests >= int64(minRequests) && stats.RecentErrorRate > errorRateThreshold {
+			erroring++
+			member.Facts = append(member.Facts, fmt.Sprintf("error rate %.0f%% over the last %s exceeds %.0f%%",
+				stats.RecentErrorRate*100, instance.RecentStatsWindow, errorRateThreshold*100))
+		}
+
+		health.Members = append(health.Members, member)
+	}
+
+	if health.ReadyMembers == 0 {
+		health.Status = ServiceDown
+		health.Reasons = append(health.Reasons, "no members are ready")
+	} else if float64(health.ReadyMembers) < degradedThreshold*float64(health.TotalMembers) {
+		health.degrade(fmt.Sprintf("%d of %d members ready, below %.0f%%", health.ReadyMembers, health.TotalMembers, degradedThreshold*100))
+	}
+	if erroring > 0 {
+		health.degrade(fmt.Sprintf("%d members have an elevated error rate", erroring))
+	}
+
+	for _, dep := range svc.DependsOn {
+		depHealth := DependencyHealth{Service: dep}
+		switch {
+		case visiting[dep]:
+			depHealth.Status = ServiceDown
+			health.Reasons = append(health.Reasons, fmt.Sprintf("dependency cycle through service %s", dep))
+		case !hasService(services, dep):
+			depHealth.Status = ServiceDown
+			health.Reasons = append(health.Reasons, fmt.Sprintf("dependency %s is not configured", dep))
+		default:
+			depHealth.Status = evaluateService(im, services, dep, visiting).Status
+			if depHealth.Status != ServiceHealthy {
+				health.Reasons = append(health.Reasons, fmt.Sprintf("dependency %s is %s", dep, depHealth.Status))
+			}
+		}
+
+		switch depHealth.Status {
+		case ServiceDown:
+			health.Status = ServiceDown
+		case ServiceDegraded:
+			health.degrade("")
+		}
+		health.Dependencies = append(health.Dependencies, depHealth)
+	}
+
+	return health
+}
+
+// degrade lowers a healthy verdict to degraded, recording the reason if given
+func (h *ServiceHealth) degrade(reason string) {
+	if h.Status == ServiceHealthy {
+		h.Status = ServiceDegraded
+	}
+	if reason != "" {
+		h.Reasons = append(h.Reasons, reason)
+	}
+}
+
+func hasService(services map[string]config.ServiceConfig, alias string) bool {
+	_, ok := services[alias]
+	return ok
+}
@@ -0,0 +1,163 @@
+package manager_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func createServiceMembers(t *testing.T, mngr manager.InstanceManager, running map[string]bool) {
+	t.Helper()
+	for name, isRunning := range running {
+		options := &instance.CreateInstanceOptions{
+			BackendType: backends.BackendTypeLlamaCpp,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{
+				Model: "/path/to/model.gguf",
+			},
+		}
+		inst, err := mngr.CreateInstance(name, options)
+		if err != nil {
+			t.Fatalf("CreateInstance %s failed: %v", name, err)
+		}
+		if isRunning {
+			inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
+		}
+	}
+}
+
+func TestEvaluateServiceHealth(t *testing.T) {
+	mngr := createTestManager()
+	createServiceMembers(t, mngr, map[string]bool{
+		"chat-1":  true,
+		"chat-2":  true,
+		"chat-3":  false,
+		"embed-1": false,
+		"embed-2": false,
+		"rerank":  true,
+	})
+
+	services := map[string]config.ServiceConfig{
+		"chat":         {Members: []string{"chat-1", "chat-2", "chat-3"}},
+		"chat-strict":  {Members: []string{"chat-1", "chat-2", "chat-3"}, DegradedThreshold: 0.9},
+		"embed":        {Members: []string{"embed-1", "embed-2"}},
+		"rerank":       {Members: []string{"rerank"}},
+		"rag":          {Members: []string{"chat-1"}, DependsOn: []string{"rerank", "chat-strict"}},
+		"rag-broken":   {Members: []string{"chat-1"}, DependsOn: []string{"embed"}},
+		"rag-missing":  {Members: []string{"chat-1"}, DependsOn: []string{"nonexistent"}},
+		"cycle-a":      {Members: []string{"rerank"}, DependsOn: []string{"cycle-b"}},
+		"cycle-b":      {Members: []string{"rerank"}, DependsOn: []string{"cycle-a"}},
+		"ghost-member": {Members: []string{"does-not-exist", "rerank"}},
+	}
+
+	tests := []struct {
+		alias    string
+		expected string
+		ready    int
+	}{
+		{"chat", manager.ServiceHealthy, 2},
+		{"chat-strict", manager.ServiceDegraded, 2},
+		{"embed", manager.ServiceDown, 0},
+		{"rag", manager.ServiceDegraded, 1},
+		{"rag-broken", manager.ServiceDown, 1},
+		{"rag-missing", manager.ServiceDown, 1},
+		{"cycle-a", manager.ServiceDown, 1},
+		{"ghost-member", manager.ServiceHealthy, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			health, err := manager.EvaluateServiceHealth(mngr, services, tt.alias)
+			if err != nil {
+				t.Fatalf("EvaluateServiceHealth failed: %v", err)
+			}
+			if health.Status != tt.expected {
+				t.Errorf("Expected status %q, got %q (reasons: %v)", tt.expected, health.Status, health.Reasons)
+			}
+			if health.ReadyMembers != tt.ready {
+				t.Errorf("Expected %d ready members, got %d", tt.ready, health.ReadyMembers)
+			}
+			if len(health.Members) != len(services[tt.alias].Members) {
+				t.Errorf("Expected %d members, got %d", len(services[tt.alias].Members), len(health.Members))
+			}
+			for _, member := range health.Members {
+				if len(member.Facts) == 0 {
+					t.Errorf("Member %s has no facts", member.Instance)
+				}
+			}
+		})
+	}
+
+	if _, err := manager.EvaluateServiceHealth(mngr, services, "unknown"); err == nil {
+		t.Error("Expected error for unknown service")
+	}
+}
+
+func TestEvaluateServiceHealth_ErrorRate(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse backend URL: %v", err)
+	}
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	mngr := createTestManager()
+	createServiceMembers(t, mngr, map[string]bool{"healthy-1": true})
+
+	inst, err := mngr.CreateInstance("failing-1", &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  backendURL.Hostname(),
+			Port:  port,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
+
+	proxy, err := inst.GetProxy()
+	if err != nil {
+		t.Fatalf("GetProxy failed: %v", err)
+	}
+	for range 10 {
+		proxy.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/chat/completions", nil))
+	}
+
+	services := map[string]config.ServiceConfig{
+		"chat":         {Members: []string{"healthy-1", "failing-1"}},
+		"chat-lenient": {Members: []string{"healthy-1", "failing-1"}, MinRequests: 100},
+	}
+
+	health, err := manager.EvaluateServiceHealth(mngr, services, "chat")
+	if err != nil {
+		t.Fatalf("EvaluateServiceHealth failed: %v", err)
+	}
+	if health.Status != manager.ServiceDegraded {
+		t.Errorf("Expected degraded status, got %q", health.Status)
+	}
+	for _, member := range health.Members {
+		if member.Instance == "failing-1" && (member.RecentErrors != 10 || member.RecentErrorRate != 1) {
+			t.Errorf("Expected 10 recent errors at rate 1, got %d at %v", member.RecentErrors, member.RecentErrorRate)
+		}
+	}
+
+	health, err = manager.EvaluateServiceHealth(mngr, services, "chat-lenient")
+	if err != nil {
+		t.Fatalf("EvaluateServiceHealth failed: %v", err)
+	}
+	if health.Status != manager.ServiceHealthy {
+		t.Errorf("Expected healthy status below the minimum request count, got %q", health.Status)
+	}
+}
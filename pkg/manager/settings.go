@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"llamactl/pkg/config"
+	"strings"
+)
+
+// GetSettings returns the instances settings the manager currently runs with
+func (im *instanceManager) GetSettings() config.InstancesConfig {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+	return im.instancesConfig
+}
+
+// UpdateSettings changes the instances settings of patch, keyed like the configuration file,
+// while llamactl runs. The changes are saved to the overrides file of the data directory so
+// they survive a restart, and apply to instances created from then on where they are defaults.
+func (im *instanceManager) UpdateSettings(patch map[string]json.RawMessage) (config.InstancesConfig, error) {
+	return im.updateSettings(patch, "")
+}
+
+// updateSettings is UpdateSettings on behalf of actor
+func (im *instanceManager) updateSettings(patch map[string]json.RawMessage, actor string) (config.InstancesConfig, error) {
+	im.mu.Lock()
+	updated, keys, err := config.PatchInstances(im.instancesConfig, patch)
+	if err != nil {
+		im.mu.Unlock()
+		return config.InstancesConfig{}, err
+	}
+	if err := config.SaveOverrides(updated, keys); err != nil {
+		im.mu.Unlock()
+		return config.InstancesConfig{}, fmt.Errorf("failed to save configuration overrides: %w", err)
+	}
+	// Only the changed fields are written, instances read the others without the lock
+	config.CopySettings(&im.instancesConfig, updated, keys)
+	settings := im.instancesConfig
+	im.mu.Unlock()
+
+	im.startLimiter.SetLimit(settings.MaxConcurrentRestarts)
+	im.memory.SetLimits(settings.InstanceMemoryLimit, settings.MemoryLimit)
+
+	changes := make([]string, len(keys))
+	for idx, key := range keys {
+		changes[idx] = key + "=" + string(patch[key])
+	}
+	im.recordAudit(actor, "update_config", "instances", strings.Join(changes, ", "))
+	return settings, nil
+}
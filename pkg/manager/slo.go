@@ -0,0 +1,108 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/validation"
+	"log"
+	"time"
+)
+
+// sloEvaluationInterval is how often the objectives of the instances are evaluated
+const sloEvaluationInterval = 10 * time.Second
+
+// ErrNoSLO is returned for the SLO status of an instance without objectives
+var ErrNoSLO = errors.New("instance has no service level objectives")
+
+// evaluateSLOs evaluates the objectives of every instance that has some
+func (im *instanceManager) evaluateSLOs() {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	im.mu.RUnlock()
+
+	for _, inst := range instances {
+		im.evaluateSLO(inst)
+	}
+}
+
+// evaluateSLO evaluates the objectives of an instance and publishes an slo event when its
+// state changed since the last evaluation
+func (im *instanceManager) evaluateSLO(inst *instance.Process) *instance.SLOStatus {
+	status, changed := inst.EvaluateSLO()
+	if status == nil || !changed {
+		return status
+	}
+
+	message := fmt.Sprintf("burn rate %.2f over %s (error rate %.4f, p95 latency %.0fms)", status.BurnRate, status.Window, status.ErrorRate, status.LatencyP95Ms)
+	if status.Degraded {
+		log.Printf("Warning: instance %s is degraded, SLO %s: %s", inst.Name, status.State, message)
+	}
+	im.events.Publish(events.Event{
+		Type:     events.TypeSLO,
+		Instance: inst.Name,
+		Code:     status.State,
+		Message:  message,
+		Data: map[string]any{
+			"degraded":       status.Degraded,
+			"burn_rate":      status.BurnRate,
+			"error_rate":     status.ErrorRate,
+			"latency_p95_ms": status.LatencyP95Ms,
+			"requests":       status.Requests,
+		},
+	})
+	return status
+}
+
+// GetSLOStatus evaluates the objectives of an instance and returns its compliance
+func (im *instanceManager) GetSLOStatus(name string) (*instance.SLOStatus, error) {
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	status := im.evaluateSLO(inst)
+	if status == nil {
+		return nil, fmt.Errorf("instance %s: %w", name, ErrNoSLO)
+	}
+	return status, nil
+}
+
+// UpdateSLO replaces the objectives of an instance without restarting it. Objectives
+// without a latency or error rate objective remove the SLO.
+func (im *instanceManager) UpdateSLO(name string, objectives *instance.SLOOptions) (*instance.SLOStatus, error) {
+	return im.updateSLO(name, objectives, "")
+}
+
+// updateSLO is UpdateSLO on behalf of actor
+func (im *instanceManager) updateSLO(name string, objectives *instance.SLOOptions, actor string) (*instance.SLOStatus, error) {
+	if err := im.checkConfigUpdate(name); err != nil {
+		return nil, err
+	}
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return nil, err
+	}
+	if objectives.HasObjectives() {
+		if err := validation.ValidateSLO(&instance.CreateInstanceOptions{SLO: objectives}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := inst.SetSLO(objectives); err != nil {
+		return nil, err
+	}
+
+	im.mu.Lock()
+	err = im.persistInstance(inst)
+	im.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist instance %s: %w", name, err)
+	}
+	im.recordAudit(actor, "update_slo", name, "")
+
+	return im.evaluateSLO(inst), nil
+}
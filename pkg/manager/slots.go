@@ -0,0 +1,27 @@
+package manager
+
+import (
+	"llamactl/pkg/instance"
+	"log"
+)
+
+// enforceSlotRetention removes the slot snapshots that were not restored and are older than
+// each instance's slot retention setting
+func (im *instanceManager) enforceSlotRetention() {
+	im.mu.RLock()
+	instances := make([]*instance.Process, 0, len(im.instances))
+	for _, inst := range im.instances {
+		instances = append(instances, inst)
+	}
+	im.mu.RUnlock()
+
+	for _, inst := range instances {
+		removed, err := inst.EnforceSlotRetention()
+		for _, file := range removed {
+			log.Printf("Slot retention: removed slot file %s of instance %s", file, inst.Name)
+		}
+		if err != nil {
+			log.Printf("Slot retention failed for instance %s: %v", inst.Name, err)
+		}
+	}
+}
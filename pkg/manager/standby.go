@@ -0,0 +1,362 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"log"
+	"time"
+)
+
+// Defaults of the standby settings of a service
+const (
+	defaultFailbackAfter       = 5 * time.Minute
+	defaultFailureThreshold    = 3
+	defaultHealthCheckInterval = 5 * time.Second
+)
+
+// Codes of failover events
+const (
+	FailoverCodeFailover = "failover" // The standby took over from the failed primary
+	FailoverCodeFailback = "failback" // The primary serves the service again
+)
+
+var (
+	// ErrNoStandby is returned for services that do not have a standby
+	ErrNoStandby = errors.New("service has no standby")
+
+	// ErrFailbackNotPossible is returned when the primary cannot take over from the standby
+	ErrFailbackNotPossible = errors.New("failback not possible")
+)
+
+// StandbyStatus is the state of the primary and standby of a service
+type StandbyStatus struct {
+	Primary   string `json:"primary"`
+	Standby   string `json:"standby"`
+	Active    string `json:"active"` // The member requests for the service are routed to
+	Failback  string `json:"failback"`
+	Failovers int    `json:"failovers"`
+
+	// Consecutive failed health checks of the active member
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	FailedOverAt        *time.Time `json:"failed_over_at,omitempty"`
+	PrimaryHealthySince *time.Time `json:"primary_healthy_since,omitempty"` // While the standby is active
+	LastReason          string     `json:"last_reason,omitempty"`
+}
+
+// standbyPair tracks which member of a service with a standby is active. Active is the single
+// field requests are routed by, only changed by failovers and failbacks, so the primary and
+// the standby can never both be active.
+type standbyPair struct {
+	alias string
+	svc   config.ServiceConfig
+	stop  chan struct{}
+
+	// Guarded by the standby lock of the manager
+	active       string
+	failures     int
+	failovers    int
+	failedOverAt time.Time
+	healthySince time.Time
+	lastReason   string
+	recycling    bool
+}
+
+// SetServices starts watching the health of the members of the services with a standby,
+// replacing the watchers of a previous call, and routes the services with weights. The primary
+// of each service is active at first.
+func (im *instanceManager) SetServices(services map[string]config.ServiceConfig) {
+	im.configureRoutes(services)
+
+	im.standbyMu.Lock()
+	defer im.standbyMu.Unlock()
+
+	im.services = services
+	for _, pair := range im.standbys {
+		close(pair.stop)
+	}
+	im.standbys = make(map[string]*standbyPair)
+	for alias, svc := range services {
+		if svc.Primary == "" || svc.Standby == "" {
+			continue
+		}
+		if svc.Failback == "" {
+			svc.Failback = config.FailbackManual
+		}
+		pair := &standbyPair{alias: alias, svc: svc, stop: make(chan struct{}), active: svc.Primary}
+		im.standbys[alias] = pair
+
+		im.background.Add(1)
+		go im.watchStandby(pair)
+	}
+}
+
+// ActiveMember returns the instance requests for a service with a standby are routed to
+func (im *instanceManager) ActiveMember(alias string) (string, bool) {
+	im.standbyMu.Lock()
+	defer im.standbyMu.Unlock()
+	pair, ok := im.standbys[alias]
+	if !ok {
+		return "", false
+	}
+	return pair.active, true
+}
+
+// GetStandbyStatus returns the state of the primary and standby of a service
+func (im *instanceManager) GetStandbyStatus(alias string) (*StandbyStatus, error) {
+	im.standbyMu.Lock()
+	defer im.standbyMu.Unlock()
+	pair, ok := im.standbys[alias]
+	if !ok {
+		return nil, fmt.Errorf("service %s: %w", alias, ErrNoStandby)
+	}
+	return pair.status(), nil
+}
+
+// Failback routes a service back to its primary, which has to pass a health check first
+func (im *instanceManager) Failback(alias string) (*StandbyStatus, error) {
+	return im.failback(alias, "")
+}
+
+// failback is Failback on behalf of actor
+func (im *instanceManager) failback(alias string, actor string) (*StandbyStatus, error) {
+	im.standbyMu.Lock()
+	pair, ok := im.standbys[alias]
+	im.standbyMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("service %s: %w", alias, ErrNoStandby)
+	}
+
+	if err := im.checkMember(pair.svc.Primary); err != nil {
+		return nil, fmt.Errorf("%w: primary %s is not healthy: %v", ErrFailbackNotPossible, pair.svc.Primary, err)
+	}
+
+	im.standbyMu.Lock()
+	if pair.active == pair.svc.Primary {
+		status := pair.status()
+		im.standbyMu.Unlock()
+		return status, nil
+	}
+	reason := "failback requested"
+	if actor != "" {
+		reason += " by " + actor
+	}
+	im.activate(pair, pair.svc.Primary, reason)
+	status := pair.status()
+	im.standbyMu.Unlock()
+
+	im.recordAudit(actor, "failback", alias, pair.svc.Primary)
+	return status, nil
+}
+
+// standbyMembers returns the primaries and standbys of the services. They are kept running
+// without traffic, so they are neither stopped when idle nor evicted.
+func (im *instanceManager) standbyMembers() map[string]bool {
+	im.standbyMu.Lock()
+	defer im.standbyMu.Unlock()
+	members := make(map[string]bool, 2*len(im.standbys))
+	for _, pair := range im.standbys {
+		members[pair.svc.Primary] = true
+		members[pair.svc.Standby] = true
+	}
+	return members
+}
+
+// watchStandby health checks the members of a service with a standby until the manager
+// shuts down or the services are reconfigured
+func (im *instanceManager) watchStandby(pair *standbyPair) {
+	defer im.background.Done()
+
+	interval := pair.svc.HealthCheckInterval.Duration()
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			im.checkStandby(pair)
+		case <-pair.stop:
+			return
+		case <-im.shutdownChan:
+			return
+		}
+	}
+}
+
+// checkStandby health checks both members of a service and fails over or back as needed
+func (im *instanceManager) checkStandby(pair *standbyPair) {
+	primary, standby := pair.svc.Primary, pair.svc.Standby
+	primaryErr := im.checkMember(primary)
+	standbyErr := im.checkMember(standby)
+
+	im.standbyMu.Lock()
+	defer im.standbyMu.Unlock()
+
+	threshold := pair.svc.FailureThreshold
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	now := time.Now()
+
+	if pair.active == primary {
+		if primaryErr == nil {
+			pair.failures = 0
+			return
+		}
+		pair.failures++
+		if pair.failures < threshold {
+			return
+		}
+		if standbyErr != nil {
+			if pair.failures > threshold {
+				return // Already reported
+			}
+			log.Printf("Warning: primary %s of service %s failed %d health checks, but standby %s is not healthy either: %v", primary, pair.alias, pair.failures, standby, standbyErr)
+			return
+		}
+
+		im.activate(pair, standby, fmt.Sprintf("primary %s failed %d consecutive health checks: %v", primary, pair.failures, primaryErr))
+		pair.failovers++
+		pair.failedOverAt = now
+		im.recyclePrimary(pair)
+		return
+	}
+
+	// The standby is active
+	if primaryErr != nil {
+		pair.healthySince = time.Time{}
+	} else if pair.healthySince.IsZero() {
+		pair.healthySince = now
+	}
+	if standbyErr == nil {
+		pair.failures = 0
+	} else {
+		pair.failures++
+	}
+
+	switch {
+	case primaryErr != nil:
+		if pair.failures == threshold {
+			log.Printf("Warning: standby %s of service %s failed %d health checks and primary %s is not healthy: %v", standby, pair.alias, pair.failures, primary, primaryErr)
+		}
+	case pair.failures >= threshold:
+		// The primary is preferred while healthy, whatever the failback policy
+		im.activate(pair, primary, fmt.Sprintf("standby %s failed %d consecutive health checks: %v", standby, pair.failures, standbyErr))
+	case pair.svc.Failback == config.FailbackAutomatic && now.Sub(pair.healthySince) >= failbackAfter(pair.svc):
+		im.activate(pair, primary, fmt.Sprintf("primary %s has been healthy for %s", primary, config.Duration(failbackAfter(pair.svc))))
+	}
+}
+
+// activate routes the service to member and publishes a failover event (caller must hold
+// the standby lock)
+func (im *instanceManager) activate(pair *standbyPair, member, reason string) {
+	previous := pair.active
+	pair.active = member
+	pair.failures = 0
+	pair.healthySince = time.Time{}
+	pair.lastReason = reason
+
+	code := FailoverCodeFailover
+	if member == pair.svc.Primary {
+		code = FailoverCodeFailback
+	}
+	log.Printf("Service %s %s from %s to %s: %s", pair.alias, code, previous, member, reason)
+	im.events.Publish(events.Event{
+		Type:     events.TypeFailover,
+		Instance: member,
+		Code:     code,
+		Message:  reason,
+		Data: map[string]any{
+			"service":  pair.alias,
+			"previous": previous,
+			"active":   member,
+		},
+	})
+}
+
+// recyclePrimary restarts the failed primary of a service in the background, so it can take
+// over again once healthy. Unmanaged and stopped primaries are left alone. (caller must hold
+// the standby lock)
+func (im *instanceManager) recyclePrimary(pair *standbyPair) {
+	if pair.recycling {
+		return
+	}
+	pair.recycling = true
+	name := pair.svc.Primary
+
+	im.background.Add(1)
+	go func() {
+		defer im.background.Done()
+		defer func() {
+			im.standbyMu.Lock()
+			pair.recycling = false
+			im.standbyMu.Unlock()
+		}()
+
+		inst, err := im.GetInstance(name)
+		if err != nil || !inst.IsManaged() || inst.GetStatus() == instance.Stopped {
+			return
+		}
+		log.Printf("Recycling failed primary %s of service %s", name, pair.alias)
+		if inst.IsRunning() {
+			_, err = im.restartInstance(name, "", OperationOptions{})
+		} else {
+			_, err = im.startInstance(name, "", OperationOptions{})
+		}
+		if err != nil {
+			log.Printf("Failed to recycle primary %s of service %s: %v", name, pair.alias, err)
+		}
+	}()
+}
+
+// checkMember health checks a member of a service
+func (im *instanceManager) checkMember(name string) error {
+	inst, err := im.GetInstance(name)
+	if err != nil {
+		return err
+	}
+	if !inst.IsRunning() {
+		return fmt.Errorf("instance %s is %s", name, inst.GetStatus())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), standbyProbeTimeout)
+	defer cancel()
+	return inst.ProbeHealth(ctx)
+}
+
+// standbyProbeTimeout bounds a single health check of a member of a service with a standby
+const standbyProbeTimeout = 5 * time.Second
+
+func failbackAfter(svc config.ServiceConfig) time.Duration {
+	if svc.FailbackAfter > 0 {
+		return svc.FailbackAfter.Duration()
+	}
+	return defaultFailbackAfter
+}
+
+// status returns a copy of the state of the pair (caller must hold the standby lock)
+func (p *standbyPair) status() *StandbyStatus {
+	status := &StandbyStatus{
+		Primary:             p.svc.Primary,
+		Standby:             p.svc.Standby,
+		Active:              p.active,
+		Failback:            p.svc.Failback,
+		Failovers:           p.failovers,
+		ConsecutiveFailures: p.failures,
+		LastReason:          p.lastReason,
+	}
+	if !p.failedOverAt.IsZero() {
+		failedOverAt := p.failedOverAt
+		status.FailedOverAt = &failedOverAt
+	}
+	if !p.healthySince.IsZero() {
+		healthySince := p.healthySince
+		status.PrimaryHealthySince = &healthySince
+	}
+	return status
+}
@@ -0,0 +1,212 @@
+package manager_test
+
+import (
+	"errors"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// createToggledExternal creates an unmanaged instance whose health check fails while the
+// returned flag is cleared
+func createToggledExternal(t *testing.T, mngr manager.InstanceManager, name string) *atomic.Bool {
+	t.Helper()
+	healthy := &atomic.Bool{}
+	healthy.Store(true)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	managed := false
+	inst, err := mngr.CreateInstance(name, &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		Managed:     &managed,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  backendURL.Hostname(),
+			Port:  port,
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance %s failed: %v", name, err)
+	}
+	waitFor(t, "instance "+name+" to be probed", inst.IsRunning)
+	return healthy
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func activeMember(mngr manager.InstanceManager, alias string) string {
+	member, _ := mngr.ActiveMember(alias)
+	return member
+}
+
+func TestStandby_FailoverAndAutomaticFailback(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+
+	primaryHealthy := createToggledExternal(t, mngr, "primary")
+	createToggledExternal(t, mngr, "standby")
+
+	sub, unsubscribe := mngr.SubscribeEvents()
+	defer unsubscribe()
+
+	mngr.SetServices(map[string]config.ServiceConfig{
+		"chat": {
+			Primary:             "primary",
+			Standby:             "standby",
+			Failback:            config.FailbackAutomatic,
+			FailbackAfter:       config.Duration(200 * time.Millisecond),
+			FailureThreshold:    2,
+			HealthCheckInterval: config.Duration(20 * time.Millisecond),
+		},
+	})
+
+	if member, ok := mngr.ActiveMember("chat"); !ok || member != "primary" {
+		t.Fatalf("Expected the primary to be active at first, got %q (%v)", member, ok)
+	}
+	if _, ok := mngr.ActiveMember("primary"); ok {
+		t.Error("Expected instances not to resolve as services")
+	}
+
+	primaryHealthy.Store(false)
+	waitFor(t, "the standby to be promoted", func() bool { return activeMember(mngr, "chat") == "standby" })
+
+	status, err := mngr.GetStandbyStatus("chat")
+	if err != nil {
+		t.Fatalf("GetStandbyStatus failed: %v", err)
+	}
+	if status.Failovers != 1 || status.FailedOverAt == nil {
+		t.Errorf("Expected one recorded failover, got %d at %v", status.Failovers, status.FailedOverAt)
+	}
+
+	event := nextFailoverEvent(t, sub)
+	if event.Code != manager.FailoverCodeFailover || event.Instance != "standby" {
+		t.Errorf("Expected a failover event for the standby, got %s for %s", event.Code, event.Instance)
+	}
+	if event.Data["service"] != "chat" || event.Data["previous"] != "primary" {
+		t.Errorf("Expected the event to name the service and the failed primary, got %v", event.Data)
+	}
+
+	// The primary has to stay healthy for a while before it takes over again
+	primaryHealthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	if member := activeMember(mngr, "chat"); member != "standby" {
+		t.Errorf("Expected the standby to stay active until the primary was healthy long enough, got %s", member)
+	}
+	waitFor(t, "the primary to take over again", func() bool { return activeMember(mngr, "chat") == "primary" })
+
+	event = nextFailoverEvent(t, sub)
+	if event.Code != manager.FailoverCodeFailback || event.Instance != "primary" {
+		t.Errorf("Expected a failback event for the primary, got %s for %s", event.Code, event.Instance)
+	}
+}
+
+func TestStandby_ManualFailback(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+
+	primaryHealthy := createToggledExternal(t, mngr, "primary")
+	createToggledExternal(t, mngr, "standby")
+
+	mngr.SetServices(map[string]config.ServiceConfig{
+		"chat": {
+			Primary:             "primary",
+			Standby:             "standby",
+			FailureThreshold:    1,
+			HealthCheckInterval: config.Duration(20 * time.Millisecond),
+		},
+	})
+
+	primaryHealthy.Store(false)
+	waitFor(t, "the standby to be promoted", func() bool { return activeMember(mngr, "chat") == "standby" })
+
+	if _, err := mngr.Failback("chat"); !errors.Is(err, manager.ErrFailbackNotPossible) {
+		t.Errorf("Expected failback to an unhealthy primary to fail, got %v", err)
+	}
+
+	// Without automatic failback the standby keeps serving the healthy primary's traffic
+	primaryHealthy.Store(true)
+	time.Sleep(100 * time.Millisecond)
+	if member := activeMember(mngr, "chat"); member != "standby" {
+		t.Fatalf("Expected the standby to stay active without automatic failback, got %s", member)
+	}
+
+	status, err := mngr.WithActor("alice").Failback("chat")
+	if err != nil {
+		t.Fatalf("Failback failed: %v", err)
+	}
+	if status.Active != "primary" || activeMember(mngr, "chat") != "primary" {
+		t.Errorf("Expected the primary to be active after failback, got %s", status.Active)
+	}
+
+	if _, err := mngr.Failback("other"); !errors.Is(err, manager.ErrNoStandby) {
+		t.Errorf("Expected ErrNoStandby for a service without a standby, got %v", err)
+	}
+}
+
+func TestStandby_NoFailoverToUnhealthyStandby(t *testing.T) {
+	mngr := createTestManager()
+	defer mngr.Shutdown()
+
+	primaryHealthy := createToggledExternal(t, mngr, "primary")
+	standbyHealthy := createToggledExternal(t, mngr, "standby")
+
+	mngr.SetServices(map[string]config.ServiceConfig{
+		"chat": {
+			Primary:             "primary",
+			Standby:             "standby",
+			FailureThreshold:    1,
+			HealthCheckInterval: config.Duration(20 * time.Millisecond),
+		},
+	})
+
+	standbyHealthy.Store(false)
+	primaryHealthy.Store(false)
+	time.Sleep(100 * time.Millisecond)
+	if member := activeMember(mngr, "chat"); member != "primary" {
+		t.Errorf("Expected the primary to stay active while the standby is unhealthy, got %s", member)
+	}
+}
+
+func nextFailoverEvent(t *testing.T, sub <-chan events.Event) events.Event {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-sub:
+			if event.Type == events.TypeFailover {
+				return event
+			}
+		case <-timeout:
+			t.Fatal("Timed out waiting for a failover event")
+			return events.Event{}
+		}
+	}
+}
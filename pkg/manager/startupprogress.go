@@ -0,0 +1,20 @@
+package manager
+
+import (
+	"fmt"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"strings"
+)
+
+// reportStartupProgress publishes a startup_progress event for a starting instance whose
+// backend advanced its startup
+func (im *instanceManager) reportStartupProgress(name string, progress instance.StartupProgress) {
+	im.events.Publish(events.Event{
+		Type:     events.TypeStartupProgress,
+		Instance: name,
+		Code:     progress.Phase,
+		Message:  fmt.Sprintf("startup %s, %d%%", strings.ReplaceAll(progress.Phase, "_", " "), *progress.Percent),
+		Data:     map[string]any{"percent": *progress.Percent},
+	})
+}
@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/validation"
+	"log"
+	"sort"
+)
+
+// ErrConfigManaged is returned when the API deletes a protected instance defined in the
+// configuration file, or updates one whose update policy rejects it
+var ErrConfigManaged = errors.New("config-managed instance")
+
+// markManagedBy records whether an instance is defined in the configuration file
+func (im *instanceManager) markManagedBy(inst *instance.Process) {
+	if _, ok := im.instancesConfig.Static[inst.Name]; ok {
+		inst.SetManagedBy(instance.ManagedByConfig)
+	}
+}
+
+// checkConfigUpdate rejects updating the options of a static instance through the API,
+// unless its update policy lets the update apply until the next start of llamactl
+func (im *instanceManager) checkConfigUpdate(name string) error {
+	static, ok := im.instancesConfig.Static[name]
+	if ok && static.UpdatePolicy != config.StaticUpdateRevert {
+		return fmt.Errorf("%w %s cannot be updated through the API, change its options in the configuration file", ErrConfigManaged, name)
+	}
+	return nil
+}
+
+// checkConfigDelete rejects deleting a protected static instance through the API
+func (im *instanceManager) checkConfigDelete(name string) error {
+	if static, ok := im.instancesConfig.Static[name]; ok && static.Protected {
+		return fmt.Errorf("%w %s cannot be deleted through the API, remove it from the configuration file", ErrConfigManaged, name)
+	}
+	return nil
+}
+
+// staticFleet returns the instances defined in the configuration file as a fleet. Invalid
+// definitions are logged and left out.
+func (im *instanceManager) staticFleet() *Fleet {
+	names := make([]string, 0, len(im.instancesConfig.Static))
+	for name := range im.instancesConfig.Static {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fleet := &Fleet{fromConfig: true}
+	for _, name := range names {
+		static := im.instancesConfig.Static[name]
+		if _, err := validation.ValidateInstanceName(name); err != nil {
+			log.Printf("Invalid static instance %s: %v", name, err)
+			continue
+		}
+
+		// Round trip through JSON so the options are parsed exactly like API requests
+		optionsJSON, err := json.Marshal(static.Options)
+		if err != nil {
+			log.Printf("Invalid static instance %s: failed to convert options: %v", name, err)
+			continue
+		}
+		options := &instance.CreateInstanceOptions{}
+		if err := json.Unmarshal(optionsJSON, options); err != nil {
+			log.Printf("Invalid static instance %s: invalid options: %v", name, err)
+			continue
+		}
+
+		state := static.State
+		if state == "" {
+			state = FleetStateRunning
+		}
+		fleet.Instances = append(fleet.Instances, FleetInstance{Name: name, State: state, Options: options})
+	}
+	return fleet
+}
+
+// reconcileStaticInstances creates the instances defined in the configuration file that
+// do not exist, and reverts the options and state of the others to their definition
+func (im *instanceManager) reconcileStaticInstances() {
+	if len(im.instancesConfig.Static) == 0 {
+		return
+	}
+
+	// Not through ApplyFleet, which waits for the restore this is part of
+	plan, err := im.applyFleet(im.staticFleet(), ApplyOptions{}, "")
+	if err != nil {
+		log.Printf("Failed to reconcile static instances: %v", err)
+		for name := range im.instancesConfig.Static {
+			im.restore.finish(name, "", err, true)
+		}
+		return
+	}
+	for _, action := range plan.Actions {
+		if action.Error != "" {
+			log.Printf("Failed to %s static instance %s: %s", action.Action, action.Instance, action.Error)
+			im.restore.finish(action.Instance, "", errors.New(action.Error), true)
+		}
+	}
+	for name := range im.instancesConfig.Static {
+		im.restore.finish(name, RestoreReconciled, nil, true)
+	}
+}
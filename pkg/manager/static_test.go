@@ -0,0 +1,142 @@
+package manager_test
+
+import (
+	"errors"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+var testStaticInstances = map[string]config.StaticInstanceConfig{
+	"embed": {
+		Protected: true,
+		Options: map[string]any{
+			"backend_type":    "llama_cpp",
+			"backend_options": map[string]any{"model": "/models/embed.gguf"},
+		},
+	},
+	"chat": {
+		State:        "stopped",
+		UpdatePolicy: config.StaticUpdateRevert,
+		Options: map[string]any{
+			"backend_type":    "llama_cpp",
+			"tags":            []any{"chat"},
+			"backend_options": map[string]any{"model": "/models/chat.gguf"},
+		},
+	},
+}
+
+// newStaticManager returns a manager with the static instances of testStaticInstances,
+// persisting to dir
+func newStaticManager(t *testing.T, dir string) manager.InstanceManager {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	backendConfig := config.BackendConfig{
+		LlamaCpp: config.BackendSettings{
+			Command: "sh",
+			Args:    []string{"-c", "exec sleep 30"},
+		},
+	}
+	cfg := config.InstancesConfig{
+		PortRange:            [2]int{8000, 9000},
+		InstancesDir:         filepath.Join(dir, "instances"),
+		LogsDir:              filepath.Join(dir, "logs"),
+		MaxInstances:         10,
+		MaxRunningInstances:  -1,
+		TimeoutCheckInterval: config.Duration(5 * time.Minute),
+		Static:               testStaticInstances,
+	}
+	mngr := manager.NewInstanceManagerWithStore(backendConfig, cfg, storage.NewFileStore(cfg.InstancesDir, dir))
+	t.Cleanup(mngr.Shutdown)
+	return mngr
+}
+
+// waitForInstance waits for the instance to exist and satisfy the condition
+func waitForInstance(t *testing.T, mngr manager.InstanceManager, name string, condition func(*instance.Process) bool) *instance.Process {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if inst, err := mngr.GetInstance(name); err == nil && condition(inst) {
+			return inst
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for instance %s", name)
+	return nil
+}
+
+func TestStaticInstances_CreatedAndProtected(t *testing.T) {
+	mngr := newStaticManager(t, t.TempDir())
+
+	embed := waitForInstance(t, mngr, "embed", (*instance.Process).IsRunning)
+	chat := waitForInstance(t, mngr, "chat", func(*instance.Process) bool { return true })
+	if chat.IsRunning() {
+		t.Error("Expected the stopped static instance not to be started")
+	}
+	for _, inst := range []*instance.Process{embed, chat} {
+		if inst.ManagedBy() != instance.ManagedByConfig {
+			t.Errorf("Expected %s to be managed by the config, got %s", inst.Name, inst.ManagedBy())
+		}
+	}
+
+	options := &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/models/other.gguf"},
+	}
+	if _, err := mngr.UpdateInstance("embed", options); !errors.Is(err, manager.ErrConfigManaged) {
+		t.Errorf("Expected updating a static instance to be rejected, got %v", err)
+	}
+	if _, err := mngr.StopInstance("embed"); err != nil {
+		t.Fatalf("StopInstance failed: %v", err)
+	}
+	if err := mngr.DeleteInstance("embed"); !errors.Is(err, manager.ErrConfigManaged) {
+		t.Errorf("Expected deleting a protected static instance to be rejected, got %v", err)
+	}
+
+	other, err := mngr.CreateInstance("other", options)
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if other.ManagedBy() != instance.ManagedByAPI {
+		t.Errorf("Expected an instance created through the API to be managed by the API, got %s", other.ManagedBy())
+	}
+
+	// Fleets do not prune the instances of the configuration file
+	plan := applyFleet(t, mngr, "instances: []\n", manager.ApplyOptions{Prune: true})
+	if got := planSummary(plan); !slices.Equal(got, []string{"delete other"}) {
+		t.Errorf("Expected only the API instance to be pruned, got %v", got)
+	}
+
+	// Deleting an unprotected static instance is allowed
+	if err := mngr.DeleteInstance("chat"); err != nil {
+		t.Errorf("Expected the unprotected static instance to be deleted, got %v", err)
+	}
+}
+
+func TestStaticInstances_RevertedOnRestart(t *testing.T) {
+	dir := t.TempDir()
+	mngr := newStaticManager(t, dir)
+	chat := waitForInstance(t, mngr, "chat", func(*instance.Process) bool { return true })
+
+	options := *chat.GetOptions()
+	options.Tags = []string{"changed"}
+	if _, err := mngr.UpdateInstance("chat", &options); err != nil {
+		t.Fatalf("Expected the revert policy to allow updates, got %v", err)
+	}
+	mngr.Shutdown()
+
+	restarted := newStaticManager(t, dir)
+	waitForInstance(t, restarted, "chat", func(inst *instance.Process) bool {
+		return slices.Equal(inst.GetOptions().Tags, []string{"chat"})
+	})
+}
@@ -7,21 +7,25 @@ import (
 )
 
 func (im *instanceManager) checkAllTimeouts() {
+	standbyMembers := im.standbyMembers()
 	im.mu.RLock()
-	var timeoutInstances []string
+	var timeoutInstances []*instance.Process
 
 	// Identify instances that should timeout
 	for _, inst := range im.instances {
-		if inst.ShouldTimeout() {
-			timeoutInstances = append(timeoutInstances, inst.Name)
+		if inst.ShouldTimeout() && !standbyMembers[inst.Name] {
+			timeoutInstances = append(timeoutInstances, inst)
 		}
 	}
 	im.mu.RUnlock() // Release read lock before calling StopInstance
 
-	// Stop the timed-out instances
-	for _, name := range timeoutInstances {
+	// Stop the timed-out instances, draining the requests still in flight
+	for _, inst := range timeoutInstances {
+		name := inst.Name
 		log.Printf("Instance %s has timed out, stopping it", name)
-		if _, err := im.StopInstance(name); err != nil {
+		message := fmt.Sprintf("instance exceeded its idle timeout, no requests for %s", inst.IdleFor())
+		// An instance busy with another operation is stopped at a later check
+		if _, err := im.stopInstance(name, instance.ReasonIdleTimeout, message, "", OperationOptions{NoQueue: true}); err != nil {
 			log.Printf("Error stopping instance %s: %v", name, err)
 		} else {
 			log.Printf("Instance %s stopped successfully", name)
@@ -31,13 +35,17 @@ func (im *instanceManager) checkAllTimeouts() {
 
 // EvictLRUInstance finds and stops the least recently used running instance.
 func (im *instanceManager) EvictLRUInstance() error {
+	standbyMembers := im.standbyMembers()
 	im.mu.RLock()
 	var lruInstance *instance.Process
 
 	for name := range im.runningInstances {
 		inst := im.instances[name]
-		if inst == nil {
-			continue
+		if inst == nil || !inst.IsManaged() {
+			continue // External backends cannot be stopped
+		}
+		if standbyMembers[name] {
+			continue // Members of a service with a standby stay warm
 		}
 
 		if inst.GetOptions() != nil && inst.GetOptions().IdleTimeout != nil && *inst.GetOptions().IdleTimeout <= 0 {
@@ -59,6 +67,6 @@ func (im *instanceManager) EvictLRUInstance() error {
 	}
 
 	// Evict Instance
-	_, err := im.StopInstance(lruInstance.Name)
+	_, err := im.stopInstance(lruInstance.Name, instance.ReasonPreempted, "evicted as least recently used to free a running slot", "", OperationOptions{NoQueue: true})
 	return err
 }
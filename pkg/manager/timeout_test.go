@@ -19,7 +19,7 @@ func TestTimeoutFunctionality(t *testing.T) {
 	}
 	cfg := config.InstancesConfig{
 		PortRange:            [2]int{8000, 9000},
-		TimeoutCheckInterval: 10,
+		TimeoutCheckInterval: config.Duration(10 * time.Minute),
 		MaxInstances:         5,
 	}
 
@@ -33,7 +33,7 @@ func TestTimeoutFunctionality(t *testing.T) {
 	testManager := createTestManager()
 	defer testManager.Shutdown()
 
-	idleTimeout := 1 // 1 minute
+	idleTimeout := config.Duration(time.Minute)
 	options := &instance.CreateInstanceOptions{
 		IdleTimeout: &idleTimeout,
 		BackendType: backends.BackendTypeLlamaCpp,
@@ -51,8 +51,8 @@ func TestTimeoutFunctionality(t *testing.T) {
 	if inst.GetOptions().IdleTimeout == nil {
 		t.Fatal("Instance should have idle timeout configured")
 	}
-	if *inst.GetOptions().IdleTimeout != 1 {
-		t.Errorf("Expected idle timeout 1 minute, got %d", *inst.GetOptions().IdleTimeout)
+	if *inst.GetOptions().IdleTimeout != config.Duration(time.Minute) {
+		t.Errorf("Expected idle timeout 1m, got %s", *inst.GetOptions().IdleTimeout)
 	}
 
 	// Test timeout logic without actually starting the process
@@ -61,7 +61,7 @@ func TestTimeoutFunctionality(t *testing.T) {
 	inst.SetTimeProvider(mockTime)
 
 	// Set instance to running state so timeout logic can work
-	inst.SetStatus(instance.Running)
+	inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
 
 	// Simulate instance being "running" for timeout check (without actual process)
 	// We'll test the ShouldTimeout logic directly
@@ -81,7 +81,7 @@ func TestTimeoutFunctionality(t *testing.T) {
 	}
 
 	// Reset running state to avoid shutdown issues
-	inst.SetStatus(instance.Stopped)
+	inst.SetStatus(instance.Stopped, instance.ReasonUserStop, "")
 
 	// Test that instance without timeout doesn't timeout
 	noTimeoutOptions := &instance.CreateInstanceOptions{
@@ -98,7 +98,7 @@ func TestTimeoutFunctionality(t *testing.T) {
 	}
 
 	noTimeoutInst.SetTimeProvider(mockTime)
-	noTimeoutInst.SetStatus(instance.Running) // Set to running for timeout check
+	noTimeoutInst.SetStatus(instance.Running, instance.ReasonUserStart, "") // Set to running for timeout check
 	noTimeoutInst.UpdateLastRequestTime()
 
 	// Even with time advanced, should not timeout
@@ -107,7 +107,7 @@ func TestTimeoutFunctionality(t *testing.T) {
 	}
 
 	// Reset running state to avoid shutdown issues
-	noTimeoutInst.SetStatus(instance.Stopped)
+	noTimeoutInst.SetStatus(instance.Stopped, instance.ReasonUserStop, "")
 }
 
 func TestEvictLRUInstance_Success(t *testing.T) {
@@ -120,21 +120,21 @@ func TestEvictLRUInstance_Success(t *testing.T) {
 		LlamaServerOptions: &llamacpp.LlamaServerOptions{
 			Model: "/path/to/model1.gguf",
 		},
-		IdleTimeout: func() *int { timeout := 1; return &timeout }(), // Any value > 0
+		IdleTimeout: func() *config.Duration { timeout := config.Duration(time.Minute); return &timeout }(), // Any value > 0
 	}
 	options2 := &instance.CreateInstanceOptions{
 		BackendType: backends.BackendTypeLlamaCpp,
 		LlamaServerOptions: &llamacpp.LlamaServerOptions{
 			Model: "/path/to/model2.gguf",
 		},
-		IdleTimeout: func() *int { timeout := 1; return &timeout }(), // Any value > 0
+		IdleTimeout: func() *config.Duration { timeout := config.Duration(time.Minute); return &timeout }(), // Any value > 0
 	}
 	options3 := &instance.CreateInstanceOptions{
 		BackendType: backends.BackendTypeLlamaCpp,
 		LlamaServerOptions: &llamacpp.LlamaServerOptions{
 			Model: "/path/to/model3.gguf",
 		},
-		IdleTimeout: func() *int { timeout := 1; return &timeout }(), // Any value > 0
+		IdleTimeout: func() *config.Duration { timeout := config.Duration(time.Minute); return &timeout }(), // Any value > 0
 	}
 
 	inst1, err := manager.CreateInstance("instance-1", options1)
@@ -156,9 +156,9 @@ func TestEvictLRUInstance_Success(t *testing.T) {
 	inst2.SetTimeProvider(mockTime)
 	inst3.SetTimeProvider(mockTime)
 
-	inst1.SetStatus(instance.Running)
-	inst2.SetStatus(instance.Running)
-	inst3.SetStatus(instance.Running)
+	inst1.SetStatus(instance.Running, instance.ReasonUserStart, "")
+	inst2.SetStatus(instance.Running, instance.ReasonUserStart, "")
+	inst3.SetStatus(instance.Running, instance.ReasonUserStart, "")
 
 	// Set different last request times (oldest to newest)
 	// inst1: oldest (will be evicted)
@@ -190,13 +190,13 @@ func TestEvictLRUInstance_Success(t *testing.T) {
 	}
 
 	// Clean up manually - set all to stopped and then shutdown
-	inst2.SetStatus(instance.Stopped)
-	inst3.SetStatus(instance.Stopped)
+	inst2.SetStatus(instance.Stopped, instance.ReasonUserStop, "")
+	inst3.SetStatus(instance.Stopped, instance.ReasonUserStop, "")
 }
 
 func TestEvictLRUInstance_NoEligibleInstances(t *testing.T) {
 	// Helper function to create instances with different timeout configurations
-	createInstanceWithTimeout := func(manager manager.InstanceManager, name, model string, timeout *int) *instance.Process {
+	createInstanceWithTimeout := func(manager manager.InstanceManager, name, model string, timeout *config.Duration) *instance.Process {
 		options := &instance.CreateInstanceOptions{
 			BackendType: backends.BackendTypeLlamaCpp,
 			LlamaServerOptions: &llamacpp.LlamaServerOptions{
@@ -229,8 +229,8 @@ func TestEvictLRUInstance_NoEligibleInstances(t *testing.T) {
 		defer manager.Shutdown()
 
 		// Create instances with various non-eligible timeout configurations
-		zeroTimeout := 0
-		negativeTimeout := -1
+		zeroTimeout := config.Duration(0)
+		negativeTimeout := config.Duration(-time.Minute)
 		inst1 := createInstanceWithTimeout(manager, "no-timeout-1", "/path/to/model1.gguf", &zeroTimeout)
 		inst2 := createInstanceWithTimeout(manager, "no-timeout-2", "/path/to/model2.gguf", &negativeTimeout)
 		inst3 := createInstanceWithTimeout(manager, "no-timeout-3", "/path/to/model3.gguf", nil)
@@ -238,12 +238,12 @@ func TestEvictLRUInstance_NoEligibleInstances(t *testing.T) {
 		// Set instances to running
 		instances := []*instance.Process{inst1, inst2, inst3}
 		for _, inst := range instances {
-			inst.SetStatus(instance.Running)
+			inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
 		}
 		defer func() {
 			// Reset instances to stopped to avoid shutdown panics
 			for _, inst := range instances {
-				inst.SetStatus(instance.Stopped)
+				inst.SetStatus(instance.Stopped, instance.ReasonUserStop, "")
 			}
 		}()
 
@@ -269,8 +269,8 @@ func TestEvictLRUInstance_NoEligibleInstances(t *testing.T) {
 		defer manager.Shutdown()
 
 		// Create mix of instances: some with timeout enabled, some disabled
-		validTimeout := 1
-		zeroTimeout := 0
+		validTimeout := config.Duration(time.Minute)
+		zeroTimeout := config.Duration(0)
 		instWithTimeout := createInstanceWithTimeout(manager, "with-timeout", "/path/to/model-with-timeout.gguf", &validTimeout)
 		instNoTimeout1 := createInstanceWithTimeout(manager, "no-timeout-1", "/path/to/model-no-timeout1.gguf", &zeroTimeout)
 		instNoTimeout2 := createInstanceWithTimeout(manager, "no-timeout-2", "/path/to/model-no-timeout2.gguf", nil)
@@ -278,14 +278,14 @@ func TestEvictLRUInstance_NoEligibleInstances(t *testing.T) {
 		// Set all instances to running
 		instances := []*instance.Process{instWithTimeout, instNoTimeout1, instNoTimeout2}
 		for _, inst := range instances {
-			inst.SetStatus(instance.Running)
+			inst.SetStatus(instance.Running, instance.ReasonUserStart, "")
 			inst.UpdateLastRequestTime()
 		}
 		defer func() {
 			// Reset instances to stopped to avoid shutdown panics
 			for _, inst := range instances {
 				if inst.IsRunning() {
-					inst.SetStatus(instance.Stopped)
+					inst.SetStatus(instance.Stopped, instance.ReasonUserStop, "")
 				}
 			}
 		}()
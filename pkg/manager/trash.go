@@ -0,0 +1,256 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/storage"
+	"log"
+	"sort"
+	"time"
+)
+
+// ErrNotInTrash is returned when restoring or purging an instance that is not in the trash
+var ErrNotInTrash = errors.New("instance is not in the trash")
+
+// DeleteOptions selects how an instance is deleted
+type DeleteOptions struct {
+	Permanent bool // Remove the definition right away rather than moving it to the trash
+	PurgeLogs bool // Remove the log files too, once the definition is purged
+}
+
+// TrashedInstance is the definition of a deleted instance kept in the trash until PurgeAt,
+// excluded from every operation until it is restored
+type TrashedInstance struct {
+	Name      string                          `json:"name"`
+	Options   *instance.CreateInstanceOptions `json:"options"`
+	DeletedAt time.Time                       `json:"deleted_at"`
+	PurgeAt   time.Time                       `json:"purge_at"`
+	PurgeLogs bool                            `json:"purge_logs,omitempty"` // The log files are removed on purge
+	DeletedBy string                          `json:"deleted_by,omitempty"`
+}
+
+// trashRecord is the persisted trash entry of an instance, with the managed backend key it is
+// restored with
+type trashRecord struct {
+	TrashedInstance
+	BackendKey string `json:"backend_key,omitempty"`
+}
+
+// trashInstance moves the definition of a deleted instance to the trash and schedules its
+// purge, replacing an earlier entry of the same name. (caller must hold the lock)
+func (im *instanceManager) trashInstance(inst *instance.Process, purgeLogs bool, actor string) (*trashRecord, error) {
+	now := time.Now()
+	rec := &trashRecord{TrashedInstance: TrashedInstance{
+		Name:      inst.Name,
+		Options:   inst.GetDesiredOptions(),
+		DeletedAt: now,
+		PurgeAt:   now.Add(im.instancesConfig.TrashRetention.Duration()),
+		PurgeLogs: purgeLogs,
+		DeletedBy: actor,
+	}}
+	if im.store != nil {
+		data, err := im.store.Get(storage.NamespaceBackendKeys, inst.Name)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("failed to read backend key of instance %s: %w", inst.Name, err)
+		}
+		var key backendKeyRecord
+		if err == nil && json.Unmarshal(data, &key) == nil {
+			rec.BackendKey = key.Key
+		}
+	}
+
+	// The logs of the earlier entry now belong to the new one
+	if old, ok := im.trash[inst.Name]; ok {
+		log.Printf("Purging instance %s from the trash, replaced by a newer deletion", inst.Name)
+		im.recordAudit(actor, "purge", inst.Name, "replaced in the trash, deleted at "+old.DeletedAt.Format(time.RFC3339))
+	}
+	if err := im.saveTrashRecord(rec); err != nil {
+		return nil, err
+	}
+	im.trash[inst.Name] = rec
+	im.scheduleTrashPurge(rec)
+	return rec, nil
+}
+
+// ListTrash returns the deleted instances kept in the trash, sorted by name, with the values
+// of their secret environment variables masked. They are restored with the values.
+func (im *instanceManager) ListTrash() []TrashedInstance {
+	im.mu.RLock()
+	defer im.mu.RUnlock()
+	trashed := make([]TrashedInstance, 0, len(im.trash))
+	for _, rec := range im.trash {
+		entry := rec.TrashedInstance
+		entry.Options = entry.Options.MaskedSecrets()
+		trashed = append(trashed, entry)
+	}
+	sort.Slice(trashed, func(a, b int) bool { return trashed[a].Name < trashed[b].Name })
+	return trashed
+}
+
+// RestoreInstance brings a deleted instance back from the trash, stopped, with the options
+// and managed backend key it was deleted with. It fails if its name or its port was taken
+// meanwhile.
+func (im *instanceManager) RestoreInstance(name string) (*instance.Process, error) {
+	return im.restoreInstance(name, "")
+}
+
+// restoreInstance is RestoreInstance on behalf of actor
+func (im *instanceManager) restoreInstance(name, actor string) (*instance.Process, error) {
+	im.mu.RLock()
+	rec, ok := im.trash[name]
+	im.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("cannot restore instance %s: %w", name, ErrNotInTrash)
+	}
+
+	// The options of the trash entry stay untouched if the restore fails
+	data, err := json.Marshal(rec.Options)
+	if err != nil {
+		return nil, err
+	}
+	var options instance.CreateInstanceOptions
+	if err := json.Unmarshal(data, &options); err != nil {
+		return nil, fmt.Errorf("invalid options of trashed instance %s: %w", name, err)
+	}
+	if err := im.validateOptions(&options); err != nil {
+		return nil, fmt.Errorf("cannot restore instance %s: %w", name, err)
+	}
+	inst, err := im.addInstance(name, &options, rec.BackendKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot restore instance %s: %w", name, err)
+	}
+
+	im.mu.Lock()
+	if im.trash[name] == rec {
+		im.removeTrashEntry(name)
+	}
+	im.mu.Unlock()
+	im.recordAudit(actor, "restore", name, "deleted at "+rec.DeletedAt.Format(time.RFC3339))
+	return inst, nil
+}
+
+// PurgeInstance removes a deleted instance from the trash right away, with its log files if
+// they were to be purged
+func (im *instanceManager) PurgeInstance(name string) error {
+	return im.purgeInstance(name, "")
+}
+
+// purgeInstance is PurgeInstance on behalf of actor
+func (im *instanceManager) purgeInstance(name, actor string) error {
+	if !im.purgeTrashed(name, nil, actor, "") {
+		return fmt.Errorf("cannot purge instance %s: %w", name, ErrNotInTrash)
+	}
+	return nil
+}
+
+// purgeTrashed removes the trash entry of an instance, only if it is still rec when rec is
+// set, and its log files if they were to be purged. It reports whether it was removed.
+func (im *instanceManager) purgeTrashed(name string, rec *trashRecord, actor, details string) bool {
+	im.mu.Lock()
+	current, ok := im.trash[name]
+	if !ok || (rec != nil && current != rec) || im.isShutdown {
+		im.mu.Unlock()
+		return false
+	}
+	im.removeTrashEntry(name)
+	im.mu.Unlock()
+
+	if current.PurgeLogs {
+		im.purgeLogs(name)
+	}
+	log.Printf("Purged instance %s from the trash", name)
+	im.recordAudit(actor, "purge", name, details)
+	return true
+}
+
+// removeTrashEntry forgets the trash entry of an instance and stops its purge timer
+// (caller must hold the lock)
+func (im *instanceManager) removeTrashEntry(name string) {
+	if timer, ok := im.trashTimers[name]; ok {
+		timer.Stop()
+		delete(im.trashTimers, name)
+	}
+	delete(im.trash, name)
+	if im.store != nil {
+		if err := im.store.Delete(storage.NamespaceTrash, name); err != nil {
+			log.Printf("Failed to delete trashed instance %s: %v", name, err)
+		}
+	}
+}
+
+// purgeLogs removes the log files of a deleted instance, unless an instance of the same name
+// was created meanwhile and writes to them
+func (im *instanceManager) purgeLogs(name string) {
+	im.mu.RLock()
+	_, exists := im.instances[name]
+	im.mu.RUnlock()
+	if exists {
+		return
+	}
+	if err := instance.NewInstanceLogger(name, im.instancesConfig.LogsDir).RemoveFiles(); err != nil {
+		log.Printf("Failed to purge the logs of instance %s: %v", name, err)
+	}
+}
+
+// saveTrashRecord persists a trash entry
+func (im *instanceManager) saveTrashRecord(rec *trashRecord) error {
+	if im.store == nil {
+		return nil // Persistence disabled
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := im.store.Put(storage.NamespaceTrash, rec.Name, data); err != nil {
+		return fmt.Errorf("failed to persist trashed instance %s: %w", rec.Name, err)
+	}
+	return nil
+}
+
+// scheduleTrashPurge replaces the timer purging a trash entry once it expired. An entry that
+// already expired, such as one restored after llamactl was down, is purged right away.
+// (caller must hold the lock)
+func (im *instanceManager) scheduleTrashPurge(rec *trashRecord) {
+	if timer, ok := im.trashTimers[rec.Name]; ok {
+		timer.Stop()
+	}
+	im.trashTimers[rec.Name] = time.AfterFunc(time.Until(rec.PurgeAt), func() {
+		im.purgeTrashed(rec.Name, rec, "", "trash retention expired")
+	})
+}
+
+// loadTrash restores the persisted trash entries and schedules their purge
+func (im *instanceManager) loadTrash() error {
+	if im.store == nil {
+		return nil
+	}
+	records, err := im.store.List(storage.NamespaceTrash)
+	if err != nil {
+		return fmt.Errorf("failed to list trashed instances: %w", err)
+	}
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	for name, data := range records {
+		var rec trashRecord
+		if err := json.Unmarshal(data, &rec); err != nil || rec.Options == nil {
+			log.Printf("Invalid trashed instance %s: %v", name, err)
+			continue
+		}
+		rec.Name = name
+		im.trash[name] = &rec
+		im.scheduleTrashPurge(&rec)
+	}
+	return nil
+}
+
+// stopTrashTimers stops the timers purging the trash, on shutdown
+func (im *instanceManager) stopTrashTimers() {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	for name, timer := range im.trashTimers {
+		timer.Stop()
+		delete(im.trashTimers, name)
+	}
+}
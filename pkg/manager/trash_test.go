@@ -0,0 +1,188 @@
+package manager_test
+
+import (
+	"errors"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	newTrashManager := func(t *testing.T, retention time.Duration) (manager.InstanceManager, storage.Store, config.InstancesConfig) {
+		t.Helper()
+		dir := t.TempDir()
+		cfg := config.InstancesConfig{
+			PortRange:            [2]int{8000, 9000},
+			InstancesDir:         filepath.Join(dir, "instances"),
+			LogsDir:              filepath.Join(dir, "logs"),
+			MaxInstances:         10,
+			MaxRunningInstances:  -1,
+			TimeoutCheckInterval: config.Duration(5 * time.Minute),
+			TrashRetention:       config.Duration(retention),
+		}
+		store := storage.NewFileStore(cfg.InstancesDir, dir)
+		mngr := manager.NewInstanceManagerWithStore(config.BackendConfig{}, cfg, store)
+		t.Cleanup(mngr.Shutdown)
+		return mngr, store, cfg
+	}
+	options := func(port int) *instance.CreateInstanceOptions {
+		return &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: port, CtxSize: 8192},
+		}
+	}
+	auditActions := func(t *testing.T, store storage.Store, target string) []string {
+		t.Helper()
+		records, err := store.ListAudit(0)
+		if err != nil {
+			t.Fatalf("ListAudit failed: %v", err)
+		}
+		var actions []string
+		for _, record := range records {
+			if record.Target == target {
+				actions = append(actions, record.Action)
+			}
+		}
+		return actions
+	}
+
+	t.Run("delete moves to the trash and restore brings back", func(t *testing.T) {
+		mngr, store, _ := newTrashManager(t, time.Hour)
+		if _, err := mngr.CreateInstance("tuned", options(8100)); err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+		if err := mngr.DeleteInstance("tuned"); err != nil {
+			t.Fatalf("DeleteInstance failed: %v", err)
+		}
+
+		if _, err := mngr.GetInstance("tuned"); err == nil {
+			t.Error("Expected the deleted instance to be excluded from the instances")
+		}
+		trashed := mngr.ListTrash()
+		if len(trashed) != 1 || trashed[0].Name != "tuned" || trashed[0].Options.LlamaServerOptions.CtxSize != 8192 {
+			t.Fatalf("Expected the instance in the trash with its options, got %+v", trashed)
+		}
+		if until := time.Until(trashed[0].PurgeAt); until <= 59*time.Minute || until > time.Hour {
+			t.Errorf("Expected the instance to be purged after the retention, at %v", trashed[0].PurgeAt)
+		}
+		if _, err := store.Get(storage.NamespaceTrash, "tuned"); err != nil {
+			t.Errorf("Expected the trash entry to be persisted: %v", err)
+		}
+
+		// The port was released, and taken meanwhile
+		blocker, err := mngr.CreateInstance("blocker", options(8100))
+		if err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+		if _, err := mngr.RestoreInstance("tuned"); err == nil || !strings.Contains(err.Error(), "port") {
+			t.Errorf("Expected the restore to conflict on the port, got %v", err)
+		}
+		if len(mngr.ListTrash()) != 1 {
+			t.Error("Expected a failed restore to keep the instance in the trash")
+		}
+		if err := mngr.DeleteInstanceWithOptions(blocker.Name, manager.DeleteOptions{Permanent: true}); err != nil {
+			t.Fatalf("DeleteInstanceWithOptions failed: %v", err)
+		}
+
+		inst, err := mngr.RestoreInstance("tuned")
+		if err != nil {
+			t.Fatalf("RestoreInstance failed: %v", err)
+		}
+		if inst.GetPort() != 8100 || inst.GetOptions().LlamaServerOptions.CtxSize != 8192 {
+			t.Errorf("Expected the instance restored with its options, got %+v", inst.GetOptions().LlamaServerOptions)
+		}
+		if len(mngr.ListTrash()) != 0 {
+			t.Error("Expected the restored instance to leave the trash")
+		}
+		if _, err := store.Get(storage.NamespaceTrash, "tuned"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("Expected the trash entry to be removed, got %v", err)
+		}
+		if _, err := mngr.RestoreInstance("tuned"); !errors.Is(err, manager.ErrNotInTrash) {
+			t.Errorf("Expected ErrNotInTrash, got %v", err)
+		}
+		if actions := strings.Join(auditActions(t, store, "tuned"), ","); actions != "create,delete,restore" {
+			t.Errorf("Expected the soft delete and the restore to be audited, got %s", actions)
+		}
+	})
+
+	t.Run("retention expiry purges with the logs", func(t *testing.T) {
+		mngr, store, cfg := newTrashManager(t, 200*time.Millisecond)
+		if _, err := mngr.CreateInstance("expiring", options(0)); err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+		os.MkdirAll(cfg.LogsDir, 0755)
+		logFile := filepath.Join(cfg.LogsDir, "expiring.log")
+		os.WriteFile(logFile, []byte("line\n"), 0644)
+
+		if err := mngr.DeleteInstanceWithOptions("expiring", manager.DeleteOptions{PurgeLogs: true}); err != nil {
+			t.Fatalf("DeleteInstanceWithOptions failed: %v", err)
+		}
+		if _, err := os.Stat(logFile); err != nil {
+			t.Errorf("Expected the logs to be kept while the instance is in the trash: %v", err)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for len(mngr.ListTrash()) > 0 && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		if len(mngr.ListTrash()) != 0 {
+			t.Fatal("Expected the instance to be purged once the retention ran out")
+		}
+		if _, err := os.Stat(logFile); !os.IsNotExist(err) {
+			t.Errorf("Expected the logs to be purged, got %v", err)
+		}
+		if actions := strings.Join(auditActions(t, store, "expiring"), ","); actions != "create,delete,purge" {
+			t.Errorf("Expected the purge to be audited, got %s", actions)
+		}
+	})
+
+	t.Run("permanent deletion skips the trash", func(t *testing.T) {
+		mngr, store, _ := newTrashManager(t, time.Hour)
+		if _, err := mngr.CreateInstance("gone", options(0)); err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+		if err := mngr.DeleteInstanceWithOptions("gone", manager.DeleteOptions{Permanent: true}); err != nil {
+			t.Fatalf("DeleteInstanceWithOptions failed: %v", err)
+		}
+		if len(mngr.ListTrash()) != 0 {
+			t.Error("Expected a permanent deletion to skip the trash")
+		}
+		if err := mngr.PurgeInstance("gone"); !errors.Is(err, manager.ErrNotInTrash) {
+			t.Errorf("Expected ErrNotInTrash, got %v", err)
+		}
+		if _, err := store.Get(storage.NamespaceTrash, "gone"); !errors.Is(err, storage.ErrNotFound) {
+			t.Errorf("Expected no trash entry, got %v", err)
+		}
+	})
+
+	t.Run("trash survives a restart", func(t *testing.T) {
+		mngr, store, cfg := newTrashManager(t, time.Hour)
+		if _, err := mngr.CreateInstance("kept", options(0)); err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+		if err := mngr.DeleteInstance("kept"); err != nil {
+			t.Fatalf("DeleteInstance failed: %v", err)
+		}
+		mngr.Shutdown()
+
+		restarted := manager.NewInstanceManagerWithStore(config.BackendConfig{}, cfg, store)
+		defer restarted.Shutdown()
+		if trashed := restarted.ListTrash(); len(trashed) != 1 || trashed[0].Name != "kept" {
+			t.Fatalf("Expected the trash to be restored, got %+v", trashed)
+		}
+		if err := restarted.PurgeInstance("kept"); err != nil {
+			t.Fatalf("PurgeInstance failed: %v", err)
+		}
+		if len(restarted.ListTrash()) != 0 {
+			t.Error("Expected the purged instance to leave the trash")
+		}
+	})
+}
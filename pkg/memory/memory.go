@@ -0,0 +1,292 @@
+// Package memory accounts for the bytes held by the in-memory buffers of instances, such as
+// the tails of their output and the events kept for replay. Buffers are rings registered
+// with an Accountant, which evicts their oldest entries once an instance, or all instances
+// together, go over their limit.
+package memory
+
+import (
+	"sync"
+)
+
+// EntryOverhead approximates the bytes an entry takes on top of its contents: the slice
+// element, the headers of its fields and allocator rounding
+const EntryOverhead = 64
+
+// Usage is the approximate bytes held by the buffers of an instance, or of all instances
+type Usage struct {
+	Bytes      int64            `json:"bytes"`
+	Subsystems map[string]int64 `json:"subsystems"` // Bytes per kind of buffer
+}
+
+// Snapshot is a point-in-time copy of the accounting
+type Snapshot struct {
+	InstanceLimit int64            `json:"instance_limit"` // 0 = unlimited
+	GlobalLimit   int64            `json:"global_limit"`   // 0 = unlimited
+	Total         Usage            `json:"total"`
+	Instances     map[string]Usage `json:"instances"`
+	// Entries evicted to keep usage within the limits, per kind of buffer
+	Evictions map[string]int64 `json:"evictions"`
+}
+
+// ring is the side of a Ring the accountant evicts from (accountant lock held)
+type ring interface {
+	owner() string
+	oldest() (uint64, bool) // Sequence number of the oldest entry
+	evictOldest() int64     // Removes the oldest entry, returning its size
+}
+
+// Accountant tracks the bytes held by the rings registered with it and enforces a limit
+// per instance and a global one, evicting the oldest entries first. All of its rings share
+// its lock. The zero value is ready to use and has no limits.
+type Accountant struct {
+	mu            sync.Mutex
+	instanceLimit int64
+	globalLimit   int64
+	seq           uint64 // Sequence number of the last entry added, across rings
+	rings         map[ring]struct{}
+	total         int64
+	usage         map[string]map[string]int64 // Bytes per instance, then per subsystem
+	evictions     map[string]int64
+}
+
+// NewAccountant creates an accountant holding the buffers of each instance to
+// instanceLimit bytes and those of all instances to globalLimit bytes, 0 being unlimited
+func NewAccountant(instanceLimit, globalLimit int64) *Accountant {
+	return &Accountant{instanceLimit: max(instanceLimit, 0), globalLimit: max(globalLimit, 0)}
+}
+
+// SetLimits changes the limits, evicting entries right away if usage is over the new ones
+func (a *Accountant) SetLimits(instanceLimit, globalLimit int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.instanceLimit, a.globalLimit = max(instanceLimit, 0), max(globalLimit, 0)
+	for instance := range a.usage {
+		a.enforce(instance)
+	}
+}
+
+// charge adds delta bytes to the usage of a subsystem of an instance (lock held)
+func (a *Accountant) charge(instance, subsystem string, delta int64) {
+	if a.usage == nil {
+		a.usage = map[string]map[string]int64{}
+	}
+	subsystems := a.usage[instance]
+	if subsystems == nil {
+		subsystems = map[string]int64{}
+		a.usage[instance] = subsystems
+	}
+	subsystems[subsystem] += delta
+	if subsystems[subsystem] == 0 {
+		delete(subsystems, subsystem)
+		if len(subsystems) == 0 {
+			delete(a.usage, instance)
+		}
+	}
+	a.total += delta
+}
+
+// instanceBytes returns the bytes held by the rings of an instance (lock held)
+func (a *Accountant) instanceBytes(instance string) int64 {
+	var bytes int64
+	for _, subsystemBytes := range a.usage[instance] {
+		bytes += subsystemBytes
+	}
+	return bytes
+}
+
+// enforce evicts the oldest entries of an instance until it is within the instance limit,
+// then the oldest entries of any instance until all of them are within the global limit
+// (lock held)
+func (a *Accountant) enforce(instance string) {
+	for a.instanceLimit > 0 && a.instanceBytes(instance) > a.instanceLimit {
+		if !a.evictOldest(func(r ring) bool { return r.owner() == instance }) {
+			break
+		}
+	}
+	for a.globalLimit > 0 && a.total > a.globalLimit {
+		if !a.evictOldest(func(ring) bool { return true }) {
+			break
+		}
+	}
+}
+
+// evictOldest removes the oldest entry of the rings matching, returning false if they are
+// all empty (lock held)
+func (a *Accountant) evictOldest(matching func(ring) bool) bool {
+	var victim ring
+	var victimSeq uint64
+	for r := range a.rings {
+		if !matching(r) {
+			continue
+		}
+		if seq, ok := r.oldest(); ok && (victim == nil || seq < victimSeq) {
+			victim, victimSeq = r, seq
+		}
+	}
+	if victim == nil {
+		return false
+	}
+	victim.evictOldest()
+	return true
+}
+
+// Snapshot returns the current accounting
+func (a *Accountant) Snapshot() Snapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	snapshot := Snapshot{
+		InstanceLimit: a.instanceLimit,
+		GlobalLimit:   a.globalLimit,
+		Total:         Usage{Bytes: a.total, Subsystems: map[string]int64{}},
+		Instances:     map[string]Usage{},
+		Evictions:     map[string]int64{},
+	}
+	for instance, subsystems := range a.usage {
+		usage := Usage{Subsystems: make(map[string]int64, len(subsystems))}
+		for subsystem, bytes := range subsystems {
+			usage.Bytes += bytes
+			usage.Subsystems[subsystem] = bytes
+			snapshot.Total.Subsystems[subsystem] += bytes
+		}
+		// Buffers not owned by an instance only count towards the total
+		if instance != "" {
+			snapshot.Instances[instance] = usage
+		}
+	}
+	for subsystem, evictions := range a.evictions {
+		snapshot.Evictions[subsystem] = evictions
+	}
+	return snapshot
+}
+
+// Ring keeps the last entries added to it, up to a number of entries, with the bytes they
+// hold charged to a subsystem of an instance. A nil Ring is empty and ignores additions.
+type Ring[T any] struct {
+	accountant *Accountant
+	instance   string
+	subsystem  string
+	size       int // Maximum number of entries, 0 = unlimited
+	entries    []ringEntry[T]
+	released   bool
+}
+
+type ringEntry[T any] struct {
+	value T
+	size  int64 // Including EntryOverhead
+	seq   uint64
+}
+
+// NewRing creates a ring keeping up to size entries (0 = unlimited), charged to a
+// subsystem of an instance. A nil accountant gives the ring an unlimited one of its own.
+// instance is empty for buffers that do not belong to an instance.
+func NewRing[T any](accountant *Accountant, instance, subsystem string, size int) *Ring[T] {
+	if accountant == nil {
+		accountant = &Accountant{}
+	}
+	r := &Ring[T]{accountant: accountant, instance: instance, subsystem: subsystem, size: max(size, 0)}
+	accountant.mu.Lock()
+	defer accountant.mu.Unlock()
+	if accountant.rings == nil {
+		accountant.rings = map[ring]struct{}{}
+	}
+	accountant.rings[r] = struct{}{}
+	return r
+}
+
+// Add appends an entry holding about size bytes, dropping the oldest one if the ring is
+// full, then evicts entries of the instance, or of any instance, over the limits
+func (r *Ring[T]) Add(value T, size int64) {
+	if r == nil {
+		return
+	}
+	a := r.accountant
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r.released {
+		return
+	}
+	if r.size > 0 && len(r.entries) >= r.size {
+		r.dropOldest()
+	}
+	a.seq++
+	entry := ringEntry[T]{value: value, size: max(size, 0) + EntryOverhead, seq: a.seq}
+	r.entries = append(r.entries, entry)
+	a.charge(r.instance, r.subsystem, entry.size)
+	a.enforce(r.instance)
+}
+
+// Snapshot returns the entries, oldest first
+func (r *Ring[T]) Snapshot() []T {
+	if r == nil {
+		return nil
+	}
+	r.accountant.mu.Lock()
+	defer r.accountant.mu.Unlock()
+	values := make([]T, len(r.entries))
+	for idx, entry := range r.entries {
+		values[idx] = entry.value
+	}
+	return values
+}
+
+// Len returns the number of entries
+func (r *Ring[T]) Len() int {
+	if r == nil {
+		return 0
+	}
+	r.accountant.mu.Lock()
+	defer r.accountant.mu.Unlock()
+	return len(r.entries)
+}
+
+// Release empties the ring and unregisters it from its accountant. Entries added after
+// are ignored.
+func (r *Ring[T]) Release() {
+	if r == nil {
+		return
+	}
+	a := r.accountant
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if r.released {
+		return
+	}
+	for len(r.entries) > 0 {
+		r.dropOldest()
+	}
+	r.released = true
+	delete(a.rings, r)
+}
+
+// dropOldest removes the oldest entry without counting it as an eviction (lock held)
+func (r *Ring[T]) dropOldest() int64 {
+	size := r.entries[0].size
+	var zero ringEntry[T]
+	r.entries[0] = zero // Let the value be collected
+	r.entries = r.entries[1:]
+	if len(r.entries) == 0 {
+		r.entries = nil
+	}
+	r.accountant.charge(r.instance, r.subsystem, -size)
+	return size
+}
+
+func (r *Ring[T]) owner() string {
+	return r.instance
+}
+
+func (r *Ring[T]) oldest() (uint64, bool) {
+	if len(r.entries) == 0 {
+		return 0, false
+	}
+	return r.entries[0].seq, true
+}
+
+func (r *Ring[T]) evictOldest() int64 {
+	a := r.accountant
+	if a.evictions == nil {
+		a.evictions = map[string]int64{}
+	}
+	a.evictions[r.subsystem]++
+	return r.dropOldest()
+}
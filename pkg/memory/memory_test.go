@@ -0,0 +1,165 @@
+package memory_test
+
+import (
+	"fmt"
+	"llamactl/pkg/memory"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const lineLength = 1000
+
+// entrySize is the bytes charged for a line of lineLength
+const entrySize = lineLength + memory.EntryOverhead
+
+func TestRing_InstanceLimit(t *testing.T) {
+	accountant := memory.NewAccountant(100*entrySize, 0)
+	stderr := memory.NewRing[string](accountant, "chatty", "stderr_tail", 0)
+	output := memory.NewRing[string](accountant, "chatty", "output_tail", 0)
+	quiet := memory.NewRing[string](accountant, "quiet", "output_tail", 0)
+	quiet.Add("ready", 5)
+
+	line := strings.Repeat("x", lineLength)
+	for idx := range 100000 {
+		stderr.Add(fmt.Sprintf("%06d", idx)+line[6:], lineLength)
+		output.Add(line, lineLength)
+
+		if bytes := accountant.Snapshot().Instances["chatty"].Bytes; bytes > 100*entrySize {
+			t.Fatalf("Expected the instance to stay within its limit, it holds %d bytes after %d lines", bytes, idx)
+		}
+	}
+
+	snapshot := accountant.Snapshot()
+	chatty := snapshot.Instances["chatty"]
+	if chatty.Bytes != 100*entrySize || chatty.Subsystems["stderr_tail"] != 50*entrySize || chatty.Subsystems["output_tail"] != 50*entrySize {
+		t.Errorf("Expected the limit to be shared by both rings, got %+v", chatty)
+	}
+	if snapshot.Evictions["stderr_tail"] != 100000-50 || snapshot.Evictions["output_tail"] != 100000-50 {
+		t.Errorf("Expected every evicted line to be counted, got %v", snapshot.Evictions)
+	}
+
+	// Oldest first: the ring keeps the last lines
+	lines := stderr.Snapshot()
+	if len(lines) != 50 || !strings.HasPrefix(lines[0], "099950") || !strings.HasPrefix(lines[49], "099999") {
+		t.Errorf("Expected the last 50 lines, got %d lines from %.6s to %.6s", len(lines), lines[0], lines[len(lines)-1])
+	}
+
+	// Other instances keep their entries
+	if quiet.Len() != 1 || snapshot.Instances["quiet"].Bytes != 5+memory.EntryOverhead {
+		t.Errorf("Expected the quiet instance to be left alone, got %d entries and %+v", quiet.Len(), snapshot.Instances["quiet"])
+	}
+	if snapshot.Total.Bytes != chatty.Bytes+5+memory.EntryOverhead {
+		t.Errorf("Expected the total to add up the instances, got %+v", snapshot.Total)
+	}
+}
+
+func TestRing_GlobalLimit(t *testing.T) {
+	accountant := memory.NewAccountant(0, 30*entrySize)
+	line := strings.Repeat("x", lineLength)
+
+	first := memory.NewRing[string](accountant, "first", "output_tail", 0)
+	for range 20 {
+		first.Add(line, lineLength)
+	}
+
+	// A chatty instance evicts the oldest entries of any instance, its own once they are the oldest
+	chatty := memory.NewRing[string](accountant, "chatty", "output_tail", 0)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10000 {
+				chatty.Add(line, lineLength)
+			}
+		}()
+	}
+	wg.Wait()
+
+	snapshot := accountant.Snapshot()
+	if snapshot.Total.Bytes != 30*entrySize {
+		t.Errorf("Expected the global limit to hold, got %d bytes", snapshot.Total.Bytes)
+	}
+	if first.Len() != 0 || chatty.Len() != 30 {
+		t.Errorf("Expected the oldest entries to be evicted first, got %d and %d entries", first.Len(), chatty.Len())
+	}
+	if _, ok := snapshot.Instances["first"]; ok {
+		t.Errorf("Expected an instance without entries not to be listed, got %+v", snapshot.Instances)
+	}
+	if snapshot.Evictions["output_tail"] != 40000+20-30 {
+		t.Errorf("Expected %d evictions, got %v", 40000+20-30, snapshot.Evictions)
+	}
+}
+
+func TestRing_Size(t *testing.T) {
+	accountant := memory.NewAccountant(0, 0)
+	ring := memory.NewRing[int](accountant, "instance", "events", 3)
+	for idx := range 10 {
+		ring.Add(idx, 10)
+	}
+	if got := ring.Snapshot(); fmt.Sprint(got) != "[7 8 9]" {
+		t.Errorf("Expected the last 3 entries, got %v", got)
+	}
+
+	// Dropping entries of a full ring is not an eviction
+	snapshot := accountant.Snapshot()
+	if snapshot.Total.Bytes != 3*(10+memory.EntryOverhead) || len(snapshot.Evictions) != 0 {
+		t.Errorf("Expected 3 entries charged and no eviction, got %+v", snapshot)
+	}
+}
+
+func TestRing_Release(t *testing.T) {
+	accountant := memory.NewAccountant(0, 0)
+	ring := memory.NewRing[string](accountant, "instance", "output_tail", 0)
+	ring.Add("line", 4)
+	ring.Release()
+	ring.Add("after release", 13)
+
+	if ring.Len() != 0 {
+		t.Errorf("Expected a released ring to stay empty, got %d entries", ring.Len())
+	}
+	if snapshot := accountant.Snapshot(); snapshot.Total.Bytes != 0 || len(snapshot.Instances) != 0 {
+		t.Errorf("Expected nothing charged after the release, got %+v", snapshot)
+	}
+
+	// Nil rings are empty, and rings without an accountant are unlimited
+	var none *memory.Ring[string]
+	none.Add("line", 4)
+	none.Release()
+	if none.Len() != 0 || none.Snapshot() != nil {
+		t.Error("Expected a nil ring to be empty")
+	}
+	unlimited := memory.NewRing[string](nil, "instance", "output_tail", 0)
+	for range 1000 {
+		unlimited.Add("line", 4)
+	}
+	if unlimited.Len() != 1000 {
+		t.Errorf("Expected 1000 entries without an accountant, got %d", unlimited.Len())
+	}
+}
+
+func TestAccountant_SetLimits(t *testing.T) {
+	accountant := memory.NewAccountant(0, 0)
+	ring := memory.NewRing[string](accountant, "instance", "output_tail", 0)
+	line := strings.Repeat("x", lineLength)
+	for range 100 {
+		ring.Add(line, lineLength)
+	}
+
+	// Lowering a limit shrinks the rings right away
+	accountant.SetLimits(10*entrySize, 0)
+	snapshot := accountant.Snapshot()
+	if ring.Len() != 10 || snapshot.Instances["instance"].Bytes != 10*entrySize || snapshot.Evictions["output_tail"] != 90 {
+		t.Errorf("Expected the instance to shrink to 10 entries, got %d and %+v", ring.Len(), snapshot)
+	}
+	if snapshot.InstanceLimit != 10*entrySize || snapshot.GlobalLimit != 0 {
+		t.Errorf("Expected the new limits to be reported, got %d and %d", snapshot.InstanceLimit, snapshot.GlobalLimit)
+	}
+
+	// An entry over the limit on its own is not kept
+	ring.Add(strings.Repeat("x", 20*lineLength), 20*lineLength)
+	if ring.Len() != 0 || accountant.Snapshot().Total.Bytes != 0 {
+		t.Errorf("Expected the oversized entry to be evicted, got %d entries", ring.Len())
+	}
+}
@@ -0,0 +1,275 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// diskSpaceMargin is kept free on top of the remaining download size
+const diskSpaceMargin = 64 * 1024 * 1024
+
+var checksumPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ProgressFunc receives the number of bytes downloaded so far and the total size
+type ProgressFunc func(downloaded, total int64)
+
+// Fetcher downloads models from a control plane into a local cache keyed by checksum,
+// so instances sharing a model download it once. Interrupted downloads are resumed with
+// range requests, and a download is only moved into the cache once its checksum matches.
+type Fetcher struct {
+	baseURL  string
+	apiKey   string
+	cacheDir string
+	client   *http.Client
+
+	// freeSpace reports the available bytes on the filesystem holding a directory
+	freeSpace func(dir string) (int64, error)
+
+	// Concurrent fetches of the same model wait for a single download
+	mu       sync.Mutex
+	inFlight map[string]*sync.Mutex
+}
+
+// NewFetcher creates a fetcher for the control plane at baseURL, authenticating with apiKey
+func NewFetcher(baseURL, apiKey, cacheDir string) *Fetcher {
+	return &Fetcher{
+		baseURL:   baseURL,
+		apiKey:    apiKey,
+		cacheDir:  cacheDir,
+		client:    &http.Client{},
+		freeSpace: freeDiskSpace,
+		inFlight:  make(map[string]*sync.Mutex),
+	}
+}
+
+// Fetch returns the local path of a model registered on the control plane, downloading it
+// first if it is not cached yet.
+func (f *Fetcher) Fetch(ctx context.Context, name string, progress ProgressFunc) (string, error) {
+	info, err := f.info(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	lock := f.lockFor(info.SHA256)
+	lock.Lock()
+	defer lock.Unlock()
+
+	dir := filepath.Join(f.cacheDir, info.SHA256)
+	path := filepath.Join(dir, info.Filename)
+	if stat, err := os.Stat(path); err == nil && stat.Size() == info.Size {
+		return path, nil // Verified when it was downloaded
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create model cache directory: %w", err)
+	}
+	if err := f.download(ctx, info, path, progress); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (f *Fetcher) lockFor(sum string) *sync.Mutex {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lock, ok := f.inFlight[sum]
+	if !ok {
+		lock = &sync.Mutex{}
+		f.inFlight[sum] = lock
+	}
+	return lock
+}
+
+// info retrieves the size and checksum of a model from the control plane
+func (f *Fetcher) info(ctx context.Context, name string) (*ModelInfo, error) {
+	resp, err := f.get(ctx, "/api/v1/models/"+url.PathEscape(name), 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to look up model %s: control plane returned status %d", name, resp.StatusCode)
+	}
+
+	var info ModelInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode info of model %s: %w", name, err)
+	}
+	if !checksumPattern.MatchString(info.SHA256) {
+		return nil, fmt.Errorf("control plane returned an invalid checksum for model %s", name)
+	}
+	if info.Filename == "" || info.Filename != filepath.Base(info.Filename) || info.Filename == "." || info.Filename == ".." {
+		return nil, fmt.Errorf("control plane returned an invalid file name for model %s", name)
+	}
+	return &info, nil
+}
+
+// download fetches a model into path, resuming from a partial download if one is left
+func (f *Fetcher) download(ctx context.Context, info *ModelInfo, path string, progress ProgressFunc) error {
+	partPath := path + ".part"
+
+	var offset int64
+	if stat, err := os.Stat(partPath); err == nil && stat.Size() <= info.Size {
+		offset = stat.Size()
+	}
+
+	if err := f.checkDiskSpace(filepath.Dir(path), info.Size-offset); err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if offset > 0 {
+		if err := hashFile(h, partPath); err != nil {
+			offset = 0
+			h.Reset()
+		}
+	}
+	if offset == info.Size {
+		// The download completed before it could be moved into the cache, no range is left
+		// to ask for; a partial file that does not match is downloaded again
+		if hex.EncodeToString(h.Sum(nil)) == info.SHA256 {
+			return moveIntoCache(info, partPath, path)
+		}
+		os.Remove(partPath)
+		offset = 0
+		h.Reset()
+	}
+
+	resp, err := f.get(ctx, "/api/v1/models/"+url.PathEscape(info.Name)+"/download", offset)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// The server sent the whole file, start over
+		offset = 0
+		h.Reset()
+		flags |= os.O_TRUNC
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The partial file no longer fits the model, the next attempt starts over
+		os.Remove(partPath)
+		return fmt.Errorf("failed to resume download of model %s: control plane returned status %d", info.Name, resp.StatusCode)
+	default:
+		return fmt.Errorf("failed to download model %s: control plane returned status %d", info.Name, resp.StatusCode)
+	}
+	if sum := resp.Header.Get(ChecksumHeader); sum != "" && sum != info.SHA256 {
+		return fmt.Errorf("model %s changed on the control plane during download", info.Name)
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open partial download: %w", err)
+	}
+
+	w := &progressWriter{total: info.Size, downloaded: offset, progress: progress}
+	_, copyErr := io.Copy(io.MultiWriter(file, h, w), io.LimitReader(resp.Body, info.Size-offset+1))
+	syncErr := file.Sync()
+	closeErr := file.Close()
+	if copyErr != nil {
+		return fmt.Errorf("failed to download model %s: %w", info.Name, copyErr)
+	}
+	if syncErr != nil || closeErr != nil {
+		return fmt.Errorf("failed to write model %s: %v", info.Name, firstErr(syncErr, closeErr))
+	}
+
+	if w.downloaded != info.Size {
+		os.Remove(partPath)
+		return fmt.Errorf("download of model %s has size %d, expected %d", info.Name, w.downloaded, info.Size)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != info.SHA256 {
+		os.Remove(partPath)
+		return fmt.Errorf("checksum mismatch for model %s: got %s, expected %s", info.Name, sum, info.SHA256)
+	}
+
+	return moveIntoCache(info, partPath, path)
+}
+
+// moveIntoCache renames a verified download to its place in the cache
+func moveIntoCache(info *ModelInfo, partPath, path string) error {
+	if err := os.Rename(partPath, path); err != nil {
+		return fmt.Errorf("failed to move model %s into the cache: %w", info.Name, err)
+	}
+	return nil
+}
+
+func (f *Fetcher) get(ctx context.Context, path string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if f.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+f.apiKey)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach control plane: %w", err)
+	}
+	return resp, nil
+}
+
+// checkDiskSpace refuses a download that would not fit, so it cannot fill the disk
+func (f *Fetcher) checkDiskSpace(dir string, remaining int64) error {
+	free, err := f.freeSpace(dir)
+	if err != nil {
+		return nil // Free space is unknown on this platform, let the download try
+	}
+	if free < remaining+diskSpaceMargin {
+		return fmt.Errorf("insufficient disk space in %s: %d bytes free, %d bytes needed", dir, free, remaining+diskSpaceMargin)
+	}
+	return nil
+}
+
+func hashFile(h hash.Hash, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(h, file)
+	return err
+}
+
+func firstErr(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// progressWriter reports download progress
+type progressWriter struct {
+	total      int64
+	downloaded int64
+	progress   ProgressFunc
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.downloaded += int64(len(p))
+	if w.progress != nil {
+		w.progress(w.downloaded, w.total)
+	}
+	return len(p), nil
+}
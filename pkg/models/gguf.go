@@ -0,0 +1,316 @@
+package models
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"strings"
+)
+
+// Bounds keeping a corrupt or hostile file from making the parser read past the header
+// or allocate without limit
+const (
+	maxHeaderSize  = 64 * 1024 * 1024
+	maxStringLen   = 16 * 1024 * 1024
+	maxArrayLen    = 1 << 24
+	maxArrayDepth  = 4
+	maxKVCount     = 1 << 16
+	maxTensorCount = 1 << 20
+	maxTensorDims  = 8
+)
+
+const ggufMagic = "GGUF"
+
+// GGUF metadata value types
+const (
+	ggufUint8   = 0
+	ggufInt8    = 1
+	ggufUint16  = 2
+	ggufInt16   = 3
+	ggufUint32  = 4
+	ggufInt32   = 5
+	ggufFloat32 = 6
+	ggufBool    = 7
+	ggufString  = 8
+	ggufArray   = 9
+	ggufUint64  = 10
+	ggufInt64   = 11
+	ggufFloat64 = 12
+)
+
+// fileTypes names the values of general.file_type, from llama.cpp's llama_ftype
+var fileTypes = map[uint64]string{
+	0: "F32", 1: "F16", 2: "Q4_0", 3: "Q4_1", 7: "Q8_0", 8: "Q5_0", 9: "Q5_1",
+	10: "Q2_K", 11: "Q3_K_S", 12: "Q3_K_M", 13: "Q3_K_L", 14: "Q4_K_S", 15: "Q4_K_M",
+	16: "Q5_K_S", 17: "Q5_K_M", 18: "Q6_K", 19: "IQ2_XXS", 20: "IQ2_XS", 21: "Q2_K_S",
+	22: "IQ3_XS", 23: "IQ3_XXS", 24: "IQ1_S", 25: "IQ4_NL", 26: "IQ3_S", 27: "IQ3_M",
+	28: "IQ2_S", 29: "IQ2_M", 30: "IQ4_XS", 31: "IQ1_M", 32: "BF16", 36: "TQ1_0", 37: "TQ2_0",
+}
+
+// GGUFMetadata is the model information read from the header of a GGUF file
+type GGUFMetadata struct {
+	Architecture    string `json:"architecture,omitempty"`
+	Name            string `json:"name,omitempty"`
+	ParameterCount  uint64 `json:"parameter_count,omitempty"`
+	Quantization    string `json:"quantization,omitempty"`
+	ContextLength   uint64 `json:"context_length,omitempty"`
+	HasChatTemplate bool   `json:"has_chat_template"`
+}
+
+// ReadGGUFMetadata parses the header of the GGUF file at path. Only the metadata and
+// tensor descriptions are read, never the tensor data.
+func ReadGGUFMetadata(path string) (*GGUFMetadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return parseGGUF(io.LimitReader(f, min(stat.Size(), maxHeaderSize)))
+}
+
+// ggufReader decodes little-endian GGUF primitives, turning a short read into an error
+type ggufReader struct {
+	r *bufio.Reader
+}
+
+func (g *ggufReader) read(data any) error {
+	if err := binary.Read(g.r, binary.LittleEndian, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+func (g *ggufReader) uint32() (uint32, error) {
+	var v uint32
+	err := g.read(&v)
+	return v, err
+}
+
+func (g *ggufReader) uint64() (uint64, error) {
+	var v uint64
+	err := g.read(&v)
+	return v, err
+}
+
+func (g *ggufReader) skip(n uint64) error {
+	if n > maxHeaderSize {
+		return io.ErrUnexpectedEOF
+	}
+	if _, err := g.r.Discard(int(n)); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (g *ggufReader) stringLen() (uint64, error) {
+	n, err := g.uint64()
+	if err != nil {
+		return 0, err
+	}
+	if n > maxStringLen {
+		return 0, fmt.Errorf("string of %d bytes exceeds the header limit", n)
+	}
+	return n, nil
+}
+
+func (g *ggufReader) string() (string, error) {
+	n, err := g.stringLen()
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(g.r, buf); err != nil {
+		return "", io.ErrUnexpectedEOF
+	}
+	return string(buf), nil
+}
+
+// value reads a metadata value. Scalars and strings are returned, arrays are skipped.
+func (g *ggufReader) value(typ uint32, depth int) (any, error) {
+	switch typ {
+	case ggufUint8, ggufInt8, ggufBool:
+		var v uint8
+		err := g.read(&v)
+		return uint64(v), err
+	case ggufUint16, ggufInt16:
+		var v uint16
+		err := g.read(&v)
+		return uint64(v), err
+	case ggufUint32, ggufInt32, ggufFloat32:
+		v, err := g.uint32()
+		return uint64(v), err
+	case ggufUint64, ggufInt64, ggufFloat64:
+		return g.uint64()
+	case ggufString:
+		return g.string()
+	case ggufArray:
+		if depth >= maxArrayDepth {
+			return nil, fmt.Errorf("arrays nested deeper than %d levels", maxArrayDepth)
+		}
+		elemType, err := g.uint32()
+		if err != nil {
+			return nil, err
+		}
+		count, err := g.uint64()
+		if err != nil {
+			return nil, err
+		}
+		if count > maxArrayLen {
+			return nil, fmt.Errorf("array of %d elements exceeds the header limit", count)
+		}
+		if size := fixedSize(elemType); size > 0 {
+			return nil, g.skip(count * size)
+		}
+		for range count {
+			if elemType == ggufString {
+				n, err := g.stringLen()
+				if err != nil {
+					return nil, err
+				}
+				if err := g.skip(n); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if _, err := g.value(elemType, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown metadata value type %d", typ)
+	}
+}
+
+func fixedSize(typ uint32) uint64 {
+	switch typ {
+	case ggufUint8, ggufInt8, ggufBool:
+		return 1
+	case ggufUint16, ggufInt16:
+		return 2
+	case ggufUint32, ggufInt32, ggufFloat32:
+		return 4
+	case ggufUint64, ggufInt64, ggufFloat64:
+		return 8
+	}
+	return 0
+}
+
+func parseGGUF(r io.Reader) (*GGUFMetadata, error) {
+	g := &ggufReader{r: bufio.NewReader(r)}
+
+	magic := make([]byte, len(ggufMagic))
+	if _, err := io.ReadFull(g.r, magic); err != nil || string(magic) != ggufMagic {
+		return nil, fmt.Errorf("not a GGUF file")
+	}
+	version, err := g.uint32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GGUF version: %w", err)
+	}
+	if version < 2 || version > 3 {
+		return nil, fmt.Errorf("unsupported GGUF version %d", version)
+	}
+
+	tensorCount, err := g.uint64()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tensor count: %w", err)
+	}
+	kvCount, err := g.uint64()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata count: %w", err)
+	}
+	if tensorCount > maxTensorCount || kvCount > maxKVCount {
+		return nil, fmt.Errorf("header declares %d tensors and %d metadata entries, exceeding the limits", tensorCount, kvCount)
+	}
+
+	meta := &GGUFMetadata{}
+	contextLengths := make(map[string]uint64)
+	for range kvCount {
+		key, err := g.string()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read metadata key: %w", err)
+		}
+		typ, err := g.uint32()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read type of %s: %w", key, err)
+		}
+		value, err := g.value(typ, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value of %s: %w", key, err)
+		}
+
+		switch {
+		case key == "general.architecture":
+			meta.Architecture, _ = value.(string)
+		case key == "general.name":
+			meta.Name, _ = value.(string)
+		case key == "general.file_type":
+			if fileType, ok := value.(uint64); ok {
+				if name, known := fileTypes[fileType]; known {
+					meta.Quantization = name
+				} else {
+					meta.Quantization = fmt.Sprintf("file_type_%d", fileType)
+				}
+			}
+		case key == "tokenizer.chat_template":
+			meta.HasChatTemplate = true
+		case strings.HasSuffix(key, ".context_length"):
+			if length, ok := value.(uint64); ok {
+				contextLengths[strings.TrimSuffix(key, ".context_length")] = length
+			}
+		}
+	}
+	meta.ContextLength = contextLengths[meta.Architecture]
+
+	for range tensorCount {
+		name, err := g.stringLen()
+		if err == nil {
+			err = g.skip(name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tensor name: %w", err)
+		}
+		dims, err := g.uint32()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tensor dimensions: %w", err)
+		}
+		if dims > maxTensorDims {
+			return nil, fmt.Errorf("tensor with %d dimensions exceeds the limit", dims)
+		}
+		elements := uint64(1)
+		for range dims {
+			dim, err := g.uint64()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read tensor dimensions: %w", err)
+			}
+			hi, lo := bits.Mul64(elements, dim)
+			if hi != 0 {
+				return nil, fmt.Errorf("tensor element count overflows")
+			}
+			elements = lo
+		}
+		// Tensor type and data offset
+		if err := g.skip(4 + 8); err != nil {
+			return nil, fmt.Errorf("failed to read tensor info: %w", err)
+		}
+		if meta.ParameterCount > math.MaxUint64-elements {
+			return nil, fmt.Errorf("parameter count overflows")
+		}
+		meta.ParameterCount += elements
+	}
+
+	return meta, nil
+}
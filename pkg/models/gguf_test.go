@@ -0,0 +1,198 @@
+package models_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"llamactl/pkg/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ggufBuilder writes GGUF v3 headers
+type ggufBuilder struct {
+	kvs     bytes.Buffer
+	kvCount uint64
+	tensors bytes.Buffer
+	tensorN uint64
+}
+
+func (b *ggufBuilder) str(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, uint64(len(s)))
+	buf.WriteString(s)
+}
+
+func (b *ggufBuilder) kvString(key, value string) *ggufBuilder {
+	b.str(&b.kvs, key)
+	binary.Write(&b.kvs, binary.LittleEndian, uint32(8))
+	b.str(&b.kvs, value)
+	b.kvCount++
+	return b
+}
+
+func (b *ggufBuilder) kvUint32(key string, value uint32) *ggufBuilder {
+	b.str(&b.kvs, key)
+	binary.Write(&b.kvs, binary.LittleEndian, uint32(4))
+	binary.Write(&b.kvs, binary.LittleEndian, value)
+	b.kvCount++
+	return b
+}
+
+func (b *ggufBuilder) kvStrings(key string, values ...string) *ggufBuilder {
+	b.str(&b.kvs, key)
+	binary.Write(&b.kvs, binary.LittleEndian, uint32(9))
+	binary.Write(&b.kvs, binary.LittleEndian, uint32(8))
+	binary.Write(&b.kvs, binary.LittleEndian, uint64(len(values)))
+	for _, v := range values {
+		b.str(&b.kvs, v)
+	}
+	b.kvCount++
+	return b
+}
+
+func (b *ggufBuilder) tensor(name string, dims ...uint64) *ggufBuilder {
+	b.str(&b.tensors, name)
+	binary.Write(&b.tensors, binary.LittleEndian, uint32(len(dims)))
+	for _, d := range dims {
+		binary.Write(&b.tensors, binary.LittleEndian, d)
+	}
+	binary.Write(&b.tensors, binary.LittleEndian, uint32(0)) // type
+	binary.Write(&b.tensors, binary.LittleEndian, uint64(0)) // offset
+	b.tensorN++
+	return b
+}
+
+func (b *ggufBuilder) bytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("GGUF")
+	binary.Write(&buf, binary.LittleEndian, uint32(3))
+	binary.Write(&buf, binary.LittleEndian, b.tensorN)
+	binary.Write(&buf, binary.LittleEndian, b.kvCount)
+	buf.Write(b.kvs.Bytes())
+	buf.Write(b.tensors.Bytes())
+	return buf.Bytes()
+}
+
+func testGGUF() []byte {
+	b := &ggufBuilder{}
+	return b.kvString("general.architecture", "llama").
+		kvString("general.name", "Tiny Llama").
+		kvUint32("general.file_type", 15).
+		kvUint32("llama.context_length", 4096).
+		kvUint32("qwen2.context_length", 32768).
+		kvStrings("tokenizer.ggml.tokens", "<s>", "</s>", "hello").
+		kvString("tokenizer.chat_template", "{{ messages }}").
+		tensor("token_embd.weight", 64, 32).
+		tensor("output_norm.weight", 64).
+		bytes()
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadGGUFMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	// Tensor data after the header is never read
+	writeFile(t, path, append(testGGUF(), make([]byte, 4096)...))
+
+	meta, err := models.ReadGGUFMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadGGUFMetadata failed: %v", err)
+	}
+
+	expected := models.GGUFMetadata{
+		Architecture:    "llama",
+		Name:            "Tiny Llama",
+		ParameterCount:  64*32 + 64,
+		Quantization:    "Q4_K_M",
+		ContextLength:   4096,
+		HasChatTemplate: true,
+	}
+	if *meta != expected {
+		t.Errorf("expected %+v, got %+v", expected, *meta)
+	}
+}
+
+func TestReadGGUFMetadata_Corrupt(t *testing.T) {
+	valid := testGGUF()
+
+	hugeString := &ggufBuilder{}
+	hugeString.str(&hugeString.kvs, "general.name")
+	binary.Write(&hugeString.kvs, binary.LittleEndian, uint32(8))
+	binary.Write(&hugeString.kvs, binary.LittleEndian, uint64(1<<62))
+	hugeString.kvCount++
+
+	tests := map[string][]byte{
+		"empty":          {},
+		"not gguf":       []byte("PK\x03\x04 definitely a zip file"),
+		"old version":    append([]byte("GGUF\x01\x00\x00\x00"), make([]byte, 16)...),
+		"huge string":    hugeString.bytes(),
+		"huge dimension": (&ggufBuilder{}).tensor("t", 1<<40, 1<<40).bytes(),
+		"too many dims":  (&ggufBuilder{}).tensor("t", 1, 1, 1, 1, 1, 1, 1, 1, 1).bytes(),
+	}
+	// Headers cut off anywhere are rejected
+	for _, n := range []int{5, 12, 24, 30, len(valid) / 2, len(valid) - 1} {
+		tests[fmt.Sprintf("truncated to %d bytes", n)] = valid[:n]
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "corrupt.gguf")
+			writeFile(t, path, data)
+			if meta, err := models.ReadGGUFMetadata(path); err == nil {
+				t.Errorf("expected an error, got %+v", meta)
+			}
+		})
+	}
+}
+
+func TestIndexer_Rescan(t *testing.T) {
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "model.gguf")
+	writeFile(t, modelPath, testGGUF())
+	writeFile(t, filepath.Join(dir, "sub", "broken.GGUF"), []byte("garbage"))
+	writeFile(t, filepath.Join(dir, "README.md"), []byte("# models"))
+
+	indexer := models.NewIndexer([]string{dir})
+	indexed := indexer.Rescan()
+	if len(indexed) != 2 {
+		t.Fatalf("expected 2 indexed files, got %+v", indexed)
+	}
+	if indexed[0].Path != modelPath || indexed[0].Architecture != "llama" || indexed[0].Error != "" {
+		t.Errorf("unexpected entry for the valid model: %+v", indexed[0])
+	}
+	if indexed[1].Error == "" {
+		t.Errorf("expected a parse error for the broken file, got %+v", indexed[1])
+	}
+
+	// A file whose size and modification time are unchanged is not parsed again
+	stat, _ := os.Stat(modelPath)
+	replaced := bytes.Replace(testGGUF(), []byte("llama.context"), []byte("other.context"), 1)
+	writeFile(t, modelPath, replaced)
+	os.Chtimes(modelPath, stat.ModTime(), stat.ModTime())
+	if got := indexer.Rescan()[0]; got.ContextLength != 4096 {
+		t.Errorf("expected the cached entry, got context length %d", got.ContextLength)
+	}
+
+	// A changed file is parsed again
+	later := stat.ModTime().Add(time.Minute)
+	os.Chtimes(modelPath, later, later)
+	if got := indexer.Rescan()[0]; got.ContextLength != 0 {
+		t.Errorf("expected the changed file to be parsed again, got context length %d", got.ContextLength)
+	}
+
+	// Removed files drop out of the index
+	os.Remove(modelPath)
+	if got := indexer.Rescan(); len(got) != 1 {
+		t.Errorf("expected the removed file to be dropped, got %+v", got)
+	}
+}
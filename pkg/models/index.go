@@ -0,0 +1,149 @@
+package models
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IndexedModel is a GGUF file found in a model directory. Error is set instead of the
+// metadata when the header could not be parsed.
+type IndexedModel struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	GGUFMetadata
+	Error string `json:"error,omitempty"`
+}
+
+// Indexer keeps an index of the GGUF files in a set of directories. Parsed headers are
+// cached by path and only read again when the size or modification time of a file changes.
+type Indexer struct {
+	dirs []string
+
+	scanMu  sync.Mutex // Serializes scans
+	mu      sync.RWMutex
+	entries map[string]IndexedModel
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewIndexer creates an indexer of the GGUF files under dirs
+func NewIndexer(dirs []string) *Indexer {
+	absDirs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		absDirs = append(absDirs, dir)
+	}
+	return &Indexer{
+		dirs:    absDirs,
+		entries: make(map[string]IndexedModel),
+	}
+}
+
+// Start indexes the directories in the background, then rescans them every interval.
+// An interval of zero disables periodic rescans.
+func (x *Indexer) Start(interval time.Duration) {
+	x.stop = make(chan struct{})
+	x.done = make(chan struct{})
+
+	go func() {
+		defer close(x.done)
+		x.Rescan()
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				x.Rescan()
+			case <-x.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends background rescans and waits for a running scan to finish
+func (x *Indexer) Stop() {
+	if x.stop == nil {
+		return
+	}
+	close(x.stop)
+	<-x.done
+	x.stop = nil
+}
+
+// Rescan walks the directories, parsing new and changed files, and returns the index
+func (x *Indexer) Rescan() []IndexedModel {
+	x.scanMu.Lock()
+	defer x.scanMu.Unlock()
+
+	x.mu.RLock()
+	previous := x.entries
+	x.mu.RUnlock()
+
+	entries := make(map[string]IndexedModel, len(previous))
+	for _, dir := range x.dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				log.Printf("Failed to scan model directory %s: %v", path, err)
+				return nil
+			}
+			if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".gguf") {
+				return nil
+			}
+			// Stat follows symlinks to model files kept elsewhere
+			stat, err := os.Stat(path)
+			if err != nil || stat.IsDir() {
+				return nil
+			}
+
+			if cached, ok := previous[path]; ok && cached.Size == stat.Size() && cached.ModTime.Equal(stat.ModTime()) {
+				entries[path] = cached
+				return nil
+			}
+
+			entry := IndexedModel{Path: path, Size: stat.Size(), ModTime: stat.ModTime()}
+			if meta, err := ReadGGUFMetadata(path); err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.GGUFMetadata = *meta
+			}
+			entries[path] = entry
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to scan model directory %s: %v", dir, err)
+		}
+	}
+
+	x.mu.Lock()
+	x.entries = entries
+	x.mu.Unlock()
+	return x.List()
+}
+
+// List returns the indexed models sorted by path
+func (x *Indexer) List() []IndexedModel {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	list := make([]IndexedModel, 0, len(x.entries))
+	for _, entry := range x.entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
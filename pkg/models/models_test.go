@@ -0,0 +1,290 @@
+package models_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// controlPlane serves registered models the way the llamactl API does
+type controlPlane struct {
+	registry *models.Registry
+	// info overrides the served model info when set
+	info      *models.ModelInfo
+	downloads atomic.Int32
+	ranges    []string
+	apiKey    string
+}
+
+func (c *controlPlane) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.apiKey != "" && r.Header.Get("Authorization") != "Bearer "+c.apiKey {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.Path, "/api/v1/models/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	name, download := strings.CutSuffix(rest, "/download")
+
+	info, path, err := c.registry.Lookup(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if c.info != nil {
+		info = c.info
+	}
+
+	if !download {
+		json.NewEncoder(w).Encode(info)
+		return
+	}
+
+	c.downloads.Add(1)
+	c.ranges = append(c.ranges, r.Header.Get("Range"))
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	stat, _ := f.Stat()
+	w.Header().Set(models.ChecksumHeader, info.SHA256)
+	http.ServeContent(w, r, info.Filename, stat.ModTime(), f)
+}
+
+func newControlPlane(t *testing.T, content []byte) (*controlPlane, *httptest.Server) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("failed to write model: %v", err)
+	}
+
+	cp := &controlPlane{registry: models.NewRegistry(map[string]config.ModelConfig{
+		"llama": {Path: path},
+	})}
+	server := httptest.NewServer(cp)
+	t.Cleanup(server.Close)
+	return cp, server
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRegistry_Lookup(t *testing.T) {
+	content := []byte("gguf model weights")
+	cp, _ := newControlPlane(t, content)
+
+	info, path, err := cp.registry.Lookup("llama")
+	if err != nil {
+		t.Fatalf("Lookup failed: %v", err)
+	}
+	if info.Filename != "model.gguf" || info.Size != int64(len(content)) {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if info.SHA256 != checksum(content) {
+		t.Errorf("expected checksum %s, got %s", checksum(content), info.SHA256)
+	}
+	if filepath.Base(path) != "model.gguf" {
+		t.Errorf("unexpected path %s", path)
+	}
+
+	if _, _, err := cp.registry.Lookup("missing"); err == nil {
+		t.Error("expected an error for an unregistered model")
+	}
+}
+
+func TestFetcher_DownloadsAndReusesCache(t *testing.T) {
+	content := bytes.Repeat([]byte("weights"), 1000)
+	cp, server := newControlPlane(t, content)
+	cp.apiKey = "secret"
+
+	cacheDir := t.TempDir()
+	fetcher := models.NewFetcher(server.URL, "secret", cacheDir)
+
+	var lastDownloaded, lastTotal int64
+	path, err := fetcher.Fetch(context.Background(), "llama", func(downloaded, total int64) {
+		lastDownloaded, lastTotal = downloaded, total
+	})
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	if expected := filepath.Join(cacheDir, checksum(content), "model.gguf"); path != expected {
+		t.Errorf("expected path %s, got %s", expected, path)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("cached model does not match the served file")
+	}
+	if lastDownloaded != int64(len(content)) || lastTotal != int64(len(content)) {
+		t.Errorf("expected final progress %d/%d, got %d/%d", len(content), len(content), lastDownloaded, lastTotal)
+	}
+
+	// A second fetch is served from the cache
+	if _, err := fetcher.Fetch(context.Background(), "llama", nil); err != nil {
+		t.Fatalf("second Fetch failed: %v", err)
+	}
+	if n := cp.downloads.Load(); n != 1 {
+		t.Errorf("expected 1 download, got %d", n)
+	}
+}
+
+func TestFetcher_RejectsChecksumMismatch(t *testing.T) {
+	content := []byte("gguf model weights")
+	cp, server := newControlPlane(t, content)
+	cp.info = &models.ModelInfo{
+		Name:     "llama",
+		Filename: "model.gguf",
+		Size:     int64(len(content)),
+		SHA256:   checksum([]byte("other weights")),
+	}
+
+	cacheDir := t.TempDir()
+	fetcher := models.NewFetcher(server.URL, "", cacheDir)
+	_, err := fetcher.Fetch(context.Background(), "llama", nil)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	dir := filepath.Join(cacheDir, cp.info.SHA256)
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("expected no files in the cache after a failed download, got %d", len(entries))
+	}
+}
+
+func TestFetcher_ResumesPartialDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	cp, server := newControlPlane(t, content)
+
+	cacheDir := t.TempDir()
+	dir := filepath.Join(cacheDir, checksum(content))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "model.gguf.part"), content[:400], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher := models.NewFetcher(server.URL, "", cacheDir)
+	path, err := fetcher.Fetch(context.Background(), "llama", nil)
+	if err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	if len(cp.ranges) != 1 || cp.ranges[0] != "bytes=400-" {
+		t.Errorf("expected a single range request from byte 400, got %v", cp.ranges)
+	}
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got, content) {
+		t.Error("resumed model does not match the served file")
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Error("expected the partial download to be moved into place")
+	}
+}
+
+func TestFetcher_CompletesFullPartialDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+
+	tests := []struct {
+		name       string
+		part       []byte
+		wantRanges []string
+	}{
+		// Nothing is left to download, the partial file is verified and moved into place
+		{name: "matching", part: content, wantRanges: nil},
+		// A partial file of the right size with other content is downloaded again
+		{name: "corrupted", part: bytes.Repeat([]byte("x"), len(content)), wantRanges: []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp, server := newControlPlane(t, content)
+			cacheDir := t.TempDir()
+			dir := filepath.Join(cacheDir, checksum(content))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "model.gguf.part"), tt.part, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			fetcher := models.NewFetcher(server.URL, "", cacheDir)
+			path, err := fetcher.Fetch(context.Background(), "llama", nil)
+			if err != nil {
+				t.Fatalf("Fetch failed: %v", err)
+			}
+
+			if !slices.Equal(cp.ranges, tt.wantRanges) {
+				t.Errorf("expected download requests %q, got %q", tt.wantRanges, cp.ranges)
+			}
+			got, _ := os.ReadFile(path)
+			if !bytes.Equal(got, content) {
+				t.Error("cached model does not match the served file")
+			}
+			if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+				t.Error("expected no partial download to be left")
+			}
+		})
+	}
+}
+
+func TestFetcher_RefusesDownloadThatDoesNotFit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("free disk space is not reported on Windows")
+	}
+
+	content := []byte("gguf model weights")
+	cp, server := newControlPlane(t, content)
+	cp.info = &models.ModelInfo{
+		Name:     "llama",
+		Filename: "model.gguf",
+		Size:     1 << 60,
+		SHA256:   checksum(content),
+	}
+
+	fetcher := models.NewFetcher(server.URL, "", t.TempDir())
+	_, err := fetcher.Fetch(context.Background(), "llama", nil)
+	if err == nil || !strings.Contains(err.Error(), "insufficient disk space") {
+		t.Fatalf("expected an insufficient disk space error, got %v", err)
+	}
+	if n := cp.downloads.Load(); n != 0 {
+		t.Errorf("expected no download to start, got %d", n)
+	}
+}
+
+func TestFetcher_RejectsUnsafeFilename(t *testing.T) {
+	content := []byte("gguf model weights")
+	cp, server := newControlPlane(t, content)
+	cp.info = &models.ModelInfo{
+		Name:     "llama",
+		Filename: "../escape.gguf",
+		Size:     int64(len(content)),
+		SHA256:   checksum(content),
+	}
+
+	fetcher := models.NewFetcher(server.URL, "", t.TempDir())
+	if _, err := fetcher.Fetch(context.Background(), "llama", nil); err == nil {
+		t.Fatal("expected an error for a filename outside the cache directory")
+	}
+}
@@ -0,0 +1,115 @@
+// Package models shares model files between llamactl nodes: the control plane serves
+// registered model files, and worker nodes download and cache them by checksum.
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"llamactl/pkg/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChecksumHeader carries the hex-encoded SHA-256 of a served model file
+const ChecksumHeader = "X-Checksum-Sha256"
+
+// ModelInfo describes a registered model file
+type ModelInfo struct {
+	Name     string `json:"name"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// Registry resolves the models registered in the configuration to files on disk.
+// Checksums that are not configured are computed on first use and cached until the
+// file changes.
+type Registry struct {
+	models map[string]config.ModelConfig
+
+	mu        sync.Mutex
+	checksums map[string]cachedChecksum
+}
+
+type cachedChecksum struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// NewRegistry creates a registry of the given models
+func NewRegistry(models map[string]config.ModelConfig) *Registry {
+	return &Registry{
+		models:    models,
+		checksums: make(map[string]cachedChecksum),
+	}
+}
+
+// Lookup returns the info of a registered model and the path of its file
+func (r *Registry) Lookup(name string) (*ModelInfo, string, error) {
+	model, ok := r.models[name]
+	if !ok {
+		return nil, "", fmt.Errorf("model %s is not registered", name)
+	}
+
+	stat, err := os.Stat(model.Path)
+	if err != nil {
+		return nil, "", fmt.Errorf("model file of %s is not accessible: %w", name, err)
+	}
+	if stat.IsDir() {
+		return nil, "", fmt.Errorf("model file of %s is a directory", name)
+	}
+
+	sum := strings.ToLower(model.SHA256)
+	if sum == "" {
+		sum, err = r.checksum(model.Path, stat)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to checksum model %s: %w", name, err)
+		}
+	}
+
+	return &ModelInfo{
+		Name:     name,
+		Filename: filepath.Base(model.Path),
+		Size:     stat.Size(),
+		SHA256:   sum,
+	}, model.Path, nil
+}
+
+func (r *Registry) checksum(path string, stat os.FileInfo) (string, error) {
+	r.mu.Lock()
+	cached, ok := r.checksums[path]
+	r.mu.Unlock()
+	if ok && cached.size == stat.Size() && cached.modTime.Equal(stat.ModTime()) {
+		return cached.sum, nil
+	}
+
+	sum, err := fileChecksum(path)
+	if err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	r.checksums[path] = cachedChecksum{size: stat.Size(), modTime: stat.ModTime(), sum: sum}
+	r.mu.Unlock()
+	return sum, nil
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package models
+
+import "errors"
+
+// freeDiskSpace is not implemented on Windows; downloads skip the preflight check
+func freeDiskSpace(dir string) (int64, error) {
+	return 0, errors.New("free disk space is not available on windows")
+}
@@ -0,0 +1,402 @@
+// Package quota enforces request and token budgets per API key over a calendar-month or
+// rolling window. Usage is counted in daily buckets that are persisted to the store, so
+// counters survive a llamactl restart.
+package quota
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"llamactl/pkg/config"
+	"llamactl/pkg/storage"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Quota windows
+const (
+	WindowCalendarMonth = "calendar_month"
+	WindowRolling       = "rolling"
+)
+
+// defaultRollingDays is the length of a rolling window when rolling_days is not set
+const defaultRollingDays = 30
+
+// flushInterval bounds how often the counters of a key are written to the store
+const flushInterval = 5 * time.Second
+
+const dayLayout = "2006-01-02"
+
+// Usage counts requests and tokens
+type Usage struct {
+	Requests int64 `json:"requests"`
+	Tokens   int64 `json:"tokens"`
+}
+
+// Status is the state of a key's quota in the current window. Remaining values are nil
+// when the quota has no limit for them.
+type Status struct {
+	ID                string    `json:"id"`
+	KeyHint           string    `json:"key_hint"`
+	Window            string    `json:"window"`
+	RequestLimit      int64     `json:"request_limit,omitempty"`
+	TokenLimit        int64     `json:"token_limit,omitempty"`
+	Used              Usage     `json:"used"`
+	RemainingRequests *int64    `json:"remaining_requests,omitempty"`
+	RemainingTokens   *int64    `json:"remaining_tokens,omitempty"`
+	ResetAt           time.Time `json:"reset_at"`
+}
+
+// Exceeded reports whether the request or token budget is used up
+func (s *Status) Exceeded() bool {
+	return (s.RemainingRequests != nil && *s.RemainingRequests <= 0) ||
+		(s.RemainingTokens != nil && *s.RemainingTokens <= 0)
+}
+
+// ExceededError is returned by Check when a key has used up its quota
+type ExceededError struct {
+	Status *Status
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota of key %s exceeded until %s", e.Status.KeyHint, e.Status.ResetAt.Format(time.RFC3339))
+}
+
+// ErrUnknownKey is returned when a quota ID does not belong to a configured key
+var ErrUnknownKey = errors.New("no quota is configured for this key")
+
+// KeyID identifies an API key in the admin API and the store without revealing it
+func KeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+type keyQuota struct {
+	id     string
+	hint   string
+	limits config.KeyQuotaConfig
+
+	buckets   map[string]Usage // Usage per UTC day
+	dirty     bool
+	lastFlush time.Time
+}
+
+// persistedUsage is the record stored per key
+type persistedUsage struct {
+	Buckets map[string]Usage `json:"buckets"`
+}
+
+// Tracker counts usage of the API keys that have a quota and enforces their budgets
+type Tracker struct {
+	store storage.Store // nil keeps counters in memory only
+	now   func() time.Time
+
+	mu     sync.Mutex
+	byKey  map[string]*keyQuota
+	byID   map[string]*keyQuota
+	flushM sync.Mutex // Serializes writes to the store
+}
+
+// NewTracker creates a tracker for the configured quotas, loading persisted counters from store
+func NewTracker(quotas map[string]config.KeyQuotaConfig, store storage.Store) *Tracker {
+	t := &Tracker{
+		store: store,
+		now:   time.Now,
+		byKey: make(map[string]*keyQuota, len(quotas)),
+		byID:  make(map[string]*keyQuota, len(quotas)),
+	}
+
+	for key, limits := range quotas {
+		q := &keyQuota{
+			id:      KeyID(key),
+			hint:    keyHint(key),
+			limits:  limits,
+			buckets: make(map[string]Usage),
+		}
+		if store != nil {
+			if data, err := store.Get(storage.NamespaceKeyUsage, q.id); err == nil {
+				var persisted persistedUsage
+				if err := json.Unmarshal(data, &persisted); err != nil {
+					log.Printf("Failed to load usage of key %s: %v", q.hint, err)
+				} else if persisted.Buckets != nil {
+					q.buckets = persisted.Buckets
+				}
+			} else if !errors.Is(err, storage.ErrNotFound) {
+				log.Printf("Failed to load usage of key %s: %v", q.hint, err)
+			}
+		}
+		t.byKey[key] = q
+		t.byID[q.id] = q
+	}
+	return t
+}
+
+// SetClock replaces the clock used for windows, for testing
+func (t *Tracker) SetClock(now func() time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.now = now
+}
+
+// Enabled reports whether any key has a quota
+func (t *Tracker) Enabled() bool {
+	return len(t.byKey) > 0
+}
+
+// Check returns the quota status of key, or nil if the key has no quota. An ExceededError
+// is returned along with the status when the budget is used up.
+func (t *Tracker) Check(key string) (*Status, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q, ok := t.byKey[key]
+	if !ok {
+		return nil, nil
+	}
+	status := t.status(q)
+	if status.Exceeded() {
+		return status, &ExceededError{Status: status}
+	}
+	return status, nil
+}
+
+// Record counts a completed request of key and the tokens it used
+func (t *Tracker) Record(key string, tokens int64) {
+	t.mu.Lock()
+	q, ok := t.byKey[key]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	now := t.now()
+	day := now.UTC().Format(dayLayout)
+	usage := q.buckets[day]
+	usage.Requests++
+	usage.Tokens += tokens
+	q.buckets[day] = usage
+	q.dirty = true
+	t.prune(q, now)
+	flush := now.Sub(q.lastFlush) >= flushInterval
+	t.mu.Unlock()
+
+	if flush {
+		t.flushKey(q)
+	}
+}
+
+// Get returns the quota status of the key with the given ID
+func (t *Tracker) Get(id string) (*Status, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	q, ok := t.byID[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return t.status(q), nil
+}
+
+// List returns the quota status of every key, sorted by ID
+func (t *Tracker) List() []*Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	statuses := make([]*Status, 0, len(t.byID))
+	for _, q := range t.byID {
+		statuses = append(statuses, t.status(q))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+// Adjust sets the usage consumed by a key in the current window, for example to settle a
+// dispute. The change is written to the store and the audit log immediately.
+func (t *Tracker) Adjust(id string, used Usage) (*Status, error) {
+	if used.Requests < 0 || used.Tokens < 0 {
+		return nil, fmt.Errorf("consumed requests and tokens cannot be negative")
+	}
+
+	t.mu.Lock()
+	q, ok := t.byID[id]
+	if !ok {
+		t.mu.Unlock()
+		return nil, ErrUnknownKey
+	}
+	previous := t.status(q).Used
+
+	now := t.now()
+	start := t.windowStart(q, now)
+	for day := range q.buckets {
+		if date, err := time.Parse(dayLayout, day); err == nil && !date.Before(start) {
+			delete(q.buckets, day)
+		}
+	}
+	q.buckets[now.UTC().Format(dayLayout)] = used
+	q.dirty = true
+	status := t.status(q)
+	t.mu.Unlock()
+
+	if err := t.flushKey(q); err != nil {
+		return nil, fmt.Errorf("failed to persist usage: %w", err)
+	}
+	if t.store != nil {
+		record := storage.AuditRecord{
+			Time:    now,
+			Action:  "adjust_quota",
+			Target:  id,
+			Details: fmt.Sprintf("requests %d -> %d, tokens %d -> %d", previous.Requests, used.Requests, previous.Tokens, used.Tokens),
+		}
+		if err := t.store.AppendAudit(record); err != nil {
+			log.Printf("Failed to record audit entry adjust_quota %s: %v", id, err)
+		}
+	}
+	return status, nil
+}
+
+// Flush writes every changed counter to the store
+func (t *Tracker) Flush() error {
+	t.mu.Lock()
+	quotas := make([]*keyQuota, 0, len(t.byID))
+	for _, q := range t.byID {
+		if q.dirty {
+			quotas = append(quotas, q)
+		}
+	}
+	t.mu.Unlock()
+
+	var errs []error
+	for _, q := range quotas {
+		if err := t.flushKey(q); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t *Tracker) flushKey(q *keyQuota) error {
+	if t.store == nil {
+		return nil
+	}
+
+	t.flushM.Lock()
+	defer t.flushM.Unlock()
+
+	t.mu.Lock()
+	if !q.dirty {
+		t.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(persistedUsage{Buckets: q.buckets})
+	q.dirty = false
+	q.lastFlush = t.now()
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := t.store.Put(storage.NamespaceKeyUsage, q.id, data); err != nil {
+		t.mu.Lock()
+		q.dirty = true
+		t.mu.Unlock()
+		log.Printf("Failed to persist usage of key %s: %v", q.hint, err)
+		return err
+	}
+	return nil
+}
+
+// status computes the quota status in the current window (caller must hold the lock)
+func (t *Tracker) status(q *keyQuota) *Status {
+	now := t.now()
+	start := t.windowStart(q, now)
+
+	status := &Status{
+		ID:           q.id,
+		KeyHint:      q.hint,
+		Window:       windowName(q.limits),
+		RequestLimit: q.limits.Requests,
+		TokenLimit:   q.limits.Tokens,
+	}
+
+	var oldest time.Time
+	for day, usage := range q.buckets {
+		date, err := time.Parse(dayLayout, day)
+		if err != nil || date.Before(start) {
+			continue
+		}
+		status.Used.Requests += usage.Requests
+		status.Used.Tokens += usage.Tokens
+		if oldest.IsZero() || date.Before(oldest) {
+			oldest = date
+		}
+	}
+
+	if q.limits.Requests > 0 {
+		remaining := max(q.limits.Requests-status.Used.Requests, 0)
+		status.RemainingRequests = &remaining
+	}
+	if q.limits.Tokens > 0 {
+		remaining := max(q.limits.Tokens-status.Used.Tokens, 0)
+		status.RemainingTokens = &remaining
+	}
+
+	if status.Window == WindowRolling {
+		// Usage frees up as the oldest day in the window drops out of it
+		if oldest.IsZero() {
+			oldest = startOfDay(now)
+		}
+		status.ResetAt = oldest.AddDate(0, 0, rollingDays(q.limits))
+	} else {
+		status.ResetAt = start.AddDate(0, 1, 0)
+	}
+	return status
+}
+
+// windowStart returns the first day counted in the current window
+func (t *Tracker) windowStart(q *keyQuota, now time.Time) time.Time {
+	today := startOfDay(now)
+	if windowName(q.limits) == WindowRolling {
+		return today.AddDate(0, 0, 1-rollingDays(q.limits))
+	}
+	return time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// prune drops buckets that no longer fall in the window (caller must hold the lock)
+func (t *Tracker) prune(q *keyQuota, now time.Time) {
+	start := t.windowStart(q, now)
+	for day := range q.buckets {
+		if date, err := time.Parse(dayLayout, day); err != nil || date.Before(start) {
+			delete(q.buckets, day)
+		}
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func windowName(limits config.KeyQuotaConfig) string {
+	if limits.Window == WindowRolling {
+		return WindowRolling
+	}
+	return WindowCalendarMonth
+}
+
+func rollingDays(limits config.KeyQuotaConfig) int {
+	if limits.RollingDays > 0 {
+		return limits.RollingDays
+	}
+	return defaultRollingDays
+}
+
+// keyHint keeps the last characters of a key so that admins can tell keys apart
+func keyHint(key string) string {
+	if len(key) <= 8 {
+		return "****"
+	}
+	return "..." + key[len(key)-4:]
+}
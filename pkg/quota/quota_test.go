@@ -0,0 +1,200 @@
+package quota_test
+
+import (
+	"errors"
+	"llamactl/pkg/config"
+	"llamactl/pkg/quota"
+	"llamactl/pkg/storage"
+	"testing"
+	"time"
+)
+
+const testKey = "sk-inference-test-0123"
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newTracker(t *testing.T, limits config.KeyQuotaConfig, store storage.Store, clock *fakeClock) *quota.Tracker {
+	t.Helper()
+	tracker := quota.NewTracker(map[string]config.KeyQuotaConfig{testKey: limits}, store)
+	tracker.SetClock(clock.Now)
+	return tracker
+}
+
+func TestTracker_EnforcesLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   config.KeyQuotaConfig
+		requests int
+		tokens   int64
+		exceeded bool
+	}{
+		{name: "under both limits", limits: config.KeyQuotaConfig{Requests: 3, Tokens: 100}, requests: 2, tokens: 10},
+		{name: "request limit reached", limits: config.KeyQuotaConfig{Requests: 3}, requests: 3, tokens: 10, exceeded: true},
+		{name: "token limit reached", limits: config.KeyQuotaConfig{Tokens: 100}, requests: 2, tokens: 50, exceeded: true},
+		{name: "requests unlimited", limits: config.KeyQuotaConfig{Tokens: 1000}, requests: 50, tokens: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)}
+			tracker := newTracker(t, tt.limits, nil, clock)
+
+			for range tt.requests {
+				tracker.Record(testKey, tt.tokens)
+			}
+
+			status, err := tracker.Check(testKey)
+			var exceeded *quota.ExceededError
+			if errors.As(err, &exceeded) != tt.exceeded {
+				t.Fatalf("expected exceeded=%v, got error %v", tt.exceeded, err)
+			}
+			if status.Used.Requests != int64(tt.requests) || status.Used.Tokens != int64(tt.requests)*tt.tokens {
+				t.Errorf("unexpected usage %+v", status.Used)
+			}
+			if tt.limits.Requests == 0 && status.RemainingRequests != nil {
+				t.Errorf("expected no request limit, got %d remaining", *status.RemainingRequests)
+			}
+			if expected := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC); !status.ResetAt.Equal(expected) {
+				t.Errorf("expected reset at %v, got %v", expected, status.ResetAt)
+			}
+		})
+	}
+}
+
+func TestTracker_UnknownKeyIsUnlimited(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	tracker := newTracker(t, config.KeyQuotaConfig{Requests: 1}, nil, clock)
+
+	tracker.Record("other-key", 10)
+	status, err := tracker.Check("other-key")
+	if status != nil || err != nil {
+		t.Errorf("expected no quota for an unconfigured key, got %+v, %v", status, err)
+	}
+}
+
+func TestTracker_Windows(t *testing.T) {
+	tests := []struct {
+		name          string
+		limits        config.KeyQuotaConfig
+		later         time.Time
+		expectedUsed  int64
+		expectedReset time.Time
+	}{
+		{
+			name:          "calendar month resets on the first",
+			limits:        config.KeyQuotaConfig{Requests: 10},
+			later:         time.Date(2024, 7, 1, 0, 30, 0, 0, time.UTC),
+			expectedUsed:  1,
+			expectedReset: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "rolling window still counts recent days",
+			limits:        config.KeyQuotaConfig{Requests: 10, Window: quota.WindowRolling, RollingDays: 7},
+			later:         time.Date(2024, 7, 5, 12, 0, 0, 0, time.UTC),
+			expectedUsed:  2,
+			expectedReset: time.Date(2024, 7, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "rolling window drops old days",
+			limits:        config.KeyQuotaConfig{Requests: 10, Window: quota.WindowRolling, RollingDays: 7},
+			later:         time.Date(2024, 7, 8, 12, 0, 0, 0, time.UTC),
+			expectedUsed:  1,
+			expectedReset: time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC)}
+			tracker := newTracker(t, tt.limits, nil, clock)
+			tracker.Record(testKey, 0)
+
+			clock.now = tt.later
+			tracker.Record(testKey, 0)
+
+			status, err := tracker.Check(testKey)
+			if err != nil {
+				t.Fatalf("Check failed: %v", err)
+			}
+			if status.Used.Requests != tt.expectedUsed {
+				t.Errorf("expected %d requests in the window, got %d", tt.expectedUsed, status.Used.Requests)
+			}
+			if !status.ResetAt.Equal(tt.expectedReset) {
+				t.Errorf("expected reset at %v, got %v", tt.expectedReset, status.ResetAt)
+			}
+		})
+	}
+}
+
+func TestTracker_PersistsCounters(t *testing.T) {
+	store := storage.NewFileStore(t.TempDir(), t.TempDir())
+	clock := &fakeClock{now: time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)}
+	limits := config.KeyQuotaConfig{Requests: 100, Tokens: 1000}
+
+	tracker := newTracker(t, limits, store, clock)
+	for range 3 {
+		tracker.Record(testKey, 20)
+	}
+	if err := tracker.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// A new tracker, as after a restart, picks up the counters
+	restarted := newTracker(t, limits, store, clock)
+	status, err := restarted.Check(testKey)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if status.Used != (quota.Usage{Requests: 3, Tokens: 60}) {
+		t.Errorf("expected persisted usage {3 60}, got %+v", status.Used)
+	}
+}
+
+func TestTracker_Adjust(t *testing.T) {
+	store := storage.NewFileStore(t.TempDir(), t.TempDir())
+	clock := &fakeClock{now: time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)}
+	limits := config.KeyQuotaConfig{Requests: 5}
+	tracker := newTracker(t, limits, store, clock)
+
+	for range 5 {
+		tracker.Record(testKey, 0)
+	}
+	if _, err := tracker.Check(testKey); err == nil {
+		t.Fatal("expected the quota to be exceeded")
+	}
+
+	id := quota.KeyID(testKey)
+	status, err := tracker.Adjust(id, quota.Usage{Requests: 2})
+	if err != nil {
+		t.Fatalf("Adjust failed: %v", err)
+	}
+	if status.Used.Requests != 2 || *status.RemainingRequests != 3 {
+		t.Errorf("unexpected status after adjust: %+v", status)
+	}
+	if _, err := tracker.Check(testKey); err != nil {
+		t.Errorf("expected the quota to be available after adjusting, got %v", err)
+	}
+
+	// The adjustment is persisted and audited right away
+	restarted := newTracker(t, limits, store, clock)
+	if status, _ := restarted.Get(id); status.Used.Requests != 2 {
+		t.Errorf("expected the adjustment to be persisted, got %+v", status.Used)
+	}
+	audit, err := store.ListAudit(0)
+	if err != nil || len(audit) != 1 || audit[0].Action != "adjust_quota" || audit[0].Target != id {
+		t.Errorf("expected an adjust_quota audit record, got %+v, %v", audit, err)
+	}
+
+	if _, err := tracker.Adjust("unknown", quota.Usage{}); !errors.Is(err, quota.ErrUnknownKey) {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+	if _, err := tracker.Adjust(id, quota.Usage{Requests: -1}); err == nil {
+		t.Error("expected an error for negative usage")
+	}
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const (
+	// maxUsageLineBytes caps a single buffered SSE line; longer lines are skipped
+	maxUsageLineBytes = 64 * 1024
+	// maxUsageBodyBytes caps the buffered body of non-streamed JSON responses
+	maxUsageBodyBytes = 1024 * 1024
+)
+
+// tokenUsage is the OpenAI usage object reported by the backend. Fields are nil when absent.
+type tokenUsage struct {
+	PromptTokens     *int `json:"prompt_tokens"`
+	CompletionTokens *int `json:"completion_tokens"`
+}
+
+// accessLogEntry collects the fields of a single access log line
+type accessLogEntry struct {
+	instance string // Set by the proxy handlers once the target instance is known
+}
+
+type accessLogContextKey struct{}
+
+// setAccessLogInstance records the instance a request is proxied to
+func setAccessLogInstance(r *http.Request, name string) {
+	if entry, ok := r.Context().Value(accessLogContextKey{}).(*accessLogEntry); ok {
+		entry.instance = name
+	}
+}
+
+// usageRecorder receives a copy of everything written to the client. It records the time
+// of the first byte and, for proxied requests, picks the usage object out of the response:
+// SSE streams are scanned line by line so only the current line is held in memory, and
+// non-streamed JSON bodies are buffered up to maxUsageBodyBytes.
+type usageRecorder struct {
+	entry  *accessLogEntry
+	header http.Header
+	now    func() time.Time
+
+	firstByte time.Time
+	mode      int // usageModeNone, usageModeSSE or usageModeJSON, decided on the first write
+	line      []byte
+	skipLine  bool
+	body      []byte
+	usage     *tokenUsage
+}
+
+const (
+	usageModeNone = iota
+	usageModeSSE
+	usageModeJSON
+)
+
+func (u *usageRecorder) Write(p []byte) (int, error) {
+	if u.firstByte.IsZero() && len(p) > 0 {
+		u.firstByte = u.now()
+		u.mode = u.detectMode()
+	}
+
+	switch u.mode {
+	case usageModeSSE:
+		u.scanLines(p)
+	case usageModeJSON:
+		if len(u.body)+len(p) <= maxUsageBodyBytes {
+			u.body = append(u.body, p...)
+		} else {
+			u.mode = usageModeNone
+			u.body = nil
+		}
+	}
+	return len(p), nil
+}
+
+func (u *usageRecorder) detectMode() int {
+	if u.entry.instance == "" {
+		return usageModeNone
+	}
+	contentType := u.header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		return usageModeSSE
+	case strings.HasPrefix(contentType, "application/json"):
+		return usageModeJSON
+	}
+	return usageModeNone
+}
+
+func (u *usageRecorder) scanLines(p []byte) {
+	for len(p) > 0 {
+		idx := bytes.IndexByte(p, '\n')
+		if idx < 0 {
+			if len(u.line)+len(p) > maxUsageLineBytes {
+				u.line, u.skipLine = u.line[:0], true
+			} else if !u.skipLine {
+				u.line = append(u.line, p...)
+			}
+			return
+		}
+
+		if !u.skipLine && len(u.line)+idx <= maxUsageLineBytes {
+			u.line = append(u.line, p[:idx]...)
+			u.parseEvent(u.line)
+		}
+		u.line, u.skipLine = u.line[:0], false
+		p = p[idx+1:]
+	}
+}
+
+// parseEvent keeps the usage object of an SSE data line; the last one in the stream wins
+func (u *usageRecorder) parseEvent(line []byte) {
+	data, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r"), []byte("data:"))
+	if !ok || !bytes.Contains(data, []byte(`"usage"`)) {
+		return
+	}
+	if usage := parseUsage(bytes.TrimSpace(data)); usage != nil {
+		u.usage = usage
+	}
+}
+
+// finish parses the buffered body of a non-streamed response
+func (u *usageRecorder) finish() {
+	if u.mode == usageModeJSON && len(u.body) > 0 {
+		u.usage = parseUsage(u.body)
+	}
+	u.body = nil
+}
+
+func parseUsage(data []byte) *tokenUsage {
+	var payload struct {
+		Usage *tokenUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil
+	}
+	return payload.Usage
+}
+
+// AccessLogger logs one line per request with structured key=value fields. Proxied inference
+// requests additionally carry the instance, the API key (masked), the time to the first byte
+// of the response and, when the backend reports usage, the prompt and completion token counts.
+// The same values are recorded in the Prometheus histograms served on /metrics.
+func (h *Handler) AccessLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		r = r.WithContext(context.WithValue(r.Context(), accessLogContextKey{}, entry))
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		recorder := &usageRecorder{entry: entry, header: ww.Header(), now: time.Now}
+		ww.Tee(recorder)
+
+		defer func() {
+			recorder.finish()
+			duration := time.Since(start)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			var b strings.Builder
+			fmt.Fprintf(&b, "method=%s path=%q status=%d bytes=%d duration_ms=%.1f remote=%s",
+				r.Method, r.URL.Path, status, ww.BytesWritten(), milliseconds(duration), r.RemoteAddr)
+
+			if entry.instance != "" {
+				fmt.Fprintf(&b, " instance=%s", entry.instance)
+				if key := extractAPIKey(r); key != "" {
+					fmt.Fprintf(&b, " api_key=%s", maskAPIKey(key))
+				}
+
+				var ttfb time.Duration
+				if !recorder.firstByte.IsZero() {
+					ttfb = recorder.firstByte.Sub(start)
+					fmt.Fprintf(&b, " ttfb_ms=%.1f", milliseconds(ttfb))
+				}
+				if usage := recorder.usage; usage != nil {
+					if usage.PromptTokens != nil {
+						fmt.Fprintf(&b, " prompt_tokens=%d", *usage.PromptTokens)
+					}
+					if usage.CompletionTokens != nil {
+						fmt.Fprintf(&b, " completion_tokens=%d", *usage.CompletionTokens)
+					}
+				}
+
+				h.metrics.observe(entry.instance, !recorder.firstByte.IsZero(), ttfb, duration, recorder.usage)
+				h.recordKeyUsage(r, recorder.usage)
+				h.exportRequest(r, entry.instance, status, duration, ttfb, recorder.usage)
+			}
+
+			log.Print(b.String())
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// recordKeyUsage counts a proxied request and its tokens against the quota of its API key
+func (h *Handler) recordKeyUsage(r *http.Request, usage *tokenUsage) {
+	var tokens int64
+	if usage != nil && usage.PromptTokens != nil {
+		tokens += int64(*usage.PromptTokens)
+	}
+	if usage != nil && usage.CompletionTokens != nil {
+		tokens += int64(*usage.CompletionTokens)
+	}
+	h.quotas.Record(extractAPIKey(r), tokens)
+}
+
+// exportRequest exports a summary of a proxied request to the sinks exporting request events
+func (h *Handler) exportRequest(r *http.Request, instance string, status int, duration, ttfb time.Duration, usage *tokenUsage) {
+	if h.exporter == nil || !h.exporter.Exports(config.EventClassRequest) {
+		return
+	}
+	data := map[string]any{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"status":      status,
+		"duration_ms": milliseconds(duration),
+	}
+	if ttfb > 0 {
+		data["ttfb_ms"] = milliseconds(ttfb)
+	}
+	if key := extractAPIKey(r); key != "" {
+		data["api_key"] = maskAPIKey(key)
+	}
+	if usage != nil && usage.PromptTokens != nil {
+		data["prompt_tokens"] = *usage.PromptTokens
+	}
+	if usage != nil && usage.CompletionTokens != nil {
+		data["completion_tokens"] = *usage.CompletionTokens
+	}
+	h.exporter.Export(events.Event{
+		Type:     events.TypeRequest,
+		Instance: instance,
+		Code:     strconv.Itoa(status),
+		Message:  r.Method + " " + r.URL.Path,
+		Data:     data,
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// maskAPIKey keeps enough of a key to tell keys apart in logs without revealing it
+func maskAPIKey(key string) string {
+	if len(key) <= 12 {
+		return "****"
+	}
+	return key[:8] + "..." + key[len(key)-4:]
+}
@@ -0,0 +1,188 @@
+package server_test
+
+import (
+	"bytes"
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newExternalBackendRouter registers backend as an unmanaged instance named "external"
+// and returns a router serving the llamactl API in front of it
+func newExternalBackendRouter(t *testing.T, backend *httptest.Server) http.Handler {
+	t.Helper()
+	return newExternalBackendRouterWithOptions(t, backend, nil)
+}
+
+// newExternalBackendRouterWithOptions is newExternalBackendRouter with the configuration and
+// the instance options adjusted by configure before the instance is created
+func newExternalBackendRouterWithOptions(t *testing.T, backend *httptest.Server, configure func(*config.AppConfig, *instance.CreateInstanceOptions)) http.Handler {
+	t.Helper()
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	cfg := config.AppConfig{
+		Instances: config.InstancesConfig{
+			PortRange:            [2]int{8000, 9000},
+			LogsDir:              t.TempDir(),
+			MaxInstances:         10,
+			MaxRunningInstances:  -1,
+			TimeoutCheckInterval: config.Duration(5 * time.Minute),
+		},
+	}
+	managed := false
+	options := &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		Managed:     &managed,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/path/to/model.gguf",
+			Host:  backendURL.Hostname(),
+			Port:  port,
+		},
+	}
+	if configure != nil {
+		configure(&cfg, options)
+	}
+
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+
+	inst, err := mngr.CreateInstance("external", options)
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !inst.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the external backend to be probed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return server.SetupRouter(server.NewHandler(mngr, cfg))
+}
+
+// captureLog redirects the standard logger for the duration of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+	return &buf
+}
+
+func TestAccessLog_StreamingUsage(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		expected []string
+		absent   []string
+	}{
+		{
+			name: "usage in final chunk",
+			chunks: []string{
+				"data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n",
+				"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,",
+				"\"completion_tokens\":34,\"total_tokens\":46}}\n\n",
+				"data: [DONE]\n\n",
+			},
+			expected: []string{"instance=external", "ttfb_ms=", "prompt_tokens=12", "completion_tokens=34", "api_key=sk-infer...0123"},
+		},
+		{
+			name:     "no usage reported",
+			chunks:   []string{"data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n", "data: [DONE]\n\n"},
+			expected: []string{"instance=external", "ttfb_ms="},
+			absent:   []string{"prompt_tokens", "completion_tokens"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/health" {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, chunk := range tt.chunks {
+					io.WriteString(w, chunk)
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer backend.Close()
+
+			router := newExternalBackendRouter(t, backend)
+			logs := captureLog(t)
+
+			req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"external","stream":true}`))
+			req.Header.Set("Authorization", "Bearer sk-inference-0123")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+			if recorder.Body.String() != strings.Join(tt.chunks, "") {
+				t.Errorf("Expected the stream to be passed through unchanged, got %q", recorder.Body.String())
+			}
+
+			line := logs.String()
+			for _, field := range tt.expected {
+				if !strings.Contains(line, field) {
+					t.Errorf("Expected access log to contain %q, got %q", field, line)
+				}
+			}
+			for _, field := range tt.absent {
+				if strings.Contains(line, field) {
+					t.Errorf("Expected access log not to contain %q, got %q", field, line)
+				}
+			}
+		})
+	}
+}
+
+func TestAccessLog_Metrics(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[],"usage":{"prompt_tokens":5,"completion_tokens":7}}`)
+	}))
+	defer backend.Close()
+
+	router := newExternalBackendRouter(t, backend)
+	captureLog(t)
+
+	req := httptest.NewRequest("POST", "/v1/completions", strings.NewReader(`{"model":"external"}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, metric := range []string{
+		`llamactl_request_ttfb_seconds_count{instance="external"} 1`,
+		`llamactl_request_duration_seconds_count{instance="external"} 1`,
+		`llamactl_request_prompt_tokens_sum{instance="external"} 5`,
+		`llamactl_request_completion_tokens_sum{instance="external"} 7`,
+	} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", metric, body)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package server_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAllowedPaths(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+	router := newUIRouter(t, backend, config.AuthConfig{})
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	update := func(body string) *httptest.ResponseRecorder {
+		return send("PUT", "/api/v1/instances/external/allowed-paths", body)
+	}
+	effective := func() []string {
+		var details struct {
+			AllowedPaths []string `json:"allowed_paths"`
+		}
+		json.Unmarshal(send("GET", "/api/v1/instances/external/", "").Body.Bytes(), &details)
+		return details.AllowedPaths
+	}
+
+	if got := effective(); !slices.Equal(got, []string{"/"}) {
+		t.Errorf("Expected every path to be allowed by default, got %v", got)
+	}
+
+	if w := update(`{"allowed_paths": ["/v1/embeddings", "/health"]}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected the update to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := effective(); !slices.Equal(got, []string{"/v1/embeddings", "/health"}) {
+		t.Errorf("Expected the allowed paths in the instance details, got %v", got)
+	}
+
+	cases := []struct {
+		method, path string
+		want         int
+	}{
+		{"POST", "/v1/embeddings", http.StatusOK},
+		{"POST", "/v1/chat/completions", http.StatusNotFound},
+		{"POST", "/llama-cpp/external/v1/embeddings", http.StatusOK},
+		{"GET", "/llama-cpp/external/props", http.StatusNotFound},
+		{"POST", "/llama-cpp/external/v1/embeddings/../../completion", http.StatusNotFound},
+		{"GET", "/instances/external/ui/", http.StatusNotFound},
+		// The management API reaches every path
+		{"GET", "/api/v1/instances/external/proxy/slots", http.StatusOK},
+	}
+	for _, tc := range cases {
+		if w := send(tc.method, tc.path, `{"model": "external"}`); w.Code != tc.want {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.path, tc.want, w.Code)
+		}
+	}
+
+	if w := update(`{"allowed_paths": ["v1/embeddings"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a relative path to be rejected, got %d", w.Code)
+	}
+
+	// No paths allow every path again
+	if w := update(`{"allowed_paths": []}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected the update to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send("GET", "/llama-cpp/external/props", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected every path to be allowed again, got %d", w.Code)
+	}
+}
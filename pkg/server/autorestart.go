@@ -0,0 +1,194 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AutoRestartPauseRequest is the optional body of a pause of automatic restarts
+type AutoRestartPauseRequest struct {
+	// Restarts resume by themselves after it, paused until resumed when unset
+	Duration config.Duration `json:"duration,omitempty"`
+}
+
+// AutoRestartPauseStatus is the global pause of automatic restarts
+type AutoRestartPauseStatus struct {
+	Paused bool       `json:"paused"`
+	Since  *time.Time `json:"since,omitempty"`
+	Until  *time.Time `json:"until,omitempty"` // Unset while paused until resumed
+}
+
+func newAutoRestartPauseStatus(pause *instance.RestartPause) AutoRestartPauseStatus {
+	if pause == nil {
+		return AutoRestartPauseStatus{}
+	}
+	since := pause.Since
+	return AutoRestartPauseStatus{Paused: true, Since: &since, Until: pause.Until}
+}
+
+// decodePauseRequest reads the duration of a pause, an empty body pausing until resumed
+func decodePauseRequest(r *http.Request) (time.Duration, error) {
+	var req AutoRestartPauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	if req.Duration < 0 {
+		return 0, errors.New("duration cannot be negative")
+	}
+	return req.Duration.Duration(), nil
+}
+
+// PauseInstanceAutoRestart godoc
+// @Summary Pause the automatic restarts of an instance
+// @Description Keeps the instance running, but if its backend crashes it stays down instead of being restarted, for duration or until resumed when no duration is given. The restart options are left untouched, and the pause survives a restart of llamactl.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Param request body AutoRestartPauseRequest false "Duration of the pause"
+// @Success 200 {object} instance.Process "Instance with its restarts paused"
+// @Failure 400 {string} string "Invalid request or unmanaged instance"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/autorestart/pause [post]
+func (h *Handler) PauseInstanceAutoRestart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+		duration, err := decodePauseRequest(r)
+		if err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.managerFor(r).PauseAutoRestart(name, duration)
+		if err != nil {
+			writeAutoRestartError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inst); err != nil {
+			http.Error(w, "Failed to encode instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ResumeInstanceAutoRestart godoc
+// @Summary Resume the automatic restarts of an instance
+// @Description Lifts the pause of the automatic restarts of an instance. A backend that crashed while paused is not started. Restarts stay paused while they are paused globally.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} instance.Process "Instance"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/autorestart/resume [post]
+func (h *Handler) ResumeInstanceAutoRestart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.managerFor(r).ResumeAutoRestart(name)
+		if err != nil {
+			writeAutoRestartError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inst); err != nil {
+			http.Error(w, "Failed to encode instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetAutoRestartPause godoc
+// @Summary Get the global pause of automatic restarts
+// @Description Returns whether the automatic restarts of every instance are paused, since when and until when
+// @Tags maintenance
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} AutoRestartPauseStatus "Global pause"
+// @Router /autorestart [get]
+func (h *Handler) GetAutoRestartPause() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeAutoRestartPause(w, h.InstanceManager.GetGlobalRestartPause())
+	}
+}
+
+// PauseAutoRestart godoc
+// @Summary Pause the automatic restarts of every instance
+// @Description Keeps the instances running, but any backend that crashes stays down instead of being restarted, for duration or until resumed when no duration is given. The pause holds on top of the pauses of the instances and survives a restart of llamactl.
+// @Tags maintenance
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param request body AutoRestartPauseRequest false "Duration of the pause"
+// @Success 200 {object} AutoRestartPauseStatus "Global pause"
+// @Failure 400 {string} string "Invalid request"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /autorestart/pause [post]
+func (h *Handler) PauseAutoRestart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		duration, err := decodePauseRequest(r)
+		if err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pause, err := h.managerFor(r).PauseAllAutoRestarts(duration)
+		if err != nil {
+			writeAutoRestartError(w, err)
+			return
+		}
+		writeAutoRestartPause(w, pause)
+	}
+}
+
+// ResumeAutoRestart godoc
+// @Summary Resume the automatic restarts of every instance
+// @Description Lifts the global pause of automatic restarts. Instances paused on their own stay paused.
+// @Tags maintenance
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} AutoRestartPauseStatus "Global pause"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /autorestart/resume [post]
+func (h *Handler) ResumeAutoRestart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h.managerFor(r).ResumeAllAutoRestarts(); err != nil {
+			writeAutoRestartError(w, err)
+			return
+		}
+		writeAutoRestartPause(w, nil)
+	}
+}
+
+func writeAutoRestartError(w http.ResponseWriter, err error) {
+	if errors.Is(err, instance.ErrUnmanaged) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, "Failed to update the pause of automatic restarts: "+err.Error(), http.StatusInternalServerError)
+}
+
+func writeAutoRestartPause(w http.ResponseWriter, pause *instance.RestartPause) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(newAutoRestartPauseStatus(pause)); err != nil {
+		http.Error(w, "Failed to encode pause: "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,83 @@
+package server_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/server"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoRestartPause(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	router := newUIRouter(t, backend, config.AuthConfig{})
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("global pause", func(t *testing.T) {
+		rec := post("/api/v1/autorestart/pause", `{"duration": "1h"}`)
+		var status server.AutoRestartPauseStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("Expected the pause, got %d: %s", rec.Code, rec.Body)
+		}
+		if !status.Paused || status.Until == nil || time.Until(*status.Until) < 50*time.Minute {
+			t.Errorf("Expected a pause of an hour, got %+v", status)
+		}
+
+		// Every instance reports it
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/instances/stopped/", nil))
+		var inst struct {
+			Paused bool       `json:"autorestart_paused"`
+			Until  *time.Time `json:"autorestart_paused_until"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &inst)
+		if !inst.Paused || inst.Until == nil || !inst.Until.Equal(*status.Until) {
+			t.Errorf("Expected the instance to report the global pause, got %s", rec.Body)
+		}
+
+		rec = post("/api/v1/autorestart/resume", "")
+		status = server.AutoRestartPauseStatus{}
+		json.Unmarshal(rec.Body.Bytes(), &status)
+		if rec.Code != http.StatusOK || status.Paused {
+			t.Errorf("Expected restarts to resume, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("instance pause", func(t *testing.T) {
+		rec := post("/api/v1/instances/stopped/autorestart/pause", "")
+		var inst struct {
+			Paused bool       `json:"autorestart_paused"`
+			Until  *time.Time `json:"autorestart_paused_until"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &inst)
+		if rec.Code != http.StatusOK || !inst.Paused || inst.Until != nil {
+			t.Errorf("Expected a pause until resumed, got %d: %s", rec.Code, rec.Body)
+		}
+
+		rec = post("/api/v1/instances/stopped/autorestart/resume", "")
+		inst.Paused = false
+		json.Unmarshal(rec.Body.Bytes(), &inst)
+		if rec.Code != http.StatusOK || inst.Paused {
+			t.Errorf("Expected restarts to resume, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		if rec := post("/api/v1/autorestart/pause", `{"duration": "-5m"}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected a negative duration to be rejected, got %d: %s", rec.Code, rec.Body)
+		}
+		if rec := post("/api/v1/instances/external/autorestart/pause", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected an unmanaged instance to be rejected, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"llamactl/pkg/manager"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RotateBackendKey godoc
+// @Summary Rotate the managed backend key of an instance
+// @Description Replaces the API key llamactl generated for the backend of an instance with managed_backend_key. A stopped instance gets the new key from its next start. A running instance is answered with 202 and recycled in the background once its in-flight requests finished, until then it keeps being reached with its current key. The key itself is never returned.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} instance.Process "Key rotated"
+// @Success 202 {object} instance.Process "Key rotated, instance recycling"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 409 {string} string "Instance does not use a managed backend key"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/rotate-backend-key [post]
+func (h *Handler) RotateBackendKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.managerFor(r).RotateBackendKey(name)
+		if err != nil {
+			if errors.Is(err, manager.ErrNoManagedBackendKey) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to rotate backend key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if inst.BackendKeyPending() {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		if err := json.NewEncoder(w).Encode(inst); err != nil {
+			http.Error(w, "Failed to encode instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
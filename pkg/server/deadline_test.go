@@ -0,0 +1,238 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueueDeadline_ShedsRequests(t *testing.T) {
+	unblock := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Header.Get("X-Block") != "" {
+			<-unblock
+		} else {
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"object":"chat.completion","choices":[]}`)
+	}))
+	defer backend.Close()
+
+	router := newExternalBackendRouterWithOptions(t, backend, func(_ *config.AppConfig, options *instance.CreateInstanceOptions) {
+		maxConcurrent := 1
+		options.MaxConcurrentRequests = &maxConcurrent
+	})
+	send := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"external"}`))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := send(nil); w.Code != http.StatusOK || w.Header().Get("X-Queue-Estimated-Wait-Ms") != "0" {
+		t.Fatalf("Expected an immediate admission, got %d with estimate %q", w.Code, w.Header().Get("X-Queue-Estimated-Wait-Ms"))
+	}
+	if w := send(map[string]string{"X-Deadline": "soon"}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid deadline to be rejected, got %d", w.Code)
+	}
+
+	// Hold the only slot, so new requests have to wait for about one service time
+	blocked := make(chan struct{})
+	go func() {
+		defer close(blocked)
+		send(map[string]string{"X-Block": "1"})
+	}()
+	defer func() {
+		close(unblock)
+		<-blocked
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		w := send(map[string]string{"X-Deadline": "0.01"})
+		if w.Code == http.StatusOK {
+			if time.Now().After(deadline) {
+				t.Fatal("Timed out waiting for the slot to be taken")
+			}
+			continue
+		}
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("Expected 429, got %d: %s", w.Code, w.Body.String())
+		}
+		var body struct {
+			Error struct {
+				Type            string `json:"type"`
+				EstimatedWaitMs int64  `json:"estimated_wait_ms"`
+				DeadlineMs      int64  `json:"deadline_ms"`
+			} `json:"error"`
+		}
+		json.NewDecoder(w.Body).Decode(&body)
+		if body.Error.Type != "queue_deadline" || body.Error.EstimatedWaitMs < 50 || body.Error.DeadlineMs != 10 {
+			t.Errorf("Expected the request to be shed with its estimated wait, got %+v", body.Error)
+		}
+		break
+	}
+}
+
+func TestMaxRequestDuration(t *testing.T) {
+	cancelled := make(chan string, 2)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"object\":\"chat.completion.chunk\",\"choices\":[]}\n\n")
+			w.(http.Flusher).Flush()
+		}
+		// Keeps generating until llamactl gives up on the request
+		<-r.Context().Done()
+		if body.Stream {
+			cancelled <- "stream"
+		} else {
+			cancelled <- "response"
+		}
+	}))
+	defer backend.Close()
+
+	router := newExternalBackendRouterWithOptions(t, backend, func(_ *config.AppConfig, options *instance.CreateInstanceOptions) {
+		limit := config.Duration(200 * time.Millisecond)
+		options.MaxRequestDuration = &limit
+	})
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	waitCancelled := func(want string) {
+		t.Helper()
+		select {
+		case got := <-cancelled:
+			if got != want {
+				t.Errorf("Expected the %s request to be cancelled, got %s", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected the backend %s request to be cancelled", want)
+		}
+	}
+
+	w := send(`{"model":"external"}`)
+	if w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), "max_request_duration of 200ms") {
+		t.Errorf("Expected a 504 naming the limit, got %d: %s", w.Code, w.Body.String())
+	}
+	waitCancelled("response")
+
+	w = send(`{"model":"external","stream":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the stream to have started, got %d", w.Code)
+	}
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	last := strings.TrimSpace(events[len(events)-1])
+	if len(events) < 2 || !strings.HasPrefix(last, "data: ") {
+		t.Fatalf("Expected the stream to end with a timeout event, got %q", w.Body.String())
+	}
+	var event struct {
+		Error struct {
+			Type string `json:"type"`
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(last, "data: ")), &event); err != nil || event.Error.Code != "request_timeout" || event.Error.Type != "timeout" {
+		t.Errorf("Expected a request_timeout error event, got %q", last)
+	}
+	waitCancelled("stream")
+
+	req := httptest.NewRequest("GET", "/api/v1/instances/external/stats", nil)
+	statsRecorder := httptest.NewRecorder()
+	router.ServeHTTP(statsRecorder, req)
+	var stats instance.StatsSnapshot
+	if err := json.NewDecoder(statsRecorder.Body).Decode(&stats); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	if stats.Timeouts != 2 || stats.Cancelled != 0 {
+		t.Errorf("Expected 2 timed out and no cancelled requests, got %d and %d", stats.Timeouts, stats.Cancelled)
+	}
+}
+
+func TestRequestPriority_CappedByKeyPriority(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"object":"chat.completion","choices":[]}`)
+	}))
+	defer backend.Close()
+
+	router := newExternalBackendRouterWithOptions(t, backend, func(cfg *config.AppConfig, options *instance.CreateInstanceOptions) {
+		cfg.Auth.RequireInferenceAuth = true
+		cfg.Auth.InferenceKeys = []string{"sk-low-key", "sk-high-key", "sk-plain-key"}
+		cfg.Auth.KeyPriorities = map[string]string{"sk-low-key": "low", "sk-high-key": "high"}
+		maxConcurrent := 10
+		options.MaxConcurrentRequests = &maxConcurrent
+	})
+
+	tests := []struct {
+		name   string
+		key    string
+		header string
+		want   string
+	}{
+		{name: "header above the key priority", key: "sk-low-key", header: "high", want: "low"},
+		{name: "header below the key priority", key: "sk-high-key", header: "low", want: "low"},
+		{name: "key priority without a header", key: "sk-high-key", want: "high"},
+		{name: "header without a key priority", key: "sk-plain-key", header: "high", want: "high"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"external"}`))
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+				req.Header.Set("X-Priority", tt.header)
+				router.ServeHTTP(httptest.NewRecorder(), req)
+			}()
+			defer func() {
+				release <- struct{}{}
+				<-done
+			}()
+
+			// The backend holds the request, listed with the priority it was admitted with
+			var requests []instance.InFlightRequest
+			for deadline := time.Now().Add(5 * time.Second); len(requests) == 0; time.Sleep(5 * time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("Timed out waiting for the request to be admitted")
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/instances/external/requests", nil))
+				json.NewDecoder(w.Body).Decode(&requests)
+			}
+			if len(requests) != 1 || requests[0].Priority != tt.want {
+				t.Errorf("Expected one request with priority %s, got %+v", tt.want, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"encoding/json"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/memory"
+	"net/http"
+	"runtime"
+)
+
+// GoroutinesResponse reports goroutine usage of the process and of each instance
+type GoroutinesResponse struct {
+	NumGoroutine int                                `json:"num_goroutine"`
+	Total        instance.GoroutineStats            `json:"total"`
+	Instances    map[string]instance.GoroutineStats `json:"instances"`
+}
+
+// GetGoroutines godoc
+// @Summary Get goroutine usage
+// @Description Returns runtime.NumGoroutine alongside the goroutines started and stopped on behalf of each instance
+// @Tags debug
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} GoroutinesResponse "Goroutine usage"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /debug/goroutines [get]
+func (h *Handler) GetGoroutines() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instances, err := h.InstanceManager.ListInstances()
+		if err != nil {
+			http.Error(w, "Failed to list instances: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := GoroutinesResponse{
+			NumGoroutine: runtime.NumGoroutine(),
+			Instances:    make(map[string]instance.GoroutineStats, len(instances)),
+		}
+		for _, inst := range instances {
+			stats := inst.GetGoroutineStats()
+			response.Instances[inst.Name] = stats
+			response.Total.Started += stats.Started
+			response.Total.Stopped += stats.Stopped
+			response.Total.Active += stats.Active
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Failed to encode goroutine stats: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// RuntimeMemStats is the part of runtime.MemStats about the size of the heap and the collector
+type RuntimeMemStats struct {
+	Alloc        uint64 `json:"alloc"`
+	TotalAlloc   uint64 `json:"total_alloc"`
+	Sys          uint64 `json:"sys"`
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapInuse    uint64 `json:"heap_inuse"`
+	HeapIdle     uint64 `json:"heap_idle"`
+	HeapReleased uint64 `json:"heap_released"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	StackInuse   uint64 `json:"stack_inuse"`
+	NextGC       uint64 `json:"next_gc"`
+	NumGC        uint32 `json:"num_gc"`
+}
+
+// MemoryResponse reports the bytes held by the in-memory buffers of the instances alongside
+// the memory statistics of the Go runtime
+type MemoryResponse struct {
+	memory.Snapshot
+	Runtime RuntimeMemStats `json:"runtime"`
+}
+
+// GetMemory godoc
+// @Summary Get memory usage
+// @Description Returns the approximate bytes held by the output tails and replayed events of each instance, the limits they are held to and the entries evicted to enforce them, alongside the Go runtime memory statistics
+// @Tags debug
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} MemoryResponse "Memory usage"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /debug/memory [get]
+func (h *Handler) GetMemory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+
+		response := MemoryResponse{
+			Snapshot: h.InstanceManager.GetMemoryUsage(),
+			Runtime: RuntimeMemStats{
+				Alloc:        stats.Alloc,
+				TotalAlloc:   stats.TotalAlloc,
+				Sys:          stats.Sys,
+				HeapAlloc:    stats.HeapAlloc,
+				HeapInuse:    stats.HeapInuse,
+				HeapIdle:     stats.HeapIdle,
+				HeapReleased: stats.HeapReleased,
+				HeapObjects:  stats.HeapObjects,
+				StackInuse:   stats.StackInuse,
+				NextGC:       stats.NextGC,
+				NumGC:        stats.NumGC,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Failed to encode memory usage: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetInstanceDrift godoc
+// @Summary Compare the definition of an instance with its runtime state
+// @Description Compares the persisted definition of an instance, its options in memory, its definition in the configuration file for a static instance and, where the platform tells, the command line of its running process, listing every field that differs with its values
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} manager.InstanceDrift "Drift of the instance"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/drift [get]
+func (h *Handler) GetInstanceDrift() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		drift, err := h.InstanceManager.GetInstanceDrift(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance drift: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(drift); err != nil {
+			http.Error(w, "Failed to encode drift: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetDrift godoc
+// @Summary List the instances whose definition differs from their runtime state
+// @Description Compares every instance as /instances/{name}/drift does, listing those with any discrepancy, so that reconciling restarts can be planned
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} manager.DriftSummary "Instances with drift"
+// @Router /drift [get]
+func (h *Handler) GetDrift() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary := h.InstanceManager.GetDrift()
+
+		// Only the instances the caller may see are listed
+		visible := summary.Instances[:0]
+		for _, drift := range summary.Instances {
+			if visibleTo(r, drift.Name) {
+				visible = append(visible, drift)
+			}
+		}
+		summary.Instances = visible
+		summary.Drifted = len(visible)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(summary); err != nil {
+			http.Error(w, "Failed to encode drift: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"llamactl/pkg/events"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// StreamEvents godoc
+// @Summary Stream instance events
+// @Description Streams instance lifecycle events as Server-Sent Events. Each event carries a stable reason code and a human readable message. A client reconnecting with the Last-Event-ID header first gets the events it missed that are still kept for replay.
+// @Tags events
+// @Security ApiKeyAuth
+// @Produces text/event-stream
+// @Param instance query string false "Comma-separated list of instance names to filter on"
+// @Param Last-Event-ID header string false "ID of the last event received, to resume after"
+// @Success 200 {object} events.Event "Stream of events"
+// @Failure 500 {string} string "Streaming not supported"
+// @Router /events [get]
+func (h *Handler) StreamEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		filter := map[string]bool{}
+		if names := r.URL.Query().Get("instance"); names != "" {
+			for _, name := range strings.Split(names, ",") {
+				filter[strings.TrimSpace(name)] = true
+			}
+		}
+
+		ch, unsubscribe := h.InstanceManager.SubscribeEvents()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		send := func(event events.Event) {
+			if len(filter) > 0 && !filter[event.Instance] {
+				return
+			}
+			if !visibleTo(r, event.Instance) {
+				return
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			flusher.Flush()
+		}
+
+		// Subscribed first, so events published while replaying are not missed, only skipped
+		// when they were replayed
+		var lastID uint64
+		if resume, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+			lastID = resume
+			for _, event := range h.InstanceManager.EventsSince(resume) {
+				send(event)
+				lastID = event.ID
+			}
+		}
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-ch:
+				if !ok {
+					return
+				}
+				if event.ID <= lastID {
+					continue
+				}
+				send(event)
+			}
+		}
+	}
+}
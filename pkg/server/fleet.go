@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"llamactl/pkg/manager"
+	"net/http"
+	"strconv"
+)
+
+// maxFleetBodySize bounds the fleet file of an apply request
+const maxFleetBodySize = 16 * 1024 * 1024
+
+// FleetStatus is the state of fleet watch mode
+type FleetStatus struct {
+	Source    string             `json:"source"`
+	LastPlan  *manager.FleetPlan `json:"last_plan,omitempty"`
+	LastError string             `json:"last_error,omitempty"`
+}
+
+// ApplyFleet godoc
+// @Summary Apply a fleet file
+// @Description Reconciles the instances with the YAML fleet file in the request body: missing instances are created, drifted options updated, and instances started or stopped to match their desired state. With prune=true, instances not listed in the file are stopped and deleted. With dry_run=true, the plan is returned without changing anything.
+// @Tags fleet
+// @Security ApiKeyAuth
+// @Accept application/yaml
+// @Produces json
+// @Param dry_run query bool false "Only report the plan"
+// @Param prune query bool false "Delete instances not listed in the file"
+// @Success 200 {object} manager.FleetPlan "Applied or planned actions"
+// @Failure 400 {string} string "Invalid fleet file"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /fleet/apply [post]
+func (h *Handler) ApplyFleet() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var opts manager.ApplyOptions
+		opts.DryRun, _ = strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		opts.Prune, _ = strconv.ParseBool(r.URL.Query().Get("prune"))
+
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxFleetBodySize))
+		if err != nil {
+			http.Error(w, "Failed to read fleet file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		fleet, err := manager.ParseFleet(data)
+		if err != nil {
+			http.Error(w, "Invalid fleet file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		plan, err := h.managerFor(r).ApplyFleet(fleet, opts)
+		if err != nil {
+			http.Error(w, "Failed to apply fleet: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(plan); err != nil {
+			http.Error(w, "Failed to encode fleet plan: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetFleetStatus godoc
+// @Summary Get the fleet watch mode status
+// @Description Returns the plan of the most recent reconciliation against the configured fleet source, and the error of the most recent one if it failed
+// @Tags fleet
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} FleetStatus "Fleet watch mode status"
+// @Failure 404 {string} string "Fleet watch mode is not enabled"
+// @Router /fleet/status [get]
+func (h *Handler) GetFleetStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.fleetWatcher == nil {
+			http.Error(w, "Fleet watch mode is not enabled", http.StatusNotFound)
+			return
+		}
+
+		status := FleetStatus{Source: h.cfg.Fleet.Source}
+		status.LastPlan, status.LastError = h.fleetWatcher.Last()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode fleet status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
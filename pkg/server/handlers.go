@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"llamactl/pkg/backends"
@@ -12,23 +13,83 @@ import (
 	"llamactl/pkg/config"
 	"llamactl/pkg/instance"
 	"llamactl/pkg/manager"
+	"llamactl/pkg/models"
+	"llamactl/pkg/quota"
+	"llamactl/pkg/sinks"
+	"llamactl/pkg/storage"
+	"llamactl/pkg/validation"
+	"log"
 	"net/http"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
 type Handler struct {
 	InstanceManager manager.InstanceManager
 	cfg             config.AppConfig
+	metrics         *proxyMetrics
+	models          *models.Registry
+	modelIndex      *models.Indexer       // nil when model indexing is disabled
+	fleetWatcher    *manager.FleetWatcher // nil when fleet watch mode is disabled
+	quotas          *quota.Tracker
+	exporter        *sinks.Exporter // nil when no sinks are configured
+	settingsMu      sync.RWMutex    // Guards the instances settings of cfg changed at runtime
+	logReads        chan struct{}   // Slots of the whole logs read at the same time, nil if unlimited
+	onDemand        onDemandStarts
 }
 
 func NewHandler(im manager.InstanceManager, cfg config.AppConfig) *Handler {
-	return &Handler{
+	return NewHandlerWithStore(im, cfg, nil)
+}
+
+// NewHandlerWithStore creates a handler that persists API key usage to store
+func NewHandlerWithStore(im manager.InstanceManager, cfg config.AppConfig, store storage.Store) *Handler {
+	h := &Handler{
 		InstanceManager: im,
 		cfg:             cfg,
+		metrics:         newProxyMetrics(),
+		models:          models.NewRegistry(cfg.Models),
+		quotas:          quota.NewTracker(cfg.Auth.KeyQuotas, store),
+	}
+	h.metrics.registry.MustRegister(restartBudgetCollector{im: im}, logQuotaCollector{im: im})
+	if cfg.Instances.LogReadConcurrency > 0 {
+		h.logReads = make(chan struct{}, cfg.Instances.LogReadConcurrency)
+	}
+	if len(cfg.ModelIndex.Dirs) > 0 {
+		h.modelIndex = models.NewIndexer(cfg.ModelIndex.Dirs)
+		h.modelIndex.Start(time.Duration(cfg.ModelIndex.RescanInterval) * time.Second)
+	}
+	if cfg.Fleet.Source != "" {
+		h.fleetWatcher = manager.NewFleetWatcher(im, cfg.Fleet.Source, cfg.Fleet.Prune)
+		h.fleetWatcher.Start(time.Duration(cfg.Fleet.Interval) * time.Second)
+	}
+	return h
+}
+
+// SetExporter exports the proxied requests to the sinks of exporter and serves its status
+func (h *Handler) SetExporter(exporter *sinks.Exporter) {
+	h.exporter = exporter
+}
+
+// Shutdown stops background model indexing and fleet reconciliation, and writes the API
+// key usage counters that have not been persisted yet. Call it before shutting down the
+// instance manager.
+func (h *Handler) Shutdown() {
+	if h.modelIndex != nil {
+		h.modelIndex.Stop()
+	}
+	if h.fleetWatcher != nil {
+		h.fleetWatcher.Stop()
+	}
+	if err := h.quotas.Flush(); err != nil {
+		log.Printf("Failed to persist API key usage: %v", err)
 	}
 }
 
@@ -116,10 +177,11 @@ func (h *Handler) LlamaServerListDevicesHandler() http.HandlerFunc {
 
 // ListInstances godoc
 // @Summary List all instances
-// @Description Returns a list of all instances managed by the server
+// @Description Returns a list of all instances managed by the server. With verbose=true each instance includes its proxy and admission queue stats.
 // @Tags instances
 // @Security ApiKeyAuth
 // @Produces json
+// @Param verbose query bool false "Include stats"
 // @Success 200 {array} instance.Process "List of instances"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /instances [get]
@@ -130,9 +192,36 @@ func (h *Handler) ListInstances() http.HandlerFunc {
 			http.Error(w, "Failed to list instances: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		// Callers limited to namespaces only see their instances
+		instances = slices.DeleteFunc(instances, func(inst *instance.Process) bool {
+			return !visibleTo(r, inst.Name)
+		})
+		if filter := r.URL.Query().Get("status"); filter != "" {
+			var statuses []instance.InstanceStatus
+			for name := range strings.SplitSeq(filter, ",") {
+				status, ok := instance.ParseStatus(strings.TrimSpace(name))
+				if !ok {
+					http.Error(w, "Unknown instance status: "+name, http.StatusBadRequest)
+					return
+				}
+				statuses = append(statuses, status)
+			}
+			instances = slices.DeleteFunc(instances, func(inst *instance.Process) bool {
+				return !slices.Contains(statuses, inst.GetStatus())
+			})
+		}
+
+		var response any = instances
+		if verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose")); verbose {
+			response, err = withStats(instances)
+			if err != nil {
+				http.Error(w, "Failed to encode instances: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
 
 		// Marshal to bytes first to set Content-Length header
-		data, err := json.Marshal(instances)
+		data, err := json.Marshal(response)
 		if err != nil {
 			http.Error(w, "Failed to encode instances: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -144,6 +233,30 @@ func (h *Handler) ListInstances() http.HandlerFunc {
 	}
 }
 
+// withStats adds "stats" and "queue" fields to the JSON representation of each instance.
+// Stats are read from atomic counters, so this never blocks proxied requests.
+func withStats(instances []*instance.Process) ([]map[string]json.RawMessage, error) {
+	result := make([]map[string]json.RawMessage, 0, len(instances))
+	for _, inst := range instances {
+		data, err := json.Marshal(inst)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		if fields["stats"], err = json.Marshal(inst.GetStats()); err != nil {
+			return nil, err
+		}
+		if fields["queue"], err = json.Marshal(inst.GetQueueStats()); err != nil {
+			return nil, err
+		}
+		result = append(result, fields)
+	}
+	return result, nil
+}
+
 // CreateInstance godoc
 // @Summary Create and start a new instance
 // @Description Creates a new instance with the provided configuration options
@@ -154,7 +267,7 @@ func (h *Handler) ListInstances() http.HandlerFunc {
 // @Param name path string true "Instance Name"
 // @Param options body instance.CreateInstanceOptions true "Instance configuration options"
 // @Success 201 {object} instance.Process "Created instance details"
-// @Failure 400 {string} string "Invalid request body"
+// @Failure 400 {object} ValidationErrorResponse "Invalid instance options"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /instances/{name} [post]
 func (h *Handler) CreateInstance() http.HandlerFunc {
@@ -167,12 +280,22 @@ func (h *Handler) CreateInstance() http.HandlerFunc {
 
 		var options instance.CreateInstanceOptions
 		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			if writeValidationError(w, validation.DecodeError(err)) {
+				return
+			}
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
-		inst, err := h.InstanceManager.CreateInstance(name, &options)
+		inst, err := h.managerFor(r).CreateInstance(name, &options)
 		if err != nil {
+			if writeValidationError(w, err) {
+				return
+			}
+			if errors.Is(err, manager.ErrPlacement) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
 			http.Error(w, "Failed to create instance: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -228,8 +351,10 @@ func (h *Handler) GetInstance() http.HandlerFunc {
 // @Produces json
 // @Param name path string true "Instance Name"
 // @Param options body instance.CreateInstanceOptions true "Instance configuration options"
+// @Param restart query bool false "Restart a running instance to apply the options now rather than on its next start"
 // @Success 200 {object} instance.Process "Updated instance details"
-// @Failure 400 {string} string "Invalid name format"
+// @Failure 400 {object} ValidationErrorResponse "Invalid instance options"
+// @Failure 403 {string} string "Config-managed instance"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /instances/{name} [put]
 func (h *Handler) UpdateInstance() http.HandlerFunc {
@@ -242,12 +367,26 @@ func (h *Handler) UpdateInstance() http.HandlerFunc {
 
 		var options instance.CreateInstanceOptions
 		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			if writeValidationError(w, validation.DecodeError(err)) {
+				return
+			}
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
 
-		inst, err := h.InstanceManager.UpdateInstance(name, &options)
+		mngr := h.managerFor(r)
+		inst, err := mngr.UpdateInstance(name, &options)
+		if restart, _ := strconv.ParseBool(r.URL.Query().Get("restart")); err == nil && restart {
+			inst, err = mngr.ApplyPendingOptions(name)
+		}
 		if err != nil {
+			if writeValidationError(w, err) {
+				return
+			}
+			if errors.Is(err, manager.ErrConfigManaged) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
 			http.Error(w, "Failed to update instance: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -260,6 +399,71 @@ func (h *Handler) UpdateInstance() http.HandlerFunc {
 	}
 }
 
+// ValidationErrorResponse is the body of a 400 response to an instance definition that
+// failed validation
+type ValidationErrorResponse struct {
+	Error  string                  `json:"error"`
+	Errors []validation.FieldError `json:"errors"`
+}
+
+// writeValidationError writes err as a structured 400 response if it is a validation
+// error, and reports whether it was
+func writeValidationError(w http.ResponseWriter, err error) bool {
+	var validationErr *validation.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(ValidationErrorResponse{
+		Error:  "Invalid instance options: " + validationErr.Error(),
+		Errors: validationErr.Errors,
+	})
+	return true
+}
+
+// ValidateInstance godoc
+// @Summary Validate an instance configuration
+// @Description Validates a name and options as creating or updating the instance would, without changing anything. Every invalid field is reported with its JSON path.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Param options body instance.CreateInstanceOptions true "Instance configuration options"
+// @Success 200 {object} map[string]bool "The configuration is valid"
+// @Failure 400 {object} ValidationErrorResponse "Invalid instance options"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/validate [post]
+func (h *Handler) ValidateInstance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+
+		var options instance.CreateInstanceOptions
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			if writeValidationError(w, validation.DecodeError(err)) {
+				return
+			}
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.InstanceManager.ValidateInstance(name, &options); err != nil {
+			if writeValidationError(w, err) {
+				return
+			}
+			http.Error(w, "Failed to validate instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]bool{"valid": true}); err != nil {
+			http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
 // StartInstance godoc
 // @Summary Start a stopped instance
 // @Description Starts a specific instance by name
@@ -267,9 +471,15 @@ func (h *Handler) UpdateInstance() http.HandlerFunc {
 // @Security ApiKeyAuth
 // @Produces json
 // @Param name path string true "Instance Name"
+// @Param queue query bool false "Wait for an operation in progress instead of failing"
+// @Param wait query bool false "Wait for the instance to become healthy"
 // @Success 200 {object} instance.Process "Started instance details"
 // @Failure 400 {string} string "Invalid name format"
+// @Failure 409 {string} string "Instance is not managed by llamactl or already starting"
+// @Failure 409 {object} instance.OperationInProgressError "Another operation is in progress"
+// @Failure 500 {object} StartFailedResponse "The backend exited while starting"
 // @Failure 500 {string} string "Internal Server Error"
+// @Failure 504 {string} string "The instance did not become healthy within its readiness timeout"
 // @Router /instances/{name}/start [post]
 func (h *Handler) StartInstance() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -279,10 +489,19 @@ func (h *Handler) StartInstance() http.HandlerFunc {
 			return
 		}
 
-		inst, err := h.InstanceManager.StartInstance(name)
+		inst, err := h.operationManager(r).StartInstance(name)
+		if err == nil && r.URL.Query().Get("wait") == "true" {
+			if err = inst.WaitForHealthy(0); err != nil {
+				writeStartWaitError(w, inst, err)
+				return
+			}
+		}
+		if writeOperationError(w, r, err) {
+			return
+		}
 		if err != nil {
 			// Check if error is due to maximum running instances limit
-			if _, ok := err.(manager.MaxRunningInstancesError); ok {
+			if _, ok := err.(manager.MaxRunningInstancesError); ok || errors.Is(err, instance.ErrUnmanaged) || errors.Is(err, manager.ErrPlacement) || errors.Is(err, instance.ErrAlreadyStarting) {
 				http.Error(w, err.Error(), http.StatusConflict)
 				return
 			}
@@ -299,15 +518,52 @@ func (h *Handler) StartInstance() http.HandlerFunc {
 	}
 }
 
+// StartFailedResponse is the body of a 500 response to a start waited on whose backend
+// exited while starting
+type StartFailedResponse struct {
+	Error           string                    `json:"error"`
+	StartFailure    *instance.StartFailure    `json:"start_failure"`
+	StartupProgress *instance.StartupProgress `json:"startup_progress,omitempty"` // How far the startup got
+}
+
+// writeStartWaitError reports why a started instance did not become healthy: the exit of
+// its backend with the output explaining it, or the readiness timeout
+func writeStartWaitError(w http.ResponseWriter, inst *instance.Process, err error) {
+	var startFailure *instance.StartFailureError
+	switch {
+	case errors.As(err, &startFailure):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(StartFailedResponse{
+			Error:           err.Error(),
+			StartFailure:    startFailure.Failure,
+			StartupProgress: inst.GetStartupProgress(),
+		})
+	case !inst.IsRunning():
+		http.Error(w, "Failed to start instance: "+err.Error(), http.StatusInternalServerError)
+	default:
+		message := "Instance did not become healthy: " + err.Error()
+		if progress := inst.GetStartupProgress(); progress != nil && progress.Percent != nil {
+			message += fmt.Sprintf(" (startup reached %s, %d%%)", progress.Phase, *progress.Percent)
+		}
+		http.Error(w, message, http.StatusGatewayTimeout)
+	}
+}
+
 // StopInstance godoc
 // @Summary Stop a running instance
-// @Description Stops a specific instance by name
+// @Description Stops a specific instance by name. When require_stop_confirmation is enabled, a disruptive stop is refused unless confirm=true is passed.
 // @Tags instances
 // @Security ApiKeyAuth
 // @Produces json
 // @Param name path string true "Instance Name"
+// @Param queue query bool false "Wait for an operation in progress instead of failing"
+// @Param confirm query bool false "Confirm a disruptive stop"
 // @Success 200 {object} instance.Process "Stopped instance details"
 // @Failure 400 {string} string "Invalid name format"
+// @Failure 409 {object} manager.StopImpact "Stop would be disruptive and was not confirmed"
+// @Failure 409 {string} string "Instance is not managed by llamactl"
+// @Failure 409 {object} instance.OperationInProgressError "Another operation is in progress"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /instances/{name}/stop [post]
 func (h *Handler) StopInstance() http.HandlerFunc {
@@ -318,8 +574,29 @@ func (h *Handler) StopInstance() http.HandlerFunc {
 			return
 		}
 
-		inst, err := h.InstanceManager.StopInstance(name)
+		if h.instancesSettings().RequireStopConfirmation && r.URL.Query().Get("confirm") != "true" {
+			impact, err := manager.EvaluateStopImpact(h.InstanceManager, h.cfg.Services, name)
+			if err != nil {
+				http.Error(w, "Failed to evaluate stop impact: "+err.Error(), http.StatusNotFound)
+				return
+			}
+			if impact.Verdict == manager.StopDisruptive {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(impact)
+				return
+			}
+		}
+
+		inst, err := h.operationManager(r).StopInstance(name)
+		if writeOperationError(w, r, err) {
+			return
+		}
 		if err != nil {
+			if errors.Is(err, instance.ErrUnmanaged) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
 			http.Error(w, "Failed to stop instance: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -332,18 +609,19 @@ func (h *Handler) StopInstance() http.HandlerFunc {
 	}
 }
 
-// RestartInstance godoc
-// @Summary Restart a running instance
-// @Description Restarts a specific instance by name
+// CancelStart godoc
+// @Summary Cancel the start of an instance
+// @Description Aborts the start of an instance that is loading its model, queued for a loading slot or waiting to be restarted. The backend is killed right away and no automatic restart follows. Unlike a stop, the cancellation does not wait for a start operation in progress, e.g. one queued for a loading slot, which fails instead.
 // @Tags instances
 // @Security ApiKeyAuth
 // @Produces json
 // @Param name path string true "Instance Name"
-// @Success 200 {object} instance.Process "Restarted instance details"
+// @Success 200 {object} instance.Process "Stopped instance details"
 // @Failure 400 {string} string "Invalid name format"
+// @Failure 409 {string} string "Instance is not starting, or not managed by llamactl"
 // @Failure 500 {string} string "Internal Server Error"
-// @Router /instances/{name}/restart [post]
-func (h *Handler) RestartInstance() http.HandlerFunc {
+// @Router /instances/{name}/cancel-start [post]
+func (h *Handler) CancelStart() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := chi.URLParam(r, "name")
 		if name == "" {
@@ -351,9 +629,13 @@ func (h *Handler) RestartInstance() http.HandlerFunc {
 			return
 		}
 
-		inst, err := h.InstanceManager.RestartInstance(name)
+		inst, err := h.managerFor(r).CancelStart(name)
 		if err != nil {
-			http.Error(w, "Failed to restart instance: "+err.Error(), http.StatusInternalServerError)
+			if errors.Is(err, instance.ErrNotStarting) || errors.Is(err, instance.ErrUnmanaged) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to cancel start: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
@@ -365,46 +647,78 @@ func (h *Handler) RestartInstance() http.HandlerFunc {
 	}
 }
 
-// DeleteInstance godoc
-// @Summary Delete an instance
-// @Description Stops and removes a specific instance by name
+// SignalRequest is the body of a signal sent to the backend of an instance
+type SignalRequest struct {
+	Signal string `json:"signal"` // Name of the signal, with or without its SIG prefix, e.g. "USR1"
+}
+
+// SignalInstance godoc
+// @Summary Send a signal to the backend of an instance
+// @Description Sends a signal, such as TERM, KILL, HUP or USR1, to the backend of a running instance, to its whole process group on Linux and macOS. A backend the signal makes exit is handled as any other exit, and restarted per its policy. Only KILL can be sent on Windows.
 // @Tags instances
 // @Security ApiKeyAuth
+// @Accept json
+// @Produces json
 // @Param name path string true "Instance Name"
-// @Success 204 "No Content"
-// @Failure 400 {string} string "Invalid name format"
+// @Param request body SignalRequest true "Signal to send"
+// @Success 200 {object} instance.Process "Instance details"
+// @Failure 400 {string} string "Invalid request or unsupported signal"
+// @Failure 409 {string} string "Instance is not running, or not managed by llamactl"
 // @Failure 500 {string} string "Internal Server Error"
-// @Router /instances/{name} [delete]
-func (h *Handler) DeleteInstance() http.HandlerFunc {
+// @Router /instances/{name}/signal [post]
+func (h *Handler) SignalInstance() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := chi.URLParam(r, "name")
 		if name == "" {
 			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
 			return
 		}
+		var req SignalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		sig, err := instance.ParseSignal(req.Signal)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
-		if err := h.InstanceManager.DeleteInstance(name); err != nil {
-			http.Error(w, "Failed to delete instance: "+err.Error(), http.StatusInternalServerError)
+		inst, err := h.managerFor(r).SignalInstance(name, sig)
+		if err != nil {
+			if errors.Is(err, instance.ErrNotRunning) || errors.Is(err, instance.ErrUnmanaged) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to signal instance: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusNoContent)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inst); err != nil {
+			http.Error(w, "Failed to encode instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 }
 
-// GetInstanceLogs godoc
-// @Summary Get logs from a specific instance
-// @Description Returns the logs from a specific instance by name with optional line limit
+// RestartInstance godoc
+// @Summary Restart an instance
+// @Description Stops a specific instance by name, cancelling any pending automatic restart, and starts it again once its port is free. A stopped instance is started. With if_binary_changed, only a running instance whose backend binary changed since its process was launched, by checksum, is restarted, and the response tells whether it was.
 // @Tags instances
 // @Security ApiKeyAuth
+// @Produces json
 // @Param name path string true "Instance Name"
-// @Param lines query string false "Number of lines to retrieve (default: all lines)"
-// @Produces text/plain
-// @Success 200 {string} string "Instance logs"
-// @Failure 400 {string} string "Invalid name format or lines parameter"
+// @Param queue query bool false "Wait for an operation in progress instead of failing"
+// @Param if_binary_changed query bool false "Only restart if the backend binary changed since the process was launched"
+// @Success 200 {object} instance.Process "Restarted instance details"
+// @Success 200 {object} manager.BinaryRestart "With if_binary_changed: whether the instance was restarted, and the instance"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 409 {string} string "Instance is not managed by llamactl, or the maximum number of running instances is reached"
+// @Failure 409 {object} instance.OperationInProgressError "Another operation is in progress"
 // @Failure 500 {string} string "Internal Server Error"
-// @Router /instances/{name}/logs [get]
-func (h *Handler) GetInstanceLogs() http.HandlerFunc {
+// @Router /instances/{name}/restart [post]
+func (h *Handler) RestartInstance() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := chi.URLParam(r, "name")
 		if name == "" {
@@ -412,47 +726,50 @@ func (h *Handler) GetInstanceLogs() http.HandlerFunc {
 			return
 		}
 
-		lines := r.URL.Query().Get("lines")
-		if lines == "" {
-			lines = "-1"
-		}
+		ifBinaryChanged, _ := strconv.ParseBool(r.URL.Query().Get("if_binary_changed"))
 
-		num_lines, err := strconv.Atoi(lines)
-		if err != nil {
-			http.Error(w, "Invalid lines parameter: "+err.Error(), http.StatusBadRequest)
+		var result any
+		var err error
+		if ifBinaryChanged {
+			result, err = h.operationManager(r).RestartInstanceIfBinaryChanged(name)
+		} else {
+			result, err = h.operationManager(r).RestartInstance(name)
+		}
+		if writeOperationError(w, r, err) {
 			return
 		}
-
-		inst, err := h.InstanceManager.GetInstance(name)
 		if err != nil {
-			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			if _, ok := err.(manager.MaxRunningInstancesError); ok || errors.Is(err, instance.ErrUnmanaged) || errors.Is(err, manager.ErrPlacement) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to restart instance: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		logs, err := inst.GetLogs(num_lines)
-		if err != nil {
-			http.Error(w, "Failed to get logs: "+err.Error(), http.StatusInternalServerError)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			http.Error(w, "Failed to encode instance: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		w.Header().Set("Content-Type", "text/plain")
-		w.Write([]byte(logs))
 	}
 }
 
-// ProxyToInstance godoc
-// @Summary Proxy requests to a specific instance
-// @Description Forwards HTTP requests to the llama-server instance running on a specific port
+// ReloadInstance godoc
+// @Summary Reload an instance without downtime
+// @Description Starts a second backend for a running instance on a free port, with the options of its next start, and switches the proxy over to it once it passes its readiness check. The previous backend is stopped once its requests in flight completed. When the new backend does not become ready it is killed and the previous one keeps serving.
 // @Tags instances
 // @Security ApiKeyAuth
+// @Produces json
 // @Param name path string true "Instance Name"
-// @Success 200 "Request successfully proxied to instance"
+// @Param queue query bool false "Wait for an operation in progress instead of failing"
+// @Success 200 {object} instance.Process "Reloaded instance details"
 // @Failure 400 {string} string "Invalid name format"
-// @Failure 500 {string} string "Internal Server Error"
-// @Failure 503 {string} string "Instance is not running"
-// @Router /instances/{name}/proxy [get]
-// @Router /instances/{name}/proxy [post]
-func (h *Handler) ProxyToInstance() http.HandlerFunc {
+// @Failure 409 {string} string "Instance is not running, not managed by llamactl or cannot be reloaded"
+// @Failure 409 {object} instance.OperationInProgressError "Another operation is in progress"
+// @Failure 500 {string} string "The new backend did not become ready, the previous one keeps serving"
+// @Router /instances/{name}/reload [post]
+func (h *Handler) ReloadInstance() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := chi.URLParam(r, "name")
 		if name == "" {
@@ -460,92 +777,1210 @@ func (h *Handler) ProxyToInstance() http.HandlerFunc {
 			return
 		}
 
-		inst, err := h.InstanceManager.GetInstance(name)
-		if err != nil {
-			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+		inst, err := h.operationManager(r).ReloadInstance(name)
+		if writeOperationError(w, r, err) {
 			return
 		}
-
-		if !inst.IsRunning() {
-			http.Error(w, "Instance is not running", http.StatusServiceUnavailable)
+		if err != nil {
+			if errors.Is(err, instance.ErrNotRunning) || errors.Is(err, instance.ErrUnmanaged) || errors.Is(err, instance.ErrReloadUnsupported) || errors.Is(err, instance.ErrAlreadyStarting) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to reload instance: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Get the cached proxy for this instance
-		proxy, err := inst.GetProxy()
-		if err != nil {
-			http.Error(w, "Failed to get proxy: "+err.Error(), http.StatusInternalServerError)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inst); err != nil {
+			http.Error(w, "Failed to encode instance: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		// Strip the "/api/v1/instances/<name>/proxy" prefix from the request URL
-		prefix := fmt.Sprintf("/api/v1/instances/%s/proxy", name)
-		r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
-
-		// Update the last request time for the instance
-		inst.UpdateLastRequestTime()
-
-		// Set forwarded headers
-		r.Header.Set("X-Forwarded-Host", r.Header.Get("Host"))
-		r.Header.Set("X-Forwarded-Proto", "http")
-
-		// Forward the request using the cached proxy
-		proxy.ServeHTTP(w, r)
 	}
 }
 
-// OpenAIListInstances godoc
-// @Summary List instances in OpenAI-compatible format
-// @Description Returns a list of instances in a format compatible with OpenAI API
-// @Tags openai
+// ApplyPendingOptions godoc
+// @Summary Apply the pending options of an instance
+// @Description Restarts a running instance whose options were updated while it was running, so they take effect right away. An instance without pending options is returned unchanged.
+// @Tags instances
 // @Security ApiKeyAuth
 // @Produces json
-// @Success 200 {object} OpenAIListInstancesResponse "List of OpenAI-compatible instances"
+// @Param name path string true "Instance Name"
+// @Param queue query bool false "Wait for an operation in progress instead of failing"
+// @Success 200 {object} instance.Process "Instance details"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 409 {object} instance.OperationInProgressError "Another operation is in progress"
 // @Failure 500 {string} string "Internal Server Error"
-// @Router /v1/models [get]
-func (h *Handler) OpenAIListInstances() http.HandlerFunc {
+// @Router /instances/{name}/apply-options [post]
+func (h *Handler) ApplyPendingOptions() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		instances, err := h.InstanceManager.ListInstances()
-		if err != nil {
-			http.Error(w, "Failed to list instances: "+err.Error(), http.StatusInternalServerError)
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
 			return
 		}
 
-		openaiInstances := make([]OpenAIInstance, len(instances))
-		for i, inst := range instances {
-			openaiInstances[i] = OpenAIInstance{
-				ID:      inst.Name,
-				Object:  "model",
-				Created: inst.Created,
-				OwnedBy: "llamactl",
-			}
+		inst, err := h.operationManager(r).ApplyPendingOptions(name)
+		if writeOperationError(w, r, err) {
+			return
 		}
-
-		openaiResponse := OpenAIListInstancesResponse{
-			Object: "list",
-			Data:   openaiInstances,
+		if err != nil {
+			http.Error(w, "Failed to apply pending options: "+err.Error(), http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(openaiResponse); err != nil {
-			http.Error(w, "Failed to encode instances: "+err.Error(), http.StatusInternalServerError)
+		if err := json.NewEncoder(w).Encode(inst); err != nil {
+			http.Error(w, "Failed to encode instance: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 	}
 }
 
-// OpenAIProxy godoc
-// @Summary OpenAI-compatible proxy endpoint
-// @Description Handles all POST requests to /v1/*, routing to the appropriate instance based on the request body. Requires API key authentication via the `Authorization` header.
-// @Tags openai
+// RetryInstance godoc
+// @Summary Retry a failed instance
+// @Description Starts an instance that failed, e.g. after exhausting its restart attempts, with its restart counter reset. The last error stays available as last_error.
+// @Tags instances
 // @Security ApiKeyAuth
-// @Accept json
 // @Produces json
-// @Success 200 "OpenAI response"
-// @Failure 400 {string} string "Invalid request body or instance name"
+// @Param name path string true "Instance Name"
+// @Param queue query bool false "Wait for an operation in progress instead of failing"
+// @Success 200 {object} instance.Process "Started instance details"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 409 {string} string "Instance has not failed or is not managed by llamactl"
+// @Failure 409 {object} instance.OperationInProgressError "Another operation is in progress"
 // @Failure 500 {string} string "Internal Server Error"
-// @Router /v1/ [post]
-func (h *Handler) OpenAIProxy() http.HandlerFunc {
+// @Router /instances/{name}/retry [post]
+func (h *Handler) RetryInstance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.operationManager(r).RetryInstance(name)
+		if writeOperationError(w, r, err) {
+			return
+		}
+		if err != nil {
+			if _, ok := err.(manager.MaxRunningInstancesError); ok || errors.Is(err, manager.ErrNotFailed) || errors.Is(err, instance.ErrUnmanaged) || errors.Is(err, manager.ErrPlacement) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to retry instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inst); err != nil {
+			http.Error(w, "Failed to encode instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// operationManager returns the manager making the lifecycle operations of a request. Unless
+// the request passes queue=true, an operation is refused while another one is in progress on
+// the instance; otherwise it waits its turn until the client goes away.
+func (h *Handler) operationManager(r *http.Request) manager.InstanceManager {
+	return h.managerFor(r).WithOperationOptions(manager.OperationOptions{
+		Context: r.Context(),
+		NoQueue: r.URL.Query().Get("queue") != "true",
+	})
+}
+
+// writeOperationError answers a lifecycle operation refused because of another one in
+// progress, with 409 and the operation in progress, or whose wait the client cancelled. It
+// reports whether the response has been written.
+func writeOperationError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var inProgress *instance.OperationInProgressError
+	if errors.As(err, &inProgress) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(inProgress)
+		return true
+	}
+	if ctxErr := r.Context().Err(); err != nil && ctxErr != nil && errors.Is(err, ctxErr) {
+		http.Error(w, "Operation cancelled: "+err.Error(), http.StatusServiceUnavailable)
+		return true
+	}
+	return false
+}
+
+// GetInstanceOperations godoc
+// @Summary Get lifecycle operations of an instance
+// @Description Returns the lifecycle operation in progress on an instance, the operations waiting their turn, and recent operations with their outcome
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} instance.OperationsSnapshot "Operations"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/operations [get]
+func (h *Handler) GetInstanceOperations() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inst.GetOperations()); err != nil {
+			http.Error(w, "Failed to encode operations: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DeleteInstance godoc
+// @Summary Delete an instance
+// @Description Removes a stopped instance by name, moving its definition to the trash until the trash retention runs out unless permanent is set or the trash is disabled
+// @Tags instances
+// @Security ApiKeyAuth
+// @Param name path string true "Instance Name"
+// @Param permanent query bool false "Remove the definition right away rather than moving it to the trash"
+// @Param purge_logs query bool false "Remove the log files too once the definition is purged"
+// @Success 204 "No Content"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 403 {string} string "Protected config-managed instance"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name} [delete]
+func (h *Handler) DeleteInstance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		var opts manager.DeleteOptions
+		opts.Permanent, _ = strconv.ParseBool(r.URL.Query().Get("permanent"))
+		opts.PurgeLogs, _ = strconv.ParseBool(r.URL.Query().Get("purge_logs"))
+
+		if err := h.managerFor(r).DeleteInstanceWithOptions(name, opts); err != nil {
+			if errors.Is(err, manager.ErrConfigManaged) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			http.Error(w, "Failed to delete instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetInstanceLogs godoc
+// @Summary Get logs from a specific instance
+// @Description Returns the logs from a specific instance by name with optional line limit, as plain text, JSON lines or an auto-refreshing HTML page. The format is chosen by the format parameter, or else by the Accept header. With follow, lines are streamed as they are logged.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Param name path string true "Instance Name"
+// @Param lines query string false "Number of lines to retrieve (default: all lines, 1000 for html, 10 when following)"
+// @Param format query string false "Output format: text, ndjson or html (default: from the Accept header, else text)"
+// @Param follow query bool false "Keep the response open and stream new lines (text and ndjson only)"
+// @Param since query string false "Only lines logged at or after this RFC 3339 time (ndjson only)"
+// @Param until query string false "Only lines logged at or before this RFC 3339 time (ndjson only)"
+// @Param max_bytes query int false "Most recent bytes of logs to return, up to log_response_max_size_mb (default: log_response_size_mb)"
+// @Produces text/plain
+// @Produces application/x-ndjson
+// @Produces text/html
+// @Success 200 {string} string "Instance logs, the last of them with X-Logs-Truncated if they exceed max_bytes"
+// @Failure 400 {string} string "Invalid name format, lines, format, follow, since, until or max_bytes parameter"
+// @Failure 409 {string} string "Instance is not managed by llamactl"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/logs [get]
+func (h *Handler) GetInstanceLogs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		format, err := logFormat(r)
+		if err != nil {
+			http.Error(w, "Invalid format parameter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		follow := false
+		if value := r.URL.Query().Get("follow"); value != "" {
+			if follow, err = strconv.ParseBool(value); err != nil {
+				http.Error(w, "Invalid follow parameter: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if follow && format == logFormatHTML {
+			http.Error(w, "The html format cannot be followed, it refreshes itself", http.StatusBadRequest)
+			return
+		}
+
+		var since, until time.Time
+		for param, value := range map[string]*time.Time{"since": &since, "until": &until} {
+			if raw := r.URL.Query().Get(param); raw != "" {
+				if *value, err = time.Parse(time.RFC3339, raw); err != nil {
+					http.Error(w, "Invalid "+param+" parameter: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		ranged := !since.IsZero() || !until.IsZero()
+		if ranged && (format != logFormatNDJSON || follow) {
+			http.Error(w, "since and until require the ndjson format and cannot be followed", http.StatusBadRequest)
+			return
+		}
+
+		lines := r.URL.Query().Get("lines")
+		if lines == "" {
+			lines = strconv.Itoa(defaultLogLines(format, follow))
+		}
+
+		num_lines, err := strconv.Atoi(lines)
+		if err != nil {
+			http.Error(w, "Invalid lines parameter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		maxBytes, err := h.logsMaxBytes(r)
+		if err != nil {
+			http.Error(w, "Invalid max_bytes parameter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logsError := func(err error) {
+			if errors.Is(err, instance.ErrUnmanaged) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to get logs: "+err.Error(), http.StatusInternalServerError)
+		}
+
+		if follow {
+			follower, err := inst.FollowLogs(num_lines, format == logFormatNDJSON)
+			if err != nil {
+				logsError(err)
+				return
+			}
+			defer follower.Close()
+			streamLogs(w, r, follower, format)
+			return
+		}
+
+		// Whole logs can be large, only a few of them are read at a time
+		if num_lines <= 0 || ranged {
+			release, ok := h.acquireLogRead(r)
+			if !ok {
+				return
+			}
+			defer release()
+		}
+
+		var logs io.ReadCloser
+		switch {
+		case format == logFormatNDJSON && ranged:
+			logs, err = inst.GetStructuredLogsBetween(since, until)
+		case format == logFormatNDJSON:
+			logs, err = inst.GetStructuredLogs(num_lines)
+		case num_lines <= 0:
+			logs, err = inst.GetLogsReader()
+		default:
+			var text string
+			text, err = inst.GetLogs(num_lines)
+			logs = io.NopCloser(strings.NewReader(text))
+		}
+		if err != nil {
+			logsError(err)
+			return
+		}
+		defer logs.Close()
+
+		limited, truncated, err := instance.LimitLogs(logs, maxBytes)
+		if err != nil {
+			logsError(err)
+			return
+		}
+		if truncated {
+			w.Header().Set(headerLogsTruncated, strconv.FormatInt(maxBytes, 10))
+		}
+
+		switch format {
+		case logFormatHTML:
+			// The page is bounded by max_bytes like any other response
+			text, err := io.ReadAll(limited)
+			if err != nil {
+				logsError(err)
+				return
+			}
+			writeLogsPage(w, name, string(text))
+		case logFormatNDJSON:
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			io.Copy(w, limited)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			io.Copy(w, limited)
+		}
+	}
+}
+
+// InstanceLogFilesResponse lists the log files of an instance
+type InstanceLogFilesResponse struct {
+	RetentionDays      int                    `json:"retention_days"`
+	DeletedByRetention int64                  `json:"deleted_by_retention"`
+	Files              []instance.LogFileInfo `json:"files"`
+}
+
+// GetInstanceLogFiles godoc
+// @Summary List log files of a specific instance
+// @Description Returns the current and rotated log files of an instance with their sizes and ages
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} InstanceLogFilesResponse "Instance log files"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/logs/files [get]
+func (h *Handler) GetInstanceLogFiles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		files, err := inst.GetLogFiles()
+		if err != nil {
+			http.Error(w, "Failed to list log files: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := InstanceLogFilesResponse{
+			DeletedByRetention: inst.LogRetentionDeletedCount(),
+			Files:              files,
+		}
+		if opts := inst.GetOptions(); opts != nil && opts.LogRetentionDays != nil {
+			response.RetentionDays = *opts.LogRetentionDays
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Failed to encode log files: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetInstanceStats godoc
+// @Summary Get proxy stats for an instance
+// @Description Returns request, error, in-flight and latency stats for requests proxied to an instance
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} instance.StatsSnapshot "Proxy stats"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/stats [get]
+func (h *Handler) GetInstanceStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inst.GetStats()); err != nil {
+			http.Error(w, "Failed to encode stats: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetInstanceSLO godoc
+// @Summary Get the SLO compliance of an instance
+// @Description Evaluates the service level objectives of an instance over their window and returns its compliance, burn rate and whether it is degraded. Probe requests and requests completed during warm-up are not counted.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} instance.SLOStatus "SLO compliance"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 404 {string} string "Instance has no service level objectives"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/slo [get]
+func (h *Handler) GetInstanceSLO() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		status, err := h.InstanceManager.GetSLOStatus(name)
+		if err != nil {
+			if errors.Is(err, manager.ErrNoSLO) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to get SLO status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode SLO status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// UpdateInstanceSLO godoc
+// @Summary Update the SLO of an instance
+// @Description Replaces the service level objectives of an instance without restarting it and returns its compliance with the new objectives. Objectives without a latency or error rate objective remove the SLO.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Param objectives body instance.SLOOptions true "Service level objectives"
+// @Success 200 {object} instance.SLOStatus "SLO compliance"
+// @Success 204 "SLO removed"
+// @Failure 400 {object} ValidationErrorResponse "Invalid objectives"
+// @Failure 403 {string} string "Config-managed instance"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/slo [put]
+func (h *Handler) UpdateInstanceSLO() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		var objectives instance.SLOOptions
+		if err := json.NewDecoder(r.Body).Decode(&objectives); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		status, err := h.managerFor(r).UpdateSLO(name, &objectives)
+		if err != nil {
+			if writeValidationError(w, err) {
+				return
+			}
+			if errors.Is(err, manager.ErrConfigManaged) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			http.Error(w, "Failed to update SLO: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if status == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode SLO status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// AllowedPathsRequest is the body of an update of the allowed paths of an instance, and the
+// response with its effective allowed paths
+type AllowedPathsRequest struct {
+	AllowedPaths []string `json:"allowed_paths"`
+}
+
+// UpdateAllowedPaths godoc
+// @Summary Update the allowed paths of an instance
+// @Description Replaces the paths the proxy forwards to the backend of an instance, as prefixes or glob patterns, without restarting it. Requests for other paths get 404 Not Found. No paths allow every path.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Param paths body AllowedPathsRequest true "Allowed paths"
+// @Success 200 {object} AllowedPathsRequest "Effective allowed paths"
+// @Failure 400 {object} ValidationErrorResponse "Invalid paths"
+// @Failure 403 {string} string "Config-managed instance"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/allowed-paths [put]
+func (h *Handler) UpdateAllowedPaths() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		var req AllowedPathsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.managerFor(r).UpdateAllowedPaths(name, req.AllowedPaths)
+		if err != nil {
+			if writeValidationError(w, err) {
+				return
+			}
+			if errors.Is(err, manager.ErrConfigManaged) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			http.Error(w, "Failed to update allowed paths: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(AllowedPathsRequest{AllowedPaths: inst.AllowedPaths()}); err != nil {
+			http.Error(w, "Failed to encode allowed paths: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetEffectiveOptions godoc
+// @Summary Get the effective options of an instance
+// @Description Returns the options an instance runs with after merging global defaults and its own options. With explain=true every field is returned with its value and source (default, template:<name>, explicit or inferred).
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Param explain query bool false "Report the source of every field"
+// @Param pending query bool false "Return the options the next start uses, including those updated while running"
+// @Success 200 {object} instance.CreateInstanceOptions "Effective options"
+// @Success 200 {object} map[string]instance.EffectiveOption "Effective options with their sources"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/options/effective [get]
+func (h *Handler) GetEffectiveOptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Secret environment values are never returned
+		var response any = inst.GetOptions().MaskedSecrets()
+		if pending, _ := strconv.ParseBool(r.URL.Query().Get("pending")); pending {
+			response = inst.GetDesiredOptions().MaskedSecrets()
+		}
+		if explain, _ := strconv.ParseBool(r.URL.Query().Get("explain")); explain {
+			response, err = inst.ExplainOptions()
+			if err != nil {
+				http.Error(w, "Failed to explain options: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, "Failed to encode options: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetOptionAliases godoc
+// @Summary Get the aliases of option keys
+// @Description Returns the alternative spellings accepted for the keys of instance options, camelCase and llama.cpp spellings such as n_ctx, with the canonical key each maps onto. Responses always use the canonical keys.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} instance.OptionAliases "Aliases of the top-level and backend option keys"
+// @Router /options/aliases [get]
+func (h *Handler) GetOptionAliases() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(instance.Aliases()); err != nil {
+			http.Error(w, "Failed to encode option aliases: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetInstanceLastExit godoc
+// @Summary Get the last crash of an instance
+// @Description Returns the exit code or signal, reason, time and last lines of output of the most recent crash of the backend. It is kept across restarts until the next crash.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} instance.LastExit "Last crash"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 404 {string} string "The instance never crashed"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/last-exit [get]
+func (h *Handler) GetInstanceLastExit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		lastExit := inst.GetLastExit()
+		if lastExit == nil {
+			http.Error(w, "Instance "+name+" has not crashed", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(lastExit); err != nil {
+			http.Error(w, "Failed to encode last exit: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetInstanceCommand godoc
+// @Summary Preview the command line of an instance
+// @Description Returns the command, arguments and launch wrapper an instance is started with, and the full argument vector that is executed
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} instance.CommandPreview "Command preview"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 409 {string} string "Instance is not managed by llamactl"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/command [get]
+func (h *Handler) GetInstanceCommand() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		preview, err := inst.GetCommandPreview()
+		if err != nil {
+			if errors.Is(err, instance.ErrUnmanaged) {
+				http.Error(w, "Failed to build command: "+err.Error(), http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to build command: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(preview); err != nil {
+			http.Error(w, "Failed to encode command: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetInstanceQueue godoc
+// @Summary Get admission queue stats for an instance
+// @Description Returns the number of active and queued requests for an instance, broken down by priority
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} instance.QueueStats "Admission queue stats"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/queue [get]
+func (h *Handler) GetInstanceQueue() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inst.GetQueueStats()); err != nil {
+			http.Error(w, "Failed to encode queue stats: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ListInstanceRequests godoc
+// @Summary List the in-flight requests of an instance
+// @Description Returns the proxied requests an instance is serving, oldest first, with their age. Request bodies are not recorded.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {array} instance.InFlightRequest "In-flight requests"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/requests [get]
+func (h *Handler) ListInstanceRequests() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(inst.GetRequests()); err != nil {
+			http.Error(w, "Failed to encode requests: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// CancelInstanceRequest godoc
+// @Summary Cancel an in-flight request of an instance
+// @Description Cancels a proxied request, aborting its backend request. A client still waiting for the response headers receives 503 Service Unavailable.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Param name path string true "Instance Name"
+// @Param id path string true "Request ID"
+// @Success 204 "Request cancelled"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 404 {string} string "Request not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/requests/{id} [delete]
+func (h *Handler) CancelInstanceRequest() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		id := chi.URLParam(r, "id")
+		if !inst.CancelRequest(id) {
+			http.Error(w, "Request not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Cancelled request %s of instance %s", id, name)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetStopImpact godoc
+// @Summary Report the impact of stopping an instance
+// @Description Dry run of a stop: reports in-flight and queued requests, the services routing to the instance, whether their other members can absorb its traffic, and the services depending on them, with a safe or disruptive verdict
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} manager.StopImpact "Stop impact report"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 404 {string} string "Instance not found"
+// @Router /instances/{name}/stop-impact [get]
+func (h *Handler) GetStopImpact() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		impact, err := manager.EvaluateStopImpact(h.InstanceManager, h.cfg.Services, name)
+		if err != nil {
+			http.Error(w, "Failed to evaluate stop impact: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(impact); err != nil {
+			http.Error(w, "Failed to encode stop impact: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetServiceHealth godoc
+// @Summary Get aggregated health of a service
+// @Description Returns a healthy, degraded or down verdict for a configured service, computed from member readiness, recent error rates and dependencies. Responds with 503 when the service is down.
+// @Tags services
+// @Security ApiKeyAuth
+// @Produces json
+// @Param alias path string true "Service Alias"
+// @Success 200 {object} manager.ServiceHealth "Service is healthy or degraded"
+// @Failure 400 {string} string "Invalid alias format"
+// @Failure 404 {string} string "Service not found"
+// @Failure 503 {object} manager.ServiceHealth "Service is down"
+// @Router /services/{alias}/health [get]
+func (h *Handler) GetServiceHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			http.Error(w, "Service alias cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		health, err := manager.EvaluateServiceHealth(h.InstanceManager, h.cfg.Services, alias)
+		if err != nil {
+			http.Error(w, "Failed to get service health: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if health.Status == manager.ServiceDown {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(health); err != nil {
+			http.Error(w, "Failed to encode service health: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// FailbackService godoc
+// @Summary Fail a service back to its primary
+// @Description Routes the requests for a service with a standby back to its primary, once the primary passes a health check. Does nothing when the primary is already active.
+// @Tags services
+// @Security ApiKeyAuth
+// @Produces json
+// @Param alias path string true "Service Alias"
+// @Success 200 {object} manager.StandbyStatus "Primary and standby state after the failback"
+// @Failure 400 {string} string "Invalid alias format"
+// @Failure 404 {string} string "Service has no standby"
+// @Failure 409 {string} string "Primary is not healthy"
+// @Router /services/{alias}/failback [post]
+func (h *Handler) FailbackService() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			http.Error(w, "Service alias cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		status, err := h.managerFor(r).Failback(alias)
+		if err != nil {
+			switch {
+			case errors.Is(err, manager.ErrNoStandby):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, manager.ErrFailbackNotPossible):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				http.Error(w, "Failed to fail back service: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode standby status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetServiceRoutes godoc
+// @Summary Get the weighted routing of a service
+// @Description Returns the weights requests for a service are split by, and the requests routed to each member, such as a stable instance and its canary
+// @Tags services
+// @Security ApiKeyAuth
+// @Produces json
+// @Param alias path string true "Service Alias"
+// @Success 200 {object} manager.RouteStatus "Weights and stats of the members"
+// @Failure 400 {string} string "Invalid alias format"
+// @Failure 404 {string} string "Service has no weighted routing"
+// @Router /services/{alias}/routes [get]
+func (h *Handler) GetServiceRoutes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			http.Error(w, "Service alias cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		status, err := h.InstanceManager.GetRoutes(alias)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode service routes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// SetServiceRoutes godoc
+// @Summary Set the weighted routing of a service
+// @Description Replaces the weights requests for a service are split by, without dropping the requests being served. The weights are kept across restarts until reset. Promoting a canary is setting its weight to 100 and that of the stable instance to 0.
+// @Tags services
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param alias path string true "Service Alias"
+// @Param rules body manager.RouteRules true "Weights, canary and guardrail"
+// @Success 200 {object} manager.RouteStatus "Weights and stats of the members"
+// @Failure 400 {string} string "Invalid weights"
+// @Failure 404 {string} string "Service not found"
+// @Router /services/{alias}/routes [put]
+func (h *Handler) SetServiceRoutes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			http.Error(w, "Service alias cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		var rules manager.RouteRules
+		if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		status, err := h.managerFor(r).SetRoutes(alias, rules)
+		if err != nil {
+			switch {
+			case errors.Is(err, manager.ErrServiceNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, manager.ErrInvalidRoutes):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to set service routes: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode service routes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ResetServiceRoutes godoc
+// @Summary Reset the weighted routing of a service
+// @Description Drops the weights set through the API or by the guardrail, going back to the weights of the configuration file, if any
+// @Tags services
+// @Security ApiKeyAuth
+// @Param alias path string true "Service Alias"
+// @Success 204 "Routing reset"
+// @Failure 400 {string} string "Invalid alias format"
+// @Failure 404 {string} string "Service not found"
+// @Router /services/{alias}/routes [delete]
+func (h *Handler) ResetServiceRoutes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			http.Error(w, "Service alias cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.managerFor(r).ResetRoutes(alias); err != nil {
+			switch {
+			case errors.Is(err, manager.ErrServiceNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, manager.ErrInvalidRoutes):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to reset service routes: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ProxyToInstance godoc
+// @Summary Proxy requests to a specific instance
+// @Description Forwards HTTP requests to the llama-server instance running on a specific port
+// @Tags instances
+// @Security ApiKeyAuth
+// @Param name path string true "Instance Name"
+// @Success 200 "Request successfully proxied to instance"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 503 {string} string "Instance is not running"
+// @Router /instances/{name}/proxy [get]
+// @Router /instances/{name}/proxy [post]
+func (h *Handler) ProxyToInstance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		setAccessLogInstance(r, name)
+
+		if !inst.IsRunning() {
+			http.Error(w, "Instance is not running", http.StatusServiceUnavailable)
+			return
+		}
+
+		// Get the cached proxy for this instance
+		proxy, err := inst.GetProxy()
+		if err != nil {
+			http.Error(w, "Failed to get proxy: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Strip the "/api/v1/instances/<name>/proxy" prefix from the request URL
+		prefix := fmt.Sprintf("/api/v1/instances/%s/proxy", name)
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+
+		// Callers of the management API reach every path, allowed_paths only restricts
+		// inference clients
+		r = r.WithContext(instance.WithManagementRequest(r.Context()))
+		if !allowRequest(w, inst, r) {
+			return
+		}
+
+		// Update the last request time for the instance
+		inst.RecordRequest(r)
+
+		// Set forwarded headers
+		r.Header.Set("X-Forwarded-Host", r.Header.Get("Host"))
+		r.Header.Set("X-Forwarded-Proto", "http")
+
+		if refuseDraining(w, inst) {
+			return
+		}
+		r, done := trackRequest(inst, r, "", false)
+		defer done()
+
+		// Forward the request using the cached proxy
+		proxy.ServeHTTP(w, r)
+	}
+}
+
+// OpenAIListInstances godoc
+// @Summary List instances in OpenAI-compatible format
+// @Description Returns a list of instances in a format compatible with OpenAI API
+// @Tags openai
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} OpenAIListInstancesResponse "List of OpenAI-compatible instances"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /v1/models [get]
+func (h *Handler) OpenAIListInstances() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instances, err := h.InstanceManager.ListInstances()
+		if err != nil {
+			http.Error(w, "Failed to list instances: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		openaiInstances := make([]OpenAIInstance, len(instances))
+		for i, inst := range instances {
+			openaiInstances[i] = OpenAIInstance{
+				ID:      inst.Name,
+				Object:  "model",
+				Created: inst.Created,
+				OwnedBy: "llamactl",
+			}
+		}
+
+		openaiResponse := OpenAIListInstancesResponse{
+			Object: "list",
+			Data:   openaiInstances,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(openaiResponse); err != nil {
+			http.Error(w, "Failed to encode instances: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// OpenAIProxy godoc
+// @Summary OpenAI-compatible proxy endpoint
+// @Description Handles all POST requests to /v1/*, routing to the appropriate instance based on the request body. Requires API key authentication via the `Authorization` header.
+// @Tags openai
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Success 200 "OpenAI response"
+// @Param X-Priority header string false "Request priority (low, normal, high)"
+// @Param X-Deadline header string false "Longest wait for admission, in seconds or as an RFC 3339 time"
+// @Header 200 {integer} X-Queue-Estimated-Wait-Ms "Wait estimated for the request when it was queued"
+// @Header 200 {string} X-Llamactl-Transform "Transform applied to the request and response"
+// @Header 200 {string} X-Llamactl-Transform-Error "Error of a transform that failed open"
+// @Failure 400 {string} string "Invalid request body or instance name"
+// @Failure 429 {object} QueueFullResponse "Admission queue is full, or the estimated wait exceeds the deadline"
+// @Failure 500 {string} string "Internal Server Error"
+// @Failure 502 {string} string "Transform failed closed"
+// @Failure 504 {string} string "Request exceeded max_request_duration"
+// @Router /v1/ [post]
+func (h *Handler) OpenAIProxy() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Read the entire body first
 		bodyBytes, err := io.ReadAll(r.Body)
@@ -568,45 +2003,50 @@ func (h *Handler) OpenAIProxy() http.HandlerFunc {
 			return
 		}
 
-		// Route to the appropriate inst based on instance name
-		inst, err := h.InstanceManager.GetInstance(modelName)
+		// Route to the appropriate inst based on instance name. Requests for a service with a
+		// standby go to whichever of its members the manager made active, and those for a
+		// service with weights to the member picked for the request.
+		name := modelName
+		routed := false
+		if member, ok := h.InstanceManager.ActiveMember(modelName); ok {
+			name = member
+		} else if member, ok := h.InstanceManager.RouteMember(modelName, r.Header.Get("X-Route-Key")); ok {
+			name, routed = member, true
+		}
+		inst, err := h.InstanceManager.GetInstance(name)
 		if err != nil {
 			http.Error(w, "Invalid instance: "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		setAccessLogInstance(r, name)
+		if routed {
+			// Counted for the member once the response is written, failed if it is a 5xx
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+			defer func() {
+				h.InstanceManager.RecordRoute(modelName, name, ww.Status() >= http.StatusInternalServerError, time.Since(start))
+			}()
+			w = ww
+		}
+		if !allowRequest(w, inst, r) {
+			return
+		}
 
-		if !inst.IsRunning() {
-			options := inst.GetOptions()
-			allowOnDemand := options != nil && options.OnDemandStart != nil && *options.OnDemandStart
-			if !allowOnDemand {
-				http.Error(w, "Instance is not running", http.StatusServiceUnavailable)
-				return
-			}
-
-			if h.InstanceManager.IsMaxRunningInstancesReached() {
-				if h.cfg.Instances.EnableLRUEviction {
-					err := h.InstanceManager.EvictLRUInstance()
-					if err != nil {
-						http.Error(w, "Cannot start Instance, failed to evict instance "+err.Error(), http.StatusInternalServerError)
-						return
-					}
-				} else {
-					http.Error(w, "Cannot start Instance, maximum number of instances reached", http.StatusConflict)
-					return
-				}
-			}
-
-			// If on-demand start is enabled, start the instance
-			if _, err := h.InstanceManager.StartInstance(modelName); err != nil {
-				http.Error(w, "Failed to start instance: "+err.Error(), http.StatusInternalServerError)
+		// Rewrite the request with the transform of the instance, if any
+		requestTransform, bodyBytes, ok := applyRequestTransform(w, r, inst, bodyBytes)
+		if !ok {
+			return
+		}
+		if requestTransform != nil {
+			requestBody = nil
+			if err := json.Unmarshal(bodyBytes, &requestBody); err != nil {
+				http.Error(w, "Invalid transformed request body", http.StatusInternalServerError)
 				return
 			}
+		}
 
-			// Wait for the instance to become healthy before proceeding
-			if err := inst.WaitForHealthy(h.cfg.Instances.OnDemandStartTimeout); err != nil { // 2 minutes timeout
-				http.Error(w, "Instance failed to become healthy: "+err.Error(), http.StatusServiceUnavailable)
-				return
-			}
+		if !h.startOnDemand(w, r, inst) {
+			return
 		}
 
 		proxy, err := inst.GetProxy()
@@ -615,17 +2055,201 @@ func (h *Handler) OpenAIProxy() http.HandlerFunc {
 			return
 		}
 
+		// The slot is held until the response, including a streamed one, has been fully written
+		release, priority, ok := h.admitRequest(w, r, inst)
+		if !ok {
+			return
+		}
+		defer release()
+
 		// Update last request time for the instance
-		inst.UpdateLastRequestTime()
+		inst.RecordRequest(r)
 
 		// Recreate the request body from the bytes we read
 		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 		r.ContentLength = int64(len(bodyBytes))
 
+		if requestTransform != nil {
+			transformer := newResponseTransformer(w, r.Context(), requestTransform)
+			defer transformer.finish()
+			w = transformer
+		}
+		if options := inst.GetOptions(); options != nil && options.NormalizeResponses != nil && *options.NormalizeResponses {
+			normalizer := newResponseNormalizer(w, modelName)
+			defer normalizer.finish()
+			w = normalizer
+		}
+
+		streaming, _ := requestBody["stream"].(bool)
+		r, done := trackRequest(inst, r, priority.String(), streaming)
+		defer done()
+
 		proxy.ServeHTTP(w, r)
 	}
 }
 
+// admitRequest waits for the admission queue of the instance to admit a request, with the
+// priority and deadline the request asks for. The returned function releases the slot. It
+// responds with the error and returns false if the request was not admitted, or the instance
+// is draining.
+func (h *Handler) admitRequest(w http.ResponseWriter, r *http.Request, inst *instance.Process) (func(), instance.Priority, bool) {
+	if refuseDraining(w, inst) {
+		return nil, instance.PriorityNormal, false
+	}
+
+	priority, err := h.requestPriority(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, priority, false
+	}
+	deadline, err := requestDeadline(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, priority, false
+	}
+
+	release, estimate, err := inst.AcquireRequestSlot(r.Context(), priority, deadline)
+	if err != nil {
+		var queueFull *instance.QueueFullError
+		var queueDeadline *instance.QueueDeadlineError
+		switch {
+		case errors.As(err, &queueFull):
+			writeQueueFull(w, queueFull)
+		case errors.As(err, &queueDeadline):
+			writeQueueDeadline(w, queueDeadline)
+		}
+		// Otherwise the client went away while queued
+		return nil, priority, false
+	}
+	if opts := inst.GetOptions(); opts != nil && opts.MaxConcurrentRequests != nil && *opts.MaxConcurrentRequests > 0 {
+		w.Header().Set("X-Queue-Estimated-Wait-Ms", strconv.FormatInt(estimate.Milliseconds(), 10))
+	}
+	return release, priority, true
+}
+
+// requestPriority resolves the admission priority of a request from the X-Priority header,
+// falling back to the priority configured for the request's API key. The configured priority
+// caps the header, which can only lower it, so clients cannot raise their own priority.
+func (h *Handler) requestPriority(r *http.Request) (instance.Priority, error) {
+	keyPriority, hasKeyPriority := instance.PriorityNormal, false
+	if configured, ok := h.cfg.Auth.KeyPriorities[extractAPIKey(r)]; ok {
+		if priority, err := instance.ParsePriority(configured); err == nil {
+			keyPriority, hasKeyPriority = priority, true
+		}
+	}
+
+	header := r.Header.Get("X-Priority")
+	if header == "" {
+		return keyPriority, nil
+	}
+	priority, err := instance.ParsePriority(header)
+	if err != nil {
+		return priority, err
+	}
+	if hasKeyPriority {
+		priority = min(priority, keyPriority)
+	}
+	return priority, nil
+}
+
+// requestDeadline returns how long a request can wait for admission from the X-Deadline
+// header, either a number of seconds or an RFC 3339 time, or 0 without a deadline
+func requestDeadline(r *http.Request) (time.Duration, error) {
+	header := strings.TrimSpace(r.Header.Get("X-Deadline"))
+	if header == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(header, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	at, err := time.Parse(time.RFC3339, header)
+	if err != nil {
+		return 0, fmt.Errorf("invalid X-Deadline %q: must be a positive number of seconds or an RFC 3339 time", header)
+	}
+	// A deadline that has passed still admits a request that does not need to wait
+	return max(time.Until(at), time.Nanosecond), nil
+}
+
+// allowRequest responds 404 Not Found to a request for a path the instance does not allow,
+// and reports whether the request can be proxied
+func allowRequest(w http.ResponseWriter, inst *instance.Process, r *http.Request) bool {
+	if inst.AllowsRequest(r) {
+		return true
+	}
+	http.NotFound(w, r)
+	return false
+}
+
+// refuseDraining responds 503 Service Unavailable to a request for an instance draining
+// before it stops, which only completes the requests it is serving, and reports whether it did
+func refuseDraining(w http.ResponseWriter, inst *instance.Process) bool {
+	if !inst.IsDraining() {
+		return false
+	}
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, "Instance is draining before it stops", http.StatusServiceUnavailable)
+	return true
+}
+
+// trackRequest registers a proxied request as in flight on the instance serving it. The
+// API key is only recorded in its masked form.
+func trackRequest(inst *instance.Process, r *http.Request, priority string, streaming bool) (*http.Request, func()) {
+	var apiKey string
+	if key := extractAPIKey(r); key != "" {
+		apiKey = maskAPIKey(key)
+	}
+	return inst.TrackRequest(r, apiKey, priority, streaming)
+}
+
+// QueueFullResponse is the body of a 429 response for a request rejected by the admission queue
+type QueueFullResponse struct {
+	Error QueueFullErrorDetail `json:"error"`
+}
+
+type QueueFullErrorDetail struct {
+	Message         string              `json:"message"`
+	Type            string              `json:"type"`
+	Priority        string              `json:"priority"`
+	Queue           instance.QueueStats `json:"queue"`
+	EstimatedWaitMs int64               `json:"estimated_wait_ms,omitempty"` // For requests rejected at their deadline
+	DeadlineMs      int64               `json:"deadline_ms,omitempty"`
+}
+
+// writeQueueFull sends a 429 response describing the rejected priority and the queue state
+func writeQueueFull(w http.ResponseWriter, err *instance.QueueFullError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(QueueFullResponse{
+		Error: QueueFullErrorDetail{
+			Message:  err.Error(),
+			Type:     "queue_full",
+			Priority: err.Priority.String(),
+			Queue:    err.Stats,
+		},
+	})
+}
+
+// writeQueueDeadline sends a 429 response for a request that cannot be admitted within its
+// deadline, with the wait estimated for it
+func writeQueueDeadline(w http.ResponseWriter, err *instance.QueueDeadlineError) {
+	errorType := "queue_deadline"
+	if err.TimedOut {
+		errorType = "queue_timeout"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(QueueFullResponse{
+		Error: QueueFullErrorDetail{
+			Message:         err.Error(),
+			Type:            errorType,
+			Priority:        err.Priority.String(),
+			Queue:           err.Stats,
+			EstimatedWaitMs: err.EstimatedWait.Milliseconds(),
+			DeadlineMs:      err.MaxWait.Milliseconds(),
+		},
+	})
+}
+
 func (h *Handler) LlamaCppProxy(onDemandStart bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
@@ -642,6 +2266,7 @@ func (h *Handler) LlamaCppProxy(onDemandStart bool) http.HandlerFunc {
 			http.Error(w, "Invalid instance: "+err.Error(), http.StatusBadRequest)
 			return
 		}
+		setAccessLogInstance(r, name)
 
 		options := inst.GetOptions()
 		if options == nil {
@@ -654,37 +2279,19 @@ func (h *Handler) LlamaCppProxy(onDemandStart bool) http.HandlerFunc {
 			return
 		}
 
-		if !inst.IsRunning() {
-
-			if !(onDemandStart && options.OnDemandStart != nil && *options.OnDemandStart) {
-				http.Error(w, "Instance is not running", http.StatusServiceUnavailable)
-				return
-			}
-
-			if h.InstanceManager.IsMaxRunningInstancesReached() {
-				if h.cfg.Instances.EnableLRUEviction {
-					err := h.InstanceManager.EvictLRUInstance()
-					if err != nil {
-						http.Error(w, "Cannot start Instance, failed to evict instance "+err.Error(), http.StatusInternalServerError)
-						return
-					}
-				} else {
-					http.Error(w, "Cannot start Instance, maximum number of instances reached", http.StatusConflict)
-					return
-				}
-			}
-
-			// If on-demand start is enabled, start the instance
-			if _, err := h.InstanceManager.StartInstance(name); err != nil {
-				http.Error(w, "Failed to start instance: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
+		// Strip the "/llama-cpp/<name>" prefix from the request URL
+		prefix := fmt.Sprintf("/llama-cpp/%s", name)
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		if !allowRequest(w, inst, r) {
+			return
+		}
 
-			// Wait for the instance to become healthy before proceeding
-			if err := inst.WaitForHealthy(h.cfg.Instances.OnDemandStartTimeout); err != nil { // 2 minutes timeout
-				http.Error(w, "Instance failed to become healthy: "+err.Error(), http.StatusServiceUnavailable)
-				return
-			}
+		if !inst.IsRunning() && !onDemandStart {
+			http.Error(w, "Instance is not running", http.StatusServiceUnavailable)
+			return
+		}
+		if !h.startOnDemand(w, r, inst) {
+			return
 		}
 
 		proxy, err := inst.GetProxy()
@@ -693,12 +2300,15 @@ func (h *Handler) LlamaCppProxy(onDemandStart bool) http.HandlerFunc {
 			return
 		}
 
-		// Strip the "/llama-cpp/<name>" prefix from the request URL
-		prefix := fmt.Sprintf("/llama-cpp/%s", name)
-		r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		if refuseDraining(w, inst) {
+			return
+		}
 
 		// Update the last request time for the instance
-		inst.UpdateLastRequestTime()
+		inst.RecordRequest(r)
+
+		r, done := trackRequest(inst, r, "", false)
+		defer done()
 
 		proxy.ServeHTTP(w, r)
 	}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"llamactl/pkg/config"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/quota"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// scopeRanks orders the permission scopes, each including the ones below it
+var scopeRanks = map[string]int{
+	config.ScopeReadOnly: 1,
+	config.ScopeOperator: 2,
+	config.ScopeAdmin:    3,
+}
+
+// Identity is the authenticated caller of a management endpoint
+type Identity struct {
+	// Recorded as the actor of audit log entries: "key:<quota ID>" for management API keys,
+	// the identity claim of a token otherwise
+	Name string
+
+	// Permissions of the roles of a token; management API keys are admins of every instance
+	grants []config.JWTRoleConfig
+}
+
+type identityContextKey struct{}
+
+// requestIdentity returns the caller of a management request, or nil when management
+// authentication is disabled
+func requestIdentity(r *http.Request) *Identity {
+	identity, _ := r.Context().Value(identityContextKey{}).(*Identity)
+	return identity
+}
+
+func withIdentity(r *http.Request, identity *Identity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), identityContextKey{}, identity))
+}
+
+// keyIdentity is the identity of a management API key
+func keyIdentity(key string) *Identity {
+	return &Identity{
+		Name:   "key:" + quota.KeyID(key),
+		grants: []config.JWTRoleConfig{{Scope: config.ScopeAdmin}},
+	}
+}
+
+// tokenIdentity maps the claims of a validated token to an identity through the configured roles
+func tokenIdentity(cfg config.JWTAuthConfig, claims map[string]any) (*Identity, error) {
+	name, _ := claimValue(claims, cfg.IdentityClaim).(string)
+	if name == "" {
+		name, _ = claims["sub"].(string)
+	}
+	if name == "" {
+		return nil, &tokenError{tokenInvalid, fmt.Sprintf("Token has no %s claim", cfg.IdentityClaim)}
+	}
+
+	identity := &Identity{Name: name}
+	var roles []string
+	switch value := claimValue(claims, cfg.RolesClaim).(type) {
+	case string:
+		roles = []string{value}
+	case []any:
+		for _, role := range value {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+	}
+	for _, role := range roles {
+		if grant, ok := cfg.Roles[role]; ok {
+			identity.grants = append(identity.grants, grant)
+		}
+	}
+	if len(identity.grants) == 0 {
+		return nil, &tokenError{tokenInsufficientScope, fmt.Sprintf("None of the %s of %s grant access to llamactl", cfg.RolesClaim, name)}
+	}
+	return identity, nil
+}
+
+// claimValue looks up a claim, descending into nested objects for dotted names such as
+// "realm_access.roles"
+func claimValue(claims map[string]any, name string) any {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+	var value any = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+// allowed reports whether the identity has at least scope on an instance. An empty instance
+// stands for the endpoints that are not about a single instance, which require a role that
+// is not limited to namespaces.
+func (id *Identity) allowed(scope, instance string) bool {
+	return slices.ContainsFunc(id.grants, func(grant config.JWTRoleConfig) bool {
+		if scopeRanks[grant.Scope] < scopeRanks[scope] {
+			return false
+		}
+		if len(grant.Namespaces) == 0 {
+			return true
+		}
+		return instance != "" && slices.ContainsFunc(grant.Namespaces, func(namespace string) bool {
+			matched, _ := path.Match(namespace, instance)
+			return matched
+		})
+	})
+}
+
+// instanceRoute splits a path under /api/v1/instances/{name} into the instance and the
+// resource requested from it
+func instanceRoute(urlPath string) (name, resource string, ok bool) {
+	rest, ok := strings.CutPrefix(urlPath, "/api/v1/instances/")
+	if !ok || rest == "" {
+		return "", "", false
+	}
+	name, resource, _ = strings.Cut(rest, "/")
+	return name, strings.TrimSuffix(resource, "/"), true
+}
+
+// requiredScope is the scope a management request needs. Reading needs read-only, the
+// lifecycle of instances and their backends need operator, and every other change needs admin.
+func requiredScope(r *http.Request) string {
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if _, resource, ok := instanceRoute(r.URL.Path); ok {
+		switch {
+		case resource == "proxy" || strings.HasPrefix(resource, "proxy/"):
+			return config.ScopeOperator
+		case read, resource == "validate":
+			return config.ScopeReadOnly
+		case r.Method == http.MethodPost && (resource == "start" || resource == "stop" || resource == "restart" || resource == "reload"):
+			return config.ScopeOperator
+		case r.Method == http.MethodPost && strings.HasPrefix(resource, "autorestart/"):
+			return config.ScopeOperator
+		case r.Method == http.MethodDelete && strings.HasPrefix(resource, "requests/"):
+			return config.ScopeOperator
+		}
+		return config.ScopeAdmin
+	}
+
+	switch {
+	case read, strings.HasPrefix(r.URL.Path, "/api/v1/backends/"):
+		// Parsing backend commands changes nothing
+		return config.ScopeReadOnly
+	case r.URL.Path == "/api/v1/maintenance/rolling-restart", r.URL.Path == "/api/v1/models/rescan":
+		return config.ScopeOperator
+	case strings.HasPrefix(r.URL.Path, "/api/v1/autorestart/"):
+		return config.ScopeOperator
+	case strings.HasPrefix(r.URL.Path, "/api/v1/services/") && strings.HasSuffix(r.URL.Path, "/failback"):
+		return config.ScopeOperator
+	}
+	return config.ScopeAdmin
+}
+
+// authorize checks that the identity may make the request. Listing instances, streaming
+// events, the restore progress and the log disk usage only need a role on some instance, the
+// handlers leave the others out.
+func (id *Identity) authorize(r *http.Request) bool {
+	scope := requiredScope(r)
+	if name, _, ok := instanceRoute(r.URL.Path); ok {
+		return id.allowed(scope, name)
+	}
+	switch urlPath := strings.TrimSuffix(r.URL.Path, "/"); {
+	case urlPath == "/api/v1/instances", urlPath == "/api/v1/events", urlPath == "/api/v1/version", urlPath == "/api/v1/restore",
+		urlPath == "/api/v1/logs/quota",
+		strings.HasPrefix(urlPath, "/api/v1/backends/"):
+		return len(id.grants) > 0
+	}
+	return id.allowed(scope, "")
+}
+
+// managerFor returns the instance manager, recording the changes made through it in the
+// audit log as made by the caller of the request
+func (h *Handler) managerFor(r *http.Request) manager.InstanceManager {
+	if identity := requestIdentity(r); identity != nil {
+		return h.InstanceManager.WithActor(identity.Name)
+	}
+	return h.InstanceManager
+}
+
+// visibleTo reports whether the caller of the request may see an instance
+func visibleTo(r *http.Request, name string) bool {
+	identity := requestIdentity(r)
+	return identity == nil || identity.allowed(config.ScopeReadOnly, name)
+}
@@ -0,0 +1,332 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"llamactl/pkg/config"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwksMinRefreshInterval limits how often a token signed by an unknown key, or a failing
+// provider, can trigger a refresh of the signing keys
+const jwksMinRefreshInterval = time.Minute
+
+// Machine-readable codes of rejected tokens
+const (
+	tokenInvalid           = "invalid_token"
+	tokenExpired           = "token_expired"
+	tokenNotYetValid       = "token_not_yet_valid"
+	tokenInvalidIssuer     = "invalid_issuer"
+	tokenInvalidAudience   = "invalid_audience"
+	tokenUnknownKey        = "unknown_signing_key"
+	tokenKeysUnavailable   = "signing_keys_unavailable"
+	tokenInsufficientScope = "insufficient_scope"
+)
+
+// tokenError is returned for a bearer token or a request signature that cannot be accepted
+type tokenError struct {
+	Code    string
+	Message string
+}
+
+func (e *tokenError) Error() string {
+	return e.Message
+}
+
+// jwtAlgorithms maps the supported JWS algorithms to their hash
+var jwtAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"PS256": crypto.SHA256, "PS384": crypto.SHA384, "PS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+// ecdsaCurves is the curve of the key each ECDSA algorithm signs with
+var ecdsaCurves = map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}
+
+// jwtVerifier validates bearer tokens of an OpenID Connect provider. The signing keys are
+// fetched from the JWKS URL, discovered from the issuer unless configured, on first use and
+// cached until the refresh interval passes or a token names a key that is not cached.
+type jwtVerifier struct {
+	cfg    config.JWTAuthConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	jwksURL     string
+	keys        map[string]crypto.PublicKey // By key ID
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastError   error // Of the last refresh
+}
+
+func newJWTVerifier(cfg config.JWTAuthConfig) *jwtVerifier {
+	return &jwtVerifier{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		jwksURL: cfg.JWKSURL,
+	}
+}
+
+// looksLikeJWT tells bearer tokens apart from API keys
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+// verify checks the signature, issuer, audience and validity period of a token and
+// returns its claims
+func (v *jwtVerifier) verify(token string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, &tokenError{tokenInvalid, "Malformed token"}
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, &tokenError{tokenInvalid, "Malformed token header"}
+	}
+	hash, ok := jwtAlgorithms[header.Alg]
+	if !ok {
+		return nil, &tokenError{tokenInvalid, fmt.Sprintf("Unsupported token algorithm %q", header.Alg)}
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, &tokenError{tokenInvalid, "Malformed token signature"}
+	}
+
+	keys, err := v.signingKeys(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	digest := hasher.Sum(nil)
+	if !slices.ContainsFunc(keys, func(key crypto.PublicKey) bool {
+		return verifySignature(header.Alg, hash, key, digest, signature)
+	}) {
+		return nil, &tokenError{tokenInvalid, "Invalid token signature"}
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, &tokenError{tokenInvalid, "Malformed token claims"}
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkClaims checks the registered claims of a token with a correct signature
+func (v *jwtVerifier) checkClaims(claims map[string]any) error {
+	if iss, _ := claims["iss"].(string); iss != v.cfg.Issuer {
+		return &tokenError{tokenInvalidIssuer, fmt.Sprintf("Token issued by %q, expected %q", iss, v.cfg.Issuer)}
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []any:
+		for _, value := range aud {
+			if s, ok := value.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	if !slices.Contains(audiences, v.cfg.Audience) {
+		return &tokenError{tokenInvalidAudience, fmt.Sprintf("Token is not intended for audience %q", v.cfg.Audience)}
+	}
+
+	now := time.Now()
+	skew := time.Duration(v.cfg.ClockSkew) * time.Second
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return &tokenError{tokenInvalid, "Token has no expiration time"}
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(skew)) {
+		return &tokenError{tokenExpired, "Token has expired"}
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(skew).Before(time.Unix(int64(nbf), 0)) {
+		return &tokenError{tokenNotYetValid, "Token is not valid yet"}
+	}
+	return nil
+}
+
+// signingKeys returns the cached keys a token signed with kid may be verified with,
+// refreshing the cache when it is stale or does not have the key
+func (v *jwtVerifier) signingKeys(kid string) ([]crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := time.Now()
+	_, known := v.keys[kid]
+	stale := now.Sub(v.fetchedAt) >= time.Duration(v.cfg.JWKSRefreshInterval)*time.Second
+	if (stale || (kid != "" && !known)) && now.Sub(v.lastAttempt) >= jwksMinRefreshInterval {
+		v.lastAttempt = now
+		// Keep verifying with the cached keys while the provider is unreachable
+		keys, err := v.fetchKeys()
+		if err == nil {
+			v.keys, v.fetchedAt = keys, now
+		}
+		v.lastError = err
+	}
+	if v.keys == nil {
+		return nil, &tokenError{tokenKeysUnavailable, "Signing keys unavailable: " + v.lastError.Error()}
+	}
+
+	if kid == "" {
+		keys := make([]crypto.PublicKey, 0, len(v.keys))
+		for _, key := range v.keys {
+			keys = append(keys, key)
+		}
+		return keys, nil
+	}
+	key, ok := v.keys[kid]
+	if !ok {
+		return nil, &tokenError{tokenUnknownKey, fmt.Sprintf("Token signed by unknown key %q", kid)}
+	}
+	return []crypto.PublicKey{key}, nil
+}
+
+// fetchKeys downloads the signing keys of the provider, discovering the JWKS URL first
+// when it is not configured (caller must hold the lock)
+func (v *jwtVerifier) fetchKeys() (map[string]crypto.PublicKey, error) {
+	if v.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(strings.TrimSuffix(v.cfg.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return nil, fmt.Errorf("failed to discover the JWKS URL: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return nil, fmt.Errorf("the provider configuration has no jwks_uri")
+		}
+		v.jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(v.jwksURL, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch the JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped, the provider may publish others
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (v *jwtVerifier) getJSON(url string, target any) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// jsonWebKey is a public key of a JWKS (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid EC coordinates")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", k.Crv)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// verifySignature checks a JWS signature, which must have been made with a key of the type
+// of the algorithm
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, digest, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+		case "PS":
+			return rsa.VerifyPSS(key, hash, digest, signature, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		// The signature is the concatenation of r and s, each the size of the curve
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if ecdsaCurves[alg] != key.Curve.Params().Name || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(segment string, target any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
@@ -0,0 +1,299 @@
+package server_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/quota"
+	"llamactl/pkg/server"
+	"llamactl/pkg/storage"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testManagementKey = "sk-management-test"
+
+// jwtProvider is an OpenID Connect provider publishing an RSA and an ECDSA signing key
+type jwtProvider struct {
+	server       *httptest.Server
+	rsaKey       *rsa.PrivateKey
+	ecKey        *ecdsa.PrivateKey
+	jwksRequests atomic.Int32
+}
+
+func newJWTProvider(t *testing.T) *jwtProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &jwtProvider{rsaKey: rsaKey, ecKey: ecKey}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": p.server.URL, "jwks_uri": p.server.URL + "/keys"})
+		case "/keys":
+			p.jwksRequests.Add(1)
+			json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+				{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes())},
+				{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X.FillBytes(make([]byte, 32))), "y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+				{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// token signs claims, completed with valid registered claims unless overridden
+func (p *jwtProvider) token(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	payload := map[string]any{
+		"iss": p.server.URL,
+		"aud": []string{"llamactl", "other"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for name, value := range claims {
+		payload[name] = value
+	}
+
+	alg := "RS256"
+	if kid == "ec" {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	body, _ := json.Marshal(payload)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	var err error
+	if kid == "ec" {
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, p.ecKey, digest[:])
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		signature, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest[:])
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// newJWTRouter returns a router accepting the tokens of the provider on the management API,
+// and the store its audit log is written to
+func newJWTRouter(t *testing.T, provider *jwtProvider) (http.Handler, storage.Store) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.AppConfig{
+		Instances: config.InstancesConfig{
+			PortRange:           [2]int{8000, 9000},
+			InstancesDir:        filepath.Join(dir, "instances"),
+			LogsDir:             filepath.Join(dir, "logs"),
+			MaxInstances:        10,
+			MaxRunningInstances: -1,
+		},
+		Auth: config.AuthConfig{
+			RequireManagementAuth: true,
+			ManagementKeys:        []string{testManagementKey},
+			JWT: config.JWTAuthConfig{
+				Issuer:              provider.server.URL,
+				Audience:            "llamactl",
+				IdentityClaim:       "email",
+				RolesClaim:          "realm_access.roles",
+				JWKSRefreshInterval: 3600,
+				ClockSkew:           60,
+				Roles: map[string]config.JWTRoleConfig{
+					"llamactl-admins":  {Scope: config.ScopeAdmin},
+					"llamactl-viewers": {Scope: config.ScopeReadOnly},
+					"team-a":           {Scope: config.ScopeOperator, Namespaces: []string{"team-a-*"}},
+				},
+			},
+		},
+	}
+	store := storage.NewFileStore(cfg.Instances.InstancesDir, dir)
+	mngr := manager.NewInstanceManagerWithStore(cfg.Backends, cfg.Instances, store)
+	t.Cleanup(mngr.Shutdown)
+	return server.SetupRouter(server.NewHandler(mngr, cfg)), store
+}
+
+func roles(values ...string) map[string]any {
+	return map[string]any{"realm_access": map[string]any{"roles": values}}
+}
+
+// authError is the body of a rejected request
+type authError struct {
+	Error struct {
+		Type string `json:"type"`
+		Code string `json:"code"`
+	} `json:"error"`
+}
+
+func TestJWTAuth_Scopes(t *testing.T) {
+	provider := newJWTProvider(t)
+	router, store := newJWTRouter(t, provider)
+
+	admin := provider.token(t, "rsa", map[string]any{"sub": "u1", "email": "alice@example.com", "realm_access": map[string]any{"roles": []string{"llamactl-admins"}}})
+	viewer := provider.token(t, "ec", map[string]any{"sub": "u2", "realm_access": map[string]any{"roles": []string{"llamactl-viewers", "unrelated"}}})
+	teamA := provider.token(t, "rsa", map[string]any{"sub": "u3", "email": "bob@example.com", "realm_access": map[string]any{"roles": []string{"team-a"}}})
+	const options = `{"backend_type": "llama_cpp", "backend_options": {"model": "/m.gguf"}}`
+
+	tests := []struct {
+		name       string
+		credential string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "admin creates", credential: admin, method: "POST", path: "/api/v1/instances/team-a-chat", wantStatus: http.StatusCreated},
+		{name: "API key creates", credential: testManagementKey, method: "POST", path: "/api/v1/instances/other", wantStatus: http.StatusCreated},
+		{name: "viewer lists", credential: viewer, method: "GET", path: "/api/v1/instances/", wantStatus: http.StatusOK, wantBody: `"other"`},
+		{name: "viewer reads", credential: viewer, method: "GET", path: "/api/v1/instances/other", wantStatus: http.StatusOK},
+		{name: "viewer cannot create", credential: viewer, method: "POST", path: "/api/v1/instances/viewer", wantStatus: http.StatusForbidden},
+		{name: "viewer cannot stop", credential: viewer, method: "POST", path: "/api/v1/instances/other/stop", wantStatus: http.StatusForbidden},
+		// Authorized, stopping fails since the instance is not running
+		{name: "operator stops in namespace", credential: teamA, method: "POST", path: "/api/v1/instances/team-a-chat/stop", wantStatus: http.StatusInternalServerError, wantBody: "already stopped"},
+		{name: "operator cannot update", credential: teamA, method: "PUT", path: "/api/v1/instances/team-a-chat", wantStatus: http.StatusForbidden},
+		{name: "operator cannot read outside namespace", credential: teamA, method: "GET", path: "/api/v1/instances/other", wantStatus: http.StatusForbidden},
+		{name: "operator cannot use fleet", credential: teamA, method: "GET", path: "/api/v1/fleet/status", wantStatus: http.StatusForbidden},
+		{name: "admin deletes", credential: admin, method: "DELETE", path: "/api/v1/instances/other", wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(options))
+			req.Header.Set("Authorization", "Bearer "+tt.credential)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("Expected the body to contain %s, got %s", tt.wantBody, w.Body.String())
+			}
+			if w.Code == http.StatusForbidden {
+				var body authError
+				json.Unmarshal(w.Body.Bytes(), &body)
+				if body.Error.Type != "permission_error" || body.Error.Code != "insufficient_scope" {
+					t.Errorf("Expected an insufficient_scope error, got %+v", body.Error)
+				}
+			}
+		})
+	}
+
+	// Callers limited to namespaces only see their instances
+	req := httptest.NewRequest("GET", "/api/v1/instances/", nil)
+	req.Header.Set("Authorization", "Bearer "+teamA)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var listed []struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &listed)
+	if len(listed) != 1 || listed[0].Name != "team-a-chat" {
+		t.Errorf("Expected only the instance of the namespace to be listed, got %+v", listed)
+	}
+
+	records, err := store.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit failed: %v", err)
+	}
+	actors := map[string]string{}
+	for _, record := range records {
+		actors[record.Action+" "+record.Target] = record.Actor
+	}
+	expected := map[string]string{
+		"create team-a-chat": "alice@example.com",
+		"create other":       "key:" + quota.KeyID(testManagementKey),
+		"delete other":       "alice@example.com",
+	}
+	for entry, actor := range expected {
+		if actors[entry] != actor {
+			t.Errorf("Expected %s to be recorded as made by %q, got %q", entry, actor, actors[entry])
+		}
+	}
+
+	// The signing keys are discovered once and cached
+	if requests := provider.jwksRequests.Load(); requests != 1 {
+		t.Errorf("Expected the JWKS to be fetched once, got %d", requests)
+	}
+}
+
+func TestJWTAuth_RejectedTokens(t *testing.T) {
+	provider := newJWTProvider(t)
+	router, _ := newJWTRouter(t, provider)
+
+	valid := provider.token(t, "rsa", roles("llamactl-admins"))
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"x"}`)) + "." + parts[2]
+
+	tests := []struct {
+		name       string
+		credential string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "expired", credential: provider.token(t, "rsa", map[string]any{"exp": time.Now().Add(-2 * time.Minute).Unix(), "sub": "u", "realm_access": map[string]any{"roles": []string{"llamactl-admins"}}}), wantStatus: http.StatusUnauthorized, wantCode: "token_expired"},
+		{name: "within clock skew", credential: provider.token(t, "rsa", map[string]any{"exp": time.Now().Add(-30 * time.Second).Unix(), "sub": "u", "realm_access": map[string]any{"roles": []string{"llamactl-admins"}}}), wantStatus: http.StatusOK},
+		{name: "not yet valid", credential: provider.token(t, "rsa", map[string]any{"nbf": time.Now().Add(time.Hour).Unix(), "sub": "u"}), wantStatus: http.StatusUnauthorized, wantCode: "token_not_yet_valid"},
+		{name: "other audience", credential: provider.token(t, "rsa", map[string]any{"aud": "other", "sub": "u"}), wantStatus: http.StatusUnauthorized, wantCode: "invalid_audience"},
+		{name: "other issuer", credential: provider.token(t, "rsa", map[string]any{"iss": "https://evil.example.com", "sub": "u"}), wantStatus: http.StatusUnauthorized, wantCode: "invalid_issuer"},
+		{name: "tampered claims", credential: tampered, wantStatus: http.StatusUnauthorized, wantCode: "invalid_token"},
+		{name: "unknown key", credential: provider.token(t, "rotated", map[string]any{"sub": "u"}), wantStatus: http.StatusUnauthorized, wantCode: "unknown_signing_key"},
+		{name: "no mapped role", credential: provider.token(t, "rsa", map[string]any{"sub": "u", "realm_access": map[string]any{"roles": []string{"unrelated"}}}), wantStatus: http.StatusForbidden, wantCode: "insufficient_scope"},
+		{name: "invalid API key", credential: "sk-management-wrong", wantStatus: http.StatusUnauthorized, wantCode: "invalid_api_key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/instances/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.credential)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			var body authError
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Expected a JSON body, got %q: %v", w.Body.String(), err)
+			}
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("Expected code %s, got %+v", tt.wantCode, body.Error)
+			}
+		})
+	}
+
+	// A token signed by an unknown key refreshes the keys at most once a minute
+	if requests := provider.jwksRequests.Load(); requests != 1 {
+		t.Errorf("Expected the JWKS to be fetched once, got %d", requests)
+	}
+}
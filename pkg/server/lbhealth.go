@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/netip"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// lbHealthStatusCodes maps the states of the instances to the codes load balancers act on
+var lbHealthStatusCodes = map[string]int{
+	instance.LBHealthy:   http.StatusOK,
+	instance.LBDraining:  http.StatusTooManyRequests,
+	instance.LBUnhealthy: http.StatusServiceUnavailable,
+}
+
+// lbHealthAllowed reports whether the client of a request is in lb_health_allowed_networks,
+// which every client is when it is empty
+func (h *Handler) lbHealthAllowed(r *http.Request) bool {
+	if len(h.cfg.Server.LBHealthAllowedNetworks) == 0 {
+		return true
+	}
+	addrPort, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, network := range h.cfg.Server.LBHealthAllowedNetworks {
+		if prefix, err := config.ParseNetwork(network); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// LBHealth godoc
+// @Summary Get the health of an instance for load balancers
+// @Description Reports whether an instance should get more work, from its cached state only: 200 when it is ready and its queue is below its lb_health thresholds, 429 while it drains its requests before a recycle, and 503 otherwise. Needs no API key; lb_health_allowed_networks restricts the clients allowed.
+// @Tags instances
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {object} instance.LBHealth "Healthy"
+// @Failure 403 {string} string "Client not in lb_health_allowed_networks"
+// @Failure 404 {string} string "Instance not found"
+// @Failure 429 {object} instance.LBHealth "Draining"
+// @Failure 503 {object} instance.LBHealth "Unhealthy"
+// @Router /instances/{name}/lb-health [get]
+func (h *Handler) LBHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.lbHealthAllowed(r) {
+			http.Error(w, "Client not allowed to query load balancer health", http.StatusForbidden)
+			return
+		}
+
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		health, err := h.InstanceManager.GetLBHealth(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(lbHealthStatusCodes[health.Status])
+		json.NewEncoder(w).Encode(health)
+	}
+}
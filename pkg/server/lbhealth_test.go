@@ -0,0 +1,160 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newLBHealthRouter serves the llamactl API with the running unmanaged instance "external",
+// unhealthy from its second request in flight, and a stopped managed instance "stopped"
+func newLBHealthRouter(t *testing.T, allowedNetworks []string) (http.Handler, manager.InstanceManager, *instance.Process) {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	cfg := config.AppConfig{
+		Server: config.ServerConfig{LBHealthAllowedNetworks: allowedNetworks},
+		Instances: config.InstancesConfig{
+			PortRange:           [2]int{8000, 9000},
+			LogsDir:             t.TempDir(),
+			MaxInstances:        10,
+			MaxRunningInstances: -1,
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+	sub, unsubscribe := mngr.SubscribeEvents()
+	defer unsubscribe()
+
+	managed := false
+	inst, err := mngr.CreateInstance("external", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		Managed:            &managed,
+		ConnectHost:        backendURL.Hostname(),
+		LBHealth:           &instance.LBHealthOptions{InFlightThreshold: 2},
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Port: port},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.CreateInstance("stopped", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for running := false; !running; {
+		select {
+		case event := <-sub:
+			running = event.Type == events.TypeStatusChange && event.Instance == "external" && event.Data["new_status"] == "running"
+		case <-timeout:
+			t.Fatal("Timed out waiting for the external backend to be probed")
+		}
+	}
+	return server.SetupRouter(server.NewHandler(mngr, cfg)), mngr, inst
+}
+
+// getLBHealth polls the load balancer health of an instance from remoteAddr
+func getLBHealth(t *testing.T, router http.Handler, name, remoteAddr string) (int, instance.LBHealth) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/instances/"+name+"/lb-health", nil)
+	req.RemoteAddr = remoteAddr
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	var health instance.LBHealth
+	if recorder.Code != http.StatusForbidden && recorder.Code != http.StatusNotFound {
+		if err := json.NewDecoder(recorder.Body).Decode(&health); err != nil {
+			t.Fatalf("Failed to decode the health of %s: %v", name, err)
+		}
+	}
+	return recorder.Code, health
+}
+
+func TestLBHealth(t *testing.T) {
+	router, mngr, inst := newLBHealthRouter(t, nil)
+	sub, unsubscribe := mngr.SubscribeEvents()
+	defer unsubscribe()
+
+	if code, health := getLBHealth(t, router, "external", "192.0.2.1:1234"); code != http.StatusOK || health.Status != instance.LBHealthy {
+		t.Errorf("Expected a ready instance to be healthy, got %d %+v", code, health)
+	}
+	if code, health := getLBHealth(t, router, "stopped", "192.0.2.1:1234"); code != http.StatusServiceUnavailable || health.Reason != instance.LBReasonNotRunning {
+		t.Errorf("Expected a stopped instance to be unhealthy, got %d %+v", code, health)
+	}
+	if code, _ := getLBHealth(t, router, "missing", "192.0.2.1:1234"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing instance, got %d", code)
+	}
+
+	// The in-flight threshold is reached with the second request
+	for range 2 {
+		release, _, err := inst.AcquireRequestSlot(context.Background(), instance.PriorityNormal, 0)
+		if err != nil {
+			t.Fatalf("AcquireRequestSlot failed: %v", err)
+		}
+		defer release()
+	}
+	code, health := getLBHealth(t, router, "external", "192.0.2.1:1234")
+	if code != http.StatusServiceUnavailable || health.Reason != instance.LBReasonInFlight || health.InFlight != 2 {
+		t.Errorf("Expected a saturated instance to be unhealthy, got %d %+v", code, health)
+	}
+
+	inst.SetDraining(true)
+	if code, health := getLBHealth(t, router, "external", "192.0.2.1:1234"); code != http.StatusTooManyRequests || health.Status != instance.LBDraining {
+		t.Errorf("Expected a draining instance to report 429, got %d %+v", code, health)
+	}
+
+	// Each change of the state returned is published, the first poll being none
+	var transitions []string
+	timeout := time.After(5 * time.Second)
+	for len(transitions) < 2 {
+		select {
+		case event := <-sub:
+			if event.Type == events.TypeLBHealth && event.Instance == "external" {
+				transitions = append(transitions, event.Data["old_status"].(string)+" -> "+event.Data["new_status"].(string))
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for the lb_health events, got %q", transitions)
+		}
+	}
+	if transitions[0] != "healthy -> unhealthy" || transitions[1] != "unhealthy -> draining" {
+		t.Errorf("Expected the transitions of external, got %q", transitions)
+	}
+}
+
+func TestLBHealth_AllowedNetworks(t *testing.T) {
+	router, _, _ := newLBHealthRouter(t, []string{"10.0.0.0/8", "2001:db8::1"})
+
+	tests := []struct {
+		remoteAddr string
+		expected   int
+	}{
+		{"10.1.2.3:1234", http.StatusOK},
+		{"[2001:db8::1]:1234", http.StatusOK},
+		{"[::ffff:10.1.2.3]:1234", http.StatusOK},
+		{"192.0.2.1:1234", http.StatusForbidden},
+		{"[2001:db8::2]:1234", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if code, _ := getLBHealth(t, router, "external", tt.remoteAddr); code != tt.expected {
+			t.Errorf("Expected %d for %s, got %d", tt.expected, tt.remoteAddr, code)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Listener roles, matched against the names of socket-activated listeners
+const (
+	ListenerRoleManagement = "management" // management API, web UI and Swagger
+	ListenerRoleInference  = "inference"  // OpenAI-compatible and llama.cpp proxy endpoints, instance web UIs
+	ListenerRoleMetrics    = "metrics"    // Prometheus metrics and debug endpoints
+)
+
+// listenerRole returns the role whose routes serve the given path
+func listenerRole(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/v1/"), strings.HasPrefix(path, "/llama-cpp/"), path == "/instances", strings.HasPrefix(path, "/instances/"):
+		return ListenerRoleInference
+	case path == "/metrics", strings.HasPrefix(path, "/debug/"):
+		return ListenerRoleMetrics
+	default:
+		return ListenerRoleManagement
+	}
+}
+
+// ForListenerRole restricts a router to the routes of a listener role, and the readiness
+// probe. Requests for the routes of another role get a 404. Listeners with any other name
+// serve every route.
+func ForListenerRole(role string, router http.Handler) http.Handler {
+	switch role {
+	case ListenerRoleManagement, ListenerRoleInference, ListenerRoleMetrics:
+	default:
+		return router
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The readiness probe is answered on every listener, whichever one is probed
+		if r.URL.Path != "/readyz" && listenerRole(r.URL.Path) != role {
+			http.NotFound(w, r)
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
+}
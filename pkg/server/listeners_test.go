@@ -0,0 +1,43 @@
+package server_test
+
+import (
+	"llamactl/pkg/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForListenerRole(t *testing.T) {
+	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		role     string
+		path     string
+		expected int
+	}{
+		{server.ListenerRoleInference, "/v1/chat/completions", http.StatusOK},
+		{server.ListenerRoleInference, "/llama-cpp/my-instance/props", http.StatusOK},
+		{server.ListenerRoleInference, "/instances/my-instance/ui/", http.StatusOK},
+		{server.ListenerRoleInference, "/api/v1/instances/", http.StatusNotFound},
+		{server.ListenerRoleManagement, "/api/v1/instances/", http.StatusOK},
+		{server.ListenerRoleManagement, "/", http.StatusOK},
+		{server.ListenerRoleManagement, "/v1/models", http.StatusNotFound},
+		{server.ListenerRoleMetrics, "/metrics", http.StatusOK},
+		{server.ListenerRoleMetrics, "/debug/goroutines", http.StatusOK},
+		{server.ListenerRoleMetrics, "/api/v1/version", http.StatusNotFound},
+		{"unknown", "/v1/models", http.StatusOK},
+		{"unknown", "/metrics", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			server.ForListenerRole(tt.role, router).ServeHTTP(recorder, httptest.NewRequest("GET", tt.path, nil))
+			if recorder.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, recorder.Code)
+			}
+		})
+	}
+}
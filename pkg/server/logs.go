@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"llamactl/pkg/instance"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Output formats of the logs endpoint
+const (
+	logFormatText   = "text"
+	logFormatNDJSON = "ndjson"
+	logFormatHTML   = "html"
+)
+
+// logsPageRefresh is the number of seconds after which the HTML view of the logs reloads
+const logsPageRefresh = 5
+
+// headerLogsTruncated is set on a logs response cut to its most recent lines, to the
+// number of bytes they were limited to
+const headerLogsTruncated = "X-Logs-Truncated"
+
+// logsMaxBytes returns the number of bytes of logs a request asks for with max_bytes,
+// bounded by log_response_max_size_mb, or else log_response_size_mb. 0 is unlimited.
+func (h *Handler) logsMaxBytes(r *http.Request) (int64, error) {
+	settings := h.instancesSettings()
+	limit := int64(settings.LogResponseMaxSizeMB) << 20
+	raw := r.URL.Query().Get("max_bytes")
+	if raw == "" {
+		return int64(settings.LogResponseSizeMB) << 20, nil
+	}
+	maxBytes, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if maxBytes <= 0 {
+		return 0, fmt.Errorf("%d is not a positive number of bytes", maxBytes)
+	}
+	if limit > 0 && maxBytes > limit {
+		return 0, fmt.Errorf("%d exceeds log_response_max_size_mb, at most %d bytes can be returned", maxBytes, limit)
+	}
+	return maxBytes, nil
+}
+
+// acquireLogRead waits for one of the log_read_concurrency slots of whole logs reads, and
+// returns the function releasing it. It returns false if the client went away meanwhile.
+func (h *Handler) acquireLogRead(r *http.Request) (func(), bool) {
+	if h.logReads == nil {
+		return func() {}, true
+	}
+	select {
+	case h.logReads <- struct{}{}:
+		return func() { <-h.logReads }, true
+	case <-r.Context().Done():
+		return nil, false
+	}
+}
+
+// logFormat returns the output format requested for logs: the format parameter, or else the
+// first of the Accept header media types that has a format, or else text.
+func logFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+	case logFormatText, logFormatNDJSON, logFormatHTML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%q is not one of %s, %s or %s", format, logFormatText, logFormatNDJSON, logFormatHTML)
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accepted, ";")
+		switch strings.TrimSpace(mediaType) {
+		case "text/plain":
+			return logFormatText, nil
+		case "application/x-ndjson":
+			return logFormatNDJSON, nil
+		case "text/html":
+			return logFormatHTML, nil
+		}
+	}
+	return logFormatText, nil
+}
+
+// defaultLogLines returns the number of lines returned when the request does not say, with -1
+// for all lines. The HTML view is meant for a quick look and following starts like tail -f.
+func defaultLogLines(format string, follow bool) int {
+	switch {
+	case follow:
+		return 10
+	case format == logFormatHTML:
+		return 1000
+	default:
+		return -1
+	}
+}
+
+// writeLogsPage writes a minimal HTML view of the logs which reloads itself
+func writeLogsPage(w http.ResponseWriter, name string, logs string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="%d">
+<title>%s logs</title>
+</head>
+<body onload="window.scrollTo(0, document.body.scrollHeight)">
+<pre>%s</pre>
+</body>
+</html>
+`, logsPageRefresh, html.EscapeString(name), html.EscapeString(logs))
+}
+
+// streamLogs writes the history of a follower, then each line logged until the client goes
+// away or the follower falls too far behind
+func streamLogs(w http.ResponseWriter, r *http.Request, follower *instance.LogFollower, format string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	contentType := "text/plain"
+	if format == logFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(w, follower.History); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line, ok := <-follower.Lines:
+			if !ok {
+				return
+			}
+			if format == logFormatNDJSON {
+				data, err := json.Marshal(line)
+				if err != nil {
+					continue
+				}
+				w.Write(append(data, '\n'))
+			} else {
+				fmt.Fprintln(w, line.Line)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// GetLogQuota godoc
+// @Summary Get the use of the log disk quota
+// @Description Returns the disk space the log files of the instances use against log_disk_quota_mb, with the usage and share of each instance, as of the last check after a rotation or with the retention policy
+// @Tags system
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} manager.LogQuotaStatus "Log disk usage"
+// @Router /logs/quota [get]
+func (h *Handler) GetLogQuota() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := h.InstanceManager.GetLogQuotaStatus()
+
+		// Only the instances the caller may see are listed
+		visible := status.Instances[:0]
+		for _, usage := range status.Instances {
+			if visibleTo(r, usage.Name) {
+				visible = append(visible, usage)
+			}
+		}
+		status.Instances = visible
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode log quota: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
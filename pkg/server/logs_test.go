@@ -0,0 +1,292 @@
+package server_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetInstanceLogs_Formats(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	cfg := config.AppConfig{
+		Backends: config.BackendConfig{LlamaCpp: config.BackendSettings{
+			Command: "sh",
+			Args:    []string{"-c", "echo '<b>loaded</b>'; exec sleep 30"},
+		}},
+		Instances: config.InstancesConfig{
+			PortRange:            [2]int{8000, 9000},
+			LogsDir:              t.TempDir(),
+			MaxInstances:         10,
+			MaxRunningInstances:  -1,
+			TimeoutCheckInterval: config.Duration(5 * time.Minute),
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+
+	inst, err := mngr.CreateInstance("logged", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.StartInstance("logged"); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if logs, err := inst.GetStructuredLogs(0); err == nil {
+			data, _ := io.ReadAll(logs)
+			logs.Close()
+			if strings.Contains(string(data), "loaded") {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the output to be logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	srv := httptest.NewServer(server.SetupRouter(server.NewHandler(mngr, cfg)))
+	defer srv.Close()
+
+	get := func(query string, accept string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest("GET", srv.URL+"/api/v1/instances/logged/logs"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("text by default", func(t *testing.T) {
+		resp := get("", "*/*")
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if ct := resp.Header.Get("Content-Type"); ct != "text/plain" {
+			t.Errorf("Expected text/plain, got %q", ct)
+		}
+		if !strings.Contains(string(body), "\n<b>loaded</b>\n") {
+			t.Errorf("Expected the plain output, got %q", body)
+		}
+	})
+
+	t.Run("html from the Accept header", func(t *testing.T) {
+		resp := get("", "text/html,application/xhtml+xml,*/*;q=0.8")
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("Expected text/html, got %q", ct)
+		}
+		if !strings.Contains(string(body), `http-equiv="refresh"`) || !strings.Contains(string(body), "&lt;b&gt;loaded&lt;/b&gt;") {
+			t.Errorf("Expected an auto-refreshing page with the escaped output, got %q", body)
+		}
+	})
+
+	t.Run("ndjson from the format parameter", func(t *testing.T) {
+		resp := get("?format=ndjson&lines=1", "text/html")
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Expected application/x-ndjson, got %q", ct)
+		}
+		var line instance.LogLine
+		if err := json.NewDecoder(resp.Body).Decode(&line); err != nil {
+			t.Fatalf("Failed to decode line: %v", err)
+		}
+		if line.Stream != instance.LogStreamStdout || line.Line != "<b>loaded</b>" || line.Time.IsZero() {
+			t.Errorf("Expected the structured stdout line, got %+v", line)
+		}
+	})
+
+	t.Run("ndjson within a time range", func(t *testing.T) {
+		resp := get("?format=ndjson&until=2000-01-01T00:00:00Z", "")
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || len(body) != 0 {
+			t.Errorf("Expected no lines before the instance started, got %d: %q", resp.StatusCode, body)
+		}
+
+		resp = get("?format=ndjson&since="+time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), "")
+		body, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(body), `"line":"\u003cb\u003eloaded\u003c/b\u003e"`) {
+			t.Errorf("Expected the lines logged since, got %q", body)
+		}
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"?format=xml", "?follow=maybe", "?follow=true&format=html", "?format=ndjson&since=yesterday", "?since=2024-06-01T00:00:00Z"} {
+			resp := get(query, "")
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %s, got %d", query, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("follow", func(t *testing.T) {
+		resp := get("?follow=true&lines=1", "application/x-ndjson")
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Expected application/x-ndjson, got %q", ct)
+		}
+
+		lines := make(chan instance.LogLine)
+		go func() {
+			defer close(lines)
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				var line instance.LogLine
+				json.Unmarshal(scanner.Bytes(), &line)
+				lines <- line
+			}
+		}()
+
+		next := func() instance.LogLine {
+			t.Helper()
+			select {
+			case line := <-lines:
+				return line
+			case <-time.After(5 * time.Second):
+				t.Fatal("Timed out waiting for a line")
+			}
+			return instance.LogLine{}
+		}
+		if line := next(); line.Line != "<b>loaded</b>" {
+			t.Errorf("Expected the last line logged first, got %+v", line)
+		}
+
+		if _, err := mngr.StopInstance("logged"); err != nil {
+			t.Fatalf("StopInstance failed: %v", err)
+		}
+		if line := next(); line.Stream != instance.LogStreamLlamactl || !strings.Contains(line.Line, "stopped") {
+			t.Errorf("Expected the stop marker to be streamed, got %+v", line)
+		}
+	})
+}
+
+func TestGetInstanceLogs_MaxBytes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// Lines of 10 bytes, the last one of 5
+	cfg := config.AppConfig{
+		Backends: config.BackendConfig{LlamaCpp: config.BackendSettings{
+			Command: "sh",
+			Args:    []string{"-c", "for i in $(seq 1000 1999); do echo \"line $i\"; done; echo done; exec sleep 30"},
+		}},
+		Instances: config.InstancesConfig{
+			PortRange:            [2]int{8000, 9000},
+			LogsDir:              t.TempDir(),
+			MaxInstances:         10,
+			MaxRunningInstances:  -1,
+			TimeoutCheckInterval: config.Duration(5 * time.Minute),
+			LogResponseSizeMB:    1,
+			LogResponseMaxSizeMB: 1,
+			LogReadConcurrency:   1,
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+
+	inst, err := mngr.CreateInstance("logged", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.StartInstance("logged"); err != nil {
+		t.Fatalf("StartInstance failed: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for logs, _ := inst.GetLogs(1); logs != "done"; logs, _ = inst.GetLogs(1) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the output to be logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	srv := httptest.NewServer(server.SetupRouter(server.NewHandler(mngr, cfg)))
+	defer srv.Close()
+
+	get := func(query string) (*http.Response, string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/api/v1/instances/logged/logs" + query)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	t.Run("within the default size", func(t *testing.T) {
+		resp, body := get("")
+		if resp.Header.Get("X-Logs-Truncated") != "" || !strings.Contains(body, "line 1000\n") || !strings.HasSuffix(body, "done\n") {
+			t.Errorf("Expected the whole logs, got %d bytes truncated to %q", len(body), resp.Header.Get("X-Logs-Truncated"))
+		}
+	})
+
+	t.Run("most recent whole lines", func(t *testing.T) {
+		for query, want := range map[string]string{
+			"?max_bytes=24":         "line 1999\ndone\n",
+			"?lines=3&max_bytes=20": "line 1999\ndone",
+		} {
+			resp, body := get(query)
+			if resp.StatusCode != http.StatusOK || body != want || resp.Header.Get("X-Logs-Truncated") == "" {
+				t.Errorf("Expected %q with the truncation header for %s, got %d %q (%q)", want, query, resp.StatusCode, body, resp.Header.Get("X-Logs-Truncated"))
+			}
+		}
+	})
+
+	t.Run("structured lines", func(t *testing.T) {
+		resp, body := get("?format=ndjson&max_bytes=300")
+		if resp.Header.Get("X-Logs-Truncated") != "300" || len(body) > 300 {
+			t.Fatalf("Expected at most 300 bytes with the truncation header, got %d bytes (%q)", len(body), resp.Header.Get("X-Logs-Truncated"))
+		}
+		lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+		for _, raw := range lines {
+			var line instance.LogLine
+			if err := json.Unmarshal([]byte(raw), &line); err != nil {
+				t.Errorf("Expected whole structured lines, got %q", raw)
+			}
+		}
+		if !strings.Contains(lines[len(lines)-1], `"line":"done"`) {
+			t.Errorf("Expected the most recent lines, got %q", body)
+		}
+	})
+
+	t.Run("over the maximum", func(t *testing.T) {
+		for _, query := range []string{"?max_bytes=2097152", "?max_bytes=0", "?max_bytes=lots"} {
+			if resp, _ := get(query); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected 400 for %s, got %d", query, resp.StatusCode)
+			}
+		}
+	})
+}
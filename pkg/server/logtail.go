@@ -0,0 +1,335 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"llamactl/pkg/events"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tailBufferLines is the number of lines of each instance an aggregated tail holds for a slow
+// client, dropping the oldest beyond it, so a chatty instance cannot crowd out the others
+const tailBufferLines = 256
+
+// tailRescanInterval is how often an aggregated tail looks for instances joining or leaving it
+const tailRescanInterval = 2 * time.Second
+
+// TailLine is a log line of an instance in an aggregated tail
+type TailLine struct {
+	Instance string `json:"instance"`
+	instance.LogLine
+}
+
+// TailStatus tells that an instance joined or left an aggregated tail, or changed status
+type TailStatus struct {
+	Instance string `json:"instance"`
+	Status   string `json:"status"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// TailDropped tells that lines of an instance were dropped because the client fell behind
+type TailDropped struct {
+	Instance string `json:"instance"`
+	Dropped  int    `json:"dropped"`
+}
+
+// tailSource is an instance followed by an aggregated tail, with the lines not sent yet
+type tailSource struct {
+	name     string
+	follower *instance.LogFollower
+	pending  []instance.LogLine // Oldest first
+	dropped  int                // Lines dropped since the last line sent
+}
+
+// logTail merges the logs of several instances. Each instance has its own bounded buffer
+// and lines are taken from the instances in turn, so they interleave fairly.
+type logTail struct {
+	mu      sync.Mutex
+	sources map[string]*tailSource
+	order   []string // Instances in the order they are taken from
+	turn    int
+	notify  chan struct{}
+}
+
+func newLogTail() *logTail {
+	return &logTail{sources: map[string]*tailSource{}, notify: make(chan struct{}, 1)}
+}
+
+// push queues a line of source, dropping its oldest line if its buffer is full
+func (t *logTail) push(source *tailSource, line instance.LogLine) {
+	t.mu.Lock()
+	if len(source.pending) >= tailBufferLines {
+		source.pending = source.pending[1:]
+		source.dropped++
+	}
+	source.pending = append(source.pending, line)
+	t.mu.Unlock()
+
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+// add follows an instance, queuing the lines it logged last, and forwards its new lines
+func (t *logTail) add(name string, follower *instance.LogFollower) {
+	source := &tailSource{name: name, follower: follower}
+	t.mu.Lock()
+	t.sources[name] = source
+	t.order = append(t.order, name)
+	t.mu.Unlock()
+
+	scanner := bufio.NewScanner(follower.History)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line instance.LogLine
+		if json.Unmarshal(scanner.Bytes(), &line) == nil {
+			t.push(source, line)
+		}
+	}
+	go func() {
+		for line := range follower.Lines {
+			t.push(source, line)
+		}
+	}()
+}
+
+// remove stops following an instance, discarding its lines not sent yet
+func (t *logTail) remove(name string) {
+	t.mu.Lock()
+	source, ok := t.sources[name]
+	if ok {
+		delete(t.sources, name)
+		t.order = slices.DeleteFunc(t.order, func(n string) bool { return n == name })
+	}
+	t.mu.Unlock()
+	if ok {
+		source.follower.Close()
+	}
+}
+
+// names returns the instances followed
+func (t *logTail) names() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.order)
+}
+
+// close stops following all instances
+func (t *logTail) close() {
+	for _, name := range t.names() {
+		t.remove(name)
+	}
+}
+
+// next takes the next line in turn, with the number of lines of its instance dropped before
+// it, or returns false if no line is pending
+func (t *logTail) next() (TailLine, int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for range t.order {
+		t.turn = (t.turn + 1) % len(t.order)
+		source := t.sources[t.order[t.turn]]
+		if len(source.pending) == 0 {
+			continue
+		}
+		line := source.pending[0]
+		source.pending = source.pending[1:]
+		dropped := source.dropped
+		source.dropped = 0
+		return TailLine{Instance: source.name, LogLine: line}, dropped, true
+	}
+	return TailLine{}, 0, false
+}
+
+// TailLogs godoc
+// @Summary Stream the logs of several instances
+// @Description Merges the logs of the listed instances, or of the instances matching a selector, into one stream of Server-Sent Events. Each instance starts with its last lines. Lines are sent as log events labeled with their instance, taking turns between instances; an instance whose lines pile up while the client falls behind has its oldest lines dropped, reported by a dropped event. Status changes of the instances are sent as status events, and join and leave events tell when instances start and stop matching a selector.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces text/event-stream
+// @Param instances query string false "Comma-separated list of instance names"
+// @Param selector query string false "all, tag:<tag> or group:<service>"
+// @Param lines query int false "Number of lines of each instance to start with (default: 10, at most 256)"
+// @Success 200 {object} TailLine "Stream of log, status, join, leave and dropped events"
+// @Failure 400 {string} string "Invalid instances, selector or lines parameter"
+// @Failure 404 {string} string "Instance not found"
+// @Failure 409 {string} string "Instance is not managed by llamactl"
+// @Failure 500 {string} string "Streaming not supported"
+// @Router /logs/tail [get]
+func (h *Handler) TailLogs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		query := r.URL.Query()
+		selector := query.Get("selector")
+		var listed []string
+		if value := query.Get("instances"); value != "" {
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" && !slices.Contains(listed, name) {
+					listed = append(listed, name)
+				}
+			}
+		}
+		if (selector == "") == (len(listed) == 0) {
+			http.Error(w, "Exactly one of the instances and selector parameters is required", http.StatusBadRequest)
+			return
+		}
+
+		numLines := defaultLogLines(logFormatNDJSON, true)
+		if value := query.Get("lines"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > tailBufferLines {
+				http.Error(w, fmt.Sprintf("Invalid lines parameter: expected a number between 0 and %d", tailBufferLines), http.StatusBadRequest)
+				return
+			}
+			numLines = n
+		}
+
+		// Listed instances must all exist, have logs and be visible to the caller
+		for _, name := range listed {
+			inst, err := h.InstanceManager.GetInstance(name)
+			if err != nil || !visibleTo(r, name) {
+				http.Error(w, fmt.Sprintf("Failed to get instance: instance %s not found", name), http.StatusNotFound)
+				return
+			}
+			if !inst.IsManaged() {
+				http.Error(w, fmt.Sprintf("logs are not available for instance %s: %v", name, instance.ErrUnmanaged), http.StatusConflict)
+				return
+			}
+		}
+
+		// matching returns the visible instances with logs the tail should follow
+		matching := func() (map[string]*instance.Process, error) {
+			names := listed
+			if selector != "" {
+				var err error
+				if names, err = manager.SelectInstances(h.InstanceManager, h.cfg.Services, selector); err != nil {
+					return nil, err
+				}
+			}
+			matched := make(map[string]*instance.Process, len(names))
+			for _, name := range names {
+				inst, err := h.InstanceManager.GetInstance(name)
+				if err == nil && inst.IsManaged() && visibleTo(r, name) {
+					matched[name] = inst
+				}
+			}
+			return matched, nil
+		}
+		if _, err := matching(); err != nil {
+			http.Error(w, "Invalid selector: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		bus, unsubscribe := h.InstanceManager.SubscribeEvents()
+		defer unsubscribe()
+		tail := newLogTail()
+		defer tail.close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		writeEvent := func(event string, payload any) {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		}
+		writeLines := func() {
+			for {
+				line, dropped, ok := tail.next()
+				if !ok {
+					break
+				}
+				if dropped > 0 {
+					writeEvent("dropped", TailDropped{Instance: line.Instance, Dropped: dropped})
+				}
+				writeEvent("log", line)
+			}
+			flusher.Flush()
+		}
+		// rescan follows the instances that started matching and drops those that stopped
+		rescan := func() {
+			matched, err := matching()
+			if err != nil {
+				return
+			}
+			for _, name := range tail.names() {
+				if _, ok := matched[name]; !ok {
+					tail.remove(name)
+					writeEvent("leave", TailStatus{Instance: name, Status: "left"})
+				}
+			}
+			followed := tail.names()
+			joining := make([]string, 0, len(matched))
+			for name := range matched {
+				if !slices.Contains(followed, name) {
+					joining = append(joining, name)
+				}
+			}
+			slices.Sort(joining)
+			for _, name := range joining {
+				inst := matched[name]
+				follower, err := inst.FollowLogs(numLines, true)
+				if err != nil {
+					if !errors.Is(err, instance.ErrUnmanaged) {
+						writeEvent("leave", TailStatus{Instance: name, Status: "left", Message: err.Error()})
+					}
+					continue
+				}
+				writeEvent("join", TailStatus{Instance: name, Status: inst.GetStatus().String()})
+				tail.add(name, follower)
+			}
+			writeLines()
+		}
+
+		rescan()
+		ticker := time.NewTicker(tailRescanInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-tail.notify:
+				writeLines()
+			case <-ticker.C:
+				rescan()
+			case event, ok := <-bus:
+				if !ok {
+					return
+				}
+				if event.Type != events.TypeStatusChange {
+					continue
+				}
+				if !slices.Contains(tail.names(), event.Instance) {
+					// It may be an instance the selector now matches
+					rescan()
+					continue
+				}
+				// Lines logged before the change are sent first
+				writeLines()
+				status, _ := event.Data["new_status"].(string)
+				writeEvent("status", TailStatus{Instance: event.Instance, Status: status, Code: event.Code, Message: event.Message})
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package server_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is an event read from a Server-Sent Events stream
+type sseEvent struct {
+	name string
+	data string
+}
+
+func TestTailLogs(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	cfg := config.AppConfig{
+		Backends: config.BackendConfig{LlamaCpp: config.BackendSettings{
+			Command: "sh",
+			Args:    []string{"-c", "echo loaded; exec sleep 30"},
+		}},
+		Instances: config.InstancesConfig{
+			PortRange:            [2]int{8000, 9000},
+			LogsDir:              t.TempDir(),
+			MaxInstances:         10,
+			MaxRunningInstances:  -1,
+			TimeoutCheckInterval: config.Duration(5 * time.Minute),
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+
+	create := func(name string, tags ...string) {
+		t.Helper()
+		_, err := mngr.CreateInstance(name, &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			AutoRestart:        testutil.BoolPtr(false),
+			Tags:               tags,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+		})
+		if err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+		if _, err := mngr.StartInstance(name); err != nil {
+			t.Fatalf("StartInstance failed: %v", err)
+		}
+	}
+	create("chat-1", "chat")
+	create("chat-2", "chat")
+	create("embed", "embed")
+
+	srv := httptest.NewServer(server.SetupRouter(server.NewHandler(mngr, cfg)))
+	defer srv.Close()
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		tests := map[string]int{
+			"":                               http.StatusBadRequest,
+			"?instances=chat-1&selector=all": http.StatusBadRequest,
+			"?selector=flavor:sweet":         http.StatusBadRequest,
+			"?instances=chat-1&lines=100000": http.StatusBadRequest,
+			"?instances=chat-1,missing":      http.StatusNotFound,
+		}
+		for query, status := range tests {
+			resp, err := http.Get(srv.URL + "/api/v1/logs/tail" + query)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != status {
+				t.Errorf("Expected %d for %q, got %d", status, query, resp.StatusCode)
+			}
+		}
+	})
+
+	t.Run("selector", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/api/v1/logs/tail?selector=tag:chat&lines=5")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Expected an event stream, got %q", ct)
+		}
+
+		received := make(chan sseEvent)
+		go func() {
+			defer close(received)
+			scanner := bufio.NewScanner(resp.Body)
+			var event sseEvent
+			for scanner.Scan() {
+				line := scanner.Text()
+				switch {
+				case strings.HasPrefix(line, "event: "):
+					event.name = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					event.data = strings.TrimPrefix(line, "data: ")
+				case line == "":
+					received <- event
+					event = sseEvent{}
+				}
+			}
+		}()
+
+		// waitFor waits for an event of the given name satisfying match, among the events
+		// received so far or those to come
+		var seen []sseEvent
+		waitFor := func(name string, match func(data string) bool) {
+			t.Helper()
+			for _, event := range seen {
+				if event.name == name && match(event.data) {
+					return
+				}
+			}
+			deadline := time.After(10 * time.Second)
+			for {
+				select {
+				case event := <-received:
+					seen = append(seen, event)
+					if event.name == name && match(event.data) {
+						return
+					}
+				case <-deadline:
+					t.Fatalf("Timed out waiting for a %s event", name)
+				}
+			}
+		}
+		lineOf := func(inst string) func(string) bool {
+			return func(data string) bool {
+				var line server.TailLine
+				json.Unmarshal([]byte(data), &line)
+				if line.Instance == "embed" {
+					t.Errorf("Expected only instances matching the selector, got %s", data)
+				}
+				return line.Instance == inst && line.Line == "loaded"
+			}
+		}
+		statusOf := func(inst, status string) func(string) bool {
+			return func(data string) bool {
+				var change server.TailStatus
+				json.Unmarshal([]byte(data), &change)
+				return change.Instance == inst && change.Status == status
+			}
+		}
+
+		// Each matching instance starts with its last lines
+		waitFor("log", lineOf("chat-1"))
+		waitFor("log", lineOf("chat-2"))
+
+		// An instance stopping mid-stream says so
+		if _, err := mngr.StopInstance("chat-1"); err != nil {
+			t.Fatalf("StopInstance failed: %v", err)
+		}
+		waitFor("status", statusOf("chat-1", "stopped"))
+
+		// A new instance matching the selector joins the stream
+		create("chat-3", "chat")
+		waitFor("join", func(data string) bool { return strings.Contains(data, `"instance":"chat-3"`) })
+		waitFor("log", lineOf("chat-3"))
+
+		// A deleted instance leaves it
+		if err := mngr.DeleteInstance("chat-1"); err != nil {
+			t.Fatalf("DeleteInstance failed: %v", err)
+		}
+		waitFor("leave", statusOf("chat-1", "left"))
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"llamactl/pkg/manager"
+	"net/http"
+)
+
+// RollingRestartRequest selects the instances of a rolling restart and how it proceeds
+type RollingRestartRequest struct {
+	// "all", "tag:<tag>" or "group:<service>"
+	Selector string `json:"selector"`
+	manager.RollingRestartOptions
+}
+
+// StartRollingRestart godoc
+// @Summary Start a rolling restart
+// @Description Restarts the running instances matching the selector in the background, max_unavailable at a time, waiting for each to become ready before moving on. With if_binary_changed, the instances whose backend binary did not change since they were launched are skipped. The rolling restart is aborted once more than max_failures instances fail. Progress is published as rolling_restart events.
+// @Tags maintenance
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param request body RollingRestartRequest true "Selector and rolling restart options"
+// @Success 202 {object} manager.RollingRestartStatus "Rolling restart started"
+// @Failure 400 {string} string "Invalid selector or options"
+// @Failure 409 {string} string "A rolling restart is already running"
+// @Router /maintenance/rolling-restart [post]
+func (h *Handler) StartRollingRestart() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req RollingRestartRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		names, err := manager.SelectInstances(h.InstanceManager, h.cfg.Services, req.Selector)
+		if err != nil {
+			http.Error(w, "Failed to select instances: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		status, err := h.managerFor(r).StartRollingRestart(req.Selector, names, req.RollingRestartOptions)
+		if errors.Is(err, manager.ErrRollingRestartRunning) {
+			http.Error(w, "Failed to start rolling restart: "+err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to start rolling restart: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode rolling restart status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetRollingRestartStatus godoc
+// @Summary Get the rolling restart status
+// @Description Returns the progress of the current or most recent rolling restart, or the idle state if none has run
+// @Tags maintenance
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} manager.RollingRestartStatus "Rolling restart status"
+// @Router /maintenance/rolling-restart/status [get]
+func (h *Handler) GetRollingRestartStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.InstanceManager.GetRollingRestartStatus()); err != nil {
+			http.Error(w, "Failed to encode rolling restart status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
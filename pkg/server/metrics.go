@@ -0,0 +1,160 @@
+package server
+
+import (
+	"llamactl/pkg/manager"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// proxyMetrics holds the Prometheus histograms of proxied inference requests
+type proxyMetrics struct {
+	registry         *prometheus.Registry
+	ttfb             *prometheus.HistogramVec
+	duration         *prometheus.HistogramVec
+	promptTokens     *prometheus.HistogramVec
+	completionTokens *prometheus.HistogramVec
+}
+
+func newProxyMetrics() *proxyMetrics {
+	m := &proxyMetrics{
+		registry: prometheus.NewRegistry(),
+		ttfb: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "llamactl_request_ttfb_seconds",
+			Help:    "Time until the first byte of the response of a proxied request was written.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+		}, []string{"instance"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "llamactl_request_duration_seconds",
+			Help:    "Total duration of a proxied request, including streaming the response.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+		}, []string{"instance"}),
+		promptTokens: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "llamactl_request_prompt_tokens",
+			Help:    "Prompt tokens of a proxied request, as reported by the backend.",
+			Buckets: prometheus.ExponentialBuckets(16, 2, 12),
+		}, []string{"instance"}),
+		completionTokens: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "llamactl_request_completion_tokens",
+			Help:    "Completion tokens of a proxied request, as reported by the backend.",
+			Buckets: prometheus.ExponentialBuckets(16, 2, 12),
+		}, []string{"instance"}),
+	}
+	m.registry.MustRegister(m.ttfb, m.duration, m.promptTokens, m.completionTokens)
+	return m
+}
+
+// observe records a completed proxied request. Token counts are only observed when the backend reported them.
+func (m *proxyMetrics) observe(instance string, hasFirstByte bool, ttfb, duration time.Duration, usage *tokenUsage) {
+	if hasFirstByte {
+		m.ttfb.WithLabelValues(instance).Observe(ttfb.Seconds())
+	}
+	m.duration.WithLabelValues(instance).Observe(duration.Seconds())
+	if usage != nil && usage.PromptTokens != nil {
+		m.promptTokens.WithLabelValues(instance).Observe(float64(*usage.PromptTokens))
+	}
+	if usage != nil && usage.CompletionTokens != nil {
+		m.completionTokens.WithLabelValues(instance).Observe(float64(*usage.CompletionTokens))
+	}
+}
+
+// Descriptions of the restart budget gauges
+var (
+	restartArmedDesc = prometheus.NewDesc("llamactl_instance_restart_armed",
+		"Whether a crash of the instance now would be followed by an automatic restart.", []string{"instance"}, nil)
+	restartsDesc = prometheus.NewDesc("llamactl_instance_restarts",
+		"Automatic restarts of the instance counted against max_restarts.", []string{"instance"}, nil)
+	restartRemainingDesc = prometheus.NewDesc("llamactl_instance_restart_budget_remaining",
+		"Automatic restarts left before the instance fails.", []string{"instance"}, nil)
+	restartResetDesc = prometheus.NewDesc("llamactl_instance_restart_budget_reset_seconds",
+		"Seconds until the current run has been ready long enough for the restart counter to start over.", []string{"instance"}, nil)
+)
+
+// restartBudgetCollector reports the restart budget of every managed instance when scraped,
+// so the gauges are as current as the instance details
+type restartBudgetCollector struct {
+	im manager.InstanceManager
+}
+
+func (c restartBudgetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- restartArmedDesc
+	ch <- restartsDesc
+	ch <- restartRemainingDesc
+	ch <- restartResetDesc
+}
+
+func (c restartBudgetCollector) Collect(ch chan<- prometheus.Metric) {
+	instances, err := c.im.ListInstances()
+	if err != nil {
+		return
+	}
+	for _, inst := range instances {
+		if !inst.IsManaged() {
+			continue
+		}
+		budget := inst.GetRestartBudget()
+		armed := 0.0
+		if budget.Armed {
+			armed = 1
+		}
+		ch <- prometheus.MustNewConstMetric(restartArmedDesc, prometheus.GaugeValue, armed, inst.Name)
+		ch <- prometheus.MustNewConstMetric(restartsDesc, prometheus.GaugeValue, float64(budget.Restarts), inst.Name)
+		ch <- prometheus.MustNewConstMetric(restartRemainingDesc, prometheus.GaugeValue, float64(budget.Remaining), inst.Name)
+		if budget.ResetsIn != nil {
+			ch <- prometheus.MustNewConstMetric(restartResetDesc, prometheus.GaugeValue, float64(*budget.ResetsIn), inst.Name)
+		}
+	}
+}
+
+// Descriptions of the log disk quota gauges
+var (
+	logDiskQuotaDesc = prometheus.NewDesc("llamactl_log_disk_quota_bytes",
+		"Bytes the log files of all instances can use together, when log_disk_quota_mb is set.", nil, nil)
+	logDiskUsageDesc = prometheus.NewDesc("llamactl_log_disk_usage_bytes",
+		"Bytes the log files of all instances use, as of the last check.", nil, nil)
+	instanceLogDiskUsageDesc = prometheus.NewDesc("llamactl_instance_log_disk_usage_bytes",
+		"Bytes the current and rotated log files of the instance use, as of the last check.", []string{"instance"}, nil)
+	instanceLogDiskShareDesc = prometheus.NewDesc("llamactl_instance_log_disk_share_bytes",
+		"Share of the log disk quota the log files of the instance can use.", []string{"instance"}, nil)
+)
+
+// logQuotaCollector reports the disk space of the log files measured by the last check of
+// the log disk quota, so scrapes never read the logs directory
+type logQuotaCollector struct {
+	im manager.InstanceManager
+}
+
+func (c logQuotaCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- logDiskQuotaDesc
+	ch <- logDiskUsageDesc
+	ch <- instanceLogDiskUsageDesc
+	ch <- instanceLogDiskShareDesc
+}
+
+func (c logQuotaCollector) Collect(ch chan<- prometheus.Metric) {
+	status := c.im.GetLogQuotaStatus()
+	if status.QuotaBytes > 0 {
+		ch <- prometheus.MustNewConstMetric(logDiskQuotaDesc, prometheus.GaugeValue, float64(status.QuotaBytes))
+	}
+	ch <- prometheus.MustNewConstMetric(logDiskUsageDesc, prometheus.GaugeValue, float64(status.UsedBytes))
+	for _, usage := range status.Instances {
+		ch <- prometheus.MustNewConstMetric(instanceLogDiskUsageDesc, prometheus.GaugeValue, float64(usage.UsedBytes), usage.Name)
+		if usage.ShareBytes > 0 {
+			ch <- prometheus.MustNewConstMetric(instanceLogDiskShareDesc, prometheus.GaugeValue, float64(usage.ShareBytes), usage.Name)
+		}
+	}
+}
+
+// MetricsHandler godoc
+// @Summary Prometheus metrics
+// @Description Exposes request latency and token count histograms of proxied requests, the restart budget of instances and the disk space of their log files, in the Prometheus text format
+// @Tags metrics
+// @Security ApiKeyAuth
+// @Produces text/plain
+// @Success 200 {string} string "Prometheus metrics"
+// @Router /metrics [get]
+func (h *Handler) MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(h.metrics.registry, promhttp.HandlerOpts{})
+}
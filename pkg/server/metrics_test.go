@@ -0,0 +1,56 @@
+package server_test
+
+import (
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"llamactl/pkg/testutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_RestartBudget(t *testing.T) {
+	cfg := config.AppConfig{
+		Instances: config.InstancesConfig{
+			PortRange:           [2]int{8000, 9000},
+			LogsDir:             t.TempDir(),
+			MaxInstances:        10,
+			MaxRunningInstances: -1,
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+	router := server.SetupRouter(server.NewHandler(mngr, cfg))
+
+	for name, autoRestart := range map[string]bool{"armed": true, "disarmed": false} {
+		_, err := mngr.CreateInstance(name, &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			AutoRestart:        testutil.BoolPtr(autoRestart),
+			MaxRestarts:        testutil.IntPtr(3),
+			RestartDelay:       testutil.DurationPtr(time.Second),
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+		})
+		if err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, metric := range []string{
+		`llamactl_instance_restart_armed{instance="armed"} 1`,
+		`llamactl_instance_restart_armed{instance="disarmed"} 0`,
+		`llamactl_instance_restarts{instance="armed"} 0`,
+		`llamactl_instance_restart_budget_remaining{instance="armed"} 3`,
+	} {
+		if !strings.Contains(body, metric) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", metric, body)
+		}
+	}
+}
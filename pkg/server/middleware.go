@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"llamactl/pkg/config"
 	"log"
@@ -24,6 +26,9 @@ type APIAuthMiddleware struct {
 	inferenceKeys         map[string]bool
 	requireManagementAuth bool
 	managementKeys        map[string]bool
+	jwtConfig             config.JWTAuthConfig
+	jwt                   *jwtVerifier     // nil when no issuer is configured
+	signing               *requestVerifier // nil when no signing secret is configured
 }
 
 // NewAPIAuthMiddleware creates a new APIAuthMiddleware with the given configuration
@@ -36,7 +41,8 @@ func NewAPIAuthMiddleware(authCfg config.AuthConfig) *APIAuthMiddleware {
 
 	const banner = "━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━"
 
-	if authCfg.RequireManagementAuth && len(authCfg.ManagementKeys) == 0 {
+	// Tokens authenticate management requests without a key when an issuer is configured
+	if authCfg.RequireManagementAuth && len(authCfg.ManagementKeys) == 0 && authCfg.JWT.Issuer == "" {
 		key := generateAPIKey(KeyTypeManagement)
 		managementAPIKeys[key] = true
 		generated = true
@@ -47,7 +53,8 @@ func NewAPIAuthMiddleware(authCfg config.AuthConfig) *APIAuthMiddleware {
 		managementAPIKeys[key] = true
 	}
 
-	if authCfg.RequireInferenceAuth && len(authCfg.InferenceKeys) == 0 {
+	// Signed requests authenticate inference requests without a key when a secret is configured
+	if authCfg.RequireInferenceAuth && len(authCfg.InferenceKeys) == 0 && len(authCfg.RequestSigning.Secrets) == 0 {
 		key := generateAPIKey(KeyTypeInference)
 		inferenceAPIKeys[key] = true
 		generated = true
@@ -66,12 +73,20 @@ func NewAPIAuthMiddleware(authCfg config.AuthConfig) *APIAuthMiddleware {
 		fmt.Println(banner)
 	}
 
-	return &APIAuthMiddleware{
+	middleware := &APIAuthMiddleware{
 		requireInferenceAuth:  authCfg.RequireInferenceAuth,
 		inferenceKeys:         inferenceAPIKeys,
 		requireManagementAuth: authCfg.RequireManagementAuth,
 		managementKeys:        managementAPIKeys,
+		jwtConfig:             authCfg.JWT,
 	}
+	if authCfg.JWT.Issuer != "" {
+		middleware.jwt = newJWTVerifier(authCfg.JWT)
+	}
+	if len(authCfg.RequestSigning.Secrets) > 0 {
+		middleware.signing = newRequestVerifier(authCfg.RequestSigning)
+	}
+	return middleware
 }
 
 // generateAPIKey creates a cryptographically secure API key
@@ -100,7 +115,10 @@ func generateAPIKey(keyType KeyType) string {
 	return fmt.Sprintf("%s-%s", prefix, hex.EncodeToString(randomBytes))
 }
 
-// AuthMiddleware returns a middleware that checks API keys for the given key type
+// AuthMiddleware returns a middleware that checks API keys for the given key type. Management
+// requests may also carry a JWT when an issuer is configured; their caller is checked against
+// the scope the request needs and passed on to the handlers. Inference requests may instead
+// be signed by a trusted gateway when signing secrets are configured.
 func (a *APIAuthMiddleware) AuthMiddleware(keyType KeyType) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -109,9 +127,52 @@ func (a *APIAuthMiddleware) AuthMiddleware(keyType KeyType) func(http.Handler) h
 				return
 			}
 
-			apiKey := a.extractAPIKey(r)
+			if keyType == KeyTypeInference && a.signing != nil && r.Header.Get(headerSignature) != "" {
+				// A valid API key lets the request through whatever its signature, so its
+				// body is left to the handlers unread
+				if key := extractAPIKey(r); key != "" && (a.isValidKey(key, KeyTypeInference) || a.isValidKey(key, KeyTypeManagement)) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				err := a.signing.verify(w, r)
+				if errors.Is(err, errSignedBodyTooLarge) {
+					writeAuthError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "body_too_large",
+						fmt.Sprintf("Signed request bodies are limited to %d bytes", a.signing.maxBodySize))
+					return
+				}
+				var signatureErr *tokenError
+				if errors.As(err, &signatureErr) {
+					a.unauthorized(w, signatureErr.Code, signatureErr.Message)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			apiKey := extractAPIKey(r)
 			if apiKey == "" {
-				a.unauthorized(w, "Missing API key")
+				a.unauthorized(w, "missing_credentials", "Missing API key")
+				return
+			}
+
+			if keyType == KeyTypeManagement {
+				identity, err := a.managementIdentity(apiKey)
+				var tokenErr *tokenError
+				switch {
+				case errors.As(err, &tokenErr) && tokenErr.Code == tokenInsufficientScope:
+					a.forbidden(w, tokenErr.Code, tokenErr.Message)
+					return
+				case errors.As(err, &tokenErr):
+					a.unauthorized(w, tokenErr.Code, tokenErr.Message)
+					return
+				case identity == nil:
+					a.unauthorized(w, "invalid_api_key", "Invalid API key")
+					return
+				case !identity.authorize(r):
+					a.forbidden(w, tokenInsufficientScope, fmt.Sprintf("%s %s requires the %s scope", r.Method, r.URL.Path, requiredScope(r)))
+					return
+				}
+				next.ServeHTTP(w, withIdentity(r, identity))
 				return
 			}
 
@@ -120,14 +181,12 @@ func (a *APIAuthMiddleware) AuthMiddleware(keyType KeyType) func(http.Handler) h
 			case KeyTypeInference:
 				// Management keys also work for OpenAI endpoints (higher privilege)
 				isValid = a.isValidKey(apiKey, KeyTypeInference) || a.isValidKey(apiKey, KeyTypeManagement)
-			case KeyTypeManagement:
-				isValid = a.isValidKey(apiKey, KeyTypeManagement)
 			default:
 				isValid = false
 			}
 
 			if !isValid {
-				a.unauthorized(w, "Invalid API key")
+				a.unauthorized(w, "invalid_api_key", "Invalid API key")
 				return
 			}
 
@@ -136,8 +195,24 @@ func (a *APIAuthMiddleware) AuthMiddleware(keyType KeyType) func(http.Handler) h
 	}
 }
 
+// managementIdentity authenticates a management API key or, when an issuer is configured, a
+// JWT. It returns nil without an error for an invalid API key.
+func (a *APIAuthMiddleware) managementIdentity(credential string) (*Identity, error) {
+	if a.isValidKey(credential, KeyTypeManagement) {
+		return keyIdentity(credential), nil
+	}
+	if a.jwt == nil || !looksLikeJWT(credential) {
+		return nil, nil
+	}
+	claims, err := a.jwt.verify(credential)
+	if err != nil {
+		return nil, err
+	}
+	return tokenIdentity(a.jwtConfig, claims)
+}
+
 // extractAPIKey extracts the API key from the request
-func (a *APIAuthMiddleware) extractAPIKey(r *http.Request) string {
+func extractAPIKey(r *http.Request) string {
 	// Check Authorization header: "Bearer sk-..."
 	if auth := r.Header.Get("Authorization"); auth != "" {
 		if after, ok := strings.CutPrefix(auth, "Bearer "); ok {
@@ -181,9 +256,19 @@ func (a *APIAuthMiddleware) isValidKey(providedKey string, keyType KeyType) bool
 }
 
 // unauthorized sends an unauthorized response
-func (a *APIAuthMiddleware) unauthorized(w http.ResponseWriter, message string) {
+func (a *APIAuthMiddleware) unauthorized(w http.ResponseWriter, code, message string) {
+	writeAuthError(w, http.StatusUnauthorized, "authentication_error", code, message)
+}
+
+// forbidden sends the response of an authenticated caller without the required scope
+func (a *APIAuthMiddleware) forbidden(w http.ResponseWriter, code, message string) {
+	writeAuthError(w, http.StatusForbidden, "permission_error", code, message)
+}
+
+func writeAuthError(w http.ResponseWriter, status int, errorType, code, message string) {
+	quoted, _ := json.Marshal(message)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusUnauthorized)
-	response := fmt.Sprintf(`{"error": {"message": "%s", "type": "authentication_error"}}`, message)
+	w.WriteHeader(status)
+	response := fmt.Sprintf(`{"error": {"message": %s, "type": "%s", "code": "%s"}}`, quoted, errorType, code)
 	w.Write([]byte(response))
 }
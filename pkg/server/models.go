@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"llamactl/pkg/models"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// GetModel godoc
+// @Summary Get a registered model
+// @Description Returns the file name, size and SHA-256 checksum of a model registered for download by other nodes
+// @Tags models
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Model Name"
+// @Success 200 {object} models.ModelInfo "Model info"
+// @Failure 404 {string} string "Model not found"
+// @Router /models/{name} [get]
+func (h *Handler) GetModel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, _, err := h.models.Lookup(chi.URLParam(r, "name"))
+		if err != nil {
+			http.Error(w, "Failed to get model: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			http.Error(w, "Failed to encode model: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DownloadModel godoc
+// @Summary Download a registered model
+// @Description Serves the file of a registered model. Range requests are supported to resume downloads, and the X-Checksum-Sha256 header carries the checksum of the whole file.
+// @Tags models
+// @Security ApiKeyAuth
+// @Produces application/octet-stream
+// @Param name path string true "Model Name"
+// @Success 200 {file} file "Model file"
+// @Success 206 {file} file "Requested range of the model file"
+// @Failure 404 {string} string "Model not found"
+// @Failure 416 {string} string "Requested range not satisfiable"
+// @Router /models/{name}/download [get]
+func (h *Handler) DownloadModel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, path, err := h.models.Lookup(chi.URLParam(r, "name"))
+		if err != nil {
+			http.Error(w, "Failed to get model: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			http.Error(w, "Failed to open model: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil {
+			http.Error(w, "Failed to stat model: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set(models.ChecksumHeader, info.SHA256)
+		// The checksum doubles as a strong ETag, so If-Range only resumes the same file
+		w.Header().Set("ETag", `"`+info.SHA256+`"`)
+		http.ServeContent(w, r, info.Filename, stat.ModTime(), f)
+	}
+}
+
+// AvailableModel is an indexed GGUF file along with the instances running it
+type AvailableModel struct {
+	models.IndexedModel
+	Instances []string `json:"instances"`
+}
+
+// ListAvailableModels godoc
+// @Summary List available model files
+// @Description Returns the GGUF files found in the configured model directories with the metadata read from their headers: architecture, parameter count, quantization, context length and chat template presence. Each file lists the instances that reference it.
+// @Tags models
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {array} AvailableModel "Indexed model files"
+// @Failure 404 {string} string "Model indexing is not enabled"
+// @Router /models/available [get]
+func (h *Handler) ListAvailableModels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.modelIndex == nil {
+			http.Error(w, "Model indexing is not enabled", http.StatusNotFound)
+			return
+		}
+		h.writeAvailableModels(w, h.modelIndex.List())
+	}
+}
+
+// RescanModels godoc
+// @Summary Rescan the model directories
+// @Description Scans the configured model directories right away, indexing new and changed GGUF files, and returns the updated index
+// @Tags models
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {array} AvailableModel "Indexed model files"
+// @Failure 404 {string} string "Model indexing is not enabled"
+// @Router /models/rescan [post]
+func (h *Handler) RescanModels() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.modelIndex == nil {
+			http.Error(w, "Model indexing is not enabled", http.StatusNotFound)
+			return
+		}
+		h.writeAvailableModels(w, h.modelIndex.Rescan())
+	}
+}
+
+func (h *Handler) writeAvailableModels(w http.ResponseWriter, indexed []models.IndexedModel) {
+	instances, err := h.InstanceManager.ListInstances()
+	if err != nil {
+		http.Error(w, "Failed to list instances: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Instance model paths are resolved the way the backend, run from the same working
+	// directory, resolves them
+	referencedBy := make(map[string][]string)
+	for _, inst := range instances {
+		model := inst.GetOptions().ModelPath()
+		if model == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(model); err == nil {
+			referencedBy[abs] = append(referencedBy[abs], inst.Name)
+		}
+	}
+
+	available := make([]AvailableModel, 0, len(indexed))
+	for _, model := range indexed {
+		names := referencedBy[model.Path]
+		sort.Strings(names)
+		if names == nil {
+			names = []string{}
+		}
+		available = append(available, AvailableModel{IndexedModel: model, Instances: names})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(available); err != nil {
+		http.Error(w, "Failed to encode models: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,68 @@
+package server_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/server"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAvailableModels_ReferencedByInstances(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	dir := t.TempDir()
+	// Minimal GGUF v3 header without tensors or metadata
+	header := []byte("GGUF\x03\x00\x00\x00" + "\x00\x00\x00\x00\x00\x00\x00\x00" + "\x00\x00\x00\x00\x00\x00\x00\x00")
+	for _, name := range []string{"used.gguf", "unused.gguf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), header, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := newExternalBackendRouterWithOptions(t, backend, func(cfg *config.AppConfig, options *instance.CreateInstanceOptions) {
+		cfg.ModelIndex.Dirs = []string{dir}
+		options.LlamaServerOptions.Model = filepath.Join(dir, "used.gguf")
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("POST", "/api/v1/models/rescan", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	var available []server.AvailableModel
+	if err := json.Unmarshal(recorder.Body.Bytes(), &available); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(available) != 2 {
+		t.Fatalf("expected 2 models, got %+v", available)
+	}
+	for _, model := range available {
+		if model.Error != "" {
+			t.Errorf("%s: unexpected parse error %q", model.Path, model.Error)
+		}
+		expected := 0
+		if filepath.Base(model.Path) == "used.gguf" {
+			expected = 1
+		}
+		if len(model.Instances) != expected {
+			t.Errorf("%s: expected %d referencing instances, got %v", model.Path, expected, model.Instances)
+		}
+	}
+
+	// Without configured directories the index is unavailable
+	disabled := newExternalBackendRouter(t, backend)
+	recorder = httptest.NewRecorder()
+	disabled.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/models/available", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 with indexing disabled, got %d", recorder.Code)
+	}
+}
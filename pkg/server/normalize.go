@@ -0,0 +1,323 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const (
+	// maxNormalizeLineBytes caps a buffered SSE line; longer lines are passed through unchanged
+	maxNormalizeLineBytes = 1024 * 1024
+	// maxNormalizeBodyBytes caps the buffered body of non-streamed JSON responses
+	maxNormalizeBodyBytes = 16 * 1024 * 1024
+)
+
+// Fields of the strict OpenAI shape, per kind of object. Anything else is dropped.
+var (
+	completionFields = fieldSet("id", "object", "created", "model", "choices", "usage", "system_fingerprint", "service_tier")
+	choiceFields     = fieldSet("index", "message", "delta", "text", "finish_reason", "logprobs")
+	messageFields    = fieldSet("role", "content", "refusal", "tool_calls", "function_call")
+	usageFields      = fieldSet("prompt_tokens", "completion_tokens", "total_tokens", "prompt_tokens_details", "completion_tokens_details")
+	listFields       = fieldSet("object", "data", "model", "usage")
+	embeddingFields  = fieldSet("object", "index", "embedding")
+)
+
+// objectTypes maps nonstandard object types to their OpenAI names
+var objectTypes = map[string]string{
+	"chat.completions":       "chat.completion",
+	"chat.completions.chunk": "chat.completion.chunk",
+	"text_completion.chunk":  "text_completion",
+	"completion":             "text_completion",
+}
+
+// finishReasons maps backend-specific finish reasons to the OpenAI values
+var finishReasons = map[string]string{
+	"stop":           "stop",
+	"length":         "length",
+	"tool_calls":     "tool_calls",
+	"content_filter": "content_filter",
+	"function_call":  "function_call",
+	"eos":            "stop",
+	"eos_token":      "stop",
+	"stop_sequence":  "stop",
+	"stopped_eos":    "stop",
+	"stopped_word":   "stop",
+	"max_tokens":     "length",
+	"limit":          "length",
+	"stopped_limit":  "length",
+	"tool_call":      "tool_calls",
+	"tool_use":       "tool_calls",
+}
+
+func fieldSet(fields ...string) map[string]bool {
+	set := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		set[field] = true
+	}
+	return set
+}
+
+// responseNormalizer rewrites the OpenAI responses of a backend to a strict OpenAI-compatible
+// shape: nonstandard fields are dropped, object types and finish reasons are normalized and
+// the model field is set to the model the client requested. SSE streams are rewritten event
+// by event as each line completes, so only the current line is held back; non-streamed JSON
+// bodies are buffered up to maxNormalizeBodyBytes. Error responses, compressed bodies and
+// anything that does not parse are passed through unchanged.
+type responseNormalizer struct {
+	w     http.ResponseWriter
+	model string
+
+	wroteHeader bool
+	mode        int // normalizeModeNone, normalizeModeSSE or normalizeModeJSON, decided by WriteHeader
+	line        []byte
+	passLine    bool // The current line outgrew maxNormalizeLineBytes and is passed through
+	body        []byte
+}
+
+const (
+	normalizeModeNone = iota
+	normalizeModeSSE
+	normalizeModeJSON
+)
+
+func newResponseNormalizer(w http.ResponseWriter, model string) *responseNormalizer {
+	return &responseNormalizer{w: w, model: model}
+}
+
+func (n *responseNormalizer) Header() http.Header {
+	return n.w.Header()
+}
+
+func (n *responseNormalizer) WriteHeader(code int) {
+	if n.wroteHeader {
+		return
+	}
+	n.wroteHeader = true
+
+	header := n.w.Header()
+	encoding := header.Get("Content-Encoding")
+	if code >= 200 && code < 300 && (encoding == "" || encoding == "identity") {
+		contentType := header.Get("Content-Type")
+		switch {
+		case strings.HasPrefix(contentType, "text/event-stream"):
+			n.mode = normalizeModeSSE
+		case strings.HasPrefix(contentType, "application/json"):
+			n.mode = normalizeModeJSON
+		}
+	}
+	if n.mode != normalizeModeNone {
+		// The rewritten body has a different length
+		header.Del("Content-Length")
+	}
+	n.w.WriteHeader(code)
+}
+
+func (n *responseNormalizer) Write(p []byte) (int, error) {
+	if !n.wroteHeader {
+		n.WriteHeader(http.StatusOK)
+	}
+
+	switch n.mode {
+	case normalizeModeSSE:
+		if _, err := n.w.Write(n.scanLines(p)); err != nil {
+			return 0, err
+		}
+	case normalizeModeJSON:
+		if len(n.body)+len(p) <= maxNormalizeBodyBytes {
+			n.body = append(n.body, p...)
+			break
+		}
+		// Too large to rewrite, send what was buffered as is
+		n.mode = normalizeModeNone
+		body := append(n.body, p...)
+		n.body = nil
+		if _, err := n.w.Write(body); err != nil {
+			return 0, err
+		}
+	default:
+		return n.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// Flush sends everything written so far; an incomplete SSE line stays buffered until it completes
+func (n *responseNormalizer) Flush() {
+	if flusher, ok := n.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (n *responseNormalizer) Unwrap() http.ResponseWriter {
+	return n.w
+}
+
+// scanLines returns the output for p: completed lines are rewritten, and the tail of an
+// incomplete line is held back unless it is too long to rewrite
+func (n *responseNormalizer) scanLines(p []byte) []byte {
+	var out []byte
+	for len(p) > 0 {
+		idx := bytes.IndexByte(p, '\n')
+		if idx < 0 {
+			if n.passLine || len(n.line)+len(p) > maxNormalizeLineBytes {
+				out = append(out, n.line...)
+				out = append(out, p...)
+				n.line, n.passLine = n.line[:0], true
+			} else {
+				n.line = append(n.line, p...)
+			}
+			return out
+		}
+
+		if n.passLine {
+			out = append(out, p[:idx+1]...)
+		} else {
+			n.line = append(n.line, p[:idx]...)
+			out = append(out, n.rewriteEvent(n.line)...)
+			out = append(out, '\n')
+		}
+		n.line, n.passLine = n.line[:0], false
+		p = p[idx+1:]
+	}
+	return out
+}
+
+// rewriteEvent normalizes the JSON payload of an SSE data line, returning other lines unchanged
+func (n *responseNormalizer) rewriteEvent(line []byte) []byte {
+	content, cr := bytes.CutSuffix(line, []byte("\r"))
+	data, ok := bytes.CutPrefix(content, []byte("data:"))
+	if !ok {
+		return line
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return line // e.g. data: [DONE]
+	}
+
+	normalized, err := normalizeResponseJSON(data, n.model)
+	if err != nil {
+		return line
+	}
+	rewritten := append([]byte("data: "), normalized...)
+	if cr {
+		rewritten = append(rewritten, '\r')
+	}
+	return rewritten
+}
+
+// finish writes what is still buffered once the backend response is complete
+func (n *responseNormalizer) finish() {
+	switch n.mode {
+	case normalizeModeSSE:
+		if len(n.line) > 0 {
+			if n.passLine {
+				n.w.Write(n.line)
+			} else {
+				n.w.Write(n.rewriteEvent(n.line))
+			}
+		}
+	case normalizeModeJSON:
+		if len(n.body) == 0 {
+			break
+		}
+		if normalized, err := normalizeResponseJSON(bytes.TrimSpace(n.body), n.model); err == nil {
+			n.w.Write(normalized)
+		} else {
+			n.w.Write(n.body)
+		}
+	}
+	n.line, n.body = nil, nil
+}
+
+// normalizeResponseJSON rewrites a single OpenAI response object
+func normalizeResponseJSON(data []byte, model string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // Keep integers and floats exactly as the backend sent them
+
+	var obj map[string]any
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, err
+	}
+	normalizeResponse(obj, model)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(obj); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// normalizeResponse rewrites a completion, chat completion (chunk) or embeddings list in place.
+// Objects of other types, such as errors, are left alone.
+func normalizeResponse(obj map[string]any, model string) {
+	object, _ := obj["object"].(string)
+	if renamed, ok := objectTypes[object]; ok {
+		object = renamed
+		obj["object"] = object
+	}
+
+	switch object {
+	case "chat.completion", "chat.completion.chunk", "text_completion":
+		keepFields(obj, completionFields)
+		if choices, ok := obj["choices"].([]any); ok {
+			for idx, c := range choices {
+				if choice, ok := c.(map[string]any); ok {
+					normalizeChoice(choice, idx)
+				}
+			}
+		}
+	case "list":
+		keepFields(obj, listFields)
+		if data, ok := obj["data"].([]any); ok {
+			for _, d := range data {
+				if item, ok := d.(map[string]any); ok && item["object"] == "embedding" {
+					keepFields(item, embeddingFields)
+				}
+			}
+		}
+	default:
+		return
+	}
+
+	if usage, ok := obj["usage"].(map[string]any); ok {
+		keepFields(usage, usageFields)
+	}
+	obj["model"] = model
+}
+
+func normalizeChoice(choice map[string]any, idx int) {
+	keepFields(choice, choiceFields)
+	if _, ok := choice["index"]; !ok {
+		choice["index"] = idx
+	}
+
+	// Strict clients expect finish_reason to be present, null until the last chunk
+	switch reason := choice["finish_reason"].(type) {
+	case nil:
+		choice["finish_reason"] = nil
+	case string:
+		if normalized, ok := finishReasons[reason]; ok {
+			choice["finish_reason"] = normalized
+		} else {
+			choice["finish_reason"] = "stop"
+		}
+	}
+
+	for _, key := range []string{"message", "delta"} {
+		if message, ok := choice[key].(map[string]any); ok {
+			keepFields(message, messageFields)
+		}
+	}
+}
+
+func keepFields(obj map[string]any, allowed map[string]bool) {
+	for key := range obj {
+		if !allowed[key] {
+			delete(obj, key)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package server_test
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"io"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// newNormalizingRouter serves the llamactl API in front of backend, with response
+// normalization enabled for the "external" instance
+func newNormalizingRouter(t *testing.T, backend *httptest.Server) http.Handler {
+	t.Helper()
+	return newExternalBackendRouterWithOptions(t, backend, func(_ *config.AppConfig, options *instance.CreateInstanceOptions) {
+		normalize := true
+		options.NormalizeResponses = &normalize
+	})
+}
+
+// TestNormalizeResponses_Golden replays recorded backend responses of each backend flavor,
+// split into small flushed writes, and compares the normalized output with the golden files
+func TestNormalizeResponses_Golden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "normalize", "*.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range inputs {
+		if strings.HasSuffix(input, ".golden") {
+			continue
+		}
+
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			raw, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contentType := "application/json"
+			if filepath.Ext(input) == ".sse" {
+				contentType = "text/event-stream"
+			}
+
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/health" {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				w.Header().Set("Content-Type", contentType)
+				for chunk := range chunks(raw, 37) {
+					w.Write(chunk)
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer backend.Close()
+
+			router := newNormalizingRouter(t, backend)
+			req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"external"}`))
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+
+			golden := input + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, recorder.Body.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(recorder.Body.Bytes(), expected) {
+				t.Errorf("normalized response does not match %s\ngot:\n%s\nwant:\n%s", golden, recorder.Body.String(), expected)
+			}
+		})
+	}
+}
+
+// chunks yields data in chunks of at most size bytes
+func chunks(data []byte, size int) func(func([]byte) bool) {
+	return func(yield func([]byte) bool) {
+		for len(data) > 0 {
+			n := min(size, len(data))
+			if !yield(data[:n]) {
+				return
+			}
+			data = data[n:]
+		}
+	}
+}
+
+func TestNormalizeResponses_StreamsEventsWithoutWaitingForTheResponse(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"object\":\"chat.completion.chunk\",\"model\":\"m\",\"choices\":[{\"delta\":{\"content\":\"Hi\"},\"timings\":{}}]}\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	server := httptest.NewServer(newNormalizingRouter(t, backend))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/v1/chat/completions", "application/json", strings.NewReader(`{"model":"external","stream":true}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	lines := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		lines <- line
+	}()
+
+	select {
+	case line := <-lines:
+		expected := "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"},\"finish_reason\":null,\"index\":0}],\"model\":\"external\",\"object\":\"chat.completion.chunk\"}\n"
+		if line != expected {
+			t.Errorf("expected %q, got %q", expected, line)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("first event was not delivered before the stream completed")
+	}
+}
+
+func TestNormalizeResponses_PassesThroughErrorsAndDisabledInstances(t *testing.T) {
+	body := `{"error":{"code":400,"message":"bad request","type":"invalid_request_error"},"timings":{}}`
+
+	tests := []struct {
+		name      string
+		status    int
+		normalize bool
+	}{
+		{name: "error response", status: http.StatusBadRequest, normalize: true},
+		{name: "normalization disabled", status: http.StatusOK, normalize: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/health" {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, body)
+			}))
+			defer backend.Close()
+
+			router := newExternalBackendRouterWithOptions(t, backend, func(_ *config.AppConfig, options *instance.CreateInstanceOptions) {
+				options.NormalizeResponses = &tt.normalize
+			})
+			req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"external"}`))
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, recorder.Code)
+			}
+			if recorder.Body.String() != body {
+				t.Errorf("expected the body to pass through unchanged, got %s", recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"llamactl/pkg/instance"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// onDemandStarts tracks the on-demand starts in progress, so that the requests arriving for
+// an instance while it loads all wait on the same start
+type onDemandStarts struct {
+	mu     sync.Mutex
+	starts map[string]*onDemandStart
+}
+
+// onDemandStart is the start of an instance for the requests waiting on it
+type onDemandStart struct {
+	done chan struct{} // Closed once the instance is ready or failed to start
+	err  *onDemandError
+}
+
+// onDemandError is why an instance could not be started on demand, with the status the
+// requests waiting on the start are answered with
+type onDemandError struct {
+	status  int
+	message string
+}
+
+// pending reports whether an on-demand start of the instance is in progress
+func (s *onDemandStarts) pending(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.starts[name]
+	return ok
+}
+
+// join returns the on-demand start of the instance in progress, beginning one with launch if
+// there is none
+func (s *onDemandStarts) join(name string, launch func() *onDemandError) *onDemandStart {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if start, ok := s.starts[name]; ok {
+		return start
+	}
+	if s.starts == nil {
+		s.starts = make(map[string]*onDemandStart)
+	}
+	start := &onDemandStart{done: make(chan struct{})}
+	s.starts[name] = start
+
+	// Not bound to the request beginning the start, which the others still wait on
+	go func() {
+		start.err = launch()
+		s.mu.Lock()
+		delete(s.starts, name)
+		s.mu.Unlock()
+		close(start.done)
+	}()
+	return start
+}
+
+// wait waits for the start to complete or ctx to be done
+func (start *onDemandStart) wait(ctx context.Context) (*onDemandError, error) {
+	select {
+	case <-start.done:
+		return start.err, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startOnDemand makes sure the instance serving the request is ready: a stopped instance with
+// on_demand_start is started and the request is held until it passes its health check, up
+// to its readiness timeout. Requests arriving while it loads, or while it is still starting
+// otherwise, wait on the same start. It responds with the error and returns false if the
+// instance cannot serve the request.
+func (h *Handler) startOnDemand(w http.ResponseWriter, r *http.Request, inst *instance.Process) bool {
+	if inst.IsReady() && !h.onDemand.pending(inst.Name) {
+		return true
+	}
+
+	options := inst.GetOptions()
+	allowOnDemand := options != nil && options.OnDemandStart != nil && *options.OnDemandStart
+	if !inst.IsRunning() && (!allowOnDemand || !inst.IsManaged()) {
+		http.Error(w, "Instance is not running", http.StatusServiceUnavailable)
+		return false
+	}
+
+	// A failed instance is left for a user to look into, as are pending automatic restarts
+	switch inst.GetStatus() {
+	case instance.Failed:
+		message := "Instance has failed"
+		if lastError := inst.GetLastError(); lastError != nil && lastError.Message != "" {
+			message += ": " + lastError.Message
+		}
+		http.Error(w, message, http.StatusServiceUnavailable)
+		return false
+	case instance.Restarting:
+		http.Error(w, "Instance is restarting", http.StatusServiceUnavailable)
+		return false
+	}
+
+	startErr, err := h.onDemand.join(inst.Name, func() *onDemandError { return h.launchOnDemand(inst) }).wait(r.Context())
+	if err != nil {
+		return false // The client went away
+	}
+	if startErr != nil {
+		http.Error(w, startErr.message, startErr.status)
+		return false
+	}
+	return true
+}
+
+// launchOnDemand starts an instance on demand, evicting the least recently used instance if
+// no more may run, and waits for it to become healthy
+func (h *Handler) launchOnDemand(inst *instance.Process) *onDemandError {
+	if !inst.IsRunning() {
+		if h.InstanceManager.IsMaxRunningInstancesReached() {
+			if !h.instancesSettings().EnableLRUEviction {
+				return &onDemandError{http.StatusConflict, "Cannot start Instance, maximum number of instances reached"}
+			}
+			if err := h.InstanceManager.EvictLRUInstance(); err != nil {
+				return &onDemandError{http.StatusInternalServerError, "Cannot start Instance, failed to evict instance " + err.Error()}
+			}
+		}
+
+		// Started meanwhile otherwise, e.g. by a user, which the requests wait for all the same
+		if _, err := h.InstanceManager.StartInstance(inst.Name); err == nil {
+			log.Printf("Instance %s started on demand", inst.Name)
+		} else if !inst.IsRunning() {
+			return &onDemandError{http.StatusBadGateway, "Failed to start instance: " + err.Error()}
+		}
+	}
+
+	if err := inst.WaitForHealthy(0); err != nil {
+		return &onDemandError{http.StatusBadGateway, "Instance failed to become healthy: " + err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package server_test
+
+import (
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"llamactl/pkg/testutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newOnDemandRouter serves a stopped instance with on_demand_start whose backend runs script,
+// each start appending a line to the returned file, and serves on port
+func newOnDemandRouter(t *testing.T, script string, port int) (http.Handler, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	starts := filepath.Join(t.TempDir(), "starts")
+	cfg := config.AppConfig{
+		Backends: config.BackendConfig{LlamaCpp: config.BackendSettings{
+			Command: "sh",
+			Args:    []string{"-c", "echo started >> " + starts + "; " + script},
+		}},
+		Instances: config.InstancesConfig{
+			PortRange:            [2]int{8000, 9000},
+			LogsDir:              t.TempDir(),
+			MaxInstances:         10,
+			MaxRunningInstances:  -1,
+			TimeoutCheckInterval: config.Duration(5 * time.Minute),
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+
+	_, err := mngr.CreateInstance("lazy", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		AutoRestart:        testutil.BoolPtr(false),
+		OnDemandStart:      testutil.BoolPtr(true),
+		ReadinessTimeout:   testutil.DurationPtr(10 * time.Second),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf", Port: port},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	return server.SetupRouter(server.NewHandler(mngr, cfg)), starts
+}
+
+// postConcurrently sends n chat requests for the instance at once
+func postConcurrently(router http.Handler, n int) []*httptest.ResponseRecorder {
+	recorders := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for k := range recorders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recorders[k] = postChat(router, `{"model": "lazy", "messages": []}`)
+		}()
+	}
+	wg.Wait()
+	return recorders
+}
+
+func countStarts(t *testing.T, starts string) int {
+	t.Helper()
+	data, err := os.ReadFile(starts)
+	if err != nil {
+		t.Fatalf("Failed to read the starts: %v", err)
+	}
+	return strings.Count(string(data), "started")
+}
+
+func TestOnDemandStart_SharedStart(t *testing.T) {
+	// The process only has to keep running, the backend answers its health check, once it
+	// loaded its model a moment after the first request
+	var loadedAt atomic.Int64
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loadedAt.CompareAndSwap(0, time.Now().Add(300*time.Millisecond).UnixNano())
+		if time.Now().UnixNano() < loadedAt.Load() {
+			http.Error(w, "loading model", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+	router, starts := newOnDemandRouter(t, "sleep 30", port)
+
+	for k, recorder := range postConcurrently(router, 5) {
+		if recorder.Code != http.StatusOK {
+			t.Errorf("Expected request %d to be served once the instance started, got %d: %s", k, recorder.Code, recorder.Body.String())
+		}
+	}
+	if n := countStarts(t, starts); n != 1 {
+		t.Errorf("Expected the concurrent requests to share one start, got %d", n)
+	}
+}
+
+func TestOnDemandStart_Failure(t *testing.T) {
+	router, starts := newOnDemandRouter(t, "echo 'failed to load model' >&2; sleep 1; exit 1", 0)
+
+	for k, recorder := range postConcurrently(router, 5) {
+		if recorder.Code != http.StatusBadGateway {
+			t.Errorf("Expected request %d to fail with 502, got %d: %s", k, recorder.Code, recorder.Body.String())
+		}
+		if body, _ := io.ReadAll(recorder.Body); !strings.Contains(string(body), "exited before becoming healthy") {
+			t.Errorf("Expected the reason the start failed in the response, got %q", body)
+		}
+	}
+	if n := countStarts(t, starts); n != 1 {
+		t.Errorf("Expected the concurrent requests to share one start, got %d", n)
+	}
+}
+
+func TestOnDemandStart_WaitsForStartingInstance(t *testing.T) {
+	// Started by a user, the instance is still loading its model when the request arrives
+	var loadedAt atomic.Int64
+	var servedWhileLoading atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		loadedAt.CompareAndSwap(0, time.Now().Add(300*time.Millisecond).UnixNano())
+		if time.Now().UnixNano() < loadedAt.Load() {
+			if r.URL.Path != "/health" {
+				servedWhileLoading.Add(1)
+			}
+			http.Error(w, "loading model", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(backend.Close)
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+	router, starts := newOnDemandRouter(t, "sleep 30", port)
+
+	start := httptest.NewRecorder()
+	router.ServeHTTP(start, httptest.NewRequest("POST", "/api/v1/instances/lazy/start", nil))
+	if start.Code != http.StatusOK {
+		t.Fatalf("Expected the instance to start, got %d: %s", start.Code, start.Body.String())
+	}
+
+	if recorder := postChat(router, `{"model": "lazy", "messages": []}`); recorder.Code != http.StatusOK {
+		t.Errorf("Expected the request to be served once the instance is ready, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if n := servedWhileLoading.Load(); n != 0 {
+		t.Errorf("Expected no request to reach the backend while it loads, got %d", n)
+	}
+	if n := countStarts(t, starts); n != 1 {
+		t.Errorf("Expected the instance to be started once, got %d", n)
+	}
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxPromptTemplateBodySize bounds the body of a prompt template update, which holds the
+// template escaped as JSON
+const maxPromptTemplateBodySize = 1024 * 1024
+
+// PromptTemplate is a named prompt template of an instance
+type PromptTemplate struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+}
+
+// GenerateRequest is the body of a completion from a prompt template
+type GenerateRequest struct {
+	Template  string         `json:"template"`
+	Variables map[string]any `json:"variables,omitempty"`
+	Stream    bool           `json:"stream,omitempty"`
+	Params    map[string]any `json:"params,omitempty"` // Passed to the completion endpoint of the backend, e.g. n_predict
+}
+
+// completionPath returns the completion endpoint of a backend, which takes a raw prompt
+func completionPath(backendType backends.BackendType) string {
+	if backendType == backends.BackendTypeLlamaCpp {
+		return "/completion"
+	}
+	return "/v1/completions"
+}
+
+// ListPromptTemplates godoc
+// @Summary List the prompt templates of an instance
+// @Description Returns the prompt templates of an instance, sorted by name. Templates are returned as stored, they are never redacted.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 200 {array} PromptTemplate "Prompt templates"
+// @Failure 400 {string} string "Invalid name format"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/templates [get]
+func (h *Handler) ListPromptTemplates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Failed to get instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		templates := inst.GetPromptTemplates()
+		list := make([]PromptTemplate, 0, len(templates))
+		for _, template := range slices.Sorted(maps.Keys(templates)) {
+			list = append(list, PromptTemplate{Name: template, Template: templates[template]})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(list); err != nil {
+			http.Error(w, "Failed to encode prompt templates: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// SetPromptTemplate godoc
+// @Summary Add or replace a prompt template of an instance
+// @Description Stores a Go text/template prompt with the options of an instance, without restarting it. Variables are referred to by a dot and their name, e.g. .name in an action. Templates are limited to 16 KiB, and to 64 per instance. They are stored and returned in clear, so they must not contain secrets.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Param template path string true "Template Name"
+// @Param body body PromptTemplate true "Template, its name is taken from the path"
+// @Success 200 {object} PromptTemplate "Stored template"
+// @Failure 400 {object} ValidationErrorResponse "Invalid template"
+// @Failure 403 {string} string "Config-managed instance"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/templates/{template} [put]
+func (h *Handler) SetPromptTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+		template := chi.URLParam(r, "template")
+
+		var req PromptTemplate
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPromptTemplateBodySize)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.managerFor(r).SetPromptTemplate(name, template, req.Template); err != nil {
+			if writeValidationError(w, err) {
+				return
+			}
+			if errors.Is(err, manager.ErrConfigManaged) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			http.Error(w, "Failed to set prompt template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(PromptTemplate{Name: template, Template: req.Template}); err != nil {
+			http.Error(w, "Failed to encode prompt template: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DeletePromptTemplate godoc
+// @Summary Delete a prompt template of an instance
+// @Description Removes a prompt template of an instance, without restarting it
+// @Tags instances
+// @Security ApiKeyAuth
+// @Param name path string true "Instance Name"
+// @Param template path string true "Template Name"
+// @Success 204 "No Content"
+// @Failure 403 {string} string "Config-managed instance"
+// @Failure 404 {string} string "Template not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /instances/{name}/templates/{template} [delete]
+func (h *Handler) DeletePromptTemplate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.managerFor(r).DeletePromptTemplate(name, chi.URLParam(r, "template")); err != nil {
+			switch {
+			case errors.Is(err, instance.ErrPromptTemplateNotFound):
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, manager.ErrConfigManaged):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			default:
+				http.Error(w, "Failed to delete prompt template: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Generate godoc
+// @Summary Complete a prompt template
+// @Description Renders a prompt template of an instance with the given variables and forwards the prompt to the completion endpoint of its backend (/completion for llama.cpp, /v1/completions otherwise), with params. The response of the backend, streamed when stream is set, is returned as is. Requests are admitted and started on demand like proxied requests.
+// @Tags instances
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Param request body GenerateRequest true "Template, variables and completion parameters"
+// @Success 200 {object} map[string]any "Completion of the backend"
+// @Failure 400 {string} string "Invalid request, or a variable of the template is missing"
+// @Failure 404 {string} string "Template not found"
+// @Failure 503 {string} string "Instance is not running"
+// @Router /instances/{name}/generate [post]
+func (h *Handler) Generate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if name == "" {
+			http.Error(w, "Instance name cannot be empty", http.StatusBadRequest)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			http.Error(w, "Invalid instance: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		setAccessLogInstance(r, name)
+
+		var req GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body.Close()
+		if req.Template == "" {
+			http.Error(w, "Template name is required", http.StatusBadRequest)
+			return
+		}
+
+		prompt, err := inst.RenderPrompt(req.Template, req.Variables)
+		if err != nil {
+			if errors.Is(err, instance.ErrPromptTemplateNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options := inst.GetOptions()
+		if options == nil {
+			http.Error(w, "Cannot obtain Instance's options", http.StatusInternalServerError)
+			return
+		}
+		body := maps.Clone(req.Params)
+		if body == nil {
+			body = map[string]any{}
+		}
+		body["prompt"] = prompt
+		body["stream"] = req.Stream
+		if _, ok := body["model"]; !ok && options.BackendType != backends.BackendTypeLlamaCpp {
+			body["model"] = name
+		}
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			http.Error(w, "Invalid params: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The rendered prompt is proxied as a request for the completion endpoint
+		r = r.Clone(r.Context())
+		r.URL.Path, r.URL.RawPath = completionPath(options.BackendType), ""
+		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		r.ContentLength = int64(len(bodyBytes))
+		r.Header.Set("Content-Type", "application/json")
+		if !allowRequest(w, inst, r) {
+			return
+		}
+
+		if !h.startOnDemand(w, r, inst) {
+			return
+		}
+
+		proxy, err := inst.GetProxy()
+		if err != nil {
+			http.Error(w, "Failed to get proxy: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		release, priority, ok := h.admitRequest(w, r, inst)
+		if !ok {
+			return
+		}
+		defer release()
+
+		inst.RecordRequest(r)
+
+		r, done := trackRequest(inst, r, priority.String(), req.Stream)
+		defer done()
+
+		proxy.ServeHTTP(w, r)
+	}
+}
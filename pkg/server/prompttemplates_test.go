@@ -0,0 +1,80 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"llamactl/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPromptTemplates(t *testing.T) {
+	// The backend echoes the completion request it gets, as an event stream when asked to
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"content\": \"streamed\"}\n\n")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"path": r.URL.Path, "prompt": body["prompt"], "n_predict": body["n_predict"]})
+	}))
+	defer backend.Close()
+	router := newUIRouter(t, backend, config.AuthConfig{})
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if w := send("PUT", "/api/v1/instances/external/templates/summarize", `{"template": "Summarize for {{.audience}}:\n{{.text}}"}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected the template to be stored, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send("PUT", "/api/v1/instances/external/templates/broken", `{"template": "{{.text"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a template that does not parse to be rejected, got %d", w.Code)
+	}
+	if w := send("PUT", "/api/v1/instances/external/templates/huge", `{"template": "`+strings.Repeat("x", 16*1024+1)+`"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a template over the size limit to be rejected, got %d", w.Code)
+	}
+
+	var list []struct{ Name, Template string }
+	json.Unmarshal(send("GET", "/api/v1/instances/external/templates", "").Body.Bytes(), &list)
+	if len(list) != 1 || list[0].Name != "summarize" {
+		t.Errorf("Expected the stored template to be listed, got %+v", list)
+	}
+
+	w := send("POST", "/instances/external/generate", `{"template": "summarize", "variables": {"audience": "kids", "text": "a long story"}, "params": {"n_predict": 64}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the completion to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var completion map[string]any
+	json.Unmarshal(w.Body.Bytes(), &completion)
+	if completion["path"] != "/completion" || completion["prompt"] != "Summarize for kids:\na long story" || completion["n_predict"] != float64(64) {
+		t.Errorf("Expected the rendered prompt to reach /completion with the params, got %v", completion)
+	}
+
+	w = send("POST", "/instances/external/generate", `{"template": "summarize", "variables": {"text": "a long story"}, "stream": true}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"audience"`) {
+		t.Errorf("Expected the missing variable to be named, got %d: %s", w.Code, w.Body.String())
+	}
+	w = send("POST", "/instances/external/generate", `{"template": "summarize", "variables": {"audience": "kids", "text": "a long story"}, "stream": true}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "data: {\"content\": \"streamed\"}") {
+		t.Errorf("Expected the stream of the backend, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := send("POST", "/instances/external/generate", `{"template": "translate"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown template, got %d", w.Code)
+	}
+
+	if w := send("DELETE", "/api/v1/instances/external/templates/summarize", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected the template to be deleted, got %d", w.Code)
+	}
+	if w := send("DELETE", "/api/v1/instances/external/templates/summarize", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a deleted template, got %d", w.Code)
+	}
+}
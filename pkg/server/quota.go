@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"llamactl/pkg/quota"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Headers exposing the remaining budget of the request's API key
+const (
+	headerQuotaRemainingRequests = "X-Quota-Remaining-Requests"
+	headerQuotaRemainingTokens   = "X-Quota-Remaining-Tokens"
+	headerQuotaReset             = "X-Quota-Reset"
+)
+
+type QuotaExceededResponse struct {
+	Error QuotaExceededErrorDetail `json:"error"`
+}
+
+type QuotaExceededErrorDetail struct {
+	Message string        `json:"message"`
+	Type    string        `json:"type"`
+	Quota   *quota.Status `json:"quota"`
+}
+
+// QuotaMiddleware rejects requests of API keys whose quota is used up and exposes the
+// remaining budget on every response. Usage is recorded by the access logger once the
+// response, and with it the token usage reported by the backend, is complete.
+func (h *Handler) QuotaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" || !h.quotas.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status, err := h.quotas.Check(extractAPIKey(r))
+		if status != nil {
+			setQuotaHeaders(w.Header(), status)
+		}
+
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			writeQuotaExceeded(w, exceeded, time.Now())
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setQuotaHeaders(header http.Header, status *quota.Status) {
+	if status.RemainingRequests != nil {
+		header.Set(headerQuotaRemainingRequests, strconv.FormatInt(*status.RemainingRequests, 10))
+	}
+	if status.RemainingTokens != nil {
+		header.Set(headerQuotaRemainingTokens, strconv.FormatInt(*status.RemainingTokens, 10))
+	}
+	header.Set(headerQuotaReset, status.ResetAt.Format(time.RFC3339))
+}
+
+// writeQuotaExceeded sends a 429 response stating the remaining quota and when it resets
+func writeQuotaExceeded(w http.ResponseWriter, err *quota.ExceededError, now time.Time) {
+	retryAfter := int64(math.Ceil(err.Status.ResetAt.Sub(now).Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(max(retryAfter, 1), 10))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(QuotaExceededResponse{
+		Error: QuotaExceededErrorDetail{
+			Message: err.Error(),
+			Type:    "quota_exceeded",
+			Quota:   err.Status,
+		},
+	})
+}
+
+// ListQuotas godoc
+// @Summary List API key quotas
+// @Description Returns the quota status of every API key with a quota, identified by key ID
+// @Tags quotas
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {array} quota.Status "Quota status per key"
+// @Router /quotas [get]
+func (h *Handler) ListQuotas() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.quotas.List()); err != nil {
+			http.Error(w, "Failed to encode quotas: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetQuota godoc
+// @Summary Get an API key quota
+// @Description Returns the limits, consumed usage, remaining budget and reset time of an API key's quota
+// @Tags quotas
+// @Security ApiKeyAuth
+// @Produces json
+// @Param id path string true "Key ID"
+// @Success 200 {object} quota.Status "Quota status"
+// @Failure 404 {string} string "No quota for this key"
+// @Router /quotas/{id} [get]
+func (h *Handler) GetQuota() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := h.quotas.Get(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Failed to get quota: "+err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode quota: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// AdjustQuota godoc
+// @Summary Adjust an API key quota
+// @Description Sets the requests and tokens an API key has consumed in the current window. The change is recorded in the audit log.
+// @Tags quotas
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param id path string true "Key ID"
+// @Param usage body quota.Usage true "Consumed requests and tokens"
+// @Success 200 {object} quota.Status "Updated quota status"
+// @Failure 400 {string} string "Invalid request body"
+// @Failure 404 {string} string "No quota for this key"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /quotas/{id} [put]
+func (h *Handler) AdjustQuota() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var used quota.Usage
+		if err := json.NewDecoder(r.Body).Decode(&used); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		status, err := h.quotas.Adjust(chi.URLParam(r, "id"), used)
+		if err != nil {
+			switch {
+			case errors.Is(err, quota.ErrUnknownKey):
+				http.Error(w, "Failed to adjust quota: "+err.Error(), http.StatusNotFound)
+			case used.Requests < 0 || used.Tokens < 0:
+				http.Error(w, "Failed to adjust quota: "+err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, "Failed to adjust quota: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode quota: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
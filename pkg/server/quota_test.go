@@ -0,0 +1,94 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/quota"
+	"llamactl/pkg/server"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestQuota_EnforcedAndAdjustable(t *testing.T) {
+	const key = "sk-inference-quota-0123"
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"object":"chat.completion","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`)
+	}))
+	defer backend.Close()
+
+	router := newExternalBackendRouterWithOptions(t, backend, func(cfg *config.AppConfig, _ *instance.CreateInstanceOptions) {
+		cfg.Auth.KeyQuotas = map[string]config.KeyQuotaConfig{key: {Requests: 2, Tokens: 1000}}
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"external"}`))
+		req.Header.Set("Authorization", "Bearer "+key)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	for i, expectedRemaining := range []string{"2", "1"} {
+		recorder := request()
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d", i, recorder.Code)
+		}
+		if got := recorder.Header().Get("X-Quota-Remaining-Requests"); got != expectedRemaining {
+			t.Errorf("request %d: expected %s remaining requests, got %q", i, expectedRemaining, got)
+		}
+		if recorder.Header().Get("X-Quota-Reset") == "" {
+			t.Errorf("request %d: expected a reset time header", i)
+		}
+	}
+
+	recorder := request()
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status 429 once the quota is used up, got %d", recorder.Code)
+	}
+	if recorder.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+	var response server.QuotaExceededResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Error.Type != "quota_exceeded" || response.Error.Quota == nil {
+		t.Fatalf("unexpected response: %s", recorder.Body.String())
+	}
+	if *response.Error.Quota.RemainingRequests != 0 || *response.Error.Quota.RemainingTokens != 970 {
+		t.Errorf("expected 0 requests and 970 tokens remaining, got %+v", response.Error.Quota)
+	}
+
+	// An admin resets the consumed quota
+	id := quota.KeyID(key)
+	req := httptest.NewRequest("PUT", "/api/v1/quotas/"+id, strings.NewReader(`{"requests":0,"tokens":0}`))
+	adjust := httptest.NewRecorder()
+	router.ServeHTTP(adjust, req)
+	if adjust.Code != http.StatusOK {
+		t.Fatalf("expected status 200 from the adjust endpoint, got %d: %s", adjust.Code, adjust.Body.String())
+	}
+
+	if recorder := request(); recorder.Code != http.StatusOK {
+		t.Errorf("expected status 200 after the adjustment, got %d", recorder.Code)
+	}
+
+	get := httptest.NewRecorder()
+	router.ServeHTTP(get, httptest.NewRequest("GET", "/api/v1/quotas/"+id, nil))
+	var status quota.Status
+	if err := json.Unmarshal(get.Body.Bytes(), &status); err != nil {
+		t.Fatalf("failed to decode quota: %v", err)
+	}
+	if status.Used != (quota.Usage{Requests: 1, Tokens: 15}) {
+		t.Errorf("expected usage {1 15}, got %+v", status.Used)
+	}
+}
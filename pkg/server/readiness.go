@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"llamactl/pkg/manager"
+	"net/http"
+)
+
+// readiness is the answer of the readiness probe, without the names of the instances since
+// the probe needs no API key
+type readiness struct {
+	Status  string `json:"status"`
+	Total   int    `json:"total"`
+	Pending int    `json:"pending"`
+}
+
+// Readyz godoc
+// @Summary Get the readiness of llamactl
+// @Description Reports whether llamactl completed the initial restore of the instances: 503 with the restoring status until the instances persisted running are adopted or started again and the static instances reconciled, then 200. Needs no API key.
+// @Tags system
+// @Produces json
+// @Success 200 {object} readiness "Ready"
+// @Failure 503 {object} readiness "Restoring"
+// @Router /readyz [get]
+func (h *Handler) Readyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := h.InstanceManager.GetRestoreStatus()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if status.State != manager.RestoreStateReady {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(readiness{Status: status.State, Total: status.Total, Pending: status.Pending})
+	}
+}
+
+// GetRestoreStatus godoc
+// @Summary Get the progress of the initial restore
+// @Description Returns the progress of the restore of every instance persisted running or defined in the configuration file, since llamactl started
+// @Tags system
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} manager.RestoreStatus "Restore progress"
+// @Router /restore [get]
+func (h *Handler) GetRestoreStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := h.InstanceManager.GetRestoreStatus()
+
+		// Only the instances the caller may see are listed
+		visible := status.Instances[:0]
+		for _, progress := range status.Instances {
+			if visibleTo(r, progress.Name) {
+				visible = append(visible, progress)
+			}
+		}
+		status.Instances = visible
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "Failed to encode restore status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	httpSwagger "github.com/swaggo/http-swagger"
 
@@ -14,12 +13,12 @@ import (
 
 func SetupRouter(handler *Handler) *chi.Mux {
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(handler.AccessLogger)
 
 	// Add CORS middleware
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   handler.cfg.Server.AllowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   handler.cfg.Server.AllowedHeaders,
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
@@ -35,6 +34,9 @@ func SetupRouter(handler *Handler) *chi.Mux {
 		))
 	}
 
+	// Polled by orchestrators, which hold no API key
+	r.Get("/readyz", handler.Readyz()) // 503 until the initial restore of the instances completed
+
 	// Define routes
 	r.Route("/api/v1", func(r chi.Router) {
 
@@ -42,7 +44,13 @@ func SetupRouter(handler *Handler) *chi.Mux {
 			r.Use(authMiddleware.AuthMiddleware(KeyTypeManagement))
 		}
 
-		r.Get("/version", handler.VersionHandler()) // Get server version
+		r.Get("/version", handler.VersionHandler())           // Get server version
+		r.Get("/events", handler.StreamEvents())              // Stream instance events (SSE)
+		r.Get("/logs/tail", handler.TailLogs())               // Stream the merged logs of several instances (SSE)
+		r.Get("/logs/quota", handler.GetLogQuota())           // Disk space of the log files against the log disk quota
+		r.Get("/options/aliases", handler.GetOptionAliases()) // Alternative spellings accepted for option keys
+		r.Get("/restore", handler.GetRestoreStatus())         // Progress of the initial restore of the instances
+		r.Get("/drift", handler.GetDrift())                   // Instances whose definition differs from their runtime state
 
 		// Backend-specific endpoints
 		r.Route("/backends", func(r chi.Router) {
@@ -66,14 +74,46 @@ func SetupRouter(handler *Handler) *chi.Mux {
 
 			r.Route("/{name}", func(r chi.Router) {
 				// Instance management
-				r.Get("/", handler.GetInstance())             // Get instance details
-				r.Post("/", handler.CreateInstance())         // Create and start new instance
-				r.Put("/", handler.UpdateInstance())          // Update instance configuration
-				r.Delete("/", handler.DeleteInstance())       // Stop and remove instance
-				r.Post("/start", handler.StartInstance())     // Start stopped instance
-				r.Post("/stop", handler.StopInstance())       // Stop running instance
-				r.Post("/restart", handler.RestartInstance()) // Restart instance
-				r.Get("/logs", handler.GetInstanceLogs())     // Get instance logs
+				r.Get("/", handler.GetInstance())                           // Get instance details
+				r.Post("/", handler.CreateInstance())                       // Create and start new instance
+				r.Put("/", handler.UpdateInstance())                        // Update instance configuration
+				r.Post("/validate", handler.ValidateInstance())             // Validate a configuration without applying it
+				r.Delete("/", handler.DeleteInstance())                     // Stop and remove instance
+				r.Post("/start", handler.StartInstance())                   // Start stopped instance
+				r.Post("/stop", handler.StopInstance())                     // Stop running instance
+				r.Post("/cancel-start", handler.CancelStart())              // Kill a backend still loading its model
+				r.Post("/signal", handler.SignalInstance())                 // Send a signal to the backend
+				r.Get("/stop-impact", handler.GetStopImpact())              // Dry-run report of what a stop would break
+				r.Post("/restart", handler.RestartInstance())               // Restart instance
+				r.Post("/reload", handler.ReloadInstance())                 // Replace the backend without downtime
+				r.Post("/retry", handler.RetryInstance())                   // Start a failed instance again
+				r.Post("/apply-options", handler.ApplyPendingOptions())     // Restart to apply the options updated while running
+				r.Post("/rotate-backend-key", handler.RotateBackendKey())   // Replace the managed backend key
+				r.Get("/operations", handler.GetInstanceOperations())       // Current, queued and recent lifecycle operations
+				r.Get("/logs", handler.GetInstanceLogs())                   // Get instance logs
+				r.Get("/logs/files", handler.GetInstanceLogFiles())         // List current and rotated log files
+				r.Get("/queue", handler.GetInstanceQueue())                 // Get admission queue stats
+				r.Get("/requests", handler.ListInstanceRequests())          // List in-flight requests
+				r.Delete("/requests/{id}", handler.CancelInstanceRequest()) // Cancel an in-flight request
+				r.Get("/stats", handler.GetInstanceStats())                 // Get proxy stats
+				r.Get("/slo", handler.GetInstanceSLO())                     // Get SLO compliance and burn rate
+				r.Put("/slo", handler.UpdateInstanceSLO())                  // Update SLO objectives without a restart
+				r.Put("/allowed-paths", handler.UpdateAllowedPaths())       // Update the paths the proxy forwards without a restart
+				r.Get("/command", handler.GetInstanceCommand())             // Preview the backend command line
+				r.Get("/last-exit", handler.GetInstanceLastExit())          // Exit status and output of the last crash
+				r.Get("/drift", handler.GetInstanceDrift())                 // Persisted definition compared with the runtime state
+
+				// Automatic restarts paused without stopping the instance
+				r.Post("/autorestart/pause", handler.PauseInstanceAutoRestart())
+				r.Post("/autorestart/resume", handler.ResumeInstanceAutoRestart())
+
+				// Prompt templates, completed by /instances/{name}/generate
+				r.Get("/templates", handler.ListPromptTemplates())
+				r.Put("/templates/{template}", handler.SetPromptTemplate())
+				r.Delete("/templates/{template}", handler.DeletePromptTemplate())
+
+				// Effective options, with the source of every field when explain=true
+				r.Get("/options/effective", handler.GetEffectiveOptions())
 
 				// Llama.cpp server proxy endpoints (proxied to the actual llama.cpp server)
 				r.Route("/proxy", func(r chi.Router) {
@@ -81,6 +121,86 @@ func SetupRouter(handler *Handler) *chi.Mux {
 				})
 			})
 		})
+
+		// Deleted instances, until they are purged
+		r.Route("/trash", func(r chi.Router) {
+			r.Get("/", handler.ListTrash())                      // Deleted instances and when they are purged
+			r.Post("/{name}/restore", handler.RestoreInstance()) // Bring an instance back
+			r.Delete("/{name}", handler.PurgeInstance())         // Purge an instance right away
+		})
+
+		// Service endpoints
+		r.Route("/services/{alias}", func(r chi.Router) {
+			r.Get("/health", handler.GetServiceHealth())      // Aggregated service health
+			r.Post("/failback", handler.FailbackService())    // Route a service with a standby back to its primary
+			r.Get("/routes", handler.GetServiceRoutes())      // Weights and per-member stats of a service with weighted routing
+			r.Put("/routes", handler.SetServiceRoutes())      // Change the weights at runtime
+			r.Delete("/routes", handler.ResetServiceRoutes()) // Go back to the weights of the configuration
+		})
+
+		// API key quotas
+		r.Route("/quotas", func(r chi.Router) {
+			r.Get("/", handler.ListQuotas())      // Quota status of every key with a quota
+			r.Get("/{id}", handler.GetQuota())    // Quota status of a key
+			r.Put("/{id}", handler.AdjustQuota()) // Set the usage a key consumed in the current window
+		})
+
+		// Declarative fleet files
+		r.Route("/fleet", func(r chi.Router) {
+			r.Post("/apply", handler.ApplyFleet())     // Reconcile the instances with a fleet file
+			r.Get("/status", handler.GetFleetStatus()) // Last reconciliation of watch mode
+		})
+
+		// Effective configuration, and the settings that can be changed at runtime
+		r.Get("/config", handler.GetConfig())      // Settings with their values and sources
+		r.Patch("/config", handler.UpdateConfig()) // Change settings without a restart
+
+		// Event export to message brokers
+		r.Get("/sinks/status", handler.GetSinksStatus()) // Connectivity and delivery counters of each sink
+
+		// Automatic restarts of every instance paused, on top of the pauses of instances
+		r.Route("/autorestart", func(r chi.Router) {
+			r.Get("/", handler.GetAutoRestartPause())      // Current global pause
+			r.Post("/pause", handler.PauseAutoRestart())   // Pause restarts, for a duration or until resumed
+			r.Post("/resume", handler.ResumeAutoRestart()) // Resume restarts
+		})
+
+		// Fleet maintenance
+		r.Route("/maintenance", func(r chi.Router) {
+			r.Post("/rolling-restart", handler.StartRollingRestart())           // Restart matching instances one batch at a time
+			r.Get("/rolling-restart/status", handler.GetRollingRestartStatus()) // Progress of the current or last rolling restart
+		})
+
+		r.Route("/models", func(r chi.Router) {
+			// GGUF files found in the model directories
+			r.Get("/available", handler.ListAvailableModels()) // Indexed model files and the instances using them
+			r.Post("/rescan", handler.RescanModels())          // Index new and changed files now
+
+			// Model files served to other nodes
+			r.Route("/{name}", func(r chi.Router) {
+				r.Get("/", handler.GetModel())              // Model size and checksum
+				r.Get("/download", handler.DownloadModel()) // Download the model file, with range support
+			})
+		})
+	})
+
+	r.Route("/debug", func(r chi.Router) {
+
+		if authMiddleware != nil && handler.cfg.Auth.RequireManagementAuth {
+			r.Use(authMiddleware.AuthMiddleware(KeyTypeManagement))
+		}
+
+		r.Get("/goroutines", handler.GetGoroutines()) // Goroutine usage per instance
+		r.Get("/memory", handler.GetMemory())         // Memory held by the in-memory buffers, with runtime memstats
+	})
+
+	r.Group(func(r chi.Router) {
+
+		if authMiddleware != nil && handler.cfg.Auth.RequireManagementAuth {
+			r.Use(authMiddleware.AuthMiddleware(KeyTypeManagement))
+		}
+
+		r.Method("GET", "/metrics", handler.MetricsHandler()) // Prometheus metrics
 	})
 
 	r.Route(("/v1"), func(r chi.Router) {
@@ -88,6 +208,7 @@ func SetupRouter(handler *Handler) *chi.Mux {
 		if authMiddleware != nil && handler.cfg.Auth.RequireInferenceAuth {
 			r.Use(authMiddleware.AuthMiddleware(KeyTypeInference))
 		}
+		r.Use(handler.QuotaMiddleware)
 
 		r.Get(("/models"), handler.OpenAIListInstances()) // List instances in OpenAI-compatible format
 
@@ -116,6 +237,7 @@ func SetupRouter(handler *Handler) *chi.Mux {
 			if authMiddleware != nil && handler.cfg.Auth.RequireInferenceAuth {
 				r.Use(authMiddleware.AuthMiddleware(KeyTypeInference))
 			}
+			r.Use(handler.QuotaMiddleware)
 
 			// This handler auto start the server if it's not running
 			llamaCppHandler := handler.LlamaCppProxy(true)
@@ -148,6 +270,31 @@ func SetupRouter(handler *Handler) *chi.Mux {
 
 	})
 
+	// llama-server web UIs of the instances, for browsers
+	r.Route("/instances", func(r chi.Router) {
+		uiAuth := authMiddleware != nil && handler.cfg.Auth.RequireInferenceAuth
+		if uiAuth {
+			r.Post("/login", authMiddleware.UILogin()) // Keep the API key of the login form in a cookie
+		}
+
+		// Polled by external load balancers, which hold no API key
+		r.Get("/{name}/lb-health", handler.LBHealth()) // Ready and not saturated, from cached state
+
+		r.Group(func(r chi.Router) {
+
+			if uiAuth {
+				r.Use(authMiddleware.UIAuthMiddleware)
+			}
+			r.Use(handler.QuotaMiddleware)
+
+			r.Get("/", handler.InstanceUIIndex())              // Links to the web UI of every instance
+			r.Post("/{name}/start", handler.StartInstanceUI()) // Start a stopped instance and open its UI
+			r.Post("/{name}/generate", handler.Generate())     // Complete a prompt template of the instance
+			r.HandleFunc("/{name}/ui", handler.InstanceUI())   // Redirect to the UI directory
+			r.HandleFunc("/{name}/ui/*", handler.InstanceUI()) // Proxy the web UI, its SSE and WebSocket streams
+		})
+	})
+
 	// Serve WebUI files
 	if err := webui.SetupWebUI(r); err != nil {
 		fmt.Printf("Failed to set up WebUI: %v\n", err)
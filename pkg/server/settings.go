@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"net/http"
+)
+
+// ConfigResponse is the configuration llamactl runs with
+type ConfigResponse struct {
+	// Every instances setting with its value and source, by configuration file key
+	Instances map[string]config.SettingValue `json:"instances"`
+}
+
+// instancesSettings returns the instances settings, including the changes made at runtime
+func (h *Handler) instancesSettings() config.InstancesConfig {
+	h.settingsMu.RLock()
+	defer h.settingsMu.RUnlock()
+	return h.cfg.Instances
+}
+
+// writeConfig writes the instances settings of the manager with their sources
+func (h *Handler) writeConfig(w http.ResponseWriter) {
+	h.settingsMu.RLock()
+	settings, err := config.DescribeInstances(h.InstanceManager.GetSettings(), h.cfg.Sources)
+	h.settingsMu.RUnlock()
+	if err != nil {
+		http.Error(w, "Failed to describe configuration: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ConfigResponse{Instances: settings}); err != nil {
+		http.Error(w, "Failed to encode configuration: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetConfig godoc
+// @Summary Get the effective configuration
+// @Description Returns every instances setting llamactl runs with, after the configuration file, environment variables and runtime overrides are applied. Each setting has its value, its source (default, file, env or override) and whether it can be changed at runtime, with the reason when it cannot. Secrets are redacted.
+// @Tags system
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {object} ConfigResponse "Effective configuration"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /config [get]
+func (h *Handler) GetConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h.writeConfig(w)
+	}
+}
+
+// UpdateConfig godoc
+// @Summary Change settings at runtime
+// @Description Changes the instances settings of the body, keyed like the configuration file, without a restart. Only settings read whenever they are used can be changed, others are rejected with the reason. Changes are validated, recorded in the audit log and saved to config_overrides.yaml in the data directory, which is applied over the configuration file and environment variables on startup.
+// @Tags system
+// @Security ApiKeyAuth
+// @Accept json
+// @Produces json
+// @Param settings body object true "Settings to change, e.g. {\"default_max_restarts\": 5}"
+// @Success 200 {object} ConfigResponse "Effective configuration"
+// @Failure 400 {string} string "Invalid or read-only setting"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /config [patch]
+func (h *Handler) UpdateConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var patch map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if _, _, err := config.PatchInstances(h.InstanceManager.GetSettings(), patch); err != nil {
+			http.Error(w, "Invalid settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		settings, err := h.managerFor(r).UpdateSettings(patch)
+		if err != nil {
+			http.Error(w, "Failed to update configuration: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		h.settingsMu.Lock()
+		keys := make([]string, 0, len(patch))
+		if h.cfg.Sources == nil {
+			h.cfg.Sources = make(map[string]string)
+		}
+		for key := range patch {
+			keys = append(keys, key)
+			h.cfg.Sources[key] = config.SourceOverride
+		}
+		config.CopySettings(&h.cfg.Instances, settings, keys)
+		h.settingsMu.Unlock()
+
+		h.writeConfig(w)
+	}
+}
@@ -0,0 +1,123 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"llamactl/pkg/storage"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigEndpoints(t *testing.T) {
+	dataDir := t.TempDir()
+	cfg := config.AppConfig{
+		Instances: config.InstancesConfig{
+			PortRange:            [2]int{8000, 9000},
+			DataDir:              dataDir,
+			InstancesDir:         filepath.Join(dataDir, "instances"),
+			LogsDir:              filepath.Join(dataDir, "logs"),
+			MaxInstances:         10,
+			MaxRunningInstances:  -1,
+			DefaultAutoRestart:   true,
+			DefaultMaxRestarts:   3,
+			DefaultRestartDelay:  config.Duration(5 * time.Second),
+			TimeoutCheckInterval: config.Duration(5 * time.Minute),
+		},
+		Sources: map[string]string{"max_instances": config.SourceFile},
+	}
+	store := storage.NewFileStore(cfg.Instances.InstancesDir, dataDir)
+	mngr := manager.NewInstanceManagerWithStore(cfg.Backends, cfg.Instances, store)
+	t.Cleanup(mngr.Shutdown)
+
+	srv := httptest.NewServer(server.SetupRouter(server.NewHandler(mngr, cfg)))
+	defer srv.Close()
+
+	request := func(method, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+"/api/v1/config", strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+	settings := func(body string) map[string]config.SettingValue {
+		t.Helper()
+		var response server.ConfigResponse
+		if err := json.Unmarshal([]byte(body), &response); err != nil {
+			t.Fatalf("Failed to decode configuration %q: %v", body, err)
+		}
+		return response.Instances
+	}
+
+	status, body := request("GET", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", status, body)
+	}
+	current := settings(body)
+	if s := current["max_instances"]; s.Source != config.SourceFile || s.Value != float64(10) {
+		t.Errorf("Expected max_instances 10 from the file, got %+v", s)
+	}
+	if s := current["default_max_restarts"]; s.Source != config.SourceDefault || !s.RuntimeTunable {
+		t.Errorf("Expected a tunable default_max_restarts from the defaults, got %+v", s)
+	}
+
+	status, body = request("PATCH", `{"data_dir": "/elsewhere"}`)
+	if status != http.StatusBadRequest || !strings.Contains(body, "data_dir cannot be changed at runtime") {
+		t.Errorf("Expected a startup setting to be rejected with the reason, got %d: %s", status, body)
+	}
+
+	status, body = request("PATCH", `{"default_max_restarts": 7, "default_restart_delay": "20s"}`)
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", status, body)
+	}
+	if s := settings(body)["default_max_restarts"]; s.Source != config.SourceOverride || s.Value != float64(7) {
+		t.Errorf("Expected default_max_restarts 7 from an override, got %+v", s)
+	}
+
+	// Instances created from then on get the new defaults
+	inst, err := mngr.CreateInstance("tuned", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if opts := inst.GetOptions(); opts.MaxRestarts == nil || *opts.MaxRestarts != 7 || opts.RestartDelay == nil || *opts.RestartDelay != config.Duration(20*time.Second) {
+		t.Errorf("Expected the new restart defaults, got %v and %v", opts.MaxRestarts, opts.RestartDelay)
+	}
+
+	// The overrides survive a restart
+	data, err := os.ReadFile(filepath.Join(dataDir, config.OverridesFile))
+	if err != nil {
+		t.Fatalf("Failed to read overrides file: %v", err)
+	}
+	if !strings.Contains(string(data), "default_max_restarts: 7") || !strings.Contains(string(data), "default_restart_delay: 20s") {
+		t.Errorf("Expected the changes to be saved, got %q", data)
+	}
+
+	records, err := store.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit failed: %v", err)
+	}
+	audited := false
+	for _, record := range records {
+		audited = audited || (record.Action == "update_config" && strings.Contains(record.Details, "default_max_restarts=7"))
+	}
+	if !audited {
+		t.Errorf("Expected the change to be audited, got %+v", records)
+	}
+}
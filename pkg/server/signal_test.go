@@ -0,0 +1,41 @@
+package server_test
+
+import (
+	"llamactl/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSignalInstance(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer backend.Close()
+	router := newUIRouter(t, backend, config.AuthConfig{})
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		body     string
+		want     int
+	}{
+		{"unsupported signal", "stopped", `{"signal": "STOP"}`, http.StatusBadRequest},
+		{"invalid body", "stopped", `signal`, http.StatusBadRequest},
+		{"stopped instance", "stopped", `{"signal": "KILL"}`, http.StatusConflict},
+		{"unmanaged instance", "external", `{"signal": "KILL"}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := post("/api/v1/instances/"+tt.instance+"/signal", tt.body); rec.Code != tt.want {
+				t.Errorf("Expected %d, got %d: %s", tt.want, rec.Code, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"llamactl/pkg/config"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers of a request signed by a trusted gateway
+const (
+	headerSignature          = "X-Llamactl-Signature" // Hex HMAC-SHA256 of the string to sign
+	headerSignatureTimestamp = "X-Llamactl-Timestamp" // Unix seconds
+	headerSignatureNonce     = "X-Llamactl-Nonce"     // Unique to each request
+)
+
+// maxNonceLength bounds the nonces remembered against replays
+const maxNonceLength = 128
+
+// Machine-readable codes of rejected signatures
+const (
+	signatureMalformed = "malformed_signature"
+	signatureInvalid   = "invalid_signature"
+	signatureExpired   = "expired_timestamp"
+	signatureReplayed  = "replayed_nonce"
+)
+
+// errSignedBodyTooLarge is returned for a signed request whose body exceeds the limit
+var errSignedBodyTooLarge = errors.New("request body is too large")
+
+// requestVerifier validates inference requests signed with one of the shared secrets of a
+// gateway. The signature covers the method, the path with its query, the timestamp, the
+// nonce and the SHA-256 of the body, so a signed request cannot be altered; the timestamp
+// bounds how long it is valid and the nonce keeps it from being replayed meanwhile.
+type requestVerifier struct {
+	secrets     [][]byte
+	skew        time.Duration
+	maxBodySize int64
+
+	mu        sync.Mutex
+	nonces    map[string]time.Time // Nonces seen, until their request is no longer valid
+	lastSweep time.Time
+}
+
+func newRequestVerifier(cfg config.RequestSigningConfig) *requestVerifier {
+	secrets := make([][]byte, len(cfg.Secrets))
+	for i, secret := range cfg.Secrets {
+		secrets[i] = []byte(secret)
+	}
+	return &requestVerifier{
+		secrets:     secrets,
+		skew:        time.Duration(cfg.ClockSkew) * time.Second,
+		maxBodySize: cfg.MaxBodySize,
+		nonces:      make(map[string]time.Time),
+	}
+}
+
+// stringToSign returns what a request is signed over
+func stringToSign(method, uri, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return method + "\n" + uri + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:])
+}
+
+// verify checks the signature, timestamp and nonce of a signed request. The body is read to
+// be hashed and put back for the handlers; errSignedBodyTooLarge is returned when it is
+// larger than the limit, before any MAC is computed.
+func (v *requestVerifier) verify(w http.ResponseWriter, r *http.Request) error {
+	timestamp := r.Header.Get(headerSignatureTimestamp)
+	nonce := r.Header.Get(headerSignatureNonce)
+	if timestamp == "" || nonce == "" {
+		return &tokenError{signatureMalformed, fmt.Sprintf("Signed requests need the %s and %s headers", headerSignatureTimestamp, headerSignatureNonce)}
+	}
+	if len(nonce) > maxNonceLength {
+		return &tokenError{signatureMalformed, fmt.Sprintf("Nonce is longer than %d characters", maxNonceLength)}
+	}
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return &tokenError{signatureMalformed, "Timestamp is not a number of Unix seconds"}
+	}
+	signature, err := hex.DecodeString(r.Header.Get(headerSignature))
+	if err != nil {
+		return &tokenError{signatureMalformed, "Signature is not hex encoded"}
+	}
+
+	var body []byte
+	if r.Body != nil {
+		if r.ContentLength > v.maxBodySize {
+			return errSignedBodyTooLarge
+		}
+		body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, v.maxBodySize))
+		if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+			return errSignedBodyTooLarge
+		}
+		if err != nil {
+			return &tokenError{signatureMalformed, "Failed to read the request body"}
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	// Every secret is tried, so the time taken does not tell which one matched
+	message := []byte(stringToSign(r.Method, r.URL.RequestURI(), timestamp, nonce, body))
+	valid := false
+	for _, secret := range v.secrets {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(message)
+		if hmac.Equal(mac.Sum(nil), signature) {
+			valid = true
+		}
+	}
+	if !valid {
+		return &tokenError{signatureInvalid, "Invalid request signature"}
+	}
+
+	now := time.Now()
+	signed := time.Unix(signedAt, 0)
+	if signed.Before(now.Add(-v.skew)) || signed.After(now.Add(v.skew)) {
+		return &tokenError{signatureExpired, fmt.Sprintf("Timestamp is more than %v away from the server clock", v.skew)}
+	}
+	if !v.remember(nonce, signed.Add(v.skew), now) {
+		return &tokenError{signatureReplayed, "Nonce was already used"}
+	}
+	return nil
+}
+
+// remember records a nonce until expiry, returning false if it was already seen. Nonces of
+// requests no longer valid are forgotten from time to time.
+func (v *requestVerifier) remember(nonce string, expiry, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if now.Sub(v.lastSweep) >= v.skew {
+		for seen, until := range v.nonces {
+			if now.After(until) {
+				delete(v.nonces, seen)
+			}
+		}
+		v.lastSweep = now
+	}
+	if until, seen := v.nonces[nonce]; seen && !now.After(until) {
+		return false
+	}
+	v.nonces[nonce] = expiry
+	return true
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"encoding/json"
+	"llamactl/pkg/sinks"
+	"net/http"
+)
+
+// GetSinksStatus godoc
+// @Summary Get the status of the event sinks
+// @Description Returns the connectivity of every configured event sink, with the events it published, dropped because its buffer was full, and still buffered
+// @Tags system
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {array} sinks.Status "Status of each sink, in configuration order"
+// @Router /sinks/status [get]
+func (h *Handler) GetSinksStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := []sinks.Status{}
+		if h.exporter != nil {
+			statuses = h.exporter.Status()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(statuses); err != nil {
+			http.Error(w, "Failed to encode sink status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
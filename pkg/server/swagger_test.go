@@ -0,0 +1,43 @@
+package server_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSwaggerDoc(t *testing.T) {
+	cfg := config.AppConfig{
+		Server: config.ServerConfig{EnableSwagger: true},
+		Instances: config.InstancesConfig{
+			PortRange:           [2]int{8000, 9000},
+			LogsDir:             t.TempDir(),
+			MaxInstances:        10,
+			MaxRunningInstances: -1,
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+	router := server.SetupRouter(server.NewHandler(mngr, cfg))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/swagger/doc.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+
+	// The docs are a template, which text in the annotations can break
+	var doc struct {
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected the docs to be valid JSON, got %v", err)
+	}
+	if _, ok := doc.Paths["/instances/{name}/start"]["post"]; !ok {
+		t.Errorf("Expected the docs to list the start endpoint, got %d paths", len(doc.Paths))
+	}
+}
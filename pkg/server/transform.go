@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/transform"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TransformHeader names the transform applied to a proxied request and its response
+	TransformHeader = "X-Llamactl-Transform"
+	// TransformErrorHeader carries the error of a transform that failed open
+	TransformErrorHeader = "X-Llamactl-Transform-Error"
+
+	// maxTransformBodyBytes caps the buffered body of responses passed to a transform
+	maxTransformBodyBytes = 16 * 1024 * 1024
+)
+
+// proxyTransform is the transform of an instance, applied to a single proxied request
+type proxyTransform struct {
+	opts *instance.TransformOptions
+	t    transform.Transformer
+}
+
+// applyRequestTransform runs the transform of the instance on the request body and returns
+// the body to forward. The response is transformed through the returned proxyTransform, nil
+// when the instance has no transform. When the transform fails closed, the request is
+// answered here and ok is false.
+func applyRequestTransform(w http.ResponseWriter, r *http.Request, inst *instance.Process, body []byte) (pt *proxyTransform, transformed []byte, ok bool) {
+	options := inst.GetOptions()
+	if options == nil || options.Transform == nil {
+		return nil, body, true
+	}
+	opts := options.Transform
+	w.Header().Set(TransformHeader, opts.Name)
+
+	t, err := transform.New(opts.Name, opts.Params)
+	if err == nil {
+		pt = &proxyTransform{opts: opts, t: t}
+		transformed, err = transform.Apply(r.Context(), opts.Budget(), body, t.Request)
+	}
+	if err == nil {
+		return pt, transformed, true
+	}
+
+	if opts.FailsClosed() {
+		http.Error(w, "Request transform "+opts.Name+" failed: "+err.Error(), http.StatusBadGateway)
+		return nil, nil, false
+	}
+	log.Printf("Request transform %s of instance %s failed, forwarding the request unchanged: %v", opts.Name, inst.Name, err)
+	w.Header().Set(TransformErrorHeader, "request: "+err.Error())
+	return pt, body, true
+}
+
+// responseTransformer passes the non-streamed JSON responses of a backend through a
+// transform. Their body is buffered up to maxTransformBodyBytes and the status line held
+// back until the transform ran, so a transform failing closed can still answer with an
+// error. Other responses, larger bodies and streams are passed through unchanged.
+type responseTransformer struct {
+	w   http.ResponseWriter
+	ctx context.Context
+	pt  *proxyTransform
+
+	wroteHeader bool
+	buffering   bool
+	code        int
+	body        []byte
+}
+
+func newResponseTransformer(w http.ResponseWriter, ctx context.Context, pt *proxyTransform) *responseTransformer {
+	return &responseTransformer{w: w, ctx: ctx, pt: pt}
+}
+
+func (t *responseTransformer) Header() http.Header {
+	return t.w.Header()
+}
+
+func (t *responseTransformer) WriteHeader(code int) {
+	if t.wroteHeader {
+		return
+	}
+	t.wroteHeader = true
+
+	header := t.w.Header()
+	encoding := header.Get("Content-Encoding")
+	if code >= 200 && code < 300 && (encoding == "" || encoding == "identity") &&
+		strings.HasPrefix(header.Get("Content-Type"), "application/json") {
+		t.buffering, t.code = true, code
+		return
+	}
+	t.w.WriteHeader(code)
+}
+
+func (t *responseTransformer) Write(p []byte) (int, error) {
+	if !t.wroteHeader {
+		t.WriteHeader(http.StatusOK)
+	}
+	if !t.buffering {
+		return t.w.Write(p)
+	}
+
+	if len(t.body)+len(p) <= maxTransformBodyBytes {
+		t.body = append(t.body, p...)
+		return len(p), nil
+	}
+	// Too large to transform, send what was buffered as is
+	t.buffering = false
+	t.w.Header().Set(TransformErrorHeader, "response: body too large to transform")
+	t.w.WriteHeader(t.code)
+	body := append(t.body, p...)
+	t.body = nil
+	if _, err := t.w.Write(body); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush sends everything written so far, except a body held back for the transform
+func (t *responseTransformer) Flush() {
+	if t.buffering {
+		return
+	}
+	if flusher, ok := t.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (t *responseTransformer) Unwrap() http.ResponseWriter {
+	return t.w
+}
+
+// finish transforms and writes the buffered response once the backend response is complete
+func (t *responseTransformer) finish() {
+	if !t.buffering {
+		return
+	}
+	t.buffering = false
+	body := t.body
+	t.body = nil
+	if len(body) == 0 {
+		t.w.WriteHeader(t.code)
+		return
+	}
+
+	opts := t.pt.opts
+	transformed, err := transform.Apply(t.ctx, opts.Budget(), body, t.pt.t.Response)
+	switch {
+	case err == nil:
+		t.w.Header().Del("Content-Length") // The transformed body has a different length
+		t.w.WriteHeader(t.code)
+		t.w.Write(transformed)
+	case opts.FailsClosed():
+		t.w.Header().Del("Content-Length")
+		http.Error(t.w, "Response transform "+opts.Name+" failed: "+err.Error(), http.StatusBadGateway)
+	default:
+		log.Printf("Response transform %s failed, sending the response unchanged: %v", opts.Name, err)
+		t.w.Header().Set(TransformErrorHeader, "response: "+err.Error())
+		t.w.WriteHeader(t.code)
+		t.w.Write(body)
+	}
+}
@@ -0,0 +1,126 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/server"
+	"llamactl/pkg/transform"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowTransform outlives any time budget given to it in the tests
+type slowTransform struct{}
+
+func (slowTransform) Request(ctx context.Context, _ map[string]any) error {
+	<-ctx.Done()
+	time.Sleep(50 * time.Millisecond)
+	return nil
+}
+
+func (slowTransform) Response(ctx context.Context, body map[string]any) error {
+	return slowTransform{}.Request(ctx, body)
+}
+
+func init() {
+	transform.Register("test_slow", func(map[string]any) (transform.Transformer, error) {
+		return slowTransform{}, nil
+	})
+}
+
+// newEchoBackend answers OpenAI requests with the request body it received
+func newEchoBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(backend.Close)
+	return backend
+}
+
+func newTransformRouter(t *testing.T, opts *instance.TransformOptions) http.Handler {
+	t.Helper()
+	return newExternalBackendRouterWithOptions(t, newEchoBackend(t), func(_ *config.AppConfig, options *instance.CreateInstanceOptions) {
+		options.Transform = opts
+	})
+}
+
+func postChat(router http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestTransform_RequestAndResponse(t *testing.T) {
+	router := newTransformRouter(t, &instance.TransformOptions{
+		Name:   transform.Metadata,
+		Params: map[string]any{"request": map[string]any{"user": "team-a"}, "response": map[string]any{"served_by": "llamactl"}},
+	})
+
+	recorder := postChat(router, `{"model":"external","messages":[]}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if got := recorder.Header().Get(server.TransformHeader); got != transform.Metadata {
+		t.Errorf("Expected the transform to be named in %s, got %q", server.TransformHeader, got)
+	}
+
+	var echoed map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &echoed); err != nil {
+		t.Fatalf("Response is not JSON: %v", err)
+	}
+	if echoed["user"] != "team-a" {
+		t.Errorf("Expected the backend to receive the transformed request, got %v", echoed)
+	}
+	if echoed["served_by"] != "llamactl" {
+		t.Errorf("Expected the response to be transformed, got %v", echoed)
+	}
+}
+
+func TestTransform_FailureModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		failMode string
+		code     int
+	}{
+		{"fail open", instance.TransformFailOpen, http.StatusOK},
+		{"fail closed", instance.TransformFailClosed, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTransformRouter(t, &instance.TransformOptions{
+				Name:     "test_slow",
+				Timeout:  config.Duration(20 * time.Millisecond),
+				FailMode: tt.failMode,
+			})
+
+			body := `{"model":"external","messages":[]}`
+			recorder := postChat(router, body)
+			if recorder.Code != tt.code {
+				t.Fatalf("Expected status %d, got %d: %s", tt.code, recorder.Code, recorder.Body.String())
+			}
+			if tt.code == http.StatusOK {
+				if recorder.Body.String() != body {
+					t.Errorf("Expected the request to be forwarded unchanged, got %s", recorder.Body.String())
+				}
+				if !strings.Contains(recorder.Header().Get(server.TransformErrorHeader), "time budget") {
+					t.Errorf("Expected the error in %s, got %q", server.TransformErrorHeader, recorder.Header().Get(server.TransformErrorHeader))
+				}
+			}
+		})
+	}
+}
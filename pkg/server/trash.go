@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"llamactl/pkg/manager"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListTrash godoc
+// @Summary List deleted instances
+// @Description Returns the deleted instances kept in the trash until they are purged, with the options they can be restored with, secret environment values masked
+// @Tags trash
+// @Security ApiKeyAuth
+// @Produces json
+// @Success 200 {array} manager.TrashedInstance "Deleted instances, sorted by name"
+// @Router /trash [get]
+func (h *Handler) ListTrash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h.InstanceManager.ListTrash()); err != nil {
+			http.Error(w, "Failed to encode trash: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// RestoreInstance godoc
+// @Summary Restore a deleted instance
+// @Description Brings a deleted instance back from the trash, stopped, with the options it was deleted with. It fails if its name or its port was taken meanwhile.
+// @Tags trash
+// @Security ApiKeyAuth
+// @Produces json
+// @Param name path string true "Instance Name"
+// @Success 201 {object} instance.Process "Restored instance"
+// @Failure 404 {string} string "Instance not in the trash"
+// @Failure 409 {string} string "Name or port taken, or options no longer valid"
+// @Router /trash/{name}/restore [post]
+func (h *Handler) RestoreInstance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+
+		inst, err := h.managerFor(r).RestoreInstance(name)
+		if err != nil {
+			if errors.Is(err, manager.ErrNotInTrash) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(inst); err != nil {
+			http.Error(w, "Failed to encode instance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// PurgeInstance godoc
+// @Summary Purge a deleted instance
+// @Description Removes a deleted instance from the trash right away, with its log files if they were to be purged
+// @Tags trash
+// @Security ApiKeyAuth
+// @Param name path string true "Instance Name"
+// @Success 204 "No Content"
+// @Failure 404 {string} string "Instance not in the trash"
+// @Router /trash/{name} [delete]
+func (h *Handler) PurgeInstance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+
+		if err := h.managerFor(r).PurgeInstance(name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
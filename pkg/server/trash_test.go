@@ -0,0 +1,148 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrashEndpoints(t *testing.T) {
+	cfg := config.AppConfig{
+		Instances: config.InstancesConfig{
+			PortRange:           [2]int{8000, 9000},
+			LogsDir:             t.TempDir(),
+			MaxInstances:        10,
+			MaxRunningInstances: -1,
+			TrashRetention:      config.Duration(time.Hour),
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+	srv := httptest.NewServer(server.SetupRouter(server.NewHandler(mngr, cfg)))
+	defer srv.Close()
+
+	create := func(name string) {
+		t.Helper()
+		if _, err := mngr.CreateInstance(name, &instance.CreateInstanceOptions{
+			BackendType:        backends.BackendTypeLlamaCpp,
+			LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+		}); err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+	}
+	request := func(method, path string) (int, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+"/api/v1"+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, data
+	}
+	listTrash := func() []manager.TrashedInstance {
+		t.Helper()
+		status, body := request("GET", "/trash")
+		var trashed []manager.TrashedInstance
+		if status != http.StatusOK || json.Unmarshal(body, &trashed) != nil {
+			t.Fatalf("Expected the trash, got %d: %s", status, body)
+		}
+		return trashed
+	}
+
+	create("restored")
+	create("purged")
+	create("permanent")
+	for _, name := range []string{"restored", "purged"} {
+		if status, body := request("DELETE", "/instances/"+name); status != http.StatusNoContent {
+			t.Fatalf("Expected 204, got %d: %s", status, body)
+		}
+	}
+	if status, body := request("DELETE", "/instances/permanent?permanent=true"); status != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", status, body)
+	}
+	if trashed := listTrash(); len(trashed) != 2 || trashed[0].Name != "purged" || trashed[1].Name != "restored" {
+		t.Fatalf("Expected the soft deleted instances in the trash, got %+v", trashed)
+	}
+
+	if status, body := request("POST", "/trash/restored/restore"); status != http.StatusCreated {
+		t.Errorf("Expected 201, got %d: %s", status, body)
+	}
+	if _, err := mngr.GetInstance("restored"); err != nil {
+		t.Errorf("Expected the instance to be restored: %v", err)
+	}
+	if status, _ := request("POST", "/trash/permanent/restore"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an instance not in the trash, got %d", status)
+	}
+
+	// Restoring over an instance created meanwhile conflicts
+	create("purged")
+	if status, _ := request("POST", "/trash/purged/restore"); status != http.StatusConflict {
+		t.Errorf("Expected 409 for a name taken meanwhile, got %d", status)
+	}
+	if status, body := request("DELETE", "/trash/purged"); status != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d: %s", status, body)
+	}
+	if trashed := listTrash(); len(trashed) != 0 {
+		t.Errorf("Expected the trash to be empty, got %+v", trashed)
+	}
+}
+
+func TestListTrash_MasksSecrets(t *testing.T) {
+	cfg := config.AppConfig{
+		Instances: config.InstancesConfig{
+			PortRange:           [2]int{8000, 9000},
+			LogsDir:             t.TempDir(),
+			MaxInstances:        10,
+			MaxRunningInstances: -1,
+			TrashRetention:      config.Duration(time.Hour),
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+	router := server.SetupRouter(server.NewHandler(mngr, cfg))
+
+	if _, err := mngr.CreateInstance("secret", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		Environment:        map[string]string{"HF_TOKEN": "hf_secret", "CUDA_VISIBLE_DEVICES": "0"},
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if err := mngr.DeleteInstance("secret"); err != nil {
+		t.Fatalf("DeleteInstance failed: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/trash", nil))
+	var trashed []manager.TrashedInstance
+	if recorder.Code != http.StatusOK || json.Unmarshal(recorder.Body.Bytes(), &trashed) != nil || len(trashed) != 1 {
+		t.Fatalf("Expected the trashed instance, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if strings.Contains(recorder.Body.String(), "hf_secret") {
+		t.Errorf("Expected the secret to be left out of the trash listing, got %s", recorder.Body.String())
+	}
+	if environment := trashed[0].Options.Environment; environment["HF_TOKEN"] != instance.MaskedEnvironmentValue || environment["CUDA_VISIBLE_DEVICES"] != "0" {
+		t.Errorf("Expected only the secret to be masked, got %v", environment)
+	}
+
+	// Restored with its value
+	inst, err := mngr.RestoreInstance("secret")
+	if err != nil {
+		t.Fatalf("RestoreInstance failed: %v", err)
+	}
+	if value := inst.GetOptions().Environment["HF_TOKEN"]; value != "hf_secret" {
+		t.Errorf("Expected the instance to be restored with its secret, got %q", value)
+	}
+}
@@ -0,0 +1,496 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"llamactl/pkg/backends"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// uiKeyCookie keeps the API key a browser logged in to the instance web UIs with
+	uiKeyCookie = "llamactl_ui_key"
+	// maxUIPageBytes caps the buffered HTML pages the base href and the header bar are injected in
+	maxUIPageBytes = 8 * 1024 * 1024
+)
+
+var uiTemplates = template.Must(template.New("ui").Parse(`
+{{define "style"}}<style>
+body{font-family:system-ui,sans-serif;margin:0;background:#f6f7f9;color:#1f2328}
+main{max-width:720px;margin:48px auto;padding:0 16px}
+table{width:100%;border-collapse:collapse;background:#fff}
+th,td{text-align:left;padding:8px 12px;border-bottom:1px solid #e4e6ea}
+.running{color:#1a7f37}.stopped{color:#6e7781}
+button,input{font:inherit;padding:6px 12px}
+.error{color:#cf222e}
+</style>{{end}}
+
+{{define "index"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>llamactl instances</title>{{template "style"}}</head>
+<body><main>
+<h1>Instances</h1>
+{{if .}}<table>
+<tr><th>Name</th><th>Status</th><th></th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td class="{{.Status}}">{{.Status}}</td><td><a href="{{.UIPath}}/">Open chat</a></td></tr>
+{{end}}</table>
+{{else}}<p>No llama.cpp instances.</p>{{end}}
+</main></body></html>
+{{end}}
+
+{{define "stopped"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Name}} is not running</title>{{template "style"}}</head>
+<body><main>
+<h1>Instance {{.Name}} is not running</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .Managed}}<form method="post" action="/instances/{{.Name}}/start">
+<p>Start it? Loading the model can take a while.</p>
+<button type="submit">Start {{.Name}}</button>
+</form>
+{{else}}<p>The instance is an external backend, it has to be started where it runs.</p>{{end}}
+<p><a href="/instances/">All instances</a></p>
+</main></body></html>
+{{end}}
+
+{{define "message"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>llamactl</title>{{template "style"}}</head>
+<body><main>
+<p>{{.}}</p>
+<p><a href="/instances/">All instances</a></p>
+</main></body></html>
+{{end}}
+
+{{define "login"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>llamactl login</title>{{template "style"}}</head>
+<body><main>
+<h1>Log in</h1>
+{{if .Invalid}}<p class="error">Invalid API key</p>{{end}}
+<form method="post" action="/instances/login">
+<input type="hidden" name="redirect" value="{{.Redirect}}">
+<input type="password" name="api_key" placeholder="API key" autofocus>
+<button type="submit">Log in</button>
+</form>
+</main></body></html>
+{{end}}
+
+{{define "bar"}}<div id="llamactl-bar" style="position:fixed;right:8px;bottom:8px;z-index:2147483647;font:12px system-ui,sans-serif;background:#1f2328;color:#fff;padding:4px 8px;border-radius:6px;opacity:.85">
+<a href="/instances/" style="color:#fff">llamactl</a>
+<select onchange="location.href=this.value" style="font:inherit">
+{{range .Instances}}<option value="{{.UIPath}}/"{{if eq .Name $.Current}} selected{{end}}>{{.Name}}{{if ne .Status "running"}} ({{.Status}}){{end}}</option>
+{{end}}</select>
+</div>{{end}}
+`))
+
+// uiInstance is an instance listed in the index page and the instance switcher
+type uiInstance struct {
+	Name   string
+	Status string
+	UIPath string
+}
+
+// instanceUIPath returns the path the web UI of an instance is served under
+func instanceUIPath(name string) string {
+	return "/instances/" + name + "/ui"
+}
+
+// writeUIPage renders a page of the instance web UIs
+func writeUIPage(w http.ResponseWriter, status int, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := uiTemplates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Failed to render %s page: %v", name, err)
+	}
+}
+
+// uiInstances lists the llama.cpp instances, the backend that ships a web UI, by name
+func (h *Handler) uiInstances() ([]uiInstance, error) {
+	instances, err := h.InstanceManager.ListInstances()
+	if err != nil {
+		return nil, err
+	}
+	result := []uiInstance{}
+	for _, inst := range instances {
+		if options := inst.GetOptions(); options == nil || options.BackendType != backends.BackendTypeLlamaCpp {
+			continue
+		}
+		status := "stopped"
+		if inst.IsRunning() {
+			status = "running"
+		}
+		result = append(result, uiInstance{Name: inst.Name, Status: status, UIPath: instanceUIPath(inst.Name)})
+	}
+	sort.Slice(result, func(a, b int) bool { return result[a].Name < result[b].Name })
+	return result, nil
+}
+
+// InstanceUIIndex godoc
+// @Summary List the instance web UIs
+// @Description Returns an HTML page linking to the web UI of every llama.cpp instance
+// @Tags ui
+// @Security ApiKeyAuth
+// @Produces html
+// @Success 200 {string} string "Index page"
+// @Router /instances/ [get]
+func (h *Handler) InstanceUIIndex() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instances, err := h.uiInstances()
+		if err != nil {
+			http.Error(w, "Failed to list instances: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeUIPage(w, http.StatusOK, "index", instances)
+	}
+}
+
+// InstanceUI godoc
+// @Summary Proxy the web UI of an instance
+// @Description Proxies the llama-server web UI of an instance under /instances/{name}/ui/, including its SSE and WebSocket streams. HTML pages get a base href so their relative assets load through the proxy, and a bar to switch between instances. A stopped instance shows a page offering to start it.
+// @Tags ui
+// @Security ApiKeyAuth
+// @Param name path string true "Instance Name"
+// @Success 200 "Request successfully proxied to instance"
+// @Failure 404 {string} string "Instance not found"
+// @Failure 503 {string} string "Instance is not running"
+// @Router /instances/{name}/ui/ [get]
+func (h *Handler) InstanceUI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		prefix := instanceUIPath(name)
+		// Relative URLs of the UI resolve against the directory
+		if r.URL.Path == prefix {
+			http.Redirect(w, r, prefix+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			writeUIPage(w, http.StatusNotFound, "message", fmt.Sprintf("Instance %s does not exist.", name))
+			return
+		}
+		setAccessLogInstance(r, name)
+
+		if options := inst.GetOptions(); options == nil || options.BackendType != backends.BackendTypeLlamaCpp {
+			writeUIPage(w, http.StatusBadRequest, "message", fmt.Sprintf("Instance %s is not a llama.cpp server and has no web UI.", name))
+			return
+		}
+		if !inst.IsRunning() {
+			writeUIPage(w, http.StatusServiceUnavailable, "stopped", map[string]any{"Name": name, "Managed": inst.IsManaged()})
+			return
+		}
+
+		proxy, err := inst.GetProxy()
+		if err != nil {
+			http.Error(w, "Failed to get proxy: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if refuseDraining(w, inst) {
+			return
+		}
+		r, done := trackRequest(inst, r, "", false)
+		defer done()
+
+		// Strip the "/instances/<name>/ui" prefix from the request URL
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		if r.URL.Path == "" {
+			r.URL.Path = "/"
+		}
+		if !allowRequest(w, inst, r) {
+			return
+		}
+		r.URL.RawPath = ""
+		stripUICredentials(r)
+
+		// Pages are requested uncompressed so the base href can be injected, the transport
+		// still fetches them compressed from the backend
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			r.Header.Del("Accept-Encoding")
+		}
+
+		// Update the last request time for the instance
+		inst.RecordRequest(r)
+
+		r.Header.Set("X-Forwarded-Host", r.Header.Get("Host"))
+		r.Header.Set("X-Forwarded-Prefix", prefix)
+
+		rewriter := &uiRewriter{w: w, prefix: prefix, bar: func() []byte { return h.uiBar(name) }}
+		proxy.ServeHTTP(rewriter, r)
+		rewriter.finish()
+	}
+}
+
+// StartInstanceUI starts a stopped instance from the form of its web UI, waits for it to
+// become healthy and redirects to the web UI. It is left out of the API documentation,
+// which would list it at the path of StartInstance.
+func (h *Handler) StartInstanceUI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		inst, err := h.InstanceManager.GetInstance(name)
+		if err != nil {
+			writeUIPage(w, http.StatusNotFound, "message", fmt.Sprintf("Instance %s does not exist.", name))
+			return
+		}
+		setAccessLogInstance(r, name)
+
+		failed := func(err error) {
+			writeUIPage(w, http.StatusServiceUnavailable, "stopped", map[string]any{"Name": name, "Managed": inst.IsManaged(), "Error": err.Error()})
+		}
+
+		if !inst.IsRunning() && inst.IsManaged() {
+			if h.InstanceManager.IsMaxRunningInstancesReached() {
+				if !h.instancesSettings().EnableLRUEviction {
+					failed(fmt.Errorf("cannot start instance, maximum number of instances reached"))
+					return
+				}
+				if err := h.InstanceManager.EvictLRUInstance(); err != nil {
+					failed(fmt.Errorf("cannot start instance, failed to evict instance: %w", err))
+					return
+				}
+			}
+			if _, err := h.InstanceManager.StartInstance(name); err != nil {
+				failed(fmt.Errorf("failed to start instance: %w", err))
+				return
+			}
+			log.Printf("Instance %s started from its web UI", name)
+		}
+
+		// Wait for the instance to become healthy before proceeding, up to its readiness timeout
+		if err := inst.WaitForHealthy(0); err != nil {
+			failed(fmt.Errorf("instance failed to become healthy: %w", err))
+			return
+		}
+		http.Redirect(w, r, instanceUIPath(name)+"/", http.StatusSeeOther)
+	}
+}
+
+// uiBar renders the instance switcher injected in the pages of an instance web UI
+func (h *Handler) uiBar(current string) []byte {
+	instances, err := h.uiInstances()
+	if err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := uiTemplates.ExecuteTemplate(&buf, "bar", map[string]any{"Instances": instances, "Current": current}); err != nil {
+		log.Printf("Failed to render the instance switcher: %v", err)
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// stripUICredentials removes the login cookie of the web UIs, and the API key copied from
+// it, so they are not forwarded to the backend
+func stripUICredentials(r *http.Request) {
+	cookies := r.Cookies()
+	found := false
+	r.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name == uiKeyCookie {
+			found = true
+			continue
+		}
+		r.AddCookie(cookie)
+	}
+	if found {
+		r.Header.Del("X-API-Key")
+	}
+}
+
+// UIAuthMiddleware authenticates browser access to the instance web UIs with an inference
+// or management key. Besides the usual headers and query parameter, the key is read from
+// the cookie set by UILogin, since a browser loading the UI and its assets sends no API
+// key. Unauthenticated page loads get a login page instead of a JSON error.
+func (a *APIAuthMiddleware) UIAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		apiKey := extractAPIKey(r)
+		if apiKey == "" {
+			if cookie, err := r.Cookie(uiKeyCookie); err == nil {
+				apiKey = cookie.Value
+				// Let the quota and access log middlewares see the key
+				r.Header.Set("X-API-Key", apiKey)
+			}
+		}
+
+		if apiKey == "" || !(a.isValidKey(apiKey, KeyTypeInference) || a.isValidKey(apiKey, KeyTypeManagement)) {
+			if r.Method == "GET" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				writeUIPage(w, http.StatusUnauthorized, "login", map[string]any{"Redirect": r.URL.RequestURI(), "Invalid": apiKey != ""})
+				return
+			}
+			a.unauthorized(w, "invalid_api_key", "Missing or invalid API key")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UILogin godoc
+// @Summary Log in to the instance web UIs
+// @Description Checks the API key of the login form and keeps it in an HttpOnly cookie scoped to /instances/, then redirects back to the page that required it
+// @Tags ui
+// @Accept x-www-form-urlencoded
+// @Param api_key formData string true "Inference or management API key"
+// @Param redirect formData string false "Page to return to"
+// @Success 303 "Redirect to the page that required the login"
+// @Failure 401 {string} string "Invalid API key"
+// @Router /instances/login [post]
+func (a *APIAuthMiddleware) UILogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.PostFormValue("api_key")
+		redirect := r.PostFormValue("redirect")
+		// Only return to the web UIs, never to another site
+		if !strings.HasPrefix(redirect, "/instances/") {
+			redirect = "/instances/"
+		}
+
+		if apiKey == "" || !(a.isValidKey(apiKey, KeyTypeInference) || a.isValidKey(apiKey, KeyTypeManagement)) {
+			writeUIPage(w, http.StatusUnauthorized, "login", map[string]any{"Redirect": redirect, "Invalid": true})
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     uiKeyCookie,
+			Value:    apiKey,
+			Path:     "/instances/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, redirect, http.StatusSeeOther)
+	}
+}
+
+// uiRewriter adapts the responses of a backend web UI to the path it is proxied under:
+// redirects to absolute paths get the prefix, and HTML pages get a base href, so their
+// relative assets and API calls go through the proxy, and the instance switcher. Pages are
+// buffered up to maxUIPageBytes; anything else, including SSE streams, is passed through.
+type uiRewriter struct {
+	w      http.ResponseWriter
+	prefix string
+	bar    func() []byte
+
+	wroteHeader bool
+	buffering   bool
+	body        []byte
+}
+
+func (u *uiRewriter) Header() http.Header {
+	return u.w.Header()
+}
+
+func (u *uiRewriter) WriteHeader(code int) {
+	if u.wroteHeader {
+		return
+	}
+	u.wroteHeader = true
+
+	header := u.w.Header()
+	if location := header.Get("Location"); strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") {
+		header.Set("Location", u.prefix+location)
+	}
+
+	encoding := header.Get("Content-Encoding")
+	if code >= 200 && code < 300 && (encoding == "" || encoding == "identity") && strings.HasPrefix(header.Get("Content-Type"), "text/html") {
+		u.buffering = true
+		// The rewritten page has a different length
+		header.Del("Content-Length")
+	}
+	u.w.WriteHeader(code)
+}
+
+func (u *uiRewriter) Write(p []byte) (int, error) {
+	if !u.wroteHeader {
+		u.WriteHeader(http.StatusOK)
+	}
+	if !u.buffering {
+		return u.w.Write(p)
+	}
+
+	if len(u.body)+len(p) <= maxUIPageBytes {
+		u.body = append(u.body, p...)
+		return len(p), nil
+	}
+	// Too large to rewrite, send what was buffered as is
+	u.buffering = false
+	body := append(u.body, p...)
+	u.body = nil
+	if _, err := u.w.Write(body); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush sends everything written so far; a buffered page is only sent by finish
+func (u *uiRewriter) Flush() {
+	if flusher, ok := u.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController, which hijacks the
+// connection of WebSocket upgrades
+func (u *uiRewriter) Unwrap() http.ResponseWriter {
+	return u.w
+}
+
+// finish writes the buffered page once the backend response is complete
+func (u *uiRewriter) finish() {
+	if !u.buffering {
+		return
+	}
+	u.w.Write(injectUI(u.body, u.prefix, u.bar()))
+	u.body = nil
+}
+
+// injectUI adds a base href after the opening head tag of a page, and the bar before its
+// closing body tag
+func injectUI(page []byte, prefix string, bar []byte) []byte {
+	base := []byte(`<base href="` + template.HTMLEscapeString(prefix) + `/">`)
+	lower := bytes.ToLower(page)
+
+	var out []byte
+	if end := headTagEnd(lower); end != -1 {
+		out = append(out, page[:end]...)
+		out = append(out, base...)
+		page, lower = page[end:], lower[end:]
+	} else {
+		out = append(out, base...)
+	}
+
+	if idx := bytes.LastIndex(lower, []byte("</body")); idx != -1 {
+		out = append(out, page[:idx]...)
+		out = append(out, bar...)
+		return append(out, page[idx:]...)
+	}
+	out = append(out, page...)
+	return append(out, bar...)
+}
+
+// headTagEnd returns the offset after the opening head tag of a lowercased page, or -1
+func headTagEnd(lower []byte) int {
+	for offset := 0; ; {
+		idx := bytes.Index(lower[offset:], []byte("<head"))
+		if idx == -1 {
+			return -1
+		}
+		start := offset + idx + len("<head")
+		// Skip <header> and the like
+		if start < len(lower) && (lower[start] == '>' || lower[start] == ' ' || lower[start] == '\t' || lower[start] == '\n' || lower[start] == '\r') {
+			if end := bytes.IndexByte(lower[start:], '>'); end != -1 {
+				return start + end + 1
+			}
+			return -1
+		}
+		offset = start
+	}
+}
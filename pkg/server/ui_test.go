@@ -0,0 +1,204 @@
+package server_test
+
+import (
+	"io"
+	"llamactl/pkg/backends"
+	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
+	"llamactl/pkg/instance"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newUIRouter serves the llamactl API in front of backend, registered as the running
+// unmanaged instance "external", with a stopped managed instance "stopped" next to it
+func newUIRouter(t *testing.T, backend *httptest.Server, auth config.AuthConfig) http.Handler {
+	t.Helper()
+	backendURL, _ := url.Parse(backend.URL)
+	port, _ := strconv.Atoi(backendURL.Port())
+
+	cfg := config.AppConfig{
+		Auth: auth,
+		Instances: config.InstancesConfig{
+			PortRange:           [2]int{8000, 9000},
+			LogsDir:             t.TempDir(),
+			MaxInstances:        10,
+			MaxRunningInstances: -1,
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+
+	managed := false
+	inst, err := mngr.CreateInstance("external", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		Managed:            &managed,
+		ConnectHost:        backendURL.Hostname(),
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Port: port},
+	})
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if _, err := mngr.CreateInstance("stopped", &instance.CreateInstanceOptions{
+		BackendType:        backends.BackendTypeLlamaCpp,
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{Model: "/path/to/model.gguf"},
+	}); err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !inst.IsRunning() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the external backend to be probed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return server.SetupRouter(server.NewHandler(mngr, cfg))
+}
+
+// browse sends a request the way a browser loading a page does
+func browse(router http.Handler, method, path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestInstanceUI(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, `<!DOCTYPE html><html><HEAD lang="en"><title>llama.cpp</title></HEAD><body><script src="./app.js"></script></body></html>`)
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"content\":\"hi\"}\n\n")
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer backend.Close()
+	router := newUIRouter(t, backend, config.AuthConfig{})
+
+	t.Run("page", func(t *testing.T) {
+		resp := browse(router, "GET", "/instances/external/ui/")
+		if resp.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", resp.Code, resp.Body.String())
+		}
+		page := resp.Body.String()
+		if !strings.Contains(page, `<HEAD lang="en"><base href="/instances/external/ui/">`) {
+			t.Errorf("Expected the base href after the head tag, got %s", page)
+		}
+		if !strings.Contains(page, `id="llamactl-bar"`) || !strings.Contains(page, `value="/instances/stopped/ui/"`) {
+			t.Errorf("Expected the instance switcher in the page, got %s", page)
+		}
+		if !strings.HasSuffix(page, "</body></html>") {
+			t.Errorf("Expected the switcher inside the body, got %s", page)
+		}
+	})
+
+	t.Run("directory redirect", func(t *testing.T) {
+		resp := browse(router, "GET", "/instances/external/ui")
+		if resp.Code != http.StatusMovedPermanently || resp.Header().Get("Location") != "/instances/external/ui/" {
+			t.Errorf("Expected a redirect to the UI directory, got %d %s", resp.Code, resp.Header().Get("Location"))
+		}
+	})
+
+	t.Run("backend redirect", func(t *testing.T) {
+		resp := browse(router, "GET", "/instances/external/ui/old")
+		if location := resp.Header().Get("Location"); location != "/instances/external/ui/new" {
+			t.Errorf("Expected the redirect to stay under the UI, got %q", location)
+		}
+	})
+
+	t.Run("event stream", func(t *testing.T) {
+		resp := browse(router, "GET", "/instances/external/ui/events")
+		if body := resp.Body.String(); body != "data: {\"content\":\"hi\"}\n\n" {
+			t.Errorf("Expected the stream to be passed through, got %q", body)
+		}
+	})
+
+	t.Run("stopped instance", func(t *testing.T) {
+		resp := browse(router, "GET", "/instances/stopped/ui/")
+		if resp.Code != http.StatusServiceUnavailable {
+			t.Fatalf("Expected 503, got %d", resp.Code)
+		}
+		if page := resp.Body.String(); !strings.Contains(page, "is not running") || !strings.Contains(page, `action="/instances/stopped/start"`) {
+			t.Errorf("Expected a page offering to start the instance, got %s", page)
+		}
+	})
+
+	t.Run("index", func(t *testing.T) {
+		resp := browse(router, "GET", "/instances/")
+		page := resp.Body.String()
+		if resp.Code != http.StatusOK || !strings.Contains(page, `href="/instances/external/ui/"`) || !strings.Contains(page, `href="/instances/stopped/ui/"`) {
+			t.Errorf("Expected links to every instance UI, got %d %s", resp.Code, page)
+		}
+	})
+}
+
+func TestInstanceUI_Login(t *testing.T) {
+	var backendCookies, backendKey string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendCookies, backendKey = r.Header.Get("Cookie"), r.Header.Get("X-API-Key")
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "<html><head></head><body></body></html>")
+	}))
+	defer backend.Close()
+	router := newUIRouter(t, backend, config.AuthConfig{
+		RequireInferenceAuth: true,
+		InferenceKeys:        []string{"sk-inference-test"},
+	})
+
+	resp := browse(router, "GET", "/instances/external/ui/")
+	if resp.Code != http.StatusUnauthorized || !strings.Contains(resp.Body.String(), `action="/instances/login"`) {
+		t.Fatalf("Expected a login page, got %d %s", resp.Code, resp.Body.String())
+	}
+
+	login := func(key, redirect string) *httptest.ResponseRecorder {
+		form := url.Values{"api_key": {key}, "redirect": {redirect}}
+		req := httptest.NewRequest("POST", "/instances/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+	if resp := login("sk-wrong", "/instances/external/ui/"); resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an invalid key to be rejected, got %d", resp.Code)
+	}
+	if resp := login("sk-inference-test", "https://example.com/"); resp.Header().Get("Location") != "/instances/" {
+		t.Errorf("Expected a redirect outside the UIs to be ignored, got %q", resp.Header().Get("Location"))
+	}
+
+	resp = login("sk-inference-test", "/instances/external/ui/")
+	if resp.Code != http.StatusSeeOther || resp.Header().Get("Location") != "/instances/external/ui/" {
+		t.Fatalf("Expected a redirect back to the UI, got %d %s", resp.Code, resp.Header().Get("Location"))
+	}
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].Path != "/instances/" {
+		t.Fatalf("Expected an HttpOnly cookie scoped to the UIs, got %+v", cookies)
+	}
+
+	resp = browse(router, "GET", "/instances/external/ui/", cookies[0], &http.Cookie{Name: "theme", Value: "dark"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Expected the cookie to authenticate the UI, got %d", resp.Code)
+	}
+	if backendCookies != "theme=dark" || backendKey != "" {
+		t.Errorf("Expected the key not to be forwarded to the backend, got cookies %q and key %q", backendCookies, backendKey)
+	}
+}
@@ -0,0 +1,77 @@
+package server_test
+
+import (
+	"encoding/json"
+	"llamactl/pkg/config"
+	"llamactl/pkg/manager"
+	"llamactl/pkg/server"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidationErrorResponse(t *testing.T) {
+	cfg := config.AppConfig{
+		Instances: config.InstancesConfig{
+			PortRange:           [2]int{8000, 9000},
+			LogsDir:             t.TempDir(),
+			MaxInstances:        10,
+			MaxRunningInstances: -1,
+		},
+	}
+	mngr := manager.NewInstanceManager(cfg.Backends, cfg.Instances)
+	t.Cleanup(mngr.Shutdown)
+	router := server.SetupRouter(server.NewHandler(mngr, cfg))
+
+	invalid := `{"backend_type": "llama_cpp", "connect_host": "0.0.0.0", "backend_options": {"model": "/m.gguf", "port": -1}}`
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantFields []string
+	}{
+		{name: "validate", method: "POST", path: "/api/v1/instances/llama/validate", body: invalid, wantStatus: http.StatusBadRequest, wantFields: []string{"backend_options.port", "connect_host"}},
+		{name: "validate name", method: "POST", path: "/api/v1/instances/bad.name/validate", body: invalid, wantStatus: http.StatusBadRequest, wantFields: []string{"name", "backend_options.port", "connect_host"}},
+		{name: "validate valid", method: "POST", path: "/api/v1/instances/llama/validate", body: `{"backend_type": "llama_cpp", "backend_options": {"model": "/m.gguf"}}`, wantStatus: http.StatusOK},
+		{name: "create", method: "POST", path: "/api/v1/instances/llama", body: invalid, wantStatus: http.StatusBadRequest, wantFields: []string{"backend_options.port", "connect_host"}},
+		{name: "conflicting aliases", method: "POST", path: "/api/v1/instances/llama/validate", body: `{"backend_type": "llama_cpp", "backend_options": {"model": "/m.gguf", "ctx_size": 4096, "n_ctx": 8192}}`, wantStatus: http.StatusBadRequest, wantFields: []string{"backend_options.n_ctx"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, recorder.Code, recorder.Body.String())
+			}
+			if tt.wantFields == nil {
+				return
+			}
+
+			var resp server.ValidationErrorResponse
+			if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Expected a JSON body, got %q: %v", recorder.Body.String(), err)
+			}
+			var fields []string
+			for _, fieldErr := range resp.Errors {
+				if fieldErr.Constraint == "" || fieldErr.Message == "" {
+					t.Errorf("Expected a constraint and a message, got %+v", fieldErr)
+				}
+				fields = append(fields, fieldErr.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Errorf("Expected invalid fields %v, got %v", tt.wantFields, fields)
+			}
+			if resp.Error == "" {
+				t.Error("Expected a summary of the errors")
+			}
+		})
+	}
+
+	if _, err := mngr.GetInstance("llama"); err == nil {
+		t.Error("Expected no instance to be created from invalid options")
+	}
+}
@@ -0,0 +1,106 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"llamactl/pkg/config"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// kafkaContentType is the embedded JSON format of the v2 API of the Kafka REST proxy
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// kafkaPublisher produces to Kafka through a Kafka REST proxy, one request per topic of a
+// batch. The proxy answers once the brokers acknowledged the records.
+type kafkaPublisher struct {
+	baseURL  string
+	user     string
+	password string
+	client   *http.Client
+}
+
+func newKafkaPublisher(cfg config.SinkConfig) (*kafkaPublisher, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid Kafka REST proxy url %q: expected an http(s) URL", cfg.URL)
+	}
+	return &kafkaPublisher{
+		baseURL:  strings.TrimSuffix(cfg.URL, "/"),
+		user:     cfg.Username,
+		password: cfg.Password,
+		client:   &http.Client{Timeout: publishTimeout},
+	}, nil
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (p *kafkaPublisher) publish(batch []message) error {
+	// Keep the order of the events of each topic
+	var topics []string
+	records := make(map[string][]kafkaRecord)
+	for _, msg := range batch {
+		if _, ok := records[msg.topic]; !ok {
+			topics = append(topics, msg.topic)
+		}
+		records[msg.topic] = append(records[msg.topic], kafkaRecord{Key: msg.key, Value: msg.payload})
+	}
+
+	for _, topic := range topics {
+		if err := p.produce(topic, records[topic]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *kafkaPublisher) produce(topic string, records []kafkaRecord) error {
+	body, err := json.Marshal(map[string]any{"records": records})
+	if err != nil {
+		return fmt.Errorf("failed to encode records: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, p.baseURL+"/topics/"+url.PathEscape(topic), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create produce request: %w", err)
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json, application/json")
+	if p.user != "" {
+		req.SetBasicAuth(p.user, p.password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce to topic %s: %w", topic, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("producing to topic %s returned status %d: %s", topic, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	// Records can fail individually, e.g. while a partition has no leader
+	var result struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := json.Unmarshal(data, &result); err == nil {
+		for _, offset := range result.Offsets {
+			if offset.Error != "" {
+				return fmt.Errorf("producing to topic %s failed: %s", topic, offset.Error)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *kafkaPublisher) close() {
+	p.client.CloseIdleConnections()
+}
@@ -0,0 +1,155 @@
+package sinks
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"llamactl/pkg/config"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultNATSPort = "4222"
+	// natsMaxIdle is how long a connection is reused without traffic. The server drops
+	// clients that leave its pings unanswered, and pings are only answered while publishing.
+	natsMaxIdle = time.Minute
+)
+
+// natsPublisher publishes to a NATS server over its text protocol. A batch is followed by
+// a PING, and acknowledged by the PONG the server answers once it processed the batch.
+type natsPublisher struct {
+	addr     string
+	user     string
+	password string
+
+	conn     net.Conn
+	reader   *bufio.Reader
+	lastUsed time.Time
+}
+
+func newNATSPublisher(cfg config.SinkConfig) (*natsPublisher, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Scheme != "nats" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid NATS url %q: expected nats://host:port", cfg.URL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultNATSPort
+	}
+	p := &natsPublisher{addr: net.JoinHostPort(u.Hostname(), port), user: cfg.Username, password: cfg.Password}
+	if u.User != nil && p.user == "" {
+		p.user = u.User.Username()
+		p.password, _ = u.User.Password()
+	}
+	return p, nil
+}
+
+func (p *natsPublisher) publish(batch []message) error {
+	if p.conn != nil && time.Since(p.lastUsed) > natsMaxIdle {
+		p.close()
+	}
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+
+	err := p.send(batch)
+	if err != nil {
+		p.close()
+		return err
+	}
+	p.lastUsed = time.Now()
+	return nil
+}
+
+func (p *natsPublisher) send(batch []message) error {
+	p.conn.SetDeadline(time.Now().Add(publishTimeout))
+
+	w := bufio.NewWriter(p.conn)
+	for _, msg := range batch {
+		fmt.Fprintf(w, "PUB %s %d\r\n", msg.topic, len(msg.payload))
+		w.Write(msg.payload)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("PING\r\n")
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to send to NATS server %s: %w", p.addr, err)
+	}
+	return p.awaitPong()
+}
+
+// awaitPong reads until the PONG answering our PING, answering the pings of the server
+func (p *natsPublisher) awaitPong() error {
+	for {
+		line, err := p.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("NATS server %s did not acknowledge: %w", p.addr, err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("failed to answer NATS server %s: %w", p.addr, err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server %s: %s", p.addr, strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK and INFO updates need no answer
+	}
+}
+
+func (p *natsPublisher) connect() error {
+	conn, err := net.DialTimeout("tcp", p.addr, publishTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS server %s: %w", p.addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(publishTimeout))
+	reader := bufio.NewReader(conn)
+
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("NATS server %s did not greet: %v", p.addr, err)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+	if info.TLSRequired {
+		conn.Close()
+		return fmt.Errorf("NATS server %s requires TLS, which is not supported", p.addr)
+	}
+
+	connect := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "llamactl",
+		"lang":     "go",
+		"protocol": 1,
+	}
+	if p.user != "" {
+		connect["user"] = p.user
+		connect["pass"] = p.password
+	}
+	options, _ := json.Marshal(connect)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", options); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to connect to NATS server %s: %w", p.addr, err)
+	}
+
+	p.conn, p.reader = conn, reader
+	return nil
+}
+
+func (p *natsPublisher) close() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn, p.reader = nil, nil
+	}
+}
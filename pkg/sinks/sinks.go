@@ -0,0 +1,341 @@
+// Package sinks exports events to message brokers. Events are the JSON objects of the
+// event stream, so consumers of the stream and of a broker share a schema. Exporting is
+// best-effort: each sink buffers a bounded number of events while its broker is
+// unreachable, redelivers them once it is back and drops events beyond the buffer.
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/storage"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxBatchSize caps the events sent to a broker in a single publish
+	maxBatchSize = 100
+	// publishTimeout bounds a single publish, connecting included
+	publishTimeout = 10 * time.Second
+
+	minRetryDelay = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// message is an encoded event bound for a topic
+type message struct {
+	topic   string
+	key     string // Instance the event is about, used as the Kafka record key
+	payload json.RawMessage
+}
+
+// publisher delivers messages to a broker. publish returns once the broker acknowledged
+// every message of the batch, or an error if it may not have received some of them.
+type publisher interface {
+	publish(batch []message) error
+	close()
+}
+
+// Status is the connectivity and the delivery counters of a sink
+type Status struct {
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	Connected   bool       `json:"connected"`
+	Published   int64      `json:"published"`   // Events acknowledged by the broker
+	Dropped     int64      `json:"dropped"`     // Events dropped because the buffer was full
+	Buffered    int        `json:"buffered"`    // Events waiting for delivery
+	BufferSize  int        `json:"buffer_size"` // Events buffered at most
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// sink delivers the events of the classes it exports to a single broker
+type sink struct {
+	cfg   config.SinkConfig
+	pub   publisher
+	queue chan message
+	stop  chan struct{}
+	done  chan struct{}
+
+	published atomic.Int64
+	dropped   atomic.Int64
+	inFlight  atomic.Int64 // Taken from the queue, not acknowledged yet
+
+	mu          sync.Mutex
+	connected   bool
+	lastError   string
+	lastErrorAt time.Time
+}
+
+// Exporter fans events out to the configured sinks. The zero number of sinks is valid, and
+// makes every method a no-op.
+type Exporter struct {
+	sinks []*sink
+
+	unsubscribe func()
+	watching    sync.WaitGroup
+	closeOnce   sync.Once
+}
+
+// NewExporter starts a delivery goroutine for each sink. Sinks connect on their first event.
+func NewExporter(sinks []config.SinkConfig) (*Exporter, error) {
+	e := &Exporter{}
+	for _, cfg := range sinks {
+		var pub publisher
+		switch cfg.Type {
+		case config.SinkNATS:
+			p, err := newNATSPublisher(cfg)
+			if err != nil {
+				e.Close()
+				return nil, fmt.Errorf("sink %s: %w", cfg.Name, err)
+			}
+			pub = p
+		case config.SinkKafka:
+			p, err := newKafkaPublisher(cfg)
+			if err != nil {
+				e.Close()
+				return nil, fmt.Errorf("sink %s: %w", cfg.Name, err)
+			}
+			pub = p
+		default:
+			e.Close()
+			return nil, fmt.Errorf("sink %s: unknown type %q", cfg.Name, cfg.Type)
+		}
+
+		s := &sink{
+			cfg:   cfg,
+			pub:   pub,
+			queue: make(chan message, max(cfg.BufferSize, 1)),
+			stop:  make(chan struct{}),
+			done:  make(chan struct{}),
+		}
+		e.sinks = append(e.sinks, s)
+		go s.run()
+	}
+	return e, nil
+}
+
+// Watch exports the events of a bus subscription until Close, e.g. the lifecycle events of
+// the instance manager. The subscription is unsubscribed on Close.
+func (e *Exporter) Watch(ch <-chan events.Event, unsubscribe func()) {
+	if len(e.sinks) == 0 {
+		unsubscribe()
+		return
+	}
+	e.unsubscribe = unsubscribe
+	e.watching.Add(1)
+	go func() {
+		defer e.watching.Done()
+		for event := range ch {
+			e.Export(event)
+		}
+	}()
+}
+
+// Exports reports whether any sink exports events of class, so callers can skip building
+// events nobody receives
+func (e *Exporter) Exports(class string) bool {
+	for _, s := range e.sinks {
+		if _, ok := s.cfg.Topics[class]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Export queues an event on the sinks exporting its class. It never blocks: a sink whose
+// buffer is full drops the event.
+func (e *Exporter) Export(event events.Event) {
+	if len(e.sinks) == 0 {
+		return
+	}
+	class := ClassOf(event.Type)
+	if !e.Exports(class) {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode %s event for export: %v", event.Type, err)
+		return
+	}
+
+	for _, s := range e.sinks {
+		topic, ok := s.cfg.Topics[class]
+		if !ok {
+			continue
+		}
+		select {
+		case s.queue <- message{topic: topic, key: event.Instance, payload: payload}:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// ExportAudit exports an audit log record as an audit event
+func (e *Exporter) ExportAudit(record storage.AuditRecord) {
+	e.Export(events.Event{
+		Type:      events.TypeAudit,
+		Instance:  record.Target,
+		Code:      record.Action,
+		Message:   record.Details,
+		Timestamp: record.Time,
+		Data: map[string]any{
+			"actor":  record.Actor,
+			"action": record.Action,
+			"target": record.Target,
+		},
+	})
+}
+
+// Status returns the status of every sink, in configuration order
+func (e *Exporter) Status() []Status {
+	statuses := make([]Status, 0, len(e.sinks))
+	for _, s := range e.sinks {
+		statuses = append(statuses, s.status())
+	}
+	return statuses
+}
+
+// Close stops watching the bus and the delivery goroutines. Events still buffered are lost.
+func (e *Exporter) Close() {
+	e.closeOnce.Do(func() {
+		if e.unsubscribe != nil {
+			e.unsubscribe()
+		}
+		e.watching.Wait()
+		for _, s := range e.sinks {
+			close(s.stop)
+			<-s.done
+			s.pub.close()
+		}
+	})
+}
+
+// ClassOf returns the class of an event type
+func ClassOf(eventType string) string {
+	switch eventType {
+	case events.TypeRequest:
+		return config.EventClassRequest
+	case events.TypeAudit:
+		return config.EventClassAudit
+	}
+	return config.EventClassLifecycle
+}
+
+// run delivers the queued events in batches, retrying a batch until the broker acknowledges
+// it, so events are delivered at least once while they fit in the buffer
+func (s *sink) run() {
+	defer close(s.done)
+
+	batch := make([]message, 0, maxBatchSize)
+	for {
+		select {
+		case msg := <-s.queue:
+			batch = append(batch, msg)
+		case <-s.stop:
+			return
+		}
+	fill:
+		for len(batch) < maxBatchSize {
+			select {
+			case msg := <-s.queue:
+				batch = append(batch, msg)
+			default:
+				break fill
+			}
+		}
+		s.inFlight.Store(int64(len(batch)))
+
+		delay := minRetryDelay
+		for {
+			err := s.pub.publish(batch)
+			s.recordResult(err)
+			if err == nil {
+				s.published.Add(int64(len(batch)))
+				break
+			}
+			select {
+			case <-time.After(delay):
+				delay = min(2*delay, maxRetryDelay)
+			case <-s.stop:
+				return
+			}
+		}
+		s.inFlight.Store(0)
+		batch = batch[:0]
+	}
+}
+
+func (s *sink) recordResult(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.connected = true
+		return
+	}
+	if s.connected || s.lastError != err.Error() {
+		log.Printf("Sink %s failed to publish events, retrying: %v", s.cfg.Name, err)
+	}
+	s.connected = false
+	s.lastError = err.Error()
+	s.lastErrorAt = time.Now()
+}
+
+func (s *sink) status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := Status{
+		Name:       s.cfg.Name,
+		Type:       s.cfg.Type,
+		Connected:  s.connected,
+		Published:  s.published.Load(),
+		Dropped:    s.dropped.Load(),
+		Buffered:   len(s.queue) + int(s.inFlight.Load()),
+		BufferSize: cap(s.queue),
+		LastError:  s.lastError,
+	}
+	if !s.lastErrorAt.IsZero() {
+		lastErrorAt := s.lastErrorAt
+		status.LastErrorAt = &lastErrorAt
+	}
+	return status
+}
+
+// auditStore exports the audit records appended to a store
+type auditStore struct {
+	storage.Store
+	exporter *Exporter
+}
+
+// ExportAuditLog returns store, exporting the audit records appended through it once they
+// are persisted
+func ExportAuditLog(store storage.Store, exporter *Exporter) storage.Store {
+	if store == nil || !exporter.Exports(config.EventClassAudit) {
+		return store
+	}
+	return &auditStore{Store: store, exporter: exporter}
+}
+
+// TakeCorrupt reports the corrupt records set aside by the wrapped store, if it does so
+func (s *auditStore) TakeCorrupt() []storage.CorruptRecord {
+	if reporter, ok := s.Store.(storage.CorruptionReporter); ok {
+		return reporter.TakeCorrupt()
+	}
+	return nil
+}
+
+func (s *auditStore) AppendAudit(record storage.AuditRecord) error {
+	if err := s.Store.AppendAudit(record); err != nil {
+		return err
+	}
+	s.exporter.ExportAudit(record)
+	return nil
+}
@@ -0,0 +1,216 @@
+package sinks_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"llamactl/pkg/config"
+	"llamactl/pkg/events"
+	"llamactl/pkg/sinks"
+	"llamactl/pkg/storage"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNATS is a NATS server recording the messages published to it
+type fakeNATS struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages map[string][]events.Event // By subject
+}
+
+func newFakeNATS(t *testing.T) *fakeNATS {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeNATS{listener: listener, messages: make(map[string][]events.Event)}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\",\"max_payload\":1048576}\r\n")
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			var event events.Event
+			json.Unmarshal(payload[:size], &event)
+			s.mu.Lock()
+			s.messages[fields[1]] = append(s.messages[fields[1]], event)
+			s.mu.Unlock()
+		case "PING":
+			conn.Write([]byte("PONG\r\n"))
+		}
+	}
+}
+
+func (s *fakeNATS) received(subject string) []events.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]events.Event(nil), s.messages[subject]...)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExporter_NATS(t *testing.T) {
+	server := newFakeNATS(t)
+	exporter, err := sinks.NewExporter([]config.SinkConfig{{
+		Name:       "nats",
+		Type:       config.SinkNATS,
+		URL:        "nats://" + server.listener.Addr().String(),
+		Topics:     map[string]string{config.EventClassLifecycle: "llamactl.lifecycle", config.EventClassAudit: "llamactl.audit"},
+		BufferSize: 10,
+	}})
+	if err != nil {
+		t.Fatalf("NewExporter failed: %v", err)
+	}
+	defer exporter.Close()
+
+	bus := events.NewBus(nil)
+	exporter.Watch(bus.Subscribe())
+	published := bus.Publish(events.Event{Type: events.TypeStatusChange, Instance: "llama", Code: "started"})
+	exporter.Export(events.Event{Type: events.TypeRequest, Instance: "llama"}) // Class not exported
+
+	store := sinks.ExportAuditLog(storage.NewFileStore(t.TempDir(), t.TempDir()), exporter)
+	if err := store.AppendAudit(storage.AuditRecord{Time: time.Now(), Actor: "alice", Action: "start", Target: "llama"}); err != nil {
+		t.Fatalf("AppendAudit failed: %v", err)
+	}
+
+	waitFor(t, "the events to be published", func() bool {
+		return len(server.received("llamactl.lifecycle")) == 1 && len(server.received("llamactl.audit")) == 1
+	})
+
+	lifecycle := server.received("llamactl.lifecycle")[0]
+	if lifecycle.ID != published.ID || lifecycle.Code != "started" || lifecycle.Instance != "llama" {
+		t.Errorf("Expected the event as published on the bus, got %+v", lifecycle)
+	}
+	audit := server.received("llamactl.audit")[0]
+	if audit.Type != events.TypeAudit || audit.Data["actor"] != "alice" || audit.Code != "start" {
+		t.Errorf("Expected the audit record as an audit event, got %+v", audit)
+	}
+
+	statuses := exporter.Status()
+	if len(statuses) != 1 || !statuses[0].Connected || statuses[0].Published != 2 || statuses[0].Dropped != 0 {
+		t.Errorf("Expected a connected sink that published 2 events, got %+v", statuses)
+	}
+}
+
+func TestExporter_BuffersAndDropsWhileUnreachable(t *testing.T) {
+	var mu sync.Mutex
+	available := false
+	var records []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !available {
+			http.Error(w, "broker unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path != "/topics/llamactl.lifecycle" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var body struct {
+			Records []struct {
+				Key   string       `json:"key"`
+				Value events.Event `json:"value"`
+			} `json:"records"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, record := range body.Records {
+			records = append(records, record.Key+":"+record.Value.Code)
+		}
+		w.Header().Set("Content-Type", "application/vnd.kafka.v2+json")
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":1}]}`))
+	}))
+	defer proxy.Close()
+
+	exporter, err := sinks.NewExporter([]config.SinkConfig{{
+		Name:       "kafka",
+		Type:       config.SinkKafka,
+		URL:        proxy.URL,
+		Topics:     map[string]string{config.EventClassLifecycle: "llamactl.lifecycle"},
+		BufferSize: 2,
+	}})
+	if err != nil {
+		t.Fatalf("NewExporter failed: %v", err)
+	}
+	defer exporter.Close()
+
+	// The first event is taken for delivery and retried, two more fill the buffer
+	for i := range 5 {
+		exporter.Export(events.Event{Type: events.TypeStatusChange, Instance: "llama", Code: strconv.Itoa(i)})
+		if i == 0 {
+			waitFor(t, "the first delivery attempt", func() bool { return exporter.Status()[0].LastError != "" })
+		}
+	}
+
+	status := exporter.Status()[0]
+	if status.Connected || status.Dropped != 2 || status.Buffered != 3 || !strings.Contains(status.LastError, "503") {
+		t.Errorf("Expected 3 buffered and 2 dropped events while the broker is down, got %+v", status)
+	}
+
+	mu.Lock()
+	available = true
+	mu.Unlock()
+
+	// Redelivered after the retry delay
+	waitFor(t, "the buffered events to be delivered", func() bool { return exporter.Status()[0].Published == 3 })
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(records, ",") != "llama:0,llama:1,llama:2" {
+		t.Errorf("Expected the buffered events in order, got %v", records)
+	}
+}
+
+func TestNewExporter_InvalidURL(t *testing.T) {
+	if _, err := sinks.NewExporter([]config.SinkConfig{{Name: "nats", Type: config.SinkNATS, URL: "http://localhost:4222"}}); err == nil {
+		t.Error("Expected a NATS sink with an http URL to be rejected")
+	}
+	if _, err := sinks.NewExporter([]config.SinkConfig{{Name: "kafka", Type: config.SinkKafka, URL: "kafka:9092"}}); err == nil {
+		t.Error("Expected a Kafka sink without a REST proxy URL to be rejected")
+	}
+}
@@ -0,0 +1,297 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const auditFileName = "audit.jsonl"
+
+// Instance definitions are kept along with their previous version, <name>.json.bak, and
+// those found empty or invalid are moved to the .corrupt directory of the instances directory
+const (
+	backupSuffix   = ".bak"
+	corruptDirName = ".corrupt"
+)
+
+// FileStore keeps each record in its own JSON file. Instance definitions live in the
+// instances directory, as <name>.json; other namespaces get a directory of their own
+// under the data directory, and the audit log is a JSON lines file next to them.
+type FileStore struct {
+	instancesDir string
+	dataDir      string
+
+	auditMu sync.Mutex
+
+	corruptMu sync.Mutex
+	corrupt   []CorruptRecord
+}
+
+// NewFileStore creates a file store. If dataDir is empty, the parent of instancesDir is used.
+func NewFileStore(instancesDir, dataDir string) *FileStore {
+	if dataDir == "" {
+		dataDir = filepath.Dir(instancesDir)
+	}
+	return &FileStore{instancesDir: instancesDir, dataDir: dataDir}
+}
+
+func (s *FileStore) dir(ns Namespace) string {
+	if ns == NamespaceInstances {
+		return s.instancesDir
+	}
+	return filepath.Join(s.dataDir, string(ns))
+}
+
+func (s *FileStore) path(ns Namespace, key string) (string, error) {
+	if err := validateRecord(ns, key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir(ns), key+".json"), nil
+}
+
+func (s *FileStore) Put(ns Namespace, key string, value []byte) error {
+	path, err := s.path(ns, key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s/%s: %w", ns, key, err)
+	}
+	if ns == NamespaceInstances {
+		// The current definition becomes the one to recover if the new one is lost
+		if previous, err := os.ReadFile(path); err == nil && validDefinition(previous) {
+			if err := writeFileAtomic(path+backupSuffix, previous, filePerm(ns)); err != nil {
+				return fmt.Errorf("failed to back up %s/%s: %w", ns, key, err)
+			}
+		}
+	}
+	return writeFileAtomic(path, value, filePerm(ns))
+}
+
+// filePerm returns the permissions of the files of a namespace
+func filePerm(ns Namespace) os.FileMode {
+	if ns == NamespaceBackendKeys || ns == NamespaceTrash {
+		return 0600 // Secrets, such as the backend keys of trashed instances, are only readable by llamactl's user
+	}
+	return 0644
+}
+
+// validDefinition reports whether data can be an instance definition, which a crash or a
+// power loss may have left empty or cut short
+func validDefinition(data []byte) bool {
+	return len(bytes.TrimSpace(data)) > 0 && json.Valid(data)
+}
+
+// read reads the record stored at path. A corrupt instance definition is set aside and
+// replaced by its previous version if that one is valid, or reported missing otherwise.
+func (s *FileStore) read(ns Namespace, key, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || ns != NamespaceInstances || validDefinition(data) {
+		return data, err
+	}
+
+	dir := filepath.Join(filepath.Dir(path), corruptDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for corrupt %s/%s: %w", ns, key, err)
+	}
+	corruptPath := filepath.Join(dir, fmt.Sprintf("%s.%s.json", key, time.Now().UTC().Format("20060102T150405.000000000Z")))
+	if err := os.Rename(path, corruptPath); err != nil {
+		return nil, fmt.Errorf("failed to set corrupt %s/%s aside: %w", ns, key, err)
+	}
+	if err := syncDir(dir); err != nil {
+		return nil, err
+	}
+	record := CorruptRecord{Namespace: ns, Key: key, Path: corruptPath}
+
+	backup, backupErr := os.ReadFile(path + backupSuffix)
+	if backupErr == nil && validDefinition(backup) {
+		if err := writeFileAtomic(path, backup, filePerm(ns)); err != nil {
+			return nil, fmt.Errorf("failed to recover %s/%s: %w", ns, key, err)
+		}
+		record.Recovered = true
+	} else if err := syncDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	s.corruptMu.Lock()
+	s.corrupt = append(s.corrupt, record)
+	s.corruptMu.Unlock()
+
+	if !record.Recovered {
+		return nil, os.ErrNotExist
+	}
+	return backup, nil
+}
+
+// TakeCorrupt returns the instance definitions set aside since the last call
+func (s *FileStore) TakeCorrupt() []CorruptRecord {
+	s.corruptMu.Lock()
+	defer s.corruptMu.Unlock()
+	records := s.corrupt
+	s.corrupt = nil
+	return records
+}
+
+func (s *FileStore) Get(ns Namespace, key string) ([]byte, error) {
+	path, err := s.path(ns, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.read(ns, key, path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", ns, key, err)
+	}
+	return data, nil
+}
+
+func (s *FileStore) Delete(ns Namespace, key string) error {
+	path, err := s.path(ns, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s/%s: %w", ns, key, err)
+	}
+	if err := os.Remove(path + backupSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete the backup of %s/%s: %w", ns, key, err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func (s *FileStore) List(ns Namespace) (map[string][]byte, error) {
+	if err := validateNamespace(ns); err != nil {
+		return nil, err
+	}
+
+	records := make(map[string][]byte)
+	files, err := os.ReadDir(s.dir(ns))
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s directory: %w", ns, err)
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		key := strings.TrimSuffix(file.Name(), ".json")
+		data, err := s.read(ns, key, filepath.Join(s.dir(ns), file.Name()))
+		if os.IsNotExist(err) {
+			continue // Corrupt without a previous version to recover
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s/%s: %w", ns, key, err)
+		}
+		records[key] = data
+	}
+	return records, nil
+}
+
+func (s *FileStore) AppendAudit(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	if err := os.MkdirAll(s.dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(s.dataDir, auditFileName), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	// Terminate a torn line left by a crash so that it does not swallow this record
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return f.Sync()
+}
+
+func (s *FileStore) ListAudit(limit int) ([]AuditRecord, error) {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	f, err := os.Open(filepath.Join(s.dataDir, auditFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record AuditRecord
+		// A crash during an append can leave a torn last line behind, which is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return lastAudit(records, limit), nil
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
+
+// writeFileAtomic replaces path with data so that a crash leaves either the old or the
+// new contents: the data is synced to a temporary file that is then renamed over path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tempPath := path + ".tmp"
+
+	f, err := os.OpenFile(tempPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return syncDir(filepath.Dir(path))
+}
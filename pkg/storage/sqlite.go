@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure Go SQLite driver, no cgo
+)
+
+// sqliteMigrations upgrade the schema one version at a time; the index of a migration
+// plus one is the schema version it produces, tracked in PRAGMA user_version.
+var sqliteMigrations = []string{
+	`CREATE TABLE records (
+		namespace  TEXT    NOT NULL,
+		key        TEXT    NOT NULL,
+		value      BLOB    NOT NULL,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (namespace, key)
+	);
+	CREATE TABLE audit (
+		id      INTEGER PRIMARY KEY AUTOINCREMENT,
+		time    INTEGER NOT NULL,
+		actor   TEXT    NOT NULL DEFAULT '',
+		action  TEXT    NOT NULL,
+		target  TEXT    NOT NULL DEFAULT '',
+		details TEXT    NOT NULL DEFAULT ''
+	);`,
+}
+
+// SQLiteStore keeps records and the audit log in a single SQLite database. The database
+// runs in WAL mode with full synchronous commits, so every write is a durable transaction.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens, creating if needed, the SQLite database at path and migrates its schema
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("SQLite database path cannot be empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for SQLite database: %w", err)
+	}
+
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=journal_mode(WAL)&_pragma=synchronous(FULL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(ON)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	// A single connection serializes writers without relying on SQLite busy retries
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLiteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, len(sqliteMigrations))
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration: %w", err)
+		}
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to migrate schema to version %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record schema version %d: %w", version+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration to version %d: %w", version+1, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Put(ns Namespace, key string, value []byte) error {
+	if err := validateRecord(ns, key); err != nil {
+		return err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO records (namespace, key, value, updated_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (namespace, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		string(ns), key, value, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to write %s/%s: %w", ns, key, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Get(ns Namespace, key string) ([]byte, error) {
+	if err := validateRecord(ns, key); err != nil {
+		return nil, err
+	}
+	var value []byte
+	err := s.db.QueryRow(`SELECT value FROM records WHERE namespace = ? AND key = ?`, string(ns), key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s/%s: %w", ns, key, err)
+	}
+	return value, nil
+}
+
+func (s *SQLiteStore) Delete(ns Namespace, key string) error {
+	if err := validateRecord(ns, key); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM records WHERE namespace = ? AND key = ?`, string(ns), key); err != nil {
+		return fmt.Errorf("failed to delete %s/%s: %w", ns, key, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) List(ns Namespace) (map[string][]byte, error) {
+	if err := validateNamespace(ns); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT key, value FROM records WHERE namespace = ?`, string(ns))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", ns, err)
+	}
+	defer rows.Close()
+
+	records := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to read %s record: %w", ns, err)
+		}
+		records[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", ns, err)
+	}
+	return records, nil
+}
+
+func (s *SQLiteStore) AppendAudit(record AuditRecord) error {
+	_, err := s.db.Exec(`INSERT INTO audit (time, actor, action, target, details) VALUES (?, ?, ?, ?, ?)`,
+		record.Time.UnixNano(), record.Actor, record.Action, record.Target, record.Details)
+	if err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListAudit(limit int) ([]AuditRecord, error) {
+	query := `SELECT time, actor, action, target, details FROM audit ORDER BY id`
+	var args []any
+	if limit > 0 {
+		query = `SELECT time, actor, action, target, details FROM
+			(SELECT id, time, actor, action, target, details FROM audit ORDER BY id DESC LIMIT ?) ORDER BY id`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []AuditRecord
+	for rows.Next() {
+		var record AuditRecord
+		var nanos int64
+		if err := rows.Scan(&nanos, &record.Actor, &record.Action, &record.Target, &record.Details); err != nil {
+			return nil, fmt.Errorf("failed to read audit record: %w", err)
+		}
+		record.Time = time.Unix(0, nanos)
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list audit records: %w", err)
+	}
+	return records, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,140 @@
+// Package storage persists instance definitions and other llamactl state behind a
+// backend-agnostic interface, with a file implementation and a SQLite implementation.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"llamactl/pkg/config"
+	"strings"
+	"time"
+)
+
+// Namespace groups records of one kind
+type Namespace string
+
+const (
+	// NamespaceInstances holds instance definitions, keyed by instance name
+	NamespaceInstances Namespace = "instances"
+	// NamespaceDesiredState holds the state instances should be reconciled to
+	NamespaceDesiredState Namespace = "desired_state"
+	// NamespaceStats holds stats persisted across restarts
+	NamespaceStats Namespace = "stats"
+	// NamespaceIdempotency holds idempotency keys of API requests
+	NamespaceIdempotency Namespace = "idempotency_keys"
+	// NamespaceKeyUsage holds the quota counters of API keys, keyed by key ID
+	NamespaceKeyUsage Namespace = "key_usage"
+	// NamespaceBackendKeys holds the managed API keys of instance backends, keyed by instance name
+	NamespaceBackendKeys Namespace = "backend_keys"
+	// NamespaceAutoRestartPause holds the pause of the automatic restarts of every instance
+	NamespaceAutoRestartPause Namespace = "autorestart_pause"
+	// NamespaceTrash holds deleted instances until they are purged, keyed by instance name
+	NamespaceTrash Namespace = "trash"
+	// NamespaceRoutes holds the routing weights of services set through the API, keyed by
+	// service alias
+	NamespaceRoutes Namespace = "routes"
+)
+
+// Namespaces lists every namespace, in the order they are exported
+var Namespaces = []Namespace{NamespaceInstances, NamespaceDesiredState, NamespaceStats, NamespaceIdempotency, NamespaceKeyUsage, NamespaceBackendKeys, NamespaceAutoRestartPause, NamespaceTrash, NamespaceRoutes}
+
+// Storage backends
+const (
+	BackendFile   = "file"
+	BackendSQLite = "sqlite"
+)
+
+// ErrNotFound is returned by Get when a record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// AuditRecord is a single entry of the audit log
+type AuditRecord struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor,omitempty"`
+	Action  string    `json:"action"`
+	Target  string    `json:"target,omitempty"`
+	Details string    `json:"details,omitempty"`
+}
+
+// CorruptRecord is a record found empty or unreadable, moved aside instead of being loaded
+type CorruptRecord struct {
+	Namespace Namespace `json:"namespace"`
+	Key       string    `json:"key"`
+	Path      string    `json:"path"`      // Where the corrupt record was moved to
+	Recovered bool      `json:"recovered"` // Replaced by the previous version of the record
+}
+
+// CorruptionReporter is implemented by stores that set corrupt records aside when reading them
+type CorruptionReporter interface {
+	// TakeCorrupt returns the records set aside since the last call
+	TakeCorrupt() []CorruptRecord
+}
+
+// Store persists keyed records and an append-only audit log. Writes are durable once they
+// return: a crash never leaves a partially written record behind. Instance definitions are
+// JSON documents; the other records are opaque.
+type Store interface {
+	// Put creates or replaces a record
+	Put(ns Namespace, key string, value []byte) error
+	// Get returns a record, or ErrNotFound
+	Get(ns Namespace, key string) ([]byte, error)
+	// Delete removes a record; deleting a missing record is not an error
+	Delete(ns Namespace, key string) error
+	// List returns all records of a namespace
+	List(ns Namespace) (map[string][]byte, error)
+
+	// AppendAudit adds a record to the audit log
+	AppendAudit(record AuditRecord) error
+	// ListAudit returns the last limit audit records, oldest first (limit <= 0 returns all)
+	ListAudit(limit int) ([]AuditRecord, error)
+
+	Close() error
+}
+
+// Open opens the store selected in the configuration
+func Open(storageConfig config.StorageConfig, instancesConfig config.InstancesConfig) (Store, error) {
+	switch storageConfig.Backend {
+	case "", BackendFile:
+		return NewFileStore(instancesConfig.InstancesDir, instancesConfig.DataDir), nil
+	case BackendSQLite:
+		return OpenSQLiteStore(storageConfig.Path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q: must be %s or %s", storageConfig.Backend, BackendFile, BackendSQLite)
+	}
+}
+
+// validateKey rejects keys that cannot be stored safely by every backend
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("record key cannot be empty")
+	}
+	if strings.ContainsAny(key, `/\`) || key == "." || key == ".." || strings.ContainsRune(key, 0) {
+		return fmt.Errorf("invalid record key %q", key)
+	}
+	return nil
+}
+
+// validateRecord validates the namespace and key of a record
+func validateRecord(ns Namespace, key string) error {
+	if err := validateNamespace(ns); err != nil {
+		return err
+	}
+	return validateKey(key)
+}
+
+func validateNamespace(ns Namespace) error {
+	for _, known := range Namespaces {
+		if ns == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown namespace %q", ns)
+}
+
+// lastAudit returns the last limit records (limit <= 0 returns all)
+func lastAudit(records []AuditRecord, limit int) []AuditRecord {
+	if limit > 0 && len(records) > limit {
+		return records[len(records)-limit:]
+	}
+	return records
+}
@@ -0,0 +1,361 @@
+package storage_test
+
+import (
+	"bytes"
+	"errors"
+	"llamactl/pkg/storage"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openFunc opens a store kept in dir; opening the same dir again must see the same data
+type openFunc func(t *testing.T, dir string) storage.Store
+
+var backends = map[string]openFunc{
+	storage.BackendFile: func(t *testing.T, dir string) storage.Store {
+		return storage.NewFileStore(filepath.Join(dir, "instances"), dir)
+	},
+	storage.BackendSQLite: func(t *testing.T, dir string) storage.Store {
+		s, err := storage.OpenSQLiteStore(filepath.Join(dir, "llamactl.db"))
+		if err != nil {
+			t.Fatalf("OpenSQLiteStore failed: %v", err)
+		}
+		return s
+	},
+}
+
+// TestConformance runs the same suite against every storage backend
+func TestConformance(t *testing.T) {
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Records", func(t *testing.T) { testRecords(t, open) })
+			t.Run("Namespaces", func(t *testing.T) { testNamespaces(t, open) })
+			t.Run("InvalidKeys", func(t *testing.T) { testInvalidKeys(t, open) })
+			t.Run("Audit", func(t *testing.T) { testAudit(t, open) })
+			t.Run("Reopen", func(t *testing.T) { testReopen(t, open) })
+		})
+	}
+}
+
+func openStore(t *testing.T, open openFunc, dir string) storage.Store {
+	t.Helper()
+	s := open(t, dir)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func testRecords(t *testing.T, open openFunc) {
+	s := openStore(t, open, t.TempDir())
+
+	if _, err := s.Get(storage.NamespaceInstances, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing record, got %v", err)
+	}
+
+	if err := s.Put(storage.NamespaceInstances, "llama", []byte(`{"name":"llama"}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Put(storage.NamespaceInstances, "llama", []byte(`{"name":"llama","v":2}`)); err != nil {
+		t.Fatalf("Put (overwrite) failed: %v", err)
+	}
+
+	got, err := s.Get(storage.NamespaceInstances, "llama")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(got) != `{"name":"llama","v":2}` {
+		t.Errorf("Expected the overwritten value, got %s", got)
+	}
+
+	if err := s.Delete(storage.NamespaceInstances, "llama"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Get(storage.NamespaceInstances, "llama"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after Delete, got %v", err)
+	}
+	if err := s.Delete(storage.NamespaceInstances, "llama"); err != nil {
+		t.Errorf("Expected deleting a missing record to succeed, got %v", err)
+	}
+}
+
+func testNamespaces(t *testing.T, open openFunc) {
+	s := openStore(t, open, t.TempDir())
+
+	// Values are JSON, like the instance definitions
+	value := func(ns storage.Namespace) string { return `"` + string(ns) + `"` }
+	for _, ns := range storage.Namespaces {
+		if err := s.Put(ns, "shared-key", []byte(value(ns))); err != nil {
+			t.Fatalf("Put to %s failed: %v", ns, err)
+		}
+	}
+	if err := s.Put(storage.NamespaceStats, "other", []byte("x")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	for _, ns := range storage.Namespaces {
+		records, err := s.List(ns)
+		if err != nil {
+			t.Fatalf("List %s failed: %v", ns, err)
+		}
+		if string(records["shared-key"]) != value(ns) {
+			t.Errorf("Expected %s to keep its own value, got %q", ns, records["shared-key"])
+		}
+		wantLen := 1
+		if ns == storage.NamespaceStats {
+			wantLen = 2
+		}
+		if len(records) != wantLen {
+			t.Errorf("Expected %d records in %s, got %d", wantLen, ns, len(records))
+		}
+	}
+
+	if err := s.Put("unknown", "key", []byte("x")); err == nil {
+		t.Error("Expected an error for an unknown namespace")
+	}
+}
+
+func testInvalidKeys(t *testing.T, open openFunc) {
+	s := openStore(t, open, t.TempDir())
+
+	for _, key := range []string{"", ".", "..", "../escape", "a/b", `a\b`} {
+		if err := s.Put(storage.NamespaceInstances, key, []byte("x")); err == nil {
+			t.Errorf("Expected an error for key %q", key)
+		}
+	}
+}
+
+func testAudit(t *testing.T, open openFunc) {
+	s := openStore(t, open, t.TempDir())
+
+	records, err := s.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit on an empty store failed: %v", err)
+	}
+	if len(records) != 0 {
+		t.Errorf("Expected no audit records, got %d", len(records))
+	}
+
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	for i, action := range []string{"create", "start", "stop", "delete"} {
+		record := storage.AuditRecord{Time: base.Add(time.Duration(i) * time.Second), Actor: "admin", Action: action, Target: "llama"}
+		if err := s.AppendAudit(record); err != nil {
+			t.Fatalf("AppendAudit failed: %v", err)
+		}
+	}
+
+	all, err := s.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit failed: %v", err)
+	}
+	if len(all) != 4 || all[0].Action != "create" || all[3].Action != "delete" {
+		t.Fatalf("Expected all 4 records oldest first, got %+v", all)
+	}
+	if !all[1].Time.Equal(base.Add(time.Second)) || all[1].Actor != "admin" || all[1].Target != "llama" {
+		t.Errorf("Expected fields to round-trip, got %+v", all[1])
+	}
+
+	last, err := s.ListAudit(2)
+	if err != nil {
+		t.Fatalf("ListAudit with limit failed: %v", err)
+	}
+	if len(last) != 2 || last[0].Action != "stop" || last[1].Action != "delete" {
+		t.Errorf("Expected the last 2 records oldest first, got %+v", last)
+	}
+}
+
+func testReopen(t *testing.T, open openFunc) {
+	dir := t.TempDir()
+
+	s := open(t, dir)
+	if err := s.Put(storage.NamespaceDesiredState, "llama", []byte("running")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.AppendAudit(storage.AuditRecord{Time: time.Now(), Action: "create"}); err != nil {
+		t.Fatalf("AppendAudit failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s = openStore(t, open, dir)
+	got, err := s.Get(storage.NamespaceDesiredState, "llama")
+	if err != nil || string(got) != "running" {
+		t.Errorf("Expected the record to survive reopening, got %q, %v", got, err)
+	}
+	records, err := s.ListAudit(0)
+	if err != nil || len(records) != 1 {
+		t.Errorf("Expected the audit record to survive reopening, got %d, %v", len(records), err)
+	}
+}
+
+func TestExportImport_BetweenBackends(t *testing.T) {
+	source := openStore(t, backends[storage.BackendFile], t.TempDir())
+	if err := source.Put(storage.NamespaceInstances, "llama", []byte(`{"name":"llama"}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := source.Put(storage.NamespaceIdempotency, "req-1", []byte{0, 1, 2}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := source.AppendAudit(storage.AuditRecord{Time: time.Unix(1705312200, 0), Action: "create", Target: "llama"}); err != nil {
+		t.Fatalf("AppendAudit failed: %v", err)
+	}
+
+	var dump bytes.Buffer
+	if err := storage.Export(source, &dump); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	target := openStore(t, backends[storage.BackendSQLite], t.TempDir())
+	if err := storage.Import(target, &dump); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	for _, ns := range storage.Namespaces {
+		want, _ := source.List(ns)
+		got, err := target.List(ns)
+		if err != nil {
+			t.Fatalf("List %s failed: %v", ns, err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("Expected %s records %v, got %v", ns, want, got)
+		}
+	}
+
+	audit, err := target.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit failed: %v", err)
+	}
+	if len(audit) != 1 || audit[0].Action != "create" || !audit[0].Time.Equal(time.Unix(1705312200, 0)) {
+		t.Errorf("Expected the audit log to be imported, got %+v", audit)
+	}
+}
+
+func TestFileStore_Layout(t *testing.T) {
+	dir := t.TempDir()
+	instancesDir := filepath.Join(dir, "instances")
+	s := storage.NewFileStore(instancesDir, dir)
+
+	if err := s.Put(storage.NamespaceInstances, "llama", []byte(`{}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(instancesDir, "llama.json")); err != nil {
+		t.Errorf("Expected instance definitions in <instances_dir>/<name>.json: %v", err)
+	}
+
+	// Leftovers of an interrupted write are not records
+	if err := os.WriteFile(filepath.Join(instancesDir, "partial.json.tmp"), []byte(`{`), 0644); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+	records, err := s.List(storage.NamespaceInstances)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected only the complete record, got %v", records)
+	}
+}
+
+func TestFileStore_SkipsTornAuditLine(t *testing.T) {
+	dir := t.TempDir()
+	s := storage.NewFileStore(filepath.Join(dir, "instances"), dir)
+
+	if err := s.AppendAudit(storage.AuditRecord{Time: time.Now(), Action: "create"}); err != nil {
+		t.Fatalf("AppendAudit failed: %v", err)
+	}
+
+	// Simulate a crash in the middle of an append
+	f, err := os.OpenFile(filepath.Join(dir, "audit.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	f.WriteString(`{"time":"2024-01-15T10:30:00Z","act`)
+	f.Close()
+
+	if err := s.AppendAudit(storage.AuditRecord{Time: time.Now(), Action: "start"}); err != nil {
+		t.Fatalf("AppendAudit after a torn line failed: %v", err)
+	}
+
+	records, err := s.ListAudit(0)
+	if err != nil {
+		t.Fatalf("ListAudit failed: %v", err)
+	}
+	if len(records) != 2 || records[1].Action != "start" {
+		t.Errorf("Expected the torn line to be skipped and later records kept, got %+v", records)
+	}
+}
+
+func TestFileStore_CorruptDefinitions(t *testing.T) {
+	dir := t.TempDir()
+	instancesDir := filepath.Join(dir, "instances")
+	s := storage.NewFileStore(instancesDir, dir)
+
+	v1 := []byte(`{"name":"llama","v":1}`)
+	v2 := []byte(`{"name":"llama","v":2}`)
+	for _, data := range [][]byte{v1, v2} {
+		if err := s.Put(storage.NamespaceInstances, "llama", data); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := s.Put(storage.NamespaceInstances, "mistral", []byte(`{"name":"mistral"}`)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// A crash while writing v3 left the temp file cut short, the definition is untouched
+	path := filepath.Join(instancesDir, "llama.json")
+	os.WriteFile(path+".tmp", []byte(`{"name":"llama","v`), 0644)
+	if data, err := s.Get(storage.NamespaceInstances, "llama"); err != nil || !bytes.Equal(data, v2) {
+		t.Fatalf("Expected the last complete definition after an interrupted write, got %s (%v)", data, err)
+	}
+
+	// A power loss truncated the definition itself, the previous version is recovered
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, data[:len(data)/2], 0644)
+	// One without a previous version to recover is skipped
+	os.WriteFile(filepath.Join(instancesDir, "mistral.json"), nil, 0644)
+
+	records, err := s.List(storage.NamespaceInstances)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if !bytes.Equal(records["llama"], v1) {
+		t.Errorf("Expected the previous version to be recovered, got %s", records["llama"])
+	}
+	if _, ok := records["mistral"]; ok || len(records) != 1 {
+		t.Errorf("Expected the empty definition to be skipped, got %v", records)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, v1) {
+		t.Errorf("Expected the recovered definition to be written back, got %s", data)
+	}
+
+	corrupt := s.TakeCorrupt()
+	if len(corrupt) != 2 {
+		t.Fatalf("Expected two corrupt records, got %+v", corrupt)
+	}
+	for _, record := range corrupt {
+		if record.Recovered != (record.Key == "llama") {
+			t.Errorf("Expected only llama to be recovered, got %+v", record)
+		}
+		if filepath.Dir(record.Path) != filepath.Join(instancesDir, ".corrupt") {
+			t.Errorf("Expected the corrupt record in the .corrupt directory, got %s", record.Path)
+		}
+		if _, err := os.Stat(record.Path); err != nil {
+			t.Errorf("Expected the corrupt record to be kept: %v", err)
+		}
+	}
+	if len(s.TakeCorrupt()) != 0 {
+		t.Error("Expected corrupt records to be reported once")
+	}
+	if _, err := s.Get(storage.NamespaceInstances, "mistral"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("Expected the skipped record to be gone, got %v", err)
+	}
+
+	// Deleting a definition deletes its previous version too
+	if err := s.Delete(storage.NamespaceInstances, "llama"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := os.Stat(path + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("Expected the backup to be deleted, got %v", err)
+	}
+}
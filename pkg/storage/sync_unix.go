@@ -0,0 +1,24 @@
+//go:build !windows
+
+package storage
+
+import (
+	"fmt"
+	"os"
+)
+
+// syncDir flushes a directory so that renames and removals in it survive a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
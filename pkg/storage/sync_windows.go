@@ -0,0 +1,8 @@
+//go:build windows
+
+package storage
+
+// syncDir is a no-op on Windows, where directories cannot be opened for syncing
+func syncDir(dir string) error {
+	return nil
+}
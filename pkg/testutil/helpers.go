@@ -1,5 +1,10 @@
 package testutil
 
+import (
+	"llamactl/pkg/config"
+	"time"
+)
+
 // Helper functions for pointer fields
 func BoolPtr(b bool) *bool {
 	return &b
@@ -8,3 +13,8 @@ func BoolPtr(b bool) *bool {
 func IntPtr(i int) *int {
 	return &i
 }
+
+func DurationPtr(d time.Duration) *config.Duration {
+	cd := config.Duration(d)
+	return &cd
+}
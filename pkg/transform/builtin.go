@@ -0,0 +1,201 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"maps"
+)
+
+// Transforms shipped with llamactl
+const (
+	SystemPromptPrefix = "system_prompt_prefix"
+	StopSequences      = "stop_sequences"
+	Metadata           = "metadata"
+)
+
+func init() {
+	Register(SystemPromptPrefix, newSystemPromptPrefix)
+	Register(StopSequences, newStopSequences)
+	Register(Metadata, newMetadata)
+}
+
+// systemPromptPrefix prepends a prefix to the system prompt of chat requests, adding a
+// system message when there is none, and to the prompt of completion requests
+type systemPromptPrefix struct {
+	prefix string
+}
+
+func newSystemPromptPrefix(params map[string]any) (Transformer, error) {
+	prefix, err := stringParam(params, "prefix")
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "" {
+		return nil, fmt.Errorf("%s requires a prefix", SystemPromptPrefix)
+	}
+	return &systemPromptPrefix{prefix: prefix}, nil
+}
+
+func (t *systemPromptPrefix) Request(_ context.Context, body map[string]any) error {
+	if prompt, ok := body["prompt"].(string); ok {
+		body["prompt"] = t.prefix + prompt
+	}
+
+	messages, ok := body["messages"].([]any)
+	if !ok {
+		return nil
+	}
+	if len(messages) > 0 {
+		if first, ok := messages[0].(map[string]any); ok && first["role"] == "system" {
+			if content, ok := first["content"].(string); ok {
+				first["content"] = t.prefix + content
+				return nil
+			}
+		}
+	}
+	system := map[string]any{"role": "system", "content": t.prefix}
+	body["messages"] = append([]any{system}, messages...)
+	return nil
+}
+
+func (t *systemPromptPrefix) Response(context.Context, map[string]any) error {
+	return nil
+}
+
+// stopSequences replaces the stop sequences of requests with stop, when set, and then adds
+// the sequences of add that are missing
+type stopSequences struct {
+	stop []string
+	add  []string
+}
+
+func newStopSequences(params map[string]any) (Transformer, error) {
+	stop, err := stringsParam(params, "stop")
+	if err != nil {
+		return nil, err
+	}
+	add, err := stringsParam(params, "add")
+	if err != nil {
+		return nil, err
+	}
+	if stop == nil && add == nil {
+		return nil, fmt.Errorf("%s requires stop or add", StopSequences)
+	}
+	return &stopSequences{stop: stop, add: add}, nil
+}
+
+func (t *stopSequences) Request(_ context.Context, body map[string]any) error {
+	var sequences []any
+	switch stop := body["stop"].(type) {
+	case string:
+		sequences = []any{stop}
+	case []any:
+		sequences = stop
+	}
+	if t.stop != nil {
+		sequences = make([]any, 0, len(t.stop)+len(t.add))
+		for _, s := range t.stop {
+			sequences = append(sequences, s)
+		}
+	}
+
+	present := make(map[any]bool, len(sequences))
+	for _, s := range sequences {
+		present[s] = true
+	}
+	for _, s := range t.add {
+		if !present[s] {
+			sequences = append(sequences, s)
+			present[s] = true
+		}
+	}
+
+	if len(sequences) == 0 {
+		delete(body, "stop")
+	} else {
+		body["stop"] = sequences
+	}
+	return nil
+}
+
+func (t *stopSequences) Response(context.Context, map[string]any) error {
+	return nil
+}
+
+// metadata sets fields of requests and responses, overriding the values already there
+type metadata struct {
+	request  map[string]any
+	response map[string]any
+}
+
+func newMetadata(params map[string]any) (Transformer, error) {
+	request, err := objectParam(params, "request")
+	if err != nil {
+		return nil, err
+	}
+	response, err := objectParam(params, "response")
+	if err != nil {
+		return nil, err
+	}
+	if len(request) == 0 && len(response) == 0 {
+		return nil, fmt.Errorf("%s requires request or response fields", Metadata)
+	}
+	return &metadata{request: request, response: response}, nil
+}
+
+func (t *metadata) Request(_ context.Context, body map[string]any) error {
+	maps.Copy(body, t.request)
+	return nil
+}
+
+func (t *metadata) Response(_ context.Context, body map[string]any) error {
+	maps.Copy(body, t.response)
+	return nil
+}
+
+func stringParam(params map[string]any, key string) (string, error) {
+	value, ok := params[key]
+	if !ok {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("param %s must be a string", key)
+	}
+	return s, nil
+}
+
+// stringsParam returns a list of strings, nil when the param is not set
+func stringsParam(params map[string]any, key string) ([]string, error) {
+	value, ok := params[key]
+	if !ok {
+		return nil, nil
+	}
+	switch v := value.(type) {
+	case []string:
+		return v, nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("param %s must be a list of strings", key)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("param %s must be a list of strings", key)
+}
+
+func objectParam(params map[string]any, key string) (map[string]any, error) {
+	value, ok := params[key]
+	if !ok {
+		return nil, nil
+	}
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("param %s must be an object", key)
+	}
+	return obj, nil
+}
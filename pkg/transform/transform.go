@@ -0,0 +1,121 @@
+// Package transform rewrites the OpenAI requests proxied to an instance and their
+// non-streamed responses. Transforms are compiled in: a package registers a Factory under
+// a name from its init function, and instances select a transform by that name.
+package transform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Transformer rewrites the decoded JSON bodies of the requests and responses of an instance.
+// Each call gets its own copy of the body, and should return once ctx is done: a call that
+// runs past its time budget is abandoned and its changes discarded.
+type Transformer interface {
+	// Request rewrites a request before it is forwarded to the backend
+	Request(ctx context.Context, body map[string]any) error
+	// Response rewrites a non-streamed response before it is sent to the client
+	Response(ctx context.Context, body map[string]any) error
+}
+
+// Factory creates a transformer from the params configured on an instance. It is called
+// for every proxied request, so it should not do more than check and keep its params.
+type Factory func(params map[string]any) (Transformer, error)
+
+var (
+	// ErrUnknown is returned for names no transform is registered under
+	ErrUnknown = errors.New("unknown transform")
+
+	// ErrTimeout is returned by Apply when a transform runs past its time budget
+	ErrTimeout = errors.New("transform exceeded its time budget")
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a transform available under name. It panics when the name is taken.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("transform %s registered twice", name))
+	}
+	registry[name] = factory
+}
+
+// New creates the transform registered under name with the given params
+func New(name string, params map[string]any) (Transformer, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q: registered transforms are %v", ErrUnknown, name, Names())
+	}
+	return factory(params)
+}
+
+// Names returns the names of the registered transforms, sorted
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Apply runs stage, a Request or Response method, on a decoded copy of the JSON object in
+// body and returns the re-encoded result. The stage runs in its own goroutine and gets at
+// most budget: past it, or when it panics, Apply returns an error and the body is not used.
+func Apply(ctx context.Context, budget time.Duration, body []byte, stage func(context.Context, map[string]any) error) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // Keep numbers exactly as the client or backend sent them
+
+	var obj map[string]any
+	if err := decoder.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("body is not a JSON object: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("transform panicked: %v", r)
+			}
+		}()
+		done <- stage(ctx, obj)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w of %s", ErrTimeout, budget)
+		}
+		return nil, ctx.Err()
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(obj); err != nil {
+		return nil, fmt.Errorf("failed to encode transformed body: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
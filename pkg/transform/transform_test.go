@@ -0,0 +1,154 @@
+package transform_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"llamactl/pkg/transform"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// apply runs the request stage of the named transform on body
+func apply(t *testing.T, name string, params map[string]any, body string) map[string]any {
+	t.Helper()
+	tr, err := transform.New(name, params)
+	if err != nil {
+		t.Fatalf("New(%s) failed: %v", name, err)
+	}
+	out, err := transform.Apply(context.Background(), time.Second, []byte(body), tr.Request)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(out, &obj); err != nil {
+		t.Fatalf("Transformed body is not JSON: %v", err)
+	}
+	return obj
+}
+
+func TestBuiltinTransforms(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform string
+		params    map[string]any
+		body      string
+		field     string
+		expected  any
+	}{
+		{
+			name:      "prefix existing system prompt",
+			transform: transform.SystemPromptPrefix,
+			params:    map[string]any{"prefix": "Be brief. "},
+			body:      `{"messages":[{"role":"system","content":"You help."},{"role":"user","content":"Hi"}]}`,
+			field:     "messages",
+			expected:  []any{map[string]any{"role": "system", "content": "Be brief. You help."}, map[string]any{"role": "user", "content": "Hi"}},
+		},
+		{
+			name:      "add system prompt",
+			transform: transform.SystemPromptPrefix,
+			params:    map[string]any{"prefix": "Be brief."},
+			body:      `{"messages":[{"role":"user","content":"Hi"}]}`,
+			field:     "messages",
+			expected:  []any{map[string]any{"role": "system", "content": "Be brief."}, map[string]any{"role": "user", "content": "Hi"}},
+		},
+		{
+			name:      "prefix completion prompt",
+			transform: transform.SystemPromptPrefix,
+			params:    map[string]any{"prefix": "Q: "},
+			body:      `{"prompt":"why?"}`,
+			field:     "prompt",
+			expected:  "Q: why?",
+		},
+		{
+			name:      "add stop sequences",
+			transform: transform.StopSequences,
+			params:    map[string]any{"add": []any{"</s>", "###"}},
+			body:      `{"stop":"###"}`,
+			field:     "stop",
+			expected:  []any{"###", "</s>"},
+		},
+		{
+			name:      "replace stop sequences",
+			transform: transform.StopSequences,
+			params:    map[string]any{"stop": []any{"\n\n"}},
+			body:      `{"stop":["a","b"]}`,
+			field:     "stop",
+			expected:  []any{"\n\n"},
+		},
+		{
+			name:      "metadata",
+			transform: transform.Metadata,
+			params:    map[string]any{"request": map[string]any{"user": "team-a"}},
+			body:      `{"user":"someone"}`,
+			field:     "user",
+			expected:  "team-a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := apply(t, tt.transform, tt.params, tt.body)
+			if !reflect.DeepEqual(obj[tt.field], tt.expected) {
+				t.Errorf("Expected %s to be %v, got %v", tt.field, tt.expected, obj[tt.field])
+			}
+		})
+	}
+}
+
+func TestNew_InvalidParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform string
+		params    map[string]any
+	}{
+		{"missing prefix", transform.SystemPromptPrefix, nil},
+		{"prefix not a string", transform.SystemPromptPrefix, map[string]any{"prefix": 1}},
+		{"no stop sequences", transform.StopSequences, map[string]any{}},
+		{"stop sequences not strings", transform.StopSequences, map[string]any{"add": []any{1}}},
+		{"no metadata", transform.Metadata, map[string]any{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := transform.New(tt.transform, tt.params); err == nil {
+				t.Error("Expected New to reject the params")
+			}
+		})
+	}
+
+	if _, err := transform.New("nope", nil); !errors.Is(err, transform.ErrUnknown) {
+		t.Errorf("Expected ErrUnknown, got %v", err)
+	}
+}
+
+func TestApply_Sandbox(t *testing.T) {
+	body := []byte(`{"n":12345678901234567890}`)
+
+	out, err := transform.Apply(context.Background(), time.Second, body, func(context.Context, map[string]any) error { return nil })
+	if err != nil || string(out) != string(body) {
+		t.Errorf("Expected numbers to round-trip exactly, got %s (%v)", out, err)
+	}
+
+	slow := func(ctx context.Context, _ map[string]any) error {
+		time.Sleep(time.Second)
+		return nil
+	}
+	start := time.Now()
+	if _, err := transform.Apply(context.Background(), 20*time.Millisecond, body, slow); !errors.Is(err, transform.ErrTimeout) {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected Apply to give up after its budget, took %s", elapsed)
+	}
+
+	panics := func(context.Context, map[string]any) error { panic("boom") }
+	if _, err := transform.Apply(context.Background(), time.Second, body, panics); err == nil {
+		t.Error("Expected a panicking transform to fail")
+	}
+
+	if _, err := transform.Apply(context.Background(), time.Second, []byte(`[1]`), slow); err == nil {
+		t.Error("Expected a body that is not an object to be rejected")
+	}
+}
@@ -0,0 +1,97 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"llamactl/pkg/instance"
+	"strings"
+)
+
+// Constraints a field can fail
+const (
+	ConstraintRequired      = "required"       // The field must be set
+	ConstraintRange         = "range"          // The value is outside the accepted range
+	ConstraintFormat        = "format"         // The value is not in the expected format
+	ConstraintMaxLength     = "max_length"     // The value is too long
+	ConstraintOneOf         = "one_of"         // The value is not one of the accepted values
+	ConstraintSafeChars     = "safe_chars"     // The value contains shell metacharacters or control characters
+	ConstraintConflict      = "conflict"       // The value conflicts with another field
+	ConstraintNotAllowed    = "not_allowed"    // The field cannot be set in this configuration
+	ConstraintFileExists    = "file_exists"    // The file the value names is not accessible
+	ConstraintCommandExists = "command_exists" // The command the value names is not found
+)
+
+// FieldError describes why the value of a single field is invalid
+type FieldError struct {
+	Field      string `json:"field"` // JSON path in the instance options, e.g. backend_options.ctx_size
+	Value      any    `json:"value,omitempty"`
+	Constraint string `json:"constraint"`
+	Message    string `json:"message"`
+}
+
+// ValidationError lists the fields of an instance definition that failed validation.
+// It is returned wrapped or unwrapped, so callers detect it with errors.As.
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+// Error joins the messages of the invalid fields
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add records an invalid field
+func (e *ValidationError) add(field string, value any, constraint, format string, args ...any) {
+	e.Errors = append(e.Errors, FieldError{
+		Field:      field,
+		Value:      value,
+		Constraint: constraint,
+		Message:    fmt.Sprintf(format, args...),
+	})
+}
+
+// err returns the validation error, or nil if no field is invalid
+func (e *ValidationError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// fieldError returns a validation error for a single invalid field
+func fieldError(field string, value any, constraint, format string, args ...any) error {
+	e := &ValidationError{}
+	e.add(field, value, constraint, format, args...)
+	return e
+}
+
+// Join merges validation errors into one listing all their fields. Nil errors are
+// skipped, and an error that is not a validation error is returned as is.
+func Join(errs ...error) error {
+	joined := &ValidationError{}
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			return err
+		}
+		joined.Errors = append(joined.Errors, validationErr.Errors...)
+	}
+	return joined.err()
+}
+
+// DecodeError returns the validation error of instance options that set an option under
+// two of its spellings with different values, or err itself
+func DecodeError(err error) error {
+	var conflict *instance.AliasConflictError
+	if !errors.As(err, &conflict) {
+		return err
+	}
+	return fieldError(conflict.Alias, conflict.Value, ConstraintConflict, "%s", conflict.Error())
+}
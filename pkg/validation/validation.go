@@ -1,13 +1,31 @@
 package validation
 
 import (
+	"errors"
 	"fmt"
 	"llamactl/pkg/backends"
+	"llamactl/pkg/config"
+	"llamactl/pkg/cron"
 	"llamactl/pkg/instance"
+	"llamactl/pkg/transform"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"path"
 	"reflect"
 	"regexp"
+	"slices"
+	"strings"
+	"time"
 )
 
+// Control characters (including newline, tab, null byte, etc.)
+var controlCharsPattern = regexp.MustCompile(`[\x00-\x1F\x7F]`)
+
+// shellControlCharsPattern matches the control characters other than newlines and tabs
+var shellControlCharsPattern = regexp.MustCompile(`[\x00-\x08\x0B-\x1F\x7F]`)
+
 // Simple security validation that focuses only on actual injection risks
 var (
 	// Block shell metacharacters that could enable command injection
@@ -15,20 +33,21 @@ var (
 		regexp.MustCompile(`[;&|$` + "`" + `]`), // Shell metacharacters
 		regexp.MustCompile(`\$\(.*\)`),          // Command substitution $(...)
 		regexp.MustCompile("`.*`"),              // Command substitution backticks
-		regexp.MustCompile(`[\x00-\x1F\x7F]`),   // Control characters (including newline, tab, null byte, etc.)
+		controlCharsPattern,
 	}
 
 	// Simple validation for instance names
 	validNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
-)
 
-type ValidationError error
+	// Hostnames accepted for bind_host and connect_host, IP addresses are parsed separately
+	validHostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.-]*[a-zA-Z0-9])?$`)
+)
 
 // validateStringForInjection checks if a string contains dangerous patterns
 func validateStringForInjection(value string) error {
 	for _, pattern := range dangerousPatterns {
 		if pattern.MatchString(value) {
-			return ValidationError(fmt.Errorf("value contains potentially dangerous characters: %s", value))
+			return fmt.Errorf("value contains potentially dangerous characters: %s", value)
 		}
 	}
 	return nil
@@ -37,7 +56,7 @@ func validateStringForInjection(value string) error {
 // ValidateInstanceOptions performs validation based on backend type
 func ValidateInstanceOptions(options *instance.CreateInstanceOptions) error {
 	if options == nil {
-		return ValidationError(fmt.Errorf("options cannot be nil"))
+		return fieldError("", nil, ConstraintRequired, "options cannot be nil")
 	}
 
 	// Validate based on backend type
@@ -49,75 +68,583 @@ func ValidateInstanceOptions(options *instance.CreateInstanceOptions) error {
 	case backends.BackendTypeVllm:
 		return validateVllmOptions(options)
 	default:
-		return ValidationError(fmt.Errorf("unsupported backend type: %s", options.BackendType))
+		return fieldError("backend_type", string(options.BackendType), ConstraintOneOf, "unsupported backend type: %s", options.BackendType)
+	}
+}
+
+// ValidateBackendTLS validates the backend TLS options of an instance.
+// insecure_skip_verify is only accepted when allowInsecure is set in the configuration.
+func ValidateBackendTLS(options *instance.CreateInstanceOptions, allowInsecure bool) error {
+	if options == nil || options.BackendTLS == nil {
+		return nil
+	}
+	tlsOpts := options.BackendTLS
+	errs := &ValidationError{}
+
+	switch tlsOpts.Scheme {
+	case "", "http", "https":
+	default:
+		return fieldError("backend_tls.scheme", tlsOpts.Scheme, ConstraintOneOf, "invalid backend scheme %q: must be http or https", tlsOpts.Scheme)
+	}
+
+	if tlsOpts.BackendScheme() == "http" && (tlsOpts.CAFile != "" || tlsOpts.CertFile != "" || tlsOpts.KeyFile != "" || tlsOpts.InsecureSkipVerify) {
+		errs.add("backend_tls.scheme", tlsOpts.Scheme, ConstraintConflict, "backend TLS options require the https scheme")
+	}
+
+	if tlsOpts.InsecureSkipVerify && !allowInsecure {
+		errs.add("backend_tls.insecure_skip_verify", true, ConstraintNotAllowed, "insecure_skip_verify is not allowed unless allow_insecure_backends is enabled")
+	}
+
+	if (tlsOpts.CertFile == "") != (tlsOpts.KeyFile == "") {
+		field := "backend_tls.key_file"
+		if tlsOpts.CertFile == "" {
+			field = "backend_tls.cert_file"
+		}
+		errs.add(field, nil, ConstraintRequired, "cert_file and key_file must be set together")
+	}
+
+	files := []struct{ field, path string }{
+		{"backend_tls.ca_file", tlsOpts.CAFile},
+		{"backend_tls.cert_file", tlsOpts.CertFile},
+		{"backend_tls.key_file", tlsOpts.KeyFile},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			errs.add(f.field, f.path, ConstraintFileExists, "backend TLS file is not accessible: %v", err)
+		}
+	}
+
+	return errs.err()
+}
+
+// ValidateLaunchWrapper validates the launch wrapper of an instance. The wrapper is executed
+// directly, not through a shell, so only control characters are rejected; its binary must exist.
+// A wrapper is only accepted when allowCommands is set in the configuration.
+func ValidateLaunchWrapper(options *instance.CreateInstanceOptions, allowCommands bool) error {
+	if options == nil || len(options.LaunchWrapper) == 0 {
+		return nil
+	}
+	if !allowCommands {
+		return commandNotAllowed("launch_wrapper", options.LaunchWrapper)
+	}
+	errs := &ValidationError{}
+
+	for i, arg := range options.LaunchWrapper {
+		if controlCharsPattern.MatchString(arg) {
+			errs.add(fmt.Sprintf("launch_wrapper[%d]", i), arg, ConstraintSafeChars, "launch_wrapper[%d] contains control characters", i)
+		}
+	}
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	binary := options.LaunchWrapper[0]
+	if binary == "" {
+		return fieldError("launch_wrapper[0]", nil, ConstraintRequired, "launch wrapper command cannot be empty")
+	}
+	if _, err := exec.LookPath(binary); err != nil {
+		return fieldError("launch_wrapper[0]", binary, ConstraintCommandExists, "launch wrapper command %q not found: %v", binary, err)
+	}
+
+	return nil
+}
+
+// ValidateLlamaExecutable validates the llama-server executable of an instance. Whether it
+// exists is checked when the instance starts, it may be installed in the meantime. An
+// executable is only accepted when allowCommands is set in the configuration.
+func ValidateLlamaExecutable(options *instance.CreateInstanceOptions, allowCommands bool) error {
+	if options == nil || options.LlamaExecutable == "" {
+		return nil
+	}
+	if !allowCommands {
+		return commandNotAllowed("llama_executable", options.LlamaExecutable)
+	}
+	errs := &ValidationError{}
+
+	if options.BackendType != backends.BackendTypeLlamaCpp {
+		errs.add("llama_executable", options.LlamaExecutable, ConstraintNotAllowed, "llama_executable requires the llama_cpp backend")
+	}
+	if controlCharsPattern.MatchString(options.LlamaExecutable) {
+		errs.add("llama_executable", options.LlamaExecutable, ConstraintSafeChars, "llama_executable contains control characters")
+	}
+
+	return errs.err()
+}
+
+// ValidateLifecycleCommands validates the commands run before an instance starts and after
+// it stops. They run through the shell, so newlines and tabs are allowed but no other
+// control characters. Commands are only accepted when allowCommands is set in the
+// configuration.
+func ValidateLifecycleCommands(options *instance.CreateInstanceOptions, allowCommands bool) error {
+	if options == nil {
+		return nil
+	}
+	errs := &ValidationError{}
+
+	commands := []struct{ field, command string }{
+		{"on_start_cmd", options.OnStartCmd},
+		{"on_stop_cmd", options.OnStopCmd},
+	}
+	for _, c := range commands {
+		if c.command == "" {
+			continue
+		}
+		if !allowCommands {
+			errs.add(c.field, c.command, ConstraintNotAllowed, "%s is not allowed unless allow_instance_commands is enabled", c.field)
+			continue
+		}
+		if shellControlCharsPattern.MatchString(c.command) {
+			errs.add(c.field, c.command, ConstraintSafeChars, "%s contains control characters", c.field)
+		}
+		if !options.IsManaged() {
+			errs.add(c.field, c.command, ConstraintNotAllowed, "%s requires a managed instance, llamactl does not start external backends", c.field)
+		}
+	}
+
+	return errs.err()
+}
+
+// commandNotAllowed is the error of a field running a command on the llamactl host while
+// allow_instance_commands is disabled
+func commandNotAllowed(field string, value any) error {
+	return fieldError(field, value, ConstraintNotAllowed, "%s is not allowed unless allow_instance_commands is enabled", field)
+}
+
+// ValidateBindInterface validates the interface a backend is restricted to. The interface
+// itself is looked up when the instance starts, since interfaces come and go.
+func ValidateBindInterface(options *instance.CreateInstanceOptions) error {
+	if options == nil || options.BindInterface == "" {
+		return nil
+	}
+
+	if controlCharsPattern.MatchString(options.BindInterface) {
+		return fieldError("bind_interface", options.BindInterface, ConstraintSafeChars, "bind_interface contains control characters")
+	}
+	if !options.IsManaged() {
+		return fieldError("bind_interface", options.BindInterface, ConstraintNotAllowed, "bind_interface requires a managed instance, an external backend chooses its own address")
+	}
+
+	return nil
+}
+
+// ValidateBackendConnections validates the connection cap of an instance proxy and what
+// happens to requests at the cap
+func ValidateBackendConnections(options *instance.CreateInstanceOptions) error {
+	if options == nil {
+		return nil
+	}
+	errs := &ValidationError{}
+
+	switch options.BackendConnectionPolicy {
+	case "", instance.ConnectionPolicyBlock, instance.ConnectionPolicyFail:
+	default:
+		errs.add("backend_connection_policy", options.BackendConnectionPolicy, ConstraintOneOf, "invalid backend_connection_policy %q: must be %s or %s", options.BackendConnectionPolicy, instance.ConnectionPolicyBlock, instance.ConnectionPolicyFail)
+	}
+
+	if options.MaxBackendConnections != nil && options.MinIdleBackendConnections != nil &&
+		*options.MaxBackendConnections > 0 && *options.MinIdleBackendConnections > *options.MaxBackendConnections {
+		errs.add("min_idle_backend_connections", *options.MinIdleBackendConnections, ConstraintConflict, "min_idle_backend_connections %d exceeds max_backend_connections %d", *options.MinIdleBackendConnections, *options.MaxBackendConnections)
+	}
+
+	return errs.err()
+}
+
+// ValidateSlotPreservation validates preserve_slots, which relies on the slot API of
+// llama-server and on llamactl restarting the backend
+func ValidateSlotPreservation(options *instance.CreateInstanceOptions) error {
+	if options == nil || options.PreserveSlots == nil || !*options.PreserveSlots {
+		return nil
+	}
+	errs := &ValidationError{}
+
+	if options.BackendType != backends.BackendTypeLlamaCpp {
+		errs.add("preserve_slots", true, ConstraintNotAllowed, "preserve_slots requires the llama_cpp backend")
+	}
+	if !options.IsManaged() {
+		errs.add("preserve_slots", true, ConstraintNotAllowed, "preserve_slots requires a managed instance, llamactl does not restart external backends")
+	}
+	if options.LlamaServerOptions != nil && options.LlamaServerOptions.NoSlots {
+		errs.add("backend_options.no_slots", true, ConstraintConflict, "preserve_slots needs the slots endpoint that no_slots disables")
+	}
+
+	return errs.err()
+}
+
+// ValidateManagedBackendKey validates managed_backend_key, which starts a llama.cpp backend
+// with a key llamactl generates in place of the api_key options
+func ValidateManagedBackendKey(options *instance.CreateInstanceOptions) error {
+	if options == nil || options.ManagedBackendKey == nil || !*options.ManagedBackendKey {
+		return nil
+	}
+	errs := &ValidationError{}
+
+	if options.BackendType != backends.BackendTypeLlamaCpp {
+		errs.add("managed_backend_key", true, ConstraintNotAllowed, "managed_backend_key requires the llama_cpp backend")
+	}
+	if !options.IsManaged() {
+		errs.add("managed_backend_key", true, ConstraintNotAllowed, "managed_backend_key requires a managed instance, llamactl does not start external backends")
+	}
+	if llama := options.LlamaServerOptions; llama != nil {
+		if llama.APIKey != "" {
+			errs.add("backend_options.api_key", nil, ConstraintConflict, "api_key cannot be set with managed_backend_key, llamactl generates the key")
+		}
+		if llama.APIKeyFile != "" {
+			errs.add("backend_options.api_key_file", llama.APIKeyFile, ConstraintConflict, "api_key_file cannot be set with managed_backend_key, llamactl generates the key")
+		}
+	}
+
+	return errs.err()
+}
+
+// ValidateSLO validates the service level objectives of an instance
+func ValidateSLO(options *instance.CreateInstanceOptions) error {
+	if options == nil || options.SLO == nil {
+		return nil
+	}
+	slo := options.SLO
+	errs := &ValidationError{}
+
+	if !slo.HasObjectives() {
+		errs.add("slo", nil, ConstraintRequired, "slo requires latency_p95_ms or error_rate")
+	}
+	if slo.LatencyP95Ms < 0 {
+		errs.add("slo.latency_p95_ms", slo.LatencyP95Ms, ConstraintRange, "slo.latency_p95_ms cannot be negative")
+	}
+	if slo.ErrorRate < 0 || slo.ErrorRate >= 1 {
+		errs.add("slo.error_rate", slo.ErrorRate, ConstraintRange, "slo.error_rate must be a fraction between 0 and 1")
+	}
+	if slo.Window < 0 || slo.Window.Duration() > instance.MaxSLOWindow {
+		errs.add("slo.window", slo.Window.String(), ConstraintRange, "slo.window must be between 0 and %s", config.Duration(instance.MaxSLOWindow))
+	}
+	if slo.BurnRateThreshold != 0 && slo.BurnRateThreshold < 1 {
+		errs.add("slo.burn_rate_threshold", slo.BurnRateThreshold, ConstraintRange, "slo.burn_rate_threshold must be at least 1")
+	}
+	if slo.MinRequests < 0 {
+		errs.add("slo.min_requests", slo.MinRequests, ConstraintRange, "slo.min_requests cannot be negative")
+	}
+	if slo.Warmup < 0 {
+		errs.add("slo.warmup", slo.Warmup.String(), ConstraintRange, "slo.warmup cannot be negative")
+	}
+
+	return errs.err()
+}
+
+// ValidateHealthCheck validates the liveness checks of an instance, which restart the
+// backend when it keeps failing them
+func ValidateHealthCheck(options *instance.CreateInstanceOptions) error {
+	if options == nil || options.HealthCheck == nil {
+		return nil
+	}
+	check := options.HealthCheck
+	errs := &ValidationError{}
+
+	if !options.IsManaged() {
+		errs.add("health_check", nil, ConstraintNotAllowed, "health_check requires a managed instance, llamactl does not restart external backends")
+	}
+	if check.Interval < 0 {
+		errs.add("health_check.interval", check.Interval.String(), ConstraintRange, "health_check.interval cannot be negative")
+	}
+	if check.Timeout < 0 {
+		errs.add("health_check.timeout", check.Timeout.String(), ConstraintRange, "health_check.timeout cannot be negative")
+	}
+	if check.FailureThreshold < 0 {
+		errs.add("health_check.failure_threshold", check.FailureThreshold, ConstraintRange, "health_check.failure_threshold cannot be negative")
+	}
+
+	return errs.err()
+}
+
+// ValidateLBHealth validates the load balancer health thresholds of an instance
+func ValidateLBHealth(options *instance.CreateInstanceOptions) error {
+	if options == nil || options.LBHealth == nil {
+		return nil
+	}
+	lbHealth := options.LBHealth
+	errs := &ValidationError{}
+
+	if lbHealth.QueueThreshold < 0 {
+		errs.add("lb_health.queue_threshold", lbHealth.QueueThreshold, ConstraintRange, "lb_health.queue_threshold cannot be negative")
+	}
+	if lbHealth.InFlightThreshold < 0 {
+		errs.add("lb_health.in_flight_threshold", lbHealth.InFlightThreshold, ConstraintRange, "lb_health.in_flight_threshold cannot be negative")
+	}
+
+	return errs.err()
+}
+
+// ValidateTransform validates the transform of an instance, creating it to check its params
+func ValidateTransform(options *instance.CreateInstanceOptions) error {
+	if options == nil || options.Transform == nil {
+		return nil
+	}
+	opts := options.Transform
+	errs := &ValidationError{}
+
+	if opts.Name == "" {
+		errs.add("transform.name", nil, ConstraintRequired, "transform requires a name, one of %v", transform.Names())
+	} else if _, err := transform.New(opts.Name, opts.Params); errors.Is(err, transform.ErrUnknown) {
+		errs.add("transform.name", opts.Name, ConstraintOneOf, "%v", err)
+	} else if err != nil {
+		errs.add("transform.params", nil, ConstraintFormat, "invalid params of transform %s: %v", opts.Name, err)
+	}
+	switch opts.FailMode {
+	case "", instance.TransformFailOpen, instance.TransformFailClosed:
+	default:
+		errs.add("transform.fail_mode", opts.FailMode, ConstraintOneOf, "invalid transform.fail_mode %q: must be %s or %s", opts.FailMode, instance.TransformFailOpen, instance.TransformFailClosed)
 	}
+	if opts.Timeout < 0 || opts.Timeout.Duration() > instance.MaxTransformTimeout {
+		errs.add("transform.timeout", opts.Timeout.String(), ConstraintRange, "transform.timeout must be between 0 and %s", config.Duration(instance.MaxTransformTimeout))
+	}
+
+	return errs.err()
+}
+
+// ValidateModelChange validates what is done when the model files of an instance change
+func ValidateModelChange(options *instance.CreateInstanceOptions) error {
+	if options == nil {
+		return nil
+	}
+
+	switch options.OnModelChange {
+	case "", instance.ModelChangeFlag:
+	case instance.ModelChangeRestart:
+		if !options.IsManaged() {
+			return fieldError("on_model_change", options.OnModelChange, ConstraintNotAllowed, "on_model_change %s requires a managed instance, llamactl does not restart external backends", instance.ModelChangeRestart)
+		}
+	default:
+		return fieldError("on_model_change", options.OnModelChange, ConstraintOneOf, "invalid on_model_change %q: must be %s or %s", options.OnModelChange, instance.ModelChangeFlag, instance.ModelChangeRestart)
+	}
+	return nil
+}
+
+// ValidateGPUFault validates what is done when the backend of an instance loses its GPU
+func ValidateGPUFault(options *instance.CreateInstanceOptions) error {
+	if options == nil {
+		return nil
+	}
+
+	switch options.OnGPUFault {
+	case "", instance.GPUFaultFlag:
+	case instance.GPUFaultRestart:
+		if !options.IsManaged() {
+			return fieldError("on_gpu_fault", options.OnGPUFault, ConstraintNotAllowed, "on_gpu_fault %s requires a managed instance, llamactl does not restart external backends", instance.GPUFaultRestart)
+		}
+	default:
+		return fieldError("on_gpu_fault", options.OnGPUFault, ConstraintOneOf, "invalid on_gpu_fault %q: must be %s or %s", options.OnGPUFault, instance.GPUFaultFlag, instance.GPUFaultRestart)
+	}
+	return nil
+}
+
+// ValidateAllowedPaths validates the paths the proxy forwards to the backend of an
+// instance: absolute paths or glob patterns
+func ValidateAllowedPaths(options *instance.CreateInstanceOptions) error {
+	if options == nil {
+		return nil
+	}
+	errs := &ValidationError{}
+
+	for i, entry := range options.AllowedPaths {
+		field := fmt.Sprintf("allowed_paths[%d]", i)
+		switch {
+		case !strings.HasPrefix(entry, "/"):
+			errs.add(field, entry, ConstraintFormat, "%s %q must start with /", field, entry)
+		case controlCharsPattern.MatchString(entry):
+			errs.add(field, entry, ConstraintSafeChars, "%s contains control characters", field)
+		default:
+			if _, err := path.Match(entry, "/"); err != nil {
+				errs.add(field, entry, ConstraintFormat, "%s %q is not a valid glob pattern: %v", field, entry, err)
+			}
+		}
+	}
+	return errs.err()
+}
+
+// ValidatePromptTemplates validates the prompt templates of an instance: named like
+// instances, within the size limits and parsing as templates
+func ValidatePromptTemplates(options *instance.CreateInstanceOptions) error {
+	if options == nil || len(options.PromptTemplates) == 0 {
+		return nil
+	}
+	if len(options.PromptTemplates) > instance.MaxPromptTemplates {
+		return fieldError("prompt_templates", len(options.PromptTemplates), ConstraintRange, "too many prompt templates (max %d)", instance.MaxPromptTemplates)
+	}
+	errs := &ValidationError{}
+
+	for _, name := range slices.Sorted(maps.Keys(options.PromptTemplates)) {
+		text := options.PromptTemplates[name]
+		field := "prompt_templates." + name
+		switch {
+		case !validNamePattern.MatchString(name) || len(name) > 50:
+			errs.add(field, name, ConstraintFormat, "prompt template name %q is invalid (up to 50 alphanumeric characters, hyphens and underscores)", name)
+		case text == "":
+			errs.add(field, nil, ConstraintRequired, "prompt template %s cannot be empty", name)
+		case len(text) > instance.MaxPromptTemplateSize:
+			errs.add(field, len(text), ConstraintMaxLength, "prompt template %s too long (max %d bytes)", name, instance.MaxPromptTemplateSize)
+		default:
+			if _, err := instance.ParsePromptTemplate(name, text); err != nil {
+				errs.add(field, text, ConstraintFormat, "prompt template %s is invalid: %v", name, err)
+			}
+		}
+	}
+	return errs.err()
+}
+
+// ValidateLogSanitize validates how the output of an instance is sanitized before it is logged
+func ValidateLogSanitize(options *instance.CreateInstanceOptions) error {
+	if options == nil {
+		return nil
+	}
+
+	switch options.LogSanitize {
+	case "", config.LogSanitizeOff, config.LogSanitizeStrip, config.LogSanitizeCollapse:
+		return nil
+	default:
+		return fieldError("log_sanitize", options.LogSanitize, ConstraintOneOf, "invalid log_sanitize %q: must be %s, %s or %s", options.LogSanitize, config.LogSanitizeOff, config.LogSanitizeStrip, config.LogSanitizeCollapse)
+	}
+}
+
+// ValidateSchedules validates the cron expressions an instance is started and stopped at
+func ValidateSchedules(options *instance.CreateInstanceOptions) error {
+	if options == nil || (options.StartSchedule == "" && options.StopSchedule == "") {
+		return nil
+	}
+	errs := &ValidationError{}
+
+	for _, schedule := range []struct{ field, expr string }{
+		{"start_schedule", options.StartSchedule},
+		{"stop_schedule", options.StopSchedule},
+	} {
+		if schedule.expr == "" {
+			continue
+		}
+		if !options.IsManaged() {
+			errs.add(schedule.field, schedule.expr, ConstraintNotAllowed, "%s requires a managed instance, llamactl does not start or stop external backends", schedule.field)
+			continue
+		}
+		parsed, err := cron.Parse(schedule.expr)
+		if err != nil {
+			errs.add(schedule.field, schedule.expr, ConstraintFormat, "invalid %s: %v", schedule.field, err)
+		} else if parsed.Next(time.Now()).IsZero() {
+			errs.add(schedule.field, schedule.expr, ConstraintFormat, "%s %q is never due", schedule.field, schedule.expr)
+		}
+	}
+	if options.StartSchedule != "" && strings.TrimSpace(options.StartSchedule) == strings.TrimSpace(options.StopSchedule) {
+		errs.add("stop_schedule", options.StopSchedule, ConstraintConflict, "stop_schedule cannot be the same as start_schedule")
+	}
+
+	return errs.err()
+}
+
+// ValidateHosts validates the address the backend listens on and the host llamactl
+// connects to. A wildcard address can be listened on but not connected to, and a host set
+// in the backend options must agree with bind_host, or connect_host for unmanaged instances.
+func ValidateHosts(options *instance.CreateInstanceOptions) error {
+	if options == nil {
+		return nil
+	}
+	errs := &ValidationError{}
+
+	hosts := []struct{ field, host string }{
+		{"bind_host", options.BindHost},
+		{"connect_host", options.ConnectHost},
+		{"backend_options.host", options.BackendHost()},
+	}
+	for _, h := range hosts {
+		if h.host != "" && net.ParseIP(h.host) == nil && !validHostnamePattern.MatchString(h.host) {
+			errs.add(h.field, h.host, ConstraintFormat, "invalid %s %q: must be an IP address or hostname without a port", h.field, h.host)
+		}
+	}
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(options.ConnectHost); ip != nil && ip.IsUnspecified() {
+		errs.add("connect_host", options.ConnectHost, ConstraintFormat, "connect_host %s is a wildcard address and cannot be connected to, set the address the backend is reachable on", options.ConnectHost)
+	}
+
+	backendHost := options.BackendHost()
+	if options.IsManaged() {
+		if backendHost != "" && options.BindHost != "" && backendHost != options.BindHost {
+			errs.add("backend_options.host", backendHost, ConstraintConflict, "backend_options.host %s conflicts with bind_host %s, set only bind_host", backendHost, options.BindHost)
+		}
+		return errs.err()
+	}
+
+	if options.BindHost != "" {
+		errs.add("bind_host", options.BindHost, ConstraintNotAllowed, "bind_host requires a managed instance, set connect_host to reach an external backend")
+	}
+	if ip := net.ParseIP(backendHost); backendHost != "" && (ip == nil || !ip.IsUnspecified()) && options.ConnectHost != "" && backendHost != options.ConnectHost {
+		errs.add("backend_options.host", backendHost, ConstraintConflict, "backend_options.host %s conflicts with connect_host %s, set only connect_host", backendHost, options.ConnectHost)
+	}
+	return errs.err()
 }
 
 // validateLlamaCppOptions validates llama.cpp specific options
 func validateLlamaCppOptions(options *instance.CreateInstanceOptions) error {
 	if options.LlamaServerOptions == nil {
-		return ValidationError(fmt.Errorf("llama server options cannot be nil for llama.cpp backend"))
+		return fieldError("backend_options", nil, ConstraintRequired, "llama server options cannot be nil for llama.cpp backend")
 	}
+	errs := &ValidationError{}
 
 	// Use reflection to check all string fields for injection patterns
-	if err := validateStructStrings(options.LlamaServerOptions, ""); err != nil {
-		return err
-	}
+	validateStructStrings(errs, options.LlamaServerOptions, "backend_options")
 
 	// Basic network validation for port
 	if options.LlamaServerOptions.Port < 0 || options.LlamaServerOptions.Port > 65535 {
-		return ValidationError(fmt.Errorf("invalid port range: %d", options.LlamaServerOptions.Port))
+		errs.add("backend_options.port", options.LlamaServerOptions.Port, ConstraintRange, "invalid port range: %d", options.LlamaServerOptions.Port)
 	}
 
-	return nil
+	return errs.err()
 }
 
 // validateMlxOptions validates MLX backend specific options
 func validateMlxOptions(options *instance.CreateInstanceOptions) error {
 	if options.MlxServerOptions == nil {
-		return ValidationError(fmt.Errorf("MLX server options cannot be nil for MLX backend"))
+		return fieldError("backend_options", nil, ConstraintRequired, "MLX server options cannot be nil for MLX backend")
 	}
+	errs := &ValidationError{}
 
-	if err := validateStructStrings(options.MlxServerOptions, ""); err != nil {
-		return err
-	}
+	validateStructStrings(errs, options.MlxServerOptions, "backend_options")
 
 	// Basic network validation for port
 	if options.MlxServerOptions.Port < 0 || options.MlxServerOptions.Port > 65535 {
-		return ValidationError(fmt.Errorf("invalid port range: %d", options.MlxServerOptions.Port))
+		errs.add("backend_options.port", options.MlxServerOptions.Port, ConstraintRange, "invalid port range: %d", options.MlxServerOptions.Port)
 	}
 
-	return nil
+	return errs.err()
 }
 
 // validateVllmOptions validates vLLM backend specific options
 func validateVllmOptions(options *instance.CreateInstanceOptions) error {
 	if options.VllmServerOptions == nil {
-		return ValidationError(fmt.Errorf("vLLM server options cannot be nil for vLLM backend"))
+		return fieldError("backend_options", nil, ConstraintRequired, "vLLM server options cannot be nil for vLLM backend")
 	}
+	errs := &ValidationError{}
 
 	// Use reflection to check all string fields for injection patterns
-	if err := validateStructStrings(options.VllmServerOptions, ""); err != nil {
-		return err
-	}
+	validateStructStrings(errs, options.VllmServerOptions, "backend_options")
 
 	// Basic network validation for port
 	if options.VllmServerOptions.Port < 0 || options.VllmServerOptions.Port > 65535 {
-		return ValidationError(fmt.Errorf("invalid port range: %d", options.VllmServerOptions.Port))
+		errs.add("backend_options.port", options.VllmServerOptions.Port, ConstraintRange, "invalid port range: %d", options.VllmServerOptions.Port)
 	}
 
-	return nil
+	return errs.err()
 }
 
-// validateStructStrings recursively validates all string fields in a struct
-func validateStructStrings(v any, fieldPath string) error {
+// validateStructStrings recursively validates all string fields in a struct, recording
+// each invalid one under its JSON path
+func validateStructStrings(errs *ValidationError, v any, fieldPath string) {
 	val := reflect.ValueOf(v)
 	if val.Kind() == reflect.Ptr {
 		val = val.Elem()
 	}
 
 	if val.Kind() != reflect.Struct {
-		return nil
+		return
 	}
 
 	typ := val.Type()
@@ -129,46 +656,58 @@ func validateStructStrings(v any, fieldPath string) error {
 			continue
 		}
 
-		fieldName := fieldType.Name
-		if fieldPath != "" {
+		fieldName := jsonFieldName(fieldType)
+		switch {
+		case fieldName == "-":
+			continue
+		case fieldType.Anonymous && fieldType.Tag.Get("json") == "":
+			// Fields of an embedded struct are inlined in JSON
+			fieldName = fieldPath
+		case fieldPath != "":
 			fieldName = fieldPath + "." + fieldName
 		}
 
 		switch field.Kind() {
 		case reflect.String:
 			if err := validateStringForInjection(field.String()); err != nil {
-				return ValidationError(fmt.Errorf("field %s: %w", fieldName, err))
+				errs.add(fieldName, field.String(), ConstraintSafeChars, "field %s: %v", fieldName, err)
 			}
 
 		case reflect.Slice:
 			if field.Type().Elem().Kind() == reflect.String {
 				for j := 0; j < field.Len(); j++ {
 					if err := validateStringForInjection(field.Index(j).String()); err != nil {
-						return ValidationError(fmt.Errorf("field %s[%d]: %w", fieldName, j, err))
+						elemName := fmt.Sprintf("%s[%d]", fieldName, j)
+						errs.add(elemName, field.Index(j).String(), ConstraintSafeChars, "field %s: %v", elemName, err)
 					}
 				}
 			}
 
 		case reflect.Struct:
-			if err := validateStructStrings(field.Interface(), fieldName); err != nil {
-				return err
-			}
+			validateStructStrings(errs, field.Interface(), fieldName)
 		}
 	}
+}
 
-	return nil
+// jsonFieldName returns the name a struct field has in JSON
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
 }
 
 func ValidateInstanceName(name string) (string, error) {
 	// Validate instance name
 	if name == "" {
-		return "", ValidationError(fmt.Errorf("name cannot be empty"))
+		return "", fieldError("name", nil, ConstraintRequired, "name cannot be empty")
 	}
 	if !validNamePattern.MatchString(name) {
-		return "", ValidationError(fmt.Errorf("name contains invalid characters (only alphanumeric, hyphens, underscores allowed)"))
+		return "", fieldError("name", name, ConstraintFormat, "name contains invalid characters (only alphanumeric, hyphens, underscores allowed)")
 	}
 	if len(name) > 50 {
-		return "", ValidationError(fmt.Errorf("name too long (max 50 characters)"))
+		return "", fieldError("name", name, ConstraintMaxLength, "name too long (max 50 characters)")
 	}
 	return name, nil
 }
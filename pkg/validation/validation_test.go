@@ -1,13 +1,19 @@
 package validation_test
 
 import (
+	"errors"
+	"fmt"
 	"llamactl/pkg/backends"
 	"llamactl/pkg/backends/llamacpp"
+	"llamactl/pkg/config"
 	"llamactl/pkg/instance"
 	"llamactl/pkg/testutil"
 	"llamactl/pkg/validation"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidateInstanceName(t *testing.T) {
@@ -250,7 +256,7 @@ func TestValidateInstanceOptions_NonStringFields(t *testing.T) {
 	options := &instance.CreateInstanceOptions{
 		AutoRestart:  testutil.BoolPtr(true),
 		MaxRestarts:  testutil.IntPtr(5),
-		RestartDelay: testutil.IntPtr(10),
+		RestartDelay: testutil.DurationPtr(10 * time.Second),
 		BackendType:  backends.BackendTypeLlamaCpp,
 		LlamaServerOptions: &llamacpp.LlamaServerOptions{
 			Port:        8080,
@@ -269,3 +275,582 @@ func TestValidateInstanceOptions_NonStringFields(t *testing.T) {
 		t.Errorf("ValidateInstanceOptions with non-string fields should not error, got: %v", err)
 	}
 }
+
+func TestValidateBackendTLS(t *testing.T) {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, []byte("placeholder"), 0644); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		tls           *instance.BackendTLSOptions
+		allowInsecure bool
+		wantErr       bool
+	}{
+		{"no TLS options", nil, false, false},
+		{"https with system roots", &instance.BackendTLSOptions{Scheme: "https"}, false, false},
+		{"https with CA bundle", &instance.BackendTLSOptions{CAFile: caFile}, false, false},
+		{"invalid scheme", &instance.BackendTLSOptions{Scheme: "ftp"}, false, true},
+		{"TLS files with http scheme", &instance.BackendTLSOptions{Scheme: "http", CAFile: caFile}, false, true},
+		{"insecure without config flag", &instance.BackendTLSOptions{InsecureSkipVerify: true}, false, true},
+		{"insecure with config flag", &instance.BackendTLSOptions{InsecureSkipVerify: true}, true, false},
+		{"cert without key", &instance.BackendTLSOptions{CertFile: caFile}, false, true},
+		{"missing CA file", &instance.BackendTLSOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{
+				BackendType: backends.BackendTypeLlamaCpp,
+				LlamaServerOptions: &llamacpp.LlamaServerOptions{
+					Model: "/path/to/model.gguf",
+				},
+				BackendTLS: tt.tls,
+			}
+
+			err := validation.ValidateBackendTLS(options, tt.allowInsecure)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBackendTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLaunchWrapper(t *testing.T) {
+	tests := []struct {
+		name    string
+		wrapper []string
+		wantErr bool
+	}{
+		{"no wrapper", nil, false},
+		{"existing binary", []string{"sh", "-c", `exec "$0" "$@"`}, false},
+		{"absolute path", []string{os.Args[0]}, false},
+		{"missing binary", []string{"definitely-not-a-real-wrapper"}, true},
+		{"empty binary", []string{""}, true},
+		{"control characters", []string{"sh", "-c", "echo\nreboot"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{
+				BackendType:   backends.BackendTypeLlamaCpp,
+				LaunchWrapper: tt.wrapper,
+			}
+			err := validation.ValidateLaunchWrapper(options, true)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLaunchWrapper() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// A wrapper runs a command on the llamactl host, only accepted when allowed
+	options := &instance.CreateInstanceOptions{BackendType: backends.BackendTypeLlamaCpp, LaunchWrapper: []string{"sh"}}
+	assertConstraint(t, validation.ValidateLaunchWrapper(options, false), "launch_wrapper", validation.ConstraintNotAllowed)
+}
+
+// assertConstraint checks that err is a validation error of field with constraint only
+func assertConstraint(t *testing.T, err error, field, constraint string) {
+	t.Helper()
+	var validationErr *validation.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ValidationError of %s, got %v", field, err)
+	}
+	if len(validationErr.Errors) != 1 || validationErr.Errors[0].Field != field || validationErr.Errors[0].Constraint != constraint {
+		t.Errorf("Expected %s to fail with %s, got %+v", field, constraint, validationErr.Errors)
+	}
+}
+
+func TestValidateLlamaExecutable(t *testing.T) {
+	tests := []struct {
+		name        string
+		backendType backends.BackendType
+		executable  string
+		wantErr     bool
+	}{
+		{"not set", backends.BackendTypeVllm, "", false},
+		{"absolute path", backends.BackendTypeLlamaCpp, "/opt/llama.cpp-vulkan/bin/llama-server", false},
+		// Checked when the instance starts
+		{"missing binary", backends.BackendTypeLlamaCpp, "definitely-not-a-real-llama-server", false},
+		{"other backend", backends.BackendTypeVllm, "/opt/llama.cpp/bin/llama-server", true},
+		{"control characters", backends.BackendTypeLlamaCpp, "llama-server\nreboot", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{
+				BackendType:     tt.backendType,
+				LlamaExecutable: tt.executable,
+			}
+			err := validation.ValidateLlamaExecutable(options, true)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLlamaExecutable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	options := &instance.CreateInstanceOptions{BackendType: backends.BackendTypeLlamaCpp, LlamaExecutable: "/opt/llama.cpp/bin/llama-server"}
+	assertConstraint(t, validation.ValidateLlamaExecutable(options, false), "llama_executable", validation.ConstraintNotAllowed)
+}
+
+func TestValidateLifecycleCommands(t *testing.T) {
+	unmanaged := false
+	tests := []struct {
+		name    string
+		onStart string
+		onStop  string
+		managed *bool
+		wantErr bool
+	}{
+		{"no commands", "", "", nil, false},
+		{"shell commands", "ln -sf /models/a.gguf /mnt/nvme/ && echo $LLAMACTL_INSTANCE_NAME", "rm -rf /mnt/nvme/*", nil, false},
+		{"multiline script", "set -e\n\tcp /models/a.gguf /mnt/nvme/", "", nil, false},
+		{"control characters", "echo\x00reboot", "", nil, true},
+		{"unmanaged instance", "", "true", &unmanaged, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{
+				BackendType: backends.BackendTypeLlamaCpp,
+				OnStartCmd:  tt.onStart,
+				OnStopCmd:   tt.onStop,
+				Managed:     tt.managed,
+			}
+			err := validation.ValidateLifecycleCommands(options, true)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLifecycleCommands() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	options := &instance.CreateInstanceOptions{BackendType: backends.BackendTypeLlamaCpp, OnStopCmd: "true"}
+	assertConstraint(t, validation.ValidateLifecycleCommands(options, false), "on_stop_cmd", validation.ConstraintNotAllowed)
+}
+
+func TestValidateBindInterface(t *testing.T) {
+	unmanaged := false
+	tests := []struct {
+		name          string
+		bindInterface string
+		managed       *bool
+		wantErr       bool
+	}{
+		{"no interface", "", nil, false},
+		{"interface name", "eth0", nil, false},
+		{"interface address", "10.0.0.5", nil, false},
+		{"control characters", "eth0\n", nil, true},
+		{"unmanaged instance", "eth0", &unmanaged, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{
+				BackendType:   backends.BackendTypeLlamaCpp,
+				BindInterface: tt.bindInterface,
+				Managed:       tt.managed,
+			}
+			err := validation.ValidateBindInterface(options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBindInterface() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSlotPreservation(t *testing.T) {
+	enabled, disabled, unmanaged := true, false, false
+	tests := []struct {
+		name        string
+		backendType backends.BackendType
+		preserve    *bool
+		managed     *bool
+		noSlots     bool
+		wantErr     bool
+	}{
+		{"not set", backends.BackendTypeVllm, nil, nil, false, false},
+		{"disabled", backends.BackendTypeVllm, &disabled, nil, false, false},
+		{"llama.cpp", backends.BackendTypeLlamaCpp, &enabled, nil, false, false},
+		{"other backend", backends.BackendTypeMlxLm, &enabled, nil, false, true},
+		{"unmanaged instance", backends.BackendTypeLlamaCpp, &enabled, &unmanaged, false, true},
+		{"slots endpoint disabled", backends.BackendTypeLlamaCpp, &enabled, nil, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{
+				BackendType:   tt.backendType,
+				PreserveSlots: tt.preserve,
+				Managed:       tt.managed,
+			}
+			if tt.backendType == backends.BackendTypeLlamaCpp {
+				options.LlamaServerOptions = &llamacpp.LlamaServerOptions{Model: "/m.gguf", NoSlots: tt.noSlots}
+			}
+			err := validation.ValidateSlotPreservation(options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSlotPreservation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateBackendConnections(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	tests := []struct {
+		name    string
+		max     *int
+		minIdle *int
+		policy  string
+		wantErr bool
+	}{
+		{"defaults", nil, nil, "", false},
+		{"block policy", intPtr(4), nil, "block", false},
+		{"fail policy", intPtr(4), intPtr(2), "fail", false},
+		{"unknown policy", intPtr(4), nil, "queue", true},
+		{"floor above cap", intPtr(2), intPtr(3), "", true},
+		{"floor without cap", intPtr(0), intPtr(3), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{
+				BackendType:               backends.BackendTypeLlamaCpp,
+				MaxBackendConnections:     tt.max,
+				MinIdleBackendConnections: tt.minIdle,
+				BackendConnectionPolicy:   tt.policy,
+			}
+			err := validation.ValidateBackendConnections(options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBackendConnections() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLogSanitize(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{"off", false},
+		{"strip", false},
+		{"collapse", false},
+		{"raw", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			err := validation.ValidateLogSanitize(&instance.CreateInstanceOptions{LogSanitize: tt.mode})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLogSanitize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSchedules(t *testing.T) {
+	unmanaged := false
+	tests := []struct {
+		name    string
+		start   string
+		stop    string
+		managed *bool
+		wantErr bool
+	}{
+		{"none", "", "", nil, false},
+		{"work hours", "0 9 * * mon-fri", "0 18 * * mon-fri", nil, false},
+		{"stop only", "", "@midnight", nil, false},
+		{"invalid", "0 25 * * *", "", nil, true},
+		{"never due", "", "0 0 30 2 *", nil, true},
+		{"same", "0 9 * * *", "0 9 * * *", nil, true},
+		{"unmanaged", "0 9 * * *", "", &unmanaged, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateSchedules(&instance.CreateInstanceOptions{StartSchedule: tt.start, StopSchedule: tt.stop, Managed: tt.managed})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSchedules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateModelChange(t *testing.T) {
+	unmanaged := false
+	tests := []struct {
+		name    string
+		policy  string
+		managed *bool
+		wantErr bool
+	}{
+		{"default", "", nil, false},
+		{"flag", "flag", nil, false},
+		{"restart", "restart", nil, false},
+		{"flag unmanaged", "flag", &unmanaged, false},
+		{"restart unmanaged", "restart", &unmanaged, true},
+		{"unknown", "reload", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateModelChange(&instance.CreateInstanceOptions{OnModelChange: tt.policy, Managed: tt.managed})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateModelChange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateHosts(t *testing.T) {
+	unmanaged := false
+	tests := []struct {
+		name        string
+		bindHost    string
+		connectHost string
+		backendHost string
+		managed     *bool
+		wantErr     bool
+	}{
+		{"no hosts", "", "", "", nil, false},
+		{"wildcard bind host", "0.0.0.0", "", "", nil, false},
+		{"IPv6 wildcard bind host", "::", "", "", nil, false},
+		{"bind and connect hosts", "0.0.0.0", "backend.internal", "", nil, false},
+		{"matching backend host", "10.0.0.5", "", "10.0.0.5", nil, false},
+		{"conflicting backend host", "10.0.0.5", "", "0.0.0.0", nil, true},
+		{"wildcard connect host", "", "0.0.0.0", "", nil, true},
+		{"IPv6 wildcard connect host", "", "::", "", nil, true},
+		{"host with port", "127.0.0.1:8080", "", "", nil, true},
+		{"host with scheme", "", "http://backend", "", nil, true},
+		{"invalid backend host", "", "", "backend/v1", nil, true},
+		{"unmanaged connect host", "", "backend.internal", "", &unmanaged, false},
+		{"unmanaged backend host", "", "", "backend.internal", &unmanaged, false},
+		{"unmanaged wildcard backend host", "", "backend.internal", "0.0.0.0", &unmanaged, false},
+		{"unmanaged conflicting backend host", "", "backend.internal", "10.0.0.5", &unmanaged, true},
+		{"unmanaged bind host", "0.0.0.0", "", "", &unmanaged, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &instance.CreateInstanceOptions{
+				BackendType:        backends.BackendTypeLlamaCpp,
+				BindHost:           tt.bindHost,
+				ConnectHost:        tt.connectHost,
+				Managed:            tt.managed,
+				LlamaServerOptions: &llamacpp.LlamaServerOptions{Host: tt.backendHost},
+			}
+			err := validation.ValidateHosts(options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateHosts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidationError_Fields(t *testing.T) {
+	options := &instance.CreateInstanceOptions{
+		BackendType: backends.BackendTypeLlamaCpp,
+		BindHost:    "127.0.0.1:8080",
+		LlamaServerOptions: &llamacpp.LlamaServerOptions{
+			Model: "/models/model.gguf; rm -rf /",
+			Port:  70000,
+			Lora:  []string{"/loras/a.gguf", "$(whoami)"},
+		},
+	}
+	err := validation.Join(validation.ValidateInstanceOptions(options), validation.ValidateHosts(options))
+
+	// The error is still found once wrapped
+	var validationErr *validation.ValidationError
+	if !errors.As(fmt.Errorf("failed to create instance: %w", err), &validationErr) {
+		t.Fatalf("Expected a *ValidationError, got %T: %v", err, err)
+	}
+
+	want := map[string]string{
+		"backend_options.model":   validation.ConstraintSafeChars,
+		"backend_options.lora[1]": validation.ConstraintSafeChars,
+		"backend_options.port":    validation.ConstraintRange,
+		"bind_host":               validation.ConstraintFormat,
+	}
+	if len(validationErr.Errors) != len(want) {
+		t.Fatalf("Expected %d invalid fields, got %+v", len(want), validationErr.Errors)
+	}
+	for _, fieldErr := range validationErr.Errors {
+		if constraint, ok := want[fieldErr.Field]; !ok || constraint != fieldErr.Constraint {
+			t.Errorf("Unexpected field error %+v", fieldErr)
+		}
+		if fieldErr.Message == "" || !strings.Contains(err.Error(), fieldErr.Message) {
+			t.Errorf("Expected the message of %s in %q", fieldErr.Field, err.Error())
+		}
+	}
+	if validationErr.Errors[2].Value != 70000 {
+		t.Errorf("Expected the invalid port to be reported, got %v", validationErr.Errors[2].Value)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if err := validation.Join(nil, nil); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	other := errors.New("storage unavailable")
+	if err := validation.Join(validation.ValidateInstanceOptions(nil), other); err != other {
+		t.Errorf("Expected an error that is not a validation error to be returned as is, got %v", err)
+	}
+}
+
+func TestValidateSLO(t *testing.T) {
+	tests := []struct {
+		name    string
+		slo     *instance.SLOOptions
+		wantErr bool
+	}{
+		{"not set", nil, false},
+		{"latency objective", &instance.SLOOptions{LatencyP95Ms: 2000}, false},
+		{"error rate objective", &instance.SLOOptions{ErrorRate: 0.01, Window: config.Duration(10 * time.Minute), BurnRateThreshold: 14.4, MinRequests: 50, Warmup: config.Duration(30 * time.Second)}, false},
+		{"no objective", &instance.SLOOptions{Window: config.Duration(10 * time.Minute)}, true},
+		{"negative latency", &instance.SLOOptions{LatencyP95Ms: -1, ErrorRate: 0.01}, true},
+		{"error rate of one", &instance.SLOOptions{ErrorRate: 1}, true},
+		{"window too long", &instance.SLOOptions{ErrorRate: 0.01, Window: config.Duration(2 * time.Hour)}, true},
+		{"burn rate below one", &instance.SLOOptions{ErrorRate: 0.01, BurnRateThreshold: 0.5}, true},
+		{"negative min requests", &instance.SLOOptions{ErrorRate: 0.01, MinRequests: -1}, true},
+		{"negative warmup", &instance.SLOOptions{ErrorRate: 0.01, Warmup: config.Duration(-time.Second)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateSLO(&instance.CreateInstanceOptions{SLO: tt.slo})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSLO() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateHealthCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		managed bool
+		check   *instance.HealthCheckOptions
+		wantErr bool
+	}{
+		{"not set", true, nil, false},
+		{"defaults", true, &instance.HealthCheckOptions{}, false},
+		{"set", true, &instance.HealthCheckOptions{Interval: config.Duration(10 * time.Second), Timeout: config.Duration(2 * time.Second), FailureThreshold: 5}, false},
+		{"unmanaged", false, &instance.HealthCheckOptions{}, true},
+		{"negative interval", true, &instance.HealthCheckOptions{Interval: config.Duration(-time.Second)}, true},
+		{"negative timeout", true, &instance.HealthCheckOptions{Timeout: config.Duration(-time.Second)}, true},
+		{"negative threshold", true, &instance.HealthCheckOptions{FailureThreshold: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateHealthCheck(&instance.CreateInstanceOptions{Managed: testutil.BoolPtr(tt.managed), HealthCheck: tt.check})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateHealthCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLBHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		lbHealth *instance.LBHealthOptions
+		wantErr  bool
+	}{
+		{"not set", nil, false},
+		{"defaults", &instance.LBHealthOptions{}, false},
+		{"set", &instance.LBHealthOptions{QueueThreshold: 4, InFlightThreshold: 8}, false},
+		{"negative queue threshold", &instance.LBHealthOptions{QueueThreshold: -1}, true},
+		{"negative in-flight threshold", &instance.LBHealthOptions{InFlightThreshold: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateLBHealth(&instance.CreateInstanceOptions{LBHealth: tt.lbHealth})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLBHealth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePromptTemplates(t *testing.T) {
+	tooMany := map[string]string{}
+	for i := range instance.MaxPromptTemplates + 1 {
+		tooMany[fmt.Sprintf("t%d", i)] = "{{.text}}"
+	}
+	tests := []struct {
+		name      string
+		templates map[string]string
+		wantErr   bool
+	}{
+		{"not set", nil, false},
+		{"valid", map[string]string{"summarize": "Summarize: {{.text}}", "qa_v2": "Q: {{.question}}\nA:"}, false},
+		{"invalid name", map[string]string{"sum marize": "{{.text}}"}, true},
+		{"empty", map[string]string{"summarize": ""}, true},
+		{"does not parse", map[string]string{"summarize": "{{.text"}, true},
+		{"too long", map[string]string{"summarize": strings.Repeat("x", instance.MaxPromptTemplateSize+1)}, true},
+		{"too many", tooMany, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidatePromptTemplates(&instance.CreateInstanceOptions{PromptTemplates: tt.templates})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePromptTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTransform(t *testing.T) {
+	prefix := map[string]any{"prefix": "Be brief."}
+	tests := []struct {
+		name      string
+		transform *instance.TransformOptions
+		wantErr   bool
+	}{
+		{"not set", nil, false},
+		{"builtin", &instance.TransformOptions{Name: "system_prompt_prefix", Params: prefix, Timeout: config.Duration(time.Second), FailMode: instance.TransformFailClosed}, false},
+		{"no name", &instance.TransformOptions{Params: prefix}, true},
+		{"unknown name", &instance.TransformOptions{Name: "starlark"}, true},
+		{"invalid params", &instance.TransformOptions{Name: "system_prompt_prefix"}, true},
+		{"invalid fail mode", &instance.TransformOptions{Name: "system_prompt_prefix", Params: prefix, FailMode: "ajar"}, true},
+		{"timeout too long", &instance.TransformOptions{Name: "system_prompt_prefix", Params: prefix, Timeout: config.Duration(time.Minute)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateTransform(&instance.CreateInstanceOptions{Transform: tt.transform})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTransform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAllowedPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		wantErr bool
+	}{
+		{"unset", nil, false},
+		{"prefixes", []string{"/v1/embeddings", "/health"}, false},
+		{"glob", []string{"/v1/*"}, false},
+		{"relative", []string{"v1/embeddings"}, true},
+		{"bad pattern", []string{"/v1/[embeddings"}, true},
+		{"control characters", []string{"/v1/\nembeddings"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validation.ValidateAllowedPaths(&instance.CreateInstanceOptions{AllowedPaths: tt.paths})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAllowedPaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
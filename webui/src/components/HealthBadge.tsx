@@ -1,14 +1,32 @@
 // ui/src/components/HealthBadge.tsx
-import React from "react";
+import React, { useEffect, useState } from "react";
 import { Badge } from "@/components/ui/badge";
 import type { HealthStatus } from "@/types/instance";
 import { CheckCircle, Loader2, XCircle } from "lucide-react";
 
 interface HealthBadgeProps {
   health?: HealthStatus;
+  nextRestartAt?: string; // when the pending automatic restart is due
 }
 
-const HealthBadge: React.FC<HealthBadgeProps> = ({ health }) => {
+// Seconds left until the given time, ticking every second, or undefined without one
+function useCountdown(until?: string): number | undefined {
+  const [now, setNow] = useState(() => Date.now());
+
+  useEffect(() => {
+    if (!until) return;
+    setNow(Date.now());
+    const timer = setInterval(() => setNow(Date.now()), 1000);
+    return () => clearInterval(timer);
+  }, [until]);
+
+  if (!until) return undefined;
+  return Math.max(0, Math.ceil((new Date(until).getTime() - now) / 1000));
+}
+
+const HealthBadge: React.FC<HealthBadgeProps> = ({ health, nextRestartAt }) => {
+  const restartIn = useCountdown(nextRestartAt);
+
   if (!health) {
     health = {
       status: "unknown", // Default to unknown if not provided
@@ -29,6 +47,10 @@ const HealthBadge: React.FC<HealthBadgeProps> = ({ health }) => {
         return <Loader2 className="h-3 w-3 animate-spin" />;
       case "failed":
         return <XCircle className="h-3 w-3" />;
+      case "queued":
+        return <Loader2 className="h-3 w-3" />;
+      case "restarting":
+        return <Loader2 className="h-3 w-3 animate-spin" />;
     }
   };
 
@@ -44,6 +66,10 @@ const HealthBadge: React.FC<HealthBadgeProps> = ({ health }) => {
         return "secondary";
       case "failed":
         return "destructive";
+      case "queued":
+        return "outline";
+      case "restarting":
+        return "outline";
     }
   };
 
@@ -59,6 +85,10 @@ const HealthBadge: React.FC<HealthBadgeProps> = ({ health }) => {
         return "Unknown";
       case "failed":
         return "Failed";
+      case "queued":
+        return "Queued";
+      case "restarting":
+        return restartIn !== undefined ? `Restarting in ${restartIn}s` : "Restarting";
     }
   };
 
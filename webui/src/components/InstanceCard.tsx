@@ -52,7 +52,8 @@ function InstanceCard({
     setIsLogsOpen(true);
   };
 
-  const running = instance.status === "running";
+  // A pending restart is stopped like a running process
+  const running = instance.status === "running" || instance.status === "starting" || instance.status === "restarting";
 
   return (
     <>
@@ -67,7 +68,7 @@ function InstanceCard({
             {/* Badges row */}
             <div className="flex items-center gap-2 flex-wrap">
               <BackendBadge backend={instance.options?.backend_type} docker={instance.docker_enabled} />
-              {running && <HealthBadge health={health} />}
+              {running && <HealthBadge health={health} nextRestartAt={instance.next_restart_at} />}
             </div>
           </div>
         </CardHeader>
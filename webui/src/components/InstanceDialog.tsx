@@ -153,7 +153,7 @@ const InstanceDialog: React.FC<InstanceDialogProps> = ({
   // Save button label logic
   let saveButtonLabel = "Create Instance";
   if (isEditing) {
-    if (instance?.status === "running") {
+    if (instance?.status === "running" || instance?.status === "starting") {
       saveButtonLabel = "Update & Restart Instance";
     } else {
       saveButtonLabel = "Update Instance";
@@ -267,7 +267,7 @@ afterEach(() => {
       
       // Set restart options
       await user.type(screen.getByLabelText(/Max Restarts/), '5')
-      await user.type(screen.getByLabelText(/Restart Delay/), '10')
+      await user.type(screen.getByLabelText(/Restart Delay/), '10s')
 
       await user.click(screen.getByTestId('dialog-save-button'))
 
@@ -275,7 +275,7 @@ afterEach(() => {
         auto_restart: true,
         backend_type: BackendType.LLAMA_CPP,
         max_restarts: 5,
-        restart_delay: 10
+        restart_delay: '10s'
       })
     })
   })
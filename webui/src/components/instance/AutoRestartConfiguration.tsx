@@ -2,6 +2,7 @@ import React from 'react'
 import type { CreateInstanceOptions } from '@/types/instance'
 import CheckboxInput from '@/components/form/CheckboxInput'
 import NumberInput from '@/components/form/NumberInput'
+import TextInput from '@/components/form/TextInput'
 
 interface AutoRestartConfigurationProps {
   formData: CreateInstanceOptions
@@ -36,13 +37,13 @@ const AutoRestartConfiguration: React.FC<AutoRestartConfigurationProps> = ({
             placeholder="3"
             description="Maximum number of restart attempts (0 = unlimited)"
           />
-          <NumberInput
+          <TextInput
             id="restart_delay"
-            label="Restart Delay (seconds)"
+            label="Restart Delay"
             value={formData.restart_delay}
             onChange={(value) => onChange('restart_delay', value)}
-            placeholder="5"
-            description="Delay in seconds before attempting restart"
+            placeholder="5s"
+            description="Delay before attempting restart, e.g. 5s or 1m"
           />
         </div>
       )}
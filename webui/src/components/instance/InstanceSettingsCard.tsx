@@ -4,7 +4,7 @@ import { Card, CardContent, CardHeader, CardTitle } from '@/components/ui/card'
 import { Label } from '@/components/ui/label'
 import { Input } from '@/components/ui/input'
 import AutoRestartConfiguration from '@/components/instance/AutoRestartConfiguration'
-import NumberInput from '@/components/form/NumberInput'
+import TextInput from '@/components/form/TextInput'
 import CheckboxInput from '@/components/form/CheckboxInput'
 import EnvironmentVariablesInput from '@/components/form/EnvironmentVariablesInput'
 
@@ -60,13 +60,13 @@ const InstanceSettingsCard: React.FC<InstanceSettingsCardProps> = ({
         <div className="space-y-4">
           <h3 className="text-lg font-medium">Basic Instance Options</h3>
 
-          <NumberInput
+          <TextInput
             id="idle_timeout"
-            label="Idle Timeout (minutes)"
+            label="Idle Timeout"
             value={formData.idle_timeout}
             onChange={(value) => onChange('idle_timeout', value)}
-            placeholder="30"
-            description="Minutes before stopping an idle instance"
+            placeholder="30m"
+            description="Time before stopping an idle instance, e.g. 30m or 2h"
           />
 
           <CheckboxInput
@@ -12,7 +12,7 @@ export function useInstanceHealth(instanceName: string, instanceStatus: Instance
       return
     }
 
-    if (instanceStatus === "failed") {
+    if (instanceStatus === "failed" || instanceStatus === "queued" || instanceStatus === "restarting") {
       setHealth({ status: instanceStatus, lastChecked: new Date() })
       return
     }
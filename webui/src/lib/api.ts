@@ -118,9 +118,9 @@ export const instancesApi = {
       body: JSON.stringify(options),
     }),
 
-  // PUT /instances/{name}
+  // PUT /instances/{name}?restart=true, applying the options to a running instance now
   update: (name: string, options: CreateInstanceOptions) =>
-    apiCall<Instance>(`/instances/${name}`, {
+    apiCall<Instance>(`/instances/${name}?restart=true`, {
       method: "PUT",
       body: JSON.stringify(options),
     }),
@@ -149,6 +149,24 @@ export const instancesApi = {
       method: "POST",
     }),
 
+  // POST /instances/{name}/reload, replacing the backend without downtime
+  reload: (name: string) =>
+    apiCall<Instance>(`/instances/${name}/reload`, {
+      method: "POST",
+    }),
+
+  // POST /instances/{name}/cancel-start
+  cancelStart: (name: string) =>
+    apiCall<Instance>(`/instances/${name}/cancel-start`, {
+      method: "POST",
+    }),
+
+  // POST /instances/{name}/retry
+  retry: (name: string) =>
+    apiCall<Instance>(`/instances/${name}/retry`, {
+      method: "POST",
+    }),
+
   // GET /instances/{name}/logs
   getLogs: (name: string, lines?: number) => {
     const params = lines ? `?lines=${lines}` : "";
@@ -5,7 +5,12 @@ export async function parseErrorResponse(response: Response): Promise<string> {
   let errorMessage = `HTTP ${response.status}`
 
   try {
-    const errorText = await response.text()
+    let errorText = await response.text()
+    // Validation errors are JSON, with a summary of the invalid fields
+    if (response.headers.get('content-type')?.includes('application/json')) {
+      const body = JSON.parse(errorText) as { error?: string }
+      errorText = body.error ?? errorText
+    }
     if (errorText) {
       errorMessage += `: ${errorText}`
     }
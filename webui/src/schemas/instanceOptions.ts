@@ -24,17 +24,130 @@ export const BackendOptionsSchema = z.union([
   VllmBackendOptionsSchema,
 ])
 
+// Durations are Go duration strings such as "90s" or "5m", or integer seconds
+// (integer minutes for idle_timeout)
+const DurationSchema = z.union([z.string(), z.number()])
+
 // Define the main create instance options schema
 export const CreateInstanceOptionsSchema = z.object({
   // Restart options
   auto_restart: z.boolean().optional(),
   max_restarts: z.number().optional(),
-  restart_delay: z.number().optional(),
-  idle_timeout: z.number().optional(),
+  restart_delay: DurationSchema.optional(),
+  // Random delay added to restart_delay: a percentage ("25%") or a duration
+  restart_jitter: DurationSchema.optional(),
+  // Double restart_delay on each consecutive crash, up to restart_backoff_max (default: 5m)
+  restart_backoff: z.boolean().optional(),
+  restart_backoff_max: DurationSchema.optional(),
+  // Time a run has to stay ready for the restart counter to start over (default: 10m)
+  restart_reset_after: DurationSchema.optional(),
+  // Time a run has to last for its crash not to count towards a crash loop (default: 0, disabled)
+  min_uptime_seconds: DurationSchema.optional(),
+  idle_timeout: DurationSchema.optional(),
+  // Cron expressions at which the instance is started and stopped, in the time zone of the server
+  start_schedule: z.string().optional(),
+  stop_schedule: z.string().optional(),
+  readiness_timeout: DurationSchema.optional(),
+  // Liveness checks of the running backend, restarted after failure_threshold failed checks
+  health_check: z.object({
+    interval: DurationSchema.optional(),
+    timeout: DurationSchema.optional(),
+    failure_threshold: z.number().optional(),
+  }).optional(),
+  // Queued and in-flight requests from which load balancers are told the instance is saturated
+  lb_health: z.object({
+    queue_threshold: z.number().optional(),
+    in_flight_threshold: z.number().optional(),
+  }).optional(),
+  stop_timeout: DurationSchema.optional(),
+  drain_timeout: DurationSchema.optional(),
   on_demand_start: z.boolean().optional(),
+  managed: z.boolean().optional(),
+
+  // Start a llama.cpp backend with an API key generated and kept by llamactl
+  managed_backend_key: z.boolean().optional(),
+
+  // Save and restore llama.cpp slots across restarts
+  preserve_slots: z.boolean().optional(),
+  slot_retention_hours: z.number().optional(),
+
+  // Sanitization of backend output before it is logged
+  log_sanitize: z.enum(['off', 'strip', 'collapse']).optional(),
+
+  // Weight of the share of the instance in the log disk quota
+  log_quota_weight: z.number().optional(),
+
+  // llama-server executable of a llama.cpp instance
+  llama_executable: z.string().optional(),
+
+  // Command prepended to the backend command line
+  launch_wrapper: z.array(z.string()).optional(),
+
+  // Shell commands run before the backend starts and after it exits
+  on_start_cmd: z.string().optional(),
+  on_stop_cmd: z.string().optional(),
+  hook_timeout: DurationSchema.optional(),
+
+  // Model downloaded from the control plane
+  remote_model: z.string().optional(),
+
+  // What is done when the model files change on disk
+  on_model_change: z.enum(['flag', 'restart']).optional(),
+
+  // What is done when the backend fails requests with GPU errors
+  on_gpu_fault: z.enum(['flag', 'restart']).optional(),
+
+  // Rewrite OpenAI responses to the strict OpenAI shape
+  normalize_responses: z.boolean().optional(),
+
+  // Transform rewriting proxied OpenAI requests and responses
+  transform: z.object({
+    name: z.string(),
+    params: z.record(z.string(), z.unknown()).optional(),
+    timeout: DurationSchema.optional(),
+    fail_mode: z.enum(['open', 'closed']).optional(),
+  }).optional(),
+
+  // Interface name or address the backend listens on
+  bind_interface: z.string().optional(),
+
+  // Address the backend listens on and host llamactl connects to
+  bind_host: z.string().optional(),
+  connect_host: z.string().optional(),
+
+  // Labels used to select instances
+  tags: z.array(z.string()).optional(),
+
+  // Paths the proxy forwards to the backend, updatable without a restart
+  allowed_paths: z.array(z.string()).optional(),
+
+  // Named prompts completed by /instances/{name}/generate, updatable without a restart
+  prompt_templates: z.record(z.string(), z.string()).optional(),
+
+  // Request admission
+  max_concurrent_requests: z.number().optional(),
+  max_queued_requests: z.number().optional(),
+  queue_timeout: DurationSchema.optional(),
+  max_request_duration: DurationSchema.optional(),
+
+  // Service level objectives, updatable without a restart
+  slo: z.object({
+    latency_p95_ms: z.number().optional(),
+    error_rate: z.number().optional(),
+    window: DurationSchema.optional(),
+    burn_rate_threshold: z.number().optional(),
+    min_requests: z.number().optional(),
+    warmup: DurationSchema.optional(),
+  }).optional(),
+
+  // Backend connections of the proxy
+  max_backend_connections: z.number().optional(),
+  backend_connection_policy: z.enum(['block', 'fail']).optional(),
+  min_idle_backend_connections: z.number().optional(),
 
   // Environment variables
   environment: z.record(z.string(), z.string()).optional(),
+  secret_environment: z.array(z.string()).optional(),
 
   // Backend configuration
   backend_type: z.enum([BackendType.LLAMA_CPP, BackendType.MLX_LM, BackendType.VLLM]).optional(),
@@ -11,17 +11,95 @@ export const BackendType = {
 
 export type BackendTypeValue = typeof BackendType[keyof typeof BackendType]
 
-export type InstanceStatus = 'running' | 'stopped' | 'failed'
+export type InstanceStatus = 'running' | 'stopped' | 'failed' | 'queued' | 'starting' | 'restarting'
 
 export interface HealthStatus {
-  status: 'ok' | 'loading' | 'error' | 'unknown' | 'failed'
+  status: 'ok' | 'loading' | 'error' | 'unknown' | 'failed' | 'queued' | 'restarting'
   message?: string
   lastChecked: Date
 }
 
+export interface LastExit {
+  exit_code: number; // -1 if killed by a signal
+  signal?: string;
+  reason: string;
+  message: string;
+  timestamp: string;
+  output: string[];
+  binary_replaced?: boolean; // the backend binary was replaced on disk while the process ran
+}
+
+export interface BinaryIdentity {
+  path: string;
+  size: number;
+  mod_time: string; // RFC3339
+  sha256: string;
+}
+
+export interface ScheduledAction {
+  action: "start" | "stop";
+  at: string; // RFC3339
+}
+
+export interface Health {
+  healthy: boolean;
+  last_check: string;
+  consecutive_failures: number;
+  last_error?: string;
+}
+
+export interface SettingMismatch {
+  setting: string; // backend option, e.g. ctx_size
+  requested: string;
+  effective: string; // as reported by the backend
+}
+
+export interface ConfigVerification {
+  status: 'verified' | 'mismatch' | 'unavailable';
+  checked?: string[];
+  mismatches?: SettingMismatch[];
+  message?: string;
+  time: string;
+}
+
+export type StartupPhase =
+  | 'unknown'
+  | 'loading_metadata'
+  | 'loading_tensors'
+  | 'creating_context'
+  | 'warming_up'
+  | 'starting_server'
+  | 'ready';
+
+export interface StartupProgress {
+  phase: StartupPhase;
+  percent?: number; // coarse, left out while the phase is unknown
+}
+
 export interface Instance {
   name: string;
   status: InstanceStatus;
   options?: CreateInstanceOptions;
   docker_enabled?: boolean; // indicates backend is running via Docker
+  executable?: string; // backend command the instance is started with
+  restarts?: number; // automatic restarts since the last manual start
+  rapid_failures?: number; // crashes in a row within min_uptime_seconds of starting
+  restarting?: boolean; // an automatic restart is pending until next_restart_at
+  next_restart_at?: string; // RFC3339
+  autorestart_paused?: boolean; // automatic restarts paused, by the instance or globally
+  autorestart_paused_until?: string; // RFC3339, unset while paused until resumed
+  started_at?: string; // start time of the running process (RFC3339)
+  uptime_seconds?: number;
+  last_exit?: LastExit; // most recent crash of the backend
+  allowed_paths?: string[]; // effective paths the proxy forwards, ["/"] for every path
+  health?: Health; // last liveness check, with a health_check
+  draining?: boolean; // stopping once its requests in flight completed, refusing new ones
+  in_flight?: number; // proxied requests in flight
+  last_request_at?: string; // last proxied request, health checks and probes left out (RFC3339)
+  binary?: BinaryIdentity; // backend binary the running process was launched from
+  next_scheduled_action?: ScheduledAction; // next transition of start_schedule or stop_schedule
+  config_verification?: ConfigVerification; // settings the backend reported once ready, compared with its options
+  startup_progress?: StartupProgress; // how far the backend got starting, while starting or running
+  pending_options?: CreateInstanceOptions; // set while running, applied on the next start
+  restart_required?: boolean;
 }
\ No newline at end of file